@@ -64,6 +64,7 @@ require (
 	gopkg.in/ini.v1 v1.52.0
 	gopkg.in/ldap.v2 v2.5.1
 	gopkg.in/macaron.v1 v1.3.4
+	gopkg.in/yaml.v2 v2.2.2
 	unknwon.dev/clog/v2 v2.1.1
 	xorm.io/builder v0.3.6
 	xorm.io/core v0.7.2
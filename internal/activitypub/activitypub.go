@@ -0,0 +1,208 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package activitypub implements documents and HTTP signatures of ActivityPub and
+// ForgeFed that are needed to federate users and repositories with other instances.
+package activitypub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// ContentType is the media type of ActivityPub documents.
+	ContentType = "application/activity+json"
+	// LDContentType is the alternative media type of ActivityPub documents.
+	LDContentType = `application/ld+json; profile="https://www.w3.org/ns/activitystreams"`
+	// JRDContentType is the media type of WebFinger documents.
+	JRDContentType = "application/jrd+json"
+
+	// PublicCollection is the special collection that addresses everyone.
+	PublicCollection = "https://www.w3.org/ns/activitystreams#Public"
+)
+
+// Context is the JSON-LD context of documents, which includes vocabularies of
+// public keys and ForgeFed.
+var Context = []string{
+	"https://www.w3.org/ns/activitystreams",
+	"https://w3id.org/security/v1",
+	"https://forgefed.org/ns",
+}
+
+// Types of actors.
+const (
+	TypePerson       = "Person"
+	TypeOrganization = "Organization"
+	TypeRepository   = "Repository" // ForgeFed
+)
+
+// Types of activities.
+const (
+	TypeAccept   = "Accept"
+	TypeAnnounce = "Announce"
+	TypeCreate   = "Create"
+	TypeFollow   = "Follow"
+	TypePush     = "Push" // ForgeFed
+	TypeUndo     = "Undo"
+)
+
+// IsActivityContentType returns true if given media type is of ActivityPub documents.
+func IsActivityContentType(contentType string) bool {
+	mediaType := strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0])
+	return mediaType == ContentType || mediaType == "application/ld+json"
+}
+
+// PublicKey is the public key of an actor to verify signatures of its requests.
+type PublicKey struct {
+	ID           string `json:"id"`
+	Owner        string `json:"owner"`
+	PublicKeyPem string `json:"publicKeyPem"`
+}
+
+// Image is an image attached to an object, e.g. the avatar of an actor.
+type Image struct {
+	Type      string `json:"type"`
+	MediaType string `json:"mediaType,omitempty"`
+	URL       string `json:"url"`
+}
+
+// Actor is a user, an organization or a repository.
+type Actor struct {
+	Context           interface{} `json:"@context,omitempty"`
+	ID                string      `json:"id"`
+	Type              string      `json:"type"`
+	PreferredUsername string      `json:"preferredUsername,omitempty"`
+	Name              string      `json:"name,omitempty"`
+	Summary           string      `json:"summary,omitempty"`
+	URL               string      `json:"url,omitempty"`
+	Icon              *Image      `json:"icon,omitempty"`
+	Inbox             string      `json:"inbox"`
+	Outbox            string      `json:"outbox,omitempty"`
+	Followers         string      `json:"followers,omitempty"`
+	// AttributedTo is the owner of a repository.
+	AttributedTo string     `json:"attributedTo,omitempty"`
+	PublicKey    *PublicKey `json:"publicKey,omitempty"`
+	Published    string     `json:"published,omitempty"`
+}
+
+// Note is a short text object, which is used to describe what happened in an activity.
+type Note struct {
+	ID           string   `json:"id,omitempty"`
+	Type         string   `json:"type"`
+	AttributedTo string   `json:"attributedTo,omitempty"`
+	Content      string   `json:"content"`
+	URL          string   `json:"url,omitempty"`
+	Published    string   `json:"published,omitempty"`
+	To           []string `json:"to,omitempty"`
+	Cc           []string `json:"cc,omitempty"`
+}
+
+// Activity is an action of an actor. The object can either be an URI or an embedded
+// object, use ObjectID or ObjectActivity to read it.
+type Activity struct {
+	Context   interface{}     `json:"@context,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Type      string          `json:"type"`
+	Actor     string          `json:"actor"`
+	Object    json.RawMessage `json:"object,omitempty"`
+	Target    string          `json:"target,omitempty"`
+	Summary   string          `json:"summary,omitempty"`
+	Published string          `json:"published,omitempty"`
+	To        []string        `json:"to,omitempty"`
+	Cc        []string        `json:"cc,omitempty"`
+}
+
+// NewActivity returns a new activity of given type with the object embedded.
+func NewActivity(typ, actor string, object interface{}) (*Activity, error) {
+	data, err := json.Marshal(object)
+	if err != nil {
+		return nil, err
+	}
+	return &Activity{
+		Type:   typ,
+		Actor:  actor,
+		Object: data,
+	}, nil
+}
+
+// ObjectID returns the ID of the object, which is either the object itself when it
+// is an URI or the "id" field of an embedded object.
+func (a *Activity) ObjectID() string {
+	if len(a.Object) == 0 {
+		return ""
+	}
+
+	var id string
+	if err := json.Unmarshal(a.Object, &id); err == nil {
+		return id
+	}
+
+	var object struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(a.Object, &object); err != nil {
+		return ""
+	}
+	return object.ID
+}
+
+// ObjectActivity returns the object as an activity, e.g. the activity being undone
+// by an Undo activity. It returns an error if the object is not embedded.
+func (a *Activity) ObjectActivity() (*Activity, error) {
+	object := new(Activity)
+	if err := json.Unmarshal(a.Object, object); err != nil {
+		return nil, fmt.Errorf("object is not an embedded activity: %v", err)
+	}
+	return object, nil
+}
+
+// OrderedCollection is an ordered list of items, e.g. activities in an outbox.
+type OrderedCollection struct {
+	Context      interface{} `json:"@context,omitempty"`
+	ID           string      `json:"id"`
+	Type         string      `json:"type"`
+	TotalItems   int64       `json:"totalItems"`
+	OrderedItems interface{} `json:"orderedItems"`
+}
+
+// NewOrderedCollection returns a new ordered collection with given items.
+func NewOrderedCollection(id string, totalItems int64, items interface{}) *OrderedCollection {
+	return &OrderedCollection{
+		Context:      Context,
+		ID:           id,
+		Type:         "OrderedCollection",
+		TotalItems:   totalItems,
+		OrderedItems: items,
+	}
+}
+
+// WebfingerLink is a link of a WebFinger resource.
+type WebfingerLink struct {
+	Rel  string `json:"rel"`
+	Type string `json:"type,omitempty"`
+	Href string `json:"href"`
+}
+
+// WebfingerResource is the JSON Resource Descriptor returned by WebFinger.
+type WebfingerResource struct {
+	Subject string           `json:"subject"`
+	Aliases []string         `json:"aliases,omitempty"`
+	Links   []*WebfingerLink `json:"links"`
+}
+
+// ParseAcct returns the name and host of an "acct:" URI, e.g. "acct:alice@example.com".
+func ParseAcct(resource string) (name, host string, _ error) {
+	if !strings.HasPrefix(resource, "acct:") {
+		return "", "", errors.New("not an acct URI")
+	}
+
+	fields := strings.Split(strings.TrimPrefix(resource, "acct:"), "@")
+	if len(fields) != 2 || fields[0] == "" || fields[1] == "" {
+		return "", "", errors.New("malformed acct URI")
+	}
+	return fields[0], fields[1], nil
+}
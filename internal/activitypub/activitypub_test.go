@@ -0,0 +1,63 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package activitypub
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_ParseAcct(t *testing.T) {
+	Convey("Parse acct URIs", t, func() {
+		name, host, err := ParseAcct("acct:alice@example.com")
+		So(err, ShouldBeNil)
+		So(name, ShouldEqual, "alice")
+		So(host, ShouldEqual, "example.com")
+
+		for _, resource := range []string{
+			"alice@example.com",
+			"acct:alice",
+			"acct:@example.com",
+			"acct:alice@",
+			"acct:alice@bob@example.com",
+		} {
+			_, _, err = ParseAcct(resource)
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
+
+func Test_Activity_Object(t *testing.T) {
+	Convey("Read object of activities", t, func() {
+		a := new(Activity)
+		So(json.Unmarshal([]byte(`{"type":"Follow","actor":"https://a.example/bob","object":"https://b.example/alice"}`), a), ShouldBeNil)
+		So(a.ObjectID(), ShouldEqual, "https://b.example/alice")
+		_, err := a.ObjectActivity()
+		So(err, ShouldNotBeNil)
+
+		a = new(Activity)
+		So(json.Unmarshal([]byte(`{"type":"Undo","actor":"https://a.example/bob","object":{"id":"https://a.example/follows/1","type":"Follow","actor":"https://a.example/bob","object":"https://b.example/alice"}}`), a), ShouldBeNil)
+		So(a.ObjectID(), ShouldEqual, "https://a.example/follows/1")
+		follow, err := a.ObjectActivity()
+		So(err, ShouldBeNil)
+		So(follow.Type, ShouldEqual, TypeFollow)
+		So(follow.ObjectID(), ShouldEqual, "https://b.example/alice")
+
+		a, err = NewActivity(TypeAccept, "https://b.example/alice", follow)
+		So(err, ShouldBeNil)
+		So(a.ObjectID(), ShouldEqual, "https://a.example/follows/1")
+	})
+}
+
+func Test_IsActivityContentType(t *testing.T) {
+	Convey("Check media types of ActivityPub documents", t, func() {
+		So(IsActivityContentType(ContentType), ShouldBeTrue)
+		So(IsActivityContentType(LDContentType), ShouldBeTrue)
+		So(IsActivityContentType("application/activity+json; charset=utf-8"), ShouldBeTrue)
+		So(IsActivityContentType("application/json"), ShouldBeFalse)
+	})
+}
@@ -0,0 +1,82 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package activitypub
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// maxDocumentSize is the max size of a document fetched from other instances.
+const maxDocumentSize = 1 << 20
+
+var client = &http.Client{
+	Timeout: 30 * time.Second,
+}
+
+// FetchActor fetches the actor document of given URI from other instances.
+func FetchActor(uri string) (*Actor, error) {
+	req, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", ContentType)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	actor := new(Actor)
+	if err = json.NewDecoder(io.LimitReader(resp.Body, maxDocumentSize)).Decode(actor); err != nil {
+		return nil, fmt.Errorf("decode: %v", err)
+	} else if actor.ID != uri {
+		return nil, fmt.Errorf("actor ID %q does not match URI", actor.ID)
+	}
+	return actor, nil
+}
+
+// Deliver posts the activity to the inbox with the request signed by the key.
+func Deliver(inbox string, activity *Activity, keyID string, key *rsa.PrivateKey) error {
+	if activity.Context == nil {
+		activity.Context = Context
+	}
+	body, err := json.Marshal(activity)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", inbox, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", ContentType)
+	if err = SignRequest(req, keyID, key, body); err != nil {
+		return fmt.Errorf("sign request: %v", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, data)
+	}
+	return nil
+}
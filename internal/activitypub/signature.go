@@ -0,0 +1,215 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package activitypub
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// maxSignatureAge is the max difference between the date of a signed request and now.
+const maxSignatureAge = 12 * time.Hour
+
+// GenerateKeyPair returns a new pair of PEM encoded RSA private and public keys.
+func GenerateKeyPair() (privateKey, publicKey string, _ error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return "", "", err
+	}
+
+	pub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return "", "", err
+	}
+
+	privateKey = string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+	publicKey = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub}))
+	return privateKey, publicKey, nil
+}
+
+// ParsePrivateKey parses a PEM encoded RSA private key.
+func ParsePrivateKey(s string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(s))
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
+
+// ParsePublicKey parses a PEM encoded RSA public key in either PKIX or PKCS #1 form.
+func ParsePublicKey(s string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(s))
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("not an RSA public key")
+	}
+	return rsaKey, nil
+}
+
+// Digest returns the value of "Digest" header of given request body.
+func Digest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// signingString returns the string to be signed of given headers of the request.
+func signingString(req *http.Request, headers []string) (string, error) {
+	lines := make([]string, len(headers))
+	for i, h := range headers {
+		switch h {
+		case "(request-target)":
+			lines[i] = fmt.Sprintf("(request-target): %s %s", strings.ToLower(req.Method), req.URL.RequestURI())
+		case "host":
+			host := req.Host
+			if host == "" {
+				host = req.URL.Host
+			}
+			lines[i] = "host: " + host
+		default:
+			v := req.Header.Get(h)
+			if v == "" {
+				return "", fmt.Errorf("header %q is missing", h)
+			}
+			lines[i] = h + ": " + v
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// SignRequest signs the request with the key using HTTP Signatures, the "Digest" header
+// is set and signed if body is not nil.
+func SignRequest(req *http.Request, keyID string, key *rsa.PrivateKey, body []byte) error {
+	if req.Header.Get("Date") == "" {
+		req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	}
+
+	headers := []string{"(request-target)", "host", "date"}
+	if body != nil {
+		req.Header.Set("Digest", Digest(body))
+		headers = append(headers, "digest")
+	}
+
+	s, err := signingString(req, headers)
+	if err != nil {
+		return err
+	}
+	hashed := sha256.Sum256([]byte(s))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Signature", fmt.Sprintf(`keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		keyID, strings.Join(headers, " "), base64.StdEncoding.EncodeToString(signature)))
+	return nil
+}
+
+// Signature is a parsed "Signature" header.
+type Signature struct {
+	KeyID     string
+	Algorithm string
+	Headers   []string
+	Signature []byte
+}
+
+// Owner returns the URI of the actor who owns the key, which is the key ID without
+// the fragment, e.g. "https://example.com/users/alice" for "https://example.com/users/alice#main-key".
+func (s *Signature) Owner() string {
+	return strings.SplitN(s.KeyID, "#", 2)[0]
+}
+
+var signatureParamPattern = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// ParseSignature parses the value of a "Signature" header.
+func ParseSignature(header string) (*Signature, error) {
+	sig := &Signature{
+		Headers: []string{"date"}, // The default when headers are not specified
+	}
+	for _, m := range signatureParamPattern.FindAllStringSubmatch(header, -1) {
+		switch m[1] {
+		case "keyId":
+			sig.KeyID = m[2]
+		case "algorithm":
+			sig.Algorithm = m[2]
+		case "headers":
+			sig.Headers = strings.Fields(strings.ToLower(m[2]))
+		case "signature":
+			signature, err := base64.StdEncoding.DecodeString(m[2])
+			if err != nil {
+				return nil, fmt.Errorf("decode signature: %v", err)
+			}
+			sig.Signature = signature
+		}
+	}
+
+	if sig.KeyID == "" || len(sig.Signature) == 0 {
+		return nil, errors.New("key ID or signature is missing")
+	}
+	switch sig.Algorithm {
+	case "", "rsa-sha256", "hs2019":
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", sig.Algorithm)
+	}
+	return sig, nil
+}
+
+// VerifyRequest verifies the signature of the request with the key. The signature must
+// cover the request target, the host and the date which must be recent, and the digest
+// of the request body which must match if the body is not empty.
+func VerifyRequest(req *http.Request, body []byte, sig *Signature, key *rsa.PublicKey) error {
+	signed := make(map[string]bool, len(sig.Headers))
+	for _, h := range sig.Headers {
+		signed[h] = true
+	}
+	for _, h := range []string{"(request-target)", "host", "date"} {
+		if !signed[h] {
+			return fmt.Errorf("header %q is not signed", h)
+		}
+	}
+
+	date, err := http.ParseTime(req.Header.Get("Date"))
+	if err != nil {
+		return fmt.Errorf("parse date: %v", err)
+	} else if d := time.Since(date); d > maxSignatureAge || d < -maxSignatureAge {
+		return errors.New("date is too far from now")
+	}
+
+	if len(body) > 0 {
+		if !signed["digest"] {
+			return errors.New(`header "digest" is not signed`)
+		} else if req.Header.Get("Digest") != Digest(body) {
+			return errors.New("digest mismatch")
+		}
+	}
+
+	s, err := signingString(req, sig.Headers)
+	if err != nil {
+		return err
+	}
+	hashed := sha256.Sum256([]byte(s))
+	return rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], sig.Signature)
+}
@@ -0,0 +1,78 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package activitypub
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_SignRequest(t *testing.T) {
+	Convey("Sign and verify requests", t, func() {
+		privateKey, publicKey, err := GenerateKeyPair()
+		So(err, ShouldBeNil)
+		priv, err := ParsePrivateKey(privateKey)
+		So(err, ShouldBeNil)
+		pub, err := ParsePublicKey(publicKey)
+		So(err, ShouldBeNil)
+
+		body := []byte(`{"type":"Follow"}`)
+		req, err := http.NewRequest("POST", "https://example.com/inbox", strings.NewReader(string(body)))
+		So(err, ShouldBeNil)
+		So(SignRequest(req, "https://a.example/bob#main-key", priv, body), ShouldBeNil)
+
+		sig, err := ParseSignature(req.Header.Get("Signature"))
+		So(err, ShouldBeNil)
+		So(sig.KeyID, ShouldEqual, "https://a.example/bob#main-key")
+		So(sig.Owner(), ShouldEqual, "https://a.example/bob")
+		So(sig.Headers, ShouldResemble, []string{"(request-target)", "host", "date", "digest"})
+		So(VerifyRequest(req, body, sig, pub), ShouldBeNil)
+
+		Convey("Tampered body", func() {
+			So(VerifyRequest(req, []byte(`{"type":"Undo"}`), sig, pub), ShouldNotBeNil)
+		})
+
+		Convey("Different request target", func() {
+			req.URL.Path = "/other/inbox"
+			So(VerifyRequest(req, body, sig, pub), ShouldNotBeNil)
+		})
+
+		Convey("Stale date", func() {
+			req.Header.Set("Date", time.Now().Add(-24*time.Hour).UTC().Format(http.TimeFormat))
+			So(VerifyRequest(req, body, sig, pub), ShouldNotBeNil)
+		})
+
+		Convey("Different key", func() {
+			_, otherKey, err := GenerateKeyPair()
+			So(err, ShouldBeNil)
+			other, err := ParsePublicKey(otherKey)
+			So(err, ShouldBeNil)
+			So(VerifyRequest(req, body, sig, other), ShouldNotBeNil)
+		})
+	})
+}
+
+func Test_ParseSignature(t *testing.T) {
+	Convey("Parse signature headers", t, func() {
+		sig, err := ParseSignature(`keyId="https://a.example/bob#main-key",headers="(request-target) Host Date",signature="YWJj"`)
+		So(err, ShouldBeNil)
+		So(sig.Headers, ShouldResemble, []string{"(request-target)", "host", "date"})
+		So(string(sig.Signature), ShouldEqual, "abc")
+
+		for _, header := range []string{
+			``,
+			`keyId="https://a.example/bob#main-key"`,
+			`signature="YWJj"`,
+			`keyId="https://a.example/bob#main-key",algorithm="hmac-sha256",signature="YWJj"`,
+		} {
+			_, err = ParseSignature(header)
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
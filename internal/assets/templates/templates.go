@@ -55,7 +55,7 @@ func NewTemplateFileSystem(dir, customDir string) macaron.TemplateFileSystem {
 		// Check if corresponding custom file exists
 		var err error
 		var data []byte
-		fpath := path.Join(customDir, name)
+		fpath := path.Join(customDir, strings.TrimPrefix(name, dir))
 		if osutil.IsFile(fpath) {
 			data, err = ioutil.ReadFile(fpath)
 		} else {
@@ -82,7 +82,16 @@ func SignedInID(c *macaron.Context, sess session.Store) (_ int64, isTokenAuth bo
 
 // SignedInUser returns the user object of signed in user, along with two bool values,
 // which indicate whether user uses HTTP Basic Authentication or token authentication respectively.
+// Deactivated users are treated as not signed in.
 func SignedInUser(ctx *macaron.Context, sess session.Store) (_ *db.User, isBasicAuth bool, isTokenAuth bool) {
+	u, isBasicAuth, isTokenAuth := signedInUser(ctx, sess)
+	if u != nil && u.IsDeactivated {
+		return nil, false, false
+	}
+	return u, isBasicAuth, isTokenAuth
+}
+
+func signedInUser(ctx *macaron.Context, sess session.Store) (_ *db.User, isBasicAuth bool, isTokenAuth bool) {
 	if !db.HasEngine {
 		return nil, false, false
 	}
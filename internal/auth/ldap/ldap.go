@@ -163,6 +163,24 @@ func dial(ls *Source) (*ldap.Conn, error) {
 	return conn, nil
 }
 
+// Ping checks if the LDAP server is reachable, and the bind DN is able to bind when
+// it is configured and does not depend on the user.
+func (ls *Source) Ping() error {
+	l, err := dial(ls)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+
+	if ls.BindDN == "" || ls.BindPassword == "" || strings.Contains(ls.BindDN, "%s") {
+		return nil
+	}
+	if err = l.Bind(ls.BindDN, ls.BindPassword); err != nil {
+		return fmt.Errorf("Bind: %v", err)
+	}
+	return nil
+}
+
 func bindUser(l *ldap.Conn, userDN, passwd string) error {
 	log.Trace("Binding with userDN: %s", userDN)
 	err := l.Bind(userDN, passwd)
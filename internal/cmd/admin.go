@@ -14,6 +14,7 @@ import (
 
 	"gogs.io/gogs/internal/conf"
 	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/repoutil"
 )
 
 var (
@@ -31,6 +32,7 @@ to make automatic initialization process more smoothly`,
 			subcmdRewriteAuthorizedKeys,
 			subcmdSyncRepositoryHooks,
 			subcmdReinitMissingRepositories,
+			subcmdMigrateStorage,
 		},
 	}
 
@@ -130,6 +132,17 @@ to make automatic initialization process more smoothly`,
 			stringFlag("config, c", "", "Custom configuration file path"),
 		},
 	}
+
+	subcmdMigrateStorage = cli.Command{
+		Name:   "migrate-storage",
+		Usage:  "Move repositories of a user or organization to another storage root",
+		Action: runMigrateStorage,
+		Flags: []cli.Flag{
+			stringFlag("name", "", "Username or organization name"),
+			stringFlag("root", "", "Name of the target storage root, leave empty to use the default root"),
+			stringFlag("config, c", "", "Custom configuration file path"),
+		},
+	}
 )
 
 func runCreateUser(c *cli.Context) error {
@@ -162,6 +175,31 @@ func runCreateUser(c *cli.Context) error {
 	return nil
 }
 
+func runMigrateStorage(c *cli.Context) error {
+	if !c.IsSet("name") {
+		return errors.New("Username is not specified")
+	}
+
+	err := conf.Init(c.String("config"))
+	if err != nil {
+		return errors.Wrap(err, "init configuration")
+	}
+
+	db.SetEngine()
+
+	u, err := db.GetUserByName(c.String("name"))
+	if err != nil {
+		return fmt.Errorf("GetUserByName: %v", err)
+	}
+
+	if err = db.MoveUserStorage(u, c.String("root")); err != nil {
+		return fmt.Errorf("MoveUserStorage: %v", err)
+	}
+
+	fmt.Printf("Repositories of '%s' have been successfully moved to %q!\n", u.Name, repoutil.StorageRoot(u.StorageRoot))
+	return nil
+}
+
 func adminDashboardOperation(operation func() error, successMessage string) func(*cli.Context) error {
 	return func(c *cli.Context) error {
 		err := conf.Init(c.String("config"))
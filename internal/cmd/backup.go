@@ -21,6 +21,7 @@ import (
 
 	"gogs.io/gogs/internal/conf"
 	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/repoutil"
 )
 
 var Backup = cli.Command{
@@ -127,6 +128,26 @@ func runBackup(c *cli.Context) error {
 		if err = z.AddFile(_ARCHIVE_ROOT_DIR+"/repositories.zip", reposDump); err != nil {
 			log.Fatal("Failed to include 'repositories.zip': %v", err)
 		}
+
+		// Named storage roots are dumped separately, e.g. "repositories-ssd.zip".
+		for _, name := range repoutil.StorageRootNames() {
+			rootPath := repoutil.StorageRoot(name)
+			if !com.IsDir(rootPath) {
+				continue
+			}
+
+			dumpName := "repositories-" + name + ".zip"
+			reposDump = filepath.Join(rootDir, dumpName)
+			log.Info("Dumping repositories in %q", rootPath)
+			if err = zip.PackTo(rootPath, reposDump, true); err != nil {
+				log.Fatal("Failed to dump repositories: %v", err)
+			}
+			log.Info("Repositories dumped to: %s", reposDump)
+
+			if err = z.AddFile(_ARCHIVE_ROOT_DIR+"/"+dumpName, reposDump); err != nil {
+				log.Fatal("Failed to include %q: %v", dumpName, err)
+			}
+		}
 	}
 
 	if err = z.Close(); err != nil {
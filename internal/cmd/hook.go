@@ -70,6 +70,19 @@ func runHookPreReceive(c *cli.Context) error {
 	setup(c, "hooks/pre-receive.log", true)
 
 	isWiki := strings.Contains(os.Getenv(db.ENV_REPO_CUSTOM_HOOKS_PATH), ".wiki.git/")
+	agitOnly := os.Getenv(db.ENV_AUTH_USER_AGIT_ONLY) == "true"
+
+	repoID := com.StrTo(os.Getenv(db.ENV_REPO_ID)).MustInt64()
+	var repo *db.Repository
+	getRepo := func() *db.Repository {
+		if repo == nil {
+			var err error
+			if repo, err = db.GetRepositoryByID(repoID); err != nil {
+				fail("Internal error", "GetRepositoryByID [%d]: %v", repoID, err)
+			}
+		}
+		return repo
+	}
 
 	buf := bytes.NewBuffer(nil)
 	scanner := bufio.NewScanner(os.Stdin)
@@ -87,10 +100,36 @@ func runHookPreReceive(c *cli.Context) error {
 		}
 		oldCommitID := string(fields[0])
 		newCommitID := string(fields[1])
-		branchName := strings.TrimPrefix(string(fields[2]), git.BRANCH_PREFIX)
+		refName := string(fields[2])
+		branchName := strings.TrimPrefix(refName, git.BRANCH_PREFIX)
+
+		// Pull requests of AGit flow
+		if strings.HasPrefix(refName, db.AGIT_REF_PREFIX) {
+			checkAgitPush(getRepo(), refName, newCommitID)
+			continue
+		} else if agitOnly {
+			fail(fmt.Sprintf("You do not have write access, push to '%s<branch>/<topic>' to create pull requests instead", db.AGIT_REF_PREFIX), "")
+		}
+
+		// Names of new branches and tags
+		if oldCommitID == git.EMPTY_SHA && newCommitID != git.EMPTY_SHA {
+			var err error
+			if strings.HasPrefix(refName, git.BRANCH_PREFIX) {
+				err = getRepo().CheckBranchName(branchName)
+			} else if strings.HasPrefix(refName, git.TAG_PREFIX) {
+				err = getRepo().CheckTagName(strings.TrimPrefix(refName, git.TAG_PREFIX))
+			}
+			if err != nil {
+				fail(refNameNotAllowedMessage(err.(errors.RefNameNotAllowed)), "")
+			}
+		}
+
+		// License policy
+		if conf.Repository.LicensePushPolicy != "" {
+			checkLicensePolicy(getRepo(), branchName, oldCommitID, newCommitID)
+		}
 
 		// Branch protection
-		repoID := com.StrTo(os.Getenv(db.ENV_REPO_ID)).MustInt64()
 		protectBranch, err := db.GetProtectBranchOfRepoByName(repoID, branchName)
 		if err != nil {
 			if errors.IsErrBranchNotExist(err) {
@@ -156,6 +195,98 @@ func runHookPreReceive(c *cli.Context) error {
 	return nil
 }
 
+// checkAgitPush rejects the push to the reference of AGit flow if it cannot create or
+// update a pull request.
+func checkAgitPush(repo *db.Repository, refName, newCommitID string) {
+	if !repo.AllowsPulls() {
+		fail("Pull requests are not enabled for this repository", "")
+	} else if newCommitID == git.EMPTY_SHA {
+		fail(fmt.Sprintf("Reference '%s' cannot be deleted", refName), "")
+	}
+
+	baseBranch, _, ok := db.ParseAgitRef(refName, getPushOptions())
+	if !ok {
+		fail(fmt.Sprintf("Reference '%s' is not valid, push to '%s<branch>/<topic>' or give the topic by '-o topic=<topic>' to create a pull request", refName, db.AGIT_REF_PREFIX), "")
+	}
+
+	gitRepo, err := git.OpenRepository(repo.RepoPath())
+	if err != nil {
+		fail("Internal error", "Failed to open repository: %v", err)
+	} else if !gitRepo.IsBranchExist(baseBranch) {
+		fail(fmt.Sprintf("Branch '%s' does not exist", baseBranch), "")
+	}
+
+	mergeBase, err := gitRepo.GetMergeBase(git.BRANCH_PREFIX+baseBranch, newCommitID)
+	if err != nil {
+		if git.IsErrNoMergeBase(err) {
+			fail(fmt.Sprintf("Commit '%s' has no common history with branch '%s'", newCommitID, baseBranch), "")
+		}
+		fail("Internal error", "Failed to get merge base: %v", err)
+	} else if mergeBase == newCommitID {
+		fail(fmt.Sprintf("There is no new commit compared to branch '%s'", baseBranch), "")
+	}
+}
+
+// refNameNotAllowedMessage returns the descriptive message of why the ref name is not allowed.
+func refNameNotAllowedMessage(err errors.RefNameNotAllowed) string {
+	kind := strings.Title(err.Kind)
+	if len(err.Patterns) == 0 {
+		return fmt.Sprintf("%s name '%s' is not allowed, only ASCII letters, digits and characters '-_./+@' can be used", kind, err.Name)
+	}
+	return fmt.Sprintf("%s name '%s' is not allowed, it must match one of patterns: %s", kind, err.Name, strings.Join(err.Patterns, ", "))
+}
+
+// checkLicensePolicy warns or rejects the push according to license push policy when
+// it replaces the LICENSE file of the default branch with a license that is not allowed.
+func checkLicensePolicy(repo *db.Repository, branchName, oldCommitID, newCommitID string) {
+	policy := conf.Repository.LicensePushPolicy
+	if (policy != "warn" && policy != "block") ||
+		branchName != repo.DefaultBranch || newCommitID == git.EMPTY_SHA {
+		return
+	}
+
+	if len(db.Licenses) == 0 {
+		db.LoadRepoConfig()
+	}
+	allowed, restricted := db.AllowedLicenses(repo.MustOwner())
+	if !restricted {
+		return
+	}
+
+	repoPath := repo.RepoPath()
+	newBlobID, err := git.NewCommand("rev-parse", "--verify", "--quiet", newCommitID+":LICENSE").RunInDir(repoPath)
+	if err != nil {
+		// There is no LICENSE file in the new commit.
+		return
+	}
+	if oldCommitID != git.EMPTY_SHA {
+		oldBlobID, err := git.NewCommand("rev-parse", "--verify", "--quiet", oldCommitID+":LICENSE").RunInDir(repoPath)
+		if err == nil && oldBlobID == newBlobID {
+			return
+		}
+	}
+
+	content, err := git.NewCommand("cat-file", "blob", strings.TrimSpace(newBlobID)).RunInDirBytes(repoPath)
+	if err != nil {
+		fail("Internal error", "Failed to read LICENSE file: %v", err)
+	}
+	name := db.IdentifyLicense(string(content))
+	if com.IsSliceContainsStr(allowed, name) {
+		return
+	}
+
+	var msg string
+	if name == "" {
+		msg = "LICENSE file does not match any allowed license"
+	} else {
+		msg = fmt.Sprintf("License '%s' in LICENSE file is not allowed", name)
+	}
+	if policy == "block" {
+		fail(fmt.Sprintf("%s, allowed licenses are: %s", msg, strings.Join(allowed, ", ")), "")
+	}
+	fmt.Fprintln(os.Stderr, "Gogs: warning:", msg)
+}
+
 func runHookUpdate(c *cli.Context) error {
 	if len(os.Getenv("SSH_ORIGINAL_COMMAND")) == 0 {
 		return nil
@@ -190,6 +321,34 @@ func runHookUpdate(c *cli.Context) error {
 	return nil
 }
 
+// getPushOptions returns options given by "git push -o", which are passed to hooks
+// through environment variables by Git.
+func getPushOptions() db.PushOptions {
+	n := com.StrTo(os.Getenv("GIT_PUSH_OPTION_COUNT")).MustInt()
+	options := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		options = append(options, os.Getenv(fmt.Sprintf("GIT_PUSH_OPTION_%d", i)))
+	}
+	return db.ParsePushOptions(options)
+}
+
+// pushAgitPullRequest creates or updates the pull request of AGit flow, and tells the
+// pusher where the pull request is.
+func pushAgitPullRequest(opts db.PushUpdateOptions) {
+	pr, isNew, err := db.PushAgitPullRequest(opts)
+	if err != nil {
+		log.Error("PushAgitPullRequest: %v", err)
+		fmt.Fprintln(os.Stderr, "Gogs: failed to create or update pull request, please contact the site administrator")
+		return
+	}
+
+	action := "updated"
+	if isNew {
+		action = "created"
+	}
+	fmt.Fprintf(os.Stderr, "Gogs: pull request #%d has been %s:\n  %s\n", pr.Index, action, pr.Issue.HTMLURL())
+}
+
 func runHookPostReceive(c *cli.Context) error {
 	if len(os.Getenv("SSH_ORIGINAL_COMMAND")) == 0 {
 		return nil
@@ -201,6 +360,7 @@ func runHookPostReceive(c *cli.Context) error {
 	email.NewContext()
 
 	isWiki := strings.Contains(os.Getenv(db.ENV_REPO_CUSTOM_HOOKS_PATH), ".wiki.git/")
+	pushOptions := getPushOptions()
 
 	buf := bytes.NewBuffer(nil)
 	scanner := bufio.NewScanner(os.Stdin)
@@ -226,8 +386,11 @@ func runHookPostReceive(c *cli.Context) error {
 			PusherName:   os.Getenv(db.ENV_AUTH_USER_NAME),
 			RepoUserName: os.Getenv(db.ENV_REPO_OWNER_NAME),
 			RepoName:     os.Getenv(db.ENV_REPO_NAME),
+			PushOptions:  pushOptions,
 		}
-		if err := db.PushUpdate(options); err != nil {
+		if strings.HasPrefix(options.RefFullName, db.AGIT_REF_PREFIX) {
+			pushAgitPullRequest(options)
+		} else if err := db.PushUpdate(options); err != nil {
 			log.Error("PushUpdate: %v", err)
 		}
 
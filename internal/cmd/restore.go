@@ -19,6 +19,7 @@ import (
 
 	"gogs.io/gogs/internal/conf"
 	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/repoutil"
 )
 
 var Restore = cli.Command{
@@ -147,6 +148,18 @@ func runRestore(c *cli.Context) error {
 		if err := zip.ExtractTo(reposPath, filepath.Dir(conf.Repository.Root)); err != nil {
 			log.Fatal("Failed to extract 'repositories.zip': %v", err)
 		}
+
+		for _, name := range repoutil.StorageRootNames() {
+			dumpName := "repositories-" + name + ".zip"
+			reposPath = filepath.Join(archivePath, dumpName)
+			if !com.IsExist(reposPath) {
+				continue
+			}
+
+			if err := zip.ExtractTo(reposPath, filepath.Dir(repoutil.StorageRoot(name))); err != nil {
+				log.Fatal("Failed to extract %q: %v", dumpName, err)
+			}
+		}
 	}
 
 	log.Info("Restore succeed!")
@@ -5,6 +5,7 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
@@ -19,6 +20,7 @@ import (
 	"gogs.io/gogs/internal/conf"
 	"gogs.io/gogs/internal/db"
 	"gogs.io/gogs/internal/db/errors"
+	"gogs.io/gogs/internal/repoutil"
 )
 
 const (
@@ -124,9 +126,36 @@ var (
 		"git-upload-pack":    db.ACCESS_MODE_READ,
 		"git-upload-archive": db.ACCESS_MODE_READ,
 		"git-receive-pack":   db.ACCESS_MODE_WRITE,
+		// Access mode of Git LFS depends on the operation.
+		"git-lfs-authenticate": db.ACCESS_MODE_READ,
 	}
 )
 
+// lfsAuthenticateExpires is how long the token issued to Git LFS client is valid.
+const lfsAuthenticateExpires = 30 * time.Minute
+
+// printLFSAuthentication prints the URL and credentials of Git LFS API of the repository
+// for the client connected via SSH. User is nil for deploy keys and anonymous access.
+func printLFSAuthentication(user *db.User, repo *db.Repository, mode db.AccessMode) {
+	var userID int64
+	if user != nil {
+		userID = user.ID
+	}
+	token := db.NewLFSToken(userID, repo.ID, mode, time.Now().Add(lfsAuthenticateExpires))
+
+	data, err := json.Marshal(map[string]interface{}{
+		"href": repo.HTMLURL() + ".git/info/lfs",
+		"header": map[string]string{
+			"Authorization": "Bearer " + token,
+		},
+		"expires_in": int(lfsAuthenticateExpires.Seconds()),
+	})
+	if err != nil {
+		fail("Internal error", "Failed to encode LFS authentication: %v", err)
+	}
+	fmt.Println(string(data))
+}
+
 func runServ(c *cli.Context) error {
 	setup(c, "serv.log", true)
 
@@ -147,6 +176,20 @@ func runServ(c *cli.Context) error {
 	}
 
 	verb, args := parseSSHCmd(sshCmd)
+
+	// Git LFS client passes the operation after the repository path,
+	// e.g. "git-lfs-authenticate 'owner/repo.git' upload".
+	var lfsOperation string
+	if verb == "git-lfs-authenticate" {
+		if !conf.LFS.Enabled {
+			fail("Git LFS is disabled", "")
+		}
+		fields := strings.Fields(args)
+		if len(fields) != 2 {
+			fail("Invalid arguments", "Invalid arguments of git-lfs-authenticate: %v", args)
+		}
+		args, lfsOperation = fields[0], fields[1]
+	}
 	repoFullName := strings.ToLower(strings.Trim(args, "'"))
 	repoFields := strings.SplitN(repoFullName, "/", 2)
 	if len(repoFields) != 2 {
@@ -154,6 +197,7 @@ func runServ(c *cli.Context) error {
 	}
 	ownerName := strings.ToLower(repoFields[0])
 	repoName := strings.TrimSuffix(strings.ToLower(repoFields[1]), ".git")
+	isWiki := strings.HasSuffix(repoName, ".wiki")
 	repoName = strings.TrimSuffix(repoName, ".wiki")
 
 	owner, err := db.GetUserByName(ownerName)
@@ -173,18 +217,35 @@ func runServ(c *cli.Context) error {
 	}
 	repo.Owner = owner
 
+	if isWiki && !repo.HasInternalWiki() {
+		fail(_ACCESS_DENIED_MESSAGE, "Wiki is not enabled: %s/%s", owner.Name, repoName)
+	}
+
 	requestMode, ok := allowedCommands[verb]
 	if !ok {
 		fail("Unknown git command", "Unknown git command '%s'", verb)
 	}
+	switch lfsOperation {
+	case "", "download":
+	case "upload":
+		requestMode = db.ACCESS_MODE_WRITE
+	default:
+		fail("Unknown Git LFS operation", "Unknown Git LFS operation '%s'", lfsOperation)
+	}
 
 	// Prohibit push to mirror repositories.
 	if requestMode > db.ACCESS_MODE_READ && repo.IsMirror {
 		fail("Mirror repository is read-only", "")
 	}
 
+	// Prohibit push to archived repositories.
+	if requestMode > db.ACCESS_MODE_READ && repo.IsArchived {
+		fail("Archived repository is read-only", "")
+	}
+
 	// Allow anonymous (user is nil) clone for public repositories.
 	var user *db.User
+	var agitOnly bool
 
 	key, err := db.GetPublicKeyByID(com.StrTo(strings.TrimPrefix(c.Args()[0], "key-")).MustInt64())
 	if err != nil {
@@ -202,14 +263,29 @@ func runServ(c *cli.Context) error {
 			user, err = db.GetUserByKeyID(key.ID)
 			if err != nil {
 				fail("Internal error", "Failed to get user by key ID '%d': %v", key.ID, err)
+			} else if user.IsDeactivated {
+				fail("User account is deactivated", "User '%s' is deactivated", user.Name)
 			}
 
-			mode, err := db.UserAccessMode(user.ID, repo)
+			unit := db.REPO_UNIT_CODE
+			if isWiki {
+				unit = db.REPO_UNIT_WIKI
+			}
+			mode, err := db.UserUnitAccessMode(user.ID, repo, unit)
 			if err != nil {
 				fail("Internal error", "Failed to check access: %v", err)
 			}
 
-			if mode < requestMode {
+			has := mode >= requestMode
+			if isWiki {
+				// Wiki has its own access control separate from code.
+				has = repo.CanAccessWiki(mode, requestMode)
+			} else if !has && verb == "git-receive-pack" && mode >= db.ACCESS_MODE_READ && repo.AllowsPulls() {
+				// Users who can read the repository are able to create pull requests by
+				// pushing to references of AGit flow, which is checked by pre-receive hook.
+				has, agitOnly = true, true
+			}
+			if !has {
 				clientMessage := _ACCESS_DENIED_MESSAGE
 				if mode >= db.ACCESS_MODE_READ {
 					clientMessage = "You do not have sufficient authorization for this action"
@@ -218,6 +294,11 @@ func runServ(c *cli.Context) error {
 					"User '%s' does not have level '%v' access to repository '%s'",
 					user.Name, requestMode, repoFullName)
 			}
+
+			if requestMode == db.ACCESS_MODE_WRITE && !user.HasAcceptedTerms() {
+				fail("You must accept the terms before pushing: "+conf.Server.ExternalURL+"user/terms",
+					"User '%s' has not accepted the terms", user.Name)
+			}
 		}
 	} else {
 		// Check if the key can access to the repository in case of it is a deploy key (a deploy keys != user key).
@@ -242,6 +323,18 @@ func runServ(c *cli.Context) error {
 		}
 	}
 
+	if lfsOperation != "" {
+		printLFSAuthentication(user, repo, requestMode)
+		return nil
+	}
+
+	// Wiki is created by the first push if it does not exist yet.
+	if isWiki && requestMode == db.ACCESS_MODE_WRITE {
+		if err = repo.InitWiki(); err != nil {
+			fail("Internal error", "Failed to initialize wiki: %v", err)
+		}
+	}
+
 	// Special handle for Windows.
 	if conf.IsWindowsRuntime() {
 		verb = strings.Replace(verb, "-", " ", 1)
@@ -262,9 +355,12 @@ func runServ(c *cli.Context) error {
 			RepoID:    repo.ID,
 			RepoName:  repo.Name,
 			RepoPath:  repo.RepoPath(),
+			AgitOnly:  agitOnly,
 		})...)
+		// Accept push options given by "git push -o", which are passed to hooks.
+		gitCmd.Env = append(gitCmd.Env, "GIT_CONFIG_PARAMETERS='receive.advertisepushoptions=true'")
 	}
-	gitCmd.Dir = conf.Repository.Root
+	gitCmd.Dir = repoutil.StorageRoot(owner.StorageRoot)
 	gitCmd.Stdout = os.Stdout
 	gitCmd.Stdin = os.Stdin
 	gitCmd.Stderr = os.Stderr
@@ -23,8 +23,8 @@ import (
 	"github.com/go-macaron/i18n"
 	"github.com/go-macaron/session"
 	"github.com/go-macaron/toolbox"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"github.com/unknwon/com"
 	"github.com/urfave/cli"
 	"gopkg.in/macaron.v1"
 	log "unknwon.dev/clog/v2"
@@ -43,6 +43,7 @@ import (
 	"gogs.io/gogs/internal/route/org"
 	"gogs.io/gogs/internal/route/repo"
 	"gogs.io/gogs/internal/route/user"
+	"gogs.io/gogs/internal/storage"
 	"gogs.io/gogs/internal/template"
 )
 
@@ -188,12 +189,19 @@ func runWeb(c *cli.Context) error {
 			c.Redirect(conf.Server.Subpath + "/explore/repos")
 		})
 		m.Get("/repos", route.ExploreRepos)
+		m.Get("/trending", route.ExploreTrending)
+		m.Get("/topics", route.ExploreTopics)
 		m.Get("/users", route.ExploreUsers)
 		m.Get("/organizations", route.ExploreOrganizations)
 	}, ignSignIn)
+	m.Get("/search", ignSignIn, route.Search)
 	m.Combo("/install", route.InstallInit).Get(route.Install).
 		Post(bindIgnErr(form.Install{}), route.InstallPost)
 	m.Get("/^:type(issues|pulls)$", reqSignIn, user.Issues)
+	m.Group("/notifications", func() {
+		m.Get("", user.Notifications)
+		m.Post("/status", user.NotificationStatusPost)
+	}, reqSignIn)
 
 	// ***** START: User *****
 	m.Group("/user", func() {
@@ -202,6 +210,7 @@ func runWeb(c *cli.Context) error {
 				Post(bindIgnErr(form.SignIn{}), user.LoginPost)
 			m.Combo("/two_factor").Get(user.LoginTwoFactor).Post(user.LoginTwoFactorPost)
 			m.Combo("/two_factor_recovery_code").Get(user.LoginTwoFactorRecoveryCode).Post(user.LoginTwoFactorRecoveryCodePost)
+			m.Combo("/reactivate").Get(user.LoginReactivate).Post(user.LoginReactivatePost)
 		})
 
 		m.Get("/sign_up", user.SignUp)
@@ -240,9 +249,12 @@ func runWeb(c *cli.Context) error {
 			m.Get("", user.SettingsOrganizations)
 			m.Post("/leave", user.SettingsLeaveOrganization)
 		})
+		m.Combo("/digests").Get(user.SettingsDigests).
+			Post(user.SettingsDigestsPost)
 		m.Combo("/applications").Get(user.SettingsApplications).
 			Post(bindIgnErr(form.NewAccessToken{}), user.SettingsApplicationsPost)
 		m.Post("/applications/delete", user.SettingsDeleteApplication)
+		m.Post("/deactivate", user.SettingsDeactivatePost)
 		m.Route("/delete", "GET,POST", user.SettingsDelete)
 	}, reqSignIn, func(c *context.Context) {
 		c.Data["PageIsUserSettings"] = true
@@ -252,10 +264,27 @@ func runWeb(c *cli.Context) error {
 		m.Any("/activate", user.Activate)
 		m.Any("/activate_email", user.ActivateEmail)
 		m.Get("/email2user", user.Email2User)
+		m.Get("/digest/unsubscribe", user.UnsubscribeDigest)
 		m.Get("/forget_password", user.ForgotPasswd)
 		m.Post("/forget_password", user.ForgotPasswdPost)
 		m.Post("/logout", user.SignOut)
 	})
+
+	m.Get("/user/terms", ignSignIn, user.Terms)
+	m.Post("/user/terms", reqSignIn, user.TermsPost)
+
+	m.Group("/user/onboarding", func() {
+		m.Get("", user.Onboarding)
+		m.Post("/complete", user.OnboardingCompletePost)
+		m.Post("/dismiss", user.OnboardingDismissPost)
+	}, reqSignIn)
+
+	m.Group("/user/stars/lists", func() {
+		m.Post("/new", bindIgnErr(form.StarList{}), user.NewStarListPost)
+		m.Post("/:id/edit", bindIgnErr(form.StarList{}), user.EditStarListPost)
+		m.Post("/:id/delete", user.DeleteStarListPost)
+		m.Post("/:id/repos", user.StarListReposPost)
+	}, reqSignIn)
 	// ***** END: User *****
 
 	reqAdmin := context.Toggle(&context.ToggleOptions{SignInRequired: true, AdminRequired: true})
@@ -265,13 +294,18 @@ func runWeb(c *cli.Context) error {
 		m.Get("", admin.Dashboard)
 		m.Get("/config", admin.Config)
 		m.Post("/config/test_mail", admin.SendTestMail)
+		m.Group("/config/mail_templates", func() {
+			m.Get("", admin.MailTemplates)
+			m.Get("/preview", admin.MailTemplatePreview)
+			m.Post("/test", admin.SendTestMailTemplate)
+		})
 		m.Get("/monitor", admin.Monitor)
 
 		m.Group("/users", func() {
 			m.Get("", admin.Users)
 			m.Combo("/new").Get(admin.NewUser).Post(bindIgnErr(form.AdminCrateUser{}), admin.NewUserPost)
 			m.Combo("/:userid").Get(admin.EditUser).Post(bindIgnErr(form.AdminEditUser{}), admin.EditUserPost)
-			m.Post("/:userid/delete", admin.DeleteUser)
+			m.Post("/:userid/delete", bindIgnErr(form.AdminDeleteUser{}), admin.DeleteUser)
 		})
 
 		m.Group("/orgs", func() {
@@ -280,7 +314,9 @@ func runWeb(c *cli.Context) error {
 
 		m.Group("/repos", func() {
 			m.Get("", admin.Repos)
+			m.Get("/export", admin.ExportRepos)
 			m.Post("/delete", admin.DeleteRepo)
+			m.Post("/:action(archive|unarchive)", admin.ArchiveRepo)
 		})
 
 		m.Group("/auths", func() {
@@ -291,11 +327,43 @@ func runWeb(c *cli.Context) error {
 			m.Post("/:authid/delete", admin.DeleteAuthSource)
 		})
 
+		m.Group("/keys", func() {
+			m.Get("", admin.Keys)
+			m.Post("/revoke", admin.RevokeKeys)
+			m.Post("/notify", admin.NotifyKeyOwners)
+		})
+
 		m.Group("/notices", func() {
 			m.Get("", admin.Notices)
 			m.Post("/delete", admin.DeleteNotices)
 			m.Get("/empty", admin.EmptyNotices)
 		})
+
+		m.Group("/hooks", func() {
+			m.Get("", admin.Webhooks)
+			m.Post("/delete", admin.DeleteWebhook)
+			m.Get("/:type/new", repo.WebhooksNew)
+			m.Post("/gogs/new", bindIgnErr(form.NewWebhook{}), repo.WebHooksNewPost)
+			m.Post("/slack/new", bindIgnErr(form.NewSlackHook{}), repo.SlackHooksNewPost)
+			m.Post("/discord/new", bindIgnErr(form.NewDiscordHook{}), repo.DiscordHooksNewPost)
+			m.Post("/dingtalk/new", bindIgnErr(form.NewDingtalkHook{}), repo.DingtalkHooksNewPost)
+			m.Post("/matrix/new", bindIgnErr(form.NewMatrixHook{}), repo.MatrixHooksNewPost)
+			m.Post("/telegram/new", bindIgnErr(form.NewTelegramHook{}), repo.TelegramHooksNewPost)
+			m.Get("/:id", repo.WebHooksEdit)
+			m.Post("/:id/redelivery", repo.RedeliveryWebhook)
+			m.Post("/gogs/:id", bindIgnErr(form.NewWebhook{}), repo.WebHooksEditPost)
+			m.Post("/slack/:id", bindIgnErr(form.NewSlackHook{}), repo.SlackHooksEditPost)
+			m.Post("/discord/:id", bindIgnErr(form.NewDiscordHook{}), repo.DiscordHooksEditPost)
+			m.Post("/dingtalk/:id", bindIgnErr(form.NewDingtalkHook{}), repo.DingtalkHooksEditPost)
+			m.Post("/matrix/:id", bindIgnErr(form.NewMatrixHook{}), repo.MatrixHooksEditPost)
+			m.Post("/telegram/:id", bindIgnErr(form.NewTelegramHook{}), repo.TelegramHooksEditPost)
+		}, admin.HooksAssignment)
+
+		m.Group("/autolinks", func() {
+			m.Combo("").Get(repo.Autolinks).
+				Post(bindIgnErr(form.Autolink{}), repo.AutolinksPost)
+			m.Post("/delete", repo.DeleteAutolink)
+		}, admin.AutolinksAssignment)
 	}, reqAdmin)
 	// ***** END: Admin *****
 
@@ -312,18 +380,19 @@ func runWeb(c *cli.Context) error {
 			if err != nil {
 				c.NotFoundOrServerError("GetAttachmentByUUID", db.IsErrAttachmentNotExist, err)
 				return
-			} else if !com.IsFile(attach.LocalPath()) {
-				c.NotFound()
-				return
 			}
 
-			fr, err := os.Open(attach.LocalPath())
+			fr, err := attach.Open()
 			if err != nil {
-				c.ServerError("open attachment file", err)
+				c.NotFoundOrServerError("open attachment file", func(err error) bool { return err == storage.ErrNotExist }, err)
 				return
 			}
 			defer fr.Close()
 
+			if err = attach.IncreaseDownloadCount(); err != nil {
+				log.Error("Failed to increase download count of attachment [uuid: %s]: %v", attach.UUID, err)
+			}
+
 			c.Header().Set("Cache-Control", "public,max-age=86400")
 			c.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s"`, attach.Name))
 
@@ -345,7 +414,11 @@ func runWeb(c *cli.Context) error {
 	}
 
 	reqRepoAdmin := context.RequireRepoAdmin()
-	reqRepoWriter := context.RequireRepoWriter()
+	reqRepoMaintainer := context.RequireRepoMaintainer()
+	reqRepoCodeWriter := context.RequireRepoUnitWriter(db.REPO_UNIT_CODE)
+	reqRepoReleaseWriter := context.RequireRepoUnitWriter(db.REPO_UNIT_RELEASES)
+	reqRepoTriager := context.RequireRepoTriager()
+	reqRepoWikiWriter := context.RequireRepoWikiWriter()
 
 	// ***** START: Organization *****
 	m.Group("/org", func() {
@@ -365,6 +438,25 @@ func runWeb(c *cli.Context) error {
 			m.Get("/members/action/:action", org.MembersAction)
 
 			m.Get("/teams", org.Teams)
+
+			m.Group("/projects", func() {
+				m.Get("", org.Projects)
+				m.Combo("/new").Get(org.NewProject).
+					Post(bindIgnErr(form.CreateProject{}), org.NewProjectPost)
+				m.Post("/delete", org.DeleteProject)
+				m.Group("/:id", func() {
+					m.Get("", org.ViewProject)
+					m.Combo("/edit").Get(org.EditProject).
+						Post(bindIgnErr(form.CreateProject{}), org.EditProjectPost)
+					m.Get("/:action(open|close)", org.ChangeProjectStatus)
+					m.Post("/columns/new", bindIgnErr(form.CreateProjectColumn{}), org.NewProjectColumnPost)
+					m.Post("/columns/delete", org.DeleteProjectColumn)
+					m.Post("/columns/:columnid/edit", bindIgnErr(form.CreateProjectColumn{}), org.EditProjectColumnPost)
+					m.Post("/cards/new", bindIgnErr(form.AddProjectCard{}), org.AddProjectCardPost)
+					m.Post("/cards/:cardid/move", org.MoveProjectCard)
+					m.Post("/cards/:cardid/delete", org.DeleteProjectCard)
+				})
+			})
 		}, context.OrgAssignment(true))
 
 		m.Group("/:org", func() {
@@ -395,13 +487,23 @@ func runWeb(c *cli.Context) error {
 					m.Post("/slack/new", bindIgnErr(form.NewSlackHook{}), repo.SlackHooksNewPost)
 					m.Post("/discord/new", bindIgnErr(form.NewDiscordHook{}), repo.DiscordHooksNewPost)
 					m.Post("/dingtalk/new", bindIgnErr(form.NewDingtalkHook{}), repo.DingtalkHooksNewPost)
+					m.Post("/matrix/new", bindIgnErr(form.NewMatrixHook{}), repo.MatrixHooksNewPost)
+					m.Post("/telegram/new", bindIgnErr(form.NewTelegramHook{}), repo.TelegramHooksNewPost)
 					m.Get("/:id", repo.WebHooksEdit)
+					m.Post("/:id/redelivery", repo.RedeliveryWebhook)
 					m.Post("/gogs/:id", bindIgnErr(form.NewWebhook{}), repo.WebHooksEditPost)
 					m.Post("/slack/:id", bindIgnErr(form.NewSlackHook{}), repo.SlackHooksEditPost)
 					m.Post("/discord/:id", bindIgnErr(form.NewDiscordHook{}), repo.DiscordHooksEditPost)
 					m.Post("/dingtalk/:id", bindIgnErr(form.NewDingtalkHook{}), repo.DingtalkHooksEditPost)
+					m.Post("/matrix/:id", bindIgnErr(form.NewMatrixHook{}), repo.MatrixHooksEditPost)
+					m.Post("/telegram/:id", bindIgnErr(form.NewTelegramHook{}), repo.TelegramHooksEditPost)
 				})
 
+				m.Get("/teams", org.SettingsTeams)
+				m.Combo("/collaborators").Get(org.SettingsCollaborators).
+					Post(org.SettingsCollaboratorsPost)
+				m.Combo("/repo_defaults").Get(org.SettingsRepoDefaults).
+					Post(bindIgnErr(form.OrgRepoDefaults{}), org.SettingsRepoDefaultsPost)
 				m.Route("/delete", "GET,POST", org.SettingsDelete)
 			})
 
@@ -431,12 +533,24 @@ func runWeb(c *cli.Context) error {
 				m.Combo("").Get(repo.SettingsCollaboration).Post(repo.SettingsCollaborationPost)
 				m.Post("/access_mode", repo.ChangeCollaborationAccessMode)
 				m.Post("/delete", repo.DeleteCollaboration)
-			})
+			}, reqRepoAdmin)
 			m.Group("/branches", func() {
 				m.Get("", repo.SettingsBranches)
 				m.Post("/default_branch", repo.UpdateDefaultBranch)
+				m.Post("/ref_names", bindIgnErr(form.RefNameRules{}), repo.UpdateRefNameRules)
 				m.Combo("/*").Get(repo.SettingsProtectedBranch).
 					Post(bindIgnErr(form.ProtectBranch{}), repo.SettingsProtectedBranchPost)
+			}, reqRepoAdmin, func(c *context.Context) {
+				if c.Repo.Repository.IsMirror {
+					c.NotFound()
+					return
+				}
+			})
+
+			m.Group("/deleted_branches", func() {
+				m.Get("", repo.SettingsDeletedBranches)
+				m.Post("/restore", repo.RestoreDeletedBranch)
+				m.Post("/delete", repo.PurgeDeletedBranch)
 			}, func(c *context.Context) {
 				if c.Repo.Repository.IsMirror {
 					c.NotFound()
@@ -444,6 +558,37 @@ func runWeb(c *cli.Context) error {
 				}
 			})
 
+			m.Group("/push_mirrors", func() {
+				m.Combo("").Get(repo.SettingsPushMirrors).
+					Post(bindIgnErr(form.AddPushMirror{}), repo.SettingsPushMirrorsPost)
+				m.Post("/sync", repo.SyncPushMirror)
+				m.Post("/delete", repo.DeletePushMirror)
+			})
+
+			m.Group("/issue_mirror", func() {
+				m.Combo("").Get(repo.SettingsIssueMirror).
+					Post(bindIgnErr(form.IssueMirror{}), repo.SettingsIssueMirrorPost)
+				m.Post("/sync", repo.SyncIssueMirror)
+				m.Post("/delete", repo.DeleteIssueMirror)
+			}, reqRepoAdmin, func(c *context.Context) {
+				if !c.Repo.Repository.EnableIssues || c.Repo.Repository.EnableExternalTracker {
+					c.NotFound()
+					return
+				}
+			})
+
+			m.Group("/autolinks", func() {
+				m.Combo("").Get(repo.Autolinks).
+					Post(bindIgnErr(form.Autolink{}), repo.AutolinksPost)
+				m.Post("/delete", repo.DeleteAutolink)
+			}, reqRepoAdmin)
+
+			m.Group("/migration", func() {
+				m.Get("", repo.SettingsMigration)
+				m.Post("/retry", repo.RetryMigration)
+			})
+			m.Get("/export", repo.SettingsExport)
+
 			m.Group("/hooks", func() {
 				m.Get("", repo.Webhooks)
 				m.Post("/delete", repo.DeleteWebhook)
@@ -452,10 +597,14 @@ func runWeb(c *cli.Context) error {
 				m.Post("/slack/new", bindIgnErr(form.NewSlackHook{}), repo.SlackHooksNewPost)
 				m.Post("/discord/new", bindIgnErr(form.NewDiscordHook{}), repo.DiscordHooksNewPost)
 				m.Post("/dingtalk/new", bindIgnErr(form.NewDingtalkHook{}), repo.DingtalkHooksNewPost)
+				m.Post("/matrix/new", bindIgnErr(form.NewMatrixHook{}), repo.MatrixHooksNewPost)
+				m.Post("/telegram/new", bindIgnErr(form.NewTelegramHook{}), repo.TelegramHooksNewPost)
 				m.Post("/gogs/:id", bindIgnErr(form.NewWebhook{}), repo.WebHooksEditPost)
 				m.Post("/slack/:id", bindIgnErr(form.NewSlackHook{}), repo.SlackHooksEditPost)
 				m.Post("/discord/:id", bindIgnErr(form.NewDiscordHook{}), repo.DiscordHooksEditPost)
 				m.Post("/dingtalk/:id", bindIgnErr(form.NewDingtalkHook{}), repo.DingtalkHooksEditPost)
+				m.Post("/matrix/:id", bindIgnErr(form.NewMatrixHook{}), repo.MatrixHooksEditPost)
+				m.Post("/telegram/:id", bindIgnErr(form.NewTelegramHook{}), repo.TelegramHooksEditPost)
 
 				m.Group("/:id", func() {
 					m.Get("", repo.WebHooksEdit)
@@ -468,25 +617,27 @@ func runWeb(c *cli.Context) error {
 					m.Combo("/:name").Get(repo.SettingsGitHooksEdit).
 						Post(repo.SettingsGitHooksEditPost)
 				}, context.GitHookService())
-			})
+			}, reqRepoAdmin)
 
 			m.Group("/keys", func() {
 				m.Combo("").Get(repo.SettingsDeployKeys).
 					Post(bindIgnErr(form.AddSSHKey{}), repo.SettingsDeployKeysPost)
 				m.Post("/delete", repo.DeleteDeployKey)
-			})
+			}, reqRepoAdmin)
 
 		}, func(c *context.Context) {
 			c.Data["PageIsSettings"] = true
 		})
-	}, reqSignIn, context.RepoAssignment(), reqRepoAdmin, context.RepoRef())
+	}, reqSignIn, context.RepoAssignment(), reqRepoMaintainer, context.RepoRef())
 
 	m.Post("/:username/:reponame/action/:action", reqSignIn, context.RepoAssignment(), repo.Action)
 	m.Group("/:username/:reponame", func() {
 		m.Get("/issues", repo.RetrieveLabels, repo.Issues)
 		m.Get("/issues/:index", repo.ViewIssue)
+		m.Get("/comments/:id/revisions/:revision_id", repo.GetCommentRevision)
 		m.Get("/labels/", repo.RetrieveLabels, repo.Labels)
 		m.Get("/milestones", repo.Milestones)
+		m.Get("/milestones/:id/progress", repo.MilestoneProgress)
 	}, ignSignIn, context.RepoAssignment(true))
 	m.Group("/:username/:reponame", func() {
 		// FIXME: should use different URLs but mostly same logic for comments of issue and pull reuqest.
@@ -498,37 +649,31 @@ func runWeb(c *cli.Context) error {
 			m.Group("/:index", func() {
 				m.Post("/title", repo.UpdateIssueTitle)
 				m.Post("/content", repo.UpdateIssueContent)
+				m.Patch("/task", repo.UpdateIssueTask)
+				m.Post("/watch", repo.WatchIssue)
 				m.Combo("/comments").Post(bindIgnErr(form.CreateComment{}), repo.NewComment)
 			})
 		})
 		m.Group("/comments/:id", func() {
 			m.Post("", repo.UpdateCommentContent)
+			m.Patch("/task", repo.UpdateCommentTask)
 			m.Post("/delete", repo.DeleteComment)
+			m.Post("/resolve", repo.ResolveComment)
+			m.Post("/unresolve", repo.UnresolveComment)
+			m.Post("/revisions/:revision_id/delete", repo.DeleteCommentRevision)
 		})
-	}, reqSignIn, context.RepoAssignment(true))
-	m.Group("/:username/:reponame", func() {
-		m.Group("/wiki", func() {
-			m.Get("/?:page", repo.Wiki)
-			m.Get("/_pages", repo.WikiPages)
-		}, repo.MustEnableWiki, context.RepoRef())
-	}, ignSignIn, context.RepoAssignment(false, true))
-
-	m.Group("/:username/:reponame", func() {
-		// FIXME: should use different URLs but mostly same logic for comments of issue and pull reuqest.
-		// So they can apply their own enable/disable logic on routers.
-		m.Group("/issues", func() {
-			m.Group("/:index", func() {
-				m.Post("/label", repo.UpdateIssueLabel)
-				m.Post("/milestone", repo.UpdateIssueMilestone)
-				m.Post("/assignee", repo.UpdateIssueAssignee)
-			}, reqRepoWriter)
-		})
+		m.Group("/issues/:index", func() {
+			m.Post("/label", repo.UpdateIssueLabel)
+			m.Post("/milestone", repo.UpdateIssueMilestone)
+			m.Post("/assignee", repo.UpdateIssueAssignee)
+			m.Post("/confidential", repo.UpdateIssueConfidential)
+		}, reqRepoTriager)
 		m.Group("/labels", func() {
 			m.Post("/new", bindIgnErr(form.CreateLabel{}), repo.NewLabel)
 			m.Post("/edit", bindIgnErr(form.CreateLabel{}), repo.UpdateLabel)
 			m.Post("/delete", repo.DeleteLabel)
 			m.Post("/initialize", bindIgnErr(form.InitializeLabels{}), repo.InitializeLabels)
-		}, reqRepoWriter, context.RepoRef())
+		}, reqRepoTriager, context.RepoRef())
 		m.Group("/milestones", func() {
 			m.Combo("/new").Get(repo.NewMilestone).
 				Post(bindIgnErr(form.CreateMilestone{}), repo.NewMilestonePost)
@@ -536,15 +681,39 @@ func runWeb(c *cli.Context) error {
 			m.Post("/:id/edit", bindIgnErr(form.CreateMilestone{}), repo.EditMilestonePost)
 			m.Get("/:id/:action", repo.ChangeMilestonStatus)
 			m.Post("/delete", repo.DeleteMilestone)
-		}, reqRepoWriter, context.RepoRef())
+		}, reqRepoTriager, context.RepoRef())
+	}, reqSignIn, context.RepoAssignment(true), repo.MustBeNotArchived)
+	m.Group("/:username/:reponame", func() {
+		m.Group("/wiki", func() {
+			m.Get("/?:page", repo.Wiki)
+			m.Get("/_pages", repo.WikiPages)
+			m.Get("/_raw/*", repo.WikiRaw)
+			m.Get("/_export", repo.ExportWiki)
+			m.Get("/:page/_history", repo.WikiHistory)
+			m.Get("/:page/_compare", repo.WikiCompare)
+			m.Get("/:page/_compare/:sha([a-f0-9]{40})$", repo.WikiCompare)
+			m.Get("/:page/_compare/:before([a-f0-9]{40})\\.\\.\\.:after([a-f0-9]{40})", repo.WikiCompare)
+
+			m.Group("", func() {
+				m.Combo("/_new").Get(repo.NewWiki).
+					Post(bindIgnErr(form.NewWiki{}), repo.NewWikiPost)
+				m.Combo("/:page/_edit").Get(repo.EditWiki).
+					Post(bindIgnErr(form.NewWiki{}), repo.EditWikiPost)
+				m.Post("/:page/delete", repo.DeleteWikiPagePost)
+				m.Post("/:page/_revert", repo.RevertWikiPagePost)
+				m.Post("/_upload", repo.UploadWikiFile)
+			}, reqSignIn, reqRepoWikiWriter, repo.MustBeNotArchived)
+		}, repo.MustEnableWiki, context.RepoRef())
+	}, ignSignIn, context.RepoAssignment(false, true))
 
+	m.Group("/:username/:reponame", func() {
 		m.Group("/releases", func() {
 			m.Get("/new", repo.NewRelease)
 			m.Post("/new", bindIgnErr(form.NewRelease{}), repo.NewReleasePost)
 			m.Post("/delete", repo.DeleteRelease)
 			m.Get("/edit/*", repo.EditRelease)
 			m.Post("/edit/*", bindIgnErr(form.EditRelease{}), repo.EditReleasePost)
-		}, repo.MustBeNotBare, reqRepoWriter, func(c *context.Context) {
+		}, repo.MustBeNotBare, reqRepoReleaseWriter, func(c *context.Context) {
 			c.Data["PageIsViewFiles"] = true
 		})
 
@@ -563,6 +732,10 @@ func runWeb(c *cli.Context) error {
 			m.Post("/_preview/*", bindIgnErr(form.EditPreviewDiff{}), repo.DiffPreviewPost)
 			m.Combo("/_delete/*").Get(repo.DeleteFile).
 				Post(bindIgnErr(form.DeleteRepoFile{}), repo.DeleteFilePost)
+			m.Combo("/_changes/*").Get(repo.StagedChanges).
+				Post(bindIgnErr(form.CommitStagedChanges{}), repo.StagedChangesPost)
+			m.Post("/_unstage/*", repo.UnstageChange)
+			m.Post("/_discard/*", repo.DiscardStagedChanges)
 
 			m.Group("", func() {
 				m.Combo("/_upload/*").Get(repo.UploadFile).
@@ -575,7 +748,7 @@ func runWeb(c *cli.Context) error {
 					return
 				}
 			})
-		}, repo.MustBeNotBare, reqRepoWriter, context.RepoRef(), func(c *context.Context) {
+		}, repo.MustBeNotBare, reqRepoCodeWriter, context.RepoRef(), func(c *context.Context) {
 			if !c.Repo.CanEnableEditor() {
 				c.NotFound()
 				return
@@ -583,7 +756,7 @@ func runWeb(c *cli.Context) error {
 
 			c.Data["PageIsViewFiles"] = true
 		})
-	}, reqSignIn, context.RepoAssignment())
+	}, reqSignIn, context.RepoAssignment(), repo.MustBeNotArchived)
 
 	m.Group("/:username/:reponame", func() {
 		m.Group("", func() {
@@ -595,27 +768,30 @@ func runWeb(c *cli.Context) error {
 		m.Group("/branches", func() {
 			m.Get("", repo.Branches)
 			m.Get("/all", repo.AllBranches)
-			m.Post("/delete/*", reqSignIn, reqRepoWriter, repo.DeleteBranchPost)
+			m.Post("/delete/*", reqSignIn, reqRepoCodeWriter, repo.MustBeNotArchived, repo.DeleteBranchPost)
 		}, repo.MustBeNotBare, func(c *context.Context) {
 			c.Data["PageIsViewFiles"] = true
 		})
 
-		m.Group("/wiki", func() {
-			m.Group("", func() {
-				m.Combo("/_new").Get(repo.NewWiki).
-					Post(bindIgnErr(form.NewWiki{}), repo.NewWikiPost)
-				m.Combo("/:page/_edit").Get(repo.EditWiki).
-					Post(bindIgnErr(form.NewWiki{}), repo.EditWikiPost)
-				m.Post("/:page/delete", repo.DeleteWikiPagePost)
-			}, reqSignIn, reqRepoWriter)
-		}, repo.MustEnableWiki, context.RepoRef())
+		m.Post("/topics", reqSignIn, reqRepoAdmin, repo.TopicsPost)
+
+		m.Group("/packages", func() {
+			m.Get("", repo.Packages)
+			m.Get("/:id", repo.ViewPackage)
+			m.Post("/:id/delete", reqSignIn, reqRepoAdmin, repo.DeletePackage)
+		}, repo.MustEnablePackages)
 
 		m.Get("/archive/*", repo.MustBeNotBare, repo.Download)
+		m.Get("/bundle", repo.MustBeNotBare, repo.DownloadBundle)
 
 		m.Group("/pulls/:index", func() {
 			m.Get("/commits", context.RepoRef(), repo.ViewPullCommits)
 			m.Get("/files", context.RepoRef(), repo.ViewPullFiles)
-			m.Post("/merge", reqRepoWriter, repo.MergePullRequest)
+			m.Get("/checks", context.RepoRef(), repo.ViewPullChecks)
+			m.Post("/merge", reqRepoCodeWriter, repo.MustBeNotArchived, repo.MergePullRequest)
+			m.Post("/restore_branch", reqRepoCodeWriter, repo.MustBeNotArchived, repo.RestorePullBranch)
+			m.Combo("/conflicts", reqSignIn, repo.MustBeNotArchived, context.RepoRef()).Get(repo.ResolveConflicts).
+				Post(repo.ResolveConflictsPost)
 		}, repo.MustAllowPulls)
 
 		m.Group("", func() {
@@ -623,14 +799,30 @@ func runWeb(c *cli.Context) error {
 			m.Get("/raw/*", repo.SingleDownload)
 			m.Get("/commits/*", repo.RefCommits)
 			m.Get("/commit/:sha([a-f0-9]{7,40})$", repo.Diff)
+			m.Get("/commit/:sha([a-f0-9]{7,40})/checks", repo.CommitChecks)
 			m.Get("/forks", repo.Forks)
+			m.Group("/graphs", func() {
+				m.Get("/contributors", repo.GraphContributors)
+				m.Get("/commit-activity", repo.GraphCommitActivity)
+				m.Get("/code-frequency", repo.GraphCodeFrequency)
+			})
 		}, repo.MustBeNotBare, context.RepoRef())
 		m.Get("/commit/:sha([a-f0-9]{7,40})\\.:ext(patch|diff)", repo.MustBeNotBare, repo.RawDiff)
+		m.Group("/commit/:sha([a-f0-9]{7,40})", func() {
+			m.Post("/cherry-pick", bindIgnErr(form.CherryPick{}), repo.CherryPickPost)
+			m.Post("/revert", bindIgnErr(form.CherryPick{}), repo.RevertPost)
+		}, reqSignIn, reqRepoCodeWriter, repo.MustBeNotBare, repo.MustBeNotArchived, context.RepoRef(), func(c *context.Context) {
+			if !c.Repo.Repository.CanEnableEditor() {
+				c.NotFound()
+				return
+			}
+		})
 
 		m.Get("/compare/:before([a-z0-9]{40})\\.\\.\\.:after([a-z0-9]{40})", repo.MustBeNotBare, context.RepoRef(), repo.CompareDiff)
 	}, ignSignIn, context.RepoAssignment())
 	m.Group("/:username/:reponame", func() {
 		m.Get("/stars", repo.Stars)
+		m.Get("/stars/history", repo.StarHistory)
 		m.Get("/watchers", repo.Watchers)
 	}, ignSignIn, context.RepoAssignment(), context.RepoRef())
 
@@ -640,25 +832,51 @@ func runWeb(c *cli.Context) error {
 		m.Group("/:reponame", func() {
 			m.Head("/tasks/trigger", repo.TriggerTask)
 		})
+		lfs := func() {
+			m.Post("/objects/batch", repo.LFSBatch)
+			m.Get("/objects/basic/:oid", repo.LFSDownloadObject)
+			m.Put("/objects/basic/:oid", repo.LFSUploadObject)
+			m.Post("/objects/basic/verify", repo.LFSVerifyObject)
+			m.Combo("/locks").
+				Get(repo.LFSListLocks).
+				Post(repo.LFSCreateLock)
+			m.Post("/locks/verify", repo.LFSVerifyLocks)
+			m.Post("/locks/:id/unlock", repo.LFSUnlock)
+		}
 		// Use the regexp to match the repository name
 		// Duplicated route to enable different ways of accessing same set of URLs,
 		// e.g. with or without ".git" suffix.
 		m.Group("/:reponame([\\d\\w-_\\.]+\\.git$)", func() {
 			m.Get("", ignSignIn, context.RepoAssignment(), context.RepoRef(), repo.Home)
+			m.Group("/info/lfs", lfs, ignSignInAndCsrf, repo.LFSContexter())
 			m.Options("/*", ignSignInAndCsrf, repo.HTTPContexter(), repo.HTTP)
 			m.Route("/*", "GET,POST", ignSignInAndCsrf, repo.HTTPContexter(), repo.HTTP)
 		})
+		m.Group("/:reponame/info/lfs", lfs, ignSignInAndCsrf, repo.LFSContexter())
 		m.Options("/:reponame/*", ignSignInAndCsrf, repo.HTTPContexter(), repo.HTTP)
 		m.Route("/:reponame/*", "GET,POST", ignSignInAndCsrf, repo.HTTPContexter(), repo.HTTP)
 	})
 	// ***** END: Repository *****
 
+	// ***** START: Packages *****
+	m.Group("/api/packages/:username/:reponame", func() {
+		m.Combo("/generic/:name/:version/:filename").
+			Get(repo.DownloadGenericPackageFile).
+			Head(repo.DownloadGenericPackageFile).
+			Put(repo.UploadGenericPackageFile).
+			Delete(repo.DeleteGenericPackageVersion)
+	}, ignSignInAndCsrf, repo.GenericPackageContexter())
+	m.Route("/v2", "GET,HEAD", ignSignInAndCsrf, repo.ContainerRegistry)
+	m.Route("/v2/*", "GET,HEAD,POST,PUT,PATCH,DELETE", ignSignInAndCsrf, repo.ContainerRegistry)
+	// ***** END: Packages *****
+
 	m.Group("/api", func() {
 		apiv1.RegisterRoutes(m)
 	}, ignSignIn)
 
 	m.Group("/-", func() {
 		if conf.Prometheus.Enabled {
+			prometheus.MustRegister(db.NewLoginSourceHealthCollector())
 			m.Get("/metrics", func(c *context.Context) {
 				if !conf.Prometheus.EnableBasicAuth {
 					return
@@ -670,13 +888,14 @@ func runWeb(c *cli.Context) error {
 	})
 
 	// robots.txt
-	m.Get("/robots.txt", func(c *context.Context) {
-		if conf.HasRobotsTxt {
-			c.ServeFileContent(filepath.Join(conf.CustomDir(), "robots.txt"))
-		} else {
-			c.NotFound()
-		}
-	})
+	m.Get("/robots.txt", route.RobotsTxt)
+	if conf.Federation.Enabled {
+		m.Get("/.well-known/webfinger", route.Webfinger)
+	}
+	if conf.SearchEngine.EnableIndexing && conf.SearchEngine.EnableSitemap {
+		m.Get("/sitemap.xml", route.SitemapIndex)
+		m.Get("/sitemap/:type(users|repos|releases)/:page(\\d+)", route.Sitemap)
+	}
 
 	// Not found handler.
 	m.NotFound(route.NotFound)
@@ -28,6 +28,7 @@ import (
 
 	"gogs.io/gogs/internal/assets/conf"
 	"gogs.io/gogs/internal/osutil"
+	"gogs.io/gogs/internal/storage"
 )
 
 func init() {
@@ -92,6 +93,17 @@ func Init(customConf string) error {
 		log.Warn("Custom config %q not found. Ignore this warning if you're running for the first time", customConf)
 	}
 
+	// Settings modified via API may be saved to a separate file that overrides others.
+	if settingsFile := File.Section("admin").Key("SETTINGS_FILE").String(); settingsFile != "" {
+		settingsFile = ensureAbs(settingsFile)
+		File.Section("admin").Key("SETTINGS_FILE").SetValue(settingsFile)
+		if osutil.IsFile(settingsFile) {
+			if err = File.Append(settingsFile); err != nil {
+				return errors.Wrapf(err, "append %q", settingsFile)
+			}
+		}
+	}
+
 	if err = File.Section(ini.DefaultSection).MapTo(&App); err != nil {
 		return errors.Wrap(err, "mapping default section")
 	}
@@ -185,6 +197,25 @@ func Init(customConf string) error {
 	Repository.Root = ensureAbs(Repository.Root)
 	Repository.Upload.TempPath = ensureAbs(Repository.Upload.TempPath)
 
+	Repository.Storage.RootPaths = make(map[string]string, len(Repository.Storage.Roots))
+	for _, root := range Repository.Storage.Roots {
+		fields := strings.SplitN(root, ":", 2)
+		if len(fields) != 2 || strings.TrimSpace(fields[0]) == "" || strings.TrimSpace(fields[1]) == "" {
+			return errors.Errorf("invalid repository storage root %q", root)
+		}
+		Repository.Storage.RootPaths[strings.TrimSpace(fields[0])] = ensureAbs(strings.TrimSpace(fields[1]))
+	}
+
+	if Repository.Signing.SigningKey != "" {
+		switch Repository.Signing.Format {
+		case "openpgp":
+		case "ssh":
+			Repository.Signing.SigningKey = ensureAbs(Repository.Signing.SigningKey)
+		default:
+			return errors.Errorf("invalid repository signing format %q", Repository.Signing.Format)
+		}
+	}
+
 	// *******************************
 	// ----- Database settings -----
 	// *******************************
@@ -353,6 +384,75 @@ func Init(customConf string) error {
 		log.Fatal("Failed to map UI settings: %v", err)
 	} else if err = File.Section("prometheus").MapTo(&Prometheus); err != nil {
 		log.Fatal("Failed to map Prometheus settings: %v", err)
+	} else if err = File.Section("search_engine").MapTo(&SearchEngine); err != nil {
+		log.Fatal("Failed to map Search Engine settings: %v", err)
+	} else if err = File.Section("indexer").MapTo(&Indexer); err != nil {
+		log.Fatal("Failed to map Indexer settings: %v", err)
+	} else if err = File.Section("onboarding").MapTo(&Onboarding); err != nil {
+		log.Fatal("Failed to map Onboarding settings: %v", err)
+	} else if err = File.Section("terms").MapTo(&Terms); err != nil {
+		log.Fatal("Failed to map Terms settings: %v", err)
+	} else if err = File.Section("storage.s3").MapTo(&S3); err != nil {
+		log.Fatal("Failed to map S3 settings: %v", err)
+	} else if err = File.Section("lfs").MapTo(&LFS); err != nil {
+		log.Fatal("Failed to map LFS settings: %v", err)
+	} else if err = File.Section("packages").MapTo(&Packages); err != nil {
+		log.Fatal("Failed to map Packages settings: %v", err)
+	} else if err = File.Section("federation").MapTo(&Federation); err != nil {
+		log.Fatal("Failed to map Federation settings: %v", err)
+	}
+
+	if Indexer.RepoIndexerEnabled {
+		switch Indexer.RepoIndexerType {
+		case "database", "elasticsearch":
+		default:
+			log.Fatal("Unsupported repository indexer type %q", Indexer.RepoIndexerType)
+		}
+	}
+	switch Indexer.IssueIndexerType {
+	case "", "database", "postgres", "elasticsearch":
+	default:
+		log.Fatal("Unsupported issue indexer type %q", Indexer.IssueIndexerType)
+	}
+	switch Indexer.UserIndexerType {
+	case "", "database", "trigram":
+	default:
+		log.Fatal("Unsupported user indexer type %q", Indexer.UserIndexerType)
+	}
+
+	if Terms.Enabled && Terms.Version == "" {
+		log.Fatal("Version of terms must be set when terms are enabled")
+	}
+
+	checkStorage(&Release.Attachment.Storage, "release attachments")
+	checkStorage(&LFS.Storage, "LFS objects")
+	checkStorage(&Packages.Storage, "packages")
+
+	if LFS.ObjectsPath == "" {
+		LFS.ObjectsPath = filepath.Join(Server.AppDataPath, "lfs-objects")
+	}
+	if !filepath.IsAbs(LFS.ObjectsPath) {
+		LFS.ObjectsPath = path.Join(workDir, LFS.ObjectsPath)
+	}
+
+	if Packages.StoragePath == "" {
+		Packages.StoragePath = filepath.Join(Server.AppDataPath, "packages")
+	}
+	if !filepath.IsAbs(Packages.StoragePath) {
+		Packages.StoragePath = path.Join(workDir, Packages.StoragePath)
+	}
+	if Packages.TempPath == "" {
+		Packages.TempPath = filepath.Join(Server.AppDataPath, "tmp", "packages")
+	}
+	if !filepath.IsAbs(Packages.TempPath) {
+		Packages.TempPath = path.Join(workDir, Packages.TempPath)
+	}
+
+	if Git.BundlePath == "" {
+		Git.BundlePath = filepath.Join(Server.AppDataPath, "bundles")
+	}
+	if !filepath.IsAbs(Git.BundlePath) {
+		Git.BundlePath = path.Join(workDir, Git.BundlePath)
 	}
 
 	if Mirror.DefaultInterval <= 0 {
@@ -370,6 +470,22 @@ func Init(customConf string) error {
 	return nil
 }
 
+// checkStorage validates the name of blob store that the files are saved to, and
+// defaults to the local store if not set.
+func checkStorage(name *string, files string) {
+	switch *name {
+	case "":
+		*name = storage.LOCAL
+	case storage.LOCAL:
+	case storage.S3:
+		if S3.Endpoint == "" || S3.Bucket == "" {
+			log.Fatal("Endpoint and bucket of S3 storage must be set when it is used")
+		}
+	default:
+		log.Fatal("Unsupported storage of %s: %q", files, *name)
+	}
+}
+
 // MustInit panics if configuration initialization failed.
 func MustInit(customConf string) {
 	err := Init(customConf)
@@ -408,14 +524,51 @@ var (
 			AllowedTypes []string `delim:"|"`
 			MaxSize      int64
 			MaxFiles     int
+			// Storage is the name of blob store that release attachments are saved to.
+			Storage string
 		} `ini:"-"`
 	}
 
+	// Amazon S3 compatible storage settings
+	S3 struct {
+		Endpoint        string
+		Region          string
+		Bucket          string
+		AccessKeyID     string `ini:"ACCESS_KEY_ID"`
+		SecretAccessKey string
+		PathStyle       bool
+	}
+
+	// Git LFS settings
+	LFS struct {
+		Enabled bool
+		// Storage is the name of blob store that LFS objects are saved to.
+		Storage     string
+		ObjectsPath string
+		MaxFileSize int64
+		MaxRepoSize int64
+	}
+
+	// Package registry settings
+	Packages struct {
+		Enabled bool
+		// Storage is the name of blob store that package files are saved to.
+		Storage     string
+		StoragePath string
+		// TempPath is where uploads in progress are saved to before they are complete.
+		TempPath    string
+		MaxFileSize int64
+	}
+
 	// Markdown sttings
 	Markdown struct {
 		EnableHardLineBreak bool
 		CustomURLSchemes    []string `ini:"CUSTOM_URL_SCHEMES"`
 		FileExtensions      []string
+		EnableMath          bool
+		EnableMermaid       bool
+		KaTeXURL            string `ini:"KATEX_URL"`
+		MermaidURL          string `ini:"MERMAID_URL"`
 	}
 
 	// Smartypants settings
@@ -430,6 +583,9 @@ var (
 	// Admin settings
 	Admin struct {
 		DisableRegularOrgCreation bool
+		// SettingsFile is the file that settings modified via API are saved to, default
+		// is the custom configuration file.
+		SettingsFile string
 	}
 
 	// Picture settings
@@ -489,6 +645,69 @@ var (
 			Schedule   string
 			OlderThan  time.Duration
 		} `ini:"cron.repo_archive_cleanup"`
+		PruneTables struct {
+			Enabled                 bool
+			RunAtStart              bool
+			Schedule                string
+			HookTaskRetention       time.Duration
+			ActionRetention         time.Duration
+			NoticeRetention         time.Duration
+			MaintenanceJobRetention time.Duration
+			BatchSize               int
+			BatchInterval           time.Duration
+		} `ini:"cron.prune_tables"`
+		ReviewReminders struct {
+			Enabled     bool
+			RunAtStart  bool
+			Schedule    string
+			PendingDays int
+		} `ini:"cron.review_reminders"`
+		CheckPullMergeability struct {
+			Enabled    bool
+			RunAtStart bool
+			Schedule   string
+		} `ini:"cron.check_pull_mergeability"`
+		SendDigests struct {
+			Enabled    bool
+			RunAtStart bool
+			Schedule   string
+			StaleDays  int
+		} `ini:"cron.send_digests"`
+		SendNotificationDigests struct {
+			Enabled    bool
+			RunAtStart bool
+			Schedule   string
+		} `ini:"cron.send_notification_digests"`
+		RepoGC struct {
+			Enabled      bool
+			RunAtStart   bool
+			Schedule     string
+			LooseObjects int64
+			Packs        int64
+			MaxAge       time.Duration
+		} `ini:"cron.repo_gc"`
+		GenerateBundles struct {
+			Enabled     bool
+			RunAtStart  bool
+			Schedule    string
+			MinRepoSize int64
+		} `ini:"cron.generate_bundles"`
+		CheckLoginSources struct {
+			Enabled    bool
+			RunAtStart bool
+			Schedule   string
+		} `ini:"cron.check_login_sources"`
+		PurgeDeletedBranches struct {
+			Enabled    bool
+			RunAtStart bool
+			Schedule   string
+			Retention  time.Duration
+		} `ini:"cron.purge_deleted_branches"`
+		UpdateTrendingRepos struct {
+			Enabled    bool
+			RunAtStart bool
+			Schedule   string
+		} `ini:"cron.update_trending_repos"`
 	}
 
 	// Git settings
@@ -499,12 +718,17 @@ var (
 		MaxGitDiffLineCharacters int
 		MaxGitDiffFiles          int
 		GCArgs                   []string `ini:"GC_ARGS" delim:" "`
+		DisablePartialClone      bool
+		BundlePath               string
 		Timeout                  struct {
 			Migrate int
 			Mirror  int
 			Clone   int
 			Pull    int
 			GC      int `ini:"GC"`
+			Bundle  int
+			Stats   int
+			Archive int
 		} `ini:"git.timeout"`
 	}
 
@@ -520,17 +744,19 @@ var (
 
 	// UI settings
 	UI struct {
-		ExplorePagingNum   int
-		IssuePagingNum     int
-		FeedMaxCommitNum   int
-		ThemeColorMetaTag  string
-		MaxDisplayFileSize int64
+		ExplorePagingNum       int
+		IssuePagingNum         int
+		FeedMaxCommitNum       int
+		ThemeColorMetaTag      string
+		MaxDisplayFileSize     int64
+		MaxRichDisplayFileSize int64
 
 		Admin struct {
 			UserPagingNum   int
 			RepoPagingNum   int
 			NoticePagingNum int
 			OrgPagingNum    int
+			KeyPagingNum    int
 		} `ini:"ui.admin"`
 		User struct {
 			RepoPagingNum     int
@@ -547,6 +773,49 @@ var (
 		BasicAuthPassword string
 	}
 
+	// Search engine settings
+	SearchEngine struct {
+		EnableIndexing   bool
+		EnableSitemap    bool
+		SitemapPagingNum int
+		CrawlDelay       int
+	}
+
+	// Indexer settings
+	Indexer struct {
+		RepoIndexerEnabled            bool
+		RepoIndexerType               string
+		RepoIndexerElasticsearchURL   string `ini:"REPO_INDEXER_ELASTICSEARCH_URL"`
+		RepoIndexerElasticsearchIndex string
+		MaxFileSize                   int64
+
+		IssueIndexerType               string
+		IssueIndexerElasticsearchURL   string `ini:"ISSUE_INDEXER_ELASTICSEARCH_URL"`
+		IssueIndexerElasticsearchIndex string
+
+		UserIndexerType string
+	}
+
+	// Onboarding settings
+	Onboarding struct {
+		Enabled            bool
+		WelcomeTitle       string
+		WelcomeDescription string
+		WelcomeLink        string
+	}
+
+	// Terms settings
+	Terms struct {
+		Enabled   bool
+		Version   string
+		Documents []string
+	}
+
+	// Federation settings
+	Federation struct {
+		Enabled bool
+	}
+
 	// I18n settings
 	Langs     []string
 	Names     []string
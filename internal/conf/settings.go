@@ -0,0 +1,332 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package conf
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"gopkg.in/ini.v1"
+
+	"gogs.io/gogs/internal/osutil"
+)
+
+// Types of settings, which are named after JSON schema types.
+const (
+	SettingTypeBoolean = "boolean"
+	SettingTypeInteger = "integer"
+	SettingTypeString  = "string"
+)
+
+// Setting is a configuration option that is safe to be modified at runtime, i.e. its
+// value is read every time it is used.
+type Setting struct {
+	Section     string
+	Key         string
+	Type        string
+	Description string
+	// Enum is the list of allowed values of a string setting, empty means any value.
+	Enum []string
+	// Minimum and Maximum are bounds of an integer setting, zero Maximum means no upper bound.
+	Minimum int64
+	Maximum int64
+
+	// ptr points to the runtime value, which is one of *bool, *int, *int64 and *string.
+	ptr interface{}
+}
+
+// Name returns the name of the setting in the form of "<section>.<KEY>".
+func (s *Setting) Name() string {
+	return s.Section + "." + s.Key
+}
+
+// Value returns the current runtime value of the setting.
+func (s *Setting) Value() interface{} {
+	switch p := s.ptr.(type) {
+	case *bool:
+		return *p
+	case *int:
+		return int64(*p)
+	case *int64:
+		return *p
+	case *string:
+		return *p
+	}
+	return nil
+}
+
+// parse validates and converts a value decoded from JSON for the setting.
+func (s *Setting) parse(v interface{}) (interface{}, error) {
+	switch s.Type {
+	case SettingTypeBoolean:
+		b, ok := v.(bool)
+		if !ok {
+			return nil, errors.New("must be a boolean")
+		}
+		return b, nil
+
+	case SettingTypeInteger:
+		f, ok := v.(float64)
+		if !ok || f != math.Trunc(f) {
+			return nil, errors.New("must be an integer")
+		}
+		n := int64(f)
+		if n < s.Minimum {
+			return nil, errors.Errorf("must be greater than or equal to %d", s.Minimum)
+		} else if s.Maximum > 0 && n > s.Maximum {
+			return nil, errors.Errorf("must be less than or equal to %d", s.Maximum)
+		}
+		return n, nil
+
+	case SettingTypeString:
+		str, ok := v.(string)
+		if !ok {
+			return nil, errors.New("must be a string")
+		}
+		if len(s.Enum) > 0 {
+			for _, e := range s.Enum {
+				if e == str {
+					return str, nil
+				}
+			}
+			return nil, errors.Errorf("must be one of %q", s.Enum)
+		}
+		return str, nil
+	}
+	return nil, errors.Errorf("unsupported type %q", s.Type)
+}
+
+// set updates the runtime value of the setting.
+func (s *Setting) set(v interface{}) {
+	switch p := s.ptr.(type) {
+	case *bool:
+		*p = v.(bool)
+	case *int:
+		*p = int(v.(int64))
+	case *int64:
+		*p = v.(int64)
+	case *string:
+		*p = v.(string)
+	}
+}
+
+// formatSettingValue returns the string representation of a setting value in the
+// configuration file.
+func formatSettingValue(v interface{}) string {
+	switch v := v.(type) {
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	}
+	return fmt.Sprint(v)
+}
+
+// Settings returns all settings that can be modified at runtime in the order of names.
+func Settings() []*Setting {
+	settings := []*Setting{
+		{Section: "repository", Key: "FORCE_PRIVATE", Type: SettingTypeBoolean, ptr: &Repository.ForcePrivate,
+			Description: "Whether to force every new repository to be private."},
+		{Section: "repository", Key: "MAX_CREATION_LIMIT", Type: SettingTypeInteger, Minimum: -1, ptr: &Repository.MaxCreationLimit,
+			Description: "Global maximum creation limit of repositories per user, -1 means no limit."},
+		{Section: "repository", Key: "LICENSE_PUSH_POLICY", Type: SettingTypeString, Enum: []string{"", "warn", "block"}, ptr: &Repository.LicensePushPolicy,
+			Description: "What to do when a push replaces the LICENSE file with a license that is not allowed."},
+		{Section: "auth", Key: "DISABLE_REGISTRATION", Type: SettingTypeBoolean, ptr: &Auth.DisableRegistration,
+			Description: "Whether to disallow anonymous users to register new accounts."},
+		{Section: "auth", Key: "ACTIVATE_CODE_LIVES", Type: SettingTypeInteger, Minimum: 1, ptr: &Auth.ActivateCodeLives,
+			Description: "The valid duration of account activation codes in minutes."},
+		{Section: "auth", Key: "RESET_PASSWORD_CODE_LIVES", Type: SettingTypeInteger, Minimum: 1, ptr: &Auth.ResetPasswordCodeLives,
+			Description: "The valid duration of password reset codes in minutes."},
+		{Section: "user", Key: "ENABLE_EMAIL_NOTIFICATION", Type: SettingTypeBoolean, ptr: &User.EnableEmailNotification,
+			Description: "Whether to enable email notifications for users."},
+		{Section: "admin", Key: "DISABLE_REGULAR_ORG_CREATION", Type: SettingTypeBoolean, ptr: &Admin.DisableRegularOrgCreation,
+			Description: "Whether to disallow regular (non-admin) users to create organizations."},
+		{Section: "webhook", Key: "DELIVER_TIMEOUT", Type: SettingTypeInteger, Minimum: 1, ptr: &Webhook.DeliverTimeout,
+			Description: "Timeout of webhook deliveries in seconds."},
+		{Section: "webhook", Key: "SKIP_TLS_VERIFY", Type: SettingTypeBoolean, ptr: &Webhook.SkipTLSVerify,
+			Description: "Whether to allow insecure certificates of webhook endpoints."},
+		{Section: "webhook", Key: "PAGING_NUM", Type: SettingTypeInteger, Minimum: 1, ptr: &Webhook.PagingNum,
+			Description: "Number of webhook history items to display per page."},
+		{Section: "git", Key: "MAX_GIT_DIFF_LINES", Type: SettingTypeInteger, Minimum: 1, ptr: &Git.MaxGitDiffLines,
+			Description: "Maximum number of lines allowed of a single file in diff view."},
+		{Section: "git", Key: "MAX_GIT_DIFF_LINE_CHARACTERS", Type: SettingTypeInteger, Minimum: 1, ptr: &Git.MaxGitDiffLineCharacters,
+			Description: "Maximum number of characters of a line allowed in diff view."},
+		{Section: "git", Key: "MAX_GIT_DIFF_FILES", Type: SettingTypeInteger, Minimum: 1, ptr: &Git.MaxGitDiffFiles,
+			Description: "Maximum number of files allowed to be displayed in diff view."},
+		{Section: "mirror", Key: "DEFAULT_INTERVAL", Type: SettingTypeInteger, Minimum: 1, ptr: &Mirror.DefaultInterval,
+			Description: "Default interval in hours between each mirror synchronization of new mirrors."},
+		{Section: "api", Key: "MAX_RESPONSE_ITEMS", Type: SettingTypeInteger, Minimum: 1, ptr: &API.MaxResponseItems,
+			Description: "Maximum number of items per page of API responses."},
+		{Section: "ui", Key: "EXPLORE_PAGING_NUM", Type: SettingTypeInteger, Minimum: 1, ptr: &UI.ExplorePagingNum,
+			Description: "Number of repositories to display per page of explore pages."},
+		{Section: "ui", Key: "ISSUE_PAGING_NUM", Type: SettingTypeInteger, Minimum: 1, ptr: &UI.IssuePagingNum,
+			Description: "Number of issues to display per page."},
+		{Section: "ui", Key: "FEED_MAX_COMMIT_NUM", Type: SettingTypeInteger, Minimum: 1, ptr: &UI.FeedMaxCommitNum,
+			Description: "Maximum number of commits to display of a push in activity feeds."},
+		{Section: "ui", Key: "THEME_COLOR_META_TAG", Type: SettingTypeString, ptr: &UI.ThemeColorMetaTag,
+			Description: "Value of \"theme-color\" meta tag."},
+		{Section: "ui", Key: "MAX_DISPLAY_FILE_SIZE", Type: SettingTypeInteger, Minimum: 1, ptr: &UI.MaxDisplayFileSize,
+			Description: "Maximum size in bytes of files to be displayed."},
+		{Section: "ui", Key: "MAX_RICH_DISPLAY_FILE_SIZE", Type: SettingTypeInteger, Minimum: 1, ptr: &UI.MaxRichDisplayFileSize,
+			Description: "Maximum size in bytes of notebooks, CSV, STL and GeoJSON files to be rendered."},
+		{Section: "ui.admin", Key: "USER_PAGING_NUM", Type: SettingTypeInteger, Minimum: 1, ptr: &UI.Admin.UserPagingNum,
+			Description: "Number of users to display per page of admin panel."},
+		{Section: "ui.admin", Key: "REPO_PAGING_NUM", Type: SettingTypeInteger, Minimum: 1, ptr: &UI.Admin.RepoPagingNum,
+			Description: "Number of repositories to display per page of admin panel."},
+		{Section: "ui.admin", Key: "NOTICE_PAGING_NUM", Type: SettingTypeInteger, Minimum: 1, ptr: &UI.Admin.NoticePagingNum,
+			Description: "Number of system notices to display per page of admin panel."},
+		{Section: "ui.admin", Key: "ORG_PAGING_NUM", Type: SettingTypeInteger, Minimum: 1, ptr: &UI.Admin.OrgPagingNum,
+			Description: "Number of organizations to display per page of admin panel."},
+		{Section: "ui.user", Key: "REPO_PAGING_NUM", Type: SettingTypeInteger, Minimum: 1, ptr: &UI.User.RepoPagingNum,
+			Description: "Number of repositories to display per page of user pages."},
+		{Section: "ui.user", Key: "NEWS_FEED_PAGING_NUM", Type: SettingTypeInteger, Minimum: 1, ptr: &UI.User.NewsFeedPagingNum,
+			Description: "Number of activities to display per page of news feeds."},
+		{Section: "ui.user", Key: "COMMITS_PAGING_NUM", Type: SettingTypeInteger, Minimum: 1, ptr: &UI.User.CommitsPagingNum,
+			Description: "Number of commits to display per page of commit history."},
+		{Section: "other", Key: "SHOW_FOOTER_BRANDING", Type: SettingTypeBoolean, ptr: &ShowFooterBranding,
+			Description: "Whether to show Gogs branding in the footer."},
+		{Section: "other", Key: "SHOW_FOOTER_TEMPLATE_LOAD_TIME", Type: SettingTypeBoolean, ptr: &ShowFooterTemplateLoadTime,
+			Description: "Whether to show time of template execution in the footer."},
+	}
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Name() < settings[j].Name() })
+	return settings
+}
+
+// SettingsSchema returns the JSON schema of the object to modify settings, which is
+// keyed by names of settings.
+func SettingsSchema() map[string]interface{} {
+	properties := make(map[string]interface{})
+	for _, s := range Settings() {
+		prop := map[string]interface{}{
+			"type":        s.Type,
+			"description": s.Description,
+		}
+		if len(s.Enum) > 0 {
+			prop["enum"] = s.Enum
+		}
+		if s.Type == SettingTypeInteger {
+			prop["minimum"] = s.Minimum
+			if s.Maximum > 0 {
+				prop["maximum"] = s.Maximum
+			}
+		}
+		properties[s.Name()] = prop
+	}
+	return map[string]interface{}{
+		"$schema":              "http://json-schema.org/draft-07/schema#",
+		"title":                "Gogs settings",
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+// SettingsFile returns the absolute path of the file that modified settings are saved to.
+func SettingsFile() string {
+	if Admin.SettingsFile != "" {
+		return Admin.SettingsFile
+	}
+	return CustomConf
+}
+
+// SettingChange is a change to the value of a setting.
+type SettingChange struct {
+	Setting  *Setting
+	OldValue interface{}
+	NewValue interface{}
+}
+
+// SettingErrors contains validation errors of settings.
+type SettingErrors []string
+
+func (errs SettingErrors) Error() string {
+	return strings.Join(errs, "; ")
+}
+
+// PlanSettings validates new values of settings keyed by their names and returns
+// changes to be made in the order of names, values that are not changed are skipped.
+// It returns SettingErrors if any value is invalid.
+func PlanSettings(values map[string]interface{}) ([]*SettingChange, error) {
+	var errs SettingErrors
+	settings := make(map[string]*Setting)
+	for _, s := range Settings() {
+		settings[s.Name()] = s
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	changes := make([]*SettingChange, 0, len(names))
+	for _, name := range names {
+		s := settings[name]
+		if s == nil {
+			errs = append(errs, fmt.Sprintf("%s: unknown or read-only setting", name))
+			continue
+		}
+
+		v, err := s.parse(values[name])
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		if old := s.Value(); old != v {
+			changes = append(changes, &SettingChange{
+				Setting:  s,
+				OldValue: old,
+				NewValue: v,
+			})
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return changes, nil
+}
+
+var settingsLock sync.Mutex
+
+// ApplySettings saves changes to the settings file and applies them at runtime.
+func ApplySettings(changes []*SettingChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	settingsLock.Lock()
+	defer settingsLock.Unlock()
+
+	// Load the file alone to not write default values or values from other files.
+	path := SettingsFile()
+	cfg := ini.Empty()
+	if osutil.IsFile(path) {
+		if err := cfg.Append(path); err != nil {
+			return errors.Wrapf(err, "load %q", path)
+		}
+	}
+	for _, c := range changes {
+		cfg.Section(c.Setting.Section).Key(c.Setting.Key).SetValue(formatSettingValue(c.NewValue))
+	}
+
+	_ = os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	if err := cfg.SaveTo(path); err != nil {
+		return errors.Wrapf(err, "save %q", path)
+	}
+
+	for _, c := range changes {
+		File.Section(c.Setting.Section).Key(c.Setting.Key).SetValue(formatSettingValue(c.NewValue))
+		c.Setting.set(c.NewValue)
+	}
+	return nil
+}
@@ -103,10 +103,13 @@ var (
 		ForcePrivate             bool
 		MaxCreationLimit         int
 		PreferredLicenses        []string
+		AllowedLicenses          []string
+		LicensePushPolicy        string
 		DisableHTTPGit           bool `ini:"DISABLE_HTTP_GIT"`
 		EnableLocalPathMigration bool
 		EnableRawFileRenderMode  bool
 		CommitsFetchConcurrency  int
+		StarNotifyThresholds     []int
 
 		// Repository editor settings
 		Editor struct {
@@ -114,6 +117,14 @@ var (
 			PreviewableFileModes []string
 		} `ini:"repository.editor"`
 
+		// Repository storage settings
+		Storage struct {
+			Roots []string
+
+			// Derived from other static values
+			RootPaths map[string]string `ini:"-"` // Parsed absolute paths of named storage roots.
+		} `ini:"repository.storage"`
+
 		// Repository upload settings
 		Upload struct {
 			Enabled      bool
@@ -122,6 +133,20 @@ var (
 			FileMaxSize  int64
 			MaxFiles     int
 		} `ini:"repository.upload"`
+
+		// Repository bundle download settings
+		Bundle struct {
+			Enabled       bool
+			MaxConcurrent int
+			MaxPerHour    int
+		} `ini:"repository.bundle"`
+
+		// Repository commit signing settings
+		Signing struct {
+			SigningKey string
+			Format     string
+			SignOn     []string
+		} `ini:"repository.signing"`
 	}
 
 	// Database settings
@@ -210,6 +235,9 @@ var (
 	// User settings
 	User struct {
 		EnableEmailNotification bool
+		// DeactivationCooldownDays is the number of days that users can reactivate
+		// their own deactivated accounts, zero means no limit.
+		DeactivationCooldownDays int
 	}
 )
 
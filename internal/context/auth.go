@@ -17,6 +17,19 @@ import (
 	"gogs.io/gogs/internal/tool"
 )
 
+// isTermsExempted returns true if the request is allowed before the user accepts the terms.
+func isTermsExempted(c *Context) bool {
+	switch c.Req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+
+	path := c.Req.URL.Path
+	return path == "/user/terms" || path == "/user/logout" ||
+		// Git over HTTP checks the terms by itself for pushes only.
+		strings.HasSuffix(path, "/git-upload-pack") || strings.HasSuffix(path, "/git-receive-pack")
+}
+
 type ToggleOptions struct {
 	SignInRequired  bool
 	SignOutRequired bool
@@ -51,7 +64,8 @@ func Toggle(options *ToggleOptions) macaron.Handler {
 			return
 		}
 
-		if !options.SignOutRequired && !options.DisableCSRF && c.Req.Method == "POST" && !auth.IsAPIPath(c.Req.URL.Path) {
+		if !options.SignOutRequired && !options.DisableCSRF &&
+			(c.Req.Method == http.MethodPost || c.Req.Method == http.MethodPatch) && !auth.IsAPIPath(c.Req.URL.Path) {
 			csrf.Validate(c.Context, c.csrf)
 			if c.Written() {
 				return
@@ -78,6 +92,23 @@ func Toggle(options *ToggleOptions) macaron.Handler {
 			}
 		}
 
+		// Require the user to accept the current terms before making changes.
+		if c.IsLogged && !c.User.HasAcceptedTerms() {
+			c.Data["TermsNotAccepted"] = true
+			if !isTermsExempted(c) {
+				if auth.IsAPIPath(c.Req.URL.Path) {
+					c.JSON(http.StatusForbidden, map[string]string{
+						"message": "You must accept the terms before making changes: " + conf.Server.ExternalURL + "user/terms",
+					})
+					return
+				}
+
+				c.Flash.Warning(c.Tr("terms.required"))
+				c.Redirect(conf.Server.Subpath + "/user/terms")
+				return
+			}
+		}
+
 		// Redirect to log in page if auto-signin info is provided and has not signed in.
 		if !options.SignOutRequired && !c.IsLogged && !auth.IsAPIPath(c.Req.URL.Path) &&
 			len(c.GetCookie(conf.Security.CookieUsername)) > 0 {
@@ -305,6 +305,14 @@ func Contexter() macaron.Handler {
 			c.Data["LoggedUserID"] = c.User.ID
 			c.Data["LoggedUserName"] = c.User.Name
 			c.Data["IsAdmin"] = c.User.IsAdmin
+
+			if !auth.IsAPIPath(c.Req.URL.Path) {
+				count, err := db.CountUnreadNotifications(c.User.ID)
+				if err != nil {
+					log.Error("Failed to count unread notifications [user_id: %d]: %v", c.User.ID, err)
+				}
+				c.Data["UnreadNotificationCount"] = count
+			}
 		} else {
 			c.Data["LoggedUserID"] = 0
 			c.Data["LoggedUserName"] = ""
@@ -325,6 +333,11 @@ func Contexter() macaron.Handler {
 
 		c.Data["ShowRegistrationButton"] = !conf.Auth.DisableRegistration
 		c.Data["ShowFooterBranding"] = conf.ShowFooterBranding
+		c.Data["IsCodeIndexerEnabled"] = db.IsCodeIndexerEnabled()
+		if !conf.SearchEngine.EnableIndexing {
+			c.Data["DisableIndexing"] = true
+			c.Resp.Header().Set("X-Robots-Tag", "noindex, nofollow")
+		}
 
 		c.renderNoticeBanner()
 
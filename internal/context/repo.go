@@ -29,6 +29,7 @@ type PullRequest struct {
 
 type Repository struct {
 	AccessMode   db.AccessMode
+	UnitModes    db.UnitAccessModes
 	IsWatching   bool
 	IsViewBranch bool
 	IsViewTag    bool
@@ -60,11 +61,47 @@ func (r *Repository) IsAdmin() bool {
 	return r.AccessMode >= db.ACCESS_MODE_ADMIN
 }
 
+// IsMaintainer returns true if current user has maintain or higher access of repository.
+func (r *Repository) IsMaintainer() bool {
+	return r.AccessMode >= db.ACCESS_MODE_MAINTAIN
+}
+
 // IsWriter returns true if current user has write or higher access of repository.
 func (r *Repository) IsWriter() bool {
 	return r.AccessMode >= db.ACCESS_MODE_WRITE
 }
 
+// UnitMode returns the access mode of current user to the unit of repository.
+func (r *Repository) UnitMode(unit db.RepoUnit) db.AccessMode {
+	return r.UnitModes.Get(unit, r.AccessMode)
+}
+
+// CanWrite returns true if current user has write or higher access to the unit of repository.
+func (r *Repository) CanWrite(unit db.RepoUnit) bool {
+	return r.UnitMode(unit) >= db.ACCESS_MODE_WRITE
+}
+
+// CanReadCode returns true if current user can read code of repository.
+func (r *Repository) CanReadCode() bool {
+	return r.UnitMode(db.REPO_UNIT_CODE) >= db.ACCESS_MODE_READ
+}
+
+// CanEditWiki returns true if current user can edit wiki of repository.
+func (r *Repository) CanEditWiki() bool {
+	return r.Repository.CanAccessWiki(r.UnitMode(db.REPO_UNIT_WIKI), db.ACCESS_MODE_WRITE)
+}
+
+// IsTriager returns true if current user has triage or higher access to issues of repository.
+func (r *Repository) IsTriager() bool {
+	return r.UnitMode(db.REPO_UNIT_ISSUES) >= db.ACCESS_MODE_TRIAGE
+}
+
+// CanSeeIssue returns true if the user can see the issue, confidential issues are only
+// visible to their posters and users who can triage issues of the repository.
+func (r *Repository) CanSeeIssue(issue *db.Issue, userID int64) bool {
+	return !issue.IsConfidential || r.IsTriager() || (userID > 0 && issue.IsPoster(userID))
+}
+
 // HasAccess returns true if the current user has at least read access for this repository
 func (r *Repository) HasAccess() bool {
 	return r.AccessMode >= db.ACCESS_MODE_READ
@@ -72,7 +109,7 @@ func (r *Repository) HasAccess() bool {
 
 // CanEnableEditor returns true if repository is editable and user has proper access level.
 func (r *Repository) CanEnableEditor() bool {
-	return r.Repository.CanEnableEditor() && r.IsViewBranch && r.IsWriter() && !r.Repository.IsBranchRequirePullRequest(r.BranchName)
+	return r.Repository.CanEnableEditor() && r.IsViewBranch && r.CanWrite(db.REPO_UNIT_CODE) && !r.Repository.IsBranchRequirePullRequest(r.BranchName)
 }
 
 // GetEditorconfig returns the .editorconfig definition if found in the
@@ -183,6 +220,12 @@ func RepoAssignment(pages ...bool) macaron.Handler {
 				return
 			}
 			c.Repo.AccessMode = mode
+
+			c.Repo.UnitModes, err = db.UserUnitAccessModes(c.UserID(), repo)
+			if err != nil {
+				c.ServerError("UserUnitAccessModes", err)
+				return
+			}
 		}
 
 		// Check access
@@ -213,6 +256,22 @@ func RepoAssignment(pages ...bool) macaron.Handler {
 
 			c.Repo.Repository.EnableIssues = repo.CanGuestViewIssues()
 			c.Repo.Repository.EnableWiki = repo.CanGuestViewWiki()
+		} else if !c.Repo.CanReadCode() {
+			// Users without access to code can only participate in issues, and read
+			// wiki if they are allowed to.
+			canReadWiki := c.Repo.UnitMode(db.REPO_UNIT_WIKI) >= db.ACCESS_MODE_READ
+			if !isIssuesPage && !(isWikiPage && canReadWiki) {
+				switch {
+				case repo.EnableIssues && !repo.EnableExternalTracker:
+					c.Redirect(repo.Link() + "/issues")
+				case repo.EnableWiki && canReadWiki:
+					c.Redirect(repo.Link() + "/wiki")
+				default:
+					c.NotFound()
+				}
+				return
+			}
+			c.Repo.Repository.EnableWiki = repo.EnableWiki && canReadWiki
 		}
 
 		if repo.IsMirror {
@@ -244,17 +303,31 @@ func RepoAssignment(pages ...bool) macaron.Handler {
 		c.Data["Title"] = owner.Name + "/" + repo.Name
 		c.Data["Repository"] = repo
 		c.Data["Owner"] = c.Repo.Repository.Owner
+		if repo.DisableIndexing {
+			c.Data["DisableIndexing"] = true
+			c.Resp.Header().Set("X-Robots-Tag", "noindex, nofollow")
+		}
 		c.Data["IsRepositoryOwner"] = c.Repo.IsOwner()
 		c.Data["IsRepositoryAdmin"] = c.Repo.IsAdmin()
-		c.Data["IsRepositoryWriter"] = c.Repo.IsWriter()
+		c.Data["IsRepositoryMaintainer"] = c.Repo.IsMaintainer()
+		// Archived repository is read-only, hide operations that require write access.
+		c.Data["IsRepositoryWriter"] = c.Repo.CanWrite(db.REPO_UNIT_CODE) && !repo.IsArchived
+		c.Data["IsReleaseWriter"] = c.Repo.CanWrite(db.REPO_UNIT_RELEASES) && !repo.IsArchived
+		c.Data["IsRepositoryTriager"] = c.Repo.IsTriager() && !repo.IsArchived
+		c.Data["CanReadCode"] = c.Repo.CanReadCode()
+		c.Data["IsWikiWriter"] = c.IsLogged && c.Repo.CanEditWiki() && !repo.IsArchived
 
 		c.Data["DisableSSH"] = conf.SSH.Disabled
 		c.Data["DisableHTTP"] = conf.Repository.DisableHTTPGit
 		c.Data["CloneLink"] = repo.CloneLink()
+		c.Data["BundleEnabled"] = conf.Repository.Bundle.Enabled
 		c.Data["WikiCloneLink"] = repo.WikiCloneLink()
+		c.Data["PackagesEnabled"] = conf.Packages.Enabled
 
 		if c.IsLogged {
-			c.Data["IsWatchingRepo"] = db.IsWatching(c.User.ID, repo.ID)
+			watchMode := db.GetWatchMode(c.User.ID, repo.ID)
+			c.Data["WatchMode"] = watchMode.Name()
+			c.Data["IsWatchingRepo"] = watchMode == db.WATCH_MODE_ALL
 			c.Data["IsStaringRepo"] = db.IsStaring(c.User.ID, repo.ID)
 		}
 
@@ -421,6 +494,10 @@ func RepoRef() macaron.Handler {
 				}
 			}
 		}
+		// Archived repository is read-only thus does not accept new pull requests.
+		if c.Repo.PullRequest.Allowed && c.Repo.PullRequest.BaseRepo.IsArchived {
+			c.Repo.PullRequest.Allowed = false
+		}
 		c.Data["PullRequestCtx"] = c.Repo.PullRequest
 	}
 }
@@ -434,6 +511,15 @@ func RequireRepoAdmin() macaron.Handler {
 	}
 }
 
+func RequireRepoMaintainer() macaron.Handler {
+	return func(c *Context) {
+		if !c.IsLogged || (!c.Repo.IsMaintainer() && !c.User.IsAdmin) {
+			c.NotFound()
+			return
+		}
+	}
+}
+
 func RequireRepoWriter() macaron.Handler {
 	return func(c *Context) {
 		if !c.IsLogged || (!c.Repo.IsWriter() && !c.User.IsAdmin) {
@@ -443,6 +529,35 @@ func RequireRepoWriter() macaron.Handler {
 	}
 }
 
+// RequireRepoUnitWriter requires current user to have write access to the unit
+// of repository.
+func RequireRepoUnitWriter(unit db.RepoUnit) macaron.Handler {
+	return func(c *Context) {
+		if !c.IsLogged || (!c.Repo.CanWrite(unit) && !c.User.IsAdmin) {
+			c.NotFound()
+			return
+		}
+	}
+}
+
+func RequireRepoWikiWriter() macaron.Handler {
+	return func(c *Context) {
+		if !c.IsLogged || (!c.Repo.CanEditWiki() && !c.User.IsAdmin) {
+			c.NotFound()
+			return
+		}
+	}
+}
+
+func RequireRepoTriager() macaron.Handler {
+	return func(c *Context) {
+		if !c.IsLogged || (!c.Repo.IsTriager() && !c.User.IsAdmin) {
+			c.NotFound()
+			return
+		}
+	}
+}
+
 // GitHookService checks if repository Git hooks service has been enabled.
 func GitHookService() macaron.Handler {
 	return func(c *Context) {
@@ -17,13 +17,16 @@ type ParamsUser struct {
 }
 
 // InjectParamsUser returns a handler that retrieves target user based on URL parameter ':username',
-// and injects it as *ParamsUser.
+// and injects it as *ParamsUser. Deactivated users are only visible to admins.
 func InjectParamsUser() macaron.Handler {
 	return func(c *Context) {
 		user, err := db.GetUserByName(c.Params(":username"))
 		if err != nil {
 			c.NotFoundOrServerError("GetUserByName", errors.IsUserNotExist, err)
 			return
+		} else if user.IsDeactivated && !(c.IsLogged && c.User.IsAdmin) {
+			c.NotFound()
+			return
 		}
 		c.Map(&ParamsUser{user})
 	}
@@ -66,6 +66,116 @@ func NewContext() {
 			go db.DeleteOldRepositoryArchives()
 		}
 	}
+	if conf.Cron.PruneTables.Enabled {
+		entry, err = c.AddFunc("Prune old table records", conf.Cron.PruneTables.Schedule, db.PruneTables)
+		if err != nil {
+			log.Fatal("Cron.(prune old table records): %v", err)
+		}
+		if conf.Cron.PruneTables.RunAtStart {
+			entry.Prev = time.Now()
+			entry.ExecTimes++
+			go db.PruneTables()
+		}
+	}
+	if conf.Cron.ReviewReminders.Enabled {
+		entry, err = c.AddFunc("Send review reminders", conf.Cron.ReviewReminders.Schedule, db.SendReviewReminders)
+		if err != nil {
+			log.Fatal("Cron.(send review reminders): %v", err)
+		}
+		if conf.Cron.ReviewReminders.RunAtStart {
+			entry.Prev = time.Now()
+			entry.ExecTimes++
+			go db.SendReviewReminders()
+		}
+	}
+	if conf.Cron.CheckPullMergeability.Enabled {
+		entry, err = c.AddFunc("Check pull request mergeability", conf.Cron.CheckPullMergeability.Schedule, db.CheckPullRequestsMergeability)
+		if err != nil {
+			log.Fatal("Cron.(check pull request mergeability): %v", err)
+		}
+		if conf.Cron.CheckPullMergeability.RunAtStart {
+			entry.Prev = time.Now()
+			entry.ExecTimes++
+			go db.CheckPullRequestsMergeability()
+		}
+	}
+	if conf.Cron.SendDigests.Enabled {
+		entry, err = c.AddFunc("Send digest emails", conf.Cron.SendDigests.Schedule, db.SendDigests)
+		if err != nil {
+			log.Fatal("Cron.(send digest emails): %v", err)
+		}
+		if conf.Cron.SendDigests.RunAtStart {
+			entry.Prev = time.Now()
+			entry.ExecTimes++
+			go db.SendDigests()
+		}
+	}
+	if conf.Cron.SendNotificationDigests.Enabled {
+		entry, err = c.AddFunc("Send notification digest emails", conf.Cron.SendNotificationDigests.Schedule, db.SendNotificationDigests)
+		if err != nil {
+			log.Fatal("Cron.(send notification digest emails): %v", err)
+		}
+		if conf.Cron.SendNotificationDigests.RunAtStart {
+			entry.Prev = time.Now()
+			entry.ExecTimes++
+			go db.SendNotificationDigests()
+		}
+	}
+	if conf.Cron.RepoGC.Enabled {
+		entry, err = c.AddFunc("Repository garbage collection", conf.Cron.RepoGC.Schedule, db.ScheduledRepoGC)
+		if err != nil {
+			log.Fatal("Cron.(repository garbage collection): %v", err)
+		}
+		if conf.Cron.RepoGC.RunAtStart {
+			entry.Prev = time.Now()
+			entry.ExecTimes++
+			go db.ScheduledRepoGC()
+		}
+	}
+	if conf.Cron.GenerateBundles.Enabled {
+		entry, err = c.AddFunc("Generate repository bundles", conf.Cron.GenerateBundles.Schedule, db.GenerateRepoBundles)
+		if err != nil {
+			log.Fatal("Cron.(generate repository bundles): %v", err)
+		}
+		if conf.Cron.GenerateBundles.RunAtStart {
+			entry.Prev = time.Now()
+			entry.ExecTimes++
+			go db.GenerateRepoBundles()
+		}
+	}
+	if conf.Cron.CheckLoginSources.Enabled {
+		entry, err = c.AddFunc("Check login sources health", conf.Cron.CheckLoginSources.Schedule, db.CheckLoginSourcesHealth)
+		if err != nil {
+			log.Fatal("Cron.(check login sources health): %v", err)
+		}
+		if conf.Cron.CheckLoginSources.RunAtStart {
+			entry.Prev = time.Now()
+			entry.ExecTimes++
+			go db.CheckLoginSourcesHealth()
+		}
+	}
+	if conf.Cron.PurgeDeletedBranches.Enabled {
+		entry, err = c.AddFunc("Purge deleted branches", conf.Cron.PurgeDeletedBranches.Schedule, db.PurgeDeletedBranches)
+		if err != nil {
+			log.Fatal("Cron.(purge deleted branches): %v", err)
+		}
+		if conf.Cron.PurgeDeletedBranches.RunAtStart {
+			entry.Prev = time.Now()
+			entry.ExecTimes++
+			go db.PurgeDeletedBranches()
+		}
+	}
+	if conf.Cron.UpdateTrendingRepos.Enabled {
+		entry, err = c.AddFunc("Update trending repositories", conf.Cron.UpdateTrendingRepos.Schedule, db.UpdateTrendingRepos)
+		if err != nil {
+			log.Fatal("Cron.(update trending repositories): %v", err)
+		}
+		if conf.Cron.UpdateTrendingRepos.RunAtStart {
+			entry.Prev = time.Now()
+			entry.ExecTimes++
+			go db.UpdateTrendingRepos()
+		}
+	}
 	c.Start()
 }
 
@@ -14,20 +14,27 @@ import (
 
 type AccessMode int
 
+// NOTE: Access modes are stored in database, make a migration when changing values.
 const (
-	ACCESS_MODE_NONE  AccessMode = iota // 0
-	ACCESS_MODE_READ                    // 1
-	ACCESS_MODE_WRITE                   // 2
-	ACCESS_MODE_ADMIN                   // 3
-	ACCESS_MODE_OWNER                   // 4
+	ACCESS_MODE_NONE     AccessMode = iota // 0
+	ACCESS_MODE_READ                       // 1
+	ACCESS_MODE_TRIAGE                     // 2: Manage issues, labels and milestones without write access to code
+	ACCESS_MODE_WRITE                      // 3
+	ACCESS_MODE_MAINTAIN                   // 4: Manage repository settings except access and destructive actions
+	ACCESS_MODE_ADMIN                      // 5
+	ACCESS_MODE_OWNER                      // 6
 )
 
 func (mode AccessMode) String() string {
 	switch mode {
 	case ACCESS_MODE_READ:
 		return "read"
+	case ACCESS_MODE_TRIAGE:
+		return "triage"
 	case ACCESS_MODE_WRITE:
 		return "write"
+	case ACCESS_MODE_MAINTAIN:
+		return "maintain"
 	case ACCESS_MODE_ADMIN:
 		return "admin"
 	case ACCESS_MODE_OWNER:
@@ -40,8 +47,12 @@ func (mode AccessMode) String() string {
 // ParseAccessMode returns corresponding access mode to given permission string.
 func ParseAccessMode(permission string) AccessMode {
 	switch permission {
+	case "triage":
+		return ACCESS_MODE_TRIAGE
 	case "write":
 		return ACCESS_MODE_WRITE
+	case "maintain":
+		return ACCESS_MODE_MAINTAIN
 	case "admin":
 		return ACCESS_MODE_ADMIN
 	default:
@@ -59,12 +70,18 @@ type Access struct {
 	Mode   AccessMode
 }
 
-func userAccessMode(e Engine, userID int64, repo *Repository) (AccessMode, error) {
-	mode := ACCESS_MODE_NONE
-	// Everyone has read access to public repository
-	if !repo.IsPrivate {
-		mode = ACCESS_MODE_READ
+// baseAccessMode returns the access mode that given user has to the repository
+// regardless of any access granted. Everyone has read access to public repository,
+// and every signed-in user has read access to internal repository.
+func (repo *Repository) baseAccessMode(userID int64) AccessMode {
+	if !repo.IsPrivate || (repo.IsInternal && userID > 0) {
+		return ACCESS_MODE_READ
 	}
+	return ACCESS_MODE_NONE
+}
+
+func userAccessMode(e Engine, userID int64, repo *Repository) (AccessMode, error) {
+	mode := repo.baseAccessMode(userID)
 
 	if userID <= 0 {
 		return mode, nil
@@ -148,14 +165,31 @@ func maxAccessMode(modes ...AccessMode) AccessMode {
 }
 
 // FIXME: do corss-comparison so reduce deletions and additions to the minimum?
-func (repo *Repository) refreshAccesses(e Engine, accessMap map[int64]AccessMode) (err error) {
+//
+// The unitAccessMap contains access modes of users to repository units, only those
+// differ from access modes in accessMap are saved.
+func (repo *Repository) refreshAccesses(e Engine, accessMap map[int64]AccessMode, unitAccessMap map[int64]UnitAccessModes) (err error) {
 	newAccesses := make([]Access, 0, len(accessMap))
+	newUnitAccesses := make([]AccessUnit, 0, len(unitAccessMap))
 	for userID, mode := range accessMap {
 		newAccesses = append(newAccesses, Access{
 			UserID: userID,
 			RepoID: repo.ID,
 			Mode:   mode,
 		})
+
+		for _, unit := range RepoUnits {
+			unitMode := unitAccessMap[userID].Get(unit, mode)
+			if unitMode == mode {
+				continue
+			}
+			newUnitAccesses = append(newUnitAccesses, AccessUnit{
+				UserID: userID,
+				RepoID: repo.ID,
+				Unit:   unit,
+				Mode:   unitMode,
+			})
+		}
 	}
 
 	// Delete old accesses and insert new ones for repository.
@@ -164,17 +198,27 @@ func (repo *Repository) refreshAccesses(e Engine, accessMap map[int64]AccessMode
 	} else if _, err = e.Insert(newAccesses); err != nil {
 		return fmt.Errorf("insert new accesses: %v", err)
 	}
+
+	if _, err = e.Delete(&AccessUnit{RepoID: repo.ID}); err != nil {
+		return fmt.Errorf("delete old unit accesses: %v", err)
+	} else if len(newUnitAccesses) > 0 {
+		if _, err = e.Insert(newUnitAccesses); err != nil {
+			return fmt.Errorf("insert new unit accesses: %v", err)
+		}
+	}
 	return nil
 }
 
-// refreshCollaboratorAccesses retrieves repository collaborations with their access modes.
-func (repo *Repository) refreshCollaboratorAccesses(e Engine, accessMap map[int64]AccessMode) error {
+// refreshCollaboratorAccesses retrieves repository collaborations with their access
+// modes to the repository and its units.
+func (repo *Repository) refreshCollaboratorAccesses(e Engine, accessMap map[int64]AccessMode, unitAccessMap map[int64]UnitAccessModes) error {
 	collaborations, err := repo.getCollaborations(e)
 	if err != nil {
 		return fmt.Errorf("getCollaborations: %v", err)
 	}
 	for _, c := range collaborations {
 		accessMap[c.UserID] = c.Mode
+		unitAccessMap[c.UserID] = c.unitModes()
 	}
 	return nil
 }
@@ -184,6 +228,7 @@ func (repo *Repository) refreshCollaboratorAccesses(e Engine, accessMap map[int6
 // remove repository from that team.
 func (repo *Repository) recalculateTeamAccesses(e Engine, ignTeamID int64) (err error) {
 	accessMap := make(map[int64]AccessMode, 20)
+	unitAccessMap := make(map[int64]UnitAccessModes, 20)
 
 	if err = repo.getOwner(e); err != nil {
 		return err
@@ -191,7 +236,7 @@ func (repo *Repository) recalculateTeamAccesses(e Engine, ignTeamID int64) (err
 		return fmt.Errorf("owner is not an organization: %d", repo.OwnerID)
 	}
 
-	if err = repo.refreshCollaboratorAccesses(e, accessMap); err != nil {
+	if err = repo.refreshCollaboratorAccesses(e, accessMap, unitAccessMap); err != nil {
 		return fmt.Errorf("refreshCollaboratorAccesses: %v", err)
 	}
 
@@ -212,15 +257,24 @@ func (repo *Repository) recalculateTeamAccesses(e Engine, ignTeamID int64) (err
 			continue
 		}
 
-		if err = t.getMembers(e); err != nil {
-			return fmt.Errorf("getMembers '%d': %v", t.ID, err)
+		if err = t.getInheritedMembers(e); err != nil {
+			return fmt.Errorf("getInheritedMembers '%d': %v", t.ID, err)
+		} else if err = t.getUnits(e); err != nil {
+			return fmt.Errorf("getUnits '%d': %v", t.ID, err)
 		}
 		for _, m := range t.Members {
 			accessMap[m.ID] = maxAccessMode(accessMap[m.ID], t.Authorize)
+
+			if unitAccessMap[m.ID] == nil {
+				unitAccessMap[m.ID] = make(UnitAccessModes, len(RepoUnits))
+			}
+			for _, unit := range RepoUnits {
+				unitAccessMap[m.ID][unit] = maxAccessMode(unitAccessMap[m.ID][unit], t.UnitMode(unit))
+			}
 		}
 	}
 
-	return repo.refreshAccesses(e, accessMap)
+	return repo.refreshAccesses(e, accessMap, unitAccessMap)
 }
 
 func (repo *Repository) recalculateAccesses(e Engine) error {
@@ -229,10 +283,11 @@ func (repo *Repository) recalculateAccesses(e Engine) error {
 	}
 
 	accessMap := make(map[int64]AccessMode, 10)
-	if err := repo.refreshCollaboratorAccesses(e, accessMap); err != nil {
+	unitAccessMap := make(map[int64]UnitAccessModes, 10)
+	if err := repo.refreshCollaboratorAccesses(e, accessMap, unitAccessMap); err != nil {
 		return fmt.Errorf("refreshCollaboratorAccesses: %v", err)
 	}
-	return repo.refreshAccesses(e, accessMap)
+	return repo.refreshAccesses(e, accessMap, unitAccessMap)
 }
 
 // RecalculateAccesses recalculates all accesses for repository.
@@ -0,0 +1,49 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_AccessMode(t *testing.T) {
+	Convey("Parse and format access modes", t, func() {
+		for _, mode := range []AccessMode{
+			ACCESS_MODE_READ,
+			ACCESS_MODE_TRIAGE,
+			ACCESS_MODE_WRITE,
+			ACCESS_MODE_MAINTAIN,
+			ACCESS_MODE_ADMIN,
+		} {
+			So(ParseAccessMode(mode.String()), ShouldEqual, mode)
+		}
+		So(ParseAccessMode("unknown"), ShouldEqual, ACCESS_MODE_READ)
+	})
+
+	Convey("Roles are ordered by privileges", t, func() {
+		So(ACCESS_MODE_TRIAGE, ShouldBeGreaterThan, ACCESS_MODE_READ)
+		So(ACCESS_MODE_TRIAGE, ShouldBeLessThan, ACCESS_MODE_WRITE)
+		So(ACCESS_MODE_MAINTAIN, ShouldBeGreaterThan, ACCESS_MODE_WRITE)
+		So(ACCESS_MODE_MAINTAIN, ShouldBeLessThan, ACCESS_MODE_ADMIN)
+	})
+}
+
+func Test_Repository_baseAccessMode(t *testing.T) {
+	Convey("Get access mode of users to repository regardless of access granted", t, func() {
+		repo := &Repository{}
+		So(repo.baseAccessMode(0), ShouldEqual, ACCESS_MODE_READ)
+		So(repo.baseAccessMode(1), ShouldEqual, ACCESS_MODE_READ)
+
+		repo = &Repository{IsPrivate: true}
+		So(repo.baseAccessMode(0), ShouldEqual, ACCESS_MODE_NONE)
+		So(repo.baseAccessMode(1), ShouldEqual, ACCESS_MODE_NONE)
+
+		repo = &Repository{IsPrivate: true, IsInternal: true}
+		So(repo.baseAccessMode(0), ShouldEqual, ACCESS_MODE_NONE)
+		So(repo.baseAccessMode(1), ShouldEqual, ACCESS_MODE_READ)
+	})
+}
@@ -51,8 +51,78 @@ const (
 	ACTION_MIRROR_SYNC_PUSH                          // 20
 	ACTION_MIRROR_SYNC_CREATE                        // 21
 	ACTION_MIRROR_SYNC_DELETE                        // 22
+	ACTION_PUBLISH_RELEASE                           // 23
 )
 
+var actionTypeNames = map[ActionType]string{
+	ACTION_CREATE_REPO:         "create_repo",
+	ACTION_RENAME_REPO:         "rename_repo",
+	ACTION_STAR_REPO:           "star_repo",
+	ACTION_WATCH_REPO:          "watch_repo",
+	ACTION_COMMIT_REPO:         "commit_repo",
+	ACTION_CREATE_ISSUE:        "create_issue",
+	ACTION_CREATE_PULL_REQUEST: "create_pull_request",
+	ACTION_TRANSFER_REPO:       "transfer_repo",
+	ACTION_PUSH_TAG:            "push_tag",
+	ACTION_COMMENT_ISSUE:       "comment_issue",
+	ACTION_MERGE_PULL_REQUEST:  "merge_pull_request",
+	ACTION_CLOSE_ISSUE:         "close_issue",
+	ACTION_REOPEN_ISSUE:        "reopen_issue",
+	ACTION_CLOSE_PULL_REQUEST:  "close_pull_request",
+	ACTION_REOPEN_PULL_REQUEST: "reopen_pull_request",
+	ACTION_CREATE_BRANCH:       "create_branch",
+	ACTION_DELETE_BRANCH:       "delete_branch",
+	ACTION_DELETE_TAG:          "delete_tag",
+	ACTION_FORK_REPO:           "fork_repo",
+	ACTION_MIRROR_SYNC_PUSH:    "mirror_sync_push",
+	ACTION_MIRROR_SYNC_CREATE:  "mirror_sync_create",
+	ACTION_MIRROR_SYNC_DELETE:  "mirror_sync_delete",
+	ACTION_PUBLISH_RELEASE:     "publish_release",
+}
+
+// Name returns the name of the action type, e.g. "commit_repo".
+func (t ActionType) Name() string {
+	return actionTypeNames[t]
+}
+
+// Types of activities that actions are grouped into for filtering.
+const (
+	ACTIVITY_TYPE_COMMITS  = "commits"
+	ACTIVITY_TYPE_ISSUES   = "issues"
+	ACTIVITY_TYPE_RELEASES = "releases"
+)
+
+// activityOpTypes is the types of actions of each type of activities, where issues
+// include pull requests since comments of both are the same type of actions.
+var activityOpTypes = map[string][]ActionType{
+	ACTIVITY_TYPE_COMMITS: {
+		ACTION_COMMIT_REPO,
+		ACTION_MIRROR_SYNC_PUSH,
+	},
+	ACTIVITY_TYPE_ISSUES: {
+		ACTION_CREATE_ISSUE,
+		ACTION_CREATE_PULL_REQUEST,
+		ACTION_COMMENT_ISSUE,
+		ACTION_MERGE_PULL_REQUEST,
+		ACTION_CLOSE_ISSUE,
+		ACTION_REOPEN_ISSUE,
+		ACTION_CLOSE_PULL_REQUEST,
+		ACTION_REOPEN_PULL_REQUEST,
+	},
+	ACTIVITY_TYPE_RELEASES: {
+		ACTION_PUSH_TAG,
+		ACTION_DELETE_TAG,
+		ACTION_PUBLISH_RELEASE,
+	},
+}
+
+// IsValidActivityType returns true if given type of activities is known, or is empty
+// which means all types.
+func IsValidActivityType(activityType string) bool {
+	_, ok := activityOpTypes[activityType]
+	return ok || activityType == ""
+}
+
 var (
 	// Same as Github. See https://help.github.com/articles/closing-issues-via-commit-messages
 	IssueCloseKeywords  = []string{"close", "closes", "closed", "fix", "fixes", "fixed", "resolve", "resolves", "resolved"}
@@ -70,20 +140,21 @@ func assembleKeywordsPattern(words []string) string {
 // Action represents user operation type and other information to repository,
 // it implemented interface base.Actioner so that can be used in template render.
 type Action struct {
-	ID           int64
-	UserID       int64 // Receiver user ID
-	OpType       ActionType
-	ActUserID    int64  // Doer user ID
-	ActUserName  string // Doer user name
-	ActAvatar    string `xorm:"-" json:"-"`
-	RepoID       int64  `xorm:"INDEX"`
-	RepoUserName string
-	RepoName     string
-	RefName      string
-	IsPrivate    bool      `xorm:"NOT NULL DEFAULT false"`
-	Content      string    `xorm:"TEXT"`
-	Created      time.Time `xorm:"-" json:"-"`
-	CreatedUnix  int64
+	ID             int64
+	UserID         int64 // Receiver user ID
+	OpType         ActionType
+	ActUserID      int64  // Doer user ID
+	ActUserName    string // Doer user name
+	ActAvatar      string `xorm:"-" json:"-"`
+	RepoID         int64  `xorm:"INDEX"`
+	RepoUserName   string
+	RepoName       string
+	RefName        string
+	IsPrivate      bool      `xorm:"NOT NULL DEFAULT false"`
+	IsConfidential bool      `xorm:"NOT NULL DEFAULT false"` // About a confidential issue
+	Content        string    `xorm:"TEXT"`
+	Created        time.Time `xorm:"-" json:"-"`
+	CreatedUnix    int64     `xorm:"INDEX"`
 }
 
 func (a *Action) BeforeInsert() {
@@ -315,6 +386,48 @@ func (push *PushCommits) AvatarLink(email string) string {
 	return push.avatars[email]
 }
 
+// isUnresolvedIssueRef returns true if the error means the issue reference does not
+// point to an existing issue, e.g. the repository of the reference does not exist.
+func isUnresolvedIssueRef(err error) bool {
+	return errors.IsIssueNotExist(err) ||
+		errors.IsInvalidIssueReference(err) ||
+		errors.IsInvalidRepoReference(err) ||
+		errors.IsRepoNotExist(err) ||
+		errors.IsUserNotExist(err)
+}
+
+// canReferenceIssueByCommit returns true if the issue can be referenced by commits
+// pushed to the repository by the doer. The doer must be able to see the issue when
+// it is in a different repository.
+func canReferenceIssueByCommit(doer *User, repo *Repository, issue *Issue) (bool, error) {
+	if issue.RepoID == repo.ID {
+		return true, nil
+	}
+
+	mode, err := userUnitAccessMode(x, doer.ID, issue.Repo, REPO_UNIT_ISSUES)
+	if err != nil {
+		return false, err
+	} else if mode < ACCESS_MODE_READ {
+		return false, nil
+	}
+	return issue.isVisibleTo(x, doer.ID)
+}
+
+// canChangeIssueStatusByCommit returns true if the issue can be closed or reopened
+// by commits pushed to the repository by the doer. The doer must be able to triage
+// issues, or be the poster of the issue, when it is in a different repository.
+func canChangeIssueStatusByCommit(doer *User, repo *Repository, issue *Issue) (bool, error) {
+	if issue.RepoID == repo.ID {
+		return true, nil
+	}
+
+	mode, err := userUnitAccessMode(x, doer.ID, issue.Repo, REPO_UNIT_ISSUES)
+	if err != nil {
+		return false, err
+	}
+	return mode >= ACCESS_MODE_TRIAGE || (mode >= ACCESS_MODE_READ && issue.IsPoster(doer.ID)), nil
+}
+
 // UpdateIssuesCommit checks if issues are manipulated by commit message.
 func UpdateIssuesCommit(doer *User, repo *Repository, commits []*PushCommit) error {
 	// Commits are appended in the reverse order.
@@ -341,7 +454,7 @@ func UpdateIssuesCommit(doer *User, repo *Repository, commits []*PushCommit) err
 
 			issue, err := GetIssueByRef(ref)
 			if err != nil {
-				if errors.IsIssueNotExist(err) {
+				if isUnresolvedIssueRef(err) {
 					continue
 				}
 				return err
@@ -352,13 +465,19 @@ func UpdateIssuesCommit(doer *User, repo *Repository, commits []*PushCommit) err
 			}
 			refMarked[issue.ID] = true
 
+			if ok, err := canReferenceIssueByCommit(doer, repo, issue); err != nil {
+				return fmt.Errorf("canReferenceIssueByCommit: %v", err)
+			} else if !ok {
+				continue
+			}
+
 			msgLines := strings.Split(c.Message, "\n")
 			shortMsg := msgLines[0]
 			if len(msgLines) > 2 {
 				shortMsg += "..."
 			}
 			message := fmt.Sprintf(`<a href="%s/commit/%s">%s</a>`, repo.Link(), c.Sha1, shortMsg)
-			if err = CreateRefComment(doer, repo, issue, message, c.Sha1); err != nil {
+			if err = CreateRefComment(doer, issue.Repo, issue, message, c.Sha1); err != nil {
 				return err
 			}
 		}
@@ -383,7 +502,7 @@ func UpdateIssuesCommit(doer *User, repo *Repository, commits []*PushCommit) err
 
 			issue, err := GetIssueByRef(ref)
 			if err != nil {
-				if errors.IsIssueNotExist(err) {
+				if isUnresolvedIssueRef(err) {
 					continue
 				}
 				return err
@@ -394,11 +513,15 @@ func UpdateIssuesCommit(doer *User, repo *Repository, commits []*PushCommit) err
 			}
 			refMarked[issue.ID] = true
 
-			if issue.RepoID != repo.ID || issue.IsClosed {
+			if issue.IsClosed {
+				continue
+			} else if ok, err := canChangeIssueStatusByCommit(doer, repo, issue); err != nil {
+				return fmt.Errorf("canChangeIssueStatusByCommit: %v", err)
+			} else if !ok {
 				continue
 			}
 
-			if err = issue.ChangeStatus(doer, repo, true); err != nil {
+			if err = issue.ChangeStatus(doer, issue.Repo, true); err != nil {
 				return err
 			}
 		}
@@ -423,7 +546,7 @@ func UpdateIssuesCommit(doer *User, repo *Repository, commits []*PushCommit) err
 
 			issue, err := GetIssueByRef(ref)
 			if err != nil {
-				if errors.IsIssueNotExist(err) {
+				if isUnresolvedIssueRef(err) {
 					continue
 				}
 				return err
@@ -434,11 +557,15 @@ func UpdateIssuesCommit(doer *User, repo *Repository, commits []*PushCommit) err
 			}
 			refMarked[issue.ID] = true
 
-			if issue.RepoID != repo.ID || !issue.IsClosed {
+			if !issue.IsClosed {
+				continue
+			} else if ok, err := canChangeIssueStatusByCommit(doer, repo, issue); err != nil {
+				return fmt.Errorf("canChangeIssueStatusByCommit: %v", err)
+			} else if !ok {
 				continue
 			}
 
-			if err = issue.ChangeStatus(doer, repo, false); err != nil {
+			if err = issue.ChangeStatus(doer, issue.Repo, false); err != nil {
 				return err
 			}
 		}
@@ -454,6 +581,7 @@ type CommitRepoActionOptions struct {
 	OldCommitID string
 	NewCommitID string
 	Commits     *PushCommits
+	PushOptions PushOptions
 }
 
 // CommitRepoAction adds new commit actio to the repository, and prepare corresponding webhooks.
@@ -516,12 +644,18 @@ func CommitRepoAction(opts CommitRepoActionOptions) error {
 		IsPrivate:    repo.IsPrivate,
 	}
 
+	// Pushers can skip webhook deliveries of the push by push options.
+	prepareWebhooks := PrepareWebhooks
+	if opts.PushOptions.SkipCI() {
+		prepareWebhooks = func(*Repository, HookEventType, api.Payloader) error { return nil }
+	}
+
 	apiRepo := repo.APIFormat(nil)
 	apiPusher := pusher.APIFormat()
 	switch opType {
 	case ACTION_COMMIT_REPO: // Push
 		if isDelRef {
-			if err = PrepareWebhooks(repo, HOOK_EVENT_DELETE, &api.DeletePayload{
+			if err = prepareWebhooks(repo, HOOK_EVENT_DELETE, &api.DeletePayload{
 				Ref:        refName,
 				RefType:    "branch",
 				PusherType: api.PUSHER_TYPE_USER,
@@ -543,7 +677,7 @@ func CommitRepoAction(opts CommitRepoActionOptions) error {
 		compareURL := conf.Server.ExternalURL + opts.Commits.CompareURL
 		if isNewRef {
 			compareURL = ""
-			if err = PrepareWebhooks(repo, HOOK_EVENT_CREATE, &api.CreatePayload{
+			if err = prepareWebhooks(repo, HOOK_EVENT_CREATE, &api.CreatePayload{
 				Ref:           refName,
 				RefType:       "branch",
 				DefaultBranch: repo.DefaultBranch,
@@ -564,15 +698,18 @@ func CommitRepoAction(opts CommitRepoActionOptions) error {
 			return fmt.Errorf("ToApiPayloadCommits: %v", err)
 		}
 
-		if err = PrepareWebhooks(repo, HOOK_EVENT_PUSH, &api.PushPayload{
-			Ref:        opts.RefFullName,
-			Before:     opts.OldCommitID,
-			After:      opts.NewCommitID,
-			CompareURL: compareURL,
-			Commits:    commits,
-			Repo:       apiRepo,
-			Pusher:     apiPusher,
-			Sender:     apiPusher,
+		if err = prepareWebhooks(repo, HOOK_EVENT_PUSH, &PushPayload{
+			PushPayload: &api.PushPayload{
+				Ref:        opts.RefFullName,
+				Before:     opts.OldCommitID,
+				After:      opts.NewCommitID,
+				CompareURL: compareURL,
+				Commits:    commits,
+				Repo:       apiRepo,
+				Pusher:     apiPusher,
+				Sender:     apiPusher,
+			},
+			Options: opts.PushOptions,
 		}); err != nil {
 			return fmt.Errorf("PrepareWebhooks.(new commit): %v", err)
 		}
@@ -584,7 +721,7 @@ func CommitRepoAction(opts CommitRepoActionOptions) error {
 
 	case ACTION_PUSH_TAG: // Tag
 		if isDelRef {
-			if err = PrepareWebhooks(repo, HOOK_EVENT_DELETE, &api.DeletePayload{
+			if err = prepareWebhooks(repo, HOOK_EVENT_DELETE, &api.DeletePayload{
 				Ref:        refName,
 				RefType:    "tag",
 				PusherType: api.PUSHER_TYPE_USER,
@@ -601,7 +738,7 @@ func CommitRepoAction(opts CommitRepoActionOptions) error {
 			return nil
 		}
 
-		if err = PrepareWebhooks(repo, HOOK_EVENT_CREATE, &api.CreatePayload{
+		if err = prepareWebhooks(repo, HOOK_EVENT_CREATE, &api.CreatePayload{
 			Ref:           refName,
 			RefType:       "tag",
 			Sha:           opts.NewCommitID,
@@ -737,12 +874,16 @@ func MirrorSyncDeleteAction(repo *Repository, refName string) error {
 // GetFeeds returns action list of given user in given context.
 // actorID is the user who's requesting, ctxUserID is the user/org that is requested.
 // actorID can be -1 when isProfile is true or to skip the permission check.
-func GetFeeds(ctxUser *User, actorID, afterID int64, isProfile bool) ([]*Action, error) {
+// activityType filters actions by the type of activities, empty means all types.
+func GetFeeds(ctxUser *User, actorID, afterID int64, isProfile bool, activityType string) ([]*Action, error) {
 	actions := make([]*Action, 0, conf.UI.User.NewsFeedPagingNum)
 	sess := x.Limit(conf.UI.User.NewsFeedPagingNum).Where("user_id = ?", ctxUser.ID).Desc("id")
 	if afterID > 0 {
 		sess.And("id < ?", afterID)
 	}
+	if opTypes := activityOpTypes[activityType]; len(opTypes) > 0 {
+		sess.In("op_type", opTypes)
+	}
 	if isProfile {
 		sess.And("is_private = ?", false).And("act_user_id = ?", ctxUser.ID)
 	} else if actorID != -1 && ctxUser.IsOrganization() {
@@ -765,3 +906,25 @@ func GetFeeds(ctxUser *User, actorID, afterID int64, isProfile bool) ([]*Action,
 	err := sess.Find(&actions)
 	return actions, err
 }
+
+// GetRepositoryActivities returns at most limit number of actions in the repository
+// before the action of given ID, latest first, and filters actions by the type of
+// activities where empty means all types. The beforeID is the cursor of pagination,
+// which is the ID of the last action of previous page, zero means the latest.
+// Actions of confidential issues are only included when includeConfidential is true.
+func GetRepositoryActivities(repoID int64, activityType string, beforeID int64, limit int, includeConfidential bool) ([]*Action, error) {
+	actions := make([]*Action, 0, limit)
+	// Every action is copied to watchers of the repository, only the copy of the
+	// doer is needed.
+	sess := x.Limit(limit).Where("repo_id = ?", repoID).And("user_id = act_user_id").Desc("id")
+	if beforeID > 0 {
+		sess.And("id < ?", beforeID)
+	}
+	if !includeConfidential {
+		sess.And("is_confidential = ?", false)
+	}
+	if opTypes := activityOpTypes[activityType]; len(opTypes) > 0 {
+		sess.In("op_type", opTypes)
+	}
+	return actions, sess.Find(&actions)
+}
@@ -0,0 +1,25 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"gogs.io/gogs/internal/db/errors"
+)
+
+func Test_isUnresolvedIssueRef(t *testing.T) {
+	Convey("Check if issue reference cannot be resolved", t, func() {
+		So(isUnresolvedIssueRef(errors.IssueNotExist{}), ShouldBeTrue)
+		So(isUnresolvedIssueRef(errors.InvalidIssueReference{Ref: "org/repo"}), ShouldBeTrue)
+		So(isUnresolvedIssueRef(errors.InvalidRepoReference{Ref: "r#1"}), ShouldBeTrue)
+		So(isUnresolvedIssueRef(errors.RepoNotExist{Name: "repo"}), ShouldBeTrue)
+		So(isUnresolvedIssueRef(errors.UserNotExist{Name: "org"}), ShouldBeTrue)
+		So(isUnresolvedIssueRef(fmt.Errorf("database is locked")), ShouldBeFalse)
+	})
+}
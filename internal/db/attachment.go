@@ -5,17 +5,19 @@
 package db
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
-	"mime/multipart"
-	"os"
 	"path"
 	"time"
 
 	gouuid "github.com/satori/go.uuid"
+	log "unknwon.dev/clog/v2"
 	"xorm.io/xorm"
 
 	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/storage"
 )
 
 // Attachment represent a attachment of issue/comment/release.
@@ -26,6 +28,11 @@ type Attachment struct {
 	CommentID int64
 	ReleaseID int64 `xorm:"INDEX"`
 	Name      string
+	// Storage is the name of blob store that the file is saved to, empty means local.
+	Storage       string
+	Size          int64
+	SHA256        string `xorm:"sha256 VARCHAR(64)"`
+	DownloadCount int64  `xorm:"NOT NULL DEFAULT 0"`
 
 	Created     time.Time `xorm:"-" json:"-"`
 	CreatedUnix int64
@@ -42,45 +49,92 @@ func (a *Attachment) AfterSet(colName string, _ xorm.Cell) {
 	}
 }
 
-// AttachmentLocalPath returns where attachment is stored in local file system based on given UUID.
-func AttachmentLocalPath(uuid string) string {
-	return path.Join(conf.AttachmentPath, uuid[0:1], uuid[1:2], uuid)
+// attachmentStore returns the blob store of given name that attachments are saved to.
+func attachmentStore(name string) (storage.Store, error) {
+	return blobStore(name, conf.AttachmentPath, "attachments")
 }
 
-// LocalPath returns where attachment is stored in local file system.
-func (attach *Attachment) LocalPath() string {
-	return AttachmentLocalPath(attach.UUID)
+// key returns the key of attachment file in the blob store.
+func (attach *Attachment) key() string {
+	return path.Join(attach.UUID[0:1], attach.UUID[1:2], attach.UUID)
 }
 
-// NewAttachment creates a new attachment object.
-func NewAttachment(name string, buf []byte, file multipart.File) (_ *Attachment, err error) {
-	attach := &Attachment{
-		UUID: gouuid.NewV4().String(),
-		Name: name,
+// Open returns the content of attachment file, or storage.ErrNotExist if the file
+// does not exist in the blob store.
+func (attach *Attachment) Open() (io.ReadCloser, error) {
+	store, err := attachmentStore(attach.Storage)
+	if err != nil {
+		return nil, err
 	}
+	return store.Open(attach.key())
+}
 
-	localPath := attach.LocalPath()
-	if err = os.MkdirAll(path.Dir(localPath), os.ModePerm); err != nil {
-		return nil, fmt.Errorf("MkdirAll: %v", err)
+// removeFile deletes the attachment file from the blob store.
+func (attach *Attachment) removeFile() error {
+	store, err := attachmentStore(attach.Storage)
+	if err != nil {
+		return err
 	}
+	return store.Delete(attach.key())
+}
 
-	fw, err := os.Create(localPath)
+// newAttachment saves the file of given size read from r to the blob store and
+// inserts the attachment with its checksum.
+func newAttachment(attach *Attachment, r io.Reader, size int64) (err error) {
+	store, err := attachmentStore(attach.Storage)
 	if err != nil {
-		return nil, fmt.Errorf("Create: %v", err)
+		return err
 	}
-	defer fw.Close()
 
-	if _, err = fw.Write(buf); err != nil {
-		return nil, fmt.Errorf("Write: %v", err)
-	} else if _, err = io.Copy(fw, file); err != nil {
-		return nil, fmt.Errorf("Copy: %v", err)
+	attach.UUID = gouuid.NewV4().String()
+	attach.Size = size
+	h := sha256.New()
+	if err = store.Put(attach.key(), io.TeeReader(r, h), size); err != nil {
+		return fmt.Errorf("put file: %v", err)
 	}
+	attach.SHA256 = hex.EncodeToString(h.Sum(nil))
 
-	if _, err := x.Insert(attach); err != nil {
-		return nil, err
+	if _, err = x.Insert(attach); err != nil {
+		if err := store.Delete(attach.key()); err != nil {
+			log.Error("Failed to delete attachment file [uuid: %s]: %v", attach.UUID, err)
+		}
+		return err
 	}
+	return nil
+}
 
-	return attach, nil
+// NewAttachment creates a new attachment object with the file of given size read from r,
+// which is saved to local file system.
+func NewAttachment(name string, r io.Reader, size int64) (*Attachment, error) {
+	attach := &Attachment{
+		Name:    name,
+		Storage: storage.LOCAL,
+	}
+	return attach, newAttachment(attach, r, size)
+}
+
+// NewReleaseAttachment creates a new attachment of the release with the file of given
+// size read from r, which is saved to the storage configured for release attachments.
+// The release ID is zero when the file is uploaded before the release is created.
+func NewReleaseAttachment(releaseID int64, name string, r io.Reader, size int64) (*Attachment, error) {
+	attach := &Attachment{
+		ReleaseID: releaseID,
+		Name:      name,
+		Storage:   conf.Release.Attachment.Storage,
+	}
+	return attach, newAttachment(attach, r, size)
+}
+
+// IncreaseDownloadCount increases the download count of the attachment by one.
+func (attach *Attachment) IncreaseDownloadCount() error {
+	_, err := x.Exec("UPDATE `attachment` SET download_count = download_count + 1 WHERE id = ?", attach.ID)
+	return err
+}
+
+// UpdateAttachmentName updates the name of the attachment.
+func UpdateAttachmentName(attach *Attachment) error {
+	_, err := x.ID(attach.ID).Cols("name").Update(attach)
+	return err
 }
 
 func getAttachmentByUUID(e Engine, uuid string) (*Attachment, error) {
@@ -149,7 +203,7 @@ func DeleteAttachment(a *Attachment, remove bool) error {
 func DeleteAttachments(attachments []*Attachment, remove bool) (int, error) {
 	for i, a := range attachments {
 		if remove {
-			if err := os.Remove(a.LocalPath()); err != nil {
+			if err := a.removeFile(); err != nil {
 				return i, err
 			}
 		}
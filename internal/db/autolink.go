@@ -0,0 +1,128 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"time"
+
+	log "unknwon.dev/clog/v2"
+	"xorm.io/xorm"
+
+	"gogs.io/gogs/internal/db/errors"
+	"gogs.io/gogs/internal/markup"
+)
+
+// Autolink is a rule to render references matching the pattern as links in issues,
+// pull requests, commit messages and other rendered content, e.g. "JIRA-123" to the
+// issue of an external tracker. Rules with RepoID 0 apply to all repositories.
+type Autolink struct {
+	ID      int64
+	RepoID  int64 `xorm:"INDEX"`
+	Pattern string
+	URL     string
+
+	Created     time.Time `xorm:"-" json:"-"`
+	CreatedUnix int64
+}
+
+func (l *Autolink) BeforeInsert() {
+	l.CreatedUnix = time.Now().Unix()
+}
+
+func (l *Autolink) AfterSet(colName string, _ xorm.Cell) {
+	switch colName {
+	case "created_unix":
+		l.Created = time.Unix(l.CreatedUnix, 0).Local()
+	}
+}
+
+// compileAutolinks compiles autolink rules for the renderer, rules that are no
+// longer valid are skipped.
+func compileAutolinks(links []*Autolink) []*markup.Autolink {
+	rules := make([]*markup.Autolink, 0, len(links))
+	for _, l := range links {
+		rule, err := markup.NewAutolink(l.Pattern, l.URL)
+		if err != nil {
+			log.Warn("Failed to compile autolink [id: %d]: %v", l.ID, err)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// reloadAutolinks sets autolink rules of the repository to the renderer.
+func reloadAutolinks(repoID int64) error {
+	links, err := GetAutolinks(repoID)
+	if err != nil {
+		return err
+	}
+	markup.SetAutolinks(repoID, compileAutolinks(links))
+	return nil
+}
+
+// LoadAutolinks sets autolink rules of the site and all repositories to the renderer.
+func LoadAutolinks() {
+	links := make([]*Autolink, 0, 10)
+	if err := x.Asc("id").Find(&links); err != nil {
+		log.Error("Failed to load autolinks: %v", err)
+		return
+	}
+
+	repoLinks := make(map[int64][]*Autolink)
+	for _, l := range links {
+		repoLinks[l.RepoID] = append(repoLinks[l.RepoID], l)
+	}
+	for repoID := range repoLinks {
+		markup.SetAutolinks(repoID, compileAutolinks(repoLinks[repoID]))
+	}
+}
+
+// GetAutolinks returns autolink rules of the repository, or rules of the site if
+// repoID is 0.
+func GetAutolinks(repoID int64) ([]*Autolink, error) {
+	links := make([]*Autolink, 0, 5)
+	return links, x.Where("repo_id = ?", repoID).Asc("id").Find(&links)
+}
+
+// NewAutolink creates a new autolink rule for the repository, or for the site if
+// repoID is 0. The URL is a template that "$0" is expanded to the whole match and
+// "$1", "$2" and so on to submatches of the pattern.
+func NewAutolink(repoID int64, pattern, url string) (*Autolink, error) {
+	if _, err := markup.NewAutolink(pattern, url); err != nil || !markup.IsValidAutolinkURL(url) {
+		return nil, errors.InvalidAutolink{Pattern: pattern, URL: url}
+	}
+
+	l := &Autolink{
+		RepoID:  repoID,
+		Pattern: pattern,
+		URL:     url,
+	}
+	if _, err := x.Insert(l); err != nil {
+		return nil, err
+	}
+
+	if err := reloadAutolinks(repoID); err != nil {
+		return nil, fmt.Errorf("reloadAutolinks: %v", err)
+	}
+	return l, nil
+}
+
+// DeleteAutolink deletes the autolink rule of given ID that belongs to the
+// repository, or to the site if repoID is 0.
+func DeleteAutolink(repoID, id int64) error {
+	affected, err := x.Where("id = ? AND repo_id = ?", id, repoID).Delete(new(Autolink))
+	if err != nil {
+		return err
+	} else if affected == 0 {
+		return errors.AutolinkNotExist{ID: id, RepoID: repoID}
+	}
+
+	if err = reloadAutolinks(repoID); err != nil {
+		return fmt.Errorf("reloadAutolinks: %v", err)
+	}
+	return nil
+}
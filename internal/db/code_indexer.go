@@ -0,0 +1,496 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/unknwon/com"
+	log "unknwon.dev/clog/v2"
+	"xorm.io/xorm"
+
+	"github.com/gogs/git-module"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/sync"
+)
+
+// CodeIndexerQueue is the queue of IDs of repositories to update code index for.
+var CodeIndexerQueue = sync.NewUniqueQueue(1000)
+
+// codeIndexBatchSize is the max number of files read and indexed at a time.
+const codeIndexBatchSize = 50
+
+// CodeIndexFile is a file on the default branch of a repository in the code index.
+// Files are stored in the table only when the index is kept in the database.
+type CodeIndexFile struct {
+	ID       int64
+	RepoID   int64  `xorm:"INDEX"`
+	Path     string `xorm:"TEXT"`
+	Language string `xorm:"INDEX"`
+	Content  string `xorm:"LONGTEXT"`
+}
+
+// RepoIndexerStatus records the commit that code of a repository is indexed at.
+type RepoIndexerStatus struct {
+	ID       int64
+	RepoID   int64  `xorm:"UNIQUE"`
+	CommitID string `xorm:"VARCHAR(40)"`
+
+	Updated     time.Time `xorm:"-" json:"-"`
+	UpdatedUnix int64
+}
+
+func (s *RepoIndexerStatus) BeforeInsert() {
+	s.UpdatedUnix = time.Now().Unix()
+}
+
+func (s *RepoIndexerStatus) BeforeUpdate() {
+	s.UpdatedUnix = time.Now().Unix()
+}
+
+func (s *RepoIndexerStatus) AfterSet(colName string, _ xorm.Cell) {
+	switch colName {
+	case "updated_unix":
+		s.Updated = time.Unix(s.UpdatedUnix, 0).Local()
+	}
+}
+
+// CodeLanguageCount is the number of files matched in a language.
+type CodeLanguageCount struct {
+	Language string
+	Count    int64
+}
+
+// codeSearchHits is the result of a search in the code index.
+type codeSearchHits struct {
+	Files     []*CodeIndexFile
+	Total     int64
+	Languages []*CodeLanguageCount
+}
+
+// codeSearchQuery is a search in the code index.
+type codeSearchQuery struct {
+	Keyword  string
+	Terms    []string // Lower cased terms of the keyword
+	RepoIDs  []int64
+	Language string
+	Page     int
+	PageSize int
+}
+
+// codeIndexer is a backend that keeps the code index.
+type codeIndexer interface {
+	// index adds files of the repository to the index, or replaces existing files
+	// with the same paths.
+	index(repoID int64, files []*CodeIndexFile) error
+	// delete removes files of the repository from the index.
+	delete(repoID int64, paths []string) error
+	// deleteRepo removes all files of the repository from the index.
+	deleteRepo(repoID int64) error
+	// search returns files that contain all terms in content or path, language
+	// counts are not affected by the language filter.
+	search(q *codeSearchQuery) (*codeSearchHits, error)
+}
+
+// codeIdx is the backend of the code index, it is nil when the indexer is disabled.
+var codeIdx codeIndexer
+
+// IsCodeIndexerEnabled returns true if code is indexed for code search.
+func IsCodeIndexerEnabled() bool {
+	return codeIdx != nil
+}
+
+// codeLanguages maps lower cased file extensions or names to languages.
+var codeLanguages = map[string]string{
+	".c":             "C",
+	".h":             "C",
+	".cc":            "C++",
+	".cpp":           "C++",
+	".cxx":           "C++",
+	".hpp":           "C++",
+	".cs":            "C#",
+	".clj":           "Clojure",
+	".coffee":        "CoffeeScript",
+	".css":           "CSS",
+	".dart":          "Dart",
+	".ex":            "Elixir",
+	".exs":           "Elixir",
+	".erl":           "Erlang",
+	".go":            "Go",
+	".groovy":        "Groovy",
+	".hs":            "Haskell",
+	".html":          "HTML",
+	".htm":           "HTML",
+	".ini":           "INI",
+	".java":          "Java",
+	".js":            "JavaScript",
+	".jsx":           "JavaScript",
+	".json":          "JSON",
+	".kt":            "Kotlin",
+	".less":          "Less",
+	".lua":           "Lua",
+	".md":            "Markdown",
+	".markdown":      "Markdown",
+	".m":             "Objective-C",
+	".pl":            "Perl",
+	".php":           "PHP",
+	".ps1":           "PowerShell",
+	".proto":         "Protocol Buffer",
+	".py":            "Python",
+	".r":             "R",
+	".rb":            "Ruby",
+	".rs":            "Rust",
+	".scala":         "Scala",
+	".scss":          "SCSS",
+	".sh":            "Shell",
+	".bash":          "Shell",
+	".sql":           "SQL",
+	".swift":         "Swift",
+	".tex":           "TeX",
+	".toml":          "TOML",
+	".ts":            "TypeScript",
+	".tsx":           "TypeScript",
+	".vb":            "Visual Basic",
+	".vue":           "Vue",
+	".xml":           "XML",
+	".yaml":          "YAML",
+	".yml":           "YAML",
+	"cmakelists.txt": "CMake",
+	"dockerfile":     "Dockerfile",
+	"makefile":       "Makefile",
+}
+
+// codeLanguage returns the language of the file by its name, or "Text" if unknown.
+func codeLanguage(treePath string) string {
+	name := strings.ToLower(path.Base(treePath))
+	if lang, ok := codeLanguages[name]; ok {
+		return lang
+	} else if lang, ok = codeLanguages[path.Ext(name)]; ok {
+		return lang
+	}
+	return "Text"
+}
+
+// isIndexableContent returns true if the content is text that can be indexed.
+func isIndexableContent(content []byte) bool {
+	head := content
+	if len(head) > 8000 {
+		head = head[:8000]
+	}
+	return bytes.IndexByte(head, 0) == -1 && utf8.Valid(content)
+}
+
+// codeIndexEntry is a file to be indexed in the tree of a commit.
+type codeIndexEntry struct {
+	Path   string
+	BlobID string
+}
+
+// parseLsTree parses output of "git ls-tree -r -z" and returns regular files.
+func parseLsTree(data []byte) []*codeIndexEntry {
+	entries := make([]*codeIndexEntry, 0, 10)
+	for _, line := range bytes.Split(data, []byte{0}) {
+		// <mode> SP <type> SP <object> TAB <file>
+		tab := bytes.IndexByte(line, '\t')
+		if tab == -1 {
+			continue
+		}
+		fields := strings.Fields(string(line[:tab]))
+		if len(fields) != 3 || fields[1] != "blob" || fields[0] == "120000" {
+			continue
+		}
+		entries = append(entries, &codeIndexEntry{
+			Path:   string(line[tab+1:]),
+			BlobID: fields[2],
+		})
+	}
+	return entries
+}
+
+// parseDiffTree parses output of "git diff-tree -r -z --no-renames" and returns
+// regular files that are added or modified, and paths of files that are deleted or
+// no longer regular files.
+func parseDiffTree(data []byte) (changed []*codeIndexEntry, deleted []string) {
+	fields := bytes.Split(data, []byte{0})
+	for i := 0; i+1 < len(fields); i += 2 {
+		// :<old mode> SP <new mode> SP <old object> SP <new object> SP <status> NUL <path> NUL
+		info := strings.Fields(strings.TrimPrefix(string(fields[i]), ":"))
+		if len(info) != 5 {
+			continue
+		}
+		treePath := string(fields[i+1])
+		newMode, newBlobID, status := info[1], info[3], info[4]
+		if status == "D" || !strings.HasPrefix(newMode, "100") {
+			deleted = append(deleted, treePath)
+			continue
+		}
+		changed = append(changed, &codeIndexEntry{
+			Path:   treePath,
+			BlobID: newBlobID,
+		})
+	}
+	return changed, deleted
+}
+
+// isCommitExist returns true if the commit exists in the repository.
+func isCommitExist(repoPath, commitID string) bool {
+	_, err := git.NewCommand("cat-file", "-e", commitID+"^{commit}").RunInDir(repoPath)
+	return err == nil
+}
+
+// blobReader reads contents of blobs by a long running "git cat-file --batch".
+type blobReader struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+}
+
+func newBlobReader(repoPath string) (*blobReader, error) {
+	cmd := exec.Command("git", "cat-file", "--batch")
+	cmd.Dir = repoPath
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err = cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &blobReader{
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: bufio.NewReader(stdout),
+	}, nil
+}
+
+// read returns content of the blob, or nil if the blob is larger than maxSize.
+func (r *blobReader) read(blobID string, maxSize int64) ([]byte, error) {
+	if _, err := fmt.Fprintln(r.stdin, blobID); err != nil {
+		return nil, err
+	}
+
+	// <object> SP <type> SP <size> LF <contents> LF
+	header, err := r.stdout.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(header)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("unexpected header of blob %q: %q", blobID, header)
+	}
+	size, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("parse size of blob %q: %v", blobID, err)
+	}
+
+	if maxSize > 0 && size > maxSize {
+		_, err = io.CopyN(ioutil.Discard, r.stdout, size+1)
+		return nil, err
+	}
+	content := make([]byte, size+1)
+	if _, err = io.ReadFull(r.stdout, content); err != nil {
+		return nil, err
+	}
+	return content[:size], nil
+}
+
+func (r *blobReader) close() {
+	r.stdin.Close()
+	if err := r.cmd.Wait(); err != nil {
+		log.Trace("Wait for git cat-file: %v", err)
+	}
+}
+
+// indexCodeEntries reads and indexes files of the repository in batches. Files that
+// cannot be indexed are removed from the index.
+func indexCodeEntries(repoID int64, repoPath string, entries []*codeIndexEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	r, err := newBlobReader(repoPath)
+	if err != nil {
+		return fmt.Errorf("start git cat-file: %v", err)
+	}
+	defer r.close()
+
+	for start := 0; start < len(entries); start += codeIndexBatchSize {
+		end := start + codeIndexBatchSize
+		if end > len(entries) {
+			end = len(entries)
+		}
+
+		files := make([]*CodeIndexFile, 0, end-start)
+		skipped := make([]string, 0, 5)
+		for _, entry := range entries[start:end] {
+			content, err := r.read(entry.BlobID, conf.Indexer.MaxFileSize)
+			if err != nil {
+				return fmt.Errorf("read blob %q: %v", entry.BlobID, err)
+			} else if content == nil || !isIndexableContent(content) {
+				skipped = append(skipped, entry.Path)
+				continue
+			}
+
+			files = append(files, &CodeIndexFile{
+				RepoID:   repoID,
+				Path:     entry.Path,
+				Language: codeLanguage(entry.Path),
+				Content:  string(content),
+			})
+		}
+
+		if err = codeIdx.index(repoID, files); err != nil {
+			return fmt.Errorf("index: %v", err)
+		} else if err = codeIdx.delete(repoID, skipped); err != nil {
+			return fmt.Errorf("delete: %v", err)
+		}
+	}
+	return nil
+}
+
+// UpdateCodeIndex brings the code index of the repository up to date with its default
+// branch. Only changed files are indexed again when the repository has been indexed.
+func (repo *Repository) UpdateCodeIndex() error {
+	if codeIdx == nil {
+		return nil
+	}
+
+	status := &RepoIndexerStatus{RepoID: repo.ID}
+	has, err := x.Get(status)
+	if err != nil {
+		return fmt.Errorf("get indexer status: %v", err)
+	}
+
+	repoPath := repo.RepoPath()
+	commitID := ""
+	if !repo.IsBare {
+		commitID, err = repo.defaultBranchCommitID()
+		if err != nil {
+			return fmt.Errorf("get default branch commit ID: %v", err)
+		}
+	}
+	if has && status.CommitID == commitID {
+		return nil
+	}
+
+	if commitID == "" {
+		if err = codeIdx.deleteRepo(repo.ID); err != nil {
+			return fmt.Errorf("deleteRepo: %v", err)
+		}
+	} else if has && status.CommitID != "" && isCommitExist(repoPath, status.CommitID) {
+		stdout, err := git.NewCommand("diff-tree", "-r", "-z", "--no-renames", status.CommitID, commitID).RunInDirBytes(repoPath)
+		if err != nil {
+			return fmt.Errorf("diff-tree: %v", err)
+		}
+		changed, deleted := parseDiffTree(stdout)
+		if err = codeIdx.delete(repo.ID, deleted); err != nil {
+			return fmt.Errorf("delete: %v", err)
+		} else if err = indexCodeEntries(repo.ID, repoPath, changed); err != nil {
+			return err
+		}
+	} else {
+		// The indexed commit may have been lost by a force push, index from scratch.
+		stdout, err := git.NewCommand("ls-tree", "-r", "-z", "--full-tree", commitID).RunInDirBytes(repoPath)
+		if err != nil {
+			return fmt.Errorf("ls-tree: %v", err)
+		}
+		if err = codeIdx.deleteRepo(repo.ID); err != nil {
+			return fmt.Errorf("deleteRepo: %v", err)
+		} else if err = indexCodeEntries(repo.ID, repoPath, parseLsTree(stdout)); err != nil {
+			return err
+		}
+	}
+
+	status.CommitID = commitID
+	if has {
+		_, err = x.ID(status.ID).AllCols().Update(status)
+	} else {
+		_, err = x.Insert(status)
+	}
+	return err
+}
+
+// AddCodeIndexerTask queues the repository to update its code index, it does nothing
+// when the indexer is disabled.
+func AddCodeIndexerTask(repoID int64) {
+	if codeIdx == nil {
+		return
+	}
+	CodeIndexerQueue.Add(repoID)
+}
+
+// deleteRepoCodeIndex removes all files of the repository from the code index.
+func deleteRepoCodeIndex(repoID int64) {
+	if codeIdx == nil {
+		return
+	}
+	if err := codeIdx.deleteRepo(repoID); err != nil {
+		log.Error("Failed to delete code index [repo_id: %d]: %v", repoID, err)
+	}
+}
+
+// RunCodeIndexer updates code index of queued repositories.
+func RunCodeIndexer() {
+	for repoID := range CodeIndexerQueue.Queue() {
+		log.Trace("RunCodeIndexer [repo_id: %s]", repoID)
+		CodeIndexerQueue.Remove(repoID)
+
+		repo, err := GetRepositoryByID(com.StrTo(repoID).MustInt64())
+		if err != nil {
+			log.Error("GetRepositoryByID [%s]: %v", repoID, err)
+		} else if err = repo.UpdateCodeIndex(); err != nil {
+			log.Error("Failed to update code index [repo_id: %d]: %v", repo.ID, err)
+		}
+	}
+}
+
+// queueAllReposForCodeIndexer queues all repositories to catch up with changes that
+// have been made while the indexer was disabled or not running.
+func queueAllReposForCodeIndexer() {
+	repoIDs := make([]int64, 0, 100)
+	if err := x.Table("repository").Cols("id").Find(&repoIDs); err != nil {
+		log.Error("Failed to get repositories for code indexer: %v", err)
+		return
+	}
+	for _, repoID := range repoIDs {
+		CodeIndexerQueue.Add(repoID)
+	}
+}
+
+// InitCodeIndexer initializes the backend of the code index and starts indexing
+// queued repositories.
+func InitCodeIndexer() {
+	if !conf.Indexer.RepoIndexerEnabled {
+		return
+	}
+
+	switch conf.Indexer.RepoIndexerType {
+	case "elasticsearch":
+		idx, err := newElasticsearchCodeIndexer(conf.Indexer.RepoIndexerElasticsearchURL, conf.Indexer.RepoIndexerElasticsearchIndex)
+		if err != nil {
+			log.Fatal("Failed to initialize Elasticsearch code indexer: %v", err)
+		}
+		codeIdx = idx
+	default:
+		codeIdx = &databaseCodeIndexer{}
+	}
+
+	go RunCodeIndexer()
+	go queueAllReposForCodeIndexer()
+}
@@ -0,0 +1,101 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"xorm.io/xorm"
+)
+
+var _ codeIndexer = (*databaseCodeIndexer)(nil)
+
+// databaseCodeIndexer keeps the code index in the database, files are matched by
+// substrings of their contents and paths.
+type databaseCodeIndexer struct{}
+
+func deleteCodeIndexFiles(e Engine, repoID int64, paths []string) error {
+	// Delete in batches to keep the number of arguments under database limits.
+	const batchSize = 100
+	for start := 0; start < len(paths); start += batchSize {
+		end := start + batchSize
+		if end > len(paths) {
+			end = len(paths)
+		}
+
+		if _, err := e.Where("repo_id = ?", repoID).In("path", paths[start:end]).Delete(new(CodeIndexFile)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (*databaseCodeIndexer) index(repoID int64, files []*CodeIndexFile) (err error) {
+	if len(files) == 0 {
+		return nil
+	}
+
+	paths := make([]string, len(files))
+	for i := range files {
+		paths[i] = files[i].Path
+	}
+
+	sess := x.NewSession()
+	defer sess.Close()
+	if err = sess.Begin(); err != nil {
+		return err
+	}
+
+	if err = deleteCodeIndexFiles(sess, repoID, paths); err != nil {
+		return err
+	} else if _, err = sess.Insert(files); err != nil {
+		return err
+	}
+	return sess.Commit()
+}
+
+func (*databaseCodeIndexer) delete(repoID int64, paths []string) error {
+	return deleteCodeIndexFiles(x, repoID, paths)
+}
+
+func (*databaseCodeIndexer) deleteRepo(repoID int64) error {
+	_, err := x.Delete(&CodeIndexFile{RepoID: repoID})
+	return err
+}
+
+func (*databaseCodeIndexer) search(q *codeSearchQuery) (*codeSearchHits, error) {
+	hits := &codeSearchHits{
+		Files:     make([]*CodeIndexFile, 0, q.PageSize),
+		Languages: make([]*CodeLanguageCount, 0, 5),
+	}
+	if len(q.RepoIDs) == 0 || len(q.Terms) == 0 {
+		return hits, nil
+	}
+
+	cond := func() *xorm.Session {
+		sess := x.In("repo_id", q.RepoIDs)
+		for _, term := range q.Terms {
+			sess.And("(LOWER(content) LIKE ? OR LOWER(path) LIKE ?)", "%"+term+"%", "%"+term+"%")
+		}
+		return sess
+	}
+
+	if err := cond().Table("code_index_file").Select("language, COUNT(*) AS count").
+		GroupBy("language").Desc("count").Find(&hits.Languages); err != nil {
+		return nil, err
+	}
+	for _, lang := range hits.Languages {
+		if q.Language == "" || lang.Language == q.Language {
+			hits.Total += lang.Count
+		}
+	}
+
+	sess := cond()
+	if q.Language != "" {
+		sess.And("language = ?", q.Language)
+	}
+	if err := sess.Asc("repo_id", "id").Limit(q.PageSize, (q.Page-1)*q.PageSize).Find(&hits.Files); err != nil {
+		return nil, err
+	}
+	return hits, nil
+}
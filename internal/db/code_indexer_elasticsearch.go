@@ -0,0 +1,207 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"gogs.io/gogs/internal/tool"
+)
+
+var _ codeIndexer = (*elasticsearchCodeIndexer)(nil)
+
+// elasticsearchCodeMapping is the settings and mappings of the index. Contents and
+// paths are split into identifiers, which are further split by case changes and
+// underscores while keeping the originals, so that "parseHTTPRequest" is matched by
+// "parse", "http", "request" and itself.
+const elasticsearchCodeMapping = `{
+	"settings": {
+		"analysis": {
+			"tokenizer": {
+				"code": {
+					"type": "pattern",
+					"pattern": "[^\\p{L}\\p{N}_]+"
+				}
+			},
+			"filter": {
+				"code_word_delimiter": {
+					"type": "word_delimiter_graph",
+					"preserve_original": true
+				}
+			},
+			"analyzer": {
+				"code": {
+					"type": "custom",
+					"tokenizer": "code",
+					"filter": ["code_word_delimiter", "lowercase"]
+				}
+			}
+		}
+	},
+	"mappings": {
+		"properties": {
+			"repo_id": {"type": "long"},
+			"path": {"type": "text", "analyzer": "code"},
+			"language": {"type": "keyword"},
+			"content": {"type": "text", "analyzer": "code"}
+		}
+	}
+}`
+
+// elasticsearchCodeIndexer keeps the code index in an external Elasticsearch server.
+type elasticsearchCodeIndexer struct {
+	*elasticsearchClient
+}
+
+// newElasticsearchCodeIndexer returns a code indexer of the index in the server, the
+// index is created if not exists.
+func newElasticsearchCodeIndexer(url, index string) (*elasticsearchCodeIndexer, error) {
+	client, _, err := newElasticsearchClient(url, index, elasticsearchCodeMapping)
+	if err != nil {
+		return nil, err
+	}
+	return &elasticsearchCodeIndexer{client}, nil
+}
+
+// documentID returns the ID of the document of the file in the repository.
+func (idx *elasticsearchCodeIndexer) documentID(repoID int64, treePath string) string {
+	return fmt.Sprintf("%d_%s", repoID, tool.SHA1(treePath))
+}
+
+func (idx *elasticsearchCodeIndexer) index(repoID int64, files []*CodeIndexFile) error {
+	if len(files) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, f := range files {
+		if err := enc.Encode(map[string]interface{}{
+			"index": map[string]string{"_index": idx.indexName, "_id": idx.documentID(repoID, f.Path)},
+		}); err != nil {
+			return err
+		} else if err = enc.Encode(map[string]interface{}{
+			"repo_id":  repoID,
+			"path":     f.Path,
+			"language": f.Language,
+			"content":  f.Content,
+		}); err != nil {
+			return err
+		}
+	}
+	return idx.bulk(&buf)
+}
+
+func (idx *elasticsearchCodeIndexer) delete(repoID int64, paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, p := range paths {
+		if err := enc.Encode(map[string]interface{}{
+			"delete": map[string]string{"_index": idx.indexName, "_id": idx.documentID(repoID, p)},
+		}); err != nil {
+			return err
+		}
+	}
+	return idx.bulk(&buf)
+}
+
+func (idx *elasticsearchCodeIndexer) deleteRepo(repoID int64) error {
+	return idx.deleteByRepo(repoID)
+}
+
+func (idx *elasticsearchCodeIndexer) search(q *codeSearchQuery) (*codeSearchHits, error) {
+	hits := &codeSearchHits{
+		Files:     make([]*CodeIndexFile, 0, q.PageSize),
+		Languages: make([]*CodeLanguageCount, 0, 5),
+	}
+	if len(q.RepoIDs) == 0 || len(q.Terms) == 0 {
+		return hits, nil
+	}
+
+	query := map[string]interface{}{
+		"from":             (q.Page - 1) * q.PageSize,
+		"size":             q.PageSize,
+		"track_total_hits": true,
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must": map[string]interface{}{
+					"multi_match": map[string]interface{}{
+						"query":    q.Keyword,
+						"fields":   []string{"content", "path^2"},
+						"operator": "and",
+					},
+				},
+				"filter": map[string]interface{}{
+					"terms": map[string]interface{}{"repo_id": q.RepoIDs},
+				},
+			},
+		},
+		"aggs": map[string]interface{}{
+			"languages": map[string]interface{}{
+				"terms": map[string]interface{}{"field": "language", "size": 20},
+			},
+		},
+	}
+	// Post filter does not affect aggregations of languages.
+	if q.Language != "" {
+		query["post_filter"] = map[string]interface{}{
+			"term": map[string]interface{}{"language": q.Language},
+		}
+	}
+	body, err := json.Marshal(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Hits struct {
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				Source struct {
+					RepoID   int64  `json:"repo_id"`
+					Path     string `json:"path"`
+					Language string `json:"language"`
+					Content  string `json:"content"`
+				} `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+		Aggregations struct {
+			Languages struct {
+				Buckets []struct {
+					Key      string `json:"key"`
+					DocCount int64  `json:"doc_count"`
+				} `json:"buckets"`
+			} `json:"languages"`
+		} `json:"aggregations"`
+	}
+	if err = idx.request("POST", "/"+idx.indexName+"/_search", "application/json", bytes.NewReader(body), &result); err != nil {
+		return nil, err
+	}
+
+	hits.Total = result.Hits.Total.Value
+	for _, hit := range result.Hits.Hits {
+		hits.Files = append(hits.Files, &CodeIndexFile{
+			RepoID:   hit.Source.RepoID,
+			Path:     hit.Source.Path,
+			Language: hit.Source.Language,
+			Content:  hit.Source.Content,
+		})
+	}
+	for _, bucket := range result.Aggregations.Languages.Buckets {
+		hits.Languages = append(hits.Languages, &CodeLanguageCount{
+			Language: bucket.Key,
+			Count:    bucket.DocCount,
+		})
+	}
+	return hits, nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_codeLanguage(t *testing.T) {
+	Convey("Detect language of files", t, func() {
+		testCases := []struct {
+			path   string
+			expect string
+		}{
+			{"main.go", "Go"},
+			{"web/App.TSX", "TypeScript"},
+			{"Dockerfile", "Dockerfile"},
+			{"build/Makefile", "Makefile"},
+			{"LICENSE", "Text"},
+		}
+		for _, tc := range testCases {
+			So(codeLanguage(tc.path), ShouldEqual, tc.expect)
+		}
+	})
+}
+
+func Test_parseLsTree(t *testing.T) {
+	Convey("Parse regular files from ls-tree", t, func() {
+		data := "100644 blob 1111111111111111111111111111111111111111\tREADME.md\x00" +
+			"120000 blob 2222222222222222222222222222222222222222\tlink\x00" +
+			"160000 commit 3333333333333333333333333333333333333333\tvendor/lib\x00" +
+			"100755 blob 4444444444444444444444444444444444444444\tscripts/a b.sh\x00"
+		entries := parseLsTree([]byte(data))
+		So(entries, ShouldHaveLength, 2)
+		So(entries[0].Path, ShouldEqual, "README.md")
+		So(entries[0].BlobID, ShouldEqual, "1111111111111111111111111111111111111111")
+		So(entries[1].Path, ShouldEqual, "scripts/a b.sh")
+	})
+}
+
+func Test_parseDiffTree(t *testing.T) {
+	Convey("Parse changed and deleted files from diff-tree", t, func() {
+		data := ":100644 100644 1111111111111111111111111111111111111111 2222222222222222222222222222222222222222 M\x00main.go\x00" +
+			":000000 100644 0000000000000000000000000000000000000000 3333333333333333333333333333333333333333 A\x00new.go\x00" +
+			":100644 000000 4444444444444444444444444444444444444444 0000000000000000000000000000000000000000 D\x00old.go\x00" +
+			":100644 120000 5555555555555555555555555555555555555555 6666666666666666666666666666666666666666 T\x00link\x00"
+		changed, deleted := parseDiffTree([]byte(data))
+		So(changed, ShouldHaveLength, 2)
+		So(changed[0].Path, ShouldEqual, "main.go")
+		So(changed[0].BlobID, ShouldEqual, "2222222222222222222222222222222222222222")
+		So(changed[1].Path, ShouldEqual, "new.go")
+		So(deleted, ShouldResemble, []string{"old.go", "link"})
+	})
+}
+
+func Test_codeSearchLines(t *testing.T) {
+	Convey("Get matched lines with context", t, func() {
+		content := "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hello\")\n}\n"
+
+		Convey("Matched lines are merged with overlapping context", func() {
+			lines := codeSearchLines(content, []string{"fmt"})
+			nums := make([]int, len(lines))
+			for i := range lines {
+				nums[i] = lines[i].Num
+			}
+			So(nums, ShouldResemble, []int{2, 3, 4, 5, 6, 7})
+			So(lines[1].IsMatch, ShouldBeTrue)
+			So(lines[4].IsMatch, ShouldBeTrue)
+			So(lines[3].IsGap, ShouldBeFalse)
+		})
+
+		Convey("Gaps are marked between fragments", func() {
+			lines := codeSearchLines(content, []string{"package", "println"})
+			So(lines, ShouldHaveLength, 5)
+			So(lines[2].Num, ShouldEqual, 5)
+			So(lines[2].IsGap, ShouldBeTrue)
+		})
+
+		Convey("First lines are returned without matches", func() {
+			lines := codeSearchLines(content, []string{"main.go"})
+			So(lines, ShouldHaveLength, 3)
+			So(lines[0].IsMatch, ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,169 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// maxCodeSearchMatches is the max number of matched lines shown for a file.
+	maxCodeSearchMatches = 3
+	// codeSearchContextLines is the number of lines shown around a matched line.
+	codeSearchContextLines = 1
+)
+
+// CodeSearchLine is a line of a file in code search results.
+type CodeSearchLine struct {
+	Num     int
+	Content string
+	IsMatch bool
+	// IsGap is true if lines are omitted between the previous line and this line.
+	IsGap bool
+}
+
+// CodeSearchResult is a file matched in code search.
+type CodeSearchResult struct {
+	Repo     *Repository
+	Path     string
+	Language string
+	// Lines are matched lines with context.
+	Lines []*CodeSearchLine
+}
+
+type SearchCodeOptions struct {
+	Keyword string
+	// UserID is the user who searches, only repositories that the user has access to
+	// are searched. Zero means an anonymous user.
+	UserID int64
+	// RepoID limits the search to a single repository when it is not zero.
+	RepoID   int64
+	Language string
+	Page     int
+	PageSize int
+}
+
+// codeSearchRepoIDs returns IDs of indexed repositories that the user has access to.
+func codeSearchRepoIDs(userID, repoID int64) ([]int64, error) {
+	cond, args := repoAccessCond(userID)
+	sess := x.Table("repository").Join("INNER", "repo_indexer_status", "repo_indexer_status.repo_id = repository.id").
+		Where(cond, args...)
+	if repoID > 0 {
+		sess.And("repository.id = ?", repoID)
+	}
+	if userID > 0 {
+		// Exclude private repositories that the user cannot read code of.
+		sess.And("NOT (repository.is_private = ? AND repository.id IN (SELECT repo_id FROM access_unit WHERE user_id = ? AND unit = ? AND mode = ?))",
+			true, userID, REPO_UNIT_CODE, ACCESS_MODE_NONE)
+	}
+
+	repoIDs := make([]int64, 0, 10)
+	return repoIDs, sess.Cols("repository.id").Find(&repoIDs)
+}
+
+// codeSearchLines returns lines that contain any of the terms with context. The first
+// lines are returned when no line matches, e.g. the file is matched by its path.
+func codeSearchLines(content string, terms []string) []*CodeSearchLine {
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+
+	matched := make([]int, 0, maxCodeSearchMatches)
+	isMatched := make(map[int]bool)
+	for i := range lines {
+		lower := strings.ToLower(lines[i])
+		for _, term := range terms {
+			if strings.Contains(lower, term) {
+				matched = append(matched, i)
+				isMatched[i] = true
+				break
+			}
+		}
+		if len(matched) == maxCodeSearchMatches {
+			break
+		}
+	}
+
+	results := make([]*CodeSearchLine, 0, maxCodeSearchMatches*(2*codeSearchContextLines+1))
+	if len(matched) == 0 {
+		for i := 0; i < len(lines) && i < maxCodeSearchMatches; i++ {
+			results = append(results, &CodeSearchLine{Num: i + 1, Content: lines[i]})
+		}
+		return results
+	}
+
+	next := 0 // The first line that has not been added
+	for _, m := range matched {
+		start := m - codeSearchContextLines
+		if start < next {
+			start = next
+		}
+		end := m + codeSearchContextLines
+		if end >= len(lines) {
+			end = len(lines) - 1
+		}
+		for i := start; i <= end; i++ {
+			results = append(results, &CodeSearchLine{
+				Num:     i + 1,
+				Content: lines[i],
+				IsMatch: isMatched[i],
+				IsGap:   i == start && start > next && len(results) > 0,
+			})
+		}
+		next = end + 1
+	}
+	return results
+}
+
+// SearchCode returns files that contain all words of the keyword in their contents or
+// paths on default branches of repositories, and numbers of matched files in each
+// language.
+func SearchCode(opts *SearchCodeOptions) (_ []*CodeSearchResult, _ int64, _ []*CodeLanguageCount, err error) {
+	if codeIdx == nil {
+		return nil, 0, nil, fmt.Errorf("code indexer is not enabled")
+	}
+	if opts.Page <= 0 {
+		opts.Page = 1
+	}
+
+	q := &codeSearchQuery{
+		Keyword:  opts.Keyword,
+		Terms:    strings.Fields(strings.ToLower(opts.Keyword)),
+		Language: opts.Language,
+		Page:     opts.Page,
+		PageSize: opts.PageSize,
+	}
+	q.RepoIDs, err = codeSearchRepoIDs(opts.UserID, opts.RepoID)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("codeSearchRepoIDs: %v", err)
+	}
+
+	hits, err := codeIdx.search(q)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("search: %v", err)
+	}
+
+	repos := make(map[int64]*Repository)
+	results := make([]*CodeSearchResult, 0, len(hits.Files))
+	for _, f := range hits.Files {
+		repo, ok := repos[f.RepoID]
+		if !ok {
+			repo, err = GetRepositoryByID(f.RepoID)
+			if err != nil {
+				return nil, 0, nil, fmt.Errorf("GetRepositoryByID [%d]: %v", f.RepoID, err)
+			} else if err = repo.GetOwner(); err != nil {
+				return nil, 0, nil, fmt.Errorf("GetOwner [repo_id: %d]: %v", f.RepoID, err)
+			}
+			repos[f.RepoID] = repo
+		}
+
+		results = append(results, &CodeSearchResult{
+			Repo:     repo,
+			Path:     f.Path,
+			Language: f.Language,
+			Lines:    codeSearchLines(f.Content, q.Terms),
+		})
+	}
+	return results, hits.Total, hits.Languages, nil
+}
@@ -59,6 +59,8 @@ type Comment struct {
 	Line            int64
 	Content         string `xorm:"TEXT"`
 	RenderedContent string `xorm:"-" json:"-"`
+	NumRevisions    int
+	Revisions       []*CommentRevision `xorm:"-" json:"-"`
 
 	Created     time.Time `xorm:"-" json:"-"`
 	CreatedUnix int64
@@ -68,6 +70,12 @@ type Comment struct {
 	// Reference issue in commit message
 	CommitSHA string `xorm:"VARCHAR(40)"`
 
+	// Conversation resolution of pull request comments
+	ResolverID   int64
+	Resolver     *User     `xorm:"-" json:"-"`
+	Resolved     time.Time `xorm:"-" json:"-"`
+	ResolvedUnix int64
+
 	Attachments []*Attachment `xorm:"-" json:"-"`
 
 	// For view issue page.
@@ -75,8 +83,13 @@ type Comment struct {
 }
 
 func (c *Comment) BeforeInsert() {
-	c.CreatedUnix = time.Now().Unix()
-	c.UpdatedUnix = c.CreatedUnix
+	// Imported comments come with their original time.
+	if c.CreatedUnix == 0 {
+		c.CreatedUnix = time.Now().Unix()
+	}
+	if c.UpdatedUnix == 0 {
+		c.UpdatedUnix = c.CreatedUnix
+	}
 }
 
 func (c *Comment) BeforeUpdate() {
@@ -89,6 +102,8 @@ func (c *Comment) AfterSet(colName string, _ xorm.Cell) {
 		c.Created = time.Unix(c.CreatedUnix, 0).Local()
 	case "updated_unix":
 		c.Updated = time.Unix(c.UpdatedUnix, 0).Local()
+	case "resolved_unix":
+		c.Resolved = time.Unix(c.ResolvedUnix, 0).Local()
 	}
 }
 
@@ -97,14 +112,24 @@ func (c *Comment) loadAttributes(e Engine) (err error) {
 		c.Poster, err = GetUserByID(c.PosterID)
 		if err != nil {
 			if errors.IsUserNotExist(err) {
+				c.Poster = getGhostUser(e, c.PosterID)
 				c.PosterID = -1
-				c.Poster = NewGhostUser()
 			} else {
 				return fmt.Errorf("getUserByID.(Poster) [%d]: %v", c.PosterID, err)
 			}
 		}
 	}
 
+	if c.Resolver == nil && c.ResolverID > 0 {
+		c.Resolver, err = getUserByID(e, c.ResolverID)
+		if err != nil {
+			if !errors.IsUserNotExist(err) {
+				return fmt.Errorf("getUserByID.(Resolver) [%d]: %v", c.ResolverID, err)
+			}
+			c.Resolver = getGhostUser(e, c.ResolverID)
+		}
+	}
+
 	if c.Issue == nil {
 		c.Issue, err = getRawIssueByID(e, c.IssueID)
 		if err != nil {
@@ -149,6 +174,11 @@ func (c *Comment) APIFormat() *api.Comment {
 	}
 }
 
+// IsResolved returns true if the conversation started by the comment has been resolved.
+func (c *Comment) IsResolved() bool {
+	return c.ResolverID > 0
+}
+
 func CommentHashTag(id int64) string {
 	return "issuecomment-" + com.ToStr(id)
 }
@@ -204,13 +234,14 @@ func createComment(e *xorm.Session, opts *CreateCommentOptions) (_ *Comment, err
 	// Compose comment action, could be plain comment, close or reopen issue/pull request.
 	// This object will be used to notify watchers in the end of function.
 	act := &Action{
-		ActUserID:    opts.Doer.ID,
-		ActUserName:  opts.Doer.Name,
-		Content:      fmt.Sprintf("%d|%s", opts.Issue.Index, strings.Split(opts.Content, "\n")[0]),
-		RepoID:       opts.Repo.ID,
-		RepoUserName: opts.Repo.Owner.Name,
-		RepoName:     opts.Repo.Name,
-		IsPrivate:    opts.Repo.IsPrivate,
+		ActUserID:      opts.Doer.ID,
+		ActUserName:    opts.Doer.Name,
+		Content:        fmt.Sprintf("%d|%s", opts.Issue.Index, strings.Split(opts.Content, "\n")[0]),
+		RepoID:         opts.Repo.ID,
+		RepoUserName:   opts.Repo.Owner.Name,
+		RepoName:       opts.Repo.Name,
+		IsPrivate:      opts.Repo.IsPrivate || opts.Issue.IsConfidential,
+		IsConfidential: opts.Issue.IsConfidential,
 	}
 
 	// Check comment type.
@@ -222,6 +253,13 @@ func createComment(e *xorm.Session, opts *CreateCommentOptions) (_ *Comment, err
 			return nil, err
 		}
 
+		// Commenting on a pull request fulfills the requested review of the user.
+		if opts.Issue.IsPull {
+			if err = markReviewRequestDone(e, opts.Issue.ID, opts.Doer.ID); err != nil {
+				return nil, fmt.Errorf("markReviewRequestDone: %v", err)
+			}
+		}
+
 		// Check attachments
 		attachments := make([]*Attachment, 0, len(opts.Attachments))
 		for _, uuid := range opts.Attachments {
@@ -287,6 +325,9 @@ func createComment(e *xorm.Session, opts *CreateCommentOptions) (_ *Comment, err
 		if err = comment.mailParticipants(e, act.OpType, opts.Issue); err != nil {
 			log.Error("MailParticipants: %v", err)
 		}
+		if err = notifyIssueParticipants(e, opts.Repo, opts.Issue, comment.ID, opts.Doer.ID, opts.Content); err != nil {
+			log.Error("notifyIssueParticipants: %v", err)
+		}
 	}
 
 	return comment, comment.loadAttributes(e)
@@ -349,6 +390,10 @@ func CreateIssueComment(doer *User, repo *Repository, issue *Issue, content stri
 	}
 
 	comment.Issue = issue
+	if err = createIssueReferenceEvents(doer, issue, comment.ID, content); err != nil {
+		log.Error("createIssueReferenceEvents [comment_id: %d]: %v", comment.ID, err)
+	}
+
 	if err = PrepareWebhooks(repo, HOOK_EVENT_ISSUE_COMMENT, &api.IssueCommentPayload{
 		Action:     api.HOOK_ISSUE_COMMENT_CREATED,
 		Issue:      issue.APIFormat(),
@@ -427,12 +472,16 @@ func getCommentsByIssueIDSince(e Engine, issueID, since int64) ([]*Comment, erro
 	return comments, loadCommentsAttributes(e, comments)
 }
 
-func getCommentsByRepoIDSince(e Engine, repoID, since int64) ([]*Comment, error) {
+func getCommentsByRepoIDSince(e Engine, repoID, since int64, hideConfidential bool, userID int64) ([]*Comment, error) {
 	comments := make([]*Comment, 0, 10)
 	sess := e.Where("issue.repo_id = ?", repoID).Join("INNER", "issue", "issue.id = comment.issue_id").Asc("comment.created_unix")
 	if since > 0 {
 		sess.And("comment.updated_unix >= ?", since)
 	}
+	if hideConfidential {
+		cond, args := confidentialIssuesCond(userID)
+		sess.And(cond, args...)
+	}
 	if err := sess.Find(&comments); err != nil {
 		return nil, err
 	}
@@ -454,16 +503,33 @@ func GetCommentsByIssueIDSince(issueID, since int64) ([]*Comment, error) {
 }
 
 // GetCommentsByRepoIDSince returns a list of comments for all issues in a repo since a given time point.
-func GetCommentsByRepoIDSince(repoID, since int64) ([]*Comment, error) {
-	return getCommentsByRepoIDSince(x, repoID, since)
+// Comments of confidential issues that the user cannot see are excluded when hideConfidential is true.
+func GetCommentsByRepoIDSince(repoID, since int64, hideConfidential bool, userID int64) ([]*Comment, error) {
+	return getCommentsByRepoIDSince(x, repoID, since, hideConfidential, userID)
 }
 
 // UpdateComment updates information of comment.
 func UpdateComment(doer *User, c *Comment, oldContent string) (err error) {
-	if _, err = x.Id(c.ID).AllCols().Update(c); err != nil {
+	sess := x.NewSession()
+	defer sess.Close()
+	if err = sess.Begin(); err != nil {
 		return err
 	}
 
+	if _, err = sess.ID(c.ID).AllCols().Update(c); err != nil {
+		return err
+	}
+	if c.Content != oldContent {
+		if err = createCommentRevision(sess, c, doer.ID, oldContent); err != nil {
+			return fmt.Errorf("createCommentRevision: %v", err)
+		}
+		c.NumRevisions++
+	}
+
+	if err = sess.Commit(); err != nil {
+		return fmt.Errorf("commit: %v", err)
+	}
+
 	if err = c.Issue.LoadAttributes(); err != nil {
 		log.Error("Issue.LoadAttributes [issue_id: %d]: %v", c.IssueID, err)
 	} else if err = PrepareWebhooks(c.Issue.Repo, HOOK_EVENT_ISSUE_COMMENT, &api.IssueCommentPayload{
@@ -484,6 +550,30 @@ func UpdateComment(doer *User, c *Comment, oldContent string) (err error) {
 	return nil
 }
 
+// ResolveComment marks the conversation started by the comment as resolved by doer.
+func ResolveComment(doer *User, c *Comment) error {
+	c.ResolverID = doer.ID
+	c.Resolver = doer
+	c.ResolvedUnix = time.Now().Unix()
+	c.Resolved = time.Unix(c.ResolvedUnix, 0).Local()
+	_, err := x.ID(c.ID).Cols("resolver_id", "resolved_unix").Update(c)
+	return err
+}
+
+// UnresolveComment marks the conversation started by the comment as unresolved.
+func UnresolveComment(c *Comment) error {
+	c.ResolverID = 0
+	c.Resolver = nil
+	c.ResolvedUnix = 0
+	_, err := x.ID(c.ID).Cols("resolver_id", "resolved_unix").Update(c)
+	return err
+}
+
+// CountUnresolvedComments returns the number of unresolved conversations of the issue.
+func CountUnresolvedComments(issueID int64) (int64, error) {
+	return x.Where("issue_id = ? AND type = ? AND resolver_id = 0", issueID, COMMENT_TYPE_COMMENT).Count(new(Comment))
+}
+
 // DeleteCommentByID deletes the comment by given ID.
 func DeleteCommentByID(doer *User, id int64) error {
 	comment, err := GetCommentByID(id)
@@ -503,6 +593,9 @@ func DeleteCommentByID(doer *User, id int64) error {
 	if _, err = sess.ID(comment.ID).Delete(new(Comment)); err != nil {
 		return err
 	}
+	if _, err = sess.Delete(&CommentRevision{CommentID: comment.ID}); err != nil {
+		return err
+	}
 
 	if comment.Type == COMMENT_TYPE_COMMENT {
 		if _, err = sess.Exec("UPDATE `issue` SET num_comments = num_comments - 1 WHERE id = ?", comment.IssueID); err != nil {
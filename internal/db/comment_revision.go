@@ -0,0 +1,117 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"xorm.io/xorm"
+
+	"gogs.io/gogs/internal/db/errors"
+)
+
+// CommentRevision is the content of a comment before it was edited.
+type CommentRevision struct {
+	ID        int64
+	IssueID   int64 `xorm:"INDEX"`
+	CommentID int64 `xorm:"INDEX"`
+	// EditorID is the user who replaced the content by editing the comment.
+	EditorID int64
+	Editor   *User  `xorm:"-" json:"-"`
+	Content  string `xorm:"TEXT"`
+
+	Created     time.Time `xorm:"-" json:"-"`
+	CreatedUnix int64
+}
+
+func (r *CommentRevision) BeforeInsert() {
+	r.CreatedUnix = time.Now().Unix()
+}
+
+func (r *CommentRevision) AfterSet(colName string, _ xorm.Cell) {
+	switch colName {
+	case "created_unix":
+		r.Created = time.Unix(r.CreatedUnix, 0).Local()
+	}
+}
+
+func (r *CommentRevision) loadAttributes(e Engine) (err error) {
+	if r.Editor == nil {
+		r.Editor, err = getUserByID(e, r.EditorID)
+		if err != nil {
+			if !errors.IsUserNotExist(err) {
+				return fmt.Errorf("getUserByID [%d]: %v", r.EditorID, err)
+			}
+			r.Editor = NewGhostUser()
+		}
+	}
+	return nil
+}
+
+// createCommentRevision keeps the content of the comment before it is replaced by
+// the editor.
+func createCommentRevision(e Engine, c *Comment, editorID int64, oldContent string) error {
+	if _, err := e.Insert(&CommentRevision{
+		IssueID:   c.IssueID,
+		CommentID: c.ID,
+		EditorID:  editorID,
+		Content:   oldContent,
+	}); err != nil {
+		return err
+	}
+
+	_, err := e.Exec("UPDATE `comment` SET num_revisions = num_revisions + 1 WHERE id = ?", c.ID)
+	return err
+}
+
+// GetCommentRevisions returns revisions of the comment, the most recent first.
+func GetCommentRevisions(commentID int64) ([]*CommentRevision, error) {
+	revisions := make([]*CommentRevision, 0, 5)
+	if err := x.Where("comment_id = ?", commentID).Desc("id").Find(&revisions); err != nil {
+		return nil, err
+	}
+
+	for i := range revisions {
+		if err := revisions[i].loadAttributes(x); err != nil {
+			return nil, err
+		}
+	}
+	return revisions, nil
+}
+
+// GetCommentRevision returns the revision of given ID of the comment.
+func GetCommentRevision(commentID, id int64) (*CommentRevision, error) {
+	r := new(CommentRevision)
+	has, err := x.Where("id = ? AND comment_id = ?", id, commentID).Get(r)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		return nil, errors.CommentRevisionNotExist{ID: id, CommentID: commentID}
+	}
+	return r, r.loadAttributes(x)
+}
+
+// DeleteCommentRevision deletes the revision of given ID of the comment, e.g. to
+// remove sensitive information that was edited out.
+func DeleteCommentRevision(commentID, id int64) error {
+	sess := x.NewSession()
+	defer sess.Close()
+	if err := sess.Begin(); err != nil {
+		return err
+	}
+
+	affected, err := sess.Where("id = ? AND comment_id = ?", id, commentID).Delete(new(CommentRevision))
+	if err != nil {
+		return err
+	} else if affected == 0 {
+		return errors.CommentRevisionNotExist{ID: id, CommentID: commentID}
+	}
+
+	if _, err = sess.Exec("UPDATE `comment` SET num_revisions = num_revisions - 1 WHERE id = ?", commentID); err != nil {
+		return err
+	}
+	return sess.Commit()
+}
@@ -0,0 +1,70 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/gogs/git-module"
+)
+
+// CommitCoAuthor is a co-author of a commit given by a "Co-authored-by" trailer.
+type CommitCoAuthor struct {
+	Name  string
+	Email string
+	// User is the account that the email belongs to, or nil if there is none.
+	User *User
+}
+
+var coAuthorPattern = regexp.MustCompile(`(?im)^co-authored-by:[ \t]*([^<\r\n]*?)[ \t]*<([^>\r\n]+)>[ \t]*$`)
+
+// parseCoAuthors returns co-authors in "Co-authored-by" trailers of the commit message.
+// Co-authors are distinct by emails, and the author itself is excluded.
+func parseCoAuthors(message, authorEmail string) []*CommitCoAuthor {
+	matches := coAuthorPattern.FindAllStringSubmatch(message, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+
+	seen := map[string]bool{
+		strings.ToLower(authorEmail): true,
+	}
+	coAuthors := make([]*CommitCoAuthor, 0, len(matches))
+	for _, m := range matches {
+		email := strings.TrimSpace(m[2])
+		if seen[strings.ToLower(email)] {
+			continue
+		}
+		seen[strings.ToLower(email)] = true
+
+		name := m[1]
+		if name == "" {
+			name = email
+		}
+		coAuthors = append(coAuthors, &CommitCoAuthor{
+			Name:  name,
+			Email: email,
+		})
+	}
+	return coAuthors
+}
+
+// getCommitCoAuthors returns co-authors of the commit with accounts matched by getUser.
+func getCommitCoAuthors(c *git.Commit, getUser func(email string) *User) []*CommitCoAuthor {
+	coAuthors := parseCoAuthors(c.Message(), c.Author.Email)
+	for i := range coAuthors {
+		coAuthors[i].User = getUser(coAuthors[i].Email)
+	}
+	return coAuthors
+}
+
+// GetCommitCoAuthors returns co-authors of the commit with their accounts if exist.
+func GetCommitCoAuthors(c *git.Commit) []*CommitCoAuthor {
+	return getCommitCoAuthors(c, func(email string) *User {
+		u, _ := GetUserByEmail(email)
+		return u
+	})
+}
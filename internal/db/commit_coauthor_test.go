@@ -0,0 +1,35 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_parseCoAuthors(t *testing.T) {
+	Convey("Parse co-authors of commit message", t, func() {
+		message := `Fix race condition
+
+Details of the fix.
+
+Signed-off-by: Alice <alice@example.com>
+Co-authored-by: Bob <bob@example.com>
+co-authored-by: Carol Smith <carol@example.com>
+Co-Authored-By: Bob Again <BOB@example.com>
+Co-authored-by: Alice <Alice@example.com>
+Co-authored-by: <dave@example.com>
+Co-authored-by: Eve`
+
+		coAuthors := parseCoAuthors(message, "alice@example.com")
+		So(coAuthors, ShouldHaveLength, 3)
+		So(*coAuthors[0], ShouldResemble, CommitCoAuthor{Name: "Bob", Email: "bob@example.com"})
+		So(*coAuthors[1], ShouldResemble, CommitCoAuthor{Name: "Carol Smith", Email: "carol@example.com"})
+		So(*coAuthors[2], ShouldResemble, CommitCoAuthor{Name: "dave@example.com", Email: "dave@example.com"})
+
+		So(parseCoAuthors("Fix typo", "alice@example.com"), ShouldBeNil)
+	})
+}
@@ -0,0 +1,201 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"xorm.io/xorm"
+
+	"gogs.io/gogs/internal/db/errors"
+)
+
+// CommitStatusState is the state of a status reported by an external system, e.g.
+// a continuous integration service, for a commit.
+type CommitStatusState string
+
+const (
+	COMMIT_STATUS_PENDING CommitStatusState = "pending"
+	COMMIT_STATUS_SUCCESS CommitStatusState = "success"
+	COMMIT_STATUS_ERROR   CommitStatusState = "error"
+	COMMIT_STATUS_FAILURE CommitStatusState = "failure"
+)
+
+// commitStatusStatePriorities is used to decide the combined state of statuses, the
+// state with the highest priority wins.
+var commitStatusStatePriorities = map[CommitStatusState]int{
+	COMMIT_STATUS_SUCCESS: 1,
+	COMMIT_STATUS_PENDING: 2,
+	COMMIT_STATUS_FAILURE: 3,
+	COMMIT_STATUS_ERROR:   4,
+}
+
+// IsValid returns true if the state is a known state.
+func (s CommitStatusState) IsValid() bool {
+	return commitStatusStatePriorities[s] > 0
+}
+
+// IsFinished returns true if the state will not change without a new run.
+func (s CommitStatusState) IsFinished() bool {
+	return s.IsValid() && s != COMMIT_STATUS_PENDING
+}
+
+// CombineCommitStatusStates returns the combined state of given states: it is failed
+// if any is failed, pending if any is pending, and successful only if all are
+// successful. Empty states are combined as pending.
+func CombineCommitStatusStates(states ...CommitStatusState) CommitStatusState {
+	combined := COMMIT_STATUS_PENDING
+	if len(states) > 0 {
+		combined = COMMIT_STATUS_SUCCESS
+	}
+	for _, s := range states {
+		if commitStatusStatePriorities[s] > commitStatusStatePriorities[combined] {
+			combined = s
+		}
+	}
+	return combined
+}
+
+// CommitStatus is a status reported for a commit of a repository. Statuses of the
+// same context are runs of the same check, the latest one is the current state.
+type CommitStatus struct {
+	ID          int64
+	RepoID      int64             `xorm:"INDEX(s) NOT NULL"`
+	SHA         string            `xorm:"VARCHAR(40) INDEX(s) NOT NULL"`
+	State       CommitStatusState `xorm:"VARCHAR(7) NOT NULL"`
+	TargetURL   string            `xorm:"TEXT"`
+	Description string            `xorm:"TEXT"`
+	Context     string            `xorm:"NOT NULL DEFAULT 'default'"`
+	CreatorID   int64
+	Creator     *User `xorm:"-" json:"-"`
+
+	Created     time.Time `xorm:"-" json:"-"`
+	CreatedUnix int64
+}
+
+func (s *CommitStatus) BeforeInsert() {
+	s.CreatedUnix = time.Now().Unix()
+}
+
+func (s *CommitStatus) AfterSet(colName string, _ xorm.Cell) {
+	switch colName {
+	case "created_unix":
+		s.Created = time.Unix(s.CreatedUnix, 0).Local()
+	}
+}
+
+func (s *CommitStatus) loadAttributes(e Engine) (err error) {
+	if s.Creator == nil {
+		s.Creator, err = getUserByID(e, s.CreatorID)
+		if errors.IsUserNotExist(err) {
+			s.Creator = NewGhostUser()
+			err = nil
+		} else if err != nil {
+			return fmt.Errorf("getUserByID [%d]: %v", s.CreatorID, err)
+		}
+	}
+	return nil
+}
+
+// CreateCommitStatus creates a new status for the commit of the repository.
+func CreateCommitStatus(repo *Repository, doer *User, sha string, status *CommitStatus) error {
+	if !status.State.IsValid() {
+		return fmt.Errorf("invalid state %q", status.State)
+	}
+	if status.Context == "" {
+		status.Context = "default"
+	}
+	status.RepoID = repo.ID
+	status.SHA = sha
+	status.CreatorID = doer.ID
+	status.Creator = doer
+	_, err := x.Insert(status)
+	return err
+}
+
+// GetCommitStatuses returns all statuses of the commit of given repositories, the
+// latest first.
+func GetCommitStatuses(repoIDs []int64, sha string) ([]*CommitStatus, error) {
+	statuses := make([]*CommitStatus, 0, 10)
+	if err := x.In("repo_id", repoIDs).And("sha = ?", sha).Desc("id").Find(&statuses); err != nil {
+		return nil, err
+	}
+	for _, s := range statuses {
+		if err := s.loadAttributes(x); err != nil {
+			return nil, err
+		}
+	}
+	return statuses, nil
+}
+
+// CommitCheck aggregates statuses of the same context of a commit.
+type CommitCheck struct {
+	Context string
+	// Latest is the current status of the check.
+	Latest *CommitStatus
+	// Statuses are all statuses of the check, the latest first.
+	Statuses []*CommitStatus
+	// Started is when the first status was reported.
+	Started time.Time
+}
+
+// State returns the current state of the check.
+func (c *CommitCheck) State() CommitStatusState {
+	return c.Latest.State
+}
+
+// Duration returns how long the check ran from the first status to the latest status,
+// it is zero if the check is not finished yet.
+func (c *CommitCheck) Duration() time.Duration {
+	if !c.Latest.State.IsFinished() {
+		return 0
+	}
+	return c.Latest.Created.Sub(c.Started)
+}
+
+// GroupCommitStatuses groups statuses, which are sorted by the latest first, by their
+// contexts. Checks are sorted by name of contexts.
+func GroupCommitStatuses(statuses []*CommitStatus) []*CommitCheck {
+	checks := make([]*CommitCheck, 0, len(statuses))
+	contextChecks := make(map[string]*CommitCheck, len(statuses))
+	for _, s := range statuses {
+		check, ok := contextChecks[s.Context]
+		if !ok {
+			check = &CommitCheck{
+				Context: s.Context,
+				Latest:  s,
+			}
+			contextChecks[s.Context] = check
+			checks = append(checks, check)
+		}
+		check.Statuses = append(check.Statuses, s)
+		check.Started = s.Created
+	}
+	sort.SliceStable(checks, func(i, j int) bool {
+		return checks[i].Context < checks[j].Context
+	})
+	return checks
+}
+
+// CombineCommitChecks returns the combined state of current states of the checks.
+func CombineCommitChecks(checks []*CommitCheck) CommitStatusState {
+	states := make([]CommitStatusState, len(checks))
+	for i := range checks {
+		states[i] = checks[i].State()
+	}
+	return CombineCommitStatusStates(states...)
+}
+
+// GetCommitChecks returns checks of the commit aggregated from its statuses reported
+// to given repositories.
+func GetCommitChecks(repoIDs []int64, sha string) ([]*CommitCheck, error) {
+	statuses, err := GetCommitStatuses(repoIDs, sha)
+	if err != nil {
+		return nil, err
+	}
+	return GroupCommitStatuses(statuses), nil
+}
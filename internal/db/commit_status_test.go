@@ -0,0 +1,56 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_CombineCommitStatusStates(t *testing.T) {
+	Convey("Combine states of commit statuses", t, func() {
+		testCases := []struct {
+			states []CommitStatusState
+			expect CommitStatusState
+		}{
+			{nil, COMMIT_STATUS_PENDING},
+			{[]CommitStatusState{COMMIT_STATUS_SUCCESS}, COMMIT_STATUS_SUCCESS},
+			{[]CommitStatusState{COMMIT_STATUS_SUCCESS, COMMIT_STATUS_PENDING}, COMMIT_STATUS_PENDING},
+			{[]CommitStatusState{COMMIT_STATUS_PENDING, COMMIT_STATUS_FAILURE, COMMIT_STATUS_SUCCESS}, COMMIT_STATUS_FAILURE},
+			{[]CommitStatusState{COMMIT_STATUS_FAILURE, COMMIT_STATUS_ERROR}, COMMIT_STATUS_ERROR},
+		}
+		for _, tc := range testCases {
+			So(CombineCommitStatusStates(tc.states...), ShouldEqual, tc.expect)
+		}
+	})
+}
+
+func Test_GroupCommitStatuses(t *testing.T) {
+	Convey("Group commit statuses by contexts", t, func() {
+		now := time.Now()
+		statuses := []*CommitStatus{
+			{ID: 4, Context: "ci/test", State: COMMIT_STATUS_FAILURE, Created: now},
+			{ID: 3, Context: "ci/build", State: COMMIT_STATUS_PENDING, Created: now.Add(-time.Minute)},
+			{ID: 2, Context: "ci/test", State: COMMIT_STATUS_PENDING, Created: now.Add(-2 * time.Minute)},
+			{ID: 1, Context: "ci/test", State: COMMIT_STATUS_PENDING, Created: now.Add(-3 * time.Minute)},
+		}
+
+		checks := GroupCommitStatuses(statuses)
+		So(checks, ShouldHaveLength, 2)
+
+		So(checks[0].Context, ShouldEqual, "ci/build")
+		So(checks[0].State(), ShouldEqual, COMMIT_STATUS_PENDING)
+		So(checks[0].Duration(), ShouldEqual, 0)
+
+		So(checks[1].Context, ShouldEqual, "ci/test")
+		So(checks[1].Latest.ID, ShouldEqual, 4)
+		So(checks[1].Statuses, ShouldHaveLength, 3)
+		So(checks[1].Duration(), ShouldEqual, 3*time.Minute)
+
+		So(CombineCommitChecks(checks), ShouldEqual, COMMIT_STATUS_FAILURE)
+	})
+}
@@ -0,0 +1,224 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"time"
+
+	log "unknwon.dev/clog/v2"
+	"xorm.io/xorm"
+
+	"github.com/gogs/git-module"
+	api "github.com/gogs/go-gogs-client"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/db/errors"
+)
+
+// DELETED_BRANCH_REF_PREFIX is the prefix of hidden references that keep commits of
+// deleted branches from being garbage collected.
+const DELETED_BRANCH_REF_PREFIX = "refs/deleted-branches/"
+
+// DeletedBranch is a branch deleted from a repository, which can be restored until it
+// is purged after the retention period.
+type DeletedBranch struct {
+	ID          int64
+	RepoID      int64  `xorm:"INDEX NOT NULL"`
+	Name        string `xorm:"NOT NULL"`
+	Commit      string `xorm:"VARCHAR(40) NOT NULL"`
+	DeletedByID int64
+	DeletedBy   *User `xorm:"-" json:"-"`
+
+	Deleted     time.Time `xorm:"-" json:"-"`
+	DeletedUnix int64     `xorm:"INDEX"`
+}
+
+func (b *DeletedBranch) BeforeInsert() {
+	b.DeletedUnix = time.Now().Unix()
+}
+
+func (b *DeletedBranch) AfterSet(colName string, _ xorm.Cell) {
+	switch colName {
+	case "deleted_unix":
+		b.Deleted = time.Unix(b.DeletedUnix, 0).Local()
+	}
+}
+
+// RefName returns the name of the hidden reference to the commit of the branch.
+func (b *DeletedBranch) RefName() string {
+	return fmt.Sprintf("%s%d", DELETED_BRANCH_REF_PREFIX, b.ID)
+}
+
+// ExpiresAt returns the time that the branch will be purged, or zero time if deleted
+// branches are never purged.
+func (b *DeletedBranch) ExpiresAt() time.Time {
+	if !conf.Cron.PurgeDeletedBranches.Enabled || conf.Cron.PurgeDeletedBranches.Retention <= 0 {
+		return time.Time{}
+	}
+	return b.Deleted.Add(conf.Cron.PurgeDeletedBranches.Retention)
+}
+
+func (b *DeletedBranch) loadAttributes() (err error) {
+	if b.DeletedBy == nil {
+		b.DeletedBy, err = GetUserByID(b.DeletedByID)
+		if errors.IsUserNotExist(err) {
+			b.DeletedBy = NewGhostUser()
+			err = nil
+		} else if err != nil {
+			return fmt.Errorf("get user by ID: %v", err)
+		}
+	}
+	return nil
+}
+
+// AddDeletedBranch records a branch deleted by the doer, and keeps its commit alive
+// by a hidden reference.
+func (repo *Repository) AddDeletedBranch(doerID int64, name, commit string) error {
+	b := &DeletedBranch{
+		RepoID:      repo.ID,
+		Name:        name,
+		Commit:      commit,
+		DeletedByID: doerID,
+	}
+	if _, err := x.Insert(b); err != nil {
+		return err
+	}
+
+	repoPath := repo.RepoPath()
+	// Hidden references should neither be advertised to nor updated by clients.
+	if _, err := git.NewCommand("config", "--replace-all", "transfer.hideRefs", DELETED_BRANCH_REF_PREFIX, "^"+DELETED_BRANCH_REF_PREFIX+"$").RunInDir(repoPath); err != nil {
+		return fmt.Errorf("hide references of deleted branches: %v", err)
+	}
+	if _, err := git.NewCommand("update-ref", b.RefName(), commit).RunInDir(repoPath); err != nil {
+		return fmt.Errorf("update-ref %q: %v", b.RefName(), err)
+	}
+	return nil
+}
+
+// GetDeletedBranches returns deleted branches of the repository, most recently
+// deleted first.
+func GetDeletedBranches(repoID int64) ([]*DeletedBranch, error) {
+	branches := make([]*DeletedBranch, 0, 5)
+	if err := x.Where("repo_id = ?", repoID).Desc("id").Find(&branches); err != nil {
+		return nil, err
+	}
+
+	for _, b := range branches {
+		if err := b.loadAttributes(); err != nil {
+			return nil, err
+		}
+	}
+	return branches, nil
+}
+
+// GetDeletedBranchByID returns the deleted branch of the repository by given ID.
+func GetDeletedBranchByID(repoID, id int64) (*DeletedBranch, error) {
+	b := new(DeletedBranch)
+	has, err := x.Where("id = ? AND repo_id = ?", id, repoID).Get(b)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		return nil, errors.DeletedBranchNotExist{ID: id, RepoID: repoID}
+	}
+	return b, b.loadAttributes()
+}
+
+// GetDeletedBranchByCommit returns the most recently deleted branch of the repository
+// by given name and commit.
+func GetDeletedBranchByCommit(repoID int64, name, commit string) (*DeletedBranch, error) {
+	b := &DeletedBranch{
+		RepoID: repoID,
+		Name:   name,
+		Commit: commit,
+	}
+	has, err := x.Desc("id").Get(b)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		return nil, errors.DeletedBranchNotExist{RepoID: repoID}
+	}
+	return b, b.loadAttributes()
+}
+
+// removeDeletedBranch removes the record and the hidden reference of the deleted branch.
+func (repo *Repository) removeDeletedBranch(b *DeletedBranch) error {
+	if _, err := x.Id(b.ID).Delete(new(DeletedBranch)); err != nil {
+		return err
+	}
+
+	if _, err := git.NewCommand("update-ref", "-d", b.RefName()).RunInDir(repo.RepoPath()); err != nil {
+		return fmt.Errorf("update-ref -d %q: %v", b.RefName(), err)
+	}
+	return nil
+}
+
+// RestoreDeletedBranch creates the deleted branch again from its commit by the doer,
+// it fails if a branch with the same name already exists.
+func (repo *Repository) RestoreDeletedBranch(doer *User, b *DeletedBranch) error {
+	repoPath := repo.RepoPath()
+	if git.IsBranchExist(repoPath, b.Name) {
+		return errors.BranchAlreadyExists{Name: b.Name}
+	}
+
+	// Creating with zero value as old value fails if the branch has been created since.
+	if _, err := git.NewCommand("update-ref", git.BRANCH_PREFIX+b.Name, b.Commit, git.EMPTY_SHA).RunInDir(repoPath); err != nil {
+		return fmt.Errorf("update-ref %q: %v", git.BRANCH_PREFIX+b.Name, err)
+	}
+	if err := repo.removeDeletedBranch(b); err != nil {
+		return err
+	}
+
+	if err := PrepareWebhooks(repo, HOOK_EVENT_CREATE, &api.CreatePayload{
+		Ref:           b.Name,
+		RefType:       "branch",
+		Sha:           b.Commit,
+		DefaultBranch: repo.DefaultBranch,
+		Repo:          repo.APIFormat(nil),
+		Sender:        doer.APIFormat(),
+	}); err != nil {
+		return fmt.Errorf("PrepareWebhooks: %v", err)
+	}
+	return nil
+}
+
+// PurgeDeletedBranch permanently removes the deleted branch.
+func (repo *Repository) PurgeDeletedBranch(b *DeletedBranch) error {
+	return repo.removeDeletedBranch(b)
+}
+
+// PurgeDeletedBranches removes deleted branches that have exceeded the retention period.
+func PurgeDeletedBranches() {
+	if taskStatusTable.IsRunning(_PURGE_DELETED_BRANCHES) {
+		return
+	}
+	taskStatusTable.Start(_PURGE_DELETED_BRANCHES)
+	defer taskStatusTable.Stop(_PURGE_DELETED_BRANCHES)
+
+	log.Trace("Doing: PurgeDeletedBranches")
+
+	if conf.Cron.PurgeDeletedBranches.Retention <= 0 {
+		return
+	}
+	olderThan := time.Now().Add(-conf.Cron.PurgeDeletedBranches.Retention).Unix()
+
+	branches := make([]*DeletedBranch, 0, 10)
+	if err := x.Where("deleted_unix < ?", olderThan).Find(&branches); err != nil {
+		log.Error("Failed to find expired deleted branches: %v", err)
+		return
+	}
+
+	for _, b := range branches {
+		repo, err := GetRepositoryByID(b.RepoID)
+		if err != nil {
+			log.Error("GetRepositoryByID [repo_id: %d]: %v", b.RepoID, err)
+			continue
+		}
+
+		if err = repo.removeDeletedBranch(b); err != nil {
+			log.Error("Failed to purge deleted branch [repo_id: %d, name: %s]: %v", b.RepoID, b.Name, err)
+		}
+	}
+}
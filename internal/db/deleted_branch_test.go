@@ -0,0 +1,34 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"gogs.io/gogs/internal/conf"
+)
+
+func Test_DeletedBranch(t *testing.T) {
+	Convey("Deleted branch", t, func() {
+		deleted := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+		b := &DeletedBranch{ID: 12, Deleted: deleted}
+
+		So(b.RefName(), ShouldEqual, "refs/deleted-branches/12")
+
+		Convey("Expires after the retention period", func() {
+			conf.Cron.PurgeDeletedBranches.Enabled = true
+			conf.Cron.PurgeDeletedBranches.Retention = 72 * time.Hour
+			So(b.ExpiresAt(), ShouldEqual, deleted.Add(72*time.Hour))
+		})
+
+		Convey("Never expires when purging is disabled", func() {
+			conf.Cron.PurgeDeletedBranches.Enabled = false
+			So(b.ExpiresAt().IsZero(), ShouldBeTrue)
+		})
+	})
+}
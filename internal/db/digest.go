@@ -0,0 +1,296 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"time"
+
+	log "unknwon.dev/clog/v2"
+	"xorm.io/xorm"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/db/errors"
+	"gogs.io/gogs/internal/email"
+	"gogs.io/gogs/internal/tool"
+)
+
+// DigestFrequency is how often a digest email is sent.
+type DigestFrequency int
+
+const (
+	DIGEST_NONE DigestFrequency = iota
+	DIGEST_DAILY
+	DIGEST_WEEKLY
+)
+
+// ParseDigestFrequency returns the frequency of given value, or DIGEST_NONE if invalid.
+func ParseDigestFrequency(v int) DigestFrequency {
+	switch f := DigestFrequency(v); f {
+	case DIGEST_DAILY, DIGEST_WEEKLY:
+		return f
+	}
+	return DIGEST_NONE
+}
+
+// Period returns the duration between two digests of the frequency.
+func (f DigestFrequency) Period() time.Duration {
+	switch f {
+	case DIGEST_DAILY:
+		return 24 * time.Hour
+	case DIGEST_WEEKLY:
+		return 7 * 24 * time.Hour
+	}
+	return 0
+}
+
+// maxDigestItems is the maximum number of items listed in each section of a digest.
+const maxDigestItems = 20
+
+// DigestSubscription represents a subscription of a user to scheduled digest emails
+// of repositories the user watches, or of repositories in an organization.
+type DigestSubscription struct {
+	ID        int64
+	UserID    int64 `xorm:"UNIQUE(s)"`
+	OrgID     int64 `xorm:"UNIQUE(s)"` // Zero means repositories the user watches.
+	Frequency DigestFrequency
+	Token     string `xorm:"UNIQUE"` // Used by unsubscribe link without signing in.
+
+	Created      time.Time `xorm:"-" json:"-"`
+	CreatedUnix  int64
+	LastSentUnix int64
+}
+
+func (s *DigestSubscription) BeforeInsert() {
+	s.CreatedUnix = time.Now().Unix()
+}
+
+func (s *DigestSubscription) AfterSet(colName string, _ xorm.Cell) {
+	switch colName {
+	case "created_unix":
+		s.Created = time.Unix(s.CreatedUnix, 0).Local()
+	}
+}
+
+// GetDigestSubscriptions returns all digest subscriptions of the user.
+func GetDigestSubscriptions(userID int64) ([]*DigestSubscription, error) {
+	subs := make([]*DigestSubscription, 0, 5)
+	return subs, x.Where("user_id = ?", userID).Find(&subs)
+}
+
+// UpdateDigestSubscription sets the digest frequency of the user for given organization,
+// or for repositories the user watches if orgID is zero. Setting DIGEST_NONE unsubscribes.
+func UpdateDigestSubscription(userID, orgID int64, freq DigestFrequency) error {
+	if freq == DIGEST_NONE {
+		_, err := x.Delete(&DigestSubscription{UserID: userID, OrgID: orgID})
+		return err
+	}
+
+	sub := &DigestSubscription{UserID: userID, OrgID: orgID}
+	has, err := x.Get(sub)
+	if err != nil {
+		return err
+	} else if has {
+		sub.Frequency = freq
+		_, err = x.ID(sub.ID).Cols("frequency").Update(sub)
+		return err
+	}
+
+	sub.Frequency = freq
+	// Do not send digest of past items right after subscribing.
+	sub.LastSentUnix = time.Now().Unix()
+	if sub.Token, err = tool.RandomString(40); err != nil {
+		return fmt.Errorf("generate token: %v", err)
+	}
+	_, err = x.Insert(sub)
+	return err
+}
+
+// UnsubscribeDigestByToken deletes the digest subscription of given token.
+func UnsubscribeDigestByToken(token string) (*DigestSubscription, error) {
+	if token == "" {
+		return nil, errors.DigestSubscriptionNotExist{Token: token}
+	}
+
+	sub := &DigestSubscription{Token: token}
+	has, err := x.Get(sub)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		return nil, errors.DigestSubscriptionNotExist{Token: token}
+	}
+
+	if _, err = x.ID(sub.ID).Delete(new(DigestSubscription)); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// digestRepositories returns repositories covered by the subscription that the user
+// still has read access to.
+func (s *DigestSubscription) digestRepositories(u *User) (map[int64]*Repository, error) {
+	var repos []*Repository
+	if s.OrgID > 0 {
+		org, err := GetUserByID(s.OrgID)
+		if err != nil {
+			return nil, fmt.Errorf("GetUserByID [%d]: %v", s.OrgID, err)
+		}
+		if !org.IsOrgMember(u.ID) {
+			return nil, nil
+		}
+		if repos, _, err = org.GetUserRepositories(u.ID, 1, org.NumRepos); err != nil {
+			return nil, fmt.Errorf("GetUserRepositories: %v", err)
+		}
+	} else {
+		repos = make([]*Repository, 0, 10)
+		if err := x.Join("INNER", "watch", "watch.repo_id = repository.id").
+			Where("watch.user_id = ? AND watch.mode = ?", u.ID, WATCH_MODE_ALL).Find(&repos); err != nil {
+			return nil, fmt.Errorf("get watched repositories: %v", err)
+		}
+	}
+
+	result := make(map[int64]*Repository, len(repos))
+	for _, repo := range repos {
+		if has, err := HasAccess(u.ID, repo, ACCESS_MODE_READ); err != nil {
+			return nil, fmt.Errorf("HasAccess: %v", err)
+		} else if has {
+			result[repo.ID] = repo
+		}
+	}
+	return result, nil
+}
+
+// toDigestItems converts issues to items of digest email, issues of repositories
+// not in given set are ignored.
+func toDigestItems(issues []*Issue, repos map[int64]*Repository) []*email.DigestItem {
+	items := make([]*email.DigestItem, 0, len(issues))
+	for _, issue := range issues {
+		repo := repos[issue.RepoID]
+		if repo == nil {
+			continue
+		}
+		issue.Repo = repo
+		items = append(items, &email.DigestItem{
+			RepoName: repo.FullName(),
+			Index:    issue.Index,
+			Title:    issue.Title,
+			IsPull:   issue.IsPull,
+			Link:     issue.HTMLURL(),
+		})
+	}
+	return items
+}
+
+// buildDigest collects new issues, pull requests awaiting review of the user and
+// stale items for the subscription. It returns nil if there is nothing to report.
+func (s *DigestSubscription) buildDigest(u *User) (*email.Digest, error) {
+	repos, err := s.digestRepositories(u)
+	if err != nil {
+		return nil, err
+	} else if len(repos) == 0 {
+		return nil, nil
+	}
+	repoIDs := make([]int64, 0, len(repos))
+	for id := range repos {
+		repoIDs = append(repoIDs, id)
+	}
+
+	d := new(email.Digest)
+	confidentialCond, confidentialArgs := confidentialIssuesCond(u.ID)
+
+	issues := make([]*Issue, 0, maxDigestItems)
+	if err = x.In("repo_id", repoIDs).
+		And("is_pull = ? AND created_unix > ?", false, s.LastSentUnix).
+		And(confidentialCond, confidentialArgs...).
+		Desc("created_unix").Limit(maxDigestItems).Find(&issues); err != nil {
+		return nil, fmt.Errorf("get new issues: %v", err)
+	}
+	d.NewIssues = toDigestItems(issues, repos)
+
+	issues = make([]*Issue, 0, maxDigestItems)
+	if err = x.Join("INNER", "review_request", "review_request.issue_id = issue.id").
+		Where("review_request.reviewer_id = ? AND review_request.is_done = ?", u.ID, false).
+		And("issue.is_closed = ?", false).In("issue.repo_id", repoIDs).
+		Asc("review_request.created_unix").Limit(maxDigestItems).Find(&issues); err != nil {
+		return nil, fmt.Errorf("get pull requests awaiting review: %v", err)
+	}
+	d.AwaitingReview = toDigestItems(issues, repos)
+
+	if days := conf.Cron.SendDigests.StaleDays; days > 0 {
+		issues = make([]*Issue, 0, maxDigestItems)
+		if err = x.In("repo_id", repoIDs).
+			And("is_closed = ? AND updated_unix < ?", false, time.Now().AddDate(0, 0, -days).Unix()).
+			And(confidentialCond, confidentialArgs...).
+			Asc("updated_unix").Limit(maxDigestItems).Find(&issues); err != nil {
+			return nil, fmt.Errorf("get stale issues: %v", err)
+		}
+		d.Stale = toDigestItems(issues, repos)
+		d.StaleDays = days
+	}
+
+	if len(d.NewIssues) == 0 && len(d.AwaitingReview) == 0 && len(d.Stale) == 0 {
+		return nil, nil
+	}
+	return d, nil
+}
+
+// SendDigests sends digest emails to subscribers whose period has passed since last time.
+func SendDigests() {
+	if taskStatusTable.IsRunning(_SEND_DIGESTS) {
+		return
+	}
+	taskStatusTable.Start(_SEND_DIGESTS)
+	defer taskStatusTable.Stop(_SEND_DIGESTS)
+
+	log.Trace("Doing: SendDigests")
+
+	subs := make([]*DigestSubscription, 0, 10)
+	if err := x.Where("frequency > ?", DIGEST_NONE).Find(&subs); err != nil {
+		log.Error("Failed to get digest subscriptions: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for _, s := range subs {
+		// Allow some tolerance so that the digest is not delayed by one more run of the schedule.
+		if now.Sub(time.Unix(s.LastSentUnix, 0)) < s.Frequency.Period()-time.Hour {
+			continue
+		}
+
+		u, err := GetUserByID(s.UserID)
+		if err != nil {
+			if !errors.IsUserNotExist(err) {
+				log.Error("GetUserByID [%d]: %v", s.UserID, err)
+			}
+			continue
+		} else if !u.IsMailable() {
+			continue
+		}
+
+		d, err := s.buildDigest(u)
+		if err != nil {
+			log.Error("Failed to build digest [%d]: %v", s.ID, err)
+			continue
+		}
+		if d != nil {
+			if s.OrgID > 0 {
+				org, err := GetUserByID(s.OrgID)
+				if err != nil {
+					log.Error("GetUserByID [%d]: %v", s.OrgID, err)
+					continue
+				}
+				d.Scope = org.Name
+			}
+			d.IsWeekly = s.Frequency == DIGEST_WEEKLY
+			d.UnsubscribeLink = conf.Server.ExternalURL + "user/digest/unsubscribe?token=" + s.Token
+			email.SendDigestMail(NewMailerUser(u), d)
+		}
+
+		s.LastSentUnix = now.Unix()
+		if _, err = x.ID(s.ID).Cols("last_sent_unix").Update(s); err != nil {
+			log.Error("Failed to update last sent time of digest subscription [%d]: %v", s.ID, err)
+		}
+	}
+}
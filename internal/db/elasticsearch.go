@@ -0,0 +1,118 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// elasticsearchClient is a minimal client of the Elasticsearch REST API that works
+// with a single index.
+type elasticsearchClient struct {
+	url       string
+	indexName string
+	client    *http.Client
+}
+
+// newElasticsearchClient returns a client of the index in the server, the index is
+// created with given settings and mappings if not exists. It returns true if the
+// index is created.
+func newElasticsearchClient(url, index, mapping string) (_ *elasticsearchClient, created bool, _ error) {
+	c := &elasticsearchClient{
+		url:       strings.TrimSuffix(url, "/"),
+		indexName: index,
+		client:    &http.Client{Timeout: time.Minute},
+	}
+
+	resp, err := c.client.Head(c.url + "/" + c.indexName)
+	if err != nil {
+		return nil, false, fmt.Errorf("check index: %v", err)
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return c, false, nil
+	case http.StatusNotFound:
+		if err = c.request("PUT", "/"+c.indexName, "application/json", strings.NewReader(mapping), nil); err != nil {
+			return nil, false, fmt.Errorf("create index: %v", err)
+		}
+		return c, true, nil
+	}
+	return nil, false, fmt.Errorf("check index: unexpected status %d", resp.StatusCode)
+}
+
+// request sends a request to the server and decodes the JSON response to the result
+// if it is not nil.
+func (c *elasticsearchClient) request(method, path, contentType string, body io.Reader, result interface{}) error {
+	req, err := http.NewRequest(method, c.url+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, data)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// bulk sends actions in NDJSON format to the Bulk API, and returns the first error
+// of failed actions.
+func (c *elasticsearchClient) bulk(buf *bytes.Buffer) error {
+	var result struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int             `json:"status"`
+			Error  json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	if err := c.request("POST", "/"+c.indexName+"/_bulk", "application/x-ndjson", buf, &result); err != nil {
+		return err
+	} else if !result.Errors {
+		return nil
+	}
+
+	for _, item := range result.Items {
+		for action, r := range item {
+			// Deleting a missing document is not an error.
+			if len(r.Error) > 0 && !(action == "delete" && r.Status == http.StatusNotFound) {
+				return fmt.Errorf("%s: %s", action, r.Error)
+			}
+		}
+	}
+	return nil
+}
+
+// deleteByRepo removes all documents of the repository, documents must have a
+// "repo_id" field.
+func (c *elasticsearchClient) deleteByRepo(repoID int64) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"query": map[string]interface{}{
+			"term": map[string]interface{}{"repo_id": repoID},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return c.request("POST", "/"+c.indexName+"/_delete_by_query?conflicts=proceed", "application/json", bytes.NewReader(body), nil)
+}
@@ -33,3 +33,30 @@ func IsInvalidIssueReference(err error) bool {
 func (err InvalidIssueReference) Error() string {
 	return fmt.Sprintf("invalid issue reference [ref: %s]", err.Ref)
 }
+
+type IssueFormDataNotExist struct {
+	IssueID int64
+}
+
+func IsIssueFormDataNotExist(err error) bool {
+	_, ok := err.(IssueFormDataNotExist)
+	return ok
+}
+
+func (err IssueFormDataNotExist) Error() string {
+	return fmt.Sprintf("issue form data does not exist [issue_id: %d]", err.IssueID)
+}
+
+type CommentRevisionNotExist struct {
+	ID        int64
+	CommentID int64
+}
+
+func IsCommentRevisionNotExist(err error) bool {
+	_, ok := err.(CommentRevisionNotExist)
+	return ok
+}
+
+func (err CommentRevisionNotExist) Error() string {
+	return fmt.Sprintf("comment revision does not exist [id: %d, comment_id: %d]", err.ID, err.CommentID)
+}
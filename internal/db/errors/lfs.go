@@ -0,0 +1,91 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package errors
+
+import "fmt"
+
+type LFSObjectNotExist struct {
+	RepoID int64
+	OID    string
+}
+
+func IsLFSObjectNotExist(err error) bool {
+	_, ok := err.(LFSObjectNotExist)
+	return ok
+}
+
+func (err LFSObjectNotExist) Error() string {
+	return fmt.Sprintf("LFS object does not exist [repo_id: %d, oid: %s]", err.RepoID, err.OID)
+}
+
+type LFSObjectMismatch struct {
+	OID  string
+	Size int64
+}
+
+func IsLFSObjectMismatch(err error) bool {
+	_, ok := err.(LFSObjectMismatch)
+	return ok
+}
+
+func (err LFSObjectMismatch) Error() string {
+	return fmt.Sprintf("content of LFS object does not match its OID or size [oid: %s, size: %d]", err.OID, err.Size)
+}
+
+type LFSQuotaExceeded struct {
+	RepoID int64
+	// Quota is the max total size of objects in megabytes.
+	Quota int64
+}
+
+func IsLFSQuotaExceeded(err error) bool {
+	_, ok := err.(LFSQuotaExceeded)
+	return ok
+}
+
+func (err LFSQuotaExceeded) Error() string {
+	return fmt.Sprintf("LFS objects of repository exceed the quota [repo_id: %d, quota: %d MB]", err.RepoID, err.Quota)
+}
+
+type LFSLockNotExist struct {
+	ID int64
+}
+
+func IsLFSLockNotExist(err error) bool {
+	_, ok := err.(LFSLockNotExist)
+	return ok
+}
+
+func (err LFSLockNotExist) Error() string {
+	return fmt.Sprintf("LFS lock does not exist [id: %d]", err.ID)
+}
+
+type LFSLockAlreadyExist struct {
+	RepoID int64
+	Path   string
+}
+
+func IsLFSLockAlreadyExist(err error) bool {
+	_, ok := err.(LFSLockAlreadyExist)
+	return ok
+}
+
+func (err LFSLockAlreadyExist) Error() string {
+	return fmt.Sprintf("LFS lock already exists [repo_id: %d, path: %s]", err.RepoID, err.Path)
+}
+
+type LFSLockNotOwned struct {
+	ID     int64
+	UserID int64
+}
+
+func IsLFSLockNotOwned(err error) bool {
+	_, ok := err.(LFSLockNotOwned)
+	return ok
+}
+
+func (err LFSLockNotOwned) Error() string {
+	return fmt.Sprintf("LFS lock is not owned by the user [id: %d, user_id: %d]", err.ID, err.UserID)
+}
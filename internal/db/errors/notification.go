@@ -0,0 +1,20 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package errors
+
+import "fmt"
+
+type NotificationNotExist struct {
+	ID int64
+}
+
+func IsNotificationNotExist(err error) bool {
+	_, ok := err.(NotificationNotExist)
+	return ok
+}
+
+func (err NotificationNotExist) Error() string {
+	return fmt.Sprintf("notification does not exist [id: %d]", err.ID)
+}
@@ -19,3 +19,30 @@ func IsTeamNotExist(err error) bool {
 func (err TeamNotExist) Error() string {
 	return fmt.Sprintf("team does not exist [team_id: %d, name: %s]", err.TeamID, err.Name)
 }
+
+type NotOrganization struct {
+	Name string
+}
+
+func IsNotOrganization(err error) bool {
+	_, ok := err.(NotOrganization)
+	return ok
+}
+
+func (err NotOrganization) Error() string {
+	return fmt.Sprintf("user is not an organization [name: %s]", err.Name)
+}
+
+type InvalidTeamParent struct {
+	TeamID   int64
+	ParentID int64
+}
+
+func IsInvalidTeamParent(err error) bool {
+	_, ok := err.(InvalidTeamParent)
+	return ok
+}
+
+func (err InvalidTeamParent) Error() string {
+	return fmt.Sprintf("invalid parent team [team_id: %d, parent_id: %d]", err.TeamID, err.ParentID)
+}
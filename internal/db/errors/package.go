@@ -0,0 +1,108 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package errors
+
+import "fmt"
+
+type PackageNotExist struct {
+	RepoID int64
+	Type   string
+	Name   string
+	ID     int64
+}
+
+func IsPackageNotExist(err error) bool {
+	_, ok := err.(PackageNotExist)
+	return ok
+}
+
+func (err PackageNotExist) Error() string {
+	return fmt.Sprintf("package does not exist [repo_id: %d, type: %s, name: %s, id: %d]", err.RepoID, err.Type, err.Name, err.ID)
+}
+
+type PackageVersionNotExist struct {
+	PackageID int64
+	Version   string
+}
+
+func IsPackageVersionNotExist(err error) bool {
+	_, ok := err.(PackageVersionNotExist)
+	return ok
+}
+
+func (err PackageVersionNotExist) Error() string {
+	return fmt.Sprintf("package version does not exist [package_id: %d, version: %s]", err.PackageID, err.Version)
+}
+
+type PackageFileNotExist struct {
+	PackageID int64
+	Name      string
+	SHA256    string
+}
+
+func IsPackageFileNotExist(err error) bool {
+	_, ok := err.(PackageFileNotExist)
+	return ok
+}
+
+func (err PackageFileNotExist) Error() string {
+	return fmt.Sprintf("package file does not exist [package_id: %d, name: %s, sha256: %s]", err.PackageID, err.Name, err.SHA256)
+}
+
+type PackageFileAlreadyExist struct {
+	PackageID int64
+	Version   string
+	Name      string
+}
+
+func IsPackageFileAlreadyExist(err error) bool {
+	_, ok := err.(PackageFileAlreadyExist)
+	return ok
+}
+
+func (err PackageFileAlreadyExist) Error() string {
+	return fmt.Sprintf("package file already exists [package_id: %d, version: %s, name: %s]", err.PackageID, err.Version, err.Name)
+}
+
+type PackageBlobNotExist struct {
+	RepoID int64
+	SHA256 string
+}
+
+func IsPackageBlobNotExist(err error) bool {
+	_, ok := err.(PackageBlobNotExist)
+	return ok
+}
+
+func (err PackageBlobNotExist) Error() string {
+	return fmt.Sprintf("package blob does not exist [repo_id: %d, sha256: %s]", err.RepoID, err.SHA256)
+}
+
+type PackageBlobMismatch struct {
+	SHA256 string
+}
+
+func IsPackageBlobMismatch(err error) bool {
+	_, ok := err.(PackageBlobMismatch)
+	return ok
+}
+
+func (err PackageBlobMismatch) Error() string {
+	return fmt.Sprintf("content of package blob does not match its digest [sha256: %s]", err.SHA256)
+}
+
+type PackageBlobTooLarge struct {
+	// MaxSize is the max size of blobs in megabytes.
+	MaxSize int64
+}
+
+func IsPackageBlobTooLarge(err error) bool {
+	_, ok := err.(PackageBlobTooLarge)
+	return ok
+}
+
+func (err PackageBlobTooLarge) Error() string {
+	return fmt.Sprintf("package blob exceeds the max size [max_size: %d MB]", err.MaxSize)
+}
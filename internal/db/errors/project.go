@@ -0,0 +1,63 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package errors
+
+import "fmt"
+
+type ProjectNotExist struct {
+	ID    int64
+	OrgID int64
+}
+
+func IsProjectNotExist(err error) bool {
+	_, ok := err.(ProjectNotExist)
+	return ok
+}
+
+func (err ProjectNotExist) Error() string {
+	return fmt.Sprintf("project does not exist [id: %d, org_id: %d]", err.ID, err.OrgID)
+}
+
+type ProjectColumnNotExist struct {
+	ID        int64
+	ProjectID int64
+}
+
+func IsProjectColumnNotExist(err error) bool {
+	_, ok := err.(ProjectColumnNotExist)
+	return ok
+}
+
+func (err ProjectColumnNotExist) Error() string {
+	return fmt.Sprintf("project column does not exist [id: %d, project_id: %d]", err.ID, err.ProjectID)
+}
+
+type ProjectCardNotExist struct {
+	ID        int64
+	ProjectID int64
+}
+
+func IsProjectCardNotExist(err error) bool {
+	_, ok := err.(ProjectCardNotExist)
+	return ok
+}
+
+func (err ProjectCardNotExist) Error() string {
+	return fmt.Sprintf("project card does not exist [id: %d, project_id: %d]", err.ID, err.ProjectID)
+}
+
+type ProjectCardAlreadyExist struct {
+	ProjectID int64
+	IssueID   int64
+}
+
+func IsProjectCardAlreadyExist(err error) bool {
+	_, ok := err.(ProjectCardAlreadyExist)
+	return ok
+}
+
+func (err ProjectCardAlreadyExist) Error() string {
+	return fmt.Sprintf("project card already exists [project_id: %d, issue_id: %d]", err.ProjectID, err.IssueID)
+}
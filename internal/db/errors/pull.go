@@ -0,0 +1,46 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package errors
+
+import "fmt"
+
+type PullRequestNotConflicted struct {
+	ID int64
+}
+
+func IsPullRequestNotConflicted(err error) bool {
+	_, ok := err.(PullRequestNotConflicted)
+	return ok
+}
+
+func (err PullRequestNotConflicted) Error() string {
+	return fmt.Sprintf("pull request has no conflicts [id: %d]", err.ID)
+}
+
+type ConflictNotResolvable struct {
+	Name string
+}
+
+func IsConflictNotResolvable(err error) bool {
+	_, ok := err.(ConflictNotResolvable)
+	return ok
+}
+
+func (err ConflictNotResolvable) Error() string {
+	return fmt.Sprintf("conflict cannot be resolved in web editor [name: %s]", err.Name)
+}
+
+type ConflictNotResolved struct {
+	Name string
+}
+
+func IsConflictNotResolved(err error) bool {
+	_, ok := err.(ConflictNotResolved)
+	return ok
+}
+
+func (err ConflictNotResolved) Error() string {
+	return fmt.Sprintf("conflict is not resolved [name: %s]", err.Name)
+}
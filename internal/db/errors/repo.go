@@ -34,6 +34,19 @@ func (err ReachLimitOfRepo) Error() string {
 	return fmt.Sprintf("user has reached maximum limit of repositories [limit: %d]", err.Limit)
 }
 
+type LicenseNotAllowed struct {
+	Name string
+}
+
+func IsLicenseNotAllowed(err error) bool {
+	_, ok := err.(LicenseNotAllowed)
+	return ok
+}
+
+func (err LicenseNotAllowed) Error() string {
+	return fmt.Sprintf("license is not allowed [name: %s]", err.Name)
+}
+
 type InvalidRepoReference struct {
 	Ref string
 }
@@ -60,6 +73,59 @@ func (err MirrorNotExist) Error() string {
 	return fmt.Sprintf("mirror does not exist [repo_id: %d]", err.RepoID)
 }
 
+type PushMirrorNotExist struct {
+	ID     int64
+	RepoID int64
+}
+
+func IsPushMirrorNotExist(err error) bool {
+	_, ok := err.(PushMirrorNotExist)
+	return ok
+}
+
+func (err PushMirrorNotExist) Error() string {
+	return fmt.Sprintf("push mirror does not exist [id: %d, repo_id: %d]", err.ID, err.RepoID)
+}
+
+type IssueMirrorNotExist struct {
+	RepoID int64
+}
+
+func IsIssueMirrorNotExist(err error) bool {
+	_, ok := err.(IssueMirrorNotExist)
+	return ok
+}
+
+func (err IssueMirrorNotExist) Error() string {
+	return fmt.Sprintf("issue mirror does not exist [repo_id: %d]", err.RepoID)
+}
+
+type MigrationTaskNotExist struct {
+	RepoID int64
+}
+
+func IsMigrationTaskNotExist(err error) bool {
+	_, ok := err.(MigrationTaskNotExist)
+	return ok
+}
+
+func (err MigrationTaskNotExist) Error() string {
+	return fmt.Sprintf("migration task does not exist [repo_id: %d]", err.RepoID)
+}
+
+type InvalidRepoArchive struct {
+	Reason string
+}
+
+func IsInvalidRepoArchive(err error) bool {
+	_, ok := err.(InvalidRepoArchive)
+	return ok
+}
+
+func (err InvalidRepoArchive) Error() string {
+	return fmt.Sprintf("invalid repository archive [reason: %s]", err.Reason)
+}
+
 type BranchAlreadyExists struct {
 	Name string
 }
@@ -85,3 +151,167 @@ func IsErrBranchNotExist(err error) bool {
 func (err ErrBranchNotExist) Error() string {
 	return fmt.Sprintf("branch does not exist [name: %s]", err.Name)
 }
+
+type RefNameNotAllowed struct {
+	Kind     string // Either "branch" or "tag"
+	Name     string
+	Patterns []string // Empty when the name contains unsafe characters
+}
+
+func IsRefNameNotAllowed(err error) bool {
+	_, ok := err.(RefNameNotAllowed)
+	return ok
+}
+
+func (err RefNameNotAllowed) Error() string {
+	return fmt.Sprintf("ref name is not allowed [kind: %s, name: %s, patterns: %v]", err.Kind, err.Name, err.Patterns)
+}
+
+type StorageRootNotExist struct {
+	Name string
+}
+
+func IsStorageRootNotExist(err error) bool {
+	_, ok := err.(StorageRootNotExist)
+	return ok
+}
+
+func (err StorageRootNotExist) Error() string {
+	return fmt.Sprintf("repository storage root does not exist [name: %s]", err.Name)
+}
+
+type InvalidMaintenanceAction struct {
+	Action string
+}
+
+func IsInvalidMaintenanceAction(err error) bool {
+	_, ok := err.(InvalidMaintenanceAction)
+	return ok
+}
+
+func (err InvalidMaintenanceAction) Error() string {
+	return fmt.Sprintf("invalid maintenance action [action: %s]", err.Action)
+}
+
+type MaintenanceJobNotExist struct {
+	ID int64
+}
+
+func IsMaintenanceJobNotExist(err error) bool {
+	_, ok := err.(MaintenanceJobNotExist)
+	return ok
+}
+
+func (err MaintenanceJobNotExist) Error() string {
+	return fmt.Sprintf("maintenance job does not exist [id: %d]", err.ID)
+}
+
+type StagedChangeNotExist struct {
+	ID       int64
+	TreePath string
+}
+
+func IsStagedChangeNotExist(err error) bool {
+	_, ok := err.(StagedChangeNotExist)
+	return ok
+}
+
+func (err StagedChangeNotExist) Error() string {
+	return fmt.Sprintf("staged change does not exist [id: %d, tree_path: %s]", err.ID, err.TreePath)
+}
+
+type NoStagedChanges struct {
+	Branch string
+}
+
+func IsNoStagedChanges(err error) bool {
+	_, ok := err.(NoStagedChanges)
+	return ok
+}
+
+func (err NoStagedChanges) Error() string {
+	return fmt.Sprintf("no staged changes [branch: %s]", err.Branch)
+}
+
+type DeletedBranchNotExist struct {
+	ID     int64
+	RepoID int64
+}
+
+func IsDeletedBranchNotExist(err error) bool {
+	_, ok := err.(DeletedBranchNotExist)
+	return ok
+}
+
+func (err DeletedBranchNotExist) Error() string {
+	return fmt.Sprintf("deleted branch does not exist [id: %d, repo_id: %d]", err.ID, err.RepoID)
+}
+
+type CherryPickConflict struct {
+	CommitID string
+	Branch   string
+	Revert   bool
+}
+
+func IsCherryPickConflict(err error) bool {
+	_, ok := err.(CherryPickConflict)
+	return ok
+}
+
+func (err CherryPickConflict) Error() string {
+	return fmt.Sprintf("commit cannot be applied cleanly [commit_id: %s, branch: %s, revert: %t]", err.CommitID, err.Branch, err.Revert)
+}
+
+type InvalidBundleRef struct {
+	Ref string
+}
+
+func IsInvalidBundleRef(err error) bool {
+	_, ok := err.(InvalidBundleRef)
+	return ok
+}
+
+func (err InvalidBundleRef) Error() string {
+	return fmt.Sprintf("reference does not exist [ref: %s]", err.Ref)
+}
+
+type BundleLimitExceeded struct {
+	Key string
+}
+
+func IsBundleLimitExceeded(err error) bool {
+	_, ok := err.(BundleLimitExceeded)
+	return ok
+}
+
+func (err BundleLimitExceeded) Error() string {
+	return fmt.Sprintf("too many bundles are being created or have been created recently [key: %s]", err.Key)
+}
+
+type AutolinkNotExist struct {
+	ID     int64
+	RepoID int64
+}
+
+func IsAutolinkNotExist(err error) bool {
+	_, ok := err.(AutolinkNotExist)
+	return ok
+}
+
+func (err AutolinkNotExist) Error() string {
+	return fmt.Sprintf("autolink does not exist [id: %d, repo_id: %d]", err.ID, err.RepoID)
+}
+
+type InvalidAutolink struct {
+	Pattern string
+	URL     string
+}
+
+func IsInvalidAutolink(err error) bool {
+	_, ok := err.(InvalidAutolink)
+	return ok
+}
+
+func (err InvalidAutolink) Error() string {
+	return fmt.Sprintf("invalid autolink [pattern: %s, url: %s]", err.Pattern, err.URL)
+}
@@ -43,3 +43,82 @@ func IsUserNotKeyOwner(err error) bool {
 func (err UserNotKeyOwner) Error() string {
 	return fmt.Sprintf("user is not the owner of public key [key_id: %d]", err.KeyID)
 }
+
+type DigestSubscriptionNotExist struct {
+	Token string
+}
+
+func IsDigestSubscriptionNotExist(err error) bool {
+	_, ok := err.(DigestSubscriptionNotExist)
+	return ok
+}
+
+func (err DigestSubscriptionNotExist) Error() string {
+	return fmt.Sprintf("digest subscription does not exist [token: %s]", err.Token)
+}
+
+type OnboardingNotExist struct {
+	UserID int64
+}
+
+func IsOnboardingNotExist(err error) bool {
+	_, ok := err.(OnboardingNotExist)
+	return ok
+}
+
+func (err OnboardingNotExist) Error() string {
+	return fmt.Sprintf("onboarding does not exist [user_id: %d]", err.UserID)
+}
+
+type InvalidOnboardingStep struct {
+	Name string
+}
+
+func IsInvalidOnboardingStep(err error) bool {
+	_, ok := err.(InvalidOnboardingStep)
+	return ok
+}
+
+func (err InvalidOnboardingStep) Error() string {
+	return fmt.Sprintf("onboarding step cannot be marked as done [name: %s]", err.Name)
+}
+
+type UserReactivationExpired struct {
+	UserID int64
+}
+
+func IsUserReactivationExpired(err error) bool {
+	_, ok := err.(UserReactivationExpired)
+	return ok
+}
+
+func (err UserReactivationExpired) Error() string {
+	return fmt.Sprintf("cooldown window of reactivation has passed [user_id: %d]", err.UserID)
+}
+
+type StarListNotExist struct {
+	ID     int64
+	UserID int64
+}
+
+func IsStarListNotExist(err error) bool {
+	_, ok := err.(StarListNotExist)
+	return ok
+}
+
+func (err StarListNotExist) Error() string {
+	return fmt.Sprintf("star list does not exist [id: %d, user_id: %d]", err.ID, err.UserID)
+}
+
+type StarListAlreadyExist struct {
+	Name string
+}
+
+func IsStarListAlreadyExist(err error) bool {
+	_, ok := err.(StarListAlreadyExist)
+	return ok
+}
+
+func (err StarListAlreadyExist) Error() string {
+	return fmt.Sprintf("star list already exists [name: %s]", err.Name)
+}
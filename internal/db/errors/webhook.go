@@ -21,6 +21,7 @@ func (err WebhookNotExist) Error() string {
 
 type HookTaskNotExist struct {
 	HookID int64
+	ID     int64
 	UUID   string
 }
 
@@ -30,5 +31,18 @@ func IsHookTaskNotExist(err error) bool {
 }
 
 func (err HookTaskNotExist) Error() string {
-	return fmt.Sprintf("hook task does not exist [hook_id: %d, uuid: %s]", err.HookID, err.UUID)
+	return fmt.Sprintf("hook task does not exist [hook_id: %d, id: %d, uuid: %s]", err.HookID, err.ID, err.UUID)
+}
+
+type InvalidHookFilter struct {
+	Pattern string
+}
+
+func IsInvalidHookFilter(err error) bool {
+	_, ok := err.(InvalidHookFilter)
+	return ok
+}
+
+func (err InvalidHookFilter) Error() string {
+	return fmt.Sprintf("invalid hook filter pattern [pattern: %s]", err.Pattern)
 }
@@ -0,0 +1,504 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"html/template"
+	"time"
+
+	"github.com/unknwon/com"
+	log "unknwon.dev/clog/v2"
+	"xorm.io/xorm"
+
+	"gogs.io/gogs/internal/activitypub"
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/sync"
+)
+
+// FederationQueue is the queue of actions to be delivered to remote followers.
+var FederationQueue = sync.NewUniqueQueue(1000)
+
+// maxFederationOutboxItems is the max number of activities listed in an outbox.
+const maxFederationOutboxItems = 20
+
+// FederationActorType is the type of a local actor that can be followed from other instances.
+type FederationActorType int
+
+const (
+	FEDERATION_ACTOR_USER FederationActorType = iota + 1 // Users and organizations
+	FEDERATION_ACTOR_REPO
+)
+
+// federationActorURL returns the URI of the local actor, which uses the ID so it does
+// not change when the user or repository is renamed.
+func federationActorURL(actorType FederationActorType, actorID int64) string {
+	if actorType == FEDERATION_ACTOR_REPO {
+		return fmt.Sprintf("%sapi/v1/activitypub/repo-id/%d", conf.Server.ExternalURL, actorID)
+	}
+	return fmt.Sprintf("%sapi/v1/activitypub/user-id/%d", conf.Server.ExternalURL, actorID)
+}
+
+// FederationActorURL returns the URI of the user as an ActivityPub actor.
+func (u *User) FederationActorURL() string {
+	return federationActorURL(FEDERATION_ACTOR_USER, u.ID)
+}
+
+// IsFederated returns true if the user can be followed from other instances.
+func (u *User) IsFederated() bool {
+	return conf.Federation.Enabled && u.IsActive && !u.ProhibitLogin && !u.IsDeactivated
+}
+
+// FederationActorURL returns the URI of the repository as an ActivityPub actor.
+func (repo *Repository) FederationActorURL() string {
+	return federationActorURL(FEDERATION_ACTOR_REPO, repo.ID)
+}
+
+// IsFederated returns true if the repository can be followed from other instances.
+func (repo *Repository) IsFederated() bool {
+	return conf.Federation.Enabled && !repo.IsPrivate
+}
+
+// FederationKey is the key pair of a local actor to sign requests to other instances.
+type FederationKey struct {
+	ID         int64
+	ActorType  FederationActorType `xorm:"UNIQUE(s)"`
+	ActorID    int64               `xorm:"UNIQUE(s)"`
+	PrivateKey string              `xorm:"TEXT"`
+	PublicKey  string              `xorm:"TEXT"`
+
+	CreatedUnix int64
+}
+
+func (k *FederationKey) BeforeInsert() {
+	k.CreatedUnix = time.Now().Unix()
+}
+
+// getFederationKey returns the key pair of the local actor, it is generated on first use.
+func getFederationKey(actorType FederationActorType, actorID int64) (*FederationKey, error) {
+	key := &FederationKey{
+		ActorType: actorType,
+		ActorID:   actorID,
+	}
+	has, err := x.Get(key)
+	if err != nil {
+		return nil, err
+	} else if has {
+		return key, nil
+	}
+
+	key.PrivateKey, key.PublicKey, err = activitypub.GenerateKeyPair()
+	if err != nil {
+		return nil, fmt.Errorf("GenerateKeyPair: %v", err)
+	}
+	if _, err = x.Insert(key); err != nil {
+		// The key may have been generated by a concurrent request.
+		if has, _ = x.Get(&FederationKey{ActorType: actorType, ActorID: actorID}); has {
+			return getFederationKey(actorType, actorID)
+		}
+		return nil, fmt.Errorf("insert: %v", err)
+	}
+	return key, nil
+}
+
+func federationPublicKey(actorType FederationActorType, actorID int64) (*activitypub.PublicKey, error) {
+	key, err := getFederationKey(actorType, actorID)
+	if err != nil {
+		return nil, fmt.Errorf("getFederationKey: %v", err)
+	}
+
+	actorURL := federationActorURL(actorType, actorID)
+	return &activitypub.PublicKey{
+		ID:           actorURL + "#main-key",
+		Owner:        actorURL,
+		PublicKeyPem: key.PublicKey,
+	}, nil
+}
+
+// FederationActor returns the ActivityPub actor document of the user or organization.
+func (u *User) FederationActor() (*activitypub.Actor, error) {
+	publicKey, err := federationPublicKey(FEDERATION_ACTOR_USER, u.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	actorURL := u.FederationActorURL()
+	actor := &activitypub.Actor{
+		Context:           activitypub.Context,
+		ID:                actorURL,
+		Type:              activitypub.TypePerson,
+		PreferredUsername: u.Name,
+		Name:              u.DisplayName(),
+		Summary:           template.HTMLEscapeString(u.Description),
+		URL:               u.HTMLURL(),
+		Icon: &activitypub.Image{
+			Type: "Image",
+			URL:  u.AvatarLink(),
+		},
+		Inbox:     actorURL + "/inbox",
+		Outbox:    actorURL + "/outbox",
+		Followers: actorURL + "/followers",
+		PublicKey: publicKey,
+		Published: time.Unix(u.CreatedUnix, 0).UTC().Format(time.RFC3339),
+	}
+	if u.IsOrganization() {
+		actor.Type = activitypub.TypeOrganization
+	}
+	return actor, nil
+}
+
+// FederationActor returns the ForgeFed actor document of the repository.
+func (repo *Repository) FederationActor() (*activitypub.Actor, error) {
+	if err := repo.GetOwner(); err != nil {
+		return nil, fmt.Errorf("GetOwner: %v", err)
+	}
+	publicKey, err := federationPublicKey(FEDERATION_ACTOR_REPO, repo.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	actorURL := repo.FederationActorURL()
+	return &activitypub.Actor{
+		Context:           activitypub.Context,
+		ID:                actorURL,
+		Type:              activitypub.TypeRepository,
+		PreferredUsername: repo.Name,
+		Name:              repo.FullName(),
+		Summary:           template.HTMLEscapeString(repo.Description),
+		URL:               repo.HTMLURL(),
+		Inbox:             actorURL + "/inbox",
+		Outbox:            actorURL + "/outbox",
+		Followers:         actorURL + "/followers",
+		AttributedTo:      repo.Owner.FederationActorURL(),
+		PublicKey:         publicKey,
+		Published:         time.Unix(repo.CreatedUnix, 0).UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// RemoteFollower is an actor of another instance who follows a local user or repository.
+type RemoteFollower struct {
+	ID          int64
+	ActorType   FederationActorType `xorm:"UNIQUE(s)"`
+	ActorID     int64               `xorm:"UNIQUE(s)"`
+	RemoteActor string              `xorm:"UNIQUE(s)"` // URI of the remote actor
+	Inbox       string              `xorm:"TEXT"`
+
+	Created     time.Time `xorm:"-" json:"-"`
+	CreatedUnix int64
+}
+
+func (f *RemoteFollower) BeforeInsert() {
+	f.CreatedUnix = time.Now().Unix()
+}
+
+func (f *RemoteFollower) AfterSet(colName string, _ xorm.Cell) {
+	switch colName {
+	case "created_unix":
+		f.Created = time.Unix(f.CreatedUnix, 0).Local()
+	}
+}
+
+// AddRemoteFollower adds the remote actor as a follower of the local actor, or updates
+// the inbox if the remote actor already follows.
+func AddRemoteFollower(actorType FederationActorType, actorID int64, remoteActor, inbox string) error {
+	f := &RemoteFollower{
+		ActorType:   actorType,
+		ActorID:     actorID,
+		RemoteActor: remoteActor,
+	}
+	has, err := x.Get(f)
+	if err != nil {
+		return err
+	} else if has {
+		f.Inbox = inbox
+		_, err = x.ID(f.ID).Cols("inbox").Update(f)
+		return err
+	}
+
+	f.Inbox = inbox
+	_, err = x.Insert(f)
+	return err
+}
+
+// RemoveRemoteFollower removes the remote actor from followers of the local actor.
+func RemoveRemoteFollower(actorType FederationActorType, actorID int64, remoteActor string) error {
+	_, err := x.Delete(&RemoteFollower{
+		ActorType:   actorType,
+		ActorID:     actorID,
+		RemoteActor: remoteActor,
+	})
+	return err
+}
+
+// GetRemoteFollowers returns all remote followers of the local actor.
+func GetRemoteFollowers(actorType FederationActorType, actorID int64) ([]*RemoteFollower, error) {
+	followers := make([]*RemoteFollower, 0, 10)
+	return followers, x.Where("actor_type = ? AND actor_id = ?", actorType, actorID).Asc("id").Find(&followers)
+}
+
+// AcceptRemoteFollow adds the actor of the Follow activity as a follower of the local
+// actor, and delivers an Accept activity to its inbox in background.
+func AcceptRemoteFollow(actorType FederationActorType, actorID int64, follow *activitypub.Activity, remote *activitypub.Actor) error {
+	if err := AddRemoteFollower(actorType, actorID, remote.ID, remote.Inbox); err != nil {
+		return fmt.Errorf("AddRemoteFollower: %v", err)
+	}
+
+	accept, err := activitypub.NewActivity(activitypub.TypeAccept, federationActorURL(actorType, actorID), follow)
+	if err != nil {
+		return fmt.Errorf("NewActivity: %v", err)
+	}
+	go func() {
+		if err := deliverFederationActivity(actorType, actorID, []string{remote.Inbox}, accept); err != nil {
+			log.Error("Failed to deliver Accept activity to %q: %v", remote.Inbox, err)
+		}
+	}()
+	return nil
+}
+
+// deliverFederationActivity delivers the activity to the inboxes on behalf of the local actor.
+func deliverFederationActivity(actorType FederationActorType, actorID int64, inboxes []string, activity *activitypub.Activity) error {
+	key, err := getFederationKey(actorType, actorID)
+	if err != nil {
+		return fmt.Errorf("getFederationKey: %v", err)
+	}
+	privateKey, err := activitypub.ParsePrivateKey(key.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("ParsePrivateKey: %v", err)
+	}
+
+	keyID := federationActorURL(actorType, actorID) + "#main-key"
+	for _, inbox := range inboxes {
+		if err = activitypub.Deliver(inbox, activity, keyID, privateKey); err != nil {
+			log.Error("Failed to deliver activity to %q: %v", inbox, err)
+		}
+	}
+	return nil
+}
+
+// followerInboxes returns inboxes of remote followers of the local actor, each inbox
+// is only returned once.
+func followerInboxes(actorType FederationActorType, actorID int64) ([]string, error) {
+	followers, err := GetRemoteFollowers(actorType, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	inboxes := make([]string, 0, len(followers))
+	seen := make(map[string]bool, len(followers))
+	for _, f := range followers {
+		if !seen[f.Inbox] {
+			seen[f.Inbox] = true
+			inboxes = append(inboxes, f.Inbox)
+		}
+	}
+	return inboxes, nil
+}
+
+// GetFederationActions returns the latest public actions of the local actor to be listed
+// in its outbox, and the total number of them.
+func GetFederationActions(actorType FederationActorType, actorID int64) ([]*Action, int64, error) {
+	sess := x.Where("is_private = ?", false)
+	if actorType == FEDERATION_ACTOR_REPO {
+		sess.And("repo_id = ? AND user_id = act_user_id", actorID)
+	} else {
+		sess.And("user_id = ? AND act_user_id = ?", actorID, actorID)
+	}
+
+	count, err := sess.Clone().Count(new(Action))
+	if err != nil {
+		return nil, 0, fmt.Errorf("count: %v", err)
+	}
+
+	actions := make([]*Action, 0, maxFederationOutboxItems)
+	return actions, count, sess.Desc("id").Limit(maxFederationOutboxItems).Find(&actions)
+}
+
+// federationSummary returns a sentence that describes the action.
+func (a *Action) federationSummary() string {
+	repo := a.RepoUserName + "/" + a.RepoName
+	issue := repo + "#" + a.GetIssueInfos()[0]
+	switch a.OpType {
+	case ACTION_CREATE_REPO:
+		return fmt.Sprintf("%s created repository %s", a.ActUserName, repo)
+	case ACTION_FORK_REPO:
+		return fmt.Sprintf("%s forked a repository to %s", a.ActUserName, repo)
+	case ACTION_RENAME_REPO:
+		return fmt.Sprintf("%s renamed repository from %s to %s", a.ActUserName, a.Content, repo)
+	case ACTION_TRANSFER_REPO:
+		return fmt.Sprintf("%s transferred repository %s to %s", a.ActUserName, a.Content, repo)
+	case ACTION_STAR_REPO:
+		return fmt.Sprintf("%s starred %s", a.ActUserName, repo)
+	case ACTION_WATCH_REPO:
+		return fmt.Sprintf("%s started watching %s", a.ActUserName, repo)
+	case ACTION_COMMIT_REPO, ACTION_MIRROR_SYNC_PUSH:
+		return fmt.Sprintf("%s pushed to %s at %s", a.ActUserName, a.RefName, repo)
+	case ACTION_CREATE_BRANCH:
+		return fmt.Sprintf("%s created branch %s at %s", a.ActUserName, a.RefName, repo)
+	case ACTION_DELETE_BRANCH:
+		return fmt.Sprintf("%s deleted branch %s at %s", a.ActUserName, a.RefName, repo)
+	case ACTION_PUSH_TAG:
+		return fmt.Sprintf("%s pushed tag %s to %s", a.ActUserName, a.RefName, repo)
+	case ACTION_DELETE_TAG:
+		return fmt.Sprintf("%s deleted tag %s at %s", a.ActUserName, a.RefName, repo)
+	case ACTION_MIRROR_SYNC_CREATE:
+		return fmt.Sprintf("%s synced new reference %s to %s", a.ActUserName, a.RefName, repo)
+	case ACTION_MIRROR_SYNC_DELETE:
+		return fmt.Sprintf("%s synced and deleted reference %s at %s", a.ActUserName, a.RefName, repo)
+	case ACTION_PUBLISH_RELEASE:
+		return fmt.Sprintf("%s published release %s of %s", a.ActUserName, a.RefName, repo)
+	case ACTION_CREATE_ISSUE:
+		return fmt.Sprintf("%s opened issue %s", a.ActUserName, issue)
+	case ACTION_CREATE_PULL_REQUEST:
+		return fmt.Sprintf("%s opened pull request %s", a.ActUserName, issue)
+	case ACTION_COMMENT_ISSUE:
+		return fmt.Sprintf("%s commented on %s", a.ActUserName, issue)
+	case ACTION_MERGE_PULL_REQUEST:
+		return fmt.Sprintf("%s merged pull request %s", a.ActUserName, issue)
+	case ACTION_CLOSE_ISSUE:
+		return fmt.Sprintf("%s closed issue %s", a.ActUserName, issue)
+	case ACTION_REOPEN_ISSUE:
+		return fmt.Sprintf("%s reopened issue %s", a.ActUserName, issue)
+	case ACTION_CLOSE_PULL_REQUEST:
+		return fmt.Sprintf("%s closed pull request %s", a.ActUserName, issue)
+	case ACTION_REOPEN_PULL_REQUEST:
+		return fmt.Sprintf("%s reopened pull request %s", a.ActUserName, issue)
+	}
+	return fmt.Sprintf("%s updated %s", a.ActUserName, repo)
+}
+
+// FederationActivity returns the activity of the action on behalf of the user who did it.
+// Pushes are represented by ForgeFed Push activities, and others by Create activities
+// of notes describing the actions.
+func (a *Action) FederationActivity() (*activitypub.Activity, error) {
+	actorURL := federationActorURL(FEDERATION_ACTOR_USER, a.ActUserID)
+	published := time.Unix(a.CreatedUnix, 0).UTC().Format(time.RFC3339)
+	to := []string{activitypub.PublicCollection}
+	cc := []string{actorURL + "/followers"}
+
+	summary := a.federationSummary()
+	note := &activitypub.Note{
+		ID:           fmt.Sprintf("%s/outbox/%d/object", actorURL, a.ID),
+		Type:         "Note",
+		AttributedTo: actorURL,
+		Content:      template.HTMLEscapeString(summary),
+		URL:          conf.Server.ExternalURL + a.GetRepoPath(),
+		Published:    published,
+		To:           to,
+		Cc:           cc,
+	}
+
+	typ := activitypub.TypeCreate
+	if a.OpType == ACTION_COMMIT_REPO || a.OpType == ACTION_MIRROR_SYNC_PUSH {
+		typ = activitypub.TypePush
+	}
+	activity, err := activitypub.NewActivity(typ, actorURL, note)
+	if err != nil {
+		return nil, err
+	}
+	activity.ID = fmt.Sprintf("%s/outbox/%d", actorURL, a.ID)
+	activity.Summary = summary
+	activity.Published = published
+	activity.To = to
+	activity.Cc = cc
+	if a.RepoID > 0 {
+		activity.Target = federationActorURL(FEDERATION_ACTOR_REPO, a.RepoID)
+	}
+	return activity, nil
+}
+
+// FederationAnnounce returns the activity of the repository announcing the action.
+func (a *Action) FederationAnnounce() (*activitypub.Activity, error) {
+	inner, err := a.FederationActivity()
+	if err != nil {
+		return nil, err
+	}
+
+	actorURL := federationActorURL(FEDERATION_ACTOR_REPO, a.RepoID)
+	announce, err := activitypub.NewActivity(activitypub.TypeAnnounce, actorURL, inner)
+	if err != nil {
+		return nil, err
+	}
+	announce.ID = fmt.Sprintf("%s/outbox/%d", actorURL, a.ID)
+	announce.Published = inner.Published
+	announce.To = []string{activitypub.PublicCollection}
+	announce.Cc = []string{actorURL + "/followers"}
+	return announce, nil
+}
+
+// deliverFederatedAction delivers the action to remote followers of the user who did
+// it and of the repository.
+func deliverFederatedAction(a *Action) error {
+	u, err := GetUserByID(a.ActUserID)
+	if err != nil {
+		return fmt.Errorf("GetUserByID [%d]: %v", a.ActUserID, err)
+	}
+	if u.IsFederated() {
+		inboxes, err := followerInboxes(FEDERATION_ACTOR_USER, u.ID)
+		if err != nil {
+			return fmt.Errorf("followerInboxes [user_id: %d]: %v", u.ID, err)
+		} else if len(inboxes) > 0 {
+			activity, err := a.FederationActivity()
+			if err != nil {
+				return fmt.Errorf("FederationActivity: %v", err)
+			}
+			if err = deliverFederationActivity(FEDERATION_ACTOR_USER, u.ID, inboxes, activity); err != nil {
+				return fmt.Errorf("deliverFederationActivity [user_id: %d]: %v", u.ID, err)
+			}
+		}
+	}
+
+	if a.RepoID == 0 {
+		return nil
+	}
+	repo, err := GetRepositoryByID(a.RepoID)
+	if err != nil {
+		return fmt.Errorf("GetRepositoryByID [%d]: %v", a.RepoID, err)
+	} else if !repo.IsFederated() {
+		return nil
+	}
+	inboxes, err := followerInboxes(FEDERATION_ACTOR_REPO, repo.ID)
+	if err != nil {
+		return fmt.Errorf("followerInboxes [repo_id: %d]: %v", repo.ID, err)
+	} else if len(inboxes) == 0 {
+		return nil
+	}
+	announce, err := a.FederationAnnounce()
+	if err != nil {
+		return fmt.Errorf("FederationAnnounce: %v", err)
+	}
+	if err = deliverFederationActivity(FEDERATION_ACTOR_REPO, repo.ID, inboxes, announce); err != nil {
+		return fmt.Errorf("deliverFederationActivity [repo_id: %d]: %v", repo.ID, err)
+	}
+	return nil
+}
+
+// DeliverFederatedActions delivers queued actions to remote followers.
+func DeliverFederatedActions() {
+	for id := range FederationQueue.Queue() {
+		log.Trace("DeliverFederatedActions [action_id: %s]", id)
+		FederationQueue.Remove(id)
+
+		a := new(Action)
+		has, err := x.ID(com.StrTo(id).MustInt64()).Get(a)
+		if err != nil {
+			log.Error("Get action [%s]: %v", id, err)
+			continue
+		} else if !has || a.IsPrivate {
+			continue
+		}
+
+		if err = deliverFederatedAction(a); err != nil {
+			log.Error("Failed to deliver action %d: %v", a.ID, err)
+		}
+	}
+}
+
+// InitFederation starts delivering actions to remote followers if federation is enabled.
+func InitFederation() {
+	if !conf.Federation.Enabled {
+		return
+	}
+	go DeliverFederatedActions()
+}
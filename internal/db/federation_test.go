@@ -0,0 +1,64 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"gogs.io/gogs/internal/activitypub"
+	"gogs.io/gogs/internal/conf"
+)
+
+func Test_Action_federationSummary(t *testing.T) {
+	Convey("Describe actions to be federated", t, func() {
+		testCases := []struct {
+			action *Action
+			expect string
+		}{
+			{
+				action: &Action{OpType: ACTION_CREATE_REPO, ActUserName: "alice", RepoUserName: "alice", RepoName: "demo"},
+				expect: "alice created repository alice/demo",
+			},
+			{
+				action: &Action{OpType: ACTION_COMMIT_REPO, ActUserName: "alice", RepoUserName: "org", RepoName: "demo", RefName: "master"},
+				expect: "alice pushed to master at org/demo",
+			},
+			{
+				action: &Action{OpType: ACTION_CREATE_ISSUE, ActUserName: "bob", RepoUserName: "org", RepoName: "demo", Content: "12|Crash on start"},
+				expect: "bob opened issue org/demo#12",
+			},
+			{
+				action: &Action{OpType: ACTION_PUBLISH_RELEASE, ActUserName: "alice", RepoUserName: "org", RepoName: "demo", RefName: "v1.0"},
+				expect: "alice published release v1.0 of org/demo",
+			},
+		}
+		for _, tc := range testCases {
+			So(tc.action.federationSummary(), ShouldEqual, tc.expect)
+		}
+	})
+}
+
+func Test_Action_FederationActivity(t *testing.T) {
+	Convey("Convert actions to activities", t, func() {
+		conf.Server.ExternalURL = "https://example.com/"
+
+		a := &Action{ID: 3, OpType: ACTION_COMMIT_REPO, ActUserID: 1, ActUserName: "alice", RepoID: 2, RepoUserName: "alice", RepoName: "demo", RefName: "master"}
+		activity, err := a.FederationActivity()
+		So(err, ShouldBeNil)
+		So(activity.Type, ShouldEqual, activitypub.TypePush)
+		So(activity.ID, ShouldEqual, "https://example.com/api/v1/activitypub/user-id/1/outbox/3")
+		So(activity.Actor, ShouldEqual, "https://example.com/api/v1/activitypub/user-id/1")
+		So(activity.Target, ShouldEqual, "https://example.com/api/v1/activitypub/repo-id/2")
+		So(activity.ObjectID(), ShouldEqual, "https://example.com/api/v1/activitypub/user-id/1/outbox/3/object")
+
+		announce, err := a.FederationAnnounce()
+		So(err, ShouldBeNil)
+		So(announce.Type, ShouldEqual, activitypub.TypeAnnounce)
+		So(announce.Actor, ShouldEqual, "https://example.com/api/v1/activitypub/repo-id/2")
+		So(announce.ObjectID(), ShouldEqual, activity.ID)
+	})
+}
@@ -10,6 +10,10 @@ import (
 	"html"
 	"html/template"
 	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/sergi/go-diff/diffmatchpatch"
 	"golang.org/x/net/html/charset"
@@ -107,6 +111,10 @@ func (diffSection *DiffSection) ComputedInlineDiffFor(diffLine *git.DiffLine) te
 type DiffFile struct {
 	*git.DiffFile
 	Sections []*DiffSection
+
+	// Whether the diff is not shown because of attributes in .gitattributes,
+	// content of the file is not loaded in this case.
+	IsSuppressed bool
 }
 
 func (diffFile *DiffFile) HighlightClass() string {
@@ -177,18 +185,234 @@ func ParsePatch(maxLines, maxLineCharacteres, maxFiles int, reader io.Reader) (*
 	return NewDiff(gitDiff), nil
 }
 
-func GetDiffRange(repoPath, beforeCommitID, afterCommitID string, maxLines, maxLineCharacteres, maxFiles int) (*Diff, error) {
-	gitDiff, err := git.GetDiffRange(repoPath, beforeCommitID, afterCommitID, maxLines, maxLineCharacteres, maxFiles)
+// emptyTreeID is the ID of the empty tree object, which is used to compare with
+// the first commit of a repository.
+const emptyTreeID = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+
+// diffSuppressedPatterns returns glob patterns of files whose diff should not be shown
+// according to given content of .gitattributes, i.e. files with "-diff" or "binary"
+// attribute, or a custom merge driver.
+func diffSuppressedPatterns(attrs string) []string {
+	patterns := make([]string, 0, 5)
+	for _, line := range strings.Split(attrs, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 ||
+			strings.HasPrefix(fields[0], "#") ||
+			strings.HasPrefix(fields[0], "[attr]") ||
+			strings.HasPrefix(fields[0], "!") ||
+			strings.HasSuffix(fields[0], "/") {
+			continue
+		}
+
+		suppressed := false
+		for _, attr := range fields[1:] {
+			switch {
+			case attr == "-diff", attr == "binary":
+				suppressed = true
+			case strings.HasPrefix(attr, "merge="):
+				switch strings.TrimPrefix(attr, "merge=") {
+				case "text", "binary", "union": // Built-in merge drivers
+				default:
+					suppressed = true
+				}
+			}
+		}
+		if !suppressed {
+			continue
+		}
+
+		// Patterns without a slash match at any level, as same as .gitignore.
+		pattern := fields[0]
+		if strings.Contains(pattern, "/") {
+			pattern = strings.TrimPrefix(pattern, "/")
+		} else {
+			pattern = "**/" + pattern
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns
+}
+
+// parseRawDiff parses output of "git diff --raw -z --no-abbrev" to a list of files
+// without content.
+func parseRawDiff(raw string) []*git.DiffFile {
+	fields := strings.Split(strings.TrimSuffix(raw, "\x00"), "\x00")
+	files := make([]*git.DiffFile, 0, len(fields)/2)
+	for i := 0; i+1 < len(fields); i += 2 {
+		// e.g. ":100644 100644 <old SHA> <new SHA> M"
+		meta := strings.Fields(fields[i])
+		if len(meta) != 5 || len(meta[4]) == 0 {
+			break
+		}
+
+		f := &git.DiffFile{
+			Name:  fields[i+1],
+			Index: meta[3],
+		}
+		switch meta[4][0] {
+		case 'A':
+			f.Type = git.DiffFileAdd
+			f.IsCreated = true
+		case 'D':
+			f.Type = git.DiffFileDel
+			f.IsDeleted = true
+			f.Index = meta[2]
+		case 'R':
+			if i+2 >= len(fields) {
+				return files
+			}
+			f.Type = git.DiffFileRename
+			f.IsRenamed = true
+			f.OldName = f.Name
+			f.Name = fields[i+2]
+			i++
+		default:
+			f.Type = git.DiffFileChange
+		}
+		files = append(files, f)
+	}
+	return files
+}
+
+// getDiffSuppressedPatterns returns glob patterns of files whose diff should not be shown
+// according to .gitattributes of the commit.
+func getDiffSuppressedPatterns(commit *git.Commit) ([]string, error) {
+	entry, err := commit.GetTreeEntryByPath(".gitattributes")
 	if err != nil {
-		return nil, fmt.Errorf("GetDiffRange: %v", err)
+		if git.IsErrNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	} else if entry.IsDir() {
+		return nil, nil
 	}
-	return NewDiff(gitDiff), nil
+
+	r, err := entry.Blob().Data()
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return diffSuppressedPatterns(string(data)), nil
 }
 
-func GetDiffCommit(repoPath, commitID string, maxLines, maxLineCharacteres, maxFiles int) (*Diff, error) {
-	gitDiff, err := git.GetDiffCommit(repoPath, commitID, maxLines, maxLineCharacteres, maxFiles)
+// parentCommitID returns the ID of the first parent of the commit, or the ID of the
+// empty tree if the commit is the first commit of repository.
+func parentCommitID(commit *git.Commit) (string, error) {
+	if commit.ParentCount() == 0 {
+		return emptyTreeID, nil
+	}
+	parentID, err := commit.ParentID(0)
 	if err != nil {
-		return nil, fmt.Errorf("GetDiffCommit: %v", err)
+		return "", fmt.Errorf("get parent ID: %v", err)
 	}
-	return NewDiff(gitDiff), nil
+	return parentID.String(), nil
+}
+
+// runDiff runs the git command and parses its output as a patch.
+func runDiff(repoPath string, maxLines, maxLineCharacteres, maxFiles int, args ...string) (*git.Diff, error) {
+	stdout, w := io.Pipe()
+	done := make(chan error, 1)
+	var diff *git.Diff
+	go func() {
+		diff = git.ParsePatch(done, maxLines, maxLineCharacteres, maxFiles, stdout)
+	}()
+
+	stderr := new(bytes.Buffer)
+	err := git.NewCommand(args...).RunInDirTimeoutPipeline(2*time.Minute, repoPath, w, stderr)
+	w.Close() // Close writer to exit parsing goroutine
+	if err != nil {
+		return nil, fmt.Errorf("%v - %s", err, stderr)
+	}
+	return diff, <-done
+}
+
+// GetDiffRange returns the diff between given commits. Diffs of files that are suppressed
+// by .gitattributes of the after commit are not generated unless their names are given
+// in expandedFiles.
+func GetDiffRange(repoPath, beforeCommitID, afterCommitID string, maxLines, maxLineCharacteres, maxFiles int, expandedFiles ...string) (*Diff, error) {
+	gitRepo, err := git.OpenRepository(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("OpenRepository: %v", err)
+	}
+	commit, err := gitRepo.GetCommit(afterCommitID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(beforeCommitID) == 0 {
+		beforeCommitID, err = parentCommitID(commit)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	patterns, err := getDiffSuppressedPatterns(commit)
+	if err != nil {
+		return nil, fmt.Errorf("getDiffSuppressedPatterns: %v", err)
+	}
+
+	args := []string{"diff", "--full-index", "-M", beforeCommitID, afterCommitID}
+	var suppressed []*git.DiffFile
+	if len(patterns) > 0 {
+		rawArgs := []string{"diff", "--raw", "-z", "--no-abbrev", "-M", beforeCommitID, afterCommitID, "--"}
+		args = append(args, "--", ".")
+		for _, pattern := range patterns {
+			rawArgs = append(rawArgs, ":(glob)"+pattern)
+			args = append(args, ":(exclude,glob)"+pattern)
+		}
+
+		raw, err := git.NewCommand(rawArgs...).RunInDirTimeout(time.Minute, repoPath)
+		if err != nil {
+			return nil, fmt.Errorf("list suppressed files: %v", err)
+		}
+		suppressed = parseRawDiff(string(raw))
+	}
+
+	gitDiff, err := runDiff(repoPath, maxLines, maxLineCharacteres, maxFiles, args...)
+	if err != nil {
+		return nil, fmt.Errorf("GetDiffRange: %v", err)
+	}
+	diff := NewDiff(gitDiff)
+	if len(suppressed) == 0 {
+		return diff, nil
+	}
+
+	expanded := make(map[string]bool, len(expandedFiles))
+	for _, name := range expandedFiles {
+		expanded[name] = true
+	}
+	for _, f := range suppressed {
+		if expanded[f.Name] {
+			fileArgs := []string{"diff", "--full-index", "-M", beforeCommitID, afterCommitID, "--", ":(literal)" + f.Name}
+			if f.IsRenamed {
+				fileArgs = append(fileArgs, ":(literal)"+f.OldName)
+			}
+			fileDiff, err := runDiff(repoPath, maxLines, maxLineCharacteres, maxFiles, fileArgs...)
+			if err != nil {
+				return nil, fmt.Errorf("get diff of %q: %v", f.Name, err)
+			}
+			if fileDiff.NumFiles() > 0 {
+				gitDiff.Files = append(gitDiff.Files, fileDiff.Files...)
+				diff.Files = append(diff.Files, NewDiff(fileDiff).Files...)
+				continue
+			}
+		}
+
+		gitDiff.Files = append(gitDiff.Files, f)
+		diff.Files = append(diff.Files, &DiffFile{
+			DiffFile:     f,
+			IsSuppressed: true,
+		})
+	}
+	sort.SliceStable(diff.Files, func(i, j int) bool {
+		return diff.Files[i].Name < diff.Files[j].Name
+	})
+	return diff, nil
+}
+
+func GetDiffCommit(repoPath, commitID string, maxLines, maxLineCharacteres, maxFiles int, expandedFiles ...string) (*Diff, error) {
+	return GetDiffRange(repoPath, "", commitID, maxLines, maxLineCharacteres, maxFiles, expandedFiles...)
 }
@@ -10,6 +10,7 @@ import (
 
 	"github.com/gogs/git-module"
 	dmp "github.com/sergi/go-diff/diffmatchpatch"
+	. "github.com/smartystreets/goconvey/convey"
 )
 
 func assertEqual(t *testing.T, s1 string, s2 template.HTML) {
@@ -39,3 +40,39 @@ func Test_diffToHTML(t *testing.T) {
 		dmp.Diff{dmp.DiffEqual, " biz"},
 	}, git.DIFF_LINE_DEL))
 }
+
+func Test_diffSuppressedPatterns(t *testing.T) {
+	Convey("Get patterns of files whose diff should not be shown", t, func() {
+		attrs := `# comment
+*.go text diff=golang
+*.min.js -diff
+/vendor/** binary
+docs/*.pdf merge=union
+*.lock merge=ours
+[attr]generated -diff
+build/ -diff
+`
+		So(diffSuppressedPatterns(attrs), ShouldResemble, []string{"**/*.min.js", "vendor/**", "**/*.lock"})
+		So(diffSuppressedPatterns(""), ShouldBeEmpty)
+	})
+}
+
+func Test_parseRawDiff(t *testing.T) {
+	Convey("Parse raw diff output", t, func() {
+		sha1 := "1111111111111111111111111111111111111111"
+		sha2 := "2222222222222222222222222222222222222222"
+		zero := "0000000000000000000000000000000000000000"
+		raw := ":100644 100644 " + sha1 + " " + sha2 + " M\x00a.min.js\x00" +
+			":000000 100644 " + zero + " " + sha2 + " A\x00b.min.js\x00" +
+			":100644 000000 " + sha1 + " " + zero + " D\x00c.min.js\x00" +
+			":100644 100644 " + sha1 + " " + sha2 + " R090\x00d.min.js\x00e.min.js\x00"
+
+		So(parseRawDiff(raw), ShouldResemble, []*git.DiffFile{
+			{Name: "a.min.js", Index: sha2, Type: git.DiffFileChange},
+			{Name: "b.min.js", Index: sha2, Type: git.DiffFileAdd, IsCreated: true},
+			{Name: "c.min.js", Index: sha1, Type: git.DiffFileDel, IsDeleted: true},
+			{Name: "e.min.js", OldName: "d.min.js", Index: sha2, Type: git.DiffFileRename, IsRenamed: true},
+		})
+		So(parseRawDiff(""), ShouldBeEmpty)
+	})
+}
@@ -44,6 +44,7 @@ type Issue struct {
 	IsClosed        bool
 	IsRead          bool         `xorm:"-" json:"-"`
 	IsPull          bool         // Indicates whether is a pull request or not.
+	IsConfidential  bool         // Only visible to the poster and users who can triage issues.
 	PullRequest     *PullRequest `xorm:"-" json:"-"`
 	NumComments     int
 
@@ -59,8 +60,13 @@ type Issue struct {
 }
 
 func (issue *Issue) BeforeInsert() {
-	issue.CreatedUnix = time.Now().Unix()
-	issue.UpdatedUnix = issue.CreatedUnix
+	// Imported issues come with their original time.
+	if issue.CreatedUnix == 0 {
+		issue.CreatedUnix = time.Now().Unix()
+	}
+	if issue.UpdatedUnix == 0 {
+		issue.UpdatedUnix = issue.CreatedUnix
+	}
 }
 
 func (issue *Issue) BeforeUpdate() {
@@ -91,8 +97,8 @@ func (issue *Issue) loadAttributes(e Engine) (err error) {
 		issue.Poster, err = getUserByID(e, issue.PosterID)
 		if err != nil {
 			if errors.IsUserNotExist(err) {
+				issue.Poster = getGhostUser(e, issue.PosterID)
 				issue.PosterID = -1
-				issue.Poster = NewGhostUser()
 			} else {
 				return fmt.Errorf("getUserByID.(Poster) [%d]: %v", issue.PosterID, err)
 			}
@@ -217,6 +223,46 @@ func (issue *Issue) IsPoster(uid int64) bool {
 	return issue.PosterID == uid
 }
 
+// canSeeConfidentialIssues returns true if the user can see all confidential issues
+// of the repository, i.e. the user has triage or higher access to issues.
+func canSeeConfidentialIssues(e Engine, userID int64, repo *Repository) (bool, error) {
+	mode, err := userUnitAccessMode(e, userID, repo, REPO_UNIT_ISSUES)
+	return mode >= ACCESS_MODE_TRIAGE, err
+}
+
+// isVisibleTo returns true if the user can see the issue, which is only false for
+// confidential issues that are not posted by the user.
+func (issue *Issue) isVisibleTo(e Engine, userID int64) (bool, error) {
+	if !issue.IsConfidential || (userID > 0 && issue.IsPoster(userID)) {
+		return true, nil
+	}
+
+	if issue.Repo == nil {
+		repo, err := getRepositoryByID(e, issue.RepoID)
+		if err != nil {
+			return false, fmt.Errorf("getRepositoryByID [%d]: %v", issue.RepoID, err)
+		}
+		issue.Repo = repo
+	}
+	return canSeeConfidentialIssues(e, userID, issue.Repo)
+}
+
+// confidentialIssuesCond returns the condition and its arguments of issues that are
+// visible to the user, i.e. all but confidential issues that the user did not post in
+// repositories where the user has less than triage access to issues. Zero user ID
+// means an anonymous user.
+func confidentialIssuesCond(userID int64) (string, []interface{}) {
+	if userID <= 0 {
+		return "issue.is_confidential = ?", []interface{}{false}
+	}
+	return "(issue.is_confidential = ? OR issue.poster_id = ?" +
+			" OR issue.repo_id IN (SELECT id FROM repository WHERE owner_id = ?)" +
+			" OR issue.repo_id IN (SELECT repo_id FROM access_unit WHERE user_id = ? AND unit = ? AND mode >= ?)" +
+			" OR issue.repo_id IN (SELECT repo_id FROM access WHERE user_id = ? AND mode >= ?" +
+			" AND repo_id NOT IN (SELECT repo_id FROM access_unit WHERE user_id = ? AND unit = ?)))",
+		[]interface{}{false, userID, userID, userID, REPO_UNIT_ISSUES, ACCESS_MODE_TRIAGE, userID, ACCESS_MODE_TRIAGE, userID, REPO_UNIT_ISSUES}
+}
+
 func (issue *Issue) hasLabel(e Engine, labelID int64) bool {
 	return hasIssueLabel(e, issue.ID, labelID)
 }
@@ -255,13 +301,13 @@ func (issue *Issue) sendLabelUpdatedWebhook(doer *User) {
 	}
 }
 
-func (issue *Issue) addLabel(e *xorm.Session, label *Label) error {
-	return newIssueLabel(e, issue, label)
+func (issue *Issue) addLabel(e *xorm.Session, doer *User, label *Label) error {
+	return newIssueLabel(e, doer, issue, label)
 }
 
 // AddLabel adds a new label to the issue.
 func (issue *Issue) AddLabel(doer *User, label *Label) error {
-	if err := NewIssueLabel(issue, label); err != nil {
+	if err := NewIssueLabel(doer, issue, label); err != nil {
 		return err
 	}
 
@@ -269,13 +315,13 @@ func (issue *Issue) AddLabel(doer *User, label *Label) error {
 	return nil
 }
 
-func (issue *Issue) addLabels(e *xorm.Session, labels []*Label) error {
-	return newIssueLabels(e, issue, labels)
+func (issue *Issue) addLabels(e *xorm.Session, doer *User, labels []*Label) error {
+	return newIssueLabels(e, doer, issue, labels)
 }
 
 // AddLabels adds a list of new labels to the issue.
 func (issue *Issue) AddLabels(doer *User, labels []*Label) error {
-	if err := NewIssueLabels(issue, labels); err != nil {
+	if err := NewIssueLabels(doer, issue, labels); err != nil {
 		return err
 	}
 
@@ -295,13 +341,13 @@ func (issue *Issue) getLabels(e Engine) (err error) {
 	return nil
 }
 
-func (issue *Issue) removeLabel(e *xorm.Session, label *Label) error {
-	return deleteIssueLabel(e, issue, label)
+func (issue *Issue) removeLabel(e *xorm.Session, doer *User, label *Label) error {
+	return deleteIssueLabel(e, doer, issue, label)
 }
 
 // RemoveLabel removes a label from issue by given ID.
 func (issue *Issue) RemoveLabel(doer *User, label *Label) error {
-	if err := DeleteIssueLabel(issue, label); err != nil {
+	if err := DeleteIssueLabel(doer, issue, label); err != nil {
 		return err
 	}
 
@@ -309,7 +355,7 @@ func (issue *Issue) RemoveLabel(doer *User, label *Label) error {
 	return nil
 }
 
-func (issue *Issue) clearLabels(e *xorm.Session) (err error) {
+func (issue *Issue) clearLabels(e *xorm.Session, doer *User) (err error) {
 	if err = issue.getLabels(e); err != nil {
 		return fmt.Errorf("getLabels: %v", err)
 	}
@@ -320,7 +366,7 @@ func (issue *Issue) clearLabels(e *xorm.Session) (err error) {
 		labels[i] = issue.Labels[i]
 	}
 	for i := range labels {
-		if err = issue.removeLabel(e, labels[i]); err != nil {
+		if err = issue.removeLabel(e, doer, labels[i]); err != nil {
 			return fmt.Errorf("removeLabel: %v", err)
 		}
 	}
@@ -335,7 +381,7 @@ func (issue *Issue) ClearLabels(doer *User) (err error) {
 		return err
 	}
 
-	if err = issue.clearLabels(sess); err != nil {
+	if err = issue.clearLabels(sess, doer); err != nil {
 		return err
 	}
 
@@ -372,17 +418,35 @@ func (issue *Issue) ClearLabels(doer *User) (err error) {
 	return nil
 }
 
-// ReplaceLabels removes all current labels and add new labels to the issue.
-func (issue *Issue) ReplaceLabels(labels []*Label) (err error) {
+// ReplaceLabels removes current labels that are not in the list and add new labels to the issue.
+func (issue *Issue) ReplaceLabels(doer *User, labels []*Label) (err error) {
 	sess := x.NewSession()
 	defer sess.Close()
 	if err = sess.Begin(); err != nil {
 		return err
 	}
 
-	if err = issue.clearLabels(sess); err != nil {
-		return fmt.Errorf("clearLabels: %v", err)
-	} else if err = issue.addLabels(sess, labels); err != nil {
+	if err = issue.getLabels(sess); err != nil {
+		return fmt.Errorf("getLabels: %v", err)
+	}
+
+	keep := make(map[int64]bool, len(labels))
+	for i := range labels {
+		keep[labels[i].ID] = true
+	}
+
+	// NOTE: issue.removeLabel slices issue.Labels, so we need to create another slice to be unaffected.
+	oldLabels := make([]*Label, len(issue.Labels))
+	copy(oldLabels, issue.Labels)
+	for i := range oldLabels {
+		if keep[oldLabels[i].ID] {
+			continue
+		} else if err = issue.removeLabel(sess, doer, oldLabels[i]); err != nil {
+			return fmt.Errorf("removeLabel: %v", err)
+		}
+	}
+
+	if err = issue.addLabels(sess, doer, labels); err != nil {
 		return fmt.Errorf("addLabels: %v", err)
 	}
 
@@ -403,6 +467,9 @@ func (issue *Issue) GetAssignee() (err error) {
 
 // ReadBy sets issue to be read by given user.
 func (issue *Issue) ReadBy(uid int64) error {
+	if err := markIssueNotificationRead(x, uid, issue.ID); err != nil {
+		return fmt.Errorf("markIssueNotificationRead: %v", err)
+	}
 	return UpdateIssueUserByRead(uid, issue.ID)
 }
 
@@ -515,6 +582,18 @@ func (issue *Issue) ChangeTitle(doer *User, title string) (err error) {
 	if err = UpdateIssueCols(issue, "name"); err != nil {
 		return fmt.Errorf("UpdateIssueCols: %v", err)
 	}
+	AddIssueIndexerTask(issue.ID)
+
+	if err = createIssueEvent(x, &IssueEvent{
+		RepoID:   issue.RepoID,
+		IssueID:  issue.ID,
+		Type:     ISSUE_EVENT_RENAMED,
+		ActorID:  doer.ID,
+		OldTitle: oldTitle,
+		NewTitle: title,
+	}); err != nil {
+		return fmt.Errorf("createIssueEvent: %v", err)
+	}
 
 	if issue.IsPull {
 		issue.PullRequest.Issue = issue
@@ -557,6 +636,7 @@ func (issue *Issue) ChangeContent(doer *User, content string) (err error) {
 	if err = UpdateIssueCols(issue, "content"); err != nil {
 		return fmt.Errorf("UpdateIssueCols: %v", err)
 	}
+	AddIssueIndexerTask(issue.ID)
 
 	if issue.IsPull {
 		issue.PullRequest.Issue = issue
@@ -593,12 +673,53 @@ func (issue *Issue) ChangeContent(doer *User, content string) (err error) {
 	return nil
 }
 
+// ChangeConfidential changes whether the issue is confidential. Actions of the issue
+// are hidden from public feeds while it is confidential.
+func (issue *Issue) ChangeConfidential(isConfidential bool) (err error) {
+	sess := x.NewSession()
+	defer sess.Close()
+	if err = sess.Begin(); err != nil {
+		return err
+	}
+
+	issue.IsConfidential = isConfidential
+	if err = updateIssueCols(sess, issue, "is_confidential"); err != nil {
+		return fmt.Errorf("updateIssueCols: %v", err)
+	}
+
+	// Contents of actions of the issue start with the issue index.
+	if _, err = sess.Where("repo_id = ? AND content LIKE ?", issue.RepoID, fmt.Sprintf("%d|%%", issue.Index)).
+		In("op_type", ACTION_CREATE_ISSUE, ACTION_COMMENT_ISSUE, ACTION_CLOSE_ISSUE, ACTION_REOPEN_ISSUE).
+		Cols("is_private", "is_confidential").
+		Update(&Action{
+			IsPrivate:      issue.Repo.IsPrivate || isConfidential,
+			IsConfidential: isConfidential,
+		}); err != nil {
+		return fmt.Errorf("change action visibility of issue: %v", err)
+	}
+
+	return sess.Commit()
+}
+
 func (issue *Issue) ChangeAssignee(doer *User, assigneeID int64) (err error) {
+	oldAssigneeID := issue.AssigneeID
 	issue.AssigneeID = assigneeID
 	if err = UpdateIssueUserByAssignee(issue); err != nil {
 		return fmt.Errorf("UpdateIssueUserByAssignee: %v", err)
 	}
 
+	if oldAssigneeID > 0 && oldAssigneeID != assigneeID {
+		if err = createIssueEvent(x, &IssueEvent{
+			RepoID:     issue.RepoID,
+			IssueID:    issue.ID,
+			Type:       ISSUE_EVENT_UNASSIGNED,
+			ActorID:    doer.ID,
+			AssigneeID: oldAssigneeID,
+		}); err != nil {
+			return fmt.Errorf("createIssueEvent: %v", err)
+		}
+	}
+
 	issue.Assignee, err = GetUserByID(issue.AssigneeID)
 	if err != nil && !errors.IsUserNotExist(err) {
 		log.Error("GetUserByID [assignee_id: %v]: %v", issue.AssigneeID, err)
@@ -607,6 +728,22 @@ func (issue *Issue) ChangeAssignee(doer *User, assigneeID int64) (err error) {
 
 	// Error not nil here means user does not exist, which is remove assignee.
 	isRemoveAssignee := err != nil
+	if !isRemoveAssignee && oldAssigneeID != assigneeID {
+		if err = createIssueEvent(x, &IssueEvent{
+			RepoID:     issue.RepoID,
+			IssueID:    issue.ID,
+			Type:       ISSUE_EVENT_ASSIGNED,
+			ActorID:    doer.ID,
+			AssigneeID: assigneeID,
+		}); err != nil {
+			return fmt.Errorf("createIssueEvent: %v", err)
+		}
+
+		if err = notifyIssueUsers(x, issue.Repo, issue, 0, doer.ID, NOTIFICATION_REASON_ASSIGN, []int64{assigneeID}); err != nil {
+			log.Error("notifyIssueUsers: %v", err)
+		}
+	}
+
 	if issue.IsPull {
 		issue.PullRequest.Issue = issue
 		apiPullRequest := &api.PullRequestPayload{
@@ -695,6 +832,9 @@ func newIssue(e *xorm.Session, opts NewIssueOptions) (err error) {
 	if _, err = e.Insert(opts.Issue); err != nil {
 		return err
 	}
+	if err = createIssueHistory(e, opts.Issue, opts.Issue.MilestoneID, ISSUE_HISTORY_MILESTONE_ADD); err != nil {
+		return fmt.Errorf("createIssueHistory: %v", err)
+	}
 
 	if opts.IsPull {
 		_, err = e.Exec("UPDATE `repository` SET num_pulls = num_pulls + 1 WHERE id = ?", opts.Issue.RepoID)
@@ -719,7 +859,7 @@ func newIssue(e *xorm.Session, opts NewIssueOptions) (err error) {
 				continue
 			}
 
-			if err = opts.Issue.addLabel(e, label); err != nil {
+			if err = opts.Issue.addLabel(e, nil, label); err != nil {
 				return fmt.Errorf("addLabel [id: %d]: %v", label.ID, err)
 			}
 		}
@@ -766,22 +906,31 @@ func NewIssue(repo *Repository, issue *Issue, labelIDs []int64, uuids []string)
 	if err = sess.Commit(); err != nil {
 		return fmt.Errorf("Commit: %v", err)
 	}
+	AddIssueIndexerTask(issue.ID)
+
+	if err = createIssueReferenceEvents(issue.Poster, issue, 0, issue.Content); err != nil {
+		log.Error("createIssueReferenceEvents: %v", err)
+	}
 
 	if err = NotifyWatchers(&Action{
-		ActUserID:    issue.Poster.ID,
-		ActUserName:  issue.Poster.Name,
-		OpType:       ACTION_CREATE_ISSUE,
-		Content:      fmt.Sprintf("%d|%s", issue.Index, issue.Title),
-		RepoID:       repo.ID,
-		RepoUserName: repo.Owner.Name,
-		RepoName:     repo.Name,
-		IsPrivate:    repo.IsPrivate,
+		ActUserID:      issue.Poster.ID,
+		ActUserName:    issue.Poster.Name,
+		OpType:         ACTION_CREATE_ISSUE,
+		Content:        fmt.Sprintf("%d|%s", issue.Index, issue.Title),
+		RepoID:         repo.ID,
+		RepoUserName:   repo.Owner.Name,
+		RepoName:       repo.Name,
+		IsPrivate:      repo.IsPrivate || issue.IsConfidential,
+		IsConfidential: issue.IsConfidential,
 	}); err != nil {
 		log.Error("NotifyWatchers: %v", err)
 	}
 	if err = issue.MailParticipants(); err != nil {
 		log.Error("MailParticipants: %v", err)
 	}
+	if err = notifyIssueParticipants(x, repo, issue, 0, issue.PosterID, issue.Content); err != nil {
+		log.Error("notifyIssueParticipants: %v", err)
+	}
 
 	if err = PrepareWebhooks(repo, HOOK_EVENT_ISSUES, &api.IssuesPayload{
 		Action:     api.HOOK_ISSUE_OPENED,
@@ -877,12 +1026,18 @@ type IssuesOptions struct {
 	PosterID    int64
 	MilestoneID int64
 	RepoIDs     []int64
-	Page        int
-	IsClosed    bool
-	IsMention   bool
-	IsPull      bool
-	Labels      string
-	SortType    string
+	// IssueIDs limits issues to given IDs when it is not nil, e.g. issues matched by
+	// a keyword search.
+	IssueIDs  []int64
+	Page      int
+	IsClosed  bool
+	IsMention bool
+	IsPull    bool
+	Labels    string
+	SortType  string
+	// HideConfidential excludes confidential issues that the user of UserID cannot
+	// see, zero or negative UserID stands for anonymous users.
+	HideConfidential bool
 }
 
 // buildIssuesQuery returns nil if it foresees there won't be any value returned.
@@ -905,6 +1060,13 @@ func buildIssuesQuery(opts *IssuesOptions) *xorm.Session {
 		sess.Where("issue.is_closed=?", opts.IsClosed)
 	}
 
+	if opts.IssueIDs != nil {
+		if len(opts.IssueIDs) == 0 {
+			return nil
+		}
+		sess.In("issue.id", opts.IssueIDs)
+	}
+
 	if opts.AssigneeID > 0 {
 		sess.And("issue.assignee_id=?", opts.AssigneeID)
 	} else if opts.PosterID > 0 {
@@ -917,6 +1079,11 @@ func buildIssuesQuery(opts *IssuesOptions) *xorm.Session {
 
 	sess.And("issue.is_pull=?", opts.IsPull)
 
+	if opts.HideConfidential {
+		cond, args := confidentialIssuesCond(opts.UserID)
+		sess.And(cond, args...)
+	}
+
 	switch opts.SortType {
 	case "oldest":
 		sess.Asc("issue.created_unix")
@@ -1208,6 +1375,11 @@ type IssueStatsOptions struct {
 	AssigneeID  int64
 	FilterMode  FilterMode
 	IsPull      bool
+	// IssueIDs limits issues to given IDs when it is not nil.
+	IssueIDs []int64
+	// HideConfidential excludes confidential issues that the user of UserID cannot
+	// see from the counts.
+	HideConfidential bool
 }
 
 // GetIssueStats returns issue statistic information by given conditions.
@@ -1232,6 +1404,19 @@ func GetIssueStats(opts *IssueStatsOptions) *IssueStats {
 			sess.And("assignee_id = ?", opts.AssigneeID)
 		}
 
+		if opts.IssueIDs != nil {
+			if len(opts.IssueIDs) == 0 {
+				sess.And("1 = 0")
+			} else {
+				sess.In("issue.id", opts.IssueIDs)
+			}
+		}
+
+		if opts.HideConfidential {
+			cond, args := confidentialIssuesCond(opts.UserID)
+			sess.And(cond, args...)
+		}
+
 		return sess
 	}
 
@@ -1273,7 +1458,9 @@ func GetIssueStats(opts *IssueStatsOptions) *IssueStats {
 }
 
 // GetUserIssueStats returns issue statistic information for dashboard by given conditions.
-func GetUserIssueStats(repoID, userID int64, repoIDs []int64, filterMode FilterMode, isPull bool) *IssueStats {
+// Confidential issues that are not visible to the viewer are not counted, all issues
+// are counted if the viewer is nil.
+func GetUserIssueStats(repoID, userID int64, repoIDs []int64, filterMode FilterMode, isPull bool, viewer *User) *IssueStats {
 	stats := &IssueStats{}
 	hasAnyRepo := repoID > 0 || len(repoIDs) > 0
 	countSession := func(isClosed, isPull bool, repoID int64, repoIDs []int64) *xorm.Session {
@@ -1285,6 +1472,11 @@ func GetUserIssueStats(repoID, userID int64, repoIDs []int64, filterMode FilterM
 			sess.In("repo_id", repoIDs)
 		}
 
+		if viewer != nil {
+			cond, args := confidentialIssuesCond(viewer.ID)
+			sess.And(cond, args...)
+		}
+
 		return sess
 	}
 
@@ -0,0 +1,305 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+
+	"github.com/unknwon/com"
+	"xorm.io/xorm"
+
+	"gogs.io/gogs/internal/db/errors"
+	"gogs.io/gogs/internal/markup"
+)
+
+// IssueEventType is the type of a change made to an issue.
+type IssueEventType int
+
+const (
+	ISSUE_EVENT_LABELED IssueEventType = iota + 1
+	ISSUE_EVENT_UNLABELED
+	ISSUE_EVENT_MILESTONED
+	ISSUE_EVENT_DEMILESTONED
+	ISSUE_EVENT_ASSIGNED
+	ISSUE_EVENT_UNASSIGNED
+	ISSUE_EVENT_RENAMED
+	ISSUE_EVENT_REFERENCED
+)
+
+var issueEventTypeNames = map[IssueEventType]string{
+	ISSUE_EVENT_LABELED:      "labeled",
+	ISSUE_EVENT_UNLABELED:    "unlabeled",
+	ISSUE_EVENT_MILESTONED:   "milestoned",
+	ISSUE_EVENT_DEMILESTONED: "demilestoned",
+	ISSUE_EVENT_ASSIGNED:     "assigned",
+	ISSUE_EVENT_UNASSIGNED:   "unassigned",
+	ISSUE_EVENT_RENAMED:      "renamed",
+	ISSUE_EVENT_REFERENCED:   "referenced",
+}
+
+// Name returns the name of the event type, e.g. "labeled".
+func (t IssueEventType) Name() string {
+	return issueEventTypeNames[t]
+}
+
+// IssueEvent represents a typed change of an issue, e.g. a label is added or title is changed.
+// Names of labels and milestones are saved along with their IDs, so events remain readable
+// after the label or milestone is deleted.
+type IssueEvent struct {
+	ID      int64
+	RepoID  int64 `xorm:"INDEX"`
+	IssueID int64 `xorm:"INDEX"`
+	Type    IssueEventType
+	ActorID int64
+	Actor   *User `xorm:"-" json:"-"`
+
+	LabelID       int64
+	LabelName     string
+	LabelColor    string `xorm:"VARCHAR(7)"`
+	MilestoneID   int64
+	MilestoneName string
+	AssigneeID    int64
+	Assignee      *User `xorm:"-" json:"-"`
+	OldTitle      string
+	NewTitle      string
+	RefIssueID    int64
+	RefIssue      *Issue `xorm:"-" json:"-"`
+	RefCommentID  int64
+
+	Created     time.Time `xorm:"-" json:"-"`
+	CreatedUnix int64
+}
+
+func (e *IssueEvent) BeforeInsert() {
+	e.CreatedUnix = time.Now().Unix()
+}
+
+func (e *IssueEvent) AfterSet(colName string, _ xorm.Cell) {
+	switch colName {
+	case "created_unix":
+		e.Created = time.Unix(e.CreatedUnix, 0).Local()
+	}
+}
+
+func (e *IssueEvent) loadAttributes(engine Engine) (err error) {
+	if e.Actor == nil {
+		e.Actor, err = getUserByID(engine, e.ActorID)
+		if err != nil {
+			if !errors.IsUserNotExist(err) {
+				return fmt.Errorf("getUserByID.(Actor) [%d]: %v", e.ActorID, err)
+			}
+			e.Actor = getGhostUser(engine, e.ActorID)
+			e.ActorID = -1
+		}
+	}
+
+	if e.Assignee == nil && e.AssigneeID > 0 {
+		e.Assignee, err = getUserByID(engine, e.AssigneeID)
+		if err != nil {
+			if !errors.IsUserNotExist(err) {
+				return fmt.Errorf("getUserByID.(Assignee) [%d]: %v", e.AssigneeID, err)
+			}
+			e.Assignee = getGhostUser(engine, e.AssigneeID)
+		}
+	}
+
+	if e.RefIssue == nil && e.RefIssueID > 0 {
+		e.RefIssue, err = getRawIssueByID(engine, e.RefIssueID)
+		if err != nil {
+			if !errors.IsIssueNotExist(err) {
+				return fmt.Errorf("getRawIssueByID [%d]: %v", e.RefIssueID, err)
+			}
+			// The referencing issue has gone, nothing to show.
+			e.RefIssueID = 0
+		} else if e.RefIssue.Repo, err = getRepositoryByID(engine, e.RefIssue.RepoID); err != nil {
+			return fmt.Errorf("getRepositoryByID [%d]: %v", e.RefIssue.RepoID, err)
+		} else if e.RefIssue.RepoID != e.RepoID && e.RefIssue.Repo.IsPrivate {
+			// The referencing repository has become private since then.
+			e.RefIssue = nil
+			e.RefIssueID = 0
+		}
+	}
+	return nil
+}
+
+// EventTag returns unique event hash tag for the event.
+func (e *IssueEvent) EventTag() string {
+	return fmt.Sprintf("event-%d", e.ID)
+}
+
+// LabelForegroundColor returns the text color for the label based on its background color.
+func (e *IssueEvent) LabelForegroundColor() template.CSS {
+	return (&Label{Color: e.LabelColor}).ForegroundColor()
+}
+
+func createIssueEvent(e Engine, event *IssueEvent) error {
+	_, err := e.Insert(event)
+	return err
+}
+
+// createLabelEvent records a label is added to or removed from the issue. No event is recorded
+// when doer is nil, e.g. labels are set at the time the issue is created.
+func createLabelEvent(e Engine, doer *User, issue *Issue, label *Label, tp IssueEventType) error {
+	if doer == nil {
+		return nil
+	}
+	return createIssueEvent(e, &IssueEvent{
+		RepoID:     issue.RepoID,
+		IssueID:    issue.ID,
+		Type:       tp,
+		ActorID:    doer.ID,
+		LabelID:    label.ID,
+		LabelName:  label.Name,
+		LabelColor: label.Color,
+	})
+}
+
+// createMilestoneEvent records the issue is added to or removed from the milestone.
+func createMilestoneEvent(e Engine, doer *User, issue *Issue, milestoneID int64, tp IssueEventType) error {
+	m, err := getMilestoneByRepoID(e, issue.RepoID, milestoneID)
+	if err != nil {
+		if IsErrMilestoneNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("getMilestoneByRepoID [%d]: %v", milestoneID, err)
+	}
+
+	return createIssueEvent(e, &IssueEvent{
+		RepoID:        issue.RepoID,
+		IssueID:       issue.ID,
+		Type:          tp,
+		ActorID:       doer.ID,
+		MilestoneID:   m.ID,
+		MilestoneName: m.Name,
+	})
+}
+
+type issueReference struct {
+	Owner string // Empty when the issue is in the same repository.
+	Repo  string
+	Index int64
+}
+
+// findIssueReferences returns issue references found in given content, e.g. "#1" and "owner/repo#1".
+func findIssueReferences(content string) []issueReference {
+	refs := make([]issueReference, 0, 5)
+	for _, m := range markup.IssueNumericPattern.FindAllString(content, -1) {
+		m = strings.TrimLeft(m, " ([")
+		refs = append(refs, issueReference{
+			Index: com.StrTo(m[1:]).MustInt64(),
+		})
+	}
+	for _, m := range markup.CrossReferenceIssueNumericPattern.FindAllString(content, -1) {
+		m = strings.TrimSpace(m)
+		n := strings.IndexByte(m, '/')
+		i := strings.IndexByte(m, '#')
+		refs = append(refs, issueReference{
+			Owner: m[:n],
+			Repo:  m[n+1 : i],
+			Index: com.StrTo(m[i+1:]).MustInt64(),
+		})
+	}
+	return refs
+}
+
+// createIssueReferenceEvents records events for issues referenced in the content of given issue
+// or comment. References to issues in other repositories are only recorded when the issue is
+// in a public repository, so private information is not disclosed to the referenced issue.
+func createIssueReferenceEvents(doer *User, issue *Issue, commentID int64, content string) error {
+	repo, err := GetRepositoryByID(issue.RepoID)
+	if err != nil {
+		return fmt.Errorf("GetRepositoryByID [%d]: %v", issue.RepoID, err)
+	}
+
+	seen := make(map[int64]bool)
+	for _, ref := range findIssueReferences(content) {
+		refRepoID := repo.ID
+		if ref.Owner != "" {
+			if repo.IsPrivate {
+				continue
+			}
+
+			refRepo, err := GetRepositoryByRef(ref.Owner + "/" + ref.Repo)
+			if err != nil {
+				if errors.IsRepoNotExist(err) || errors.IsUserNotExist(err) {
+					continue
+				}
+				return fmt.Errorf("GetRepositoryByRef [%s/%s]: %v", ref.Owner, ref.Repo, err)
+			}
+			refRepoID = refRepo.ID
+		}
+
+		refIssue, err := GetRawIssueByIndex(refRepoID, ref.Index)
+		if err != nil {
+			if errors.IsIssueNotExist(err) {
+				continue
+			}
+			return fmt.Errorf("GetRawIssueByIndex [repo_id: %d, index: %d]: %v", refRepoID, ref.Index, err)
+		}
+
+		if refIssue.ID == issue.ID || seen[refIssue.ID] {
+			continue
+		}
+		seen[refIssue.ID] = true
+
+		if err = createIssueEvent(x, &IssueEvent{
+			RepoID:       refIssue.RepoID,
+			IssueID:      refIssue.ID,
+			Type:         ISSUE_EVENT_REFERENCED,
+			ActorID:      doer.ID,
+			RefIssueID:   issue.ID,
+			RefCommentID: commentID,
+		}); err != nil {
+			return fmt.Errorf("createIssueEvent: %v", err)
+		}
+	}
+	return nil
+}
+
+// getIssueEventsByIssueID returns all events of the issue in chronological order.
+func getIssueEventsByIssueID(e Engine, issueID int64) ([]*IssueEvent, error) {
+	events := make([]*IssueEvent, 0, 10)
+	if err := e.Where("issue_id = ?", issueID).Asc("created_unix", "id").Find(&events); err != nil {
+		return nil, err
+	}
+
+	for i := range events {
+		if err := events[i].loadAttributes(e); err != nil {
+			return nil, err
+		}
+	}
+	return events, nil
+}
+
+// GetIssueEventsByIssueID returns all events of the issue in chronological order.
+func GetIssueEventsByIssueID(issueID int64) ([]*IssueEvent, error) {
+	return getIssueEventsByIssueID(x, issueID)
+}
+
+// TimelineItem is either a comment or an event of an issue.
+type TimelineItem struct {
+	Comment *Comment
+	Event   *IssueEvent
+}
+
+// Timeline returns comments and events of the issue merged in chronological order.
+// Comments and events must be loaded before calling this method.
+func (issue *Issue) Timeline(events []*IssueEvent) []*TimelineItem {
+	items := make([]*TimelineItem, 0, len(issue.Comments)+len(events))
+	i, j := 0, 0
+	for i < len(issue.Comments) || j < len(events) {
+		if j >= len(events) || (i < len(issue.Comments) && issue.Comments[i].CreatedUnix <= events[j].CreatedUnix) {
+			items = append(items, &TimelineItem{Comment: issue.Comments[i]})
+			i++
+		} else {
+			items = append(items, &TimelineItem{Event: events[j]})
+			j++
+		}
+	}
+	return items
+}
@@ -0,0 +1,31 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_findIssueReferences(t *testing.T) {
+	Convey("Find issue references in content", t, func() {
+		testCases := []struct {
+			content string
+			expect  []issueReference
+		}{
+			{"", []issueReference{}},
+			{"no reference", []issueReference{}},
+			{"fixes #1", []issueReference{{Index: 1}}},
+			{"#2 and (#3)", []issueReference{{Index: 2}, {Index: 3}}},
+			{"see gogs/gogs#4", []issueReference{{Owner: "gogs", Repo: "gogs", Index: 4}}},
+			{"abc#5", []issueReference{}},
+		}
+
+		for _, tc := range testCases {
+			So(findIssueReferences(tc.content), ShouldResemble, tc.expect)
+		}
+	})
+}
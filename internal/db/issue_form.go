@@ -0,0 +1,211 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"gogs.io/gogs/internal/db/errors"
+)
+
+// Types of fields in issue forms.
+const (
+	ISSUE_FORM_FIELD_MARKDOWN   = "markdown"
+	ISSUE_FORM_FIELD_INPUT      = "input"
+	ISSUE_FORM_FIELD_TEXTAREA   = "textarea"
+	ISSUE_FORM_FIELD_DROPDOWN   = "dropdown"
+	ISSUE_FORM_FIELD_CHECKBOXES = "checkboxes"
+)
+
+// IssueForm is a structured form in YAML for creating issues, whose field values
+// are composed into the issue content and also stored as structured data.
+type IssueForm struct {
+	Name        string            `yaml:"name"`
+	Description string            `yaml:"description"`
+	Title       string            `yaml:"title"`
+	Body        []*IssueFormField `yaml:"body"`
+}
+
+// IssueFormField is a field of an issue form. Fields of markdown type only show
+// their values as text and take no input.
+type IssueFormField struct {
+	Type       string `yaml:"type"`
+	ID         string `yaml:"id"`
+	Attributes struct {
+		Label       string   `yaml:"label"`
+		Description string   `yaml:"description"`
+		Placeholder string   `yaml:"placeholder"`
+		Value       string   `yaml:"value"`
+		Options     []string `yaml:"options"`
+	} `yaml:"attributes"`
+	Validations struct {
+		Required bool `yaml:"required"`
+	} `yaml:"validations"`
+
+	// Rendered is the rendered HTML of value of markdown type.
+	Rendered string `yaml:"-"`
+}
+
+// IsInput returns true if the field takes input.
+func (f *IssueFormField) IsInput() bool {
+	return f.Type != ISSUE_FORM_FIELD_MARKDOWN
+}
+
+// ParseIssueForm parses and validates an issue form in YAML.
+func ParseIssueForm(data []byte) (*IssueForm, error) {
+	form := new(IssueForm)
+	if err := yaml.Unmarshal(data, form); err != nil {
+		return nil, err
+	} else if form.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+
+	ids := make(map[string]bool, len(form.Body))
+	for i, field := range form.Body {
+		switch field.Type {
+		case ISSUE_FORM_FIELD_MARKDOWN:
+			continue
+		case ISSUE_FORM_FIELD_INPUT, ISSUE_FORM_FIELD_TEXTAREA:
+		case ISSUE_FORM_FIELD_DROPDOWN, ISSUE_FORM_FIELD_CHECKBOXES:
+			if len(field.Attributes.Options) == 0 {
+				return nil, fmt.Errorf("body[%d]: options are required", i)
+			}
+		default:
+			return nil, fmt.Errorf("body[%d]: unknown type %q", i, field.Type)
+		}
+
+		if field.ID == "" {
+			return nil, fmt.Errorf("body[%d]: id is required", i)
+		} else if ids[field.ID] {
+			return nil, fmt.Errorf("body[%d]: duplicated id %q", i, field.ID)
+		} else if field.Attributes.Label == "" {
+			return nil, fmt.Errorf("body[%d]: label is required", i)
+		}
+		ids[field.ID] = true
+	}
+	return form, nil
+}
+
+// IssueFormValue is the value of a field of the issue form that an issue is created from.
+type IssueFormValue struct {
+	ID    string `json:"id"`
+	Type  string `json:"type"`
+	Label string `json:"label"`
+	// Value is the text of input, textarea or the selected option of dropdown.
+	Value string `json:"value,omitempty"`
+	// Values are the checked options of checkboxes.
+	Values []string `json:"values,omitempty"`
+}
+
+// IsEmpty returns true if nothing is given to the field.
+func (v *IssueFormValue) IsEmpty() bool {
+	return strings.TrimSpace(v.Value) == "" && len(v.Values) == 0
+}
+
+// Values collects values of input fields of the form, where get returns all values
+// given to the field of the ID. The label of the first required field that is given
+// nothing is returned as missing.
+func (f *IssueForm) Values(get func(id string) []string) (values []*IssueFormValue, missing string) {
+	for _, field := range f.Body {
+		if !field.IsInput() {
+			continue
+		}
+
+		value := &IssueFormValue{
+			ID:    field.ID,
+			Type:  field.Type,
+			Label: field.Attributes.Label,
+		}
+		given := get(field.ID)
+		switch field.Type {
+		case ISSUE_FORM_FIELD_CHECKBOXES:
+			// Only known options are kept.
+			for _, option := range field.Attributes.Options {
+				for i := range given {
+					if given[i] == option {
+						value.Values = append(value.Values, option)
+						break
+					}
+				}
+			}
+		case ISSUE_FORM_FIELD_DROPDOWN:
+			for _, option := range field.Attributes.Options {
+				if len(given) > 0 && given[0] == option {
+					value.Value = option
+					break
+				}
+			}
+		default:
+			if len(given) > 0 {
+				value.Value = strings.TrimSpace(given[0])
+			}
+		}
+
+		if field.Validations.Required && value.IsEmpty() {
+			return nil, field.Attributes.Label
+		}
+		values = append(values, value)
+	}
+	return values, ""
+}
+
+// RenderIssueFormContent composes values of issue form fields into Markdown content.
+func RenderIssueFormContent(values []*IssueFormValue) string {
+	var buf strings.Builder
+	for _, value := range values {
+		buf.WriteString("### ")
+		buf.WriteString(value.Label)
+		buf.WriteString("\n\n")
+
+		switch {
+		case value.Type == ISSUE_FORM_FIELD_CHECKBOXES && len(value.Values) > 0:
+			for _, v := range value.Values {
+				buf.WriteString("- [x] ")
+				buf.WriteString(v)
+				buf.WriteString("\n")
+			}
+		case value.IsEmpty():
+			buf.WriteString("_No response_\n")
+		default:
+			buf.WriteString(value.Value)
+			buf.WriteString("\n")
+		}
+		buf.WriteString("\n")
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+// IssueFormData is the structured data of the issue form that an issue is created from.
+type IssueFormData struct {
+	ID       int64
+	IssueID  int64 `xorm:"UNIQUE"`
+	FormName string
+	Fields   []*IssueFormValue `xorm:"TEXT JSON"`
+}
+
+// NewIssueFormData saves values of the issue form that the issue is created from.
+func NewIssueFormData(issueID int64, formName string, values []*IssueFormValue) error {
+	_, err := x.Insert(&IssueFormData{
+		IssueID:  issueID,
+		FormName: formName,
+		Fields:   values,
+	})
+	return err
+}
+
+// GetIssueFormData returns the structured data of the issue form that the issue is created from.
+func GetIssueFormData(issueID int64) (*IssueFormData, error) {
+	data := &IssueFormData{IssueID: issueID}
+	has, err := x.Get(data)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		return nil, errors.IssueFormDataNotExist{IssueID: issueID}
+	}
+	return data, nil
+}
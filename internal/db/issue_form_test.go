@@ -0,0 +1,111 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const testIssueForm = `
+name: Bug Report
+title: "[Bug]: "
+body:
+  - type: markdown
+    attributes:
+      value: Thanks for reporting!
+  - type: input
+    id: version
+    attributes:
+      label: Version
+    validations:
+      required: true
+  - type: textarea
+    id: what-happened
+    attributes:
+      label: What happened?
+  - type: dropdown
+    id: database
+    attributes:
+      label: Database
+      options: [MySQL, PostgreSQL, SQLite3]
+  - type: checkboxes
+    id: terms
+    attributes:
+      label: Checklist
+      options:
+        - I have searched existing issues
+        - I have read the documentation
+`
+
+func Test_ParseIssueForm(t *testing.T) {
+	Convey("Parse issue form", t, func() {
+		form, err := ParseIssueForm([]byte(testIssueForm))
+		So(err, ShouldBeNil)
+		So(form.Name, ShouldEqual, "Bug Report")
+		So(form.Title, ShouldEqual, "[Bug]: ")
+		So(form.Body, ShouldHaveLength, 5)
+		So(form.Body[0].IsInput(), ShouldBeFalse)
+		So(form.Body[1].Validations.Required, ShouldBeTrue)
+		So(form.Body[3].Attributes.Options, ShouldResemble, []string{"MySQL", "PostgreSQL", "SQLite3"})
+
+		for _, data := range []string{
+			"body: []",
+			"name: A\nbody:\n  - type: input\n    attributes:\n      label: A",
+			"name: A\nbody:\n  - type: input\n    id: a",
+			"name: A\nbody:\n  - type: dropdown\n    id: a\n    attributes:\n      label: A",
+			"name: A\nbody:\n  - type: unknown\n    id: a\n    attributes:\n      label: A",
+			"name: A\nbody:\n  - type: input\n    id: a\n    attributes:\n      label: A\n  - type: textarea\n    id: a\n    attributes:\n      label: B",
+		} {
+			_, err = ParseIssueForm([]byte(data))
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
+
+func Test_IssueForm_Values(t *testing.T) {
+	Convey("Collect values of issue form", t, func() {
+		form, err := ParseIssueForm([]byte(testIssueForm))
+		So(err, ShouldBeNil)
+
+		given := map[string][]string{
+			"version":  {" 0.12.0 "},
+			"database": {"Oracle"},
+			"terms":    {"I have read the documentation", "Unknown"},
+		}
+		values, missing := form.Values(func(id string) []string {
+			return given[id]
+		})
+		So(missing, ShouldBeEmpty)
+		So(values, ShouldHaveLength, 4)
+		So(values[0].Value, ShouldEqual, "0.12.0")
+		So(values[1].IsEmpty(), ShouldBeTrue)
+		So(values[2].Value, ShouldBeEmpty)
+		So(values[3].Values, ShouldResemble, []string{"I have read the documentation"})
+
+		So(RenderIssueFormContent(values), ShouldEqual, `### Version
+
+0.12.0
+
+### What happened?
+
+_No response_
+
+### Database
+
+_No response_
+
+### Checklist
+
+- [x] I have read the documentation`)
+
+		delete(given, "version")
+		_, missing = form.Values(func(id string) []string {
+			return given[id]
+		})
+		So(missing, ShouldEqual, "Version")
+	})
+}
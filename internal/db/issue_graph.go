@@ -0,0 +1,231 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+
+	"xorm.io/builder"
+)
+
+// IssueGraphNode is an issue or a pull request in the reference graph.
+type IssueGraphNode struct {
+	ID         int64  `json:"id"`
+	Repository string `json:"repository"`
+	Index      int64  `json:"number"`
+	Title      string `json:"title"`
+	State      string `json:"state"`
+	IsPull     bool   `json:"is_pull"`
+	URL        string `json:"html_url"`
+}
+
+// IssueGraphEdge is a reference from the source issue to the target issue, e.g. the
+// source mentions "#1" in its content or comments.
+type IssueGraphEdge struct {
+	Source int64 `json:"source"`
+	Target int64 `json:"target"`
+}
+
+// IssueGraph is the graph of cross references between issues and pull requests.
+type IssueGraph struct {
+	Nodes []*IssueGraphNode `json:"nodes"`
+	Edges []*IssueGraphEdge `json:"edges"`
+}
+
+type IssueGraphOptions struct {
+	// RepoID limits the graph to issues of the repository when it is not zero,
+	// otherwise issues of all repositories of the owner are included.
+	RepoID  int64
+	OwnerID int64
+	// UserID is the user who requests the graph, issues in repositories that the
+	// user has no access to are excluded. Zero means an anonymous user.
+	UserID int64
+}
+
+// GetIssueGraph returns the reference graph of issues and pull requests in given
+// repositories. Issues of other repositories that reference or are referenced by
+// these issues are included as well.
+func GetIssueGraph(opts *IssueGraphOptions) (*IssueGraph, error) {
+	graph := &IssueGraph{
+		Nodes: make([]*IssueGraphNode, 0, 10),
+		Edges: make([]*IssueGraphEdge, 0, 10),
+	}
+
+	var repoIDs []int64
+	if opts.RepoID > 0 {
+		repoIDs = []int64{opts.RepoID}
+	} else {
+		cond, args := repoAccessCond(opts.UserID)
+		if err := x.Table("repository").Where(cond, args...).And("repository.owner_id = ?", opts.OwnerID).
+			Cols("repository.id").Find(&repoIDs); err != nil {
+			return nil, fmt.Errorf("get repositories: %v", err)
+		}
+	}
+	if len(repoIDs) == 0 {
+		return graph, nil
+	}
+
+	issues := make([]*Issue, 0, 10)
+	if err := x.In("repo_id", repoIDs).Asc("repo_id", "`index`").Find(&issues); err != nil {
+		return nil, fmt.Errorf("get issues: %v", err)
+	}
+
+	events := make([]*IssueEvent, 0, 10)
+	if err := x.Where("type = ?", ISSUE_EVENT_REFERENCED).
+		And(builder.Or(
+			builder.In("repo_id", repoIDs),
+			builder.In("ref_issue_id", builder.Select("id").From("issue").Where(builder.In("repo_id", repoIDs))))).
+		Asc("id").Find(&events); err != nil {
+		return nil, fmt.Errorf("get reference events: %v", err)
+	}
+
+	// Load issues of other repositories at ends of references.
+	isInGraph := make(map[int64]bool, len(issues))
+	for _, issue := range issues {
+		isInGraph[issue.ID] = true
+	}
+	externalIDs := make([]int64, 0, 5)
+	for _, e := range events {
+		for _, id := range []int64{e.IssueID, e.RefIssueID} {
+			if !isInGraph[id] {
+				isInGraph[id] = true
+				externalIDs = append(externalIDs, id)
+			}
+		}
+	}
+	if len(externalIDs) > 0 {
+		cond, args := repoAccessCond(opts.UserID)
+		external := make([]*Issue, 0, len(externalIDs))
+		if err := x.In("id", externalIDs).
+			And(builder.In("repo_id", builder.Select("repository.id").From("repository").Where(builder.Expr(cond, args...)))).
+			Asc("id").Find(&external); err != nil {
+			return nil, fmt.Errorf("get referenced issues: %v", err)
+		}
+		issues = append(issues, external...)
+	}
+
+	repos := make(map[int64]*Repository)
+	isNode := make(map[int64]bool, len(issues))
+	for _, issue := range issues {
+		repo, ok := repos[issue.RepoID]
+		if !ok {
+			var err error
+			repo, err = GetRepositoryByID(issue.RepoID)
+			if err != nil {
+				return nil, fmt.Errorf("GetRepositoryByID [%d]: %v", issue.RepoID, err)
+			} else if err = repo.GetOwner(); err != nil {
+				return nil, fmt.Errorf("GetOwner [repo_id: %d]: %v", repo.ID, err)
+			}
+			repos[issue.RepoID] = repo
+		}
+		issue.Repo = repo
+
+		isNode[issue.ID] = true
+		graph.Nodes = append(graph.Nodes, &IssueGraphNode{
+			ID:         issue.ID,
+			Repository: repo.FullName(),
+			Index:      issue.Index,
+			Title:      issue.Title,
+			State:      string(issue.State()),
+			IsPull:     issue.IsPull,
+			URL:        issue.HTMLURL(),
+		})
+	}
+
+	type edgeKey struct{ source, target int64 }
+	seen := make(map[edgeKey]bool, len(events))
+	for _, e := range events {
+		key := edgeKey{source: e.RefIssueID, target: e.IssueID}
+		// Issues may be deleted or in repositories that the user has no access to.
+		if seen[key] || !isNode[key.source] || !isNode[key.target] {
+			continue
+		}
+		seen[key] = true
+		graph.Edges = append(graph.Edges, &IssueGraphEdge{Source: key.source, Target: key.target})
+	}
+	return graph, nil
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string `xml:"source,attr"`
+	Target string `xml:"target,attr"`
+}
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   struct {
+		ID          string        `xml:"id,attr"`
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Nodes       []graphMLNode `xml:"node"`
+		Edges       []graphMLEdge `xml:"edge"`
+	} `xml:"graph"`
+}
+
+// GraphML returns the graph in GraphML format, nodes are identified by "n" followed
+// by IDs of issues and have attributes of the JSON format.
+func (g *IssueGraph) GraphML() ([]byte, error) {
+	doc := &graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "repository", For: "node", Name: "repository", Type: "string"},
+			{ID: "number", For: "node", Name: "number", Type: "long"},
+			{ID: "title", For: "node", Name: "title", Type: "string"},
+			{ID: "state", For: "node", Name: "state", Type: "string"},
+			{ID: "is_pull", For: "node", Name: "is_pull", Type: "boolean"},
+			{ID: "html_url", For: "node", Name: "html_url", Type: "string"},
+		},
+	}
+	doc.Graph.ID = "issues"
+	doc.Graph.EdgeDefault = "directed"
+
+	nodeID := func(id int64) string {
+		return "n" + strconv.FormatInt(id, 10)
+	}
+	doc.Graph.Nodes = make([]graphMLNode, len(g.Nodes))
+	for i, n := range g.Nodes {
+		doc.Graph.Nodes[i] = graphMLNode{
+			ID: nodeID(n.ID),
+			Data: []graphMLData{
+				{Key: "repository", Value: n.Repository},
+				{Key: "number", Value: strconv.FormatInt(n.Index, 10)},
+				{Key: "title", Value: n.Title},
+				{Key: "state", Value: n.State},
+				{Key: "is_pull", Value: strconv.FormatBool(n.IsPull)},
+				{Key: "html_url", Value: n.URL},
+			},
+		}
+	}
+	doc.Graph.Edges = make([]graphMLEdge, len(g.Edges))
+	for i, e := range g.Edges {
+		doc.Graph.Edges[i] = graphMLEdge{Source: nodeID(e.Source), Target: nodeID(e.Target)}
+	}
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
@@ -0,0 +1,34 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_IssueGraph_GraphML(t *testing.T) {
+	Convey("Export issue graph in GraphML format", t, func() {
+		graph := &IssueGraph{
+			Nodes: []*IssueGraphNode{
+				{ID: 1, Repository: "alice/app", Index: 1, Title: "Crash on <start>", State: "open", URL: "http://localhost:3000/alice/app/issues/1"},
+				{ID: 2, Repository: "alice/app", Index: 2, Title: "Fix crash", State: "closed", IsPull: true, URL: "http://localhost:3000/alice/app/pulls/2"},
+			},
+			Edges: []*IssueGraphEdge{
+				{Source: 2, Target: 1},
+			},
+		}
+
+		data, err := graph.GraphML()
+		So(err, ShouldBeNil)
+		So(string(data), ShouldStartWith, `<?xml version="1.0" encoding="UTF-8"?>`)
+		So(string(data), ShouldContainSubstring, `<graph id="issues" edgedefault="directed">`)
+		So(string(data), ShouldContainSubstring, `<node id="n1">`)
+		So(string(data), ShouldContainSubstring, `<data key="title">Crash on &lt;start&gt;</data>`)
+		So(string(data), ShouldContainSubstring, `<data key="is_pull">true</data>`)
+		So(string(data), ShouldContainSubstring, `<edge source="n2" target="n1"></edge>`)
+	})
+}
@@ -0,0 +1,149 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"xorm.io/xorm"
+)
+
+// IssueHistoryType is the type of change recorded in issue history.
+type IssueHistoryType int
+
+const (
+	ISSUE_HISTORY_MILESTONE_ADD    IssueHistoryType = iota + 1 // Issue is added to the milestone.
+	ISSUE_HISTORY_MILESTONE_REMOVE                             // Issue is removed from the milestone.
+	ISSUE_HISTORY_CLOSE                                        // Issue is closed while in the milestone.
+	ISSUE_HISTORY_REOPEN                                       // Issue is reopened while in the milestone.
+)
+
+// IssueHistory represents a change of issue state that affects progress of a milestone.
+type IssueHistory struct {
+	ID          int64
+	RepoID      int64 `xorm:"INDEX"`
+	IssueID     int64 `xorm:"INDEX"`
+	MilestoneID int64 `xorm:"INDEX"`
+	IsPull      bool
+	IsClosed    bool // Whether the issue is closed after the change.
+	Type        IssueHistoryType
+
+	Created     time.Time `xorm:"-" json:"-"`
+	CreatedUnix int64     `xorm:"INDEX"`
+}
+
+func (h *IssueHistory) BeforeInsert() {
+	if h.CreatedUnix == 0 {
+		h.CreatedUnix = time.Now().Unix()
+	}
+}
+
+func (h *IssueHistory) AfterSet(colName string, _ xorm.Cell) {
+	switch colName {
+	case "created_unix":
+		h.Created = time.Unix(h.CreatedUnix, 0).Local()
+	}
+}
+
+// createIssueHistory records a change of given type for the issue within the milestone.
+func createIssueHistory(e Engine, issue *Issue, milestoneID int64, tp IssueHistoryType) error {
+	if issue.ID == 0 || milestoneID <= 0 {
+		return nil
+	}
+
+	_, err := e.Insert(&IssueHistory{
+		RepoID:      issue.RepoID,
+		IssueID:     issue.ID,
+		MilestoneID: milestoneID,
+		IsPull:      issue.IsPull,
+		IsClosed:    issue.IsClosed,
+		Type:        tp,
+	})
+	return err
+}
+
+// MilestoneProgress represents numbers of open and closed issues of a milestone at the end of a day.
+type MilestoneProgress struct {
+	Date   time.Time `json:"date"`
+	Open   int       `json:"open"`
+	Closed int       `json:"closed"`
+}
+
+// truncateDay returns the start of the day of given time in local time zone.
+func truncateDay(t time.Time) time.Time {
+	t = t.Local()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// Progress returns daily numbers of open and closed issues of the milestone since
+// the first issue was added, until the milestone is closed or today.
+func (m *Milestone) Progress(includePulls bool) ([]*MilestoneProgress, error) {
+	histories := make([]*IssueHistory, 0, m.NumIssues)
+	sess := x.Where("milestone_id = ?", m.ID)
+	if !includePulls {
+		sess.And("is_pull = ?", false)
+	}
+	if err := sess.Asc("created_unix", "id").Find(&histories); err != nil {
+		return nil, fmt.Errorf("find histories: %v", err)
+	} else if len(histories) == 0 {
+		return []*MilestoneProgress{}, nil
+	}
+
+	end := truncateDay(time.Now())
+	if m.IsClosed && m.ClosedDateUnix > 0 {
+		end = truncateDay(m.ClosedDate)
+	}
+
+	type state struct {
+		inMilestone bool
+		isClosed    bool
+	}
+	states := make(map[int64]*state)
+	var open, closed int
+	progress := make([]*MilestoneProgress, 0, 30)
+	day := truncateDay(histories[0].Created)
+	for i := 0; !day.After(end); day = day.AddDate(0, 0, 1) {
+		next := day.AddDate(0, 0, 1)
+		for ; i < len(histories) && histories[i].Created.Before(next); i++ {
+			h := histories[i]
+			s, ok := states[h.IssueID]
+			if !ok {
+				s = new(state)
+				states[h.IssueID] = s
+			}
+
+			// Withdraw previous contribution of the issue before applying the change.
+			if s.inMilestone {
+				if s.isClosed {
+					closed--
+				} else {
+					open--
+				}
+			}
+			switch h.Type {
+			case ISSUE_HISTORY_MILESTONE_ADD:
+				s.inMilestone = true
+			case ISSUE_HISTORY_MILESTONE_REMOVE:
+				s.inMilestone = false
+			}
+			s.isClosed = h.IsClosed
+			if s.inMilestone {
+				if s.isClosed {
+					closed++
+				} else {
+					open++
+				}
+			}
+		}
+
+		progress = append(progress, &MilestoneProgress{
+			Date:   day,
+			Open:   open,
+			Closed: closed,
+		})
+	}
+	return progress, nil
+}
@@ -0,0 +1,235 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/unknwon/com"
+	log "unknwon.dev/clog/v2"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/sync"
+)
+
+// maxIssueSearchResults is the max number of issues returned by a keyword search,
+// the most relevant issues are kept when there are more matches.
+const maxIssueSearchResults = 1000
+
+// IssueIndexerQueue is a queue of IDs of issues to be updated in an external issue
+// index.
+var IssueIndexerQueue = sync.NewUniqueQueue(1000)
+
+// issueIndexer is a backend of keyword search of issues.
+type issueIndexer interface {
+	// index adds or updates issues in the index.
+	index(issues []*Issue) error
+	// deleteRepo removes all issues of the repository from the index.
+	deleteRepo(repoID int64) error
+	// search returns IDs of at most limit issues in the repositories that contain all
+	// words of the keyword in their titles or contents, most relevant first.
+	search(repoIDs []int64, keyword string, limit int) ([]int64, error)
+}
+
+var (
+	// issueIdx is the backend of issue search, it matches substrings in the database
+	// until the indexer is initialized.
+	issueIdx issueIndexer = &databaseIssueIndexer{}
+	// isIssueIndexExternal is true if the index is kept outside of the database, and
+	// has to be updated when issues are changed.
+	isIssueIndexExternal bool
+)
+
+// SearchIssueIDs returns IDs of issues in the repositories that match the keyword,
+// most relevant first.
+func SearchIssueIDs(repoIDs []int64, keyword string) ([]int64, error) {
+	keyword = strings.TrimSpace(keyword)
+	if len(repoIDs) == 0 || keyword == "" {
+		return []int64{}, nil
+	}
+	return issueIdx.search(repoIDs, keyword, maxIssueSearchResults)
+}
+
+// AddIssueIndexerTask queues the issue to be updated in the index, it does nothing
+// when the index is kept in the database.
+func AddIssueIndexerTask(issueID int64) {
+	if !isIssueIndexExternal {
+		return
+	}
+	IssueIndexerQueue.Add(issueID)
+}
+
+// deleteRepoIssueIndex removes all issues of the repository from the index.
+func deleteRepoIssueIndex(repoID int64) {
+	if !isIssueIndexExternal {
+		return
+	}
+	if err := issueIdx.deleteRepo(repoID); err != nil {
+		log.Error("Failed to delete issue index [repo_id: %d]: %v", repoID, err)
+	}
+}
+
+// RunIssueIndexer updates queued issues in the index.
+func RunIssueIndexer() {
+	for issueID := range IssueIndexerQueue.Queue() {
+		log.Trace("RunIssueIndexer [issue_id: %s]", issueID)
+		IssueIndexerQueue.Remove(issueID)
+
+		issue, err := getRawIssueByID(x, com.StrTo(issueID).MustInt64())
+		if err != nil {
+			log.Error("getRawIssueByID [%s]: %v", issueID, err)
+		} else if err = issueIdx.index([]*Issue{issue}); err != nil {
+			log.Error("Failed to update issue index [issue_id: %d]: %v", issue.ID, err)
+		}
+	}
+}
+
+// populateIssueIndex adds all existing issues to a newly created index.
+func populateIssueIndex() {
+	const batchSize = 100
+	var lastID int64
+	for {
+		issues := make([]*Issue, 0, batchSize)
+		if err := x.Where("id > ?", lastID).Asc("id").Limit(batchSize).Find(&issues); err != nil {
+			log.Error("Failed to get issues for issue indexer: %v", err)
+			return
+		} else if len(issues) == 0 {
+			break
+		}
+
+		if err := issueIdx.index(issues); err != nil {
+			log.Error("Failed to populate issue index: %v", err)
+			return
+		}
+		lastID = issues[len(issues)-1].ID
+	}
+	log.Info("Issue index has been populated")
+}
+
+// InitIssueIndexer initializes the backend of issue search and starts updating
+// queued issues in the index.
+func InitIssueIndexer() {
+	switch conf.Indexer.IssueIndexerType {
+	case "postgres":
+		if !conf.UsePostgreSQL {
+			log.Fatal("Issue indexer type %q requires PostgreSQL as the database", conf.Indexer.IssueIndexerType)
+		}
+		idx, err := newPostgresIssueIndexer()
+		if err != nil {
+			log.Fatal("Failed to initialize PostgreSQL issue indexer: %v", err)
+		}
+		issueIdx = idx
+	case "elasticsearch":
+		idx, created, err := newElasticsearchIssueIndexer(conf.Indexer.IssueIndexerElasticsearchURL, conf.Indexer.IssueIndexerElasticsearchIndex)
+		if err != nil {
+			log.Fatal("Failed to initialize Elasticsearch issue indexer: %v", err)
+		}
+		issueIdx = idx
+		isIssueIndexExternal = true
+
+		go RunIssueIndexer()
+		if created {
+			go populateIssueIndex()
+		}
+	default:
+		issueIdx = &databaseIssueIndexer{}
+	}
+}
+
+type SearchIssuesOptions struct {
+	Keyword string
+	// UserID is the user who searches, only issues in repositories that the user has
+	// access to are searched. Zero means an anonymous user.
+	UserID   int64
+	Page     int
+	PageSize int
+	// HideConfidential excludes confidential issues that the user cannot see.
+	HideConfidential bool
+}
+
+// visibleIssueIDs returns IDs of issues in given IDs that are visible to the user, in
+// the same order.
+func visibleIssueIDs(userID int64, issueIDs []int64) ([]int64, error) {
+	if len(issueIDs) == 0 {
+		return issueIDs, nil
+	}
+
+	cond, args := confidentialIssuesCond(userID)
+	found := make([]int64, 0, len(issueIDs))
+	if err := x.Table("issue").In("issue.id", issueIDs).And(cond, args...).Cols("issue.id").Find(&found); err != nil {
+		return nil, err
+	}
+	isVisible := make(map[int64]bool, len(found))
+	for _, id := range found {
+		isVisible[id] = true
+	}
+
+	visibleIDs := make([]int64, 0, len(found))
+	for _, id := range issueIDs {
+		if isVisible[id] {
+			visibleIDs = append(visibleIDs, id)
+		}
+	}
+	return visibleIDs, nil
+}
+
+// SearchIssues returns issues and pull requests in repositories that the user has
+// access to and match the keyword, most relevant first, and the number of matched
+// issues.
+func SearchIssues(opts *SearchIssuesOptions) ([]*Issue, int64, error) {
+	if opts.Page <= 0 {
+		opts.Page = 1
+	}
+
+	repoIDs, err := accessibleRepoIDs(opts.UserID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("accessibleRepoIDs: %v", err)
+	}
+	issueIDs, err := SearchIssueIDs(repoIDs, opts.Keyword)
+	if err != nil {
+		return nil, 0, fmt.Errorf("SearchIssueIDs: %v", err)
+	}
+	if opts.HideConfidential {
+		issueIDs, err = visibleIssueIDs(opts.UserID, issueIDs)
+		if err != nil {
+			return nil, 0, fmt.Errorf("visibleIssueIDs: %v", err)
+		}
+	}
+
+	total := int64(len(issueIDs))
+	start := (opts.Page - 1) * opts.PageSize
+	if start >= len(issueIDs) {
+		return []*Issue{}, total, nil
+	}
+	end := start + opts.PageSize
+	if end > len(issueIDs) {
+		end = len(issueIDs)
+	}
+	issueIDs = issueIDs[start:end]
+
+	found := make([]*Issue, 0, len(issueIDs))
+	if err = x.In("id", issueIDs).Find(&found); err != nil {
+		return nil, 0, fmt.Errorf("find issues: %v", err)
+	}
+	byID := make(map[int64]*Issue, len(found))
+	for _, issue := range found {
+		byID[issue.ID] = issue
+	}
+
+	// Keep the order of relevance, issues may be deleted since they are indexed.
+	issues := make([]*Issue, 0, len(found))
+	for _, id := range issueIDs {
+		issue, ok := byID[id]
+		if !ok {
+			continue
+		}
+		if err = issue.LoadAttributes(); err != nil {
+			return nil, 0, fmt.Errorf("LoadAttributes [%d]: %v", issue.ID, err)
+		}
+		issues = append(issues, issue)
+	}
+	return issues, total, nil
+}
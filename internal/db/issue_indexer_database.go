@@ -0,0 +1,77 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"strings"
+)
+
+var (
+	_ issueIndexer = (*databaseIssueIndexer)(nil)
+	_ issueIndexer = (*postgresIssueIndexer)(nil)
+)
+
+// databaseIssueIndexer searches issues by substrings of their titles and contents in
+// the database, it needs no index but does not scale to a large number of issues.
+type databaseIssueIndexer struct{}
+
+func (*databaseIssueIndexer) index([]*Issue) error {
+	return nil
+}
+
+func (*databaseIssueIndexer) deleteRepo(int64) error {
+	return nil
+}
+
+func (*databaseIssueIndexer) search(repoIDs []int64, keyword string, limit int) ([]int64, error) {
+	sess := x.Table("issue").Cols("id").In("repo_id", repoIDs)
+	for _, term := range strings.Fields(strings.ToLower(keyword)) {
+		sess.And("(LOWER(name) LIKE ? OR LOWER(content) LIKE ?)", "%"+term+"%", "%"+term+"%")
+	}
+
+	issueIDs := make([]int64, 0, 10)
+	return issueIDs, sess.Desc("id").Limit(limit).Find(&issueIDs)
+}
+
+// postgresIssueSearchVector is the text search vector of issues, the same expression
+// is used by the index and queries so that the index is used.
+const postgresIssueSearchVector = `to_tsvector('simple', coalesce(name, '') || ' ' || coalesce(content, ''))`
+
+// postgresIssueIndexer searches issues with full text search of PostgreSQL, which
+// is backed by a GIN index on the table of issues and updated by the database.
+type postgresIssueIndexer struct{}
+
+// newPostgresIssueIndexer returns an issue indexer of PostgreSQL, the index is
+// created if not exists.
+func newPostgresIssueIndexer() (*postgresIssueIndexer, error) {
+	if _, err := x.Exec("CREATE INDEX IF NOT EXISTS IDX_issue_search_vector ON issue USING GIN (" + postgresIssueSearchVector + ")"); err != nil {
+		return nil, fmt.Errorf("create index: %v", err)
+	}
+	return &postgresIssueIndexer{}, nil
+}
+
+func (*postgresIssueIndexer) index([]*Issue) error {
+	return nil
+}
+
+func (*postgresIssueIndexer) deleteRepo(int64) error {
+	return nil
+}
+
+func (*postgresIssueIndexer) search(repoIDs []int64, keyword string, limit int) ([]int64, error) {
+	args := make([]interface{}, 0, len(repoIDs)+3)
+	for _, repoID := range repoIDs {
+		args = append(args, repoID)
+	}
+	args = append(args, keyword, keyword, limit)
+
+	issueIDs := make([]int64, 0, 10)
+	return issueIDs, x.SQL(`SELECT id FROM issue
+WHERE repo_id IN (`+strings.TrimSuffix(strings.Repeat("?,", len(repoIDs)), ",")+`)
+AND `+postgresIssueSearchVector+` @@ plainto_tsquery('simple', ?)
+ORDER BY ts_rank(`+postgresIssueSearchVector+`, plainto_tsquery('simple', ?)) DESC, id DESC
+LIMIT ?`, args...).Find(&issueIDs)
+}
@@ -0,0 +1,112 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+)
+
+var _ issueIndexer = (*elasticsearchIssueIndexer)(nil)
+
+// elasticsearchIssueMapping is the mappings of the index, documents are identified
+// by IDs of issues.
+const elasticsearchIssueMapping = `{
+	"mappings": {
+		"properties": {
+			"repo_id": {"type": "long"},
+			"title": {"type": "text"},
+			"content": {"type": "text"}
+		}
+	}
+}`
+
+// elasticsearchIssueIndexer keeps the issue index in an external Elasticsearch server.
+type elasticsearchIssueIndexer struct {
+	*elasticsearchClient
+}
+
+// newElasticsearchIssueIndexer returns an issue indexer of the index in the server,
+// the index is created if not exists. It returns true if the index is created.
+func newElasticsearchIssueIndexer(url, index string) (*elasticsearchIssueIndexer, bool, error) {
+	client, created, err := newElasticsearchClient(url, index, elasticsearchIssueMapping)
+	if err != nil {
+		return nil, false, err
+	}
+	return &elasticsearchIssueIndexer{client}, created, nil
+}
+
+func (idx *elasticsearchIssueIndexer) index(issues []*Issue) error {
+	if len(issues) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, issue := range issues {
+		if err := enc.Encode(map[string]interface{}{
+			"index": map[string]string{"_index": idx.indexName, "_id": strconv.FormatInt(issue.ID, 10)},
+		}); err != nil {
+			return err
+		} else if err = enc.Encode(map[string]interface{}{
+			"repo_id": issue.RepoID,
+			"title":   issue.Title,
+			"content": issue.Content,
+		}); err != nil {
+			return err
+		}
+	}
+	return idx.bulk(&buf)
+}
+
+func (idx *elasticsearchIssueIndexer) deleteRepo(repoID int64) error {
+	return idx.deleteByRepo(repoID)
+}
+
+func (idx *elasticsearchIssueIndexer) search(repoIDs []int64, keyword string, limit int) ([]int64, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"size":    limit,
+		"_source": false,
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must": map[string]interface{}{
+					"multi_match": map[string]interface{}{
+						"query":    keyword,
+						"fields":   []string{"title^2", "content"},
+						"operator": "and",
+					},
+				},
+				"filter": map[string]interface{}{
+					"terms": map[string]interface{}{"repo_id": repoIDs},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Hits struct {
+			Hits []struct {
+				ID string `json:"_id"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err = idx.request("POST", "/"+idx.indexName+"/_search", "application/json", bytes.NewReader(body), &result); err != nil {
+		return nil, err
+	}
+
+	issueIDs := make([]int64, 0, len(result.Hits.Hits))
+	for _, hit := range result.Hits.Hits {
+		id, err := strconv.ParseInt(hit.ID, 10, 64)
+		if err != nil {
+			continue
+		}
+		issueIDs = append(issueIDs, id)
+	}
+	return issueIDs, nil
+}
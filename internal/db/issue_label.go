@@ -254,7 +254,7 @@ func HasIssueLabel(issueID, labelID int64) bool {
 	return hasIssueLabel(x, issueID, labelID)
 }
 
-func newIssueLabel(e *xorm.Session, issue *Issue, label *Label) (err error) {
+func newIssueLabel(e *xorm.Session, doer *User, issue *Issue, label *Label) (err error) {
 	if _, err = e.Insert(&IssueLabel{
 		IssueID: issue.ID,
 		LabelID: label.ID,
@@ -269,6 +269,8 @@ func newIssueLabel(e *xorm.Session, issue *Issue, label *Label) (err error) {
 
 	if err = updateLabel(e, label); err != nil {
 		return fmt.Errorf("updateLabel: %v", err)
+	} else if err = createLabelEvent(e, doer, issue, label, ISSUE_EVENT_LABELED); err != nil {
+		return fmt.Errorf("createLabelEvent: %v", err)
 	}
 
 	issue.Labels = append(issue.Labels, label)
@@ -276,7 +278,7 @@ func newIssueLabel(e *xorm.Session, issue *Issue, label *Label) (err error) {
 }
 
 // NewIssueLabel creates a new issue-label relation.
-func NewIssueLabel(issue *Issue, label *Label) (err error) {
+func NewIssueLabel(doer *User, issue *Issue, label *Label) (err error) {
 	if HasIssueLabel(issue.ID, label.ID) {
 		return nil
 	}
@@ -287,20 +289,20 @@ func NewIssueLabel(issue *Issue, label *Label) (err error) {
 		return err
 	}
 
-	if err = newIssueLabel(sess, issue, label); err != nil {
+	if err = newIssueLabel(sess, doer, issue, label); err != nil {
 		return err
 	}
 
 	return sess.Commit()
 }
 
-func newIssueLabels(e *xorm.Session, issue *Issue, labels []*Label) (err error) {
+func newIssueLabels(e *xorm.Session, doer *User, issue *Issue, labels []*Label) (err error) {
 	for i := range labels {
 		if hasIssueLabel(e, issue.ID, labels[i].ID) {
 			continue
 		}
 
-		if err = newIssueLabel(e, issue, labels[i]); err != nil {
+		if err = newIssueLabel(e, doer, issue, labels[i]); err != nil {
 			return fmt.Errorf("newIssueLabel: %v", err)
 		}
 	}
@@ -309,14 +311,14 @@ func newIssueLabels(e *xorm.Session, issue *Issue, labels []*Label) (err error)
 }
 
 // NewIssueLabels creates a list of issue-label relations.
-func NewIssueLabels(issue *Issue, labels []*Label) (err error) {
+func NewIssueLabels(doer *User, issue *Issue, labels []*Label) (err error) {
 	sess := x.NewSession()
 	defer sess.Close()
 	if err = sess.Begin(); err != nil {
 		return err
 	}
 
-	if err = newIssueLabels(sess, issue, labels); err != nil {
+	if err = newIssueLabels(sess, doer, issue, labels); err != nil {
 		return err
 	}
 
@@ -333,7 +335,7 @@ func GetIssueLabels(issueID int64) ([]*IssueLabel, error) {
 	return getIssueLabels(x, issueID)
 }
 
-func deleteIssueLabel(e *xorm.Session, issue *Issue, label *Label) (err error) {
+func deleteIssueLabel(e *xorm.Session, doer *User, issue *Issue, label *Label) (err error) {
 	if _, err = e.Delete(&IssueLabel{
 		IssueID: issue.ID,
 		LabelID: label.ID,
@@ -347,6 +349,8 @@ func deleteIssueLabel(e *xorm.Session, issue *Issue, label *Label) (err error) {
 	}
 	if err = updateLabel(e, label); err != nil {
 		return fmt.Errorf("updateLabel: %v", err)
+	} else if err = createLabelEvent(e, doer, issue, label, ISSUE_EVENT_UNLABELED); err != nil {
+		return fmt.Errorf("createLabelEvent: %v", err)
 	}
 
 	for i := range issue.Labels {
@@ -359,14 +363,14 @@ func deleteIssueLabel(e *xorm.Session, issue *Issue, label *Label) (err error) {
 }
 
 // DeleteIssueLabel deletes issue-label relation.
-func DeleteIssueLabel(issue *Issue, label *Label) (err error) {
+func DeleteIssueLabel(doer *User, issue *Issue, label *Label) (err error) {
 	sess := x.NewSession()
 	defer sess.Close()
 	if err = sess.Begin(); err != nil {
 		return err
 	}
 
-	if err = deleteIssueLabel(sess, issue, label); err != nil {
+	if err = deleteIssueLabel(sess, doer, issue, label); err != nil {
 		return err
 	}
 
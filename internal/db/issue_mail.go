@@ -11,6 +11,7 @@ import (
 	log "unknwon.dev/clog/v2"
 
 	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/db/errors"
 	"gogs.io/gogs/internal/email"
 	"gogs.io/gogs/internal/markup"
 )
@@ -92,75 +93,76 @@ func NewMailerIssue(issue *Issue) email.Issue {
 
 // mailIssueCommentToParticipants can be used for both new issue creation and comment.
 // This functions sends two list of emails:
-// 1. Repository watchers, users who participated in comments and the assignee.
+// 1. Subscribers of the issue, i.e. watchers, participants and subscribed users.
 // 2. Users who are not in 1. but get mentioned in current issue/comment.
 func mailIssueCommentToParticipants(issue *Issue, doer *User, mentions []string) error {
 	if !conf.User.EnableEmailNotification {
 		return nil
 	}
 
-	watchers, err := GetWatchers(issue.RepoID)
+	subscriberIDs, err := getIssueSubscriberIDs(x, issue)
 	if err != nil {
-		return fmt.Errorf("GetWatchers [repo_id: %d]: %v", issue.RepoID, err)
-	}
-	participants, err := GetParticipantsByIssueID(issue.ID)
-	if err != nil {
-		return fmt.Errorf("GetParticipantsByIssueID [issue_id: %d]: %v", issue.ID, err)
+		return fmt.Errorf("getIssueSubscriberIDs [issue_id: %d]: %v", issue.ID, err)
 	}
 
-	// In case the issue poster is not watching the repository,
-	// even if we have duplicated in watchers, can be safely filtered out.
-	if issue.PosterID != doer.ID {
-		participants = append(participants, issue.Poster)
-	}
-
-	tos := make([]string, 0, len(watchers)) // List of email addresses
-	names := make([]string, 0, len(watchers))
-	for i := range watchers {
-		if watchers[i].UserID == doer.ID {
+	tos := make([]string, 0, len(subscriberIDs)) // List of email addresses
+	names := make([]string, 0, len(subscriberIDs))
+	for _, id := range subscriberIDs {
+		if id == doer.ID {
 			continue
 		}
 
-		to, err := GetUserByID(watchers[i].UserID)
+		to, err := GetUserByID(id)
 		if err != nil {
-			return fmt.Errorf("GetUserByID [%d]: %v", watchers[i].UserID, err)
+			// Users who participated in comments may have been deleted.
+			if errors.IsUserNotExist(err) {
+				continue
+			}
+			return fmt.Errorf("GetUserByID [%d]: %v", id, err)
 		}
-		if to.IsOrganization() || !to.IsActive {
+		if to.IsOrganization() || !to.IsMailable() {
 			continue
 		}
-
-		tos = append(tos, to.Email)
-		names = append(names, to.Name)
-	}
-	for i := range participants {
-		if participants[i].ID == doer.ID {
+		// Subscribers with batched notification emails are notified by digests.
+		if to.NotificationEmailMode != NOTIFICATION_EMAIL_IMMEDIATE {
 			continue
-		} else if com.IsSliceContainsStr(names, participants[i].Name) {
+		}
+		if visible, err := issue.isVisibleTo(x, to.ID); err != nil {
+			return fmt.Errorf("isVisibleTo [user_id: %d]: %v", to.ID, err)
+		} else if !visible {
 			continue
 		}
 
-		tos = append(tos, participants[i].Email)
-		names = append(names, participants[i].Name)
-	}
-	if issue.Assignee != nil && issue.Assignee.ID != doer.ID {
-		if !com.IsSliceContainsStr(names, issue.Assignee.Name) {
-			tos = append(tos, issue.Assignee.Email)
-			names = append(names, issue.Assignee.Name)
-		}
+		tos = append(tos, to.Email)
+		names = append(names, to.Name)
 	}
 	email.SendIssueCommentMail(NewMailerIssue(issue), NewMailerRepo(issue.Repo), NewMailerUser(doer), tos)
 
-	// Mail mentioned people and exclude watchers.
+	// Mail mentioned people and exclude subscribers.
 	names = append(names, doer.Name)
-	tos = make([]string, 0, len(mentions)) // list of user names.
+	tos = make([]string, 0, len(mentions)) // list of email addresses.
 	for i := range mentions {
 		if com.IsSliceContainsStr(names, mentions[i]) {
 			continue
 		}
 
-		tos = append(tos, mentions[i])
+		to, err := GetUserByName(mentions[i])
+		if err != nil || !to.IsMailable() || to.NotificationEmailMode != NOTIFICATION_EMAIL_IMMEDIATE {
+			continue
+		}
+		if ignored, err := isIgnoringIssue(x, to.ID, issue); err != nil {
+			return fmt.Errorf("isIgnoringIssue [user_id: %d]: %v", to.ID, err)
+		} else if ignored {
+			continue
+		}
+		if visible, err := issue.isVisibleTo(x, to.ID); err != nil {
+			return fmt.Errorf("isVisibleTo [user_id: %d]: %v", to.ID, err)
+		} else if !visible {
+			continue
+		}
+		tos = append(tos, to.Email)
 	}
-	email.SendIssueMentionMail(NewMailerIssue(issue), NewMailerRepo(issue.Repo), NewMailerUser(doer), GetUserEmailsByNames(tos))
+	email.SendIssueMentionMail(NewMailerIssue(issue), NewMailerRepo(issue.Repo), NewMailerUser(doer), tos)
 	return nil
 }
 
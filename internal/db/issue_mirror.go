@@ -0,0 +1,621 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/unknwon/com"
+	log "unknwon.dev/clog/v2"
+	"xorm.io/xorm"
+
+	"gogs.io/gogs/internal/db/errors"
+	"gogs.io/gogs/internal/sync"
+)
+
+// IssueMirrorQueue is the queue of IDs of issue mirrors to sync.
+var IssueMirrorQueue = sync.NewUniqueQueue(1000)
+
+// Services that issues can be mirrored with, Gitea is compatible with Gogs.
+const (
+	ISSUE_MIRROR_SERVICE_GOGS   = "gogs"
+	ISSUE_MIRROR_SERVICE_GITHUB = "github"
+)
+
+// IssueMirror represents a remote repository that issues and comments of a repository
+// are synced with in both directions. New issues, new comments and open/closed states
+// are synced, and the remote side wins when a state is changed on both sides.
+type IssueMirror struct {
+	ID      int64
+	RepoID  int64 `xorm:"UNIQUE"`
+	DoerID  int64 // The user who posts issues and comments pulled from the remote
+	Service string
+	// RemoteAddr is the web URL of the remote repository.
+	RemoteAddr string `xorm:"TEXT"`
+	APIURL     string `xorm:"TEXT"`
+	Token      string `xorm:"TEXT" json:"-"`
+	Interval   int    // Hour, only syncs on demand when it is zero.
+
+	Created      time.Time `xorm:"-" json:"-"`
+	CreatedUnix  int64
+	LastSync     time.Time `xorm:"-" json:"-"`
+	LastSyncUnix int64
+	NextSyncUnix int64 `xorm:"INDEX"`
+	// Error of the last sync, it is empty when the last sync succeeded.
+	LastError string `xorm:"TEXT"`
+}
+
+func (m *IssueMirror) BeforeInsert() {
+	m.CreatedUnix = time.Now().Unix()
+}
+
+func (m *IssueMirror) AfterSet(colName string, _ xorm.Cell) {
+	switch colName {
+	case "created_unix":
+		m.Created = time.Unix(m.CreatedUnix, 0).Local()
+	case "last_sync_unix":
+		m.LastSync = time.Unix(m.LastSyncUnix, 0).Local()
+	}
+}
+
+// HasSynced returns true if the issue mirror has been synced at least once.
+func (m *IssueMirror) HasSynced() bool {
+	return m.LastSyncUnix > 0
+}
+
+// scheduleNextSync sets next sync time based on the interval, it is zero when
+// the issue mirror does not sync periodically.
+func (m *IssueMirror) scheduleNextSync() {
+	if m.Interval > 0 {
+		m.NextSyncUnix = time.Now().Add(time.Duration(m.Interval) * time.Hour).Unix()
+	} else {
+		m.NextSyncUnix = 0
+	}
+}
+
+// siteURL returns the URL of the remote site, which is the web URL of the remote
+// repository without owner and repository names.
+func (m *IssueMirror) siteURL() string {
+	addr := m.RemoteAddr
+	for i := 0; i < 2; i++ {
+		addr = addr[:strings.LastIndex(addr, "/")]
+	}
+	return addr
+}
+
+// IssueMirrorIssue links a local issue to the remote issue it is synced with.
+type IssueMirrorIssue struct {
+	ID           int64
+	RepoID       int64 `xorm:"UNIQUE(s)"`
+	IssueID      int64 `xorm:"UNIQUE"`
+	RemoteNumber int64 `xorm:"UNIQUE(s)"`
+	// IsClosed is the state of both sides at the last sync.
+	IsClosed bool `xorm:"NOT NULL DEFAULT false"`
+}
+
+// IssueMirrorComment links a local comment to the remote comment it is synced with.
+type IssueMirrorComment struct {
+	ID        int64
+	RepoID    int64 `xorm:"INDEX"`
+	IssueID   int64 `xorm:"INDEX"`
+	CommentID int64 `xorm:"UNIQUE"`
+	RemoteID  int64
+}
+
+// issueMirrorURLs returns the API URL and the normalized web URL of the remote repository
+// at given address.
+func issueMirrorURLs(service, remoteAddr string) (apiURL, htmlURL string, _ error) {
+	u, err := url.Parse(strings.TrimSpace(remoteAddr))
+	if err != nil {
+		return "", "", err
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		return "", "", fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+
+	repoPath := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+	fields := strings.Split(repoPath, "/")
+	if len(fields) < 2 || fields[len(fields)-2] == "" || fields[len(fields)-1] == "" {
+		return "", "", fmt.Errorf("invalid repository path %q", repoPath)
+	}
+	htmlURL = fmt.Sprintf("%s://%s/%s", u.Scheme, u.Host, repoPath)
+
+	switch service {
+	case ISSUE_MIRROR_SERVICE_GOGS:
+		// Gogs may be served under a subpath, which is followed by owner and repository names.
+		prefix := strings.Join(fields[:len(fields)-2], "/")
+		if prefix != "" {
+			prefix = "/" + prefix
+		}
+		apiURL = fmt.Sprintf("%s://%s%s/api/v1/repos/%s/%s", u.Scheme, u.Host, prefix, fields[len(fields)-2], fields[len(fields)-1])
+	case ISSUE_MIRROR_SERVICE_GITHUB:
+		if apiURL, err = migrationAPIURL(MIGRATION_SERVICE_GITHUB, htmlURL); err != nil {
+			return "", "", err
+		}
+	default:
+		return "", "", fmt.Errorf("unsupported service %q", service)
+	}
+	return apiURL, htmlURL, nil
+}
+
+// IssueMirrorOptions contains options of creating an issue mirror.
+type IssueMirrorOptions struct {
+	Service    string
+	RemoteAddr string
+	Token      string
+	Interval   int
+}
+
+// CreateIssueMirror creates a new issue mirror of the repository, issues and comments
+// created by the doer are posted to the remote by the owner of the token.
+func CreateIssueMirror(doer *User, repo *Repository, opts IssueMirrorOptions) (*IssueMirror, error) {
+	apiURL, htmlURL, err := issueMirrorURLs(opts.Service, opts.RemoteAddr)
+	if err != nil {
+		log.Trace("Invalid issue mirror address %q: %v", opts.RemoteAddr, err)
+		return nil, ErrInvalidCloneAddr{IsURLError: true}
+	}
+
+	m := &IssueMirror{
+		RepoID:     repo.ID,
+		DoerID:     doer.ID,
+		Service:    opts.Service,
+		RemoteAddr: htmlURL,
+		APIURL:     apiURL,
+		Token:      opts.Token,
+		Interval:   opts.Interval,
+	}
+	m.scheduleNextSync()
+	if _, err = x.Insert(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// GetIssueMirrorByRepoID returns the issue mirror of the repository.
+func GetIssueMirrorByRepoID(repoID int64) (*IssueMirror, error) {
+	m := &IssueMirror{RepoID: repoID}
+	has, err := x.Get(m)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		return nil, errors.IssueMirrorNotExist{RepoID: repoID}
+	}
+	return m, nil
+}
+
+// DeleteIssueMirror deletes the issue mirror and links of synced issues and comments,
+// issues and comments already synced are kept on both sides.
+func DeleteIssueMirror(m *IssueMirror) error {
+	sess := x.NewSession()
+	defer sess.Close()
+	if err := sess.Begin(); err != nil {
+		return err
+	}
+
+	if err := deleteBeans(sess,
+		&IssueMirror{ID: m.ID},
+		&IssueMirrorIssue{RepoID: m.RepoID},
+		&IssueMirrorComment{RepoID: m.RepoID},
+	); err != nil {
+		return err
+	}
+	return sess.Commit()
+}
+
+func (m *IssueMirror) client() *migrationClient {
+	headers := make(map[string]string, 2)
+	if m.Service == ISSUE_MIRROR_SERVICE_GITHUB {
+		headers["Accept"] = "application/vnd.github.v3+json"
+	}
+	if m.Token != "" {
+		headers["Authorization"] = "token " + m.Token
+	}
+	return &migrationClient{
+		baseURL: m.APIURL,
+		headers: headers,
+	}
+}
+
+// remoteIssues returns remote issues updated since given time in ascending order of
+// creation, pull requests are excluded. Gogs ignores "since" and returns all issues,
+// but only lists either open or closed issues at a time.
+func (m *IssueMirror) remoteIssues(c *migrationClient, since time.Time) ([]*githubIssue, error) {
+	states := []string{"all"}
+	if m.Service == ISSUE_MIRROR_SERVICE_GOGS {
+		states = []string{"open", "closed"}
+	}
+
+	var results []*githubIssue
+	for _, state := range states {
+		for page := 1; ; page++ {
+			query := githubListQuery(page)
+			query.Set("state", state)
+			// Gitea lists pull requests as well unless the type is specified.
+			query.Set("type", "issues")
+			if !since.IsZero() {
+				query.Set("since", since.UTC().Format(time.RFC3339))
+			}
+
+			var issues []*githubIssue
+			if err := c.getJSON("/issues", query, &issues); err != nil {
+				return nil, err
+			}
+			for _, issue := range issues {
+				if issue.PullRequest == nil {
+					results = append(results, issue)
+				}
+			}
+
+			// Page size is fixed by the server in Gogs, the last page is empty.
+			if len(issues) == 0 || (m.Service == ISSUE_MIRROR_SERVICE_GITHUB && len(issues) < migrationPageSize) {
+				break
+			}
+		}
+	}
+	return results, nil
+}
+
+// remoteComments returns all comments of the remote issue in chronological order.
+func (m *IssueMirror) remoteComments(c *migrationClient, number int64) ([]*githubComment, error) {
+	path := fmt.Sprintf("/issues/%d/comments", number)
+	// Comments of an issue are not paginated in Gogs.
+	if m.Service == ISSUE_MIRROR_SERVICE_GOGS {
+		var comments []*githubComment
+		return comments, c.getJSON(path, nil, &comments)
+	}
+
+	var results []*githubComment
+	for page := 1; ; page++ {
+		var comments []*githubComment
+		if err := c.getJSON(path, githubPageQuery(page), &comments); err != nil {
+			return nil, err
+		}
+		results = append(results, comments...)
+		if len(comments) < migrationPageSize {
+			return results, nil
+		}
+	}
+}
+
+// remoteAttribution returns the attribution line of an item posted by given remote user.
+func (m *IssueMirror) remoteAttribution(user githubUser, originalURL string) string {
+	authorURL := user.HTMLURL
+	if authorURL == "" {
+		authorURL = m.siteURL() + "/" + user.Login
+	}
+	return migrationAttribution(user.Login, authorURL, originalURL)
+}
+
+// insertRemoteComment inserts the remote comment to the local issue along with its link.
+func (m *IssueMirror) insertRemoteComment(e Engine, doer *User, issueID int64, rc *githubComment) error {
+	comment := &Comment{
+		Type:        COMMENT_TYPE_COMMENT,
+		PosterID:    doer.ID,
+		IssueID:     issueID,
+		Content:     m.remoteAttribution(rc.User, "") + rc.Body,
+		CreatedUnix: rc.CreatedAt.Unix(),
+		UpdatedUnix: rc.UpdatedAt.Unix(),
+	}
+	if _, err := e.Insert(comment); err != nil {
+		return fmt.Errorf("insert comment: %v", err)
+	}
+	if _, err := e.Exec("UPDATE `issue` SET num_comments = num_comments + 1 WHERE id = ?", issueID); err != nil {
+		return err
+	}
+	_, err := e.Insert(&IssueMirrorComment{
+		RepoID:    m.RepoID,
+		IssueID:   issueID,
+		CommentID: comment.ID,
+		RemoteID:  rc.ID,
+	})
+	return err
+}
+
+// pullIssue creates a local issue of the remote issue along with its comments.
+func (m *IssueMirror) pullIssue(c *migrationClient, doer *User, ri *githubIssue) error {
+	comments, err := m.remoteComments(c, ri.Number)
+	if err != nil {
+		return fmt.Errorf("get comments: %v", err)
+	}
+
+	// Repository is reloaded to have the latest numbers of issues for next index.
+	repo, err := GetRepositoryByID(m.RepoID)
+	if err != nil {
+		return fmt.Errorf("GetRepositoryByID: %v", err)
+	}
+
+	issue := &Issue{
+		RepoID:      repo.ID,
+		Repo:        repo,
+		PosterID:    doer.ID,
+		Poster:      doer,
+		Title:       ri.Title,
+		Content:     m.remoteAttribution(ri.User, fmt.Sprintf("%s/issues/%d", m.RemoteAddr, ri.Number)) + ri.Body,
+		IsClosed:    ri.State == "closed",
+		CreatedUnix: ri.CreatedAt.Unix(),
+		UpdatedUnix: ri.UpdatedAt.Unix(),
+	}
+
+	sess := x.NewSession()
+	defer sess.Close()
+	if err = sess.Begin(); err != nil {
+		return err
+	}
+
+	if err = newIssue(sess, NewIssueOptions{
+		Repo:  repo,
+		Issue: issue,
+	}); err != nil {
+		return fmt.Errorf("newIssue: %v", err)
+	}
+	if issue.IsClosed {
+		if err = updateIssueUsersByStatus(sess, issue.ID, true); err != nil {
+			return fmt.Errorf("updateIssueUsersByStatus: %v", err)
+		}
+		if _, err = sess.Exec("UPDATE `repository` SET num_closed_issues = num_closed_issues + 1 WHERE id = ?", repo.ID); err != nil {
+			return err
+		}
+	}
+
+	if _, err = sess.Insert(&IssueMirrorIssue{
+		RepoID:       m.RepoID,
+		IssueID:      issue.ID,
+		RemoteNumber: ri.Number,
+		IsClosed:     issue.IsClosed,
+	}); err != nil {
+		return fmt.Errorf("insert issue link: %v", err)
+	}
+	for _, comment := range comments {
+		if err = m.insertRemoteComment(sess, doer, issue.ID, comment); err != nil {
+			return err
+		}
+	}
+	return sess.Commit()
+}
+
+// pullComments creates local comments of remote comments that have not been synced.
+func (m *IssueMirror) pullComments(c *migrationClient, doer *User, link *IssueMirrorIssue) error {
+	comments, err := m.remoteComments(c, link.RemoteNumber)
+	if err != nil {
+		return fmt.Errorf("get comments: %v", err)
+	}
+
+	links := make([]*IssueMirrorComment, 0, len(comments))
+	if err = x.Where("issue_id = ?", link.IssueID).Find(&links); err != nil {
+		return fmt.Errorf("find comment links: %v", err)
+	}
+	synced := make(map[int64]bool, len(links))
+	for i := range links {
+		synced[links[i].RemoteID] = true
+	}
+
+	for _, comment := range comments {
+		if synced[comment.ID] {
+			continue
+		}
+		if err = m.insertRemoteComment(x, doer, link.IssueID, comment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pull syncs remote issues that have been updated since the last sync to local.
+func (m *IssueMirror) pull(c *migrationClient, doer *User, repo *Repository) error {
+	since := time.Time{}
+	if m.HasSynced() {
+		since = time.Unix(m.LastSyncUnix, 0)
+	}
+	issues, err := m.remoteIssues(c, since)
+	if err != nil {
+		return fmt.Errorf("get issues: %v", err)
+	}
+
+	for _, ri := range issues {
+		link := &IssueMirrorIssue{
+			RepoID:       m.RepoID,
+			RemoteNumber: ri.Number,
+		}
+		has, err := x.Get(link)
+		if err != nil {
+			return fmt.Errorf("get issue link: %v", err)
+		} else if !has {
+			if err = m.pullIssue(c, doer, ri); err != nil {
+				return fmt.Errorf("pull #%d: %v", ri.Number, err)
+			}
+			continue
+		}
+
+		isClosed := ri.State == "closed"
+		if isClosed != link.IsClosed {
+			issue, err := GetIssueByID(link.IssueID)
+			if err != nil {
+				return fmt.Errorf("GetIssueByID [%d]: %v", link.IssueID, err)
+			}
+			if issue.IsClosed != isClosed {
+				if err = issue.ChangeStatus(doer, repo, isClosed); err != nil {
+					return fmt.Errorf("ChangeStatus [%d]: %v", issue.ID, err)
+				}
+			}
+			link.IsClosed = isClosed
+			if _, err = x.ID(link.ID).Cols("is_closed").Update(link); err != nil {
+				return fmt.Errorf("update issue link: %v", err)
+			}
+		}
+
+		// Gogs does not update issues when comments are created, comments of open
+		// issues are always checked.
+		if !ri.UpdatedAt.Before(since) ||
+			(m.Service == ISSUE_MIRROR_SERVICE_GOGS && !isClosed) {
+			if err = m.pullComments(c, doer, link); err != nil {
+				return fmt.Errorf("pull comments of #%d: %v", ri.Number, err)
+			}
+		}
+	}
+	return nil
+}
+
+// setRemoteState changes the state of the remote issue.
+func (m *IssueMirror) setRemoteState(c *migrationClient, number int64, isClosed bool) error {
+	state := "open"
+	if isClosed {
+		state = "closed"
+	}
+	var issue githubIssue
+	return c.sendJSON("PATCH", fmt.Sprintf("/issues/%d", number), map[string]string{"state": state}, &issue)
+}
+
+// push creates remote issues and comments of local ones that are created after the
+// issue mirror was added and have not been synced, and then changes states of remote
+// issues that have been changed locally.
+func (m *IssueMirror) push(c *migrationClient, repo *Repository) error {
+	issues := make([]*Issue, 0, 10)
+	// Confidential issues are never pushed to the remote repository.
+	if err := x.Where("repo_id = ? AND is_pull = ? AND is_confidential = ? AND created_unix >= ?", m.RepoID, false, false, m.CreatedUnix).
+		And("id NOT IN (SELECT issue_id FROM issue_mirror_issue WHERE repo_id = ?)", m.RepoID).
+		Asc("id").Find(&issues); err != nil {
+		return fmt.Errorf("find issues: %v", err)
+	}
+	for _, issue := range issues {
+		issue.Repo = repo
+		poster, err := GetUserByID(issue.PosterID)
+		if err != nil {
+			poster = NewGhostUser()
+		}
+
+		var ri githubIssue
+		if err = c.sendJSON("POST", "/issues", map[string]string{
+			"title": issue.Title,
+			"body":  migrationAttribution(poster.Name, poster.HTMLURL(), issue.HTMLURL()) + issue.Content,
+		}, &ri); err != nil {
+			return fmt.Errorf("create issue of #%d: %v", issue.Index, err)
+		}
+		if issue.IsClosed {
+			if err = m.setRemoteState(c, ri.Number, true); err != nil {
+				return fmt.Errorf("close #%d: %v", ri.Number, err)
+			}
+		}
+
+		if _, err = x.Insert(&IssueMirrorIssue{
+			RepoID:       m.RepoID,
+			IssueID:      issue.ID,
+			RemoteNumber: ri.Number,
+			IsClosed:     issue.IsClosed,
+		}); err != nil {
+			return fmt.Errorf("insert issue link: %v", err)
+		}
+	}
+
+	comments := make([]*Comment, 0, 10)
+	if err := x.Where("type = ? AND created_unix >= ?", COMMENT_TYPE_COMMENT, m.CreatedUnix).
+		And("issue_id IN (SELECT issue_id FROM issue_mirror_issue WHERE repo_id = ?)", m.RepoID).
+		And("id NOT IN (SELECT comment_id FROM issue_mirror_comment WHERE repo_id = ?)", m.RepoID).
+		Asc("id").Find(&comments); err != nil {
+		return fmt.Errorf("find comments: %v", err)
+	}
+	for _, comment := range comments {
+		link := &IssueMirrorIssue{IssueID: comment.IssueID}
+		if _, err := x.Get(link); err != nil {
+			return fmt.Errorf("get issue link: %v", err)
+		}
+		poster, err := GetUserByID(comment.PosterID)
+		if err != nil {
+			poster = NewGhostUser()
+		}
+
+		var rc githubComment
+		if err = c.sendJSON("POST", fmt.Sprintf("/issues/%d/comments", link.RemoteNumber), map[string]string{
+			"body": migrationAttribution(poster.Name, poster.HTMLURL(), "") + comment.Content,
+		}, &rc); err != nil {
+			return fmt.Errorf("create comment on #%d: %v", link.RemoteNumber, err)
+		}
+
+		if _, err = x.Insert(&IssueMirrorComment{
+			RepoID:    m.RepoID,
+			IssueID:   comment.IssueID,
+			CommentID: comment.ID,
+			RemoteID:  rc.ID,
+		}); err != nil {
+			return fmt.Errorf("insert comment link: %v", err)
+		}
+	}
+
+	links := make([]*IssueMirrorIssue, 0, 10)
+	if err := x.Where("repo_id = ?", m.RepoID).
+		And("EXISTS (SELECT 1 FROM issue WHERE issue.id = issue_mirror_issue.issue_id AND issue.is_closed <> issue_mirror_issue.is_closed)").
+		Find(&links); err != nil {
+		return fmt.Errorf("find changed issues: %v", err)
+	}
+	for _, link := range links {
+		link.IsClosed = !link.IsClosed
+		if err := m.setRemoteState(c, link.RemoteNumber, link.IsClosed); err != nil {
+			return fmt.Errorf("change state of #%d: %v", link.RemoteNumber, err)
+		}
+		if _, err := x.ID(link.ID).Cols("is_closed").Update(link); err != nil {
+			return fmt.Errorf("update issue link: %v", err)
+		}
+	}
+	return nil
+}
+
+// runSync pulls changes from the remote first, so changes of the remote win when
+// the state of an issue is changed on both sides, and then pushes local changes.
+func (m *IssueMirror) runSync(repo *Repository) error {
+	if !repo.EnableIssues || repo.EnableExternalTracker {
+		return fmt.Errorf("issue tracker of the repository is disabled")
+	}
+	doer, err := GetUserByID(m.DoerID)
+	if err != nil {
+		return fmt.Errorf("GetUserByID [%d]: %v", m.DoerID, err)
+	}
+
+	c := m.client()
+	if err = m.pull(c, doer, repo); err != nil {
+		return fmt.Errorf("pull: %v", err)
+	}
+	if err = m.push(c, repo); err != nil {
+		return fmt.Errorf("push: %v", err)
+	}
+	return nil
+}
+
+// SyncIssueMirrors syncs queued issue mirrors.
+func SyncIssueMirrors() {
+	for id := range IssueMirrorQueue.Queue() {
+		log.Trace("SyncIssueMirrors [id: %s]", id)
+		IssueMirrorQueue.Remove(id)
+
+		m := new(IssueMirror)
+		has, err := x.ID(com.StrTo(id).MustInt64()).Get(m)
+		if err != nil {
+			log.Error("Get issue mirror [%s]: %v", id, err)
+			continue
+		} else if !has {
+			continue
+		}
+
+		repo, err := GetRepositoryByID(m.RepoID)
+		if err != nil {
+			log.Error("GetRepositoryByID [%d]: %v", m.RepoID, err)
+			continue
+		}
+
+		// Time of the sync is taken before it starts to not miss changes during the sync.
+		syncUnix := time.Now().Unix()
+		m.LastError = ""
+		if err = m.runSync(repo); err != nil {
+			m.LastError = err.Error()
+			log.Error("Failed to sync issue mirror %d of repository '%s': %v", m.ID, repo.FullName(), err)
+		} else {
+			m.LastSyncUnix = syncUnix
+		}
+		m.scheduleNextSync()
+		if _, err = x.ID(m.ID).Cols("last_sync_unix", "next_sync_unix", "last_error").Update(m); err != nil {
+			log.Error("Update issue mirror [%d]: %v", m.ID, err)
+		}
+	}
+}
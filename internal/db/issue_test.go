@@ -0,0 +1,29 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_confidentialIssuesCond(t *testing.T) {
+	Convey("Build condition to hide confidential issues", t, func() {
+		Convey("Anonymous user only sees issues that are not confidential", func() {
+			cond, args := confidentialIssuesCond(0)
+			So(cond, ShouldEqual, "issue.is_confidential = ?")
+			So(args, ShouldResemble, []interface{}{false})
+		})
+
+		Convey("Signed-in user also sees own issues and issues of repositories can be triaged", func() {
+			cond, args := confidentialIssuesCond(7)
+			So(strings.Count(cond, "?"), ShouldEqual, len(args))
+			So(args[0], ShouldEqual, false)
+			So(args[1], ShouldEqual, int64(7))
+		})
+	})
+}
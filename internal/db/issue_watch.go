@@ -0,0 +1,172 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"xorm.io/xorm"
+)
+
+// IssueWatch is an explicit subscription or unsubscription of an issue or a pull
+// request, which takes precedence over the watch mode of the repository.
+type IssueWatch struct {
+	ID         int64
+	UserID     int64 `xorm:"UNIQUE(watch) NOT NULL"`
+	IssueID    int64 `xorm:"UNIQUE(watch) NOT NULL"`
+	RepoID     int64 `xorm:"INDEX NOT NULL"`
+	IsWatching bool  `xorm:"NOT NULL"`
+
+	Created     time.Time `xorm:"-" json:"-"`
+	CreatedUnix int64
+	Updated     time.Time `xorm:"-" json:"-"`
+	UpdatedUnix int64
+}
+
+func (w *IssueWatch) BeforeInsert() {
+	w.CreatedUnix = time.Now().Unix()
+	w.UpdatedUnix = w.CreatedUnix
+}
+
+func (w *IssueWatch) BeforeUpdate() {
+	w.UpdatedUnix = time.Now().Unix()
+}
+
+func (w *IssueWatch) AfterSet(colName string, _ xorm.Cell) {
+	switch colName {
+	case "created_unix":
+		w.Created = time.Unix(w.CreatedUnix, 0).Local()
+	case "updated_unix":
+		w.Updated = time.Unix(w.UpdatedUnix, 0).Local()
+	}
+}
+
+// SetIssueWatch subscribes the user to the issue or unsubscribes from it.
+func SetIssueWatch(userID int64, issue *Issue, isWatching bool) error {
+	w := &IssueWatch{UserID: userID, IssueID: issue.ID}
+	has, err := x.Get(w)
+	if err != nil {
+		return err
+	} else if !has {
+		w.RepoID = issue.RepoID
+		w.IsWatching = isWatching
+		_, err = x.Insert(w)
+		return err
+	}
+
+	w.IsWatching = isWatching
+	_, err = x.ID(w.ID).Cols("is_watching", "updated_unix").Update(w)
+	return err
+}
+
+// isIssueParticipant returns true if the user posted, is assigned to, commented on or
+// is mentioned in the issue.
+func isIssueParticipant(e Engine, userID int64, issue *Issue) (bool, error) {
+	if issue.PosterID == userID || issue.AssigneeID == userID {
+		return true, nil
+	}
+
+	has, err := e.Where("issue_id = ? AND poster_id = ?", issue.ID, userID).Get(new(Comment))
+	if err != nil {
+		return false, fmt.Errorf("get comment: %v", err)
+	} else if has {
+		return true, nil
+	}
+	return e.Where("issue_id = ? AND uid = ? AND is_mentioned = ?", issue.ID, userID, true).Get(new(IssueUser))
+}
+
+// IsIssueWatching returns true if the user is notified of activities of the issue,
+// which is decided by the explicit subscription of the issue if any, or the watch
+// mode of the repository and the participation of the issue.
+func IsIssueWatching(userID int64, issue *Issue) (bool, error) {
+	w := &IssueWatch{UserID: userID, IssueID: issue.ID}
+	if has, err := x.Get(w); err != nil {
+		return false, err
+	} else if has {
+		return w.IsWatching, nil
+	}
+
+	switch getWatchMode(x, userID, issue.RepoID) {
+	case WATCH_MODE_ALL:
+		return true, nil
+	case WATCH_MODE_IGNORE:
+		return false, nil
+	}
+	return isIssueParticipant(x, userID, issue)
+}
+
+// getIssueSubscriberIDs returns IDs of users who are notified of all activities of
+// the issue: watchers of all activities of the repository, participants and users
+// subscribed to the issue. Users who unsubscribed from the issue or ignore the
+// repository are excluded.
+func getIssueSubscriberIDs(e Engine, issue *Issue) ([]int64, error) {
+	candidateIDs := make([]int64, 0, 10)
+	if err := e.Table("watch").Where("repo_id = ? AND mode = ?", issue.RepoID, WATCH_MODE_ALL).
+		Cols("user_id").Find(&candidateIDs); err != nil {
+		return nil, fmt.Errorf("get watchers: %v", err)
+	}
+
+	participantIDs := make([]int64, 0, 10)
+	if err := e.Table("comment").Where("issue_id = ?", issue.ID).Distinct("poster_id").Find(&participantIDs); err != nil {
+		return nil, fmt.Errorf("get commenters: %v", err)
+	}
+	candidateIDs = append(candidateIDs, participantIDs...)
+
+	participantIDs = participantIDs[:0]
+	if err := e.Table("issue_user").Where("issue_id = ? AND is_mentioned = ?", issue.ID, true).
+		Cols("uid").Find(&participantIDs); err != nil {
+		return nil, fmt.Errorf("get mentioned users: %v", err)
+	}
+	candidateIDs = append(candidateIDs, participantIDs...)
+	candidateIDs = append(candidateIDs, issue.PosterID, issue.AssigneeID)
+
+	watches := make([]*IssueWatch, 0, 5)
+	if err := e.Where("issue_id = ?", issue.ID).Find(&watches); err != nil {
+		return nil, fmt.Errorf("get issue watches: %v", err)
+	}
+	isWatched := make(map[int64]bool, len(watches))
+	isUnwatched := make(map[int64]bool, len(watches))
+	for _, w := range watches {
+		if w.IsWatching {
+			candidateIDs = append(candidateIDs, w.UserID)
+			isWatched[w.UserID] = true
+		} else {
+			isUnwatched[w.UserID] = true
+		}
+	}
+
+	ignoredIDs := make([]int64, 0, 5)
+	if err := e.Table("watch").Where("repo_id = ? AND mode = ?", issue.RepoID, WATCH_MODE_IGNORE).
+		Cols("user_id").Find(&ignoredIDs); err != nil {
+		return nil, fmt.Errorf("get ignoring users: %v", err)
+	}
+	for _, id := range ignoredIDs {
+		if !isWatched[id] {
+			isUnwatched[id] = true
+		}
+	}
+
+	seen := make(map[int64]bool, len(candidateIDs))
+	subscriberIDs := make([]int64, 0, len(candidateIDs))
+	for _, id := range candidateIDs {
+		if id <= 0 || seen[id] || isUnwatched[id] {
+			continue
+		}
+		seen[id] = true
+		subscriberIDs = append(subscriberIDs, id)
+	}
+	return subscriberIDs, nil
+}
+
+// isIgnoringIssue returns true if the user ignores the repository of the issue and
+// is not subscribed to the issue, who should not be notified even if mentioned.
+func isIgnoringIssue(e Engine, userID int64, issue *Issue) (bool, error) {
+	if getWatchMode(e, userID, issue.RepoID) != WATCH_MODE_IGNORE {
+		return false, nil
+	}
+	has, err := e.Where("user_id = ? AND issue_id = ? AND is_watching = ?", userID, issue.ID, true).Get(new(IssueWatch))
+	return !has, err
+}
@@ -0,0 +1,343 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gogs/git-module"
+	"github.com/unknwon/com"
+	log "unknwon.dev/clog/v2"
+
+	"gogs.io/gogs/internal/sync"
+)
+
+// LanguageStatsQueue is the queue of IDs of repositories to compute language
+// statistics for.
+var LanguageStatsQueue = sync.NewUniqueQueue(1000)
+
+// nonCodeLanguages are languages of files not counted for language statistics.
+var nonCodeLanguages = map[string]bool{
+	"Text":     true,
+	"Markdown": true,
+	"JSON":     true,
+	"YAML":     true,
+	"INI":      true,
+	"TOML":     true,
+	"XML":      true,
+}
+
+// excludedLanguagePaths matches paths of vendored, generated and documentation files
+// that are not counted for language statistics, which follows heuristics of enry
+// (https://github.com/go-enry/go-enry) used by GitHub Linguist.
+var excludedLanguagePaths = regexp.MustCompile(`(?i)(` +
+	// Vendored dependencies
+	`(^|/)(vendor|vendors|node_modules|bower_components|third[-_]?party|3rd[-_]?party|external|Godeps|Carthage|Pods)/` +
+	// Minified and generated files
+	`|\.min\.(js|css)$|\.pb\.go$|_gen\.go$|\.generated\.[a-z]+$` +
+	// Documentation
+	`|(^|/)(docs?|documentation|examples?|samples?)/` +
+	// Dot files and directories
+	`|(^|/)\.` +
+	`)`)
+
+// isExcludedLanguagePath returns true if the file is not counted for language
+// statistics.
+func isExcludedLanguagePath(treePath string) bool {
+	return excludedLanguagePaths.MatchString(treePath)
+}
+
+// parseLanguageSizes parses output of "git ls-tree -r -l -z" and returns total sizes
+// of regular files by their languages.
+func parseLanguageSizes(data []byte) map[string]int64 {
+	sizes := make(map[string]int64)
+	for _, line := range bytes.Split(data, []byte{0}) {
+		// <mode> SP <type> SP <object> SP+ <size> TAB <file>
+		tab := bytes.IndexByte(line, '\t')
+		if tab == -1 {
+			continue
+		}
+		fields := strings.Fields(string(line[:tab]))
+		if len(fields) != 4 || fields[1] != "blob" || fields[0] == "120000" {
+			continue
+		}
+		treePath := string(line[tab+1:])
+		if isExcludedLanguagePath(treePath) {
+			continue
+		}
+		lang := codeLanguage(treePath)
+		if nonCodeLanguages[lang] {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[3], 10, 64)
+		if err != nil {
+			continue
+		}
+		sizes[lang] += size
+	}
+	return sizes
+}
+
+// computeLanguageSizes returns total sizes of files of the commit by their languages.
+func computeLanguageSizes(repoPath, commitID string) (map[string]int64, error) {
+	stdout, err := git.NewCommand("ls-tree", "-r", "-l", "-z", "--full-tree", commitID).RunInDirBytes(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("ls-tree: %v", err)
+	}
+	return parseLanguageSizes(stdout), nil
+}
+
+// primaryLanguage returns the language with the largest total size of files, empty
+// if no file is written in a known programming language.
+func primaryLanguage(sizes map[string]int64) string {
+	var lang string
+	var max int64
+	for l, size := range sizes {
+		if size > max || (size == max && l < lang) {
+			lang, max = l, size
+		}
+	}
+	return lang
+}
+
+// detectPrimaryLanguage returns the primary language of files of the commit.
+func detectPrimaryLanguage(repoPath, commitID string) (string, error) {
+	sizes, err := computeLanguageSizes(repoPath, commitID)
+	if err != nil {
+		return "", err
+	}
+	return primaryLanguage(sizes), nil
+}
+
+// languageColors are colors of languages used by GitHub Linguist.
+var languageColors = map[string]string{
+	"C":               "#555555",
+	"C++":             "#f34b7d",
+	"C#":              "#178600",
+	"Clojure":         "#db5855",
+	"CMake":           "#da3434",
+	"CoffeeScript":    "#244776",
+	"CSS":             "#563d7c",
+	"Dart":            "#00b4ab",
+	"Dockerfile":      "#384d54",
+	"Elixir":          "#6e4a7e",
+	"Erlang":          "#b83998",
+	"Go":              "#00add8",
+	"Groovy":          "#e69f56",
+	"Haskell":         "#5e5086",
+	"HTML":            "#e34c26",
+	"Java":            "#b07219",
+	"JavaScript":      "#f1e05a",
+	"Kotlin":          "#a97bff",
+	"Less":            "#1d365d",
+	"Lua":             "#000080",
+	"Makefile":        "#427819",
+	"Objective-C":     "#438eff",
+	"Perl":            "#0298c3",
+	"PHP":             "#4f5d95",
+	"PowerShell":      "#012456",
+	"Protocol Buffer": "#6a6a6a",
+	"Python":          "#3572a5",
+	"R":               "#198ce7",
+	"Ruby":            "#701516",
+	"Rust":            "#dea584",
+	"Scala":           "#c22d40",
+	"SCSS":            "#c6538c",
+	"Shell":           "#89e051",
+	"SQL":             "#e38c00",
+	"Swift":           "#f05138",
+	"TeX":             "#3d6117",
+	"TypeScript":      "#2b7489",
+	"Visual Basic":    "#945db7",
+	"Vue":             "#41b883",
+}
+
+// LanguageStatOther is the name of the group of languages that are too small to be
+// shown separately.
+const LanguageStatOther = "Other"
+
+// LanguageStat is the total size of files written in a language on the default branch
+// of a repository.
+type LanguageStat struct {
+	ID       int64
+	RepoID   int64  `xorm:"UNIQUE(s) NOT NULL"`
+	CommitID string `xorm:"VARCHAR(40)"`
+	Language string `xorm:"UNIQUE(s) NOT NULL"`
+	Size     int64  `xorm:"NOT NULL DEFAULT 0"`
+
+	Percentage float64 `xorm:"-" json:"-"`
+}
+
+// Color returns the color of the language to render.
+func (s *LanguageStat) Color() string {
+	if color, ok := languageColors[s.Language]; ok {
+		return color
+	}
+	return "#cccccc"
+}
+
+// newLanguageStats returns statistics of languages sorted by size in descending order
+// with percentages rounded to one decimal place.
+func newLanguageStats(sizes map[string]int64) []*LanguageStat {
+	var total int64
+	stats := make([]*LanguageStat, 0, len(sizes))
+	for lang, size := range sizes {
+		total += size
+		stats = append(stats, &LanguageStat{
+			Language: lang,
+			Size:     size,
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Size != stats[j].Size {
+			return stats[i].Size > stats[j].Size
+		}
+		return stats[i].Language < stats[j].Language
+	})
+	for _, s := range stats {
+		if total > 0 {
+			s.Percentage = math.Round(float64(s.Size)*1000/float64(total)) / 10
+		}
+	}
+	return stats
+}
+
+// GroupLanguageStats returns the statistics with languages smaller than the percentage
+// grouped as "Other", which is put at the end.
+func GroupLanguageStats(stats []*LanguageStat, minPercentage float64) []*LanguageStat {
+	grouped := make([]*LanguageStat, 0, len(stats))
+	var other *LanguageStat
+	for _, s := range stats {
+		if s.Percentage >= minPercentage {
+			grouped = append(grouped, s)
+			continue
+		}
+
+		if other == nil {
+			other = &LanguageStat{
+				RepoID:   s.RepoID,
+				CommitID: s.CommitID,
+				Language: LanguageStatOther,
+			}
+		}
+		other.Size += s.Size
+		other.Percentage = math.Round((other.Percentage+s.Percentage)*10) / 10
+	}
+	if other != nil {
+		grouped = append(grouped, other)
+	}
+	return grouped
+}
+
+// GetLanguageStats returns statistics of languages of the repository sorted by size in
+// descending order.
+func GetLanguageStats(repoID int64) ([]*LanguageStat, error) {
+	stats := make([]*LanguageStat, 0, 5)
+	if err := x.Where("repo_id = ?", repoID).Find(&stats); err != nil {
+		return nil, err
+	}
+
+	sizes := make(map[string]int64, len(stats))
+	for _, s := range stats {
+		sizes[s.Language] = s.Size
+	}
+	results := newLanguageStats(sizes)
+	for _, s := range results {
+		s.RepoID = repoID
+		if len(stats) > 0 {
+			s.CommitID = stats[0].CommitID
+		}
+	}
+	return results, nil
+}
+
+// UpdateLanguageStats computes and saves statistics of languages of the default branch
+// of the repository, it does nothing if the default branch has not changed since the
+// last computation.
+func (repo *Repository) UpdateLanguageStats() (err error) {
+	if repo.IsBare {
+		return nil
+	}
+
+	commitID, err := repo.defaultBranchCommitID()
+	if err != nil {
+		return err
+	}
+	last := new(LanguageStat)
+	has, err := x.Where("repo_id = ?", repo.ID).Get(last)
+	if err != nil {
+		return fmt.Errorf("get last language stat: %v", err)
+	} else if has && last.CommitID == commitID {
+		return nil
+	}
+
+	sizes, err := computeLanguageSizes(repo.RepoPath(), commitID)
+	if err != nil {
+		return err
+	}
+
+	sess := x.NewSession()
+	defer sess.Close()
+	if err = sess.Begin(); err != nil {
+		return err
+	}
+
+	if _, err = sess.Delete(&LanguageStat{RepoID: repo.ID}); err != nil {
+		return fmt.Errorf("delete old language stats: %v", err)
+	}
+	for _, s := range newLanguageStats(sizes) {
+		s.RepoID = repo.ID
+		s.CommitID = commitID
+		if _, err = sess.Insert(s); err != nil {
+			return fmt.Errorf("insert language stat %q: %v", s.Language, err)
+		}
+	}
+
+	return sess.Commit()
+}
+
+// AddLanguageStatsTask queues the repository to compute its language statistics.
+func AddLanguageStatsTask(repoID int64) {
+	LanguageStatsQueue.Add(repoID)
+}
+
+// RunLanguageStats computes language statistics of queued repositories.
+func RunLanguageStats() {
+	for repoID := range LanguageStatsQueue.Queue() {
+		log.Trace("RunLanguageStats [repo_id: %s]", repoID)
+		LanguageStatsQueue.Remove(repoID)
+
+		repo, err := GetRepositoryByID(com.StrTo(repoID).MustInt64())
+		if err != nil {
+			log.Error("GetRepositoryByID [%s]: %v", repoID, err)
+		} else if err = repo.UpdateLanguageStats(); err != nil {
+			log.Error("Failed to update language statistics [repo_id: %d]: %v", repo.ID, err)
+		}
+	}
+}
+
+// InitLanguageStats starts computing language statistics of queued repositories, and
+// queues repositories that have never been computed.
+func InitLanguageStats() {
+	go RunLanguageStats()
+
+	go func() {
+		repoIDs := make([]int64, 0, 100)
+		if err := x.Table("repository").Where("is_bare = ? AND id NOT IN (SELECT repo_id FROM language_stat)", false).
+			Cols("id").Find(&repoIDs); err != nil {
+			log.Error("Failed to get repositories for language statistics: %v", err)
+			return
+		}
+		for _, repoID := range repoIDs {
+			LanguageStatsQueue.Add(repoID)
+		}
+	}()
+}
@@ -0,0 +1,82 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_isExcludedLanguagePath(t *testing.T) {
+	Convey("Check if paths are excluded from language statistics", t, func() {
+		testCases := []struct {
+			path   string
+			expect bool
+		}{
+			{"main.go", false},
+			{"internal/db/repo.go", false},
+			{"public/js/gogs.js", false},
+			{"vendor/github.com/a/b/b.go", true},
+			{"web/node_modules/jquery/jquery.js", true},
+			{"third_party/lib.c", true},
+			{"docs/conf.py", true},
+			{".github/scripts/release.sh", true},
+			{"public/js/jquery.min.js", true},
+			{"api/service.pb.go", true},
+			{"internal/assets/bindata_gen.go", true},
+		}
+		for _, tc := range testCases {
+			So(isExcludedLanguagePath(tc.path), ShouldEqual, tc.expect)
+		}
+	})
+}
+
+func Test_parseLanguageSizes(t *testing.T) {
+	Convey("Parse sizes of languages from ls-tree output", t, func() {
+		data := []byte("100644 blob 0a1b2c3d4e5f0a1b2c3d4e5f0a1b2c3d4e5f0a1b     120\tREADME.md\x00" +
+			"100644 blob 1a1b2c3d4e5f0a1b2c3d4e5f0a1b2c3d4e5f0a1b    1000\tmain.go\x00" +
+			"100644 blob 2a1b2c3d4e5f0a1b2c3d4e5f0a1b2c3d4e5f0a1b     500\tinternal/db/repo.go\x00" +
+			"100755 blob 3a1b2c3d4e5f0a1b2c3d4e5f0a1b2c3d4e5f0a1b     800\tscripts/build.sh\x00" +
+			"120000 blob 4a1b2c3d4e5f0a1b2c3d4e5f0a1b2c3d4e5f0a1b      10\tlink.go\x00" +
+			"160000 commit 5a1b2c3d4e5f0a1b2c3d4e5f0a1b2c3d4e5f0a1b       -\tvendor/module\x00" +
+			"100644 blob 6a1b2c3d4e5f0a1b2c3d4e5f0a1b2c3d4e5f0a1b    9000\tvendor/github.com/a/b/b.go\x00")
+		sizes := parseLanguageSizes(data)
+		So(sizes, ShouldResemble, map[string]int64{
+			"Go":    1500,
+			"Shell": 800,
+		})
+		So(primaryLanguage(sizes), ShouldEqual, "Go")
+	})
+
+	Convey("Get primary language", t, func() {
+		So(primaryLanguage(nil), ShouldBeEmpty)
+		So(primaryLanguage(map[string]int64{"Ruby": 10, "Python": 10}), ShouldEqual, "Python")
+	})
+}
+
+func Test_GroupLanguageStats(t *testing.T) {
+	Convey("Compute and group language statistics", t, func() {
+		stats := newLanguageStats(map[string]int64{
+			"Go":         7000,
+			"JavaScript": 2900,
+			"Shell":      60,
+			"Makefile":   40,
+		})
+		So(len(stats), ShouldEqual, 4)
+		So(stats[0].Language, ShouldEqual, "Go")
+		So(stats[0].Percentage, ShouldEqual, 70)
+		So(stats[1].Percentage, ShouldEqual, 29)
+
+		grouped := GroupLanguageStats(stats, 1)
+		So(len(grouped), ShouldEqual, 3)
+		So(grouped[2].Language, ShouldEqual, LanguageStatOther)
+		So(grouped[2].Size, ShouldEqual, 100)
+		So(grouped[2].Percentage, ShouldEqual, 1)
+
+		So(GroupLanguageStats(stats, 0), ShouldHaveLength, 4)
+		So(newLanguageStats(nil), ShouldBeEmpty)
+	})
+}
@@ -0,0 +1,422 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	log "unknwon.dev/clog/v2"
+	"xorm.io/xorm"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/db/errors"
+	"gogs.io/gogs/internal/storage"
+)
+
+var lfsOIDPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// IsValidLFSOID returns true if given string is a valid OID of LFS object, which is
+// the hex-encoded SHA-256 hash of its content.
+func IsValidLFSOID(oid string) bool {
+	return lfsOIDPattern.MatchString(oid)
+}
+
+// LFSPointerMaxSize is the max size of pointer files that reference LFS objects.
+const LFSPointerMaxSize = 1024
+
+// ParseLFSPointer returns the OID and size of LFS object referenced by the content
+// of a pointer file. It returns false if the content is not a valid pointer.
+func ParseLFSPointer(data []byte) (oid string, size int64, ok bool) {
+	if len(data) > LFSPointerMaxSize {
+		return "", 0, false
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) < 3 || lines[0] != "version https://git-lfs.github.com/spec/v1" {
+		return "", 0, false
+	}
+	for _, line := range lines[1:] {
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 {
+			return "", 0, false
+		}
+		switch fields[0] {
+		case "oid":
+			oid = strings.TrimPrefix(fields[1], "sha256:")
+		case "size":
+			var err error
+			size, err = strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return "", 0, false
+			}
+		}
+	}
+	if !IsValidLFSOID(oid) || size < 0 {
+		return "", 0, false
+	}
+	return oid, size, true
+}
+
+// LFSObject is a Git LFS object that is uploaded to a repository. Objects are
+// content-addressed, an object with the same OID is saved only once in the storage
+// no matter how many repositories it is uploaded to.
+type LFSObject struct {
+	ID      int64
+	RepoID  int64  `xorm:"UNIQUE(s)"`
+	OID     string `xorm:"oid UNIQUE(s) INDEX VARCHAR(64)"`
+	Size    int64
+	Storage string
+
+	Created     time.Time `xorm:"-" json:"-"`
+	CreatedUnix int64
+}
+
+func (o *LFSObject) BeforeInsert() {
+	o.CreatedUnix = time.Now().Unix()
+}
+
+func (o *LFSObject) AfterSet(colName string, _ xorm.Cell) {
+	switch colName {
+	case "created_unix":
+		o.Created = time.Unix(o.CreatedUnix, 0).Local()
+	}
+}
+
+// lfsObjectStore returns the blob store of given name that LFS objects are saved to.
+func lfsObjectStore(name string) (storage.Store, error) {
+	return blobStore(name, conf.LFS.ObjectsPath, "lfs")
+}
+
+// lfsObjectKey returns the key of the object of given OID in the blob store.
+func lfsObjectKey(oid string) string {
+	return oid[0:2] + "/" + oid[2:4] + "/" + oid
+}
+
+// Open opens the content of the object for reading.
+func (o *LFSObject) Open() (io.ReadCloser, error) {
+	store, err := lfsObjectStore(o.Storage)
+	if err != nil {
+		return nil, err
+	}
+	return store.Open(lfsObjectKey(o.OID))
+}
+
+// GetLFSObject returns the LFS object of given OID in the repository.
+func GetLFSObject(repoID int64, oid string) (*LFSObject, error) {
+	o := new(LFSObject)
+	has, err := x.Where("repo_id = ? AND oid = ?", repoID, oid).Get(o)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		return nil, errors.LFSObjectNotExist{RepoID: repoID, OID: oid}
+	}
+	return o, nil
+}
+
+// GetLFSObjectsSize returns the total size of LFS objects of the repository.
+func GetLFSObjectsSize(repoID int64) (int64, error) {
+	size, err := x.Where("repo_id = ?", repoID).SumInt(new(LFSObject), "size")
+	if err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// CheckLFSQuota returns an error if adding objects of given total size exceeds the
+// max total size of LFS objects of the repository.
+func CheckLFSQuota(repoID, size int64) error {
+	if conf.LFS.MaxRepoSize <= 0 {
+		return nil
+	}
+
+	total, err := GetLFSObjectsSize(repoID)
+	if err != nil {
+		return fmt.Errorf("GetLFSObjectsSize: %v", err)
+	}
+	if total+size > conf.LFS.MaxRepoSize<<20 {
+		return errors.LFSQuotaExceeded{RepoID: repoID, Quota: conf.LFS.MaxRepoSize}
+	}
+	return nil
+}
+
+// CreateLFSObject saves the content of LFS object read from r to the repository.
+// The content is verified against the OID and size while being saved.
+func CreateLFSObject(repoID int64, oid string, size int64, r io.Reader) (*LFSObject, error) {
+	if _, err := GetLFSObject(repoID, oid); err == nil {
+		return nil, fmt.Errorf("object already exists [repo_id: %d, oid: %s]", repoID, oid)
+	} else if !errors.IsLFSObjectNotExist(err) {
+		return nil, fmt.Errorf("GetLFSObject: %v", err)
+	}
+	if err := CheckLFSQuota(repoID, size); err != nil {
+		return nil, err
+	}
+
+	h := sha256.New()
+	cr := &countingReader{r: io.TeeReader(r, h)}
+	verified := func() bool {
+		return cr.n == size && hex.EncodeToString(h.Sum(nil)) == oid
+	}
+
+	// Content of the object has been verified when it was uploaded to other repositories,
+	// therefore no need to save again.
+	existing := new(LFSObject)
+	has, err := x.Where("oid = ?", oid).Get(existing)
+	if err != nil {
+		return nil, fmt.Errorf("get existing object: %v", err)
+	}
+	o := &LFSObject{
+		RepoID:  repoID,
+		OID:     oid,
+		Size:    size,
+		Storage: conf.LFS.Storage,
+	}
+	if has {
+		o.Storage = existing.Storage
+		if _, err = io.Copy(ioutil.Discard, cr); err != nil {
+			return nil, fmt.Errorf("read content: %v", err)
+		} else if !verified() {
+			return nil, errors.LFSObjectMismatch{OID: oid, Size: size}
+		}
+	} else {
+		store, err := lfsObjectStore(o.Storage)
+		if err != nil {
+			return nil, err
+		}
+		key := lfsObjectKey(oid)
+		if err = store.Put(key, cr, size); err != nil {
+			return nil, fmt.Errorf("put: %v", err)
+		} else if !verified() {
+			if err = store.Delete(key); err != nil {
+				log.Error("Failed to delete mismatched LFS object [oid: %s]: %v", oid, err)
+			}
+			return nil, errors.LFSObjectMismatch{OID: oid, Size: size}
+		}
+	}
+
+	if _, err = x.Insert(o); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// removeLFSObjectFiles removes files of given objects from the storage if they are
+// no longer referenced by any repository.
+func removeLFSObjectFiles(objects []*LFSObject) {
+	for _, o := range objects {
+		count, err := x.Where("oid = ?", o.OID).Count(new(LFSObject))
+		if err != nil {
+			log.Error("Failed to count LFS objects [oid: %s]: %v", o.OID, err)
+			continue
+		} else if count > 0 {
+			continue
+		}
+
+		store, err := lfsObjectStore(o.Storage)
+		if err == nil {
+			err = store.Delete(lfsObjectKey(o.OID))
+		}
+		if err != nil {
+			desc := fmt.Sprintf("Delete LFS object [oid: %s]: %v", o.OID, err)
+			log.Warn(desc)
+			if err = CreateRepositoryNotice(desc); err != nil {
+				log.Error("CreateRepositoryNotice: %v", err)
+			}
+		}
+	}
+}
+
+// LFSLock is a lock of a file path in a repository held by a user, which prevents
+// others from pushing changes to the file.
+type LFSLock struct {
+	ID      int64
+	RepoID  int64 `xorm:"UNIQUE(s)"`
+	OwnerID int64
+	Owner   *User  `xorm:"-" json:"-"`
+	Path    string `xorm:"UNIQUE(s)"`
+
+	Created     time.Time `xorm:"-" json:"-"`
+	CreatedUnix int64
+}
+
+func (l *LFSLock) BeforeInsert() {
+	l.CreatedUnix = time.Now().Unix()
+}
+
+func (l *LFSLock) AfterSet(colName string, _ xorm.Cell) {
+	switch colName {
+	case "created_unix":
+		l.Created = time.Unix(l.CreatedUnix, 0).Local()
+	}
+}
+
+func (l *LFSLock) loadAttributes(e Engine) (err error) {
+	if l.Owner == nil {
+		l.Owner, err = getUserByID(e, l.OwnerID)
+		if err != nil {
+			if errors.IsUserNotExist(err) {
+				l.Owner = getGhostUser(e, l.OwnerID)
+				l.OwnerID = -1
+			} else {
+				return fmt.Errorf("getUserByID.(Owner) [owner_id: %d]: %v", l.OwnerID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// cleanLFSLockPath returns the path relative to the repository root with slashes.
+func cleanLFSLockPath(p string) string {
+	return strings.Trim(strings.Replace(p, "\\", "/", -1), "/")
+}
+
+// CreateLFSLock creates a new lock of the path in the repository held by the user.
+// It returns the existing lock along with the error if the path is already locked.
+func CreateLFSLock(repoID int64, owner *User, path string) (*LFSLock, error) {
+	path = cleanLFSLockPath(path)
+	existing := new(LFSLock)
+	has, err := x.Where("repo_id = ? AND path = ?", repoID, path).Get(existing)
+	if err != nil {
+		return nil, err
+	} else if has {
+		if err = existing.loadAttributes(x); err != nil {
+			return nil, err
+		}
+		return existing, errors.LFSLockAlreadyExist{RepoID: repoID, Path: path}
+	}
+
+	lock := &LFSLock{
+		RepoID:  repoID,
+		OwnerID: owner.ID,
+		Owner:   owner,
+		Path:    path,
+	}
+	if _, err = x.Insert(lock); err != nil {
+		return nil, err
+	}
+	return lock, nil
+}
+
+// GetLFSLockByID returns the lock of given ID in the repository.
+func GetLFSLockByID(repoID, id int64) (*LFSLock, error) {
+	lock := new(LFSLock)
+	has, err := x.Where("id = ? AND repo_id = ?", id, repoID).Get(lock)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		return nil, errors.LFSLockNotExist{ID: id}
+	}
+	return lock, lock.loadAttributes(x)
+}
+
+type LFSLocksOptions struct {
+	RepoID int64
+	Path   string
+	// AfterID is the ID of last lock of previous page.
+	AfterID  int64
+	PageSize int
+}
+
+// GetLFSLocks returns a list of locks in the repository that match given options,
+// ordered by ID.
+func GetLFSLocks(opts *LFSLocksOptions) ([]*LFSLock, error) {
+	sess := x.Where("repo_id = ? AND id > ?", opts.RepoID, opts.AfterID)
+	if opts.Path != "" {
+		sess.And("path = ?", cleanLFSLockPath(opts.Path))
+	}
+	if opts.PageSize > 0 {
+		sess.Limit(opts.PageSize)
+	}
+
+	locks := make([]*LFSLock, 0, opts.PageSize)
+	if err := sess.Asc("id").Find(&locks); err != nil {
+		return nil, err
+	}
+	for i := range locks {
+		if err := locks[i].loadAttributes(x); err != nil {
+			return nil, err
+		}
+	}
+	return locks, nil
+}
+
+// DeleteLFSLock deletes the lock on behalf of the user. Locks held by other users
+// can only be deleted with force.
+func DeleteLFSLock(lock *LFSLock, doer *User, force bool) error {
+	if lock.OwnerID != doer.ID && !force {
+		return errors.LFSLockNotOwned{ID: lock.ID, UserID: doer.ID}
+	}
+
+	_, err := x.ID(lock.ID).Delete(new(LFSLock))
+	return err
+}
+
+// lfsTokenSignature returns the signature of the payload of LFS token.
+func lfsTokenSignature(payload string) string {
+	h := hmac.New(sha256.New, []byte(conf.Security.SecretKey))
+	h.Write([]byte(payload))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// NewLFSToken returns a token that grants the access mode of the repository for Git
+// LFS requests until it expires, which is issued to clients connected via SSH.
+// The user ID is zero for deploy keys.
+func NewLFSToken(userID, repoID int64, mode AccessMode, expires time.Time) string {
+	payload := fmt.Sprintf("%d:%d:%d:%d", userID, repoID, mode, expires.Unix())
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + lfsTokenSignature(payload)
+}
+
+// VerifyLFSToken returns the user ID and access mode granted by the token to the
+// repository. It returns false if the token is invalid or has expired.
+func VerifyLFSToken(token string, repoID int64) (userID int64, mode AccessMode, ok bool) {
+	i := strings.IndexByte(token, '.')
+	if i < 0 {
+		return 0, ACCESS_MODE_NONE, false
+	}
+	data, err := base64.RawURLEncoding.DecodeString(token[:i])
+	if err != nil {
+		return 0, ACCESS_MODE_NONE, false
+	}
+	payload := string(data)
+	if !hmac.Equal([]byte(token[i+1:]), []byte(lfsTokenSignature(payload))) {
+		return 0, ACCESS_MODE_NONE, false
+	}
+
+	fields := strings.Split(payload, ":")
+	if len(fields) != 4 {
+		return 0, ACCESS_MODE_NONE, false
+	}
+	var values [4]int64
+	for i := range fields {
+		values[i], err = strconv.ParseInt(fields[i], 10, 64)
+		if err != nil {
+			return 0, ACCESS_MODE_NONE, false
+		}
+	}
+	if values[1] != repoID || time.Now().Unix() > values[3] {
+		return 0, ACCESS_MODE_NONE, false
+	}
+	return values[0], AccessMode(values[2]), true
+}
@@ -0,0 +1,68 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"gogs.io/gogs/internal/conf"
+)
+
+func Test_ParseLFSPointer(t *testing.T) {
+	Convey("Parse pointer files of LFS objects", t, func() {
+		const oid = "4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393"
+		testCases := []struct {
+			data string
+			oid  string
+			size int64
+			ok   bool
+		}{
+			{"version https://git-lfs.github.com/spec/v1\noid sha256:" + oid + "\nsize 12345\n", oid, 12345, true},
+			{"version https://git-lfs.github.com/spec/v1\noid sha256:" + oid + "\nsize abc\n", "", 0, false},
+			{"version https://git-lfs.github.com/spec/v1\noid sha256:4d7a\nsize 12345\n", "", 0, false},
+			{"version https://git-lfs.github.com/spec/v2\noid sha256:" + oid + "\nsize 12345\n", "", 0, false},
+			{"Hello world\n", "", 0, false},
+		}
+		for _, tc := range testCases {
+			oid, size, ok := ParseLFSPointer([]byte(tc.data))
+			So(oid, ShouldEqual, tc.oid)
+			So(size, ShouldEqual, tc.size)
+			So(ok, ShouldEqual, tc.ok)
+		}
+	})
+}
+
+func Test_LFSToken(t *testing.T) {
+	Convey("Issue and verify tokens of Git LFS", t, func() {
+		conf.Security.SecretKey = "secret"
+		expires := time.Now().Add(time.Hour)
+		token := NewLFSToken(1, 2, ACCESS_MODE_WRITE, expires)
+
+		userID, mode, ok := VerifyLFSToken(token, 2)
+		So(ok, ShouldBeTrue)
+		So(userID, ShouldEqual, 1)
+		So(mode, ShouldEqual, ACCESS_MODE_WRITE)
+
+		Convey("Token of another repository", func() {
+			_, _, ok := VerifyLFSToken(token, 3)
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("Expired token", func() {
+			token := NewLFSToken(1, 2, ACCESS_MODE_WRITE, time.Now().Add(-time.Minute))
+			_, _, ok := VerifyLFSToken(token, 2)
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("Token signed with another key", func() {
+			conf.Security.SecretKey = "another"
+			_, _, ok := VerifyLFSToken(token, 2)
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
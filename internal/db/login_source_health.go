@@ -0,0 +1,222 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "unknwon.dev/clog/v2"
+)
+
+// LoginSourceHealth is the result of health probes of a login source.
+type LoginSourceHealth struct {
+	SourceID            int64
+	IsUp                bool
+	Latency             time.Duration
+	LastError           string
+	LastChecked         time.Time
+	Failures            int64 // Total number of failed probes
+	ConsecutiveFailures int
+}
+
+var loginSourceHealths = struct {
+	sync.RWMutex
+	m map[int64]*LoginSourceHealth
+}{m: make(map[int64]*LoginSourceHealth)}
+
+// Health returns a copy of the latest health probe result of the login source, or nil
+// if the login source has not been probed.
+func (s *LoginSource) Health() *LoginSourceHealth {
+	loginSourceHealths.RLock()
+	defer loginSourceHealths.RUnlock()
+
+	h, ok := loginSourceHealths.m[s.ID]
+	if !ok {
+		return nil
+	}
+	clone := *h
+	return &clone
+}
+
+// IsProbeable returns true if health of the login source can be probed.
+func (s *LoginSource) IsProbeable() bool {
+	return s.IsLDAP() || s.IsDLDAP() || s.IsSMTP()
+}
+
+// pingSMTP checks if the SMTP server is reachable and supports authentication,
+// without authenticating any user.
+func pingSMTP(cfg *SMTPConfig) error {
+	conn, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", cfg.Host, cfg.Port), 10*time.Second)
+	if err != nil {
+		return err
+	}
+	c, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if err = c.Hello("gogs"); err != nil {
+		return err
+	}
+
+	if cfg.TLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("SMTP server unsupports TLS")
+		} else if err = c.StartTLS(&tls.Config{
+			InsecureSkipVerify: cfg.SkipVerify,
+			ServerName:         cfg.Host,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if ok, _ := c.Extension("AUTH"); !ok {
+		return fmt.Errorf("unsupported SMTP authentication method")
+	}
+	return c.Quit()
+}
+
+// probeLoginSource probes the login source and records the result.
+func probeLoginSource(s *LoginSource) {
+	start := time.Now()
+	var err error
+	switch {
+	case s.IsLDAP(), s.IsDLDAP():
+		err = s.LDAP().Ping()
+	case s.IsSMTP():
+		err = pingSMTP(s.SMTP())
+	default:
+		return
+	}
+	latency := time.Since(start).Round(time.Microsecond)
+
+	loginSourceHealths.Lock()
+	defer loginSourceHealths.Unlock()
+
+	h, ok := loginSourceHealths.m[s.ID]
+	if !ok {
+		h = &LoginSourceHealth{SourceID: s.ID}
+		loginSourceHealths.m[s.ID] = h
+	}
+	h.Latency = latency
+	h.LastChecked = start
+	if err != nil {
+		if h.IsUp || h.LastError == "" {
+			log.Warn("Login source %q [id: %d] is down: %v", s.Name, s.ID, err)
+		}
+		h.IsUp = false
+		h.LastError = err.Error()
+		h.Failures++
+		h.ConsecutiveFailures++
+		return
+	}
+
+	if !h.IsUp && h.LastError != "" {
+		log.Info("Login source %q [id: %d] is up again", s.Name, s.ID)
+	}
+	h.IsUp = true
+	h.LastError = ""
+	h.ConsecutiveFailures = 0
+}
+
+// CheckLoginSourcesHealth probes all activated LDAP and SMTP login sources.
+func CheckLoginSourcesHealth() {
+	if taskStatusTable.IsRunning(_CHECK_LOGIN_SOURCES) {
+		return
+	}
+	taskStatusTable.Start(_CHECK_LOGIN_SOURCES)
+	defer taskStatusTable.Stop(_CHECK_LOGIN_SOURCES)
+
+	log.Trace("Doing: CheckLoginSourcesHealth")
+
+	sources, err := ActivatedLoginSources()
+	if err != nil {
+		log.Error("ActivatedLoginSources: %v", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	activated := make(map[int64]bool, len(sources))
+	for _, s := range sources {
+		if !s.IsProbeable() {
+			continue
+		}
+		activated[s.ID] = true
+
+		wg.Add(1)
+		go func(s *LoginSource) {
+			defer wg.Done()
+			probeLoginSource(s)
+		}(s)
+	}
+	wg.Wait()
+
+	// Forget login sources that have been deleted or deactivated.
+	loginSourceHealths.Lock()
+	for id := range loginSourceHealths.m {
+		if !activated[id] {
+			delete(loginSourceHealths.m, id)
+		}
+	}
+	loginSourceHealths.Unlock()
+}
+
+// loginSourceHealthCollector exports health of login sources as Prometheus metrics.
+type loginSourceHealthCollector struct {
+	up       *prometheus.Desc
+	latency  *prometheus.Desc
+	failures *prometheus.Desc
+}
+
+// NewLoginSourceHealthCollector returns a Prometheus collector of health of login sources.
+func NewLoginSourceHealthCollector() prometheus.Collector {
+	labels := []string{"id", "name", "type"}
+	return &loginSourceHealthCollector{
+		up: prometheus.NewDesc("gogs_login_source_up",
+			"Whether the last health probe of the login source succeeded.", labels, nil),
+		latency: prometheus.NewDesc("gogs_login_source_probe_duration_seconds",
+			"Duration of the last health probe of the login source.", labels, nil),
+		failures: prometheus.NewDesc("gogs_login_source_probe_failures_total",
+			"Total number of failed health probes of the login source.", labels, nil),
+	}
+}
+
+func (c *loginSourceHealthCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.up
+	ch <- c.latency
+	ch <- c.failures
+}
+
+func (c *loginSourceHealthCollector) Collect(ch chan<- prometheus.Metric) {
+	sources, err := ActivatedLoginSources()
+	if err != nil {
+		log.Error("ActivatedLoginSources: %v", err)
+		return
+	}
+
+	for _, s := range sources {
+		h := s.Health()
+		if h == nil {
+			continue
+		}
+
+		labels := []string{fmt.Sprintf("%d", s.ID), s.Name, s.TypeName()}
+		var up float64
+		if h.IsUp {
+			up = 1
+		}
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, up, labels...)
+		ch <- prometheus.MustNewConstMetric(c.latency, prometheus.GaugeValue, h.Latency.Seconds(), labels...)
+		ch <- prometheus.MustNewConstMetric(c.failures, prometheus.CounterValue, float64(h.Failures), labels...)
+	}
+}
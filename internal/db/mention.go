@@ -0,0 +1,134 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+// recentParticipantsDays is the number of days that participants of issues and comments
+// are considered recent and mentionable in the repository.
+const recentParticipantsDays = 90
+
+// getRecentParticipantIDs returns IDs of users who have posted issues or comments in the
+// repository within recent days.
+func getRecentParticipantIDs(e Engine, repoID int64) ([]int64, error) {
+	since := time.Now().AddDate(0, 0, -recentParticipantsDays).Unix()
+
+	issuePosterIDs := make([]int64, 0, 10)
+	if err := e.Table("issue").Cols("poster_id").
+		Where("repo_id = ? AND created_unix >= ?", repoID, since).
+		Distinct("poster_id").
+		Find(&issuePosterIDs); err != nil {
+		return nil, fmt.Errorf("get issue poster IDs: %v", err)
+	}
+
+	commentPosterIDs := make([]int64, 0, 10)
+	if err := e.Table("comment").Cols("comment.poster_id").
+		Join("INNER", "issue", "issue.id = comment.issue_id").
+		Where("issue.repo_id = ? AND comment.created_unix >= ?", repoID, since).
+		Distinct("comment.poster_id").
+		Find(&commentPosterIDs); err != nil {
+		return nil, fmt.Errorf("get comment poster IDs: %v", err)
+	}
+
+	return append(issuePosterIDs, commentPosterIDs...), nil
+}
+
+// GetMentionableUsers returns users who can be mentioned in issues and pull requests of the
+// repository, including users who have read access, members of the owner organization and
+// recent participants. Only users who have read access are returned for private repositories.
+func (repo *Repository) GetMentionableUsers() ([]*User, error) {
+	readers, err := repo.getUsersWithAccesMode(x, ACCESS_MODE_READ)
+	if err != nil {
+		return nil, fmt.Errorf("getUsersWithAccesMode: %v", err)
+	}
+
+	users := make([]*User, 0, len(readers))
+	seen := make(map[int64]bool, len(readers))
+	for _, u := range readers {
+		if !seen[u.ID] {
+			seen[u.ID] = true
+			users = append(users, u)
+		}
+	}
+	if repo.IsPrivate {
+		// Other users must not be mentioned since they are not able to see the mention.
+		return users, nil
+	}
+
+	candidateIDs := make([]int64, 0, 10)
+	if repo.Owner.IsOrganization() {
+		ous, err := getOrgUsersByOrgID(x, repo.OwnerID)
+		if err != nil {
+			return nil, fmt.Errorf("getOrgUsersByOrgID: %v", err)
+		}
+		for _, ou := range ous {
+			candidateIDs = append(candidateIDs, ou.Uid)
+		}
+	}
+
+	participantIDs, err := getRecentParticipantIDs(x, repo.ID)
+	if err != nil {
+		return nil, fmt.Errorf("getRecentParticipantIDs: %v", err)
+	}
+	candidateIDs = append(candidateIDs, participantIDs...)
+
+	newIDs := make([]int64, 0, len(candidateIDs))
+	for _, id := range candidateIDs {
+		if !seen[id] {
+			seen[id] = true
+			newIDs = append(newIDs, id)
+		}
+	}
+	if len(newIDs) == 0 {
+		return users, nil
+	}
+
+	candidates, err := GetUsersByIDs(newIDs)
+	if err != nil {
+		return nil, fmt.Errorf("GetUsersByIDs: %v", err)
+	}
+	for _, u := range candidates {
+		if u.IsActive && !u.ProhibitLogin && !u.IsDeactivated {
+			users = append(users, u)
+		}
+	}
+	return users, nil
+}
+
+// GetParticipants returns the poster and users who have commented on the issue. Only users
+// who have read access are returned when the issue belongs to a private repository.
+func (issue *Issue) GetParticipants() ([]*User, error) {
+	if err := issue.loadAttributes(x); err != nil {
+		return nil, err
+	}
+
+	commenters, err := GetParticipantsByIssueID(issue.ID)
+	if err != nil {
+		return nil, fmt.Errorf("GetParticipantsByIssueID: %v", err)
+	}
+
+	users := make([]*User, 0, len(commenters)+1)
+	seen := make(map[int64]bool, len(commenters)+1)
+	for _, u := range append([]*User{issue.Poster}, commenters...) {
+		if u.ID <= 0 || seen[u.ID] {
+			continue
+		}
+		seen[u.ID] = true
+
+		if issue.Repo.IsPrivate && !u.IsAdmin {
+			has, err := hasAccess(x, u.ID, issue.Repo, ACCESS_MODE_READ)
+			if err != nil {
+				return nil, fmt.Errorf("hasAccess: %v", err)
+			} else if !has {
+				continue
+			}
+		}
+		users = append(users, u)
+	}
+	return users, nil
+}
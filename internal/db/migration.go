@@ -0,0 +1,906 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/unknwon/com"
+	log "unknwon.dev/clog/v2"
+	"xorm.io/xorm"
+
+	"github.com/gogs/git-module"
+
+	"gogs.io/gogs/internal/db/errors"
+	"gogs.io/gogs/internal/httplib"
+	"gogs.io/gogs/internal/sync"
+)
+
+// MigrationQueue is the queue of IDs of migration tasks to run.
+var MigrationQueue = sync.NewUniqueQueue(1000)
+
+// Services that issues, pull requests and releases can be imported from.
+const (
+	MIGRATION_SERVICE_GITHUB = "github"
+	MIGRATION_SERVICE_GITLAB = "gitlab"
+	// MIGRATION_SERVICE_ARCHIVE imports from a repository archive extracted on local disk.
+	MIGRATION_SERVICE_ARCHIVE = "archive"
+)
+
+type MigrationStatus int
+
+const (
+	MIGRATION_STATUS_QUEUED MigrationStatus = iota
+	MIGRATION_STATUS_RUNNING
+	MIGRATION_STATUS_FAILED
+	MIGRATION_STATUS_DONE
+)
+
+func (s MigrationStatus) String() string {
+	switch s {
+	case MIGRATION_STATUS_QUEUED:
+		return "queued"
+	case MIGRATION_STATUS_RUNNING:
+		return "running"
+	case MIGRATION_STATUS_FAILED:
+		return "failed"
+	case MIGRATION_STATUS_DONE:
+		return "done"
+	}
+	return "unknown"
+}
+
+// Stages of a migration task, which are run in the order as listed.
+const (
+	MIGRATION_STAGE_LABELS     = "labels"
+	MIGRATION_STAGE_MILESTONES = "milestones"
+	MIGRATION_STAGE_ISSUES     = "issues"
+	MIGRATION_STAGE_PULLS      = "pulls"
+	MIGRATION_STAGE_RELEASES   = "releases"
+	MIGRATION_STAGE_DONE       = "done"
+)
+
+// MigrationTask represents a task that imports data other than Git objects of a migrated
+// repository through API of the service it is migrated from. Progress is saved after each
+// imported item so that a failed or interrupted task resumes where it stopped.
+type MigrationTask struct {
+	ID      int64
+	RepoID  int64 `xorm:"UNIQUE"`
+	DoerID  int64
+	Service string
+	// APIURL is the directory that the archive is extracted to for service "archive".
+	APIURL string `xorm:"api_url"`
+	// Token is cleared once the task is done.
+	Token string `xorm:"TEXT" json:"-"`
+
+	Issues       bool `xorm:"NOT NULL DEFAULT false"`
+	PullRequests bool `xorm:"NOT NULL DEFAULT false"`
+	Releases     bool `xorm:"NOT NULL DEFAULT false"`
+
+	Status MigrationStatus
+	Stage  string
+	// Page is the page of remote items that the current stage works on.
+	Page int
+	// Cursor is the number of the last imported remote issue or pull request.
+	Cursor int64
+	// Message is the error that the task failed with.
+	Message string `xorm:"TEXT"`
+
+	NumLabels     int
+	NumMilestones int
+	NumIssues     int
+	NumPulls      int
+	NumComments   int
+	NumReleases   int
+
+	Created     time.Time `xorm:"-" json:"-"`
+	CreatedUnix int64
+	Updated     time.Time `xorm:"-" json:"-"`
+	UpdatedUnix int64
+}
+
+func (t *MigrationTask) BeforeInsert() {
+	t.CreatedUnix = time.Now().Unix()
+	t.UpdatedUnix = t.CreatedUnix
+}
+
+func (t *MigrationTask) BeforeUpdate() {
+	t.UpdatedUnix = time.Now().Unix()
+}
+
+func (t *MigrationTask) AfterSet(colName string, _ xorm.Cell) {
+	switch colName {
+	case "created_unix":
+		t.Created = time.Unix(t.CreatedUnix, 0).Local()
+	case "updated_unix":
+		t.Updated = time.Unix(t.UpdatedUnix, 0).Local()
+	}
+}
+
+// IsFailed returns true if the task stopped with an error.
+func (t *MigrationTask) IsFailed() bool {
+	return t.Status == MIGRATION_STATUS_FAILED
+}
+
+// IsFinished returns true if the task is not going to run anymore unless it is retried.
+func (t *MigrationTask) IsFinished() bool {
+	return t.IsFailed() || t.Status == MIGRATION_STATUS_DONE
+}
+
+// stages returns the stages to run with respect to what to import.
+func (t *MigrationTask) stages() []string {
+	var stages []string
+	if t.Issues || t.PullRequests {
+		stages = append(stages, MIGRATION_STAGE_LABELS, MIGRATION_STAGE_MILESTONES)
+	}
+	if t.Issues {
+		stages = append(stages, MIGRATION_STAGE_ISSUES)
+	}
+	if t.PullRequests {
+		stages = append(stages, MIGRATION_STAGE_PULLS)
+	}
+	if t.Releases {
+		stages = append(stages, MIGRATION_STAGE_RELEASES)
+	}
+	return append(stages, MIGRATION_STAGE_DONE)
+}
+
+// nextStage returns the stage after the current one.
+func (t *MigrationTask) nextStage() string {
+	stages := t.stages()
+	for i := range stages {
+		if stages[i] == t.Stage && i+1 < len(stages) {
+			return stages[i+1]
+		}
+	}
+	return MIGRATION_STAGE_DONE
+}
+
+// migrationAPIURL returns the API URL of the remote repository that is cloned from given address.
+func migrationAPIURL(service, remoteAddr string) (string, error) {
+	// Git data of an archive is cloned from the directory that the archive is extracted to.
+	if service == MIGRATION_SERVICE_ARCHIVE {
+		return filepath.Dir(remoteAddr), nil
+	}
+
+	u, err := url.Parse(remoteAddr)
+	if err != nil {
+		return "", err
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+
+	repoPath := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+	if !strings.Contains(repoPath, "/") {
+		return "", fmt.Errorf("invalid repository path %q", repoPath)
+	}
+
+	switch service {
+	case MIGRATION_SERVICE_GITHUB:
+		if strings.Count(repoPath, "/") != 1 {
+			return "", fmt.Errorf("invalid repository path %q", repoPath)
+		}
+		if u.Host == "github.com" {
+			return "https://api.github.com/repos/" + repoPath, nil
+		}
+		// GitHub Enterprise Server
+		return fmt.Sprintf("%s://%s/api/v3/repos/%s", u.Scheme, u.Host, repoPath), nil
+	case MIGRATION_SERVICE_GITLAB:
+		return fmt.Sprintf("%s://%s/api/v4/projects/%s", u.Scheme, u.Host, url.PathEscape(repoPath)), nil
+	}
+	return "", fmt.Errorf("unsupported service %q", service)
+}
+
+// MigrationOptions contains options of importing data through API of the service
+// that a repository is migrated from.
+type MigrationOptions struct {
+	Service      string
+	Token        string
+	Issues       bool
+	PullRequests bool
+	Releases     bool
+}
+
+// newMigrationTask creates and queues a new migration task of the repository.
+func newMigrationTask(doer *User, repo *Repository, remoteAddr string, opts MigrationOptions) error {
+	apiURL, err := migrationAPIURL(opts.Service, remoteAddr)
+	if err != nil {
+		return fmt.Errorf("migrationAPIURL: %v", err)
+	}
+
+	t := &MigrationTask{
+		RepoID:       repo.ID,
+		DoerID:       doer.ID,
+		Service:      opts.Service,
+		APIURL:       apiURL,
+		Token:        opts.Token,
+		Issues:       opts.Issues,
+		PullRequests: opts.PullRequests,
+		Releases:     opts.Releases,
+		Status:       MIGRATION_STATUS_QUEUED,
+		Page:         1,
+	}
+	t.Stage = t.stages()[0]
+	if _, err = x.Insert(t); err != nil {
+		return err
+	}
+
+	MigrationQueue.Add(t.ID)
+	return nil
+}
+
+// GetMigrationTaskByRepoID returns the migration task of the repository.
+func GetMigrationTaskByRepoID(repoID int64) (*MigrationTask, error) {
+	t := &MigrationTask{RepoID: repoID}
+	has, err := x.Get(t)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		return nil, errors.MigrationTaskNotExist{RepoID: repoID}
+	}
+	return t, nil
+}
+
+// RetryMigrationTask queues the failed task to resume from where it stopped.
+func RetryMigrationTask(t *MigrationTask) error {
+	if t.Status != MIGRATION_STATUS_FAILED {
+		return nil
+	}
+
+	t.Status = MIGRATION_STATUS_QUEUED
+	t.Message = ""
+	if _, err := x.ID(t.ID).Cols("status", "message", "updated_unix").Update(t); err != nil {
+		return err
+	}
+
+	MigrationQueue.Add(t.ID)
+	return nil
+}
+
+// saveProgress saves progress of the task.
+func (t *MigrationTask) saveProgress(e Engine) error {
+	_, err := e.ID(t.ID).Cols("token", "status", "stage", "page", "cursor", "message",
+		"num_labels", "num_milestones", "num_issues", "num_pulls", "num_comments", "num_releases",
+		"updated_unix").Update(t)
+	return err
+}
+
+// migrationPageSize is the number of items to request per page.
+const migrationPageSize = 100
+
+// migrationRequestTimeout is the timeout of each request to remote API.
+const migrationRequestTimeout = time.Minute
+
+// migrationClient is a client of remote API that returns JSON.
+type migrationClient struct {
+	baseURL string
+	headers map[string]string
+}
+
+// getJSON requests the path relative to base URL with given query and decodes the
+// response body into v.
+func (c *migrationClient) getJSON(path string, query url.Values, v interface{}) error {
+	reqURL := c.baseURL + path
+	if len(query) > 0 {
+		reqURL += "?" + query.Encode()
+	}
+	return c.do(httplib.Get(reqURL), "GET", path, v)
+}
+
+// sendJSON sends the body encoded in JSON to the path relative to base URL with given
+// method, and decodes the response body into v.
+func (c *migrationClient) sendJSON(method, path string, body, v interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	var req *httplib.Request
+	switch method {
+	case "POST":
+		req = httplib.Post(c.baseURL + path)
+	case "PATCH":
+		req = httplib.Patch(c.baseURL + path)
+	default:
+		return fmt.Errorf("unsupported method %q", method)
+	}
+	return c.do(req.Header("Content-Type", "application/json").Body(data), method, path, v)
+}
+
+func (c *migrationClient) do(req *httplib.Request, method, path string, v interface{}) error {
+	req = req.SetTimeout(migrationRequestTimeout, migrationRequestTimeout).
+		Header("Accept", "application/json")
+	for k, v := range c.headers {
+		req.Header(k, v)
+	}
+
+	resp, err := req.Response()
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		if resp.Header.Get("X-RateLimit-Remaining") == "0" ||
+			resp.Header.Get("RateLimit-Remaining") == "0" {
+			return fmt.Errorf("%s %s: API rate limit exceeded, retry later or use an access token", method, path)
+		}
+		data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s: unexpected status %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if err = json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%s %s: decode: %v", method, path, err)
+	}
+	return nil
+}
+
+// Items to import are also the format of exported repository archives, see ExportRepository.
+
+type migrationLabel struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+type migrationMilestone struct {
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	IsClosed    bool       `json:"is_closed"`
+	Deadline    *time.Time `json:"deadline,omitempty"`
+}
+
+type migrationIssue struct {
+	Number    int64     `json:"number"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	Author    string    `json:"author"`
+	AuthorURL string    `json:"author_url"`
+	URL       string    `json:"url"`
+	IsClosed  bool      `json:"is_closed"`
+	Labels    []string  `json:"labels"`
+	Milestone string    `json:"milestone"`
+	Created   time.Time `json:"created"`
+	Updated   time.Time `json:"updated"`
+}
+
+type migrationPull struct {
+	migrationIssue
+	// IsCrossRepo indicates whether head branch is in another repository, i.e. a fork.
+	IsCrossRepo    bool      `json:"is_cross_repo"`
+	HeadUserName   string    `json:"head_user_name"`
+	HeadBranch     string    `json:"head_branch"`
+	BaseBranch     string    `json:"base_branch"`
+	IsMerged       bool      `json:"is_merged"`
+	MergeBase      string    `json:"merge_base"`
+	MergedCommitID string    `json:"merged_commit_id"`
+	Merged         time.Time `json:"merged"`
+}
+
+type migrationComment struct {
+	Body      string    `json:"body"`
+	Author    string    `json:"author"`
+	AuthorURL string    `json:"author_url"`
+	Created   time.Time `json:"created"`
+	Updated   time.Time `json:"updated"`
+}
+
+type migrationRelease struct {
+	TagName      string    `json:"tag_name"`
+	Target       string    `json:"target"`
+	Title        string    `json:"title"`
+	Note         string    `json:"note"`
+	IsDraft      bool      `json:"is_draft"`
+	IsPrerelease bool      `json:"is_prerelease"`
+	Created      time.Time `json:"created"`
+}
+
+// migrationSource is the API of a service that data is imported from. Methods that
+// list items return whether there are more pages.
+type migrationSource interface {
+	labels(page int) ([]*migrationLabel, bool, error)
+	milestones(page int) ([]*migrationMilestone, bool, error)
+	// issues returns issues in ascending order of their numbers, pull requests are excluded.
+	issues(page int) ([]*migrationIssue, bool, error)
+	// pulls returns pull requests in ascending order of their numbers.
+	pulls(page int) ([]*migrationPull, bool, error)
+	// comments returns all comments of the issue or pull request in chronological order.
+	comments(issue *migrationIssue, isPull bool) ([]*migrationComment, error)
+	releases(page int) ([]*migrationRelease, bool, error)
+}
+
+func (t *MigrationTask) source() (migrationSource, error) {
+	switch t.Service {
+	case MIGRATION_SERVICE_GITHUB:
+		return newGitHubMigrationSource(t.APIURL, t.Token), nil
+	case MIGRATION_SERVICE_GITLAB:
+		return newGitLabMigrationSource(t.APIURL, t.Token), nil
+	case MIGRATION_SERVICE_ARCHIVE:
+		return newArchiveMigrationSource(t.APIURL), nil
+	}
+	return nil, fmt.Errorf("unsupported service %q", t.Service)
+}
+
+// migrationAttribution returns the line prepended to imported content to tell its
+// original author and location.
+func migrationAttribution(author, authorURL, originalURL string) string {
+	line := "*Originally posted by "
+	if authorURL != "" {
+		line += fmt.Sprintf("[%s](%s)", author, authorURL)
+	} else {
+		line += author
+	}
+	if originalURL != "" {
+		line += fmt.Sprintf(" in [%s](%s)", originalURL, originalURL)
+	}
+	return line + "*\n\n"
+}
+
+// run runs remaining stages of the task.
+func (t *MigrationTask) run() error {
+	src, err := t.source()
+	if err != nil {
+		return err
+	}
+	doer, err := GetUserByID(t.DoerID)
+	if err != nil {
+		return fmt.Errorf("GetUserByID [%d]: %v", t.DoerID, err)
+	}
+	repo, err := GetRepositoryByID(t.RepoID)
+	if err != nil {
+		return fmt.Errorf("GetRepositoryByID [%d]: %v", t.RepoID, err)
+	}
+
+	for t.Stage != MIGRATION_STAGE_DONE {
+		switch t.Stage {
+		case MIGRATION_STAGE_LABELS:
+			err = t.importLabels(src)
+		case MIGRATION_STAGE_MILESTONES:
+			err = t.importMilestones(src)
+		case MIGRATION_STAGE_ISSUES:
+			err = t.importIssues(src, doer)
+		case MIGRATION_STAGE_PULLS:
+			err = t.importPulls(src, doer, repo)
+		case MIGRATION_STAGE_RELEASES:
+			err = t.importReleases(src, doer, repo)
+		default:
+			err = fmt.Errorf("unknown stage %q", t.Stage)
+		}
+		if err != nil {
+			return fmt.Errorf("import %s: %v", t.Stage, err)
+		}
+
+		t.Stage = t.nextStage()
+		t.Page = 1
+		t.Cursor = 0
+		if err = t.saveProgress(x); err != nil {
+			return fmt.Errorf("save progress: %v", err)
+		}
+	}
+	return nil
+}
+
+// forEachPage calls fn with page numbers from the current page until there are no
+// more pages, and the progress is saved after each page.
+func (t *MigrationTask) forEachPage(fn func(page int) (hasMore bool, err error)) error {
+	for {
+		hasMore, err := fn(t.Page)
+		if err != nil {
+			return err
+		} else if !hasMore {
+			return nil
+		}
+
+		t.Page++
+		if err = t.saveProgress(x); err != nil {
+			return fmt.Errorf("save progress: %v", err)
+		}
+	}
+}
+
+// Labels and milestones are matched by names so that they are not duplicated when
+// the stage is resumed.
+
+func (t *MigrationTask) importLabels(src migrationSource) error {
+	return t.forEachPage(func(page int) (bool, error) {
+		labels, hasMore, err := src.labels(page)
+		if err != nil {
+			return false, err
+		}
+
+		for _, label := range labels {
+			if _, err = GetLabelOfRepoByName(t.RepoID, label.Name); err == nil {
+				continue
+			} else if !IsErrLabelNotExist(err) {
+				return false, fmt.Errorf("GetLabelOfRepoByName: %v", err)
+			}
+
+			color := "#" + strings.TrimPrefix(label.Color, "#")
+			if len(color) != 7 {
+				color = "#cccccc"
+			}
+			if err = NewLabels(&Label{
+				RepoID: t.RepoID,
+				Name:   label.Name,
+				Color:  color,
+			}); err != nil {
+				return false, fmt.Errorf("NewLabels: %v", err)
+			}
+			t.NumLabels++
+		}
+		return hasMore, nil
+	})
+}
+
+func (t *MigrationTask) importMilestones(src migrationSource) error {
+	existing, err := GetMilestonesByRepoID(t.RepoID)
+	if err != nil {
+		return fmt.Errorf("GetMilestonesByRepoID: %v", err)
+	}
+	names := make(map[string]bool, len(existing))
+	for i := range existing {
+		names[existing[i].Name] = true
+	}
+
+	return t.forEachPage(func(page int) (bool, error) {
+		milestones, hasMore, err := src.milestones(page)
+		if err != nil {
+			return false, err
+		}
+
+		for _, milestone := range milestones {
+			if names[milestone.Title] {
+				continue
+			}
+
+			m := &Milestone{
+				RepoID:  t.RepoID,
+				Name:    milestone.Title,
+				Content: milestone.Description,
+			}
+			if milestone.Deadline != nil {
+				m.Deadline = *milestone.Deadline
+			} else {
+				// Same as no deadline is given when creating a milestone.
+				m.Deadline, _ = time.ParseInLocation("2006-01-02", "9999-12-31", time.Local)
+			}
+			if err = NewMilestone(m); err != nil {
+				return false, fmt.Errorf("NewMilestone: %v", err)
+			}
+			if milestone.IsClosed {
+				if err = ChangeMilestoneStatus(m, true); err != nil {
+					return false, fmt.Errorf("ChangeMilestoneStatus: %v", err)
+				}
+			}
+			names[m.Name] = true
+			t.NumMilestones++
+		}
+		return hasMore, nil
+	})
+}
+
+// issueAttributeIDs returns IDs of labels and milestone that are known by names.
+func (t *MigrationTask) issueAttributeIDs(issue *migrationIssue) (labelIDs []int64, milestoneID int64, err error) {
+	for _, name := range issue.Labels {
+		label, err := GetLabelOfRepoByName(t.RepoID, name)
+		if err != nil {
+			if IsErrLabelNotExist(err) {
+				continue
+			}
+			return nil, 0, fmt.Errorf("GetLabelOfRepoByName: %v", err)
+		}
+		labelIDs = append(labelIDs, label.ID)
+	}
+
+	if issue.Milestone != "" {
+		m := &Milestone{
+			RepoID: t.RepoID,
+			Name:   issue.Milestone,
+		}
+		if _, err = x.Get(m); err != nil {
+			return nil, 0, fmt.Errorf("get milestone: %v", err)
+		}
+		milestoneID = m.ID
+	}
+	return labelIDs, milestoneID, nil
+}
+
+// importIssue imports the issue or pull request along with its comments, and saves
+// progress in the same transaction.
+func (t *MigrationTask) importIssue(src migrationSource, doer *User, mi *migrationIssue, pr *PullRequest) error {
+	comments, err := src.comments(mi, pr != nil)
+	if err != nil {
+		return fmt.Errorf("get comments of #%d: %v", mi.Number, err)
+	}
+	labelIDs, milestoneID, err := t.issueAttributeIDs(mi)
+	if err != nil {
+		return err
+	}
+
+	if mi.Updated.IsZero() {
+		mi.Updated = mi.Created
+	}
+
+	// Repository is reloaded to have the latest numbers of issues for next index.
+	repo, err := GetRepositoryByID(t.RepoID)
+	if err != nil {
+		return fmt.Errorf("GetRepositoryByID: %v", err)
+	}
+
+	issue := &Issue{
+		RepoID:      repo.ID,
+		Repo:        repo,
+		PosterID:    doer.ID,
+		Poster:      doer,
+		Title:       mi.Title,
+		Content:     migrationAttribution(mi.Author, mi.AuthorURL, mi.URL) + mi.Body,
+		MilestoneID: milestoneID,
+		IsClosed:    mi.IsClosed,
+		IsPull:      pr != nil,
+		CreatedUnix: mi.Created.Unix(),
+		UpdatedUnix: mi.Updated.Unix(),
+	}
+
+	sess := x.NewSession()
+	defer sess.Close()
+	if err = sess.Begin(); err != nil {
+		return err
+	}
+
+	if err = newIssue(sess, NewIssueOptions{
+		Repo:     repo,
+		Issue:    issue,
+		LableIDs: labelIDs,
+		IsPull:   issue.IsPull,
+	}); err != nil {
+		return fmt.Errorf("newIssue: %v", err)
+	}
+
+	if issue.IsClosed {
+		if err = updateIssueUsersByStatus(sess, issue.ID, true); err != nil {
+			return fmt.Errorf("updateIssueUsersByStatus: %v", err)
+		}
+		if issue.IsPull {
+			_, err = sess.Exec("UPDATE `repository` SET num_closed_pulls = num_closed_pulls + 1 WHERE id = ?", repo.ID)
+		} else {
+			_, err = sess.Exec("UPDATE `repository` SET num_closed_issues = num_closed_issues + 1 WHERE id = ?", repo.ID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if pr != nil {
+		pr.IssueID = issue.ID
+		pr.Index = issue.Index
+		if _, err = sess.Insert(pr); err != nil {
+			return fmt.Errorf("insert pull request: %v", err)
+		}
+	}
+
+	for _, comment := range comments {
+		if _, err = sess.Insert(&Comment{
+			Type:        COMMENT_TYPE_COMMENT,
+			PosterID:    doer.ID,
+			IssueID:     issue.ID,
+			Content:     migrationAttribution(comment.Author, comment.AuthorURL, "") + comment.Body,
+			CreatedUnix: comment.Created.Unix(),
+			UpdatedUnix: comment.Updated.Unix(),
+		}); err != nil {
+			return fmt.Errorf("insert comment: %v", err)
+		}
+	}
+	if len(comments) > 0 {
+		if _, err = sess.Exec("UPDATE `issue` SET num_comments = ? WHERE id = ?", len(comments), issue.ID); err != nil {
+			return err
+		}
+	}
+
+	t.Cursor = mi.Number
+	if issue.IsPull {
+		t.NumPulls++
+	} else {
+		t.NumIssues++
+	}
+	t.NumComments += len(comments)
+	if err = t.saveProgress(sess); err != nil {
+		return fmt.Errorf("save progress: %v", err)
+	}
+	return sess.Commit()
+}
+
+func (t *MigrationTask) importIssues(src migrationSource, doer *User) error {
+	return t.forEachPage(func(page int) (bool, error) {
+		issues, hasMore, err := src.issues(page)
+		if err != nil {
+			return false, err
+		}
+
+		for _, issue := range issues {
+			if issue.Number <= t.Cursor {
+				continue
+			}
+			if err = t.importIssue(src, doer, issue, nil); err != nil {
+				return false, fmt.Errorf("import #%d: %v", issue.Number, err)
+			}
+		}
+		return hasMore, nil
+	})
+}
+
+func (t *MigrationTask) importPulls(src migrationSource, doer *User, repo *Repository) error {
+	gitRepo, err := git.OpenRepository(repo.RepoPath())
+	if err != nil {
+		return fmt.Errorf("OpenRepository: %v", err)
+	}
+	isCommitExist := func(id string) bool {
+		if id == "" {
+			return false
+		}
+		_, err := gitRepo.GetCommit(id)
+		return err == nil
+	}
+
+	return t.forEachPage(func(page int) (bool, error) {
+		pulls, hasMore, err := src.pulls(page)
+		if err != nil {
+			return false, err
+		}
+
+		for _, pull := range pulls {
+			if pull.Number <= t.Cursor {
+				continue
+			}
+
+			pr := &PullRequest{
+				Type:         PULL_REQUEST_GOGS,
+				Status:       PULL_REQUEST_STATUS_MERGEABLE,
+				BaseRepoID:   repo.ID,
+				HeadUserName: repo.MustOwner().Name,
+				HeadBranch:   pull.HeadBranch,
+				BaseBranch:   pull.BaseBranch,
+			}
+			// Head branch of a fork has nowhere to be found, which is shown as deleted.
+			if pull.IsCrossRepo {
+				pr.HeadUserName = pull.HeadUserName
+			} else {
+				pr.HeadRepoID = repo.ID
+			}
+			// Merged information is only kept when commits are available to show.
+			if pull.IsMerged && isCommitExist(pull.MergeBase) && isCommitExist(pull.MergedCommitID) {
+				pr.HasMerged = true
+				pr.MergeBase = pull.MergeBase
+				pr.MergedCommitID = pull.MergedCommitID
+				pr.MergerID = doer.ID
+				pr.MergedUnix = pull.Merged.Unix()
+			}
+
+			if err = t.importIssue(src, doer, &pull.migrationIssue, pr); err != nil {
+				return false, fmt.Errorf("import #%d: %v", pull.Number, err)
+			}
+		}
+		return hasMore, nil
+	})
+}
+
+// importReleases imports published releases whose tags exist in the repository,
+// releases are matched by tag names so that they are not duplicated when the
+// stage is resumed.
+func (t *MigrationTask) importReleases(src migrationSource, doer *User, repo *Repository) error {
+	gitRepo, err := git.OpenRepository(repo.RepoPath())
+	if err != nil {
+		return fmt.Errorf("OpenRepository: %v", err)
+	}
+
+	return t.forEachPage(func(page int) (bool, error) {
+		releases, hasMore, err := src.releases(page)
+		if err != nil {
+			return false, err
+		}
+
+		for _, release := range releases {
+			if release.IsDraft || !gitRepo.IsTagExist(release.TagName) {
+				continue
+			}
+			if isExist, err := IsReleaseExist(t.RepoID, release.TagName); err != nil {
+				return false, fmt.Errorf("IsReleaseExist: %v", err)
+			} else if isExist {
+				continue
+			}
+
+			commit, err := gitRepo.GetTagCommit(release.TagName)
+			if err != nil {
+				return false, fmt.Errorf("GetTagCommit [%s]: %v", release.TagName, err)
+			}
+			numCommits, err := commit.CommitsCount()
+			if err != nil {
+				return false, fmt.Errorf("CommitsCount: %v", err)
+			}
+
+			title := release.Title
+			if title == "" {
+				title = release.TagName
+			}
+			// Target may be a commit rather than a branch, which is not known to releases.
+			target := release.Target
+			if !gitRepo.IsBranchExist(target) {
+				target = repo.DefaultBranch
+			}
+			if _, err = x.Insert(&Release{
+				RepoID:       t.RepoID,
+				PublisherID:  doer.ID,
+				TagName:      release.TagName,
+				LowerTagName: strings.ToLower(release.TagName),
+				Target:       target,
+				Title:        title,
+				Sha1:         commit.ID.String(),
+				NumCommits:   numCommits,
+				Note:         release.Note,
+				IsPrerelease: release.IsPrerelease,
+				CreatedUnix:  release.Created.Unix(),
+			}); err != nil {
+				return false, fmt.Errorf("insert release: %v", err)
+			}
+			t.NumReleases++
+		}
+		return hasMore, nil
+	})
+}
+
+// RunMigrations runs queued migration tasks.
+func RunMigrations() {
+	for id := range MigrationQueue.Queue() {
+		log.Trace("RunMigrations [id: %s]", id)
+		MigrationQueue.Remove(id)
+
+		t := new(MigrationTask)
+		has, err := x.ID(com.StrTo(id).MustInt64()).Get(t)
+		if err != nil {
+			log.Error("Get migration task [%s]: %v", id, err)
+			continue
+		} else if !has || t.IsFinished() {
+			continue
+		}
+
+		t.Status = MIGRATION_STATUS_RUNNING
+		if err = t.saveProgress(x); err != nil {
+			log.Error("Update migration task [%d]: %v", t.ID, err)
+			continue
+		}
+
+		if err = t.run(); err != nil {
+			log.Error("Failed to run migration task %d of repository %d: %v", t.ID, t.RepoID, err)
+			t.Status = MIGRATION_STATUS_FAILED
+			t.Message = err.Error()
+		} else {
+			t.Status = MIGRATION_STATUS_DONE
+			t.Token = ""
+			if t.Service == MIGRATION_SERVICE_ARCHIVE {
+				RemoveAllWithNotice("Delete extracted repository archive", t.APIURL)
+			}
+		}
+		if err = t.saveProgress(x); err != nil {
+			log.Error("Update migration task [%d]: %v", t.ID, err)
+		}
+	}
+}
+
+// InitMigrations resumes unfinished migration tasks and starts running queued tasks.
+func InitMigrations() {
+	tasks := make([]*MigrationTask, 0, 10)
+	if err := x.In("status", MIGRATION_STATUS_QUEUED, MIGRATION_STATUS_RUNNING).Find(&tasks); err != nil {
+		log.Error("Find unfinished migration tasks: %v", err)
+	}
+	for i := range tasks {
+		MigrationQueue.Add(tasks[i].ID)
+	}
+
+	go RunMigrations()
+}
@@ -0,0 +1,113 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Files of items in a repository archive.
+const (
+	repoArchiveMetadata   = "metadata.json"
+	repoArchiveLabels     = "labels.json"
+	repoArchiveMilestones = "milestones.json"
+	repoArchiveIssues     = "issues.json"
+	repoArchivePulls      = "pulls.json"
+	repoArchiveReleases   = "releases.json"
+)
+
+// archiveIssue is an issue in a repository archive along with its comments.
+type archiveIssue struct {
+	migrationIssue
+	Comments []*migrationComment `json:"comments"`
+}
+
+// archivePull is a pull request in a repository archive along with its comments.
+type archivePull struct {
+	migrationPull
+	Comments []*migrationComment `json:"comments"`
+}
+
+// archiveMigrationSource imports data from a repository archive extracted on local disk.
+// All items are listed in the first page.
+type archiveMigrationSource struct {
+	dir string
+	// issueComments are comments of issues and pull requests by their numbers.
+	issueComments map[int64][]*migrationComment
+}
+
+func newArchiveMigrationSource(dir string) *archiveMigrationSource {
+	return &archiveMigrationSource{
+		dir:           dir,
+		issueComments: make(map[int64][]*migrationComment),
+	}
+}
+
+// readJSON decodes the file of items in the archive into v, it is a no-op if the file
+// does not exist.
+func (s *archiveMigrationSource) readJSON(name string, v interface{}) error {
+	data, err := ioutil.ReadFile(filepath.Join(s.dir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if err = json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("decode %s: %v", name, err)
+	}
+	return nil
+}
+
+func (s *archiveMigrationSource) labels(page int) ([]*migrationLabel, bool, error) {
+	var labels []*migrationLabel
+	return labels, false, s.readJSON(repoArchiveLabels, &labels)
+}
+
+func (s *archiveMigrationSource) milestones(page int) ([]*migrationMilestone, bool, error) {
+	var milestones []*migrationMilestone
+	return milestones, false, s.readJSON(repoArchiveMilestones, &milestones)
+}
+
+func (s *archiveMigrationSource) issues(page int) ([]*migrationIssue, bool, error) {
+	var issues []*archiveIssue
+	if err := s.readJSON(repoArchiveIssues, &issues); err != nil {
+		return nil, false, err
+	}
+
+	results := make([]*migrationIssue, len(issues))
+	for i := range issues {
+		results[i] = &issues[i].migrationIssue
+		s.issueComments[issues[i].Number] = issues[i].Comments
+	}
+	return results, false, nil
+}
+
+func (s *archiveMigrationSource) pulls(page int) ([]*migrationPull, bool, error) {
+	var pulls []*archivePull
+	if err := s.readJSON(repoArchivePulls, &pulls); err != nil {
+		return nil, false, err
+	}
+
+	results := make([]*migrationPull, len(pulls))
+	for i := range pulls {
+		results[i] = &pulls[i].migrationPull
+		s.issueComments[pulls[i].Number] = pulls[i].Comments
+	}
+	return results, false, nil
+}
+
+func (s *archiveMigrationSource) comments(issue *migrationIssue, isPull bool) ([]*migrationComment, error) {
+	return s.issueComments[issue.Number], nil
+}
+
+func (s *archiveMigrationSource) releases(page int) ([]*migrationRelease, bool, error) {
+	var releases []*migrationRelease
+	return releases, false, s.readJSON(repoArchiveReleases, &releases)
+}
@@ -0,0 +1,265 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/unknwon/com"
+)
+
+// githubMigrationSource imports data through GitHub REST API v3.
+type githubMigrationSource struct {
+	client *migrationClient
+}
+
+func newGitHubMigrationSource(apiURL, token string) *githubMigrationSource {
+	headers := map[string]string{
+		"Accept": "application/vnd.github.v3+json",
+	}
+	if token != "" {
+		headers["Authorization"] = "token " + token
+	}
+	return &githubMigrationSource{
+		client: &migrationClient{
+			baseURL: apiURL,
+			headers: headers,
+		},
+	}
+}
+
+func githubPageQuery(page int) url.Values {
+	return url.Values{
+		"page":     []string{com.ToStr(page)},
+		"per_page": []string{com.ToStr(migrationPageSize)},
+	}
+}
+
+// githubListQuery returns query of listing issues or pull requests in ascending order
+// of creation, which is the same as numbers.
+func githubListQuery(page int) url.Values {
+	query := githubPageQuery(page)
+	query.Set("state", "all")
+	query.Set("sort", "created")
+	query.Set("direction", "asc")
+	return query
+}
+
+type githubUser struct {
+	Login   string `json:"login"`
+	HTMLURL string `json:"html_url"`
+}
+
+type githubLabel struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+type githubMilestone struct {
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	State       string     `json:"state"`
+	DueOn       *time.Time `json:"due_on"`
+}
+
+type githubIssue struct {
+	Number      int64            `json:"number"`
+	Title       string           `json:"title"`
+	Body        string           `json:"body"`
+	User        githubUser       `json:"user"`
+	HTMLURL     string           `json:"html_url"`
+	State       string           `json:"state"`
+	Labels      []githubLabel    `json:"labels"`
+	Milestone   *githubMilestone `json:"milestone"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+	PullRequest *struct{}        `json:"pull_request"`
+}
+
+func (issue *githubIssue) toMigrationIssue() *migrationIssue {
+	mi := &migrationIssue{
+		Number:    issue.Number,
+		Title:     issue.Title,
+		Body:      issue.Body,
+		Author:    issue.User.Login,
+		AuthorURL: issue.User.HTMLURL,
+		URL:       issue.HTMLURL,
+		IsClosed:  issue.State == "closed",
+		Created:   issue.CreatedAt,
+		Updated:   issue.UpdatedAt,
+	}
+	for _, label := range issue.Labels {
+		mi.Labels = append(mi.Labels, label.Name)
+	}
+	if issue.Milestone != nil {
+		mi.Milestone = issue.Milestone.Title
+	}
+	return mi
+}
+
+type githubPullBranch struct {
+	Ref  string      `json:"ref"`
+	SHA  string      `json:"sha"`
+	User *githubUser `json:"user"`
+	Repo *struct {
+		FullName string `json:"full_name"`
+	} `json:"repo"`
+}
+
+type githubPull struct {
+	githubIssue
+	Head           githubPullBranch `json:"head"`
+	Base           githubPullBranch `json:"base"`
+	MergedAt       *time.Time       `json:"merged_at"`
+	MergeCommitSHA string           `json:"merge_commit_sha"`
+}
+
+type githubComment struct {
+	ID        int64      `json:"id"`
+	Body      string     `json:"body"`
+	User      githubUser `json:"user"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+type githubRelease struct {
+	TagName         string    `json:"tag_name"`
+	TargetCommitish string    `json:"target_commitish"`
+	Name            string    `json:"name"`
+	Body            string    `json:"body"`
+	Draft           bool      `json:"draft"`
+	Prerelease      bool      `json:"prerelease"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+func (s *githubMigrationSource) labels(page int) ([]*migrationLabel, bool, error) {
+	var labels []*githubLabel
+	if err := s.client.getJSON("/labels", githubPageQuery(page), &labels); err != nil {
+		return nil, false, err
+	}
+
+	results := make([]*migrationLabel, len(labels))
+	for i := range labels {
+		results[i] = &migrationLabel{
+			Name:  labels[i].Name,
+			Color: labels[i].Color,
+		}
+	}
+	return results, len(labels) == migrationPageSize, nil
+}
+
+func (s *githubMigrationSource) milestones(page int) ([]*migrationMilestone, bool, error) {
+	query := githubPageQuery(page)
+	query.Set("state", "all")
+	var milestones []*githubMilestone
+	if err := s.client.getJSON("/milestones", query, &milestones); err != nil {
+		return nil, false, err
+	}
+
+	results := make([]*migrationMilestone, len(milestones))
+	for i := range milestones {
+		results[i] = &migrationMilestone{
+			Title:       milestones[i].Title,
+			Description: milestones[i].Description,
+			IsClosed:    milestones[i].State == "closed",
+			Deadline:    milestones[i].DueOn,
+		}
+	}
+	return results, len(milestones) == migrationPageSize, nil
+}
+
+func (s *githubMigrationSource) issues(page int) ([]*migrationIssue, bool, error) {
+	var issues []*githubIssue
+	if err := s.client.getJSON("/issues", githubListQuery(page), &issues); err != nil {
+		return nil, false, err
+	}
+
+	results := make([]*migrationIssue, 0, len(issues))
+	for i := range issues {
+		// Pull requests are also issues in GitHub, they are imported separately.
+		if issues[i].PullRequest != nil {
+			continue
+		}
+		results = append(results, issues[i].toMigrationIssue())
+	}
+	return results, len(issues) == migrationPageSize, nil
+}
+
+func (s *githubMigrationSource) pulls(page int) ([]*migrationPull, bool, error) {
+	var pulls []*githubPull
+	if err := s.client.getJSON("/pulls", githubListQuery(page), &pulls); err != nil {
+		return nil, false, err
+	}
+
+	results := make([]*migrationPull, len(pulls))
+	for i, pull := range pulls {
+		mp := &migrationPull{
+			migrationIssue: *pull.toMigrationIssue(),
+			HeadBranch:     pull.Head.Ref,
+			BaseBranch:     pull.Base.Ref,
+			MergeBase:      pull.Base.SHA,
+			MergedCommitID: pull.MergeCommitSHA,
+		}
+		// Repository of head branch is gone when the fork has been deleted.
+		if pull.Head.Repo == nil || pull.Base.Repo == nil || pull.Head.Repo.FullName != pull.Base.Repo.FullName {
+			mp.IsCrossRepo = true
+			if pull.Head.User != nil {
+				mp.HeadUserName = pull.Head.User.Login
+			}
+		}
+		if pull.MergedAt != nil {
+			mp.IsMerged = true
+			mp.Merged = *pull.MergedAt
+		}
+		results[i] = mp
+	}
+	return results, len(pulls) == migrationPageSize, nil
+}
+
+func (s *githubMigrationSource) comments(issue *migrationIssue, _ bool) ([]*migrationComment, error) {
+	var results []*migrationComment
+	for page := 1; ; page++ {
+		var comments []*githubComment
+		if err := s.client.getJSON(fmt.Sprintf("/issues/%d/comments", issue.Number), githubPageQuery(page), &comments); err != nil {
+			return nil, err
+		}
+
+		for _, comment := range comments {
+			results = append(results, &migrationComment{
+				Body:      comment.Body,
+				Author:    comment.User.Login,
+				AuthorURL: comment.User.HTMLURL,
+				Created:   comment.CreatedAt,
+				Updated:   comment.UpdatedAt,
+			})
+		}
+		if len(comments) < migrationPageSize {
+			return results, nil
+		}
+	}
+}
+
+func (s *githubMigrationSource) releases(page int) ([]*migrationRelease, bool, error) {
+	var releases []*githubRelease
+	if err := s.client.getJSON("/releases", githubPageQuery(page), &releases); err != nil {
+		return nil, false, err
+	}
+
+	results := make([]*migrationRelease, len(releases))
+	for i := range releases {
+		results[i] = &migrationRelease{
+			TagName:      releases[i].TagName,
+			Target:       releases[i].TargetCommitish,
+			Title:        releases[i].Name,
+			Note:         releases[i].Body,
+			IsDraft:      releases[i].Draft,
+			IsPrerelease: releases[i].Prerelease,
+			Created:      releases[i].CreatedAt,
+		}
+	}
+	return results, len(releases) == migrationPageSize, nil
+}
@@ -0,0 +1,269 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/unknwon/com"
+)
+
+// gitlabMigrationSource imports data through GitLab REST API v4.
+type gitlabMigrationSource struct {
+	client *migrationClient
+}
+
+func newGitLabMigrationSource(apiURL, token string) *gitlabMigrationSource {
+	headers := make(map[string]string)
+	if token != "" {
+		headers["PRIVATE-TOKEN"] = token
+	}
+	return &gitlabMigrationSource{
+		client: &migrationClient{
+			baseURL: apiURL,
+			headers: headers,
+		},
+	}
+}
+
+func gitlabPageQuery(page int) url.Values {
+	return url.Values{
+		"page":     []string{com.ToStr(page)},
+		"per_page": []string{com.ToStr(migrationPageSize)},
+	}
+}
+
+// gitlabListQuery returns query of listing issues, merge requests or notes in
+// ascending order of creation.
+func gitlabListQuery(page int) url.Values {
+	query := gitlabPageQuery(page)
+	query.Set("order_by", "created_at")
+	query.Set("sort", "asc")
+	return query
+}
+
+type gitlabUser struct {
+	Username string `json:"username"`
+	WebURL   string `json:"web_url"`
+}
+
+type gitlabLabel struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+type gitlabMilestone struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	State       string `json:"state"`
+	DueDate     string `json:"due_date"`
+}
+
+type gitlabIssue struct {
+	IID       int64            `json:"iid"`
+	Title     string           `json:"title"`
+	Desc      string           `json:"description"`
+	Author    gitlabUser       `json:"author"`
+	WebURL    string           `json:"web_url"`
+	State     string           `json:"state"`
+	Labels    []string         `json:"labels"`
+	Milestone *gitlabMilestone `json:"milestone"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+}
+
+func (issue *gitlabIssue) toMigrationIssue() *migrationIssue {
+	mi := &migrationIssue{
+		Number:    issue.IID,
+		Title:     issue.Title,
+		Body:      issue.Desc,
+		Author:    issue.Author.Username,
+		AuthorURL: issue.Author.WebURL,
+		URL:       issue.WebURL,
+		IsClosed:  issue.State != "opened",
+		Labels:    issue.Labels,
+		Created:   issue.CreatedAt,
+		Updated:   issue.UpdatedAt,
+	}
+	if issue.Milestone != nil {
+		mi.Milestone = issue.Milestone.Title
+	}
+	return mi
+}
+
+type gitlabMergeRequest struct {
+	gitlabIssue
+	SourceProjectID int64      `json:"source_project_id"`
+	TargetProjectID int64      `json:"target_project_id"`
+	SourceBranch    string     `json:"source_branch"`
+	TargetBranch    string     `json:"target_branch"`
+	MergeCommitSHA  string     `json:"merge_commit_sha"`
+	SquashCommitSHA string     `json:"squash_commit_sha"`
+	MergedAt        *time.Time `json:"merged_at"`
+	DiffRefs        *struct {
+		BaseSHA string `json:"base_sha"`
+	} `json:"diff_refs"`
+}
+
+type gitlabNote struct {
+	Body      string     `json:"body"`
+	Author    gitlabUser `json:"author"`
+	System    bool       `json:"system"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+type gitlabRelease struct {
+	TagName     string    `json:"tag_name"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	Upcoming    bool      `json:"upcoming_release"`
+	Commit      struct {
+		ID string `json:"id"`
+	} `json:"commit"`
+}
+
+func (s *gitlabMigrationSource) labels(page int) ([]*migrationLabel, bool, error) {
+	var labels []*gitlabLabel
+	if err := s.client.getJSON("/labels", gitlabPageQuery(page), &labels); err != nil {
+		return nil, false, err
+	}
+
+	results := make([]*migrationLabel, len(labels))
+	for i := range labels {
+		results[i] = &migrationLabel{
+			Name:  labels[i].Name,
+			Color: labels[i].Color,
+		}
+	}
+	return results, len(labels) == migrationPageSize, nil
+}
+
+func (s *gitlabMigrationSource) milestones(page int) ([]*migrationMilestone, bool, error) {
+	var milestones []*gitlabMilestone
+	if err := s.client.getJSON("/milestones", gitlabPageQuery(page), &milestones); err != nil {
+		return nil, false, err
+	}
+
+	results := make([]*migrationMilestone, len(milestones))
+	for i := range milestones {
+		results[i] = &migrationMilestone{
+			Title:       milestones[i].Title,
+			Description: milestones[i].Description,
+			IsClosed:    milestones[i].State == "closed",
+		}
+		if milestones[i].DueDate != "" {
+			deadline, err := time.ParseInLocation("2006-01-02", milestones[i].DueDate, time.Local)
+			if err == nil {
+				results[i].Deadline = &deadline
+			}
+		}
+	}
+	return results, len(milestones) == migrationPageSize, nil
+}
+
+func (s *gitlabMigrationSource) issues(page int) ([]*migrationIssue, bool, error) {
+	var issues []*gitlabIssue
+	if err := s.client.getJSON("/issues", gitlabListQuery(page), &issues); err != nil {
+		return nil, false, err
+	}
+
+	results := make([]*migrationIssue, len(issues))
+	for i := range issues {
+		results[i] = issues[i].toMigrationIssue()
+	}
+	return results, len(issues) == migrationPageSize, nil
+}
+
+func (s *gitlabMigrationSource) pulls(page int) ([]*migrationPull, bool, error) {
+	var mrs []*gitlabMergeRequest
+	if err := s.client.getJSON("/merge_requests", gitlabListQuery(page), &mrs); err != nil {
+		return nil, false, err
+	}
+
+	results := make([]*migrationPull, len(mrs))
+	for i, mr := range mrs {
+		mp := &migrationPull{
+			migrationIssue: *mr.toMigrationIssue(),
+			IsCrossRepo:    mr.SourceProjectID != mr.TargetProjectID,
+			HeadUserName:   mr.Author.Username,
+			HeadBranch:     mr.SourceBranch,
+			BaseBranch:     mr.TargetBranch,
+			MergedCommitID: mr.MergeCommitSHA,
+		}
+		if mp.MergedCommitID == "" {
+			mp.MergedCommitID = mr.SquashCommitSHA
+		}
+		if mr.DiffRefs != nil {
+			mp.MergeBase = mr.DiffRefs.BaseSHA
+		}
+		if mr.State == "merged" {
+			mp.IsMerged = true
+			if mr.MergedAt != nil {
+				mp.Merged = *mr.MergedAt
+			} else {
+				mp.Merged = mr.UpdatedAt
+			}
+		}
+		results[i] = mp
+	}
+	return results, len(mrs) == migrationPageSize, nil
+}
+
+func (s *gitlabMigrationSource) comments(issue *migrationIssue, isPull bool) ([]*migrationComment, error) {
+	path := fmt.Sprintf("/issues/%d/notes", issue.Number)
+	if isPull {
+		path = fmt.Sprintf("/merge_requests/%d/notes", issue.Number)
+	}
+
+	var results []*migrationComment
+	for page := 1; ; page++ {
+		var notes []*gitlabNote
+		if err := s.client.getJSON(path, gitlabListQuery(page), &notes); err != nil {
+			return nil, err
+		}
+
+		for _, note := range notes {
+			// System notes are events like status changes rather than comments.
+			if note.System {
+				continue
+			}
+			results = append(results, &migrationComment{
+				Body:      note.Body,
+				Author:    note.Author.Username,
+				AuthorURL: note.Author.WebURL,
+				Created:   note.CreatedAt,
+				Updated:   note.UpdatedAt,
+			})
+		}
+		if len(notes) < migrationPageSize {
+			return results, nil
+		}
+	}
+}
+
+func (s *gitlabMigrationSource) releases(page int) ([]*migrationRelease, bool, error) {
+	var releases []*gitlabRelease
+	if err := s.client.getJSON("/releases", gitlabPageQuery(page), &releases); err != nil {
+		return nil, false, err
+	}
+
+	results := make([]*migrationRelease, len(releases))
+	for i := range releases {
+		results[i] = &migrationRelease{
+			TagName: releases[i].TagName,
+			Target:  releases[i].Commit.ID,
+			Title:   releases[i].Name,
+			Note:    releases[i].Description,
+			// Upcoming releases are treated as drafts that are not published yet.
+			IsDraft: releases[i].Upcoming,
+			Created: releases[i].CreatedAt,
+		}
+	}
+	return results, len(releases) == migrationPageSize, nil
+}
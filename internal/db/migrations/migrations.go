@@ -68,6 +68,14 @@ var migrations = []Migration{
 	NewMigration("store long text in repository description field", updateRepositoryDescriptionField),
 	// v18 -> v19:v0.11.55
 	NewMigration("clean unlinked webhook and hook_tasks", cleanUnlinkedWebhookAndHookTasks),
+	// v19 -> v20:v0.12.0
+	NewMigration("backfill issue history of milestones", backfillMilestoneIssueHistory),
+	// v20 -> v21:v0.12.0
+	NewMigration("backfill star events of repositories", backfillStarEvents),
+	// v21 -> v22:v0.12.0
+	NewMigration("renumber access modes for triage and maintain roles", renumberAccessModes),
+	// v22 -> v23:v0.12.0
+	NewMigration("backfill search names of users", backfillUserSearchNames),
 }
 
 // Migrate database to current version
@@ -0,0 +1,78 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"fmt"
+
+	"xorm.io/xorm"
+)
+
+func backfillMilestoneIssueHistory(x *xorm.Engine) (err error) {
+	type Issue struct {
+		ID          int64
+		RepoID      int64
+		MilestoneID int64
+		IsPull      bool
+		IsClosed    bool
+		CreatedUnix int64
+		UpdatedUnix int64
+	}
+	type IssueHistory struct {
+		ID          int64
+		RepoID      int64 `xorm:"INDEX"`
+		IssueID     int64 `xorm:"INDEX"`
+		MilestoneID int64 `xorm:"INDEX"`
+		IsPull      bool
+		IsClosed    bool
+		Type        int
+		CreatedUnix int64 `xorm:"INDEX"`
+	}
+	if err = x.Sync2(new(IssueHistory)); err != nil {
+		return fmt.Errorf("Sync2: %v", err)
+	}
+
+	const (
+		historyMilestoneAdd = 1
+		historyClose        = 3
+	)
+	var lastID int64
+	for {
+		issues := make([]*Issue, 0, 100)
+		if err = x.Where("id > ? AND milestone_id > 0", lastID).Asc("id").Limit(100).Find(&issues); err != nil {
+			return fmt.Errorf("find issues [last_id: %d]: %v", lastID, err)
+		} else if len(issues) == 0 {
+			return nil
+		}
+		lastID = issues[len(issues)-1].ID
+
+		histories := make([]*IssueHistory, 0, len(issues)*2)
+		for _, issue := range issues {
+			histories = append(histories, &IssueHistory{
+				RepoID:      issue.RepoID,
+				IssueID:     issue.ID,
+				MilestoneID: issue.MilestoneID,
+				IsPull:      issue.IsPull,
+				Type:        historyMilestoneAdd,
+				CreatedUnix: issue.CreatedUnix,
+			})
+			// There is no record of when the issue was closed, last update is the best guess.
+			if issue.IsClosed {
+				histories = append(histories, &IssueHistory{
+					RepoID:      issue.RepoID,
+					IssueID:     issue.ID,
+					MilestoneID: issue.MilestoneID,
+					IsPull:      issue.IsPull,
+					IsClosed:    true,
+					Type:        historyClose,
+					CreatedUnix: issue.UpdatedUnix,
+				})
+			}
+		}
+		if _, err = x.Insert(&histories); err != nil {
+			return fmt.Errorf("insert histories: %v", err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"fmt"
+
+	"xorm.io/xorm"
+)
+
+func backfillStarEvents(x *xorm.Engine) (err error) {
+	type Star struct {
+		ID     int64
+		UID    int64
+		RepoID int64
+	}
+	type Repository struct {
+		ID          int64
+		CreatedUnix int64
+	}
+	type StarEvent struct {
+		ID          int64
+		RepoID      int64 `xorm:"INDEX"`
+		UserID      int64
+		IsStar      bool
+		NumStars    int
+		CreatedUnix int64 `xorm:"INDEX"`
+	}
+	if err = x.Sync2(new(StarEvent)); err != nil {
+		return fmt.Errorf("Sync2: %v", err)
+	}
+
+	// There is no record of when existing stars were given, they are counted from
+	// the time the repository was created.
+	numStars := make(map[int64]int)
+	createdUnix := make(map[int64]int64)
+	var lastID int64
+	for {
+		stars := make([]*Star, 0, 100)
+		if err = x.Where("id > ?", lastID).Asc("id").Limit(100).Find(&stars); err != nil {
+			return fmt.Errorf("find stars [last_id: %d]: %v", lastID, err)
+		} else if len(stars) == 0 {
+			return nil
+		}
+		lastID = stars[len(stars)-1].ID
+
+		events := make([]*StarEvent, 0, len(stars))
+		for _, star := range stars {
+			created, ok := createdUnix[star.RepoID]
+			if !ok {
+				repo := new(Repository)
+				if _, err = x.ID(star.RepoID).Get(repo); err != nil {
+					return fmt.Errorf("get repository [id: %d]: %v", star.RepoID, err)
+				}
+				created = repo.CreatedUnix
+				createdUnix[star.RepoID] = created
+			}
+
+			numStars[star.RepoID]++
+			events = append(events, &StarEvent{
+				RepoID:      star.RepoID,
+				UserID:      star.UID,
+				IsStar:      true,
+				NumStars:    numStars[star.RepoID],
+				CreatedUnix: created,
+			})
+		}
+		if _, err = x.Insert(&events); err != nil {
+			return fmt.Errorf("insert events: %v", err)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"fmt"
+
+	"xorm.io/xorm"
+)
+
+// renumberAccessModes makes room for triage and maintain access modes, which are
+// placed between read and write, and between write and admin respectively.
+func renumberAccessModes(x *xorm.Engine) error {
+	columns := []struct {
+		table, column string
+	}{
+		{"access", "mode"},
+		{"collaboration", "mode"},
+		{"team", "authorize"},
+		{"public_key", "mode"},
+	}
+
+	sess := x.NewSession()
+	defer sess.Close()
+	if err := sess.Begin(); err != nil {
+		return err
+	}
+
+	// Old values: read=1, write=2, admin=3, owner=4
+	// New values: read=1, triage=2, write=3, maintain=4, admin=5, owner=6
+	for _, c := range columns {
+		if _, err := sess.Exec(fmt.Sprintf(
+			"UPDATE %[1]s SET %[2]s = CASE %[2]s WHEN 2 THEN 3 WHEN 3 THEN 5 WHEN 4 THEN 6 ELSE %[2]s END",
+			c.table, c.column)); err != nil {
+			return fmt.Errorf("update %s.%s: %v", c.table, c.column, err)
+		}
+	}
+	return sess.Commit()
+}
@@ -0,0 +1,50 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"fmt"
+	"strings"
+
+	"xorm.io/xorm"
+
+	"gogs.io/gogs/internal/tool"
+)
+
+// backfillUserSearchNames adds case and diacritic folded names of users, which are
+// matched by user search.
+func backfillUserSearchNames(x *xorm.Engine) (err error) {
+	type User struct {
+		ID         int64
+		Name       string
+		FullName   string
+		SearchName string `xorm:"NOT NULL DEFAULT ''"`
+	}
+	if err = x.Sync2(new(User)); err != nil {
+		return fmt.Errorf("Sync2: %v", err)
+	}
+
+	var lastID int64
+	for {
+		users := make([]*User, 0, 100)
+		if err = x.Where("id > ?", lastID).Asc("id").Limit(100).Find(&users); err != nil {
+			return fmt.Errorf("find users [last_id: %d]: %v", lastID, err)
+		} else if len(users) == 0 {
+			return nil
+		}
+		lastID = users[len(users)-1].ID
+
+		for _, u := range users {
+			searchName := []rune(tool.FoldString(strings.TrimSpace(u.Name + " " + u.FullName)))
+			if len(searchName) > 255 {
+				searchName = searchName[:255]
+			}
+			u.SearchName = string(searchName)
+			if _, err = x.ID(u.ID).Cols("search_name").Update(u); err != nil {
+				return fmt.Errorf("update user [id: %d]: %v", u.ID, err)
+			}
+		}
+	}
+}
@@ -239,15 +239,20 @@ func changeMilestoneIssueStats(e *xorm.Session, issue *Issue) error {
 		return err
 	}
 
+	historyType := ISSUE_HISTORY_REOPEN
 	if issue.IsClosed {
 		m.NumOpenIssues--
 		m.NumClosedIssues++
+		historyType = ISSUE_HISTORY_CLOSE
 	} else {
 		m.NumOpenIssues++
 		m.NumClosedIssues--
 	}
 
-	return updateMilestone(e, m)
+	if err = updateMilestone(e, m); err != nil {
+		return err
+	}
+	return createIssueHistory(e, issue, m.ID, historyType)
 }
 
 // ChangeMilestoneIssueStats updates the open/closed issues counter and progress
@@ -283,6 +288,9 @@ func changeMilestoneAssign(e *xorm.Session, issue *Issue, oldMilestoneID int64)
 		} else if _, err = e.Exec("UPDATE `issue_user` SET milestone_id = 0 WHERE issue_id = ?", issue.ID); err != nil {
 			return err
 		}
+		if err = createIssueHistory(e, issue, oldMilestoneID, ISSUE_HISTORY_MILESTONE_REMOVE); err != nil {
+			return fmt.Errorf("createIssueHistory: %v", err)
+		}
 
 		issue.Milestone = nil
 	}
@@ -303,6 +311,9 @@ func changeMilestoneAssign(e *xorm.Session, issue *Issue, oldMilestoneID int64)
 		} else if _, err = e.Exec("UPDATE `issue_user` SET milestone_id = ? WHERE issue_id = ?", m.ID, issue.ID); err != nil {
 			return err
 		}
+		if err = createIssueHistory(e, issue, m.ID, ISSUE_HISTORY_MILESTONE_ADD); err != nil {
+			return fmt.Errorf("createIssueHistory: %v", err)
+		}
 
 		issue.Milestone = m
 	}
@@ -322,6 +333,17 @@ func ChangeMilestoneAssign(doer *User, issue *Issue, oldMilestoneID int64) (err
 		return err
 	}
 
+	if oldMilestoneID > 0 {
+		if err = createMilestoneEvent(sess, doer, issue, oldMilestoneID, ISSUE_EVENT_DEMILESTONED); err != nil {
+			return fmt.Errorf("createMilestoneEvent: %v", err)
+		}
+	}
+	if issue.MilestoneID > 0 {
+		if err = createMilestoneEvent(sess, doer, issue, issue.MilestoneID, ISSUE_EVENT_MILESTONED); err != nil {
+			return fmt.Errorf("createMilestoneEvent: %v", err)
+		}
+	}
+
 	if err = sess.Commit(); err != nil {
 		return fmt.Errorf("Commit: %v", err)
 	}
@@ -397,6 +419,8 @@ func DeleteMilestoneOfRepoByID(repoID, id int64) error {
 		return err
 	} else if _, err = sess.Exec("UPDATE `issue_user` SET milestone_id = 0 WHERE milestone_id = ?", m.ID); err != nil {
 		return err
+	} else if _, err = sess.Delete(&IssueHistory{MilestoneID: m.ID}); err != nil {
+		return err
 	}
 	return sess.Commit()
 }
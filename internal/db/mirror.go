@@ -356,12 +356,29 @@ func MirrorUpdate() {
 			log.Error("Disconnected mirror repository found: %d", m.ID)
 			return nil
 		}
+		if m.Repo.IsArchived {
+			return nil
+		}
 
 		MirrorQueue.Add(m.RepoID)
 		return nil
 	}); err != nil {
 		log.Error("MirrorUpdate: %v", err)
 	}
+
+	if err := x.Where("next_sync_unix > 0 AND next_sync_unix <= ?", time.Now().Unix()).Iterate(new(PushMirror), func(idx int, bean interface{}) error {
+		PushMirrorQueue.Add(bean.(*PushMirror).ID)
+		return nil
+	}); err != nil {
+		log.Error("MirrorUpdate: push mirrors: %v", err)
+	}
+
+	if err := x.Where("next_sync_unix > 0 AND next_sync_unix <= ?", time.Now().Unix()).Iterate(new(IssueMirror), func(idx int, bean interface{}) error {
+		IssueMirrorQueue.Add(bean.(*IssueMirror).ID)
+		return nil
+	}); err != nil {
+		log.Error("MirrorUpdate: issue mirrors: %v", err)
+	}
 }
 
 // SyncMirrors checks and syncs mirrors.
@@ -397,6 +414,10 @@ func SyncMirrors() {
 		if len(results) == 0 {
 			log.Trace("SyncMirrors [repo_id: %d]: no commits fetched", m.RepoID)
 		} else {
+			AddPushMirrorSyncTask(m.RepoID)
+			go AddCodeIndexerTask(m.RepoID)
+			go AddLanguageStatsTask(m.RepoID)
+
 			gitRepo, err = git.OpenRepository(m.Repo.RepoPath())
 			if err != nil {
 				log.Error("OpenRepository [%d]: %v", m.RepoID, err)
@@ -502,4 +523,6 @@ func SyncMirrors() {
 
 func InitSyncMirrors() {
 	go SyncMirrors()
+	go SyncPushMirrors()
+	go SyncIssueMirrors()
 }
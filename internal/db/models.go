@@ -54,17 +54,22 @@ var (
 
 func init() {
 	tables = append(tables,
-		new(User), new(PublicKey), new(AccessToken), new(TwoFactor), new(TwoFactorRecoveryCode),
+		new(User), new(DeletedUser), new(PublicKey), new(AccessToken), new(TwoFactor), new(TwoFactorRecoveryCode),
 		new(Repository), new(DeployKey), new(Collaboration), new(Access), new(Upload),
-		new(Watch), new(Star), new(Follow), new(Action),
-		new(Issue), new(PullRequest), new(Comment), new(Attachment), new(IssueUser),
-		new(Label), new(IssueLabel), new(Milestone),
-		new(Mirror), new(Release), new(LoginSource), new(Webhook), new(HookTask),
+		new(Watch), new(Star), new(StarEvent), new(StarList), new(StarListRepo), new(Follow), new(Action),
+		new(LFSObject), new(LFSLock),
+		new(Issue), new(PullRequest), new(Comment), new(CommentRevision), new(Attachment), new(IssueUser),
+		new(Label), new(IssueLabel), new(Milestone), new(IssueHistory), new(IssueEvent), new(ReviewRequest), new(IssueFormData), new(Notification), new(IssueWatch),
+		new(DigestSubscription), new(Onboarding), new(OnboardingStep), new(TermsAcceptance),
+		new(Project), new(ProjectColumn), new(ProjectCard),
+		new(Mirror), new(PushMirror), new(MigrationTask), new(RepoGC), new(MaintenanceJob), new(RepoGraphStats), new(RepoTrending), new(LanguageStat), new(StagedChange), new(DeletedBranch), new(CommitStatus), new(Package), new(PackageVersion), new(PackageFile), new(PackageBlob), new(Topic), new(RepoTopic), new(RepoIndexerStatus), new(CodeIndexFile), new(Release), new(LoginSource), new(Webhook), new(HookTask),
 		new(ProtectBranch), new(ProtectBranchWhitelist),
-		new(Team), new(OrgUser), new(TeamUser), new(TeamRepo),
+		new(Team), new(OrgUser), new(TeamUser), new(TeamRepo), new(TeamUnit), new(AccessUnit), new(OrgRepoDefaults),
+		new(FederationKey), new(RemoteFollower),
+		new(IssueMirror), new(IssueMirrorIssue), new(IssueMirrorComment), new(Autolink),
 		new(Notice), new(EmailAddress))
 
-	gonicNames := []string{"SSL"}
+	gonicNames := []string{"SSL", "LFS", "GC"}
 	for _, name := range gonicNames {
 		core.LintGonicMapper[name] = true
 	}
@@ -0,0 +1,332 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"xorm.io/xorm"
+
+	"gogs.io/gogs/internal/db/errors"
+	"gogs.io/gogs/internal/markup"
+)
+
+// NotificationStatus is the state of a notification in the notification center.
+type NotificationStatus int
+
+const (
+	NOTIFICATION_STATUS_UNREAD NotificationStatus = iota + 1
+	NOTIFICATION_STATUS_READ
+	NOTIFICATION_STATUS_DONE
+)
+
+var notificationStatusNames = map[NotificationStatus]string{
+	NOTIFICATION_STATUS_UNREAD: "unread",
+	NOTIFICATION_STATUS_READ:   "read",
+	NOTIFICATION_STATUS_DONE:   "done",
+}
+
+// Name returns the name of the status, e.g. "unread".
+func (s NotificationStatus) Name() string {
+	return notificationStatusNames[s]
+}
+
+// ParseNotificationStatus returns the status of given name, or zero if invalid.
+func ParseNotificationStatus(name string) NotificationStatus {
+	for s, n := range notificationStatusNames {
+		if n == name {
+			return s
+		}
+	}
+	return 0
+}
+
+// NotificationReason is why a user is notified of an issue or a pull request.
+type NotificationReason int
+
+const (
+	NOTIFICATION_REASON_SUBSCRIBED NotificationReason = iota + 1 // Watching the repository or participating.
+	NOTIFICATION_REASON_MENTION
+	NOTIFICATION_REASON_REVIEW_REQUESTED
+	NOTIFICATION_REASON_ASSIGN
+)
+
+var notificationReasonNames = map[NotificationReason]string{
+	NOTIFICATION_REASON_SUBSCRIBED:       "subscribed",
+	NOTIFICATION_REASON_MENTION:          "mention",
+	NOTIFICATION_REASON_REVIEW_REQUESTED: "review_requested",
+	NOTIFICATION_REASON_ASSIGN:           "assign",
+}
+
+// Name returns the name of the reason, e.g. "mention".
+func (r NotificationReason) Name() string {
+	return notificationReasonNames[r]
+}
+
+// Notification represents the latest activity of an issue or a pull request that
+// the user is notified of, there is at most one notification of an issue for each
+// user, which becomes unread again on new activities.
+type Notification struct {
+	ID        int64
+	UserID    int64              `xorm:"UNIQUE(s) INDEX(status) NOT NULL"`
+	RepoID    int64              `xorm:"INDEX NOT NULL"`
+	IssueID   int64              `xorm:"UNIQUE(s) NOT NULL"`
+	Status    NotificationStatus `xorm:"INDEX(status) NOT NULL"`
+	Reason    NotificationReason `xorm:"NOT NULL DEFAULT 1"`
+	CommentID int64              // The latest comment, zero means the issue itself.
+	UpdatedBy int64              // The user who made the latest activity.
+
+	Repo  *Repository `xorm:"-" json:"-"`
+	Issue *Issue      `xorm:"-" json:"-"`
+
+	Created     time.Time `xorm:"-" json:"-"`
+	CreatedUnix int64
+	Updated     time.Time `xorm:"-" json:"-"`
+	UpdatedUnix int64     `xorm:"INDEX"`
+}
+
+func (n *Notification) BeforeInsert() {
+	n.CreatedUnix = time.Now().Unix()
+	n.UpdatedUnix = n.CreatedUnix
+}
+
+func (n *Notification) AfterSet(colName string, _ xorm.Cell) {
+	switch colName {
+	case "created_unix":
+		n.Created = time.Unix(n.CreatedUnix, 0).Local()
+	case "updated_unix":
+		n.Updated = time.Unix(n.UpdatedUnix, 0).Local()
+	}
+}
+
+// loadAttributes loads the issue and the repository of the notification, errors of
+// not existing issues or repositories are returned as is.
+func (n *Notification) loadAttributes(e Engine) (err error) {
+	if n.Issue == nil {
+		n.Issue, err = getRawIssueByID(e, n.IssueID)
+		if err != nil {
+			return err
+		}
+	}
+	if n.Repo == nil {
+		n.Repo, err = getRepositoryByID(e, n.RepoID)
+		if err != nil {
+			return err
+		} else if err = n.Repo.getOwner(e); err != nil {
+			return fmt.Errorf("getOwner [repo_id: %d]: %v", n.RepoID, err)
+		}
+	}
+	n.Issue.Repo = n.Repo
+	return nil
+}
+
+func (n *Notification) LoadAttributes() error {
+	return n.loadAttributes(x)
+}
+
+// HTMLURL returns the URL of the latest activity.
+func (n *Notification) HTMLURL() string {
+	if n.CommentID > 0 {
+		return n.Issue.HTMLURL() + "#" + CommentHashTag(n.CommentID)
+	}
+	return n.Issue.HTMLURL()
+}
+
+// notifyIssueUsers creates or updates notifications of the issue for given users to
+// the unread status. The doer and users who have no access to the repository are
+// never notified.
+func notifyIssueUsers(e Engine, repo *Repository, issue *Issue, commentID, doerID int64, reason NotificationReason, userIDs []int64) error {
+	seen := make(map[int64]bool, len(userIDs))
+	for _, userID := range userIDs {
+		if userID <= 0 || userID == doerID || seen[userID] {
+			continue
+		}
+		seen[userID] = true
+
+		if has, err := hasAccess(e, userID, repo, ACCESS_MODE_READ); err != nil {
+			return fmt.Errorf("hasAccess [user_id: %d]: %v", userID, err)
+		} else if !has {
+			continue
+		}
+		if visible, err := issue.isVisibleTo(e, userID); err != nil {
+			return fmt.Errorf("isVisibleTo [user_id: %d]: %v", userID, err)
+		} else if !visible {
+			continue
+		}
+		if ignored, err := isIgnoringIssue(e, userID, issue); err != nil {
+			return fmt.Errorf("isIgnoringIssue [user_id: %d]: %v", userID, err)
+		} else if ignored {
+			continue
+		}
+
+		n := new(Notification)
+		has, err := e.Where("user_id = ? AND issue_id = ?", userID, issue.ID).Get(n)
+		if err != nil {
+			return fmt.Errorf("get notification: %v", err)
+		} else if !has {
+			if _, err = e.Insert(&Notification{
+				UserID:    userID,
+				RepoID:    repo.ID,
+				IssueID:   issue.ID,
+				Status:    NOTIFICATION_STATUS_UNREAD,
+				Reason:    reason,
+				CommentID: commentID,
+				UpdatedBy: doerID,
+			}); err != nil {
+				return fmt.Errorf("insert notification: %v", err)
+			}
+			continue
+		}
+
+		n.Status = NOTIFICATION_STATUS_UNREAD
+		n.Reason = reason
+		n.CommentID = commentID
+		n.UpdatedBy = doerID
+		n.UpdatedUnix = time.Now().Unix()
+		if _, err = e.ID(n.ID).Cols("status", "reason", "comment_id", "updated_by", "updated_unix").Update(n); err != nil {
+			return fmt.Errorf("update notification [id: %d]: %v", n.ID, err)
+		}
+	}
+	return nil
+}
+
+// notifyIssueParticipants notifies subscribers of the issue of a new issue or
+// comment, and users mentioned in the content.
+func notifyIssueParticipants(e Engine, repo *Repository, issue *Issue, commentID, doerID int64, content string) error {
+	subscriberIDs, err := getIssueSubscriberIDs(e, issue)
+	if err != nil {
+		return fmt.Errorf("getIssueSubscriberIDs: %v", err)
+	}
+
+	names := markup.FindAllMentions(content)
+	for i := range names {
+		names[i] = strings.ToLower(names[i])
+	}
+	mentionedIDs := make([]int64, 0, len(names))
+	if len(names) > 0 {
+		if err = e.Table("user").In("lower_name", names).Cols("id").Find(&mentionedIDs); err != nil {
+			return fmt.Errorf("get mentioned users: %v", err)
+		}
+	}
+	isMentioned := make(map[int64]bool, len(mentionedIDs))
+	for _, id := range mentionedIDs {
+		isMentioned[id] = true
+	}
+
+	for i := 0; i < len(subscriberIDs); i++ {
+		if isMentioned[subscriberIDs[i]] {
+			subscriberIDs = append(subscriberIDs[:i], subscriberIDs[i+1:]...)
+			i--
+		}
+	}
+
+	if err = notifyIssueUsers(e, repo, issue, commentID, doerID, NOTIFICATION_REASON_SUBSCRIBED, subscriberIDs); err != nil {
+		return err
+	}
+	return notifyIssueUsers(e, repo, issue, commentID, doerID, NOTIFICATION_REASON_MENTION, mentionedIDs)
+}
+
+type NotificationsOptions struct {
+	UserID int64
+	// RepoID limits notifications to the repository when it is not zero.
+	RepoID int64
+	// Statuses limits notifications to given statuses, empty means all statuses.
+	Statuses []NotificationStatus
+	// UpdatedAfter and UpdatedBefore limit notifications to the time range when they
+	// are not zero.
+	UpdatedAfter  int64
+	UpdatedBefore int64
+	Page          int
+	PageSize      int
+}
+
+func (opts *NotificationsOptions) session() *xorm.Session {
+	sess := x.Where("user_id = ?", opts.UserID)
+	if opts.RepoID > 0 {
+		sess.And("repo_id = ?", opts.RepoID)
+	}
+	if len(opts.Statuses) > 0 {
+		sess.In("status", opts.Statuses)
+	}
+	if opts.UpdatedAfter > 0 {
+		sess.And("updated_unix > ?", opts.UpdatedAfter)
+	}
+	if opts.UpdatedBefore > 0 {
+		sess.And("updated_unix < ?", opts.UpdatedBefore)
+	}
+	return sess
+}
+
+// Notifications returns notifications by given options, the most recently updated
+// first, and the total number of matched notifications.
+func Notifications(opts *NotificationsOptions) ([]*Notification, int64, error) {
+	if opts.Page <= 0 {
+		opts.Page = 1
+	}
+
+	count, err := opts.session().Count(new(Notification))
+	if err != nil {
+		return nil, 0, fmt.Errorf("count: %v", err)
+	}
+
+	notifications := make([]*Notification, 0, opts.PageSize)
+	if err = opts.session().Desc("updated_unix", "id").
+		Limit(opts.PageSize, (opts.Page-1)*opts.PageSize).Find(&notifications); err != nil {
+		return nil, 0, fmt.Errorf("find: %v", err)
+	}
+
+	// Skip notifications of issues that have been deleted.
+	loaded := notifications[:0]
+	for _, n := range notifications {
+		if err = n.LoadAttributes(); err != nil {
+			if errors.IsIssueNotExist(err) || errors.IsRepoNotExist(err) {
+				continue
+			}
+			return nil, 0, err
+		}
+		loaded = append(loaded, n)
+	}
+	return loaded, count, nil
+}
+
+// CountUnreadNotifications returns the number of unread notifications of the user.
+func CountUnreadNotifications(userID int64) (int64, error) {
+	return x.Where("user_id = ? AND status = ?", userID, NOTIFICATION_STATUS_UNREAD).Count(new(Notification))
+}
+
+// GetNotificationByID returns the notification of the user with given ID.
+func GetNotificationByID(userID, id int64) (*Notification, error) {
+	n := new(Notification)
+	has, err := x.Where("id = ? AND user_id = ?", id, userID).Get(n)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		return nil, errors.NotificationNotExist{ID: id}
+	}
+	return n, n.LoadAttributes()
+}
+
+// SetNotificationStatus changes status of the notification of the user.
+func SetNotificationStatus(userID, id int64, status NotificationStatus) error {
+	_, err := x.Where("id = ? AND user_id = ?", id, userID).Cols("status").Update(&Notification{Status: status})
+	return err
+}
+
+// SetNotificationsStatus changes status of notifications matched by given options,
+// page options are ignored.
+func SetNotificationsStatus(opts *NotificationsOptions, status NotificationStatus) error {
+	_, err := opts.session().Cols("status").Update(&Notification{Status: status})
+	return err
+}
+
+// markIssueNotificationRead marks the unread notification of the issue of the user
+// as read.
+func markIssueNotificationRead(e Engine, userID, issueID int64) error {
+	_, err := e.Where("user_id = ? AND issue_id = ? AND status = ?", userID, issueID, NOTIFICATION_STATUS_UNREAD).
+		Cols("status").Update(&Notification{Status: NOTIFICATION_STATUS_READ})
+	return err
+}
@@ -0,0 +1,138 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"time"
+
+	log "unknwon.dev/clog/v2"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/email"
+)
+
+// NotificationEmailMode is how the user receives notification emails of issues and
+// pull requests.
+type NotificationEmailMode int
+
+const (
+	NOTIFICATION_EMAIL_IMMEDIATE NotificationEmailMode = iota // One email per event.
+	NOTIFICATION_EMAIL_HOURLY
+	NOTIFICATION_EMAIL_DAILY
+)
+
+// ParseNotificationEmailMode returns the mode of given value, or
+// NOTIFICATION_EMAIL_IMMEDIATE if invalid.
+func ParseNotificationEmailMode(v int) NotificationEmailMode {
+	switch m := NotificationEmailMode(v); m {
+	case NOTIFICATION_EMAIL_HOURLY, NOTIFICATION_EMAIL_DAILY:
+		return m
+	}
+	return NOTIFICATION_EMAIL_IMMEDIATE
+}
+
+// Period returns the duration between two digests of the mode, or zero when emails
+// are sent immediately.
+func (m NotificationEmailMode) Period() time.Duration {
+	switch m {
+	case NOTIFICATION_EMAIL_HOURLY:
+		return time.Hour
+	case NOTIFICATION_EMAIL_DAILY:
+		return 24 * time.Hour
+	}
+	return 0
+}
+
+// maxNotificationDigestItems is the maximum number of notifications listed in a
+// notification digest.
+const maxNotificationDigestItems = 50
+
+// UpdateNotificationEmailMode changes the notification email mode of the user.
+func UpdateNotificationEmailMode(u *User, mode NotificationEmailMode) error {
+	if u.NotificationEmailMode == mode {
+		return nil
+	}
+
+	u.NotificationEmailMode = mode
+	// Do not send digest of notifications before switching to batched mode.
+	u.LastNotificationEmailUnix = time.Now().Unix()
+	_, err := x.ID(u.ID).Cols("notification_email_mode", "last_notification_email_unix").Update(u)
+	return err
+}
+
+// SendNotificationDigests sends unread notifications to users who batch notification
+// emails, when the period of their modes has passed since last time.
+func SendNotificationDigests() {
+	if taskStatusTable.IsRunning(_SEND_NOTIFICATION_DIGESTS) {
+		return
+	}
+	taskStatusTable.Start(_SEND_NOTIFICATION_DIGESTS)
+	defer taskStatusTable.Stop(_SEND_NOTIFICATION_DIGESTS)
+
+	if !conf.User.EnableEmailNotification {
+		return
+	}
+
+	log.Trace("Doing: SendNotificationDigests")
+
+	users := make([]*User, 0, 10)
+	if err := x.Where("type = ? AND notification_email_mode > ?", USER_TYPE_INDIVIDUAL, NOTIFICATION_EMAIL_IMMEDIATE).
+		Find(&users); err != nil {
+		log.Error("Failed to get users of batched notification emails: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for _, u := range users {
+		// Allow some tolerance so that the digest is not delayed by one more run of the schedule.
+		if now.Sub(time.Unix(u.LastNotificationEmailUnix, 0)) < u.NotificationEmailMode.Period()-5*time.Minute {
+			continue
+		}
+
+		if u.IsMailable() {
+			if err := sendNotificationDigest(u, now); err != nil {
+				log.Error("Failed to send notification digest [user_id: %d]: %v", u.ID, err)
+				continue
+			}
+		}
+
+		u.LastNotificationEmailUnix = now.Unix()
+		if _, err := x.ID(u.ID).Cols("last_notification_email_unix").Update(u); err != nil {
+			log.Error("Failed to update last notification email time [user_id: %d]: %v", u.ID, err)
+		}
+	}
+}
+
+// sendNotificationDigest sends the digest of notifications of the user that are
+// updated since last digest and still unread.
+func sendNotificationDigest(u *User, now time.Time) error {
+	notifications, _, err := Notifications(&NotificationsOptions{
+		UserID:        u.ID,
+		Statuses:      []NotificationStatus{NOTIFICATION_STATUS_UNREAD},
+		UpdatedAfter:  u.LastNotificationEmailUnix,
+		UpdatedBefore: now.Unix() + 1,
+		PageSize:      maxNotificationDigestItems,
+	})
+	if err != nil {
+		return fmt.Errorf("Notifications: %v", err)
+	} else if len(notifications) == 0 {
+		return nil
+	}
+
+	items := make([]*email.NotificationDigestItem, len(notifications))
+	for i, n := range notifications {
+		items[i] = &email.NotificationDigestItem{
+			RepoName: n.Repo.FullName(),
+			Index:    n.Issue.Index,
+			Title:    n.Issue.Title,
+			IsPull:   n.Issue.IsPull,
+			Reason:   n.Reason.Name(),
+			Link:     n.HTMLURL(),
+		}
+	}
+	email.SendNotificationDigestMail(NewMailerUser(u), items, u.NotificationEmailMode == NOTIFICATION_EMAIL_DAILY)
+	return nil
+}
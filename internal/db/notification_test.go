@@ -0,0 +1,60 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_ParseNotificationStatus(t *testing.T) {
+	Convey("Parse notification status by name", t, func() {
+		testCases := []struct {
+			name   string
+			expect NotificationStatus
+		}{
+			{"unread", NOTIFICATION_STATUS_UNREAD},
+			{"read", NOTIFICATION_STATUS_READ},
+			{"done", NOTIFICATION_STATUS_DONE},
+			{"pinned", 0},
+			{"", 0},
+		}
+		for _, tc := range testCases {
+			So(ParseNotificationStatus(tc.name), ShouldEqual, tc.expect)
+		}
+	})
+
+	Convey("Name of notification status is parsed as the same status", t, func() {
+		for _, status := range []NotificationStatus{NOTIFICATION_STATUS_UNREAD, NOTIFICATION_STATUS_READ, NOTIFICATION_STATUS_DONE} {
+			So(ParseNotificationStatus(status.Name()), ShouldEqual, status)
+		}
+	})
+}
+
+func Test_ParseNotificationEmailMode(t *testing.T) {
+	Convey("Parse notification email mode", t, func() {
+		testCases := []struct {
+			value  int
+			expect NotificationEmailMode
+		}{
+			{0, NOTIFICATION_EMAIL_IMMEDIATE},
+			{1, NOTIFICATION_EMAIL_HOURLY},
+			{2, NOTIFICATION_EMAIL_DAILY},
+			{3, NOTIFICATION_EMAIL_IMMEDIATE},
+			{-1, NOTIFICATION_EMAIL_IMMEDIATE},
+		}
+		for _, tc := range testCases {
+			So(ParseNotificationEmailMode(tc.value), ShouldEqual, tc.expect)
+		}
+	})
+
+	Convey("Only batched modes have periods", t, func() {
+		So(NOTIFICATION_EMAIL_IMMEDIATE.Period(), ShouldEqual, 0)
+		So(NOTIFICATION_EMAIL_HOURLY.Period(), ShouldEqual, time.Hour)
+		So(NOTIFICATION_EMAIL_DAILY.Period(), ShouldEqual, 24*time.Hour)
+	})
+}
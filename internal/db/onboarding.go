@@ -0,0 +1,217 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/db/errors"
+)
+
+const (
+	ONBOARDING_STEP_SSH_KEY      = "ssh_key"
+	ONBOARDING_STEP_VERIFY_EMAIL = "verify_email"
+	ONBOARDING_STEP_CREATE_REPO  = "create_repo"
+	ONBOARDING_STEP_AVATAR       = "avatar"
+	ONBOARDING_STEP_WELCOME      = "welcome" // The custom step defined by site admin.
+)
+
+// Onboarding represents the onboarding state of a user, it only exists for users
+// created when onboarding is enabled.
+type Onboarding struct {
+	ID            int64
+	UserID        int64 `xorm:"UNIQUE"`
+	Dismissed     bool  `xorm:"NOT NULL DEFAULT false"`
+	CreatedUnix   int64
+	CompletedUnix int64 // Zero until all steps are completed.
+}
+
+func (o *Onboarding) BeforeInsert() {
+	o.CreatedUnix = time.Now().Unix()
+}
+
+// IsActive returns true if the onboarding checklist should be shown to the user.
+func (o *Onboarding) IsActive() bool {
+	return !o.Dismissed && o.CompletedUnix == 0
+}
+
+// OnboardingStep represents a completed onboarding step of a user.
+type OnboardingStep struct {
+	ID          int64
+	UserID      int64  `xorm:"UNIQUE(s)"`
+	Name        string `xorm:"UNIQUE(s)"`
+	CreatedUnix int64
+}
+
+func (s *OnboardingStep) BeforeInsert() {
+	s.CreatedUnix = time.Now().Unix()
+}
+
+// OnboardingStepStatus is the status of an onboarding step of a user.
+type OnboardingStepStatus struct {
+	Name      string `json:"name"`
+	Completed bool   `json:"completed"`
+	// Manual step is completed by marking as done instead of doing something.
+	Manual bool `json:"manual"`
+}
+
+// OnboardingSteps returns names of onboarding steps available on the site in order.
+func OnboardingSteps() []string {
+	steps := make([]string, 0, 5)
+	if !conf.SSH.Disabled {
+		steps = append(steps, ONBOARDING_STEP_SSH_KEY)
+	}
+	if conf.Auth.RequireEmailConfirmation {
+		steps = append(steps, ONBOARDING_STEP_VERIFY_EMAIL)
+	}
+	steps = append(steps, ONBOARDING_STEP_CREATE_REPO, ONBOARDING_STEP_AVATAR)
+	if conf.Onboarding.WelcomeTitle != "" {
+		steps = append(steps, ONBOARDING_STEP_WELCOME)
+	}
+	return steps
+}
+
+// isOnboardingStepDone returns true if the user has done what the step asks for.
+func isOnboardingStepDone(e Engine, u *User, step string) (bool, error) {
+	switch step {
+	case ONBOARDING_STEP_SSH_KEY:
+		count, err := e.Where("owner_id = ? AND type = ?", u.ID, KEY_TYPE_USER).Count(new(PublicKey))
+		return count > 0, err
+	case ONBOARDING_STEP_VERIFY_EMAIL:
+		return u.IsActive, nil
+	case ONBOARDING_STEP_CREATE_REPO:
+		return u.NumRepos > 0, nil
+	case ONBOARDING_STEP_AVATAR:
+		return u.UseCustomAvatar, nil
+	}
+	return false, nil
+}
+
+func createOnboarding(e Engine, userID int64) error {
+	_, err := e.Insert(&Onboarding{UserID: userID})
+	return err
+}
+
+// GetOnboarding returns the onboarding state of the user.
+func GetOnboarding(userID int64) (*Onboarding, error) {
+	o := &Onboarding{UserID: userID}
+	has, err := x.Get(o)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		return nil, errors.OnboardingNotExist{UserID: userID}
+	}
+	return o, nil
+}
+
+// GetOnboardingChecklist returns status of onboarding steps of the user. Steps that the
+// user has done since last check are recorded as completed, and the onboarding is
+// completed when all steps are completed.
+func GetOnboardingChecklist(u *User, o *Onboarding) ([]*OnboardingStepStatus, error) {
+	completedSteps := make([]*OnboardingStep, 0, 5)
+	if err := x.Where("user_id = ?", u.ID).Find(&completedSteps); err != nil {
+		return nil, fmt.Errorf("get completed steps: %v", err)
+	}
+	completed := make(map[string]bool, len(completedSteps))
+	for _, step := range completedSteps {
+		completed[step.Name] = true
+	}
+
+	steps := OnboardingSteps()
+	statuses := make([]*OnboardingStepStatus, 0, len(steps))
+	allCompleted := true
+	for _, step := range steps {
+		done := completed[step]
+		if !done {
+			var err error
+			if done, err = isOnboardingStepDone(x, u, step); err != nil {
+				return nil, fmt.Errorf("check step %q: %v", step, err)
+			} else if done {
+				if _, err = x.Insert(&OnboardingStep{UserID: u.ID, Name: step}); err != nil {
+					return nil, fmt.Errorf("complete step %q: %v", step, err)
+				}
+			}
+		}
+
+		statuses = append(statuses, &OnboardingStepStatus{
+			Name:      step,
+			Completed: done,
+			Manual:    step == ONBOARDING_STEP_WELCOME,
+		})
+		allCompleted = allCompleted && done
+	}
+
+	if allCompleted && o.CompletedUnix == 0 {
+		o.CompletedUnix = time.Now().Unix()
+		if _, err := x.ID(o.ID).Cols("completed_unix").Update(o); err != nil {
+			return nil, fmt.Errorf("complete onboarding: %v", err)
+		}
+	}
+	return statuses, nil
+}
+
+// CompleteOnboardingStep marks the manual onboarding step as done for the user.
+func CompleteOnboardingStep(userID int64, step string) error {
+	if step != ONBOARDING_STEP_WELCOME || conf.Onboarding.WelcomeTitle == "" {
+		return errors.InvalidOnboardingStep{Name: step}
+	}
+
+	has, err := x.Get(&OnboardingStep{UserID: userID, Name: step})
+	if err != nil {
+		return err
+	} else if has {
+		return nil
+	}
+	_, err = x.Insert(&OnboardingStep{UserID: userID, Name: step})
+	return err
+}
+
+// DismissOnboarding dismisses the onboarding checklist.
+func DismissOnboarding(o *Onboarding) error {
+	o.Dismissed = true
+	_, err := x.ID(o.ID).Cols("dismissed").Update(o)
+	return err
+}
+
+// OnboardingStepStats is the number of users who completed an onboarding step.
+type OnboardingStepStats struct {
+	Name      string
+	Completed int64
+}
+
+// OnboardingStats is the adoption statistics of onboarding.
+type OnboardingStats struct {
+	Total     int64 // Number of users who have the onboarding checklist.
+	Completed int64
+	Dismissed int64
+	Steps     []*OnboardingStepStats
+}
+
+// GetOnboardingStats returns the adoption statistics of onboarding.
+func GetOnboardingStats() (*OnboardingStats, error) {
+	stats := new(OnboardingStats)
+	var err error
+	if stats.Total, err = x.Count(new(Onboarding)); err != nil {
+		return nil, fmt.Errorf("count total: %v", err)
+	} else if stats.Completed, err = x.Where("completed_unix > 0").Count(new(Onboarding)); err != nil {
+		return nil, fmt.Errorf("count completed: %v", err)
+	} else if stats.Dismissed, err = x.Where("dismissed = ?", true).Count(new(Onboarding)); err != nil {
+		return nil, fmt.Errorf("count dismissed: %v", err)
+	}
+
+	for _, step := range OnboardingSteps() {
+		count, err := x.Where("name = ?", step).Count(new(OnboardingStep))
+		if err != nil {
+			return nil, fmt.Errorf("count step %q: %v", step, err)
+		}
+		stats.Steps = append(stats.Steps, &OnboardingStepStats{
+			Name:      step,
+			Completed: count,
+		})
+	}
+	return stats, nil
+}
@@ -202,7 +202,7 @@ func Organizations(page, pageSize int) ([]*User, error) {
 
 // DeleteOrganization completely and permanently deletes everything of organization.
 func DeleteOrganization(org *User) (err error) {
-	if err := DeleteUser(org); err != nil {
+	if err := DeleteUser(org, DeleteUserOptions{}); err != nil {
 		return err
 	}
 
@@ -216,11 +216,18 @@ func DeleteOrganization(org *User) (err error) {
 		&Team{OrgID: org.ID},
 		&OrgUser{OrgID: org.ID},
 		&TeamUser{OrgID: org.ID},
+		&TeamUnit{OrgID: org.ID},
+		&OrgRepoDefaults{OrgID: org.ID},
+		&DigestSubscription{OrgID: org.ID},
 	); err != nil {
 		return fmt.Errorf("deleteBeans: %v", err)
 	}
 
-	if err = deleteUser(sess, org); err != nil {
+	if err = deleteProjectsByOrgID(sess, org.ID); err != nil {
+		return fmt.Errorf("deleteProjectsByOrgID: %v", err)
+	}
+
+	if err = deleteUser(sess, org, DeleteUserOptions{}); err != nil {
 		return fmt.Errorf("deleteUser: %v", err)
 	}
 
@@ -513,6 +520,7 @@ func (org *User) GetUserRepositories(userID int64, page, pageSize int) ([]*Repos
 	if err = x.Where("owner_id = ?", org.ID).
 		And(builder.Or(
 			builder.Expr("is_private = ?", false),
+			builder.Expr("is_internal = ?", true),
 			builder.In("id", teamRepoIDs))).
 		Desc("updated_unix").
 		Limit(pageSize, (page-1)*pageSize).
@@ -523,6 +531,7 @@ func (org *User) GetUserRepositories(userID int64, page, pageSize int) ([]*Repos
 	repoCount, err := x.Where("owner_id = ?", org.ID).
 		And(builder.Or(
 			builder.Expr("is_private = ?", false),
+			builder.Expr("is_internal = ?", true),
 			builder.In("id", teamRepoIDs))).
 		Count(new(Repository))
 	if err != nil {
@@ -0,0 +1,117 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+)
+
+// OutsideCollaboration is a collaboration on a repository of an organization.
+type OutsideCollaboration struct {
+	*Collaboration
+	Repo *Repository
+}
+
+// OutsideCollaborator is a user who has access to repositories of an organization
+// as a collaborator without being a member of the organization.
+type OutsideCollaborator struct {
+	*User
+	Collaborations []*OutsideCollaboration
+}
+
+// getOutsideCollaborations returns collaborations on repositories of the organization
+// by users who are not members of the organization, optionally limited to given users.
+func getOutsideCollaborations(orgID int64, userIDs ...int64) ([]*Collaboration, error) {
+	sess := x.Join("INNER", "repository", "repository.id = collaboration.repo_id").
+		Where("repository.owner_id = ?", orgID).
+		And("collaboration.user_id NOT IN (SELECT uid FROM org_user WHERE org_id = ?)", orgID)
+	if len(userIDs) > 0 {
+		sess.In("collaboration.user_id", userIDs)
+	}
+
+	collaborations := make([]*Collaboration, 0, 10)
+	return collaborations, sess.Asc("collaboration.user_id", "repository.lower_name").Find(&collaborations)
+}
+
+// GetOutsideCollaborators returns outside collaborators of the organization along
+// with their collaborations on repositories of the organization.
+func GetOutsideCollaborators(orgID int64) ([]*OutsideCollaborator, error) {
+	collaborations, err := getOutsideCollaborations(orgID)
+	if err != nil {
+		return nil, fmt.Errorf("getOutsideCollaborations: %v", err)
+	}
+
+	collaborators := make([]*OutsideCollaborator, 0, 5)
+	repos := make(map[int64]*Repository, 5)
+	for _, c := range collaborations {
+		repo, ok := repos[c.RepoID]
+		if !ok {
+			repo, err = getRepositoryByID(x, c.RepoID)
+			if err != nil {
+				return nil, fmt.Errorf("getRepositoryByID [%d]: %v", c.RepoID, err)
+			}
+			repos[c.RepoID] = repo
+		}
+
+		// Collaborations are ordered by user.
+		if len(collaborators) == 0 || collaborators[len(collaborators)-1].ID != c.UserID {
+			u, err := getUserByID(x, c.UserID)
+			if err != nil {
+				return nil, fmt.Errorf("getUserByID [%d]: %v", c.UserID, err)
+			}
+			collaborators = append(collaborators, &OutsideCollaborator{User: u})
+		}
+		last := collaborators[len(collaborators)-1]
+		last.Collaborations = append(last.Collaborations, &OutsideCollaboration{
+			Collaboration: c,
+			Repo:          repo,
+		})
+	}
+	return collaborators, nil
+}
+
+// RemoveOutsideCollaborators removes collaborations of given users on all repositories
+// of the organization. Users who are members of the organization are ignored.
+func RemoveOutsideCollaborators(orgID int64, userIDs []int64) (err error) {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	collaborations, err := getOutsideCollaborations(orgID, userIDs...)
+	if err != nil {
+		return fmt.Errorf("getOutsideCollaborations: %v", err)
+	} else if len(collaborations) == 0 {
+		return nil
+	}
+
+	sess := x.NewSession()
+	defer sess.Close()
+	if err = sess.Begin(); err != nil {
+		return err
+	}
+
+	repoIDs := make([]int64, 0, len(collaborations))
+	seen := make(map[int64]bool, len(collaborations))
+	for _, c := range collaborations {
+		if _, err = sess.ID(c.ID).Delete(new(Collaboration)); err != nil {
+			return fmt.Errorf("delete collaboration [%d]: %v", c.ID, err)
+		}
+		if !seen[c.RepoID] {
+			seen[c.RepoID] = true
+			repoIDs = append(repoIDs, c.RepoID)
+		}
+	}
+
+	for _, repoID := range repoIDs {
+		repo, err := getRepositoryByID(sess, repoID)
+		if err != nil {
+			return fmt.Errorf("getRepositoryByID [%d]: %v", repoID, err)
+		} else if err = repo.recalculateTeamAccesses(sess, 0); err != nil {
+			return fmt.Errorf("recalculateTeamAccesses [%d]: %v", repoID, err)
+		}
+	}
+
+	return sess.Commit()
+}
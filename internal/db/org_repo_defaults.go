@@ -0,0 +1,144 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+
+	"gogs.io/gogs/internal/conf"
+)
+
+// RepoVisibility is the visibility enforced on new repositories of an organization.
+type RepoVisibility int
+
+const (
+	REPO_VISIBILITY_ANY RepoVisibility = iota // Chosen by the creator
+	REPO_VISIBILITY_PUBLIC
+	REPO_VISIBILITY_PRIVATE
+)
+
+// OrgRepoDefaults represents settings applied to every new repository of an organization.
+type OrgRepoDefaults struct {
+	ID         int64
+	OrgID      int64          `xorm:"UNIQUE"`
+	Visibility RepoVisibility `xorm:"NOT NULL DEFAULT 0"`
+
+	// Name of the default branch, empty means the instance default.
+	DefaultBranch string
+	// Name of the label template to initialize issue labels, empty means no labels.
+	LabelTemplate string
+
+	ProtectDefaultBranch bool `xorm:"NOT NULL DEFAULT false"`
+	RequirePullRequest   bool `xorm:"NOT NULL DEFAULT false"`
+
+	// Webhook to be added to new repositories, empty URL means no webhook.
+	WebhookURL            string `xorm:"TEXT"`
+	WebhookContentType    HookContentType
+	WebhookSecret         string `xorm:"TEXT"`
+	WebhookSendEverything bool   `xorm:"NOT NULL DEFAULT false"`
+}
+
+func getOrgRepoDefaults(e Engine, orgID int64) (*OrgRepoDefaults, error) {
+	defaults := &OrgRepoDefaults{OrgID: orgID}
+	if _, err := e.Get(defaults); err != nil {
+		return nil, err
+	}
+	return defaults, nil
+}
+
+// GetOrgRepoDefaults returns default repository settings of the organization.
+// It returns settings with zero values if the organization never saved any.
+func GetOrgRepoDefaults(orgID int64) (*OrgRepoDefaults, error) {
+	return getOrgRepoDefaults(x, orgID)
+}
+
+// UpdateOrgRepoDefaults saves default repository settings of an organization.
+// If ID is 0, it creates a new record. Otherwise, updates existing record.
+func UpdateOrgRepoDefaults(defaults *OrgRepoDefaults) (err error) {
+	if defaults.ID == 0 {
+		_, err = x.Insert(defaults)
+	} else {
+		_, err = x.ID(defaults.ID).AllCols().Update(defaults)
+	}
+	return err
+}
+
+// applyOptions returns creation options with visibility and default branch
+// overridden by the defaults.
+func (d *OrgRepoDefaults) applyOptions(opts CreateRepoOptions) CreateRepoOptions {
+	switch d.Visibility {
+	case REPO_VISIBILITY_PUBLIC:
+		opts.IsPrivate = conf.Repository.ForcePrivate
+		opts.IsInternal = false
+	case REPO_VISIBILITY_PRIVATE:
+		opts.IsPrivate = true
+		opts.IsInternal = false
+	}
+
+	if opts.DefaultBranch == "" {
+		opts.DefaultBranch = d.DefaultBranch
+	}
+	return opts
+}
+
+// applyRepository creates issue labels, branch protection and webhook of a newly
+// created repository according to the defaults.
+func (d *OrgRepoDefaults) applyRepository(e Engine, repo *Repository, opts CreateRepoOptions) error {
+	if d.LabelTemplate != "" {
+		list, err := GetLabelTemplateFile(d.LabelTemplate)
+		if err != nil {
+			return fmt.Errorf("GetLabelTemplateFile [%s]: %v", d.LabelTemplate, err)
+		}
+
+		labels := make([]*Label, len(list))
+		for i := range list {
+			labels[i] = &Label{
+				RepoID: repo.ID,
+				Name:   list[i][0],
+				Color:  list[i][1],
+			}
+		}
+		if len(labels) > 0 {
+			if _, err = e.Insert(labels); err != nil {
+				return fmt.Errorf("insert labels: %v", err)
+			}
+		}
+	}
+
+	if d.ProtectDefaultBranch && !opts.IsMirror {
+		if _, err := e.Insert(&ProtectBranch{
+			RepoID:             repo.ID,
+			Name:               opts.defaultBranch(),
+			Protected:          true,
+			RequirePullRequest: d.RequirePullRequest,
+		}); err != nil {
+			return fmt.Errorf("insert protect branch: %v", err)
+		}
+	}
+
+	if d.WebhookURL != "" {
+		w := &Webhook{
+			RepoID:      repo.ID,
+			URL:         d.WebhookURL,
+			ContentType: d.WebhookContentType,
+			Secret:      d.WebhookSecret,
+			HookEvent: &HookEvent{
+				PushOnly:       !d.WebhookSendEverything,
+				SendEverything: d.WebhookSendEverything,
+			},
+			IsActive:     true,
+			HookTaskType: GOGS,
+		}
+		if w.ContentType == 0 {
+			w.ContentType = JSON
+		}
+		if err := w.UpdateEvent(); err != nil {
+			return fmt.Errorf("UpdateEvent: %v", err)
+		} else if _, err = e.Insert(w); err != nil {
+			return fmt.Errorf("insert webhook: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_OrgRepoDefaults_applyOptions(t *testing.T) {
+	Convey("Apply organization defaults to repository creation options", t, func() {
+		defaults := &OrgRepoDefaults{}
+		opts := defaults.applyOptions(CreateRepoOptions{IsPrivate: true})
+		So(opts.IsPrivate, ShouldBeTrue)
+		So(opts.defaultBranch(), ShouldEqual, "master")
+
+		defaults = &OrgRepoDefaults{
+			Visibility:    REPO_VISIBILITY_PRIVATE,
+			DefaultBranch: "main",
+		}
+		opts = defaults.applyOptions(CreateRepoOptions{IsInternal: true})
+		So(opts.IsPrivate, ShouldBeTrue)
+		So(opts.IsInternal, ShouldBeFalse)
+		So(opts.defaultBranch(), ShouldEqual, "main")
+
+		opts = defaults.applyOptions(CreateRepoOptions{DefaultBranch: "develop"})
+		So(opts.defaultBranch(), ShouldEqual, "develop")
+
+		defaults = &OrgRepoDefaults{Visibility: REPO_VISIBILITY_PUBLIC}
+		opts = defaults.applyOptions(CreateRepoOptions{IsPrivate: true})
+		So(opts.IsPrivate, ShouldBeFalse)
+	})
+}
@@ -25,8 +25,17 @@ type Team struct {
 	Authorize   AccessMode
 	Repos       []*Repository `xorm:"-" json:"-"`
 	Members     []*User       `xorm:"-" json:"-"`
+	Units       []*TeamUnit   `xorm:"-" json:"-"`
 	NumRepos    int
 	NumMembers  int
+
+	// Members of the parent team inherit access to repositories of this team.
+	ParentID int64 `xorm:"INDEX NOT NULL DEFAULT 0"`
+
+	// Automatic reviewer selection for new pull requests
+	ReviewStrategy ReviewAssignStrategy `xorm:"NOT NULL DEFAULT 0"`
+	ReviewerCount  int                  `xorm:"NOT NULL DEFAULT 1"`
+	LastReviewerID int64
 }
 
 func (t *Team) AfterSet(colName string, _ xorm.Cell) {
@@ -230,6 +239,8 @@ func NewTeam(t *Team) error {
 
 	if err := IsUsableTeamName(t.Name); err != nil {
 		return err
+	} else if err = checkTeamParent(x, t); err != nil {
+		return err
 	}
 
 	has, err := x.Id(t.OrgID).Get(new(User))
@@ -256,6 +267,9 @@ func NewTeam(t *Team) error {
 	if _, err = sess.Insert(t); err != nil {
 		sess.Rollback()
 		return err
+	} else if err = t.updateUnits(sess); err != nil {
+		sess.Rollback()
+		return err
 	}
 
 	// Update organization number of teams.
@@ -333,19 +347,25 @@ func UpdateTeam(t *Team, authChanged bool) (err error) {
 		return err
 	} else if has {
 		return ErrTeamAlreadyExist{t.OrgID, t.LowerName}
+	} else if err = checkTeamParent(sess, t); err != nil {
+		return err
 	}
 
 	if _, err = sess.ID(t.ID).AllCols().Update(t); err != nil {
 		return fmt.Errorf("update: %v", err)
+	} else if err = t.updateUnits(sess); err != nil {
+		return fmt.Errorf("updateUnits: %v", err)
 	}
 
-	// Update access for team members if needed.
+	// Update access for team members if needed, child teams are also affected when
+	// parent of the team is changed.
 	if authChanged {
-		if err = t.getRepositories(sess); err != nil {
-			return fmt.Errorf("getRepositories:%v", err)
+		repos, err := t.getInheritingRepositories(sess)
+		if err != nil {
+			return fmt.Errorf("getInheritingRepositories: %v", err)
 		}
 
-		for _, repo := range t.Repos {
+		for _, repo := range repos {
 			if err = repo.recalculateTeamAccesses(sess, 0); err != nil {
 				return fmt.Errorf("recalculateTeamAccesses: %v", err)
 			}
@@ -355,10 +375,11 @@ func UpdateTeam(t *Team, authChanged bool) (err error) {
 	return sess.Commit()
 }
 
-// DeleteTeam deletes given team.
+// DeleteTeam deletes given team, child teams of the team are moved to its parent.
 // It's caller's responsibility to assign organization ID.
 func DeleteTeam(t *Team) error {
-	if err := t.GetRepositories(); err != nil {
+	repos, err := t.getInheritingRepositories(x)
+	if err != nil {
 		return err
 	}
 
@@ -374,8 +395,13 @@ func DeleteTeam(t *Team) error {
 		return err
 	}
 
+	// Move child teams to parent of the team.
+	if _, err = sess.Where("org_id = ? AND parent_id = ?", t.OrgID, t.ID).Cols("parent_id").Update(&Team{ParentID: t.ParentID}); err != nil {
+		return err
+	}
+
 	// Delete all accesses.
-	for _, repo := range t.Repos {
+	for _, repo := range repos {
 		if err = repo.recalculateTeamAccesses(sess, t.ID); err != nil {
 			return err
 		}
@@ -386,6 +412,11 @@ func DeleteTeam(t *Team) error {
 		return err
 	}
 
+	// Delete team-unit.
+	if _, err = sess.Delete(&TeamUnit{TeamID: t.ID}); err != nil {
+		return err
+	}
+
 	// Delete team.
 	if _, err = sess.ID(t.ID).Delete(new(Team)); err != nil {
 		return err
@@ -484,7 +515,8 @@ func AddTeamMember(orgID, teamID, userID int64) error {
 	}
 	t.NumMembers++
 
-	if err = t.GetRepositories(); err != nil {
+	repos, err := t.getInheritingRepositories(x)
+	if err != nil {
 		return err
 	}
 
@@ -505,8 +537,8 @@ func AddTeamMember(orgID, teamID, userID int64) error {
 		return err
 	}
 
-	// Give access to team repositories.
-	for _, repo := range t.Repos {
+	// Give access to repositories of the team and its child teams.
+	for _, repo := range repos {
 		if err = repo.recalculateTeamAccesses(sess, 0); err != nil {
 			return err
 		}
@@ -546,7 +578,8 @@ func removeTeamMember(e Engine, orgID, teamID, uid int64) error {
 
 	t.NumMembers--
 
-	if err = t.getRepositories(e); err != nil {
+	repos, err := t.getInheritingRepositories(e)
+	if err != nil {
 		return err
 	}
 
@@ -567,8 +600,8 @@ func removeTeamMember(e Engine, orgID, teamID, uid int64) error {
 		return err
 	}
 
-	// Delete access to team repositories.
-	for _, repo := range t.Repos {
+	// Delete access to repositories of the team and its child teams.
+	for _, repo := range repos {
 		if err = repo.recalculateTeamAccesses(e, 0); err != nil {
 			return err
 		}
@@ -0,0 +1,223 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+
+	"gogs.io/gogs/internal/db/errors"
+)
+
+// getAncestors returns parent teams of the team from the nearest to the farthest.
+// Loops in hierarchy are broken by stopping at a visited team.
+func (t *Team) getAncestors(e Engine) ([]*Team, error) {
+	ancestors := make([]*Team, 0, 2)
+	visited := map[int64]bool{t.ID: true}
+	for parentID := t.ParentID; parentID > 0 && !visited[parentID]; {
+		visited[parentID] = true
+
+		parent, err := getTeamByID(e, parentID)
+		if err != nil {
+			if errors.IsTeamNotExist(err) {
+				break
+			}
+			return nil, fmt.Errorf("getTeamByID [%d]: %v", parentID, err)
+		}
+		ancestors = append(ancestors, parent)
+		parentID = parent.ParentID
+	}
+	return ancestors, nil
+}
+
+// getDescendants returns all child teams of the team recursively.
+func (t *Team) getDescendants(e Engine) ([]*Team, error) {
+	teams, err := getTeamsByOrgID(e, t.OrgID)
+	if err != nil {
+		return nil, fmt.Errorf("getTeamsByOrgID: %v", err)
+	}
+
+	descendants := make([]*Team, 0, 2)
+	visited := map[int64]bool{t.ID: true}
+	parentIDs := []int64{t.ID}
+	for len(parentIDs) > 0 {
+		parentID := parentIDs[0]
+		parentIDs = parentIDs[1:]
+		for _, team := range teams {
+			if team.ParentID != parentID || visited[team.ID] {
+				continue
+			}
+			visited[team.ID] = true
+			descendants = append(descendants, team)
+			parentIDs = append(parentIDs, team.ID)
+		}
+	}
+	return descendants, nil
+}
+
+// GetDescendants returns all child teams of the team recursively.
+func (t *Team) GetDescendants() ([]*Team, error) {
+	return t.getDescendants(x)
+}
+
+// getInheritedMembers loads members of the team along with members of its parent
+// teams into t.Members, who all have access to repositories of the team.
+func (t *Team) getInheritedMembers(e Engine) error {
+	if err := t.getMembers(e); err != nil {
+		return err
+	} else if t.IsOwnerTeam() {
+		return nil
+	}
+
+	ancestors, err := t.getAncestors(e)
+	if err != nil {
+		return fmt.Errorf("getAncestors: %v", err)
+	}
+
+	memberIDs := make(map[int64]bool, len(t.Members))
+	for _, m := range t.Members {
+		memberIDs[m.ID] = true
+	}
+	for _, parent := range ancestors {
+		members, err := getTeamMembers(e, parent.ID)
+		if err != nil {
+			return fmt.Errorf("getTeamMembers [%d]: %v", parent.ID, err)
+		}
+		for _, m := range members {
+			if memberIDs[m.ID] {
+				continue
+			}
+			memberIDs[m.ID] = true
+			t.Members = append(t.Members, m)
+		}
+	}
+	return nil
+}
+
+// getInheritingRepositories returns repositories of the team and all its child teams,
+// whose accesses depend on members of the team.
+func (t *Team) getInheritingRepositories(e Engine) ([]*Repository, error) {
+	if err := t.getRepositories(e); err != nil {
+		return nil, fmt.Errorf("getRepositories: %v", err)
+	}
+
+	descendants, err := t.getDescendants(e)
+	if err != nil {
+		return nil, fmt.Errorf("getDescendants: %v", err)
+	}
+
+	repos := make([]*Repository, 0, len(t.Repos))
+	repoIDs := make(map[int64]bool, len(t.Repos))
+	for _, team := range append([]*Team{t}, descendants...) {
+		if team != t {
+			if err = team.getRepositories(e); err != nil {
+				return nil, fmt.Errorf("getRepositories [%d]: %v", team.ID, err)
+			}
+		}
+		for _, repo := range team.Repos {
+			if repoIDs[repo.ID] {
+				continue
+			}
+			repoIDs[repo.ID] = true
+			repos = append(repos, repo)
+		}
+	}
+	return repos, nil
+}
+
+// checkTeamParent returns an error if the team cannot have the parent team, i.e.
+// the parent belongs to another organization, or the team is the owner team or an
+// ancestor of the parent team.
+func checkTeamParent(e Engine, t *Team) error {
+	if t.ParentID == 0 {
+		return nil
+	}
+
+	invalidErr := errors.InvalidTeamParent{TeamID: t.ID, ParentID: t.ParentID}
+	if t.IsOwnerTeam() || t.ParentID == t.ID {
+		return invalidErr
+	}
+
+	parent, err := getTeamByID(e, t.ParentID)
+	if err != nil {
+		if errors.IsTeamNotExist(err) {
+			return invalidErr
+		}
+		return fmt.Errorf("getTeamByID: %v", err)
+	} else if parent.OrgID != t.OrgID || parent.IsOwnerTeam() {
+		return invalidErr
+	}
+
+	ancestors, err := parent.getAncestors(e)
+	if err != nil {
+		return fmt.Errorf("getAncestors: %v", err)
+	}
+	for _, ancestor := range ancestors {
+		if ancestor.ID == t.ID {
+			return invalidErr
+		}
+	}
+	return nil
+}
+
+// TeamNode is a team in the hierarchy of teams of an organization.
+type TeamNode struct {
+	*Team
+	// Depth is the number of ancestors of the team.
+	Depth int
+}
+
+// buildTeamTree returns teams in depth-first order of the hierarchy, with top-level
+// teams and child teams of a team in their original order. Teams whose parent is
+// not in the list are treated as top-level.
+func buildTeamTree(teams []*Team) []*TeamNode {
+	teamIDs := make(map[int64]bool, len(teams))
+	for _, t := range teams {
+		teamIDs[t.ID] = true
+	}
+
+	children := make(map[int64][]*Team, len(teams))
+	for _, t := range teams {
+		parentID := t.ParentID
+		if !teamIDs[parentID] {
+			parentID = 0
+		}
+		children[parentID] = append(children[parentID], t)
+	}
+
+	nodes := make([]*TeamNode, 0, len(teams))
+	visited := make(map[int64]bool, len(teams))
+	var walk func(parentID int64, depth int)
+	walk = func(parentID int64, depth int) {
+		for _, t := range children[parentID] {
+			if visited[t.ID] {
+				continue
+			}
+			visited[t.ID] = true
+			nodes = append(nodes, &TeamNode{Team: t, Depth: depth})
+			walk(t.ID, depth+1)
+		}
+	}
+	walk(0, 0)
+
+	// Teams in a loop of hierarchy are unreachable from top-level teams.
+	for _, t := range teams {
+		if visited[t.ID] {
+			continue
+		}
+		visited[t.ID] = true
+		nodes = append(nodes, &TeamNode{Team: t, Depth: 0})
+		walk(t.ID, 1)
+	}
+	return nodes
+}
+
+// GetTeamTree returns teams of the organization in depth-first order of the hierarchy.
+func GetTeamTree(orgID int64) ([]*TeamNode, error) {
+	teams := make([]*Team, 0, 5)
+	if err := x.Where("org_id = ?", orgID).Asc("lower_name").Find(&teams); err != nil {
+		return nil, err
+	}
+	return buildTeamTree(teams), nil
+}
@@ -0,0 +1,36 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_buildTeamTree(t *testing.T) {
+	Convey("Build hierarchy of teams", t, func() {
+		teams := []*Team{
+			{ID: 1, Name: "Owners"},
+			{ID: 2, Name: "backend", ParentID: 4},
+			{ID: 3, Name: "docs", ParentID: 5},
+			{ID: 4, Name: "engineering"},
+			{ID: 5, Name: "frontend", ParentID: 4},
+			{ID: 6, Name: "orphan", ParentID: 99},
+			{ID: 7, Name: "loop-a", ParentID: 8},
+			{ID: 8, Name: "loop-b", ParentID: 7},
+		}
+
+		nodes := buildTeamTree(teams)
+		names := make([]string, len(nodes))
+		depths := make([]int, len(nodes))
+		for i := range nodes {
+			names[i] = nodes[i].Name
+			depths[i] = nodes[i].Depth
+		}
+		So(names, ShouldResemble, []string{"Owners", "engineering", "backend", "frontend", "docs", "orphan", "loop-a", "loop-b"})
+		So(depths, ShouldResemble, []int{0, 0, 1, 1, 2, 0, 0, 1})
+	})
+}
@@ -0,0 +1,191 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+)
+
+// RepoUnit is a functional part of a repository that teams can be granted access
+// to separately from their access level of the repository.
+type RepoUnit int
+
+// NOTE: Repository units are stored in database, make a migration when changing values.
+const (
+	REPO_UNIT_CODE     RepoUnit = iota + 1 // 1
+	REPO_UNIT_ISSUES                       // 2
+	REPO_UNIT_WIKI                         // 3
+	REPO_UNIT_RELEASES                     // 4
+)
+
+// RepoUnits contains all repository units in the order of display.
+var RepoUnits = []RepoUnit{
+	REPO_UNIT_CODE,
+	REPO_UNIT_ISSUES,
+	REPO_UNIT_WIKI,
+	REPO_UNIT_RELEASES,
+}
+
+func (unit RepoUnit) String() string {
+	switch unit {
+	case REPO_UNIT_CODE:
+		return "code"
+	case REPO_UNIT_ISSUES:
+		return "issues"
+	case REPO_UNIT_WIKI:
+		return "wiki"
+	case REPO_UNIT_RELEASES:
+		return "releases"
+	default:
+		return ""
+	}
+}
+
+// IsValidUnitAccessMode returns true if given access mode can be granted to a
+// repository unit. Units do not have administrative access levels because there
+// is nothing to administrate within a single unit, but access to a unit can be
+// taken away, e.g. to let a team participate in issues without reading code.
+func IsValidUnitAccessMode(mode AccessMode) bool {
+	return mode >= ACCESS_MODE_NONE && mode <= ACCESS_MODE_WRITE
+}
+
+// TeamUnit represents the access mode of a team to a unit of repositories, which
+// overrides the access level of the team for the unit.
+type TeamUnit struct {
+	ID     int64
+	OrgID  int64      `xorm:"INDEX"`
+	TeamID int64      `xorm:"UNIQUE(s)"`
+	Unit   RepoUnit   `xorm:"UNIQUE(s)"`
+	Mode   AccessMode `xorm:"NOT NULL"`
+}
+
+func (t *Team) getUnits(e Engine) error {
+	t.Units = make([]*TeamUnit, 0, len(RepoUnits))
+	return e.Where("team_id = ?", t.ID).Asc("unit").Find(&t.Units)
+}
+
+// GetUnits loads access modes of the team to repository units that differ from
+// the access level of the team.
+func (t *Team) GetUnits() error {
+	return t.getUnits(x)
+}
+
+// UnitMode returns the access mode of the team to the repository unit, it falls
+// back to the access level of the team if no mode is set for the unit. Units of
+// the team must be loaded before calling this method.
+func (t *Team) UnitMode(unit RepoUnit) AccessMode {
+	if t.IsOwnerTeam() {
+		return ACCESS_MODE_OWNER
+	}
+	for _, u := range t.Units {
+		if u.Unit == unit {
+			return u.Mode
+		}
+	}
+	return t.Authorize
+}
+
+// HasUnitMode returns true if the team has access mode set for the repository unit.
+func (t *Team) HasUnitMode(unit RepoUnit) bool {
+	for _, u := range t.Units {
+		if u.Unit == unit {
+			return true
+		}
+	}
+	return false
+}
+
+// updateUnits replaces access modes of the team to repository units with t.Units.
+func (t *Team) updateUnits(e Engine) error {
+	if _, err := e.Delete(&TeamUnit{TeamID: t.ID}); err != nil {
+		return fmt.Errorf("delete old units: %v", err)
+	}
+	if t.IsOwnerTeam() || len(t.Units) == 0 {
+		return nil
+	}
+
+	for _, u := range t.Units {
+		u.ID = 0
+		u.OrgID = t.OrgID
+		u.TeamID = t.ID
+	}
+	if _, err := e.Insert(t.Units); err != nil {
+		return fmt.Errorf("insert units: %v", err)
+	}
+	return nil
+}
+
+// AccessUnit represents the access mode of a user to a unit of the repository when
+// it is different from the access mode of the user to the repository. Like Access,
+// it is derived from team and collaborator settings when accesses are recalculated.
+type AccessUnit struct {
+	ID     int64
+	UserID int64      `xorm:"UNIQUE(s)"`
+	RepoID int64      `xorm:"UNIQUE(s) INDEX"`
+	Unit   RepoUnit   `xorm:"UNIQUE(s)"`
+	Mode   AccessMode `xorm:"NOT NULL"`
+}
+
+// UnitAccessModes contains access modes of a user to repository units that
+// differ from the access mode of the user to the repository.
+type UnitAccessModes map[RepoUnit]AccessMode
+
+// Get returns the access mode to the unit, or given default mode if no separate
+// access mode is set for the unit.
+func (modes UnitAccessModes) Get(unit RepoUnit, defaultMode AccessMode) AccessMode {
+	if mode, ok := modes[unit]; ok {
+		return mode
+	}
+	return defaultMode
+}
+
+func userUnitAccessModes(e Engine, userID int64, repo *Repository) (UnitAccessModes, error) {
+	if userID <= 0 || userID == repo.OwnerID {
+		return nil, nil
+	}
+
+	accesses := make([]*AccessUnit, 0, len(RepoUnits))
+	if err := e.Where("user_id = ? AND repo_id = ?", userID, repo.ID).Find(&accesses); err != nil {
+		return nil, err
+	}
+
+	floorMode := repo.baseAccessMode(userID)
+	modes := make(UnitAccessModes, len(accesses))
+	for _, access := range accesses {
+		modes[access.Unit] = maxAccessMode(floorMode, access.Mode)
+	}
+	return modes, nil
+}
+
+// UserUnitAccessModes returns access modes of given user to units of the repository
+// that differ from the access mode of the user to the repository.
+func UserUnitAccessModes(userID int64, repo *Repository) (UnitAccessModes, error) {
+	return userUnitAccessModes(x, userID, repo)
+}
+
+func userUnitAccessMode(e Engine, userID int64, repo *Repository, unit RepoUnit) (AccessMode, error) {
+	mode, err := userAccessMode(e, userID, repo)
+	if err != nil {
+		return ACCESS_MODE_NONE, err
+	}
+
+	modes, err := userUnitAccessModes(e, userID, repo)
+	if err != nil {
+		return ACCESS_MODE_NONE, err
+	}
+	return modes.Get(unit, mode), nil
+}
+
+// UserUnitAccessMode returns the access mode of given user to the unit of the repository.
+func UserUnitAccessMode(userID int64, repo *Repository, unit RepoUnit) (AccessMode, error) {
+	return userUnitAccessMode(x, userID, repo, unit)
+}
+
+// HasUnitAccess returns true if given user has the requested access mode to the
+// unit of the repository.
+func HasUnitAccess(userID int64, repo *Repository, unit RepoUnit, testMode AccessMode) (bool, error) {
+	mode, err := userUnitAccessMode(x, userID, repo, unit)
+	return mode >= testMode, err
+}
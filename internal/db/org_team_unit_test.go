@@ -0,0 +1,47 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Team_UnitMode(t *testing.T) {
+	Convey("Get access mode of team to repository units", t, func() {
+		team := &Team{
+			Name:      "docs",
+			Authorize: ACCESS_MODE_READ,
+			Units: []*TeamUnit{
+				{Unit: REPO_UNIT_WIKI, Mode: ACCESS_MODE_WRITE},
+			},
+		}
+		So(team.UnitMode(REPO_UNIT_CODE), ShouldEqual, ACCESS_MODE_READ)
+		So(team.UnitMode(REPO_UNIT_WIKI), ShouldEqual, ACCESS_MODE_WRITE)
+		So(team.HasUnitMode(REPO_UNIT_CODE), ShouldBeFalse)
+		So(team.HasUnitMode(REPO_UNIT_WIKI), ShouldBeTrue)
+
+		owners := &Team{
+			Name:      OWNER_TEAM,
+			Authorize: ACCESS_MODE_OWNER,
+			Units: []*TeamUnit{
+				{Unit: REPO_UNIT_CODE, Mode: ACCESS_MODE_READ},
+			},
+		}
+		So(owners.UnitMode(REPO_UNIT_CODE), ShouldEqual, ACCESS_MODE_OWNER)
+	})
+}
+
+func Test_UnitAccessModes_Get(t *testing.T) {
+	Convey("Get access mode to repository unit", t, func() {
+		modes := UnitAccessModes{REPO_UNIT_CODE: ACCESS_MODE_READ}
+		So(modes.Get(REPO_UNIT_CODE, ACCESS_MODE_WRITE), ShouldEqual, ACCESS_MODE_READ)
+		So(modes.Get(REPO_UNIT_ISSUES, ACCESS_MODE_WRITE), ShouldEqual, ACCESS_MODE_WRITE)
+
+		var nilModes UnitAccessModes
+		So(nilModes.Get(REPO_UNIT_WIKI, ACCESS_MODE_TRIAGE), ShouldEqual, ACCESS_MODE_TRIAGE)
+	})
+}
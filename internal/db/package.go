@@ -0,0 +1,577 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	log "unknwon.dev/clog/v2"
+	"xorm.io/xorm"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/db/errors"
+	"gogs.io/gogs/internal/storage"
+)
+
+// PackageType is the format of packages, which decides how they are published and
+// installed by clients.
+type PackageType string
+
+const (
+	PACKAGE_TYPE_GENERIC   PackageType = "generic"
+	PACKAGE_TYPE_CONTAINER PackageType = "container"
+)
+
+// Package is a package published to the registry of a repository, which has the same
+// permissions as the repository.
+type Package struct {
+	ID          int64
+	RepoID      int64       `xorm:"UNIQUE(s) INDEX NOT NULL"`
+	Type        PackageType `xorm:"UNIQUE(s) VARCHAR(16) NOT NULL"`
+	Name        string      `xorm:"UNIQUE(s) NOT NULL"`
+	NumVersions int         `xorm:"NOT NULL DEFAULT 0"`
+
+	Created     time.Time `xorm:"-" json:"-"`
+	CreatedUnix int64
+	Updated     time.Time `xorm:"-" json:"-"`
+	UpdatedUnix int64     `xorm:"INDEX"`
+}
+
+// IsContainer returns true if the package is a container image.
+func (p *Package) IsContainer() bool {
+	return p.Type == PACKAGE_TYPE_CONTAINER
+}
+
+func (p *Package) BeforeInsert() {
+	p.CreatedUnix = time.Now().Unix()
+	p.UpdatedUnix = p.CreatedUnix
+}
+
+func (p *Package) AfterSet(colName string, _ xorm.Cell) {
+	switch colName {
+	case "created_unix":
+		p.Created = time.Unix(p.CreatedUnix, 0).Local()
+	case "updated_unix":
+		p.Updated = time.Unix(p.UpdatedUnix, 0).Local()
+	}
+}
+
+// PackageVersion is a published version of a package, which is a tag of images for
+// container packages.
+type PackageVersion struct {
+	ID        int64
+	PackageID int64  `xorm:"UNIQUE(s) INDEX NOT NULL"`
+	Version   string `xorm:"UNIQUE(s) NOT NULL"`
+	CreatorID int64
+	Creator   *User          `xorm:"-" json:"-"`
+	Files     []*PackageFile `xorm:"-" json:"-"`
+
+	Created     time.Time `xorm:"-" json:"-"`
+	CreatedUnix int64
+}
+
+func (v *PackageVersion) BeforeInsert() {
+	v.CreatedUnix = time.Now().Unix()
+}
+
+func (v *PackageVersion) AfterSet(colName string, _ xorm.Cell) {
+	switch colName {
+	case "created_unix":
+		v.Created = time.Unix(v.CreatedUnix, 0).Local()
+	}
+}
+
+func (v *PackageVersion) loadAttributes(e Engine) (err error) {
+	if v.Creator == nil {
+		v.Creator, err = getUserByID(e, v.CreatorID)
+		if errors.IsUserNotExist(err) {
+			v.Creator = NewGhostUser()
+			err = nil
+		} else if err != nil {
+			return fmt.Errorf("getUserByID [%d]: %v", v.CreatorID, err)
+		}
+	}
+	if v.Files == nil {
+		v.Files = make([]*PackageFile, 0, 1)
+		if err = e.Where("version_id = ?", v.ID).Asc("name").Find(&v.Files); err != nil {
+			return fmt.Errorf("find files: %v", err)
+		}
+	}
+	return nil
+}
+
+// PackageFile is a file of a package version, the content is saved as a blob of the
+// repository.
+type PackageFile struct {
+	ID          int64
+	PackageID   int64  `xorm:"INDEX NOT NULL"`
+	VersionID   int64  `xorm:"UNIQUE(s) NOT NULL"`
+	Name        string `xorm:"UNIQUE(s) NOT NULL"`
+	SHA256      string `xorm:"sha256 VARCHAR(64) INDEX NOT NULL"`
+	Size        int64
+	ContentType string
+
+	Created     time.Time `xorm:"-" json:"-"`
+	CreatedUnix int64
+}
+
+func (f *PackageFile) BeforeInsert() {
+	f.CreatedUnix = time.Now().Unix()
+}
+
+func (f *PackageFile) AfterSet(colName string, _ xorm.Cell) {
+	switch colName {
+	case "created_unix":
+		f.Created = time.Unix(f.CreatedUnix, 0).Local()
+	}
+}
+
+// PackageBlob is content uploaded to the package registry of a repository. Blobs are
+// content-addressed, a blob with the same digest is saved only once in the storage
+// no matter how many repositories it is uploaded to.
+type PackageBlob struct {
+	ID      int64
+	RepoID  int64  `xorm:"UNIQUE(s) NOT NULL"`
+	SHA256  string `xorm:"sha256 UNIQUE(s) INDEX VARCHAR(64) NOT NULL"`
+	Size    int64
+	Storage string
+
+	Created     time.Time `xorm:"-" json:"-"`
+	CreatedUnix int64
+}
+
+func (b *PackageBlob) BeforeInsert() {
+	b.CreatedUnix = time.Now().Unix()
+}
+
+func (b *PackageBlob) AfterSet(colName string, _ xorm.Cell) {
+	switch colName {
+	case "created_unix":
+		b.Created = time.Unix(b.CreatedUnix, 0).Local()
+	}
+}
+
+// packageBlobStore returns the blob store of given name that package blobs are saved to.
+func packageBlobStore(name string) (storage.Store, error) {
+	return blobStore(name, conf.Packages.StoragePath, "packages")
+}
+
+// packageBlobKey returns the key of the blob of given digest in the blob store.
+func packageBlobKey(sha string) string {
+	return sha[0:2] + "/" + sha[2:4] + "/" + sha
+}
+
+// IsValidSHA256 returns true if given string is a valid hex-encoded SHA256 digest.
+func IsValidSHA256(sha string) bool {
+	if len(sha) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(sha)
+	return err == nil && strings.ToLower(sha) == sha
+}
+
+// Open opens the content of the blob for reading.
+func (b *PackageBlob) Open() (io.ReadCloser, error) {
+	store, err := packageBlobStore(b.Storage)
+	if err != nil {
+		return nil, err
+	}
+	return store.Open(packageBlobKey(b.SHA256))
+}
+
+// GetPackageBlob returns the blob of given digest in the repository.
+func GetPackageBlob(repoID int64, sha string) (*PackageBlob, error) {
+	b := new(PackageBlob)
+	has, err := x.Where("repo_id = ? AND sha256 = ?", repoID, sha).Get(b)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		return nil, errors.PackageBlobNotExist{RepoID: repoID, SHA256: sha}
+	}
+	return b, nil
+}
+
+// CreatePackageBlob saves content read from r as a blob of the repository. The content
+// is verified against the digest if it is not empty. It returns the existing blob if
+// the repository already has the same content.
+func CreatePackageBlob(repoID int64, r io.Reader, sha string) (*PackageBlob, error) {
+	if err := os.MkdirAll(conf.Packages.TempPath, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("create temp directory: %v", err)
+	}
+	tmp, err := ioutil.TempFile(conf.Packages.TempPath, "blob-")
+	if err != nil {
+		return nil, fmt.Errorf("create temp file: %v", err)
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+
+	if conf.Packages.MaxFileSize > 0 {
+		r = io.LimitReader(r, conf.Packages.MaxFileSize<<20+1)
+	}
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if err != nil {
+		return nil, fmt.Errorf("read content: %v", err)
+	} else if conf.Packages.MaxFileSize > 0 && size > conf.Packages.MaxFileSize<<20 {
+		return nil, errors.PackageBlobTooLarge{MaxSize: conf.Packages.MaxFileSize}
+	}
+	digest := hex.EncodeToString(h.Sum(nil))
+	if sha != "" && sha != digest {
+		return nil, errors.PackageBlobMismatch{SHA256: sha}
+	}
+
+	b, err := GetPackageBlob(repoID, digest)
+	if err == nil {
+		return b, nil
+	} else if !errors.IsPackageBlobNotExist(err) {
+		return nil, fmt.Errorf("GetPackageBlob: %v", err)
+	}
+
+	// The same content uploaded to other repositories does not need to be saved again.
+	existing := new(PackageBlob)
+	has, err := x.Where("sha256 = ?", digest).Get(existing)
+	if err != nil {
+		return nil, fmt.Errorf("get existing blob: %v", err)
+	}
+	b = &PackageBlob{
+		RepoID:  repoID,
+		SHA256:  digest,
+		Size:    size,
+		Storage: conf.Packages.Storage,
+	}
+	if has {
+		b.Storage = existing.Storage
+	} else {
+		store, err := packageBlobStore(b.Storage)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("seek: %v", err)
+		} else if err = store.Put(packageBlobKey(digest), tmp, size); err != nil {
+			return nil, fmt.Errorf("put: %v", err)
+		}
+	}
+
+	if _, err = x.Insert(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// removePackageBlobFiles removes files of given blobs from the storage if they are no
+// longer referenced by any repository.
+func removePackageBlobFiles(blobs []*PackageBlob) {
+	for _, b := range blobs {
+		count, err := x.Where("sha256 = ?", b.SHA256).Count(new(PackageBlob))
+		if err != nil {
+			log.Error("Failed to count package blobs [sha256: %s]: %v", b.SHA256, err)
+			continue
+		} else if count > 0 {
+			continue
+		}
+
+		store, err := packageBlobStore(b.Storage)
+		if err == nil {
+			err = store.Delete(packageBlobKey(b.SHA256))
+		}
+		if err != nil {
+			log.Error("Failed to delete package blob [sha256: %s]: %v", b.SHA256, err)
+		}
+	}
+}
+
+// GetPackageByName returns the package of given type and name in the repository.
+func GetPackageByName(repoID int64, typ PackageType, name string) (*Package, error) {
+	p := new(Package)
+	has, err := x.Where("repo_id = ? AND type = ? AND name = ?", repoID, typ, name).Get(p)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		return nil, errors.PackageNotExist{RepoID: repoID, Type: string(typ), Name: name}
+	}
+	return p, nil
+}
+
+// GetPackageByID returns the package of given ID in the repository.
+func GetPackageByID(repoID, id int64) (*Package, error) {
+	p := new(Package)
+	has, err := x.Where("id = ? AND repo_id = ?", id, repoID).Get(p)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		return nil, errors.PackageNotExist{RepoID: repoID, ID: id}
+	}
+	return p, nil
+}
+
+// GetPackages returns packages of the repository, the most recently updated first.
+func GetPackages(repoID int64, page, pageSize int) ([]*Package, error) {
+	if page <= 0 {
+		page = 1
+	}
+	packages := make([]*Package, 0, pageSize)
+	return packages, x.Where("repo_id = ?", repoID).Desc("updated_unix", "id").
+		Limit(pageSize, (page-1)*pageSize).Find(&packages)
+}
+
+// CountPackages returns the number of packages of the repository.
+func CountPackages(repoID int64) (int64, error) {
+	return x.Where("repo_id = ?", repoID).Count(new(Package))
+}
+
+// GetPackageVersion returns the version of the package.
+func GetPackageVersion(packageID int64, version string) (*PackageVersion, error) {
+	v := new(PackageVersion)
+	has, err := x.Where("package_id = ? AND version = ?", packageID, version).Get(v)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		return nil, errors.PackageVersionNotExist{PackageID: packageID, Version: version}
+	}
+	return v, v.loadAttributes(x)
+}
+
+// GetPackageVersionByID returns the version of given ID of the package.
+func GetPackageVersionByID(packageID, id int64) (*PackageVersion, error) {
+	v := new(PackageVersion)
+	has, err := x.Where("id = ? AND package_id = ?", id, packageID).Get(v)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		return nil, errors.PackageVersionNotExist{PackageID: packageID}
+	}
+	return v, v.loadAttributes(x)
+}
+
+// GetPackageVersions returns all versions of the package with their files, the latest
+// first.
+func GetPackageVersions(packageID int64) ([]*PackageVersion, error) {
+	versions := make([]*PackageVersion, 0, 10)
+	if err := x.Where("package_id = ?", packageID).Desc("id").Find(&versions); err != nil {
+		return nil, err
+	}
+	for _, v := range versions {
+		if err := v.loadAttributes(x); err != nil {
+			return nil, err
+		}
+	}
+	return versions, nil
+}
+
+// GetPackageFile returns the file of given name of the package version.
+func GetPackageFile(version *PackageVersion, name string) (*PackageFile, error) {
+	f := new(PackageFile)
+	has, err := x.Where("version_id = ? AND name = ?", version.ID, name).Get(f)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		return nil, errors.PackageFileNotExist{PackageID: version.PackageID, Name: name}
+	}
+	return f, nil
+}
+
+// GetPackageFileBySHA256 returns a file of the package with given digest.
+func GetPackageFileBySHA256(packageID int64, sha string) (*PackageFile, error) {
+	f := new(PackageFile)
+	has, err := x.Where("package_id = ? AND sha256 = ?", packageID, sha).Get(f)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		return nil, errors.PackageFileNotExist{PackageID: packageID, SHA256: sha}
+	}
+	return f, nil
+}
+
+// AddPackageFileOptions contains options of a file to be added to a package version.
+type AddPackageFileOptions struct {
+	Type        PackageType
+	Name        string
+	Version     string
+	FileName    string
+	ContentType string
+	// Overwrite indicates whether to replace the existing file of the same name, or
+	// returns errors.PackageFileAlreadyExist.
+	Overwrite bool
+}
+
+// AddPackageFile adds the blob as a file of the package version in the repository,
+// the package and the version are created if they do not exist.
+func AddPackageFile(doer *User, repo *Repository, blob *PackageBlob, opts AddPackageFileOptions) (_ *PackageFile, err error) {
+	sess := x.NewSession()
+	defer sess.Close()
+	if err = sess.Begin(); err != nil {
+		return nil, err
+	}
+
+	p := &Package{
+		RepoID: repo.ID,
+		Type:   opts.Type,
+		Name:   opts.Name,
+	}
+	has, err := sess.Get(p)
+	if err != nil {
+		return nil, fmt.Errorf("get package: %v", err)
+	} else if !has {
+		if _, err = sess.Insert(p); err != nil {
+			return nil, fmt.Errorf("insert package: %v", err)
+		}
+	}
+
+	v := &PackageVersion{
+		PackageID: p.ID,
+		Version:   opts.Version,
+	}
+	has, err = sess.Get(v)
+	if err != nil {
+		return nil, fmt.Errorf("get version: %v", err)
+	} else if !has {
+		v.CreatorID = doer.ID
+		if _, err = sess.Insert(v); err != nil {
+			return nil, fmt.Errorf("insert version: %v", err)
+		}
+		p.NumVersions++
+	}
+
+	f := &PackageFile{
+		VersionID: v.ID,
+		Name:      opts.FileName,
+	}
+	has, err = sess.Get(f)
+	if err != nil {
+		return nil, fmt.Errorf("get file: %v", err)
+	} else if has {
+		if !opts.Overwrite {
+			return nil, errors.PackageFileAlreadyExist{PackageID: p.ID, Version: v.Version, Name: f.Name}
+		}
+		if _, err = sess.ID(f.ID).Delete(new(PackageFile)); err != nil {
+			return nil, fmt.Errorf("delete existing file: %v", err)
+		}
+	}
+
+	f = &PackageFile{
+		PackageID:   p.ID,
+		VersionID:   v.ID,
+		Name:        opts.FileName,
+		SHA256:      blob.SHA256,
+		Size:        blob.Size,
+		ContentType: opts.ContentType,
+	}
+	if _, err = sess.Insert(f); err != nil {
+		return nil, fmt.Errorf("insert file: %v", err)
+	}
+
+	p.UpdatedUnix = time.Now().Unix()
+	if _, err = sess.ID(p.ID).Cols("num_versions", "updated_unix").Update(p); err != nil {
+		return nil, fmt.Errorf("update package: %v", err)
+	}
+	return f, sess.Commit()
+}
+
+// DeletePackageVersion deletes the version and its files from the package, and the
+// package if there are no more versions. Blobs of files of generic packages that are
+// no longer referenced are deleted, layers of container images are kept until the
+// repository is deleted because they are not tracked by files.
+func DeletePackageVersion(p *Package, v *PackageVersion) (err error) {
+	files := make([]*PackageFile, 0, 1)
+	if err = x.Where("version_id = ?", v.ID).Find(&files); err != nil {
+		return fmt.Errorf("find files: %v", err)
+	}
+
+	sess := x.NewSession()
+	defer sess.Close()
+	if err = sess.Begin(); err != nil {
+		return err
+	}
+
+	if _, err = sess.Where("version_id = ?", v.ID).Delete(new(PackageFile)); err != nil {
+		return fmt.Errorf("delete files: %v", err)
+	} else if _, err = sess.ID(v.ID).Delete(new(PackageVersion)); err != nil {
+		return fmt.Errorf("delete version: %v", err)
+	}
+
+	p.NumVersions--
+	if p.NumVersions <= 0 {
+		_, err = sess.ID(p.ID).Delete(new(Package))
+	} else {
+		_, err = sess.ID(p.ID).Cols("num_versions").Update(p)
+	}
+	if err != nil {
+		return fmt.Errorf("update package: %v", err)
+	}
+
+	var deletedBlobs []*PackageBlob
+	if p.Type == PACKAGE_TYPE_GENERIC {
+		for _, f := range files {
+			count, err := sess.Where("package_id IN (SELECT id FROM package WHERE repo_id = ?) AND sha256 = ?", p.RepoID, f.SHA256).
+				Count(new(PackageFile))
+			if err != nil {
+				return fmt.Errorf("count files: %v", err)
+			} else if count > 0 {
+				continue
+			}
+
+			b := &PackageBlob{RepoID: p.RepoID, SHA256: f.SHA256}
+			if has, err := sess.Get(b); err != nil {
+				return fmt.Errorf("get blob: %v", err)
+			} else if !has {
+				continue
+			}
+			if _, err = sess.ID(b.ID).Delete(new(PackageBlob)); err != nil {
+				return fmt.Errorf("delete blob: %v", err)
+			}
+			deletedBlobs = append(deletedBlobs, b)
+		}
+	}
+
+	if err = sess.Commit(); err != nil {
+		return err
+	}
+	removePackageBlobFiles(deletedBlobs)
+	return nil
+}
+
+// DeletePackage deletes all versions of the package and the package itself.
+func DeletePackage(p *Package) error {
+	versions := make([]*PackageVersion, 0, 10)
+	if err := x.Where("package_id = ?", p.ID).Find(&versions); err != nil {
+		return fmt.Errorf("find versions: %v", err)
+	}
+	for _, v := range versions {
+		if err := DeletePackageVersion(p, v); err != nil {
+			return fmt.Errorf("DeletePackageVersion [%d]: %v", v.ID, err)
+		}
+	}
+	return nil
+}
+
+// deleteRepoPackages deletes all packages of the repository, and returns blobs to be
+// removed from the storage after the transaction is committed.
+func deleteRepoPackages(e Engine, repoID int64) ([]*PackageBlob, error) {
+	blobs := make([]*PackageBlob, 0, 10)
+	if err := e.Where("repo_id = ?", repoID).Find(&blobs); err != nil {
+		return nil, fmt.Errorf("find blobs: %v", err)
+	}
+
+	if _, err := e.Exec("DELETE FROM package_file WHERE package_id IN (SELECT id FROM package WHERE repo_id = ?)", repoID); err != nil {
+		return nil, fmt.Errorf("delete files: %v", err)
+	} else if _, err = e.Exec("DELETE FROM package_version WHERE package_id IN (SELECT id FROM package WHERE repo_id = ?)", repoID); err != nil {
+		return nil, fmt.Errorf("delete versions: %v", err)
+	} else if _, err = e.Delete(&Package{RepoID: repoID}); err != nil {
+		return nil, fmt.Errorf("delete packages: %v", err)
+	} else if _, err = e.Delete(&PackageBlob{RepoID: repoID}); err != nil {
+		return nil, fmt.Errorf("delete blobs: %v", err)
+	}
+	return blobs, nil
+}
@@ -0,0 +1,36 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_IsValidSHA256(t *testing.T) {
+	Convey("Validate SHA256 digests", t, func() {
+		testCases := []struct {
+			sha    string
+			expect bool
+		}{
+			{"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", true},
+			{"E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855", false},
+			{"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b85", false},
+			{"z3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", false},
+			{"", false},
+		}
+		for _, tc := range testCases {
+			So(IsValidSHA256(tc.sha), ShouldEqual, tc.expect)
+		}
+	})
+}
+
+func Test_packageBlobKey(t *testing.T) {
+	Convey("Get key of package blob", t, func() {
+		So(packageBlobKey("e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"), ShouldEqual,
+			"e3/b0/e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
+	})
+}
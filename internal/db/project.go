@@ -0,0 +1,387 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"xorm.io/xorm"
+
+	"gogs.io/gogs/internal/db/errors"
+)
+
+// defaultProjectColumns are titles of columns created along with a new project.
+var defaultProjectColumns = []string{"To do", "In progress", "Done"}
+
+// Project represents a board of an organization to track issues and pull requests
+// across repositories of the organization.
+type Project struct {
+	ID          int64
+	OrgID       int64 `xorm:"INDEX"`
+	Title       string
+	Description string `xorm:"TEXT"`
+	CreatorID   int64
+	IsClosed    bool
+
+	Created     time.Time `xorm:"-" json:"-"`
+	CreatedUnix int64
+	Updated     time.Time `xorm:"-" json:"-"`
+	UpdatedUnix int64
+}
+
+func (p *Project) BeforeInsert() {
+	p.CreatedUnix = time.Now().Unix()
+	p.UpdatedUnix = p.CreatedUnix
+}
+
+func (p *Project) BeforeUpdate() {
+	p.UpdatedUnix = time.Now().Unix()
+}
+
+func (p *Project) AfterSet(colName string, _ xorm.Cell) {
+	switch colName {
+	case "created_unix":
+		p.Created = time.Unix(p.CreatedUnix, 0).Local()
+	case "updated_unix":
+		p.Updated = time.Unix(p.UpdatedUnix, 0).Local()
+	}
+}
+
+// ProjectColumn represents a column of a project board.
+type ProjectColumn struct {
+	ID        int64
+	ProjectID int64 `xorm:"INDEX"`
+	Title     string
+	Sorting   int
+
+	Cards []*ProjectCard `xorm:"-" json:"-"`
+}
+
+// ProjectCard represents an issue or a pull request that is placed in a column of a project.
+type ProjectCard struct {
+	ID        int64
+	ProjectID int64  `xorm:"UNIQUE(s)"`
+	ColumnID  int64  `xorm:"INDEX"`
+	RepoID    int64  `xorm:"INDEX"`
+	IssueID   int64  `xorm:"UNIQUE(s)"`
+	Issue     *Issue `xorm:"-" json:"-"`
+	Sorting   int64
+
+	Created     time.Time `xorm:"-" json:"-"`
+	CreatedUnix int64
+}
+
+func (c *ProjectCard) BeforeInsert() {
+	c.CreatedUnix = time.Now().Unix()
+}
+
+func (c *ProjectCard) AfterSet(colName string, _ xorm.Cell) {
+	switch colName {
+	case "created_unix":
+		c.Created = time.Unix(c.CreatedUnix, 0).Local()
+	}
+}
+
+// NewProject creates a new project with default columns.
+func NewProject(p *Project) (err error) {
+	sess := x.NewSession()
+	defer sess.Close()
+	if err = sess.Begin(); err != nil {
+		return err
+	}
+
+	if _, err = sess.Insert(p); err != nil {
+		return err
+	}
+
+	for i, title := range defaultProjectColumns {
+		if _, err = sess.Insert(&ProjectColumn{
+			ProjectID: p.ID,
+			Title:     title,
+			Sorting:   i,
+		}); err != nil {
+			return fmt.Errorf("insert column: %v", err)
+		}
+	}
+	return sess.Commit()
+}
+
+func getProjectByID(e Engine, orgID, id int64) (*Project, error) {
+	p := new(Project)
+	has, err := e.Where("id = ? AND org_id = ?", id, orgID).Get(p)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		return nil, errors.ProjectNotExist{ID: id, OrgID: orgID}
+	}
+	return p, nil
+}
+
+// GetProjectByID returns the project with given ID in the organization.
+func GetProjectByID(orgID, id int64) (*Project, error) {
+	return getProjectByID(x, orgID, id)
+}
+
+// GetProjects returns open or closed projects of the organization.
+func GetProjects(orgID int64, isClosed bool) ([]*Project, error) {
+	projects := make([]*Project, 0, 10)
+	if err := x.Where("org_id = ? AND is_closed = ?", orgID, isClosed).Desc("updated_unix").Find(&projects); err != nil {
+		return nil, err
+	}
+	return projects, nil
+}
+
+// CountProjects returns the number of open or closed projects of the organization.
+func CountProjects(orgID int64, isClosed bool) int64 {
+	count, _ := x.Where("org_id = ? AND is_closed = ?", orgID, isClosed).Count(new(Project))
+	return count
+}
+
+// UpdateProject updates information of given project.
+func UpdateProject(p *Project) error {
+	_, err := x.ID(p.ID).AllCols().Update(p)
+	return err
+}
+
+func deleteProjectsByOrgID(e Engine, orgID int64) error {
+	for _, bean := range []interface{}{new(ProjectCard), new(ProjectColumn)} {
+		if _, err := e.Where("project_id IN (SELECT id FROM project WHERE org_id = ?)", orgID).Delete(bean); err != nil {
+			return err
+		}
+	}
+	_, err := e.Delete(&Project{OrgID: orgID})
+	return err
+}
+
+// DeleteProject deletes the project with all its columns and cards.
+func DeleteProject(p *Project) (err error) {
+	sess := x.NewSession()
+	defer sess.Close()
+	if err = sess.Begin(); err != nil {
+		return err
+	}
+
+	if err = deleteBeans(sess,
+		&ProjectCard{ProjectID: p.ID},
+		&ProjectColumn{ProjectID: p.ID},
+		&Project{ID: p.ID},
+	); err != nil {
+		return fmt.Errorf("deleteBeans: %v", err)
+	}
+	return sess.Commit()
+}
+
+// GetColumns returns all columns of the project in order.
+func (p *Project) GetColumns() ([]*ProjectColumn, error) {
+	columns := make([]*ProjectColumn, 0, len(defaultProjectColumns))
+	if err := x.Where("project_id = ?", p.ID).Asc("sorting", "id").Find(&columns); err != nil {
+		return nil, err
+	}
+	return columns, nil
+}
+
+// NewProjectColumn appends a new column to the project.
+func NewProjectColumn(p *Project, title string) (*ProjectColumn, error) {
+	last := new(ProjectColumn)
+	if _, err := x.Where("project_id = ?", p.ID).Desc("sorting").Get(last); err != nil {
+		return nil, fmt.Errorf("get last column: %v", err)
+	}
+
+	col := &ProjectColumn{
+		ProjectID: p.ID,
+		Title:     title,
+		Sorting:   last.Sorting + 1,
+	}
+	if _, err := x.Insert(col); err != nil {
+		return nil, err
+	}
+	return col, nil
+}
+
+// GetProjectColumnByID returns the column with given ID in the project.
+func GetProjectColumnByID(projectID, id int64) (*ProjectColumn, error) {
+	col := new(ProjectColumn)
+	has, err := x.Where("id = ? AND project_id = ?", id, projectID).Get(col)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		return nil, errors.ProjectColumnNotExist{ID: id, ProjectID: projectID}
+	}
+	return col, nil
+}
+
+// UpdateProjectColumn updates information of given column.
+func UpdateProjectColumn(col *ProjectColumn) error {
+	_, err := x.ID(col.ID).AllCols().Update(col)
+	return err
+}
+
+// DeleteProjectColumn deletes the column with all its cards.
+func DeleteProjectColumn(col *ProjectColumn) (err error) {
+	sess := x.NewSession()
+	defer sess.Close()
+	if err = sess.Begin(); err != nil {
+		return err
+	}
+
+	if err = deleteBeans(sess,
+		&ProjectCard{ColumnID: col.ID},
+		&ProjectColumn{ID: col.ID},
+	); err != nil {
+		return fmt.Errorf("deleteBeans: %v", err)
+	}
+	return sess.Commit()
+}
+
+// AddProjectCard adds the issue to the column of the project. The issue must belong to a
+// repository of the organization that owns the project.
+func AddProjectCard(p *Project, col *ProjectColumn, issue *Issue) (*ProjectCard, error) {
+	repo, err := GetRepositoryByID(issue.RepoID)
+	if err != nil {
+		return nil, fmt.Errorf("GetRepositoryByID [%d]: %v", issue.RepoID, err)
+	} else if repo.OwnerID != p.OrgID {
+		return nil, fmt.Errorf("issue does not belong to the organization [issue_id: %d, org_id: %d]", issue.ID, p.OrgID)
+	}
+
+	has, err := x.Where("project_id = ? AND issue_id = ?", p.ID, issue.ID).Get(new(ProjectCard))
+	if err != nil {
+		return nil, err
+	} else if has {
+		return nil, errors.ProjectCardAlreadyExist{ProjectID: p.ID, IssueID: issue.ID}
+	}
+
+	card := &ProjectCard{
+		ProjectID: p.ID,
+		ColumnID:  col.ID,
+		RepoID:    issue.RepoID,
+		IssueID:   issue.ID,
+		Sorting:   time.Now().UnixNano(),
+		Issue:     issue,
+	}
+	issue.Repo = repo
+	if _, err = x.Insert(card); err != nil {
+		return nil, err
+	}
+	return card, nil
+}
+
+// GetProjectCardByID returns the card with given ID in the project.
+func GetProjectCardByID(projectID, id int64) (*ProjectCard, error) {
+	card := new(ProjectCard)
+	has, err := x.Where("id = ? AND project_id = ?", id, projectID).Get(card)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		return nil, errors.ProjectCardNotExist{ID: id, ProjectID: projectID}
+	}
+	return card, nil
+}
+
+// MoveProjectCard moves the card to the end of given column.
+func MoveProjectCard(card *ProjectCard, col *ProjectColumn) error {
+	card.ColumnID = col.ID
+	card.Sorting = time.Now().UnixNano()
+	_, err := x.ID(card.ID).Cols("column_id", "sorting").Update(card)
+	return err
+}
+
+// DeleteProjectCard removes the card from its project.
+func DeleteProjectCard(card *ProjectCard) error {
+	_, err := x.ID(card.ID).Delete(new(ProjectCard))
+	return err
+}
+
+// ProjectCardsOptions contains options to filter cards of a project.
+type ProjectCardsOptions struct {
+	UserID int64  // Cards of repositories that the user does not have read access are excluded.
+	RepoID int64  // Only cards of the repository are included.
+	Label  string // Only cards of issues that have the label with this name are included.
+}
+
+// GetCards returns cards of the project that match given options in order, with their issues loaded.
+func (p *Project) GetCards(opts ProjectCardsOptions) ([]*ProjectCard, error) {
+	sess := x.Where("project_id = ?", p.ID)
+	if opts.RepoID > 0 {
+		sess.And("repo_id = ?", opts.RepoID)
+	}
+	cards := make([]*ProjectCard, 0, 10)
+	if err := sess.Asc("sorting", "id").Find(&cards); err != nil {
+		return nil, err
+	}
+
+	repos := make(map[int64]*Repository)
+	readable := make(map[int64]bool)
+	result := make([]*ProjectCard, 0, len(cards))
+	for _, card := range cards {
+		if _, ok := readable[card.RepoID]; !ok {
+			repo, err := GetRepositoryByID(card.RepoID)
+			if err != nil {
+				return nil, fmt.Errorf("GetRepositoryByID [%d]: %v", card.RepoID, err)
+			}
+			repos[repo.ID] = repo
+			readable[repo.ID], err = HasAccess(opts.UserID, repo, ACCESS_MODE_READ)
+			if err != nil {
+				return nil, fmt.Errorf("HasAccess: %v", err)
+			}
+		}
+		if !readable[card.RepoID] {
+			continue
+		}
+
+		issue, err := getRawIssueByID(x, card.IssueID)
+		if err != nil {
+			if errors.IsIssueNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("getRawIssueByID [%d]: %v", card.IssueID, err)
+		}
+		issue.Repo = repos[card.RepoID]
+		if issue.Labels, err = GetLabelsByIssueID(issue.ID); err != nil {
+			return nil, fmt.Errorf("GetLabelsByIssueID [%d]: %v", issue.ID, err)
+		}
+		if opts.Label != "" && !hasLabelNamed(issue.Labels, opts.Label) {
+			continue
+		}
+
+		card.Issue = issue
+		result = append(result, card)
+	}
+	return result, nil
+}
+
+func hasLabelNamed(labels []*Label, name string) bool {
+	for _, l := range labels {
+		if strings.EqualFold(l.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetBoard returns columns of the project with cards that match given options.
+func (p *Project) GetBoard(opts ProjectCardsOptions) ([]*ProjectColumn, error) {
+	columns, err := p.GetColumns()
+	if err != nil {
+		return nil, fmt.Errorf("GetColumns: %v", err)
+	}
+	cards, err := p.GetCards(opts)
+	if err != nil {
+		return nil, fmt.Errorf("GetCards: %v", err)
+	}
+
+	set := make(map[int64]*ProjectColumn, len(columns))
+	for _, col := range columns {
+		col.Cards = make([]*ProjectCard, 0, 5)
+		set[col.ID] = col
+	}
+	for _, card := range cards {
+		if col := set[card.ColumnID]; col != nil {
+			col.Cards = append(col.Cards, card)
+		}
+	}
+	return columns, nil
+}
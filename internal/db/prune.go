@@ -0,0 +1,140 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"time"
+
+	log "unknwon.dev/clog/v2"
+	"xorm.io/builder"
+
+	"gogs.io/gogs/internal/conf"
+)
+
+// prunableTable describes a high-churn table whose old records can be pruned.
+type prunableTable struct {
+	name      string
+	bean      func() interface{}
+	retention func() time.Duration
+	// cond returns condition of records that are old enough to be pruned.
+	cond func(olderThan time.Time) builder.Cond
+}
+
+var prunableTables = []prunableTable{
+	{
+		name:      "hook_task",
+		bean:      func() interface{} { return new(HookTask) },
+		retention: func() time.Duration { return conf.Cron.PruneTables.HookTaskRetention },
+		cond: func(olderThan time.Time) builder.Cond {
+			// Undelivered tasks are never pruned.
+			return builder.Eq{"is_delivered": true}.And(builder.Lt{"delivered": olderThan.UnixNano()})
+		},
+	},
+	{
+		name:      "action",
+		bean:      func() interface{} { return new(Action) },
+		retention: func() time.Duration { return conf.Cron.PruneTables.ActionRetention },
+		cond: func(olderThan time.Time) builder.Cond {
+			return builder.Lt{"created_unix": olderThan.Unix()}
+		},
+	},
+	{
+		name:      "maintenance_job",
+		bean:      func() interface{} { return new(MaintenanceJob) },
+		retention: func() time.Duration { return conf.Cron.PruneTables.MaintenanceJobRetention },
+		cond: func(olderThan time.Time) builder.Cond {
+			// Unfinished jobs are never pruned.
+			return builder.In("status", MAINTENANCE_STATUS_FAILED, MAINTENANCE_STATUS_DONE).
+				And(builder.Lt{"created_unix": olderThan.Unix()})
+		},
+	},
+	{
+		name:      "notice",
+		bean:      func() interface{} { return new(Notice) },
+		retention: func() time.Duration { return conf.Cron.PruneTables.NoticeRetention },
+		cond: func(olderThan time.Time) builder.Cond {
+			return builder.Lt{"created_unix": olderThan.Unix()}
+		},
+	},
+}
+
+// pruneTable deletes records match the condition in batches, it sleeps for given interval
+// between batches so other writers are not blocked by a long-running delete.
+func pruneTable(t prunableTable, cond builder.Cond, batchSize int, interval time.Duration) (int64, error) {
+	var total int64
+	for {
+		ids := make([]int64, 0, batchSize)
+		if err := x.Table(t.bean()).Cols("id").Where(cond).Asc("id").Limit(batchSize).Find(&ids); err != nil {
+			return total, fmt.Errorf("find IDs: %v", err)
+		} else if len(ids) == 0 {
+			return total, nil
+		}
+
+		n, err := x.In("id", ids).Delete(t.bean())
+		if err != nil {
+			return total, fmt.Errorf("delete: %v", err)
+		}
+		total += n
+
+		if len(ids) < batchSize {
+			return total, nil
+		}
+		time.Sleep(interval)
+	}
+}
+
+// PruneTables deletes records of high-churn tables that are older than configured retentions.
+func PruneTables() {
+	if taskStatusTable.IsRunning(_PRUNE_TABLES) {
+		return
+	}
+	taskStatusTable.Start(_PRUNE_TABLES)
+	defer taskStatusTable.Stop(_PRUNE_TABLES)
+
+	log.Trace("Doing: PruneTables")
+
+	batchSize := conf.Cron.PruneTables.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	for _, t := range prunableTables {
+		retention := t.retention()
+		if retention <= 0 {
+			continue
+		}
+
+		n, err := pruneTable(t, t.cond(time.Now().Add(-retention)), batchSize, conf.Cron.PruneTables.BatchInterval)
+		if err != nil {
+			log.Error("Failed to prune table %q: %v", t.name, err)
+			continue
+		}
+		log.Trace("Pruned %d records from table %q", n, t.name)
+	}
+}
+
+// TableStat contains statistics of a prunable table.
+type TableStat struct {
+	Name      string
+	Rows      int64
+	Retention time.Duration // Zero means records are kept forever.
+}
+
+// GetPrunableTableStats returns statistics of tables that can be pruned.
+func GetPrunableTableStats() ([]*TableStat, error) {
+	stats := make([]*TableStat, len(prunableTables))
+	for i, t := range prunableTables {
+		rows, err := x.Count(t.bean())
+		if err != nil {
+			return nil, fmt.Errorf("count %q: %v", t.name, err)
+		}
+		stats[i] = &TableStat{
+			Name:      t.name,
+			Rows:      rows,
+			Retention: t.retention(),
+		}
+	}
+	return stats, nil
+}
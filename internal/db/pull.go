@@ -33,6 +33,7 @@ type PullRequestType int
 const (
 	PULL_REQUEST_GOGS PullRequestType = iota
 	PLLL_ERQUEST_GIT
+	PULL_REQUEST_AGIT // Created by pushing to "refs/for/<base branch>" of the base repository
 )
 
 type PullRequestStatus int
@@ -62,6 +63,9 @@ type PullRequest struct {
 	BaseBranch   string
 	MergeBase    string `xorm:"VARCHAR(40)"`
 
+	// Files that have conflicts when the patch is applied to the base branch.
+	ConflictedFiles []string `xorm:"TEXT JSON"`
+
 	HasMerged      bool
 	MergedCommitID string `xorm:"VARCHAR(40)"`
 	MergerID       int64
@@ -105,8 +109,8 @@ func (pr *PullRequest) loadAttributes(e Engine) (err error) {
 	if pr.HasMerged && pr.Merger == nil {
 		pr.Merger, err = getUserByID(e, pr.MergerID)
 		if errors.IsUserNotExist(err) {
+			pr.Merger = getGhostUser(e, pr.MergerID)
 			pr.MergerID = -1
-			pr.Merger = NewGhostUser()
 		} else if err != nil {
 			return fmt.Errorf("getUserByID [%d]: %v", pr.MergerID, err)
 		}
@@ -185,6 +189,43 @@ func (pr *PullRequest) CanAutoMerge() bool {
 	return pr.Status == PULL_REQUEST_STATUS_MERGEABLE
 }
 
+// HeadRefName returns the reference in the base repository that keeps the head commit
+// of the pull request, which is kept after the pull request is merged.
+func (pr *PullRequest) HeadRefName() string {
+	return fmt.Sprintf("refs/pull/%d/head", pr.Index)
+}
+
+// IsAgit returns true if the pull request is created by pushing to "refs/for/<base branch>",
+// whose head commit is only kept by the reference of the pull request in the base repository.
+func (pr *PullRequest) IsAgit() bool {
+	return pr.Type == PULL_REQUEST_AGIT
+}
+
+// HeadRevision returns the revision of the head commit in the head repository.
+func (pr *PullRequest) HeadRevision() string {
+	if pr.IsAgit() {
+		return pr.HeadRefName()
+	}
+	return pr.HeadBranch
+}
+
+// IsHeadExist returns true if the head commit still exists in the head repository.
+func (pr *PullRequest) IsHeadExist(headGitRepo *git.Repository) bool {
+	if pr.IsAgit() {
+		_, err := git.GetFullCommitID(headGitRepo.Path, pr.HeadRefName())
+		return err == nil
+	}
+	return headGitRepo.IsBranchExist(pr.HeadBranch)
+}
+
+// HeadCommitID returns the ID of the head commit in the head repository.
+func (pr *PullRequest) HeadCommitID(headGitRepo *git.Repository) (string, error) {
+	if pr.IsAgit() {
+		return git.GetFullCommitID(headGitRepo.Path, pr.HeadRefName())
+	}
+	return headGitRepo.GetBranchCommitID(pr.HeadBranch)
+}
+
 // MergeStyle represents the approach to merge commits into base branch.
 type MergeStyle string
 
@@ -239,10 +280,15 @@ func (pr *PullRequest) Merge(doer *User, baseGitRepo *git.Repository, mergeStyle
 		return fmt.Errorf("git remote add [%s -> %s]: %s", headRepoPath, tmpBasePath, stderr)
 	}
 
-	// Fetch information from head repository to the temporary copy.
+	// Fetch information from head repository to the temporary copy, the head commit of
+	// pull requests of AGit flow is fetched as if it is a branch of the head repository.
+	fetchArgs := []string{"fetch", "head_repo"}
+	if pr.IsAgit() {
+		fetchArgs = append(fetchArgs, "+"+pr.HeadRefName()+":refs/remotes/head_repo/"+pr.HeadBranch)
+	}
 	if _, stderr, err = process.ExecDir(-1, tmpBasePath,
 		fmt.Sprintf("PullRequest.Merge (git fetch): %s", tmpBasePath),
-		"git", "fetch", "head_repo"); err != nil {
+		"git", fetchArgs...); err != nil {
 		return fmt.Errorf("git fetch [%s -> %s]: %s", headRepoPath, tmpBasePath, stderr)
 	}
 
@@ -267,18 +313,19 @@ func (pr *PullRequest) Merge(doer *User, baseGitRepo *git.Repository, mergeStyle
 		sig := doer.NewGitSig()
 		if _, stderr, err = process.ExecDir(-1, tmpBasePath,
 			fmt.Sprintf("PullRequest.Merge (git merge): %s", tmpBasePath),
-			"git", "commit", fmt.Sprintf("--author='%s <%s>'", sig.Name, sig.Email),
-			"-m", fmt.Sprintf("Merge branch '%s' of %s/%s into %s", pr.HeadBranch, pr.HeadUserName, pr.HeadRepo.Name, pr.BaseBranch),
-			"-m", commitDescription); err != nil {
+			"git", append(signingArgs(signOnMerge), "commit", fmt.Sprintf("--author='%s <%s>'", sig.Name, sig.Email),
+				"-m", fmt.Sprintf("Merge branch '%s' of %s/%s into %s", pr.HeadBranch, pr.HeadUserName, pr.HeadRepo.Name, pr.BaseBranch),
+				"-m", commitDescription)...); err != nil {
 			return fmt.Errorf("git commit [%s]: %v - %s", tmpBasePath, err, stderr)
 		}
 
 	case MERGE_STYLE_REBASE: // Rebase before merging
 
 		// Rebase head branch based on base branch, this creates a non-branch commit state.
+		// Rebased commits are new commits created by the server to be signed.
 		if _, stderr, err = process.ExecDir(-1, tmpBasePath,
 			fmt.Sprintf("PullRequest.Merge (git rebase): %s", tmpBasePath),
-			"git", "rebase", "--quiet", pr.BaseBranch, remoteHeadBranch); err != nil {
+			"git", append(signingArgs(signOnMerge), "rebase", "--quiet", pr.BaseBranch, remoteHeadBranch)...); err != nil {
 			return fmt.Errorf("git rebase [%s on %s]: %s", remoteHeadBranch, pr.BaseBranch, stderr)
 		}
 
@@ -315,9 +362,9 @@ func (pr *PullRequest) Merge(doer *User, baseGitRepo *git.Repository, mergeStyle
 		return fmt.Errorf("git push: %s", stderr)
 	}
 
-	pr.MergedCommitID, err = headGitRepo.GetBranchCommitID(pr.HeadBranch)
+	pr.MergedCommitID, err = pr.HeadCommitID(headGitRepo)
 	if err != nil {
-		return fmt.Errorf("GetBranchCommit: %v", err)
+		return fmt.Errorf("HeadCommitID: %v", err)
 	}
 
 	pr.HasMerged = true
@@ -429,17 +476,43 @@ func (pr *PullRequest) testPatch() (err error) {
 	args = append(args, patchPath)
 
 	pr.Status = PULL_REQUEST_STATUS_CHECKING
+	pr.ConflictedFiles = nil
 	_, stderr, err := process.ExecDir(-1, pr.BaseRepo.LocalCopyPath(),
 		fmt.Sprintf("testPatch (git apply --check): %d", pr.BaseRepo.ID),
 		"git", args...)
 	if err != nil {
 		log.Trace("PullRequest[%d].testPatch (apply): has conflit\n%s", pr.ID, stderr)
 		pr.Status = PULL_REQUEST_STATUS_CONFLICT
+		pr.ConflictedFiles = parseConflictedFiles(stderr)
 		return nil
 	}
 	return nil
 }
 
+// parseConflictedFiles returns names of files that failed to apply from the output of "git apply --check".
+func parseConflictedFiles(stderr string) []string {
+	files := make([]string, 0, 5)
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(stderr, "\n") {
+		if !strings.HasPrefix(line, "error: ") || strings.HasPrefix(line, "error: patch failed: ") {
+			continue
+		}
+
+		// e.g. "error: README.md: patch does not apply"
+		line = strings.TrimPrefix(line, "error: ")
+		i := strings.LastIndex(line, ": ")
+		if i <= 0 {
+			continue
+		}
+		name := line[:i]
+		if !seen[name] {
+			seen[name] = true
+			files = append(files, name)
+		}
+	}
+	return files
+}
+
 // NewPullRequest creates new pull request with labels for repository.
 func NewPullRequest(repo *Repository, pull *Issue, labelIDs []int64, uuids []string, pr *PullRequest, patch []byte) (err error) {
 	sess := x.NewSession()
@@ -480,6 +553,14 @@ func NewPullRequest(repo *Repository, pull *Issue, labelIDs []int64, uuids []str
 	if err = sess.Commit(); err != nil {
 		return fmt.Errorf("Commit: %v", err)
 	}
+	AddIssueIndexerTask(pull.ID)
+
+	if err = createIssueReferenceEvents(pull.Poster, pull, 0, pull.Content); err != nil {
+		log.Error("createIssueReferenceEvents: %v", err)
+	}
+	if err = AssignReviewers(pull); err != nil {
+		log.Error("AssignReviewers: %v", err)
+	}
 
 	if err = NotifyWatchers(&Action{
 		ActUserID:    pull.Poster.ID,
@@ -496,6 +577,9 @@ func NewPullRequest(repo *Repository, pull *Issue, labelIDs []int64, uuids []str
 	if err = pull.MailParticipants(); err != nil {
 		log.Error("MailParticipants: %v", err)
 	}
+	if err = notifyIssueParticipants(x, repo, pull, 0, pull.PosterID, pull.Content); err != nil {
+		log.Error("notifyIssueParticipants: %v", err)
+	}
 
 	pr.Issue = pull
 	pull.PullRequest = pr
@@ -532,8 +616,8 @@ func GetUnmergedPullRequest(headRepoID, baseRepoID int64, headBranch, baseBranch
 // by given head information (repo and branch).
 func GetUnmergedPullRequestsByHeadInfo(repoID int64, branch string) ([]*PullRequest, error) {
 	prs := make([]*PullRequest, 0, 2)
-	return prs, x.Where("head_repo_id = ? AND head_branch = ? AND has_merged = ? AND issue.is_closed = ?",
-		repoID, branch, false, false).
+	return prs, x.Where("head_repo_id = ? AND head_branch = ? AND has_merged = ? AND issue.is_closed = ? AND type != ?",
+		repoID, branch, false, false, PULL_REQUEST_AGIT).
 		Join("INNER", "issue", "issue.id = pull_request.issue_id").Find(&prs)
 }
 
@@ -613,14 +697,14 @@ func (pr *PullRequest) UpdatePatch() (err error) {
 		headGitRepo.RemoveRemote(tmpRemote)
 	}()
 	remoteBranch := "remotes/" + tmpRemote + "/" + pr.BaseBranch
-	pr.MergeBase, err = headGitRepo.GetMergeBase(remoteBranch, pr.HeadBranch)
+	pr.MergeBase, err = headGitRepo.GetMergeBase(remoteBranch, pr.HeadRevision())
 	if err != nil {
 		return fmt.Errorf("GetMergeBase: %v", err)
 	} else if err = pr.Update(); err != nil {
 		return fmt.Errorf("Update: %v", err)
 	}
 
-	patch, err := headGitRepo.GetPatch(pr.MergeBase, pr.HeadBranch)
+	patch, err := headGitRepo.GetPatch(pr.MergeBase, pr.HeadRevision())
 	if err != nil {
 		return fmt.Errorf("GetPatch: %v", err)
 	}
@@ -636,6 +720,11 @@ func (pr *PullRequest) UpdatePatch() (err error) {
 // corresponding branches of base repository.
 // FIXME: Only push branches that are actually updates?
 func (pr *PullRequest) PushToBaseRepo() (err error) {
+	// The head commit of pull requests of AGit flow is already in the base repository.
+	if pr.IsAgit() {
+		return nil
+	}
+
 	log.Trace("PushToBaseRepo[%d]: pushing commits to base repo 'refs/pull/%d/head'", pr.BaseRepoID, pr.Index)
 
 	headRepoPath := pr.HeadRepo.RepoPath()
@@ -651,7 +740,7 @@ func (pr *PullRequest) PushToBaseRepo() (err error) {
 	// Make sure to remove the remote even if the push fails
 	defer headGitRepo.RemoveRemote(tmpRemoteName)
 
-	headFile := fmt.Sprintf("refs/pull/%d/head", pr.Index)
+	headFile := pr.HeadRefName()
 
 	// Remove head in case there is a conflict.
 	os.Remove(path.Join(pr.BaseRepo.RepoPath(), headFile))
@@ -796,7 +885,7 @@ func (pr *PullRequest) checkAndUpdateStatus() {
 
 	// Make sure there is no waiting test to process before levaing the checking status.
 	if !PullRequestQueue.Exist(pr.ID) {
-		if err := pr.UpdateCols("status"); err != nil {
+		if err := pr.UpdateCols("status", "conflicted_files"); err != nil {
 			log.Error("Update[%d]: %v", pr.ID, err)
 		}
 	}
@@ -848,6 +937,32 @@ func TestPullRequests() {
 	}
 }
 
+// CheckPullRequestsMergeability adds all open pull requests to the test task queue, so their
+// mergeability is kept up to date when base branches are changed without being pushed, e.g. by
+// syncing mirrors. Statuses of pull requests are not changed until the tests are done.
+func CheckPullRequestsMergeability() {
+	if taskStatusTable.IsRunning(_CHECK_PULL_MERGEABILITY) {
+		return
+	}
+	taskStatusTable.Start(_CHECK_PULL_MERGEABILITY)
+	defer taskStatusTable.Stop(_CHECK_PULL_MERGEABILITY)
+
+	log.Trace("Doing: CheckPullRequestsMergeability")
+
+	ids := make([]int64, 0, 10)
+	if err := x.Table("pull_request").Cols("pull_request.id").
+		Join("INNER", "issue", "issue.id = pull_request.issue_id").
+		Where("pull_request.has_merged = ? AND issue.is_closed = ?", false, false).
+		Find(&ids); err != nil {
+		log.Error("Failed to get open pull requests: %v", err)
+		return
+	}
+
+	for _, id := range ids {
+		PullRequestQueue.Add(id)
+	}
+}
+
 func InitTestPullRequests() {
 	go TestPullRequests()
 }
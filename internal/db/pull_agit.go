@@ -0,0 +1,217 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gogs/git-module"
+	api "github.com/gogs/go-gogs-client"
+
+	"gogs.io/gogs/internal/process"
+)
+
+// AGIT_REF_PREFIX is the prefix of references that pushing to creates or updates a pull
+// request without a fork or a branch, e.g. "refs/for/master/topic".
+const AGIT_REF_PREFIX = "refs/for/"
+
+// isValidAgitTopic returns true if the topic only consists of safe characters and
+// can be a part of reference names.
+func isValidAgitTopic(topic string) bool {
+	if strings.IndexFunc(topic, func(r rune) bool { return !isSafeRefNameChar(r) }) > -1 ||
+		strings.Contains(topic, "..") {
+		return false
+	}
+	for _, s := range strings.Split(topic, "/") {
+		if s == "" || strings.HasPrefix(s, ".") || strings.HasSuffix(s, ".") || strings.HasSuffix(s, ".lock") {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseAgitRef returns the base branch and the topic of the pull request from the
+// reference of AGit flow. The topic is given by the push option "topic", or is the
+// last path component of the reference otherwise.
+func ParseAgitRef(refName string, opts PushOptions) (baseBranch, topic string, ok bool) {
+	name := strings.TrimPrefix(refName, AGIT_REF_PREFIX)
+	if name == refName {
+		return "", "", false
+	}
+
+	if topic, ok = opts.Lookup("topic"); ok {
+		baseBranch = name
+	} else if i := strings.LastIndex(name, "/"); i > -1 {
+		baseBranch, topic = name[:i], name[i+1:]
+	}
+	return baseBranch, topic, baseBranch != "" && isValidAgitTopic(topic)
+}
+
+// getUnmergedAgitPullRequest returns the open pull request of AGit flow by given
+// repository, head and base branch.
+func getUnmergedAgitPullRequest(repoID int64, headBranch, baseBranch string) (*PullRequest, error) {
+	pr := new(PullRequest)
+	has, err := x.Where("base_repo_id = ? AND head_branch = ? AND base_branch = ? AND type = ? AND has_merged = ? AND issue.is_closed = ?",
+		repoID, headBranch, baseBranch, PULL_REQUEST_AGIT, false, false).
+		Join("INNER", "issue", "issue.id = pull_request.issue_id").Get(pr)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		return nil, ErrPullRequestNotExist{0, 0, repoID, repoID, headBranch, baseBranch}
+	}
+	return pr, nil
+}
+
+// updateHeadRef points the reference of the pull request in the base repository to
+// the commit.
+func (pr *PullRequest) updateHeadRef(commitID string) error {
+	repoPath := pr.BaseRepo.RepoPath()
+	_, stderr, err := process.ExecDir(-1, repoPath,
+		fmt.Sprintf("PullRequest.updateHeadRef (git update-ref): %s", repoPath),
+		"git", "update-ref", pr.HeadRefName(), commitID)
+	if err != nil {
+		return fmt.Errorf("git update-ref: %v - %s", err, stderr)
+	}
+	return nil
+}
+
+// updateAgitHead updates the pull request of AGit flow to the new head commit pushed
+// by doer, and tests the new patch right away.
+func (pr *PullRequest) updateAgitHead(doer *User, commitID string) (err error) {
+	if err = pr.LoadAttributes(); err != nil {
+		return fmt.Errorf("LoadAttributes: %v", err)
+	} else if err = pr.LoadIssue(); err != nil {
+		return fmt.Errorf("LoadIssue: %v", err)
+	} else if err = pr.updateHeadRef(commitID); err != nil {
+		return fmt.Errorf("updateHeadRef: %v", err)
+	} else if err = pr.UpdatePatch(); err != nil {
+		return fmt.Errorf("UpdatePatch: %v", err)
+	}
+
+	if err = pr.testPatch(); err != nil {
+		return fmt.Errorf("testPatch: %v", err)
+	}
+	pr.checkAndUpdateStatus()
+
+	pr.Issue.PullRequest = pr
+	if err = pr.Issue.LoadAttributes(); err != nil {
+		return fmt.Errorf("Issue.LoadAttributes: %v", err)
+	}
+	if err = PrepareWebhooks(pr.Issue.Repo, HOOK_EVENT_PULL_REQUEST, &api.PullRequestPayload{
+		Action:      api.HOOK_ISSUE_SYNCHRONIZED,
+		Index:       pr.Issue.Index,
+		PullRequest: pr.APIFormat(),
+		Repository:  pr.Issue.Repo.APIFormat(nil),
+		Sender:      doer.APIFormat(),
+	}); err != nil {
+		return fmt.Errorf("PrepareWebhooks: %v", err)
+	}
+	return nil
+}
+
+// PushAgitPullRequest creates a pull request of the base branch with the commit pushed
+// to the reference of AGit flow, or updates the open pull request of the same topic by
+// the pusher. It returns true if the pull request is newly created. The title and
+// description of a new pull request can be given by push options "title" and
+// "description", the title is the summary of the commit by default.
+//
+// The pushed reference is deleted afterwards since the commit is kept by the reference
+// of the pull request, so the pusher is able to push to the same reference again.
+func PushAgitPullRequest(opts PushUpdateOptions) (_ *PullRequest, isNew bool, err error) {
+	repoPath := RepoPath(opts.RepoUserName, opts.RepoName)
+	defer func() {
+		_, stderr, e := process.ExecDir(-1, repoPath,
+			fmt.Sprintf("PushAgitPullRequest (git update-ref -d): %s", repoPath),
+			"git", "update-ref", "-d", opts.RefFullName)
+		if e != nil && err == nil {
+			err = fmt.Errorf("git update-ref -d: %v - %s", e, stderr)
+		}
+	}()
+
+	if opts.NewCommitID == git.EMPTY_SHA {
+		return nil, false, fmt.Errorf("reference %q is deleted", opts.RefFullName)
+	}
+	baseBranch, topic, ok := ParseAgitRef(opts.RefFullName, opts.PushOptions)
+	if !ok {
+		return nil, false, fmt.Errorf("invalid reference %q", opts.RefFullName)
+	}
+
+	owner, err := GetUserByName(opts.RepoUserName)
+	if err != nil {
+		return nil, false, fmt.Errorf("GetUserByName: %v", err)
+	}
+	repo, err := GetRepositoryByName(owner.ID, opts.RepoName)
+	if err != nil {
+		return nil, false, fmt.Errorf("GetRepositoryByName: %v", err)
+	}
+	repo.Owner = owner
+	pusher, err := GetUserByID(opts.PusherID)
+	if err != nil {
+		return nil, false, fmt.Errorf("GetUserByID: %v", err)
+	}
+
+	headBranch := pusher.Name + "/" + topic
+	pr, err := getUnmergedAgitPullRequest(repo.ID, headBranch, baseBranch)
+	if err == nil {
+		if err = pr.updateAgitHead(pusher, opts.NewCommitID); err != nil {
+			return nil, false, fmt.Errorf("updateAgitHead: %v", err)
+		}
+		return pr, false, nil
+	} else if !IsErrPullRequestNotExist(err) {
+		return nil, false, fmt.Errorf("getUnmergedAgitPullRequest: %v", err)
+	}
+
+	gitRepo, err := git.OpenRepository(repoPath)
+	if err != nil {
+		return nil, false, fmt.Errorf("OpenRepository: %v", err)
+	}
+	commit, err := gitRepo.GetCommit(opts.NewCommitID)
+	if err != nil {
+		return nil, false, fmt.Errorf("GetCommit: %v", err)
+	}
+	mergeBase, err := gitRepo.GetMergeBase(git.BRANCH_PREFIX+baseBranch, opts.NewCommitID)
+	if err != nil {
+		return nil, false, fmt.Errorf("GetMergeBase: %v", err)
+	}
+	patch, err := gitRepo.GetPatch(mergeBase, opts.NewCommitID)
+	if err != nil {
+		return nil, false, fmt.Errorf("GetPatch: %v", err)
+	}
+
+	title, _ := opts.PushOptions.Lookup("title")
+	if strings.TrimSpace(title) == "" {
+		title = commit.Summary()
+	}
+	description, _ := opts.PushOptions.Lookup("description")
+
+	pull := &Issue{
+		RepoID:   repo.ID,
+		Repo:     repo,
+		Index:    repo.NextIssueIndex(),
+		Title:    title,
+		PosterID: pusher.ID,
+		Poster:   pusher,
+		IsPull:   true,
+		Content:  description,
+	}
+	pr = &PullRequest{
+		HeadRepoID:   repo.ID,
+		BaseRepoID:   repo.ID,
+		HeadUserName: owner.Name,
+		HeadBranch:   headBranch,
+		BaseBranch:   baseBranch,
+		HeadRepo:     repo,
+		BaseRepo:     repo,
+		MergeBase:    mergeBase,
+		Type:         PULL_REQUEST_AGIT,
+	}
+	if err = NewPullRequest(repo, pull, nil, nil, pr, patch); err != nil {
+		return nil, false, fmt.Errorf("NewPullRequest: %v", err)
+	} else if err = pr.updateHeadRef(opts.NewCommitID); err != nil {
+		return nil, false, fmt.Errorf("updateHeadRef: %v", err)
+	}
+	return pr, true, nil
+}
@@ -0,0 +1,39 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_ParseAgitRef(t *testing.T) {
+	Convey("Parse references of AGit flow", t, func() {
+		testCases := []struct {
+			refName    string
+			options    []string
+			baseBranch string
+			topic      string
+			ok         bool
+		}{
+			{"refs/for/master/fix-typo", nil, "master", "fix-typo", true},
+			{"refs/for/release/v1.0/fix-typo", nil, "release/v1.0", "fix-typo", true},
+			{"refs/for/release/v1.0", []string{"topic=fix/typo"}, "release/v1.0", "fix/typo", true},
+			{"refs/for/master", nil, "", "", false},
+			{"refs/for/master", []string{"topic"}, "master", "", false},
+			{"refs/for/master", []string{"topic=../typo"}, "master", "../typo", false},
+			{"refs/for/master", []string{"topic=fix typo"}, "master", "fix typo", false},
+			{"refs/for/master", []string{"topic=typo.lock"}, "master", "typo.lock", false},
+			{"refs/heads/master/fix-typo", nil, "", "", false},
+		}
+		for _, tc := range testCases {
+			baseBranch, topic, ok := ParseAgitRef(tc.refName, ParsePushOptions(tc.options))
+			So(baseBranch, ShouldEqual, tc.baseBranch)
+			So(topic, ShouldEqual, tc.topic)
+			So(ok, ShouldEqual, tc.ok)
+		}
+	})
+}
@@ -0,0 +1,193 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/unknwon/com"
+
+	"github.com/gogs/git-module"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/db/errors"
+	"gogs.io/gogs/internal/process"
+)
+
+// ConflictedFile is a file that has conflicts when merging the base branch into
+// the head branch of a pull request.
+type ConflictedFile struct {
+	Name    string
+	Content string // Content with conflict markers, only available when IsText is true.
+	IsText  bool   // Whether both sides changed the content of a text file.
+}
+
+// parseUnmergedStages returns stages of unmerged files from the output of "git ls-files -u -z".
+// Stage 1 is the common ancestor, stage 2 is "ours" and stage 3 is "theirs".
+func parseUnmergedStages(stdout string) map[string][]int64 {
+	stages := make(map[string][]int64)
+	for _, entry := range strings.Split(stdout, "\x00") {
+		// e.g. "100644 3b18e512dba79e4c8300dd08aeb37f8e728b8dad 1\tREADME.md"
+		i := strings.IndexByte(entry, '\t')
+		if i <= 0 {
+			continue
+		}
+		fields := strings.Fields(entry[:i])
+		if len(fields) != 3 {
+			continue
+		}
+		name := entry[i+1:]
+		stages[name] = append(stages[name], com.StrTo(fields[2]).MustInt64())
+	}
+	return stages
+}
+
+// hasConflictMarkers returns true if any line of the content is a conflict marker.
+func hasConflictMarkers(content string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(line, "<<<<<<< ") ||
+			strings.HasPrefix(line, ">>>>>>> ") ||
+			strings.TrimRight(line, "\r") == "=======" {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeBaseIntoHead clones the head branch to given path and merges the base branch into it
+// without committing, then returns the conflicted files sorted by name.
+func (pr *PullRequest) mergeBaseIntoHead(tmpPath string) ([]*ConflictedFile, error) {
+	if err := pr.LoadAttributes(); err != nil {
+		return nil, fmt.Errorf("LoadAttributes: %v", err)
+	} else if pr.HeadRepo == nil {
+		return nil, fmt.Errorf("head repository does not exist")
+	}
+
+	var stderr string
+	var err error
+	if _, stderr, err = process.ExecTimeout(5*time.Minute,
+		fmt.Sprintf("PullRequest.mergeBaseIntoHead (git clone): %s", tmpPath),
+		"git", "clone", "-b", pr.HeadBranch, pr.HeadRepo.RepoPath(), tmpPath); err != nil {
+		return nil, fmt.Errorf("git clone: %s", stderr)
+	}
+
+	if _, stderr, err = process.ExecDir(-1, tmpPath,
+		fmt.Sprintf("PullRequest.mergeBaseIntoHead (git fetch): %s", tmpPath),
+		"git", "fetch", pr.BaseRepo.RepoPath(), pr.BaseBranch); err != nil {
+		return nil, fmt.Errorf("git fetch: %s", stderr)
+	}
+
+	// A failed merge is expected when there are conflicts, which are listed below.
+	process.ExecDir(-1, tmpPath,
+		fmt.Sprintf("PullRequest.mergeBaseIntoHead (git merge): %s", tmpPath),
+		"git", "merge", "--no-ff", "--no-commit", "FETCH_HEAD")
+
+	stdout, stderr, err := process.ExecDir(-1, tmpPath,
+		fmt.Sprintf("PullRequest.mergeBaseIntoHead (git ls-files): %s", tmpPath),
+		"git", "ls-files", "-u", "-z")
+	if err != nil {
+		return nil, fmt.Errorf("git ls-files: %s", stderr)
+	}
+
+	stages := parseUnmergedStages(stdout)
+	files := make([]*ConflictedFile, 0, len(stages))
+	for name, ss := range stages {
+		f := &ConflictedFile{Name: name}
+		files = append(files, f)
+
+		// Files deleted on either side or added with different modes cannot be resolved by editing the content.
+		if !com.IsSliceContainsInt64(ss, 2) || !com.IsSliceContainsInt64(ss, 3) {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(tmpPath, name))
+		if err != nil || bytes.IndexByte(data, 0) > -1 {
+			continue
+		}
+		f.Content = string(data)
+		f.IsText = true
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+	return files, nil
+}
+
+// GetConflictedFiles returns files that have conflicts when merging the base branch into
+// the head branch of the pull request.
+func (pr *PullRequest) GetConflictedFiles() ([]*ConflictedFile, error) {
+	tmpPath := filepath.Join(conf.Server.AppDataPath, "tmp", "conflicts", com.ToStr(time.Now().UnixNano()))
+	os.MkdirAll(filepath.Dir(tmpPath), os.ModePerm)
+	defer os.RemoveAll(tmpPath)
+
+	return pr.mergeBaseIntoHead(tmpPath)
+}
+
+// ResolveConflicts merges the base branch into the head branch of the pull request with given
+// contents of conflicted files, and pushes the merge commit to the head branch on behalf of doer.
+func (pr *PullRequest) ResolveConflicts(doer *User, contents map[string]string, message string) error {
+	tmpPath := filepath.Join(conf.Server.AppDataPath, "tmp", "conflicts", com.ToStr(time.Now().UnixNano()))
+	os.MkdirAll(filepath.Dir(tmpPath), os.ModePerm)
+	defer os.RemoveAll(tmpPath)
+
+	files, err := pr.mergeBaseIntoHead(tmpPath)
+	if err != nil {
+		return err
+	} else if len(files) == 0 {
+		return errors.PullRequestNotConflicted{ID: pr.ID}
+	}
+
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		if !f.IsText {
+			return errors.ConflictNotResolvable{Name: f.Name}
+		}
+
+		content, ok := contents[f.Name]
+		if !ok || hasConflictMarkers(content) {
+			return errors.ConflictNotResolved{Name: f.Name}
+		}
+
+		// Browsers submit line breaks as CRLF, keep line endings of the original file.
+		content = strings.Replace(content, "\r\n", "\n", -1)
+		if strings.Contains(f.Content, "\r\n") {
+			content = strings.Replace(content, "\n", "\r\n", -1)
+		}
+		if err = ioutil.WriteFile(path.Join(tmpPath, f.Name), []byte(content), 0666); err != nil {
+			return fmt.Errorf("write file: %v", err)
+		}
+		names = append(names, f.Name)
+	}
+
+	if err = git.AddChanges(tmpPath, false, append([]string{"--"}, names...)...); err != nil {
+		return fmt.Errorf("git add: %v", err)
+	} else if err = commitChanges(tmpPath, git.CommitChangesOptions{
+		Committer: doer.NewGitSig(),
+		Message:   message,
+	}, signOnMerge); err != nil {
+		return fmt.Errorf("commit changes on %q: %v", tmpPath, err)
+	}
+
+	headOwner := pr.HeadRepo.MustOwner()
+	if err = git.PushWithEnvs(tmpPath, "origin", pr.HeadBranch,
+		ComposeHookEnvs(ComposeHookEnvsOptions{
+			AuthUser:  doer,
+			OwnerName: headOwner.Name,
+			OwnerSalt: headOwner.Salt,
+			RepoID:    pr.HeadRepo.ID,
+			RepoName:  pr.HeadRepo.Name,
+			RepoPath:  pr.HeadRepo.RepoPath(),
+		})); err != nil {
+		return fmt.Errorf("git push origin %s: %v", pr.HeadBranch, err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_parseConflictedFiles(t *testing.T) {
+	Convey("Parse conflicted files from output of git apply", t, func() {
+		stderr := `error: patch failed: README.md:1
+error: README.md: patch does not apply
+error: patch failed: src/main.go:10
+error: src/main.go: patch does not apply
+error: docs/new.md: already exists in working directory
+`
+		So(parseConflictedFiles(stderr), ShouldResemble, []string{"README.md", "src/main.go", "docs/new.md"})
+		So(parseConflictedFiles(""), ShouldResemble, []string{})
+	})
+}
+
+func Test_parseUnmergedStages(t *testing.T) {
+	Convey("Parse stages of unmerged files", t, func() {
+		stdout := "100644 3b18e512dba79e4c8300dd08aeb37f8e728b8dad 1\tREADME.md\x00" +
+			"100644 9daeafb9864cf43055ae93beb0afd6c7d144bfa4 2\tREADME.md\x00" +
+			"100644 0f7bc766052a5a0ee28a393d51d2370f96d8ceb8 3\tREADME.md\x00" +
+			"100644 78981922613b2afb6025042ff6bd878ac1994e85 1\tdeleted file.txt\x00" +
+			"100644 c3219ebbfa21b48e6709a82743eb1c6713d42b73 3\tdeleted file.txt\x00"
+		So(parseUnmergedStages(stdout), ShouldResemble, map[string][]int64{
+			"README.md":        {1, 2, 3},
+			"deleted file.txt": {1, 3},
+		})
+		So(parseUnmergedStages(""), ShouldBeEmpty)
+	})
+}
+
+func Test_hasConflictMarkers(t *testing.T) {
+	Convey("Check conflict markers in content", t, func() {
+		So(hasConflictMarkers("a\n<<<<<<< HEAD\nb\n=======\nc\n>>>>>>> FETCH_HEAD\n"), ShouldBeTrue)
+		So(hasConflictMarkers("a\r\n=======\r\n"), ShouldBeTrue)
+		So(hasConflictMarkers("a\nb\n"), ShouldBeFalse)
+		So(hasConflictMarkers("Title\n========\n"), ShouldBeFalse)
+	})
+}
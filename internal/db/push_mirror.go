@@ -0,0 +1,215 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/unknwon/com"
+	"gopkg.in/ini.v1"
+	log "unknwon.dev/clog/v2"
+	"xorm.io/xorm"
+
+	"github.com/gogs/git-module"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/db/errors"
+	"gogs.io/gogs/internal/process"
+	"gogs.io/gogs/internal/sync"
+)
+
+var PushMirrorQueue = sync.NewUniqueQueue(1000)
+
+// PushMirror represents a remote that branches and tags of a repository are pushed to.
+// The remote address with credentials is stored in Git repository config.
+type PushMirror struct {
+	ID         int64
+	RepoID     int64 `xorm:"INDEX"`
+	Interval   int   // Hour, only syncs on demand or on push when it is zero.
+	SyncOnPush bool  `xorm:"NOT NULL DEFAULT false"`
+
+	Created      time.Time `xorm:"-" json:"-"`
+	CreatedUnix  int64
+	LastSync     time.Time `xorm:"-" json:"-"`
+	LastSyncUnix int64
+	NextSyncUnix int64 `xorm:"INDEX"`
+	// Error of the last sync, it is empty when the last sync succeeded.
+	LastError string `xorm:"TEXT"`
+
+	address string `xorm:"-" json:"-"`
+}
+
+func (m *PushMirror) BeforeInsert() {
+	m.CreatedUnix = time.Now().Unix()
+}
+
+func (m *PushMirror) AfterSet(colName string, _ xorm.Cell) {
+	switch colName {
+	case "created_unix":
+		m.Created = time.Unix(m.CreatedUnix, 0).Local()
+	case "last_sync_unix":
+		m.LastSync = time.Unix(m.LastSyncUnix, 0).Local()
+	}
+}
+
+// RemoteName returns the name of remote in Git repository config.
+func (m *PushMirror) RemoteName() string {
+	return fmt.Sprintf("push_mirror_%d", m.ID)
+}
+
+// HasSynced returns true if the push mirror has been synced at least once.
+func (m *PushMirror) HasSynced() bool {
+	return m.LastSyncUnix > 0
+}
+
+func (m *PushMirror) readAddress(repo *Repository) {
+	if len(m.address) > 0 {
+		return
+	}
+
+	cfg, err := ini.Load(repo.GitConfigPath())
+	if err != nil {
+		log.Error("Load: %v", err)
+		return
+	}
+	m.address = cfg.Section(fmt.Sprintf("remote %q", m.RemoteName())).Key("url").Value()
+}
+
+// MosaicsAddress returns push mirror address from Git repository config with credentials under mosaics.
+func (m *PushMirror) MosaicsAddress(repo *Repository) string {
+	m.readAddress(repo)
+	return HandleMirrorCredentials(m.address, true)
+}
+
+// scheduleNextSync sets next sync time based on the interval, it is zero when
+// the push mirror does not sync periodically.
+func (m *PushMirror) scheduleNextSync() {
+	if m.Interval > 0 {
+		m.NextSyncUnix = time.Now().Add(time.Duration(m.Interval) * time.Hour).Unix()
+	} else {
+		m.NextSyncUnix = 0
+	}
+}
+
+// CreatePushMirror creates a new push mirror of the repository to given address,
+// which includes necessary credentials.
+func CreatePushMirror(repo *Repository, addr string, interval int, syncOnPush bool) (*PushMirror, error) {
+	m := &PushMirror{
+		RepoID:     repo.ID,
+		Interval:   interval,
+		SyncOnPush: syncOnPush,
+	}
+	m.scheduleNextSync()
+
+	sess := x.NewSession()
+	defer sess.Close()
+	if err := sess.Begin(); err != nil {
+		return nil, err
+	}
+
+	if _, err := sess.Insert(m); err != nil {
+		return nil, err
+	}
+	if err := git.AddRemote(repo.RepoPath(), m.RemoteName(), addr, git.AddRemoteOptions{}); err != nil {
+		return nil, fmt.Errorf("add remote %q: %v", m.RemoteName(), err)
+	}
+
+	return m, sess.Commit()
+}
+
+// GetPushMirrorsByRepoID returns all push mirrors of the repository.
+func GetPushMirrorsByRepoID(repoID int64) ([]*PushMirror, error) {
+	mirrors := make([]*PushMirror, 0, 2)
+	return mirrors, x.Where("repo_id = ?", repoID).Asc("id").Find(&mirrors)
+}
+
+// GetPushMirrorOfRepoByID returns the push mirror of the repository with given ID.
+func GetPushMirrorOfRepoByID(repoID, id int64) (*PushMirror, error) {
+	m := new(PushMirror)
+	has, err := x.Where("id = ? AND repo_id = ?", id, repoID).Get(m)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		return nil, errors.PushMirrorNotExist{ID: id, RepoID: repoID}
+	}
+	return m, nil
+}
+
+// DeletePushMirror deletes the push mirror and its remote from Git repository config.
+func DeletePushMirror(repo *Repository, m *PushMirror) error {
+	if err := git.RemoveRemote(repo.RepoPath(), m.RemoteName()); err != nil {
+		return fmt.Errorf("remove remote %q: %v", m.RemoteName(), err)
+	}
+	_, err := x.ID(m.ID).Delete(new(PushMirror))
+	return err
+}
+
+// runSync pushes all branches and tags to the remote, and deletes ones that no longer
+// exist in the repository.
+func (m *PushMirror) runSync(repo *Repository) error {
+	repoPath := repo.RepoPath()
+	timeout := time.Duration(conf.Git.Timeout.Mirror) * time.Second
+	_, stderr, err := process.ExecDir(
+		timeout, repoPath, fmt.Sprintf("PushMirror.runSync: %s", repoPath),
+		"git", "push", "--prune", m.RemoteName(), "+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*")
+	if err != nil {
+		// Credentials must not be exposed in error messages.
+		m.readAddress(repo)
+		if m.address != "" {
+			stderr = strings.Replace(stderr, m.address, m.MosaicsAddress(repo), -1)
+		}
+		return fmt.Errorf("%v - %s", err, stderr)
+	}
+	return nil
+}
+
+// AddPushMirrorSyncTask adds push mirrors of the repository that sync on push to the queue.
+func AddPushMirrorSyncTask(repoID int64) {
+	mirrors := make([]*PushMirror, 0, 2)
+	if err := x.Where("repo_id = ? AND sync_on_push = ?", repoID, true).Find(&mirrors); err != nil {
+		log.Error("Find push mirrors [repo_id: %d]: %v", repoID, err)
+		return
+	}
+	for _, m := range mirrors {
+		PushMirrorQueue.Add(m.ID)
+	}
+}
+
+// SyncPushMirrors checks and syncs push mirrors.
+func SyncPushMirrors() {
+	// Start listening on new sync requests.
+	for id := range PushMirrorQueue.Queue() {
+		log.Trace("SyncPushMirrors [id: %s]", id)
+		PushMirrorQueue.Remove(id)
+
+		m := new(PushMirror)
+		has, err := x.ID(com.StrTo(id).MustInt64()).Get(m)
+		if err != nil {
+			log.Error("Get push mirror [%s]: %v", id, err)
+			continue
+		} else if !has {
+			continue
+		}
+
+		repo, err := GetRepositoryByID(m.RepoID)
+		if err != nil {
+			log.Error("GetRepositoryByID [%d]: %v", m.RepoID, err)
+			continue
+		}
+
+		m.LastError = ""
+		if err = m.runSync(repo); err != nil {
+			m.LastError = err.Error()
+			log.Error("Failed to sync push mirror %d of repository '%s': %v", m.ID, repo.FullName(), err)
+		}
+		m.LastSyncUnix = time.Now().Unix()
+		m.scheduleNextSync()
+		if _, err = x.ID(m.ID).Cols("last_sync_unix", "next_sync_unix", "last_error").Update(m); err != nil {
+			log.Error("Update push mirror [%d]: %v", m.ID, err)
+		}
+	}
+}
@@ -69,8 +69,8 @@ func (r *Release) loadAttributes(e Engine) (err error) {
 		r.Publisher, err = getUserByID(e, r.PublisherID)
 		if err != nil {
 			if errors.IsUserNotExist(err) {
+				r.Publisher = getGhostUser(e, r.PublisherID)
 				r.PublisherID = -1
-				r.Publisher = NewGhostUser()
 			} else {
 				return fmt.Errorf("getUserByID.(Publisher) [publisher_id: %d]: %v", r.PublisherID, err)
 			}
@@ -160,6 +160,23 @@ func (r *Release) preparePublishWebhooks() {
 	}
 }
 
+// newPublishAction adds new action for publishing the release.
+func (r *Release) newPublishAction() {
+	if err := NotifyWatchers(&Action{
+		ActUserID:    r.Publisher.ID,
+		ActUserName:  r.Publisher.Name,
+		OpType:       ACTION_PUBLISH_RELEASE,
+		Content:      r.Title,
+		RepoID:       r.Repo.ID,
+		RepoUserName: r.Repo.MustOwner().Name,
+		RepoName:     r.Repo.Name,
+		RefName:      r.TagName,
+		IsPrivate:    r.Repo.IsPrivate,
+	}); err != nil {
+		log.Error("NotifyWatchers: %v", err)
+	}
+}
+
 // NewRelease creates a new release with attachments for repository.
 func NewRelease(gitRepo *git.Repository, r *Release, uuids []string) error {
 	isExist, err := IsReleaseExist(r.RepoID, r.TagName)
@@ -169,6 +186,15 @@ func NewRelease(gitRepo *git.Repository, r *Release, uuids []string) error {
 		return ErrReleaseAlreadyExist{r.TagName}
 	}
 
+	if !gitRepo.IsTagExist(r.TagName) {
+		repo, err := GetRepositoryByID(r.RepoID)
+		if err != nil {
+			return fmt.Errorf("GetRepositoryByID: %v", err)
+		} else if err = repo.CheckTagName(r.TagName); err != nil {
+			return err
+		}
+	}
+
 	if err = createTag(gitRepo, r); err != nil {
 		return err
 	}
@@ -203,6 +229,7 @@ func NewRelease(gitRepo *git.Repository, r *Release, uuids []string) error {
 		return fmt.Errorf("GetReleaseByID: %v", err)
 	}
 	r.preparePublishWebhooks()
+	r.newPublishAction()
 	return nil
 }
 
@@ -317,6 +344,7 @@ func UpdateRelease(doer *User, gitRepo *git.Repository, r *Release, isPublish bo
 	}
 	r.Publisher = doer
 	r.preparePublishWebhooks()
+	r.newPublishAction()
 	return nil
 }
 
@@ -348,5 +376,8 @@ func DeleteReleaseOfRepoByID(repoID, id int64) error {
 		return fmt.Errorf("Delete: %v", err)
 	}
 
+	if _, err = DeleteAttachments(rel.Attachments, true); err != nil {
+		return fmt.Errorf("DeleteAttachments: %v", err)
+	}
 	return nil
 }
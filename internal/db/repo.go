@@ -36,6 +36,7 @@ import (
 	"gogs.io/gogs/internal/markup"
 	"gogs.io/gogs/internal/osutil"
 	"gogs.io/gogs/internal/process"
+	"gogs.io/gogs/internal/repoutil"
 	"gogs.io/gogs/internal/sync"
 )
 
@@ -157,9 +158,12 @@ type Repository struct {
 	Description     string `xorm:"VARCHAR(512)"`
 	Website         string
 	DefaultBranch   string
-	Size            int64 `xorm:"NOT NULL DEFAULT 0"`
+	Size            int64 `xorm:"INDEX NOT NULL DEFAULT 0"`
 	UseCustomAvatar bool
 
+	// Topics is only loaded by RepositoryList.LoadTopics.
+	Topics []string `xorm:"-" json:"-"`
+
 	// Counters
 	NumWatches          int
 	NumStars            int
@@ -175,18 +179,34 @@ type Repository struct {
 	NumOpenMilestones   int `xorm:"-" json:"-"`
 	NumTags             int `xorm:"-" json:"-"`
 
-	IsPrivate bool
-	IsBare    bool
+	IsPrivate bool `xorm:"INDEX"`
+	// Internal repository is visible to any signed-in user but hidden from anonymous
+	// visitors. It is always private as well.
+	IsInternal bool `xorm:"NOT NULL DEFAULT false"`
+	IsBare     bool
 
-	IsMirror bool
+	IsMirror bool `xorm:"INDEX"`
 	*Mirror  `xorm:"-" json:"-"`
 
+	// Archived repository is read-only but still browsable.
+	IsArchived bool `xorm:"NOT NULL DEFAULT false"`
+	// Asks search engines not to index pages and excludes from sitemap.
+	DisableIndexing bool `xorm:"NOT NULL DEFAULT false"`
+	// Template repository can be used to generate new repositories from its contents.
+	IsTemplate bool `xorm:"NOT NULL DEFAULT false"`
+	// Glob patterns, one per line, that names of new branches and tags must match.
+	BranchNamePatterns string `xorm:"TEXT"`
+	TagNamePatterns    string `xorm:"TEXT"`
+	// Rejects names of new branches and tags with unsafe characters.
+	RestrictRefNameChars bool `xorm:"NOT NULL DEFAULT false"`
+
 	// Advanced settings
 	EnableWiki            bool `xorm:"NOT NULL DEFAULT true"`
 	AllowPublicWiki       bool
 	EnableExternalWiki    bool
 	ExternalWikiURL       string
-	EnableIssues          bool `xorm:"NOT NULL DEFAULT true"`
+	WikiWriteAccess       AccessMode `xorm:"NOT NULL DEFAULT 0"` // Zero value means write access
+	EnableIssues          bool       `xorm:"NOT NULL DEFAULT true"`
 	AllowPublicIssues     bool
 	EnableExternalTracker bool
 	ExternalTrackerURL    string
@@ -196,15 +216,17 @@ type Repository struct {
 	EnablePulls           bool              `xorm:"NOT NULL DEFAULT true"`
 	PullsIgnoreWhitespace bool              `xorm:"NOT NULL DEFAULT false"`
 	PullsAllowRebase      bool              `xorm:"NOT NULL DEFAULT false"`
+	PullsRequireResolved  bool              `xorm:"NOT NULL DEFAULT false"`
+	PullsDeleteHeadBranch bool              `xorm:"NOT NULL DEFAULT false"`
 
-	IsFork   bool `xorm:"NOT NULL DEFAULT false"`
+	IsFork   bool `xorm:"INDEX NOT NULL DEFAULT false"`
 	ForkID   int64
 	BaseRepo *Repository `xorm:"-" json:"-"`
 
 	Created     time.Time `xorm:"-" json:"-"`
 	CreatedUnix int64
 	Updated     time.Time `xorm:"-" json:"-"`
-	UpdatedUnix int64
+	UpdatedUnix int64     `xorm:"INDEX"`
 }
 
 func (repo *Repository) BeforeInsert() {
@@ -272,10 +294,37 @@ func (repo *Repository) IsPartialPublic() bool {
 	return !repo.IsPrivate || repo.AllowPublicWiki || repo.AllowPublicIssues
 }
 
+// HasInternalWiki returns true if the builtin wiki of repository is enabled.
+func (repo *Repository) HasInternalWiki() bool {
+	return repo.EnableWiki && !repo.EnableExternalWiki
+}
+
 func (repo *Repository) CanGuestViewWiki() bool {
 	return repo.EnableWiki && !repo.EnableExternalWiki && repo.AllowPublicWiki
 }
 
+// WikiWriteAccessMode returns the minimum access mode to the repository that is
+// required to edit its wiki, read access means any signed-in user who can view
+// the wiki is allowed.
+func (repo *Repository) WikiWriteAccessMode() AccessMode {
+	if repo.WikiWriteAccess == ACCESS_MODE_NONE {
+		return ACCESS_MODE_WRITE
+	}
+	return repo.WikiWriteAccess
+}
+
+// CanAccessWiki returns true if a signed-in user with given access mode to the
+// repository has the requested access mode to its wiki.
+func (repo *Repository) CanAccessWiki(mode, requestMode AccessMode) bool {
+	if requestMode > ACCESS_MODE_READ {
+		requestMode = repo.WikiWriteAccessMode()
+	}
+	if requestMode == ACCESS_MODE_READ && repo.CanGuestViewWiki() {
+		return true
+	}
+	return mode >= requestMode
+}
+
 func (repo *Repository) CanGuestViewIssues() bool {
 	return repo.EnableIssues && !repo.EnableExternalTracker && repo.AllowPublicIssues
 }
@@ -395,6 +444,26 @@ func (repo *Repository) APIFormat(permission *api.Permission, user ...*User) *ap
 	return apiRepo
 }
 
+// APIRepository is the API representation of a repository which includes
+// attributes that are not available in the SDK.
+type APIRepository struct {
+	*api.Repository
+	Archived bool `json:"archived"`
+	Template bool `json:"template"`
+	Internal bool `json:"internal"`
+}
+
+// ExtendedAPIFormat returns the API format of the repository with attributes
+// that are not available in the SDK.
+func (repo *Repository) ExtendedAPIFormat(permission *api.Permission, user ...*User) *APIRepository {
+	return &APIRepository{
+		Repository: repo.APIFormat(permission, user...),
+		Archived:   repo.IsArchived,
+		Template:   repo.IsTemplate,
+		Internal:   repo.IsInternal,
+	}
+}
+
 func (repo *Repository) getOwner(e Engine) (err error) {
 	if repo.Owner != nil {
 		return nil
@@ -432,23 +501,28 @@ func (repo *Repository) UpdateSize() error {
 	return nil
 }
 
-// ComposeMetas composes a map of metas for rendering external issue tracker URL.
+// ComposeMetas composes a map of metas for rendering external issue tracker URL
+// and autolinks of the repository.
 func (repo *Repository) ComposeMetas() map[string]string {
-	if !repo.EnableExternalTracker {
+	if repo.ExternalMetas != nil {
+		return repo.ExternalMetas
+	} else if !repo.EnableExternalTracker && !markup.HasAutolinks(repo.ID) {
 		return nil
-	} else if repo.ExternalMetas == nil {
-		repo.ExternalMetas = map[string]string{
-			"format": repo.ExternalTrackerFormat,
-			"user":   repo.MustOwner().Name,
-			"repo":   repo.Name,
-		}
+	}
+
+	repo.ExternalMetas = map[string]string{
+		"repoID": com.ToStr(repo.ID),
+	}
+	if repo.EnableExternalTracker {
+		repo.ExternalMetas["format"] = repo.ExternalTrackerFormat
+		repo.ExternalMetas["user"] = repo.MustOwner().Name
+		repo.ExternalMetas["repo"] = repo.Name
 		switch repo.ExternalTrackerStyle {
 		case markup.ISSUE_NAME_STYLE_ALPHANUMERIC:
 			repo.ExternalMetas["style"] = markup.ISSUE_NAME_STYLE_ALPHANUMERIC
 		default:
 			repo.ExternalMetas["style"] = markup.ISSUE_NAME_STYLE_NUMERIC
 		}
-
 	}
 	return repo.ExternalMetas
 }
@@ -529,7 +603,8 @@ func (repo *Repository) GetMirror() (err error) {
 }
 
 func (repo *Repository) repoPath(e Engine) string {
-	return RepoPath(repo.mustOwner(e).Name, repo.Name)
+	owner := repo.mustOwner(e)
+	return repoutil.RepositoryPath(owner.StorageRoot, owner.Name, repo.Name)
 }
 
 func (repo *Repository) RepoPath() string {
@@ -582,7 +657,7 @@ func (repo *Repository) IsBranchRequirePullRequest(name string) bool {
 
 // CanEnableEditor returns true if repository meets the requirements of web editor.
 func (repo *Repository) CanEnableEditor() bool {
-	return !repo.IsMirror
+	return !repo.IsMirror && !repo.IsArchived
 }
 
 // FIXME: should have a mutex to prevent producing same index for two issues that are created
@@ -715,6 +790,8 @@ type MigrateRepoOptions struct {
 	IsPrivate   bool
 	IsMirror    bool
 	RemoteAddr  string
+	// Importing data through API of the service is skipped when Migration.Service is empty.
+	Migration MigrationOptions
 }
 
 /*
@@ -816,6 +893,12 @@ func MigrateRepository(doer, owner *User, opts MigrateRepoOptions) (*Repository,
 		}
 	}
 
+	if opts.Migration.Service != "" {
+		if err = newMigrationTask(doer, repo, opts.RemoteAddr, opts.Migration); err != nil {
+			return repo, fmt.Errorf("newMigrationTask: %v", err)
+		}
+	}
+
 	if opts.IsMirror {
 		if _, err = x.InsertOne(&Mirror{
 			RepoID:      repo.ID,
@@ -890,7 +973,7 @@ func CleanUpMigrateInfo(repo *Repository) (*Repository, error) {
 }
 
 // initRepoCommit temporarily changes with work directory.
-func initRepoCommit(tmpPath string, sig *git.Signature) (err error) {
+func initRepoCommit(tmpPath, branch string, sig *git.Signature) (err error) {
 	var stderr string
 	if _, stderr, err = process.ExecDir(-1,
 		tmpPath, fmt.Sprintf("initRepoCommit (git add): %s", tmpPath),
@@ -900,14 +983,14 @@ func initRepoCommit(tmpPath string, sig *git.Signature) (err error) {
 
 	if _, stderr, err = process.ExecDir(-1,
 		tmpPath, fmt.Sprintf("initRepoCommit (git commit): %s", tmpPath),
-		"git", "commit", fmt.Sprintf("--author='%s <%s>'", sig.Name, sig.Email),
-		"-m", "Initial commit"); err != nil {
+		"git", append(signingArgs(signOnInitial), "commit", fmt.Sprintf("--author='%s <%s>'", sig.Name, sig.Email),
+			"-m", "Initial commit")...); err != nil {
 		return fmt.Errorf("git commit: %s", stderr)
 	}
 
 	if _, stderr, err = process.ExecDir(-1,
 		tmpPath, fmt.Sprintf("initRepoCommit (git push): %s", tmpPath),
-		"git", "push", "origin", "master"); err != nil {
+		"git", "push", "origin", "HEAD:refs/heads/"+branch); err != nil {
 		return fmt.Errorf("git push: %s", stderr)
 	}
 	return nil
@@ -920,8 +1003,20 @@ type CreateRepoOptions struct {
 	License     string
 	Readme      string
 	IsPrivate   bool
+	IsInternal  bool // Internal repository is always private
 	IsMirror    bool
 	AutoInit    bool
+	Template    *Repository // Generates contents from the template repository when set.
+
+	// Name of the default branch, empty means "master".
+	DefaultBranch string
+}
+
+func (opts CreateRepoOptions) defaultBranch() string {
+	if opts.DefaultBranch == "" {
+		return "master"
+	}
+	return opts.DefaultBranch
 }
 
 func getRepoInitFile(tp, name string) ([]byte, error) {
@@ -1009,12 +1104,24 @@ func initRepository(e Engine, repoPath string, doer *User, repo *Repository, opt
 		return fmt.Errorf("InitRepository: %v", err)
 	} else if err = createDelegateHooks(repoPath); err != nil {
 		return fmt.Errorf("createDelegateHooks: %v", err)
+	} else if _, err = git.NewCommand("symbolic-ref", "HEAD", git.BranchPrefix+opts.defaultBranch()).RunInDir(repoPath); err != nil {
+		return fmt.Errorf("set HEAD: %v", err)
 	}
 
 	tmpDir := filepath.Join(os.TempDir(), "gogs-"+repo.Name+"-"+com.ToStr(time.Now().Nanosecond()))
 
 	// Initialize repository according to user's choice.
-	if opts.AutoInit {
+	if opts.Template != nil {
+		defer RemoveAllWithNotice("Delete repository for template generation", tmpDir)
+
+		if err = prepareTemplateCommit(repo, tmpDir, repoPath, opts.Template); err != nil {
+			return fmt.Errorf("prepareTemplateCommit: %v", err)
+		}
+
+		if err = initRepoCommit(tmpDir, opts.defaultBranch(), doer.NewGitSig()); err != nil {
+			return fmt.Errorf("initRepoCommit: %v", err)
+		}
+	} else if opts.AutoInit {
 		os.MkdirAll(tmpDir, os.ModePerm)
 		defer RemoveAllWithNotice("Delete repository for auto-initialization", tmpDir)
 
@@ -1023,7 +1130,7 @@ func initRepository(e Engine, repoPath string, doer *User, repo *Repository, opt
 		}
 
 		// Apply changes and commit.
-		if err = initRepoCommit(tmpDir, doer.NewGitSig()); err != nil {
+		if err = initRepoCommit(tmpDir, opts.defaultBranch(), doer.NewGitSig()); err != nil {
 			return fmt.Errorf("initRepoCommit: %v", err)
 		}
 	}
@@ -1034,11 +1141,11 @@ func initRepository(e Engine, repoPath string, doer *User, repo *Repository, opt
 		return fmt.Errorf("getRepositoryByID: %v", err)
 	}
 
-	if !opts.AutoInit {
+	if !opts.AutoInit && opts.Template == nil {
 		repo.IsBare = true
 	}
 
-	repo.DefaultBranch = "master"
+	repo.DefaultBranch = opts.defaultBranch()
 	if err = updateRepository(e, repo, false); err != nil {
 		return fmt.Errorf("updateRepository: %v", err)
 	}
@@ -1108,6 +1215,18 @@ func CreateRepository(doer, owner *User, opts CreateRepoOptions) (_ *Repository,
 	if !owner.CanCreateRepo() {
 		return nil, errors.ReachLimitOfRepo{owner.RepoCreationNum()}
 	}
+	if opts.AutoInit && opts.License != "" && !IsLicenseAllowed(owner, opts.License) {
+		return nil, errors.LicenseNotAllowed{Name: opts.License}
+	}
+
+	var defaults *OrgRepoDefaults
+	if owner.IsOrganization() {
+		defaults, err = GetOrgRepoDefaults(owner.ID)
+		if err != nil {
+			return nil, fmt.Errorf("GetOrgRepoDefaults: %v", err)
+		}
+		opts = defaults.applyOptions(opts)
+	}
 
 	repo := &Repository{
 		OwnerID:      owner.ID,
@@ -1115,7 +1234,8 @@ func CreateRepository(doer, owner *User, opts CreateRepoOptions) (_ *Repository,
 		Name:         opts.Name,
 		LowerName:    strings.ToLower(opts.Name),
 		Description:  opts.Description,
-		IsPrivate:    opts.IsPrivate,
+		IsPrivate:    opts.IsPrivate || opts.IsInternal,
+		IsInternal:   opts.IsInternal,
 		EnableWiki:   true,
 		EnableIssues: true,
 		EnablePulls:  true,
@@ -1147,7 +1267,18 @@ func CreateRepository(doer, owner *User, opts CreateRepoOptions) (_ *Repository,
 		}
 	}
 
-	return repo, sess.Commit()
+	if defaults != nil {
+		if err = defaults.applyRepository(sess, repo, opts); err != nil {
+			return nil, fmt.Errorf("apply organization defaults: %v", err)
+		}
+	}
+
+	if err = sess.Commit(); err != nil {
+		return nil, err
+	}
+
+	PrepareRepositoryWebhooks(doer, repo, HOOK_REPO_CREATED, nil)
+	return repo, nil
 }
 
 func countRepositories(userID int64, private bool) int64 {
@@ -1232,7 +1363,7 @@ func FilterRepositoryWithIssues(repoIDs []int64) ([]int64, error) {
 
 // RepoPath returns repository path by given user and repository name.
 func RepoPath(userName, repoName string) string {
-	return filepath.Join(UserPath(userName), strings.ToLower(repoName)+".git")
+	return repoutil.RepositoryPath(userStorageRoot(userName), userName, repoName)
 }
 
 // TransferOwnership transfers all corresponding setting from old user to new one.
@@ -1304,6 +1435,11 @@ func TransferOwnership(doer *User, newOwnerName string, repo *Repository) error
 		if err = owner.removeOrgRepo(sess, repo.ID); err != nil {
 			return fmt.Errorf("removeOrgRepo: %v", err)
 		}
+
+		// Issues of the repository can no longer be tracked by projects of the old owner.
+		if _, err = sess.Delete(&ProjectCard{RepoID: repo.ID}); err != nil {
+			return fmt.Errorf("delete project cards: %v", err)
+		}
 	}
 
 	if newOwner.IsOrganization() {
@@ -1334,18 +1470,21 @@ func TransferOwnership(doer *User, newOwnerName string, repo *Repository) error
 	}
 
 	// Rename remote repository to new path and delete local copy.
-	os.MkdirAll(UserPath(newOwner.Name), os.ModePerm)
-	if err = os.Rename(RepoPath(owner.Name, repo.Name), RepoPath(newOwner.Name, repo.Name)); err != nil {
+	// Owners may be pinned to different storage roots, so it could be a move across devices.
+	if err = moveDir(
+		repoutil.RepositoryPath(owner.StorageRoot, owner.Name, repo.Name),
+		repoutil.RepositoryPath(newOwner.StorageRoot, newOwner.Name, repo.Name),
+	); err != nil {
 		return fmt.Errorf("rename repository directory: %v", err)
 	}
 
 	deleteRepoLocalCopy(repo)
 
 	// Rename remote wiki repository to new path and delete local copy.
-	wikiPath := WikiPath(owner.Name, repo.Name)
+	wikiPath := repoutil.WikiPath(owner.StorageRoot, owner.Name, repo.Name)
 	if com.IsExist(wikiPath) {
 		RemoveAllWithNotice("Delete repository wiki local copy", repo.LocalWikiPath())
-		if err = os.Rename(wikiPath, WikiPath(newOwner.Name, repo.Name)); err != nil {
+		if err = moveDir(wikiPath, repoutil.WikiPath(newOwner.StorageRoot, newOwner.Name, repo.Name)); err != nil {
 			return fmt.Errorf("rename repository wiki: %v", err)
 		}
 	}
@@ -1451,13 +1590,15 @@ func updateRepository(e Engine, repo *Repository, visibilityChanged bool) (err e
 		}
 		for i := range forkRepos {
 			forkRepos[i].IsPrivate = repo.IsPrivate
+			forkRepos[i].IsInternal = repo.IsInternal
 			if err = updateRepository(e, forkRepos[i], true); err != nil {
 				return fmt.Errorf("updateRepository[%d]: %v", forkRepos[i].ID, err)
 			}
 		}
 
 		// Change visibility of generated actions
-		if _, err = e.Where("repo_id = ?", repo.ID).Cols("is_private").Update(&Action{IsPrivate: repo.IsPrivate}); err != nil {
+		// Actions of confidential issues stay private.
+		if _, err = e.Where("repo_id = ? AND is_confidential = ?", repo.ID, false).Cols("is_private").Update(&Action{IsPrivate: repo.IsPrivate}); err != nil {
 			return fmt.Errorf("change action visibility of repository: %v", err)
 		}
 	}
@@ -1479,6 +1620,66 @@ func UpdateRepository(repo *Repository, visibilityChanged bool) (err error) {
 	return sess.Commit()
 }
 
+// SetRepositoryArchived archives or unarchives the repository and sends
+// the repository webhook event.
+func SetRepositoryArchived(doer *User, repo *Repository, archived bool) (err error) {
+	if repo.IsArchived == archived {
+		return nil
+	}
+
+	repo.IsArchived = archived
+	if _, err = x.ID(repo.ID).Cols("is_archived").Update(repo); err != nil {
+		return fmt.Errorf("update: %v", err)
+	}
+
+	action := HOOK_REPO_ARCHIVED
+	if !archived {
+		action = HOOK_REPO_UNARCHIVED
+	}
+	PrepareRepositoryWebhooks(doer, repo, action, nil)
+	return nil
+}
+
+// PrepareRepositoryWebhooks sends the repository webhook event of given action done by
+// the doer, changes are only set for the renamed action.
+func PrepareRepositoryWebhooks(doer *User, repo *Repository, action HookRepoAction, changes *RepositoryChanges) {
+	if err := repo.GetOwner(); err != nil {
+		log.Error("GetOwner [repo_id: %d]: %v", repo.ID, err)
+		return
+	}
+	if err := PrepareWebhooks(repo, HOOK_EVENT_REPOSITORY, &RepositoryPayload{
+		Action:  action,
+		Changes: changes,
+		Repo:    repo.ExtendedAPIFormat(nil),
+		Sender:  doer.APIFormat(),
+	}); err != nil {
+		log.Error("PrepareWebhooks [repo_id: %d]: %v", repo.ID, err)
+	}
+}
+
+// DeleteRepositoryByUser deletes the repository by the doer and sends the repository
+// webhook event. Webhooks of the repository are deleted along with it, so only
+// webhooks of the owner organization and system webhooks receive the event.
+func DeleteRepositoryByUser(doer *User, repo *Repository) error {
+	if err := repo.GetOwner(); err != nil {
+		return fmt.Errorf("GetOwner: %v", err)
+	}
+	p := &RepositoryPayload{
+		Action: HOOK_REPO_DELETED,
+		Repo:   repo.ExtendedAPIFormat(nil),
+		Sender: doer.APIFormat(),
+	}
+
+	if err := DeleteRepository(repo.OwnerID, repo.ID); err != nil {
+		return err
+	}
+
+	if err := PrepareWebhooks(repo, HOOK_EVENT_REPOSITORY, p); err != nil {
+		log.Error("PrepareWebhooks [repo_id: %d]: %v", repo.ID, err)
+	}
+	return nil
+}
+
 // DeleteRepository deletes a repository for a user or organization.
 func DeleteRepository(uid, repoID int64) error {
 	repo := &Repository{ID: repoID, OwnerID: uid}
@@ -1500,6 +1701,12 @@ func DeleteRepository(uid, repoID int64) error {
 		}
 	}
 
+	// Archive extracted for an unfinished import is deleted along with repository files.
+	migrationTask, err := GetMigrationTaskByRepoID(repoID)
+	if err != nil && !errors.IsMigrationTaskNotExist(err) {
+		return fmt.Errorf("GetMigrationTaskByRepoID: %v", err)
+	}
+
 	sess := x.NewSession()
 	defer sess.Close()
 	if err = sess.Begin(); err != nil {
@@ -1516,15 +1723,60 @@ func DeleteRepository(uid, repoID int64) error {
 		}
 	}
 
+	// Release attachments are found before releases are deleted.
+	releases := make([]*Release, 0, 5)
+	if err = sess.Cols("id").Where("repo_id = ?", repoID).Find(&releases); err != nil {
+		return fmt.Errorf("find releases: %v", err)
+	}
+	releaseIDs := make([]int64, len(releases))
+	for i := range releases {
+		releaseIDs[i] = releases[i].ID
+	}
+	deletedAttachments := make([]*Attachment, 0, len(releaseIDs))
+	if len(releaseIDs) > 0 {
+		if err = sess.In("release_id", releaseIDs).Find(&deletedAttachments); err != nil {
+			return fmt.Errorf("find release attachments: %v", err)
+		} else if _, err = sess.In("release_id", releaseIDs).Delete(new(Attachment)); err != nil {
+			return fmt.Errorf("delete release attachments: %v", err)
+		}
+	}
+
+	deletedLFSObjects := make([]*LFSObject, 0, 5)
+	if err = sess.Where("repo_id = ?", repoID).Find(&deletedLFSObjects); err != nil {
+		return fmt.Errorf("find LFS objects: %v", err)
+	}
+	deletedPackageBlobs, err := deleteRepoPackages(sess, repoID)
+	if err != nil {
+		return fmt.Errorf("deleteRepoPackages: %v", err)
+	}
+	if err = deleteRepoTopics(sess, repoID); err != nil {
+		return fmt.Errorf("deleteRepoTopics: %v", err)
+	}
+
 	if err = deleteBeans(sess,
 		&Repository{ID: repoID},
 		&Access{RepoID: repo.ID},
+		&AccessUnit{RepoID: repo.ID},
 		&Action{RepoID: repo.ID},
 		&Watch{RepoID: repoID},
 		&Star{RepoID: repoID},
+		&StarListRepo{RepoID: repoID},
+		&StarEvent{RepoID: repoID},
 		&Mirror{RepoID: repoID},
+		&PushMirror{RepoID: repoID},
+		&IssueMirror{RepoID: repoID},
+		&IssueMirrorIssue{RepoID: repoID},
+		&IssueMirrorComment{RepoID: repoID},
+		&Autolink{RepoID: repoID},
+		&MigrationTask{RepoID: repoID},
 		&IssueUser{RepoID: repoID},
 		&Milestone{RepoID: repoID},
+		&IssueHistory{RepoID: repoID},
+		&IssueEvent{RepoID: repoID},
+		&ReviewRequest{RepoID: repoID},
+		&Notification{RepoID: repoID},
+		&IssueWatch{RepoID: repoID},
+		&ProjectCard{RepoID: repoID},
 		&Release{RepoID: repoID},
 		&Collaboration{RepoID: repoID},
 		&PullRequest{BaseRepoID: repoID},
@@ -1532,13 +1784,25 @@ func DeleteRepository(uid, repoID int64) error {
 		&ProtectBranchWhitelist{RepoID: repoID},
 		&Webhook{RepoID: repoID},
 		&HookTask{RepoID: repoID},
+		&LFSObject{RepoID: repoID},
+		&LFSLock{RepoID: repoID},
+		&RepoGC{RepoID: repoID},
+		&MaintenanceJob{RepoID: repoID},
+		&RepoGraphStats{RepoID: repoID},
+		&StagedChange{RepoID: repoID},
+		&DeletedBranch{RepoID: repoID},
+		&CommitStatus{RepoID: repoID},
+		&RepoTrending{RepoID: repoID},
+		&LanguageStat{RepoID: repoID},
+		&RepoIndexerStatus{RepoID: repoID},
+		&FederationKey{ActorType: FEDERATION_ACTOR_REPO, ActorID: repoID},
+		&RemoteFollower{ActorType: FEDERATION_ACTOR_REPO, ActorID: repoID},
 	); err != nil {
 		return fmt.Errorf("deleteBeans: %v", err)
 	}
 
 	// Delete comments and attachments.
 	issues := make([]*Issue, 0, 25)
-	attachmentPaths := make([]string, 0, len(issues))
 	if err = sess.Where("repo_id=?", repoID).Find(&issues); err != nil {
 		return err
 	}
@@ -1546,14 +1810,18 @@ func DeleteRepository(uid, repoID int64) error {
 		if _, err = sess.Delete(&Comment{IssueID: issues[i].ID}); err != nil {
 			return err
 		}
+		if _, err = sess.Delete(&CommentRevision{IssueID: issues[i].ID}); err != nil {
+			return err
+		}
+		if _, err = sess.Delete(&IssueFormData{IssueID: issues[i].ID}); err != nil {
+			return err
+		}
 
 		attachments := make([]*Attachment, 0, 5)
 		if err = sess.Where("issue_id=?", issues[i].ID).Find(&attachments); err != nil {
 			return err
 		}
-		for j := range attachments {
-			attachmentPaths = append(attachmentPaths, attachments[j].LocalPath())
-		}
+		deletedAttachments = append(deletedAttachments, attachments...)
 
 		if _, err = sess.Delete(&Attachment{IssueID: issues[i].ID}); err != nil {
 			return err
@@ -1585,8 +1853,25 @@ func DeleteRepository(uid, repoID int64) error {
 	repo.DeleteWiki()
 
 	// Remove attachment files.
-	for i := range attachmentPaths {
-		RemoveAllWithNotice("Delete attachment", attachmentPaths[i])
+	for _, attach := range deletedAttachments {
+		if err = attach.removeFile(); err != nil {
+			desc := fmt.Sprintf("Delete attachment [uuid: %s]: %v", attach.UUID, err)
+			log.Warn(desc)
+			if err = CreateRepositoryNotice(desc); err != nil {
+				log.Error("CreateRepositoryNotice: %v", err)
+			}
+		}
+	}
+
+	removeLFSObjectFiles(deletedLFSObjects)
+	removePackageBlobFiles(deletedPackageBlobs)
+	RemoveAllWithNotice("Delete repository bundle", RepoBundlePath(repo.ID))
+	deleteRepoCodeIndex(repo.ID)
+	deleteRepoIssueIndex(repo.ID)
+	markup.SetAutolinks(repo.ID, nil)
+
+	if migrationTask != nil && migrationTask.Service == MIGRATION_SERVICE_ARCHIVE {
+		RemoveAllWithNotice("Delete extracted repository archive", migrationTask.APIURL)
 	}
 
 	if repo.NumForks > 0 {
@@ -1649,6 +1934,7 @@ func GetRepositoryByID(id int64) (*Repository, error) {
 type UserRepoOptions struct {
 	UserID   int64
 	Private  bool
+	Internal bool // Include internal repositories when private repositories are not included
 	Page     int
 	PageSize int
 }
@@ -1657,7 +1943,11 @@ type UserRepoOptions struct {
 func GetUserRepositories(opts *UserRepoOptions) ([]*Repository, error) {
 	sess := x.Where("owner_id=?", opts.UserID).Desc("updated_unix")
 	if !opts.Private {
-		sess.And("is_private=?", false)
+		if opts.Internal {
+			sess.And("is_private=? OR is_internal=?", false, true)
+		} else {
+			sess.And("is_private=?", false)
+		}
 	}
 
 	if opts.Page <= 0 {
@@ -1710,6 +2000,8 @@ func GetRepositoryCount(u *User) (int64, error) {
 
 type SearchRepoOptions struct {
 	Keyword  string
+	Topic    string // When set results will only contain repositories with the topic
+	Language string // When set results will only contain repositories with the primary language
 	OwnerID  int64
 	UserID   int64 // When set results will contain all public/private repositories user has access to
 	OrderBy  string
@@ -1731,7 +2023,7 @@ func SearchRepositoryByName(opts *SearchRepoOptions) (repos []*Repository, count
 	// this does not include other people's private repositories even if opts.UserID is an admin.
 	if !opts.Private && opts.UserID > 0 {
 		sess.Join("LEFT", "access", "access.repo_id = repo.id").
-			Where("repo.owner_id = ? OR access.user_id = ? OR repo.is_private = ? OR (repo.is_private = ? AND (repo.allow_public_wiki = ? OR repo.allow_public_issues = ?))", opts.UserID, opts.UserID, false, true, true, true)
+			Where("repo.owner_id = ? OR access.user_id = ? OR repo.is_private = ? OR repo.is_internal = ? OR (repo.is_private = ? AND (repo.allow_public_wiki = ? OR repo.allow_public_issues = ?))", opts.UserID, opts.UserID, false, true, true, true, true)
 	} else {
 		// Only return public repositories if opts.Private is not set
 		if !opts.Private {
@@ -1741,6 +2033,12 @@ func SearchRepositoryByName(opts *SearchRepoOptions) (repos []*Repository, count
 	if len(opts.Keyword) > 0 {
 		sess.And("repo.lower_name LIKE ? OR repo.description LIKE ?", "%"+strings.ToLower(opts.Keyword)+"%", "%"+strings.ToLower(opts.Keyword)+"%")
 	}
+	if len(opts.Topic) > 0 {
+		sess.And("repo.id IN (SELECT repo_topic.repo_id FROM repo_topic INNER JOIN topic ON topic.id = repo_topic.topic_id WHERE topic.name = ?)", strings.ToLower(opts.Topic))
+	}
+	if len(opts.Language) > 0 {
+		sess.And("repo.id IN (SELECT repo_id FROM repo_trending WHERE language = ?)", opts.Language)
+	}
 	if opts.OwnerID > 0 {
 		sess.And("repo.owner_id = ?", opts.OwnerID)
 	}
@@ -1757,6 +2055,130 @@ func SearchRepositoryByName(opts *SearchRepoOptions) (repos []*Repository, count
 	return repos, count, sess.Distinct("repo.*").Limit(opts.PageSize, (opts.Page-1)*opts.PageSize).Find(&repos)
 }
 
+// repoAccessCond returns the condition and its arguments of repositories that the
+// user has access to, zero user ID means an anonymous user.
+func repoAccessCond(userID int64) (string, []interface{}) {
+	if userID > 0 {
+		return "repository.is_private = ? OR repository.is_internal = ? OR repository.owner_id = ? OR repository.id IN (SELECT repo_id FROM access WHERE user_id = ?)", []interface{}{false, true, userID, userID}
+	}
+	return "repository.is_private = ?", []interface{}{false}
+}
+
+// accessibleRepoIDs returns IDs of all repositories that the user has access to.
+func accessibleRepoIDs(userID int64) ([]int64, error) {
+	cond, args := repoAccessCond(userID)
+	repoIDs := make([]int64, 0, 10)
+	return repoIDs, x.Table("repository").Where(cond, args...).Cols("repository.id").Find(&repoIDs)
+}
+
+// AdminSearchRepoOptions contains options for filtering repositories in the admin panel.
+type AdminSearchRepoOptions struct {
+	Keyword    string
+	OwnerID    int64
+	Visibility string // "public", "internal" or "private", empty means all
+	Type       string // "source", "fork", "mirror" or "archived", empty means all
+	MinSize    int64  // In bytes, zero means no lower bound
+	MaxSize    int64  // In bytes, zero means no upper bound
+	SortType   string
+	Page       int
+	PageSize   int
+}
+
+func (opts *AdminSearchRepoOptions) session() *xorm.Session {
+	sess := x.Where("1 = 1")
+	if len(opts.Keyword) > 0 {
+		sess.And("(lower_name LIKE ? OR description LIKE ?)", "%"+strings.ToLower(opts.Keyword)+"%", "%"+strings.ToLower(opts.Keyword)+"%")
+	}
+	if opts.OwnerID != 0 {
+		sess.And("owner_id = ?", opts.OwnerID)
+	}
+
+	switch opts.Visibility {
+	case "public":
+		sess.And("is_private = ?", false)
+	case "internal":
+		sess.And("is_internal = ?", true)
+	case "private":
+		sess.And("is_private = ?", true).And("is_internal = ?", false)
+	}
+
+	switch opts.Type {
+	case "source":
+		sess.And("is_fork = ?", false).And("is_mirror = ?", false)
+	case "fork":
+		sess.And("is_fork = ?", true)
+	case "mirror":
+		sess.And("is_mirror = ?", true)
+	case "archived":
+		sess.And("is_archived = ?", true)
+	}
+
+	if opts.MinSize > 0 {
+		sess.And("size >= ?", opts.MinSize)
+	}
+	if opts.MaxSize > 0 {
+		sess.And("size <= ?", opts.MaxSize)
+	}
+	return sess
+}
+
+// adminRepoOrderBy maps sort types of the admin panel to corresponding SQL clauses.
+var adminRepoOrderBy = map[string]string{
+	"oldest":       "id ASC",
+	"newest":       "id DESC",
+	"recentupdate": "updated_unix DESC",
+	"leastupdate":  "updated_unix ASC",
+	"largest":      "size DESC",
+	"smallest":     "size ASC",
+}
+
+// AdminSearchRepositories returns repositories match given options in the range of page,
+// and number of total results.
+func AdminSearchRepositories(opts *AdminSearchRepoOptions) ([]*Repository, int64, error) {
+	if opts.Page <= 0 {
+		opts.Page = 1
+	}
+
+	count, err := opts.session().Count(new(Repository))
+	if err != nil {
+		return nil, 0, fmt.Errorf("count: %v", err)
+	}
+
+	orderBy, ok := adminRepoOrderBy[opts.SortType]
+	if !ok {
+		orderBy = adminRepoOrderBy["oldest"]
+	}
+
+	repos := make([]*Repository, 0, opts.PageSize)
+	return repos, count, opts.session().OrderBy(orderBy).Limit(opts.PageSize, (opts.Page-1)*opts.PageSize).Find(&repos)
+}
+
+// IterateAdminSearchRepositories calls fn with batches of repositories match given options
+// in the order of ID, pagination and sort options are ignored. Attributes of each batch
+// are loaded before calling fn.
+func IterateAdminSearchRepositories(opts *AdminSearchRepoOptions, batchSize int, fn func([]*Repository) error) error {
+	var lastID int64
+	for {
+		repos := make([]*Repository, 0, batchSize)
+		if err := opts.session().And("id > ?", lastID).Asc("id").Limit(batchSize).Find(&repos); err != nil {
+			return fmt.Errorf("find: %v", err)
+		} else if len(repos) == 0 {
+			return nil
+		}
+
+		if err := RepositoryList(repos).LoadAttributes(); err != nil {
+			return fmt.Errorf("LoadAttributes: %v", err)
+		} else if err = fn(repos); err != nil {
+			return err
+		}
+
+		if len(repos) < batchSize {
+			return nil
+		}
+		lastID = repos[len(repos)-1].ID
+	}
+}
+
 func DeleteOldRepositoryArchives() {
 	if taskStatusTable.IsRunning(_CLEAN_OLD_ARCHIVES) {
 		return
@@ -1910,10 +2332,20 @@ func SyncRepositoryHooks() error {
 var taskStatusTable = sync.NewStatusTable()
 
 const (
-	_MIRROR_UPDATE      = "mirror_update"
-	_GIT_FSCK           = "git_fsck"
-	_CHECK_REPO_STATS   = "check_repos_stats"
-	_CLEAN_OLD_ARCHIVES = "clean_old_archives"
+	_MIRROR_UPDATE             = "mirror_update"
+	_GIT_FSCK                  = "git_fsck"
+	_CHECK_REPO_STATS          = "check_repos_stats"
+	_CLEAN_OLD_ARCHIVES        = "clean_old_archives"
+	_PRUNE_TABLES              = "prune_tables"
+	_REVIEW_REMINDERS          = "review_reminders"
+	_CHECK_PULL_MERGEABILITY   = "check_pull_mergeability"
+	_SEND_DIGESTS              = "send_digests"
+	_SEND_NOTIFICATION_DIGESTS = "send_notification_digests"
+	_REPO_GC                   = "repo_gc"
+	_GENERATE_BUNDLES          = "generate_bundles"
+	_CHECK_LOGIN_SOURCES       = "check_login_sources"
+	_PURGE_DELETED_BRANCHES    = "purge_deleted_branches"
+	_UPDATE_TRENDING_REPOS     = "update_trending_repos"
 )
 
 // GitFsck calls 'git fsck' to check repository health.
@@ -1944,21 +2376,13 @@ func GitFsck() {
 }
 
 func GitGcRepos() error {
-	args := append([]string{"gc"}, conf.Git.GCArgs...)
 	return x.Where("id > 0").Iterate(new(Repository),
 		func(idx int, bean interface{}) error {
 			repo := bean.(*Repository)
 			if err := repo.GetOwner(); err != nil {
 				return err
 			}
-			_, stderr, err := process.ExecDir(
-				time.Duration(conf.Git.Timeout.GC)*time.Second,
-				RepoPath(repo.Owner.Name, repo.Name), "Repository garbage collection",
-				"git", args...)
-			if err != nil {
-				return fmt.Errorf("%v: %v", err, stderr)
-			}
-			return nil
+			return repo.RunGC()
 		})
 }
 
@@ -1995,8 +2419,8 @@ func CheckRepoStats() {
 	checkers := []*repoChecker{
 		// Repository.NumWatches
 		{
-			"SELECT repo.id FROM `repository` repo WHERE repo.num_watches!=(SELECT COUNT(*) FROM `watch` WHERE repo_id=repo.id)",
-			"UPDATE `repository` SET num_watches=(SELECT COUNT(*) FROM `watch` WHERE repo_id=?) WHERE id=?",
+			"SELECT repo.id FROM `repository` repo WHERE repo.num_watches!=(SELECT COUNT(*) FROM `watch` WHERE repo_id=repo.id AND mode=1)",
+			"UPDATE `repository` SET num_watches=(SELECT COUNT(*) FROM `watch` WHERE repo_id=? AND mode=1) WHERE id=?",
 			"repository count 'num_watches'",
 		},
 		// Repository.NumStars
@@ -2177,16 +2601,60 @@ func (repos MirrorRepositoryList) LoadAttributes() error {
 //   \__/\  /  (____  /__|  \___  >___|  /
 //        \/        \/          \/     \/
 
+// WatchMode is how a user is notified of activities of a repository.
+type WatchMode int
+
+const (
+	WATCH_MODE_ALL           WatchMode = iota + 1 // Notified of all activities.
+	WATCH_MODE_PARTICIPATING                      // Only notified of issues that the user participates in, which is the default.
+	WATCH_MODE_IGNORE                             // Never notified, even if participating or mentioned.
+)
+
+var watchModeNames = map[WatchMode]string{
+	WATCH_MODE_ALL:           "all",
+	WATCH_MODE_PARTICIPATING: "participating",
+	WATCH_MODE_IGNORE:        "ignore",
+}
+
+// Name returns the name of the watch mode, e.g. "all".
+func (mode WatchMode) Name() string {
+	return watchModeNames[mode]
+}
+
+// ParseWatchMode returns the watch mode of given name, or zero if invalid.
+func ParseWatchMode(name string) WatchMode {
+	for mode, n := range watchModeNames {
+		if n == name {
+			return mode
+		}
+	}
+	return 0
+}
+
 // Watch is connection request for receiving repository notification.
+// Users without a watch are in the participating mode.
 type Watch struct {
 	ID     int64
-	UserID int64 `xorm:"UNIQUE(watch)"`
-	RepoID int64 `xorm:"UNIQUE(watch)"`
+	UserID int64     `xorm:"UNIQUE(watch)"`
+	RepoID int64     `xorm:"UNIQUE(watch)"`
+	Mode   WatchMode `xorm:"NOT NULL DEFAULT 1"`
+}
+
+func getWatchMode(e Engine, userID, repoID int64) WatchMode {
+	w := &Watch{UserID: userID, RepoID: repoID}
+	if has, _ := e.Get(w); !has {
+		return WATCH_MODE_PARTICIPATING
+	}
+	return w.Mode
+}
+
+// GetWatchMode returns the watch mode of the user for given repository.
+func GetWatchMode(userID, repoID int64) WatchMode {
+	return getWatchMode(x, userID, repoID)
 }
 
 func isWatching(e Engine, userID, repoID int64) bool {
-	has, _ := e.Get(&Watch{0, userID, repoID})
-	return has
+	return getWatchMode(e, userID, repoID) == WATCH_MODE_ALL
 }
 
 // IsWatching checks if user has watched given repository.
@@ -2194,27 +2662,48 @@ func IsWatching(userID, repoID int64) bool {
 	return isWatching(x, userID, repoID)
 }
 
-func watchRepo(e Engine, userID, repoID int64, watch bool) (err error) {
-	if watch {
-		if isWatching(e, userID, repoID) {
-			return nil
-		}
-		if _, err = e.Insert(&Watch{RepoID: repoID, UserID: userID}); err != nil {
-			return err
-		}
+// setWatchMode changes the watch mode of the user for given repository, only users
+// watching all activities are counted as watchers.
+func setWatchMode(e Engine, userID, repoID int64, mode WatchMode) (err error) {
+	oldMode := getWatchMode(e, userID, repoID)
+	if oldMode == mode {
+		return nil
+	}
+
+	switch {
+	case mode == WATCH_MODE_PARTICIPATING:
+		_, err = e.Delete(&Watch{UserID: userID, RepoID: repoID})
+	case oldMode == WATCH_MODE_PARTICIPATING:
+		_, err = e.Insert(&Watch{UserID: userID, RepoID: repoID, Mode: mode})
+	default:
+		_, err = e.Where("user_id = ? AND repo_id = ?", userID, repoID).Cols("mode").Update(&Watch{Mode: mode})
+	}
+	if err != nil {
+		return err
+	}
+
+	if mode == WATCH_MODE_ALL {
 		_, err = e.Exec("UPDATE `repository` SET num_watches = num_watches + 1 WHERE id = ?", repoID)
-	} else {
-		if !isWatching(e, userID, repoID) {
-			return nil
-		}
-		if _, err = e.Delete(&Watch{0, userID, repoID}); err != nil {
-			return err
-		}
+	} else if oldMode == WATCH_MODE_ALL {
 		_, err = e.Exec("UPDATE `repository` SET num_watches = num_watches - 1 WHERE id = ?", repoID)
 	}
 	return err
 }
 
+// SetWatchMode changes the watch mode of the user for given repository.
+func SetWatchMode(userID, repoID int64, mode WatchMode) error {
+	return setWatchMode(x, userID, repoID, mode)
+}
+
+func watchRepo(e Engine, userID, repoID int64, watch bool) (err error) {
+	if watch {
+		return setWatchMode(e, userID, repoID, WATCH_MODE_ALL)
+	} else if !isWatching(e, userID, repoID) {
+		return nil
+	}
+	return setWatchMode(e, userID, repoID, WATCH_MODE_PARTICIPATING)
+}
+
 // Watch or unwatch repository.
 func WatchRepo(userID, repoID int64, watch bool) (err error) {
 	return watchRepo(x, userID, repoID, watch)
@@ -2222,7 +2711,7 @@ func WatchRepo(userID, repoID int64, watch bool) (err error) {
 
 func getWatchers(e Engine, repoID int64) ([]*Watch, error) {
 	watches := make([]*Watch, 0, 10)
-	return watches, e.Find(&watches, &Watch{RepoID: repoID})
+	return watches, e.Find(&watches, &Watch{RepoID: repoID, Mode: WATCH_MODE_ALL})
 }
 
 // GetWatchers returns all watchers of given repository.
@@ -2233,7 +2722,7 @@ func GetWatchers(repoID int64) ([]*Watch, error) {
 // Repository.GetWatchers returns range of users watching given repository.
 func (repo *Repository) GetWatchers(page int) ([]*User, error) {
 	users := make([]*User, 0, ItemsPerPage)
-	sess := x.Limit(ItemsPerPage, (page-1)*ItemsPerPage).Where("watch.repo_id=?", repo.ID)
+	sess := x.Limit(ItemsPerPage, (page-1)*ItemsPerPage).Where("watch.repo_id=? AND watch.mode=?", repo.ID, WATCH_MODE_ALL)
 	if conf.UsePostgreSQL {
 		sess = sess.Join("LEFT", "watch", `"user".id=watch.user_id`)
 	} else {
@@ -2249,6 +2738,15 @@ func notifyWatchers(e Engine, act *Action) error {
 		return fmt.Errorf("getWatchers: %v", err)
 	}
 
+	// Only watchers who can see the issue are notified of actions of confidential issues.
+	var repo *Repository
+	if act.IsConfidential {
+		repo, err = getRepositoryByID(e, act.RepoID)
+		if err != nil {
+			return fmt.Errorf("getRepositoryByID [%d]: %v", act.RepoID, err)
+		}
+	}
+
 	// Reset ID to reuse Action object
 	act.ID = 0
 
@@ -2257,11 +2755,22 @@ func notifyWatchers(e Engine, act *Action) error {
 	if _, err = e.Insert(act); err != nil {
 		return fmt.Errorf("insert new action: %v", err)
 	}
+	if conf.Federation.Enabled && !act.IsPrivate {
+		go FederationQueue.Add(act.ID)
+	}
 
 	for i := range watchers {
 		if act.ActUserID == watchers[i].UserID {
 			continue
 		}
+		if act.IsConfidential {
+			canSee, err := canSeeConfidentialIssues(e, watchers[i].UserID, repo)
+			if err != nil {
+				return fmt.Errorf("canSeeConfidentialIssues [user_id: %d]: %v", watchers[i].UserID, err)
+			} else if !canSee {
+				continue
+			}
+		}
 
 		act.ID = 0
 		act.UserID = watchers[i].UserID
@@ -2308,12 +2817,21 @@ func StarRepo(userID, repoID int64, star bool) (err error) {
 		}
 		if _, err = x.Delete(&Star{0, userID, repoID}); err != nil {
 			return err
+		} else if err = removeRepoFromStarLists(x, userID, repoID); err != nil {
+			return fmt.Errorf("removeRepoFromStarLists: %v", err)
 		} else if _, err = x.Exec("UPDATE `repository` SET num_stars = num_stars - 1 WHERE id = ?", repoID); err != nil {
 			return err
 		}
 		_, err = x.Exec("UPDATE `user` SET num_stars = num_stars - 1 WHERE id = ?", userID)
 	}
-	return err
+	if err != nil {
+		return err
+	}
+
+	if err = recordStarEvent(userID, repoID, star); err != nil {
+		log.Error("Failed to record star event [user_id: %d, repo_id: %d]: %v", userID, repoID, err)
+	}
+	return nil
 }
 
 // IsStaring checks if user has starred given repository.
@@ -2367,6 +2885,7 @@ func ForkRepository(doer, owner *User, baseRepo *Repository, name, desc string)
 		Description:   desc,
 		DefaultBranch: baseRepo.DefaultBranch,
 		IsPrivate:     baseRepo.IsPrivate,
+		IsInternal:    baseRepo.IsInternal,
 		IsFork:        true,
 		ForkID:        baseRepo.ID,
 	}
@@ -0,0 +1,62 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gogs/git-module"
+
+	"gogs.io/gogs/internal/conf"
+)
+
+const (
+	ARCHIVE_FORMAT_ZIP    = "zip"
+	ARCHIVE_FORMAT_TAR    = "tar"
+	ARCHIVE_FORMAT_TAR_GZ = "tar.gz"
+)
+
+// archiveFormats is the list of supported archive formats, longer extensions
+// are matched first.
+var archiveFormats = []string{ARCHIVE_FORMAT_TAR_GZ, ARCHIVE_FORMAT_TAR, ARCHIVE_FORMAT_ZIP}
+
+// ParseArchiveName splits an archive file name, e.g. "v1.0.tar.gz", into the
+// reference and the format. It returns false if the format is not supported.
+func ParseArchiveName(name string) (ref, format string, ok bool) {
+	for _, format := range archiveFormats {
+		ref = strings.TrimSuffix(name, "."+format)
+		if ref != name && ref != "" {
+			return ref, format, true
+		}
+	}
+	return "", "", false
+}
+
+type ArchiveOptions struct {
+	CommitID string
+	Format   string
+	// Path is the subdirectory to archive, the whole tree is archived when empty.
+	Path string
+}
+
+// WriteArchive streams an archive of the commit to w, files are put under the
+// directory named after the repository in the archive.
+func (repo *Repository) WriteArchive(w io.Writer, opts ArchiveOptions) error {
+	args := []string{"archive", "--format=" + opts.Format, "--prefix=" + repo.Name + "/", "--", opts.CommitID}
+	if opts.Path != "" {
+		args = append(args, opts.Path)
+	}
+
+	stderr := new(bytes.Buffer)
+	if err := git.NewCommand(args...).RunInDirTimeoutPipeline(
+		time.Duration(conf.Git.Timeout.Archive)*time.Second, repo.RepoPath(), w, stderr); err != nil {
+		return fmt.Errorf("%v: %s", err, stderr)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_ParseArchiveName(t *testing.T) {
+	Convey("Parse reference and format of archive names", t, func() {
+		testCases := []struct {
+			name   string
+			ref    string
+			format string
+			ok     bool
+		}{
+			{"master.zip", "master", ARCHIVE_FORMAT_ZIP, true},
+			{"v1.0.tar.gz", "v1.0", ARCHIVE_FORMAT_TAR_GZ, true},
+			{"feature/tar.tar", "feature/tar", ARCHIVE_FORMAT_TAR, true},
+			{"master.rar", "", "", false},
+			{".zip", "", "", false},
+			{"master", "", "", false},
+		}
+		for _, tc := range testCases {
+			ref, format, ok := ParseArchiveName(tc.name)
+			So(ref, ShouldEqual, tc.ref)
+			So(format, ShouldEqual, tc.format)
+			So(ok, ShouldEqual, tc.ok)
+		}
+	})
+}
@@ -0,0 +1,216 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gogs/git-module"
+	"github.com/unknwon/com"
+	log "unknwon.dev/clog/v2"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/db/errors"
+	"gogs.io/gogs/internal/osutil"
+	"gogs.io/gogs/internal/process"
+)
+
+// RepoBundlePath returns the path of pre-generated bundle of the repository, which
+// is named by ID to survive renaming and transferring.
+func RepoBundlePath(repoID int64) string {
+	return filepath.Join(conf.Git.BundlePath, com.ToStr(repoID)+".bundle")
+}
+
+// GenerateBundle generates a bundle of all refs of the repository, the existing
+// bundle is replaced only after the new one is completely written.
+func (repo *Repository) GenerateBundle() error {
+	bundlePath := RepoBundlePath(repo.ID)
+	if err := os.MkdirAll(filepath.Dir(bundlePath), os.ModePerm); err != nil {
+		return err
+	}
+
+	tmpPath := bundlePath + ".tmp"
+	defer os.Remove(tmpPath)
+
+	_, stderr, err := process.ExecDir(
+		time.Duration(conf.Git.Timeout.Bundle)*time.Second,
+		repo.RepoPath(), fmt.Sprintf("GenerateBundle: %s", repo.RepoPath()),
+		"git", "bundle", "create", tmpPath, "--all")
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, stderr)
+	}
+	return os.Rename(tmpPath, bundlePath)
+}
+
+// isBundleOutdated returns true if the bundle is missing or the repository has been
+// updated since the bundle was generated.
+func isBundleOutdated(repo *Repository) bool {
+	fi, err := os.Stat(RepoBundlePath(repo.ID))
+	if err != nil {
+		return true
+	}
+	return fi.ModTime().Unix() < repo.UpdatedUnix
+}
+
+// GenerateRepoBundles generates bundles for repositories that are large enough and
+// have been updated since their bundles were generated. Bundles of repositories that
+// become smaller than the threshold are removed.
+func GenerateRepoBundles() {
+	if taskStatusTable.IsRunning(_GENERATE_BUNDLES) {
+		return
+	}
+	taskStatusTable.Start(_GENERATE_BUNDLES)
+	defer taskStatusTable.Stop(_GENERATE_BUNDLES)
+
+	log.Trace("Doing: GenerateRepoBundles")
+
+	minSize := conf.Cron.GenerateBundles.MinRepoSize << 20
+	if err := x.Where("id > 0").Iterate(new(Repository),
+		func(idx int, bean interface{}) error {
+			repo := bean.(*Repository)
+			if repo.IsBare || repo.Size < minSize {
+				if bundlePath := RepoBundlePath(repo.ID); osutil.IsFile(bundlePath) {
+					RemoveAllWithNotice("Delete repository bundle", bundlePath)
+				}
+				return nil
+			} else if !isBundleOutdated(repo) {
+				return nil
+			}
+
+			if err := repo.GetOwner(); err != nil {
+				log.Error("GetOwner [repo_id: %d]: %v", repo.ID, err)
+				return nil
+			}
+			if err := repo.GenerateBundle(); err != nil {
+				desc := fmt.Sprintf("Failed to generate bundle of repository '%s': %v", repo.RepoPath(), err)
+				log.Warn(desc)
+				if err = CreateRepositoryNotice(desc); err != nil {
+					log.Error("CreateRepositoryNotice: %v", err)
+				}
+			}
+			return nil
+		}); err != nil {
+		log.Error("GenerateRepoBundles: %v", err)
+	}
+}
+
+// bundleLimiter limits the number of bundles being created at the same time and the
+// number of bundles created for each user or IP address in an hour.
+var bundleLimiter = struct {
+	sync.Mutex
+	running int
+	history map[string][]time.Time
+}{
+	history: make(map[string][]time.Time),
+}
+
+// AcquireBundle reserves a slot to create a bundle for given key, e.g. the user ID
+// or IP address. It returns errors.BundleLimitExceeded when too many bundles are being
+// created or have been created for the key in the last hour. The returned function
+// must be called to release the slot when the bundle is created.
+func AcquireBundle(key string) (release func(), err error) {
+	bundleLimiter.Lock()
+	defer bundleLimiter.Unlock()
+
+	maxConcurrent := conf.Repository.Bundle.MaxConcurrent
+	if maxConcurrent > 0 && bundleLimiter.running >= maxConcurrent {
+		return nil, errors.BundleLimitExceeded{Key: key}
+	}
+
+	now := time.Now()
+	for k, times := range bundleLimiter.history {
+		recent := times[:0]
+		for _, t := range times {
+			if now.Sub(t) < time.Hour {
+				recent = append(recent, t)
+			}
+		}
+		if len(recent) == 0 {
+			delete(bundleLimiter.history, k)
+		} else {
+			bundleLimiter.history[k] = recent
+		}
+	}
+
+	maxPerHour := conf.Repository.Bundle.MaxPerHour
+	if maxPerHour > 0 && len(bundleLimiter.history[key]) >= maxPerHour {
+		return nil, errors.BundleLimitExceeded{Key: key}
+	}
+	bundleLimiter.history[key] = append(bundleLimiter.history[key], now)
+	bundleLimiter.running++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			bundleLimiter.Lock()
+			bundleLimiter.running--
+			bundleLimiter.Unlock()
+		})
+	}, nil
+}
+
+// ResolveBundleRefs returns full names of given references of the repository, which
+// are either full names or names of branches or tags. It returns
+// errors.InvalidBundleRef if any reference does not exist.
+func (repo *Repository) ResolveBundleRefs(refs []string) ([]string, error) {
+	repoPath := repo.RepoPath()
+	fullNames := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		switch {
+		case strings.HasPrefix(ref, "refs/") && git.IsReferenceExist(repoPath, ref):
+			fullNames = append(fullNames, ref)
+		case git.IsBranchExist(repoPath, ref):
+			fullNames = append(fullNames, git.BRANCH_PREFIX+ref)
+		case git.IsTagExist(repoPath, ref):
+			fullNames = append(fullNames, git.TAG_PREFIX+ref)
+		default:
+			return nil, errors.InvalidBundleRef{Ref: ref}
+		}
+	}
+	return fullNames, nil
+}
+
+// WriteBundle streams a bundle of given full names of references of the repository
+// to w, or all references if none is given, and returns the SHA-256 checksum of the
+// bundle in hex. The pre-generated bundle is used when all references are requested
+// and it is up to date.
+func (repo *Repository) WriteBundle(w io.Writer, refs []string) (string, error) {
+	hash := sha256.New()
+	w = io.MultiWriter(w, hash)
+
+	args := []string{"bundle", "create", "-"}
+	if len(refs) == 0 {
+		if !isBundleOutdated(repo) {
+			f, err := os.Open(RepoBundlePath(repo.ID))
+			if err == nil {
+				defer f.Close()
+				if _, err = io.Copy(w, f); err != nil {
+					return "", err
+				}
+				return hex.EncodeToString(hash.Sum(nil)), nil
+			}
+			log.Warn("Failed to open bundle of repository %d: %v", repo.ID, err)
+		}
+		args = append(args, "--all")
+	} else {
+		args = append(args, refs...)
+	}
+
+	stderr := new(bytes.Buffer)
+	if err := git.NewCommand(args...).RunInDirTimeoutPipeline(
+		time.Duration(conf.Git.Timeout.Bundle)*time.Second, repo.RepoPath(), w, stderr); err != nil {
+		return "", fmt.Errorf("%v: %s", err, stderr)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
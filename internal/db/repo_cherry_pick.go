@@ -0,0 +1,128 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+
+	"github.com/unknwon/com"
+
+	"github.com/gogs/git-module"
+
+	"gogs.io/gogs/internal/db/errors"
+)
+
+// CanPushToBranch returns true if the user is allowed to push commits to the branch
+// directly according to branch protection.
+func (repo *Repository) CanPushToBranch(userID int64, branch string) bool {
+	protectBranch, err := GetProtectBranchOfRepoByName(repo.ID, branch)
+	if err != nil {
+		return errors.IsErrBranchNotExist(err)
+	} else if !protectBranch.Protected {
+		return true
+	}
+
+	if protectBranch.EnableWhitelist {
+		return IsUserInProtectBranchWhitelist(repo.ID, userID, branch)
+	}
+	return !protectBranch.RequirePullRequest
+}
+
+type CherryPickOptions struct {
+	CommitID  string
+	OldBranch string
+	NewBranch string
+	// Revert reverts changes of the commit instead of applying them.
+	Revert bool
+}
+
+// CherryPick applies or reverts changes of the commit on top of the old branch as a new
+// commit, and pushes the commit to the new branch. The original author is kept when
+// applying changes, and the doer is the committer.
+func (repo *Repository) CherryPick(doer *User, opts CherryPickOptions) (err error) {
+	gitRepo, err := git.OpenRepository(repo.RepoPath())
+	if err != nil {
+		return fmt.Errorf("open repository: %v", err)
+	}
+	commit, err := gitRepo.GetCommit(opts.CommitID)
+	if err != nil {
+		return fmt.Errorf("get commit: %v", err)
+	}
+	commitID := commit.ID.String()
+
+	repoWorkingPool.CheckIn(com.ToStr(repo.ID))
+	defer repoWorkingPool.CheckOut(com.ToStr(repo.ID))
+
+	if err = repo.DiscardLocalRepoBranchChanges(opts.OldBranch); err != nil {
+		return fmt.Errorf("discard local repo branch[%s] changes: %v", opts.OldBranch, err)
+	} else if err = repo.UpdateLocalCopyBranch(opts.OldBranch); err != nil {
+		return fmt.Errorf("update local copy branch[%s]: %v", opts.OldBranch, err)
+	}
+
+	repoPath := repo.RepoPath()
+	localPath := repo.LocalCopyPath()
+
+	if opts.OldBranch != opts.NewBranch {
+		// Directly return error if new branch already exists in the server
+		if git.IsBranchExist(repoPath, opts.NewBranch) {
+			return errors.BranchAlreadyExists{Name: opts.NewBranch}
+		}
+
+		// Otherwise, delete branch from local copy in case out of sync
+		if git.IsBranchExist(localPath, opts.NewBranch) {
+			if err = git.DeleteBranch(localPath, opts.NewBranch, git.DeleteBranchOptions{
+				Force: true,
+			}); err != nil {
+				return fmt.Errorf("delete branch[%s]: %v", opts.NewBranch, err)
+			}
+		}
+
+		if err = repo.CheckoutNewBranch(opts.OldBranch, opts.NewBranch); err != nil {
+			return fmt.Errorf("checkout new branch[%s] from old branch[%s]: %v", opts.NewBranch, opts.OldBranch, err)
+		}
+	}
+
+	// The commit may not be reachable from any branch fetched to the local copy.
+	if _, err = git.NewCommand("fetch", "origin", commitID).RunInDir(localPath); err != nil {
+		return fmt.Errorf("fetch commit %q: %v", commitID, err)
+	}
+
+	op := "cherry-pick"
+	args := []string{op, "-x"}
+	if opts.Revert {
+		op = "revert"
+		args = []string{op, "--no-edit"}
+	}
+	// Changes of merge commits are relative to the first parent.
+	if commit.ParentCount() > 1 {
+		args = append(args, "-m", "1")
+	}
+	args = append(args, commitID)
+
+	sig := doer.NewGitSig()
+	if _, err = git.NewCommand(append(signingArgs(signOnEditor), args...)...).AddEnvs(
+		"GIT_AUTHOR_NAME="+sig.Name,
+		"GIT_AUTHOR_EMAIL="+sig.Email,
+		"GIT_COMMITTER_NAME="+sig.Name,
+		"GIT_COMMITTER_EMAIL="+sig.Email,
+	).RunInDir(localPath); err != nil {
+		// Leave the local copy clean for next operations.
+		_, _ = git.NewCommand(op, "--abort").RunInDir(localPath)
+		return errors.CherryPickConflict{CommitID: commitID, Branch: opts.OldBranch, Revert: opts.Revert}
+	}
+
+	if err = git.PushWithEnvs(localPath, "origin", opts.NewBranch,
+		ComposeHookEnvs(ComposeHookEnvsOptions{
+			AuthUser:  doer,
+			OwnerName: repo.MustOwner().Name,
+			OwnerSalt: repo.MustOwner().Salt,
+			RepoID:    repo.ID,
+			RepoName:  repo.Name,
+			RepoPath:  repo.RepoPath(),
+		})); err != nil {
+		return fmt.Errorf("git push origin %s: %v", opts.NewBranch, err)
+	}
+	return nil
+}
@@ -17,15 +17,26 @@ type Collaboration struct {
 	ID     int64
 	RepoID int64      `xorm:"UNIQUE(s) INDEX NOT NULL"`
 	UserID int64      `xorm:"UNIQUE(s) INDEX NOT NULL"`
-	Mode   AccessMode `xorm:"DEFAULT 2 NOT NULL"`
+	Mode   AccessMode `xorm:"DEFAULT 3 NOT NULL"`
+	// IssuesOnly indicates the collaborator can only participate in issues with
+	// the access mode but cannot read code or other units of the repository.
+	IssuesOnly bool `xorm:"NOT NULL DEFAULT false"`
 }
 
 func (c *Collaboration) ModeI18nKey() string {
+	if c.IssuesOnly {
+		return "repo.settings.collaboration.issues_only"
+	}
+
 	switch c.Mode {
 	case ACCESS_MODE_READ:
 		return "repo.settings.collaboration.read"
+	case ACCESS_MODE_TRIAGE:
+		return "repo.settings.collaboration.triage"
 	case ACCESS_MODE_WRITE:
 		return "repo.settings.collaboration.write"
+	case ACCESS_MODE_MAINTAIN:
+		return "repo.settings.collaboration.maintain"
 	case ACCESS_MODE_ADMIN:
 		return "repo.settings.collaboration.admin"
 	default:
@@ -92,13 +103,27 @@ type Collaborator struct {
 	Collaboration *Collaboration
 }
 
+// unitModes returns access modes of the collaborator to repository units.
+func (c *Collaboration) unitModes() UnitAccessModes {
+	modes := make(UnitAccessModes, len(RepoUnits))
+	for _, unit := range RepoUnits {
+		if c.IssuesOnly && unit != REPO_UNIT_ISSUES {
+			modes[unit] = ACCESS_MODE_NONE
+		} else {
+			modes[unit] = c.Mode
+		}
+	}
+	return modes
+}
+
 func (c *Collaborator) APIFormat() *api.Collaborator {
+	codeMode := c.Collaboration.unitModes()[REPO_UNIT_CODE]
 	return &api.Collaborator{
 		User: c.User.APIFormat(),
 		Permissions: api.Permission{
-			Admin: c.Collaboration.Mode >= ACCESS_MODE_ADMIN,
-			Push:  c.Collaboration.Mode >= ACCESS_MODE_WRITE,
-			Pull:  c.Collaboration.Mode >= ACCESS_MODE_READ,
+			Admin: codeMode >= ACCESS_MODE_ADMIN,
+			Push:  codeMode >= ACCESS_MODE_WRITE,
+			Pull:  codeMode >= ACCESS_MODE_READ,
 		},
 	}
 }
@@ -146,10 +171,11 @@ func (repo *Repository) ChangeCollaborationAccessMode(userID int64, mode AccessM
 		return nil
 	}
 
-	if collaboration.Mode == mode {
+	if collaboration.Mode == mode && !collaboration.IssuesOnly {
 		return nil
 	}
 	collaboration.Mode = mode
+	collaboration.IssuesOnly = false
 
 	// If it's an organizational repository, merge with team access level for highest permission
 	if repo.Owner.IsOrganization() {
@@ -192,6 +218,43 @@ func (repo *Repository) ChangeCollaborationAccessMode(userID int64, mode AccessM
 		return fmt.Errorf("update/insert access table: %v", err)
 	}
 
+	// Access modes to repository units are merged with those of teams, and the
+	// collaborator may have been limited to issues before.
+	if err = repo.recalculateAccesses(sess); err != nil {
+		return fmt.Errorf("recalculateAccesses: %v", err)
+	}
+
+	return sess.Commit()
+}
+
+// ChangeCollaborationToIssuesOnly limits the collaborator to read and participate
+// in issues of the repository without access to code or other units.
+func (repo *Repository) ChangeCollaborationToIssuesOnly(userID int64) (err error) {
+	collaboration := &Collaboration{
+		RepoID: repo.ID,
+		UserID: userID,
+	}
+	has, err := x.Get(collaboration)
+	if err != nil {
+		return fmt.Errorf("get collaboration: %v", err)
+	} else if !has || collaboration.IssuesOnly {
+		return nil
+	}
+	collaboration.Mode = ACCESS_MODE_READ
+	collaboration.IssuesOnly = true
+
+	sess := x.NewSession()
+	defer sess.Close()
+	if err = sess.Begin(); err != nil {
+		return err
+	}
+
+	if _, err = sess.ID(collaboration.ID).AllCols().Update(collaboration); err != nil {
+		return fmt.Errorf("update collaboration: %v", err)
+	} else if err = repo.recalculateAccesses(sess); err != nil {
+		return fmt.Errorf("recalculateAccesses: %v", err)
+	}
+
 	return sess.Commit()
 }
 
@@ -0,0 +1,30 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Collaboration_unitModes(t *testing.T) {
+	Convey("Get access modes of collaborator to repository units", t, func() {
+		c := &Collaboration{Mode: ACCESS_MODE_WRITE}
+		modes := c.unitModes()
+		for _, unit := range RepoUnits {
+			So(modes[unit], ShouldEqual, ACCESS_MODE_WRITE)
+		}
+		So(c.ModeI18nKey(), ShouldEqual, "repo.settings.collaboration.write")
+
+		c = &Collaboration{Mode: ACCESS_MODE_READ, IssuesOnly: true}
+		modes = c.unitModes()
+		So(modes[REPO_UNIT_ISSUES], ShouldEqual, ACCESS_MODE_READ)
+		So(modes[REPO_UNIT_CODE], ShouldEqual, ACCESS_MODE_NONE)
+		So(modes[REPO_UNIT_WIKI], ShouldEqual, ACCESS_MODE_NONE)
+		So(modes[REPO_UNIT_RELEASES], ShouldEqual, ACCESS_MODE_NONE)
+		So(c.ModeI18nKey(), ShouldEqual, "repo.settings.collaboration.issues_only")
+	})
+}
@@ -0,0 +1,188 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gogs/git-module"
+
+	"gogs.io/gogs/internal/db/errors"
+)
+
+// maxMergeBaseCacheSize is the max number of merge bases kept in memory, the cache
+// is cleared when it is full.
+const maxMergeBaseCacheSize = 10000
+
+// mergeBaseCache caches merge bases of pairs of commits, which never change once
+// computed.
+var mergeBaseCache = struct {
+	sync.RWMutex
+	bases map[string]string
+}{bases: make(map[string]string)}
+
+// getMergeBase returns the merge base of two commits in the repository, and uses the
+// cache whenever possible.
+func getMergeBase(gitRepo *git.Repository, repoID int64, base, head string) (string, error) {
+	key := fmt.Sprintf("%d:%s:%s", repoID, base, head)
+	mergeBaseCache.RLock()
+	mergeBase, ok := mergeBaseCache.bases[key]
+	mergeBaseCache.RUnlock()
+	if ok {
+		return mergeBase, nil
+	}
+
+	mergeBase, err := gitRepo.GetMergeBase(base, head)
+	if err != nil {
+		return "", err
+	}
+
+	mergeBaseCache.Lock()
+	if len(mergeBaseCache.bases) >= maxMergeBaseCacheSize {
+		mergeBaseCache.bases = make(map[string]string)
+	}
+	mergeBaseCache.bases[key] = mergeBase
+	mergeBaseCache.Unlock()
+	return mergeBase, nil
+}
+
+// RefContributor is an author of commits between two references, who is identified
+// by email.
+type RefContributor struct {
+	Name    string
+	Email   string
+	User    *User // Nil if the email does not belong to any user
+	Commits int
+}
+
+// RefComparison contains changes between two references of a repository, which are
+// useful for composing release notes.
+type RefComparison struct {
+	FromCommitID string
+	ToCommitID   string
+	MergeBase    string
+	// Commits are reachable from the "to" reference but not the merge base, newest first.
+	Commits []*git.Commit
+	// PullRequests are merged pull requests whose head commits are within commits.
+	PullRequests []*PullRequest
+	// Contributors are in descending order of number of commits.
+	Contributors []*RefContributor
+}
+
+// aggregateRefContributors returns authors of commits in descending order of number
+// of commits, and then in alphabetical order of names.
+func aggregateRefContributors(commits []*git.Commit) []*RefContributor {
+	contributors := make([]*RefContributor, 0, 10)
+	indexes := make(map[string]int)
+	for _, c := range commits {
+		email := strings.ToLower(c.Author.Email)
+		i, ok := indexes[email]
+		if !ok {
+			i = len(contributors)
+			indexes[email] = i
+			contributors = append(contributors, &RefContributor{
+				Name:  c.Author.Name,
+				Email: c.Author.Email,
+			})
+		}
+		contributors[i].Commits++
+	}
+
+	sort.SliceStable(contributors, func(i, j int) bool {
+		if contributors[i].Commits != contributors[j].Commits {
+			return contributors[i].Commits > contributors[j].Commits
+		}
+		return contributors[i].Name < contributors[j].Name
+	})
+	return contributors
+}
+
+// getMergedPullRequestsByCommitIDs returns merged pull requests of the base repository
+// whose merged commits are in given list, in descending order of merged time.
+func getMergedPullRequestsByCommitIDs(baseRepoID int64, commitIDs []string) ([]*PullRequest, error) {
+	prs := make([]*PullRequest, 0, 10)
+	// Query in batches to keep the number of arguments under database limits.
+	const batchSize = 500
+	for start := 0; start < len(commitIDs); start += batchSize {
+		end := start + batchSize
+		if end > len(commitIDs) {
+			end = len(commitIDs)
+		}
+
+		batch := make([]*PullRequest, 0, 10)
+		if err := x.Where("base_repo_id = ? AND has_merged = ?", baseRepoID, true).
+			In("merged_commit_id", commitIDs[start:end]).Find(&batch); err != nil {
+			return nil, err
+		}
+		prs = append(prs, batch...)
+	}
+
+	sort.Slice(prs, func(i, j int) bool { return prs[i].MergedUnix > prs[j].MergedUnix })
+	for _, pr := range prs {
+		if err := pr.LoadAttributes(); err != nil {
+			return nil, fmt.Errorf("LoadAttributes [%d]: %v", pr.ID, err)
+		} else if err = pr.LoadIssue(); err != nil {
+			return nil, fmt.Errorf("LoadIssue [%d]: %v", pr.ID, err)
+		}
+	}
+	return prs, nil
+}
+
+// revParseCommit returns the ID of the commit that the revision points to, annotated
+// tags are peeled to their commits.
+func revParseCommit(repoPath, rev string) (string, error) {
+	return RevParseObject(repoPath, rev, git.ObjectCommit)
+}
+
+// CompareRefs returns commits, merged pull requests and contributors between two
+// revisions of the repository, i.e. what the "to" revision has since it diverged
+// from the "from" revision. It returns git.ErrNotExist if any revision does not
+// point to a commit, or git.ErrNoMergeBase if they have no common ancestor.
+func (repo *Repository) CompareRefs(gitRepo *git.Repository, from, to string) (_ *RefComparison, err error) {
+	cmp := new(RefComparison)
+	if cmp.FromCommitID, err = revParseCommit(gitRepo.Path, from); err != nil {
+		return nil, err
+	} else if cmp.ToCommitID, err = revParseCommit(gitRepo.Path, to); err != nil {
+		return nil, err
+	}
+
+	cmp.MergeBase, err = getMergeBase(gitRepo, repo.ID, cmp.FromCommitID, cmp.ToCommitID)
+	if err != nil {
+		return nil, err
+	}
+
+	cmp.Commits = []*git.Commit{}
+	if cmp.MergeBase != cmp.ToCommitID {
+		l, err := gitRepo.CommitsBetweenIDs(cmp.ToCommitID, cmp.MergeBase)
+		if err != nil {
+			return nil, fmt.Errorf("CommitsBetweenIDs: %v", err)
+		}
+		cmp.Commits = make([]*git.Commit, 0, l.Len())
+		for e := l.Front(); e != nil; e = e.Next() {
+			cmp.Commits = append(cmp.Commits, e.Value.(*git.Commit))
+		}
+	}
+
+	commitIDs := make([]string, len(cmp.Commits))
+	for i := range cmp.Commits {
+		commitIDs[i] = cmp.Commits[i].ID.String()
+	}
+	cmp.PullRequests, err = getMergedPullRequestsByCommitIDs(repo.ID, commitIDs)
+	if err != nil {
+		return nil, fmt.Errorf("getMergedPullRequestsByCommitIDs: %v", err)
+	}
+
+	cmp.Contributors = aggregateRefContributors(cmp.Commits)
+	for _, c := range cmp.Contributors {
+		c.User, err = GetUserByEmail(c.Email)
+		if err != nil && !errors.IsUserNotExist(err) {
+			return nil, fmt.Errorf("GetUserByEmail [%s]: %v", c.Email, err)
+		}
+	}
+	return cmp, nil
+}
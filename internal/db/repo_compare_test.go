@@ -0,0 +1,35 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/gogs/git-module"
+)
+
+func Test_aggregateRefContributors(t *testing.T) {
+	Convey("Aggregate contributors of commits", t, func() {
+		newCommit := func(name, email string) *git.Commit {
+			return &git.Commit{Author: &git.Signature{Name: name, Email: email}}
+		}
+		contributors := aggregateRefContributors([]*git.Commit{
+			newCommit("Bob", "bob@example.com"),
+			newCommit("Alice", "alice@example.com"),
+			newCommit("Carol", "carol@example.com"),
+			newCommit("Alice", "Alice@Example.com"),
+		})
+
+		So(contributors, ShouldHaveLength, 3)
+		So(contributors[0].Name, ShouldEqual, "Alice")
+		So(contributors[0].Email, ShouldEqual, "alice@example.com")
+		So(contributors[0].Commits, ShouldEqual, 2)
+		So(contributors[1].Name, ShouldEqual, "Bob")
+		So(contributors[1].Commits, ShouldEqual, 1)
+		So(contributors[2].Name, ShouldEqual, "Carol")
+	})
+}
@@ -32,6 +32,7 @@ const (
 	ENV_AUTH_USER_ID           = "GOGS_AUTH_USER_ID"
 	ENV_AUTH_USER_NAME         = "GOGS_AUTH_USER_NAME"
 	ENV_AUTH_USER_EMAIL        = "GOGS_AUTH_USER_EMAIL"
+	ENV_AUTH_USER_AGIT_ONLY    = "GOGS_AUTH_USER_AGIT_ONLY"
 	ENV_REPO_OWNER_NAME        = "GOGS_REPO_OWNER_NAME"
 	ENV_REPO_OWNER_SALT_MD5    = "GOGS_REPO_OWNER_SALT_MD5"
 	ENV_REPO_ID                = "GOGS_REPO_ID"
@@ -46,6 +47,9 @@ type ComposeHookEnvsOptions struct {
 	RepoID    int64
 	RepoName  string
 	RepoPath  string
+	// Whether the user is only allowed to push to references of AGit flow to create
+	// pull requests.
+	AgitOnly bool
 }
 
 func ComposeHookEnvs(opts ComposeHookEnvsOptions) []string {
@@ -54,6 +58,7 @@ func ComposeHookEnvs(opts ComposeHookEnvsOptions) []string {
 		ENV_AUTH_USER_ID + "=" + com.ToStr(opts.AuthUser.ID),
 		ENV_AUTH_USER_NAME + "=" + opts.AuthUser.Name,
 		ENV_AUTH_USER_EMAIL + "=" + opts.AuthUser.Email,
+		ENV_AUTH_USER_AGIT_ONLY + "=" + com.ToStr(opts.AgitOnly),
 		ENV_REPO_OWNER_NAME + "=" + opts.OwnerName,
 		ENV_REPO_OWNER_SALT_MD5 + "=" + tool.MD5(opts.OwnerSalt),
 		ENV_REPO_ID + "=" + com.ToStr(opts.RepoID),
@@ -108,6 +113,15 @@ func (repo *Repository) CheckoutNewBranch(oldBranch, newBranch string) error {
 	return checkoutNewBranch(repo.RepoPath(), repo.LocalCopyPath(), oldBranch, newBranch)
 }
 
+// editorSignature returns the signature to commit changes as, which is the doer
+// unless overridden.
+func editorSignature(doer *User, sig *git.Signature) *git.Signature {
+	if sig != nil {
+		return sig
+	}
+	return doer.NewGitSig()
+}
+
 type UpdateRepoFileOptions struct {
 	LastCommitID string
 	OldBranch    string
@@ -117,6 +131,9 @@ type UpdateRepoFileOptions struct {
 	Message      string
 	Content      string
 	IsNewFile    bool
+	// Author and Committer override signatures of the commit, both default to the doer.
+	Author    *git.Signature
+	Committer *git.Signature
 }
 
 // UpdateRepoFile adds or updates a file in repository.
@@ -178,10 +195,11 @@ func (repo *Repository) UpdateRepoFile(doer *User, opts UpdateRepoFileOptions) (
 
 	if err = git.AddChanges(localPath, true); err != nil {
 		return fmt.Errorf("git add --all: %v", err)
-	} else if err = git.CommitChanges(localPath, git.CommitChangesOptions{
-		Committer: doer.NewGitSig(),
+	} else if err = commitChanges(localPath, git.CommitChangesOptions{
+		Committer: editorSignature(doer, opts.Committer),
+		Author:    opts.Author,
 		Message:   opts.Message,
-	}); err != nil {
+	}, signOnEditor); err != nil {
 		return fmt.Errorf("commit changes on %q: %v", localPath, err)
 	} else if err = git.PushWithEnvs(localPath, "origin", opts.NewBranch,
 		ComposeHookEnvs(ComposeHookEnvsOptions{
@@ -257,6 +275,9 @@ type DeleteRepoFileOptions struct {
 	NewBranch    string
 	TreePath     string
 	Message      string
+	// Author and Committer override signatures of the commit, both default to the doer.
+	Author    *git.Signature
+	Committer *git.Signature
 }
 
 func (repo *Repository) DeleteRepoFile(doer *User, opts DeleteRepoFileOptions) (err error) {
@@ -282,10 +303,11 @@ func (repo *Repository) DeleteRepoFile(doer *User, opts DeleteRepoFileOptions) (
 
 	if err = git.AddChanges(localPath, true); err != nil {
 		return fmt.Errorf("git add --all: %v", err)
-	} else if err = git.CommitChanges(localPath, git.CommitChangesOptions{
-		Committer: doer.NewGitSig(),
+	} else if err = commitChanges(localPath, git.CommitChangesOptions{
+		Committer: editorSignature(doer, opts.Committer),
+		Author:    opts.Author,
 		Message:   opts.Message,
-	}); err != nil {
+	}, signOnEditor); err != nil {
 		return fmt.Errorf("commit changes to %q: %v", localPath, err)
 	} else if err = git.PushWithEnvs(localPath, "origin", opts.NewBranch,
 		ComposeHookEnvs(ComposeHookEnvsOptions{
@@ -498,10 +520,10 @@ func (repo *Repository) UploadRepoFiles(doer *User, opts UploadRepoFileOptions)
 
 	if err = git.AddChanges(localPath, true); err != nil {
 		return fmt.Errorf("git add --all: %v", err)
-	} else if err = git.CommitChanges(localPath, git.CommitChangesOptions{
+	} else if err = commitChanges(localPath, git.CommitChangesOptions{
 		Committer: doer.NewGitSig(),
 		Message:   opts.Message,
-	}); err != nil {
+	}, signOnEditor); err != nil {
 		return fmt.Errorf("commit changes on %q: %v", localPath, err)
 	} else if err = git.PushWithEnvs(localPath, "origin", opts.NewBranch,
 		ComposeHookEnvs(ComposeHookEnvsOptions{
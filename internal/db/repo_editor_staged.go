@@ -0,0 +1,311 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/unknwon/com"
+	"xorm.io/xorm"
+
+	"github.com/gogs/git-module"
+
+	"gogs.io/gogs/internal/db/errors"
+)
+
+// StagedChange is a change to a file made in the web editor but not yet committed.
+// Staged changes of a user to the same branch are committed together.
+type StagedChange struct {
+	ID       int64
+	UserID   int64  `xorm:"INDEX(s)"`
+	RepoID   int64  `xorm:"INDEX(s)"`
+	Branch   string `xorm:"INDEX(s)"`
+	TreePath string `xorm:"TEXT"`
+	// OldTreePath is the path of the file in the branch, which is empty for new files,
+	// or different from TreePath for renamed files.
+	OldTreePath string `xorm:"TEXT"`
+	Content     string `xorm:"LONGTEXT"`
+	IsNew       bool   `xorm:"NOT NULL DEFAULT false"`
+	IsDelete    bool   `xorm:"NOT NULL DEFAULT false"`
+
+	Created     time.Time `xorm:"-" json:"-"`
+	CreatedUnix int64
+	Updated     time.Time `xorm:"-" json:"-"`
+	UpdatedUnix int64
+}
+
+func (c *StagedChange) BeforeInsert() {
+	c.CreatedUnix = time.Now().Unix()
+	c.UpdatedUnix = c.CreatedUnix
+}
+
+func (c *StagedChange) BeforeUpdate() {
+	c.UpdatedUnix = time.Now().Unix()
+}
+
+func (c *StagedChange) AfterSet(colName string, _ xorm.Cell) {
+	switch colName {
+	case "created_unix":
+		c.Created = time.Unix(c.CreatedUnix, 0).Local()
+	case "updated_unix":
+		c.Updated = time.Unix(c.UpdatedUnix, 0).Local()
+	}
+}
+
+// IsRename returns true if the change renames an existing file.
+func (c *StagedChange) IsRename() bool {
+	return !c.IsNew && c.OldTreePath != c.TreePath
+}
+
+// GetStagedChanges returns staged changes of the user to the branch of the repository
+// in the order of tree paths.
+func GetStagedChanges(userID, repoID int64, branch string) ([]*StagedChange, error) {
+	changes := make([]*StagedChange, 0, 5)
+	if err := x.Where("user_id = ? AND repo_id = ? AND branch = ?", userID, repoID, branch).Find(&changes); err != nil {
+		return nil, err
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].TreePath < changes[j].TreePath })
+	return changes, nil
+}
+
+// CountStagedChanges returns the number of staged changes of the user to the branch
+// of the repository.
+func CountStagedChanges(userID, repoID int64, branch string) (int64, error) {
+	return x.Where("user_id = ? AND repo_id = ? AND branch = ?", userID, repoID, branch).Count(new(StagedChange))
+}
+
+// GetStagedChangeByTreePath returns the staged change of the user to the file in the
+// branch of the repository.
+func GetStagedChangeByTreePath(userID, repoID int64, branch, treePath string) (*StagedChange, error) {
+	changes, err := GetStagedChanges(userID, repoID, branch)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range changes {
+		if c.TreePath == treePath {
+			return c, nil
+		}
+	}
+	return nil, errors.StagedChangeNotExist{TreePath: treePath}
+}
+
+type StageRepoFileOptions struct {
+	Branch string
+	// OldTreePath is the path of the file being edited or deleted, which is empty for
+	// new files.
+	OldTreePath string
+	TreePath    string
+	Content     string
+	IsNewFile   bool
+	IsDelete    bool
+}
+
+// StageRepoFile stages a change to a file of the repository for the user, which replaces
+// previously staged change to the same file.
+func (repo *Repository) StageRepoFile(doer *User, opts StageRepoFileOptions) error {
+	if isRepositoryGitPath(opts.TreePath) {
+		return fmt.Errorf("malicious path detected: %s", opts.TreePath)
+	}
+
+	changes, err := GetStagedChanges(doer.ID, repo.ID, opts.Branch)
+	if err != nil {
+		return fmt.Errorf("GetStagedChanges: %v", err)
+	}
+
+	srcPath := opts.OldTreePath
+	if opts.IsNewFile {
+		srcPath = opts.TreePath
+	}
+	var change *StagedChange
+	for _, c := range changes {
+		if c.TreePath == srcPath {
+			change = c
+		} else if c.TreePath == opts.TreePath && !c.IsDelete {
+			return ErrRepoFileAlreadyExist{opts.TreePath}
+		}
+	}
+
+	if change != nil && opts.IsNewFile && !change.IsDelete {
+		return ErrRepoFileAlreadyExist{opts.TreePath}
+	}
+
+	if change == nil {
+		change = &StagedChange{
+			UserID:      doer.ID,
+			RepoID:      repo.ID,
+			Branch:      opts.Branch,
+			TreePath:    opts.TreePath,
+			OldTreePath: opts.OldTreePath,
+			Content:     opts.Content,
+			IsNew:       opts.IsNewFile,
+			IsDelete:    opts.IsDelete,
+		}
+		_, err = x.Insert(change)
+		return err
+	}
+
+	// Deleting a staged new file leaves nothing to commit.
+	if change.IsNew && opts.IsDelete {
+		_, err = x.Id(change.ID).Delete(new(StagedChange))
+		return err
+	}
+
+	change.TreePath = opts.TreePath
+	change.Content = opts.Content
+	change.IsDelete = opts.IsDelete
+	_, err = x.Id(change.ID).AllCols().Update(change)
+	return err
+}
+
+// UnstageChange removes a staged change of the user.
+func UnstageChange(userID, id int64) error {
+	affected, err := x.Delete(&StagedChange{ID: id, UserID: userID})
+	if err != nil {
+		return err
+	} else if affected == 0 {
+		return errors.StagedChangeNotExist{ID: id}
+	}
+	return nil
+}
+
+// DiscardStagedChanges removes all staged changes of the user to the branch of the
+// repository.
+func DiscardStagedChanges(userID, repoID int64, branch string) error {
+	_, err := x.Delete(&StagedChange{UserID: userID, RepoID: repoID, Branch: branch})
+	return err
+}
+
+// stagedChangeOrder returns the order of applying the change, deletions and renames
+// go first to make room for others.
+func stagedChangeOrder(c *StagedChange) int {
+	switch {
+	case c.IsDelete:
+		return 0
+	case c.IsRename():
+		return 1
+	case c.IsNew:
+		return 3
+	}
+	return 2
+}
+
+// applyStagedChanges applies staged changes to files in the local copy.
+func applyStagedChanges(localPath string, changes []*StagedChange) (err error) {
+	changes = append([]*StagedChange(nil), changes...)
+	sort.SliceStable(changes, func(i, j int) bool {
+		return stagedChangeOrder(changes[i]) < stagedChangeOrder(changes[j])
+	})
+
+	for _, c := range changes {
+		filePath := path.Join(localPath, c.TreePath)
+		switch {
+		case c.IsDelete:
+			if err = os.Remove(path.Join(localPath, c.OldTreePath)); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("remove file %q: %v", c.OldTreePath, err)
+			}
+			continue
+
+		case c.IsRename():
+			if com.IsExist(filePath) {
+				return ErrRepoFileAlreadyExist{c.TreePath}
+			}
+			os.MkdirAll(path.Dir(filePath), os.ModePerm)
+			if err = git.MoveFile(localPath, c.OldTreePath, c.TreePath); err != nil {
+				return fmt.Errorf("git mv %q %q: %v", c.OldTreePath, c.TreePath, err)
+			}
+
+		case c.IsNew:
+			if com.IsExist(filePath) {
+				return ErrRepoFileAlreadyExist{c.TreePath}
+			}
+			os.MkdirAll(path.Dir(filePath), os.ModePerm)
+		}
+
+		if err = ioutil.WriteFile(filePath, []byte(c.Content), 0666); err != nil {
+			return fmt.Errorf("write file %q: %v", c.TreePath, err)
+		}
+	}
+	return nil
+}
+
+type CommitStagedChangesOptions struct {
+	OldBranch string
+	NewBranch string
+	Message   string
+}
+
+// CommitStagedChanges commits all staged changes of the user to the old branch as a
+// single commit, and pushes the commit to the new branch. Staged changes are removed
+// after they are committed.
+func (repo *Repository) CommitStagedChanges(doer *User, opts CommitStagedChangesOptions) (err error) {
+	changes, err := GetStagedChanges(doer.ID, repo.ID, opts.OldBranch)
+	if err != nil {
+		return fmt.Errorf("GetStagedChanges: %v", err)
+	} else if len(changes) == 0 {
+		return errors.NoStagedChanges{Branch: opts.OldBranch}
+	}
+
+	repoWorkingPool.CheckIn(com.ToStr(repo.ID))
+	defer repoWorkingPool.CheckOut(com.ToStr(repo.ID))
+
+	if err = repo.DiscardLocalRepoBranchChanges(opts.OldBranch); err != nil {
+		return fmt.Errorf("discard local repo branch[%s] changes: %v", opts.OldBranch, err)
+	} else if err = repo.UpdateLocalCopyBranch(opts.OldBranch); err != nil {
+		return fmt.Errorf("update local copy branch[%s]: %v", opts.OldBranch, err)
+	}
+
+	repoPath := repo.RepoPath()
+	localPath := repo.LocalCopyPath()
+
+	if opts.OldBranch != opts.NewBranch {
+		// Directly return error if new branch already exists in the server
+		if git.IsBranchExist(repoPath, opts.NewBranch) {
+			return errors.BranchAlreadyExists{Name: opts.NewBranch}
+		}
+
+		// Otherwise, delete branch from local copy in case out of sync
+		if git.IsBranchExist(localPath, opts.NewBranch) {
+			if err = git.DeleteBranch(localPath, opts.NewBranch, git.DeleteBranchOptions{
+				Force: true,
+			}); err != nil {
+				return fmt.Errorf("delete branch[%s]: %v", opts.NewBranch, err)
+			}
+		}
+
+		if err = repo.CheckoutNewBranch(opts.OldBranch, opts.NewBranch); err != nil {
+			return fmt.Errorf("checkout new branch[%s] from old branch[%s]: %v", opts.NewBranch, opts.OldBranch, err)
+		}
+	}
+
+	if err = applyStagedChanges(localPath, changes); err != nil {
+		return err
+	}
+
+	if err = git.AddChanges(localPath, true); err != nil {
+		return fmt.Errorf("git add --all: %v", err)
+	} else if err = commitChanges(localPath, git.CommitChangesOptions{
+		Committer: doer.NewGitSig(),
+		Message:   opts.Message,
+	}, signOnEditor); err != nil {
+		return fmt.Errorf("commit changes on %q: %v", localPath, err)
+	} else if err = git.PushWithEnvs(localPath, "origin", opts.NewBranch,
+		ComposeHookEnvs(ComposeHookEnvsOptions{
+			AuthUser:  doer,
+			OwnerName: repo.MustOwner().Name,
+			OwnerSalt: repo.MustOwner().Salt,
+			RepoID:    repo.ID,
+			RepoName:  repo.Name,
+			RepoPath:  repo.RepoPath(),
+		})); err != nil {
+		return fmt.Errorf("git push origin %s: %v", opts.NewBranch, err)
+	}
+
+	return DiscardStagedChanges(doer.ID, repo.ID, opts.OldBranch)
+}
@@ -0,0 +1,58 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_StagedChange_IsRename(t *testing.T) {
+	Convey("Check if staged change renames a file", t, func() {
+		So((&StagedChange{OldTreePath: "a.md", TreePath: "a.md"}).IsRename(), ShouldBeFalse)
+		So((&StagedChange{OldTreePath: "a.md", TreePath: "b.md"}).IsRename(), ShouldBeTrue)
+		So((&StagedChange{TreePath: "b.md", IsNew: true}).IsRename(), ShouldBeFalse)
+	})
+}
+
+func Test_applyStagedChanges(t *testing.T) {
+	Convey("Apply staged changes to local copy", t, func() {
+		localPath, err := ioutil.TempDir("", "staged")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(localPath)
+
+		So(ioutil.WriteFile(filepath.Join(localPath, "README.md"), []byte("old"), 0666), ShouldBeNil)
+		So(ioutil.WriteFile(filepath.Join(localPath, "old.txt"), []byte("old"), 0666), ShouldBeNil)
+
+		// The new file replaces the deleted one, which requires the deletion goes first.
+		So(applyStagedChanges(localPath, []*StagedChange{
+			{TreePath: "old.txt", Content: "new", IsNew: true},
+			{TreePath: "docs/guide.md", Content: "guide", IsNew: true},
+			{OldTreePath: "README.md", TreePath: "README.md", Content: "new"},
+			{OldTreePath: "old.txt", TreePath: "old.txt", IsDelete: true},
+		}), ShouldBeNil)
+
+		for name, content := range map[string]string{
+			"README.md":     "new",
+			"old.txt":       "new",
+			"docs/guide.md": "guide",
+		} {
+			data, err := ioutil.ReadFile(filepath.Join(localPath, name))
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, content)
+		}
+
+		Convey("Add a file that already exists", func() {
+			err := applyStagedChanges(localPath, []*StagedChange{
+				{TreePath: "README.md", Content: "new", IsNew: true},
+			})
+			So(IsErrRepoFileAlreadyExist(err), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,416 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/unknwon/com"
+	log "unknwon.dev/clog/v2"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/db/errors"
+)
+
+// repoArchiveVersion is the version of format of repository archives.
+const repoArchiveVersion = 1
+
+// Directories of Git data in a repository archive, which are named in the way that
+// wiki is found when the repository is cloned from the archive.
+const (
+	repoArchiveGitDir  = "repository.git"
+	repoArchiveWikiDir = "repository.wiki.git"
+)
+
+// repoArchiveInfo is the metadata of a repository archive.
+type repoArchiveInfo struct {
+	Version     int       `json:"version"`
+	GogsVersion string    `json:"gogs_version"`
+	Exported    time.Time `json:"exported"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Website     string    `json:"website"`
+}
+
+// isRepoArchiveEntry returns true if the entry of given name is known to repository
+// archives. Only objects and references are taken from Git data so that hooks and
+// configuration are never carried by archives.
+func isRepoArchiveEntry(name string) bool {
+	if name == "" || path.IsAbs(name) || path.Clean(name) != name || strings.HasPrefix(name, "..") {
+		return false
+	}
+
+	switch name {
+	case repoArchiveMetadata, repoArchiveLabels, repoArchiveMilestones,
+		repoArchiveIssues, repoArchivePulls, repoArchiveReleases:
+		return true
+	}
+
+	fields := strings.SplitN(name, "/", 3)
+	if len(fields) < 2 || (fields[0] != repoArchiveGitDir && fields[0] != repoArchiveWikiDir) {
+		return false
+	}
+	switch fields[1] {
+	case "HEAD", "packed-refs":
+		return len(fields) == 2
+	case "refs", "objects":
+		return true
+	}
+	return false
+}
+
+// addRepoArchiveGitDir adds Git data in given repository path to the archive under the directory.
+func addRepoArchiveGitDir(z *zip.Writer, dir, repoPath string) error {
+	return filepath.Walk(repoPath, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		} else if p == repoPath {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(repoPath, p)
+		if err != nil {
+			return err
+		}
+		name := path.Join(dir, filepath.ToSlash(relPath))
+		if !isRepoArchiveEntry(name) {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// Empty directories like "refs/heads" are still required by Git.
+		if fi.IsDir() {
+			_, err = z.Create(name + "/")
+			return err
+		} else if !fi.Mode().IsRegular() {
+			return nil
+		}
+
+		w, err := z.Create(name)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(w, f)
+		return err
+	})
+}
+
+func addRepoArchiveJSON(z *zip.Writer, name string, v interface{}) error {
+	w, err := z.Create(name)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(v)
+}
+
+// exportUserURL returns the URL of the user's profile, or empty for ghost users.
+func exportUserURL(u *User) string {
+	if u.ID <= 0 {
+		return ""
+	}
+	return u.HTMLURL()
+}
+
+// exportIssue returns the issue or pull request in the format of repository archives.
+func exportIssue(issue *Issue) (*migrationIssue, []*migrationComment) {
+	mi := &migrationIssue{
+		Number:    issue.Index,
+		Title:     issue.Title,
+		Body:      issue.Content,
+		Author:    issue.Poster.Name,
+		AuthorURL: exportUserURL(issue.Poster),
+		URL:       issue.HTMLURL(),
+		IsClosed:  issue.IsClosed,
+		Labels:    make([]string, len(issue.Labels)),
+		Created:   issue.Created,
+		Updated:   issue.Updated,
+	}
+	for i := range issue.Labels {
+		mi.Labels[i] = issue.Labels[i].Name
+	}
+	if issue.Milestone != nil {
+		mi.Milestone = issue.Milestone.Name
+	}
+
+	comments := make([]*migrationComment, 0, len(issue.Comments))
+	for _, c := range issue.Comments {
+		if c.Type != COMMENT_TYPE_COMMENT {
+			continue
+		}
+		comments = append(comments, &migrationComment{
+			Body:      c.Content,
+			Author:    c.Poster.Name,
+			AuthorURL: exportUserURL(c.Poster),
+			Created:   c.Created,
+			Updated:   c.Updated,
+		})
+	}
+	return mi, comments
+}
+
+// ExportRepository writes an archive of the repository to w, which contains Git data of the
+// repository and its wiki, labels, milestones, issues and pull requests with comments, and
+// releases. The archive can be imported by ImportRepository into another instance.
+func ExportRepository(repo *Repository, w io.Writer) error {
+	z := zip.NewWriter(w)
+
+	if err := addRepoArchiveJSON(z, repoArchiveMetadata, &repoArchiveInfo{
+		Version:     repoArchiveVersion,
+		GogsVersion: conf.App.Version,
+		Exported:    time.Now(),
+		Name:        repo.Name,
+		Description: repo.Description,
+		Website:     repo.Website,
+	}); err != nil {
+		return fmt.Errorf("add metadata: %v", err)
+	}
+
+	if err := addRepoArchiveGitDir(z, repoArchiveGitDir, repo.RepoPath()); err != nil {
+		return fmt.Errorf("add repository: %v", err)
+	}
+	if repo.HasWiki() {
+		if err := addRepoArchiveGitDir(z, repoArchiveWikiDir, repo.WikiPath()); err != nil {
+			return fmt.Errorf("add wiki: %v", err)
+		}
+	}
+
+	labels, err := GetLabelsByRepoID(repo.ID)
+	if err != nil {
+		return fmt.Errorf("GetLabelsByRepoID: %v", err)
+	}
+	archiveLabels := make([]*migrationLabel, len(labels))
+	for i := range labels {
+		archiveLabels[i] = &migrationLabel{
+			Name:  labels[i].Name,
+			Color: labels[i].Color,
+		}
+	}
+	if err = addRepoArchiveJSON(z, repoArchiveLabels, archiveLabels); err != nil {
+		return fmt.Errorf("add labels: %v", err)
+	}
+
+	milestones, err := GetMilestonesByRepoID(repo.ID)
+	if err != nil {
+		return fmt.Errorf("GetMilestonesByRepoID: %v", err)
+	}
+	archiveMilestones := make([]*migrationMilestone, len(milestones))
+	for i, m := range milestones {
+		archiveMilestones[i] = &migrationMilestone{
+			Title:       m.Name,
+			Description: m.Content,
+			IsClosed:    m.IsClosed,
+		}
+		if m.Deadline.Year() < 9999 {
+			archiveMilestones[i].Deadline = &milestones[i].Deadline
+		}
+	}
+	if err = addRepoArchiveJSON(z, repoArchiveMilestones, archiveMilestones); err != nil {
+		return fmt.Errorf("add milestones: %v", err)
+	}
+
+	issues := make([]*Issue, 0, repo.NumIssues+repo.NumPulls)
+	if err = x.Where("repo_id = ?", repo.ID).Asc("`index`").Find(&issues); err != nil {
+		return fmt.Errorf("find issues: %v", err)
+	}
+	archiveIssues := make([]*archiveIssue, 0, repo.NumIssues)
+	archivePulls := make([]*archivePull, 0, repo.NumPulls)
+	for _, issue := range issues {
+		issue.Repo = repo
+		if err = issue.LoadAttributes(); err != nil {
+			return fmt.Errorf("LoadAttributes [issue_id: %d]: %v", issue.ID, err)
+		}
+
+		mi, comments := exportIssue(issue)
+		if !issue.IsPull {
+			archiveIssues = append(archiveIssues, &archiveIssue{
+				migrationIssue: *mi,
+				Comments:       comments,
+			})
+			continue
+		}
+
+		pr := issue.PullRequest
+		if pr == nil {
+			continue
+		}
+		archivePulls = append(archivePulls, &archivePull{
+			migrationPull: migrationPull{
+				migrationIssue: *mi,
+				IsCrossRepo:    pr.HeadRepoID != pr.BaseRepoID,
+				HeadUserName:   pr.HeadUserName,
+				HeadBranch:     pr.HeadBranch,
+				BaseBranch:     pr.BaseBranch,
+				IsMerged:       pr.HasMerged,
+				MergeBase:      pr.MergeBase,
+				MergedCommitID: pr.MergedCommitID,
+				Merged:         pr.Merged,
+			},
+			Comments: comments,
+		})
+	}
+	if err = addRepoArchiveJSON(z, repoArchiveIssues, archiveIssues); err != nil {
+		return fmt.Errorf("add issues: %v", err)
+	}
+	if err = addRepoArchiveJSON(z, repoArchivePulls, archivePulls); err != nil {
+		return fmt.Errorf("add pull requests: %v", err)
+	}
+
+	releases := make([]*Release, 0, 10)
+	if err = x.Where("repo_id = ?", repo.ID).Asc("created_unix").Find(&releases); err != nil {
+		return fmt.Errorf("find releases: %v", err)
+	}
+	archiveReleases := make([]*migrationRelease, len(releases))
+	for i, r := range releases {
+		archiveReleases[i] = &migrationRelease{
+			TagName:      r.TagName,
+			Target:       r.Target,
+			Title:        r.Title,
+			Note:         r.Note,
+			IsDraft:      r.IsDraft,
+			IsPrerelease: r.IsPrerelease,
+			Created:      r.Created,
+		}
+	}
+	if err = addRepoArchiveJSON(z, repoArchiveReleases, archiveReleases); err != nil {
+		return fmt.Errorf("add releases: %v", err)
+	}
+
+	return z.Close()
+}
+
+// extractRepoArchive extracts known entries of the archive to the directory.
+func extractRepoArchive(z *zip.Reader, dir string) error {
+	for _, f := range z.File {
+		name := strings.TrimSuffix(f.Name, "/")
+		if !isRepoArchiveEntry(name) {
+			continue
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, os.ModePerm); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+			return err
+		}
+
+		r, err := f.Open()
+		if err != nil {
+			return errors.InvalidRepoArchive{Reason: fmt.Sprintf("open %q: %v", f.Name, err)}
+		}
+		w, err := os.Create(target)
+		if err != nil {
+			r.Close()
+			return err
+		}
+		_, err = io.Copy(w, r)
+		r.Close()
+		w.Close()
+		if err != nil {
+			return errors.InvalidRepoArchive{Reason: fmt.Sprintf("extract %q: %v", f.Name, err)}
+		}
+	}
+	return nil
+}
+
+type ImportRepoOptions struct {
+	// Name is the name of new repository, the name in the archive is used when it is empty.
+	Name      string
+	IsPrivate bool
+	Archive   io.ReaderAt
+	Size      int64
+}
+
+// ImportRepository creates a new repository from an archive exported by ExportRepository.
+// Git data is imported right away, and other data is imported by a migration task in
+// background.
+func ImportRepository(doer, owner *User, opts ImportRepoOptions) (_ *Repository, err error) {
+	z, err := zip.NewReader(opts.Archive, opts.Size)
+	if err != nil {
+		return nil, errors.InvalidRepoArchive{Reason: err.Error()}
+	}
+
+	// The archive is kept until the migration task is done, thus it is not extracted
+	// to the temporary directory that is cleaned up on start.
+	dir := filepath.Join(conf.Server.AppDataPath, "imports", com.ToStr(time.Now().UnixNano()))
+	defer func() {
+		if err != nil {
+			RemoveAllWithNotice("Delete extracted repository archive", dir)
+		}
+	}()
+	if err = extractRepoArchive(z, dir); err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, repoArchiveMetadata))
+	if err != nil {
+		return nil, errors.InvalidRepoArchive{Reason: "metadata is missing"}
+	}
+	info := new(repoArchiveInfo)
+	if err = json.Unmarshal(data, info); err != nil {
+		return nil, errors.InvalidRepoArchive{Reason: fmt.Sprintf("decode metadata: %v", err)}
+	} else if info.Version < 1 || info.Version > repoArchiveVersion {
+		return nil, errors.InvalidRepoArchive{Reason: fmt.Sprintf("unsupported version %d", info.Version)}
+	}
+
+	gitDir := filepath.Join(dir, repoArchiveGitDir)
+	if !com.IsFile(filepath.Join(gitDir, "HEAD")) {
+		return nil, errors.InvalidRepoArchive{Reason: "Git data is missing"}
+	}
+	for _, name := range []string{"objects", "refs"} {
+		if err = os.MkdirAll(filepath.Join(gitDir, name), os.ModePerm); err != nil {
+			return nil, err
+		}
+	}
+
+	name := opts.Name
+	if name == "" {
+		name = info.Name
+	}
+	repo, err := MigrateRepository(doer, owner, MigrateRepoOptions{
+		Name:        name,
+		Description: info.Description,
+		IsPrivate:   opts.IsPrivate,
+		RemoteAddr:  gitDir,
+		Migration: MigrationOptions{
+			Service:      MIGRATION_SERVICE_ARCHIVE,
+			Issues:       true,
+			PullRequests: true,
+			Releases:     true,
+		},
+	})
+	if err != nil {
+		return repo, err
+	}
+
+	if info.Website != "" {
+		repo.Website = info.Website
+		if err = UpdateRepository(repo, false); err != nil {
+			return repo, fmt.Errorf("UpdateRepository: %v", err)
+		}
+	}
+
+	log.Trace("Repository imported from archive exported by Gogs %s: %s/%s", info.GogsVersion, owner.Name, repo.Name)
+	return repo, nil
+}
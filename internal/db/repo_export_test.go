@@ -0,0 +1,43 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_isRepoArchiveEntry(t *testing.T) {
+	Convey("Check entries of repository archive", t, func() {
+		testCases := []struct {
+			name   string
+			expect bool
+		}{
+			{"metadata.json", true},
+			{"issues.json", true},
+			{"repository.git/HEAD", true},
+			{"repository.git/packed-refs", true},
+			{"repository.git/refs", true},
+			{"repository.git/refs/heads/master", true},
+			{"repository.git/objects/pack/pack-1.pack", true},
+			{"repository.wiki.git/objects/info", true},
+
+			{"", false},
+			{"unknown.json", false},
+			{"repository.git", false},
+			{"repository.git/config", false},
+			{"repository.git/hooks/pre-receive", false},
+			{"repository.git/HEAD/x", false},
+			{"repository.git/refs/../config", false},
+			{"other.git/refs/heads/master", false},
+			{"../repository.git/HEAD", false},
+			{"/repository.git/HEAD", false},
+		}
+		for _, tc := range testCases {
+			So(isRepoArchiveEntry(tc.name), ShouldEqual, tc.expect)
+		}
+	})
+}
@@ -0,0 +1,299 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/unknwon/com"
+	log "unknwon.dev/clog/v2"
+	"xorm.io/xorm"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/process"
+	"gogs.io/gogs/internal/sync"
+)
+
+// RepoGC is the status of garbage collection of a repository.
+type RepoGC struct {
+	ID     int64
+	RepoID int64 `xorm:"UNIQUE"`
+	// IsAutoDisabled indicates whether the repository is skipped by scheduled garbage collection.
+	IsAutoDisabled bool `xorm:"NOT NULL DEFAULT false"`
+
+	// Number of loose objects and packs found by the last check.
+	LooseObjects int64
+	Packs        int64
+	CheckedUnix  int64
+
+	// Status of the last run.
+	Command     string
+	IsManual    bool
+	LastRun     time.Time `xorm:"-" json:"-"`
+	LastRunUnix int64
+	DurationMS  int64
+	SizeBefore  int64 // Size of objects in bytes
+	SizeAfter   int64
+	LastError   string `xorm:"TEXT"`
+}
+
+func (gc *RepoGC) AfterSet(colName string, _ xorm.Cell) {
+	switch colName {
+	case "last_run_unix":
+		gc.LastRun = time.Unix(gc.LastRunUnix, 0).Local()
+	}
+}
+
+// Duration returns the duration of the last run.
+func (gc *RepoGC) Duration() time.Duration {
+	return time.Duration(gc.DurationMS) * time.Millisecond
+}
+
+// GetRepoGC returns the status of garbage collection of the repository, a status
+// with zero values is returned if garbage collection has never been run.
+func GetRepoGC(repoID int64) (*RepoGC, error) {
+	gc := &RepoGC{RepoID: repoID}
+	if _, err := x.Where("repo_id = ?", repoID).Get(gc); err != nil {
+		return nil, err
+	}
+	return gc, nil
+}
+
+// saveRepoGC inserts or updates the status of garbage collection.
+func saveRepoGC(gc *RepoGC) (err error) {
+	if gc.ID == 0 {
+		_, err = x.Insert(gc)
+	} else {
+		_, err = x.ID(gc.ID).AllCols().Update(gc)
+	}
+	return err
+}
+
+// SetRepoAutoGC enables or disables scheduled garbage collection of the repository.
+func SetRepoAutoGC(repoID int64, enabled bool) error {
+	gc, err := GetRepoGC(repoID)
+	if err != nil {
+		return fmt.Errorf("GetRepoGC: %v", err)
+	}
+	gc.IsAutoDisabled = !enabled
+	return saveRepoGC(gc)
+}
+
+// gitObjectStats contains statistics of objects of a repository reported by
+// "git count-objects -v".
+type gitObjectStats struct {
+	Count    int64 // Number of loose objects
+	SizeKB   int64 // Disk space consumed by loose objects
+	InPack   int64 // Number of in-pack objects
+	Packs    int64 // Number of packs
+	SizePack int64 // Disk space consumed by packs
+}
+
+// Size returns disk space consumed by loose objects and packs in bytes.
+func (s *gitObjectStats) Size() int64 {
+	return (s.SizeKB + s.SizePack) * 1024
+}
+
+// parseCountObjects parses output of "git count-objects -v".
+func parseCountObjects(output string) (*gitObjectStats, error) {
+	stats := new(gitObjectStats)
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), ":", 2)
+		if len(fields) != 2 {
+			continue
+		}
+
+		var v *int64
+		switch fields[0] {
+		case "count":
+			v = &stats.Count
+		case "size":
+			v = &stats.SizeKB
+		case "in-pack":
+			v = &stats.InPack
+		case "packs":
+			v = &stats.Packs
+		case "size-pack":
+			v = &stats.SizePack
+		default:
+			continue
+		}
+
+		n, err := strconv.ParseInt(strings.TrimSpace(fields[1]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse %q: %v", fields[0], err)
+		}
+		*v = n
+	}
+	return stats, scanner.Err()
+}
+
+// countObjects returns statistics of objects of the repository.
+func countObjects(repoPath string) (*gitObjectStats, error) {
+	stdout, stderr, err := process.ExecDir(-1, repoPath, "Count objects", "git", "count-objects", "-v")
+	if err != nil {
+		return nil, fmt.Errorf("%v: %s", err, stderr)
+	}
+	return parseCountObjects(stdout)
+}
+
+// needsGC returns true if the repository has enough loose objects or packs, or it
+// has not been collected for longer than configured max age.
+func needsGC(stats *gitObjectStats, gc *RepoGC, now time.Time) bool {
+	opts := conf.Cron.RepoGC
+	switch {
+	case opts.LooseObjects > 0 && stats.Count >= opts.LooseObjects,
+		opts.Packs > 0 && stats.Packs >= opts.Packs:
+		return true
+	case opts.MaxAge > 0 && stats.Count+stats.Packs > 0:
+		return now.Sub(time.Unix(gc.LastRunUnix, 0)) >= opts.MaxAge
+	}
+	return false
+}
+
+// gcArgs returns arguments of Git command to optimize the repository. When there are
+// only too many packs, they are consolidated without the expensive full gc.
+func gcArgs(stats *gitObjectStats) []string {
+	opts := conf.Cron.RepoGC
+	if stats != nil && stats.Count < opts.LooseObjects && opts.Packs > 0 && stats.Packs >= opts.Packs {
+		return []string{"repack", "-d", "-l"}
+	}
+	return append([]string{"gc"}, conf.Git.GCArgs...)
+}
+
+var repoGCStatusTable = sync.NewStatusTable()
+
+// IsRepoGCRunning returns true if garbage collection of the repository is running.
+func IsRepoGCRunning(repoID int64) bool {
+	return repoGCStatusTable.IsRunning(com.ToStr(repoID))
+}
+
+// runRepoGC runs garbage collection of the repository and records the status. The
+// stats are nil for manual runs, which always do a full gc.
+func runRepoGC(repo *Repository, gc *RepoGC, stats *gitObjectStats) error {
+	key := com.ToStr(repo.ID)
+	if repoGCStatusTable.IsRunning(key) {
+		return nil
+	}
+	repoGCStatusTable.Start(key)
+	defer repoGCStatusTable.Stop(key)
+
+	repoPath := repo.RepoPath()
+	gc.IsManual = stats == nil
+	args := gcArgs(stats)
+	if gc.IsManual {
+		var err error
+		if stats, err = countObjects(repoPath); err != nil {
+			return fmt.Errorf("count objects: %v", err)
+		}
+	}
+	gc.Command = "git " + strings.Join(args, " ")
+	gc.SizeBefore = stats.Size()
+
+	start := time.Now()
+	_, stderr, err := process.ExecDir(
+		time.Duration(conf.Git.Timeout.GC)*time.Second,
+		repoPath, fmt.Sprintf("Repository garbage collection: %s", repoPath),
+		"git", args...)
+	gc.LastRunUnix = start.Unix()
+	gc.LastRun = start
+	gc.DurationMS = int64(time.Since(start) / time.Millisecond)
+	gc.LastError = ""
+	if err != nil {
+		err = fmt.Errorf("%v: %s", err, stderr)
+		gc.LastError = err.Error()
+	}
+
+	if after, err := countObjects(repoPath); err != nil {
+		log.Error("Failed to count objects after garbage collection [repo_id: %d]: %v", repo.ID, err)
+	} else {
+		gc.LooseObjects = after.Count
+		gc.Packs = after.Packs
+		gc.SizeAfter = after.Size()
+	}
+	gc.CheckedUnix = time.Now().Unix()
+
+	if err := saveRepoGC(gc); err != nil {
+		log.Error("Failed to save status of garbage collection [repo_id: %d]: %v", repo.ID, err)
+	}
+	return err
+}
+
+// RunGC runs garbage collection of the repository immediately.
+func (repo *Repository) RunGC() error {
+	gc, err := GetRepoGC(repo.ID)
+	if err != nil {
+		return fmt.Errorf("GetRepoGC: %v", err)
+	}
+	return runRepoGC(repo, gc, nil)
+}
+
+// ScheduledRepoGC runs garbage collection of repositories that need it, which is
+// decided by numbers of loose objects and packs and time since the last run.
+func ScheduledRepoGC() {
+	if taskStatusTable.IsRunning(_REPO_GC) {
+		return
+	}
+	taskStatusTable.Start(_REPO_GC)
+	defer taskStatusTable.Stop(_REPO_GC)
+
+	log.Trace("Doing: ScheduledRepoGC")
+
+	now := time.Now()
+	repos := make([]*Repository, 0, 10)
+	for start := 0; ; start += 100 {
+		repos = repos[:0]
+		if err := x.Where("id > 0").Asc("id").Limit(100, start).Find(&repos); err != nil {
+			log.Error("Failed to find repositories: %v", err)
+			return
+		}
+		if len(repos) == 0 {
+			break
+		}
+
+		for _, repo := range repos {
+			gc, err := GetRepoGC(repo.ID)
+			if err != nil {
+				log.Error("GetRepoGC [repo_id: %d]: %v", repo.ID, err)
+				continue
+			} else if gc.IsAutoDisabled {
+				continue
+			}
+
+			if err = repo.GetOwner(); err != nil {
+				log.Error("GetOwner [repo_id: %d]: %v", repo.ID, err)
+				continue
+			}
+			stats, err := countObjects(repo.RepoPath())
+			if err != nil {
+				log.Error("Failed to count objects [repo_id: %d]: %v", repo.ID, err)
+				continue
+			}
+
+			if !needsGC(stats, gc, now) {
+				gc.LooseObjects = stats.Count
+				gc.Packs = stats.Packs
+				gc.CheckedUnix = now.Unix()
+				if err = saveRepoGC(gc); err != nil {
+					log.Error("Failed to save status of garbage collection [repo_id: %d]: %v", repo.ID, err)
+				}
+				continue
+			}
+
+			if err = runRepoGC(repo, gc, stats); err != nil {
+				desc := fmt.Sprintf("Failed to perform garbage collection on repository '%s': %v", repo.RepoPath(), err)
+				log.Warn(desc)
+				if err = CreateRepositoryNotice(desc); err != nil {
+					log.Error("CreateRepositoryNotice: %v", err)
+				}
+			}
+		}
+	}
+}
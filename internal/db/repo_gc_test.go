@@ -0,0 +1,65 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"gogs.io/gogs/internal/conf"
+)
+
+func Test_parseCountObjects(t *testing.T) {
+	Convey("Parse output of git count-objects", t, func() {
+		stats, err := parseCountObjects(`count: 12
+size: 48
+in-pack: 3401
+packs: 2
+size-pack: 1024
+prune-packable: 0
+garbage: 0
+size-garbage: 0
+`)
+		So(err, ShouldBeNil)
+		So(*stats, ShouldResemble, gitObjectStats{
+			Count:    12,
+			SizeKB:   48,
+			InPack:   3401,
+			Packs:    2,
+			SizePack: 1024,
+		})
+
+		_, err = parseCountObjects("count: many\n")
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func Test_needsGC(t *testing.T) {
+	Convey("Decide whether a repository needs garbage collection", t, func() {
+		opts := conf.Cron.RepoGC
+		defer func() { conf.Cron.RepoGC = opts }()
+		conf.Cron.RepoGC.LooseObjects = 100
+		conf.Cron.RepoGC.Packs = 10
+		conf.Cron.RepoGC.MaxAge = 24 * time.Hour
+
+		now := time.Now()
+		recent := &RepoGC{LastRunUnix: now.Add(-time.Hour).Unix()}
+		stale := &RepoGC{LastRunUnix: now.Add(-48 * time.Hour).Unix()}
+
+		So(needsGC(&gitObjectStats{Count: 100}, recent, now), ShouldBeTrue)
+		So(needsGC(&gitObjectStats{Packs: 10}, recent, now), ShouldBeTrue)
+		So(needsGC(&gitObjectStats{Count: 5, Packs: 1}, recent, now), ShouldBeFalse)
+		So(needsGC(&gitObjectStats{Count: 5, Packs: 1}, stale, now), ShouldBeTrue)
+		So(needsGC(&gitObjectStats{}, stale, now), ShouldBeFalse)
+
+		Convey("Only repack when there are too many packs", func() {
+			So(gcArgs(&gitObjectStats{Count: 5, Packs: 10}), ShouldResemble, []string{"repack", "-d", "-l"})
+			So(gcArgs(&gitObjectStats{Count: 100, Packs: 10})[0], ShouldEqual, "gc")
+			So(gcArgs(nil)[0], ShouldEqual, "gc")
+		})
+	})
+}
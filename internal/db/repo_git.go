@@ -0,0 +1,161 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gogs/git-module"
+)
+
+// RevParseObject returns the ID of the object of given type that the revision points
+// to, the revision is peeled until an object of the type is found. It returns
+// git.ErrNotExist if there is no such object.
+func RevParseObject(repoPath, rev string, typ git.ObjectType) (string, error) {
+	// Do not let the revision be taken as an option.
+	if rev == "" || strings.HasPrefix(rev, "-") {
+		return "", git.ErrNotExist{ID: rev}
+	}
+
+	stdout, err := git.NewCommand("rev-parse", "--verify", "--quiet", rev+"^{"+string(typ)+"}").RunInDir(repoPath)
+	if err != nil {
+		if strings.Contains(err.Error(), "exit status 1") {
+			return "", git.ErrNotExist{ID: rev}
+		}
+		return "", err
+	}
+	return strings.TrimSpace(stdout), nil
+}
+
+// GitTreeEntry is an entry of a Git tree.
+type GitTreeEntry struct {
+	Mode string
+	Type git.ObjectType
+	ID   string
+	// Size is only available for blobs.
+	Size int64
+	Path string
+}
+
+// parseGitTreeEntries parses the output of "git ls-tree -l -z", each entry is in
+// format of "<mode> <type> <object> <size>\t<path>\x00".
+func parseGitTreeEntries(data []byte) ([]*GitTreeEntry, error) {
+	entries := make([]*GitTreeEntry, 0, bytes.Count(data, []byte{0}))
+	for _, line := range bytes.Split(data, []byte{0}) {
+		if len(line) == 0 {
+			continue
+		}
+
+		tab := bytes.IndexByte(line, '\t')
+		if tab < 0 {
+			return nil, fmt.Errorf("malformed entry %q", line)
+		}
+		fields := strings.Fields(string(line[:tab]))
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("malformed entry %q", line)
+		}
+
+		entry := &GitTreeEntry{
+			Mode: fields[0],
+			Type: git.ObjectType(fields[1]),
+			ID:   fields[2],
+			Path: string(line[tab+1:]),
+		}
+		if fields[3] != "-" {
+			size, err := strconv.ParseInt(fields[3], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("malformed size of entry %q: %v", line, err)
+			}
+			entry.Size = size
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// GetGitTree returns the ID of the tree that the revision points to and its entries.
+// Entries of all subtrees are also returned when recursive.
+func GetGitTree(repoPath, rev string, recursive bool) (string, []*GitTreeEntry, error) {
+	treeID, err := RevParseObject(repoPath, rev, git.ObjectTree)
+	if err != nil {
+		return "", nil, err
+	}
+
+	args := []string{"ls-tree", "-l", "-z"}
+	if recursive {
+		args = append(args, "-r", "-t")
+	}
+	stdout, err := git.NewCommand(append(args, treeID)...).RunInDirBytes(repoPath)
+	if err != nil {
+		return "", nil, fmt.Errorf("list tree: %v", err)
+	}
+
+	entries, err := parseGitTreeEntries(stdout)
+	if err != nil {
+		return "", nil, err
+	}
+	return treeID, entries, nil
+}
+
+// GetGitBlob returns the full ID and content of the blob that the revision points to.
+func GetGitBlob(repoPath, rev string) (string, []byte, error) {
+	blobID, err := RevParseObject(repoPath, rev, git.ObjectBlob)
+	if err != nil {
+		return "", nil, err
+	}
+
+	stdout, err := git.NewCommand("cat-file", "blob", blobID).RunInDirBytes(repoPath)
+	if err != nil {
+		return "", nil, fmt.Errorf("read blob: %v", err)
+	}
+	return blobID, stdout, nil
+}
+
+// GitRef is a reference of a Git repository.
+type GitRef struct {
+	Name       string
+	ObjectType git.ObjectType
+	ObjectID   string
+}
+
+// parseGitRefs parses the output of "git for-each-ref" in the format of
+// "%(objectname) %(objecttype) %(refname)".
+func parseGitRefs(data []byte) ([]*GitRef, error) {
+	refs := make([]*GitRef, 0, bytes.Count(data, []byte{'\n'}))
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			continue
+		}
+
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("malformed reference %q", line)
+		}
+		refs = append(refs, &GitRef{
+			Name:       fields[2],
+			ObjectType: git.ObjectType(fields[1]),
+			ObjectID:   fields[0],
+		})
+	}
+	return refs, nil
+}
+
+// GetGitRefs returns references of the repository, only references under the path
+// are returned if the path is not empty, e.g. "heads" or "tags/v1.0".
+func GetGitRefs(repoPath, path string) ([]*GitRef, error) {
+	pattern := "refs"
+	if path != "" {
+		pattern += "/" + strings.Trim(path, "/")
+	}
+
+	stdout, err := git.NewCommand("for-each-ref", "--format=%(objectname) %(objecttype) %(refname)", pattern).RunInDirBytes(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("list references: %v", err)
+	}
+	return parseGitRefs(stdout)
+}
@@ -0,0 +1,54 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/gogs/git-module"
+)
+
+func Test_parseGitTreeEntries(t *testing.T) {
+	Convey("Parse entries of a Git tree", t, func() {
+		entries, err := parseGitTreeEntries([]byte("100644 blob 2e65efe2a145dda7ee51d1741299f848e5bf752e      12\tREADME.md\x00" +
+			"040000 tree 9bdf2d5e5c7d7a5e8b8b4e6e5e8ef1d8e0d5f1a2       -\tdocs\x00" +
+			"160000 commit 7c35a3ce607a14953f070f0f83b5d74c2296ef93       -\tvendor/lib with space\x00"))
+		So(err, ShouldBeNil)
+		So(entries, ShouldHaveLength, 3)
+
+		So(entries[0].Mode, ShouldEqual, "100644")
+		So(entries[0].Type, ShouldEqual, git.ObjectBlob)
+		So(entries[0].ID, ShouldEqual, "2e65efe2a145dda7ee51d1741299f848e5bf752e")
+		So(entries[0].Size, ShouldEqual, 12)
+		So(entries[0].Path, ShouldEqual, "README.md")
+
+		So(entries[1].Type, ShouldEqual, git.ObjectTree)
+		So(entries[1].Size, ShouldEqual, 0)
+		So(entries[2].Type, ShouldEqual, git.ObjectCommit)
+		So(entries[2].Path, ShouldEqual, "vendor/lib with space")
+
+		_, err = parseGitTreeEntries([]byte("100644 blob README.md\x00"))
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func Test_parseGitRefs(t *testing.T) {
+	Convey("Parse references of a Git repository", t, func() {
+		refs, err := parseGitRefs([]byte("2e65efe2a145dda7ee51d1741299f848e5bf752e commit refs/heads/master\n" +
+			"9bdf2d5e5c7d7a5e8b8b4e6e5e8ef1d8e0d5f1a2 tag refs/tags/v1.0\n"))
+		So(err, ShouldBeNil)
+		So(refs, ShouldHaveLength, 2)
+		So(refs[0].Name, ShouldEqual, "refs/heads/master")
+		So(refs[0].ObjectType, ShouldEqual, git.ObjectCommit)
+		So(refs[0].ObjectID, ShouldEqual, "2e65efe2a145dda7ee51d1741299f848e5bf752e")
+		So(refs[1].Name, ShouldEqual, "refs/tags/v1.0")
+		So(refs[1].ObjectType, ShouldEqual, git.ObjectTag)
+
+		_, err = parseGitRefs([]byte("malformed\n"))
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,459 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/unknwon/com"
+	log "unknwon.dev/clog/v2"
+	"xorm.io/xorm"
+
+	"github.com/gogs/git-module"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/process"
+	"gogs.io/gogs/internal/sync"
+)
+
+// GraphStatsQueue is the queue of IDs of repositories to compute graph statistics for.
+var GraphStatsQueue = sync.NewUniqueQueue(1000)
+
+const (
+	// maxGraphContributors is the max number of contributors kept in graph statistics.
+	maxGraphContributors = 100
+	// commitActivityWeeks is the number of recent weeks covered by commit activity.
+	commitActivityWeeks = 52
+)
+
+// ContributorWeek is the activity of a contributor in a week.
+type ContributorWeek struct {
+	Week      int64 `json:"w"` // Unix timestamp of the start of the week
+	Additions int   `json:"a"`
+	Deletions int   `json:"d"`
+	Commits   int   `json:"c"`
+}
+
+// ContributorStats is the activity of a contributor, who is identified by email.
+// Weeks without any commit are omitted.
+type ContributorStats struct {
+	Name      string             `json:"name"`
+	Email     string             `json:"email"`
+	Total     int                `json:"total"`
+	Additions int                `json:"additions"`
+	Deletions int                `json:"deletions"`
+	Weeks     []*ContributorWeek `json:"weeks"`
+}
+
+// CommitActivity is the number of commits in each day of a week, starting on Sunday.
+type CommitActivity struct {
+	Week  int64  `json:"week"`
+	Total int    `json:"total"`
+	Days  [7]int `json:"days"`
+}
+
+// CodeFrequency is the number of lines added and deleted in a week.
+type CodeFrequency struct {
+	Week      int64 `json:"week"`
+	Additions int   `json:"additions"`
+	Deletions int   `json:"deletions"`
+}
+
+// RepoGraphStats caches statistics of commits on the default branch of a repository,
+// which are saved in JSON.
+type RepoGraphStats struct {
+	ID       int64
+	RepoID   int64  `xorm:"UNIQUE"`
+	CommitID string `xorm:"VARCHAR(40)"` // The commit that statistics are computed at
+
+	Contributors   string `xorm:"LONGTEXT"`
+	CommitActivity string `xorm:"TEXT"`
+	CodeFrequency  string `xorm:"LONGTEXT"`
+
+	Updated     time.Time `xorm:"-" json:"-"`
+	UpdatedUnix int64
+}
+
+func (s *RepoGraphStats) AfterSet(colName string, _ xorm.Cell) {
+	switch colName {
+	case "updated_unix":
+		s.Updated = time.Unix(s.UpdatedUnix, 0).Local()
+	}
+}
+
+// GetContributors returns contributors in descending order of number of commits.
+func (s *RepoGraphStats) GetContributors() ([]*ContributorStats, error) {
+	contributors := make([]*ContributorStats, 0, 10)
+	return contributors, json.Unmarshal([]byte(s.Contributors), &contributors)
+}
+
+// GetCommitActivity returns commit activity of recent weeks in chronological order.
+func (s *RepoGraphStats) GetCommitActivity() ([]*CommitActivity, error) {
+	activity := make([]*CommitActivity, 0, commitActivityWeeks)
+	return activity, json.Unmarshal([]byte(s.CommitActivity), &activity)
+}
+
+// GetCodeFrequency returns code frequency of all weeks in chronological order.
+func (s *RepoGraphStats) GetCodeFrequency() ([]*CodeFrequency, error) {
+	frequency := make([]*CodeFrequency, 0, 10)
+	return frequency, json.Unmarshal([]byte(s.CodeFrequency), &frequency)
+}
+
+// weekStart returns the start of the week in UTC that the time is in, weeks start
+// on Sunday.
+func weekStart(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return day.AddDate(0, 0, -int(day.Weekday()))
+}
+
+// isStale returns true if statistics were computed at a different commit, or in a
+// previous week so that commit activity no longer covers recent weeks.
+func (s *RepoGraphStats) isStale(commitID string, now time.Time) bool {
+	return s.CommitID != commitID || weekStart(time.Unix(s.UpdatedUnix, 0)) != weekStart(now)
+}
+
+// graphCommit is a commit parsed from output of "git log --numstat".
+type graphCommit struct {
+	Name      string
+	Email     string
+	CoAuthors []*CommitCoAuthor
+	Time      time.Time
+	Additions int
+	Deletions int
+}
+
+// graphLogFormat lists values of "Co-authored-by" trailers separated by "\x1d" after
+// the author, the placeholder is not expanded by Git versions that do not support
+// these options and no co-author is found then.
+const graphLogFormat = "--format=\x1e%at\x1f%aN\x1f%aE\x1f%(trailers:key=Co-authored-by,valueonly,separator=%x1d)"
+
+// parseGraphCoAuthors returns co-authors in values of "Co-authored-by" trailers
+// separated by "\x1d".
+func parseGraphCoAuthors(values, authorEmail string) []*CommitCoAuthor {
+	if values == "" {
+		return nil
+	}
+
+	trailers := strings.Split(values, "\x1d")
+	for i := range trailers {
+		trailers[i] = "Co-authored-by: " + trailers[i]
+	}
+	return parseCoAuthors(strings.Join(trailers, "\n"), authorEmail)
+}
+
+// parseGraphLog parses output of "git log --numstat" in graphLogFormat, and calls fn
+// for each commit in the order of output.
+func parseGraphLog(r io.Reader, fn func(c *graphCommit)) error {
+	var c *graphCommit
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "\x1e") {
+			if c != nil {
+				fn(c)
+			}
+
+			fields := strings.SplitN(line[1:], "\x1f", 4)
+			if len(fields) < 3 {
+				return fmt.Errorf("invalid commit line: %q", line)
+			}
+			unix, err := strconv.ParseInt(fields[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("parse time %q: %v", fields[0], err)
+			}
+			c = &graphCommit{
+				Name:  fields[1],
+				Email: fields[2],
+				Time:  time.Unix(unix, 0),
+			}
+			if len(fields) == 4 {
+				c.CoAuthors = parseGraphCoAuthors(fields[3], c.Email)
+			}
+			continue
+		}
+
+		// Lines of numstat are "<additions>\t<deletions>\t<path>", both numbers are "-"
+		// for binary files.
+		fields := strings.SplitN(line, "\t", 3)
+		if c == nil || len(fields) != 3 {
+			continue
+		}
+		additions, _ := strconv.Atoi(fields[0])
+		deletions, _ := strconv.Atoi(fields[1])
+		c.Additions += additions
+		c.Deletions += deletions
+	}
+	if c != nil {
+		fn(c)
+	}
+	return scanner.Err()
+}
+
+// graphStats accumulates commits into statistics.
+type graphStats struct {
+	now            time.Time
+	contributors   map[string]*ContributorStats
+	contributorWks map[string]map[int64]*ContributorWeek
+	activity       map[int64]*CommitActivity
+	frequency      map[int64]*CodeFrequency
+}
+
+func newGraphStats(now time.Time) *graphStats {
+	s := &graphStats{
+		now:            now,
+		contributors:   make(map[string]*ContributorStats),
+		contributorWks: make(map[string]map[int64]*ContributorWeek),
+		activity:       make(map[int64]*CommitActivity, commitActivityWeeks),
+		frequency:      make(map[int64]*CodeFrequency),
+	}
+	for t := weekStart(now).AddDate(0, 0, -7*(commitActivityWeeks-1)); !t.After(now); t = t.AddDate(0, 0, 7) {
+		s.activity[t.Unix()] = &CommitActivity{Week: t.Unix()}
+	}
+	return s
+}
+
+// addContributor credits the commit to the contributor of the email.
+func (s *graphStats) addContributor(name, email string, week int64, c *graphCommit) {
+	key := strings.ToLower(email)
+	contributor := s.contributors[key]
+	if contributor == nil {
+		// Commits are listed in reverse chronological order, so the name is the latest.
+		contributor = &ContributorStats{
+			Name:  name,
+			Email: email,
+		}
+		s.contributors[key] = contributor
+		s.contributorWks[key] = make(map[int64]*ContributorWeek)
+	}
+	contributor.Total++
+	contributor.Additions += c.Additions
+	contributor.Deletions += c.Deletions
+	cw := s.contributorWks[key][week]
+	if cw == nil {
+		cw = &ContributorWeek{Week: week}
+		s.contributorWks[key][week] = cw
+	}
+	cw.Commits++
+	cw.Additions += c.Additions
+	cw.Deletions += c.Deletions
+}
+
+// add adds the commit to statistics, the commit is credited to the author and each
+// of co-authors as contributors, but only counted once in commit activity and code
+// frequency.
+func (s *graphStats) add(c *graphCommit) {
+	week := weekStart(c.Time).Unix()
+
+	s.addContributor(c.Name, c.Email, week, c)
+	for _, coAuthor := range c.CoAuthors {
+		s.addContributor(coAuthor.Name, coAuthor.Email, week, c)
+	}
+
+	if a := s.activity[week]; a != nil {
+		a.Total++
+		a.Days[c.Time.UTC().Weekday()]++
+	}
+
+	f := s.frequency[week]
+	if f == nil {
+		f = &CodeFrequency{Week: week}
+		s.frequency[week] = f
+	}
+	f.Additions += c.Additions
+	f.Deletions += c.Deletions
+}
+
+// results returns contributors, commit activity and code frequency computed from
+// commits have been added.
+func (s *graphStats) results() ([]*ContributorStats, []*CommitActivity, []*CodeFrequency) {
+	contributors := make([]*ContributorStats, 0, len(s.contributors))
+	for key, c := range s.contributors {
+		c.Weeks = make([]*ContributorWeek, 0, len(s.contributorWks[key]))
+		for _, w := range s.contributorWks[key] {
+			c.Weeks = append(c.Weeks, w)
+		}
+		sort.Slice(c.Weeks, func(i, j int) bool { return c.Weeks[i].Week < c.Weeks[j].Week })
+		contributors = append(contributors, c)
+	}
+	sort.Slice(contributors, func(i, j int) bool {
+		if contributors[i].Total != contributors[j].Total {
+			return contributors[i].Total > contributors[j].Total
+		}
+		return contributors[i].Email < contributors[j].Email
+	})
+	if len(contributors) > maxGraphContributors {
+		contributors = contributors[:maxGraphContributors]
+	}
+
+	activity := make([]*CommitActivity, 0, commitActivityWeeks)
+	for t := weekStart(s.now).AddDate(0, 0, -7*(commitActivityWeeks-1)); !t.After(s.now); t = t.AddDate(0, 0, 7) {
+		activity = append(activity, s.activity[t.Unix()])
+	}
+
+	frequency := make([]*CodeFrequency, 0, len(s.frequency))
+	if len(s.frequency) > 0 {
+		first, last := int64(-1), int64(-1)
+		for week := range s.frequency {
+			if first == -1 || week < first {
+				first = week
+			}
+			if week > last {
+				last = week
+			}
+		}
+		for t := time.Unix(first, 0).UTC(); t.Unix() <= last; t = t.AddDate(0, 0, 7) {
+			f := s.frequency[t.Unix()]
+			if f == nil {
+				f = &CodeFrequency{Week: t.Unix()}
+			}
+			frequency = append(frequency, f)
+		}
+	}
+	return contributors, activity, frequency
+}
+
+// computeGraphStats computes graph statistics of commits reachable from the commit.
+func computeGraphStats(repoPath, commitID string, now time.Time) (*graphStats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(conf.Git.Timeout.Stats)*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", "log", "--no-merges", "--numstat", graphLogFormat, commitID)
+	cmd.Dir = repoPath
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err = cmd.Start(); err != nil {
+		return nil, err
+	}
+	pid := process.Add(fmt.Sprintf("ComputeGraphStats: %s", repoPath), cmd)
+	defer process.Remove(pid)
+
+	stats := newGraphStats(now)
+	if err = parseGraphLog(stdout, stats.add); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return nil, fmt.Errorf("parse log: %v", err)
+	}
+	if err = cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return nil, process.ErrExecTimeout
+		}
+		return nil, err
+	}
+	return stats, nil
+}
+
+// defaultBranchCommitID returns ID of the latest commit on the default branch.
+func (repo *Repository) defaultBranchCommitID() (string, error) {
+	gitRepo, err := git.OpenRepository(repo.RepoPath())
+	if err != nil {
+		return "", fmt.Errorf("OpenRepository: %v", err)
+	}
+	commitID, err := gitRepo.GetBranchCommitID(repo.DefaultBranch)
+	if err != nil {
+		return "", fmt.Errorf("GetBranchCommitID: %v", err)
+	}
+	return commitID, nil
+}
+
+// GenerateGraphStats computes and saves graph statistics of commits on the default
+// branch of the repository.
+func (repo *Repository) GenerateGraphStats() error {
+	commitID, err := repo.defaultBranchCommitID()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	stats, err := computeGraphStats(repo.RepoPath(), commitID, now)
+	if err != nil {
+		return fmt.Errorf("computeGraphStats: %v", err)
+	}
+	contributors, activity, frequency := stats.results()
+
+	gs := &RepoGraphStats{RepoID: repo.ID}
+	if _, err = x.Get(gs); err != nil {
+		return err
+	}
+	gs.CommitID = commitID
+	gs.UpdatedUnix = now.Unix()
+	for _, v := range []struct {
+		field *string
+		value interface{}
+	}{
+		{&gs.Contributors, contributors},
+		{&gs.CommitActivity, activity},
+		{&gs.CodeFrequency, frequency},
+	} {
+		data, err := json.Marshal(v.value)
+		if err != nil {
+			return err
+		}
+		*v.field = string(data)
+	}
+
+	if gs.ID == 0 {
+		_, err = x.Insert(gs)
+	} else {
+		_, err = x.ID(gs.ID).AllCols().Update(gs)
+	}
+	return err
+}
+
+// GetRepoGraphStats returns cached graph statistics of the repository, it returns nil
+// if statistics have never been computed. Computation is queued when statistics are
+// missing or out of date.
+func GetRepoGraphStats(repo *Repository) (*RepoGraphStats, error) {
+	gs := &RepoGraphStats{RepoID: repo.ID}
+	has, err := x.Get(gs)
+	if err != nil {
+		return nil, err
+	}
+
+	commitID, err := repo.defaultBranchCommitID()
+	if err != nil {
+		return nil, err
+	}
+	if !has || gs.isStale(commitID, time.Now()) {
+		go GraphStatsQueue.Add(repo.ID)
+	}
+	if !has {
+		return nil, nil
+	}
+	return gs, nil
+}
+
+// RunGraphStats computes graph statistics of queued repositories.
+func RunGraphStats() {
+	for repoID := range GraphStatsQueue.Queue() {
+		log.Trace("RunGraphStats [repo_id: %s]", repoID)
+
+		repo, err := GetRepositoryByID(com.StrTo(repoID).MustInt64())
+		if err != nil {
+			log.Error("GetRepositoryByID [%s]: %v", repoID, err)
+		} else if err = repo.GenerateGraphStats(); err != nil {
+			log.Error("Failed to generate graph statistics [repo_id: %d]: %v", repo.ID, err)
+		}
+		// Removes after computing so that requests in the meantime do not queue again.
+		GraphStatsQueue.Remove(repoID)
+	}
+}
+
+// InitGraphStats starts computing graph statistics of queued repositories.
+func InitGraphStats() {
+	go RunGraphStats()
+}
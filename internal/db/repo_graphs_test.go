@@ -0,0 +1,134 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_weekStart(t *testing.T) {
+	Convey("Get start of the week", t, func() {
+		sunday := time.Date(2020, 6, 7, 0, 0, 0, 0, time.UTC)
+		So(weekStart(sunday), ShouldEqual, sunday)
+		So(weekStart(time.Date(2020, 6, 10, 15, 4, 5, 0, time.UTC)), ShouldEqual, sunday)
+		So(weekStart(time.Date(2020, 6, 13, 23, 59, 59, 0, time.UTC)), ShouldEqual, sunday)
+		So(weekStart(time.Date(2020, 6, 14, 0, 0, 0, 0, time.UTC)), ShouldEqual, sunday.AddDate(0, 0, 7))
+	})
+}
+
+func Test_parseGraphLog(t *testing.T) {
+	Convey("Parse output of git log", t, func() {
+		log := "\x1e1591801445\x1fAlice\x1falice@example.com\n" +
+			"\n" +
+			"3\t1\tREADME.md\n" +
+			"-\t-\tlogo.png\n" +
+			"10\t0\tmain.go\n" +
+			"\x1e1591196645\x1fBob\x1fbob@example.com\n" +
+			"\x1e1591110245\x1fAlice\x1fALICE@example.com\n" +
+			"\n" +
+			"0\t5\tmain.go\n"
+
+		var commits []*graphCommit
+		So(parseGraphLog(strings.NewReader(log), func(c *graphCommit) {
+			commits = append(commits, c)
+		}), ShouldBeNil)
+		So(commits, ShouldHaveLength, 3)
+		So(commits[0].Name, ShouldEqual, "Alice")
+		So(commits[0].Email, ShouldEqual, "alice@example.com")
+		So(commits[0].Time.Unix(), ShouldEqual, 1591801445)
+		So(commits[0].Additions, ShouldEqual, 13)
+		So(commits[0].Deletions, ShouldEqual, 1)
+		So(commits[1].Additions, ShouldEqual, 0)
+		So(commits[2].Deletions, ShouldEqual, 5)
+
+		So(parseGraphLog(strings.NewReader("\x1ebad\n"), func(*graphCommit) {}), ShouldNotBeNil)
+	})
+}
+
+func Test_parseGraphLog_coAuthors(t *testing.T) {
+	Convey("Parse co-authors in output of git log", t, func() {
+		log := "\x1e1591801445\x1fAlice\x1falice@example.com\x1fBob <bob@example.com>\x1dAlice <ALICE@example.com>\x1dCarol <carol@example.com>\n" +
+			"\n" +
+			"3\t1\tREADME.md\n" +
+			"\x1e1591196645\x1fBob\x1fbob@example.com\x1f\n"
+
+		var commits []*graphCommit
+		So(parseGraphLog(strings.NewReader(log), func(c *graphCommit) {
+			commits = append(commits, c)
+		}), ShouldBeNil)
+		So(commits, ShouldHaveLength, 2)
+		So(commits[0].CoAuthors, ShouldHaveLength, 2)
+		So(commits[0].CoAuthors[0].Email, ShouldEqual, "bob@example.com")
+		So(commits[0].CoAuthors[1].Name, ShouldEqual, "Carol")
+		So(commits[0].Additions, ShouldEqual, 3)
+		So(commits[1].CoAuthors, ShouldBeEmpty)
+	})
+}
+
+func Test_graphStats(t *testing.T) {
+	Convey("Compute graph statistics from commits", t, func() {
+		now := time.Date(2020, 6, 10, 12, 0, 0, 0, time.UTC)
+		stats := newGraphStats(now)
+		stats.add(&graphCommit{Name: "Alice", Email: "alice@example.com", Time: now, Additions: 13, Deletions: 1})
+		stats.add(&graphCommit{Name: "Bob", Email: "bob@example.com", Time: now.AddDate(0, 0, -7), Additions: 2})
+		stats.add(&graphCommit{Name: "Al", Email: "ALICE@example.com", Time: now.AddDate(0, 0, -21), Deletions: 5})
+
+		contributors, activity, frequency := stats.results()
+		So(contributors, ShouldHaveLength, 2)
+		So(contributors[0].Name, ShouldEqual, "Alice")
+		So(contributors[0].Total, ShouldEqual, 2)
+		So(contributors[0].Additions, ShouldEqual, 13)
+		So(contributors[0].Deletions, ShouldEqual, 6)
+		So(contributors[0].Weeks, ShouldHaveLength, 2)
+		So(contributors[0].Weeks[0].Week, ShouldBeLessThan, contributors[0].Weeks[1].Week)
+		So(contributors[1].Name, ShouldEqual, "Bob")
+
+		So(activity, ShouldHaveLength, commitActivityWeeks)
+		last := activity[len(activity)-1]
+		So(last.Week, ShouldEqual, weekStart(now).Unix())
+		So(last.Total, ShouldEqual, 1)
+		So(last.Days[time.Wednesday], ShouldEqual, 1)
+
+		// Weeks without commits are filled in.
+		So(frequency, ShouldHaveLength, 4)
+		So(frequency[0].Deletions, ShouldEqual, 5)
+		So(frequency[1].Additions, ShouldEqual, 0)
+		So(frequency[2].Additions, ShouldEqual, 2)
+		So(frequency[3].Additions, ShouldEqual, 13)
+	})
+}
+
+func Test_graphStats_coAuthors(t *testing.T) {
+	Convey("Credit commits to co-authors", t, func() {
+		now := time.Date(2020, 6, 10, 12, 0, 0, 0, time.UTC)
+		stats := newGraphStats(now)
+		stats.add(&graphCommit{
+			Name:      "Alice",
+			Email:     "alice@example.com",
+			CoAuthors: []*CommitCoAuthor{{Name: "Bob", Email: "bob@example.com"}},
+			Time:      now,
+			Additions: 10,
+		})
+		stats.add(&graphCommit{Name: "Bob", Email: "BOB@example.com", Time: now, Deletions: 2})
+
+		contributors, activity, frequency := stats.results()
+		So(contributors, ShouldHaveLength, 2)
+		So(contributors[0].Email, ShouldEqual, "bob@example.com")
+		So(contributors[0].Total, ShouldEqual, 2)
+		So(contributors[0].Additions, ShouldEqual, 10)
+		So(contributors[0].Deletions, ShouldEqual, 2)
+		So(contributors[1].Email, ShouldEqual, "alice@example.com")
+		So(contributors[1].Total, ShouldEqual, 1)
+
+		// The commit is only counted once.
+		So(activity[len(activity)-1].Total, ShouldEqual, 2)
+		So(frequency, ShouldHaveLength, 1)
+		So(frequency[0].Additions, ShouldEqual, 10)
+	})
+}
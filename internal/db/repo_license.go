@@ -0,0 +1,110 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/unknwon/com"
+
+	"gogs.io/gogs/internal/conf"
+)
+
+// InstanceAllowedLicenses returns names of licenses that are allowed by instance policy.
+func InstanceAllowedLicenses() []string {
+	if len(conf.Repository.AllowedLicenses) == 0 {
+		return Licenses
+	}
+
+	names := make([]string, 0, len(conf.Repository.AllowedLicenses))
+	for _, name := range Licenses {
+		if com.IsSliceContainsStr(conf.Repository.AllowedLicenses, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// AllowedLicenses returns names of licenses that are allowed for new repositories
+// of the owner, which are restricted by both instance and organization policies.
+// The restricted is false when all licenses are allowed.
+func AllowedLicenses(owner *User) (names []string, restricted bool) {
+	names = InstanceAllowedLicenses()
+	restricted = len(conf.Repository.AllowedLicenses) > 0
+	if !owner.IsOrganization() || owner.AllowedLicenses == "" {
+		return names, restricted
+	}
+
+	orgAllowed := strings.Split(owner.AllowedLicenses, ",")
+	filtered := make([]string, 0, len(orgAllowed))
+	for _, name := range names {
+		if com.IsSliceContainsStr(orgAllowed, name) {
+			filtered = append(filtered, name)
+		}
+	}
+	return filtered, true
+}
+
+// IsLicenseAllowed returns true if the license of given name is allowed for new
+// repositories of the owner.
+func IsLicenseAllowed(owner *User, name string) bool {
+	names, restricted := AllowedLicenses(owner)
+	return !restricted || com.IsSliceContainsStr(names, name)
+}
+
+// licenseWords returns the set of lowercase words in the license text.
+func licenseWords(text string) map[string]bool {
+	words := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		words[word] = true
+	}
+	return words
+}
+
+// licenseSimilarity returns the Sørensen–Dice coefficient of word sets of two license
+// texts, which is between 0 and 1 and tolerates filled placeholders like year and name.
+func licenseSimilarity(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	common := 0
+	for word := range a {
+		if b[word] {
+			common++
+		}
+	}
+	return 2 * float64(common) / float64(len(a)+len(b))
+}
+
+// minLicenseSimilarity is the minimum similarity for a text to be identified as a license.
+const minLicenseSimilarity = 0.9
+
+// IdentifyLicense returns the name of the known license that the text is most similar to,
+// or empty if the text does not look like any of known licenses.
+func IdentifyLicense(text string) string {
+	words := licenseWords(text)
+
+	var (
+		name string
+		best float64
+	)
+	for _, license := range Licenses {
+		data, err := getRepoInitFile("license", license)
+		if err != nil {
+			continue
+		}
+		if similarity := licenseSimilarity(words, licenseWords(string(data))); similarity > best {
+			name = license
+			best = similarity
+		}
+	}
+	if best < minLicenseSimilarity {
+		return ""
+	}
+	return name
+}
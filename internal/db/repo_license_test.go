@@ -0,0 +1,23 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_licenseSimilarity(t *testing.T) {
+	Convey("Compute similarity of license texts", t, func() {
+		template := licenseWords("Copyright (c) <year> <copyright holders>\n\nPermission is hereby granted, free of charge.")
+
+		So(licenseSimilarity(template, template), ShouldEqual, 1)
+		So(licenseSimilarity(template, licenseWords("COPYRIGHT (C) <YEAR> <COPYRIGHT HOLDERS> permission is hereby granted free of charge")), ShouldEqual, 1)
+		So(licenseSimilarity(template, licenseWords("Copyright (c) 2020 The Gogs Authors\nPermission is hereby granted, free of charge.")), ShouldBeBetween, 0.5, 1)
+		So(licenseSimilarity(template, licenseWords("All rights reserved")), ShouldEqual, 0)
+		So(licenseSimilarity(template, licenseWords("")), ShouldEqual, 0)
+	})
+}
@@ -0,0 +1,330 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/unknwon/com"
+	log "unknwon.dev/clog/v2"
+	"xorm.io/xorm"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/db/errors"
+	"gogs.io/gogs/internal/process"
+	"gogs.io/gogs/internal/sync"
+)
+
+// MaintenanceQueue is the queue of IDs of repository maintenance jobs to run.
+var MaintenanceQueue = sync.NewUniqueQueue(1000)
+
+// Actions of repository maintenance jobs.
+const (
+	MAINTENANCE_ACTION_GC                 = "gc"
+	MAINTENANCE_ACTION_FSCK               = "fsck"
+	MAINTENANCE_ACTION_UPDATE_SERVER_INFO = "update-server-info"
+	MAINTENANCE_ACTION_RECOMPUTE_STATS    = "recompute-stats"
+)
+
+// MaintenanceActions is the list of supported actions of repository maintenance jobs.
+var MaintenanceActions = []string{
+	MAINTENANCE_ACTION_GC,
+	MAINTENANCE_ACTION_FSCK,
+	MAINTENANCE_ACTION_UPDATE_SERVER_INFO,
+	MAINTENANCE_ACTION_RECOMPUTE_STATS,
+}
+
+// IsValidMaintenanceAction returns true if given action is supported.
+func IsValidMaintenanceAction(action string) bool {
+	for i := range MaintenanceActions {
+		if MaintenanceActions[i] == action {
+			return true
+		}
+	}
+	return false
+}
+
+type MaintenanceStatus int
+
+const (
+	MAINTENANCE_STATUS_QUEUED MaintenanceStatus = iota
+	MAINTENANCE_STATUS_RUNNING
+	MAINTENANCE_STATUS_FAILED
+	MAINTENANCE_STATUS_DONE
+)
+
+func (s MaintenanceStatus) String() string {
+	switch s {
+	case MAINTENANCE_STATUS_QUEUED:
+		return "queued"
+	case MAINTENANCE_STATUS_RUNNING:
+		return "running"
+	case MAINTENANCE_STATUS_FAILED:
+		return "failed"
+	case MAINTENANCE_STATUS_DONE:
+		return "done"
+	}
+	return "unknown"
+}
+
+// MaintenanceJob represents a maintenance action requested to run on a repository
+// in background.
+type MaintenanceJob struct {
+	ID     int64
+	RepoID int64 `xorm:"INDEX"`
+	DoerID int64
+	Action string
+	Status MaintenanceStatus `xorm:"INDEX"`
+	// Output is the output of the action, or the error that the job failed with.
+	Output string `xorm:"TEXT"`
+
+	Created      time.Time `xorm:"-" json:"-"`
+	CreatedUnix  int64
+	Started      time.Time `xorm:"-" json:"-"`
+	StartedUnix  int64
+	Finished     time.Time `xorm:"-" json:"-"`
+	FinishedUnix int64
+}
+
+func (j *MaintenanceJob) BeforeInsert() {
+	j.CreatedUnix = time.Now().Unix()
+}
+
+func (j *MaintenanceJob) AfterSet(colName string, _ xorm.Cell) {
+	switch colName {
+	case "created_unix":
+		j.Created = time.Unix(j.CreatedUnix, 0).Local()
+	case "started_unix":
+		j.Started = time.Unix(j.StartedUnix, 0).Local()
+	case "finished_unix":
+		j.Finished = time.Unix(j.FinishedUnix, 0).Local()
+	}
+}
+
+// IsFinished returns true if the job is either done or failed.
+func (j *MaintenanceJob) IsFinished() bool {
+	return j.Status == MAINTENANCE_STATUS_FAILED || j.Status == MAINTENANCE_STATUS_DONE
+}
+
+// NewMaintenanceJob queues a new maintenance job of the repository. The unfinished
+// job is returned if the same action has already been queued or is running.
+func NewMaintenanceJob(doer *User, repo *Repository, action string) (*MaintenanceJob, error) {
+	if !IsValidMaintenanceAction(action) {
+		return nil, errors.InvalidMaintenanceAction{Action: action}
+	}
+
+	j := new(MaintenanceJob)
+	has, err := x.Where("repo_id = ? AND action = ?", repo.ID, action).
+		In("status", MAINTENANCE_STATUS_QUEUED, MAINTENANCE_STATUS_RUNNING).Get(j)
+	if err != nil {
+		return nil, err
+	} else if has {
+		return j, nil
+	}
+
+	j = &MaintenanceJob{
+		RepoID: repo.ID,
+		DoerID: doer.ID,
+		Action: action,
+		Status: MAINTENANCE_STATUS_QUEUED,
+	}
+	if _, err = x.Insert(j); err != nil {
+		return nil, err
+	}
+	j.Created = time.Unix(j.CreatedUnix, 0).Local()
+
+	MaintenanceQueue.Add(j.ID)
+	return j, nil
+}
+
+// GetMaintenanceJob returns the maintenance job with given ID of the repository.
+func GetMaintenanceJob(repoID, id int64) (*MaintenanceJob, error) {
+	j := new(MaintenanceJob)
+	has, err := x.Where("id = ? AND repo_id = ?", id, repoID).Get(j)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		return nil, errors.MaintenanceJobNotExist{ID: id}
+	}
+	return j, nil
+}
+
+// GetMaintenanceJobs returns maintenance jobs of the repository in reverse order of creation.
+func GetMaintenanceJobs(repoID int64, page, pageSize int) ([]*MaintenanceJob, error) {
+	if page <= 0 {
+		page = 1
+	}
+	jobs := make([]*MaintenanceJob, 0, pageSize)
+	return jobs, x.Where("repo_id = ?", repoID).Desc("id").Limit(pageSize, (page-1)*pageSize).Find(&jobs)
+}
+
+// fsckRepo checks connectivity and validity of objects of the repository.
+func fsckRepo(repo *Repository) (string, error) {
+	repoPath := repo.RepoPath()
+	stdout, stderr, err := process.ExecDir(conf.Cron.RepoHealthCheck.Timeout,
+		repoPath, fmt.Sprintf("Maintenance fsck: %s", repoPath),
+		"git", append([]string{"fsck"}, conf.Cron.RepoHealthCheck.Args...)...)
+	output := strings.TrimSpace(stdout + stderr)
+	if err != nil {
+		return output, fmt.Errorf("%v: %s", err, output)
+	}
+	return output, nil
+}
+
+// updateServerInfo updates auxiliary info files of the repository for dumb servers.
+func updateServerInfo(repo *Repository) error {
+	repoPath := repo.RepoPath()
+	_, stderr, err := process.ExecDir(-1,
+		repoPath, fmt.Sprintf("Maintenance update-server-info: %s", repoPath),
+		"git", "update-server-info")
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, stderr)
+	}
+	return nil
+}
+
+// recomputeRepoStats recomputes cached counters and size of the repository, and
+// returns a summary of counters that have been corrected.
+func recomputeRepoStats(repo *Repository) (string, error) {
+	counts := []struct {
+		col   string
+		field *int
+		count func() (int64, error)
+	}{
+		{"num_watches", &repo.NumWatches, func() (int64, error) {
+			return x.Where("repo_id = ?", repo.ID).Count(new(Watch))
+		}},
+		{"num_stars", &repo.NumStars, func() (int64, error) {
+			return x.Where("repo_id = ?", repo.ID).Count(new(Star))
+		}},
+		{"num_forks", &repo.NumForks, func() (int64, error) {
+			return x.Where("fork_id = ?", repo.ID).Count(new(Repository))
+		}},
+		{"num_issues", &repo.NumIssues, func() (int64, error) {
+			return x.Where("repo_id = ? AND is_pull = ?", repo.ID, false).Count(new(Issue))
+		}},
+		{"num_closed_issues", &repo.NumClosedIssues, func() (int64, error) {
+			return x.Where("repo_id = ? AND is_pull = ? AND is_closed = ?", repo.ID, false, true).Count(new(Issue))
+		}},
+		{"num_pulls", &repo.NumPulls, func() (int64, error) {
+			return x.Where("repo_id = ? AND is_pull = ?", repo.ID, true).Count(new(Issue))
+		}},
+		{"num_closed_pulls", &repo.NumClosedPulls, func() (int64, error) {
+			return x.Where("repo_id = ? AND is_pull = ? AND is_closed = ?", repo.ID, true, true).Count(new(Issue))
+		}},
+		{"num_milestones", &repo.NumMilestones, func() (int64, error) {
+			return x.Where("repo_id = ?", repo.ID).Count(new(Milestone))
+		}},
+		{"num_closed_milestones", &repo.NumClosedMilestones, func() (int64, error) {
+			return x.Where("repo_id = ? AND is_closed = ?", repo.ID, true).Count(new(Milestone))
+		}},
+	}
+
+	changes := make([]string, 0, len(counts))
+	cols := make([]string, 0, len(counts))
+	for _, c := range counts {
+		n, err := c.count()
+		if err != nil {
+			return "", fmt.Errorf("count %q: %v", c.col, err)
+		}
+		if int(n) != *c.field {
+			changes = append(changes, fmt.Sprintf("%s: %d -> %d", c.col, *c.field, n))
+			*c.field = int(n)
+			cols = append(cols, c.col)
+		}
+	}
+	if len(cols) > 0 {
+		if _, err := x.ID(repo.ID).Cols(cols...).Update(repo); err != nil {
+			return "", fmt.Errorf("update counters: %v", err)
+		}
+	}
+
+	size := repo.Size
+	if err := repo.UpdateSize(); err != nil {
+		return "", err
+	} else if size != repo.Size {
+		changes = append(changes, fmt.Sprintf("size: %d -> %d", size, repo.Size))
+	}
+	return strings.Join(changes, "\n"), nil
+}
+
+// run runs the action of the job and returns its output.
+func (j *MaintenanceJob) run() (string, error) {
+	repo, err := GetRepositoryByID(j.RepoID)
+	if err != nil {
+		return "", fmt.Errorf("GetRepositoryByID: %v", err)
+	}
+
+	switch j.Action {
+	case MAINTENANCE_ACTION_GC:
+		if err = repo.RunGC(); err != nil {
+			return "", err
+		}
+		gc, err := GetRepoGC(repo.ID)
+		if err != nil {
+			return "", fmt.Errorf("GetRepoGC: %v", err)
+		}
+		return fmt.Sprintf("%s: %d -> %d bytes", gc.Command, gc.SizeBefore, gc.SizeAfter), nil
+	case MAINTENANCE_ACTION_FSCK:
+		return fsckRepo(repo)
+	case MAINTENANCE_ACTION_UPDATE_SERVER_INFO:
+		return "", updateServerInfo(repo)
+	case MAINTENANCE_ACTION_RECOMPUTE_STATS:
+		return recomputeRepoStats(repo)
+	}
+	return "", errors.InvalidMaintenanceAction{Action: j.Action}
+}
+
+// RunMaintenanceJobs runs queued repository maintenance jobs.
+func RunMaintenanceJobs() {
+	for id := range MaintenanceQueue.Queue() {
+		log.Trace("RunMaintenanceJobs [id: %s]", id)
+		MaintenanceQueue.Remove(id)
+
+		j := new(MaintenanceJob)
+		has, err := x.ID(com.StrTo(id).MustInt64()).Get(j)
+		if err != nil {
+			log.Error("Get maintenance job [%s]: %v", id, err)
+			continue
+		} else if !has || j.IsFinished() {
+			continue
+		}
+
+		j.Status = MAINTENANCE_STATUS_RUNNING
+		j.StartedUnix = time.Now().Unix()
+		if _, err = x.ID(j.ID).Cols("status", "started_unix").Update(j); err != nil {
+			log.Error("Update maintenance job [%d]: %v", j.ID, err)
+			continue
+		}
+
+		j.Output, err = j.run()
+		if err != nil {
+			log.Error("Failed to run maintenance job %d of repository %d: %v", j.ID, j.RepoID, err)
+			j.Status = MAINTENANCE_STATUS_FAILED
+			j.Output = err.Error()
+		} else {
+			j.Status = MAINTENANCE_STATUS_DONE
+		}
+		j.FinishedUnix = time.Now().Unix()
+		if _, err = x.ID(j.ID).Cols("status", "output", "finished_unix").Update(j); err != nil {
+			log.Error("Update maintenance job [%d]: %v", j.ID, err)
+		}
+	}
+}
+
+// InitMaintenanceJobs resumes unfinished maintenance jobs and starts running queued jobs.
+func InitMaintenanceJobs() {
+	jobs := make([]*MaintenanceJob, 0, 10)
+	if err := x.In("status", MAINTENANCE_STATUS_QUEUED, MAINTENANCE_STATUS_RUNNING).Asc("id").Find(&jobs); err != nil {
+		log.Error("Find unfinished maintenance jobs: %v", err)
+	}
+	for i := range jobs {
+		MaintenanceQueue.Add(jobs[i].ID)
+	}
+
+	go RunMaintenanceJobs()
+}
@@ -0,0 +1,70 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"gogs.io/gogs/internal/db/errors"
+)
+
+// ParseRefNamePatterns returns non-empty glob patterns of branch or tag names,
+// one per line, and validates each of them.
+func ParseRefNamePatterns(s string) ([]string, error) {
+	var patterns []string
+	for _, line := range strings.Split(s, "\n") {
+		pattern := strings.TrimSpace(line)
+		if pattern == "" {
+			continue
+		}
+		if _, err := globToRegexp(pattern); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// isSafeRefNameChar returns true if the character is allowed in ref names when unsafe
+// characters are restricted. Non-ASCII characters are not allowed because they can be
+// used to create names that look the same as others.
+func isSafeRefNameChar(r rune) bool {
+	return ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') ||
+		strings.ContainsRune("-_./+@", r)
+}
+
+// checkRefName returns errors.RefNameNotAllowed if the name of new branch or tag contains
+// unsafe characters when restricted, or does not match any of the patterns.
+func checkRefName(kind, name, patterns string, restrictChars bool) error {
+	if restrictChars && strings.IndexFunc(name, func(r rune) bool { return !isSafeRefNameChar(r) }) > -1 {
+		return errors.RefNameNotAllowed{Kind: kind, Name: name}
+	}
+
+	// Invalid patterns are rejected when saved, the error is ignored here.
+	globs, _ := ParseRefNamePatterns(patterns)
+	if len(globs) == 0 {
+		return nil
+	}
+	for _, pattern := range globs {
+		re, _ := globToRegexp(pattern)
+		if re.MatchString(name) {
+			return nil
+		}
+	}
+	return errors.RefNameNotAllowed{Kind: kind, Name: name, Patterns: globs}
+}
+
+// CheckBranchName returns errors.RefNameNotAllowed if the name is not allowed for
+// new branches of the repository.
+func (repo *Repository) CheckBranchName(name string) error {
+	return checkRefName("branch", name, repo.BranchNamePatterns, repo.RestrictRefNameChars)
+}
+
+// CheckTagName returns errors.RefNameNotAllowed if the name is not allowed for
+// new tags of the repository.
+func (repo *Repository) CheckTagName(name string) error {
+	return checkRefName("tag", name, repo.TagNamePatterns, repo.RestrictRefNameChars)
+}
@@ -0,0 +1,44 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"gogs.io/gogs/internal/db/errors"
+)
+
+func Test_checkRefName(t *testing.T) {
+	Convey("Check names of new branches and tags", t, func() {
+		testCases := []struct {
+			name          string
+			patterns      string
+			restrictChars bool
+			expect        bool
+		}{
+			{"anything goes", "", false, true},
+			{"feature/login", "feature/*\n\nfix/*\n", false, true},
+			{"fix/crash", "feature/*\n\nfix/*\n", false, true},
+			{"master", "feature/*\n\nfix/*\n", false, false},
+			{"feature/a/b", "feature/*", false, false},
+			{"feature/a/b", "feature/**", false, true},
+			{"v1.0.0", "", true, true},
+			{"feature/user@v2+1", "", true, true},
+			{"feature/ß", "", true, false},
+			{"feature/\u202emaster", "feature/*", true, false},
+			{"feature/\u202emaster", "feature/*", false, true},
+		}
+		for _, tc := range testCases {
+			err := checkRefName("branch", tc.name, tc.patterns, tc.restrictChars)
+			if tc.expect {
+				So(err, ShouldBeNil)
+			} else {
+				So(errors.IsRefNameNotAllowed(err), ShouldBeTrue)
+			}
+		}
+	})
+}
@@ -0,0 +1,182 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/gogs/git-module"
+	"github.com/unknwon/com"
+	"golang.org/x/crypto/ssh"
+	log "unknwon.dev/clog/v2"
+
+	"gogs.io/gogs/internal/conf"
+)
+
+// Events of creating commits by the server that commits can be signed on.
+const (
+	signOnInitial = "initial"
+	signOnEditor  = "editor"
+	signOnWiki    = "wiki"
+	signOnMerge   = "merge"
+)
+
+// signingArgs returns Git options to sign commits created by the command on the event,
+// or nil if commits should not be signed.
+func signingArgs(event string) []string {
+	signing := conf.Repository.Signing
+	if signing.SigningKey == "" || !com.IsSliceContainsStr(signing.SignOn, event) {
+		return nil
+	}
+	return []string{
+		"-c", "commit.gpgSign=true",
+		"-c", "gpg.format=" + signing.Format,
+		"-c", "user.signingKey=" + signing.SigningKey,
+	}
+}
+
+// commitChanges commits local changes like git.CommitChanges, and signs the commit if
+// commits should be signed on the event.
+func commitChanges(repoPath string, opts git.CommitChangesOptions, event string) error {
+	args := signingArgs(event)
+	if args == nil {
+		return git.CommitChanges(repoPath, opts)
+	}
+
+	cmd := git.NewCommand(args...)
+	if opts.Committer != nil {
+		cmd.AddEnvs("GIT_COMMITTER_NAME="+opts.Committer.Name, "GIT_COMMITTER_EMAIL="+opts.Committer.Email)
+	}
+	cmd.AddArguments("commit")
+
+	if opts.Author == nil {
+		opts.Author = opts.Committer
+	}
+	if opts.Author != nil {
+		cmd.AddArguments(fmt.Sprintf("--author='%s <%s>'", opts.Author.Name, opts.Author.Email))
+	}
+	cmd.AddArguments("-m", opts.Message)
+
+	_, err := cmd.RunInDir(repoPath)
+	// No stderr but exit status 1 means nothing to commit.
+	if err != nil && err.Error() == "exit status 1" {
+		return nil
+	}
+	return err
+}
+
+// CommitVerification is the result of verifying the signature of a commit.
+type CommitVerification struct {
+	// Signed is true if the commit has a signature.
+	Signed bool
+	// Verified is true if the signature is good and is made by the signing key of the
+	// instance.
+	Verified bool
+}
+
+var (
+	signingKeyOnce sync.Once
+	// signingKeyID is the ID of the signing key to be compared with the key of
+	// signatures, which is the fingerprint for SSH keys.
+	signingKeyID string
+	// allowedSignersFile is the file that lists the signing key as the only trusted
+	// key for verifying SSH signatures.
+	allowedSignersFile string
+)
+
+// loadSigningKey loads the ID of the signing key, and writes the allowed signers file
+// for SSH keys.
+func loadSigningKey() {
+	signing := conf.Repository.Signing
+	if signing.Format != "ssh" {
+		keyID := strings.ToUpper(strings.Replace(signing.SigningKey, " ", "", -1))
+		signingKeyID = strings.TrimPrefix(keyID, "0X")
+		return
+	}
+
+	pubKeyPath := signing.SigningKey
+	if !strings.HasSuffix(pubKeyPath, ".pub") {
+		pubKeyPath += ".pub"
+	}
+	data, err := ioutil.ReadFile(pubKeyPath)
+	if err != nil {
+		log.Error("Failed to read public key of signing key: %v", err)
+		return
+	}
+	pubKey, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		log.Error("Failed to parse public key of signing key: %v", err)
+		return
+	}
+
+	fpath := filepath.Join(conf.Server.AppDataPath, "signing", "allowed_signers")
+	if err = os.MkdirAll(filepath.Dir(fpath), os.ModePerm); err != nil {
+		log.Error("Failed to create directory of allowed signers file: %v", err)
+		return
+	}
+	if err = ioutil.WriteFile(fpath, []byte("gogs "+strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pubKey)))+"\n"), 0644); err != nil {
+		log.Error("Failed to write allowed signers file: %v", err)
+		return
+	}
+	signingKeyID = ssh.FingerprintSHA256(pubKey)
+	allowedSignersFile = fpath
+}
+
+// parseCommitVerification parses the output line of "git log" in the format of
+// "%H %G? %GK %GF %GP" for verification of the commit signed by the key of given ID,
+// and returns the commit ID with the result.
+func parseCommitVerification(line, keyID string) (string, *CommitVerification) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return "", nil
+	}
+
+	v := &CommitVerification{
+		Signed: fields[1] != "N",
+	}
+	if keyID != "" && (fields[1] == "G" || fields[1] == "U") {
+		for _, id := range fields[2:] {
+			if strings.HasSuffix(strings.ToUpper(id), keyID) || id == keyID {
+				v.Verified = true
+				break
+			}
+		}
+	}
+	return fields[0], v
+}
+
+// VerifyCommits returns verification results of signatures of given commits in the
+// repository by commit IDs. It returns nil if commits are not signed by the server.
+func VerifyCommits(repoPath string, commitIDs ...string) (map[string]*CommitVerification, error) {
+	if conf.Repository.Signing.SigningKey == "" || len(commitIDs) == 0 {
+		return nil, nil
+	}
+	signingKeyOnce.Do(loadSigningKey)
+
+	var args []string
+	if allowedSignersFile != "" {
+		args = append(args, "-c", "gpg.ssh.allowedSignersFile="+allowedSignersFile)
+	}
+	args = append(args, "log", "--no-walk=unsorted", "--format=%H %G? %GK %GF %GP")
+	args = append(args, commitIDs...)
+	stdout, err := git.NewCommand(args...).RunInDirBytes(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("git log: %v", err)
+	}
+
+	verifications := make(map[string]*CommitVerification, len(commitIDs))
+	for _, line := range strings.Split(string(stdout), "\n") {
+		commitID, v := parseCommitVerification(line, signingKeyID)
+		if v != nil {
+			verifications[commitID] = v
+		}
+	}
+	return verifications, nil
+}
@@ -0,0 +1,47 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_parseCommitVerification(t *testing.T) {
+	Convey("Parse verification results of commits", t, func() {
+		const (
+			commitID = "065cdb753f269c4095c905749668db14965b92b0"
+			gpgLine  = commitID + " G A88B189FC71F66AF AFE164413E63F346DF9E72BFA88B189FC71F66AF AFE164413E63F346DF9E72BFA88B189FC71F66AF"
+			sshLine  = commitID + " G SHA256:RLKEQXgRt8xjAMMimuP8OHflLFrarqROg95xQz/JwRM SHA256:RLKEQXgRt8xjAMMimuP8OHflLFrarqROg95xQz/JwRM"
+		)
+		testCases := []struct {
+			line     string
+			keyID    string
+			signed   bool
+			verified bool
+		}{
+			{gpgLine, "A88B189FC71F66AF", true, true},
+			{gpgLine, "C71F66AF", true, true},
+			{gpgLine, "AFE164413E63F346DF9E72BFA88B189FC71F66AF", true, true},
+			{gpgLine, "1234567890ABCDEF", true, false},
+			{gpgLine, "", true, false},
+			{commitID + " B A88B189FC71F66AF AFE164413E63F346DF9E72BFA88B189FC71F66AF", "A88B189FC71F66AF", true, false},
+			{sshLine, "SHA256:RLKEQXgRt8xjAMMimuP8OHflLFrarqROg95xQz/JwRM", true, true},
+			{sshLine, "SHA256:Xn0cd4i4aqMOXIY3R/b8M/6+1d1Cl2vH2QpsqN6Pu1E", true, false},
+			{commitID + " N", "A88B189FC71F66AF", false, false},
+		}
+		for _, tc := range testCases {
+			id, v := parseCommitVerification(tc.line, tc.keyID)
+			So(id, ShouldEqual, commitID)
+			So(v.Signed, ShouldEqual, tc.signed)
+			So(v.Verified, ShouldEqual, tc.verified)
+		}
+
+		id, v := parseCommitVerification("", "A88B189FC71F66AF")
+		So(id, ShouldBeEmpty)
+		So(v, ShouldBeNil)
+	})
+}
@@ -0,0 +1,224 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gogs.io/gogs/internal/process"
+)
+
+// templateConfigPath is the file in a template repository that lists glob patterns,
+// one per line, of files whose names and contents have variables expanded when
+// generating new repositories. The file is not copied to generated repositories.
+const templateConfigPath = ".gogs/template"
+
+// globToRegexp converts a glob pattern to regular expression, where "*" matches
+// any characters except "/", "?" matches one character except "/" and "**" matches
+// any number of directories.
+func globToRegexp(pattern string) (*regexp.Regexp, error) {
+	var buf strings.Builder
+	buf.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch ch := pattern[i]; ch {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+				if i+1 < len(pattern) && pattern[i+1] == '/' {
+					i++
+					buf.WriteString("(.*/)?")
+				} else {
+					buf.WriteString(".*")
+				}
+			} else {
+				buf.WriteString("[^/]*")
+			}
+		case '?':
+			buf.WriteString("[^/]")
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	buf.WriteString("$")
+	return regexp.Compile(buf.String())
+}
+
+// parseTemplateGlobs parses glob patterns from content of template config file,
+// empty lines and lines start with "#" are ignored.
+func parseTemplateGlobs(data []byte) ([]*regexp.Regexp, error) {
+	var globs []*regexp.Regexp
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		glob, err := globToRegexp(strings.TrimPrefix(line, "/"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", line, err)
+		}
+		globs = append(globs, glob)
+	}
+	return globs, scanner.Err()
+}
+
+// expandTemplateVars replaces variables in both forms of "$NAME" and "${NAME}" with
+// their values, unknown variables are left as-is.
+func expandTemplateVars(s string, vars map[string]string) string {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	// Longer names go first so that a name does not shadow another one it prefixes.
+	sort.Slice(names, func(i, j int) bool {
+		return len(names[i]) > len(names[j])
+	})
+
+	oldnew := make([]string, 0, len(names)*4)
+	for _, name := range names {
+		oldnew = append(oldnew, "${"+name+"}", vars[name])
+	}
+	for _, name := range names {
+		oldnew = append(oldnew, "$"+name, vars[name])
+	}
+	return strings.NewReplacer(oldnew...).Replace(s)
+}
+
+// templateVars returns variables available to files of the template repository.
+func templateVars(repo, tpl *Repository) map[string]string {
+	cloneLink := repo.CloneLink()
+	return map[string]string{
+		"REPO_NAME":            repo.Name,
+		"REPO_OWNER":           repo.MustOwner().Name,
+		"REPO_DESCRIPTION":     repo.Description,
+		"REPO_LINK":            repo.HTMLURL(),
+		"REPO_CLONE_URL_SSH":   cloneLink.SSH,
+		"REPO_CLONE_URL_HTTPS": cloneLink.HTTPS,
+		"TEMPLATE_NAME":        tpl.Name,
+		"TEMPLATE_OWNER":       tpl.MustOwner().Name,
+		"TEMPLATE_LINK":        tpl.HTMLURL(),
+	}
+}
+
+// expandTemplateFiles expands variables in names and contents of regular files in the
+// directory that match template config file, then removes the config file.
+func expandTemplateFiles(dir string, vars map[string]string) error {
+	configPath := filepath.Join(dir, filepath.FromSlash(templateConfigPath))
+	data, err := ioutil.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("read template config: %v", err)
+	}
+	if err = os.Remove(configPath); err != nil {
+		return fmt.Errorf("remove template config: %v", err)
+	}
+	// The directory is only removed when it is empty.
+	_ = os.Remove(filepath.Dir(configPath))
+
+	globs, err := parseTemplateGlobs(data)
+	if err != nil {
+		return err
+	}
+
+	var files []string
+	if err = filepath.Walk(dir, func(fpath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		// Symbolic links must not be followed to avoid reading files outside the repository.
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		name, err := filepath.Rel(dir, fpath)
+		if err != nil {
+			return err
+		}
+		name = filepath.ToSlash(name)
+		for _, glob := range globs {
+			if glob.MatchString(name) {
+				files = append(files, name)
+				break
+			}
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("walk: %v", err)
+	}
+
+	for _, name := range files {
+		oldPath := filepath.Join(dir, filepath.FromSlash(name))
+		data, err := ioutil.ReadFile(oldPath)
+		if err != nil {
+			return fmt.Errorf("read %q: %v", name, err)
+		}
+		info, err := os.Lstat(oldPath)
+		if err != nil {
+			return fmt.Errorf("stat %q: %v", name, err)
+		}
+
+		// Clean the expanded name to make sure the file stays inside the directory.
+		newName := strings.TrimPrefix(path.Clean("/"+expandTemplateVars(name, vars)), "/")
+		if newName == "" || newName == ".git" || strings.HasPrefix(newName, ".git/") {
+			newName = name
+		}
+		newPath := filepath.Join(dir, filepath.FromSlash(newName))
+		if newPath != oldPath {
+			if err = os.MkdirAll(filepath.Dir(newPath), os.ModePerm); err != nil {
+				return fmt.Errorf("create parent directory of %q: %v", newName, err)
+			} else if err = os.Remove(oldPath); err != nil {
+				return fmt.Errorf("remove %q: %v", name, err)
+			}
+		}
+
+		if err = ioutil.WriteFile(newPath, []byte(expandTemplateVars(string(data), vars)), info.Mode()); err != nil {
+			return fmt.Errorf("write %q: %v", newName, err)
+		}
+	}
+	return nil
+}
+
+// prepareTemplateCommit copies files of the template repository at its default branch
+// to the temporary directory without history, and expands variables in files listed
+// in template config file.
+func prepareTemplateCommit(repo *Repository, tmpDir, repoPath string, tpl *Repository) error {
+	_, stderr, err := process.Exec(
+		fmt.Sprintf("prepareTemplateCommit(git clone): %s", tpl.RepoPath()),
+		"git", "clone", "--branch", tpl.DefaultBranch, tpl.RepoPath(), tmpDir)
+	if err != nil {
+		return fmt.Errorf("git clone: %v - %s", err, stderr)
+	}
+
+	// Start from scratch so that history of the template repository is not carried.
+	if err = os.RemoveAll(filepath.Join(tmpDir, ".git")); err != nil {
+		return fmt.Errorf("remove .git: %v", err)
+	}
+	for _, args := range [][]string{
+		{"init"},
+		{"symbolic-ref", "HEAD", "refs/heads/master"},
+		{"remote", "add", "origin", repoPath},
+	} {
+		if _, stderr, err = process.ExecDir(-1, tmpDir,
+			fmt.Sprintf("prepareTemplateCommit(git %s): %s", args[0], tmpDir),
+			"git", args...); err != nil {
+			return fmt.Errorf("git %s: %v - %s", args[0], err, stderr)
+		}
+	}
+
+	return expandTemplateFiles(tmpDir, templateVars(repo, tpl))
+}
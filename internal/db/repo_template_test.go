@@ -0,0 +1,79 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_globToRegexp(t *testing.T) {
+	Convey("Convert glob patterns to regular expressions", t, func() {
+		testCases := []struct {
+			pattern string
+			name    string
+			expect  bool
+		}{
+			{"README.md", "README.md", true},
+			{"README.md", "docs/README.md", false},
+			{"*.go", "main.go", true},
+			{"*.go", "cmd/main.go", false},
+			{"cmd/*.go", "cmd/main.go", true},
+			{"**/*.go", "main.go", true},
+			{"**/*.go", "cmd/serv/main.go", true},
+			{"docs/**", "docs/a/b.md", true},
+			{"docs/**", "README.md", false},
+			{"?.txt", "a.txt", true},
+			{"?.txt", "ab.txt", false},
+			{"a+b.txt", "a+b.txt", true},
+			{"a+b.txt", "aab.txt", false},
+		}
+		for _, tc := range testCases {
+			re, err := globToRegexp(tc.pattern)
+			So(err, ShouldBeNil)
+			So(re.MatchString(tc.name), ShouldEqual, tc.expect)
+		}
+	})
+}
+
+func Test_parseTemplateGlobs(t *testing.T) {
+	Convey("Parse glob patterns from template config", t, func() {
+		globs, err := parseTemplateGlobs([]byte(`
+# Comment
+README.md
+
+/cmd/*.go
+`))
+		So(err, ShouldBeNil)
+		So(globs, ShouldHaveLength, 2)
+		So(globs[0].MatchString("README.md"), ShouldBeTrue)
+		So(globs[1].MatchString("cmd/main.go"), ShouldBeTrue)
+	})
+}
+
+func Test_expandTemplateVars(t *testing.T) {
+	Convey("Expand variables in template files", t, func() {
+		vars := map[string]string{
+			"REPO_NAME":  "gogs",
+			"REPO_OWNER": "gogs-org",
+			"REPO_LINK":  "https://gogs.example.com/gogs-org/gogs",
+		}
+		testCases := []struct {
+			input  string
+			expect string
+		}{
+			{"$REPO_NAME", "gogs"},
+			{"${REPO_NAME}.go", "gogs.go"},
+			{"$REPO_OWNER/$REPO_NAME", "gogs-org/gogs"},
+			{"[$REPO_NAME]($REPO_LINK)", "[gogs](https://gogs.example.com/gogs-org/gogs)"},
+			{"$HOME and ${UNKNOWN}", "$HOME and ${UNKNOWN}"},
+			{"no variables", "no variables"},
+		}
+		for _, tc := range testCases {
+			So(expandTemplateVars(tc.input, vars), ShouldEqual, tc.expect)
+		}
+	})
+}
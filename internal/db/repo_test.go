@@ -28,6 +28,15 @@ func TestRepo(t *testing.T) {
 				repo.ExternalTrackerStyle = markup.ISSUE_NAME_STYLE_NUMERIC
 				So(repo.ComposeMetas(), ShouldEqual, map[string]string(nil))
 			})
+			Convey("It should contain the repo ID if the repository has autolinks", func() {
+				repo.ID = 99
+				repo.EnableExternalTracker = false
+				rule, err := markup.NewAutolink(`JIRA-[0-9]+`, "https://jira.example.com/browse/$0")
+				So(err, ShouldBeNil)
+				markup.SetAutolinks(repo.ID, []*markup.Autolink{rule})
+				defer markup.SetAutolinks(repo.ID, nil)
+				So(repo.ComposeMetas(), ShouldResemble, map[string]string{"repoID": "99"})
+			})
 		})
 
 		Convey("When an external issue tracker is configured", func() {
@@ -61,3 +70,42 @@ func TestRepo(t *testing.T) {
 		})
 	})
 }
+
+func Test_ParseWatchMode(t *testing.T) {
+	Convey("Parse watch mode by name", t, func() {
+		So(db.ParseWatchMode("all"), ShouldEqual, db.WATCH_MODE_ALL)
+		So(db.ParseWatchMode("participating"), ShouldEqual, db.WATCH_MODE_PARTICIPATING)
+		So(db.ParseWatchMode("ignore"), ShouldEqual, db.WATCH_MODE_IGNORE)
+		So(db.ParseWatchMode("custom"), ShouldEqual, 0)
+		So(db.ParseWatchMode(""), ShouldEqual, 0)
+	})
+}
+
+func TestRepository_CanAccessWiki(t *testing.T) {
+	Convey("Check access to wiki", t, func() {
+		repo := &db.Repository{EnableWiki: true, IsPrivate: true}
+
+		Convey("Writers can edit wiki by default", func() {
+			So(repo.WikiWriteAccessMode(), ShouldEqual, db.ACCESS_MODE_WRITE)
+			So(repo.CanAccessWiki(db.ACCESS_MODE_WRITE, db.ACCESS_MODE_WRITE), ShouldBeTrue)
+			So(repo.CanAccessWiki(db.ACCESS_MODE_TRIAGE, db.ACCESS_MODE_WRITE), ShouldBeFalse)
+			So(repo.CanAccessWiki(db.ACCESS_MODE_READ, db.ACCESS_MODE_READ), ShouldBeTrue)
+			So(repo.CanAccessWiki(db.ACCESS_MODE_NONE, db.ACCESS_MODE_READ), ShouldBeFalse)
+		})
+
+		Convey("Restrict editing to admins", func() {
+			repo.WikiWriteAccess = db.ACCESS_MODE_ADMIN
+			So(repo.CanAccessWiki(db.ACCESS_MODE_MAINTAIN, db.ACCESS_MODE_WRITE), ShouldBeFalse)
+			So(repo.CanAccessWiki(db.ACCESS_MODE_ADMIN, db.ACCESS_MODE_WRITE), ShouldBeTrue)
+		})
+
+		Convey("Open editing to any signed-in user who can view wiki", func() {
+			repo.WikiWriteAccess = db.ACCESS_MODE_READ
+			So(repo.CanAccessWiki(db.ACCESS_MODE_READ, db.ACCESS_MODE_WRITE), ShouldBeTrue)
+			So(repo.CanAccessWiki(db.ACCESS_MODE_NONE, db.ACCESS_MODE_WRITE), ShouldBeFalse)
+
+			repo.AllowPublicWiki = true
+			So(repo.CanAccessWiki(db.ACCESS_MODE_NONE, db.ACCESS_MODE_WRITE), ShouldBeTrue)
+		})
+	})
+}
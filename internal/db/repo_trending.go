@@ -0,0 +1,321 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"time"
+
+	log "unknwon.dev/clog/v2"
+	"xorm.io/xorm"
+)
+
+// TrendingPeriod is the time window that stars and forks are gained in for trending
+// repositories.
+type TrendingPeriod string
+
+const (
+	TRENDING_DAILY   TrendingPeriod = "daily"
+	TRENDING_WEEKLY  TrendingPeriod = "weekly"
+	TRENDING_MONTHLY TrendingPeriod = "monthly"
+)
+
+// TrendingPeriods are all supported trending periods.
+var TrendingPeriods = []TrendingPeriod{TRENDING_DAILY, TRENDING_WEEKLY, TRENDING_MONTHLY}
+
+// ParseTrendingPeriod returns the period of given name, it falls back to daily for
+// unknown names.
+func ParseTrendingPeriod(name string) TrendingPeriod {
+	for _, p := range TrendingPeriods {
+		if string(p) == name {
+			return p
+		}
+	}
+	return TRENDING_DAILY
+}
+
+// Duration returns the length of the time window.
+func (p TrendingPeriod) Duration() time.Duration {
+	switch p {
+	case TRENDING_WEEKLY:
+		return 7 * 24 * time.Hour
+	case TRENDING_MONTHLY:
+		return 30 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// RepoTrending is the periodically updated statistics of a repository for discovery,
+// which contains stars and forks gained in each trending period and the primary
+// language of the default branch.
+type RepoTrending struct {
+	ID           int64
+	RepoID       int64 `xorm:"UNIQUE NOT NULL"`
+	StarsDaily   int   `xorm:"NOT NULL DEFAULT 0"`
+	ForksDaily   int   `xorm:"NOT NULL DEFAULT 0"`
+	StarsWeekly  int   `xorm:"NOT NULL DEFAULT 0"`
+	ForksWeekly  int   `xorm:"NOT NULL DEFAULT 0"`
+	StarsMonthly int   `xorm:"NOT NULL DEFAULT 0"`
+	ForksMonthly int   `xorm:"NOT NULL DEFAULT 0"`
+	// Language is the primary language of the default branch, empty if unknown.
+	Language string `xorm:"INDEX NOT NULL DEFAULT ''"`
+	// LanguageCommitID is the commit that the language is detected from.
+	LanguageCommitID string `xorm:"VARCHAR(40)"`
+
+	Updated     time.Time `xorm:"-" json:"-"`
+	UpdatedUnix int64
+}
+
+func (t *RepoTrending) AfterSet(colName string, _ xorm.Cell) {
+	switch colName {
+	case "updated_unix":
+		t.Updated = time.Unix(t.UpdatedUnix, 0).Local()
+	}
+}
+
+// gains returns pointers to stars and forks gained in the period.
+func (t *RepoTrending) gains(p TrendingPeriod) (stars, forks *int) {
+	switch p {
+	case TRENDING_WEEKLY:
+		return &t.StarsWeekly, &t.ForksWeekly
+	case TRENDING_MONTHLY:
+		return &t.StarsMonthly, &t.ForksMonthly
+	}
+	return &t.StarsDaily, &t.ForksDaily
+}
+
+// trendingColumns returns columns of stars and forks gained in the period.
+func trendingColumns(p TrendingPeriod) (stars, forks string) {
+	return "repo_trending.stars_" + string(p), "repo_trending.forks_" + string(p)
+}
+
+// countRepoGains returns numbers of stars and forks gained by repositories since the
+// time. Unstarring counts as a lost star.
+func countRepoGains(since int64) (stars, forks map[int64]int, err error) {
+	type repoCount struct {
+		RepoID int64
+		Count  int
+	}
+	var rows []*repoCount
+
+	stars = make(map[int64]int)
+	for _, isStar := range []bool{true, false} {
+		rows = rows[:0]
+		if err = x.Table("star_event").Select("repo_id, COUNT(*) AS count").
+			Where("created_unix >= ? AND is_star = ?", since, isStar).
+			GroupBy("repo_id").Find(&rows); err != nil {
+			return nil, nil, fmt.Errorf("count star events: %v", err)
+		}
+		for _, row := range rows {
+			if isStar {
+				stars[row.RepoID] += row.Count
+			} else {
+				stars[row.RepoID] -= row.Count
+			}
+		}
+	}
+
+	rows = rows[:0]
+	if err = x.Table("repository").Select("fork_id AS repo_id, COUNT(*) AS count").
+		Where("is_fork = ? AND created_unix >= ?", true, since).
+		GroupBy("fork_id").Find(&rows); err != nil {
+		return nil, nil, fmt.Errorf("count forks: %v", err)
+	}
+	forks = make(map[int64]int, len(rows))
+	for _, row := range rows {
+		forks[row.RepoID] = row.Count
+	}
+	return stars, forks, nil
+}
+
+// repoGains is the stars and forks gained by repositories in a trending period.
+type repoGains struct {
+	stars map[int64]int
+	forks map[int64]int
+}
+
+// updateRepoTrending saves gains of the repository and detects the primary language
+// again if the default branch has changed.
+func updateRepoTrending(repo *Repository, gains map[TrendingPeriod]*repoGains, now time.Time) error {
+	t := &RepoTrending{RepoID: repo.ID}
+	if _, err := x.Get(t); err != nil {
+		return fmt.Errorf("get trending: %v", err)
+	}
+	before := *t
+
+	for _, p := range TrendingPeriods {
+		stars, forks := t.gains(p)
+		*stars = gains[p].stars[repo.ID]
+		*forks = gains[p].forks[repo.ID]
+	}
+
+	if !repo.IsBare {
+		commitID, err := repo.defaultBranchCommitID()
+		if err != nil {
+			return err
+		}
+		if commitID != t.LanguageCommitID {
+			t.Language, err = detectPrimaryLanguage(repo.RepoPath(), commitID)
+			if err != nil {
+				return err
+			}
+			t.LanguageCommitID = commitID
+		}
+	}
+
+	if t.ID > 0 && *t == before {
+		return nil
+	}
+	t.UpdatedUnix = now.Unix()
+	if t.ID == 0 {
+		_, err := x.Insert(t)
+		return err
+	}
+	_, err := x.ID(t.ID).AllCols().Update(t)
+	return err
+}
+
+// UpdateTrendingRepos updates stars and forks gained by all repositories in trending
+// periods and their primary languages.
+func UpdateTrendingRepos() {
+	if taskStatusTable.IsRunning(_UPDATE_TRENDING_REPOS) {
+		return
+	}
+	taskStatusTable.Start(_UPDATE_TRENDING_REPOS)
+	defer taskStatusTable.Stop(_UPDATE_TRENDING_REPOS)
+
+	log.Trace("Doing: UpdateTrendingRepos")
+
+	now := time.Now()
+	gains := make(map[TrendingPeriod]*repoGains, len(TrendingPeriods))
+	for _, p := range TrendingPeriods {
+		stars, forks, err := countRepoGains(now.Add(-p.Duration()).Unix())
+		if err != nil {
+			log.Error("Failed to count gains of repositories [period: %s]: %v", p, err)
+			return
+		}
+		gains[p] = &repoGains{
+			stars: stars,
+			forks: forks,
+		}
+	}
+
+	repos := make([]*Repository, 0, 100)
+	for start := 0; ; start += 100 {
+		repos = repos[:0]
+		if err := x.Where("id > 0").Asc("id").Limit(100, start).Find(&repos); err != nil {
+			log.Error("Failed to find repositories: %v", err)
+			return
+		}
+		if len(repos) == 0 {
+			break
+		}
+
+		for _, repo := range repos {
+			if err := updateRepoTrending(repo, gains, now); err != nil {
+				log.Error("Failed to update trending of repository [repo_id: %d]: %v", repo.ID, err)
+			}
+		}
+	}
+}
+
+// TrendingRepository is a repository with stars and forks gained in a trending period.
+type TrendingRepository struct {
+	*Repository
+	StarsGained int
+	ForksGained int
+}
+
+type TrendingRepoOptions struct {
+	Period   TrendingPeriod
+	Language string
+	UserID   int64 // Results only contain repositories the user has access to, zero for anonymous
+	Page     int
+	PageSize int
+}
+
+// GetTrendingRepos returns repositories that gained the most stars and forks in the
+// period, and the total number of repositories gained any.
+func GetTrendingRepos(opts *TrendingRepoOptions) ([]*TrendingRepository, int64, error) {
+	if opts.Page <= 0 {
+		opts.Page = 1
+	}
+
+	starsCol, forksCol := trendingColumns(opts.Period)
+	cond, args := repoAccessCond(opts.UserID)
+	newSession := func() *xorm.Session {
+		sess := x.Table("repo_trending").
+			Join("INNER", "repository", "repository.id = repo_trending.repo_id").
+			Where(cond, args...).
+			And(starsCol + " + " + forksCol + " > 0")
+		if opts.Language != "" {
+			sess.And("repo_trending.language = ?", opts.Language)
+		}
+		return sess
+	}
+
+	count, err := newSession().Count(new(RepoTrending))
+	if err != nil {
+		return nil, 0, fmt.Errorf("count: %v", err)
+	}
+
+	trendings := make([]*RepoTrending, 0, opts.PageSize)
+	if err = newSession().Select("repo_trending.*").
+		OrderBy(starsCol+" + "+forksCol+" DESC, "+starsCol+" DESC, repository.num_stars DESC").
+		Limit(opts.PageSize, (opts.Page-1)*opts.PageSize).Find(&trendings); err != nil {
+		return nil, 0, fmt.Errorf("find: %v", err)
+	}
+
+	repoIDs := make([]int64, len(trendings))
+	for i := range trendings {
+		repoIDs[i] = trendings[i].RepoID
+	}
+	repos := make([]*Repository, 0, len(repoIDs))
+	if len(repoIDs) > 0 {
+		if err = x.In("id", repoIDs).Find(&repos); err != nil {
+			return nil, 0, fmt.Errorf("find repositories: %v", err)
+		}
+	}
+	repoSet := make(map[int64]*Repository, len(repos))
+	for i := range repos {
+		repoSet[repos[i].ID] = repos[i]
+	}
+
+	results := make([]*TrendingRepository, 0, len(trendings))
+	for _, t := range trendings {
+		repo := repoSet[t.RepoID]
+		if repo == nil {
+			continue
+		}
+		stars, forks := t.gains(opts.Period)
+		results = append(results, &TrendingRepository{
+			Repository:  repo,
+			StarsGained: *stars,
+			ForksGained: *forks,
+		})
+	}
+	return results, count, nil
+}
+
+// GetTrendingLanguages returns primary languages of repositories, the languages used
+// by most repositories come first.
+func GetTrendingLanguages(limit int) ([]string, error) {
+	type languageCount struct {
+		Language string
+		Count    int
+	}
+	rows := make([]*languageCount, 0, limit)
+	if err := x.Table("repo_trending").Select("language, COUNT(*) AS count").
+		Where("language != ''").GroupBy("language").
+		OrderBy("count DESC, language ASC").Limit(limit).Find(&rows); err != nil {
+		return nil, err
+	}
+
+	languages := make([]string, len(rows))
+	for i := range rows {
+		languages[i] = rows[i].Language
+	}
+	return languages, nil
+}
@@ -0,0 +1,20 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_ParseTrendingPeriod(t *testing.T) {
+	Convey("Parse trending periods", t, func() {
+		So(ParseTrendingPeriod("weekly"), ShouldEqual, TRENDING_WEEKLY)
+		So(ParseTrendingPeriod("monthly"), ShouldEqual, TRENDING_MONTHLY)
+		So(ParseTrendingPeriod(""), ShouldEqual, TRENDING_DAILY)
+		So(ParseTrendingPeriod("yearly"), ShouldEqual, TRENDING_DAILY)
+	})
+}
@@ -0,0 +1,282 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	log "unknwon.dev/clog/v2"
+	"xorm.io/xorm"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/db/errors"
+	"gogs.io/gogs/internal/email"
+)
+
+// ReviewAssignStrategy is the strategy of a team to select reviewers automatically
+// from its members for new pull requests.
+type ReviewAssignStrategy int
+
+const (
+	REVIEW_ASSIGN_NONE        ReviewAssignStrategy = iota
+	REVIEW_ASSIGN_ROUND_ROBIN                      // Members take turns in order.
+	REVIEW_ASSIGN_LEAST_BUSY                       // Members with fewest pending reviews first.
+)
+
+// ParseReviewAssignStrategy returns the strategy of given value, or REVIEW_ASSIGN_NONE if invalid.
+func ParseReviewAssignStrategy(v int) ReviewAssignStrategy {
+	switch s := ReviewAssignStrategy(v); s {
+	case REVIEW_ASSIGN_ROUND_ROBIN, REVIEW_ASSIGN_LEAST_BUSY:
+		return s
+	}
+	return REVIEW_ASSIGN_NONE
+}
+
+// ReviewRequest represents a request for a user to review a pull request.
+type ReviewRequest struct {
+	ID         int64
+	RepoID     int64 `xorm:"INDEX"`
+	IssueID    int64 `xorm:"INDEX"`
+	ReviewerID int64 `xorm:"INDEX"`
+	Reviewer   *User `xorm:"-" json:"-"`
+	TeamID     int64 // The team that the reviewer is selected from.
+	IsDone     bool  `xorm:"INDEX NOT NULL DEFAULT false"`
+
+	Created      time.Time `xorm:"-" json:"-"`
+	CreatedUnix  int64
+	RemindedUnix int64
+}
+
+func (r *ReviewRequest) BeforeInsert() {
+	r.CreatedUnix = time.Now().Unix()
+}
+
+func (r *ReviewRequest) AfterSet(colName string, _ xorm.Cell) {
+	switch colName {
+	case "created_unix":
+		r.Created = time.Unix(r.CreatedUnix, 0).Local()
+	}
+}
+
+// countPendingReviewRequests returns the number of requested reviews of the user that
+// are not done and whose pull requests are still open.
+func countPendingReviewRequests(e Engine, reviewerID int64) (int64, error) {
+	return e.Where("review_request.reviewer_id = ? AND review_request.is_done = ?", reviewerID, false).
+		Join("INNER", "issue", "issue.id = review_request.issue_id").
+		And("issue.is_closed = ?", false).
+		Count(new(ReviewRequest))
+}
+
+// selectReviewers returns at most n candidates according to the strategy of the team.
+// Candidates must be sorted by ID.
+func (t *Team) selectReviewers(e Engine, candidates []*User, n int) ([]*User, error) {
+	if n > len(candidates) {
+		n = len(candidates)
+	}
+
+	switch t.ReviewStrategy {
+	case REVIEW_ASSIGN_ROUND_ROBIN:
+		// Start from the first member after the one who was selected last time.
+		start := 0
+		for i := range candidates {
+			if candidates[i].ID > t.LastReviewerID {
+				start = i
+				break
+			}
+		}
+		reviewers := make([]*User, n)
+		for i := 0; i < n; i++ {
+			reviewers[i] = candidates[(start+i)%len(candidates)]
+		}
+		return reviewers, nil
+
+	case REVIEW_ASSIGN_LEAST_BUSY:
+		pending := make(map[int64]int64, len(candidates))
+		for _, u := range candidates {
+			count, err := countPendingReviewRequests(e, u.ID)
+			if err != nil {
+				return nil, fmt.Errorf("countPendingReviewRequests [%d]: %v", u.ID, err)
+			}
+			pending[u.ID] = count
+		}
+		reviewers := make([]*User, len(candidates))
+		copy(reviewers, candidates)
+		sort.SliceStable(reviewers, func(i, j int) bool {
+			return pending[reviewers[i].ID] < pending[reviewers[j].ID]
+		})
+		return reviewers[:n], nil
+	}
+	return nil, nil
+}
+
+// assignTeamReviewers requests reviews of the pull request from members of the team
+// according to its strategy. The poster of the pull request is never selected.
+func (t *Team) assignTeamReviewers(e Engine, repo *Repository, pull *Issue) error {
+	members, err := getTeamMembers(e, t.ID)
+	if err != nil {
+		return fmt.Errorf("getTeamMembers: %v", err)
+	}
+
+	candidates := make([]*User, 0, len(members))
+	for _, u := range members {
+		if u.ID != pull.PosterID && u.IsActive && !u.ProhibitLogin && !u.IsDeactivated {
+			candidates = append(candidates, u)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].ID < candidates[j].ID
+	})
+
+	count := t.ReviewerCount
+	if count <= 0 {
+		count = 1
+	}
+	reviewers, err := t.selectReviewers(e, candidates, count)
+	if err != nil {
+		return err
+	}
+	requestedIDs := make([]int64, 0, len(reviewers))
+	for _, u := range reviewers {
+		has, err := e.Where("issue_id = ? AND reviewer_id = ?", pull.ID, u.ID).Get(new(ReviewRequest))
+		if err != nil {
+			return fmt.Errorf("get review request: %v", err)
+		} else if has {
+			continue
+		}
+
+		if _, err = e.Insert(&ReviewRequest{
+			RepoID:     pull.RepoID,
+			IssueID:    pull.ID,
+			ReviewerID: u.ID,
+			TeamID:     t.ID,
+		}); err != nil {
+			return fmt.Errorf("insert review request: %v", err)
+		}
+		t.LastReviewerID = u.ID
+		requestedIDs = append(requestedIDs, u.ID)
+	}
+
+	if err = notifyIssueUsers(e, repo, pull, 0, pull.PosterID, NOTIFICATION_REASON_REVIEW_REQUESTED, requestedIDs); err != nil {
+		return fmt.Errorf("notifyIssueUsers: %v", err)
+	}
+
+	if t.ReviewStrategy == REVIEW_ASSIGN_ROUND_ROBIN && len(reviewers) > 0 {
+		if _, err = e.ID(t.ID).Cols("last_reviewer_id").Update(t); err != nil {
+			return fmt.Errorf("update last reviewer: %v", err)
+		}
+	}
+	return nil
+}
+
+// AssignReviewers requests reviews of the pull request from teams of the organization
+// that have access to the repository and automatic reviewer selection enabled.
+func AssignReviewers(pull *Issue) error {
+	repo, err := GetRepositoryByID(pull.RepoID)
+	if err != nil {
+		return fmt.Errorf("GetRepositoryByID [%d]: %v", pull.RepoID, err)
+	} else if err = repo.GetOwner(); err != nil {
+		return fmt.Errorf("GetOwner: %v", err)
+	} else if !repo.Owner.IsOrganization() {
+		return nil
+	}
+
+	teams, err := GetTeamsByOrgID(repo.OwnerID)
+	if err != nil {
+		return fmt.Errorf("GetTeamsByOrgID [%d]: %v", repo.OwnerID, err)
+	}
+	for _, t := range teams {
+		if t.ReviewStrategy == REVIEW_ASSIGN_NONE || !t.HasRepository(repo.ID) {
+			continue
+		}
+		if err = t.assignTeamReviewers(x, repo, pull); err != nil {
+			return fmt.Errorf("assignTeamReviewers [team_id: %d]: %v", t.ID, err)
+		}
+	}
+	return nil
+}
+
+// GetReviewRequestsByIssueID returns all review requests of the pull request.
+func GetReviewRequestsByIssueID(issueID int64) ([]*ReviewRequest, error) {
+	requests := make([]*ReviewRequest, 0, 2)
+	if err := x.Where("issue_id = ?", issueID).Asc("id").Find(&requests); err != nil {
+		return nil, err
+	}
+
+	var err error
+	for _, r := range requests {
+		r.Reviewer, err = GetUserByID(r.ReviewerID)
+		if err != nil {
+			if !errors.IsUserNotExist(err) {
+				return nil, fmt.Errorf("GetUserByID [%d]: %v", r.ReviewerID, err)
+			}
+			r.Reviewer = getGhostUser(x, r.ReviewerID)
+		}
+	}
+	return requests, nil
+}
+
+// markReviewRequestDone marks the requested review of the user done for the pull request.
+func markReviewRequestDone(e Engine, issueID, reviewerID int64) error {
+	_, err := e.Where("issue_id = ? AND reviewer_id = ?", issueID, reviewerID).
+		Cols("is_done").
+		Update(&ReviewRequest{IsDone: true})
+	return err
+}
+
+// SendReviewReminders sends reminders to reviewers whose requested reviews have been
+// pending longer than configured days. A reminder is sent again after the same days.
+func SendReviewReminders() {
+	if taskStatusTable.IsRunning(_REVIEW_REMINDERS) {
+		return
+	}
+	taskStatusTable.Start(_REVIEW_REMINDERS)
+	defer taskStatusTable.Stop(_REVIEW_REMINDERS)
+
+	log.Trace("Doing: SendReviewReminders")
+
+	days := conf.Cron.ReviewReminders.PendingDays
+	if days <= 0 {
+		return
+	}
+	deadline := time.Now().AddDate(0, 0, -days).Unix()
+
+	requests := make([]*ReviewRequest, 0, 10)
+	if err := x.Join("INNER", "issue", "issue.id = review_request.issue_id").
+		Where("review_request.is_done = ? AND issue.is_closed = ?", false, false).
+		And("review_request.created_unix < ? AND review_request.reminded_unix < ?", deadline, deadline).
+		Find(&requests); err != nil {
+		log.Error("Failed to get pending review requests: %v", err)
+		return
+	}
+
+	for _, r := range requests {
+		reviewer, err := GetUserByID(r.ReviewerID)
+		if err != nil {
+			if !errors.IsUserNotExist(err) {
+				log.Error("GetUserByID [%d]: %v", r.ReviewerID, err)
+			}
+			continue
+		} else if !reviewer.IsMailable() {
+			continue
+		}
+		issue, err := GetIssueByID(r.IssueID)
+		if err != nil {
+			log.Error("GetIssueByID [%d]: %v", r.IssueID, err)
+			continue
+		}
+
+		email.SendReviewReminderMail(NewMailerUser(reviewer), NewMailerIssue(issue), NewMailerRepo(issue.Repo), int(time.Since(r.Created).Hours()/24))
+
+		r.RemindedUnix = time.Now().Unix()
+		if _, err = x.ID(r.ID).Cols("reminded_unix").Update(r); err != nil {
+			log.Error("Failed to update reminded time of review request [%d]: %v", r.ID, err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"xorm.io/builder"
+)
+
+// sitemapUserCond returns the condition of users and organizations listed in sitemap.
+func sitemapUserCond() builder.Cond {
+	return builder.Eq{
+		"is_active":      true,
+		"prohibit_login": false,
+		"is_deactivated": false,
+	}
+}
+
+// sitemapRepoCond returns the condition of repositories listed in sitemap.
+func sitemapRepoCond() builder.Cond {
+	return builder.Eq{
+		"is_private":       false,
+		"disable_indexing": false,
+	}
+}
+
+// CountSitemapUsers returns number of users and organizations listed in sitemap.
+func CountSitemapUsers() (int64, error) {
+	return x.Where(sitemapUserCond()).Count(new(User))
+}
+
+// GetSitemapUsers returns a page of users and organizations listed in sitemap.
+func GetSitemapUsers(page, pageSize int) ([]*User, error) {
+	users := make([]*User, 0, pageSize)
+	return users, x.Where(sitemapUserCond()).Asc("id").
+		Limit(pageSize, (page-1)*pageSize).Find(&users)
+}
+
+// CountSitemapRepositories returns number of repositories listed in sitemap.
+func CountSitemapRepositories() (int64, error) {
+	return x.Where(sitemapRepoCond()).Count(new(Repository))
+}
+
+// GetSitemapRepositories returns a page of repositories listed in sitemap with owners loaded.
+func GetSitemapRepositories(page, pageSize int) ([]*Repository, error) {
+	repos := make([]*Repository, 0, pageSize)
+	if err := x.Where(sitemapRepoCond()).Asc("id").
+		Limit(pageSize, (page-1)*pageSize).Find(&repos); err != nil {
+		return nil, err
+	}
+	return repos, RepositoryList(repos).LoadAttributes()
+}
+
+// sitemapReleaseCond returns the condition of published releases whose repositories are listed in sitemap.
+func sitemapReleaseCond() builder.Cond {
+	return builder.Eq{"is_draft": false}.
+		And(builder.In("repo_id", builder.Select("id").From("repository").Where(sitemapRepoCond())))
+}
+
+// CountSitemapReleaseRepositories returns number of repositories that have releases listed in sitemap.
+func CountSitemapReleaseRepositories() (int64, error) {
+	return x.Where(sitemapReleaseCond()).Distinct("repo_id").Count(new(Release))
+}
+
+// SitemapReleases is the releases page of a repository listed in sitemap.
+type SitemapReleases struct {
+	Repo    *Repository
+	Updated time.Time // Time of the latest release.
+}
+
+// GetSitemapReleases returns a page of releases pages of repositories listed in sitemap.
+func GetSitemapReleases(page, pageSize int) ([]*SitemapReleases, error) {
+	type latestRelease struct {
+		RepoID     int64
+		LatestUnix int64
+	}
+	latests := make([]*latestRelease, 0, pageSize)
+	if err := x.Table(new(Release)).Select("repo_id, MAX(created_unix) AS latest_unix").
+		Where(sitemapReleaseCond()).GroupBy("repo_id").Asc("repo_id").
+		Limit(pageSize, (page-1)*pageSize).Find(&latests); err != nil {
+		return nil, fmt.Errorf("get latest releases: %v", err)
+	} else if len(latests) == 0 {
+		return nil, nil
+	}
+
+	repoIDs := make([]int64, len(latests))
+	for i := range latests {
+		repoIDs[i] = latests[i].RepoID
+	}
+	repos := make([]*Repository, 0, len(repoIDs))
+	if err := x.In("id", repoIDs).Find(&repos); err != nil {
+		return nil, fmt.Errorf("get repositories: %v", err)
+	}
+	if err := RepositoryList(repos).LoadAttributes(); err != nil {
+		return nil, fmt.Errorf("LoadAttributes: %v", err)
+	}
+	repoSet := make(map[int64]*Repository, len(repos))
+	for i := range repos {
+		repoSet[repos[i].ID] = repos[i]
+	}
+
+	releases := make([]*SitemapReleases, 0, len(latests))
+	for _, latest := range latests {
+		repo := repoSet[latest.RepoID]
+		if repo == nil {
+			continue
+		}
+		releases = append(releases, &SitemapReleases{
+			Repo:    repo,
+			Updated: time.Unix(latest.LatestUnix, 0),
+		})
+	}
+	return releases, nil
+}
@@ -0,0 +1,264 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+
+	log "unknwon.dev/clog/v2"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/email"
+)
+
+// Reasons why a public key is considered weak.
+const (
+	KEY_WEAKNESS_DSA           = "dsa"
+	KEY_WEAKNESS_SHORT_RSA     = "short_rsa"
+	KEY_WEAKNESS_NOT_ALLOWED   = "not_allowed"
+	KEY_WEAKNESS_BELOW_MINIMUM = "below_minimum"
+	KEY_WEAKNESS_UNKNOWN       = "unknown"
+)
+
+// KeyAudit contains audit information of a public key.
+type KeyAudit struct {
+	*PublicKey
+	Owner *User // Nil for deploy keys
+	// Repos is the list of repositories that use the deploy key.
+	Repos     []*Repository
+	Algorithm string
+	Bits      int
+	// Weakness is the reason why the key is considered weak, or empty if not.
+	Weakness string
+}
+
+// IsWeak returns true if the key is considered weak.
+func (a *KeyAudit) IsWeak() bool {
+	return a.Weakness != ""
+}
+
+// keyWeakness returns the reason why a key of given algorithm and bit length is
+// considered weak, or empty string if the key is not weak. Besides DSA and RSA keys
+// shorter than 2048 bits, keys not meeting configured minimum key sizes are weak.
+func keyWeakness(algo string, bits int) string {
+	switch {
+	case algo == "":
+		return KEY_WEAKNESS_UNKNOWN
+	case algo == "dsa":
+		return KEY_WEAKNESS_DSA
+	case algo == "rsa" && bits < 2048:
+		return KEY_WEAKNESS_SHORT_RSA
+	}
+
+	if conf.SSH.MinimumKeySizeCheck && len(conf.SSH.MinimumKeySizes) > 0 {
+		minLen, found := conf.SSH.MinimumKeySizes[algo]
+		if !found {
+			return KEY_WEAKNESS_NOT_ALLOWED
+		} else if bits < minLen {
+			return KEY_WEAKNESS_BELOW_MINIMUM
+		}
+	}
+	return ""
+}
+
+// auditPublicKeys returns audit information of given public keys with their owners
+// and repositories of deploy keys loaded.
+func auditPublicKeys(keys []*PublicKey) ([]*KeyAudit, error) {
+	ownerIDs := make([]int64, 0, len(keys))
+	deployKeyIDs := make([]int64, 0, len(keys))
+	for _, k := range keys {
+		if k.IsDeployKey() {
+			deployKeyIDs = append(deployKeyIDs, k.ID)
+		} else {
+			ownerIDs = append(ownerIDs, k.OwnerID)
+		}
+	}
+
+	owners, err := GetUsersByIDs(ownerIDs)
+	if err != nil {
+		return nil, fmt.Errorf("GetUsersByIDs: %v", err)
+	}
+	ownerSet := make(map[int64]*User, len(owners))
+	for _, u := range owners {
+		ownerSet[u.ID] = u
+	}
+
+	keyRepos := make(map[int64][]*Repository)
+	if len(deployKeyIDs) > 0 {
+		deployKeys := make([]*DeployKey, 0, len(deployKeyIDs))
+		if err = x.In("key_id", deployKeyIDs).Find(&deployKeys); err != nil {
+			return nil, fmt.Errorf("find deploy keys: %v", err)
+		}
+		repoIDs := make([]int64, len(deployKeys))
+		for i := range deployKeys {
+			repoIDs[i] = deployKeys[i].RepoID
+		}
+		repos := make([]*Repository, 0, len(repoIDs))
+		if err = x.In("id", repoIDs).Find(&repos); err != nil {
+			return nil, fmt.Errorf("find repositories: %v", err)
+		} else if err = RepositoryList(repos).LoadAttributes(); err != nil {
+			return nil, fmt.Errorf("LoadAttributes: %v", err)
+		}
+		repoSet := make(map[int64]*Repository, len(repos))
+		for _, repo := range repos {
+			repoSet[repo.ID] = repo
+		}
+		for _, k := range deployKeys {
+			if repo := repoSet[k.RepoID]; repo != nil {
+				keyRepos[k.KeyID] = append(keyRepos[k.KeyID], repo)
+			}
+		}
+	}
+
+	audits := make([]*KeyAudit, len(keys))
+	for i, k := range keys {
+		a := &KeyAudit{
+			PublicKey: k,
+			Owner:     ownerSet[k.OwnerID],
+			Repos:     keyRepos[k.ID],
+		}
+		if k.IsDeployKey() {
+			a.Owner = nil
+		}
+		if algo, bits, err := SSHNativeParsePublicKey(k.Content); err != nil {
+			log.Trace("Failed to parse public key [id: %d]: %v", k.ID, err)
+		} else {
+			a.Algorithm, a.Bits = algo, bits
+		}
+		a.Weakness = keyWeakness(a.Algorithm, a.Bits)
+		audits[i] = a
+	}
+	return audits, nil
+}
+
+// AuditPublicKeys returns audit information of all public keys in the order of
+// creation, only weak keys are returned if weakOnly is true.
+func AuditPublicKeys(weakOnly bool) ([]*KeyAudit, error) {
+	keys := make([]*PublicKey, 0, 10)
+	if err := x.Asc("id").Find(&keys); err != nil {
+		return nil, fmt.Errorf("find public keys: %v", err)
+	}
+
+	audits, err := auditPublicKeys(keys)
+	if err != nil {
+		return nil, err
+	} else if !weakOnly {
+		return audits, nil
+	}
+
+	weak := audits[:0]
+	for _, a := range audits {
+		if a.IsWeak() {
+			weak = append(weak, a)
+		}
+	}
+	return weak, nil
+}
+
+// GetKeyAudits returns audit information of public keys with given IDs, non-existent
+// IDs are ignored.
+func GetKeyAudits(ids []int64) ([]*KeyAudit, error) {
+	keys := make([]*PublicKey, 0, len(ids))
+	if len(ids) > 0 {
+		if err := x.In("id", ids).Asc("id").Find(&keys); err != nil {
+			return nil, fmt.Errorf("find public keys: %v", err)
+		}
+	}
+	return auditPublicKeys(keys)
+}
+
+// RevokePublicKeys deletes public keys with given IDs, including deploy keys from all
+// repositories that use them, and returns audit information of revoked keys.
+func RevokePublicKeys(ids []int64) ([]*KeyAudit, error) {
+	audits, err := GetKeyAudits(ids)
+	if err != nil {
+		return nil, err
+	} else if len(audits) == 0 {
+		return audits, nil
+	}
+
+	keyIDs := make([]int64, len(audits))
+	for i := range audits {
+		keyIDs[i] = audits[i].ID
+	}
+
+	sess := x.NewSession()
+	defer sess.Close()
+	if err = sess.Begin(); err != nil {
+		return nil, err
+	}
+
+	if _, err = sess.In("key_id", keyIDs).Delete(new(DeployKey)); err != nil {
+		return nil, fmt.Errorf("delete deploy keys: %v", err)
+	} else if err = deletePublicKeys(sess, keyIDs...); err != nil {
+		return nil, fmt.Errorf("deletePublicKeys: %v", err)
+	}
+
+	if err = sess.Commit(); err != nil {
+		return nil, err
+	}
+	return audits, RewriteAuthorizedKeys()
+}
+
+// keyAuditRecipients returns users to be notified about the key, which is the owner
+// of a user key, or owners of repositories that use a deploy key.
+func keyAuditRecipients(a *KeyAudit) []*User {
+	if a.Owner != nil {
+		return []*User{a.Owner}
+	}
+
+	users := make([]*User, 0, len(a.Repos))
+	for _, repo := range a.Repos {
+		if !repo.Owner.IsOrganization() {
+			users = append(users, repo.Owner)
+			continue
+		}
+
+		t, err := repo.Owner.GetOwnerTeam()
+		if err != nil {
+			log.Error("GetOwnerTeam [org_id: %d]: %v", repo.Owner.ID, err)
+			continue
+		} else if err = t.GetMembers(); err != nil {
+			log.Error("GetMembers [team_id: %d]: %v", t.ID, err)
+			continue
+		}
+		users = append(users, t.Members...)
+	}
+	return users
+}
+
+// NotifyKeyOwners sends an email to each owner of given keys, which asks for replacing
+// the keys, or tells the keys have been revoked. It returns the number of users notified.
+func NotifyKeyOwners(audits []*KeyAudit, revoked bool) int {
+	users := make(map[int64]*User)
+	userKeys := make(map[int64][]*email.AuditedKey)
+	for _, a := range audits {
+		key := &email.AuditedKey{
+			Name:        a.Name,
+			Fingerprint: a.Fingerprint,
+			Algorithm:   a.Algorithm,
+			Bits:        a.Bits,
+		}
+		for _, repo := range a.Repos {
+			key.Repos = append(key.Repos, repo.FullName())
+		}
+
+		// The same user may own multiple repositories that use a deploy key.
+		seen := make(map[int64]bool)
+		for _, u := range keyAuditRecipients(a) {
+			if seen[u.ID] || !u.IsMailable() {
+				continue
+			}
+			seen[u.ID] = true
+			users[u.ID] = u
+			userKeys[u.ID] = append(userKeys[u.ID], key)
+		}
+	}
+
+	for id, u := range users {
+		email.SendKeyAuditMail(NewMailerUser(u), userKeys[id], revoked)
+	}
+	return len(users)
+}
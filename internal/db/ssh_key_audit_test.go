@@ -0,0 +1,39 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"gogs.io/gogs/internal/conf"
+)
+
+func Test_keyWeakness(t *testing.T) {
+	Convey("Find weakness of public keys", t, func() {
+		conf.SSH.MinimumKeySizeCheck = false
+		So(keyWeakness("", 0), ShouldEqual, KEY_WEAKNESS_UNKNOWN)
+		So(keyWeakness("dsa", 1024), ShouldEqual, KEY_WEAKNESS_DSA)
+		So(keyWeakness("rsa", 1024), ShouldEqual, KEY_WEAKNESS_SHORT_RSA)
+		So(keyWeakness("rsa", 2048), ShouldBeEmpty)
+		So(keyWeakness("ecdsa", 256), ShouldBeEmpty)
+		So(keyWeakness("ed25519", 256), ShouldBeEmpty)
+
+		Convey("With minimum key sizes", func() {
+			conf.SSH.MinimumKeySizeCheck = true
+			conf.SSH.MinimumKeySizes = map[string]int{"rsa": 3072, "ed25519": 256}
+			defer func() {
+				conf.SSH.MinimumKeySizeCheck = false
+				conf.SSH.MinimumKeySizes = nil
+			}()
+
+			So(keyWeakness("rsa", 2048), ShouldEqual, KEY_WEAKNESS_BELOW_MINIMUM)
+			So(keyWeakness("rsa", 4096), ShouldBeEmpty)
+			So(keyWeakness("ecdsa", 256), ShouldEqual, KEY_WEAKNESS_NOT_ALLOWED)
+			So(keyWeakness("ed25519", 256), ShouldBeEmpty)
+		})
+	})
+}
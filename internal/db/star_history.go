@@ -0,0 +1,159 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"time"
+
+	log "unknwon.dev/clog/v2"
+	"xorm.io/xorm"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/email"
+)
+
+// StarEvent is a record of a user starring or unstarring a repository.
+type StarEvent struct {
+	ID     int64
+	RepoID int64 `xorm:"INDEX"`
+	UserID int64
+	IsStar bool
+	// NumStars is the number of stars of the repository right after the event.
+	NumStars int
+
+	Created     time.Time `xorm:"-" json:"-"`
+	CreatedUnix int64     `xorm:"INDEX"`
+}
+
+func (e *StarEvent) BeforeInsert() {
+	if e.CreatedUnix == 0 {
+		e.CreatedUnix = time.Now().Unix()
+	}
+}
+
+func (e *StarEvent) AfterSet(colName string, _ xorm.Cell) {
+	switch colName {
+	case "created_unix":
+		e.Created = time.Unix(e.CreatedUnix, 0).Local()
+	}
+}
+
+// recordStarEvent records the user starring or unstarring the repository, and notifies
+// owners of the repository when it reaches a configured number of stars.
+func recordStarEvent(userID, repoID int64, isStar bool) error {
+	repo, err := getRepositoryByID(x, repoID)
+	if err != nil {
+		return fmt.Errorf("getRepositoryByID: %v", err)
+	}
+
+	event := &StarEvent{
+		RepoID:   repoID,
+		UserID:   userID,
+		IsStar:   isStar,
+		NumStars: repo.NumStars,
+	}
+	if _, err = x.Insert(event); err != nil {
+		return fmt.Errorf("insert: %v", err)
+	}
+
+	if !isStar {
+		return nil
+	}
+	reached, err := isStarThresholdReached(event)
+	if err != nil {
+		return fmt.Errorf("isStarThresholdReached: %v", err)
+	} else if reached {
+		notifyStarThreshold(repo)
+	}
+	return nil
+}
+
+// isStarThresholdReached returns true if the repository reaches one of configured
+// numbers of stars by the event for the first time. Stars may be taken back and given
+// again, only the first time counts.
+func isStarThresholdReached(event *StarEvent) (bool, error) {
+	for _, threshold := range conf.Repository.StarNotifyThresholds {
+		if threshold != event.NumStars {
+			continue
+		}
+
+		count, err := x.Where("repo_id = ? AND id < ? AND num_stars >= ?", event.RepoID, event.ID, threshold).Count(new(StarEvent))
+		if err != nil {
+			return false, err
+		}
+		return count == 0, nil
+	}
+	return false, nil
+}
+
+// notifyStarThreshold sends mail notifications to owners of the repository about its
+// current number of stars.
+func notifyStarThreshold(repo *Repository) {
+	if err := repo.GetOwner(); err != nil {
+		log.Error("GetOwner [repo_id: %d]: %v", repo.ID, err)
+		return
+	}
+
+	owners := []*User{repo.Owner}
+	if repo.Owner.IsOrganization() {
+		t, err := repo.Owner.GetOwnerTeam()
+		if err != nil {
+			log.Error("GetOwnerTeam [org_id: %d]: %v", repo.Owner.ID, err)
+			return
+		} else if err = t.GetMembers(); err != nil {
+			log.Error("GetMembers [team_id: %d]: %v", t.ID, err)
+			return
+		}
+		owners = t.Members
+	}
+
+	for _, u := range owners {
+		if !u.IsMailable() {
+			continue
+		}
+		email.SendStarThresholdMail(NewMailerUser(u), NewMailerRepo(repo), repo.NumStars)
+	}
+}
+
+// StarHistory represents the number of stars of a repository at the end of a day.
+type StarHistory struct {
+	Date  time.Time `json:"date"`
+	Stars int       `json:"stars"`
+}
+
+// starHistory returns daily numbers of stars from the day of first event until the end.
+// Events must be in chronological order.
+func starHistory(events []*StarEvent, end time.Time) []*StarHistory {
+	if len(events) == 0 {
+		return []*StarHistory{}
+	}
+
+	end = truncateDay(end)
+	var stars int
+	history := make([]*StarHistory, 0, 30)
+	day := truncateDay(events[0].Created)
+	for i := 0; !day.After(end); day = day.AddDate(0, 0, 1) {
+		next := day.AddDate(0, 0, 1)
+		for ; i < len(events) && events[i].Created.Before(next); i++ {
+			stars = events[i].NumStars
+		}
+
+		history = append(history, &StarHistory{
+			Date:  day,
+			Stars: stars,
+		})
+	}
+	return history
+}
+
+// StarHistory returns daily numbers of stars of the repository since it was first starred.
+func (repo *Repository) StarHistory() ([]*StarHistory, error) {
+	events := make([]*StarEvent, 0, repo.NumStars)
+	if err := x.Where("repo_id = ?", repo.ID).Asc("created_unix", "id").Find(&events); err != nil {
+		return nil, fmt.Errorf("find events: %v", err)
+	}
+	return starHistory(events, time.Now()), nil
+}
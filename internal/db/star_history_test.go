@@ -0,0 +1,36 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_starHistory(t *testing.T) {
+	Convey("Compute daily numbers of stars", t, func() {
+		day := time.Date(2020, 3, 1, 0, 0, 0, 0, time.Local)
+		events := []*StarEvent{
+			{NumStars: 1, Created: day.Add(time.Hour)},
+			{NumStars: 2, Created: day.Add(2 * time.Hour)},
+			{NumStars: 1, Created: day.AddDate(0, 0, 2)},
+			{NumStars: 2, Created: day.AddDate(0, 0, 2).Add(time.Hour)},
+			{NumStars: 3, Created: day.AddDate(0, 0, 3).Add(time.Hour)},
+		}
+
+		history := starHistory(events, day.AddDate(0, 0, 4).Add(time.Hour))
+		stars := make([]int, len(history))
+		for i := range history {
+			stars[i] = history[i].Stars
+		}
+		So(stars, ShouldResemble, []int{2, 2, 2, 3, 3})
+		So(history[0].Date, ShouldEqual, day)
+		So(history[4].Date, ShouldEqual, day.AddDate(0, 0, 4))
+
+		So(starHistory(nil, day), ShouldBeEmpty)
+	})
+}
@@ -0,0 +1,225 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"xorm.io/xorm"
+
+	"gogs.io/gogs/internal/db/errors"
+)
+
+// StarList represents a list defined by a user to group repositories starred by the user.
+type StarList struct {
+	ID          int64
+	UserID      int64  `xorm:"UNIQUE(s)"`
+	Name        string `xorm:"NOT NULL"`
+	LowerName   string `xorm:"UNIQUE(s) NOT NULL"`
+	Description string
+	NumRepos    int `xorm:"-" json:"-"`
+
+	Created     time.Time `xorm:"-" json:"-"`
+	CreatedUnix int64
+	Updated     time.Time `xorm:"-" json:"-"`
+	UpdatedUnix int64
+}
+
+func (l *StarList) BeforeInsert() {
+	l.CreatedUnix = time.Now().Unix()
+	l.UpdatedUnix = l.CreatedUnix
+}
+
+func (l *StarList) BeforeUpdate() {
+	l.UpdatedUnix = time.Now().Unix()
+}
+
+func (l *StarList) AfterSet(colName string, _ xorm.Cell) {
+	switch colName {
+	case "created_unix":
+		l.Created = time.Unix(l.CreatedUnix, 0).Local()
+	case "updated_unix":
+		l.Updated = time.Unix(l.UpdatedUnix, 0).Local()
+	}
+}
+
+// StarListRepo represents a repository that is put in a star list.
+type StarListRepo struct {
+	ID     int64
+	UserID int64 `xorm:"INDEX"`
+	ListID int64 `xorm:"UNIQUE(s)"`
+	RepoID int64 `xorm:"UNIQUE(s) INDEX"`
+}
+
+// isStarListExist returns true if the user has a star list with given name,
+// except the list with given ID.
+func isStarListExist(userID, listID int64, name string) (bool, error) {
+	return x.Where("user_id = ? AND lower_name = ? AND id != ?", userID, strings.ToLower(name), listID).Get(new(StarList))
+}
+
+// NewStarList creates a new star list of the user.
+func NewStarList(l *StarList) error {
+	has, err := isStarListExist(l.UserID, 0, l.Name)
+	if err != nil {
+		return fmt.Errorf("isStarListExist: %v", err)
+	} else if has {
+		return errors.StarListAlreadyExist{Name: l.Name}
+	}
+
+	l.LowerName = strings.ToLower(l.Name)
+	_, err = x.Insert(l)
+	return err
+}
+
+// GetStarListByID returns the star list with given ID of the user.
+func GetStarListByID(userID, id int64) (*StarList, error) {
+	l := new(StarList)
+	has, err := x.Where("id = ? AND user_id = ?", id, userID).Get(l)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		return nil, errors.StarListNotExist{ID: id, UserID: userID}
+	}
+	return l, nil
+}
+
+// GetStarLists returns all star lists of the user in the order of names, along with
+// numbers of repositories in lists. Private repositories are counted only if private
+// is true.
+func GetStarLists(userID int64, private bool) ([]*StarList, error) {
+	lists := make([]*StarList, 0, 5)
+	if err := x.Where("user_id = ?", userID).Asc("lower_name").Find(&lists); err != nil {
+		return nil, err
+	}
+
+	for _, l := range lists {
+		sess := x.Where("star_list_repo.list_id = ?", l.ID)
+		if !private {
+			sess.Join("INNER", "repository", "repository.id = star_list_repo.repo_id").
+				And("repository.is_private = ?", false)
+		}
+		count, err := sess.Count(new(StarListRepo))
+		if err != nil {
+			return nil, fmt.Errorf("count repositories of list %d: %v", l.ID, err)
+		}
+		l.NumRepos = int(count)
+	}
+	return lists, nil
+}
+
+// UpdateStarList updates name and description of the star list.
+func UpdateStarList(l *StarList) error {
+	has, err := isStarListExist(l.UserID, l.ID, l.Name)
+	if err != nil {
+		return fmt.Errorf("isStarListExist: %v", err)
+	} else if has {
+		return errors.StarListAlreadyExist{Name: l.Name}
+	}
+
+	l.LowerName = strings.ToLower(l.Name)
+	_, err = x.ID(l.ID).Cols("name", "lower_name", "description").Update(l)
+	return err
+}
+
+// DeleteStarList deletes the star list, repositories in the list remain starred.
+func DeleteStarList(l *StarList) (err error) {
+	sess := x.NewSession()
+	defer sess.Close()
+	if err = sess.Begin(); err != nil {
+		return err
+	}
+
+	if _, err = sess.Delete(&StarListRepo{ListID: l.ID}); err != nil {
+		return fmt.Errorf("delete repositories of list: %v", err)
+	} else if _, err = sess.ID(l.ID).Delete(new(StarList)); err != nil {
+		return fmt.Errorf("delete list: %v", err)
+	}
+	return sess.Commit()
+}
+
+// AddRepo puts the repository in the star list, and stars the repository for the
+// owner of the list if it is not starred yet.
+func (l *StarList) AddRepo(repoID int64) error {
+	if err := StarRepo(l.UserID, repoID, true); err != nil {
+		return fmt.Errorf("StarRepo: %v", err)
+	}
+
+	has, err := x.Get(&StarListRepo{ListID: l.ID, RepoID: repoID})
+	if err != nil {
+		return err
+	} else if has {
+		return nil
+	}
+	_, err = x.Insert(&StarListRepo{
+		UserID: l.UserID,
+		ListID: l.ID,
+		RepoID: repoID,
+	})
+	return err
+}
+
+// RemoveRepo removes the repository from the star list, the repository remains starred.
+func (l *StarList) RemoveRepo(repoID int64) error {
+	_, err := x.Delete(&StarListRepo{ListID: l.ID, RepoID: repoID})
+	return err
+}
+
+// removeRepoFromStarLists removes the repository from all star lists of the user.
+func removeRepoFromStarLists(e Engine, userID, repoID int64) error {
+	_, err := e.Delete(&StarListRepo{UserID: userID, RepoID: repoID})
+	return err
+}
+
+// GetStarListIDsOfRepo returns IDs of star lists of the user that the repository is in.
+func GetStarListIDsOfRepo(userID, repoID int64) ([]int64, error) {
+	listIDs := make([]int64, 0, 5)
+	return listIDs, x.Table("star_list_repo").Where("user_id = ? AND repo_id = ?", userID, repoID).Cols("list_id").Find(&listIDs)
+}
+
+// StarredReposOptions contains options to list repositories starred by a user.
+type StarredReposOptions struct {
+	UserID int64
+	// ListID is the star list that repositories are in, zero means all starred
+	// repositories.
+	ListID   int64
+	Private  bool
+	Page     int
+	PageSize int
+}
+
+func (opts *StarredReposOptions) session() *xorm.Session {
+	var sess *xorm.Session
+	if opts.ListID > 0 {
+		sess = x.Join("INNER", "star_list_repo", "star_list_repo.repo_id = repository.id").
+			Where("star_list_repo.list_id = ?", opts.ListID)
+	} else {
+		sess = x.Join("INNER", "star", "star.repo_id = repository.id").
+			Where("star.uid = ?", opts.UserID)
+	}
+	if !opts.Private {
+		sess.And("repository.is_private = ?", false)
+	}
+	return sess
+}
+
+// GetStarredRepos returns repositories starred by the user, latest updated first.
+func GetStarredRepos(opts *StarredReposOptions) ([]*Repository, error) {
+	if opts.Page <= 0 {
+		opts.Page = 1
+	}
+	repos := make([]*Repository, 0, opts.PageSize)
+	if err := opts.session().Desc("repository.updated_unix").
+		Limit(opts.PageSize, (opts.Page-1)*opts.PageSize).Find(&repos); err != nil {
+		return nil, fmt.Errorf("find repositories: %v", err)
+	}
+	return repos, RepositoryList(repos).LoadAttributes()
+}
+
+// CountStarredRepos returns the number of repositories starred by the user.
+func CountStarredRepos(opts *StarredReposOptions) (int64, error) {
+	return opts.session().Count(new(Repository))
+}
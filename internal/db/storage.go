@@ -0,0 +1,32 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/storage"
+)
+
+// blobStore returns the blob store of given name, files are saved under the root
+// directory in local file system, or with the prefix in names of S3 objects.
+func blobStore(name, localRoot, s3Prefix string) (storage.Store, error) {
+	switch name {
+	case "", storage.LOCAL:
+		return storage.NewLocalStore(localRoot), nil
+	case storage.S3:
+		return storage.NewS3Store(storage.S3Options{
+			Endpoint:        conf.S3.Endpoint,
+			Region:          conf.S3.Region,
+			Bucket:          conf.S3.Bucket,
+			AccessKeyID:     conf.S3.AccessKeyID,
+			SecretAccessKey: conf.S3.SecretAccessKey,
+			PathStyle:       conf.S3.PathStyle,
+			Prefix:          s3Prefix,
+		}), nil
+	}
+	return nil, fmt.Errorf("unsupported storage %q", name)
+}
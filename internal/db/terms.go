@@ -0,0 +1,77 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"xorm.io/xorm"
+
+	"gogs.io/gogs/internal/conf"
+)
+
+// TermsAcceptance represents a record of a user accepting a version of the terms.
+type TermsAcceptance struct {
+	ID          int64
+	UserID      int64     `xorm:"UNIQUE(s)"`
+	Version     string    `xorm:"UNIQUE(s)"`
+	Created     time.Time `xorm:"-" json:"-"`
+	CreatedUnix int64
+}
+
+func (a *TermsAcceptance) BeforeInsert() {
+	a.CreatedUnix = time.Now().Unix()
+}
+
+func (a *TermsAcceptance) AfterSet(colName string, _ xorm.Cell) {
+	switch colName {
+	case "created_unix":
+		a.Created = time.Unix(a.CreatedUnix, 0).Local()
+	}
+}
+
+// HasAcceptedTerms returns true if terms are not enabled or the user has accepted
+// the current version of the terms.
+func (u *User) HasAcceptedTerms() bool {
+	return !conf.Terms.Enabled || u.AcceptedTermsVersion == conf.Terms.Version
+}
+
+// AcceptTerms records the user accepting given version of the terms.
+func AcceptTerms(u *User, version string) (err error) {
+	sess := x.NewSession()
+	defer sess.Close()
+	if err = sess.Begin(); err != nil {
+		return err
+	}
+
+	has, err := sess.Get(&TermsAcceptance{UserID: u.ID, Version: version})
+	if err != nil {
+		return err
+	} else if !has {
+		if _, err = sess.Insert(&TermsAcceptance{UserID: u.ID, Version: version}); err != nil {
+			return fmt.Errorf("insert acceptance: %v", err)
+		}
+	}
+
+	u.AcceptedTermsVersion = version
+	if _, err = sess.ID(u.ID).Cols("accepted_terms_version").Update(u); err != nil {
+		return fmt.Errorf("update user: %v", err)
+	}
+	return sess.Commit()
+}
+
+// GetTermsAcceptance returns the record of the user accepting given version of the terms.
+// It returns nil if the user has not accepted the version.
+func GetTermsAcceptance(userID int64, version string) (*TermsAcceptance, error) {
+	a := &TermsAcceptance{UserID: userID, Version: version}
+	has, err := x.Get(a)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		return nil, nil
+	}
+	return a, nil
+}
@@ -0,0 +1,226 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"xorm.io/xorm"
+)
+
+const (
+	// MaxTopicsPerRepo is the max number of topics a repository can have.
+	MaxTopicsPerRepo = 25
+	// maxTopicNameLength is the max length of a topic name.
+	maxTopicNameLength = 35
+)
+
+var topicPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// IsValidTopic returns true if given name is a valid topic, which consists of lowercase
+// letters, numbers and hyphens and starts with a letter or number.
+func IsValidTopic(name string) bool {
+	return len(name) <= maxTopicNameLength && topicPattern.MatchString(name)
+}
+
+// SanitizeTopics returns lowercased and deduplicated topics in the original order, and
+// the invalid topics if any.
+func SanitizeTopics(names []string) (valid, invalid []string) {
+	seen := make(map[string]bool, len(names))
+	valid = make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		if IsValidTopic(name) {
+			valid = append(valid, name)
+		} else {
+			invalid = append(invalid, name)
+		}
+	}
+	return valid, invalid
+}
+
+// Topic is a free-form label of repositories used for discovery.
+type Topic struct {
+	ID        int64
+	Name      string `xorm:"UNIQUE NOT NULL"`
+	RepoCount int    `xorm:"INDEX NOT NULL DEFAULT 0"`
+
+	Created     time.Time `xorm:"-" json:"-"`
+	CreatedUnix int64
+	Updated     time.Time `xorm:"-" json:"-"`
+	UpdatedUnix int64
+}
+
+func (t *Topic) BeforeInsert() {
+	t.CreatedUnix = time.Now().Unix()
+	t.UpdatedUnix = t.CreatedUnix
+}
+
+func (t *Topic) AfterSet(colName string, _ xorm.Cell) {
+	switch colName {
+	case "created_unix":
+		t.Created = time.Unix(t.CreatedUnix, 0).Local()
+	case "updated_unix":
+		t.Updated = time.Unix(t.UpdatedUnix, 0).Local()
+	}
+}
+
+// RepoTopic represents a topic of a repository.
+type RepoTopic struct {
+	ID      int64
+	RepoID  int64 `xorm:"UNIQUE(s) NOT NULL"`
+	TopicID int64 `xorm:"UNIQUE(s) INDEX NOT NULL"`
+}
+
+func getRepoTopics(e Engine, repoID int64) ([]*Topic, error) {
+	topics := make([]*Topic, 0, 5)
+	return topics, e.Where("id IN (SELECT topic_id FROM `repo_topic` WHERE repo_id = ?)", repoID).
+		Asc("name").Find(&topics)
+}
+
+// GetRepoTopicNames returns names of topics of the repository in alphabetical order.
+func GetRepoTopicNames(repoID int64) ([]string, error) {
+	topics, err := getRepoTopics(x, repoID)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(topics))
+	for i := range topics {
+		names[i] = topics[i].Name
+	}
+	return names, nil
+}
+
+// updateTopicRepoCounts recalculates the number of repositories of given topics.
+func updateTopicRepoCounts(e Engine, topicIDs []int64) error {
+	for _, id := range topicIDs {
+		if _, err := e.Exec("UPDATE `topic` SET repo_count=(SELECT COUNT(*) FROM `repo_topic` WHERE topic_id=?), updated_unix=? WHERE id=?", id, time.Now().Unix(), id); err != nil {
+			return fmt.Errorf("update repo count of topic [%d]: %v", id, err)
+		}
+	}
+	return nil
+}
+
+// deleteRepoTopics removes all topics from the repository.
+func deleteRepoTopics(e Engine, repoID int64) error {
+	topics, err := getRepoTopics(e, repoID)
+	if err != nil {
+		return fmt.Errorf("getRepoTopics: %v", err)
+	}
+	if _, err = e.Delete(&RepoTopic{RepoID: repoID}); err != nil {
+		return fmt.Errorf("delete repo topics: %v", err)
+	}
+
+	topicIDs := make([]int64, len(topics))
+	for i := range topics {
+		topicIDs[i] = topics[i].ID
+	}
+	return updateTopicRepoCounts(e, topicIDs)
+}
+
+// SaveRepoTopics replaces topics of the repository with given names, which should have
+// been sanitized by SanitizeTopics. Topics that do not exist yet are created.
+func SaveRepoTopics(repoID int64, names []string) (err error) {
+	if len(names) > MaxTopicsPerRepo {
+		return fmt.Errorf("too many topics: %d", len(names))
+	}
+
+	sess := x.NewSession()
+	defer sess.Close()
+	if err = sess.Begin(); err != nil {
+		return err
+	}
+
+	if err = deleteRepoTopics(sess, repoID); err != nil {
+		return err
+	}
+
+	topicIDs := make([]int64, 0, len(names))
+	for _, name := range names {
+		topic := &Topic{Name: name}
+		has, err := sess.Get(topic)
+		if err != nil {
+			return fmt.Errorf("get topic %q: %v", name, err)
+		} else if !has {
+			if _, err = sess.Insert(topic); err != nil {
+				return fmt.Errorf("insert topic %q: %v", name, err)
+			}
+		}
+
+		if _, err = sess.Insert(&RepoTopic{
+			RepoID:  repoID,
+			TopicID: topic.ID,
+		}); err != nil {
+			return fmt.Errorf("insert repo topic %q: %v", name, err)
+		}
+		topicIDs = append(topicIDs, topic.ID)
+	}
+	if err = updateTopicRepoCounts(sess, topicIDs); err != nil {
+		return err
+	}
+
+	return sess.Commit()
+}
+
+// SearchTopics returns topics used by any repository whose names start with the
+// keyword, the most used topics come first.
+func SearchTopics(keyword string, limit int) ([]*Topic, error) {
+	topics := make([]*Topic, 0, limit)
+	return topics, x.Where("repo_count > 0 AND name LIKE ?", strings.ToLower(keyword)+"%").
+		Desc("repo_count").Asc("name").Limit(limit).Find(&topics)
+}
+
+// GetPopularTopics returns topics used by any repository with pagination, the most
+// used topics come first.
+func GetPopularTopics(page, pageSize int) ([]*Topic, error) {
+	topics := make([]*Topic, 0, pageSize)
+	return topics, x.Where("repo_count > 0").Desc("repo_count").Asc("name").
+		Limit(pageSize, (page-1)*pageSize).Find(&topics)
+}
+
+// CountPopularTopics returns the number of topics used by any repository.
+func CountPopularTopics() (int64, error) {
+	return x.Where("repo_count > 0").Count(new(Topic))
+}
+
+// LoadTopics loads topic names of all repositories in the list.
+func (repos RepositoryList) LoadTopics() error {
+	if len(repos) == 0 {
+		return nil
+	}
+
+	repoIDs := make([]int64, len(repos))
+	for i := range repos {
+		repoIDs[i] = repos[i].ID
+	}
+
+	type repoTopicName struct {
+		RepoID int64
+		Name   string
+	}
+	rows := make([]*repoTopicName, 0, len(repos))
+	if err := x.Table("repo_topic").Select("repo_topic.repo_id, topic.name").
+		Join("INNER", "topic", "topic.id = repo_topic.topic_id").
+		In("repo_topic.repo_id", repoIDs).Asc("topic.name").Find(&rows); err != nil {
+		return fmt.Errorf("find repo topics: %v", err)
+	}
+
+	set := make(map[int64][]string, len(repos))
+	for _, row := range rows {
+		set[row.RepoID] = append(set[row.RepoID], row.Name)
+	}
+	for i := range repos {
+		repos[i].Topics = set[repos[i].ID]
+	}
+	return nil
+}
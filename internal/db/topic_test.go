@@ -0,0 +1,45 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_IsValidTopic(t *testing.T) {
+	Convey("Validate topic names", t, func() {
+		testCases := []struct {
+			name   string
+			expect bool
+		}{
+			{"go", true},
+			{"git-server", true},
+			{"2fa", true},
+			{"", false},
+			{"-go", false},
+			{"Go", false},
+			{"git server", false},
+			{"go_lang", false},
+			{"abcdefghijklmnopqrstuvwxyz0123456789", false},
+		}
+		for _, tc := range testCases {
+			So(IsValidTopic(tc.name), ShouldEqual, tc.expect)
+		}
+	})
+}
+
+func Test_SanitizeTopics(t *testing.T) {
+	Convey("Sanitize topic names", t, func() {
+		valid, invalid := SanitizeTopics([]string{" Go ", "git", "go", "", "git server", "self-hosted"})
+		So(valid, ShouldResemble, []string{"go", "git", "self-hosted"})
+		So(invalid, ShouldResemble, []string{"git server"})
+
+		valid, invalid = SanitizeTopics(nil)
+		So(valid, ShouldBeEmpty)
+		So(invalid, ShouldBeNil)
+	})
+}
@@ -11,6 +11,7 @@ import (
 	"strings"
 
 	git "github.com/gogs/git-module"
+	log "unknwon.dev/clog/v2"
 )
 
 // CommitToPushCommit transforms a git.Commit to PushCommit type.
@@ -43,6 +44,56 @@ func ListToPushCommits(l *list.List) *PushCommits {
 	return &PushCommits{l.Len(), commits, "", nil}
 }
 
+// PushOptions are options given by "git push -o" in the form of "key" or "key=value",
+// which are kept in the order as given.
+type PushOptions []string
+
+// Push options that skip webhook deliveries of the push, thus CI builds triggered by
+// webhooks.
+var skipCIPushOptions = []string{"skip-ci", "ci.skip"}
+
+// ParsePushOptions trims spaces around keys of push options and drops options
+// without a key.
+func ParsePushOptions(options []string) PushOptions {
+	opts := make(PushOptions, 0, len(options))
+	for _, option := range options {
+		fields := strings.SplitN(option, "=", 2)
+		fields[0] = strings.TrimSpace(fields[0])
+		if fields[0] == "" {
+			continue
+		}
+		opts = append(opts, strings.Join(fields, "="))
+	}
+	return opts
+}
+
+// Lookup returns the value of the last option with given key, the value is empty
+// if the option is given without "=". The second returned value reports whether
+// the option is given.
+func (opts PushOptions) Lookup(key string) (string, bool) {
+	for i := len(opts) - 1; i >= 0; i-- {
+		fields := strings.SplitN(opts[i], "=", 2)
+		if fields[0] != key {
+			continue
+		}
+		if len(fields) == 2 {
+			return fields[1], true
+		}
+		return "", true
+	}
+	return "", false
+}
+
+// SkipCI returns true if the pusher asks to skip webhook deliveries of the push.
+func (opts PushOptions) SkipCI() bool {
+	for _, key := range skipCIPushOptions {
+		if _, ok := opts.Lookup(key); ok {
+			return true
+		}
+	}
+	return false
+}
+
 type PushUpdateOptions struct {
 	OldCommitID  string
 	NewCommitID  string
@@ -51,6 +102,7 @@ type PushUpdateOptions struct {
 	PusherName   string
 	RepoUserName string
 	RepoName     string
+	PushOptions  PushOptions
 }
 
 // PushUpdate must be called for any push actions in order to
@@ -99,12 +151,21 @@ func PushUpdate(opts PushUpdateOptions) (err error) {
 			OldCommitID: opts.OldCommitID,
 			NewCommitID: opts.NewCommitID,
 			Commits:     &PushCommits{},
+			PushOptions: opts.PushOptions,
 		}); err != nil {
 			return fmt.Errorf("CommitRepoAction.(tag): %v", err)
 		}
 		return nil
 	}
 
+	// Keep the deleted branch to be restored later
+	if isDelRef {
+		branchName := strings.TrimPrefix(opts.RefFullName, git.BRANCH_PREFIX)
+		if err = repo.AddDeletedBranch(opts.PusherID, branchName, opts.OldCommitID); err != nil {
+			log.Error("Failed to add deleted branch %q [repo_id: %d]: %v", branchName, repo.ID, err)
+		}
+	}
+
 	var l *list.List
 	// Skip read parent commits when delete branch
 	if !isDelRef {
@@ -135,6 +196,7 @@ func PushUpdate(opts PushUpdateOptions) (err error) {
 		OldCommitID: opts.OldCommitID,
 		NewCommitID: opts.NewCommitID,
 		Commits:     ListToPushCommits(l),
+		PushOptions: opts.PushOptions,
 	}); err != nil {
 		return fmt.Errorf("CommitRepoAction.(branch): %v", err)
 	}
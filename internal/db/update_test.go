@@ -0,0 +1,32 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_PushOptions(t *testing.T) {
+	Convey("Parse push options", t, func() {
+		opts := ParsePushOptions([]string{"ci.skip", " reviewer =alice=bob", " ", "=value", "topic=", "topic=go"})
+		So(opts, ShouldResemble, PushOptions{"ci.skip", "reviewer=alice=bob", "topic=", "topic=go"})
+
+		value, ok := opts.Lookup("reviewer")
+		So(ok, ShouldBeTrue)
+		So(value, ShouldEqual, "alice=bob")
+		value, ok = opts.Lookup("topic")
+		So(ok, ShouldBeTrue)
+		So(value, ShouldEqual, "go")
+		_, ok = opts.Lookup("merge")
+		So(ok, ShouldBeFalse)
+
+		So(opts.SkipCI(), ShouldBeTrue)
+		So(ParsePushOptions([]string{"skip-ci"}).SkipCI(), ShouldBeTrue)
+		So(ParsePushOptions([]string{"skip-ci-later=1"}).SkipCI(), ShouldBeFalse)
+		So(ParsePushOptions(nil).SkipCI(), ShouldBeFalse)
+	})
+}
@@ -32,6 +32,7 @@ import (
 	"gogs.io/gogs/internal/avatar"
 	"gogs.io/gogs/internal/conf"
 	"gogs.io/gogs/internal/db/errors"
+	"gogs.io/gogs/internal/repoutil"
 	"gogs.io/gogs/internal/tool"
 )
 
@@ -51,6 +52,8 @@ type User struct {
 	LowerName string `xorm:"UNIQUE NOT NULL"`
 	Name      string `xorm:"UNIQUE NOT NULL"`
 	FullName  string
+	// SearchName is the case and diacritic folded name and full name for search.
+	SearchName string `xorm:"NOT NULL DEFAULT ''" json:"-"`
 	// Email is the primary email address (to be used for communication)
 	Email       string `xorm:"NOT NULL"`
 	Passwd      string `xorm:"NOT NULL"`
@@ -61,6 +64,8 @@ type User struct {
 	OwnedOrgs   []*User       `xorm:"-" json:"-"`
 	Orgs        []*User       `xorm:"-" json:"-"`
 	Repos       []*Repository `xorm:"-" json:"-"`
+	// Name of the repository storage root, empty means the default root
+	StorageRoot string `xorm:"NOT NULL DEFAULT ''"`
 	Location    string
 	Website     string
 	Rands       string `xorm:"VARCHAR(10)"`
@@ -75,6 +80,11 @@ type User struct {
 	LastRepoVisibility bool
 	// Maximum repository creation limit, -1 means use gloabl default
 	MaxRepoCreation int `xorm:"NOT NULL DEFAULT -1"`
+	// Version of the terms that the user accepted most recently
+	AcceptedTermsVersion string
+	// Whether notification emails are sent for each event or batched into digests
+	NotificationEmailMode     NotificationEmailMode `xorm:"NOT NULL DEFAULT 0"`
+	LastNotificationEmailUnix int64                 `xorm:"NOT NULL DEFAULT 0"`
 
 	// Permissions
 	IsActive         bool // Activate primary email
@@ -82,6 +92,11 @@ type User struct {
 	AllowGitHook     bool
 	AllowImportLocal bool // Allow migrate repository by local path
 	ProhibitLogin    bool
+	// Deactivated by the user, which hides the profile and blocks sign in until
+	// the account is reactivated.
+	IsDeactivated   bool      `xorm:"NOT NULL DEFAULT false"`
+	Deactivated     time.Time `xorm:"-" json:"-"`
+	DeactivatedUnix int64     `xorm:"NOT NULL DEFAULT 0"`
 
 	// Avatar
 	Avatar          string `xorm:"VARCHAR(2048) NOT NULL"`
@@ -100,9 +115,12 @@ type User struct {
 	NumMembers  int
 	Teams       []*Team `xorm:"-" json:"-"`
 	Members     []*User `xorm:"-" json:"-"`
+	// Comma-separated names of licenses allowed for new repositories, empty means no restriction.
+	AllowedLicenses string `xorm:"TEXT"`
 }
 
 func (u *User) BeforeInsert() {
+	u.SearchName = userSearchName(u.Name, u.FullName)
 	u.CreatedUnix = time.Now().Unix()
 	u.UpdatedUnix = u.CreatedUnix
 }
@@ -120,6 +138,8 @@ func (u *User) AfterSet(colName string, _ xorm.Cell) {
 		u.Created = time.Unix(u.CreatedUnix, 0).Local()
 	case "updated_unix":
 		u.Updated = time.Unix(u.UpdatedUnix, 0).Local()
+	case "deactivated_unix":
+		u.Deactivated = time.Unix(u.DeactivatedUnix, 0).Local()
 	}
 }
 
@@ -285,6 +305,9 @@ func (u *User) AvatarLink() string {
 
 // User.GetFollwoers returns range of user's followers.
 func (u *User) GetFollowers(page int) ([]*User, error) {
+	if page <= 0 {
+		page = 1
+	}
 	users := make([]*User, 0, ItemsPerPage)
 	sess := x.Limit(ItemsPerPage, (page-1)*ItemsPerPage).Where("follow.follow_id=?", u.ID)
 	if conf.UsePostgreSQL {
@@ -301,6 +324,9 @@ func (u *User) IsFollowing(followID int64) bool {
 
 // GetFollowing returns range of user's following.
 func (u *User) GetFollowing(page int) ([]*User, error) {
+	if page <= 0 {
+		page = 1
+	}
 	users := make([]*User, 0, ItemsPerPage)
 	sess := x.Limit(ItemsPerPage, (page-1)*ItemsPerPage).Where("follow.user_id=?", u.ID)
 	if conf.UsePostgreSQL {
@@ -376,11 +402,11 @@ func (u *User) IsAdminOfRepo(repo *Repository) bool {
 	return has
 }
 
-// IsWriterOfRepo returns true if user has write access to given repository.
+// IsWriterOfRepo returns true if user has write access to code of given repository.
 func (u *User) IsWriterOfRepo(repo *Repository) bool {
-	has, err := HasAccess(u.ID, repo, ACCESS_MODE_WRITE)
+	has, err := HasUnitAccess(u.ID, repo, REPO_UNIT_CODE, ACCESS_MODE_WRITE)
 	if err != nil {
-		log.Error("HasAccess: %v", err)
+		log.Error("HasUnitAccess: %v", err)
 	}
 	return has
 }
@@ -469,7 +495,7 @@ func (u *User) ShortName(length int) string {
 // IsMailable checks if a user is elegible
 // to receive emails.
 func (u *User) IsMailable() bool {
-	return u.IsActive
+	return u.IsActive && !u.IsDeactivated
 }
 
 // IsUserExist checks if given user name exist,
@@ -498,7 +524,7 @@ func NewGhostUser() *User {
 }
 
 var (
-	reservedUsernames    = []string{"explore", "create", "assets", "css", "img", "js", "less", "plugins", "debug", "raw", "install", "api", "avatar", "user", "org", "help", "stars", "issues", "pulls", "commits", "repo", "template", "admin", "new", ".", ".."}
+	reservedUsernames    = []string{"explore", "create", "assets", "css", "img", "js", "less", "plugins", "debug", "raw", "install", "api", "avatar", "user", "org", "help", "stars", "issues", "pulls", "commits", "repo", "template", "admin", "new", "sitemap", "v2", ".", ".."}
 	reservedUserPatterns = []string{"*.keys"}
 )
 
@@ -576,6 +602,12 @@ func CreateUser(u *User) (err error) {
 		return err
 	}
 
+	if conf.Onboarding.Enabled {
+		if err = createOnboarding(sess, u.ID); err != nil {
+			return fmt.Errorf("createOnboarding: %v", err)
+		}
+	}
+
 	return sess.Commit()
 }
 
@@ -595,6 +627,18 @@ func Users(page, pageSize int) ([]*User, error) {
 	return users, x.Limit(pageSize, (page-1)*pageSize).Where("type=0").Asc("id").Find(&users)
 }
 
+// CountListedUsers returns number of users who are not deactivated.
+func CountListedUsers() int64 {
+	count, _ := x.Where("type = 0 AND is_deactivated = ?", false).Count(new(User))
+	return count
+}
+
+// ListedUsers returns users who are not deactivated in given page.
+func ListedUsers(page, pageSize int) ([]*User, error) {
+	users := make([]*User, 0, pageSize)
+	return users, x.Limit(pageSize, (page-1)*pageSize).Where("type = 0 AND is_deactivated = ?", false).Asc("id").Find(&users)
+}
+
 // parseUserFromCode returns user by username encoded in code.
 // It returns nil if code or username is invalid.
 func parseUserFromCode(code string) (user *User) {
@@ -679,8 +723,8 @@ func ChangeUserName(u *User, newUserName string) (err error) {
 	}
 
 	// Rename or create user base directory
-	baseDir := UserPath(u.Name)
-	newBaseDir := UserPath(newUserName)
+	baseDir := repoutil.UserPath(u.StorageRoot, u.Name)
+	newBaseDir := repoutil.UserPath(u.StorageRoot, newUserName)
 	if com.IsExist(baseDir) {
 		return os.Rename(baseDir, newBaseDir)
 	}
@@ -705,6 +749,7 @@ func updateUser(e Engine, u *User) error {
 	}
 
 	u.LowerName = strings.ToLower(u.Name)
+	u.SearchName = userSearchName(u.Name, u.FullName)
 	u.Location = tool.TruncateString(u.Location, 255)
 	u.Website = tool.TruncateString(u.Website, 255)
 	u.Description = tool.TruncateString(u.Description, 255)
@@ -718,6 +763,47 @@ func UpdateUser(u *User) error {
 	return updateUser(x, u)
 }
 
+// DeactivateUser deactivates the account on behalf of the user. The profile of a
+// deactivated user is hidden, no emails are sent and sign in is blocked until the
+// account is reactivated.
+func DeactivateUser(u *User) error {
+	if u.IsOrganization() {
+		return fmt.Errorf("organization cannot be deactivated [user_id: %d]", u.ID)
+	}
+
+	u.IsDeactivated = true
+	u.DeactivatedUnix = time.Now().Unix()
+	u.Deactivated = time.Unix(u.DeactivatedUnix, 0).Local()
+	_, err := x.ID(u.ID).Cols("is_deactivated", "deactivated_unix").Update(u)
+	return err
+}
+
+// CanReactivate returns true if the user can reactivate the deactivated account by
+// themselves, which is only allowed within the cooldown window since deactivation.
+func (u *User) CanReactivate() bool {
+	if !u.IsDeactivated {
+		return false
+	} else if conf.User.DeactivationCooldownDays <= 0 {
+		return true
+	}
+	return time.Since(u.Deactivated) < time.Duration(conf.User.DeactivationCooldownDays)*24*time.Hour
+}
+
+// ReactivateUser reactivates the deactivated account on behalf of the doer, users
+// can only reactivate their own accounts within the cooldown window.
+func ReactivateUser(u, doer *User) error {
+	if !u.IsDeactivated {
+		return nil
+	} else if doer.ID == u.ID && !u.CanReactivate() {
+		return errors.UserReactivationExpired{UserID: u.ID}
+	}
+
+	u.IsDeactivated = false
+	u.DeactivatedUnix = 0
+	_, err := x.ID(u.ID).Cols("is_deactivated", "deactivated_unix").Update(u)
+	return err
+}
+
 // deleteBeans deletes all given beans, beans should contain delete conditions.
 func deleteBeans(e Engine, beans ...interface{}) (err error) {
 	for i := range beans {
@@ -728,8 +814,72 @@ func deleteBeans(e Engine, beans ...interface{}) (err error) {
 	return nil
 }
 
+// Choices of who content of a deleted user is attributed to.
+const (
+	DELETED_USER_CONTENT_GHOST     = "ghost"     // Shown as the Ghost user.
+	DELETED_USER_CONTENT_ANONYMIZE = "anonymize" // Shown with an anonymous name unique to the deleted user.
+)
+
+// DeleteUserOptions contains options of deleting a user.
+type DeleteUserOptions struct {
+	// Doer is the user who deletes the user, it is required to transfer repositories.
+	Doer *User
+	// ContentAttribution is who issues, comments and other content of the user are
+	// attributed to, defaults to DELETED_USER_CONTENT_GHOST.
+	ContentAttribution string
+	// TransferReposTo is the organization that repositories owned by the user are
+	// transferred to before deletion, instead of failing with ErrUserOwnRepos.
+	TransferReposTo *User
+}
+
+// DeletedUser is a record of a deleted user whose content is attributed to an
+// anonymous name.
+type DeletedUser struct {
+	ID          int64
+	UserID      int64 `xorm:"UNIQUE"`
+	DisplayName string
+	CreatedUnix int64
+}
+
+func (u *DeletedUser) BeforeInsert() {
+	u.CreatedUnix = time.Now().Unix()
+}
+
+// getGhostUser returns the fake user that content of the deleted user is attributed to.
+func getGhostUser(e Engine, userID int64) *User {
+	ghost := NewGhostUser()
+	deleted := new(DeletedUser)
+	has, err := e.Where("user_id = ?", userID).Get(deleted)
+	if err != nil {
+		log.Error("Failed to get deleted user [user_id: %d]: %v", userID, err)
+	} else if has {
+		ghost.FullName = deleted.DisplayName
+	}
+	return ghost
+}
+
+// transferUserRepos transfers all repositories owned by the user to the organization.
+func transferUserRepos(doer, u, org *User) error {
+	if !org.IsOrganization() {
+		return errors.NotOrganization{Name: org.Name}
+	}
+
+	repos := make([]*Repository, 0, u.NumRepos)
+	if err := x.Where("owner_id = ?", u.ID).Find(&repos); err != nil {
+		return fmt.Errorf("find repositories: %v", err)
+	}
+	for _, repo := range repos {
+		repo.Owner = u
+		if err := TransferOwnership(doer, org.Name, repo); err != nil {
+			// Note: don't wrapper error here.
+			return err
+		}
+	}
+	return nil
+}
+
 // FIXME: need some kind of mechanism to record failure. HINT: system notice
-func deleteUser(e *xorm.Session, u *User) error {
+func deleteUser(e *xorm.Session, u *User, opts DeleteUserOptions) error {
 	// Note: A user owns any repository or belongs to any organization
 	//	cannot perform delete operation.
 
@@ -751,7 +901,7 @@ func deleteUser(e *xorm.Session, u *User) error {
 
 	// ***** START: Watch *****
 	watches := make([]*Watch, 0, 10)
-	if err = e.Find(&watches, &Watch{UserID: u.ID}); err != nil {
+	if err = e.Find(&watches, &Watch{UserID: u.ID, Mode: WATCH_MODE_ALL}); err != nil {
 		return fmt.Errorf("get all watches: %v", err)
 	}
 	for i := range watches {
@@ -789,12 +939,25 @@ func deleteUser(e *xorm.Session, u *User) error {
 		&AccessToken{UID: u.ID},
 		&Collaboration{UserID: u.ID},
 		&Access{UserID: u.ID},
+		&AccessUnit{UserID: u.ID},
 		&Watch{UserID: u.ID},
 		&Star{UID: u.ID},
+		&StarList{UserID: u.ID},
+		&StarListRepo{UserID: u.ID},
 		&Follow{FollowID: u.ID},
+		&FederationKey{ActorType: FEDERATION_ACTOR_USER, ActorID: u.ID},
+		&RemoteFollower{ActorType: FEDERATION_ACTOR_USER, ActorID: u.ID},
 		&Action{UserID: u.ID},
 		&IssueUser{UID: u.ID},
+		&ReviewRequest{ReviewerID: u.ID},
+		&Notification{UserID: u.ID},
+		&IssueWatch{UserID: u.ID},
+		&DigestSubscription{UserID: u.ID},
+		&Onboarding{UserID: u.ID},
+		&TermsAcceptance{UserID: u.ID},
+		&OnboardingStep{UserID: u.ID},
 		&EmailAddress{UID: u.ID},
+		&StagedChange{UserID: u.ID},
 	); err != nil {
 		return fmt.Errorf("deleteBeans: %v", err)
 	}
@@ -823,11 +986,24 @@ func deleteUser(e *xorm.Session, u *User) error {
 		return fmt.Errorf("Delete: %v", err)
 	}
 
+	if opts.ContentAttribution == DELETED_USER_CONTENT_ANONYMIZE {
+		suffix, err := tool.RandomString(8)
+		if err != nil {
+			return fmt.Errorf("RandomString: %v", err)
+		}
+		if _, err = e.Insert(&DeletedUser{
+			UserID:      u.ID,
+			DisplayName: "deleted-user-" + strings.ToLower(suffix),
+		}); err != nil {
+			return fmt.Errorf("insert deleted user: %v", err)
+		}
+	}
+
 	// FIXME: system notice
 	// Note: There are something just cannot be roll back,
 	//	so just keep error logs of those operations.
 
-	os.RemoveAll(UserPath(u.Name))
+	os.RemoveAll(repoutil.UserPath(u.StorageRoot, u.Name))
 	os.Remove(u.CustomAvatarPath())
 
 	return nil
@@ -835,14 +1011,21 @@ func deleteUser(e *xorm.Session, u *User) error {
 
 // DeleteUser completely and permanently deletes everything of a user,
 // but issues/comments/pulls will be kept and shown as someone has been deleted.
-func DeleteUser(u *User) (err error) {
+// Repositories of the user are transferred first when opts.TransferReposTo is set.
+func DeleteUser(u *User, opts DeleteUserOptions) (err error) {
+	if opts.TransferReposTo != nil {
+		if err = transferUserRepos(opts.Doer, u, opts.TransferReposTo); err != nil {
+			return err
+		}
+	}
+
 	sess := x.NewSession()
 	defer sess.Close()
 	if err = sess.Begin(); err != nil {
 		return err
 	}
 
-	if err = deleteUser(sess, u); err != nil {
+	if err = deleteUser(sess, u, opts); err != nil {
 		// Note: don't wrapper error here.
 		return err
 	}
@@ -862,7 +1045,7 @@ func DeleteInactivateUsers() (err error) {
 	}
 	// FIXME: should only update authorized_keys file once after all deletions.
 	for _, u := range users {
-		if err = DeleteUser(u); err != nil {
+		if err = DeleteUser(u, DeleteUserOptions{}); err != nil {
 			// Ignore users that were set inactive by admin.
 			if IsErrUserOwnRepos(err) || IsErrUserHasOrgs(err) {
 				continue
@@ -877,7 +1060,71 @@ func DeleteInactivateUsers() (err error) {
 
 // UserPath returns the path absolute path of user repositories.
 func UserPath(userName string) string {
-	return filepath.Join(conf.Repository.Root, strings.ToLower(userName))
+	return repoutil.UserPath(userStorageRoot(userName), userName)
+}
+
+// userStorageRoot returns the name of storage root that the user is pinned to.
+// It does not touch the database when no named storage root is configured.
+func userStorageRoot(userName string) string {
+	if len(conf.Repository.Storage.RootPaths) == 0 || x == nil {
+		return ""
+	}
+
+	u := new(User)
+	has, err := x.Cols("storage_root").Where("lower_name = ?", strings.ToLower(userName)).Get(u)
+	if err != nil {
+		log.Error("Failed to get storage root of user %q: %v", userName, err)
+		return ""
+	} else if !has {
+		return ""
+	}
+	return u.StorageRoot
+}
+
+// moveDir moves the directory to the new path, it falls back to copy and then
+// remove when a plain rename is not possible (e.g. across different devices).
+func moveDir(oldPath, newPath string) error {
+	if err := os.MkdirAll(filepath.Dir(newPath), os.ModePerm); err != nil {
+		return err
+	}
+
+	if err := os.Rename(oldPath, newPath); err == nil {
+		return nil
+	}
+
+	if err := com.CopyDir(oldPath, newPath); err != nil {
+		os.RemoveAll(newPath)
+		return fmt.Errorf("copy directory: %v", err)
+	}
+	return os.RemoveAll(oldPath)
+}
+
+// MoveUserStorage moves all repositories and wikis of the user or organization to
+// the storage root with given name, and pins the user to the new storage root.
+func MoveUserStorage(u *User, root string) error {
+	if !repoutil.IsValidStorageRoot(root) {
+		return errors.StorageRootNotExist{Name: root}
+	} else if u.StorageRoot == root {
+		return nil
+	}
+
+	oldPath := repoutil.UserPath(u.StorageRoot, u.Name)
+	newPath := repoutil.UserPath(root, u.Name)
+	if com.IsExist(newPath) {
+		return fmt.Errorf("target directory %q already exists", newPath)
+	}
+
+	if com.IsExist(oldPath) {
+		if err := moveDir(oldPath, newPath); err != nil {
+			return fmt.Errorf("move directory: %v", err)
+		}
+	}
+
+	u.StorageRoot = root
+	if _, err := x.ID(u.ID).Cols("storage_root").Update(u); err != nil {
+		return fmt.Errorf("update storage root: %v", err)
+	}
+	return nil
 }
 
 func GetUserByKeyID(keyID int64) (*User, error) {
@@ -907,6 +1154,36 @@ func GetUserByID(id int64) (*User, error) {
 	return getUserByID(x, id)
 }
 
+// GetUsersByIDs returns individual users by given IDs, non-existent IDs are ignored.
+func GetUsersByIDs(ids []int64) ([]*User, error) {
+	users := make([]*User, 0, len(ids))
+	if len(ids) == 0 {
+		return users, nil
+	}
+	if err := x.Where("type = ?", USER_TYPE_INDIVIDUAL).In("id", ids).Find(&users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// GetUsersByNames returns individual users by given names case-insensitively,
+// non-existent names are ignored.
+func GetUsersByNames(names []string) ([]*User, error) {
+	users := make([]*User, 0, len(names))
+	if len(names) == 0 {
+		return users, nil
+	}
+
+	lowerNames := make([]string, len(names))
+	for i := range names {
+		lowerNames[i] = strings.ToLower(names[i])
+	}
+	if err := x.Where("type = ?", USER_TYPE_INDIVIDUAL).In("lower_name", lowerNames).Find(&users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
 // GetAssigneeByID returns the user with write access of repository by given ID.
 func GetAssigneeByID(repo *Repository, userID int64) (*User, error) {
 	has, err := HasAccess(userID, repo, ACCESS_MODE_READ)
@@ -963,7 +1240,8 @@ func GetUserIDsByNames(names []string) []int64 {
 
 // UserCommit represents a commit with validation of user.
 type UserCommit struct {
-	User *User
+	User      *User
+	CoAuthors []*CommitCoAuthor
 	*git.Commit
 }
 
@@ -979,24 +1257,24 @@ func ValidateCommitWithEmail(c *git.Commit) *User {
 // ValidateCommitsWithEmails checks if authors' e-mails of commits are corresponding to users.
 func ValidateCommitsWithEmails(oldCommits *list.List) *list.List {
 	var (
-		u          *User
 		emails     = map[string]*User{}
 		newCommits = list.New()
 		e          = oldCommits.Front()
 	)
+	getUser := func(email string) *User {
+		if u, ok := emails[email]; ok {
+			return u
+		}
+		u, _ := GetUserByEmail(email)
+		emails[email] = u
+		return u
+	}
 	for e != nil {
 		c := e.Value.(*git.Commit)
-
-		if v, ok := emails[c.Author.Email]; !ok {
-			u, _ = GetUserByEmail(c.Author.Email)
-			emails[c.Author.Email] = u
-		} else {
-			u = v
-		}
-
 		newCommits.PushBack(UserCommit{
-			User:   u,
-			Commit: c,
+			User:      getUser(c.Author.Email),
+			CoAuthors: getCommitCoAuthors(c, getUser),
+			Commit:    c,
 		})
 		e = e.Next()
 	}
@@ -1039,15 +1317,17 @@ type SearchUserOptions struct {
 	OrderBy  string
 	Page     int
 	PageSize int // Can be smaller than or equal to setting.UI.ExplorePagingNum
+	// Whether to include deactivated users in results.
+	IncludeDeactivated bool
 }
 
 // SearchUserByName takes keyword and part of user name to search,
 // it returns results in given range and number of total results.
 func SearchUserByName(opts *SearchUserOptions) (users []*User, _ int64, _ error) {
+	opts.Keyword = strings.TrimSpace(opts.Keyword)
 	if len(opts.Keyword) == 0 {
 		return users, 0, nil
 	}
-	opts.Keyword = strings.ToLower(opts.Keyword)
 
 	if opts.PageSize <= 0 || opts.PageSize > conf.UI.ExplorePagingNum {
 		opts.PageSize = conf.UI.ExplorePagingNum
@@ -1056,12 +1336,13 @@ func SearchUserByName(opts *SearchUserOptions) (users []*User, _ int64, _ error)
 		opts.Page = 1
 	}
 
-	searchQuery := "%" + opts.Keyword + "%"
 	users = make([]*User, 0, opts.PageSize)
 	// Append conditions
-	sess := x.Where("LOWER(lower_name) LIKE ?", searchQuery).
-		Or("LOWER(full_name) LIKE ?", searchQuery).
+	sess := x.Where(userSearchCond(opts.Keyword)).
 		And("type = ?", opts.Type)
+	if !opts.IncludeDeactivated {
+		sess.And("is_deactivated = ?", false)
+	}
 
 	var countSess xorm.Session
 	countSess = *sess
@@ -0,0 +1,119 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	log "unknwon.dev/clog/v2"
+	"xorm.io/builder"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/tool"
+)
+
+// maxUserSearchNameLength is the max number of characters of search names, which is
+// the max length of indexable columns.
+const maxUserSearchNameLength = 255
+
+// userSearchName returns the search name of the user, which is the case and
+// diacritic folded name and full name.
+func userSearchName(name, fullName string) string {
+	searchName := []rune(tool.FoldString(strings.TrimSpace(name + " " + fullName)))
+	if len(searchName) > maxUserSearchNameLength {
+		searchName = searchName[:maxUserSearchNameLength]
+	}
+	return string(searchName)
+}
+
+// isUserSearchFTS is true if users are searched with the full text search table of
+// SQLite, which matches prefixes of words instead of substrings.
+var isUserSearchFTS bool
+
+// userSearchFTSQuery returns the FTS query that matches search names containing words
+// that start with every term of the keyword.
+func userSearchFTSQuery(keyword string) string {
+	terms := strings.Fields(keyword)
+	for i := range terms {
+		terms[i] = `"` + strings.Replace(terms[i], `"`, "", -1) + `*"`
+	}
+	return strings.Join(terms, " ")
+}
+
+// userSearchCond returns the condition of users whose names or full names match the
+// keyword regardless of case and diacritics.
+func userSearchCond(keyword string) builder.Cond {
+	keyword = tool.FoldString(strings.TrimSpace(keyword))
+	if isUserSearchFTS {
+		return builder.Expr("id IN (SELECT docid FROM user_search WHERE user_search MATCH ?)", userSearchFTSQuery(keyword))
+	}
+	return builder.Expr("search_name LIKE ?", "%"+keyword+"%")
+}
+
+// initPostgresUserSearch creates a trigram index on search names, which is used by
+// PostgreSQL to match substrings.
+func initPostgresUserSearch() error {
+	if _, err := x.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm"); err != nil {
+		return fmt.Errorf("create extension: %v", err)
+	} else if _, err = x.Exec(`CREATE INDEX IF NOT EXISTS IDX_user_search_name_trgm ON "user" USING GIN (search_name gin_trgm_ops)`); err != nil {
+		return fmt.Errorf("create index: %v", err)
+	}
+	return nil
+}
+
+// initSQLiteUserSearch creates a full text search table of search names which is kept
+// in sync with the table of users by triggers, the table is populated when it is
+// created.
+func initSQLiteUserSearch() error {
+	exists, err := x.IsTableExist("user_search")
+	if err != nil {
+		return fmt.Errorf("check table: %v", err)
+	}
+
+	sess := x.NewSession()
+	defer sess.Close()
+	if err = sess.Begin(); err != nil {
+		return err
+	}
+
+	for _, stmt := range []string{
+		"CREATE VIRTUAL TABLE IF NOT EXISTS user_search USING fts4(search_name, tokenize=unicode61)",
+		"CREATE TRIGGER IF NOT EXISTS user_search_insert AFTER INSERT ON `user` BEGIN INSERT INTO user_search(docid, search_name) VALUES (new.id, new.search_name); END",
+		"CREATE TRIGGER IF NOT EXISTS user_search_update AFTER UPDATE OF search_name ON `user` BEGIN UPDATE user_search SET search_name = new.search_name WHERE docid = new.id; END",
+		"CREATE TRIGGER IF NOT EXISTS user_search_delete AFTER DELETE ON `user` BEGIN DELETE FROM user_search WHERE docid = old.id; END",
+	} {
+		if _, err = sess.Exec(stmt); err != nil {
+			return fmt.Errorf("exec %q: %v", stmt, err)
+		}
+	}
+	if !exists {
+		if _, err = sess.Exec("INSERT INTO user_search(docid, search_name) SELECT id, search_name FROM `user`"); err != nil {
+			return fmt.Errorf("populate table: %v", err)
+		}
+	}
+	return sess.Commit()
+}
+
+// InitUserSearch prepares indexes of the configured type for user search.
+func InitUserSearch() {
+	if conf.Indexer.UserIndexerType != "trigram" {
+		return
+	}
+
+	switch {
+	case conf.UsePostgreSQL:
+		if err := initPostgresUserSearch(); err != nil {
+			log.Fatal("Failed to initialize trigram index of user search: %v", err)
+		}
+	case conf.UseSQLite3:
+		if err := initSQLiteUserSearch(); err != nil {
+			log.Fatal("Failed to initialize full text search table of user search: %v", err)
+		}
+		isUserSearchFTS = true
+	default:
+		log.Fatal("User indexer type %q requires PostgreSQL or SQLite3 as the database", conf.Indexer.UserIndexerType)
+	}
+}
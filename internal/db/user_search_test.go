@@ -0,0 +1,28 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_userSearchName(t *testing.T) {
+	Convey("Get search name of a user", t, func() {
+		So(userSearchName("zoe", ""), ShouldEqual, "zoe")
+		So(userSearchName("Zoe", "Zoë Åström"), ShouldEqual, "zoe zoe astrom")
+		So([]rune(userSearchName("alice", strings.Repeat("é", 300))), ShouldHaveLength, maxUserSearchNameLength)
+	})
+}
+
+func Test_userSearchFTSQuery(t *testing.T) {
+	Convey("Build FTS query of a keyword", t, func() {
+		So(userSearchFTSQuery("jose"), ShouldEqual, `"jose*"`)
+		So(userSearchFTSQuery(" jose  gar "), ShouldEqual, `"jose*" "gar*"`)
+		So(userSearchFTSQuery(`jo"se`), ShouldEqual, `"jose*"`)
+	})
+}
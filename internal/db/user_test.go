@@ -0,0 +1,33 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"gogs.io/gogs/internal/conf"
+)
+
+func Test_User_CanReactivate(t *testing.T) {
+	Convey("Reactivate within the cooldown window", t, func() {
+		conf.User.DeactivationCooldownDays = 30
+
+		u := &User{}
+		So(u.CanReactivate(), ShouldBeFalse)
+
+		u.IsDeactivated = true
+		u.Deactivated = time.Now().AddDate(0, 0, -29)
+		So(u.CanReactivate(), ShouldBeTrue)
+
+		u.Deactivated = time.Now().AddDate(0, 0, -31)
+		So(u.CanReactivate(), ShouldBeFalse)
+
+		conf.User.DeactivationCooldownDays = 0
+		So(u.CanReactivate(), ShouldBeTrue)
+	})
+}
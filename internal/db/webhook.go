@@ -11,6 +11,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io/ioutil"
+	"net/url"
 	"strings"
 	"time"
 
@@ -71,6 +72,11 @@ type HookEvents struct {
 	PullRequest  bool `json:"pull_request"`
 	IssueComment bool `json:"issue_comment"`
 	Release      bool `json:"release"`
+	Repository   bool `json:"repository"`
+	Wiki         bool `json:"wiki"`
+	// ConfidentialIssues allows issues and issue comment events of confidential
+	// issues to be sent.
+	ConfidentialIssues bool `json:"confidential_issues"`
 }
 
 // HookEvent represents events that will delivery hook.
@@ -80,6 +86,10 @@ type HookEvent struct {
 	ChooseEvents   bool `json:"choose_events"`
 
 	HookEvents `json:"events"`
+
+	// Glob patterns separated by commas or newlines, empty means no filtering.
+	BranchFilter string `json:"branch_filter,omitempty"`
+	PathFilter   string `json:"path_filter,omitempty"`
 }
 
 type HookStatus int
@@ -90,11 +100,13 @@ const (
 	HOOK_STATUS_FAILED
 )
 
-// Webhook represents a web hook object.
+// Webhook represents a web hook object, which belongs to a repository, an
+// organization or the whole site when it is a system webhook.
 type Webhook struct {
 	ID           int64
 	RepoID       int64
 	OrgID        int64
+	IsSystem     bool   `xorm:"NOT NULL DEFAULT false"`
 	URL          string `xorm:"url TEXT"`
 	ContentType  HookContentType
 	Secret       string     `xorm:"TEXT"`
@@ -144,6 +156,22 @@ func (w *Webhook) GetSlackHook() *SlackMeta {
 	return s
 }
 
+func (w *Webhook) GetMatrixHook() *MatrixMeta {
+	m := &MatrixMeta{}
+	if err := jsoniter.Unmarshal([]byte(w.Meta), m); err != nil {
+		log.Error("GetMatrixHook [%d]: %v", w.ID, err)
+	}
+	return m
+}
+
+func (w *Webhook) GetTelegramHook() *TelegramMeta {
+	t := &TelegramMeta{}
+	if err := jsoniter.Unmarshal([]byte(w.Meta), t); err != nil {
+		log.Error("GetTelegramHook [%d]: %v", w.ID, err)
+	}
+	return t
+}
+
 // History returns history of webhook by given conditions.
 func (w *Webhook) History(page int) ([]*HookTask, error) {
 	return HookTasks(w.ID, page)
@@ -204,6 +232,24 @@ func (w *Webhook) HasReleaseEvent() bool {
 		(w.ChooseEvents && w.HookEvents.Release)
 }
 
+// HasRepositoryEvent returns true if hook enabled repository event.
+func (w *Webhook) HasRepositoryEvent() bool {
+	return w.SendEverything ||
+		(w.ChooseEvents && w.HookEvents.Repository)
+}
+
+// HasWikiEvent returns true if hook enabled wiki event.
+func (w *Webhook) HasWikiEvent() bool {
+	return w.SendEverything ||
+		(w.ChooseEvents && w.HookEvents.Wiki)
+}
+
+// HasConfidentialIssuesEvent returns true if hook enabled events of confidential issues,
+// which are never sent unless chosen explicitly.
+func (w *Webhook) HasConfidentialIssuesEvent() bool {
+	return w.ChooseEvents && w.HookEvents.ConfidentialIssues
+}
+
 type eventChecker struct {
 	checker func() bool
 	typ     HookEventType
@@ -220,6 +266,9 @@ func (w *Webhook) EventsArray() []string {
 		{w.HasPullRequestEvent, HOOK_EVENT_PULL_REQUEST},
 		{w.HasIssueCommentEvent, HOOK_EVENT_ISSUE_COMMENT},
 		{w.HasReleaseEvent, HOOK_EVENT_RELEASE},
+		{w.HasRepositoryEvent, HOOK_EVENT_REPOSITORY},
+		{w.HasWikiEvent, HOOK_EVENT_WIKI},
+		{w.HasConfidentialIssuesEvent, HOOK_EVENT_CONFIDENTIAL_ISSUES},
 	}
 	for _, c := range eventCheckers {
 		if c.checker() {
@@ -336,6 +385,35 @@ func getActiveWebhooksByOrgID(e Engine, orgID int64) ([]*Webhook, error) {
 	return ws, e.Where("org_id=?", orgID).And("is_active=?", true).Find(&ws)
 }
 
+// GetSystemWebhookByID returns system webhook by given ID.
+func GetSystemWebhookByID(id int64) (*Webhook, error) {
+	return getWebhook(&Webhook{
+		ID:       id,
+		IsSystem: true,
+	})
+}
+
+// GetSystemWebhooks returns all system webhooks.
+func GetSystemWebhooks() ([]*Webhook, error) {
+	ws := make([]*Webhook, 0, 3)
+	return ws, x.Where("is_system = ?", true).Asc("id").Find(&ws)
+}
+
+// DeleteSystemWebhookByID deletes system webhook by given ID.
+func DeleteSystemWebhookByID(id int64) error {
+	return deleteWebhook(&Webhook{
+		ID:       id,
+		IsSystem: true,
+	})
+}
+
+// getActiveSystemWebhooks returns all active system webhooks, which receive events
+// of all repositories.
+func getActiveSystemWebhooks(e Engine) ([]*Webhook, error) {
+	ws := make([]*Webhook, 0, 3)
+	return ws, e.Where("is_system = ?", true).And("is_active = ?", true).Find(&ws)
+}
+
 //   ___ ___                __   ___________              __
 //  /   |   \  ____   ____ |  | _\__    ___/____    _____|  | __
 // /    ~    \/  _ \ /  _ \|  |/ / |    |  \__  \  /  ___/  |/ /
@@ -350,6 +428,8 @@ const (
 	SLACK
 	DISCORD
 	DINGTALK
+	MATRIX
+	TELEGRAM
 )
 
 var hookTaskTypes = map[string]HookTaskType{
@@ -357,6 +437,8 @@ var hookTaskTypes = map[string]HookTaskType{
 	"slack":    SLACK,
 	"discord":  DISCORD,
 	"dingtalk": DINGTALK,
+	"matrix":   MATRIX,
+	"telegram": TELEGRAM,
 }
 
 // ToHookTaskType returns HookTaskType by given name.
@@ -374,6 +456,10 @@ func (t HookTaskType) Name() string {
 		return "discord"
 	case DINGTALK:
 		return "dingtalk"
+	case MATRIX:
+		return "matrix"
+	case TELEGRAM:
+		return "telegram"
 	}
 	return ""
 }
@@ -395,11 +481,84 @@ const (
 	HOOK_EVENT_PULL_REQUEST  HookEventType = "pull_request"
 	HOOK_EVENT_ISSUE_COMMENT HookEventType = "issue_comment"
 	HOOK_EVENT_RELEASE       HookEventType = "release"
+	HOOK_EVENT_REPOSITORY    HookEventType = "repository"
+	HOOK_EVENT_WIKI          HookEventType = "wiki"
+
+	// HOOK_EVENT_CONFIDENTIAL_ISSUES is not delivered by itself, it allows issues and
+	// issue comment events of confidential issues to be delivered.
+	HOOK_EVENT_CONFIDENTIAL_ISSUES HookEventType = "confidential_issues"
+)
+
+type HookRepoAction string
+
+const (
+	HOOK_REPO_CREATED    HookRepoAction = "created"
+	HOOK_REPO_DELETED    HookRepoAction = "deleted"
+	HOOK_REPO_RENAMED    HookRepoAction = "renamed"
+	HOOK_REPO_ARCHIVED   HookRepoAction = "archived"
+	HOOK_REPO_UNARCHIVED HookRepoAction = "unarchived"
+)
+
+// RepositoryChanges represents changes of a renamed repository.
+type RepositoryChanges struct {
+	Name *api.ChangesFromPayload `json:"name,omitempty"`
+}
+
+// RepositoryPayload represents a payload information of repository event.
+type RepositoryPayload struct {
+	Action  HookRepoAction     `json:"action"`
+	Changes *RepositoryChanges `json:"changes,omitempty"`
+	Repo    *APIRepository     `json:"repository"`
+	Sender  *api.User          `json:"sender"`
+}
+
+func (p *RepositoryPayload) JSONPayload() ([]byte, error) {
+	return jsoniter.MarshalIndent(p, "", "  ")
+}
+
+// PushPayload represents a payload information of push event, which has options
+// given by the pusher besides fields of api.PushPayload.
+type PushPayload struct {
+	*api.PushPayload
+	Options PushOptions `json:"push_options,omitempty"`
+}
+
+func (p *PushPayload) JSONPayload() ([]byte, error) {
+	return jsoniter.MarshalIndent(p, "", "  ")
+}
+
+type HookWikiAction string
+
+const (
+	HOOK_WIKI_CREATED HookWikiAction = "created"
+	HOOK_WIKI_EDITED  HookWikiAction = "edited"
+	HOOK_WIKI_DELETED HookWikiAction = "deleted"
 )
 
+// WikiPagePayload represents a wiki page that has been changed.
+type WikiPagePayload struct {
+	Title   string `json:"title"`
+	Name    string `json:"page_name"`
+	Sha     string `json:"sha"`
+	HTMLURL string `json:"html_url"`
+}
+
+// WikiPayload represents a payload information of wiki event.
+type WikiPayload struct {
+	Action HookWikiAction   `json:"action"`
+	Page   *WikiPagePayload `json:"page"`
+	Repo   *APIRepository   `json:"repository"`
+	Sender *api.User        `json:"sender"`
+}
+
+func (p *WikiPayload) JSONPayload() ([]byte, error) {
+	return jsoniter.MarshalIndent(p, "", "  ")
+}
+
 // HookRequest represents hook task request information.
 type HookRequest struct {
 	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
 }
 
 // HookResponse represents hook task response information.
@@ -424,8 +583,9 @@ type HookTask struct {
 	EventType       HookEventType
 	IsSSL           bool
 	IsDelivered     bool
-	Delivered       int64
+	Delivered       int64  `xorm:"INDEX"`
 	DeliveredString string `xorm:"-" json:"-"`
+	Duration        int64  `xorm:"NOT NULL DEFAULT 0"` // Milliseconds that the delivery took
 
 	// History info.
 	IsSucceed       bool
@@ -509,23 +669,86 @@ func GetHookTaskOfWebhookByUUID(webhookID int64, uuid string) (*HookTask, error)
 	if err != nil {
 		return nil, err
 	} else if !has {
-		return nil, errors.HookTaskNotExist{webhookID, uuid}
+		return nil, errors.HookTaskNotExist{HookID: webhookID, UUID: uuid}
+	}
+	return hookTask, nil
+}
+
+// GetHookTaskOfWebhookByID returns hook task of given webhook by ID.
+func GetHookTaskOfWebhookByID(webhookID, id int64) (*HookTask, error) {
+	hookTask := new(HookTask)
+	has, err := x.Where("id = ? AND hook_id = ?", id, webhookID).Get(hookTask)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		return nil, errors.HookTaskNotExist{HookID: webhookID, ID: id}
 	}
 	return hookTask, nil
 }
 
+// RedeliverHookTask adds a new hook task with the same payload of the given one to
+// the task queue, so that the history of the previous delivery is kept.
+func RedeliverHookTask(t *HookTask) (*HookTask, error) {
+	redelivery := &HookTask{
+		RepoID:         t.RepoID,
+		HookID:         t.HookID,
+		UUID:           gouuid.NewV4().String(),
+		Type:           t.Type,
+		URL:            t.URL,
+		Signature:      t.Signature,
+		PayloadContent: t.PayloadContent,
+		ContentType:    t.ContentType,
+		EventType:      t.EventType,
+		IsSSL:          t.IsSSL,
+	}
+	if _, err := x.Insert(redelivery); err != nil {
+		return nil, err
+	}
+
+	go HookQueue.Add(t.RepoID)
+	return redelivery, nil
+}
+
 // UpdateHookTask updates information of hook task.
 func UpdateHookTask(t *HookTask) error {
 	_, err := x.Id(t.ID).AllCols().Update(t)
 	return err
 }
 
+// isConfidentialIssuePayload returns true if the payload is about a confidential issue.
+func isConfidentialIssuePayload(e Engine, p api.Payloader) (bool, error) {
+	var issue *api.Issue
+	switch p := p.(type) {
+	case *api.IssuesPayload:
+		issue = p.Issue
+	case *api.IssueCommentPayload:
+		issue = p.Issue
+	}
+	if issue == nil {
+		return false, nil
+	}
+
+	count, err := e.Where("id = ? AND is_confidential = ?", issue.ID, true).Count(new(Issue))
+	return count > 0, err
+}
+
 // prepareHookTasks adds list of webhooks to task queue.
 func prepareHookTasks(e Engine, repo *Repository, event HookEventType, p api.Payloader, webhooks []*Webhook) (err error) {
 	if len(webhooks) == 0 {
 		return nil
 	}
 
+	// Filters and payloads of other types than Gogs only know about the API payload.
+	payload := p
+	if push, ok := p.(*PushPayload); ok {
+		payload = push.PushPayload
+	}
+
+	files := &hookChangedFiles{repo: repo, event: event, payload: payload}
+	isConfidential, err := isConfidentialIssuePayload(e, payload)
+	if err != nil {
+		return fmt.Errorf("isConfidentialIssuePayload: %v", err)
+	}
 	var payloader api.Payloader
 	for _, w := range webhooks {
 		switch event {
@@ -561,25 +784,49 @@ func prepareHookTasks(e Engine, repo *Repository, event HookEventType, p api.Pay
 			if !w.HasReleaseEvent() {
 				continue
 			}
+		case HOOK_EVENT_REPOSITORY:
+			if !w.HasRepositoryEvent() {
+				continue
+			}
+		case HOOK_EVENT_WIKI:
+			if !w.HasWikiEvent() {
+				continue
+			}
+		}
+		if isConfidential && !w.HasConfidentialIssuesEvent() {
+			continue
+		}
+		if !w.matchFilters(event, payload, files) {
+			continue
 		}
 
 		// Use separate objects so modifcations won't be made on payload on non-Gogs type hooks.
 		switch w.HookTaskType {
 		case SLACK:
-			payloader, err = GetSlackPayload(p, event, w.Meta)
+			payloader, err = GetSlackPayload(payload, event, w.Meta)
 			if err != nil {
 				return fmt.Errorf("GetSlackPayload: %v", err)
 			}
 		case DISCORD:
-			payloader, err = GetDiscordPayload(p, event, w.Meta)
+			payloader, err = GetDiscordPayload(payload, event, w.Meta)
 			if err != nil {
 				return fmt.Errorf("GetDiscordPayload: %v", err)
 			}
 		case DINGTALK:
-			payloader, err = GetDingtalkPayload(p, event)
+			payloader, err = GetDingtalkPayload(payload, event)
 			if err != nil {
 				return fmt.Errorf("GetDingtalkPayload: %v", err)
 			}
+		case MATRIX:
+			payloader, err = GetMatrixPayload(payload, event, w.Meta)
+			if err != nil {
+				return fmt.Errorf("GetMatrixPayload: %v", err)
+			}
+		case TELEGRAM:
+			payloader, err = GetTelegramPayload(payload, event, w.Meta)
+			if err != nil {
+				return fmt.Errorf("GetTelegramPayload: %v", err)
+			}
 		default:
 			payloader = p
 		}
@@ -632,6 +879,12 @@ func prepareWebhooks(e Engine, repo *Repository, event HookEventType, p api.Payl
 		}
 		webhooks = append(webhooks, orgws...)
 	}
+
+	sysws, err := getActiveSystemWebhooks(e)
+	if err != nil {
+		return fmt.Errorf("getActiveSystemWebhooks: %v", err)
+	}
+	webhooks = append(webhooks, sysws...)
 	return prepareHookTasks(e, repo, event, p, webhooks)
 }
 
@@ -646,6 +899,12 @@ func TestWebhook(repo *Repository, event HookEventType, p api.Payloader, webhook
 	if err != nil {
 		return fmt.Errorf("GetWebhookOfRepoByID [repo_id: %d, id: %d]: %v", repo.ID, webhookID, err)
 	}
+
+	// Test deliveries are sent regardless of filters.
+	if webhook.HookEvent != nil {
+		webhook.BranchFilter = ""
+		webhook.PathFilter = ""
+	}
 	return prepareHookTasks(x, repo, event, p, []*Webhook{webhook})
 }
 
@@ -653,7 +912,13 @@ func (t *HookTask) deliver() {
 	t.IsDelivered = true
 
 	timeout := time.Duration(conf.Webhook.DeliverTimeout) * time.Second
-	req := httplib.Post(t.URL).SetTimeout(timeout, timeout).
+	req := httplib.Post(t.URL)
+	if t.Type == MATRIX {
+		// Matrix only accepts messages with transaction IDs, which are the UUID of
+		// deliveries.
+		req = httplib.Put(t.URL + "/" + t.UUID)
+	}
+	req = req.SetTimeout(timeout, timeout).
 		Header("X-Github-Delivery", t.UUID).
 		Header("X-Github-Event", string(t.EventType)).
 		Header("X-Gogs-Delivery", t.UUID).
@@ -661,27 +926,41 @@ func (t *HookTask) deliver() {
 		Header("X-Gogs-Event", string(t.EventType)).
 		SetTLSClientConfig(&tls.Config{InsecureSkipVerify: conf.Webhook.SkipTLSVerify})
 
+	// Record delivery information.
+	t.RequestInfo = &HookRequest{
+		Headers: map[string]string{},
+	}
+
 	switch t.ContentType {
 	case JSON:
 		req = req.Header("Content-Type", "application/json").Body(t.PayloadContent)
+		t.RequestInfo.Body = t.PayloadContent
 	case FORM:
 		req.Param("payload", t.PayloadContent)
-	}
-
-	// Record delivery information.
-	t.RequestInfo = &HookRequest{
-		Headers: map[string]string{},
+		t.RequestInfo.Body = url.Values{"payload": {t.PayloadContent}}.Encode()
 	}
 	for k, vals := range req.Headers() {
 		t.RequestInfo.Headers[k] = strings.Join(vals, ",")
 	}
 
+	// The access token of Matrix is not recorded in the delivery history.
+	if t.Type == MATRIX {
+		w, err := GetWebhookByID(t.HookID)
+		if err != nil {
+			log.Error("GetWebhookByID: %v", err)
+		} else {
+			req.Header("Authorization", "Bearer "+w.GetMatrixHook().AccessToken)
+		}
+	}
+
 	t.ResponseInfo = &HookResponse{
 		Headers: map[string]string{},
 	}
 
+	start := time.Now()
 	defer func() {
 		t.Delivered = time.Now().UnixNano()
+		t.Duration = int64(time.Since(start) / time.Millisecond)
 		if t.IsSucceed {
 			log.Trace("Hook delivered: %s", t.UUID)
 		} else {
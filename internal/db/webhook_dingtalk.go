@@ -76,6 +76,10 @@ func GetDingtalkPayload(p api.Payloader, event HookEventType) (payload *Dingtalk
 		payload, err = getDingtalkPullRequestPayload(p.(*api.PullRequestPayload))
 	case HOOK_EVENT_RELEASE:
 		payload, err = getDingtalkReleasePayload(p.(*api.ReleasePayload))
+	case HOOK_EVENT_REPOSITORY:
+		payload, err = getDingtalkRepositoryPayload(p.(*RepositoryPayload))
+	case HOOK_EVENT_WIKI:
+		payload, err = getDingtalkWikiPayload(p.(*WikiPayload))
 	}
 
 	if err != nil {
@@ -255,6 +259,27 @@ func getDingtalkReleasePayload(p *api.ReleasePayload) (*DingtalkPayload, error)
 	return &DingtalkPayload{MsgType: "actionCard", ActionCard: actionCard}, nil
 }
 
+func getDingtalkRepositoryPayload(p *RepositoryPayload) (*DingtalkPayload, error) {
+	actionCard := NewDingtalkActionCard("View Repo", p.Repo.HTMLURL)
+
+	actionCard.Text += "# Repo " + strings.Title(string(p.Action))
+	actionCard.Text += "\n- Repo: **" + MarkdownLinkFormatter(p.Repo.HTMLURL, p.Repo.FullName) + "**"
+	actionCard.Text += "\n- Sender: " + p.Sender.UserName
+
+	return &DingtalkPayload{MsgType: "actionCard", ActionCard: actionCard}, nil
+}
+
+func getDingtalkWikiPayload(p *WikiPayload) (*DingtalkPayload, error) {
+	actionCard := NewDingtalkActionCard("View Wiki", p.Repo.HTMLURL+"/wiki")
+
+	actionCard.Text += "# Wiki Page " + strings.Title(string(p.Action))
+	actionCard.Text += "\n- Repo: **" + MarkdownLinkFormatter(p.Repo.HTMLURL, p.Repo.FullName) + "**"
+	actionCard.Text += "\n- Page: **" + p.Page.Title + "**"
+	actionCard.Text += "\n- Sender: " + p.Sender.UserName
+
+	return &DingtalkPayload{MsgType: "actionCard", ActionCard: actionCard}, nil
+}
+
 //Format link addr and title into markdown style
 func MarkdownLinkFormatter(link, text string) string {
 	return "[" + text + "](" + link + ")"
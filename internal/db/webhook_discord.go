@@ -370,6 +370,40 @@ func getDiscordReleasePayload(p *api.ReleasePayload) (*DiscordPayload, error) {
 	}, nil
 }
 
+func getDiscordRepositoryPayload(p *RepositoryPayload) (*DiscordPayload, error) {
+	repoLink := DiscordLinkFormatter(p.Repo.HTMLURL, p.Repo.FullName)
+	content := fmt.Sprintf("Repository %s %s", repoLink, p.Action)
+	return &DiscordPayload{
+		Embeds: []*DiscordEmbedObject{{
+			Description: content,
+			URL:         conf.Server.ExternalURL + p.Sender.UserName,
+			Author: &DiscordEmbedAuthorObject{
+				Name:    p.Sender.UserName,
+				IconURL: p.Sender.AvatarUrl,
+			},
+		}},
+	}, nil
+}
+
+func getDiscordWikiPayload(p *WikiPayload) (*DiscordPayload, error) {
+	repoLink := DiscordLinkFormatter(p.Repo.HTMLURL, p.Repo.FullName)
+	pageLink := DiscordLinkFormatter(p.Page.HTMLURL, p.Page.Title)
+	if p.Action == HOOK_WIKI_DELETED {
+		pageLink = p.Page.Title
+	}
+	content := fmt.Sprintf("Wiki page %s of %s %s", pageLink, repoLink, p.Action)
+	return &DiscordPayload{
+		Embeds: []*DiscordEmbedObject{{
+			Description: content,
+			URL:         conf.Server.ExternalURL + p.Sender.UserName,
+			Author: &DiscordEmbedAuthorObject{
+				Name:    p.Sender.UserName,
+				IconURL: p.Sender.AvatarUrl,
+			},
+		}},
+	}, nil
+}
+
 func GetDiscordPayload(p api.Payloader, event HookEventType, meta string) (payload *DiscordPayload, err error) {
 	slack := &SlackMeta{}
 	if err := jsoniter.Unmarshal([]byte(meta), &slack); err != nil {
@@ -393,6 +427,10 @@ func GetDiscordPayload(p api.Payloader, event HookEventType, meta string) (paylo
 		payload, err = getDiscordPullRequestPayload(p.(*api.PullRequestPayload), slack)
 	case HOOK_EVENT_RELEASE:
 		payload, err = getDiscordReleasePayload(p.(*api.ReleasePayload))
+	case HOOK_EVENT_REPOSITORY:
+		payload, err = getDiscordRepositoryPayload(p.(*RepositoryPayload))
+	case HOOK_EVENT_WIKI:
+		payload, err = getDiscordWikiPayload(p.(*WikiPayload))
 	}
 	if err != nil {
 		return nil, fmt.Errorf("event '%s': %v", event, err)
@@ -0,0 +1,191 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gogs/git-module"
+	api "github.com/gogs/go-gogs-client"
+	log "unknwon.dev/clog/v2"
+
+	"gogs.io/gogs/internal/db/errors"
+)
+
+// splitHookFilter returns glob patterns of the filter, which are separated by commas
+// or newlines.
+func splitHookFilter(filter string) []string {
+	fields := strings.FieldsFunc(filter, func(r rune) bool {
+		return r == ',' || r == '\n'
+	})
+	patterns := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if f = strings.TrimSpace(f); f != "" {
+			patterns = append(patterns, f)
+		}
+	}
+	return patterns
+}
+
+// ValidateHookFilter returns an error if any pattern of the filter is invalid.
+func ValidateHookFilter(filter string) error {
+	for _, p := range splitHookFilter(filter) {
+		if _, err := globToRegexp(strings.TrimPrefix(p, "!")); err != nil {
+			return errors.InvalidHookFilter{Pattern: p}
+		}
+	}
+	return nil
+}
+
+// matchHookFilter returns true if the name is matched by the filter. Patterns prefixed
+// with "!" exclude matching names, and a filter with only excluding patterns matches
+// all other names. Invalid patterns never match.
+func matchHookFilter(filter, name string) bool {
+	patterns := splitHookFilter(filter)
+
+	// The name must be matched by one of including patterns if there is any.
+	matched := true
+	for _, p := range patterns {
+		if !strings.HasPrefix(p, "!") {
+			matched = false
+			break
+		}
+	}
+
+	for _, p := range patterns {
+		exclude := strings.HasPrefix(p, "!")
+		re, err := globToRegexp(strings.TrimPrefix(p, "!"))
+		if err != nil || !re.MatchString(name) {
+			continue
+		}
+		if exclude {
+			return false
+		}
+		matched = true
+	}
+	return matched
+}
+
+// hookChangedFiles lazily determines paths of files changed by an event, which is
+// shared by all webhooks of the event.
+type hookChangedFiles struct {
+	repo    *Repository
+	event   HookEventType
+	payload api.Payloader
+
+	loaded bool
+	files  []string
+	ok     bool
+}
+
+// diffNames returns paths of files changed in the revision range.
+func (f *hookChangedFiles) diffNames(args ...string) ([]string, error) {
+	stdout, err := git.NewCommand(append([]string{"diff", "--name-only"}, args...)...).RunInDir(f.repo.RepoPath())
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(stdout), nil
+}
+
+// get returns paths of changed files, and false if they cannot be determined.
+func (f *hookChangedFiles) get() ([]string, bool) {
+	if f.loaded {
+		return f.files, f.ok
+	}
+	f.loaded = true
+
+	var err error
+	switch p := f.payload.(type) {
+	case *api.PushPayload:
+		// Commits in the payload are truncated for large pushes, use the whole range when possible.
+		if p.Before != "" && p.Before != git.EMPTY_SHA && p.After != git.EMPTY_SHA {
+			if f.files, err = f.diffNames(p.Before, p.After); err == nil {
+				f.ok = true
+				break
+			}
+			log.Trace("Failed to get changed files of push [repo_id: %d]: %v", f.repo.ID, err)
+		}
+
+		seen := make(map[string]bool)
+		for _, c := range p.Commits {
+			for _, files := range [][]string{c.Added, c.Removed, c.Modified} {
+				for _, file := range files {
+					if !seen[file] {
+						seen[file] = true
+						f.files = append(f.files, file)
+					}
+				}
+			}
+		}
+		f.ok = true
+
+	case *api.PullRequestPayload:
+		if p.PullRequest == nil {
+			break
+		}
+		f.files, err = f.diffNames(fmt.Sprintf("%s...refs/pull/%d/head", p.PullRequest.BaseBranch, p.Index))
+		if err != nil {
+			log.Trace("Failed to get changed files of pull request [repo_id: %d, index: %d]: %v", f.repo.ID, p.Index, err)
+			break
+		}
+		// The diff is empty once the pull request has been merged.
+		f.ok = len(f.files) > 0
+	}
+	return f.files, f.ok
+}
+
+// hookEventBranch returns the branch that the event is about, or empty string if the
+// event is not about a branch.
+func hookEventBranch(p api.Payloader) string {
+	switch p := p.(type) {
+	case *api.PushPayload:
+		if strings.HasPrefix(p.Ref, git.BRANCH_PREFIX) {
+			return strings.TrimPrefix(p.Ref, git.BRANCH_PREFIX)
+		}
+	case *api.CreatePayload:
+		if p.RefType == "branch" {
+			return p.Ref
+		}
+	case *api.DeletePayload:
+		if p.RefType == "branch" {
+			return p.Ref
+		}
+	case *api.PullRequestPayload:
+		if p.PullRequest != nil {
+			return p.PullRequest.BaseBranch
+		}
+	}
+	return ""
+}
+
+// matchFilters returns true if the event should be delivered according to branch and
+// path filters of the webhook. Events not about a branch are not filtered by branches,
+// and events whose changed files cannot be determined are not filtered by paths.
+func (w *Webhook) matchFilters(event HookEventType, p api.Payloader, files *hookChangedFiles) bool {
+	if w.HookEvent == nil {
+		return true
+	}
+
+	if w.BranchFilter != "" {
+		if branch := hookEventBranch(p); branch != "" && !matchHookFilter(w.BranchFilter, branch) {
+			return false
+		}
+	}
+
+	if w.PathFilter != "" && (event == HOOK_EVENT_PUSH || event == HOOK_EVENT_PULL_REQUEST) {
+		changed, ok := files.get()
+		if !ok {
+			return true
+		}
+		for _, file := range changed {
+			if matchHookFilter(w.PathFilter, file) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
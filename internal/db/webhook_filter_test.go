@@ -0,0 +1,81 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+
+	api "github.com/gogs/go-gogs-client"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_splitHookFilter(t *testing.T) {
+	Convey("Split patterns of hook filter", t, func() {
+		So(splitHookFilter(""), ShouldBeEmpty)
+		So(splitHookFilter(" master , release/*\n\n!docs/**"), ShouldResemble, []string{"master", "release/*", "!docs/**"})
+	})
+}
+
+func Test_matchHookFilter(t *testing.T) {
+	Convey("Match names against hook filter", t, func() {
+		testCases := []struct {
+			filter string
+			name   string
+			expect bool
+		}{
+			{"", "master", true},
+			{"master", "master", true},
+			{"master", "develop", false},
+			{"release/*", "release/v1.0", true},
+			{"release/*", "release/v1/hotfix", false},
+			{"release/**", "release/v1/hotfix", true},
+			{"master, release/*", "release/v1.0", true},
+			{"!wip/*", "master", true},
+			{"!wip/*", "wip/feature", false},
+			{"services/**", "services/api/main.go", true},
+			{"services/**, !**/*.md", "services/api/README.md", false},
+			{"**/*.go", "main.go", true},
+			{"**/*.go", "cmd/gogs/main.go", true},
+			{"*.go", "cmd/gogs/main.go", false},
+			{"v?.0", "v1.0", true},
+		}
+		for _, tc := range testCases {
+			So(matchHookFilter(tc.filter, tc.name), ShouldEqual, tc.expect)
+		}
+	})
+}
+
+func Test_hookEventBranch(t *testing.T) {
+	Convey("Get branch of hook events", t, func() {
+		So(hookEventBranch(&api.PushPayload{Ref: "refs/heads/release/v1"}), ShouldEqual, "release/v1")
+		So(hookEventBranch(&api.PushPayload{Ref: "refs/tags/v1.0"}), ShouldBeEmpty)
+		So(hookEventBranch(&api.CreatePayload{Ref: "develop", RefType: "branch"}), ShouldEqual, "develop")
+		So(hookEventBranch(&api.DeletePayload{Ref: "v1.0", RefType: "tag"}), ShouldBeEmpty)
+		So(hookEventBranch(&api.PullRequestPayload{PullRequest: &api.PullRequest{BaseBranch: "master"}}), ShouldEqual, "master")
+		So(hookEventBranch(&api.IssuesPayload{}), ShouldBeEmpty)
+	})
+}
+
+func Test_Webhook_matchFilters(t *testing.T) {
+	Convey("Match events against filters of webhook", t, func() {
+		w := &Webhook{HookEvent: &HookEvent{
+			BranchFilter: "master",
+			PathFilter:   "docs/**",
+		}}
+		files := func(paths ...string) *hookChangedFiles {
+			return &hookChangedFiles{loaded: true, files: paths, ok: true}
+		}
+
+		push := &api.PushPayload{Ref: "refs/heads/master"}
+		So(w.matchFilters(HOOK_EVENT_PUSH, push, files("docs/index.md")), ShouldBeTrue)
+		So(w.matchFilters(HOOK_EVENT_PUSH, push, files("main.go")), ShouldBeFalse)
+		So(w.matchFilters(HOOK_EVENT_PUSH, &api.PushPayload{Ref: "refs/heads/develop"}, files("docs/index.md")), ShouldBeFalse)
+
+		// Unknown changed files are not filtered.
+		So(w.matchFilters(HOOK_EVENT_PULL_REQUEST, &api.PullRequestPayload{PullRequest: &api.PullRequest{BaseBranch: "master"}}, &hookChangedFiles{loaded: true}), ShouldBeTrue)
+		// Events not about branches are not filtered.
+		So(w.matchFilters(HOOK_EVENT_ISSUES, &api.IssuesPayload{}, files()), ShouldBeTrue)
+	})
+}
@@ -0,0 +1,79 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/json-iterator/go"
+
+	api "github.com/gogs/go-gogs-client"
+)
+
+type MatrixMeta struct {
+	HomeserverURL string `json:"homeserver_url"`
+	RoomID        string `json:"room_id"`
+	AccessToken   string `json:"access_token"`
+	// MessageType is either "m.notice" or "m.text".
+	MessageType string `json:"message_type"`
+}
+
+// MatrixURL returns the URL of the client-server API to send messages to the room,
+// the transaction ID is appended to the URL when the message is delivered.
+func MatrixURL(homeserverURL, roomID string) string {
+	return fmt.Sprintf("%s/_matrix/client/r0/rooms/%s/send/m.room.message",
+		strings.TrimRight(homeserverURL, "/"), url.PathEscape(roomID))
+}
+
+// Refer: https://matrix.org/docs/spec/client_server/r0.6.1#m-room-message-msgtypes
+type MatrixPayload struct {
+	MsgType       string `json:"msgtype"`
+	Body          string `json:"body"`
+	Format        string `json:"format"`
+	FormattedBody string `json:"formatted_body"`
+}
+
+func (p *MatrixPayload) JSONPayload() ([]byte, error) {
+	data, err := jsoniter.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return []byte{}, err
+	}
+	return data, nil
+}
+
+var matrixLinkPattern = regexp.MustCompile(`<a href="([^"]*)">([^<]*)</a>`)
+
+// matrixPlainBody converts the HTML message to the plain text fallback, links are
+// written in Markdown.
+func matrixPlainBody(message string) string {
+	return html.UnescapeString(matrixLinkPattern.ReplaceAllString(message, "[$2]($1)"))
+}
+
+func GetMatrixPayload(p api.Payloader, event HookEventType, meta string) (*MatrixPayload, error) {
+	matrix := &MatrixMeta{}
+	if err := jsoniter.Unmarshal([]byte(meta), &matrix); err != nil {
+		return nil, fmt.Errorf("Unmarshal: %v", err)
+	}
+
+	message, err := GetHTMLMessage(p, event)
+	if err != nil {
+		return nil, fmt.Errorf("event '%s': %v", event, err)
+	}
+
+	msgType := matrix.MessageType
+	if msgType != "m.text" {
+		msgType = "m.notice"
+	}
+	return &MatrixPayload{
+		MsgType:       msgType,
+		Body:          matrixPlainBody(message),
+		Format:        "org.matrix.custom.html",
+		FormattedBody: strings.Replace(message, "\n", "<br>", -1),
+	}, nil
+}
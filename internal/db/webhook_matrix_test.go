@@ -0,0 +1,37 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+
+	api "github.com/gogs/go-gogs-client"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_MatrixURL(t *testing.T) {
+	Convey("Compose URL to send messages to Matrix room", t, func() {
+		So(MatrixURL("https://matrix.org/", "!room:matrix.org"), ShouldEqual,
+			"https://matrix.org/_matrix/client/r0/rooms/%21room:matrix.org/send/m.room.message")
+	})
+}
+
+func Test_GetMatrixPayload(t *testing.T) {
+	Convey("Compose Matrix payload of release event", t, func() {
+		p := &api.ReleasePayload{
+			Release: &api.Release{TagName: "v1.0"},
+			Repository: &api.Repository{
+				Name:    "a<b",
+				HTMLURL: "https://gogs.example.com/user/a<b",
+			},
+			Sender: &api.User{UserName: "user"},
+		}
+		payload, err := GetMatrixPayload(p, HOOK_EVENT_RELEASE, `{"message_type":"m.text"}`)
+		So(err, ShouldBeNil)
+		So(payload.MsgType, ShouldEqual, "m.text")
+		So(payload.FormattedBody, ShouldContainSubstring, `<a href="https://gogs.example.com/user/a&lt;b">a&lt;b</a>`)
+		So(payload.Body, ShouldContainSubstring, "[a<b](https://gogs.example.com/user/a<b)")
+	})
+}
@@ -0,0 +1,162 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/gogs/git-module"
+	api "github.com/gogs/go-gogs-client"
+
+	"gogs.io/gogs/internal/conf"
+)
+
+// HTMLLinkFormatter returns an HTML link of given URL and text.
+func HTMLLinkFormatter(url, text string) string {
+	return fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(url), html.EscapeString(text))
+}
+
+// htmlUserLink returns the HTML link to the profile page of the user.
+func htmlUserLink(u *api.User) string {
+	return HTMLLinkFormatter(conf.Server.ExternalURL+u.UserName, u.UserName)
+}
+
+// htmlIssueActionText returns human-readable text of the action, e.g. "label updated".
+func htmlIssueActionText(action api.HookIssueAction) string {
+	return strings.Replace(string(action), "_", " ", -1)
+}
+
+// htmlBodyText returns escaped body of an issue, a pull request or a comment, which
+// is truncated to keep messages within limits of chat services.
+func htmlBodyText(body string) string {
+	const maxRunes = 1000
+	if runes := []rune(body); len(runes) > maxRunes {
+		body = string(runes[:maxRunes]) + "..."
+	}
+	return html.EscapeString(body)
+}
+
+// GetHTMLMessage composes a message of the event in the subset of HTML that chat
+// services like Matrix and Telegram accept, lines are separated by "\n".
+func GetHTMLMessage(p api.Payloader, event HookEventType) (string, error) {
+	switch event {
+	case HOOK_EVENT_CREATE:
+		p := p.(*api.CreatePayload)
+		refName := git.RefEndName(p.Ref)
+		return fmt.Sprintf("[%s:%s] %s created by %s",
+			HTMLLinkFormatter(p.Repo.HTMLURL, p.Repo.Name),
+			HTMLLinkFormatter(p.Repo.HTMLURL+"/src/"+refName, refName),
+			p.RefType, htmlUserLink(p.Sender)), nil
+
+	case HOOK_EVENT_DELETE:
+		p := p.(*api.DeletePayload)
+		return fmt.Sprintf("[%s:%s] %s deleted by %s",
+			HTMLLinkFormatter(p.Repo.HTMLURL, p.Repo.Name),
+			html.EscapeString(git.RefEndName(p.Ref)),
+			p.RefType, htmlUserLink(p.Sender)), nil
+
+	case HOOK_EVENT_FORK:
+		p := p.(*api.ForkPayload)
+		return fmt.Sprintf("%s is forked to %s",
+			HTMLLinkFormatter(p.Repo.HTMLURL, p.Repo.Name),
+			HTMLLinkFormatter(p.Forkee.HTMLURL, p.Forkee.FullName)), nil
+
+	case HOOK_EVENT_PUSH:
+		p := p.(*api.PushPayload)
+		branchName := git.RefEndName(p.Ref)
+		commitDesc := fmt.Sprintf("%d new commits", len(p.Commits))
+		if len(p.Commits) == 1 {
+			commitDesc = "1 new commit"
+		}
+		if len(p.CompareURL) > 0 {
+			commitDesc = HTMLLinkFormatter(p.CompareURL, commitDesc)
+		}
+
+		lines := make([]string, 0, len(p.Commits)+1)
+		lines = append(lines, fmt.Sprintf("[%s:%s] %s pushed by %s",
+			HTMLLinkFormatter(p.Repo.HTMLURL, p.Repo.Name),
+			HTMLLinkFormatter(p.Repo.HTMLURL+"/src/"+branchName, branchName),
+			commitDesc, htmlUserLink(p.Pusher)))
+		for _, commit := range p.Commits {
+			lines = append(lines, fmt.Sprintf("%s: %s - %s",
+				HTMLLinkFormatter(commit.URL, commit.ID[:7]),
+				html.EscapeString(strings.Split(commit.Message, "\n")[0]),
+				html.EscapeString(commit.Author.Name)))
+		}
+		return strings.Join(lines, "\n"), nil
+
+	case HOOK_EVENT_ISSUES:
+		p := p.(*api.IssuesPayload)
+		titleLink := HTMLLinkFormatter(fmt.Sprintf("%s/issues/%d", p.Repository.HTMLURL, p.Index),
+			fmt.Sprintf("#%d %s", p.Index, p.Issue.Title))
+		action := htmlIssueActionText(p.Action)
+		if p.Action == api.HOOK_ISSUE_ASSIGNED && p.Issue.Assignee != nil {
+			action += " to " + htmlUserLink(p.Issue.Assignee)
+		}
+		text := fmt.Sprintf("[%s] Issue %s: %s by %s",
+			html.EscapeString(p.Repository.FullName), action, titleLink, htmlUserLink(p.Sender))
+		if p.Action == api.HOOK_ISSUE_OPENED && len(p.Issue.Body) > 0 {
+			text += "\n" + htmlBodyText(p.Issue.Body)
+		}
+		return text, nil
+
+	case HOOK_EVENT_ISSUE_COMMENT:
+		p := p.(*api.IssueCommentPayload)
+		url := fmt.Sprintf("%s/issues/%d", p.Repository.HTMLURL, p.Issue.Index)
+		if p.Action != api.HOOK_ISSUE_COMMENT_DELETED {
+			url += "#" + CommentHashTag(p.Comment.ID)
+		}
+		text := fmt.Sprintf("[%s] Comment %s on %s by %s",
+			html.EscapeString(p.Repository.FullName), p.Action,
+			HTMLLinkFormatter(url, fmt.Sprintf("#%d %s", p.Issue.Index, p.Issue.Title)),
+			htmlUserLink(p.Sender))
+		if p.Action != api.HOOK_ISSUE_COMMENT_DELETED && len(p.Comment.Body) > 0 {
+			text += "\n" + htmlBodyText(p.Comment.Body)
+		}
+		return text, nil
+
+	case HOOK_EVENT_PULL_REQUEST:
+		p := p.(*api.PullRequestPayload)
+		titleLink := HTMLLinkFormatter(fmt.Sprintf("%s/pulls/%d", p.Repository.HTMLURL, p.Index),
+			fmt.Sprintf("#%d %s", p.Index, p.PullRequest.Title))
+		action := htmlIssueActionText(p.Action)
+		switch {
+		case p.Action == api.HOOK_ISSUE_CLOSED && p.PullRequest.HasMerged:
+			action = "merged"
+		case p.Action == api.HOOK_ISSUE_ASSIGNED && p.PullRequest.Assignee != nil:
+			action += " to " + htmlUserLink(p.PullRequest.Assignee)
+		}
+		text := fmt.Sprintf("[%s] Pull request %s: %s by %s",
+			html.EscapeString(p.Repository.FullName), action, titleLink, htmlUserLink(p.Sender))
+		if p.Action == api.HOOK_ISSUE_OPENED && len(p.PullRequest.Body) > 0 {
+			text += "\n" + htmlBodyText(p.PullRequest.Body)
+		}
+		return text, nil
+
+	case HOOK_EVENT_RELEASE:
+		p := p.(*api.ReleasePayload)
+		return fmt.Sprintf("[%s] new release %s published by %s",
+			HTMLLinkFormatter(p.Repository.HTMLURL, p.Repository.Name),
+			HTMLLinkFormatter(p.Repository.HTMLURL+"/src/"+p.Release.TagName, p.Release.TagName),
+			htmlUserLink(p.Sender)), nil
+
+	case HOOK_EVENT_REPOSITORY:
+		p := p.(*RepositoryPayload)
+		return fmt.Sprintf("[%s] repository %s by %s",
+			HTMLLinkFormatter(p.Repo.HTMLURL, p.Repo.FullName), p.Action, htmlUserLink(p.Sender)), nil
+
+	case HOOK_EVENT_WIKI:
+		p := p.(*WikiPayload)
+		pageLink := HTMLLinkFormatter(p.Page.HTMLURL, p.Page.Title)
+		if p.Action == HOOK_WIKI_DELETED {
+			pageLink = html.EscapeString(p.Page.Title)
+		}
+		return fmt.Sprintf("[%s] wiki page %s %s by %s",
+			HTMLLinkFormatter(p.Repo.HTMLURL, p.Repo.FullName), pageLink, p.Action, htmlUserLink(p.Sender)), nil
+	}
+	return "", fmt.Errorf("unsupported event '%s'", event)
+}
@@ -287,6 +287,26 @@ func getSlackReleasePayload(p *api.ReleasePayload) (*SlackPayload, error) {
 	}, nil
 }
 
+func getSlackRepositoryPayload(p *RepositoryPayload) (*SlackPayload, error) {
+	repoLink := SlackLinkFormatter(p.Repo.HTMLURL, p.Repo.FullName)
+	text := fmt.Sprintf("[%s] repository %s by %s", repoLink, p.Action, p.Sender.UserName)
+	return &SlackPayload{
+		Text: text,
+	}, nil
+}
+
+func getSlackWikiPayload(p *WikiPayload) (*SlackPayload, error) {
+	repoLink := SlackLinkFormatter(p.Repo.HTMLURL, p.Repo.FullName)
+	pageLink := SlackLinkFormatter(p.Page.HTMLURL, p.Page.Title)
+	if p.Action == HOOK_WIKI_DELETED {
+		pageLink = SlackTextFormatter(p.Page.Title)
+	}
+	text := fmt.Sprintf("[%s] wiki page %s %s by %s", repoLink, pageLink, p.Action, p.Sender.UserName)
+	return &SlackPayload{
+		Text: text,
+	}, nil
+}
+
 func GetSlackPayload(p api.Payloader, event HookEventType, meta string) (payload *SlackPayload, err error) {
 	slack := &SlackMeta{}
 	if err := jsoniter.Unmarshal([]byte(meta), &slack); err != nil {
@@ -310,6 +330,10 @@ func GetSlackPayload(p api.Payloader, event HookEventType, meta string) (payload
 		payload, err = getSlackPullRequestPayload(p.(*api.PullRequestPayload), slack)
 	case HOOK_EVENT_RELEASE:
 		payload, err = getSlackReleasePayload(p.(*api.ReleasePayload))
+	case HOOK_EVENT_REPOSITORY:
+		payload, err = getSlackRepositoryPayload(p.(*RepositoryPayload))
+	case HOOK_EVENT_WIKI:
+		payload, err = getSlackWikiPayload(p.(*WikiPayload))
 	}
 	if err != nil {
 		return nil, fmt.Errorf("event '%s': %v", event, err)
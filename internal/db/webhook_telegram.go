@@ -0,0 +1,57 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"fmt"
+
+	"github.com/json-iterator/go"
+
+	api "github.com/gogs/go-gogs-client"
+)
+
+type TelegramMeta struct {
+	BotToken string `json:"bot_token"`
+	ChatID   string `json:"chat_id"`
+}
+
+// TelegramURL returns the URL of the Bot API to send messages by the bot.
+func TelegramURL(botToken string) string {
+	return "https://api.telegram.org/bot" + botToken + "/sendMessage"
+}
+
+// Refer: https://core.telegram.org/bots/api#sendmessage
+type TelegramPayload struct {
+	ChatID                string `json:"chat_id"`
+	Text                  string `json:"text"`
+	ParseMode             string `json:"parse_mode"`
+	DisableWebPagePreview bool   `json:"disable_web_page_preview"`
+}
+
+func (p *TelegramPayload) JSONPayload() ([]byte, error) {
+	data, err := jsoniter.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return []byte{}, err
+	}
+	return data, nil
+}
+
+func GetTelegramPayload(p api.Payloader, event HookEventType, meta string) (*TelegramPayload, error) {
+	telegram := &TelegramMeta{}
+	if err := jsoniter.Unmarshal([]byte(meta), &telegram); err != nil {
+		return nil, fmt.Errorf("Unmarshal: %v", err)
+	}
+
+	message, err := GetHTMLMessage(p, event)
+	if err != nil {
+		return nil, fmt.Errorf("event '%s': %v", event, err)
+	}
+	return &TelegramPayload{
+		ChatID:                telegram.ChatID,
+		Text:                  message,
+		ParseMode:             "HTML",
+		DisableWebPagePreview: true,
+	}, nil
+}
@@ -6,6 +6,7 @@ package db
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/url"
 	"os"
@@ -13,12 +14,17 @@ import (
 	"path/filepath"
 	"strings"
 
+	gouuid "github.com/satori/go.uuid"
 	"github.com/unknwon/com"
+	log "unknwon.dev/clog/v2"
 
 	"github.com/gogs/git-module"
 
 	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/markup"
+	"gogs.io/gogs/internal/repoutil"
 	"gogs.io/gogs/internal/sync"
+	"gogs.io/gogs/internal/tool"
 )
 
 var wikiWorkingPool = sync.NewExclusivePool()
@@ -28,12 +34,209 @@ func ToWikiPageURL(name string) string {
 	return url.QueryEscape(name)
 }
 
-// ToWikiPageName formats a URL back to corresponding wiki page name,
-// and removes leading characters './' to prevent changing files
-// that are not belong to wiki repository.
+// ToWikiPageName formats a URL back to corresponding wiki page name, where "/"
+// separates directories of the page. It removes leading characters './' of the
+// page and its directories to prevent changing files that are not belong to wiki
+// repository, e.g. files in the ".git" directory of the local copy.
 func ToWikiPageName(urlString string) string {
 	name, _ := url.QueryUnescape(urlString)
-	return strings.Replace(strings.TrimLeft(path.Clean("/"+name), "/"), "/", " ", -1)
+	segments := strings.Split(strings.TrimLeft(path.Clean("/"+name), "/"), "/")
+	for i := 0; i < len(segments)-1; i++ {
+		segments[i] = strings.TrimLeft(segments[i], ".")
+		if segments[i] == "" {
+			segments = append(segments[:i], segments[i+1:]...)
+			i--
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// WikiPageFormat is a markup format that wiki pages can be written in.
+type WikiPageFormat struct {
+	Name      string
+	Extension string
+}
+
+// WikiPageFormats are formats of new wiki pages, the first one is the default format.
+var WikiPageFormats = []WikiPageFormat{
+	{"Markdown", ".md"},
+	{"AsciiDoc", ".adoc"},
+	{"Org-mode", ".org"},
+	{"reStructuredText", ".rst"},
+}
+
+// wikiPageExtension returns the extension if it is an extension of files in any markup
+// format that can be rendered, or the extension of the default format otherwise.
+func wikiPageExtension(ext string) string {
+	if markup.IsRenderableFile("page" + ext) {
+		return ext
+	}
+	return WikiPageFormats[0].Extension
+}
+
+// ParseWikiPageFilename returns the title of wiki page of the file name, and reports
+// whether the file is a wiki page, which is a file in any markup format that can be
+// rendered.
+func ParseWikiPageFilename(filename string) (string, bool) {
+	if !markup.IsRenderableFile(filename) {
+		return "", false
+	}
+	return strings.TrimSuffix(filename, path.Ext(filename)), true
+}
+
+// matchWikiPageFilename returns the file name of the wiki page with given title
+// among the file names, Markdown files take precedence over files in other formats.
+// It returns an empty string if no file is the page.
+func matchWikiPageFilename(filenames []string, title string) string {
+	found := ""
+	for _, filename := range filenames {
+		if t, ok := ParseWikiPageFilename(filename); ok && t == title {
+			if found == "" || markup.IsMarkdownFile(filename) {
+				found = filename
+			}
+		}
+	}
+	return found
+}
+
+// FindWikiPageFilename returns the path of file of the wiki page with given title
+// in the commit of repository wiki.
+func FindWikiPageFilename(commit *git.Commit, title string) (string, error) {
+	dir := path.Dir(title)
+	tree := &commit.Tree
+	if dir != "." {
+		entry, err := commit.GetTreeEntryByPath(dir)
+		if err != nil {
+			return "", err
+		} else if !entry.IsDir() {
+			return "", git.ErrNotExist{ID: commit.ID.String(), RelPath: title}
+		}
+
+		tree, err = commit.SubTree(dir)
+		if err != nil {
+			return "", fmt.Errorf("SubTree: %v", err)
+		}
+	}
+
+	entries, err := tree.ListEntries()
+	if err != nil {
+		return "", fmt.Errorf("ListEntries: %v", err)
+	}
+	filenames := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.Type == git.OBJECT_BLOB {
+			filenames = append(filenames, e.Name())
+		}
+	}
+
+	filename := matchWikiPageFilename(filenames, path.Base(title))
+	if filename == "" {
+		return "", git.ErrNotExist{ID: commit.ID.String(), RelPath: title}
+	}
+	return path.Join(dir, filename), nil
+}
+
+// findLocalWikiPageFilename returns the path of file of the wiki page with given
+// title in the local copy of repository wiki, or an empty string if it does not exist.
+func findLocalWikiPageFilename(localPath, title string) string {
+	dir := path.Dir(title)
+	fis, err := ioutil.ReadDir(filepath.Join(localPath, dir))
+	if err != nil {
+		return ""
+	}
+	filenames := make([]string, 0, len(fis))
+	for _, fi := range fis {
+		if fi.Mode().IsRegular() {
+			filenames = append(filenames, fi.Name())
+		}
+	}
+
+	filename := matchWikiPageFilename(filenames, path.Base(title))
+	if filename == "" {
+		return ""
+	}
+	return path.Join(dir, filename)
+}
+
+// WikiPageEntry is an entry of the tree of wiki pages, which is either a page or
+// a directory that contains pages.
+type WikiPageEntry struct {
+	// Title is the title of the page, or the path of the directory.
+	Title string
+	// Name is the last element of the title.
+	Name string
+	// Filename is the path of file of the page.
+	Filename string
+	// Depth is the number of directories that the entry is in.
+	Depth int
+	IsDir bool
+}
+
+// ListWikiPages returns the tree of wiki pages in the commit of repository wiki in
+// depth-first order, where each directory is followed by entries it contains.
+// Directories without any page, hidden directories and the directory of uploaded
+// files are omitted.
+func ListWikiPages(commit *git.Commit) ([]*WikiPageEntry, error) {
+	return listWikiPages(&commit.Tree, "", 0)
+}
+
+func listWikiPages(tree *git.Tree, dir string, depth int) ([]*WikiPageEntry, error) {
+	entries, err := tree.ListEntries()
+	if err != nil {
+		return nil, fmt.Errorf("ListEntries: %v", err)
+	}
+
+	var dirs, pages []*WikiPageEntry
+	indexes := make(map[string]int, len(entries))
+	for _, e := range entries {
+		switch {
+		case e.IsDir():
+			if strings.HasPrefix(e.Name(), ".") || (depth == 0 && e.Name() == WikiAttachmentsDir) {
+				continue
+			}
+
+			subtree, err := tree.SubTree(e.Name())
+			if err != nil {
+				return nil, fmt.Errorf("SubTree: %v", err)
+			}
+			children, err := listWikiPages(subtree, path.Join(dir, e.Name()), depth+1)
+			if err != nil {
+				return nil, err
+			} else if len(children) == 0 {
+				continue
+			}
+			dirs = append(dirs, &WikiPageEntry{
+				Title: path.Join(dir, e.Name()),
+				Name:  e.Name(),
+				Depth: depth,
+				IsDir: true,
+			})
+			dirs = append(dirs, children...)
+
+		case e.Type == git.OBJECT_BLOB:
+			name, ok := ParseWikiPageFilename(e.Name())
+			if !ok {
+				continue
+			}
+
+			// Markdown files take precedence over files in other formats.
+			filename := path.Join(dir, e.Name())
+			if i, ok := indexes[name]; ok {
+				if markup.IsMarkdownFile(filename) {
+					pages[i].Filename = filename
+				}
+				continue
+			}
+			indexes[name] = len(pages)
+			pages = append(pages, &WikiPageEntry{
+				Title:    path.Join(dir, name),
+				Name:     name,
+				Filename: filename,
+				Depth:    depth,
+			})
+		}
+	}
+	return append(dirs, pages...), nil
 }
 
 // WikiCloneLink returns clone URLs of repository wiki.
@@ -43,11 +246,12 @@ func (repo *Repository) WikiCloneLink() (cl *CloneLink) {
 
 // WikiPath returns wiki data path by given user and repository name.
 func WikiPath(userName, repoName string) string {
-	return filepath.Join(UserPath(userName), strings.ToLower(repoName)+".wiki.git")
+	return repoutil.WikiPath(userStorageRoot(userName), userName, repoName)
 }
 
 func (repo *Repository) WikiPath() string {
-	return WikiPath(repo.MustOwner().Name, repo.Name)
+	owner := repo.MustOwner()
+	return repoutil.WikiPath(owner.StorageRoot, owner.Name, repo.Name)
 }
 
 // HasWiki returns true if repository has wiki.
@@ -83,8 +287,31 @@ func discardLocalWikiChanges(localPath string) error {
 	return discardLocalRepoBranchChanges(localPath, "master")
 }
 
-// updateWikiPage adds new page to repository wiki.
-func (repo *Repository) updateWikiPage(doer *User, oldTitle, title, content, message string, isNew bool) (err error) {
+// checkLocalWikiPageDir returns an error if any existing element of the directory
+// in the local copy of repository wiki is not a directory, e.g. a symlink.
+func checkLocalWikiPageDir(localPath, dir string) error {
+	if dir == "." {
+		return nil
+	}
+
+	p := localPath
+	for _, name := range strings.Split(dir, "/") {
+		p = filepath.Join(p, name)
+		fi, err := os.Lstat(p)
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("Lstat: %v", err)
+		} else if !fi.IsDir() {
+			return fmt.Errorf("%q of wiki page is not a directory", name)
+		}
+	}
+	return nil
+}
+
+// updateWikiPage adds new page to repository wiki, where ext is the file extension
+// of the page format.
+func (repo *Repository) updateWikiPage(doer *User, oldTitle, title, ext, content, message string, isNew bool) (err error) {
 	wikiWorkingPool.CheckIn(com.ToStr(repo.ID))
 	defer wikiWorkingPool.CheckOut(com.ToStr(repo.ID))
 
@@ -100,15 +327,23 @@ func (repo *Repository) updateWikiPage(doer *User, oldTitle, title, content, mes
 	}
 
 	title = ToWikiPageName(title)
-	filename := path.Join(localPath, title+".md")
+	filename := filepath.Join(localPath, title+wikiPageExtension(ext))
 
 	// If not a new file, show perform update not create.
 	if isNew {
-		if com.IsExist(filename) {
-			return ErrWikiAlreadyExist{filename}
+		if existing := findLocalWikiPageFilename(localPath, title); existing != "" {
+			return ErrWikiAlreadyExist{path.Join(localPath, existing)}
 		}
-	} else {
-		os.Remove(path.Join(localPath, oldTitle+".md"))
+	} else if existing := findLocalWikiPageFilename(localPath, ToWikiPageName(oldTitle)); existing != "" {
+		os.Remove(path.Join(localPath, existing))
+	}
+
+	// SECURITY: directories of the page must not be symlinks, otherwise the page
+	// can be written to anywhere outside the wiki repository.
+	if err = checkLocalWikiPageDir(localPath, path.Dir(title)); err != nil {
+		return err
+	} else if err = os.MkdirAll(filepath.Dir(filename), os.ModePerm); err != nil {
+		return fmt.Errorf("MkdirAll: %v", err)
 	}
 
 	// SECURITY: if new file is a symlink to non-exist critical file,
@@ -127,24 +362,113 @@ func (repo *Repository) updateWikiPage(doer *User, oldTitle, title, content, mes
 	}
 	if err = git.AddChanges(localPath, true); err != nil {
 		return fmt.Errorf("AddChanges: %v", err)
-	} else if err = git.CommitChanges(localPath, git.CommitChangesOptions{
+	} else if err = commitChanges(localPath, git.CommitChangesOptions{
 		Committer: doer.NewGitSig(),
 		Message:   message,
-	}); err != nil {
+	}, signOnWiki); err != nil {
 		return fmt.Errorf("CommitChanges: %v", err)
 	} else if err = git.Push(localPath, "origin", "master"); err != nil {
 		return fmt.Errorf("Push: %v", err)
 	}
 
+	action := HOOK_WIKI_EDITED
+	if isNew {
+		action = HOOK_WIKI_CREATED
+	}
+	repo.prepareWikiWebhooks(doer, action, title)
 	return nil
 }
 
-func (repo *Repository) AddWikiPage(doer *User, title, content, message string) error {
-	return repo.updateWikiPage(doer, "", title, content, message, true)
+// prepareWikiWebhooks sends the wiki webhook event of the page changed by the latest
+// commit of the local copy of the wiki.
+func (repo *Repository) prepareWikiWebhooks(doer *User, action HookWikiAction, title string) {
+	sha, err := git.NewCommand("rev-parse", "HEAD").RunInDir(repo.LocalWikiPath())
+	if err != nil {
+		log.Error("Failed to get latest commit of wiki [repo_id: %d]: %v", repo.ID, err)
+	}
+	if err = repo.GetOwner(); err != nil {
+		log.Error("GetOwner [repo_id: %d]: %v", repo.ID, err)
+		return
+	}
+
+	if err = PrepareWebhooks(repo, HOOK_EVENT_WIKI, &WikiPayload{
+		Action: action,
+		Page: &WikiPagePayload{
+			Title:   title,
+			Name:    ToWikiPageURL(title),
+			Sha:     strings.TrimSpace(sha),
+			HTMLURL: repo.HTMLURL() + "/wiki/" + ToWikiPageURL(title),
+		},
+		Repo:   repo.ExtendedAPIFormat(nil),
+		Sender: doer.APIFormat(),
+	}); err != nil {
+		log.Error("PrepareWebhooks [repo_id: %d]: %v", repo.ID, err)
+	}
+}
+
+// WikiAttachmentsDir is the directory of repository wiki that uploaded files are
+// committed to.
+const WikiAttachmentsDir = "attachments"
+
+// UploadWikiFile commits the file to a new directory under the attachments directory
+// of repository wiki, and returns the path of the file in repository wiki.
+func (repo *Repository) UploadWikiFile(doer *User, name string, r io.Reader) (_ string, err error) {
+	wikiWorkingPool.CheckIn(com.ToStr(repo.ID))
+	defer wikiWorkingPool.CheckOut(com.ToStr(repo.ID))
+
+	if err = repo.InitWiki(); err != nil {
+		return "", fmt.Errorf("InitWiki: %v", err)
+	}
+
+	localPath := repo.LocalWikiPath()
+	if err = discardLocalWikiChanges(localPath); err != nil {
+		return "", fmt.Errorf("discardLocalWikiChanges: %v", err)
+	} else if err = repo.UpdateLocalWiki(); err != nil {
+		return "", fmt.Errorf("UpdateLocalWiki: %v", err)
+	}
+
+	name = path.Base(strings.Replace(name, "\\", "/", -1))
+	if name == "." || name == "/" {
+		name = "file"
+	}
+	treePath := path.Join(WikiAttachmentsDir, gouuid.NewV4().String(), name)
+	filename := filepath.Join(localPath, treePath)
+	if err = os.MkdirAll(filepath.Dir(filename), os.ModePerm); err != nil {
+		return "", fmt.Errorf("MkdirAll: %v", err)
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return "", fmt.Errorf("Create: %v", err)
+	}
+	_, err = io.Copy(f, r)
+	f.Close()
+	if err != nil {
+		return "", fmt.Errorf("Copy: %v", err)
+	}
+
+	if err = git.AddChanges(localPath, true); err != nil {
+		return "", fmt.Errorf("AddChanges: %v", err)
+	} else if err = commitChanges(localPath, git.CommitChangesOptions{
+		Committer: doer.NewGitSig(),
+		Message:   "Upload file '" + name + "'",
+	}, signOnWiki); err != nil {
+		return "", fmt.Errorf("CommitChanges: %v", err)
+	} else if err = git.Push(localPath, "origin", "master"); err != nil {
+		return "", fmt.Errorf("Push: %v", err)
+	}
+	return treePath, nil
 }
 
-func (repo *Repository) EditWikiPage(doer *User, oldTitle, title, content, message string) error {
-	return repo.updateWikiPage(doer, oldTitle, title, content, message, false)
+// AddWikiPage adds a new page in the format of given file extension to repository wiki.
+func (repo *Repository) AddWikiPage(doer *User, title, ext, content, message string) error {
+	return repo.updateWikiPage(doer, "", title, ext, content, message, true)
+}
+
+// EditWikiPage updates the page of repository wiki, the page is converted to the
+// format of given file extension.
+func (repo *Repository) EditWikiPage(doer *User, oldTitle, title, ext, content, message string) error {
+	return repo.updateWikiPage(doer, oldTitle, title, ext, content, message, false)
 }
 
 func (repo *Repository) DeleteWikiPage(doer *User, title string) (err error) {
@@ -159,21 +483,93 @@ func (repo *Repository) DeleteWikiPage(doer *User, title string) (err error) {
 	}
 
 	title = ToWikiPageName(title)
-	filename := path.Join(localPath, title+".md")
-	os.Remove(filename)
+	if filename := findLocalWikiPageFilename(localPath, title); filename != "" {
+		os.Remove(path.Join(localPath, filename))
+	}
 
 	message := "Delete page '" + title + "'"
 
 	if err = git.AddChanges(localPath, true); err != nil {
 		return fmt.Errorf("AddChanges: %v", err)
-	} else if err = git.CommitChanges(localPath, git.CommitChangesOptions{
+	} else if err = commitChanges(localPath, git.CommitChangesOptions{
 		Committer: doer.NewGitSig(),
 		Message:   message,
-	}); err != nil {
+	}, signOnWiki); err != nil {
 		return fmt.Errorf("CommitChanges: %v", err)
 	} else if err = git.Push(localPath, "origin", "master"); err != nil {
 		return fmt.Errorf("Push: %v", err)
 	}
 
+	repo.prepareWikiWebhooks(doer, HOOK_WIKI_DELETED, title)
 	return nil
 }
+
+// GetWikiPageDiff returns the diff of the wiki page between given commits, the diff
+// is against the parent of the after commit if beforeCommitID is empty.
+func (repo *Repository) GetWikiPageDiff(title, beforeCommitID, afterCommitID string) (*Diff, error) {
+	wikiRepo, err := git.OpenRepository(repo.WikiPath())
+	if err != nil {
+		return nil, fmt.Errorf("OpenRepository: %v", err)
+	}
+	commit, err := wikiRepo.GetCommit(afterCommitID)
+	if err != nil {
+		return nil, err
+	}
+	if len(beforeCommitID) == 0 {
+		beforeCommitID, err = parentCommitID(commit)
+		if err != nil {
+			return nil, err
+		}
+	} else if _, err = wikiRepo.GetCommit(beforeCommitID); err != nil {
+		return nil, err
+	}
+
+	// The page may be in different formats in the two commits.
+	title = ToWikiPageName(title)
+	args := []string{"diff", "--full-index", beforeCommitID, afterCommitID, "--"}
+	for _, f := range WikiPageFormats {
+		args = append(args, ":(literal)"+title+f.Extension)
+	}
+	if filename, err := FindWikiPageFilename(commit, title); err == nil && !com.IsSliceContainsStr(args, ":(literal)"+filename) {
+		args = append(args, ":(literal)"+filename)
+	}
+
+	gitDiff, err := runDiff(wikiRepo.Path, conf.Git.MaxGitDiffLines, conf.Git.MaxGitDiffLineCharacters, conf.Git.MaxGitDiffFiles, args...)
+	if err != nil {
+		return nil, fmt.Errorf("GetWikiPageDiff: %v", err)
+	}
+	return NewDiff(gitDiff), nil
+}
+
+// RevertWikiPage reverts content of the wiki page to the revision of given commit.
+func (repo *Repository) RevertWikiPage(doer *User, title, commitID string) error {
+	wikiRepo, err := git.OpenRepository(repo.WikiPath())
+	if err != nil {
+		return fmt.Errorf("OpenRepository: %v", err)
+	}
+	commit, err := wikiRepo.GetCommit(commitID)
+	if err != nil {
+		return err
+	}
+
+	title = ToWikiPageName(title)
+	filename, err := FindWikiPageFilename(commit, title)
+	if err != nil {
+		return err
+	}
+	blob, err := commit.GetBlobByPath(filename)
+	if err != nil {
+		return err
+	}
+	r, err := blob.Data()
+	if err != nil {
+		return fmt.Errorf("Data: %v", err)
+	}
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("ReadAll: %v", err)
+	}
+
+	message := fmt.Sprintf("Revert page '%s' to %s", title, tool.ShortSHA1(commit.ID.String()))
+	return repo.updateWikiPage(doer, title, title, path.Ext(filename), string(content), message, false)
+}
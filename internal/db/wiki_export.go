@@ -0,0 +1,236 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"archive/zip"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/gogs/git-module"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/markup"
+)
+
+// wikiExportIndex is the file of index of all pages in the exported archive, it is
+// named after the URL of the page in web UI so it does not clash with any page.
+const wikiExportIndex = "_pages.html"
+
+const wikiExportPageTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>%[1]s</title>
+<style>
+body { max-width: 980px; margin: 0 auto; padding: 2em; font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; line-height: 1.6; color: #333; }
+nav { margin-bottom: 1em; font-size: 0.9em; }
+a { color: #4183c4; text-decoration: none; }
+pre, code { font-family: Consolas, "Liberation Mono", Menlo, monospace; background: #f6f8fa; }
+pre { padding: 1em; overflow: auto; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ddd; padding: 0.3em 0.6em; }
+img { max-width: 100%%; }
+ul.pages { list-style: none; padding-left: 1.5em; }
+</style>
+</head>
+<body>
+<nav><a href="%[2]s">%[3]s</a></nav>
+<h1>%[1]s</h1>
+%[4]s
+</body>
+</html>
+`
+
+// wikiExportFilename returns the path of rendered HTML file of the wiki page with
+// given title in the exported archive.
+func wikiExportFilename(title string) string {
+	return title + ".html"
+}
+
+// relativeWikiExportLink returns the escaped link to the file at given path in the
+// exported archive, relative to a file in the directory of given depth.
+func relativeWikiExportLink(depth int, filename string) string {
+	return strings.Repeat("../", depth) + (&url.URL{Path: filename}).String()
+}
+
+// rewriteWikiExportLinks rewrites links in rendered HTML of the wiki page in the
+// directory of given depth, so that links to wiki pages and files point to their
+// files in the exported archive, and other links to the site become absolute.
+func rewriteWikiExportLinks(content, repoLink string, titles []string, depth int) string {
+	wikiLink := repoLink + "/wiki/"
+	oldnew := make([]string, 0, len(titles)*2+6)
+	for _, title := range titles {
+		oldnew = append(oldnew,
+			`href="`+wikiLink+ToWikiPageURL(title)+`"`,
+			`href="`+relativeWikiExportLink(depth, wikiExportFilename(title))+`"`)
+	}
+	oldnew = append(oldnew,
+		`href="`+wikiLink+`_raw/`, `href="`+strings.Repeat("../", depth),
+		`src="`+wikiLink+`_raw/`, `src="`+strings.Repeat("../", depth),
+	)
+
+	siteURL := strings.TrimSuffix(conf.Server.ExternalURL, "/")
+	if conf.Server.URL != nil {
+		siteURL = conf.Server.URL.Scheme + "://" + conf.Server.URL.Host
+	}
+	oldnew = append(oldnew,
+		`href="/`, `href="`+siteURL+`/`,
+		`src="/`, `src="`+siteURL+`/`,
+	)
+	return strings.NewReplacer(oldnew...).Replace(content)
+}
+
+// writeWikiExportPage writes an HTML file of given title and body to the archive.
+func writeWikiExportPage(z *zip.Writer, filename, title, indexTitle, body string, depth int) error {
+	w, err := z.Create(filename)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, wikiExportPageTemplate,
+		html.EscapeString(title),
+		relativeWikiExportLink(depth, wikiExportIndex),
+		html.EscapeString(indexTitle),
+		body,
+	)
+	return err
+}
+
+// listWikiFiles returns paths of all files in the tree except pages and files in
+// hidden directories, all files in the directory of uploaded files are included.
+func listWikiFiles(tree *git.Tree, dir string) ([]string, error) {
+	entries, err := tree.ListEntries()
+	if err != nil {
+		return nil, fmt.Errorf("ListEntries: %v", err)
+	}
+	isAttachmentsDir := dir == WikiAttachmentsDir || strings.HasPrefix(dir, WikiAttachmentsDir+"/")
+
+	var files []string
+	for _, e := range entries {
+		switch {
+		case e.IsDir():
+			if strings.HasPrefix(e.Name(), ".") {
+				continue
+			}
+			subtree, err := tree.SubTree(e.Name())
+			if err != nil {
+				return nil, fmt.Errorf("SubTree: %v", err)
+			}
+			children, err := listWikiFiles(subtree, path.Join(dir, e.Name()))
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, children...)
+
+		case e.Type == git.OBJECT_BLOB:
+			if _, ok := ParseWikiPageFilename(e.Name()); !ok || isAttachmentsDir {
+				files = append(files, path.Join(dir, e.Name()))
+			}
+		}
+	}
+	return files, nil
+}
+
+// ExportWiki writes a zip archive of the wiki at the latest revision to w, where each
+// page is rendered to an HTML file with links between pages and to uploaded files
+// rewritten to be relative, and an index of all pages is at "_pages.html". The
+// archive can be browsed offline as a snapshot of documentation.
+func (repo *Repository) ExportWiki(w io.Writer) error {
+	wikiRepo, err := git.OpenRepository(repo.WikiPath())
+	if err != nil {
+		return fmt.Errorf("OpenRepository: %v", err)
+	}
+	commit, err := wikiRepo.GetBranchCommit("master")
+	if err != nil {
+		return fmt.Errorf("GetBranchCommit: %v", err)
+	}
+	pages, err := ListWikiPages(commit)
+	if err != nil {
+		return fmt.Errorf("ListWikiPages: %v", err)
+	}
+	files, err := listWikiFiles(&commit.Tree, "")
+	if err != nil {
+		return fmt.Errorf("listWikiFiles: %v", err)
+	}
+
+	titles := make([]string, 0, len(pages))
+	for _, p := range pages {
+		if !p.IsDir {
+			titles = append(titles, p.Title)
+		}
+	}
+
+	z := zip.NewWriter(w)
+	indexTitle := repo.FullName() + " Wiki"
+	repoLink := repo.Link()
+	metas := repo.ComposeMetas()
+
+	var index strings.Builder
+	index.WriteString("<ul class=\"pages\">\n")
+	depth := 0
+	for _, p := range pages {
+		for ; depth > p.Depth; depth-- {
+			index.WriteString("</ul></li>\n")
+		}
+
+		if p.IsDir {
+			index.WriteString("<li><strong>" + html.EscapeString(p.Name) + "</strong>\n<ul class=\"pages\">\n")
+			depth++
+			continue
+		}
+		index.WriteString(`<li><a href="` + relativeWikiExportLink(0, wikiExportFilename(p.Title)) + `">` + html.EscapeString(p.Name) + "</a></li>\n")
+
+		blob, err := commit.GetBlobByPath(p.Filename)
+		if err != nil {
+			return fmt.Errorf("GetBlobByPath [%s]: %v", p.Filename, err)
+		}
+		r, err := blob.Data()
+		if err != nil {
+			return fmt.Errorf("Data [%s]: %v", p.Filename, err)
+		}
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("ReadAll [%s]: %v", p.Filename, err)
+		}
+
+		content := string(markup.Render(markup.Detect(p.Filename), data, repoLink, metas))
+		content = rewriteWikiExportLinks(content, repoLink, titles, p.Depth)
+		if err = writeWikiExportPage(z, wikiExportFilename(p.Title), p.Title, indexTitle, content, p.Depth); err != nil {
+			return fmt.Errorf("write page [%s]: %v", p.Title, err)
+		}
+	}
+	for ; depth > 0; depth-- {
+		index.WriteString("</ul></li>\n")
+	}
+	index.WriteString("</ul>\n")
+	if err = writeWikiExportPage(z, wikiExportIndex, indexTitle, indexTitle, index.String(), 0); err != nil {
+		return fmt.Errorf("write index: %v", err)
+	}
+
+	for _, name := range files {
+		blob, err := commit.GetBlobByPath(name)
+		if err != nil {
+			return fmt.Errorf("GetBlobByPath [%s]: %v", name, err)
+		}
+		r, err := blob.Data()
+		if err != nil {
+			return fmt.Errorf("Data [%s]: %v", name, err)
+		}
+		fw, err := z.Create(name)
+		if err != nil {
+			return fmt.Errorf("create file [%s]: %v", name, err)
+		}
+		if _, err = io.Copy(fw, r); err != nil {
+			return fmt.Errorf("copy file [%s]: %v", name, err)
+		}
+	}
+
+	return z.Close()
+}
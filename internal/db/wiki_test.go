@@ -0,0 +1,95 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_ParseWikiPageFilename(t *testing.T) {
+	Convey("Parse titles of wiki pages from file names", t, func() {
+		testCases := []struct {
+			filename string
+			title    string
+			ok       bool
+		}{
+			{"Home.md", "Home", true},
+			{"Getting Started.adoc", "Getting Started", true},
+			{"Notes.org", "Notes", true},
+			{"API.rst", "API", true},
+			{"v1.2 Release.markdown", "v1.2 Release", true},
+			{"logo.png", "", false},
+			{"README", "", false},
+		}
+		for _, tc := range testCases {
+			title, ok := ParseWikiPageFilename(tc.filename)
+			So(title, ShouldEqual, tc.title)
+			So(ok, ShouldEqual, tc.ok)
+		}
+	})
+}
+
+func Test_matchWikiPageFilename(t *testing.T) {
+	Convey("Match file name of wiki page by title", t, func() {
+		filenames := []string{"Home.adoc", "Home.md", "Install.rst", "Install.txt", "logo.png"}
+		So(matchWikiPageFilename(filenames, "Home"), ShouldEqual, "Home.md")
+		So(matchWikiPageFilename(filenames, "Install"), ShouldEqual, "Install.rst")
+		So(matchWikiPageFilename(filenames, "logo"), ShouldBeEmpty)
+		So(matchWikiPageFilename(filenames, "FAQ"), ShouldBeEmpty)
+	})
+}
+
+func Test_wikiPageExtension(t *testing.T) {
+	Convey("Get file extension of wiki page formats", t, func() {
+		So(wikiPageExtension(".adoc"), ShouldEqual, ".adoc")
+		So(wikiPageExtension(".markdown"), ShouldEqual, ".markdown")
+		So(wikiPageExtension(".sh"), ShouldEqual, ".md")
+		So(wikiPageExtension(""), ShouldEqual, ".md")
+	})
+}
+
+func Test_ToWikiPageName(t *testing.T) {
+	Convey("Format URLs to names of wiki pages", t, func() {
+		testCases := []struct {
+			url  string
+			name string
+		}{
+			{"Home", "Home"},
+			{"Getting+Started", "Getting Started"},
+			{"Guides%2FInstall", "Guides/Install"},
+			{"Guides/Install/", "Guides/Install"},
+			{"../../Install", "Install"},
+			{".git/config", "git/config"},
+			{"Guides/../.Install", ".Install"},
+			{"/a//..b/c", "a/b/c"},
+		}
+		for _, tc := range testCases {
+			So(ToWikiPageName(tc.url), ShouldEqual, tc.name)
+		}
+	})
+}
+
+func Test_rewriteWikiExportLinks(t *testing.T) {
+	Convey("Rewrite links in rendered wiki pages for export", t, func() {
+		titles := []string{"Home", "Guides/Install", "Getting Started"}
+		content := `<a href="/user/repo/wiki/Guides%2FInstall">Install</a>` +
+			`<a href="/user/repo/wiki/Getting+Started">Start</a>` +
+			`<img src="/user/repo/wiki/_raw/attachments/1/logo.png">` +
+			`<a href="/user/repo/issues/1">#1</a>` +
+			`<a href="https://gogs.io">Gogs</a>`
+
+		So(rewriteWikiExportLinks(content, "/user/repo", titles, 0), ShouldEqual,
+			`<a href="Guides/Install.html">Install</a>`+
+				`<a href="Getting%20Started.html">Start</a>`+
+				`<img src="attachments/1/logo.png">`+
+				`<a href="http://localhost:3000/user/repo/issues/1">#1</a>`+
+				`<a href="https://gogs.io">Gogs</a>`)
+
+		So(rewriteWikiExportLinks(`<a href="/user/repo/wiki/Home">Home</a>`, "/user/repo", titles, 2), ShouldEqual,
+			`<a href="../../Home.html">Home</a>`)
+	})
+}
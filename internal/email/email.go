@@ -7,6 +7,7 @@ package email
 import (
 	"fmt"
 	"html/template"
+	"os"
 	"path/filepath"
 	"sync"
 	"time"
@@ -29,49 +30,106 @@ const (
 	MAIL_ISSUE_COMMENT = "issue/comment"
 	MAIL_ISSUE_MENTION = "issue/mention"
 
-	MAIL_NOTIFY_COLLABORATOR = "notify/collaborator"
+	MAIL_NOTIFY_COLLABORATOR    = "notify/collaborator"
+	MAIL_NOTIFY_REVIEW_REMINDER = "notify/review_reminder"
+	MAIL_NOTIFY_DIGEST          = "notify/digest"
+	MAIL_NOTIFY_NOTIFICATIONS   = "notify/notifications"
+	MAIL_NOTIFY_STAR_THRESHOLD  = "notify/star_threshold"
+	MAIL_NOTIFY_KEY_AUDIT       = "notify/key_audit"
 )
 
 var (
-	tplRender     *macaron.TplRender
-	tplRenderOnce sync.Once
+	tplRender        *macaron.TplRender
+	tplRenderModTime time.Time
+	tplRenderLock    sync.Mutex
 )
 
-// render renders a mail template with given data.
-func render(tpl string, data map[string]interface{}) (string, error) {
-	tplRenderOnce.Do(func() {
-		opt := &macaron.RenderOptions{
-			Directory:         filepath.Join(conf.WorkDir(), "templates", "mail"),
-			AppendDirectories: []string{filepath.Join(conf.CustomDir(), "templates", "mail")},
-			Extensions:        []string{".tmpl", ".html"},
-			Funcs: []template.FuncMap{map[string]interface{}{
-				"AppName": func() string {
-					return conf.App.BrandName
-				},
-				"AppURL": func() string {
-					return conf.Server.ExternalURL
-				},
-				"Year": func() int {
-					return time.Now().Year()
-				},
-				"Str2HTML": func(raw string) template.HTML {
-					return template.HTML(markup.Sanitize(raw))
-				},
-			}},
+// customTemplatesDir returns the directory of mail templates that override default ones.
+func customTemplatesDir() string {
+	return filepath.Join(conf.CustomDir(), "templates", "mail")
+}
+
+// customTemplatesModTime returns the latest modification time of custom mail templates
+// and their directories, so that adding, changing and removing templates are noticed.
+func customTemplatesModTime() time.Time {
+	var latest time.Time
+	_ = filepath.Walk(customTemplatesDir(), func(_ string, fi os.FileInfo, err error) error {
+		if err == nil && fi.ModTime().After(latest) {
+			latest = fi.ModTime()
 		}
-		if !conf.Server.LoadAssetsFromDisk {
-			opt.TemplateFileSystem = templates.NewTemplateFileSystem("mail", opt.AppendDirectories[0])
+		return nil
+	})
+	return latest
+}
+
+// newTplRender compiles mail templates, it returns an error instead of panicking when
+// any custom template is invalid.
+func newTplRender() (_ *macaron.TplRender, err error) {
+	opt := &macaron.RenderOptions{
+		Directory:         filepath.Join(conf.WorkDir(), "templates", "mail"),
+		AppendDirectories: []string{customTemplatesDir()},
+		Extensions:        []string{".tmpl", ".html"},
+		Funcs: []template.FuncMap{map[string]interface{}{
+			"AppName": func() string {
+				return conf.App.BrandName
+			},
+			"AppURL": func() string {
+				return conf.Server.ExternalURL
+			},
+			"Year": func() int {
+				return time.Now().Year()
+			},
+			"Str2HTML": func(raw string) template.HTML {
+				return template.HTML(markup.Sanitize(raw))
+			},
+		}},
+	}
+	if !conf.Server.LoadAssetsFromDisk {
+		opt.TemplateFileSystem = templates.NewTemplateFileSystem("mail", opt.AppendDirectories[0])
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("compile mail templates: %v", r)
 		}
+	}()
+	ts := macaron.NewTemplateSet()
+	ts.Set(macaron.DEFAULT_TPL_SET_NAME, opt)
+	return &macaron.TplRender{
+		TemplateSet: ts,
+		Opt:         opt,
+	}, nil
+}
 
-		ts := macaron.NewTemplateSet()
-		ts.Set(macaron.DEFAULT_TPL_SET_NAME, opt)
-		tplRender = &macaron.TplRender{
-			TemplateSet: ts,
-			Opt:         opt,
+// render renders a mail template with given data. Templates are compiled again when
+// custom mail templates are changed, which takes effect without restarting. Previous
+// templates are kept if changed templates are invalid.
+func render(tpl string, data map[string]interface{}) (_ string, err error) {
+	tplRenderLock.Lock()
+	if modTime := customTemplatesModTime(); tplRender == nil || !modTime.Equal(tplRenderModTime) {
+		r, err := newTplRender()
+		if err != nil {
+			log.Error("Failed to reload mail templates: %v", err)
+			if tplRender == nil {
+				tplRenderLock.Unlock()
+				return "", err
+			}
+		} else {
+			tplRender = r
 		}
-	})
+		// Do not retry until templates are changed again.
+		tplRenderModTime = modTime
+	}
+	r := tplRender
+	tplRenderLock.Unlock()
 
-	return tplRender.HTMLString(tpl, data)
+	// Templates are compiled again on every render in development mode.
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("render mail template: %v", r)
+		}
+	}()
+	return r.HTMLString(tpl, data)
 }
 
 func SendTestMail(email string) error {
@@ -187,6 +245,175 @@ func SendCollaboratorMail(u, doer User, repo Repository) {
 	Send(msg)
 }
 
+// SendReviewReminderMail sends mail notification to remind the reviewer of a pending review request.
+func SendReviewReminderMail(u User, issue Issue, repo Repository, days int) {
+	subject := fmt.Sprintf("Review requested %d days ago: %s", days, issue.MailSubject())
+
+	data := map[string]interface{}{
+		"Subject":  subject,
+		"RepoName": repo.FullName(),
+		"Days":     days,
+		"Link":     issue.HTMLURL(),
+	}
+	body, err := render(MAIL_NOTIFY_REVIEW_REMINDER, data)
+	if err != nil {
+		log.Error("HTMLString: %v", err)
+		return
+	}
+
+	msg := NewMessage([]string{u.Email()}, subject, body)
+	msg.Info = fmt.Sprintf("UID: %d, review reminder", u.ID())
+
+	Send(msg)
+}
+
+// SendStarThresholdMail sends mail notification to the owner of a repository that has
+// reached the number of stars.
+func SendStarThresholdMail(u User, repo Repository, stars int) {
+	subject := fmt.Sprintf("%s has reached %d stars", repo.FullName(), stars)
+
+	data := map[string]interface{}{
+		"Subject":  subject,
+		"RepoName": repo.FullName(),
+		"Stars":    stars,
+		"Link":     repo.HTMLURL() + "/stars",
+	}
+	body, err := render(MAIL_NOTIFY_STAR_THRESHOLD, data)
+	if err != nil {
+		log.Error("HTMLString: %v", err)
+		return
+	}
+
+	msg := NewMessage([]string{u.Email()}, subject, body)
+	msg.Info = fmt.Sprintf("UID: %d, star threshold", u.ID())
+
+	Send(msg)
+}
+
+// DigestItem is an issue or pull request listed in a digest email.
+type DigestItem struct {
+	RepoName string
+	Index    int64
+	Title    string
+	IsPull   bool
+	Link     string
+}
+
+// Digest contains the summary sent by a digest email.
+type Digest struct {
+	Scope           string // Name of the organization, or empty for watched repositories.
+	IsWeekly        bool
+	NewIssues       []*DigestItem
+	AwaitingReview  []*DigestItem
+	Stale           []*DigestItem
+	StaleDays       int
+	UnsubscribeLink string
+}
+
+// SendDigestMail sends scheduled digest email of issues and pull requests to the user.
+func SendDigestMail(u User, d *Digest) {
+	period := "Daily"
+	if d.IsWeekly {
+		period = "Weekly"
+	}
+	subject := fmt.Sprintf("[%s] %s digest", conf.App.BrandName, period)
+	if d.Scope != "" {
+		subject += " of " + d.Scope
+	}
+
+	data := map[string]interface{}{
+		"Subject":         subject,
+		"Scope":           d.Scope,
+		"NewIssues":       d.NewIssues,
+		"AwaitingReview":  d.AwaitingReview,
+		"Stale":           d.Stale,
+		"StaleDays":       d.StaleDays,
+		"UnsubscribeLink": d.UnsubscribeLink,
+	}
+	body, err := render(MAIL_NOTIFY_DIGEST, data)
+	if err != nil {
+		log.Error("HTMLString: %v", err)
+		return
+	}
+
+	msg := NewMessage([]string{u.Email()}, subject, body)
+	msg.Info = fmt.Sprintf("UID: %d, digest", u.ID())
+
+	Send(msg)
+}
+
+// NotificationDigestItem is a notification of an issue or pull request listed in a
+// notification digest email.
+type NotificationDigestItem struct {
+	RepoName string
+	Index    int64
+	Title    string
+	IsPull   bool
+	Reason   string
+	Link     string
+}
+
+// SendNotificationDigestMail sends hourly or daily batched notifications to the user.
+func SendNotificationDigestMail(u User, items []*NotificationDigestItem, isDaily bool) {
+	period := "Hourly"
+	if isDaily {
+		period = "Daily"
+	}
+	subject := fmt.Sprintf("[%s] %s notifications: %d updates", conf.App.BrandName, period, len(items))
+
+	data := map[string]interface{}{
+		"Subject":  subject,
+		"Items":    items,
+		"Settings": conf.Server.ExternalURL + "user/settings/digests",
+	}
+	body, err := render(MAIL_NOTIFY_NOTIFICATIONS, data)
+	if err != nil {
+		log.Error("HTMLString: %v", err)
+		return
+	}
+
+	msg := NewMessage([]string{u.Email()}, subject, body)
+	msg.Info = fmt.Sprintf("UID: %d, notification digest", u.ID())
+
+	Send(msg)
+}
+
+// AuditedKey is a public key listed in a key audit email.
+type AuditedKey struct {
+	Name        string
+	Fingerprint string
+	Algorithm   string
+	Bits        int
+	Repos       []string // Full names of repositories that use the deploy key
+}
+
+// SendKeyAuditMail sends mail notification to the user about weak public keys found by
+// an admin audit, which asks for replacing the keys or tells they have been revoked.
+func SendKeyAuditMail(u User, keys []*AuditedKey, revoked bool) {
+	subject := fmt.Sprintf("[%s] Please replace your weak SSH keys", conf.App.BrandName)
+	if revoked {
+		subject = fmt.Sprintf("[%s] Your SSH keys have been revoked", conf.App.BrandName)
+	}
+
+	data := map[string]interface{}{
+		"Subject":   subject,
+		"Username":  u.DisplayName(),
+		"Keys":      keys,
+		"IsRevoked": revoked,
+		"Link":      conf.Server.ExternalURL + "user/settings/ssh",
+	}
+	body, err := render(MAIL_NOTIFY_KEY_AUDIT, data)
+	if err != nil {
+		log.Error("HTMLString: %v", err)
+		return
+	}
+
+	msg := NewMessage([]string{u.Email()}, subject, body)
+	msg.Info = fmt.Sprintf("UID: %d, key audit", u.ID())
+
+	Send(msg)
+}
+
 func composeTplData(subject, body, link string) map[string]interface{} {
 	data := make(map[string]interface{}, 10)
 	data["Subject"] = subject
@@ -0,0 +1,120 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package email
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"gopkg.in/gomail.v2"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/osutil"
+)
+
+// Templates is the list of names of all mail templates.
+var Templates = []string{
+	MAIL_AUTH_ACTIVATE,
+	MAIL_AUTH_ACTIVATE_EMAIL,
+	MAIL_AUTH_RESET_PASSWORD,
+	MAIL_AUTH_REGISTER_NOTIFY,
+	MAIL_ISSUE_COMMENT,
+	MAIL_ISSUE_MENTION,
+	MAIL_NOTIFY_COLLABORATOR,
+	MAIL_NOTIFY_REVIEW_REMINDER,
+	MAIL_NOTIFY_DIGEST,
+	MAIL_NOTIFY_NOTIFICATIONS,
+	MAIL_NOTIFY_STAR_THRESHOLD,
+	MAIL_NOTIFY_KEY_AUDIT,
+}
+
+// IsValidTemplate returns true if the name is one of mail templates.
+func IsValidTemplate(tpl string) bool {
+	for _, name := range Templates {
+		if name == tpl {
+			return true
+		}
+	}
+	return false
+}
+
+// IsCustomTemplate returns true if the mail template is overridden by a file in the
+// custom directory.
+func IsCustomTemplate(tpl string) bool {
+	for _, ext := range []string{".tmpl", ".html"} {
+		if osutil.IsFile(filepath.Join(customTemplatesDir(), filepath.FromSlash(tpl)+ext)) {
+			return true
+		}
+	}
+	return false
+}
+
+// sampleData returns made-up data of the mail template to preview it.
+func sampleData(tpl string) map[string]interface{} {
+	link := conf.Server.ExternalURL + "gogs/gogs"
+	item := &DigestItem{
+		RepoName: "gogs/gogs",
+		Index:    1,
+		Title:    "Sample issue",
+		Link:     link + "/issues/1",
+	}
+	data := map[string]interface{}{
+		"Subject":           fmt.Sprintf("[%s] Test render of %s", conf.App.BrandName, tpl),
+		"Username":          "gogs",
+		"Email":             "gogs@example.com",
+		"Code":              "sample-code",
+		"ActiveCodeLives":   conf.Auth.ActivateCodeLives / 60,
+		"ResetPwdCodeLives": conf.Auth.ResetPasswordCodeLives / 60,
+		"Body":              "<p>This is a sample comment.</p>",
+		"Link":              link + "/issues/1",
+		"Doer":              map[string]string{"DisplayName": "gogs"},
+		"RepoName":          "gogs/gogs",
+		"Days":              3,
+		"Stars":             100,
+	}
+
+	switch tpl {
+	case MAIL_NOTIFY_DIGEST:
+		data["NewIssues"] = []*DigestItem{item}
+		data["AwaitingReview"] = []*DigestItem{item}
+		data["Stale"] = []*DigestItem{item}
+		data["StaleDays"] = 30
+		data["UnsubscribeLink"] = conf.Server.ExternalURL + "user/settings/digests"
+	case MAIL_NOTIFY_NOTIFICATIONS:
+		data["Items"] = []*NotificationDigestItem{{
+			RepoName: item.RepoName,
+			Index:    item.Index,
+			Title:    item.Title,
+			Reason:   "mention",
+			Link:     item.Link,
+		}}
+		data["Settings"] = conf.Server.ExternalURL + "user/settings/digests"
+	case MAIL_NOTIFY_KEY_AUDIT:
+		data["Keys"] = []*AuditedKey{{
+			Name:        "sample",
+			Fingerprint: "SHA256:sample",
+			Algorithm:   "ssh-dss",
+			Bits:        1024,
+		}}
+		data["Link"] = conf.Server.ExternalURL + "user/settings/ssh"
+	}
+	return data
+}
+
+// RenderPreview renders the mail template with made-up data.
+func RenderPreview(tpl string) (string, error) {
+	return render(tpl, sampleData(tpl))
+}
+
+// SendTestRender renders the mail template with made-up data and sends it to given
+// email address synchronously.
+func SendTestRender(tpl, to string) error {
+	body, err := RenderPreview(tpl)
+	if err != nil {
+		return err
+	}
+	subject := sampleData(tpl)["Subject"].(string)
+	return gomail.Send(&Sender{}, NewMessage([]string{to}, subject, body).Message)
+}
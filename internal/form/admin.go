@@ -31,13 +31,24 @@ type AdminEditUser struct {
 	Website          string `binding:"MaxSize(50)"`
 	Location         string `binding:"MaxSize(50)"`
 	MaxRepoCreation  int
+	StorageRoot      string
 	Active           bool
 	Admin            bool
 	AllowGitHook     bool
 	AllowImportLocal bool
 	ProhibitLogin    bool
+	Deactivated      bool
 }
 
 func (f *AdminEditUser) Validate(ctx *macaron.Context, errs binding.Errors) binding.Errors {
 	return validate(errs, ctx.Data, f, ctx.Locale)
 }
+
+type AdminDeleteUser struct {
+	ContentAttribution string
+	TransferReposTo    string
+}
+
+func (f *AdminDeleteUser) Validate(ctx *macaron.Context, errs binding.Errors) binding.Errors {
+	return validate(errs, ctx.Data, f, ctx.Locale)
+}
@@ -24,18 +24,68 @@ type UpdateOrgSetting struct {
 	Website         string `binding:"Url;MaxSize(100)"`
 	Location        string `binding:"MaxSize(50)"`
 	MaxRepoCreation int
+	AllowedLicenses string
 }
 
 func (f *UpdateOrgSetting) Validate(ctx *macaron.Context, errs binding.Errors) binding.Errors {
 	return validate(errs, ctx.Data, f, ctx.Locale)
 }
 
+type OrgRepoDefaults struct {
+	Visibility            int
+	DefaultBranch         string `binding:"AlphaDashDotSlash;MaxSize(100)"`
+	LabelTemplate         string
+	ProtectDefaultBranch  bool
+	RequirePullRequest    bool
+	WebhookURL            string `binding:"Url;MaxSize(255)"`
+	WebhookContentType    int
+	WebhookSecret         string
+	WebhookSendEverything bool
+}
+
+func (f *OrgRepoDefaults) Validate(ctx *macaron.Context, errs binding.Errors) binding.Errors {
+	return validate(errs, ctx.Data, f, ctx.Locale)
+}
+
 type CreateTeam struct {
-	TeamName    string `binding:"Required;AlphaDashDot;MaxSize(30)"`
-	Description string `binding:"MaxSize(255)"`
-	Permission  string
+	TeamName       string `binding:"Required;AlphaDashDot;MaxSize(30)"`
+	Description    string `binding:"MaxSize(255)"`
+	ParentID       int64
+	Permission     string
+	UnitCode       string
+	UnitIssues     string
+	UnitWiki       string
+	UnitReleases   string
+	ReviewStrategy int
+	ReviewerCount  int `binding:"Range(1,10)"`
 }
 
 func (f *CreateTeam) Validate(ctx *macaron.Context, errs binding.Errors) binding.Errors {
 	return validate(errs, ctx.Data, f, ctx.Locale)
 }
+
+type CreateProject struct {
+	Title       string `binding:"Required;MaxSize(100)"`
+	Description string
+}
+
+func (f *CreateProject) Validate(ctx *macaron.Context, errs binding.Errors) binding.Errors {
+	return validate(errs, ctx.Data, f, ctx.Locale)
+}
+
+type CreateProjectColumn struct {
+	Title string `binding:"Required;MaxSize(50)"`
+}
+
+func (f *CreateProjectColumn) Validate(ctx *macaron.Context, errs binding.Errors) binding.Errors {
+	return validate(errs, ctx.Data, f, ctx.Locale)
+}
+
+type AddProjectCard struct {
+	ColumnID int64  `binding:"Required"`
+	Issue    string `binding:"Required"`
+}
+
+func (f *AddProjectCard) Validate(ctx *macaron.Context, errs binding.Errors) binding.Errors {
+	return validate(errs, ctx.Data, f, ctx.Locale)
+}
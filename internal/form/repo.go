@@ -5,6 +5,7 @@
 package form
 
 import (
+	"mime/multipart"
 	"net/url"
 	"strings"
 
@@ -26,11 +27,13 @@ type CreateRepo struct {
 	UserID      int64  `binding:"Required"`
 	RepoName    string `binding:"Required;AlphaDashDot;MaxSize(100)"`
 	Private     bool
+	Internal    bool
 	Description string `binding:"MaxSize(512)"`
 	AutoInit    bool
 	Gitignores  string
 	License     string
 	Readme      string
+	TemplateID  int64
 }
 
 func (f *CreateRepo) Validate(ctx *macaron.Context, errs binding.Errors) binding.Errors {
@@ -46,6 +49,13 @@ type MigrateRepo struct {
 	Mirror       bool   `json:"mirror"`
 	Private      bool   `json:"private"`
 	Description  string `json:"description" binding:"MaxSize(512)"`
+
+	// Service to import issues, pull requests and releases from, only Git data is migrated when it is empty.
+	Service      string `json:"service" binding:"OmitEmpty;In(github,gitlab)"`
+	AuthToken    string `json:"auth_token"`
+	Issues       bool   `json:"issues"`
+	PullRequests bool   `json:"pull_requests"`
+	Releases     bool   `json:"releases"`
 }
 
 func (f *MigrateRepo) Validate(ctx *macaron.Context, errs binding.Errors) binding.Errors {
@@ -69,8 +79,13 @@ func (f MigrateRepo) ParseRemoteAddr(user *db.User) (string, error) {
 		}
 		if len(f.AuthUsername)+len(f.AuthPassword) > 0 {
 			u.User = url.UserPassword(f.AuthUsername, f.AuthPassword)
+		} else if f.AuthToken != "" {
+			// Both GitHub and GitLab accept access tokens as passwords.
+			u.User = url.UserPassword("oauth2", f.AuthToken)
 		}
 		remoteAddr = u.String()
+	} else if f.Service != "" {
+		return "", db.ErrInvalidCloneAddr{IsURLError: true}
 	} else if !user.CanImportLocal() {
 		return "", db.ErrInvalidCloneAddr{IsPermissionDenied: true}
 	} else if !com.IsDir(remoteAddr) {
@@ -80,6 +95,30 @@ func (f MigrateRepo) ParseRemoteAddr(user *db.User) (string, error) {
 	return remoteAddr, nil
 }
 
+// MigrationOptions returns options of importing data through API of the service.
+func (f MigrateRepo) MigrationOptions() db.MigrationOptions {
+	return db.MigrationOptions{
+		Service:      f.Service,
+		Token:        f.AuthToken,
+		Issues:       f.Issues,
+		PullRequests: f.PullRequests,
+		Releases:     f.Releases,
+	}
+}
+
+// ImportRepo is the form of importing a repository archive, name of the repository
+// in the archive is used when RepoName is empty.
+type ImportRepo struct {
+	Uid      int64  `binding:"Required"`
+	RepoName string `binding:"AlphaDashDot;MaxSize(100)"`
+	Private  bool
+	Archive  *multipart.FileHeader `binding:"Required"`
+}
+
+func (f *ImportRepo) Validate(ctx *macaron.Context, errs binding.Errors) binding.Errors {
+	return validate(errs, ctx.Data, f, ctx.Locale)
+}
+
 type RepoSetting struct {
 	RepoName      string `binding:"Required;AlphaDashDot;MaxSize(100)"`
 	Description   string `binding:"MaxSize(512)"`
@@ -88,13 +127,18 @@ type RepoSetting struct {
 	Interval      int
 	MirrorAddress string
 	Private       bool
+	Internal      bool
 	EnablePrune   bool
 
+	DisableIndexing bool
+	Template        bool
+
 	// Advanced settings
 	EnableWiki            bool
 	AllowPublicWiki       bool
 	EnableExternalWiki    bool
 	ExternalWikiURL       string
+	WikiWriteAccess       string
 	EnableIssues          bool
 	AllowPublicIssues     bool
 	EnableExternalTracker bool
@@ -104,12 +148,68 @@ type RepoSetting struct {
 	EnablePulls           bool
 	PullsIgnoreWhitespace bool
 	PullsAllowRebase      bool
+	PullsRequireResolved  bool
+	PullsDeleteHeadBranch bool
+
+	// Garbage collection settings
+	EnableAutoGC bool
 }
 
 func (f *RepoSetting) Validate(ctx *macaron.Context, errs binding.Errors) binding.Errors {
 	return validate(errs, ctx.Data, f, ctx.Locale)
 }
 
+type AddPushMirror struct {
+	Address      string `binding:"Required"`
+	AuthUsername string
+	AuthPassword string
+	Interval     int
+	SyncOnPush   bool
+}
+
+func (f *AddPushMirror) Validate(ctx *macaron.Context, errs binding.Errors) binding.Errors {
+	return validate(errs, ctx.Data, f, ctx.Locale)
+}
+
+// ParseRemoteAddr checks if given remote address is a valid HTTP/HTTPS URL,
+// and returns composed URL with needed username and password.
+func (f AddPushMirror) ParseRemoteAddr() (string, error) {
+	remoteAddr := strings.TrimSpace(f.Address)
+	if !strings.HasPrefix(remoteAddr, "http://") &&
+		!strings.HasPrefix(remoteAddr, "https://") {
+		return "", db.ErrInvalidCloneAddr{IsURLError: true}
+	}
+
+	u, err := url.Parse(remoteAddr)
+	if err != nil {
+		return "", db.ErrInvalidCloneAddr{IsURLError: true}
+	}
+	if len(f.AuthUsername)+len(f.AuthPassword) > 0 {
+		u.User = url.UserPassword(f.AuthUsername, f.AuthPassword)
+	}
+	return u.String(), nil
+}
+
+type IssueMirror struct {
+	Service  string `binding:"Required;In(gogs,github)"`
+	Address  string `binding:"Required;Url"`
+	Token    string
+	Interval int
+}
+
+func (f *IssueMirror) Validate(ctx *macaron.Context, errs binding.Errors) binding.Errors {
+	return validate(errs, ctx.Data, f, ctx.Locale)
+}
+
+type Autolink struct {
+	Pattern string `binding:"Required;MaxSize(255)"`
+	URL     string `binding:"Required;MaxSize(255)"`
+}
+
+func (f *Autolink) Validate(ctx *macaron.Context, errs binding.Errors) binding.Errors {
+	return validate(errs, ctx.Data, f, ctx.Locale)
+}
+
 // __________                             .__
 // \______   \____________    ____   ____ |  |__
 //  |    |  _/\_  __ \__  \  /    \_/ ___\|  |  \
@@ -129,6 +229,16 @@ func (f *ProtectBranch) Validate(ctx *macaron.Context, errs binding.Errors) bind
 	return validate(errs, ctx.Data, f, ctx.Locale)
 }
 
+type RefNameRules struct {
+	BranchNamePatterns   string
+	TagNamePatterns      string
+	RestrictRefNameChars bool
+}
+
+func (f *RefNameRules) Validate(ctx *macaron.Context, errs binding.Errors) binding.Errors {
+	return validate(errs, ctx.Data, f, ctx.Locale)
+}
+
 //  __      __      ___.   .__    .__            __
 // /  \    /  \ ____\_ |__ |  |__ |  |__   ____ |  | __
 // \   \/\/   // __ \| __ \|  |  \|  |  \ /  _ \|  |/ /
@@ -146,7 +256,13 @@ type Webhook struct {
 	IssueComment bool
 	PullRequest  bool
 	Release      bool
+	Repository   bool
+	Wiki         bool
+	BranchFilter string
+	PathFilter   string
 	Active       bool
+
+	ConfidentialIssues bool
 }
 
 func (f Webhook) PushOnly() bool {
@@ -206,6 +322,28 @@ func (f *NewDingtalkHook) Validate(ctx *macaron.Context, errs binding.Errors) bi
 	return validate(errs, ctx.Data, f, ctx.Locale)
 }
 
+type NewMatrixHook struct {
+	HomeserverURL string `binding:"Required;Url"`
+	RoomID        string `binding:"Required"`
+	AccessToken   string `binding:"Required"`
+	MessageType   string
+	Webhook
+}
+
+func (f *NewMatrixHook) Validate(ctx *macaron.Context, errs binding.Errors) binding.Errors {
+	return validate(errs, ctx.Data, f, ctx.Locale)
+}
+
+type NewTelegramHook struct {
+	BotToken string `binding:"Required"`
+	ChatID   string `binding:"Required"`
+	Webhook
+}
+
+func (f *NewTelegramHook) Validate(ctx *macaron.Context, errs binding.Errors) binding.Errors {
+	return validate(errs, ctx.Data, f, ctx.Locale)
+}
+
 // .___
 // |   | ______ ________ __   ____
 // |   |/  ___//  ___/  |  \_/ __ \
@@ -220,6 +358,8 @@ type NewIssue struct {
 	AssigneeID  int64
 	Content     string
 	Files       []string
+	// Confidential issues are only visible to the poster and users who can triage issues.
+	Confidential bool
 }
 
 func (f *NewIssue) Validate(ctx *macaron.Context, errs binding.Errors) binding.Errors {
@@ -321,6 +461,7 @@ func (f *EditRelease) Validate(ctx *macaron.Context, errs binding.Errors) bindin
 type NewWiki struct {
 	OldTitle string
 	Title    string `binding:"Required"`
+	Format   string
 	Content  string `binding:"Required"`
 	Message  string
 }
@@ -345,6 +486,7 @@ type EditRepoFile struct {
 	CommitChoice  string `binding:"Required;MaxSize(50)"`
 	NewBranchName string `binding:"AlphaDashDotSlash;MaxSize(100)"`
 	LastCommit    string
+	Stage         bool
 }
 
 func (f *EditRepoFile) Validate(ctx *macaron.Context, errs binding.Errors) binding.Errors {
@@ -408,6 +550,7 @@ type DeleteRepoFile struct {
 	CommitMessage string
 	CommitChoice  string `binding:"Required;MaxSize(50)"`
 	NewBranchName string `binding:"AlphaDashDot;MaxSize(100)"`
+	Stage         bool
 }
 
 func (f *DeleteRepoFile) Validate(ctx *macaron.Context, errs binding.Errors) binding.Errors {
@@ -417,3 +560,27 @@ func (f *DeleteRepoFile) Validate(ctx *macaron.Context, errs binding.Errors) bin
 func (f *DeleteRepoFile) IsNewBrnach() bool {
 	return f.CommitChoice == "commit-to-new-branch"
 }
+
+type CommitStagedChanges struct {
+	CommitSummary string `binding:"MaxSize(100)"`
+	CommitMessage string
+	CommitChoice  string `binding:"Required;MaxSize(50)"`
+	NewBranchName string `binding:"AlphaDashDotSlash;MaxSize(100)"`
+}
+
+func (f *CommitStagedChanges) Validate(ctx *macaron.Context, errs binding.Errors) binding.Errors {
+	return validate(errs, ctx.Data, f, ctx.Locale)
+}
+
+func (f *CommitStagedChanges) IsNewBrnach() bool {
+	return f.CommitChoice == "commit-to-new-branch"
+}
+
+type CherryPick struct {
+	Branch        string `binding:"Required;MaxSize(100)"`
+	NewBranchName string `binding:"AlphaDashDotSlash;MaxSize(100)"`
+}
+
+func (f *CherryPick) Validate(ctx *macaron.Context, errs binding.Errors) binding.Errors {
+	return validate(errs, ctx.Data, f, ctx.Locale)
+}
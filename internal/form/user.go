@@ -103,6 +103,15 @@ func (f *UpdateProfile) Validate(ctx *macaron.Context, errs binding.Errors) bind
 	return validate(errs, ctx.Data, f, ctx.Locale)
 }
 
+type StarList struct {
+	Name        string `binding:"Required;MaxSize(50)"`
+	Description string `binding:"MaxSize(255)"`
+}
+
+func (f *StarList) Validate(ctx *macaron.Context, errs binding.Errors) binding.Errors {
+	return validate(errs, ctx.Data, f, ctx.Locale)
+}
+
 const (
 	AVATAR_LOCAL  string = "local"
 	AVATAR_BYMAIL string = "bymail"
@@ -78,6 +78,11 @@ func Put(url string) *Request {
 	return newRequest(url, "PUT")
 }
 
+// Patch returns *Request with PATCH method.
+func Patch(url string) *Request {
+	return newRequest(url, "PATCH")
+}
+
 // Delete returns *Request DELETE method.
 func Delete(url string) *Request {
 	return newRequest(url, "DELETE")
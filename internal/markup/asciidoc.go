@@ -0,0 +1,287 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package markup
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strings"
+
+	"gogs.io/gogs/internal/lazyregexp"
+)
+
+var asciiDocExtensions = []string{".adoc", ".asciidoc", ".asc"}
+
+// IsAsciiDocFile reports whether name looks like an AsciiDoc file based on its extension.
+func IsAsciiDocFile(name string) bool {
+	return hasExtension(name, asciiDocExtensions)
+}
+
+var (
+	asciiDocHeadingPattern    = lazyregexp.New(`^(={1,6})\s+(.+?)(\s+=+)?$`)
+	asciiDocAttributePattern  = lazyregexp.New(`^:!?[\w-]+!?:`)
+	asciiDocBlockAttrPattern  = lazyregexp.New(`^\[[^\]]*\]$`)
+	asciiDocListItemPattern   = lazyregexp.New(`^\s*(\*{1,5}|-|\.{1,5}|[0-9]+\.)\s+(.*)$`)
+	asciiDocImageBlockPattern = lazyregexp.New(`^image::([^\[\s]+)\[([^\]]*)\]$`)
+	asciiDocAdmonitionPattern = lazyregexp.New(`^(NOTE|TIP|IMPORTANT|WARNING|CAUTION):\s+`)
+
+	asciiDocCodePattern      = lazyregexp.New("`([^`\\x00]+)`")
+	asciiDocURLPattern       = lazyregexp.New(`((?:https?|ftp)://[^\s\[\x00]+|mailto:[^\s\[\x00]+|link:[^\s\[\x00]+)\[([^\]]*)\]`)
+	asciiDocImagePattern     = lazyregexp.New(`image:([^\s\[:\x00][^\s\[\x00]*)\[([^\]]*)\]`)
+	asciiDocCrossRefPattern  = lazyregexp.New(`&lt;&lt;([\w-]+)(?:,\s*([^&]+?))?&gt;&gt;`)
+	asciiDocLineBreakPattern = lazyregexp.New(`(?m) \+$`)
+)
+
+// asciiDocRenderer renders a subset of AsciiDoc syntax that is commonly used in
+// README files: sections, paragraphs, lists, delimited blocks, images and links.
+type asciiDocRenderer struct {
+	urlPrefix string
+	buf       bytes.Buffer
+}
+
+// renderInline renders inline elements of the text.
+func (r *asciiDocRenderer) renderInline(text string) string {
+	var h inlineHTML
+	text = html.EscapeString(text)
+	text = asciiDocCodePattern.ReplaceAllStringFunc(text, func(m string) string {
+		return h.hold("<code>" + m[1:len(m)-1] + "</code>")
+	})
+	text = asciiDocImagePattern.ReplaceAllStringFunc(text, func(m string) string {
+		sm := asciiDocImagePattern.FindStringSubmatch(m)
+		return h.hold(htmlImage(sm[1], sm[2]))
+	})
+	text = asciiDocURLPattern.ReplaceAllStringFunc(text, func(m string) string {
+		sm := asciiDocURLPattern.FindStringSubmatch(m)
+		href, content := sm[1], sm[2]
+		if strings.HasPrefix(href, "link:") {
+			href = resolveLink(r.urlPrefix, strings.TrimPrefix(href, "link:"))
+		} else if strings.HasPrefix(href, "mailto:") && content == "" {
+			content = strings.TrimPrefix(href, "mailto:")
+		}
+		if content == "" {
+			content = href
+		}
+		return h.hold(htmlLink(href, content))
+	})
+	text = asciiDocCrossRefPattern.ReplaceAllStringFunc(text, func(m string) string {
+		sm := asciiDocCrossRefPattern.FindStringSubmatch(m)
+		content := sm[2]
+		if content == "" {
+			content = sm[1]
+		}
+		return h.hold(htmlLink("#"+sm[1], content))
+	})
+	text = h.holdBareURLs(text)
+	text = wrapDelimited(text, "*", "strong")
+	text = wrapDelimited(text, "_", "em")
+	text = asciiDocLineBreakPattern.ReplaceAllString(text, "<br>")
+	return h.restore(text)
+}
+
+// isAsciiDocDelimiter reports whether the line delimits a block, which consists of
+// at least four same characters.
+func isAsciiDocDelimiter(line string) bool {
+	if len(line) < 4 || !strings.ContainsAny(line[:1], "-.=*_/+") {
+		return false
+	}
+	return strings.Count(line, line[:1]) == len(line)
+}
+
+// isAsciiDocBlockStart reports whether the line starts a block other than paragraph.
+func isAsciiDocBlockStart(line string) bool {
+	return asciiDocHeadingPattern.MatchString(line) ||
+		asciiDocListItemPattern.MatchString(line) ||
+		asciiDocImageBlockPattern.MatchString(line) ||
+		asciiDocBlockAttrPattern.MatchString(line) ||
+		isAsciiDocDelimiter(line) ||
+		line == "'''"
+}
+
+// renderList renders consecutive list items starting from the line, and returns the
+// index of the line after the list.
+func (r *asciiDocRenderer) renderList(lines []string, i int) int {
+	type list struct {
+		marker  string
+		ordered bool
+	}
+	var stack []list
+	closeList := func() {
+		if stack[len(stack)-1].ordered {
+			r.buf.WriteString("</li>\n</ol>\n")
+		} else {
+			r.buf.WriteString("</li>\n</ul>\n")
+		}
+		stack = stack[:len(stack)-1]
+	}
+
+	for i < len(lines) {
+		sm := asciiDocListItemPattern.FindStringSubmatch(lines[i])
+		if sm == nil {
+			break
+		}
+		marker := sm[1]
+		ordered := marker[0] == '.' || ('0' <= marker[0] && marker[0] <= '9')
+		if ordered && marker[0] != '.' {
+			marker = "."
+		}
+
+		// Continuation lines are appended to the item text.
+		text := sm[2]
+		for i++; i < len(lines) && !isBlankLine(lines[i]) && !isAsciiDocBlockStart(lines[i]); i++ {
+			text += "\n" + strings.TrimSpace(lines[i])
+		}
+
+		depth := -1
+		for j := range stack {
+			if stack[j].marker == marker {
+				depth = j
+				break
+			}
+		}
+		switch {
+		case depth == -1:
+			if ordered {
+				r.buf.WriteString("<ol>\n")
+			} else {
+				r.buf.WriteString("<ul>\n")
+			}
+			stack = append(stack, list{marker: marker, ordered: ordered})
+		default:
+			for len(stack) > depth+1 {
+				closeList()
+			}
+			r.buf.WriteString("</li>\n")
+		}
+		r.buf.WriteString("<li>" + r.renderInline(text))
+
+		// Blank lines between items of the same list are allowed.
+		j := i
+		for j < len(lines) && isBlankLine(lines[j]) {
+			j++
+		}
+		if j < len(lines) && asciiDocListItemPattern.MatchString(lines[j]) {
+			i = j
+		}
+	}
+	for len(stack) > 0 {
+		closeList()
+	}
+	return i
+}
+
+// render renders the lines as blocks.
+func (r *asciiDocRenderer) render(lines []string) {
+	var lang string // Language of the next listing block
+	for i := 0; i < len(lines); {
+		line := strings.TrimRight(lines[i], " \t")
+		switch {
+		case line == "":
+			i++
+
+		case line == "////":
+			for i++; i < len(lines) && strings.TrimSpace(lines[i]) != "////"; i++ {
+			}
+			i++
+
+		case strings.HasPrefix(line, "//"),
+			asciiDocAttributePattern.MatchString(line):
+			i++
+
+		case asciiDocBlockAttrPattern.MatchString(line):
+			attrs := strings.Split(strings.Trim(line, "[]"), ",")
+			if len(attrs) > 1 && (attrs[0] == "source" || attrs[0] == "") {
+				lang = strings.TrimSpace(attrs[1])
+			}
+			i++
+			continue
+
+		case line == "'''":
+			r.buf.WriteString("<hr>\n")
+			i++
+
+		case asciiDocHeadingPattern.MatchString(line):
+			sm := asciiDocHeadingPattern.FindStringSubmatch(line)
+			level := len(sm[1])
+			r.buf.WriteString(fmt.Sprintf("<h%d>%s</h%d>\n", level, r.renderInline(sm[2]), level))
+			i++
+
+		case asciiDocImageBlockPattern.MatchString(line):
+			sm := asciiDocImageBlockPattern.FindStringSubmatch(line)
+			r.buf.WriteString("<p>" + htmlImage(html.EscapeString(sm[1]), html.EscapeString(sm[2])) + "</p>\n")
+			i++
+
+		case isAsciiDocDelimiter(line):
+			var block []string
+			for i++; i < len(lines) && strings.TrimRight(lines[i], " \t") != line; i++ {
+				block = append(block, lines[i])
+			}
+			i++
+
+			switch line[0] {
+			case '-', '.', '+':
+				r.buf.WriteString(htmlCodeBlock(block, lang))
+			case '_':
+				r.buf.WriteString("<blockquote>\n")
+				r.render(block)
+				r.buf.WriteString("</blockquote>\n")
+			case '=', '*':
+				r.buf.WriteString("<div>\n")
+				r.render(block)
+				r.buf.WriteString("</div>\n")
+			}
+
+		case asciiDocListItemPattern.MatchString(line):
+			i = r.renderList(lines, i)
+
+		case indentation(lines[i]) > 0:
+			// Indented lines form a literal paragraph.
+			var block []string
+			for ; i < len(lines) && !isBlankLine(lines[i]) && indentation(lines[i]) > 0; i++ {
+				block = append(block, lines[i])
+			}
+			r.buf.WriteString(htmlCodeBlock(dedent(block, indentation(block[0])), ""))
+
+		default:
+			text := line
+			for i++; i < len(lines) && !isBlankLine(lines[i]) && !isAsciiDocBlockStart(lines[i]); i++ {
+				text += "\n" + strings.TrimRight(lines[i], " \t")
+			}
+			if m := asciiDocAdmonitionPattern.FindString(text); m != "" {
+				label := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(m), ":"))
+				r.buf.WriteString("<blockquote><p><strong>" + label + ":</strong> " + r.renderInline(text[len(m):]) + "</p></blockquote>\n")
+			} else {
+				r.buf.WriteString("<p>" + r.renderInline(text) + "</p>\n")
+			}
+		}
+		lang = ""
+	}
+}
+
+// RawAsciiDoc renders content in AsciiDoc syntax to HTML without handling special links.
+func RawAsciiDoc(body []byte, urlPrefix string) []byte {
+	r := &asciiDocRenderer{urlPrefix: urlPrefix}
+	r.render(splitLines(body))
+	return r.buf.Bytes()
+}
+
+type asciiDocMarkup struct{}
+
+func (asciiDocMarkup) Type() Type {
+	return ASCIIDOC
+}
+
+func (asciiDocMarkup) IsFile(name string) bool {
+	return IsAsciiDocFile(name)
+}
+
+func (asciiDocMarkup) Render(content []byte, urlPrefix string) []byte {
+	return RawAsciiDoc(content, urlPrefix)
+}
+
+// AsciiDoc takes a string or []byte and renders to HTML in AsciiDoc syntax with special links.
+func AsciiDoc(input interface{}, urlPrefix string, metas map[string]string) []byte {
+	return Render(ASCIIDOC, input, urlPrefix, metas)
+}
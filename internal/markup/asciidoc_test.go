@@ -0,0 +1,56 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package markup_test
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	. "gogs.io/gogs/internal/markup"
+)
+
+func Test_IsAsciiDocFile(t *testing.T) {
+	Convey("Detect AsciiDoc file extension", t, func() {
+		testCases := []struct {
+			ext   string
+			match bool
+		}{
+			{".adoc", true},
+			{".asciidoc", true},
+			{".ADOC", true},
+			{".md", false},
+			{".rst", false},
+		}
+
+		for _, tc := range testCases {
+			So(IsAsciiDocFile(tc.ext), ShouldEqual, tc.match)
+		}
+	})
+}
+
+func Test_RawAsciiDoc(t *testing.T) {
+	Convey("Rendering AsciiDoc", t, func() {
+		testCases := []struct {
+			input  string
+			expect string
+		}{
+			{"= Title\n\n== Section", "<h1>Title</h1>\n<h2>Section</h2>\n"},
+			{"Some *bold*, _italic_ and `code`.", "<p>Some <strong>bold</strong>, <em>italic</em> and <code>code</code>.</p>\n"},
+			{"snake_case_name and 2*3*4", "<p>snake_case_name and 2*3*4</p>\n"},
+			{"https://gogs.io[Gogs] and link:docs/install.adoc[install]", `<p><a href="https://gogs.io">Gogs</a> and <a href="/user/repo/src/master/docs/install.adoc">install</a></p>` + "\n"},
+			{"Visit https://gogs.io.", `<p>Visit <a href="https://gogs.io">https://gogs.io</a>.</p>` + "\n"},
+			{"* one\n** nested\n* two", "<ul>\n<li>one<ul>\n<li>nested</li>\n</ul>\n</li>\n<li>two</li>\n</ul>\n"},
+			{". first\n. second", "<ol>\n<li>first</li>\n<li>second</li>\n</ol>\n"},
+			{"[source,go]\n----\nfmt.Println(\"<hi>\")\n----", `<pre><code class="language-go">fmt.Println(&#34;&lt;hi&gt;&#34;)</code></pre>` + "\n"},
+			{"image::logo.png[Logo]", `<p><img src="logo.png" alt="Logo"></p>` + "\n"},
+			{"// comment\n:toc:\n<script>", "<p>&lt;script&gt;</p>\n"},
+		}
+
+		for _, tc := range testCases {
+			So(string(RawAsciiDoc([]byte(tc.input), "/user/repo/src/master")), ShouldEqual, tc.expect)
+		}
+	})
+}
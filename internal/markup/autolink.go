@@ -0,0 +1,143 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package markup
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/unknwon/com"
+)
+
+// Autolink is a rule to render references matching the pattern as links, e.g.
+// "JIRA-123" to the issue of an external tracker.
+type Autolink struct {
+	pattern *regexp.Regexp
+	// url is the HTML escaped link template, "$0" is expanded to the whole match
+	// and "$1", "$2" and so on to submatches of the pattern.
+	url string
+}
+
+// NewAutolink compiles the pattern and returns a new autolink rule that links
+// matches to the URL template.
+func NewAutolink(pattern, url string) (*Autolink, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &Autolink{
+		pattern: re,
+		url:     html.EscapeString(url),
+	}, nil
+}
+
+var autolinks struct {
+	sync.RWMutex
+	rules map[int64][]*Autolink
+}
+
+// SetAutolinks replaces autolink rules of the repository, rules of the site are
+// set with repoID 0.
+func SetAutolinks(repoID int64, rules []*Autolink) {
+	autolinks.Lock()
+	defer autolinks.Unlock()
+
+	if autolinks.rules == nil {
+		autolinks.rules = make(map[int64][]*Autolink)
+	}
+	if len(rules) == 0 {
+		delete(autolinks.rules, repoID)
+		return
+	}
+	autolinks.rules[repoID] = rules
+}
+
+// HasAutolinks returns true if the repository has its own autolink rules.
+func HasAutolinks(repoID int64) bool {
+	autolinks.RLock()
+	defer autolinks.RUnlock()
+	return len(autolinks.rules[repoID]) > 0
+}
+
+// autolinksOf returns autolink rules of the site followed by rules of the repository.
+func autolinksOf(repoID int64) []*Autolink {
+	autolinks.RLock()
+	defer autolinks.RUnlock()
+
+	rules := autolinks.rules[0]
+	if repoID > 0 && len(autolinks.rules[repoID]) > 0 {
+		rules = append(append(make([]*Autolink, 0, len(rules)+len(autolinks.rules[repoID])), rules...), autolinks.rules[repoID]...)
+	}
+	return rules
+}
+
+// render renders matches in the HTML escaped text to links.
+func (l *Autolink) render(text []byte) []byte {
+	matches := l.pattern.FindAllSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, len(text)))
+	last := 0
+	for _, m := range matches {
+		if m[0] == m[1] {
+			continue
+		}
+		buf.Write(text[last:m[0]])
+		buf.WriteString(`<a href="`)
+		buf.Write(l.pattern.Expand(nil, []byte(l.url), text, m))
+		buf.WriteString(`">`)
+		buf.Write(text[m[0]:m[1]])
+		buf.WriteString(`</a>`)
+		last = m[1]
+	}
+	buf.Write(text[last:])
+	return buf.Bytes()
+}
+
+var (
+	anchorStartTag = []byte("<a ")
+	anchorEndTag   = []byte("</a>")
+)
+
+// RenderAutolinks renders references matching autolink rules of the site and the
+// repository ("repoID" of metas) to corresponding links. Text of links that have
+// been rendered is left as is.
+func RenderAutolinks(rawBytes []byte, metas map[string]string) []byte {
+	rules := autolinksOf(com.StrTo(metas["repoID"]).MustInt64())
+	for _, rule := range rules {
+		buf := bytes.NewBuffer(make([]byte, 0, len(rawBytes)))
+		rest := rawBytes
+		for len(rest) > 0 {
+			start := bytes.Index(rest, anchorStartTag)
+			if start < 0 {
+				start = len(rest)
+			}
+			buf.Write(rule.render(rest[:start]))
+			rest = rest[start:]
+
+			end := bytes.Index(rest, anchorEndTag)
+			if end < 0 {
+				end = len(rest)
+			} else {
+				end += len(anchorEndTag)
+			}
+			buf.Write(rest[:end])
+			rest = rest[end:]
+		}
+		rawBytes = buf.Bytes()
+	}
+	return rawBytes
+}
+
+// IsValidAutolinkURL returns true if the URL template of an autolink rule is an
+// HTTP/HTTPS URL.
+func IsValidAutolinkURL(url string) bool {
+	return strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")
+}
@@ -0,0 +1,59 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package markup_test
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	. "gogs.io/gogs/internal/markup"
+)
+
+func Test_NewAutolink(t *testing.T) {
+	Convey("Compile autolink rules", t, func() {
+		_, err := NewAutolink(`JIRA-([0-9]+)`, "https://jira.example.com/browse/JIRA-$1")
+		So(err, ShouldBeNil)
+
+		_, err = NewAutolink(`JIRA-([0-9]+`, "https://jira.example.com/browse/JIRA-$1")
+		So(err, ShouldNotBeNil)
+
+		So(IsValidAutolinkURL("https://jira.example.com/browse/$0"), ShouldBeTrue)
+		So(IsValidAutolinkURL("javascript:alert($0)"), ShouldBeFalse)
+	})
+}
+
+func Test_RenderAutolinks(t *testing.T) {
+	Convey("Render references matching autolink rules", t, func() {
+		jira, err := NewAutolink(`\bJIRA-([0-9]+)\b`, "https://jira.example.com/browse?id=$1&type=issue")
+		So(err, ShouldBeNil)
+		ticket, err := NewAutolink(`\bTICKET-[0-9]+\b`, "https://tickets.example.com/$0")
+		So(err, ShouldBeNil)
+
+		SetAutolinks(0, []*Autolink{jira})
+		SetAutolinks(7, []*Autolink{ticket})
+		defer SetAutolinks(0, nil)
+		defer SetAutolinks(7, nil)
+
+		So(HasAutolinks(7), ShouldBeTrue)
+		So(HasAutolinks(8), ShouldBeFalse)
+
+		Convey("Rules of the site apply without metas", func() {
+			So(string(RenderAutolinks([]byte("Fixes JIRA-12 and TICKET-3"), nil)), ShouldEqual,
+				`Fixes <a href="https://jira.example.com/browse?id=12&amp;type=issue">JIRA-12</a> and TICKET-3`)
+		})
+
+		Convey("Rules of the repository apply after rules of the site", func() {
+			So(string(RenderAutolinks([]byte("Fixes JIRA-12 and TICKET-3"), map[string]string{"repoID": "7"})), ShouldEqual,
+				`Fixes <a href="https://jira.example.com/browse?id=12&amp;type=issue">JIRA-12</a> and <a href="https://tickets.example.com/TICKET-3">TICKET-3</a>`)
+		})
+
+		Convey("Text of rendered links is left as is", func() {
+			input := `See <a href="https://example.com/JIRA-1">JIRA-1</a> and JIRA-2`
+			So(string(RenderAutolinks([]byte(input), nil)), ShouldEqual,
+				`See <a href="https://example.com/JIRA-1">JIRA-1</a> and <a href="https://jira.example.com/browse?id=2&amp;type=issue">JIRA-2</a>`)
+		})
+	})
+}
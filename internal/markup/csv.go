@@ -0,0 +1,51 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package markup
+
+import (
+	"bytes"
+	"encoding/csv"
+	"path/filepath"
+	"strings"
+)
+
+// csvDelimiters are delimiters of fields of CSV files by their extensions.
+var csvDelimiters = map[string]rune{
+	".csv": ',',
+	".tsv": '\t',
+}
+
+// CSVDelimiter returns the delimiter of fields based on extension of the file name,
+// or zero if name does not look like a CSV file.
+func CSVDelimiter(name string) rune {
+	return csvDelimiters[strings.ToLower(filepath.Ext(name))]
+}
+
+// ParseCSV parses the content with the delimiter of fields. Rows that have fewer
+// fields than others are padded with empty fields so that all rows have the same
+// number of fields.
+func ParseCSV(data []byte, delimiter rune) ([][]string, error) {
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	r.Comma = delimiter
+	r.LazyQuotes = true
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	width := 0
+	for _, row := range rows {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+	for i := range rows {
+		for len(rows[i]) < width {
+			rows[i] = append(rows[i], "")
+		}
+	}
+	return rows, nil
+}
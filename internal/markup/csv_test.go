@@ -0,0 +1,38 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package markup_test
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	. "gogs.io/gogs/internal/markup"
+)
+
+func Test_CSVDelimiter(t *testing.T) {
+	Convey("Detect delimiter of CSV files", t, func() {
+		So(CSVDelimiter("data.csv"), ShouldEqual, ',')
+		So(CSVDelimiter("DATA.CSV"), ShouldEqual, ',')
+		So(CSVDelimiter("data.tsv"), ShouldEqual, '\t')
+		So(CSVDelimiter("data.txt"), ShouldEqual, 0)
+	})
+}
+
+func Test_ParseCSV(t *testing.T) {
+	Convey("Parse CSV content", t, func() {
+		rows, err := ParseCSV([]byte("\xef\xbb\xbfname,age\n\"Doe, John\",42\nJane\n"), ',')
+		So(err, ShouldBeNil)
+		So(rows, ShouldResemble, [][]string{
+			{"name", "age"},
+			{"Doe, John", "42"},
+			{"Jane", ""},
+		})
+
+		rows, err = ParseCSV([]byte("a\tb\n1\t2"), '\t')
+		So(err, ShouldBeNil)
+		So(rows, ShouldResemble, [][]string{{"a", "b"}, {"1", "2"}})
+	})
+}
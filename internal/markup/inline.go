@@ -0,0 +1,194 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package markup
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+
+	"gogs.io/gogs/internal/lazyregexp"
+)
+
+// This file contains helpers shared by built-in renderers of lightweight markup
+// syntaxes that do not have a third-party implementation, i.e. AsciiDoc and
+// reStructuredText.
+
+// inlineHTML holds rendered HTML of inline elements, which are substituted by
+// placeholders in text to be protected from further processing.
+type inlineHTML []string
+
+var placeholderPattern = lazyregexp.New("\x00([0-9]+)\x00")
+
+// hold saves the HTML and returns its placeholder.
+func (h *inlineHTML) hold(s string) string {
+	*h = append(*h, s)
+	return "\x00" + strconv.Itoa(len(*h)-1) + "\x00"
+}
+
+// restore substitutes placeholders in the text with the HTML they hold.
+func (h inlineHTML) restore(s string) string {
+	for strings.Contains(s, "\x00") {
+		s = placeholderPattern.ReplaceAllStringFunc(s, func(m string) string {
+			i, _ := strconv.Atoi(m[1 : len(m)-1])
+			if i < len(h) {
+				return h[i]
+			}
+			return ""
+		})
+	}
+	return s
+}
+
+// isWordByte reports whether the byte is a part of a word, all bytes of multi-byte
+// characters are considered to be.
+func isWordByte(b byte) bool {
+	return b == '_' || b >= 0x80 ||
+		('0' <= b && b <= '9') || ('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z')
+}
+
+func isSpaceByte(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n'
+}
+
+// isOpeningDelimiter reports whether the delimiter at index i of s can open an
+// inline element.
+func isOpeningDelimiter(s string, i int, delim string) bool {
+	if !strings.HasPrefix(s[i:], delim) {
+		return false
+	} else if i > 0 && (isWordByte(s[i-1]) || s[i-1] == delim[0]) {
+		return false
+	}
+	next := i + len(delim)
+	return next < len(s) && !isSpaceByte(s[next]) && s[next] != delim[0]
+}
+
+// isClosingDelimiter reports whether the delimiter at index i of s can close an
+// inline element.
+func isClosingDelimiter(s string, i int, delim string) bool {
+	if !strings.HasPrefix(s[i:], delim) {
+		return false
+	} else if isSpaceByte(s[i-1]) || s[i-1] == delim[0] {
+		return false
+	}
+	next := i + len(delim)
+	return next == len(s) || (!isWordByte(s[next]) && s[next] != delim[0])
+}
+
+// wrapDelimited wraps non-space text enclosed by the delimiter at word boundaries
+// with the HTML tag, e.g. "*foo*" becomes "<strong>foo</strong>".
+func wrapDelimited(s, delim, tag string) string {
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		if !isOpeningDelimiter(s, i, delim) {
+			continue
+		}
+
+		end := -1
+		for j := i + len(delim) + 1; j+len(delim) <= len(s); j++ {
+			if isClosingDelimiter(s, j, delim) {
+				end = j
+				break
+			}
+		}
+		if end == -1 {
+			continue
+		}
+
+		buf.WriteString(s[:i])
+		buf.WriteString("<" + tag + ">")
+		buf.WriteString(s[i+len(delim) : end])
+		buf.WriteString("</" + tag + ">")
+		s = s[end+len(delim):]
+		i = -1
+	}
+	buf.WriteString(s)
+	return buf.String()
+}
+
+var bareURLPattern = lazyregexp.New(`(^|[^\w/\x00])((?:https?|ftp)://[^\s\x00]+)`)
+
+// escapedURLTerminators are escaped characters that cannot be a part of a bare URL.
+var escapedURLTerminators = []string{"&lt;", "&gt;", "&#34;", "&#39;"}
+
+// holdBareURLs substitutes bare URLs in the escaped text with links.
+func (h *inlineHTML) holdBareURLs(s string) string {
+	return bareURLPattern.ReplaceAllStringFunc(s, func(m string) string {
+		sm := bareURLPattern.FindStringSubmatch(m)
+		prefix, link := sm[1], sm[2]
+
+		var suffix string
+		for _, t := range escapedURLTerminators {
+			if i := strings.Index(link, t); i > -1 {
+				link, suffix = link[:i], link[i:]+suffix
+			}
+		}
+		trimmed := strings.TrimRight(link, ".,;:!?)")
+		link, suffix = trimmed, link[len(trimmed):]+suffix
+		return prefix + h.hold(htmlLink(link, link)) + suffix
+	})
+}
+
+// htmlLink returns HTML of a link, both href and text must be escaped.
+func htmlLink(href, text string) string {
+	return fmt.Sprintf(`<a href="%s">%s</a>`, href, text)
+}
+
+// htmlImage returns HTML of an image, both src and alt must be escaped.
+func htmlImage(src, alt string) string {
+	return fmt.Sprintf(`<img src="%s" alt="%s">`, src, alt)
+}
+
+// htmlCodeBlock returns HTML of a preformatted code block.
+func htmlCodeBlock(lines []string, lang string) string {
+	class := ""
+	if lang != "" {
+		class = ` class="language-` + html.EscapeString(lang) + `"`
+	}
+	return "<pre><code" + class + ">" + html.EscapeString(strings.Join(lines, "\n")) + "</code></pre>\n"
+}
+
+// splitLines splits content into lines without line terminators.
+func splitLines(content []byte) []string {
+	s := strings.Replace(string(content), "\r\n", "\n", -1)
+	s = strings.Replace(s, "\x00", "", -1)
+	return strings.Split(s, "\n")
+}
+
+// indentation returns the number of leading spaces of the line, tabs are expanded
+// to the next multiple of eight.
+func indentation(line string) int {
+	n := 0
+	for _, c := range line {
+		switch c {
+		case ' ':
+			n++
+		case '\t':
+			n += 8 - n%8
+		default:
+			return n
+		}
+	}
+	return n
+}
+
+// dedent removes n columns of leading whitespace from each line.
+func dedent(lines []string, n int) []string {
+	results := make([]string, len(lines))
+	for i, line := range lines {
+		line = strings.Replace(line, "\t", "        ", -1)
+		if indentation(line) >= n {
+			results[i] = line[n:]
+		} else {
+			results[i] = strings.TrimLeft(line, " ")
+		}
+	}
+	return results
+}
+
+func isBlankLine(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
@@ -8,7 +8,6 @@ import (
 	"bytes"
 	"fmt"
 	"path"
-	"path/filepath"
 	"strings"
 
 	"github.com/russross/blackfriday"
@@ -20,13 +19,7 @@ import (
 
 // IsMarkdownFile reports whether name looks like a Markdown file based on its extension.
 func IsMarkdownFile(name string) bool {
-	extension := strings.ToLower(filepath.Ext(name))
-	for _, ext := range conf.Markdown.FileExtensions {
-		if strings.ToLower(ext) == extension {
-			return true
-		}
-	}
-	return false
+	return hasExtension(name, conf.Markdown.FileExtensions)
 }
 
 // MarkdownRenderer is a extended version of underlying Markdown render object.
@@ -157,7 +150,29 @@ func RawMarkdown(body []byte, urlPrefix string) []byte {
 		extensions |= blackfriday.EXTENSION_HARD_LINE_BREAK
 	}
 
-	return blackfriday.Markdown(body, renderer, extensions)
+	var maths []string
+	if conf.Markdown.EnableMath {
+		body, maths = extractMath(body)
+	}
+	rawHTML := blackfriday.Markdown(body, renderer, extensions)
+	if len(maths) > 0 {
+		rawHTML = restoreMath(rawHTML, maths)
+	}
+	return rawHTML
+}
+
+type markdownMarkup struct{}
+
+func (markdownMarkup) Type() Type {
+	return MARKDOWN
+}
+
+func (markdownMarkup) IsFile(name string) bool {
+	return IsMarkdownFile(name)
+}
+
+func (markdownMarkup) Render(content []byte, urlPrefix string) []byte {
+	return RawMarkdown(content, urlPrefix)
 }
 
 // Markdown takes a string or []byte and renders to HTML in Markdown syntax with special links.
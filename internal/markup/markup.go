@@ -101,7 +101,7 @@ func RenderIssueIndexPattern(rawBytes []byte, urlPrefix string, metas map[string
 			m = m[1:]
 		}
 		var link string
-		if metas == nil {
+		if metas["format"] == "" {
 			link = fmt.Sprintf(`<a href="%s/issues/%s">%s</a>`, urlPrefix, m[1:], m)
 		} else {
 			// Support for external issue tracker
@@ -149,7 +149,8 @@ func RenderSha1CurrentPattern(rawBytes []byte, urlPrefix string) []byte {
 	}))
 }
 
-// RenderSpecialLink renders mentions, indexes and SHA1 strings to corresponding links.
+// RenderSpecialLink renders mentions, indexes, SHA1 strings and references matching
+// autolink rules to corresponding links.
 func RenderSpecialLink(rawBytes []byte, urlPrefix string, metas map[string]string) []byte {
 	ms := MentionPattern.FindAll(rawBytes, -1)
 	for _, m := range ms {
@@ -161,6 +162,7 @@ func RenderSpecialLink(rawBytes []byte, urlPrefix string, metas map[string]strin
 	rawBytes = RenderIssueIndexPattern(rawBytes, urlPrefix, metas)
 	rawBytes = RenderCrossReferenceIssueIndexPattern(rawBytes, urlPrefix, metas)
 	rawBytes = RenderSha1CurrentPattern(rawBytes, urlPrefix)
+	rawBytes = RenderAutolinks(rawBytes, metas)
 	return rawBytes
 }
 
@@ -314,24 +316,29 @@ OUTER_LOOP:
 type Type string
 
 const (
-	UNRECOGNIZED     Type = "unrecognized"
-	MARKDOWN         Type = "markdown"
-	ORG_MODE         Type = "orgmode"
-	IPYTHON_NOTEBOOK Type = "ipynb"
+	UNRECOGNIZED      Type = "unrecognized"
+	MARKDOWN          Type = "markdown"
+	ORG_MODE          Type = "orgmode"
+	ASCIIDOC          Type = "asciidoc"
+	RESTRUCTURED_TEXT Type = "restructuredtext"
+	IPYTHON_NOTEBOOK  Type = "ipynb"
 )
 
+func init() {
+	RegisterRenderer(markdownMarkup{})
+	RegisterRenderer(orgModeMarkup{})
+	RegisterRenderer(asciiDocMarkup{})
+	RegisterRenderer(reStructuredTextMarkup{})
+}
+
 // Detect returns best guess of a markup type based on file name.
 func Detect(filename string) Type {
-	switch {
-	case IsMarkdownFile(filename):
-		return MARKDOWN
-	case IsOrgModeFile(filename):
-		return ORG_MODE
-	case IsIPythonNotebook(filename):
+	if r := rendererByFile(filename); r != nil {
+		return r.Type()
+	} else if IsIPythonNotebook(filename) {
 		return IPYTHON_NOTEBOOK
-	default:
-		return UNRECOGNIZED
 	}
+	return UNRECOGNIZED
 }
 
 // Render takes a string or []byte and renders to sanitized HTML in given type of syntax with special links.
@@ -346,17 +353,13 @@ func Render(typ Type, input interface{}, urlPrefix string, metas map[string]stri
 		panic(fmt.Sprintf("unrecognized input content type: %T", input))
 	}
 
-	urlPrefix = strings.TrimRight(strings.Replace(urlPrefix, " ", "%20", -1), "/")
-	var rawHTML []byte
-	switch typ {
-	case MARKDOWN:
-		rawHTML = RawMarkdown(rawBytes, urlPrefix)
-	case ORG_MODE:
-		rawHTML = RawOrgMode(rawBytes, urlPrefix)
-	default:
+	r := rendererByType(typ)
+	if r == nil {
 		return rawBytes // Do nothing if syntax type is not recognized
 	}
 
+	urlPrefix = strings.TrimRight(strings.Replace(urlPrefix, " ", "%20", -1), "/")
+	rawHTML := r.Render(rawBytes, urlPrefix)
 	rawHTML = postProcessHTML(rawHTML, urlPrefix, metas)
 	return SanitizeBytes(rawHTML)
 }
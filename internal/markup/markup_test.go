@@ -38,6 +38,28 @@ func Test_IsReadmeFile(t *testing.T) {
 	})
 }
 
+func Test_Detect(t *testing.T) {
+	conf.Markdown.FileExtensions = strings.Split(".md,.markdown", ",")
+	Convey("Detect markup type of files", t, func() {
+		testCases := []struct {
+			name   string
+			expect Type
+		}{
+			{"README.md", MARKDOWN},
+			{"README.org", ORG_MODE},
+			{"README.adoc", ASCIIDOC},
+			{"README.rst", RESTRUCTURED_TEXT},
+			{"notebook.ipynb", IPYTHON_NOTEBOOK},
+			{"README", UNRECOGNIZED},
+			{"main.go", UNRECOGNIZED},
+		}
+
+		for _, tc := range testCases {
+			So(Detect(tc.name), ShouldEqual, tc.expect)
+		}
+	})
+}
+
 func Test_FindAllMentions(t *testing.T) {
 	Convey("Find all mention patterns", t, func() {
 		testCases := []struct {
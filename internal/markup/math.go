@@ -0,0 +1,200 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package markup
+
+import (
+	"html"
+	"strconv"
+	"strings"
+
+	"gogs.io/gogs/internal/lazyregexp"
+)
+
+// Math in Markdown is rendered to code of language "math" that is typeset by KaTeX
+// on the client side, where display math is in a code block and inline math is in
+// a code span. The content is escaped like other code so that it is safe in any case.
+
+// Inline math is substituted by placeholders that consist of control characters
+// and the index, which are kept as-is by the Markdown renderer.
+const (
+	mathPlaceholderStart = '\x02'
+	mathPlaceholderEnd   = '\x03'
+)
+
+var mathPlaceholderPattern = lazyregexp.New("\x02([0-9]+)\x03")
+
+// fenceMarker returns the marker if the line opens a fenced code block.
+func fenceMarker(line string) string {
+	if !strings.HasPrefix(line, "```") && !strings.HasPrefix(line, "~~~") {
+		return ""
+	}
+	n := len(line) - len(strings.TrimLeft(line, line[:1]))
+	return line[:n]
+}
+
+// closingMathDelimiter returns the index of the delimiter that closes inline math
+// opened at index i of the line, or -1 if the math is not closed.
+func closingMathDelimiter(line string, i int) int {
+	if line[i+1] == '$' {
+		end := strings.Index(line[i+2:], "$$")
+		if end <= 0 {
+			return -1
+		}
+		return i + 2 + end
+	}
+
+	// The opening "$" must be followed by a non-space character, and the closing
+	// "$" must be preceded by a non-space character and not followed by a digit,
+	// so that prices like "$5 and $10" are not math. Math cannot span code spans.
+	if line[i+1] == ' ' || line[i+1] == '\t' {
+		return -1
+	}
+	for j := i + 2; j < len(line); j++ {
+		if line[j] == '`' {
+			return -1
+		} else if line[j] != '$' || line[j-1] == '\\' {
+			continue
+		} else if line[j-1] == ' ' || line[j-1] == '\t' {
+			continue
+		} else if j+1 < len(line) && '0' <= line[j+1] && line[j+1] <= '9' {
+			continue
+		}
+		return j
+	}
+	return -1
+}
+
+// extractInlineMath substitutes inline math of the line with placeholders, and
+// appends the math to maths. Math in code spans is untouched.
+func extractInlineMath(line string, maths *[]string) string {
+	var buf strings.Builder
+	for i := 0; i < len(line); {
+		switch line[i] {
+		case '\\':
+			end := i + 2
+			if end > len(line) {
+				end = len(line)
+			}
+			buf.WriteString(line[i:end])
+			i = end
+
+		case '`':
+			n := len(line[i:]) - len(strings.TrimLeft(line[i:], "`"))
+			end := strings.Index(line[i+n:], line[i:i+n])
+			if end == -1 {
+				end = 0
+			} else {
+				end += n
+			}
+			buf.WriteString(line[i : i+n+end])
+			i += n + end
+
+		case '$':
+			end := -1
+			if i+1 < len(line) {
+				end = closingMathDelimiter(line, i)
+			}
+			if end == -1 {
+				buf.WriteByte('$')
+				i++
+				break
+			}
+
+			delim := 1
+			if line[i+1] == '$' {
+				delim = 2
+			}
+			*maths = append(*maths, line[i+delim:end])
+			buf.WriteByte(mathPlaceholderStart)
+			buf.WriteString(strconv.Itoa(len(*maths) - 1))
+			buf.WriteByte(mathPlaceholderEnd)
+			i = end + delim
+
+		default:
+			buf.WriteByte(line[i])
+			i++
+		}
+	}
+	return buf.String()
+}
+
+// extractMath converts display math between lines of "$$" to fenced code blocks of
+// language "math", and substitutes inline math between "$" or "$$" with placeholders.
+// It returns the converted content and the inline math. Math in code is untouched.
+func extractMath(body []byte) ([]byte, []string) {
+	s := strings.NewReplacer(string(mathPlaceholderStart), "", string(mathPlaceholderEnd), "").Replace(string(body))
+	lines := strings.Split(s, "\n")
+
+	var (
+		results    = make([]string, 0, len(lines))
+		maths      []string
+		fence      string   // The marker of the fenced code block we are in
+		display    []string // Lines of the display math we are in
+		inDisplay  bool
+		prevBlank  = true
+		inIndented bool
+	)
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(strings.TrimRight(line, "\r"))
+		switch {
+		case fence != "":
+			results = append(results, line)
+			if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+				fence = ""
+			}
+
+		case inDisplay:
+			if trimmed == "$$" {
+				results = append(results, "```math")
+				results = append(results, display...)
+				results = append(results, "```")
+				display = nil
+				inDisplay = false
+			} else {
+				display = append(display, line)
+			}
+
+		case fenceMarker(trimmed) != "":
+			fence = fenceMarker(trimmed)
+			results = append(results, line)
+
+		case trimmed == "$$":
+			inDisplay = true
+
+		case len(trimmed) > 4 && strings.HasPrefix(trimmed, "$$") && strings.HasSuffix(trimmed, "$$") &&
+			!strings.Contains(trimmed[2:len(trimmed)-2], "$$"):
+			results = append(results, "```math", trimmed[2:len(trimmed)-2], "```")
+
+		// Indented code block
+		case (prevBlank || inIndented) && (strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t")):
+			inIndented = true
+			results = append(results, line)
+
+		default:
+			inIndented = inIndented && trimmed == ""
+			results = append(results, extractInlineMath(line, &maths))
+		}
+		prevBlank = trimmed == ""
+	}
+
+	// Display math is not closed, restore as it was.
+	if inDisplay {
+		results = append(results, "$$")
+		results = append(results, display...)
+	}
+	return []byte(strings.Join(results, "\n")), maths
+}
+
+// restoreMath substitutes placeholders of inline math in rendered HTML with code
+// spans of language "math".
+func restoreMath(rawHTML []byte, maths []string) []byte {
+	return mathPlaceholderPattern.Regexp().ReplaceAllFunc(rawHTML, func(m []byte) []byte {
+		i, _ := strconv.Atoi(string(m[1 : len(m)-1]))
+		if i >= len(maths) {
+			return nil
+		}
+		return []byte(`<code class="language-math">` + html.EscapeString(maths[i]) + `</code>`)
+	})
+}
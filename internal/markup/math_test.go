@@ -0,0 +1,40 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package markup
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_extractMath(t *testing.T) {
+	Convey("Extract math from Markdown", t, func() {
+		testCases := []struct {
+			input  string
+			expect string
+			maths  []string
+		}{
+			{"Euler $e^{i\\pi}+1=0$ and $$x$$.", "Euler \x020\x03 and \x021\x03.", []string{"e^{i\\pi}+1=0", "x"}},
+			{"It costs $5 and $10.", "It costs $5 and $10.", nil},
+			{"Escaped \\$x$ and `$y$` in code.", "Escaped \\$x$ and `$y$` in code.", nil},
+			{"$$\n\\sum_{i=1}^n i\n$$", "```math\n\\sum_{i=1}^n i\n```", nil},
+			{"$$x<y$$", "```math\nx<y\n```", nil},
+			{"```\n$x$\n```\n\n    $y$", "```\n$x$\n```\n\n    $y$", nil},
+			{"$$\nnot closed", "$$\nnot closed", nil},
+		}
+
+		for _, tc := range testCases {
+			body, maths := extractMath([]byte(tc.input))
+			So(string(body), ShouldEqual, tc.expect)
+			So(maths, ShouldResemble, tc.maths)
+		}
+	})
+
+	Convey("Restore inline math to code spans", t, func() {
+		So(string(restoreMath([]byte("<p>\x020\x03 and \x021\x03</p>"), []string{"a<b", "c"})), ShouldEqual,
+			`<p><code class="language-math">a&lt;b</code> and <code class="language-math">c</code></p>`)
+	})
+}
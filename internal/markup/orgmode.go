@@ -6,8 +6,6 @@ package markup
 
 import (
 	"bytes"
-	"path/filepath"
-	"strings"
 
 	"github.com/niklasfasching/go-org/org"
 )
@@ -16,13 +14,7 @@ var orgModeExtensions = []string{".org"}
 
 // IsOrgModeFile reports whether name looks like a Org-mode file based on its extension.
 func IsOrgModeFile(name string) bool {
-	extension := strings.ToLower(filepath.Ext(name))
-	for _, ext := range orgModeExtensions {
-		if strings.ToLower(ext) == extension {
-			return true
-		}
-	}
-	return false
+	return hasExtension(name, orgModeExtensions)
 }
 
 // RawOrgMode renders content in Org-mode syntax to HTML without handling special links.
@@ -34,6 +26,20 @@ func RawOrgMode(body []byte, urlPrefix string) (result []byte) {
 	return []byte(html)
 }
 
+type orgModeMarkup struct{}
+
+func (orgModeMarkup) Type() Type {
+	return ORG_MODE
+}
+
+func (orgModeMarkup) IsFile(name string) bool {
+	return IsOrgModeFile(name)
+}
+
+func (orgModeMarkup) Render(content []byte, urlPrefix string) []byte {
+	return RawOrgMode(content, urlPrefix)
+}
+
 // OrgMode takes a string or []byte and renders to HTML in Org-mode syntax with special links.
 func OrgMode(input interface{}, urlPrefix string, metas map[string]string) []byte {
 	return Render(ORG_MODE, input, urlPrefix, metas)
@@ -0,0 +1,95 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package markup
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Renderer renders content in a markup syntax to HTML.
+type Renderer interface {
+	// Type returns the markup type that the renderer handles.
+	Type() Type
+	// IsFile reports whether name looks like a file in the markup syntax.
+	IsFile(name string) bool
+	// Render renders content to HTML without handling special links, the result
+	// is sanitized by the caller.
+	Render(content []byte, urlPrefix string) []byte
+}
+
+var renderers struct {
+	sync.RWMutex
+	list []Renderer
+}
+
+// RegisterRenderer registers the renderer for its markup type, it replaces the one
+// registered before for the same type. Renderers registered earlier take precedence
+// when detecting markup type of a file.
+func RegisterRenderer(r Renderer) {
+	renderers.Lock()
+	defer renderers.Unlock()
+
+	for i := range renderers.list {
+		if renderers.list[i].Type() == r.Type() {
+			renderers.list[i] = r
+			return
+		}
+	}
+	renderers.list = append(renderers.list, r)
+}
+
+// rendererByType returns the renderer registered for the markup type, or nil if not
+// registered.
+func rendererByType(typ Type) Renderer {
+	renderers.RLock()
+	defer renderers.RUnlock()
+
+	for _, r := range renderers.list {
+		if r.Type() == typ {
+			return r
+		}
+	}
+	return nil
+}
+
+// rendererByFile returns the renderer of the file, or nil if no renderer handles it.
+func rendererByFile(name string) Renderer {
+	renderers.RLock()
+	defer renderers.RUnlock()
+
+	for _, r := range renderers.list {
+		if r.IsFile(name) {
+			return r
+		}
+	}
+	return nil
+}
+
+// IsRenderableFile reports whether name looks like a file that can be rendered to HTML.
+func IsRenderableFile(name string) bool {
+	return rendererByFile(name) != nil
+}
+
+// hasExtension reports whether name has one of the extensions, case-insensitively.
+func hasExtension(name string, extensions []string) bool {
+	extension := strings.ToLower(filepath.Ext(name))
+	for _, ext := range extensions {
+		if strings.ToLower(ext) == extension {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveLink returns the link prefixed with urlPrefix if it is relative.
+func resolveLink(urlPrefix, link string) string {
+	if len(link) == 0 || link[0] == '#' || isLink([]byte(link)) {
+		return link
+	}
+	return path.Join(urlPrefix, link)
+}
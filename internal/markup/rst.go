@@ -0,0 +1,360 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package markup
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strings"
+	"unicode/utf8"
+
+	"gogs.io/gogs/internal/lazyregexp"
+)
+
+var reStructuredTextExtensions = []string{".rst", ".rest"}
+
+// IsReStructuredTextFile reports whether name looks like a reStructuredText file based on its extension.
+func IsReStructuredTextFile(name string) bool {
+	return hasExtension(name, reStructuredTextExtensions)
+}
+
+var (
+	rstBulletPattern    = lazyregexp.New(`^([*+-])(\s+|$)`)
+	rstEnumPattern      = lazyregexp.New(`^(?:[0-9]+|#)[.)](\s+|$)`)
+	rstDirectivePattern = lazyregexp.New(`^\.\.\s+([\w-]+)::\s*(.*)$`)
+	rstTargetPattern    = lazyregexp.New(`^\.\.\s+_([^:]+):\s*(\S*)$`)
+
+	rstLiteralPattern   = lazyregexp.New("``([^`\\x00]+)``")
+	rstLinkPattern      = lazyregexp.New("`([^`\\x00]*?)\\s*&lt;([^`\\s\\x00]+)&gt;`__?")
+	rstReferencePattern = lazyregexp.New("(?:`([^`\\x00]+)`|\\b([\\w-]+))__?")
+	rstRolePattern      = lazyregexp.New(":([\\w-]+):`([^`\\x00]+)`")
+	rstInterpretPattern = lazyregexp.New("`([^`\\x00]+)`")
+)
+
+// rstAdornmentChars are characters that can be used to adorn section titles.
+const rstAdornmentChars = "=-`:.'\"~^_*+#"
+
+// isRstAdornment reports whether the line consists of a repeated adornment character.
+func isRstAdornment(line string) bool {
+	if len(line) < 2 || !strings.ContainsRune(rstAdornmentChars, rune(line[0])) {
+		return false
+	}
+	return strings.Count(line, line[:1]) == len(line)
+}
+
+// rstRenderer renders a subset of reStructuredText syntax that is commonly used in
+// README files: sections, paragraphs, lists, literal blocks, images and hyperlinks.
+type rstRenderer struct {
+	urlPrefix string
+	// Section title styles in order of their first appearance, the index is the level.
+	styles []string
+	// Named hyperlink targets, keys are in lower case.
+	targets map[string]string
+	buf     bytes.Buffer
+}
+
+// collectTargets collects named hyperlink targets defined in the lines.
+func (r *rstRenderer) collectTargets(lines []string) {
+	for _, line := range lines {
+		sm := rstTargetPattern.FindStringSubmatch(strings.TrimSpace(line))
+		if sm != nil && sm[2] != "" {
+			r.targets[strings.ToLower(strings.Trim(sm[1], "`"))] = sm[2]
+		}
+	}
+}
+
+// renderInline renders inline elements of the text.
+func (r *rstRenderer) renderInline(text string) string {
+	var h inlineHTML
+	text = html.EscapeString(text)
+	text = rstLiteralPattern.ReplaceAllStringFunc(text, func(m string) string {
+		return h.hold("<code>" + m[2:len(m)-2] + "</code>")
+	})
+	text = rstRolePattern.ReplaceAllStringFunc(text, func(m string) string {
+		sm := rstRolePattern.FindStringSubmatch(m)
+		switch sm[1] {
+		case "code", "literal", "command", "file":
+			return h.hold("<code>" + sm[2] + "</code>")
+		case "strong":
+			return h.hold("<strong>" + sm[2] + "</strong>")
+		case "sub", "subscript":
+			return h.hold("<sub>" + sm[2] + "</sub>")
+		case "sup", "superscript":
+			return h.hold("<sup>" + sm[2] + "</sup>")
+		}
+		return h.hold("<em>" + sm[2] + "</em>")
+	})
+	text = rstLinkPattern.ReplaceAllStringFunc(text, func(m string) string {
+		sm := rstLinkPattern.FindStringSubmatch(m)
+		href := resolveLink(r.urlPrefix, sm[2])
+		content := sm[1]
+		if content == "" {
+			content = sm[2]
+		}
+		return h.hold(htmlLink(href, content))
+	})
+	text = rstReferencePattern.ReplaceAllStringFunc(text, func(m string) string {
+		sm := rstReferencePattern.FindStringSubmatch(m)
+		name := sm[1] + sm[2]
+		href, ok := r.targets[strings.ToLower(html.UnescapeString(name))]
+		if !ok {
+			return m
+		}
+		return h.hold(htmlLink(html.EscapeString(resolveLink(r.urlPrefix, href)), name))
+	})
+	text = rstInterpretPattern.ReplaceAllStringFunc(text, func(m string) string {
+		return h.hold("<em>" + m[1:len(m)-1] + "</em>")
+	})
+	text = h.holdBareURLs(text)
+	text = wrapDelimited(text, "**", "strong")
+	text = wrapDelimited(text, "*", "em")
+	return h.restore(text)
+}
+
+// sectionLevel returns the level of the section title style, new styles are one
+// level deeper than all styles seen before.
+func (r *rstRenderer) sectionLevel(style string) int {
+	for i := range r.styles {
+		if r.styles[i] == style {
+			return i + 1
+		}
+	}
+	r.styles = append(r.styles, style)
+	return len(r.styles)
+}
+
+// indentedBlock returns the block of lines starting from i that are indented by at
+// least minIndent columns or blank, and the index of the line after the block.
+// Trailing blank lines are not included.
+func indentedBlock(lines []string, i, minIndent int) ([]string, int) {
+	start := i
+	end := i
+	for ; i < len(lines); i++ {
+		if isBlankLine(lines[i]) {
+			continue
+		} else if indentation(lines[i]) < minIndent {
+			break
+		}
+		end = i + 1
+	}
+	return lines[start:end], end
+}
+
+// blockIndentation returns the minimum indentation of non-blank lines.
+func blockIndentation(lines []string) int {
+	n := -1
+	for _, line := range lines {
+		if isBlankLine(line) {
+			continue
+		} else if ind := indentation(line); n == -1 || ind < n {
+			n = ind
+		}
+	}
+	if n == -1 {
+		return 0
+	}
+	return n
+}
+
+// renderListItems renders consecutive list items matching the pattern starting from
+// the line, and returns the index of the line after the list.
+func (r *rstRenderer) renderListItems(lines []string, i int, pattern *lazyregexp.Regexp, tag string) int {
+	r.buf.WriteString("<" + tag + ">\n")
+	for i < len(lines) {
+		marker := pattern.FindString(lines[i])
+		if marker == "" {
+			break
+		}
+
+		contentIndent := len(marker)
+		if !strings.HasSuffix(marker, " ") && !strings.HasSuffix(marker, "\t") {
+			contentIndent++ // The item starts with an empty line
+		}
+		body, next := indentedBlock(lines, i+1, contentIndent)
+		item := append([]string{lines[i][len(marker):]}, dedent(body, contentIndent)...)
+
+		r.buf.WriteString("<li>")
+		sub := &rstRenderer{urlPrefix: r.urlPrefix, styles: r.styles, targets: r.targets}
+		sub.render(item)
+		content := sub.buf.String()
+		// Unwrap the paragraph of a simple item.
+		if strings.Count(content, "<p>") == 1 && strings.HasPrefix(content, "<p>") && strings.HasSuffix(content, "</p>\n") {
+			content = strings.TrimSuffix(strings.TrimPrefix(content, "<p>"), "</p>\n")
+		}
+		r.buf.WriteString(content)
+		r.buf.WriteString("</li>\n")
+
+		i = next
+		for i < len(lines) && isBlankLine(lines[i]) {
+			i++
+		}
+	}
+	r.buf.WriteString("</" + tag + ">\n")
+	return i
+}
+
+// renderDirective renders the directive and its indented body starting from the
+// line, and returns the index of the line after the directive.
+func (r *rstRenderer) renderDirective(lines []string, i int) int {
+	sm := rstDirectivePattern.FindStringSubmatch(strings.TrimRight(lines[i], " \t"))
+	name, arg := sm[1], sm[2]
+	body, next := indentedBlock(lines, i+1, 1)
+	body = dedent(body, blockIndentation(body))
+
+	// Skip directive options, e.g. ":alt: text".
+	options := make(map[string]string)
+	for len(body) > 0 && strings.HasPrefix(body[0], ":") {
+		if parts := strings.SplitN(body[0][1:], ":", 2); len(parts) == 2 {
+			options[parts[0]] = strings.TrimSpace(parts[1])
+		}
+		body = body[1:]
+	}
+	for len(body) > 0 && isBlankLine(body[0]) {
+		body = body[1:]
+	}
+
+	switch name {
+	case "code", "code-block", "sourcecode":
+		r.buf.WriteString(htmlCodeBlock(body, arg))
+	case "image", "figure":
+		img := htmlImage(html.EscapeString(arg), html.EscapeString(options["alt"]))
+		if target := options["target"]; target != "" {
+			img = htmlLink(html.EscapeString(resolveLink(r.urlPrefix, target)), img)
+		}
+		r.buf.WriteString("<p>" + img + "</p>\n")
+		if name == "figure" && len(body) > 0 {
+			r.render(body)
+		}
+	case "note", "tip", "hint", "important", "attention", "caution", "warning", "danger", "error", "admonition":
+		title := strings.Title(name)
+		if name == "admonition" {
+			title = arg
+		} else if arg != "" {
+			body = append([]string{arg}, body...)
+		}
+		r.buf.WriteString("<blockquote>\n<p><strong>" + html.EscapeString(title) + "</strong></p>\n")
+		r.render(body)
+		r.buf.WriteString("</blockquote>\n")
+	}
+	// Other directives are not supported and skipped with their content.
+	return next
+}
+
+// render renders the lines as blocks.
+func (r *rstRenderer) render(lines []string) {
+	for i := 0; i < len(lines); {
+		line := strings.TrimRight(lines[i], " \t")
+		var nextLine string
+		if i+1 < len(lines) {
+			nextLine = strings.TrimRight(lines[i+1], " \t")
+		}
+
+		switch {
+		case line == "":
+			i++
+
+		// Section title with overline
+		case isRstAdornment(line) && i+2 < len(lines) &&
+			strings.TrimRight(lines[i+2], " \t") == line && !isBlankLine(nextLine):
+			level := r.sectionLevel(line[:1] + line[:1])
+			r.buf.WriteString(fmt.Sprintf("<h%d>%s</h%d>\n", level, r.renderInline(strings.TrimSpace(nextLine)), level))
+			i += 3
+
+		// Transition
+		case isRstAdornment(line) && len(line) >= 4 && (nextLine == "" || i+1 == len(lines)):
+			r.buf.WriteString("<hr>\n")
+			i++
+
+		// Section title with underline
+		case indentation(line) == 0 && isRstAdornment(nextLine) &&
+			utf8.RuneCountInString(nextLine) >= utf8.RuneCountInString(line):
+			level := r.sectionLevel(nextLine[:1])
+			r.buf.WriteString(fmt.Sprintf("<h%d>%s</h%d>\n", level, r.renderInline(line), level))
+			i += 2
+
+		case rstDirectivePattern.MatchString(line):
+			i = r.renderDirective(lines, i)
+
+		// Comments and hyperlink targets
+		case line == ".." || strings.HasPrefix(line, ".. "):
+			_, i = indentedBlock(lines, i+1, 1)
+
+		case rstBulletPattern.MatchString(line):
+			i = r.renderListItems(lines, i, rstBulletPattern, "ul")
+
+		case rstEnumPattern.MatchString(line):
+			i = r.renderListItems(lines, i, rstEnumPattern, "ol")
+
+		// Block quote
+		case indentation(lines[i]) > 0:
+			block, next := indentedBlock(lines, i, 1)
+			r.buf.WriteString("<blockquote>\n")
+			r.render(dedent(block, blockIndentation(block)))
+			r.buf.WriteString("</blockquote>\n")
+			i = next
+
+		// Paragraph
+		default:
+			text := line
+			for i++; i < len(lines) && !isBlankLine(lines[i]) && indentation(lines[i]) == 0; i++ {
+				text += "\n" + strings.TrimRight(lines[i], " \t")
+			}
+
+			if !strings.HasSuffix(text, "::") {
+				r.buf.WriteString("<p>" + r.renderInline(text) + "</p>\n")
+				continue
+			}
+
+			// A paragraph ends with "::" is followed by a literal block.
+			switch {
+			case text == "::":
+			case strings.HasSuffix(text, " ::"):
+				r.buf.WriteString("<p>" + r.renderInline(strings.TrimSuffix(text, " ::")) + "</p>\n")
+			default:
+				r.buf.WriteString("<p>" + r.renderInline(strings.TrimSuffix(text, ":")) + "</p>\n")
+			}
+			for i < len(lines) && isBlankLine(lines[i]) {
+				i++
+			}
+			if i < len(lines) && indentation(lines[i]) > 0 {
+				var block []string
+				block, i = indentedBlock(lines, i, 1)
+				r.buf.WriteString(htmlCodeBlock(dedent(block, blockIndentation(block)), ""))
+			}
+		}
+	}
+}
+
+// RawReStructuredText renders content in reStructuredText syntax to HTML without handling special links.
+func RawReStructuredText(body []byte, urlPrefix string) []byte {
+	lines := splitLines(body)
+	r := &rstRenderer{
+		urlPrefix: urlPrefix,
+		targets:   make(map[string]string),
+	}
+	r.collectTargets(lines)
+	r.render(lines)
+	return r.buf.Bytes()
+}
+
+type reStructuredTextMarkup struct{}
+
+func (reStructuredTextMarkup) Type() Type {
+	return RESTRUCTURED_TEXT
+}
+
+func (reStructuredTextMarkup) IsFile(name string) bool {
+	return IsReStructuredTextFile(name)
+}
+
+func (reStructuredTextMarkup) Render(content []byte, urlPrefix string) []byte {
+	return RawReStructuredText(content, urlPrefix)
+}
+
+// ReStructuredText takes a string or []byte and renders to HTML in reStructuredText syntax with special links.
+func ReStructuredText(input interface{}, urlPrefix string, metas map[string]string) []byte {
+	return Render(RESTRUCTURED_TEXT, input, urlPrefix, metas)
+}
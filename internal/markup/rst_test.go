@@ -0,0 +1,56 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package markup_test
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	. "gogs.io/gogs/internal/markup"
+)
+
+func Test_IsReStructuredTextFile(t *testing.T) {
+	Convey("Detect reStructuredText file extension", t, func() {
+		testCases := []struct {
+			ext   string
+			match bool
+		}{
+			{".rst", true},
+			{".rest", true},
+			{".RST", true},
+			{".md", false},
+			{".adoc", false},
+		}
+
+		for _, tc := range testCases {
+			So(IsReStructuredTextFile(tc.ext), ShouldEqual, tc.match)
+		}
+	})
+}
+
+func Test_RawReStructuredText(t *testing.T) {
+	Convey("Rendering reStructuredText", t, func() {
+		testCases := []struct {
+			input  string
+			expect string
+		}{
+			{"=====\nTitle\n=====\n\nSection\n-------\n\nSub\n~~~\n\nAgain\n-----", "<h1>Title</h1>\n<h2>Section</h2>\n<h3>Sub</h3>\n<h2>Again</h2>\n"},
+			{"Some **strong**, *em* and ``literal``.", "<p>Some <strong>strong</strong>, <em>em</em> and <code>literal</code>.</p>\n"},
+			{"`Gogs <https://gogs.io>`_ and `install <docs/install.rst>`_", `<p><a href="https://gogs.io">Gogs</a> and <a href="/user/repo/src/master/docs/install.rst">install</a></p>` + "\n"},
+			{"See Gogs_.\n\n.. _Gogs: https://gogs.io", `<p>See <a href="https://gogs.io">Gogs</a>.</p>` + "\n"},
+			{"- one\n- two\n\n  - nested", "<ul>\n<li>one</li>\n<li><p>two</p>\n<ul>\n<li>nested</li>\n</ul>\n</li>\n</ul>\n"},
+			{"1. first\n#. second", "<ol>\n<li>first</li>\n<li>second</li>\n</ol>\n"},
+			{"Example::\n\n    $ make <all>", "<p>Example:</p>\n<pre><code>$ make &lt;all&gt;</code></pre>\n"},
+			{".. code-block:: go\n\n   package main", `<pre><code class="language-go">package main</code></pre>` + "\n"},
+			{".. image:: logo.png\n   :alt: Logo", `<p><img src="logo.png" alt="Logo"></p>` + "\n"},
+			{".. This is a comment\n   on two lines\n\n<script>", "<p>&lt;script&gt;</p>\n"},
+		}
+
+		for _, tc := range testCases {
+			So(string(RawReStructuredText([]byte(tc.input), "/user/repo/src/master")), ShouldEqual, tc.expect)
+		}
+	})
+}
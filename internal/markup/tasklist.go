@@ -0,0 +1,55 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package markup
+
+import (
+	"strings"
+
+	"gogs.io/gogs/internal/lazyregexp"
+)
+
+// taskListItemPattern matches list items that are rendered as checkboxes by the
+// Markdown renderer, including those in block quotes.
+var taskListItemPattern = lazyregexp.New(`^(?:[ \t]*>)*[ \t]*(?:[-*+]|[0-9]+\.)[ \t]+\[([ x])\] `)
+
+// SetTaskListItem checks or unchecks the task list item at the index in order of
+// appearance in the Markdown content, task list items in fenced code blocks are not
+// counted. It returns the updated content, or false if the item does not exist.
+func SetTaskListItem(content string, index int, checked bool) (string, bool) {
+	if index < 0 {
+		return content, false
+	}
+
+	state := " "
+	if checked {
+		state = "x"
+	}
+
+	lines := strings.Split(content, "\n")
+	var fence string
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+				fence = ""
+			}
+			continue
+		} else if fence = fenceMarker(trimmed); fence != "" {
+			continue
+		}
+
+		loc := taskListItemPattern.FindStringSubmatchIndex(line)
+		if loc == nil {
+			continue
+		} else if index > 0 {
+			index--
+			continue
+		}
+
+		lines[i] = line[:loc[2]] + state + line[loc[3]:]
+		return strings.Join(lines, "\n"), true
+	}
+	return content, false
+}
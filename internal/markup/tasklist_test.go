@@ -0,0 +1,42 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package markup_test
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	. "gogs.io/gogs/internal/markup"
+)
+
+func Test_SetTaskListItem(t *testing.T) {
+	Convey("Check and uncheck task list items", t, func() {
+		content := "- [ ] one\n" +
+			"```\n- [ ] in code\n```\n" +
+			"  * [x] two\n" +
+			"> 1. [ ] three\n" +
+			"- [] not a task"
+
+		testCases := []struct {
+			index   int
+			checked bool
+			expect  string
+			ok      bool
+		}{
+			{0, true, "- [x] one\n```\n- [ ] in code\n```\n  * [x] two\n> 1. [ ] three\n- [] not a task", true},
+			{1, false, "- [ ] one\n```\n- [ ] in code\n```\n  * [ ] two\n> 1. [ ] three\n- [] not a task", true},
+			{2, true, "- [ ] one\n```\n- [ ] in code\n```\n  * [x] two\n> 1. [x] three\n- [] not a task", true},
+			{3, true, content, false},
+			{-1, true, content, false},
+		}
+
+		for _, tc := range testCases {
+			result, ok := SetTaskListItem(content, tc.index, tc.checked)
+			So(ok, ShouldEqual, tc.ok)
+			So(result, ShouldEqual, tc.expect)
+		}
+	})
+}
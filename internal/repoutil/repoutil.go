@@ -0,0 +1,60 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package repoutil
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gogs.io/gogs/internal/conf"
+)
+
+// StorageRoot returns the absolute path of the storage root with given name.
+// It returns the default repository root when the name is empty or unknown.
+func StorageRoot(name string) string {
+	if root, ok := conf.Repository.Storage.RootPaths[name]; ok {
+		return root
+	}
+	return conf.Repository.Root
+}
+
+// IsValidStorageRoot returns true if given name is empty (i.e. the default
+// repository root) or one of the configured named storage roots.
+func IsValidStorageRoot(name string) bool {
+	if name == "" {
+		return true
+	}
+	_, ok := conf.Repository.Storage.RootPaths[name]
+	return ok
+}
+
+// StorageRootNames returns sorted names of all configured named storage roots.
+func StorageRootNames() []string {
+	names := make([]string, 0, len(conf.Repository.Storage.RootPaths))
+	for name := range conf.Repository.Storage.RootPaths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// UserPath returns the absolute path for storing repositories of the user
+// under the storage root with given name.
+func UserPath(root, user string) string {
+	return filepath.Join(StorageRoot(root), strings.ToLower(user))
+}
+
+// RepositoryPath returns the absolute path of the repository under the storage
+// root with given name.
+func RepositoryPath(root, owner, repo string) string {
+	return filepath.Join(UserPath(root, owner), strings.ToLower(repo)+".git")
+}
+
+// WikiPath returns the absolute path of the wiki repository under the storage
+// root with given name.
+func WikiPath(root, owner, repo string) string {
+	return filepath.Join(UserPath(root, owner), strings.ToLower(repo)+".wiki.git")
+}
@@ -0,0 +1,89 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package repoutil
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gogs.io/gogs/internal/conf"
+)
+
+func TestRepositoryPath(t *testing.T) {
+	oldRoot := conf.Repository.Root
+	oldRootPaths := conf.Repository.Storage.RootPaths
+	defer func() {
+		conf.Repository.Root = oldRoot
+		conf.Repository.Storage.RootPaths = oldRootPaths
+	}()
+
+	conf.Repository.Root = filepath.Join("/", "repos")
+	conf.Repository.Storage.RootPaths = map[string]string{
+		"ssd": filepath.Join("/", "mnt", "ssd"),
+	}
+
+	tests := []struct {
+		root   string
+		owner  string
+		repo   string
+		expVal string
+	}{
+		{
+			root:   "",
+			owner:  "Alice",
+			repo:   "Example",
+			expVal: filepath.Join("/", "repos", "alice", "example.git"),
+		}, {
+			root:   "ssd",
+			owner:  "Alice",
+			repo:   "Example",
+			expVal: filepath.Join("/", "mnt", "ssd", "alice", "example.git"),
+		}, {
+			root:   "not_found",
+			owner:  "Alice",
+			repo:   "Example",
+			expVal: filepath.Join("/", "repos", "alice", "example.git"),
+		},
+	}
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			assert.Equal(t, test.expVal, RepositoryPath(test.root, test.owner, test.repo))
+		})
+	}
+}
+
+func TestIsValidStorageRoot(t *testing.T) {
+	oldRootPaths := conf.Repository.Storage.RootPaths
+	defer func() {
+		conf.Repository.Storage.RootPaths = oldRootPaths
+	}()
+
+	conf.Repository.Storage.RootPaths = map[string]string{
+		"ssd": filepath.Join("/", "mnt", "ssd"),
+	}
+
+	tests := []struct {
+		name   string
+		expVal bool
+	}{
+		{
+			name:   "",
+			expVal: true,
+		}, {
+			name:   "ssd",
+			expVal: true,
+		}, {
+			name:   "not_found",
+			expVal: false,
+		},
+	}
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			assert.Equal(t, test.expVal, IsValidStorageRoot(test.name))
+		})
+	}
+}
@@ -173,6 +173,14 @@ func Dashboard(c *context.Context) {
 	c.Data["BuildCommit"] = conf.BuildCommit
 
 	c.Data["Stats"] = db.GetStatistic()
+	if conf.Onboarding.Enabled {
+		stats, err := db.GetOnboardingStats()
+		if err != nil {
+			c.ServerError("GetOnboardingStats", err)
+			return
+		}
+		c.Data["OnboardingStats"] = stats
+	}
 	// FIXME: update periodically
 	updateSystemStatus()
 	c.Data["SysStatus"] = sysStatus
@@ -246,5 +254,12 @@ func Monitor(c *context.Context) {
 	c.Data["PageIsAdminMonitor"] = true
 	c.Data["Processes"] = process.Processes
 	c.Data["Entries"] = cron.ListTasks()
+
+	tableStats, err := db.GetPrunableTableStats()
+	if err != nil {
+		c.Handle(500, "GetPrunableTableStats", err)
+		return
+	}
+	c.Data["TableStats"] = tableStats
 	c.HTML(200, MONITOR)
 }
@@ -0,0 +1,57 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package admin
+
+import (
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+)
+
+const (
+	HOOKS = "admin/hooks"
+)
+
+// HooksAssignment marks webhook pages of the admin panel, which are shared with
+// webhook pages of repositories and organizations, as the system context.
+func HooksAssignment(c *context.Context) {
+	c.Data["PageIsAdmin"] = true
+	c.Data["PageIsAdminHooks"] = true
+}
+
+// AutolinksAssignment marks autolink pages of the admin panel, which are shared
+// with autolink pages of repositories, as the system context.
+func AutolinksAssignment(c *context.Context) {
+	c.Data["PageIsAdmin"] = true
+	c.Data["PageIsAdminAutolinks"] = true
+}
+
+func Webhooks(c *context.Context) {
+	c.Data["Title"] = c.Tr("admin.hooks")
+	c.Data["BaseLink"] = conf.Server.Subpath + "/admin/hooks"
+	c.Data["Description"] = c.Tr("admin.hooks.desc")
+	c.Data["Types"] = conf.Webhook.Types
+
+	ws, err := db.GetSystemWebhooks()
+	if err != nil {
+		c.ServerError("GetSystemWebhooks", err)
+		return
+	}
+	c.Data["Webhooks"] = ws
+
+	c.Success(HOOKS)
+}
+
+func DeleteWebhook(c *context.Context) {
+	if err := db.DeleteSystemWebhookByID(c.QueryInt64("id")); err != nil {
+		c.Flash.Error("DeleteSystemWebhookByID: " + err.Error())
+	} else {
+		c.Flash.Success(c.Tr("repo.settings.webhook_deletion_success"))
+	}
+
+	c.JSONSuccess(map[string]interface{}{
+		"redirect": conf.Server.Subpath + "/admin/hooks",
+	})
+}
@@ -0,0 +1,100 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package admin
+
+import (
+	"github.com/unknwon/com"
+	"github.com/unknwon/paginater"
+	log "unknwon.dev/clog/v2"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+)
+
+const (
+	KEYS = "admin/key/list"
+)
+
+// Keys shows audit report of public keys across the instance.
+func Keys(c *context.Context) {
+	c.Data["Title"] = c.Tr("admin.keys")
+	c.Data["PageIsAdmin"] = true
+	c.Data["PageIsAdminKeys"] = true
+
+	weakOnly := c.QueryBool("weak")
+	c.Data["WeakOnly"] = weakOnly
+	if weakOnly {
+		c.Data["PageQuery"] = "weak=true"
+	}
+
+	audits, err := db.AuditPublicKeys(weakOnly)
+	if err != nil {
+		c.Handle(500, "AuditPublicKeys", err)
+		return
+	}
+
+	page := c.QueryInt("page")
+	if page <= 1 {
+		page = 1
+	}
+	pageSize := conf.UI.Admin.KeyPagingNum
+	c.Data["Total"] = len(audits)
+	c.Data["Page"] = paginater.New(len(audits), pageSize, page, 5)
+
+	start := (page - 1) * pageSize
+	if start > len(audits) {
+		start = len(audits)
+	}
+	end := start + pageSize
+	if end > len(audits) {
+		end = len(audits)
+	}
+	c.Data["Keys"] = audits[start:end]
+
+	c.HTML(200, KEYS)
+}
+
+// selectedIDs returns IDs of public keys selected by the request.
+func selectedIDs(c *context.Context) []int64 {
+	strs := c.QueryStrings("ids[]")
+	ids := make([]int64, 0, len(strs))
+	for i := range strs {
+		id := com.StrTo(strs[i]).MustInt64()
+		if id > 0 {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// RevokeKeys deletes selected public keys and notifies their owners.
+func RevokeKeys(c *context.Context) {
+	audits, err := db.RevokePublicKeys(selectedIDs(c))
+	if err != nil {
+		c.Flash.Error("RevokePublicKeys: " + err.Error())
+		c.Status(500)
+		return
+	}
+	log.Trace("Public keys revoked by admin (%s): %d", c.User.Name, len(audits))
+
+	count := db.NotifyKeyOwners(audits, true)
+	c.Flash.Success(c.Tr("admin.keys.revoke_success", len(audits), count))
+	c.Status(200)
+}
+
+// NotifyKeyOwners asks owners of selected public keys to replace them.
+func NotifyKeyOwners(c *context.Context) {
+	audits, err := db.GetKeyAudits(selectedIDs(c))
+	if err != nil {
+		c.Flash.Error("GetKeyAudits: " + err.Error())
+		c.Status(500)
+		return
+	}
+
+	count := db.NotifyKeyOwners(audits, false)
+	c.Flash.Success(c.Tr("admin.keys.notify_success", count))
+	c.Status(200)
+}
@@ -0,0 +1,85 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package admin
+
+import (
+	"net/http"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/email"
+)
+
+const (
+	MAIL_TEMPLATES = "admin/mail_templates"
+)
+
+type mailTemplate struct {
+	Name     string
+	IsCustom bool
+}
+
+// MailTemplates lists mail templates and previews the selected one.
+func MailTemplates(c *context.Context) {
+	c.Title("admin.config.mail_templates")
+	c.PageIs("Admin")
+	c.PageIs("AdminConfig")
+
+	tpls := make([]*mailTemplate, len(email.Templates))
+	for i, name := range email.Templates {
+		tpls[i] = &mailTemplate{
+			Name:     name,
+			IsCustom: email.IsCustomTemplate(name),
+		}
+	}
+	c.Data["Templates"] = tpls
+
+	selected := c.Query("tpl")
+	if !email.IsValidTemplate(selected) {
+		selected = email.Templates[0]
+	}
+	c.Data["Selected"] = selected
+	c.Data["CustomDir"] = conf.CustomDir()
+
+	c.Success(MAIL_TEMPLATES)
+}
+
+// MailTemplatePreview renders the mail template with made-up data, custom templates
+// are reloaded when changed.
+func MailTemplatePreview(c *context.Context) {
+	tpl := c.Query("tpl")
+	if !email.IsValidTemplate(tpl) {
+		c.NotFound()
+		return
+	}
+
+	body, err := email.RenderPreview(tpl)
+	if err != nil {
+		c.PlainText(http.StatusUnprocessableEntity, []byte(err.Error()))
+		return
+	}
+	c.Resp.Header().Set("Content-Type", "text/html; charset=utf-8")
+	c.Resp.Header().Set("Content-Security-Policy", "sandbox")
+	c.Resp.WriteHeader(http.StatusOK)
+	_, _ = c.Resp.Write([]byte(body))
+}
+
+// SendTestMailTemplate sends the mail template rendered with made-up data to given
+// email address.
+func SendTestMailTemplate(c *context.Context) {
+	tpl := c.Query("tpl")
+	if !email.IsValidTemplate(tpl) {
+		c.NotFound()
+		return
+	}
+
+	emailAddr := c.Query("email")
+	if err := email.SendTestRender(tpl, emailAddr); err != nil {
+		c.Flash.Error(c.Tr("admin.config.email.test_mail_failed", emailAddr, err))
+	} else {
+		c.Flash.Info(c.Tr("admin.config.email.test_mail_sent", emailAddr))
+	}
+	c.Redirect(conf.Server.Subpath + "/admin/config/mail_templates?tpl=" + tpl)
+}
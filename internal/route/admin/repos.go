@@ -5,58 +5,89 @@
 package admin
 
 import (
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"net/url"
+	"time"
+
+	"github.com/unknwon/com"
 	"github.com/unknwon/paginater"
 	log "unknwon.dev/clog/v2"
 
 	"gogs.io/gogs/internal/conf"
 	"gogs.io/gogs/internal/context"
 	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/db/errors"
 )
 
 const (
 	REPOS = "admin/repo/list"
 )
 
+// parseRepoSearchOptions parses filter options of repositories from query parameters,
+// and saves them to context data for rendering.
+func parseRepoSearchOptions(c *context.Context) (*db.AdminSearchRepoOptions, error) {
+	opts := &db.AdminSearchRepoOptions{
+		Keyword:    c.Query("q"),
+		Visibility: c.Query("visibility"),
+		Type:       c.Query("type"),
+		MinSize:    c.QueryInt64("min_size") * 1024 * 1024,
+		MaxSize:    c.QueryInt64("max_size") * 1024 * 1024,
+		SortType:   c.Query("sort"),
+		Page:       c.QueryInt("page"),
+		PageSize:   conf.UI.Admin.RepoPagingNum,
+	}
+
+	owner := c.Query("owner")
+	if len(owner) > 0 {
+		u, err := db.GetUserByName(owner)
+		if err != nil {
+			if !errors.IsUserNotExist(err) {
+				return nil, err
+			}
+			opts.OwnerID = -1 // Nothing should match a non-existent owner
+		} else {
+			opts.OwnerID = u.ID
+		}
+	}
+
+	c.Data["Keyword"] = opts.Keyword
+	c.Data["Owner"] = owner
+	c.Data["Visibility"] = opts.Visibility
+	c.Data["Type"] = opts.Type
+	c.Data["MinSize"] = c.Query("min_size")
+	c.Data["MaxSize"] = c.Query("max_size")
+	c.Data["SortType"] = opts.SortType
+
+	query := make(url.Values)
+	for _, key := range []string{"owner", "visibility", "type", "min_size", "max_size", "sort"} {
+		if val := c.Query(key); len(val) > 0 {
+			query.Set(key, val)
+		}
+	}
+	c.Data["PageQuery"] = template.URL(query.Encode())
+	return opts, nil
+}
+
 func Repos(c *context.Context) {
 	c.Data["Title"] = c.Tr("admin.repositories")
 	c.Data["PageIsAdmin"] = true
 	c.Data["PageIsAdminRepositories"] = true
 
-	page := c.QueryInt("page")
-	if page <= 0 {
-		page = 1
+	opts, err := parseRepoSearchOptions(c)
+	if err != nil {
+		c.Handle(500, "parseRepoSearchOptions", err)
+		return
 	}
 
-	var (
-		repos []*db.Repository
-		count int64
-		err   error
-	)
-
-	keyword := c.Query("q")
-	if len(keyword) == 0 {
-		repos, err = db.Repositories(page, conf.UI.Admin.RepoPagingNum)
-		if err != nil {
-			c.Handle(500, "Repositories", err)
-			return
-		}
-		count = db.CountRepositories(true)
-	} else {
-		repos, count, err = db.SearchRepositoryByName(&db.SearchRepoOptions{
-			Keyword:  keyword,
-			OrderBy:  "id ASC",
-			Private:  true,
-			Page:     page,
-			PageSize: conf.UI.Admin.RepoPagingNum,
-		})
-		if err != nil {
-			c.Handle(500, "SearchRepositoryByName", err)
-			return
-		}
+	repos, count, err := db.AdminSearchRepositories(opts)
+	if err != nil {
+		c.Handle(500, "AdminSearchRepositories", err)
+		return
 	}
-	c.Data["Keyword"] = keyword
 	c.Data["Total"] = count
-	c.Data["Page"] = paginater.New(int(count), conf.UI.Admin.RepoPagingNum, page, 5)
+	c.Data["Page"] = paginater.New(int(count), conf.UI.Admin.RepoPagingNum, opts.Page, 5)
 
 	if err = db.RepositoryList(repos).LoadAttributes(); err != nil {
 		c.Handle(500, "LoadAttributes", err)
@@ -67,6 +98,72 @@ func Repos(c *context.Context) {
 	c.HTML(200, REPOS)
 }
 
+// ExportRepos exports repositories match filter options in CSV format.
+func ExportRepos(c *context.Context) {
+	opts, err := parseRepoSearchOptions(c)
+	if err != nil {
+		c.Handle(500, "parseRepoSearchOptions", err)
+		return
+	}
+
+	c.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	c.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="repositories-%s.csv"`, time.Now().Format("20060102")))
+
+	w := csv.NewWriter(c.Resp)
+	w.Write([]string{"ID", "Owner", "Name", "Private", "Fork", "Mirror", "Watches", "Stars", "Forks", "Issues", "Pulls", "Size", "Created", "Updated"})
+	if err = db.IterateAdminSearchRepositories(opts, 100, func(repos []*db.Repository) error {
+		for _, repo := range repos {
+			if err := w.Write([]string{
+				com.ToStr(repo.ID),
+				repo.Owner.Name,
+				repo.Name,
+				com.ToStr(repo.IsPrivate),
+				com.ToStr(repo.IsFork),
+				com.ToStr(repo.IsMirror),
+				com.ToStr(repo.NumWatches),
+				com.ToStr(repo.NumStars),
+				com.ToStr(repo.NumForks),
+				com.ToStr(repo.NumIssues),
+				com.ToStr(repo.NumPulls),
+				com.ToStr(repo.Size),
+				repo.Created.Format(time.RFC3339),
+				repo.Updated.Format(time.RFC3339),
+			}); err != nil {
+				return err
+			}
+		}
+		w.Flush()
+		return w.Error()
+	}); err != nil {
+		// Headers have been sent, so it is too late to render an error page.
+		log.Error("Failed to export repositories: %v", err)
+		return
+	}
+	w.Flush()
+}
+
+func ArchiveRepo(c *context.Context) {
+	repo, err := db.GetRepositoryByID(c.QueryInt64("id"))
+	if err != nil {
+		c.NotFoundOrServerError("GetRepositoryByID", errors.IsRepoNotExist, err)
+		return
+	}
+
+	archived := c.Params(":action") == "archive"
+	if err = db.SetRepositoryArchived(c.User, repo, archived); err != nil {
+		c.ServerError("SetRepositoryArchived", err)
+		return
+	}
+	log.Trace("Repository archive status changed by admin %q: %s/%s -> %v", c.User.Name, repo.MustOwner().Name, repo.Name, archived)
+
+	if archived {
+		c.Flash.Success(c.Tr("repo.settings.archive_success"))
+	} else {
+		c.Flash.Success(c.Tr("repo.settings.unarchive_success"))
+	}
+	c.Redirect(conf.Server.Subpath + "/admin/repos?page=" + c.Query("page"))
+}
+
 func DeleteRepo(c *context.Context) {
 	repo, err := db.GetRepositoryByID(c.QueryInt64("id"))
 	if err != nil {
@@ -74,8 +171,8 @@ func DeleteRepo(c *context.Context) {
 		return
 	}
 
-	if err := db.DeleteRepository(repo.MustOwner().ID, repo.ID); err != nil {
-		c.Handle(500, "DeleteRepository", err)
+	if err := db.DeleteRepositoryByUser(c.User, repo); err != nil {
+		c.Handle(500, "DeleteRepositoryByUser", err)
 		return
 	}
 	log.Trace("Repository deleted: %s/%s", repo.MustOwner().Name, repo.Name)
@@ -13,8 +13,10 @@ import (
 	"gogs.io/gogs/internal/conf"
 	"gogs.io/gogs/internal/context"
 	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/db/errors"
 	"gogs.io/gogs/internal/email"
 	"gogs.io/gogs/internal/form"
+	"gogs.io/gogs/internal/repoutil"
 	"gogs.io/gogs/internal/route"
 )
 
@@ -36,6 +38,8 @@ func Users(c *context.Context) {
 		PageSize: conf.UI.Admin.UserPagingNum,
 		OrderBy:  "id ASC",
 		TplName:  USERS,
+
+		IncludeDeactivated: true,
 	})
 }
 
@@ -147,6 +151,7 @@ func prepareUserInfo(c *context.Context) *db.User {
 		return nil
 	}
 	c.Data["Sources"] = sources
+	c.Data["StorageRoots"] = repoutil.StorageRootNames()
 
 	return u
 }
@@ -213,6 +218,26 @@ func EditUserPost(c *context.Context, f form.AdminEditUser) {
 	u.AllowGitHook = f.AllowGitHook
 	u.AllowImportLocal = f.AllowImportLocal
 	u.ProhibitLogin = f.ProhibitLogin
+	// Accounts are only deactivated by users themselves, admins can reactivate them.
+	if u.IsDeactivated && !f.Deactivated {
+		if err := db.ReactivateUser(u, c.User); err != nil {
+			c.Handle(500, "ReactivateUser", err)
+			return
+		}
+	}
+
+	if u.StorageRoot != f.StorageRoot {
+		if err := db.MoveUserStorage(u, f.StorageRoot); err != nil {
+			if errors.IsStorageRootNotExist(err) {
+				c.Data["Err_StorageRoot"] = true
+				c.RenderWithErr(c.Tr("admin.users.storage_root_not_exist"), USER_EDIT, &f)
+			} else {
+				c.Handle(500, "MoveUserStorage", err)
+			}
+			return
+		}
+		log.Trace("Account storage root changed by admin (%s): %s -> %q", c.User.Name, u.Name, u.StorageRoot)
+	}
 
 	if err := db.UpdateUser(u); err != nil {
 		if db.IsErrEmailAlreadyUsed(err) {
@@ -229,25 +254,45 @@ func EditUserPost(c *context.Context, f form.AdminEditUser) {
 	c.Redirect(conf.Server.Subpath + "/admin/users/" + c.Params(":userid"))
 }
 
-func DeleteUser(c *context.Context) {
+func DeleteUser(c *context.Context, f form.AdminDeleteUser) {
 	u, err := db.GetUserByID(c.ParamsInt64(":userid"))
 	if err != nil {
 		c.Handle(500, "GetUserByID", err)
 		return
 	}
+	userLink := conf.Server.Subpath + "/admin/users/" + c.Params(":userid")
 
-	if err = db.DeleteUser(u); err != nil {
+	opts := db.DeleteUserOptions{
+		Doer:               c.User,
+		ContentAttribution: f.ContentAttribution,
+	}
+	if f.TransferReposTo != "" {
+		opts.TransferReposTo, err = db.GetUserByName(f.TransferReposTo)
+		if err != nil {
+			if errors.IsUserNotExist(err) {
+				c.Flash.Error(c.Tr("admin.users.transfer_org_not_exist", f.TransferReposTo))
+				c.Redirect(userLink)
+			} else {
+				c.Handle(500, "GetUserByName", err)
+			}
+			return
+		}
+	}
+
+	if err = db.DeleteUser(u, opts); err != nil {
 		switch {
 		case db.IsErrUserOwnRepos(err):
 			c.Flash.Error(c.Tr("admin.users.still_own_repo"))
-			c.JSON(200, map[string]interface{}{
-				"redirect": conf.Server.Subpath + "/admin/users/" + c.Params(":userid"),
-			})
+			c.Redirect(userLink)
 		case db.IsErrUserHasOrgs(err):
 			c.Flash.Error(c.Tr("admin.users.still_has_org"))
-			c.JSON(200, map[string]interface{}{
-				"redirect": conf.Server.Subpath + "/admin/users/" + c.Params(":userid"),
-			})
+			c.Redirect(userLink)
+		case errors.IsNotOrganization(err):
+			c.Flash.Error(c.Tr("admin.users.transfer_org_not_exist", f.TransferReposTo))
+			c.Redirect(userLink)
+		case db.IsErrRepoAlreadyExist(err):
+			c.Flash.Error(c.Tr("admin.users.transfer_repo_exist", err.(db.ErrRepoAlreadyExist).Name))
+			c.Redirect(userLink)
 		default:
 			c.Handle(500, "DeleteUser", err)
 		}
@@ -256,7 +301,5 @@ func DeleteUser(c *context.Context) {
 	log.Trace("Account deleted by admin (%s): %s", c.User.Name, u.Name)
 
 	c.Flash.Success(c.Tr("admin.users.deletion_success"))
-	c.JSON(200, map[string]interface{}{
-		"redirect": conf.Server.Subpath + "/admin/users",
-	})
+	c.Redirect(conf.Server.Subpath + "/admin/users")
 }
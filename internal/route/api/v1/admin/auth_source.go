@@ -0,0 +1,375 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	log "unknwon.dev/clog/v2"
+	"xorm.io/core"
+
+	"gogs.io/gogs/internal/auth/ldap"
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/db/errors"
+)
+
+// authSourceTypes contains names of types of authentication sources used in API.
+var authSourceTypes = map[db.LoginType]string{
+	db.LOGIN_LDAP:   "ldap",
+	db.LOGIN_DLDAP:  "dldap",
+	db.LOGIN_SMTP:   "smtp",
+	db.LOGIN_PAM:    "pam",
+	db.LOGIN_GITHUB: "github",
+}
+
+func parseAuthSourceType(name string) (db.LoginType, bool) {
+	for typ, typName := range authSourceTypes {
+		if typName == name {
+			return typ, true
+		}
+	}
+	return db.LOGIN_NOTYPE, false
+}
+
+// AuthSourceConfig is the configuration of an authentication source, only fields of its
+// type are used. Host, port and skip verify are shared by LDAP and SMTP.
+type AuthSourceConfig struct {
+	Host       string `json:"host,omitempty"`
+	Port       int    `json:"port,omitempty"`
+	SkipVerify bool   `json:"skip_verify,omitempty"`
+
+	// LDAP via BindDN (ldap) and simple auth (dldap)
+	SecurityProtocol  int    `json:"security_protocol,omitempty"` // 0: Unencrypted, 1: LDAPS, 2: StartTLS
+	BindDN            string `json:"bind_dn,omitempty"`
+	BindPassword      string `json:"bind_password,omitempty"` // It is never returned.
+	UserBase          string `json:"user_base,omitempty"`
+	UserDN            string `json:"user_dn,omitempty"`
+	AttributeUsername string `json:"attribute_username,omitempty"`
+	AttributeName     string `json:"attribute_name,omitempty"`
+	AttributeSurname  string `json:"attribute_surname,omitempty"`
+	AttributeMail     string `json:"attribute_mail,omitempty"`
+	AttributesInBind  bool   `json:"attributes_in_bind,omitempty"`
+	Filter            string `json:"filter,omitempty"`
+	AdminFilter       string `json:"admin_filter,omitempty"`
+	GroupEnabled      bool   `json:"group_enabled,omitempty"`
+	GroupDN           string `json:"group_dn,omitempty"`
+	GroupFilter       string `json:"group_filter,omitempty"`
+	GroupMemberUID    string `json:"group_member_uid,omitempty"`
+	UserUID           string `json:"user_uid,omitempty"`
+
+	// SMTP
+	SMTPAuth       string `json:"smtp_auth,omitempty"` // PLAIN or LOGIN
+	AllowedDomains string `json:"allowed_domains,omitempty"`
+	TLS            bool   `json:"tls,omitempty"`
+
+	// PAM
+	PAMServiceName string `json:"pam_service_name,omitempty"`
+
+	// GitHub
+	GitHubAPIEndpoint string `json:"github_api_endpoint,omitempty"`
+}
+
+type AuthSource struct {
+	ID        int64  `json:"id"`
+	Type      string `json:"type"`
+	Name      string `json:"name"`
+	IsActive  bool   `json:"is_active"`
+	IsDefault bool   `json:"is_default"`
+	// IsLocal indicates whether the source is loaded from a file in "custom/conf/auth.d",
+	// changes of it are written back to the file.
+	IsLocal bool             `json:"is_local"`
+	Config  AuthSourceConfig `json:"config"`
+	Created time.Time        `json:"created"`
+	Updated time.Time        `json:"updated"`
+}
+
+type CreateAuthSourceOption struct {
+	Type      string           `json:"type" binding:"Required;In(ldap,dldap,smtp,pam,github)"`
+	Name      string           `json:"name" binding:"Required;MaxSize(30)"`
+	IsActive  bool             `json:"is_active"`
+	IsDefault bool             `json:"is_default"`
+	Config    AuthSourceConfig `json:"config"`
+}
+
+// EditAuthSourceOption contains fields to be changed, the config replaces the current
+// one as a whole except that an empty bind password keeps the current password.
+type EditAuthSourceOption struct {
+	Name      *string           `json:"name"`
+	IsActive  *bool             `json:"is_active"`
+	IsDefault *bool             `json:"is_default"`
+	Config    *AuthSourceConfig `json:"config"`
+}
+
+func toAuthSource(source *db.LoginSource) *AuthSource {
+	apiSource := &AuthSource{
+		ID:        source.ID,
+		Type:      authSourceTypes[source.Type],
+		Name:      source.Name,
+		IsActive:  source.IsActived,
+		IsDefault: source.IsDefault,
+		IsLocal:   source.LocalFile != nil,
+		Created:   source.Created,
+		Updated:   source.Updated,
+	}
+
+	cfg := &apiSource.Config
+	switch source.Type {
+	case db.LOGIN_LDAP, db.LOGIN_DLDAP:
+		ls := source.LDAP().Source
+		cfg.Host = ls.Host
+		cfg.Port = ls.Port
+		cfg.SkipVerify = ls.SkipVerify
+		cfg.SecurityProtocol = int(ls.SecurityProtocol)
+		cfg.BindDN = ls.BindDN
+		cfg.UserBase = ls.UserBase
+		cfg.UserDN = ls.UserDN
+		cfg.AttributeUsername = ls.AttributeUsername
+		cfg.AttributeName = ls.AttributeName
+		cfg.AttributeSurname = ls.AttributeSurname
+		cfg.AttributeMail = ls.AttributeMail
+		cfg.AttributesInBind = ls.AttributesInBind
+		cfg.Filter = ls.Filter
+		cfg.AdminFilter = ls.AdminFilter
+		cfg.GroupEnabled = ls.GroupEnabled
+		cfg.GroupDN = ls.GroupDN
+		cfg.GroupFilter = ls.GroupFilter
+		cfg.GroupMemberUID = ls.GroupMemberUID
+		cfg.UserUID = ls.UserUID
+	case db.LOGIN_SMTP:
+		smtp := source.SMTP()
+		cfg.Host = smtp.Host
+		cfg.Port = smtp.Port
+		cfg.SkipVerify = smtp.SkipVerify
+		cfg.SMTPAuth = smtp.Auth
+		cfg.AllowedDomains = smtp.AllowedDomains
+		cfg.TLS = smtp.TLS
+	case db.LOGIN_PAM:
+		cfg.PAMServiceName = source.PAM().ServiceName
+	case db.LOGIN_GITHUB:
+		cfg.GitHubAPIEndpoint = source.GitHub().APIEndpoint
+	}
+	return apiSource
+}
+
+// loginSourceConfig validates and converts the config to the one of given type.
+func loginSourceConfig(typ db.LoginType, cfg *AuthSourceConfig) (core.Conversion, error) {
+	switch typ {
+	case db.LOGIN_LDAP, db.LOGIN_DLDAP:
+		if cfg.Host == "" || cfg.Port <= 0 {
+			return nil, fmt.Errorf("host and port are required")
+		} else if cfg.SecurityProtocol < int(ldap.SECURITY_PROTOCOL_UNENCRYPTED) ||
+			cfg.SecurityProtocol > int(ldap.SECURITY_PROTOCOL_START_TLS) {
+			return nil, fmt.Errorf("unknown security protocol %d", cfg.SecurityProtocol)
+		}
+		return &db.LDAPConfig{
+			Source: &ldap.Source{
+				Host:              cfg.Host,
+				Port:              cfg.Port,
+				SecurityProtocol:  ldap.SecurityProtocol(cfg.SecurityProtocol),
+				SkipVerify:        cfg.SkipVerify,
+				BindDN:            cfg.BindDN,
+				UserDN:            cfg.UserDN,
+				BindPassword:      cfg.BindPassword,
+				UserBase:          cfg.UserBase,
+				AttributeUsername: cfg.AttributeUsername,
+				AttributeName:     cfg.AttributeName,
+				AttributeSurname:  cfg.AttributeSurname,
+				AttributeMail:     cfg.AttributeMail,
+				AttributesInBind:  cfg.AttributesInBind,
+				Filter:            cfg.Filter,
+				GroupEnabled:      cfg.GroupEnabled,
+				GroupDN:           cfg.GroupDN,
+				GroupFilter:       cfg.GroupFilter,
+				GroupMemberUID:    cfg.GroupMemberUID,
+				UserUID:           cfg.UserUID,
+				AdminFilter:       cfg.AdminFilter,
+			},
+		}, nil
+
+	case db.LOGIN_SMTP:
+		if cfg.Host == "" || cfg.Port <= 0 {
+			return nil, fmt.Errorf("host and port are required")
+		}
+		auth := strings.ToUpper(cfg.SMTPAuth)
+		if auth == "" {
+			auth = db.SMTPAuths[0]
+		}
+		valid := false
+		for _, name := range db.SMTPAuths {
+			valid = valid || name == auth
+		}
+		if !valid {
+			return nil, fmt.Errorf("unknown SMTP authentication type %q", cfg.SMTPAuth)
+		}
+		return &db.SMTPConfig{
+			Auth:           auth,
+			Host:           cfg.Host,
+			Port:           cfg.Port,
+			AllowedDomains: cfg.AllowedDomains,
+			TLS:            cfg.TLS,
+			SkipVerify:     cfg.SkipVerify,
+		}, nil
+
+	case db.LOGIN_PAM:
+		if cfg.PAMServiceName == "" {
+			return nil, fmt.Errorf("PAM service name is required")
+		}
+		return &db.PAMConfig{
+			ServiceName: cfg.PAMServiceName,
+		}, nil
+
+	case db.LOGIN_GITHUB:
+		if u, err := url.Parse(cfg.GitHubAPIEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, fmt.Errorf("GitHub API endpoint must be a valid HTTP/HTTPS URL")
+		}
+		return &db.GitHubConfig{
+			APIEndpoint: strings.TrimSuffix(cfg.GitHubAPIEndpoint, "/") + "/",
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported type %d", typ)
+}
+
+func authSourceByParams(c *context.APIContext) *db.LoginSource {
+	source, err := db.GetLoginSourceByID(c.ParamsInt64(":id"))
+	if err != nil {
+		c.NotFoundOrServerError("GetLoginSourceByID", errors.IsLoginSourceNotExist, err)
+		return nil
+	}
+	return source
+}
+
+func ListAuthSources(c *context.APIContext) {
+	sources, err := db.LoginSources()
+	if err != nil {
+		c.ServerError("LoginSources", err)
+		return
+	}
+
+	apiSources := make([]*AuthSource, len(sources))
+	for i := range sources {
+		apiSources[i] = toAuthSource(sources[i])
+	}
+	c.JSONSuccess(&apiSources)
+}
+
+func GetAuthSource(c *context.APIContext) {
+	source := authSourceByParams(c)
+	if c.Written() {
+		return
+	}
+	c.JSONSuccess(toAuthSource(source))
+}
+
+func CreateAuthSource(c *context.APIContext, form CreateAuthSourceOption) {
+	typ, _ := parseAuthSourceType(form.Type)
+	cfg, err := loginSourceConfig(typ, &form.Config)
+	if err != nil {
+		c.Error(http.StatusUnprocessableEntity, "", err)
+		return
+	}
+
+	source := &db.LoginSource{
+		Type:      typ,
+		Name:      form.Name,
+		IsActived: form.IsActive,
+		IsDefault: form.IsDefault,
+		Cfg:       cfg,
+	}
+	if err = db.CreateLoginSource(source); err != nil {
+		if db.IsErrLoginSourceAlreadyExist(err) {
+			c.Error(http.StatusUnprocessableEntity, "", err)
+		} else {
+			c.ServerError("CreateLoginSource", err)
+		}
+		return
+	}
+	log.Trace("Authentication created by admin '%s': %s", c.User.Name, source.Name)
+
+	source, err = db.GetLoginSourceByID(source.ID)
+	if err != nil {
+		c.ServerError("GetLoginSourceByID", err)
+		return
+	}
+	c.JSON(http.StatusCreated, toAuthSource(source))
+}
+
+func EditAuthSource(c *context.APIContext, form EditAuthSourceOption) {
+	source := authSourceByParams(c)
+	if c.Written() {
+		return
+	}
+
+	if form.Name != nil && *form.Name != source.Name {
+		if *form.Name == "" || len(*form.Name) > 30 {
+			c.Error(http.StatusUnprocessableEntity, "", "name must be between 1 and 30 characters")
+			return
+		}
+		sources, err := db.LoginSources()
+		if err != nil {
+			c.ServerError("LoginSources", err)
+			return
+		}
+		for i := range sources {
+			if sources[i].Name == *form.Name {
+				c.Error(http.StatusUnprocessableEntity, "", db.ErrLoginSourceAlreadyExist{Name: *form.Name})
+				return
+			}
+		}
+		source.Name = *form.Name
+	}
+	if form.IsActive != nil {
+		source.IsActived = *form.IsActive
+	}
+	if form.IsDefault != nil {
+		source.IsDefault = *form.IsDefault
+	}
+	if form.Config != nil {
+		if form.Config.BindPassword == "" && (source.IsLDAP() || source.IsDLDAP()) {
+			form.Config.BindPassword = source.LDAP().BindPassword
+		}
+		cfg, err := loginSourceConfig(source.Type, form.Config)
+		if err != nil {
+			c.Error(http.StatusUnprocessableEntity, "", err)
+			return
+		}
+		source.Cfg = cfg
+	}
+
+	if err := db.UpdateLoginSource(source); err != nil {
+		c.ServerError("UpdateLoginSource", err)
+		return
+	}
+	log.Trace("Authentication changed by admin '%s': %d", c.User.Name, source.ID)
+
+	c.JSONSuccess(toAuthSource(source))
+}
+
+func DeleteAuthSource(c *context.APIContext) {
+	source := authSourceByParams(c)
+	if c.Written() {
+		return
+	}
+
+	if source.LocalFile != nil {
+		c.Error(http.StatusUnprocessableEntity, "", "source loaded from a file cannot be deleted")
+		return
+	}
+	if err := db.DeleteSource(source); err != nil {
+		if db.IsErrLoginSourceInUse(err) {
+			c.Error(http.StatusUnprocessableEntity, "", err)
+		} else {
+			c.ServerError("DeleteSource", err)
+		}
+		return
+	}
+	log.Trace("Authentication deleted by admin '%s': %d", c.User.Name, source.ID)
+
+	c.NoContent()
+}
@@ -0,0 +1,125 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/json-iterator/go"
+	log "unknwon.dev/clog/v2"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/context"
+)
+
+type Setting struct {
+	Name        string      `json:"name"`
+	Type        string      `json:"type"`
+	Value       interface{} `json:"value"`
+	Description string      `json:"description"`
+	Enum        []string    `json:"enum,omitempty"`
+}
+
+type SettingChange struct {
+	Name     string      `json:"name"`
+	OldValue interface{} `json:"old_value"`
+	NewValue interface{} `json:"new_value"`
+}
+
+type EditSettingsResult struct {
+	DryRun  bool             `json:"dry_run"`
+	File    string           `json:"file"`
+	Changes []*SettingChange `json:"changes"`
+	// Diff is the changes in unified diff format of the configuration file.
+	Diff string `json:"diff"`
+}
+
+func ListSettings(c *context.APIContext) {
+	settings := conf.Settings()
+	apiSettings := make([]*Setting, len(settings))
+	for i, s := range settings {
+		apiSettings[i] = &Setting{
+			Name:        s.Name(),
+			Type:        s.Type,
+			Value:       s.Value(),
+			Description: s.Description,
+			Enum:        s.Enum,
+		}
+	}
+	c.JSONSuccess(apiSettings)
+}
+
+func GetSettingsSchema(c *context.APIContext) {
+	c.JSONSuccess(conf.SettingsSchema())
+}
+
+// settingsDiff returns changes in unified diff format grouped by sections.
+func settingsDiff(changes []*conf.SettingChange) string {
+	var buf strings.Builder
+	section := ""
+	for i, c := range changes {
+		if i == 0 || c.Setting.Section != section {
+			section = c.Setting.Section
+			fmt.Fprintf(&buf, "@@ [%s] @@\n", section)
+		}
+		fmt.Fprintf(&buf, "-%s = %v\n", c.Setting.Key, c.OldValue)
+		fmt.Fprintf(&buf, "+%s = %v\n", c.Setting.Key, c.NewValue)
+	}
+	return buf.String()
+}
+
+// EditSettings validates and applies new values of settings, which are given as a JSON
+// object keyed by names of settings. Changes are only validated and returned in dry run.
+func EditSettings(c *context.APIContext) {
+	body, err := c.Req.Body().Bytes()
+	if err != nil {
+		c.Error(http.StatusUnprocessableEntity, "", err)
+		return
+	}
+
+	values := make(map[string]interface{})
+	if err = jsoniter.Unmarshal(body, &values); err != nil {
+		c.Error(http.StatusUnprocessableEntity, "", fmt.Sprintf("invalid JSON object: %v", err))
+		return
+	}
+
+	changes, err := conf.PlanSettings(values)
+	if err != nil {
+		if _, ok := err.(conf.SettingErrors); ok {
+			c.Error(http.StatusUnprocessableEntity, "", err)
+		} else {
+			c.ServerError("PlanSettings", err)
+		}
+		return
+	}
+
+	dryRun := c.QueryBool("dry_run")
+	if !dryRun {
+		if err = conf.ApplySettings(changes); err != nil {
+			c.ServerError("ApplySettings", err)
+			return
+		}
+		for _, change := range changes {
+			log.Info("Setting %q is changed by admin %q: %v -> %v", change.Setting.Name(), c.User.Name, change.OldValue, change.NewValue)
+		}
+	}
+
+	apiChanges := make([]*SettingChange, len(changes))
+	for i, change := range changes {
+		apiChanges[i] = &SettingChange{
+			Name:     change.Setting.Name(),
+			OldValue: change.OldValue,
+			NewValue: change.NewValue,
+		}
+	}
+	c.JSONSuccess(&EditSettingsResult{
+		DryRun:  dryRun,
+		File:    conf.SettingsFile(),
+		Changes: apiChanges,
+		Diff:    settingsDiff(changes),
+	})
+}
@@ -136,9 +136,30 @@ func DeleteUser(c *context.APIContext) {
 		return
 	}
 
-	if err := db.DeleteUser(u); err != nil {
+	// Repositories are transferred to the organization given by "transfer_repos_to",
+	// and content is anonymized when "content_attribution" is "anonymize".
+	opts := db.DeleteUserOptions{
+		Doer:               c.User,
+		ContentAttribution: c.Query("content_attribution"),
+	}
+	if orgName := c.Query("transfer_repos_to"); orgName != "" {
+		org, err := db.GetUserByName(orgName)
+		if err != nil {
+			if errors.IsUserNotExist(err) {
+				c.Error(http.StatusUnprocessableEntity, "", err)
+			} else {
+				c.ServerError("GetUserByName", err)
+			}
+			return
+		}
+		opts.TransferReposTo = org
+	}
+
+	if err := db.DeleteUser(u, opts); err != nil {
 		if db.IsErrUserOwnRepos(err) ||
-			db.IsErrUserHasOrgs(err) {
+			db.IsErrUserHasOrgs(err) ||
+			errors.IsNotOrganization(err) ||
+			db.IsErrRepoAlreadyExist(err) {
 			c.Error(http.StatusUnprocessableEntity, "", err)
 		} else {
 			c.ServerError("DeleteUser", err)
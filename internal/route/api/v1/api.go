@@ -6,6 +6,7 @@ package v1
 
 import (
 	admin2 "gogs.io/gogs/internal/route/api/v1/admin"
+	federation2 "gogs.io/gogs/internal/route/api/v1/federation"
 	misc2 "gogs.io/gogs/internal/route/api/v1/misc"
 	org2 "gogs.io/gogs/internal/route/api/v1/org"
 	repo2 "gogs.io/gogs/internal/route/api/v1/repo"
@@ -18,6 +19,7 @@ import (
 
 	api "github.com/gogs/go-gogs-client"
 
+	"gogs.io/gogs/internal/conf"
 	"gogs.io/gogs/internal/context"
 	"gogs.io/gogs/internal/db"
 	"gogs.io/gogs/internal/db/errors"
@@ -64,6 +66,12 @@ func repoAssignment() macaron.Handler {
 				return
 			}
 			c.Repo.AccessMode = mode
+
+			c.Repo.UnitModes, err = db.UserUnitAccessModes(c.UserID(), r)
+			if err != nil {
+				c.ServerError("UserUnitAccessModes", err)
+				return
+			}
 		}
 
 		if !c.Repo.HasAccess() {
@@ -149,7 +157,38 @@ func reqRepoWriter() macaron.Handler {
 	}
 }
 
-// reqRepoWriter makes sure the context user has at least admin access to the repository.
+// reqRepoUnitWriter makes sure the context user has at least write access to the
+// unit of repository.
+func reqRepoUnitWriter(unit db.RepoUnit) macaron.Handler {
+	return func(c *context.Context) {
+		if !c.Repo.CanWrite(unit) {
+			c.Error(http.StatusForbidden)
+			return
+		}
+	}
+}
+
+// reqRepoUnitReader makes sure the context user has read access to the unit of the repository.
+func reqRepoUnitReader(unit db.RepoUnit) macaron.Handler {
+	return func(c *context.Context) {
+		if c.Repo.UnitMode(unit) < db.ACCESS_MODE_READ {
+			c.NotFound()
+			return
+		}
+	}
+}
+
+// reqRepoTriager makes sure the context user has at least triage access to the repository.
+func reqRepoTriager() macaron.Handler {
+	return func(c *context.Context) {
+		if !c.Repo.IsTriager() {
+			c.Error(http.StatusForbidden)
+			return
+		}
+	}
+}
+
+// reqRepoAdmin makes sure the context user has at least admin access to the repository.
 func reqRepoAdmin() macaron.Handler {
 	return func(c *context.Context) {
 		if !c.Repo.IsAdmin() {
@@ -159,6 +198,37 @@ func reqRepoAdmin() macaron.Handler {
 	}
 }
 
+// reqOrgMember makes sure the context user is a member of the organization.
+func reqOrgMember() macaron.Handler {
+	return func(c *context.APIContext) {
+		if !c.Org.Organization.IsOrgMember(c.User.ID) {
+			c.Status(http.StatusForbidden)
+			return
+		}
+	}
+}
+
+// reqOrgOwner makes sure the context user is an owner of the organization or a site admin.
+func reqOrgOwner() macaron.Handler {
+	return func(c *context.APIContext) {
+		if !c.User.IsAdmin && !c.Org.Organization.IsOwnedBy(c.User.ID) {
+			c.Status(http.StatusForbidden)
+			return
+		}
+	}
+}
+
+// reqRepoNotArchived makes sure the repository is not archived for requests that make changes,
+// archived repositories are read-only.
+func reqRepoNotArchived() macaron.Handler {
+	return func(c *context.APIContext) {
+		if c.Repo.Repository.IsArchived && c.Req.Method != http.MethodGet {
+			c.Error(http.StatusForbidden, "", "repository is archived")
+			return
+		}
+	}
+}
+
 func mustEnableIssues(c *context.APIContext) {
 	if !c.Repo.Repository.EnableIssues || c.Repo.Repository.EnableExternalTracker {
 		c.NotFound()
@@ -176,12 +246,15 @@ func RegisterRoutes(m *macaron.Macaron) {
 		m.Options("/*", func() {})
 
 		// Miscellaneous
-		m.Post("/markdown", bind(api.MarkdownOption{}), misc2.Markdown)
+		m.Post("/markdown", bind(misc2.MarkdownOption{}), misc2.Markdown)
 		m.Post("/markdown/raw", misc2.MarkdownRaw)
+		m.Get("/search", misc2.Search)
+		m.Get("/topics/search", misc2.SearchTopics)
 
 		// Users
 		m.Group("/users", func() {
 			m.Get("/search", user2.Search)
+			m.Post("/batch", bind(user2.BatchGetUsersOption{}), user2.BatchGetUsers)
 
 			m.Group("/:username", func() {
 				m.Get("", user2.GetInfo)
@@ -203,6 +276,8 @@ func RegisterRoutes(m *macaron.Macaron) {
 					m.Get("", user2.ListFollowing)
 					m.Get("/:target", user2.CheckFollowing)
 				})
+
+				m.Get("/starlists", user2.ListStarLists)
 			})
 		}, reqToken())
 
@@ -231,16 +306,48 @@ func RegisterRoutes(m *macaron.Macaron) {
 					Delete(user2.DeletePublicKey)
 			})
 
+			m.Group("/onboarding", func() {
+				m.Get("", user2.GetOnboarding)
+				m.Put("/steps/:step", user2.CompleteOnboardingStep)
+				m.Post("/dismiss", user2.DismissOnboarding)
+			})
+
+			m.Group("/starlists", func() {
+				m.Combo("").
+					Get(user2.ListMyStarLists).
+					Post(bind(user2.CreateStarListOption{}), user2.CreateStarList)
+				m.Group("/:id", func() {
+					m.Combo("").
+						Get(user2.GetStarList).
+						Patch(bind(user2.EditStarListOption{}), user2.EditStarList).
+						Delete(user2.DeleteStarList)
+					m.Get("/repos", user2.ListStarListRepos)
+					m.Combo("/repos/:owner/:reponame").
+						Put(user2.AddStarListRepo).
+						Delete(user2.RemoveStarListRepo)
+				})
+			})
+
 			m.Get("/issues", repo2.ListUserIssues)
 		}, reqToken())
 
+		m.Group("/notifications", func() {
+			m.Combo("").
+				Get(user2.ListNotifications).
+				Put(user2.ReadNotifications)
+			m.Get("/new", user2.CountNewNotifications)
+			m.Combo("/threads/:id").
+				Get(user2.GetNotificationThread).
+				Patch(user2.ReadNotificationThread)
+		}, reqToken())
+
 		// Repositories
 		m.Get("/users/:username/repos", reqToken(), repo2.ListUserRepositories)
 		m.Get("/orgs/:org/repos", reqToken(), repo2.ListOrgRepositories)
 		m.Combo("/user/repos", reqToken()).
 			Get(repo2.ListMyRepos).
-			Post(bind(api.CreateRepoOption{}), repo2.Create)
-		m.Post("/org/:org/repos", reqToken(), bind(api.CreateRepoOption{}), repo2.CreateOrgRepo)
+			Post(bind(repo2.CreateRepoOption{}), repo2.Create)
+		m.Post("/org/:org/repos", reqToken(), bind(repo2.CreateRepoOption{}), repo2.CreateOrgRepo)
 
 		m.Group("/repos", func() {
 			m.Get("/search", repo2.Search)
@@ -250,6 +357,7 @@ func RegisterRoutes(m *macaron.Macaron) {
 
 		m.Group("/repos", func() {
 			m.Post("/migrate", bind(form.MigrateRepo{}), repo2.Migrate)
+			m.Post("/import", bind(form.ImportRepo{}), repo2.Import)
 			m.Delete("/:username/:reponame", repoAssignment(), repo2.Delete)
 
 			m.Group("/:username/:reponame", func() {
@@ -258,8 +366,15 @@ func RegisterRoutes(m *macaron.Macaron) {
 						Get(repo2.ListHooks).
 						Post(bind(api.CreateHookOption{}), repo2.CreateHook)
 					m.Combo("/:id").
+						Get(repo2.GetHook).
 						Patch(bind(api.EditHookOption{}), repo2.EditHook).
 						Delete(repo2.DeleteHook)
+					m.Post("/:id/tests", repo2.TestHook)
+					m.Group("/:id/deliveries", func() {
+						m.Get("", repo2.ListHookDeliveries)
+						m.Get("/:delivery_id", repo2.GetHookDelivery)
+						m.Post("/:delivery_id/attempts", repo2.RedeliverHookDelivery)
+					})
 				}, reqRepoAdmin())
 
 				m.Group("/collaborators", func() {
@@ -270,21 +385,78 @@ func RegisterRoutes(m *macaron.Macaron) {
 						Delete(repo2.DeleteCollaborator)
 				}, reqRepoAdmin())
 
-				m.Get("/raw/*", context.RepoRef(), repo2.GetRawFile)
-				m.Get("/archive/*", repo2.GetArchive)
-				m.Group("/git/trees", func() {
-					m.Get("/:sha", context.RepoRef(), repo2.GetRepoGitTree)
-				})
-				m.Get("/forks", repo2.ListForks)
-				m.Group("/branches", func() {
-					m.Get("", repo2.ListBranches)
-					m.Get("/*", repo2.GetBranch)
-				})
-				m.Group("/commits", func() {
-					m.Get("/:sha", repo2.GetSingleCommit)
-					m.Get("/*", repo2.GetReferenceSHA)
+				m.Group("/topics", func() {
+					m.Combo("").
+						Get(repo2.ListTopics).
+						Put(reqRepoAdmin(), bind(repo2.ReplaceTopicsOption{}), repo2.ReplaceTopics)
+					m.Combo("/:topic", reqRepoAdmin()).
+						Put(repo2.AddTopic).
+						Delete(repo2.DeleteTopic)
 				})
 
+				m.Combo("/archived").
+					Put(repo2.Archive).
+					Delete(repo2.Unarchive)
+
+				m.Get("/export", reqRepoAdmin(), repo2.Export)
+				m.Group("/maintenance", func() {
+					m.Combo("").
+						Get(repo2.ListMaintenanceJobs).
+						Post(bind(repo2.CreateMaintenanceJobOption{}), repo2.CreateMaintenanceJob)
+					m.Get("/:id", repo2.GetMaintenanceJob)
+				}, reqAdmin())
+				m.Get("/mentionables", repo2.ListMentionableUsers)
+
+				// Users who can only participate in issues cannot read code.
+				m.Group("", func() {
+					m.Get("/languages", repo2.ListLanguages)
+					m.Get("/raw/*", context.RepoRef(), repo2.GetRawFile)
+					m.Get("/contents", repo2.GetContents)
+					// Request body of DELETE is not parsed by bind.
+					m.Combo("/contents/*").
+						Get(repo2.GetContents).
+						Put(reqRepoUnitWriter(db.REPO_UNIT_CODE), reqRepoNotArchived(), bind(repo2.CreateOrUpdateFileOption{}), repo2.PutContents).
+						Delete(reqRepoUnitWriter(db.REPO_UNIT_CODE), reqRepoNotArchived(), binding.Json(repo2.DeleteFileOption{}), repo2.DeleteContents)
+					m.Get("/archive/*", repo2.GetArchive)
+					m.Get("/bundle", repo2.GetBundle)
+					m.Group("/git", func() {
+						m.Get("/trees/:sha", repo2.GetRepoGitTree)
+						m.Get("/blobs/:sha", repo2.GetRepoGitBlob)
+						m.Get("/refs", repo2.ListRepoGitRefs)
+						m.Get("/refs/*", repo2.ListRepoGitRefs)
+						m.Get("/commits/:sha", repo2.GetRepoGitCommit)
+					})
+					m.Group("/stats", func() {
+						m.Get("/contributors", repo2.ListContributorStats)
+						m.Get("/commit_activity", repo2.ListCommitActivity)
+						m.Get("/code_frequency", repo2.ListCodeFrequency)
+					})
+					m.Get("/forks", repo2.ListForks)
+					m.Get("/activities", repo2.ListActivities)
+					m.Group("/branches", func() {
+						m.Get("", repo2.ListBranches)
+						m.Get("/*", repo2.GetBranch)
+					})
+					m.Group("/deleted_branches", func() {
+						m.Get("", repo2.ListDeletedBranches)
+						m.Combo("/:id").
+							Get(repo2.GetDeletedBranch).
+							Delete(repo2.PurgeDeletedBranch)
+						m.Post("/:id/restore", repo2.RestoreDeletedBranch)
+					}, reqRepoUnitWriter(db.REPO_UNIT_CODE), reqRepoNotArchived())
+					m.Combo("/statuses/:sha").
+						Get(repo2.ListCommitStatuses).
+						Post(reqRepoUnitWriter(db.REPO_UNIT_CODE), reqRepoNotArchived(), bind(repo2.CreateCommitStatusOption{}), repo2.CreateCommitStatus)
+					m.Group("/commits", func() {
+						m.Get("/:ref/statuses", repo2.ListCommitStatusesByRef)
+						m.Get("/:ref/status", repo2.GetCombinedCommitStatus)
+						m.Get("/:sha", repo2.GetSingleCommit)
+						m.Get("/*", repo2.GetReferenceSHA)
+					})
+					m.Get("/compare-tags/:from/:to", repo2.CompareTags)
+					m.Get("/editorconfig/:filename", context.RepoRef(), repo2.GetEditorconfig)
+				}, reqRepoUnitReader(db.REPO_UNIT_CODE))
+
 				m.Group("/keys", func() {
 					m.Combo("").
 						Get(repo2.ListDeployKeys).
@@ -298,6 +470,7 @@ func RegisterRoutes(m *macaron.Macaron) {
 					m.Combo("").
 						Get(repo2.ListIssues).
 						Post(bind(api.CreateIssueOption{}), repo2.CreateIssue)
+					m.Get("/graph", repo2.GetIssueGraph)
 					m.Group("/comments", func() {
 						m.Get("", repo2.ListRepoIssueComments)
 						m.Patch("/:id", bind(api.EditIssueCommentOption{}), repo2.EditIssueComment)
@@ -316,6 +489,9 @@ func RegisterRoutes(m *macaron.Macaron) {
 								Delete(repo2.DeleteIssueComment)
 						})
 
+						m.Get("/form-data", repo2.GetIssueFormData)
+						m.Get("/events", repo2.ListIssueEvents)
+						m.Get("/participants", repo2.ListIssueParticipants)
 						m.Get("/labels", repo2.ListIssueLabels)
 						m.Group("/labels", func() {
 							m.Combo("").
@@ -323,9 +499,9 @@ func RegisterRoutes(m *macaron.Macaron) {
 								Put(bind(api.IssueLabelsOption{}), repo2.ReplaceIssueLabels).
 								Delete(repo2.ClearIssueLabels)
 							m.Delete("/:id", repo2.DeleteIssueLabel)
-						}, reqRepoWriter())
+						}, reqRepoTriager())
 					})
-				}, mustEnableIssues)
+				}, mustEnableIssues, reqRepoNotArchived())
 
 				m.Group("/labels", func() {
 					m.Get("", repo2.ListLabels)
@@ -336,22 +512,44 @@ func RegisterRoutes(m *macaron.Macaron) {
 					m.Combo("/:id").
 						Patch(bind(api.EditLabelOption{}), repo2.EditLabel).
 						Delete(repo2.DeleteLabel)
-				}, reqRepoWriter())
+				}, reqRepoTriager(), reqRepoNotArchived())
 
 				m.Group("/milestones", func() {
 					m.Get("", repo2.ListMilestones)
 					m.Get("/:id", repo2.GetMilestone)
+					m.Get("/:id/progress", repo2.GetMilestoneProgress)
 				})
 				m.Group("/milestones", func() {
 					m.Post("", bind(api.CreateMilestoneOption{}), repo2.CreateMilestone)
 					m.Combo("/:id").
 						Patch(bind(api.EditMilestoneOption{}), repo2.EditMilestone).
 						Delete(repo2.DeleteMilestone)
-				}, reqRepoWriter())
-
+				}, reqRepoTriager(), reqRepoNotArchived())
+
+				m.Group("/releases", func() {
+					m.Get("", repo2.ListReleases)
+					m.Get("/tags/*", repo2.GetReleaseByTag)
+					m.Get("/:id", repo2.GetRelease)
+					m.Get("/:id/assets", repo2.ListReleaseAssets)
+					m.Get("/:id/assets/:asset_id", repo2.GetReleaseAsset)
+				}, reqRepoUnitReader(db.REPO_UNIT_RELEASES))
+				m.Group("/releases", func() {
+					m.Post("", bind(repo2.CreateReleaseOption{}), repo2.CreateRelease)
+					m.Combo("/:id").
+						Patch(bind(repo2.EditReleaseOption{}), repo2.EditRelease).
+						Delete(repo2.DeleteRelease)
+					m.Post("/:id/assets", repo2.UploadReleaseAsset)
+					m.Combo("/:id/assets/:asset_id").
+						Patch(bind(repo2.EditReleaseAssetOption{}), repo2.EditReleaseAsset).
+						Delete(repo2.DeleteReleaseAsset)
+				}, reqRepoUnitWriter(db.REPO_UNIT_RELEASES), reqRepoNotArchived())
+
+				m.Get("/stars/history", repo2.GetStarHistory)
+				m.Combo("/notifications").
+					Get(user2.ListRepoNotifications).
+					Put(user2.ReadRepoNotifications)
 				m.Patch("/issue-tracker", reqRepoWriter(), bind(api.EditIssueTrackerOption{}), repo2.IssueTracker)
-				m.Post("/mirror-sync", reqRepoWriter(), repo2.MirrorSync)
-				m.Get("/editorconfig/:filename", context.RepoRef(), repo2.GetEditorconfig)
+				m.Post("/mirror-sync", reqRepoWriter(), reqRepoNotArchived(), repo2.MirrorSync)
 			}, repoAssignment())
 		}, reqToken())
 
@@ -368,9 +566,46 @@ func RegisterRoutes(m *macaron.Macaron) {
 				Get(org2.Get).
 				Patch(bind(api.EditOrgOption{}), org2.Edit)
 			m.Get("/teams", org2.ListTeams)
+			m.Get("/issues/graph", org2.GetIssueGraph)
+			m.Group("/projects", func() {
+				m.Get("", org2.ListProjects)
+				m.Group("/:id", func() {
+					m.Get("", org2.GetProjectBoard)
+					m.Post("/cards", bind(org2.AddProjectCardOption{}), org2.AddProjectCard)
+					m.Combo("/cards/:cardid").
+						Patch(bind(org2.MoveProjectCardOption{}), org2.MoveProjectCard).
+						Delete(org2.DeleteProjectCard)
+				})
+			}, reqToken(), reqOrgMember())
+			m.Group("/hooks", func() {
+				m.Combo("").
+					Get(org2.ListHooks).
+					Post(bind(api.CreateHookOption{}), org2.CreateHook)
+				m.Combo("/:id").
+					Get(org2.GetHook).
+					Patch(bind(api.EditHookOption{}), org2.EditHook).
+					Delete(org2.DeleteHook)
+			}, reqToken(), reqOrgOwner())
 		}, orgAssignment(true))
 
 		m.Group("/admin", func() {
+			m.Group("/settings", func() {
+				m.Combo("").
+					Get(admin2.ListSettings).
+					Patch(admin2.EditSettings)
+				m.Get("/schema", admin2.GetSettingsSchema)
+			})
+
+			m.Group("/auth-sources", func() {
+				m.Combo("").
+					Get(admin2.ListAuthSources).
+					Post(bind(admin2.CreateAuthSourceOption{}), admin2.CreateAuthSource)
+				m.Combo("/:id").
+					Get(admin2.GetAuthSource).
+					Patch(bind(admin2.EditAuthSourceOption{}), admin2.EditAuthSource).
+					Delete(admin2.DeleteAuthSource)
+			})
+
 			m.Group("/users", func() {
 				m.Post("", bind(api.CreateUserOption{}), admin2.CreateUser)
 
@@ -380,7 +615,7 @@ func RegisterRoutes(m *macaron.Macaron) {
 						Delete(admin2.DeleteUser)
 					m.Post("/keys", bind(api.CreateKeyOption{}), admin2.CreatePublicKey)
 					m.Post("/orgs", bind(api.CreateOrgOption{}), admin2.CreateOrg)
-					m.Post("/repos", bind(api.CreateRepoOption{}), admin2.CreateRepo)
+					m.Post("/repos", bind(repo2.CreateRepoOption{}), admin2.CreateRepo)
 				})
 			})
 
@@ -402,6 +637,23 @@ func RegisterRoutes(m *macaron.Macaron) {
 			})
 		}, reqAdmin())
 
+		if conf.Federation.Enabled {
+			m.Group("/activitypub", func() {
+				m.Group("/user-id/:id", func() {
+					m.Get("", federation2.GetUser)
+					m.Post("/inbox", federation2.UserInbox)
+					m.Get("/outbox", federation2.UserOutbox)
+					m.Get("/followers", federation2.UserFollowers)
+				})
+				m.Group("/repo-id/:id", func() {
+					m.Get("", federation2.GetRepo)
+					m.Post("/inbox", federation2.RepoInbox)
+					m.Get("/outbox", federation2.RepoOutbox)
+					m.Get("/followers", federation2.RepoFollowers)
+				})
+			})
+		}
+
 		m.Any("/*", func(c *context.Context) {
 			c.NotFound()
 		})
@@ -81,6 +81,23 @@ func ToHook(repoLink string, w *db.Webhook) *api.Hook {
 		config["icon_url"] = s.IconURL
 		config["color"] = s.Color
 	}
+	switch w.HookTaskType {
+	case db.MATRIX:
+		m := w.GetMatrixHook()
+		config["homeserver_url"] = m.HomeserverURL
+		config["room_id"] = m.RoomID
+		config["message_type"] = m.MessageType
+	case db.TELEGRAM:
+		config["chat_id"] = w.GetTelegramHook().ChatID
+	}
+	if w.HookEvent != nil {
+		if w.BranchFilter != "" {
+			config["branch_filter"] = w.BranchFilter
+		}
+		if w.PathFilter != "" {
+			config["path_filter"] = w.PathFilter
+		}
+	}
 
 	return &api.Hook{
 		ID:      w.ID,
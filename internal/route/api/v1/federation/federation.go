@@ -0,0 +1,254 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package federation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	log "unknwon.dev/clog/v2"
+
+	"gogs.io/gogs/internal/activitypub"
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/db/errors"
+)
+
+// maxInboxSize is the max size of an activity posted to an inbox.
+const maxInboxSize = 1 << 20
+
+func renderActivity(c *context.APIContext, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		c.ServerError("json.Marshal", err)
+		return
+	}
+	c.Resp.Header().Set("Content-Type", activitypub.ContentType+"; charset=utf-8")
+	c.Resp.WriteHeader(http.StatusOK)
+	if _, err = c.Resp.Write(data); err != nil {
+		log.Error("Failed to write activity: %v", err)
+	}
+}
+
+// federatedUser returns the user of the ID in URL, it responds 404 if the user
+// cannot be followed from other instances.
+func federatedUser(c *context.APIContext) *db.User {
+	u, err := db.GetUserByID(c.ParamsInt64(":id"))
+	if err != nil {
+		c.NotFoundOrServerError("GetUserByID", errors.IsUserNotExist, err)
+		return nil
+	} else if !u.IsFederated() {
+		c.NotFound()
+		return nil
+	}
+	return u
+}
+
+// federatedRepo returns the repository of the ID in URL, it responds 404 if the
+// repository cannot be followed from other instances.
+func federatedRepo(c *context.APIContext) *db.Repository {
+	repo, err := db.GetRepositoryByID(c.ParamsInt64(":id"))
+	if err != nil {
+		c.NotFoundOrServerError("GetRepositoryByID", errors.IsRepoNotExist, err)
+		return nil
+	} else if !repo.IsFederated() {
+		c.NotFound()
+		return nil
+	}
+	return repo
+}
+
+// verifyRequest verifies the HTTP signature of the request and returns the remote actor
+// who signed it.
+func verifyRequest(c *context.APIContext, body []byte) (*activitypub.Actor, error) {
+	sig, err := activitypub.ParseSignature(c.Req.Header.Get("Signature"))
+	if err != nil {
+		return nil, fmt.Errorf("parse signature: %v", err)
+	}
+
+	remote, err := activitypub.FetchActor(sig.Owner())
+	if err != nil {
+		return nil, fmt.Errorf("fetch actor %q: %v", sig.Owner(), err)
+	} else if remote.PublicKey == nil || remote.PublicKey.ID != sig.KeyID {
+		return nil, fmt.Errorf("key %q is not found", sig.KeyID)
+	}
+
+	key, err := activitypub.ParsePublicKey(remote.PublicKey.PublicKeyPem)
+	if err != nil {
+		return nil, fmt.Errorf("parse public key: %v", err)
+	} else if err = activitypub.VerifyRequest(c.Req.Request, body, sig, key); err != nil {
+		return nil, fmt.Errorf("verify signature: %v", err)
+	}
+	return remote, nil
+}
+
+// handleInbox processes an activity posted to the inbox of the local actor. Only
+// following and unfollowing are supported, other activities are accepted and ignored.
+func handleInbox(c *context.APIContext, actorType db.FederationActorType, actorID int64, actorURL string) {
+	body, err := ioutil.ReadAll(io.LimitReader(c.Req.Request.Body, maxInboxSize))
+	if err != nil {
+		c.ServerError("read body", err)
+		return
+	}
+
+	activity := new(activitypub.Activity)
+	if err = json.Unmarshal(body, activity); err != nil {
+		c.Error(http.StatusBadRequest, "", fmt.Sprintf("decode activity: %v", err))
+		return
+	}
+
+	remote, err := verifyRequest(c, body)
+	if err != nil {
+		c.Error(http.StatusUnauthorized, "", err)
+		return
+	} else if activity.Actor != remote.ID {
+		c.Error(http.StatusUnauthorized, "", "actor is not the signer of the request")
+		return
+	}
+
+	switch activity.Type {
+	case activitypub.TypeFollow:
+		if activity.ObjectID() != actorURL {
+			c.Error(http.StatusUnprocessableEntity, "", "object is not this actor")
+			return
+		} else if remote.Inbox == "" {
+			c.Error(http.StatusUnprocessableEntity, "", "actor does not have an inbox")
+			return
+		}
+		if err = db.AcceptRemoteFollow(actorType, actorID, activity, remote); err != nil {
+			c.ServerError("AcceptRemoteFollow", err)
+			return
+		}
+
+	case activitypub.TypeUndo:
+		follow, err := activity.ObjectActivity()
+		if err != nil || follow.Type != activitypub.TypeFollow || follow.ObjectID() != actorURL {
+			break
+		}
+		if err = db.RemoveRemoteFollower(actorType, actorID, remote.ID); err != nil {
+			c.ServerError("RemoveRemoteFollower", err)
+			return
+		}
+	}
+
+	c.Status(http.StatusAccepted)
+}
+
+func renderOutbox(c *context.APIContext, actorType db.FederationActorType, actorID int64, actorURL string) {
+	actions, total, err := db.GetFederationActions(actorType, actorID)
+	if err != nil {
+		c.ServerError("GetFederationActions", err)
+		return
+	}
+
+	items := make([]*activitypub.Activity, len(actions))
+	for i := range actions {
+		if actorType == db.FEDERATION_ACTOR_REPO {
+			items[i], err = actions[i].FederationAnnounce()
+		} else {
+			items[i], err = actions[i].FederationActivity()
+		}
+		if err != nil {
+			c.ServerError("FederationActivity", err)
+			return
+		}
+	}
+	renderActivity(c, activitypub.NewOrderedCollection(actorURL+"/outbox", total, items))
+}
+
+func renderFollowers(c *context.APIContext, actorType db.FederationActorType, actorID int64, actorURL string) {
+	followers, err := db.GetRemoteFollowers(actorType, actorID)
+	if err != nil {
+		c.ServerError("GetRemoteFollowers", err)
+		return
+	}
+
+	items := make([]string, len(followers))
+	for i := range followers {
+		items[i] = followers[i].RemoteActor
+	}
+	renderActivity(c, activitypub.NewOrderedCollection(actorURL+"/followers", int64(len(items)), items))
+}
+
+// GetUser returns the actor document of a user or an organization.
+func GetUser(c *context.APIContext) {
+	u := federatedUser(c)
+	if c.Written() {
+		return
+	}
+
+	actor, err := u.FederationActor()
+	if err != nil {
+		c.ServerError("FederationActor", err)
+		return
+	}
+	renderActivity(c, actor)
+}
+
+func UserInbox(c *context.APIContext) {
+	u := federatedUser(c)
+	if c.Written() {
+		return
+	}
+	handleInbox(c, db.FEDERATION_ACTOR_USER, u.ID, u.FederationActorURL())
+}
+
+func UserOutbox(c *context.APIContext) {
+	u := federatedUser(c)
+	if c.Written() {
+		return
+	}
+	renderOutbox(c, db.FEDERATION_ACTOR_USER, u.ID, u.FederationActorURL())
+}
+
+func UserFollowers(c *context.APIContext) {
+	u := federatedUser(c)
+	if c.Written() {
+		return
+	}
+	renderFollowers(c, db.FEDERATION_ACTOR_USER, u.ID, u.FederationActorURL())
+}
+
+// GetRepo returns the actor document of a repository.
+func GetRepo(c *context.APIContext) {
+	repo := federatedRepo(c)
+	if c.Written() {
+		return
+	}
+
+	actor, err := repo.FederationActor()
+	if err != nil {
+		c.ServerError("FederationActor", err)
+		return
+	}
+	renderActivity(c, actor)
+}
+
+func RepoInbox(c *context.APIContext) {
+	repo := federatedRepo(c)
+	if c.Written() {
+		return
+	}
+	handleInbox(c, db.FEDERATION_ACTOR_REPO, repo.ID, repo.FederationActorURL())
+}
+
+func RepoOutbox(c *context.APIContext) {
+	repo := federatedRepo(c)
+	if c.Written() {
+		return
+	}
+	renderOutbox(c, db.FEDERATION_ACTOR_REPO, repo.ID, repo.FederationActorURL())
+}
+
+func RepoFollowers(c *context.APIContext) {
+	repo := federatedRepo(c)
+	if c.Written() {
+		return
+	}
+	renderFollowers(c, db.FEDERATION_ACTOR_REPO, repo.ID, repo.FederationActorURL())
+}
@@ -6,14 +6,64 @@ package misc
 
 import (
 	"net/http"
-
-	api "github.com/gogs/go-gogs-client"
+	"strings"
 
 	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/db/errors"
 	"gogs.io/gogs/internal/markup"
 )
 
-func Markdown(c *context.APIContext, form api.MarkdownOption) {
+// Rendering modes of Markdown.
+const (
+	// MARKDOWN_MODE_MARKDOWN renders plain Markdown without special links.
+	MARKDOWN_MODE_MARKDOWN = "markdown"
+	// MARKDOWN_MODE_GFM renders Markdown with issue references, mentions and commit
+	// links, which is the default.
+	MARKDOWN_MODE_GFM = "gfm"
+)
+
+type MarkdownOption struct {
+	Text string `json:"text"`
+	Mode string `json:"mode" binding:"OmitEmpty;In(markdown,gfm)"`
+	// Context is either the full name of a repository (e.g. "gogs/gogs") to render
+	// in context of the repository, or the URL prefix of relative links.
+	Context string `json:"context"`
+}
+
+// markdownContext returns the URL prefix and metas to render with. A context of the
+// full name of a repository is resolved to the repository, which must be readable by
+// the current user.
+func markdownContext(c *context.APIContext, ctx string) (urlPrefix string, metas map[string]string) {
+	fields := strings.Split(ctx, "/")
+	if strings.Contains(ctx, "://") || len(fields) != 2 || fields[0] == "" || fields[1] == "" {
+		return ctx, nil
+	}
+
+	owner, err := db.GetUserByName(fields[0])
+	if err != nil {
+		c.NotFoundOrServerError("GetUserByName", errors.IsUserNotExist, err)
+		return "", nil
+	}
+	repo, err := db.GetRepositoryByName(owner.ID, fields[1])
+	if err != nil {
+		c.NotFoundOrServerError("GetRepositoryByName", errors.IsRepoNotExist, err)
+		return "", nil
+	}
+	mode, err := db.UserAccessMode(c.UserID(), repo)
+	if err != nil {
+		c.ServerError("UserAccessMode", err)
+		return "", nil
+	} else if mode < db.ACCESS_MODE_READ {
+		c.NotFound()
+		return "", nil
+	}
+
+	repo.Owner = owner
+	return repo.Link(), repo.ComposeMetas()
+}
+
+func Markdown(c *context.APIContext, form MarkdownOption) {
 	if c.HasApiError() {
 		c.Error(http.StatusUnprocessableEntity, "", c.GetErrMsg())
 		return
@@ -24,7 +74,16 @@ func Markdown(c *context.APIContext, form api.MarkdownOption) {
 		return
 	}
 
-	_, _ = c.Write(markup.Markdown([]byte(form.Text), form.Context, nil))
+	urlPrefix, metas := markdownContext(c, form.Context)
+	if c.Written() {
+		return
+	}
+
+	if form.Mode == MARKDOWN_MODE_MARKDOWN {
+		_, _ = c.Write(markup.SanitizeBytes(markup.RawMarkdown([]byte(form.Text), urlPrefix)))
+		return
+	}
+	_, _ = c.Write(markup.Markdown([]byte(form.Text), urlPrefix, metas))
 }
 
 func MarkdownRaw(c *context.APIContext) {
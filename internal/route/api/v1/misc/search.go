@@ -0,0 +1,211 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package misc
+
+import (
+	"net/http"
+	"strings"
+
+	api "github.com/gogs/go-gogs-client"
+
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/markup"
+	"gogs.io/gogs/internal/route/api/v1/convert"
+)
+
+// IssueSearchResult is an issue or a pull request matched in search with its
+// repository.
+type IssueSearchResult struct {
+	*api.Issue
+	Repository *api.Repository `json:"repository"`
+}
+
+// CodeSearchLine is a line of a file in code search results.
+type CodeSearchLine struct {
+	Num     int    `json:"num"`
+	Content string `json:"content"`
+	IsMatch bool   `json:"is_match"`
+}
+
+// CodeSearchResult is a file matched in code search with matched lines.
+type CodeSearchResult struct {
+	Repository *api.Repository   `json:"repository"`
+	Path       string            `json:"path"`
+	Language   string            `json:"language"`
+	Lines      []*CodeSearchLine `json:"lines"`
+}
+
+// SearchResults is the response of searches of all types, data is a list of results
+// of the searched type.
+type SearchResults struct {
+	Type  string      `json:"type"`
+	Total int64       `json:"total"`
+	Page  int         `json:"page"`
+	Limit int         `json:"limit"`
+	Data  interface{} `json:"data"`
+}
+
+// Search serves searches of users, repositories, issues and code with the same
+// pagination, results only contain what the user has access to.
+func Search(c *context.APIContext) {
+	keyword := strings.TrimSpace(c.Query("q"))
+	if keyword == "" {
+		c.Error(http.StatusUnprocessableEntity, "", "Keyword cannot be empty")
+		return
+	}
+
+	results := &SearchResults{
+		Type:  c.QueryTrim("type"),
+		Page:  c.QueryInt("page"),
+		Limit: convert.ToCorrectPageSize(c.QueryInt("limit")),
+	}
+	if results.Page <= 0 {
+		results.Page = 1
+	}
+
+	var err error
+	switch results.Type {
+	case "users":
+		err = searchUsers(c, keyword, results)
+	case "", "repositories":
+		results.Type = "repositories"
+		err = searchRepositories(c, keyword, results)
+	case "issues":
+		err = searchIssues(c, keyword, results)
+	case "code":
+		if !db.IsCodeIndexerEnabled() {
+			c.Error(http.StatusUnprocessableEntity, "", "Code search is not enabled")
+			return
+		}
+		err = searchCode(c, keyword, results)
+	default:
+		c.Error(http.StatusUnprocessableEntity, "", "Unsupported search type")
+		return
+	}
+	if err != nil {
+		c.ServerError("search "+results.Type, err)
+		return
+	}
+
+	c.JSONSuccess(results)
+}
+
+func searchUsers(c *context.APIContext, keyword string, results *SearchResults) error {
+	opts := &db.SearchUserOptions{
+		Keyword:  keyword,
+		Type:     db.USER_TYPE_INDIVIDUAL,
+		OrderBy:  "id ASC",
+		Page:     results.Page,
+		PageSize: results.Limit,
+	}
+	users, count, err := db.SearchUserByName(opts)
+	if err != nil {
+		return err
+	}
+	// Page size of users is capped by the setting of explore pages.
+	results.Limit = opts.PageSize
+
+	apiUsers := make([]*api.User, len(users))
+	for i := range users {
+		apiUsers[i] = &api.User{
+			ID:        users[i].ID,
+			UserName:  users[i].Name,
+			AvatarUrl: users[i].AvatarLink(),
+			FullName:  markup.Sanitize(users[i].FullName),
+		}
+		if c.IsLogged {
+			apiUsers[i].Email = users[i].Email
+		}
+	}
+	results.Total = count
+	results.Data = apiUsers
+	return nil
+}
+
+func searchRepositories(c *context.APIContext, keyword string, results *SearchResults) error {
+	repos, count, err := db.SearchRepositoryByName(&db.SearchRepoOptions{
+		Keyword:  keyword,
+		Topic:    c.QueryTrim("topic"),
+		UserID:   c.UserID(),
+		OrderBy:  "updated_unix DESC",
+		Page:     results.Page,
+		PageSize: results.Limit,
+	})
+	if err != nil {
+		return err
+	} else if err = db.RepositoryList(repos).LoadAttributes(); err != nil {
+		return err
+	}
+
+	apiRepos := make([]*api.Repository, len(repos))
+	for i := range repos {
+		apiRepos[i] = repos[i].APIFormat(nil)
+	}
+	results.Total = count
+	results.Data = apiRepos
+	return nil
+}
+
+func searchIssues(c *context.APIContext, keyword string, results *SearchResults) error {
+	issues, count, err := db.SearchIssues(&db.SearchIssuesOptions{
+		Keyword:          keyword,
+		UserID:           c.UserID(),
+		Page:             results.Page,
+		PageSize:         results.Limit,
+		HideConfidential: !c.IsLogged || !c.User.IsAdmin,
+	})
+	if err != nil {
+		return err
+	}
+
+	apiIssues := make([]*IssueSearchResult, len(issues))
+	for i := range issues {
+		if err = issues[i].Repo.GetOwner(); err != nil {
+			return err
+		}
+		apiIssues[i] = &IssueSearchResult{
+			Issue:      issues[i].APIFormat(),
+			Repository: issues[i].Repo.APIFormat(nil),
+		}
+	}
+	results.Total = count
+	results.Data = apiIssues
+	return nil
+}
+
+func searchCode(c *context.APIContext, keyword string, results *SearchResults) error {
+	files, count, _, err := db.SearchCode(&db.SearchCodeOptions{
+		Keyword:  keyword,
+		UserID:   c.UserID(),
+		Language: c.Query("lang"),
+		Page:     results.Page,
+		PageSize: results.Limit,
+	})
+	if err != nil {
+		return err
+	}
+
+	apiFiles := make([]*CodeSearchResult, len(files))
+	for i, f := range files {
+		lines := make([]*CodeSearchLine, len(f.Lines))
+		for j, line := range f.Lines {
+			lines[j] = &CodeSearchLine{
+				Num:     line.Num,
+				Content: line.Content,
+				IsMatch: line.IsMatch,
+			}
+		}
+		apiFiles[i] = &CodeSearchResult{
+			Repository: f.Repo.APIFormat(nil),
+			Path:       f.Path,
+			Language:   f.Language,
+			Lines:      lines,
+		}
+	}
+	results.Total = count
+	results.Data = apiFiles
+	return nil
+}
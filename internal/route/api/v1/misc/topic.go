@@ -0,0 +1,37 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package misc
+
+import (
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/route/api/v1/convert"
+)
+
+// Topic is the API format of a topic with the number of repositories using it.
+type Topic struct {
+	Name      string `json:"name"`
+	RepoCount int    `json:"repo_count"`
+}
+
+// SearchTopics returns used topics that start with the keyword for autocompletion.
+func SearchTopics(c *context.APIContext) {
+	topics, err := db.SearchTopics(c.QueryTrim("q"), convert.ToCorrectPageSize(c.QueryInt("limit")))
+	if err != nil {
+		c.ServerError("SearchTopics", err)
+		return
+	}
+
+	apiTopics := make([]*Topic, len(topics))
+	for i := range topics {
+		apiTopics[i] = &Topic{
+			Name:      topics[i].Name,
+			RepoCount: topics[i].RepoCount,
+		}
+	}
+	c.JSONSuccess(map[string]interface{}{
+		"topics": apiTopics,
+	})
+}
@@ -0,0 +1,77 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package org
+
+import (
+	api "github.com/gogs/go-gogs-client"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/db/errors"
+	convert2 "gogs.io/gogs/internal/route/api/v1/convert"
+	repo2 "gogs.io/gogs/internal/route/api/v1/repo"
+)
+
+// orgLink returns the link of the organization that webhook URLs are based on.
+func orgLink(c *context.APIContext) string {
+	return conf.Server.Subpath + "/org/" + c.Org.Organization.Name
+}
+
+func getHookOfOrg(c *context.APIContext) *db.Webhook {
+	w, err := db.GetWebhookByOrgID(c.Org.Organization.ID, c.ParamsInt64(":id"))
+	if err != nil {
+		c.NotFoundOrServerError("GetWebhookByOrgID", errors.IsWebhookNotExist, err)
+		return nil
+	}
+	return w
+}
+
+func ListHooks(c *context.APIContext) {
+	hooks, err := db.GetWebhooksByOrgID(c.Org.Organization.ID)
+	if err != nil {
+		c.ServerError("GetWebhooksByOrgID", err)
+		return
+	}
+
+	apiHooks := make([]*api.Hook, len(hooks))
+	for i := range hooks {
+		apiHooks[i] = convert2.ToHook(orgLink(c), hooks[i])
+	}
+	c.JSONSuccess(&apiHooks)
+}
+
+func GetHook(c *context.APIContext) {
+	w := getHookOfOrg(c)
+	if c.Written() {
+		return
+	}
+	c.JSONSuccess(convert2.ToHook(orgLink(c), w))
+}
+
+func CreateHook(c *context.APIContext, form api.CreateHookOption) {
+	repo2.CreateWebhook(c, &db.Webhook{OrgID: c.Org.Organization.ID}, form, orgLink(c))
+}
+
+func EditHook(c *context.APIContext, form api.EditHookOption) {
+	w := getHookOfOrg(c)
+	if c.Written() {
+		return
+	}
+	repo2.EditWebhook(c, w, form, orgLink(c))
+}
+
+func DeleteHook(c *context.APIContext) {
+	w := getHookOfOrg(c)
+	if c.Written() {
+		return
+	}
+
+	if err := db.DeleteWebhookOfOrgByID(c.Org.Organization.ID, w.ID); err != nil {
+		c.ServerError("DeleteWebhookOfOrgByID", err)
+		return
+	}
+	c.NoContent()
+}
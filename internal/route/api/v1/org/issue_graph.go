@@ -0,0 +1,41 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package org
+
+import (
+	"net/http"
+
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+)
+
+// GetIssueGraph exports the reference graph of issues and pull requests of all
+// repositories of the organization that the user has access to, in the same formats
+// as the graph of a repository.
+func GetIssueGraph(c *context.APIContext) {
+	graph, err := db.GetIssueGraph(&db.IssueGraphOptions{
+		OwnerID: c.Org.Organization.ID,
+		UserID:  c.UserID(),
+	})
+	if err != nil {
+		c.ServerError("GetIssueGraph", err)
+		return
+	}
+
+	switch c.Query("format") {
+	case "", "json":
+		c.JSONSuccess(graph)
+	case "graphml":
+		data, err := graph.GraphML()
+		if err != nil {
+			c.ServerError("GraphML", err)
+			return
+		}
+		c.Header().Set("Content-Type", "application/graphml+xml; charset=utf-8")
+		_, _ = c.Write(data)
+	default:
+		c.Error(http.StatusUnprocessableEntity, "", "Unsupported format")
+	}
+}
@@ -0,0 +1,286 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package org
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	api "github.com/gogs/go-gogs-client"
+
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/db/errors"
+)
+
+type project struct {
+	ID          int64     `json:"id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	State       string    `json:"state"`
+	Created     time.Time `json:"created_at"`
+	Updated     time.Time `json:"updated_at"`
+}
+
+type projectIssue struct {
+	ID         int64         `json:"id"`
+	Number     int64         `json:"number"`
+	Title      string        `json:"title"`
+	State      api.StateType `json:"state"`
+	IsPull     bool          `json:"is_pull"`
+	Repository string        `json:"repository"`
+	Labels     []*api.Label  `json:"labels"`
+	URL        string        `json:"html_url"`
+}
+
+type projectCard struct {
+	ID       int64         `json:"id"`
+	ColumnID int64         `json:"column_id"`
+	Issue    *projectIssue `json:"issue,omitempty"`
+}
+
+type projectColumn struct {
+	ID    int64          `json:"id"`
+	Title string         `json:"title"`
+	Cards []*projectCard `json:"cards"`
+}
+
+type projectBoard struct {
+	*project
+	Columns []*projectColumn `json:"columns"`
+}
+
+type AddProjectCardOption struct {
+	ColumnID int64  `json:"column_id" binding:"Required"`
+	Repo     string `json:"repo" binding:"Required"`
+	Index    int64  `json:"index" binding:"Required"`
+}
+
+type MoveProjectCardOption struct {
+	ColumnID int64 `json:"column_id" binding:"Required"`
+}
+
+func toProject(p *db.Project) *project {
+	state := "open"
+	if p.IsClosed {
+		state = "closed"
+	}
+	return &project{
+		ID:          p.ID,
+		Title:       p.Title,
+		Description: p.Description,
+		State:       state,
+		Created:     p.Created,
+		Updated:     p.Updated,
+	}
+}
+
+func toProjectCard(card *db.ProjectCard) *projectCard {
+	apiCard := &projectCard{
+		ID:       card.ID,
+		ColumnID: card.ColumnID,
+	}
+	if issue := card.Issue; issue != nil {
+		apiLabels := make([]*api.Label, len(issue.Labels))
+		for i := range issue.Labels {
+			apiLabels[i] = issue.Labels[i].APIFormat()
+		}
+		apiCard.Issue = &projectIssue{
+			ID:         issue.ID,
+			Number:     issue.Index,
+			Title:      issue.Title,
+			State:      issue.State(),
+			IsPull:     issue.IsPull,
+			Repository: issue.Repo.FullName(),
+			Labels:     apiLabels,
+			URL:        issue.HTMLURL(),
+		}
+	}
+	return apiCard
+}
+
+func ListProjects(c *context.APIContext) {
+	projects, err := db.GetProjects(c.Org.Organization.ID, c.Query("state") == "closed")
+	if err != nil {
+		c.ServerError("GetProjects", err)
+		return
+	}
+
+	apiProjects := make([]*project, len(projects))
+	for i := range projects {
+		apiProjects[i] = toProject(projects[i])
+	}
+	c.JSONSuccess(apiProjects)
+}
+
+func getProjectByParams(c *context.APIContext) *db.Project {
+	p, err := db.GetProjectByID(c.Org.Organization.ID, c.ParamsInt64(":id"))
+	if err != nil {
+		c.NotFoundOrServerError("GetProjectByID", errors.IsProjectNotExist, err)
+		return nil
+	}
+	return p
+}
+
+// GetProjectBoard returns the project with its columns and cards, cards can be
+// filtered by repository and label name via query parameters "repo" and "label".
+func GetProjectBoard(c *context.APIContext) {
+	p := getProjectByParams(c)
+	if c.Written() {
+		return
+	}
+
+	opts := db.ProjectCardsOptions{
+		UserID: c.User.ID,
+		Label:  strings.TrimSpace(c.Query("label")),
+	}
+	if name := c.Query("repo"); name != "" {
+		repo, err := db.GetRepositoryByName(c.Org.Organization.ID, name)
+		if err != nil {
+			c.NotFoundOrServerError("GetRepositoryByName", errors.IsRepoNotExist, err)
+			return
+		}
+		opts.RepoID = repo.ID
+	}
+
+	columns, err := p.GetBoard(opts)
+	if err != nil {
+		c.ServerError("GetBoard", err)
+		return
+	}
+
+	board := &projectBoard{
+		project: toProject(p),
+		Columns: make([]*projectColumn, len(columns)),
+	}
+	for i, col := range columns {
+		apiCards := make([]*projectCard, len(col.Cards))
+		for j := range col.Cards {
+			apiCards[j] = toProjectCard(col.Cards[j])
+		}
+		board.Columns[i] = &projectColumn{
+			ID:    col.ID,
+			Title: col.Title,
+			Cards: apiCards,
+		}
+	}
+	c.JSONSuccess(board)
+}
+
+func AddProjectCard(c *context.APIContext, form AddProjectCardOption) {
+	p := getProjectByParams(c)
+	if c.Written() {
+		return
+	}
+
+	col, err := db.GetProjectColumnByID(p.ID, form.ColumnID)
+	if err != nil {
+		if errors.IsProjectColumnNotExist(err) {
+			c.Error(http.StatusUnprocessableEntity, "", err)
+		} else {
+			c.ServerError("GetProjectColumnByID", err)
+		}
+		return
+	}
+
+	repo, err := db.GetRepositoryByName(c.Org.Organization.ID, form.Repo)
+	if err != nil {
+		if errors.IsRepoNotExist(err) {
+			c.Error(http.StatusUnprocessableEntity, "", err)
+		} else {
+			c.ServerError("GetRepositoryByName", err)
+		}
+		return
+	}
+	if has, err := db.HasAccess(c.User.ID, repo, db.ACCESS_MODE_READ); err != nil {
+		c.ServerError("HasAccess", err)
+		return
+	} else if !has {
+		c.Error(http.StatusUnprocessableEntity, "", errors.RepoNotExist{ID: repo.ID})
+		return
+	}
+
+	issue, err := db.GetRawIssueByIndex(repo.ID, form.Index)
+	if err != nil {
+		if errors.IsIssueNotExist(err) {
+			c.Error(http.StatusUnprocessableEntity, "", err)
+		} else {
+			c.ServerError("GetRawIssueByIndex", err)
+		}
+		return
+	}
+	issue.Repo = repo
+
+	card, err := db.AddProjectCard(p, col, issue)
+	if err != nil {
+		if errors.IsProjectCardAlreadyExist(err) {
+			c.Error(http.StatusUnprocessableEntity, "", err)
+		} else {
+			c.ServerError("AddProjectCard", err)
+		}
+		return
+	}
+	if err = issue.LoadAttributes(); err != nil {
+		c.ServerError("LoadAttributes", err)
+		return
+	}
+	card.Issue = issue
+
+	c.JSON(http.StatusCreated, toProjectCard(card))
+}
+
+func getProjectCardByParams(c *context.APIContext, p *db.Project) *db.ProjectCard {
+	card, err := db.GetProjectCardByID(p.ID, c.ParamsInt64(":cardid"))
+	if err != nil {
+		c.NotFoundOrServerError("GetProjectCardByID", errors.IsProjectCardNotExist, err)
+		return nil
+	}
+	return card
+}
+
+func MoveProjectCard(c *context.APIContext, form MoveProjectCardOption) {
+	p := getProjectByParams(c)
+	if c.Written() {
+		return
+	}
+	card := getProjectCardByParams(c, p)
+	if c.Written() {
+		return
+	}
+
+	col, err := db.GetProjectColumnByID(p.ID, form.ColumnID)
+	if err != nil {
+		if errors.IsProjectColumnNotExist(err) {
+			c.Error(http.StatusUnprocessableEntity, "", err)
+		} else {
+			c.ServerError("GetProjectColumnByID", err)
+		}
+		return
+	}
+
+	if err = db.MoveProjectCard(card, col); err != nil {
+		c.ServerError("MoveProjectCard", err)
+		return
+	}
+	c.JSONSuccess(toProjectCard(card))
+}
+
+func DeleteProjectCard(c *context.APIContext) {
+	p := getProjectByParams(c)
+	if c.Written() {
+		return
+	}
+	card := getProjectCardByParams(c, p)
+	if c.Written() {
+		return
+	}
+
+	if err := db.DeleteProjectCard(card); err != nil {
+		c.ServerError("DeleteProjectCard", err)
+		return
+	}
+	c.NoContent()
+}
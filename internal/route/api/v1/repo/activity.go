@@ -0,0 +1,202 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package repo
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	api "github.com/gogs/go-gogs-client"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/unknwon/com"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/db/errors"
+	"gogs.io/gogs/internal/route/api/v1/convert"
+)
+
+type activityIssue struct {
+	Number int64  `json:"number"`
+	Title  string `json:"title"`
+	URL    string `json:"html_url"`
+}
+
+type activityCommit struct {
+	ID        string           `json:"id"`
+	Message   string           `json:"message"`
+	URL       string           `json:"url"`
+	Author    *api.PayloadUser `json:"author"`
+	Committer *api.PayloadUser `json:"committer"`
+	Timestamp time.Time        `json:"timestamp"`
+}
+
+type activity struct {
+	ID         int64             `json:"id"`
+	Type       string            `json:"type"`
+	Actor      *api.User         `json:"actor"`
+	RefName    string            `json:"ref_name,omitempty"`
+	Issue      *activityIssue    `json:"issue,omitempty"`
+	Commits    []*activityCommit `json:"commits,omitempty"`
+	CompareURL string            `json:"compare_url,omitempty"`
+	Content    string            `json:"content,omitempty"`
+	Created    time.Time         `json:"created_at"`
+}
+
+// activityConverter converts actions of the repository to activities, and caches
+// actors and issues to reduce queries.
+type activityConverter struct {
+	repo   *db.Repository
+	actors map[int64]*api.User
+	issues map[int64]*db.Issue
+}
+
+func (ac *activityConverter) actor(act *db.Action) (*api.User, error) {
+	if u, ok := ac.actors[act.ActUserID]; ok {
+		return u, nil
+	}
+
+	u, err := db.GetUserByID(act.ActUserID)
+	if err != nil {
+		if !errors.IsUserNotExist(err) {
+			return nil, fmt.Errorf("GetUserByID: %v", err)
+		}
+		ac.actors[act.ActUserID] = &api.User{UserName: act.ActUserName}
+	} else {
+		ac.actors[act.ActUserID] = u.APIFormat()
+	}
+	return ac.actors[act.ActUserID], nil
+}
+
+func (ac *activityConverter) issue(index int64) (*activityIssue, error) {
+	issue, ok := ac.issues[index]
+	if !ok {
+		var err error
+		issue, err = db.GetRawIssueByIndex(ac.repo.ID, index)
+		if err != nil && !errors.IsIssueNotExist(err) {
+			return nil, fmt.Errorf("GetRawIssueByIndex: %v", err)
+		}
+		ac.issues[index] = issue
+	}
+
+	link := "/issues/"
+	if issue != nil && issue.IsPull {
+		link = "/pulls/"
+	}
+	apiIssue := &activityIssue{
+		Number: index,
+		URL:    ac.repo.HTMLURL() + link + com.ToStr(index),
+	}
+	if issue != nil {
+		apiIssue.Title = issue.Title
+	}
+	return apiIssue, nil
+}
+
+func (ac *activityConverter) convert(act *db.Action) (_ *activity, err error) {
+	apiActivity := &activity{
+		ID:      act.ID,
+		Type:    act.OpType.Name(),
+		RefName: act.RefName,
+		Created: act.Created,
+	}
+	apiActivity.Actor, err = ac.actor(act)
+	if err != nil {
+		return nil, err
+	}
+
+	switch act.OpType {
+	case db.ACTION_COMMIT_REPO, db.ACTION_PUSH_TAG, db.ACTION_MIRROR_SYNC_PUSH:
+		push := db.NewPushCommits()
+		if act.Content != "" {
+			if err = jsoniter.Unmarshal([]byte(act.Content), push); err != nil {
+				return nil, fmt.Errorf("unmarshal commits of action %d: %v", act.ID, err)
+			}
+		}
+		apiActivity.Commits = make([]*activityCommit, len(push.Commits))
+		for i, c := range push.Commits {
+			apiActivity.Commits[i] = &activityCommit{
+				ID:      c.Sha1,
+				Message: c.Message,
+				URL:     ac.repo.HTMLURL() + "/commit/" + c.Sha1,
+				Author: &api.PayloadUser{
+					Name:  c.AuthorName,
+					Email: c.AuthorEmail,
+				},
+				Committer: &api.PayloadUser{
+					Name:  c.CommitterName,
+					Email: c.CommitterEmail,
+				},
+				Timestamp: c.Timestamp,
+			}
+		}
+		if push.CompareURL != "" {
+			apiActivity.CompareURL = conf.Server.ExternalURL + push.CompareURL
+		}
+
+	case db.ACTION_CREATE_ISSUE, db.ACTION_CREATE_PULL_REQUEST, db.ACTION_COMMENT_ISSUE,
+		db.ACTION_MERGE_PULL_REQUEST, db.ACTION_CLOSE_ISSUE, db.ACTION_REOPEN_ISSUE,
+		db.ACTION_CLOSE_PULL_REQUEST, db.ACTION_REOPEN_PULL_REQUEST:
+		infos := act.GetIssueInfos()
+		apiActivity.Issue, err = ac.issue(com.StrTo(infos[0]).MustInt64())
+		if err != nil {
+			return nil, err
+		}
+		if act.OpType == db.ACTION_COMMENT_ISSUE && len(infos) > 1 {
+			apiActivity.Content = infos[1]
+		}
+
+	case db.ACTION_RENAME_REPO, db.ACTION_TRANSFER_REPO, db.ACTION_PUBLISH_RELEASE:
+		apiActivity.Content = act.Content
+	}
+	return apiActivity, nil
+}
+
+// ListActivities returns activities of the repository latest first, which can be
+// filtered by type of "commits", "issues" or "releases". Pages are navigated by the
+// cursor "before" that is the ID of the last activity of previous page, and the link
+// to next page is given in the "Link" header.
+func ListActivities(c *context.APIContext) {
+	activityType := c.Query("type")
+	if !db.IsValidActivityType(activityType) {
+		c.Error(http.StatusUnprocessableEntity, "", fmt.Sprintf("Invalid activity type %q", activityType))
+		return
+	}
+	limit := convert.ToCorrectPageSize(c.QueryInt("limit"))
+
+	actions, err := db.GetRepositoryActivities(c.Repo.Repository.ID, activityType, c.QueryInt64("before"), limit, c.Repo.IsTriager())
+	if err != nil {
+		c.ServerError("GetRepositoryActivities", err)
+		return
+	}
+
+	ac := &activityConverter{
+		repo:   c.Repo.Repository,
+		actors: make(map[int64]*api.User),
+		issues: make(map[int64]*db.Issue),
+	}
+	activities := make([]*activity, len(actions))
+	for i := range actions {
+		activities[i], err = ac.convert(actions[i])
+		if err != nil {
+			c.ServerError("convert", err)
+			return
+		}
+	}
+
+	if len(actions) == limit {
+		query := url.Values{}
+		if activityType != "" {
+			query.Set("type", activityType)
+		}
+		query.Set("limit", com.ToStr(limit))
+		query.Set("before", com.ToStr(actions[len(actions)-1].ID))
+		c.Header().Set("Link", fmt.Sprintf("<%s%s?%s>; rel=\"next\"", conf.Server.ExternalURL, c.Req.URL.Path[1:], query.Encode()))
+	}
+	c.JSONSuccess(&activities)
+}
@@ -12,6 +12,23 @@ import (
 	"gogs.io/gogs/internal/db/errors"
 )
 
+// collaborator extends api.Collaborator with the role of the collaborator, i.e. one of
+// "issues", "read", "triage", "write", "maintain" and "admin".
+type collaborator struct {
+	*api.Collaborator
+	Permission string `json:"permission"`
+}
+
+// issuesOnlyRole is the role of collaborators who can only participate in issues.
+const issuesOnlyRole = "issues"
+
+func collaboratorRole(c *db.Collaboration) string {
+	if c.IssuesOnly {
+		return issuesOnlyRole
+	}
+	return c.Mode.String()
+}
+
 func ListCollaborators(c *context.APIContext) {
 	collaborators, err := c.Repo.Repository.GetCollaborators()
 	if err != nil {
@@ -19,9 +36,12 @@ func ListCollaborators(c *context.APIContext) {
 		return
 	}
 
-	apiCollaborators := make([]*api.Collaborator, len(collaborators))
+	apiCollaborators := make([]*collaborator, len(collaborators))
 	for i := range collaborators {
-		apiCollaborators[i] = collaborators[i].APIFormat()
+		apiCollaborators[i] = &collaborator{
+			Collaborator: collaborators[i].APIFormat(),
+			Permission:   collaboratorRole(collaborators[i].Collaboration),
+		}
 	}
 	c.JSONSuccess(&apiCollaborators)
 }
@@ -42,7 +62,12 @@ func AddCollaborator(c *context.APIContext, form api.AddCollaboratorOption) {
 		return
 	}
 
-	if form.Permission != nil {
+	if form.Permission != nil && *form.Permission == issuesOnlyRole {
+		if err := c.Repo.Repository.ChangeCollaborationToIssuesOnly(collaborator.ID); err != nil {
+			c.Error(500, "ChangeCollaborationToIssuesOnly", err)
+			return
+		}
+	} else if form.Permission != nil {
 		if err := c.Repo.Repository.ChangeCollaborationAccessMode(collaborator.ID, db.ParseAccessMode(*form.Permission)); err != nil {
 			c.Error(500, "ChangeCollaborationAccessMode", err)
 			return
@@ -0,0 +1,89 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package repo
+
+import (
+	"net/http"
+
+	"github.com/gogs/git-module"
+	api "github.com/gogs/go-gogs-client"
+	convert2 "gogs.io/gogs/internal/route/api/v1/convert"
+
+	"gogs.io/gogs/internal/context"
+)
+
+type TagContributor struct {
+	Name    string    `json:"name"`
+	Email   string    `json:"email"`
+	User    *api.User `json:"user"`
+	Commits int       `json:"commits"`
+}
+
+type TagComparison struct {
+	From         string               `json:"from"`
+	FromSHA      string               `json:"from_sha"`
+	To           string               `json:"to"`
+	ToSHA        string               `json:"to_sha"`
+	MergeBase    string               `json:"merge_base"`
+	TotalCommits int                  `json:"total_commits"`
+	Commits      []*api.PayloadCommit `json:"commits"`
+	PullRequests []*api.PullRequest   `json:"pull_requests"`
+	Contributors []*TagContributor    `json:"contributors"`
+}
+
+// CompareTags returns commits, merged pull requests and contributors that the "to"
+// tag has since the "from" tag.
+func CompareTags(c *context.APIContext) {
+	gitRepo, err := git.OpenRepository(c.Repo.Repository.RepoPath())
+	if err != nil {
+		c.ServerError("OpenRepository", err)
+		return
+	}
+
+	from, to := c.Params(":from"), c.Params(":to")
+	cmp, err := c.Repo.Repository.CompareRefs(gitRepo, git.TAG_PREFIX+from, git.TAG_PREFIX+to)
+	if err != nil {
+		if git.IsErrNotExist(err) {
+			c.NotFound()
+		} else if _, ok := err.(git.ErrNoMergeBase); ok {
+			c.Error(http.StatusUnprocessableEntity, "", "tags have no common ancestor")
+		} else {
+			c.ServerError("CompareRefs", err)
+		}
+		return
+	}
+
+	apiCommits := make([]*api.PayloadCommit, len(cmp.Commits))
+	for i := range cmp.Commits {
+		apiCommits[i] = convert2.ToCommit(cmp.Commits[i])
+	}
+	apiPullRequests := make([]*api.PullRequest, len(cmp.PullRequests))
+	for i := range cmp.PullRequests {
+		apiPullRequests[i] = cmp.PullRequests[i].APIFormat()
+	}
+	apiContributors := make([]*TagContributor, len(cmp.Contributors))
+	for i, contributor := range cmp.Contributors {
+		apiContributors[i] = &TagContributor{
+			Name:    contributor.Name,
+			Email:   contributor.Email,
+			Commits: contributor.Commits,
+		}
+		if contributor.User != nil {
+			apiContributors[i].User = contributor.User.APIFormat()
+		}
+	}
+
+	c.JSONSuccess(&TagComparison{
+		From:         from,
+		FromSHA:      cmp.FromCommitID,
+		To:           to,
+		ToSHA:        cmp.ToCommitID,
+		MergeBase:    cmp.MergeBase,
+		TotalCommits: len(cmp.Commits),
+		Commits:      apiCommits,
+		PullRequests: apiPullRequests,
+		Contributors: apiContributors,
+	})
+}
@@ -0,0 +1,452 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package repo
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gogs/git-module"
+	api "github.com/gogs/go-gogs-client"
+
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+)
+
+// maxContentsSize is the max size of a file whose content is included in the response,
+// content of larger files has to be downloaded through the raw API.
+const maxContentsSize = 1 << 20
+
+type ContentsResponse struct {
+	Type        string `json:"type"`
+	Encoding    string `json:"encoding,omitempty"`
+	Size        int64  `json:"size"`
+	Name        string `json:"name"`
+	Path        string `json:"path"`
+	Content     string `json:"content,omitempty"`
+	SHA         string `json:"sha"`
+	URL         string `json:"url"`
+	HTMLURL     string `json:"html_url"`
+	DownloadURL string `json:"download_url,omitempty"`
+}
+
+type FileCommitResponse struct {
+	SHA       string          `json:"sha"`
+	URL       string          `json:"url"`
+	HTMLURL   string          `json:"html_url"`
+	Author    *api.CommitUser `json:"author"`
+	Committer *api.CommitUser `json:"committer"`
+	Message   string          `json:"message"`
+}
+
+type FileResponse struct {
+	Content *ContentsResponse   `json:"content"`
+	Commit  *FileCommitResponse `json:"commit"`
+}
+
+type CommitIdentity struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type CreateOrUpdateFileOption struct {
+	Message string `json:"message" binding:"Required"`
+	// Content is the new file content encoded in Base64.
+	Content string `json:"content"`
+	// SHA is the blob SHA of the file being replaced, it is required when the file exists.
+	SHA       string          `json:"sha"`
+	Branch    string          `json:"branch"`
+	Committer *CommitIdentity `json:"committer"`
+	Author    *CommitIdentity `json:"author"`
+}
+
+type DeleteFileOption struct {
+	Message   string          `json:"message"`
+	SHA       string          `json:"sha"`
+	Branch    string          `json:"branch"`
+	Committer *CommitIdentity `json:"committer"`
+	Author    *CommitIdentity `json:"author"`
+}
+
+// contentsTreePath returns the cleaned tree path in URL.
+func contentsTreePath(c *context.APIContext) string {
+	return strings.TrimPrefix(path.Clean("/"+c.Params("*")), "/")
+}
+
+// openGitRepo opens the Git repository for handlers which are not behind context.RepoRef.
+func openGitRepo(c *context.APIContext) {
+	gitRepo, err := git.OpenRepository(c.Repo.Repository.RepoPath())
+	if err != nil {
+		c.ServerError("OpenRepository", err)
+		return
+	}
+	c.Repo.GitRepo = gitRepo
+}
+
+// contentsCommit returns the commit of the branch, tag or commit ID.
+func contentsCommit(c *context.APIContext, ref string) *git.Commit {
+	var commit *git.Commit
+	var err error
+	switch {
+	case c.Repo.GitRepo.IsBranchExist(ref):
+		commit, err = c.Repo.GitRepo.GetBranchCommit(ref)
+	case c.Repo.GitRepo.IsTagExist(ref):
+		commit, err = c.Repo.GitRepo.GetTagCommit(ref)
+	case len(ref) >= 7 && !strings.HasPrefix(ref, "-"):
+		commit, err = c.Repo.GitRepo.GetCommit(ref)
+	default:
+		c.NotFound()
+		return nil
+	}
+	if err != nil {
+		c.NotFoundOrServerError("get commit", git.IsErrNotExist, err)
+		return nil
+	}
+	return commit
+}
+
+func toContentsResponse(c *context.APIContext, entry *git.TreeEntry, treePath, ref string) *ContentsResponse {
+	repo := c.Repo.Repository
+	contents := &ContentsResponse{
+		Type:    "file",
+		Name:    path.Base(treePath),
+		Path:    treePath,
+		SHA:     entry.ID.String(),
+		URL:     fmt.Sprintf("%s/repos/%s/contents/%s?ref=%s", c.BaseURL, repo.FullName(), treePath, ref),
+		HTMLURL: fmt.Sprintf("%s/src/%s/%s", repo.HTMLURL(), ref, treePath),
+	}
+	switch {
+	case entry.IsDir():
+		contents.Type = "dir"
+		return contents
+	case entry.IsSubModule():
+		contents.Type = "submodule"
+		return contents
+	case entry.IsLink():
+		contents.Type = "symlink"
+	}
+	contents.Size = entry.Size()
+	contents.DownloadURL = fmt.Sprintf("%s/raw/%s/%s", repo.HTMLURL(), ref, treePath)
+	return contents
+}
+
+// fillContent reads and encodes the content of the file, it is skipped for large files.
+func fillContent(contents *ContentsResponse, entry *git.TreeEntry) error {
+	if contents.Size > maxContentsSize {
+		contents.Encoding = "none"
+		return nil
+	}
+
+	r, err := entry.Blob().Data()
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	contents.Encoding = "base64"
+	contents.Content = base64.StdEncoding.EncodeToString(data)
+	return nil
+}
+
+// GetContents returns the content of a file or the entries of a directory.
+func GetContents(c *context.APIContext) {
+	if c.Repo.Repository.IsBare {
+		c.NotFound()
+		return
+	}
+
+	openGitRepo(c)
+	if c.Written() {
+		return
+	}
+
+	ref := c.Query("ref")
+	if ref == "" {
+		ref = c.Repo.Repository.DefaultBranch
+	}
+	commit := contentsCommit(c, ref)
+	if c.Written() {
+		return
+	}
+
+	treePath := contentsTreePath(c)
+	entry, err := commit.GetTreeEntryByPath(treePath)
+	if err != nil {
+		c.NotFoundOrServerError("GetTreeEntryByPath", git.IsErrNotExist, err)
+		return
+	}
+
+	if !entry.IsDir() {
+		contents := toContentsResponse(c, entry, treePath, ref)
+		if contents.Type == "file" || contents.Type == "symlink" {
+			if err = fillContent(contents, entry); err != nil {
+				c.ServerError("fillContent", err)
+				return
+			}
+		}
+		c.JSONSuccess(contents)
+		return
+	}
+
+	tree, err := commit.SubTree(treePath)
+	if err != nil {
+		c.ServerError("SubTree", err)
+		return
+	}
+	entries, err := tree.ListEntries()
+	if err != nil {
+		c.ServerError("ListEntries", err)
+		return
+	}
+	list := make([]*ContentsResponse, len(entries))
+	for i := range entries {
+		list[i] = toContentsResponse(c, entries[i], path.Join(treePath, entries[i].Name()), ref)
+	}
+	c.JSONSuccess(&list)
+}
+
+// toSignature returns the signature of the identity, or nil if the identity is not given.
+func toSignature(identity *CommitIdentity) (*git.Signature, error) {
+	if identity == nil {
+		return nil, nil
+	} else if identity.Name == "" || !strings.Contains(identity.Email, "@") {
+		return nil, fmt.Errorf("name and email are required for committer and author")
+	}
+	return &git.Signature{
+		Name:  identity.Name,
+		Email: identity.Email,
+		When:  time.Now(),
+	}, nil
+}
+
+// contentsBranch returns the branch to commit changes to and the commit it points to,
+// it makes sure the context user is allowed to push to the branch directly.
+func contentsBranch(c *context.APIContext, branch string) (string, *git.Commit) {
+	if !c.Repo.Repository.CanEnableEditor() {
+		c.Error(http.StatusUnprocessableEntity, "", "repository is not editable")
+		return "", nil
+	} else if c.Repo.Repository.IsBare {
+		c.Error(http.StatusUnprocessableEntity, "", "repository is empty")
+		return "", nil
+	}
+
+	openGitRepo(c)
+	if c.Written() {
+		return "", nil
+	}
+
+	if branch == "" {
+		branch = c.Repo.Repository.DefaultBranch
+	}
+	if !c.Repo.GitRepo.IsBranchExist(branch) {
+		c.Error(http.StatusNotFound, "", "branch does not exist")
+		return "", nil
+	} else if !c.Repo.Repository.CanPushToBranch(c.User.ID, branch) {
+		c.Error(http.StatusForbidden, "", "branch is protected")
+		return "", nil
+	}
+
+	commit, err := c.Repo.GitRepo.GetBranchCommit(branch)
+	if err != nil {
+		c.ServerError("GetBranchCommit", err)
+		return "", nil
+	}
+	return branch, commit
+}
+
+func toFileCommitResponse(c *context.APIContext, commit *git.Commit) *FileCommitResponse {
+	return &FileCommitResponse{
+		SHA:     commit.ID.String(),
+		URL:     c.BaseURL + "/repos/" + c.Repo.Repository.FullName() + "/commits/" + commit.ID.String(),
+		HTMLURL: c.Repo.Repository.HTMLURL() + "/commit/" + commit.ID.String(),
+		Author: &api.CommitUser{
+			Name:  commit.Author.Name,
+			Email: commit.Author.Email,
+			Date:  commit.Author.When.Format(time.RFC3339),
+		},
+		Committer: &api.CommitUser{
+			Name:  commit.Committer.Name,
+			Email: commit.Committer.Email,
+			Date:  commit.Committer.When.Format(time.RFC3339),
+		},
+		Message: commit.Message(),
+	}
+}
+
+// renderFileResponse responds with the file and the latest commit of the branch.
+func renderFileResponse(c *context.APIContext, status int, branch, treePath string) {
+	commit, err := c.Repo.GitRepo.GetBranchCommit(branch)
+	if err != nil {
+		c.ServerError("GetBranchCommit", err)
+		return
+	}
+
+	var contents *ContentsResponse
+	if treePath != "" {
+		entry, err := commit.GetTreeEntryByPath(treePath)
+		if err != nil {
+			c.ServerError("GetTreeEntryByPath", err)
+			return
+		}
+		contents = toContentsResponse(c, entry, treePath, branch)
+	}
+	c.JSON(status, &FileResponse{
+		Content: contents,
+		Commit:  toFileCommitResponse(c, commit),
+	})
+}
+
+// PutContents creates a new file or updates an existing file.
+func PutContents(c *context.APIContext, form CreateOrUpdateFileOption) {
+	treePath := contentsTreePath(c)
+	if treePath == "" {
+		c.Error(http.StatusUnprocessableEntity, "", "path is required")
+		return
+	}
+
+	content, err := base64.StdEncoding.DecodeString(strings.Replace(form.Content, "\n", "", -1))
+	if err != nil {
+		c.Error(http.StatusUnprocessableEntity, "", "content is not valid Base64")
+		return
+	}
+	committer, err := toSignature(form.Committer)
+	if err != nil {
+		c.Error(http.StatusUnprocessableEntity, "", err)
+		return
+	}
+	author, err := toSignature(form.Author)
+	if err != nil {
+		c.Error(http.StatusUnprocessableEntity, "", err)
+		return
+	}
+
+	branch, commit := contentsBranch(c, form.Branch)
+	if c.Written() {
+		return
+	}
+
+	// Parent directories must not be files or symlinks.
+	parts := strings.Split(treePath, "/")
+	for i := 1; i < len(parts); i++ {
+		entry, err := commit.GetTreeEntryByPath(strings.Join(parts[:i], "/"))
+		if err != nil {
+			if git.IsErrNotExist(err) {
+				break
+			}
+			c.ServerError("GetTreeEntryByPath", err)
+			return
+		} else if !entry.IsDir() {
+			c.Error(http.StatusUnprocessableEntity, "", fmt.Sprintf("%q is not a directory", entry.Name()))
+			return
+		}
+	}
+
+	isNewFile := false
+	entry, err := commit.GetTreeEntryByPath(treePath)
+	if err != nil {
+		if !git.IsErrNotExist(err) {
+			c.ServerError("GetTreeEntryByPath", err)
+			return
+		}
+		isNewFile = true
+	} else if entry.IsDir() || entry.IsSubModule() || entry.IsLink() {
+		c.Error(http.StatusUnprocessableEntity, "", "path is not a regular file")
+		return
+	} else if form.SHA == "" {
+		c.Error(http.StatusUnprocessableEntity, "", "sha is required to update an existing file")
+		return
+	} else if form.SHA != entry.ID.String() {
+		c.Error(http.StatusConflict, "", "sha does not match the file")
+		return
+	}
+
+	if err = c.Repo.Repository.UpdateRepoFile(c.User, db.UpdateRepoFileOptions{
+		LastCommitID: commit.ID.String(),
+		OldBranch:    branch,
+		NewBranch:    branch,
+		OldTreeName:  treePath,
+		NewTreeName:  treePath,
+		Message:      form.Message,
+		Content:      string(content),
+		IsNewFile:    isNewFile,
+		Author:       author,
+		Committer:    committer,
+	}); err != nil {
+		if db.IsErrRepoFileAlreadyExist(err) {
+			c.Error(http.StatusConflict, "", "file already exists")
+		} else {
+			c.ServerError("UpdateRepoFile", err)
+		}
+		return
+	}
+
+	status := http.StatusOK
+	if isNewFile {
+		status = http.StatusCreated
+	}
+	renderFileResponse(c, status, branch, treePath)
+}
+
+// DeleteContents deletes an existing file.
+func DeleteContents(c *context.APIContext, form DeleteFileOption) {
+	treePath := contentsTreePath(c)
+	if treePath == "" {
+		c.Error(http.StatusUnprocessableEntity, "", "path is required")
+		return
+	} else if form.Message == "" || form.SHA == "" {
+		c.Error(http.StatusUnprocessableEntity, "", "message and sha are required")
+		return
+	}
+
+	committer, err := toSignature(form.Committer)
+	if err != nil {
+		c.Error(http.StatusUnprocessableEntity, "", err)
+		return
+	}
+	author, err := toSignature(form.Author)
+	if err != nil {
+		c.Error(http.StatusUnprocessableEntity, "", err)
+		return
+	}
+
+	branch, commit := contentsBranch(c, form.Branch)
+	if c.Written() {
+		return
+	}
+
+	entry, err := commit.GetTreeEntryByPath(treePath)
+	if err != nil {
+		c.NotFoundOrServerError("GetTreeEntryByPath", git.IsErrNotExist, err)
+		return
+	} else if entry.IsDir() || entry.IsSubModule() {
+		c.Error(http.StatusUnprocessableEntity, "", "path is not a file")
+		return
+	} else if form.SHA != entry.ID.String() {
+		c.Error(http.StatusConflict, "", "sha does not match the file")
+		return
+	}
+
+	if err = c.Repo.Repository.DeleteRepoFile(c.User, db.DeleteRepoFileOptions{
+		LastCommitID: commit.ID.String(),
+		OldBranch:    branch,
+		NewBranch:    branch,
+		TreePath:     treePath,
+		Message:      form.Message,
+		Author:       author,
+		Committer:    committer,
+	}); err != nil {
+		c.ServerError("DeleteRepoFile", err)
+		return
+	}
+
+	renderFileResponse(c, http.StatusOK, branch, "")
+}
@@ -0,0 +1,112 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package repo
+
+import (
+	"net/http"
+	"time"
+
+	api "github.com/gogs/go-gogs-client"
+	convert2 "gogs.io/gogs/internal/route/api/v1/convert"
+
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/db/errors"
+)
+
+type DeletedBranch struct {
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	Commit    string     `json:"commit"`
+	DeletedBy *api.User  `json:"deleted_by"`
+	Deleted   time.Time  `json:"deleted_at"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func toDeletedBranch(b *db.DeletedBranch) *DeletedBranch {
+	apiBranch := &DeletedBranch{
+		ID:        b.ID,
+		Name:      b.Name,
+		Commit:    b.Commit,
+		DeletedBy: b.DeletedBy.APIFormat(),
+		Deleted:   b.Deleted,
+	}
+	if expiresAt := b.ExpiresAt(); !expiresAt.IsZero() {
+		apiBranch.ExpiresAt = &expiresAt
+	}
+	return apiBranch
+}
+
+func getDeletedBranchByParams(c *context.APIContext) *db.DeletedBranch {
+	b, err := db.GetDeletedBranchByID(c.Repo.Repository.ID, c.ParamsInt64(":id"))
+	if err != nil {
+		c.NotFoundOrServerError("GetDeletedBranchByID", errors.IsDeletedBranchNotExist, err)
+		return nil
+	}
+	return b
+}
+
+func ListDeletedBranches(c *context.APIContext) {
+	branches, err := db.GetDeletedBranches(c.Repo.Repository.ID)
+	if err != nil {
+		c.ServerError("GetDeletedBranches", err)
+		return
+	}
+
+	apiBranches := make([]*DeletedBranch, len(branches))
+	for i := range branches {
+		apiBranches[i] = toDeletedBranch(branches[i])
+	}
+	c.JSONSuccess(&apiBranches)
+}
+
+func GetDeletedBranch(c *context.APIContext) {
+	b := getDeletedBranchByParams(c)
+	if c.Written() {
+		return
+	}
+	c.JSONSuccess(toDeletedBranch(b))
+}
+
+func RestoreDeletedBranch(c *context.APIContext) {
+	b := getDeletedBranchByParams(c)
+	if c.Written() {
+		return
+	}
+
+	if err := c.Repo.Repository.RestoreDeletedBranch(c.User, b); err != nil {
+		if errors.IsBranchAlreadyExists(err) {
+			c.Error(http.StatusConflict, "", err)
+		} else {
+			c.ServerError("RestoreDeletedBranch", err)
+		}
+		return
+	}
+
+	branch, err := c.Repo.Repository.GetBranch(b.Name)
+	if err != nil {
+		c.ServerError("GetBranch", err)
+		return
+	}
+	commit, err := branch.GetCommit()
+	if err != nil {
+		c.ServerError("GetCommit", err)
+		return
+	}
+	c.JSON(http.StatusCreated, convert2.ToBranch(branch, commit))
+}
+
+func PurgeDeletedBranch(c *context.APIContext) {
+	b := getDeletedBranchByParams(c)
+	if c.Written() {
+		return
+	}
+
+	if err := c.Repo.Repository.PurgeDeletedBranch(b); err != nil {
+		c.ServerError("PurgeDeletedBranch", err)
+		return
+	}
+	c.NoContent()
+}
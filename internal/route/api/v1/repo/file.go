@@ -5,11 +5,18 @@
 package repo
 
 import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
 	"github.com/gogs/git-module"
+	log "unknwon.dev/clog/v2"
 
 	"gogs.io/gogs/internal/context"
 	"gogs.io/gogs/internal/db"
 	"gogs.io/gogs/internal/route/repo"
+	"gogs.io/gogs/internal/tool"
 )
 
 func GetRawFile(c *context.APIContext) {
@@ -33,16 +40,69 @@ func GetRawFile(c *context.APIContext) {
 	}
 }
 
+var archiveContentTypes = map[string]string{
+	db.ARCHIVE_FORMAT_ZIP:    "application/zip",
+	db.ARCHIVE_FORMAT_TAR:    "application/x-tar",
+	db.ARCHIVE_FORMAT_TAR_GZ: "application/gzip",
+}
+
+// GetArchive streams an archive of the reference in the form of "<ref>.<format>",
+// limited to the subdirectory given by "path" if any. The commit, format and path
+// identify the archive as its ETag, so clients can skip downloading it again.
 func GetArchive(c *context.APIContext) {
-	repoPath := db.RepoPath(c.Params(":username"), c.Params(":reponame"))
-	gitRepo, err := git.OpenRepository(repoPath)
+	if c.Repo.Repository.IsBare {
+		c.NotFound()
+		return
+	}
+
+	ref, format, ok := db.ParseArchiveName(c.Params("*"))
+	if !ok {
+		c.NotFound()
+		return
+	}
+
+	repoPath := c.Repo.Repository.RepoPath()
+	commitID, err := db.RevParseObject(repoPath, ref, git.ObjectCommit)
 	if err != nil {
-		c.ServerError("OpenRepository", err)
+		c.NotFoundOrServerError("RevParseObject", git.IsErrNotExist, err)
+		return
+	}
+
+	treePath := strings.Trim(path.Clean("/"+c.Query("path")), "/")
+	if treePath != "" {
+		if _, err = db.RevParseObject(repoPath, commitID+":"+treePath, git.ObjectTree); err != nil {
+			c.NotFoundOrServerError("RevParseObject", git.IsErrNotExist, err)
+			return
+		}
+	}
+
+	etag := `"` + tool.SHA1(commitID+":"+format+":"+treePath) + `"`
+	c.Resp.Header().Set("ETag", etag)
+	c.Resp.Header().Set("Cache-Control", "private, max-age=0, must-revalidate")
+	if strings.Contains(c.Req.Header.Get("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
 		return
 	}
-	c.Repo.GitRepo = gitRepo
 
-	repo.Download(c.Context)
+	name := c.Repo.Repository.Name + "-" + ref
+	if treePath != "" {
+		name += "-" + treePath
+	}
+	name = strings.NewReplacer("/", "-", `"`, "").Replace(name)
+	c.Resp.Header().Set("Content-Type", archiveContentTypes[format])
+	c.Resp.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, name, format))
+	if err = c.Repo.Repository.WriteArchive(c.Resp, db.ArchiveOptions{
+		CommitID: commitID,
+		Format:   format,
+		Path:     treePath,
+	}); err != nil {
+		// The response may have been partially written, nothing else can be done.
+		log.Error("Failed to write archive of repository %d: %v", c.Repo.Repository.ID, err)
+	}
+}
+
+func GetBundle(c *context.APIContext) {
+	repo.DownloadBundle(c.Context)
 }
 
 func GetEditorconfig(c *context.APIContext) {
@@ -0,0 +1,233 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package repo
+
+import (
+	"encoding/base64"
+	"time"
+
+	"github.com/gogs/git-module"
+	api "github.com/gogs/go-gogs-client"
+
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+)
+
+// maxGitTreeEntries is the max number of entries returned for a tree, the
+// response is truncated when a recursive tree has more entries.
+const maxGitTreeEntries = 100000
+
+type repoGitTree struct {
+	Sha       string              `json:"sha"`
+	URL       string              `json:"url"`
+	Tree      []*repoGitTreeEntry `json:"tree"`
+	Truncated bool                `json:"truncated"`
+}
+
+type repoGitTreeEntry struct {
+	Path string `json:"path"`
+	Mode string `json:"mode"`
+	Type string `json:"type"`
+	Size int64  `json:"size"`
+	Sha  string `json:"sha"`
+	URL  string `json:"url,omitempty"`
+}
+
+type repoGitBlob struct {
+	Sha      string `json:"sha"`
+	URL      string `json:"url"`
+	Size     int64  `json:"size"`
+	Encoding string `json:"encoding"`
+	Content  string `json:"content"`
+}
+
+type repoGitObject struct {
+	Type string `json:"type"`
+	Sha  string `json:"sha"`
+	URL  string `json:"url,omitempty"`
+}
+
+type repoGitRef struct {
+	Ref    string         `json:"ref"`
+	URL    string         `json:"url"`
+	Object *repoGitObject `json:"object"`
+}
+
+type repoGitCommitParent struct {
+	Sha     string `json:"sha"`
+	URL     string `json:"url"`
+	HTMLURL string `json:"html_url"`
+}
+
+type repoGitCommit struct {
+	Sha       string                 `json:"sha"`
+	URL       string                 `json:"url"`
+	HTMLURL   string                 `json:"html_url"`
+	Author    *api.CommitUser        `json:"author"`
+	Committer *api.CommitUser        `json:"committer"`
+	Message   string                 `json:"message"`
+	Tree      *api.CommitMeta        `json:"tree"`
+	Parents   []*repoGitCommitParent `json:"parents"`
+}
+
+// gitObjectURL returns the API URL of the Git object, it is empty for types that
+// are not available through the API.
+func gitObjectURL(c *context.APIContext, typ git.ObjectType, sha string) string {
+	var kind string
+	switch typ {
+	case git.ObjectCommit:
+		kind = "commits"
+	case git.ObjectTree:
+		kind = "trees"
+	case git.ObjectBlob:
+		kind = "blobs"
+	default:
+		return ""
+	}
+	return c.BaseURL + "/repos/" + c.Repo.Repository.FullName() + "/git/" + kind + "/" + sha
+}
+
+// GetRepoGitTree returns the tree that the SHA, branch or tag points to, entries of
+// all subtrees are included when "recursive" is given.
+func GetRepoGitTree(c *context.APIContext) {
+	treeID, entries, err := db.GetGitTree(c.Repo.Repository.RepoPath(), c.Params(":sha"), c.QueryBool("recursive"))
+	if err != nil {
+		c.NotFoundOrServerError("GetGitTree", git.IsErrNotExist, err)
+		return
+	}
+
+	tree := &repoGitTree{
+		Sha:  treeID,
+		URL:  gitObjectURL(c, git.ObjectTree, treeID),
+		Tree: make([]*repoGitTreeEntry, 0, len(entries)),
+	}
+	if len(entries) > maxGitTreeEntries {
+		entries = entries[:maxGitTreeEntries]
+		tree.Truncated = true
+	}
+	for _, entry := range entries {
+		// Submodules point to commits in other repositories.
+		url := ""
+		if entry.Type != git.ObjectCommit {
+			url = gitObjectURL(c, entry.Type, entry.ID)
+		}
+		tree.Tree = append(tree.Tree, &repoGitTreeEntry{
+			Path: entry.Path,
+			Mode: entry.Mode,
+			Type: string(entry.Type),
+			Size: entry.Size,
+			Sha:  entry.ID,
+			URL:  url,
+		})
+	}
+	c.JSONSuccess(tree)
+}
+
+// GetRepoGitBlob returns the content of a blob encoded in Base64.
+func GetRepoGitBlob(c *context.APIContext) {
+	blobID, data, err := db.GetGitBlob(c.Repo.Repository.RepoPath(), c.Params(":sha"))
+	if err != nil {
+		c.NotFoundOrServerError("GetGitBlob", git.IsErrNotExist, err)
+		return
+	}
+
+	c.JSONSuccess(&repoGitBlob{
+		Sha:      blobID,
+		URL:      gitObjectURL(c, git.ObjectBlob, blobID),
+		Size:     int64(len(data)),
+		Encoding: "base64",
+		Content:  base64.StdEncoding.EncodeToString(data),
+	})
+}
+
+// ListRepoGitRefs returns references under the path, e.g. "heads" or "tags". The
+// reference is returned as a single object when the path matches it exactly.
+func ListRepoGitRefs(c *context.APIContext) {
+	refPath := c.Params("*")
+	refs, err := db.GetGitRefs(c.Repo.Repository.RepoPath(), refPath)
+	if err != nil {
+		c.ServerError("GetGitRefs", err)
+		return
+	} else if len(refs) == 0 {
+		c.NotFound()
+		return
+	}
+
+	apiRefs := make([]*repoGitRef, len(refs))
+	for i := range refs {
+		apiRefs[i] = &repoGitRef{
+			Ref: refs[i].Name,
+			URL: c.BaseURL + "/repos/" + c.Repo.Repository.FullName() + "/git/" + refs[i].Name,
+			Object: &repoGitObject{
+				Type: string(refs[i].ObjectType),
+				Sha:  refs[i].ObjectID,
+				URL:  gitObjectURL(c, refs[i].ObjectType, refs[i].ObjectID),
+			},
+		}
+	}
+
+	if len(apiRefs) == 1 && apiRefs[0].Ref == "refs/"+refPath {
+		c.JSONSuccess(apiRefs[0])
+		return
+	}
+	c.JSONSuccess(&apiRefs)
+}
+
+// GetRepoGitCommit returns the commit that the SHA points to.
+func GetRepoGitCommit(c *context.APIContext) {
+	repoPath := c.Repo.Repository.RepoPath()
+	commitID, err := db.RevParseObject(repoPath, c.Params(":sha"), git.ObjectCommit)
+	if err != nil {
+		c.NotFoundOrServerError("RevParseObject", git.IsErrNotExist, err)
+		return
+	}
+
+	gitRepo, err := git.OpenRepository(repoPath)
+	if err != nil {
+		c.ServerError("OpenRepository", err)
+		return
+	}
+	commit, err := gitRepo.GetCommit(commitID)
+	if err != nil {
+		c.NotFoundOrServerError("GetCommit", git.IsErrNotExist, err)
+		return
+	}
+
+	parents := make([]*repoGitCommitParent, commit.ParentCount())
+	for i := range parents {
+		sha, err := commit.ParentID(i)
+		if err != nil {
+			c.ServerError("ParentID", err)
+			return
+		}
+		parents[i] = &repoGitCommitParent{
+			Sha:     sha.String(),
+			URL:     gitObjectURL(c, git.ObjectCommit, sha.String()),
+			HTMLURL: c.Repo.Repository.HTMLURL() + "/commit/" + sha.String(),
+		}
+	}
+
+	c.JSONSuccess(&repoGitCommit{
+		Sha:     commitID,
+		URL:     gitObjectURL(c, git.ObjectCommit, commitID),
+		HTMLURL: c.Repo.Repository.HTMLURL() + "/commit/" + commitID,
+		Author: &api.CommitUser{
+			Name:  commit.Author.Name,
+			Email: commit.Author.Email,
+			Date:  commit.Author.When.Format(time.RFC3339),
+		},
+		Committer: &api.CommitUser{
+			Name:  commit.Committer.Name,
+			Email: commit.Committer.Email,
+			Date:  commit.Committer.When.Format(time.RFC3339),
+		},
+		Message: commit.Message(),
+		Tree: &api.CommitMeta{
+			URL: gitObjectURL(c, git.ObjectTree, commit.Tree.ID.String()),
+			SHA: commit.Tree.ID.String(),
+		},
+		Parents: parents,
+	})
+}
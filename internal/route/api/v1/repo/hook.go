@@ -5,6 +5,9 @@
 package repo
 
 import (
+	"strings"
+
+	"github.com/gogs/git-module"
 	"github.com/json-iterator/go"
 	"github.com/unknwon/com"
 	convert2 "gogs.io/gogs/internal/route/api/v1/convert"
@@ -13,7 +16,7 @@ import (
 
 	"gogs.io/gogs/internal/context"
 	"gogs.io/gogs/internal/db"
-	"gogs.io/gogs/internal/db/errors"
+	"gogs.io/gogs/internal/route/repo"
 )
 
 // https://github.com/gogs/go-gogs-client/wiki/Repositories#list-hooks
@@ -31,16 +34,156 @@ func ListHooks(c *context.APIContext) {
 	c.JSON(200, &apiHooks)
 }
 
+// GetHook returns a webhook of the repository.
+func GetHook(c *context.APIContext) {
+	w := getHookOfRepo(c)
+	if c.Written() {
+		return
+	}
+	c.JSONSuccess(convert2.ToHook(c.Repo.RepoLink, w))
+}
+
+// TestHook triggers a test delivery of a push event to the default branch for the
+// webhook, it is not sent if the webhook is not subscribed to push events.
+func TestHook(c *context.APIContext) {
+	w := getHookOfRepo(c)
+	if c.Written() {
+		return
+	}
+
+	var commit *git.Commit
+	if !c.Repo.Repository.IsBare {
+		gitRepo, err := git.OpenRepository(c.Repo.Repository.RepoPath())
+		if err != nil {
+			c.ServerError("OpenRepository", err)
+			return
+		}
+		commit, err = gitRepo.GetBranchCommit(c.Repo.Repository.DefaultBranch)
+		if err != nil {
+			c.ServerError("GetBranchCommit", err)
+			return
+		}
+	}
+
+	p, err := repo.TestPushPayload(c.User, c.Repo.Repository, commit)
+	if err != nil {
+		c.ServerError("TestPushPayload", err)
+		return
+	} else if err = db.TestWebhook(c.Repo.Repository, db.HOOK_EVENT_PUSH, p, w.ID); err != nil {
+		c.ServerError("TestWebhook", err)
+		return
+	}
+	c.NoContent()
+}
+
+// setHookFilters sets branch and path filters of the webhook from config options, it
+// responds with 422 and returns false if any filter is invalid.
+func setHookFilters(c *context.APIContext, w *db.Webhook, config map[string]string) bool {
+	if filter, ok := config["branch_filter"]; ok {
+		if err := db.ValidateHookFilter(filter); err != nil {
+			c.Error(422, "", err)
+			return false
+		}
+		w.BranchFilter = strings.TrimSpace(filter)
+	}
+	if filter, ok := config["path_filter"]; ok {
+		if err := db.ValidateHookFilter(filter); err != nil {
+			c.Error(422, "", err)
+			return false
+		}
+		w.PathFilter = strings.TrimSpace(filter)
+	}
+	return true
+}
+
+// setHookMeta sets the URL and meta of Matrix and Telegram webhooks from config
+// options, options that are not given keep their current values. It responds with
+// 422 and returns false if any required option is missing.
+func setHookMeta(c *context.APIContext, w *db.Webhook, config map[string]string) bool {
+	option := func(name, current string) (string, bool) {
+		if v, ok := config[name]; ok {
+			return v, true
+		}
+		if current == "" {
+			c.Error(422, "", "Missing config option: "+name)
+			return "", false
+		}
+		return current, true
+	}
+
+	var meta interface{}
+	switch w.HookTaskType {
+	case db.MATRIX:
+		m := &db.MatrixMeta{}
+		if w.ID > 0 {
+			m = w.GetMatrixHook()
+		}
+		var ok bool
+		if m.HomeserverURL, ok = option("homeserver_url", m.HomeserverURL); !ok {
+			return false
+		} else if m.RoomID, ok = option("room_id", m.RoomID); !ok {
+			return false
+		} else if m.AccessToken, ok = option("access_token", m.AccessToken); !ok {
+			return false
+		}
+		if msgType, ok := config["message_type"]; ok {
+			m.MessageType = msgType
+		}
+		w.URL = db.MatrixURL(m.HomeserverURL, m.RoomID)
+		meta = m
+	case db.TELEGRAM:
+		t := &db.TelegramMeta{}
+		if w.ID > 0 {
+			t = w.GetTelegramHook()
+		}
+		var ok bool
+		if t.BotToken, ok = option("bot_token", t.BotToken); !ok {
+			return false
+		} else if t.ChatID, ok = option("chat_id", t.ChatID); !ok {
+			return false
+		}
+		w.URL = db.TelegramURL(t.BotToken)
+		meta = t
+	default:
+		return true
+	}
+
+	data, err := jsoniter.Marshal(meta)
+	if err != nil {
+		c.Error(500, "JSON marshal failed", err)
+		return false
+	}
+	w.ContentType = db.JSON
+	w.Meta = string(data)
+	return true
+}
+
 // https://github.com/gogs/go-gogs-client/wiki/Repositories#create-a-hook
 func CreateHook(c *context.APIContext, form api.CreateHookOption) {
+	CreateWebhook(c, &db.Webhook{RepoID: c.Repo.Repository.ID}, form, c.Repo.RepoLink)
+}
+
+// CreateWebhook creates the webhook of a repository or an organization, which is
+// given by the owner ID set in w, from the form and responds with it. The link is
+// the home link of the owner.
+func CreateWebhook(c *context.APIContext, w *db.Webhook, form api.CreateHookOption, link string) {
 	if !db.IsValidHookTaskType(form.Type) {
 		c.Error(422, "", "Invalid hook type")
 		return
 	}
-	for _, name := range []string{"url", "content_type"} {
-		if _, ok := form.Config[name]; !ok {
-			c.Error(422, "", "Missing config option: "+name)
-			return
+	switch db.ToHookTaskType(form.Type) {
+	case db.MATRIX, db.TELEGRAM:
+		// URL and content type are derived from type-specific options.
+		if form.Config == nil {
+			form.Config = map[string]string{}
+		}
+		form.Config["content_type"] = db.JSON.Name()
+	default:
+		for _, name := range []string{"url", "content_type"} {
+			if _, ok := form.Config[name]; !ok {
+				c.Error(422, "", "Missing config option: "+name)
+				return
+			}
 		}
 	}
 	if !db.IsValidHookContentType(form.Config["content_type"]) {
@@ -51,26 +194,30 @@ func CreateHook(c *context.APIContext, form api.CreateHookOption) {
 	if len(form.Events) == 0 {
 		form.Events = []string{"push"}
 	}
-	w := &db.Webhook{
-		RepoID:      c.Repo.Repository.ID,
-		URL:         form.Config["url"],
-		ContentType: db.ToHookContentType(form.Config["content_type"]),
-		Secret:      form.Config["secret"],
-		HookEvent: &db.HookEvent{
-			ChooseEvents: true,
-			HookEvents: db.HookEvents{
-				Create:       com.IsSliceContainsStr(form.Events, string(db.HOOK_EVENT_CREATE)),
-				Delete:       com.IsSliceContainsStr(form.Events, string(db.HOOK_EVENT_DELETE)),
-				Fork:         com.IsSliceContainsStr(form.Events, string(db.HOOK_EVENT_FORK)),
-				Push:         com.IsSliceContainsStr(form.Events, string(db.HOOK_EVENT_PUSH)),
-				Issues:       com.IsSliceContainsStr(form.Events, string(db.HOOK_EVENT_ISSUES)),
-				IssueComment: com.IsSliceContainsStr(form.Events, string(db.HOOK_EVENT_ISSUE_COMMENT)),
-				PullRequest:  com.IsSliceContainsStr(form.Events, string(db.HOOK_EVENT_PULL_REQUEST)),
-				Release:      com.IsSliceContainsStr(form.Events, string(db.HOOK_EVENT_RELEASE)),
-			},
+	w.URL = form.Config["url"]
+	w.ContentType = db.ToHookContentType(form.Config["content_type"])
+	w.Secret = form.Config["secret"]
+	w.HookEvent = &db.HookEvent{
+		ChooseEvents: true,
+		HookEvents: db.HookEvents{
+			Create:       com.IsSliceContainsStr(form.Events, string(db.HOOK_EVENT_CREATE)),
+			Delete:       com.IsSliceContainsStr(form.Events, string(db.HOOK_EVENT_DELETE)),
+			Fork:         com.IsSliceContainsStr(form.Events, string(db.HOOK_EVENT_FORK)),
+			Push:         com.IsSliceContainsStr(form.Events, string(db.HOOK_EVENT_PUSH)),
+			Issues:       com.IsSliceContainsStr(form.Events, string(db.HOOK_EVENT_ISSUES)),
+			IssueComment: com.IsSliceContainsStr(form.Events, string(db.HOOK_EVENT_ISSUE_COMMENT)),
+			PullRequest:  com.IsSliceContainsStr(form.Events, string(db.HOOK_EVENT_PULL_REQUEST)),
+			Release:      com.IsSliceContainsStr(form.Events, string(db.HOOK_EVENT_RELEASE)),
+			Repository:   com.IsSliceContainsStr(form.Events, string(db.HOOK_EVENT_REPOSITORY)),
+			Wiki:         com.IsSliceContainsStr(form.Events, string(db.HOOK_EVENT_WIKI)),
+
+			ConfidentialIssues: com.IsSliceContainsStr(form.Events, string(db.HOOK_EVENT_CONFIDENTIAL_ISSUES)),
 		},
-		IsActive:     form.Active,
-		HookTaskType: db.ToHookTaskType(form.Type),
+	}
+	w.IsActive = form.Active
+	w.HookTaskType = db.ToHookTaskType(form.Type)
+	if !setHookFilters(c, w, form.Config) || !setHookMeta(c, w, form.Config) {
+		return
 	}
 	if w.HookTaskType == db.SLACK {
 		channel, ok := form.Config["channel"]
@@ -99,22 +246,25 @@ func CreateHook(c *context.APIContext, form api.CreateHookOption) {
 		return
 	}
 
-	c.JSON(201, convert2.ToHook(c.Repo.RepoLink, w))
+	c.JSON(201, convert2.ToHook(link, w))
 }
 
 // https://github.com/gogs/go-gogs-client/wiki/Repositories#edit-a-hook
 func EditHook(c *context.APIContext, form api.EditHookOption) {
-	w, err := db.GetWebhookOfRepoByID(c.Repo.Repository.ID, c.ParamsInt64(":id"))
-	if err != nil {
-		if errors.IsWebhookNotExist(err) {
-			c.Status(404)
-		} else {
-			c.Error(500, "GetWebhookOfRepoByID", err)
-		}
+	w := getHookOfRepo(c)
+	if c.Written() {
 		return
 	}
+	EditWebhook(c, w, form, c.Repo.RepoLink)
+}
 
+// EditWebhook updates the webhook of a repository or an organization from the form
+// and responds with it. The link is the home link of the owner.
+func EditWebhook(c *context.APIContext, w *db.Webhook, form api.EditHookOption, link string) {
 	if form.Config != nil {
+		if !setHookFilters(c, w, form.Config) {
+			return
+		}
 		if url, ok := form.Config["url"]; ok {
 			w.URL = url
 		}
@@ -126,6 +276,10 @@ func EditHook(c *context.APIContext, form api.EditHookOption) {
 			w.ContentType = db.ToHookContentType(ct)
 		}
 
+		if !setHookMeta(c, w, form.Config) {
+			return
+		}
+
 		if w.HookTaskType == db.SLACK {
 			if channel, ok := form.Config["channel"]; ok {
 				meta, err := jsoniter.Marshal(&db.SlackMeta{
@@ -158,7 +312,10 @@ func EditHook(c *context.APIContext, form api.EditHookOption) {
 	w.IssueComment = com.IsSliceContainsStr(form.Events, string(db.HOOK_EVENT_ISSUE_COMMENT))
 	w.PullRequest = com.IsSliceContainsStr(form.Events, string(db.HOOK_EVENT_PULL_REQUEST))
 	w.Release = com.IsSliceContainsStr(form.Events, string(db.HOOK_EVENT_RELEASE))
-	if err = w.UpdateEvent(); err != nil {
+	w.Repository = com.IsSliceContainsStr(form.Events, string(db.HOOK_EVENT_REPOSITORY))
+	w.Wiki = com.IsSliceContainsStr(form.Events, string(db.HOOK_EVENT_WIKI))
+	w.ConfidentialIssues = com.IsSliceContainsStr(form.Events, string(db.HOOK_EVENT_CONFIDENTIAL_ISSUES))
+	if err := w.UpdateEvent(); err != nil {
 		c.Error(500, "UpdateEvent", err)
 		return
 	}
@@ -172,11 +329,16 @@ func EditHook(c *context.APIContext, form api.EditHookOption) {
 		return
 	}
 
-	c.JSON(200, convert2.ToHook(c.Repo.RepoLink, w))
+	c.JSON(200, convert2.ToHook(link, w))
 }
 
 func DeleteHook(c *context.APIContext) {
-	if err := db.DeleteWebhookOfRepoByID(c.Repo.Repository.ID, c.ParamsInt64(":id")); err != nil {
+	w := getHookOfRepo(c)
+	if c.Written() {
+		return
+	}
+
+	if err := db.DeleteWebhookOfRepoByID(c.Repo.Repository.ID, w.ID); err != nil {
 		c.Error(500, "DeleteWebhookByRepoID", err)
 		return
 	}
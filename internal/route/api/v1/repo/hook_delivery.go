@@ -0,0 +1,155 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package repo
+
+import (
+	"net/http"
+	"time"
+
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/db/errors"
+)
+
+// HookDeliveryRequest is the request sent by a webhook delivery.
+type HookDeliveryRequest struct {
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	Payload string            `json:"payload"`
+}
+
+// HookDeliveryResponse is the response received by a webhook delivery.
+type HookDeliveryResponse struct {
+	Headers map[string]string `json:"headers"`
+	Payload string            `json:"payload"`
+}
+
+// HookDelivery is the API format of a webhook delivery, which is compatible with
+// webhook deliveries of GitHub.
+type HookDelivery struct {
+	ID          int64                 `json:"id"`
+	GUID        string                `json:"guid"`
+	DeliveredAt *time.Time            `json:"delivered_at"`
+	Duration    float64               `json:"duration"` // In seconds
+	Status      string                `json:"status"`
+	StatusCode  int                   `json:"status_code"`
+	Event       string                `json:"event"`
+	Request     *HookDeliveryRequest  `json:"request,omitempty"`
+	Response    *HookDeliveryResponse `json:"response,omitempty"`
+}
+
+// toHookDelivery converts the hook task, details of the request and the response are
+// only included if withDetails is true.
+func toHookDelivery(t *db.HookTask, withDetails bool) *HookDelivery {
+	d := &HookDelivery{
+		ID:       t.ID,
+		GUID:     t.UUID,
+		Duration: float64(t.Duration) / 1000,
+		Status:   "pending",
+		Event:    string(t.EventType),
+	}
+	if t.IsDelivered {
+		delivered := time.Unix(0, t.Delivered)
+		d.DeliveredAt = &delivered
+		d.Status = "failed"
+		if t.IsSucceed {
+			d.Status = "OK"
+		}
+	}
+	if t.ResponseInfo != nil {
+		d.StatusCode = t.ResponseInfo.Status
+	}
+
+	if withDetails {
+		d.Request = &HookDeliveryRequest{
+			URL:     t.URL,
+			Payload: t.PayloadContent,
+		}
+		if t.RequestInfo != nil {
+			d.Request.Headers = t.RequestInfo.Headers
+			if t.RequestInfo.Body != "" {
+				d.Request.Payload = t.RequestInfo.Body
+			}
+		}
+		if t.ResponseInfo != nil {
+			d.Response = &HookDeliveryResponse{
+				Headers: t.ResponseInfo.Headers,
+				Payload: t.ResponseInfo.Body,
+			}
+		}
+	}
+	return d
+}
+
+func getHookOfRepo(c *context.APIContext) *db.Webhook {
+	w, err := db.GetWebhookOfRepoByID(c.Repo.Repository.ID, c.ParamsInt64(":id"))
+	if err != nil {
+		c.NotFoundOrServerError("GetWebhookOfRepoByID", errors.IsWebhookNotExist, err)
+		return nil
+	}
+	return w
+}
+
+func getHookDelivery(c *context.APIContext) *db.HookTask {
+	w := getHookOfRepo(c)
+	if c.Written() {
+		return nil
+	}
+
+	t, err := db.GetHookTaskOfWebhookByID(w.ID, c.ParamsInt64(":delivery_id"))
+	if err != nil {
+		c.NotFoundOrServerError("GetHookTaskOfWebhookByID", errors.IsHookTaskNotExist, err)
+		return nil
+	}
+	return t
+}
+
+// ListHookDeliveries lists recent deliveries of the webhook, the most recent first.
+func ListHookDeliveries(c *context.APIContext) {
+	w := getHookOfRepo(c)
+	if c.Written() {
+		return
+	}
+
+	page := c.QueryInt("page")
+	if page <= 0 {
+		page = 1
+	}
+	tasks, err := db.HookTasks(w.ID, page)
+	if err != nil {
+		c.ServerError("HookTasks", err)
+		return
+	}
+
+	deliveries := make([]*HookDelivery, len(tasks))
+	for i := range tasks {
+		deliveries[i] = toHookDelivery(tasks[i], false)
+	}
+	c.JSONSuccess(&deliveries)
+}
+
+// GetHookDelivery returns the delivery of the webhook with request and response.
+func GetHookDelivery(c *context.APIContext) {
+	t := getHookDelivery(c)
+	if c.Written() {
+		return
+	}
+	c.JSONSuccess(toHookDelivery(t, true))
+}
+
+// RedeliverHookDelivery delivers the payload of the delivery again as a new delivery.
+func RedeliverHookDelivery(c *context.APIContext) {
+	t := getHookDelivery(c)
+	if c.Written() {
+		return
+	}
+
+	redelivery, err := db.RedeliverHookTask(t)
+	if err != nil {
+		c.ServerError("RedeliverHookTask", err)
+		return
+	}
+	c.JSON(http.StatusAccepted, toHookDelivery(redelivery, false))
+}
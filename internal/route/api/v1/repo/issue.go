@@ -46,9 +46,11 @@ func listIssues(c *context.APIContext, opts *db.IssuesOptions) {
 
 func ListUserIssues(c *context.APIContext) {
 	opts := db.IssuesOptions{
-		AssigneeID: c.User.ID,
-		Page:       c.QueryInt("page"),
-		IsClosed:   api.StateType(c.Query("state")) == api.STATE_CLOSED,
+		UserID:           c.User.ID,
+		AssigneeID:       c.User.ID,
+		Page:             c.QueryInt("page"),
+		IsClosed:         api.StateType(c.Query("state")) == api.STATE_CLOSED,
+		HideConfidential: !c.User.IsAdmin,
 	}
 
 	listIssues(c, &opts)
@@ -56,9 +58,19 @@ func ListUserIssues(c *context.APIContext) {
 
 func ListIssues(c *context.APIContext) {
 	opts := db.IssuesOptions{
-		RepoID:   c.Repo.Repository.ID,
-		Page:     c.QueryInt("page"),
-		IsClosed: api.StateType(c.Query("state")) == api.STATE_CLOSED,
+		UserID:           c.UserID(),
+		RepoID:           c.Repo.Repository.ID,
+		Page:             c.QueryInt("page"),
+		IsClosed:         api.StateType(c.Query("state")) == api.STATE_CLOSED,
+		HideConfidential: !c.Repo.IsTriager(),
+	}
+	if keyword := strings.TrimSpace(c.Query("q")); keyword != "" {
+		issueIDs, err := db.SearchIssueIDs([]int64{opts.RepoID}, keyword)
+		if err != nil {
+			c.ServerError("SearchIssueIDs", err)
+			return
+		}
+		opts.IssueIDs = issueIDs
 	}
 
 	listIssues(c, &opts)
@@ -69,10 +81,40 @@ func GetIssue(c *context.APIContext) {
 	if err != nil {
 		c.NotFoundOrServerError("GetIssueByIndex", errors.IsIssueNotExist, err)
 		return
+	} else if !c.Repo.CanSeeIssue(issue, c.UserID()) {
+		c.NotFound()
+		return
 	}
 	c.JSONSuccess(issue.APIFormat())
 }
 
+// issueFormData is the structured data of the issue form that an issue is created from.
+type issueFormData struct {
+	Form   string               `json:"form"`
+	Fields []*db.IssueFormValue `json:"fields"`
+}
+
+func GetIssueFormData(c *context.APIContext) {
+	issue, err := db.GetIssueByIndex(c.Repo.Repository.ID, c.ParamsInt64(":index"))
+	if err != nil {
+		c.NotFoundOrServerError("GetIssueByIndex", errors.IsIssueNotExist, err)
+		return
+	} else if !c.Repo.CanSeeIssue(issue, c.UserID()) {
+		c.NotFound()
+		return
+	}
+
+	data, err := db.GetIssueFormData(issue.ID)
+	if err != nil {
+		c.NotFoundOrServerError("GetIssueFormData", errors.IsIssueFormDataNotExist, err)
+		return
+	}
+	c.JSONSuccess(&issueFormData{
+		Form:   data.FormName,
+		Fields: data.Fields,
+	})
+}
+
 func CreateIssue(c *context.APIContext, form api.CreateIssueOption) {
 	issue := &db.Issue{
 		RepoID:   c.Repo.Repository.ID,
@@ -82,7 +124,7 @@ func CreateIssue(c *context.APIContext, form api.CreateIssueOption) {
 		Content:  form.Body,
 	}
 
-	if c.Repo.IsWriter() {
+	if c.Repo.IsTriager() {
 		if len(form.Assignee) > 0 {
 			assignee, err := db.GetUserByName(form.Assignee)
 			if err != nil {
@@ -127,9 +169,16 @@ func EditIssue(c *context.APIContext, form api.EditIssueOption) {
 	if err != nil {
 		c.NotFoundOrServerError("GetIssueByIndex", errors.IsIssueNotExist, err)
 		return
+	} else if !c.Repo.CanSeeIssue(issue, c.UserID()) {
+		c.NotFound()
+		return
 	}
 
-	if !issue.IsPoster(c.User.ID) && !c.Repo.IsWriter() {
+	if !issue.IsPoster(c.User.ID) && !c.Repo.IsTriager() {
+		c.Status(http.StatusForbidden)
+		return
+	} else if !issue.IsPoster(c.User.ID) && !c.Repo.CanWrite(db.REPO_UNIT_ISSUES) && (len(form.Title) > 0 || form.Body != nil) {
+		// Triagers can only manage issues but not change their content.
 		c.Status(http.StatusForbidden)
 		return
 	}
@@ -141,7 +190,7 @@ func EditIssue(c *context.APIContext, form api.EditIssueOption) {
 		issue.Content = *form.Body
 	}
 
-	if c.Repo.IsWriter() && form.Assignee != nil &&
+	if c.Repo.IsTriager() && form.Assignee != nil &&
 		(issue.Assignee == nil || issue.Assignee.LowerName != strings.ToLower(*form.Assignee)) {
 		if len(*form.Assignee) == 0 {
 			issue.AssigneeID = 0
@@ -163,7 +212,7 @@ func EditIssue(c *context.APIContext, form api.EditIssueOption) {
 			return
 		}
 	}
-	if c.Repo.IsWriter() && form.Milestone != nil &&
+	if c.Repo.IsTriager() && form.Milestone != nil &&
 		issue.MilestoneID != *form.Milestone {
 		oldMilestoneID := issue.MilestoneID
 		issue.MilestoneID = *form.Milestone
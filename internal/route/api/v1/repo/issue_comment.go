@@ -29,6 +29,9 @@ func ListIssueComments(c *context.APIContext) {
 	if err != nil {
 		c.ServerError("GetRawIssueByIndex", err)
 		return
+	} else if !c.Repo.CanSeeIssue(issue, c.UserID()) {
+		c.NotFound()
+		return
 	}
 
 	comments, err := db.GetCommentsByIssueIDSince(issue.ID, since.Unix())
@@ -55,7 +58,7 @@ func ListRepoIssueComments(c *context.APIContext) {
 		}
 	}
 
-	comments, err := db.GetCommentsByRepoIDSince(c.Repo.Repository.ID, since.Unix())
+	comments, err := db.GetCommentsByRepoIDSince(c.Repo.Repository.ID, since.Unix(), !c.Repo.IsTriager(), c.UserID())
 	if err != nil {
 		c.ServerError("GetCommentsByRepoIDSince", err)
 		return
@@ -73,6 +76,9 @@ func CreateIssueComment(c *context.APIContext, form api.CreateIssueCommentOption
 	if err != nil {
 		c.ServerError("GetIssueByIndex", err)
 		return
+	} else if !c.Repo.CanSeeIssue(issue, c.UserID()) {
+		c.NotFound()
+		return
 	}
 
 	comment, err := db.CreateIssueComment(c.User, c.Repo.Repository, issue, form.Body, nil)
@@ -89,6 +95,9 @@ func EditIssueComment(c *context.APIContext, form api.EditIssueCommentOption) {
 	if err != nil {
 		c.NotFoundOrServerError("GetCommentByID", db.IsErrCommentNotExist, err)
 		return
+	} else if comment.Issue.RepoID != c.Repo.Repository.ID || !c.Repo.CanSeeIssue(comment.Issue, c.UserID()) {
+		c.NotFound()
+		return
 	}
 
 	if c.User.ID != comment.PosterID && !c.Repo.IsAdmin() {
@@ -113,6 +122,9 @@ func DeleteIssueComment(c *context.APIContext) {
 	if err != nil {
 		c.NotFoundOrServerError("GetCommentByID", db.IsErrCommentNotExist, err)
 		return
+	} else if comment.Issue.RepoID != c.Repo.Repository.ID || !c.Repo.CanSeeIssue(comment.Issue, c.UserID()) {
+		c.NotFound()
+		return
 	}
 
 	if c.User.ID != comment.PosterID && !c.Repo.IsAdmin() {
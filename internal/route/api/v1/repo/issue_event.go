@@ -0,0 +1,114 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package repo
+
+import (
+	"strings"
+	"time"
+
+	api "github.com/gogs/go-gogs-client"
+
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/db/errors"
+)
+
+type issueEventMilestone struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+}
+
+type issueEventRename struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type issueEventSource struct {
+	Repository string `json:"repository"`
+	Number     int64  `json:"number"`
+	CommentID  int64  `json:"comment_id,omitempty"`
+	URL        string `json:"html_url"`
+}
+
+type issueEvent struct {
+	ID        int64                `json:"id"`
+	Event     string               `json:"event"`
+	Actor     *api.User            `json:"actor"`
+	Label     *api.Label           `json:"label,omitempty"`
+	Milestone *issueEventMilestone `json:"milestone,omitempty"`
+	Assignee  *api.User            `json:"assignee,omitempty"`
+	Rename    *issueEventRename    `json:"rename,omitempty"`
+	Source    *issueEventSource    `json:"source,omitempty"`
+	Created   time.Time            `json:"created_at"`
+}
+
+func toIssueEvent(e *db.IssueEvent) *issueEvent {
+	apiEvent := &issueEvent{
+		ID:      e.ID,
+		Event:   e.Type.Name(),
+		Actor:   e.Actor.APIFormat(),
+		Created: e.Created,
+	}
+
+	switch e.Type {
+	case db.ISSUE_EVENT_LABELED, db.ISSUE_EVENT_UNLABELED:
+		apiEvent.Label = &api.Label{
+			ID:    e.LabelID,
+			Name:  e.LabelName,
+			Color: strings.TrimLeft(e.LabelColor, "#"),
+		}
+	case db.ISSUE_EVENT_MILESTONED, db.ISSUE_EVENT_DEMILESTONED:
+		apiEvent.Milestone = &issueEventMilestone{
+			ID:    e.MilestoneID,
+			Title: e.MilestoneName,
+		}
+	case db.ISSUE_EVENT_ASSIGNED, db.ISSUE_EVENT_UNASSIGNED:
+		if e.Assignee != nil {
+			apiEvent.Assignee = e.Assignee.APIFormat()
+		}
+	case db.ISSUE_EVENT_RENAMED:
+		apiEvent.Rename = &issueEventRename{
+			From: e.OldTitle,
+			To:   e.NewTitle,
+		}
+	case db.ISSUE_EVENT_REFERENCED:
+		if e.RefIssue != nil {
+			apiEvent.Source = &issueEventSource{
+				Repository: e.RefIssue.Repo.FullName(),
+				Number:     e.RefIssue.Index,
+				CommentID:  e.RefCommentID,
+				URL:        e.RefIssue.HTMLURL(),
+			}
+		}
+	}
+	return apiEvent
+}
+
+func ListIssueEvents(c *context.APIContext) {
+	issue, err := db.GetRawIssueByIndex(c.Repo.Repository.ID, c.ParamsInt64(":index"))
+	if err != nil {
+		c.NotFoundOrServerError("GetRawIssueByIndex", errors.IsIssueNotExist, err)
+		return
+	} else if !c.Repo.CanSeeIssue(issue, c.UserID()) {
+		c.NotFound()
+		return
+	}
+
+	events, err := db.GetIssueEventsByIssueID(issue.ID)
+	if err != nil {
+		c.ServerError("GetIssueEventsByIssueID", err)
+		return
+	}
+
+	apiEvents := make([]*issueEvent, 0, len(events))
+	for i := range events {
+		// Referencing issue may be in a repository that is no longer accessible.
+		if events[i].Type == db.ISSUE_EVENT_REFERENCED && events[i].RefIssue == nil {
+			continue
+		}
+		apiEvents = append(apiEvents, toIssueEvent(events[i]))
+	}
+	c.JSONSuccess(&apiEvents)
+}
@@ -0,0 +1,40 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package repo
+
+import (
+	"net/http"
+
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+)
+
+// GetIssueGraph exports the reference graph of issues and pull requests of the
+// repository in JSON, or in GraphML when the format is "graphml".
+func GetIssueGraph(c *context.APIContext) {
+	graph, err := db.GetIssueGraph(&db.IssueGraphOptions{
+		RepoID: c.Repo.Repository.ID,
+		UserID: c.UserID(),
+	})
+	if err != nil {
+		c.ServerError("GetIssueGraph", err)
+		return
+	}
+
+	switch c.Query("format") {
+	case "", "json":
+		c.JSONSuccess(graph)
+	case "graphml":
+		data, err := graph.GraphML()
+		if err != nil {
+			c.ServerError("GraphML", err)
+			return
+		}
+		c.Header().Set("Content-Type", "application/graphml+xml; charset=utf-8")
+		_, _ = c.Write(data)
+	default:
+		c.Error(http.StatusUnprocessableEntity, "", "Unsupported format")
+	}
+}
@@ -19,6 +19,9 @@ func ListIssueLabels(c *context.APIContext) {
 	if err != nil {
 		c.NotFoundOrServerError("GetIssueByIndex", errors.IsIssueNotExist, err)
 		return
+	} else if !c.Repo.CanSeeIssue(issue, c.UserID()) {
+		c.NotFound()
+		return
 	}
 
 	apiLabels := make([]*api.Label, len(issue.Labels))
@@ -33,6 +36,9 @@ func AddIssueLabels(c *context.APIContext, form api.IssueLabelsOption) {
 	if err != nil {
 		c.NotFoundOrServerError("GetIssueByIndex", errors.IsIssueNotExist, err)
 		return
+	} else if !c.Repo.CanSeeIssue(issue, c.UserID()) {
+		c.NotFound()
+		return
 	}
 
 	labels, err := db.GetLabelsInRepoByIDs(c.Repo.Repository.ID, form.Labels)
@@ -64,6 +70,9 @@ func DeleteIssueLabel(c *context.APIContext) {
 	if err != nil {
 		c.NotFoundOrServerError("GetIssueByIndex", errors.IsIssueNotExist, err)
 		return
+	} else if !c.Repo.CanSeeIssue(issue, c.UserID()) {
+		c.NotFound()
+		return
 	}
 
 	label, err := db.GetLabelOfRepoByID(c.Repo.Repository.ID, c.ParamsInt64(":id"))
@@ -76,7 +85,7 @@ func DeleteIssueLabel(c *context.APIContext) {
 		return
 	}
 
-	if err := db.DeleteIssueLabel(issue, label); err != nil {
+	if err := db.DeleteIssueLabel(c.User, issue, label); err != nil {
 		c.ServerError("DeleteIssueLabel", err)
 		return
 	}
@@ -89,6 +98,9 @@ func ReplaceIssueLabels(c *context.APIContext, form api.IssueLabelsOption) {
 	if err != nil {
 		c.NotFoundOrServerError("GetIssueByIndex", errors.IsIssueNotExist, err)
 		return
+	} else if !c.Repo.CanSeeIssue(issue, c.UserID()) {
+		c.NotFound()
+		return
 	}
 
 	labels, err := db.GetLabelsInRepoByIDs(c.Repo.Repository.ID, form.Labels)
@@ -97,7 +109,7 @@ func ReplaceIssueLabels(c *context.APIContext, form api.IssueLabelsOption) {
 		return
 	}
 
-	if err := issue.ReplaceLabels(labels); err != nil {
+	if err := issue.ReplaceLabels(c.User, labels); err != nil {
 		c.ServerError("ReplaceLabels", err)
 		return
 	}
@@ -120,6 +132,9 @@ func ClearIssueLabels(c *context.APIContext) {
 	if err != nil {
 		c.NotFoundOrServerError("GetIssueByIndex", errors.IsIssueNotExist, err)
 		return
+	} else if !c.Repo.CanSeeIssue(issue, c.UserID()) {
+		c.NotFound()
+		return
 	}
 
 	if err := issue.ClearLabels(c.User); err != nil {
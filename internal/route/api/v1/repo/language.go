@@ -0,0 +1,26 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package repo
+
+import (
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+)
+
+// ListLanguages responses with total sizes in bytes of files on the default branch
+// by their languages.
+func ListLanguages(c *context.APIContext) {
+	stats, err := db.GetLanguageStats(c.Repo.Repository.ID)
+	if err != nil {
+		c.ServerError("GetLanguageStats", err)
+		return
+	}
+
+	languages := make(map[string]int64, len(stats))
+	for _, s := range stats {
+		languages[s.Language] = s.Size
+	}
+	c.JSONSuccess(languages)
+}
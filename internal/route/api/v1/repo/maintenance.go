@@ -0,0 +1,89 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package repo
+
+import (
+	"net/http"
+	"time"
+
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/db/errors"
+	"gogs.io/gogs/internal/route/api/v1/convert"
+)
+
+type maintenanceJob struct {
+	ID       int64      `json:"id"`
+	Action   string     `json:"action"`
+	Status   string     `json:"status"`
+	Output   string     `json:"output"`
+	Created  time.Time  `json:"created_at"`
+	Started  *time.Time `json:"started_at"`
+	Finished *time.Time `json:"finished_at"`
+}
+
+type CreateMaintenanceJobOption struct {
+	Action string `json:"action" binding:"Required"`
+}
+
+func toMaintenanceJob(j *db.MaintenanceJob) *maintenanceJob {
+	job := &maintenanceJob{
+		ID:      j.ID,
+		Action:  j.Action,
+		Status:  j.Status.String(),
+		Output:  j.Output,
+		Created: j.Created,
+	}
+	if j.StartedUnix > 0 {
+		job.Started = &j.Started
+	}
+	if j.FinishedUnix > 0 {
+		job.Finished = &j.Finished
+	}
+	return job
+}
+
+func ListMaintenanceJobs(c *context.APIContext) {
+	jobs, err := db.GetMaintenanceJobs(c.Repo.Repository.ID, c.QueryInt("page"), convert.ToCorrectPageSize(c.QueryInt("limit")))
+	if err != nil {
+		c.ServerError("GetMaintenanceJobs", err)
+		return
+	}
+
+	apiJobs := make([]*maintenanceJob, len(jobs))
+	for i := range jobs {
+		apiJobs[i] = toMaintenanceJob(jobs[i])
+	}
+	c.JSONSuccess(&apiJobs)
+}
+
+func GetMaintenanceJob(c *context.APIContext) {
+	j, err := db.GetMaintenanceJob(c.Repo.Repository.ID, c.ParamsInt64(":id"))
+	if err != nil {
+		c.NotFoundOrServerError("GetMaintenanceJob", errors.IsMaintenanceJobNotExist, err)
+		return
+	}
+	c.JSONSuccess(toMaintenanceJob(j))
+}
+
+// CreateMaintenanceJob queues a maintenance action on the repository, and responds with
+// the job which can be polled for its status.
+func CreateMaintenanceJob(c *context.APIContext, form CreateMaintenanceJobOption) {
+	if c.Repo.Repository.IsBare && form.Action != db.MAINTENANCE_ACTION_RECOMPUTE_STATS {
+		c.Error(http.StatusUnprocessableEntity, "", "repository is empty")
+		return
+	}
+
+	j, err := db.NewMaintenanceJob(c.User, c.Repo.Repository, form.Action)
+	if err != nil {
+		if errors.IsInvalidMaintenanceAction(err) {
+			c.Error(http.StatusUnprocessableEntity, "", err)
+		} else {
+			c.ServerError("NewMaintenanceJob", err)
+		}
+		return
+	}
+	c.JSON(http.StatusAccepted, toMaintenanceJob(j))
+}
@@ -0,0 +1,118 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package repo
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	log "unknwon.dev/clog/v2"
+
+	api "github.com/gogs/go-gogs-client"
+
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/db/errors"
+	"gogs.io/gogs/internal/markup"
+	"gogs.io/gogs/internal/tool"
+)
+
+// mentionableUsersCacheTTL is the number of seconds that mentionable users of a repository are cached.
+const mentionableUsersCacheTTL = 60
+
+// toMentionUser returns the API format of the user without private information.
+func toMentionUser(u *db.User) *api.User {
+	return &api.User{
+		ID:        u.ID,
+		UserName:  u.Name,
+		Login:     u.Name,
+		FullName:  markup.Sanitize(u.FullName),
+		AvatarUrl: u.AvatarLink(),
+	}
+}
+
+// getMentionableUsers returns mentionable users of the current repository, IDs of users
+// are cached to avoid computing the list on every keystroke of the comment editor.
+func getMentionableUsers(c *context.APIContext) ([]*db.User, error) {
+	key := fmt.Sprintf("MentionableUsers_%d", c.Repo.Repository.ID)
+	if val, ok := c.Cache.Get(key).(string); ok {
+		if len(val) == 0 {
+			return []*db.User{}, nil
+		}
+		return db.GetUsersByIDs(tool.StringsToInt64s(strings.Split(val, ",")))
+	}
+
+	users, err := c.Repo.Repository.GetMentionableUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, len(users))
+	for i := range users {
+		ids[i] = users[i].ID
+	}
+	if err = c.Cache.Put(key, strings.Join(tool.Int64sToStrings(ids), ","), mentionableUsersCacheTTL); err != nil {
+		log.Error("Failed to cache mentionable users [repo_id: %d]: %v", c.Repo.Repository.ID, err)
+	}
+	return users, nil
+}
+
+// ListMentionableUsers returns users who can be mentioned in the repository, filtered by
+// the keyword that matches prefix of username or part of full name.
+func ListMentionableUsers(c *context.APIContext) {
+	users, err := getMentionableUsers(c)
+	if err != nil {
+		c.ServerError("getMentionableUsers", err)
+		return
+	}
+
+	limit := c.QueryInt("limit")
+	if limit <= 0 || limit > 50 {
+		limit = 10
+	}
+	keyword := strings.ToLower(c.Query("q"))
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].LowerName < users[j].LowerName
+	})
+	apiUsers := make([]*api.User, 0, limit)
+	for _, u := range users {
+		if len(apiUsers) >= limit {
+			break
+		}
+		if len(keyword) > 0 &&
+			!strings.HasPrefix(u.LowerName, keyword) &&
+			!strings.Contains(strings.ToLower(u.FullName), keyword) {
+			continue
+		}
+		apiUsers = append(apiUsers, toMentionUser(u))
+	}
+	c.JSONSuccess(&apiUsers)
+}
+
+// ListIssueParticipants returns the poster and commenters of the issue.
+func ListIssueParticipants(c *context.APIContext) {
+	issue, err := db.GetRawIssueByIndex(c.Repo.Repository.ID, c.ParamsInt64(":index"))
+	if err != nil {
+		c.NotFoundOrServerError("GetRawIssueByIndex", errors.IsIssueNotExist, err)
+		return
+	} else if !c.Repo.CanSeeIssue(issue, c.UserID()) {
+		c.NotFound()
+		return
+	}
+
+	users, err := issue.GetParticipants()
+	if err != nil {
+		c.ServerError("GetParticipants", err)
+		return
+	}
+
+	apiUsers := make([]*api.User, len(users))
+	for i := range users {
+		apiUsers[i] = toMentionUser(users[i])
+	}
+	c.JSONSuccess(&apiUsers)
+}
@@ -37,6 +37,22 @@ func GetMilestone(c *context.APIContext) {
 	c.JSONSuccess(milestone.APIFormat())
 }
 
+// GetMilestoneProgress returns daily numbers of open and closed issues of the milestone.
+func GetMilestoneProgress(c *context.APIContext) {
+	milestone, err := db.GetMilestoneByRepoID(c.Repo.Repository.ID, c.ParamsInt64(":id"))
+	if err != nil {
+		c.NotFoundOrServerError("GetMilestoneByRepoID", db.IsErrMilestoneNotExist, err)
+		return
+	}
+
+	progress, err := milestone.Progress(c.QueryBool("include_pulls"))
+	if err != nil {
+		c.ServerError("Progress", err)
+		return
+	}
+	c.JSONSuccess(&progress)
+}
+
 func CreateMilestone(c *context.APIContext, form api.CreateMilestoneOption) {
 	if form.Deadline == nil {
 		defaultDeadline, _ := time.ParseInLocation("2006-01-02", "9999-12-31", time.Local)
@@ -0,0 +1,385 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package repo
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gogs/git-module"
+	api "github.com/gogs/go-gogs-client"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/db/errors"
+)
+
+type releaseAsset struct {
+	ID            int64     `json:"id"`
+	Name          string    `json:"name"`
+	Size          int64     `json:"size"`
+	SHA256        string    `json:"sha256"`
+	DownloadCount int64     `json:"download_count"`
+	Created       time.Time `json:"created_at"`
+	DownloadURL   string    `json:"browser_download_url"`
+}
+
+type release struct {
+	*api.Release
+	Assets []*releaseAsset `json:"assets"`
+}
+
+type CreateReleaseOption struct {
+	TagName         string `json:"tag_name" binding:"Required"`
+	TargetCommitish string `json:"target_commitish"`
+	Name            string `json:"name" binding:"Required"`
+	Body            string `json:"body"`
+	Draft           bool   `json:"draft"`
+	Prerelease      bool   `json:"prerelease"`
+}
+
+type EditReleaseOption struct {
+	Name       *string `json:"name"`
+	Body       *string `json:"body"`
+	Draft      *bool   `json:"draft"`
+	Prerelease *bool   `json:"prerelease"`
+}
+
+type EditReleaseAssetOption struct {
+	Name string `json:"name" binding:"Required"`
+}
+
+func toReleaseAsset(a *db.Attachment) *releaseAsset {
+	return &releaseAsset{
+		ID:            a.ID,
+		Name:          a.Name,
+		Size:          a.Size,
+		SHA256:        a.SHA256,
+		DownloadCount: a.DownloadCount,
+		Created:       a.Created,
+		DownloadURL:   conf.Server.ExternalURL + "attachments/" + a.UUID,
+	}
+}
+
+func toRelease(r *db.Release) *release {
+	assets := make([]*releaseAsset, len(r.Attachments))
+	for i := range r.Attachments {
+		assets[i] = toReleaseAsset(r.Attachments[i])
+	}
+	return &release{
+		Release: r.APIFormat(),
+		Assets:  assets,
+	}
+}
+
+// getRelease returns the release of the current repository by ":id" parameter.
+// Drafts are only visible to users who have write access.
+func getRelease(c *context.APIContext) *db.Release {
+	rel, err := db.GetReleaseByID(c.ParamsInt64(":id"))
+	if err != nil {
+		c.NotFoundOrServerError("GetReleaseByID", db.IsErrReleaseNotExist, err)
+		return nil
+	} else if rel.RepoID != c.Repo.Repository.ID || (rel.IsDraft && !c.Repo.CanWrite(db.REPO_UNIT_RELEASES)) {
+		c.NotFound()
+		return nil
+	}
+	return rel
+}
+
+func ListReleases(c *context.APIContext) {
+	releases, err := db.GetPublishedReleasesByRepoID(c.Repo.Repository.ID)
+	if err != nil {
+		c.ServerError("GetPublishedReleasesByRepoID", err)
+		return
+	}
+	if c.Repo.CanWrite(db.REPO_UNIT_RELEASES) {
+		drafts, err := db.GetDraftReleasesByRepoID(c.Repo.Repository.ID)
+		if err != nil {
+			c.ServerError("GetDraftReleasesByRepoID", err)
+			return
+		}
+		releases = append(drafts, releases...)
+	}
+
+	apiReleases := make([]*release, len(releases))
+	for i := range releases {
+		if err = releases[i].LoadAttributes(); err != nil {
+			c.ServerError("LoadAttributes", err)
+			return
+		}
+		apiReleases[i] = toRelease(releases[i])
+	}
+	c.JSONSuccess(&apiReleases)
+}
+
+func GetRelease(c *context.APIContext) {
+	rel := getRelease(c)
+	if c.Written() {
+		return
+	}
+	c.JSONSuccess(toRelease(rel))
+}
+
+func GetReleaseByTag(c *context.APIContext) {
+	rel, err := db.GetRelease(c.Repo.Repository.ID, c.Params("*"))
+	if err != nil {
+		c.NotFoundOrServerError("GetRelease", db.IsErrReleaseNotExist, err)
+		return
+	} else if rel.IsDraft && !c.Repo.CanWrite(db.REPO_UNIT_RELEASES) {
+		c.NotFound()
+		return
+	}
+	c.JSONSuccess(toRelease(rel))
+}
+
+func CreateRelease(c *context.APIContext, form CreateReleaseOption) {
+	if form.TargetCommitish == "" {
+		form.TargetCommitish = c.Repo.Repository.DefaultBranch
+	}
+
+	gitRepo, err := git.OpenRepository(c.Repo.Repository.RepoPath())
+	if err != nil {
+		c.ServerError("OpenRepository", err)
+		return
+	}
+	if !gitRepo.IsBranchExist(form.TargetCommitish) {
+		c.Error(http.StatusUnprocessableEntity, "", "target branch does not exist")
+		return
+	}
+
+	// Use current time if tag not yet exist, otherwise get time from Git
+	var tagCreatedUnix int64
+	tag, err := gitRepo.GetTag(form.TagName)
+	if err == nil {
+		commit, err := tag.Commit()
+		if err == nil {
+			tagCreatedUnix = commit.Author.When.Unix()
+		}
+	}
+
+	commit, err := gitRepo.GetBranchCommit(form.TargetCommitish)
+	if err != nil {
+		c.ServerError("GetBranchCommit", err)
+		return
+	}
+	commitsCount, err := commit.CommitsCount()
+	if err != nil {
+		c.ServerError("CommitsCount", err)
+		return
+	}
+
+	rel := &db.Release{
+		RepoID:       c.Repo.Repository.ID,
+		PublisherID:  c.User.ID,
+		Title:        form.Name,
+		TagName:      form.TagName,
+		Target:       form.TargetCommitish,
+		Sha1:         commit.ID.String(),
+		NumCommits:   commitsCount,
+		Note:         form.Body,
+		IsDraft:      form.Draft,
+		IsPrerelease: form.Prerelease,
+		CreatedUnix:  tagCreatedUnix,
+	}
+	if err = db.NewRelease(gitRepo, rel, nil); err != nil {
+		switch {
+		case db.IsErrReleaseAlreadyExist(err),
+			db.IsErrInvalidTagName(err),
+			errors.IsRefNameNotAllowed(err):
+			c.Error(http.StatusUnprocessableEntity, "", err)
+		default:
+			c.ServerError("NewRelease", err)
+		}
+		return
+	}
+
+	rel, err = db.GetReleaseByID(rel.ID)
+	if err != nil {
+		c.ServerError("GetReleaseByID", err)
+		return
+	}
+	c.JSON(http.StatusCreated, toRelease(rel))
+}
+
+func EditRelease(c *context.APIContext, form EditReleaseOption) {
+	rel := getRelease(c)
+	if c.Written() {
+		return
+	}
+
+	isPublish := false
+	if form.Name != nil {
+		rel.Title = *form.Name
+	}
+	if form.Body != nil {
+		rel.Note = *form.Body
+	}
+	if form.Draft != nil {
+		isPublish = rel.IsDraft && !*form.Draft
+		rel.IsDraft = *form.Draft
+	}
+	if form.Prerelease != nil {
+		rel.IsPrerelease = *form.Prerelease
+	}
+
+	gitRepo, err := git.OpenRepository(c.Repo.Repository.RepoPath())
+	if err != nil {
+		c.ServerError("OpenRepository", err)
+		return
+	}
+
+	uuids := make([]string, len(rel.Attachments))
+	for i := range rel.Attachments {
+		uuids[i] = rel.Attachments[i].UUID
+	}
+	if err = db.UpdateRelease(c.User, gitRepo, rel, isPublish, uuids); err != nil {
+		c.ServerError("UpdateRelease", err)
+		return
+	}
+
+	rel, err = db.GetReleaseByID(rel.ID)
+	if err != nil {
+		c.ServerError("GetReleaseByID", err)
+		return
+	}
+	c.JSONSuccess(toRelease(rel))
+}
+
+func DeleteRelease(c *context.APIContext) {
+	rel := getRelease(c)
+	if c.Written() {
+		return
+	}
+
+	if err := db.DeleteReleaseOfRepoByID(c.Repo.Repository.ID, rel.ID); err != nil {
+		c.ServerError("DeleteReleaseOfRepoByID", err)
+		return
+	}
+	c.NoContent()
+}
+
+func ListReleaseAssets(c *context.APIContext) {
+	rel := getRelease(c)
+	if c.Written() {
+		return
+	}
+	c.JSONSuccess(toRelease(rel).Assets)
+}
+
+// getReleaseAsset returns the asset of the release by ":asset_id" parameter.
+func getReleaseAsset(c *context.APIContext) *db.Attachment {
+	rel := getRelease(c)
+	if c.Written() {
+		return nil
+	}
+
+	assetID := c.ParamsInt64(":asset_id")
+	for _, a := range rel.Attachments {
+		if a.ID == assetID {
+			return a
+		}
+	}
+	c.NotFound()
+	return nil
+}
+
+func GetReleaseAsset(c *context.APIContext) {
+	asset := getReleaseAsset(c)
+	if c.Written() {
+		return
+	}
+	c.JSONSuccess(toReleaseAsset(asset))
+}
+
+// isAttachmentTypeAllowed returns true if the content type detected from the head of
+// a file is one of allowed types.
+func isAttachmentTypeAllowed(head []byte, allowedTypes []string) bool {
+	fileType := http.DetectContentType(head)
+	for _, t := range allowedTypes {
+		t = strings.TrimSpace(t)
+		if t == "*/*" || t == fileType {
+			return true
+		}
+	}
+	return false
+}
+
+// UploadReleaseAsset uploads a new asset to the release from the "attachment" field
+// of multipart form, the "name" query parameter overrides the file name.
+func UploadReleaseAsset(c *context.APIContext) {
+	if !conf.Release.Attachment.Enabled {
+		c.NotFound()
+		return
+	}
+
+	rel := getRelease(c)
+	if c.Written() {
+		return
+	}
+
+	file, header, err := c.Req.FormFile("attachment")
+	if err != nil {
+		c.Error(http.StatusUnprocessableEntity, "", fmt.Sprintf("read attachment: %v", err))
+		return
+	}
+	defer file.Close()
+
+	if header.Size > conf.Release.Attachment.MaxSize<<20 {
+		c.Error(http.StatusRequestEntityTooLarge, "", fmt.Sprintf("attachment exceeds the max size of %d MB", conf.Release.Attachment.MaxSize))
+		return
+	}
+
+	buf := make([]byte, 1024)
+	n, _ := file.Read(buf)
+	if !isAttachmentTypeAllowed(buf[:n], conf.Release.Attachment.AllowedTypes) {
+		c.Error(http.StatusUnprocessableEntity, "", "file type is not allowed")
+		return
+	} else if _, err = file.Seek(0, io.SeekStart); err != nil {
+		c.ServerError("Seek", err)
+		return
+	}
+
+	name := c.Query("name")
+	if name == "" {
+		name = header.Filename
+	}
+	asset, err := db.NewReleaseAttachment(rel.ID, name, file, header.Size)
+	if err != nil {
+		c.ServerError("NewReleaseAttachment", err)
+		return
+	}
+	c.JSON(http.StatusCreated, toReleaseAsset(asset))
+}
+
+func EditReleaseAsset(c *context.APIContext, form EditReleaseAssetOption) {
+	asset := getReleaseAsset(c)
+	if c.Written() {
+		return
+	}
+
+	asset.Name = form.Name
+	if err := db.UpdateAttachmentName(asset); err != nil {
+		c.ServerError("UpdateAttachmentName", err)
+		return
+	}
+	c.JSONSuccess(toReleaseAsset(asset))
+}
+
+func DeleteReleaseAsset(c *context.APIContext) {
+	asset := getReleaseAsset(c)
+	if c.Written() {
+		return
+	}
+
+	if err := db.DeleteAttachment(asset, true); err != nil {
+		c.ServerError("DeleteAttachment", err)
+		return
+	}
+	c.NoContent()
+}
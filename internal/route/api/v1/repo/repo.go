@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"net/http"
 	"path"
+	"time"
 
 	log "unknwon.dev/clog/v2"
 
@@ -24,7 +25,9 @@ import (
 func Search(c *context.APIContext) {
 	opts := &db.SearchRepoOptions{
 		Keyword:  path.Base(c.Query("q")),
+		Topic:    c.QueryTrim("topic"),
 		OwnerID:  c.QueryInt64("uid"),
+		UserID:   c.UserID(),
 		PageSize: convert.ToCorrectPageSize(c.QueryInt("limit")),
 		Page:     c.QueryInt("page"),
 	}
@@ -66,9 +69,9 @@ func Search(c *context.APIContext) {
 		return
 	}
 
-	results := make([]*api.Repository, len(repos))
+	results := make([]*db.APIRepository, len(repos))
 	for i := range repos {
-		results[i] = repos[i].APIFormat(nil)
+		results[i] = repos[i].ExtendedAPIFormat(nil)
 	}
 
 	c.SetLinkHeader(int(count), opts.PageSize)
@@ -94,6 +97,7 @@ func listUserRepositories(c *context.APIContext, username string) {
 		ownRepos, err = db.GetUserRepositories(&db.UserRepoOptions{
 			UserID:   user.ID,
 			Private:  c.User.ID == user.ID,
+			Internal: true,
 			Page:     1,
 			PageSize: user.NumRepos,
 		})
@@ -110,9 +114,9 @@ func listUserRepositories(c *context.APIContext, username string) {
 
 	// Early return for querying other user's repositories
 	if c.User.ID != user.ID {
-		repos := make([]*api.Repository, len(ownRepos))
+		repos := make([]*db.APIRepository, len(ownRepos))
 		for i := range ownRepos {
-			repos[i] = ownRepos[i].APIFormat(&api.Permission{true, true, true})
+			repos[i] = ownRepos[i].ExtendedAPIFormat(&api.Permission{true, true, true})
 		}
 		c.JSONSuccess(&repos)
 		return
@@ -125,14 +129,14 @@ func listUserRepositories(c *context.APIContext, username string) {
 	}
 
 	numOwnRepos := len(ownRepos)
-	repos := make([]*api.Repository, numOwnRepos+len(accessibleRepos))
+	repos := make([]*db.APIRepository, numOwnRepos+len(accessibleRepos))
 	for i := range ownRepos {
-		repos[i] = ownRepos[i].APIFormat(&api.Permission{true, true, true})
+		repos[i] = ownRepos[i].ExtendedAPIFormat(&api.Permission{true, true, true})
 	}
 
 	i := numOwnRepos
 	for repo, access := range accessibleRepos {
-		repos[i] = repo.APIFormat(&api.Permission{
+		repos[i] = repo.ExtendedAPIFormat(&api.Permission{
 			Admin: access >= db.ACCESS_MODE_ADMIN,
 			Push:  access >= db.ACCESS_MODE_WRITE,
 			Pull:  true,
@@ -155,7 +159,14 @@ func ListOrgRepositories(c *context.APIContext) {
 	listUserRepositories(c, c.Params(":org"))
 }
 
-func CreateUserRepo(c *context.APIContext, owner *db.User, opt api.CreateRepoOption) {
+// CreateRepoOption is the option of creating a repository which includes attributes
+// that are not available in the SDK.
+type CreateRepoOption struct {
+	api.CreateRepoOption
+	Internal bool `json:"internal"`
+}
+
+func CreateUserRepo(c *context.APIContext, owner *db.User, opt CreateRepoOption) {
 	repo, err := db.CreateRepository(c.User, owner, db.CreateRepoOptions{
 		Name:        opt.Name,
 		Description: opt.Description,
@@ -163,12 +174,14 @@ func CreateUserRepo(c *context.APIContext, owner *db.User, opt api.CreateRepoOpt
 		License:     opt.License,
 		Readme:      opt.Readme,
 		IsPrivate:   opt.Private,
+		IsInternal:  opt.Internal,
 		AutoInit:    opt.AutoInit,
 	})
 	if err != nil {
 		if db.IsErrRepoAlreadyExist(err) ||
 			db.IsErrNameReserved(err) ||
-			db.IsErrNamePatternNotAllowed(err) {
+			db.IsErrNamePatternNotAllowed(err) ||
+			errors.IsLicenseNotAllowed(err) {
 			c.Error(http.StatusUnprocessableEntity, "", err)
 		} else {
 			if repo != nil {
@@ -181,10 +194,10 @@ func CreateUserRepo(c *context.APIContext, owner *db.User, opt api.CreateRepoOpt
 		return
 	}
 
-	c.JSON(201, repo.APIFormat(&api.Permission{true, true, true}))
+	c.JSON(201, repo.ExtendedAPIFormat(&api.Permission{true, true, true}))
 }
 
-func Create(c *context.APIContext, opt api.CreateRepoOption) {
+func Create(c *context.APIContext, opt CreateRepoOption) {
 	// Shouldn't reach this condition, but just in case.
 	if c.User.IsOrganization() {
 		c.Error(http.StatusUnprocessableEntity, "", "not allowed creating repository for organization")
@@ -193,7 +206,7 @@ func Create(c *context.APIContext, opt api.CreateRepoOption) {
 	CreateUserRepo(c, c.User, opt)
 }
 
-func CreateOrgRepo(c *context.APIContext, opt api.CreateRepoOption) {
+func CreateOrgRepo(c *context.APIContext, opt CreateRepoOption) {
 	org, err := db.GetOrgByName(c.Params(":org"))
 	if err != nil {
 		c.NotFoundOrServerError("GetOrgByName", errors.IsUserNotExist, err)
@@ -207,38 +220,48 @@ func CreateOrgRepo(c *context.APIContext, opt api.CreateRepoOption) {
 	CreateUserRepo(c, org, opt)
 }
 
-func Migrate(c *context.APIContext, f form.MigrateRepo) {
+// parseNewRepoOwner returns the user or organization of given ID that current user
+// creates a new repository for.
+func parseNewRepoOwner(c *context.APIContext, uid int64) *db.User {
 	ctxUser := c.User
 	// Not equal means context user is an organization,
 	// or is another user/organization if current user is admin.
-	if f.Uid != ctxUser.ID {
-		org, err := db.GetUserByID(f.Uid)
+	if uid != ctxUser.ID {
+		org, err := db.GetUserByID(uid)
 		if err != nil {
 			if errors.IsUserNotExist(err) {
 				c.Error(http.StatusUnprocessableEntity, "", err)
 			} else {
 				c.Error(http.StatusInternalServerError, "GetUserByID", err)
 			}
-			return
+			return nil
 		} else if !org.IsOrganization() && !c.User.IsAdmin {
 			c.Error(http.StatusForbidden, "", "given user is not an organization")
-			return
+			return nil
 		}
 		ctxUser = org
 	}
 
 	if c.HasError() {
 		c.Error(http.StatusUnprocessableEntity, "", c.GetErrMsg())
-		return
+		return nil
 	}
 
 	if ctxUser.IsOrganization() && !c.User.IsAdmin {
 		// Check ownership of organization.
 		if !ctxUser.IsOwnedBy(c.User.ID) {
 			c.Error(http.StatusForbidden, "", "Given user is not owner of organization")
-			return
+			return nil
 		}
 	}
+	return ctxUser
+}
+
+func Migrate(c *context.APIContext, f form.MigrateRepo) {
+	ctxUser := parseNewRepoOwner(c, f.Uid)
+	if c.Written() {
+		return
+	}
 
 	remoteAddr, err := f.ParseRemoteAddr(c.User)
 	if err != nil {
@@ -266,6 +289,7 @@ func Migrate(c *context.APIContext, f form.MigrateRepo) {
 		IsPrivate:   f.Private || conf.Repository.ForcePrivate,
 		IsMirror:    f.Mirror,
 		RemoteAddr:  remoteAddr,
+		Migration:   f.MigrationOptions(),
 	})
 	if err != nil {
 		if repo != nil {
@@ -283,7 +307,61 @@ func Migrate(c *context.APIContext, f form.MigrateRepo) {
 	}
 
 	log.Trace("Repository migrated: %s/%s", ctxUser.Name, f.RepoName)
-	c.JSON(201, repo.APIFormat(&api.Permission{true, true, true}))
+	c.JSON(201, repo.ExtendedAPIFormat(&api.Permission{true, true, true}))
+}
+
+// Import creates a new repository from an archive exported by Gogs.
+func Import(c *context.APIContext, f form.ImportRepo) {
+	ctxUser := parseNewRepoOwner(c, f.Uid)
+	if c.Written() {
+		return
+	}
+
+	archive, err := f.Archive.Open()
+	if err != nil {
+		c.ServerError("Open", err)
+		return
+	}
+	defer archive.Close()
+
+	repo, err := db.ImportRepository(c.User, ctxUser, db.ImportRepoOptions{
+		Name:      f.RepoName,
+		IsPrivate: f.Private || conf.Repository.ForcePrivate,
+		Archive:   archive,
+		Size:      f.Archive.Size,
+	})
+	if err != nil {
+		if repo != nil {
+			if errDelete := db.DeleteRepository(ctxUser.ID, repo.ID); errDelete != nil {
+				log.Error("DeleteRepository: %v", errDelete)
+			}
+		}
+
+		switch {
+		case errors.IsInvalidRepoArchive(err),
+			errors.IsReachLimitOfRepo(err),
+			db.IsErrRepoAlreadyExist(err),
+			db.IsErrNameReserved(err),
+			db.IsErrNamePatternNotAllowed(err):
+			c.Error(http.StatusUnprocessableEntity, "", err)
+		default:
+			c.ServerError("ImportRepository", err)
+		}
+		return
+	}
+
+	log.Trace("Repository imported: %s/%s", ctxUser.Name, repo.Name)
+	c.JSON(http.StatusCreated, repo.ExtendedAPIFormat(&api.Permission{Admin: true, Push: true, Pull: true}))
+}
+
+// Export writes an archive of the repository that can be imported into another instance.
+func Export(c *context.APIContext) {
+	repo := c.Repo.Repository
+	c.Header().Set("Content-Type", "application/zip")
+	c.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.zip"`, repo.Name, time.Now().Format("20060102150405")))
+	if err := db.ExportRepository(repo, c.Resp); err != nil {
+		log.Error("Failed to export repository %d: %v", repo.ID, err)
+	}
 }
 
 // FIXME: inject in the handler chain
@@ -313,9 +391,9 @@ func Get(c *context.APIContext) {
 		return
 	}
 
-	c.JSONSuccess(repo.APIFormat(&api.Permission{
+	c.JSONSuccess(repo.ExtendedAPIFormat(&api.Permission{
 		Admin: c.Repo.IsAdmin(),
-		Push:  c.Repo.IsWriter(),
+		Push:  c.Repo.CanWrite(db.REPO_UNIT_CODE),
 		Pull:  true,
 	}))
 }
@@ -331,8 +409,8 @@ func Delete(c *context.APIContext) {
 		return
 	}
 
-	if err := db.DeleteRepository(owner.ID, repo.ID); err != nil {
-		c.ServerError("DeleteRepository", err)
+	if err := db.DeleteRepositoryByUser(c.User, repo); err != nil {
+		c.ServerError("DeleteRepositoryByUser", err)
 		return
 	}
 
@@ -340,6 +418,36 @@ func Delete(c *context.APIContext) {
 	c.NoContent()
 }
 
+func setArchived(c *context.APIContext, archived bool) {
+	if !c.Repo.IsOwner() {
+		c.Status(http.StatusForbidden)
+		return
+	}
+
+	repo := c.Repo.Repository
+	if err := db.SetRepositoryArchived(c.User, repo, archived); err != nil {
+		c.ServerError("SetRepositoryArchived", err)
+		return
+	}
+	log.Trace("Repository archive status changed: %s/%s -> %v", c.Repo.Owner.Name, repo.Name, archived)
+
+	c.JSONSuccess(repo.ExtendedAPIFormat(&api.Permission{
+		Admin: c.Repo.IsAdmin(),
+		Push:  c.Repo.CanWrite(db.REPO_UNIT_CODE),
+		Pull:  true,
+	}))
+}
+
+// Archive marks the repository as archived and read-only.
+func Archive(c *context.APIContext) {
+	setArchived(c, true)
+}
+
+// Unarchive makes the archived repository writable again.
+func Unarchive(c *context.APIContext) {
+	setArchived(c, false)
+}
+
 func ListForks(c *context.APIContext) {
 	forks, err := c.Repo.Repository.GetForks()
 	if err != nil {
@@ -347,13 +455,13 @@ func ListForks(c *context.APIContext) {
 		return
 	}
 
-	apiForks := make([]*api.Repository, len(forks))
+	apiForks := make([]*db.APIRepository, len(forks))
 	for i := range forks {
 		if err := forks[i].GetOwner(); err != nil {
 			c.ServerError("GetOwner", err)
 			return
 		}
-		apiForks[i] = forks[i].APIFormat(&api.Permission{
+		apiForks[i] = forks[i].ExtendedAPIFormat(&api.Permission{
 			Admin: c.User.IsAdminOfRepo(forks[i]),
 			Push:  c.User.IsWriterOfRepo(forks[i]),
 			Pull:  true,
@@ -0,0 +1,19 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package repo
+
+import (
+	"gogs.io/gogs/internal/context"
+)
+
+// GetStarHistory returns daily numbers of stars of the repository since it was first starred.
+func GetStarHistory(c *context.APIContext) {
+	history, err := c.Repo.Repository.StarHistory()
+	if err != nil {
+		c.ServerError("StarHistory", err)
+		return
+	}
+	c.JSONSuccess(&history)
+}
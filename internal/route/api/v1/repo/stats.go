@@ -0,0 +1,80 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package repo
+
+import (
+	"net/http"
+
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+)
+
+// getGraphStats returns graph statistics of the repository, it responds with 202 and
+// returns nil if statistics are being computed.
+func getGraphStats(c *context.APIContext) *db.RepoGraphStats {
+	stats, err := db.GetRepoGraphStats(c.Repo.Repository)
+	if err != nil {
+		c.ServerError("GetRepoGraphStats", err)
+		return nil
+	} else if stats == nil {
+		c.Status(http.StatusAccepted)
+		return nil
+	}
+	return stats
+}
+
+func ListContributorStats(c *context.APIContext) {
+	if c.Repo.Repository.IsBare {
+		c.JSONSuccess([]*db.ContributorStats{})
+		return
+	}
+
+	stats := getGraphStats(c)
+	if stats == nil {
+		return
+	}
+	contributors, err := stats.GetContributors()
+	if err != nil {
+		c.ServerError("GetContributors", err)
+		return
+	}
+	c.JSONSuccess(contributors)
+}
+
+func ListCommitActivity(c *context.APIContext) {
+	if c.Repo.Repository.IsBare {
+		c.JSONSuccess([]*db.CommitActivity{})
+		return
+	}
+
+	stats := getGraphStats(c)
+	if stats == nil {
+		return
+	}
+	activity, err := stats.GetCommitActivity()
+	if err != nil {
+		c.ServerError("GetCommitActivity", err)
+		return
+	}
+	c.JSONSuccess(activity)
+}
+
+func ListCodeFrequency(c *context.APIContext) {
+	if c.Repo.Repository.IsBare {
+		c.JSONSuccess([]*db.CodeFrequency{})
+		return
+	}
+
+	stats := getGraphStats(c)
+	if stats == nil {
+		return
+	}
+	frequency, err := stats.GetCodeFrequency()
+	if err != nil {
+		c.ServerError("GetCodeFrequency", err)
+		return
+	}
+	c.JSONSuccess(frequency)
+}
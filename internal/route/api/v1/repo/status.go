@@ -0,0 +1,159 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package repo
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gogs/git-module"
+	api "github.com/gogs/go-gogs-client"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+)
+
+// CommitStatus is the API format of a status of a commit, which is compatible with
+// clients of the commit status API of GitHub.
+type CommitStatus struct {
+	ID          int64     `json:"id"`
+	State       string    `json:"state"`
+	TargetURL   string    `json:"target_url"`
+	Description string    `json:"description"`
+	Context     string    `json:"context"`
+	Creator     *api.User `json:"creator"`
+	Created     time.Time `json:"created_at"`
+	Updated     time.Time `json:"updated_at"`
+	URL         string    `json:"url"`
+}
+
+// CombinedCommitStatus is the combined state of current statuses of all contexts of
+// a commit.
+type CombinedCommitStatus struct {
+	State      string          `json:"state"`
+	SHA        string          `json:"sha"`
+	TotalCount int             `json:"total_count"`
+	Statuses   []*CommitStatus `json:"statuses"`
+	Repository *api.Repository `json:"repository"`
+	CommitURL  string          `json:"commit_url"`
+	URL        string          `json:"url"`
+}
+
+type CreateCommitStatusOption struct {
+	State       string `json:"state" binding:"Required"`
+	TargetURL   string `json:"target_url" binding:"OmitEmpty;Url"`
+	Description string `json:"description"`
+	Context     string `json:"context"`
+}
+
+func toCommitStatus(c *context.APIContext, s *db.CommitStatus) *CommitStatus {
+	return &CommitStatus{
+		ID:          s.ID,
+		State:       string(s.State),
+		TargetURL:   s.TargetURL,
+		Description: s.Description,
+		Context:     s.Context,
+		Creator:     s.Creator.APIFormat(),
+		Created:     s.Created,
+		Updated:     s.Created,
+		URL:         fmt.Sprintf("%s/repos/%s/statuses/%s", c.BaseURL, c.Repo.Repository.FullName(), s.SHA),
+	}
+}
+
+// getStatusCommitID returns the full commit ID of the reference or the SHA in the
+// parameter.
+func getStatusCommitID(c *context.APIContext, param string) string {
+	gitRepo, err := git.OpenRepository(c.Repo.Repository.RepoPath())
+	if err != nil {
+		c.ServerError("OpenRepository", err)
+		return ""
+	}
+	commit, err := gitRepo.GetCommit(c.Params(param))
+	if err != nil {
+		c.NotFoundOrServerError("GetCommit", git.IsErrNotExist, err)
+		return ""
+	}
+	return commit.ID.String()
+}
+
+func CreateCommitStatus(c *context.APIContext, form CreateCommitStatusOption) {
+	sha := getStatusCommitID(c, ":sha")
+	if c.Written() {
+		return
+	}
+
+	status := &db.CommitStatus{
+		State:       db.CommitStatusState(form.State),
+		TargetURL:   form.TargetURL,
+		Description: form.Description,
+		Context:     form.Context,
+	}
+	if !status.State.IsValid() {
+		c.Error(http.StatusUnprocessableEntity, "", fmt.Sprintf("Invalid state %q", form.State))
+		return
+	}
+	if err := db.CreateCommitStatus(c.Repo.Repository, c.User, sha, status); err != nil {
+		c.ServerError("CreateCommitStatus", err)
+		return
+	}
+	c.JSON(http.StatusCreated, toCommitStatus(c, status))
+}
+
+func listCommitStatuses(c *context.APIContext, param string) {
+	sha := getStatusCommitID(c, param)
+	if c.Written() {
+		return
+	}
+
+	statuses, err := db.GetCommitStatuses([]int64{c.Repo.Repository.ID}, sha)
+	if err != nil {
+		c.ServerError("GetCommitStatuses", err)
+		return
+	}
+
+	apiStatuses := make([]*CommitStatus, len(statuses))
+	for i := range statuses {
+		apiStatuses[i] = toCommitStatus(c, statuses[i])
+	}
+	c.JSONSuccess(&apiStatuses)
+}
+
+func ListCommitStatuses(c *context.APIContext) {
+	listCommitStatuses(c, ":sha")
+}
+
+func ListCommitStatusesByRef(c *context.APIContext) {
+	listCommitStatuses(c, ":ref")
+}
+
+func GetCombinedCommitStatus(c *context.APIContext) {
+	sha := getStatusCommitID(c, ":ref")
+	if c.Written() {
+		return
+	}
+
+	checks, err := db.GetCommitChecks([]int64{c.Repo.Repository.ID}, sha)
+	if err != nil {
+		c.ServerError("GetCommitChecks", err)
+		return
+	}
+
+	statuses := make([]*CommitStatus, len(checks))
+	for i := range checks {
+		statuses[i] = toCommitStatus(c, checks[i].Latest)
+	}
+	repoURL := c.BaseURL + "/repos/" + c.Repo.Repository.FullName()
+	c.JSONSuccess(&CombinedCommitStatus{
+		State:      string(db.CombineCommitChecks(checks)),
+		SHA:        sha,
+		TotalCount: len(statuses),
+		Statuses:   statuses,
+		Repository: c.Repo.Repository.APIFormat(nil),
+		CommitURL:  repoURL + "/commits/" + sha,
+		URL:        conf.Server.ExternalURL + c.Link[1:],
+	})
+}
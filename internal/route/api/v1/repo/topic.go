@@ -0,0 +1,85 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package repo
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+)
+
+// Topics is the API format of topics of a repository.
+type Topics struct {
+	Topics []string `json:"topics"`
+}
+
+type ReplaceTopicsOption struct {
+	Topics []string `json:"topics"`
+}
+
+// saveTopics validates and saves topics of the repository, then responses with the
+// saved topics.
+func saveTopics(c *context.APIContext, names []string) {
+	topics, invalid := db.SanitizeTopics(names)
+	if len(invalid) > 0 {
+		c.Error(http.StatusUnprocessableEntity, "", fmt.Sprintf("Invalid topics: %s", strings.Join(invalid, ", ")))
+		return
+	} else if len(topics) > db.MaxTopicsPerRepo {
+		c.Error(http.StatusUnprocessableEntity, "", fmt.Sprintf("Repository cannot have more than %d topics", db.MaxTopicsPerRepo))
+		return
+	}
+
+	if err := db.SaveRepoTopics(c.Repo.Repository.ID, topics); err != nil {
+		c.ServerError("SaveRepoTopics", err)
+		return
+	}
+	c.JSONSuccess(&Topics{Topics: topics})
+}
+
+func ListTopics(c *context.APIContext) {
+	topics, err := db.GetRepoTopicNames(c.Repo.Repository.ID)
+	if err != nil {
+		c.ServerError("GetRepoTopicNames", err)
+		return
+	}
+	c.JSONSuccess(&Topics{Topics: topics})
+}
+
+func ReplaceTopics(c *context.APIContext, form ReplaceTopicsOption) {
+	saveTopics(c, form.Topics)
+}
+
+func AddTopic(c *context.APIContext) {
+	topics, err := db.GetRepoTopicNames(c.Repo.Repository.ID)
+	if err != nil {
+		c.ServerError("GetRepoTopicNames", err)
+		return
+	}
+	saveTopics(c, append(topics, c.Params(":topic")))
+}
+
+func DeleteTopic(c *context.APIContext) {
+	topics, err := db.GetRepoTopicNames(c.Repo.Repository.ID)
+	if err != nil {
+		c.ServerError("GetRepoTopicNames", err)
+		return
+	}
+
+	name := strings.ToLower(c.Params(":topic"))
+	remains := make([]string, 0, len(topics))
+	for _, topic := range topics {
+		if topic != name {
+			remains = append(remains, topic)
+		}
+	}
+	if len(remains) == len(topics) {
+		c.NotFound()
+		return
+	}
+	saveTopics(c, remains)
+}
@@ -0,0 +1,102 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package user
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	api "github.com/gogs/go-gogs-client"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+)
+
+type BatchGetUsersOption struct {
+	IDs       []int64  `json:"ids"`
+	Usernames []string `json:"usernames"`
+}
+
+type BatchGetUsersResult struct {
+	Users            []*api.User `json:"users"`
+	MissingIDs       []int64     `json:"missing_ids"`
+	MissingUsernames []string    `json:"missing_usernames"`
+}
+
+// BatchGetUsers returns users by IDs and usernames in one request, users are in the
+// order of given IDs followed by usernames without duplicates. IDs and usernames that
+// do not belong to any visible user are returned separately.
+func BatchGetUsers(c *context.APIContext, form BatchGetUsersOption) {
+	if len(form.IDs)+len(form.Usernames) > conf.API.MaxResponseItems {
+		c.Error(http.StatusUnprocessableEntity, "", fmt.Sprintf("at most %d IDs and usernames are allowed in total", conf.API.MaxResponseItems))
+		return
+	}
+
+	usersByID, err := db.GetUsersByIDs(form.IDs)
+	if err != nil {
+		c.ServerError("GetUsersByIDs", err)
+		return
+	}
+	usersByName, err := db.GetUsersByNames(form.Usernames)
+	if err != nil {
+		c.ServerError("GetUsersByNames", err)
+		return
+	}
+
+	// Deactivated users are invisible to non-admins as if they do not exist.
+	visible := func(u *db.User) bool {
+		return !u.IsDeactivated || (c.IsLogged && c.User.IsAdmin)
+	}
+	idToUser := make(map[int64]*db.User, len(usersByID))
+	for _, u := range usersByID {
+		if visible(u) {
+			idToUser[u.ID] = u
+		}
+	}
+	nameToUser := make(map[string]*db.User, len(usersByName))
+	for _, u := range usersByName {
+		if visible(u) {
+			nameToUser[u.LowerName] = u
+		}
+	}
+
+	result := &BatchGetUsersResult{
+		Users:            make([]*api.User, 0, len(form.IDs)+len(form.Usernames)),
+		MissingIDs:       []int64{},
+		MissingUsernames: []string{},
+	}
+	added := make(map[int64]bool)
+	add := func(u *db.User) {
+		if added[u.ID] {
+			return
+		}
+		added[u.ID] = true
+
+		apiUser := u.APIFormat()
+		// Hide user e-mail when API caller isn't signed in.
+		if !c.IsLogged {
+			apiUser.Email = ""
+		}
+		result.Users = append(result.Users, apiUser)
+	}
+
+	for _, id := range form.IDs {
+		if u, ok := idToUser[id]; ok {
+			add(u)
+		} else {
+			result.MissingIDs = append(result.MissingIDs, id)
+		}
+	}
+	for _, name := range form.Usernames {
+		if u, ok := nameToUser[strings.ToLower(name)]; ok {
+			add(u)
+		} else {
+			result.MissingUsernames = append(result.MissingUsernames, name)
+		}
+	}
+	c.JSONSuccess(result)
+}
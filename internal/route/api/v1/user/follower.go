@@ -25,6 +25,7 @@ func listUserFollowers(c *context.APIContext, u *db.User) {
 		c.ServerError("GetUserFollowers", err)
 		return
 	}
+	c.SetLinkHeader(u.NumFollowers, db.ItemsPerPage)
 	responseApiUsers(c, users)
 }
 
@@ -46,6 +47,7 @@ func listUserFollowing(c *context.APIContext, u *db.User) {
 		c.ServerError("GetFollowing", err)
 		return
 	}
+	c.SetLinkHeader(u.NumFollowing, db.ItemsPerPage)
 	responseApiUsers(c, users)
 }
 
@@ -0,0 +1,239 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package user
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	api "github.com/gogs/go-gogs-client"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/db/errors"
+	"gogs.io/gogs/internal/route/api/v1/convert"
+)
+
+// NotificationSubject is the issue or pull request of a notification thread.
+type NotificationSubject struct {
+	Title                string `json:"title"`
+	URL                  string `json:"url"`
+	HTMLURL              string `json:"html_url"`
+	LatestCommentURL     string `json:"latest_comment_url"`
+	LatestCommentHTMLURL string `json:"latest_comment_html_url"`
+	Type                 string `json:"type"`
+	State                string `json:"state"`
+}
+
+// NotificationThread is the API format of a notification, which is compatible with
+// clients of the notifications API of Gitea.
+type NotificationThread struct {
+	ID         int64                `json:"id"`
+	Repository *api.Repository      `json:"repository"`
+	Subject    *NotificationSubject `json:"subject"`
+	Unread     bool                 `json:"unread"`
+	Pinned     bool                 `json:"pinned"`
+	Reason     string               `json:"reason"`
+	UpdatedAt  time.Time            `json:"updated_at"`
+	URL        string               `json:"url"`
+}
+
+func toNotificationThread(n *db.Notification) *NotificationThread {
+	repoURL := conf.Server.ExternalURL + "api/v1/repos/" + n.Repo.FullName()
+	subject := &NotificationSubject{
+		Title:   n.Issue.Title,
+		URL:     fmt.Sprintf("%s/issues/%d", repoURL, n.Issue.Index),
+		HTMLURL: n.Issue.HTMLURL(),
+		Type:    "Issue",
+		State:   string(n.Issue.State()),
+	}
+	if n.Issue.IsPull {
+		subject.URL = fmt.Sprintf("%s/pulls/%d", repoURL, n.Issue.Index)
+		subject.Type = "Pull"
+	}
+	if n.CommentID > 0 {
+		subject.LatestCommentURL = fmt.Sprintf("%s/issues/comments/%d", repoURL, n.CommentID)
+		subject.LatestCommentHTMLURL = n.HTMLURL()
+	}
+
+	return &NotificationThread{
+		ID:         n.ID,
+		Repository: n.Repo.APIFormat(nil),
+		Subject:    subject,
+		Unread:     n.Status == db.NOTIFICATION_STATUS_UNREAD,
+		Reason:     n.Reason.Name(),
+		UpdatedAt:  n.Updated,
+		URL:        fmt.Sprintf("%sapi/v1/notifications/threads/%d", conf.Server.ExternalURL, n.ID),
+	}
+}
+
+// parseNotificationStatuses returns statuses of the "status-types" parameter, or the
+// default statuses when it is not given. It writes the response when any status is
+// invalid.
+func parseNotificationStatuses(c *context.APIContext, defaults ...db.NotificationStatus) []db.NotificationStatus {
+	names := c.QueryStrings("status-types")
+	if len(names) == 0 {
+		return defaults
+	}
+
+	statuses := make([]db.NotificationStatus, 0, len(names))
+	for _, name := range names {
+		status := db.ParseNotificationStatus(name)
+		if status == 0 {
+			c.Error(http.StatusUnprocessableEntity, "", fmt.Sprintf("Invalid status type %q", name))
+			return nil
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// parseNotificationToStatus returns the status of the "to-status" parameter, which
+// is read by default. It writes the response when the status is invalid.
+func parseNotificationToStatus(c *context.APIContext) db.NotificationStatus {
+	name := c.QueryTrim("to-status")
+	if name == "" {
+		return db.NOTIFICATION_STATUS_READ
+	}
+	status := db.ParseNotificationStatus(name)
+	if status == 0 {
+		c.Error(http.StatusUnprocessableEntity, "", fmt.Sprintf("Invalid status type %q", name))
+	}
+	return status
+}
+
+// parseNotificationTime returns Unix time of the RFC 3339 time of the parameter, or
+// zero when it is not given. It writes the response when the time is invalid.
+func parseNotificationTime(c *context.APIContext, name string) int64 {
+	value := c.QueryTrim(name)
+	if value == "" {
+		return 0
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		c.Error(http.StatusUnprocessableEntity, "", fmt.Sprintf("Invalid time of %q: %v", name, err))
+		return 0
+	}
+	return t.Unix()
+}
+
+func listNotifications(c *context.APIContext, repoID int64) {
+	opts := &db.NotificationsOptions{
+		UserID:   c.User.ID,
+		RepoID:   repoID,
+		Page:     c.QueryInt("page"),
+		PageSize: convert.ToCorrectPageSize(c.QueryInt("limit")),
+	}
+	if !c.QueryBool("all") {
+		opts.Statuses = parseNotificationStatuses(c, db.NOTIFICATION_STATUS_UNREAD)
+	}
+	opts.UpdatedAfter = parseNotificationTime(c, "since")
+	opts.UpdatedBefore = parseNotificationTime(c, "before")
+	if c.Written() {
+		return
+	}
+
+	notifications, count, err := db.Notifications(opts)
+	if err != nil {
+		c.ServerError("Notifications", err)
+		return
+	}
+
+	threads := make([]*NotificationThread, len(notifications))
+	for i := range notifications {
+		threads[i] = toNotificationThread(notifications[i])
+	}
+	c.SetLinkHeader(int(count), opts.PageSize)
+	c.JSONSuccess(&threads)
+}
+
+// readNotifications changes status of notifications updated before "last_read_at",
+// which are unread by default, to "to-status" which is read by default.
+func readNotifications(c *context.APIContext, repoID int64) {
+	opts := &db.NotificationsOptions{
+		UserID:        c.User.ID,
+		RepoID:        repoID,
+		Statuses:      parseNotificationStatuses(c, db.NOTIFICATION_STATUS_UNREAD),
+		UpdatedBefore: parseNotificationTime(c, "last_read_at"),
+	}
+	status := parseNotificationToStatus(c)
+	if c.Written() {
+		return
+	}
+	if opts.UpdatedBefore == 0 {
+		opts.UpdatedBefore = time.Now().Unix() + 1
+	}
+
+	if err := db.SetNotificationsStatus(opts, status); err != nil {
+		c.ServerError("SetNotificationsStatus", err)
+		return
+	}
+	c.Status(http.StatusResetContent)
+}
+
+func ListNotifications(c *context.APIContext) {
+	listNotifications(c, 0)
+}
+
+func ReadNotifications(c *context.APIContext) {
+	readNotifications(c, 0)
+}
+
+func ListRepoNotifications(c *context.APIContext) {
+	listNotifications(c, c.Repo.Repository.ID)
+}
+
+func ReadRepoNotifications(c *context.APIContext) {
+	readNotifications(c, c.Repo.Repository.ID)
+}
+
+func CountNewNotifications(c *context.APIContext) {
+	count, err := db.CountUnreadNotifications(c.User.ID)
+	if err != nil {
+		c.ServerError("CountUnreadNotifications", err)
+		return
+	}
+	c.JSONSuccess(map[string]int64{"new": count})
+}
+
+func getNotificationThread(c *context.APIContext) *db.Notification {
+	n, err := db.GetNotificationByID(c.User.ID, c.ParamsInt64(":id"))
+	if err != nil {
+		c.NotFoundOrServerError("GetNotificationByID", func(err error) bool {
+			return errors.IsNotificationNotExist(err) || errors.IsIssueNotExist(err) || errors.IsRepoNotExist(err)
+		}, err)
+		return nil
+	}
+	return n
+}
+
+func GetNotificationThread(c *context.APIContext) {
+	n := getNotificationThread(c)
+	if c.Written() {
+		return
+	}
+	c.JSONSuccess(toNotificationThread(n))
+}
+
+func ReadNotificationThread(c *context.APIContext) {
+	n := getNotificationThread(c)
+	if c.Written() {
+		return
+	}
+
+	status := parseNotificationToStatus(c)
+	if c.Written() {
+		return
+	}
+
+	if err := db.SetNotificationStatus(c.User.ID, n.ID, status); err != nil {
+		c.ServerError("SetNotificationStatus", err)
+		return
+	}
+	n.Status = status
+	c.JSON(http.StatusResetContent, toNotificationThread(n))
+}
@@ -0,0 +1,83 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package user
+
+import (
+	"net/http"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/db/errors"
+)
+
+// onboardingChecklist is the onboarding checklist of the authenticated user.
+type onboardingChecklist struct {
+	Steps     []*db.OnboardingStepStatus `json:"steps"`
+	Dismissed bool                       `json:"dismissed"`
+	Completed bool                       `json:"completed"`
+}
+
+func getOnboarding(c *context.APIContext) *db.Onboarding {
+	if !conf.Onboarding.Enabled {
+		c.NotFound()
+		return nil
+	}
+
+	o, err := db.GetOnboarding(c.User.ID)
+	if err != nil {
+		c.NotFoundOrServerError("GetOnboarding", errors.IsOnboardingNotExist, err)
+		return nil
+	}
+	return o
+}
+
+func GetOnboarding(c *context.APIContext) {
+	o := getOnboarding(c)
+	if c.Written() {
+		return
+	}
+
+	steps, err := db.GetOnboardingChecklist(c.User, o)
+	if err != nil {
+		c.ServerError("GetOnboardingChecklist", err)
+		return
+	}
+	c.JSONSuccess(&onboardingChecklist{
+		Steps:     steps,
+		Dismissed: o.Dismissed,
+		Completed: o.CompletedUnix > 0,
+	})
+}
+
+func CompleteOnboardingStep(c *context.APIContext) {
+	getOnboarding(c)
+	if c.Written() {
+		return
+	}
+
+	if err := db.CompleteOnboardingStep(c.User.ID, c.Params(":step")); err != nil {
+		if errors.IsInvalidOnboardingStep(err) {
+			c.Error(http.StatusUnprocessableEntity, "", err)
+		} else {
+			c.ServerError("CompleteOnboardingStep", err)
+		}
+		return
+	}
+	c.NoContent()
+}
+
+func DismissOnboarding(c *context.APIContext) {
+	o := getOnboarding(c)
+	if c.Written() {
+		return
+	}
+
+	if err := db.DismissOnboarding(o); err != nil {
+		c.ServerError("DismissOnboarding", err)
+		return
+	}
+	c.NoContent()
+}
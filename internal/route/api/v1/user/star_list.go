@@ -0,0 +1,244 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package user
+
+import (
+	"net/http"
+	"time"
+
+	api "github.com/gogs/go-gogs-client"
+
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/db/errors"
+	"gogs.io/gogs/internal/route/api/v1/convert"
+)
+
+type starList struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	NumRepos    int       `json:"num_repos"`
+	Created     time.Time `json:"created_at"`
+	Updated     time.Time `json:"updated_at"`
+}
+
+type CreateStarListOption struct {
+	Name        string `json:"name" binding:"Required;MaxSize(50)"`
+	Description string `json:"description" binding:"MaxSize(255)"`
+}
+
+type EditStarListOption struct {
+	Name        *string `json:"name" binding:"OmitEmpty;MaxSize(50)"`
+	Description *string `json:"description" binding:"OmitEmpty;MaxSize(255)"`
+}
+
+func toStarList(l *db.StarList) *starList {
+	return &starList{
+		ID:          l.ID,
+		Name:        l.Name,
+		Description: l.Description,
+		NumRepos:    l.NumRepos,
+		Created:     l.Created,
+		Updated:     l.Updated,
+	}
+}
+
+func listStarLists(c *context.APIContext, u *db.User, private bool) {
+	lists, err := db.GetStarLists(u.ID, private)
+	if err != nil {
+		c.ServerError("GetStarLists", err)
+		return
+	}
+
+	apiLists := make([]*starList, len(lists))
+	for i := range lists {
+		apiLists[i] = toStarList(lists[i])
+	}
+	c.JSONSuccess(&apiLists)
+}
+
+func ListMyStarLists(c *context.APIContext) {
+	listStarLists(c, c.User, true)
+}
+
+func ListStarLists(c *context.APIContext) {
+	u := GetUserByParams(c)
+	if c.Written() {
+		return
+	}
+	listStarLists(c, u, c.User.ID == u.ID || c.User.IsAdmin)
+}
+
+func getStarList(c *context.APIContext) *db.StarList {
+	list, err := db.GetStarListByID(c.User.ID, c.ParamsInt64(":id"))
+	if err != nil {
+		c.NotFoundOrServerError("GetStarListByID", errors.IsStarListNotExist, err)
+		return nil
+	}
+	return list
+}
+
+func GetStarList(c *context.APIContext) {
+	list := getStarList(c)
+	if c.Written() {
+		return
+	}
+
+	count, err := db.CountStarredRepos(&db.StarredReposOptions{
+		UserID:  c.User.ID,
+		ListID:  list.ID,
+		Private: true,
+	})
+	if err != nil {
+		c.ServerError("CountStarredRepos", err)
+		return
+	}
+	list.NumRepos = int(count)
+	c.JSONSuccess(toStarList(list))
+}
+
+func CreateStarList(c *context.APIContext, form CreateStarListOption) {
+	list := &db.StarList{
+		UserID:      c.User.ID,
+		Name:        form.Name,
+		Description: form.Description,
+	}
+	if err := db.NewStarList(list); err != nil {
+		if errors.IsStarListAlreadyExist(err) {
+			c.Error(http.StatusUnprocessableEntity, "", err)
+		} else {
+			c.ServerError("NewStarList", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, toStarList(list))
+}
+
+func EditStarList(c *context.APIContext, form EditStarListOption) {
+	list := getStarList(c)
+	if c.Written() {
+		return
+	}
+
+	if form.Name != nil {
+		list.Name = *form.Name
+	}
+	if form.Description != nil {
+		list.Description = *form.Description
+	}
+	if err := db.UpdateStarList(list); err != nil {
+		if errors.IsStarListAlreadyExist(err) {
+			c.Error(http.StatusUnprocessableEntity, "", err)
+		} else {
+			c.ServerError("UpdateStarList", err)
+		}
+		return
+	}
+
+	list, err := db.GetStarListByID(c.User.ID, list.ID)
+	if err != nil {
+		c.ServerError("GetStarListByID", err)
+		return
+	}
+	c.JSONSuccess(toStarList(list))
+}
+
+func DeleteStarList(c *context.APIContext) {
+	list := getStarList(c)
+	if c.Written() {
+		return
+	}
+
+	if err := db.DeleteStarList(list); err != nil {
+		c.ServerError("DeleteStarList", err)
+		return
+	}
+	c.NoContent()
+}
+
+func ListStarListRepos(c *context.APIContext) {
+	list := getStarList(c)
+	if c.Written() {
+		return
+	}
+
+	repos, err := db.GetStarredRepos(&db.StarredReposOptions{
+		UserID:   c.User.ID,
+		ListID:   list.ID,
+		Private:  true,
+		Page:     c.QueryInt("page"),
+		PageSize: convert.ToCorrectPageSize(c.QueryInt("limit")),
+	})
+	if err != nil {
+		c.ServerError("GetStarredRepos", err)
+		return
+	}
+
+	apiRepos := make([]*api.Repository, len(repos))
+	for i := range repos {
+		apiRepos[i] = repos[i].APIFormat(nil)
+	}
+	c.JSONSuccess(&apiRepos)
+}
+
+// getStarListRepo returns the star list of current user and the repository by
+// parameters in URL.
+func getStarListRepo(c *context.APIContext) (*db.StarList, *db.Repository) {
+	list := getStarList(c)
+	if c.Written() {
+		return nil, nil
+	}
+
+	owner, err := db.GetUserByName(c.Params(":owner"))
+	if err != nil {
+		c.NotFoundOrServerError("GetUserByName", errors.IsUserNotExist, err)
+		return nil, nil
+	}
+	repo, err := db.GetRepositoryByName(owner.ID, c.Params(":reponame"))
+	if err != nil {
+		c.NotFoundOrServerError("GetRepositoryByName", errors.IsRepoNotExist, err)
+		return nil, nil
+	}
+
+	has, err := db.HasAccess(c.User.ID, repo, db.ACCESS_MODE_READ)
+	if err != nil {
+		c.ServerError("HasAccess", err)
+		return nil, nil
+	} else if !has {
+		c.NotFound()
+		return nil, nil
+	}
+	return list, repo
+}
+
+// AddStarListRepo puts the repository in the star list, the repository is starred
+// if it is not starred yet.
+func AddStarListRepo(c *context.APIContext) {
+	list, repo := getStarListRepo(c)
+	if c.Written() {
+		return
+	}
+
+	if err := list.AddRepo(repo.ID); err != nil {
+		c.ServerError("AddRepo", err)
+		return
+	}
+	c.NoContent()
+}
+
+func RemoveStarListRepo(c *context.APIContext) {
+	list, repo := getStarListRepo(c)
+	if c.Written() {
+		return
+	}
+
+	if err := list.RemoveRepo(repo.ID); err != nil {
+		c.ServerError("RemoveRepo", err)
+		return
+	}
+	c.NoContent()
+}
@@ -60,6 +60,9 @@ func GetInfo(c *context.APIContext) {
 	if err != nil {
 		c.NotFoundOrServerError("GetUserByName", errors.IsUserNotExist, err)
 		return
+	} else if u.IsDeactivated && !(c.IsLogged && c.User.IsAdmin) {
+		c.NotFound()
+		return
 	}
 
 	// Hide user e-mail when API caller isn't signed in.
@@ -16,6 +16,8 @@ import (
 const (
 	HOME                  = "home"
 	EXPLORE_REPOS         = "explore/repos"
+	EXPLORE_TRENDING      = "explore/trending"
+	EXPLORE_TOPICS        = "explore/topics"
 	EXPLORE_USERS         = "explore/users"
 	EXPLORE_ORGANIZATIONS = "explore/organizations"
 )
@@ -52,11 +54,28 @@ func ExploreRepos(c *context.Context) {
 		page = 1
 	}
 
+	prepareExploreLanguages(c)
+	if c.Written() {
+		return
+	}
+
+	orderBy := "updated_unix DESC"
+	sortType := c.Query("sort")
+	if sortType == "stars" {
+		orderBy = "num_stars DESC"
+	} else {
+		sortType = ""
+	}
+
 	keyword := c.Query("q")
+	topic := c.QueryTrim("topic")
+	language := c.QueryTrim("language")
 	repos, count, err := db.SearchRepositoryByName(&db.SearchRepoOptions{
 		Keyword:  keyword,
+		Topic:    topic,
+		Language: language,
 		UserID:   c.UserID(),
-		OrderBy:  "updated_unix DESC",
+		OrderBy:  orderBy,
 		Page:     page,
 		PageSize: conf.UI.ExplorePagingNum,
 	})
@@ -65,6 +84,9 @@ func ExploreRepos(c *context.Context) {
 		return
 	}
 	c.Data["Keyword"] = keyword
+	c.Data["Topic"] = topic
+	c.Data["Language"] = language
+	c.Data["SortType"] = sortType
 	c.Data["Total"] = count
 	c.Data["Page"] = paginater.New(int(count), conf.UI.ExplorePagingNum, page, 5)
 
@@ -72,11 +94,106 @@ func ExploreRepos(c *context.Context) {
 		c.ServerError("RepositoryList.LoadAttributes", err)
 		return
 	}
+	if err = db.RepositoryList(repos).LoadTopics(); err != nil {
+		c.ServerError("RepositoryList.LoadTopics", err)
+		return
+	}
 	c.Data["Repos"] = repos
 
 	c.Success(EXPLORE_REPOS)
 }
 
+// prepareExploreLanguages sets primary languages of repositories for filtering.
+func prepareExploreLanguages(c *context.Context) {
+	languages, err := db.GetTrendingLanguages(30)
+	if err != nil {
+		c.ServerError("GetTrendingLanguages", err)
+		return
+	}
+	c.Data["Languages"] = languages
+}
+
+func ExploreTrending(c *context.Context) {
+	c.Data["Title"] = c.Tr("explore.trending")
+	c.Data["PageIsExplore"] = true
+	c.Data["PageIsExploreRepositories"] = true
+	c.Data["PageIsExploreTrending"] = true
+
+	prepareExploreLanguages(c)
+	if c.Written() {
+		return
+	}
+
+	page := c.QueryInt("page")
+	if page <= 0 {
+		page = 1
+	}
+
+	period := db.ParseTrendingPeriod(c.Query("since"))
+	language := c.QueryTrim("language")
+	repos, count, err := db.GetTrendingRepos(&db.TrendingRepoOptions{
+		Period:   period,
+		Language: language,
+		UserID:   c.UserID(),
+		Page:     page,
+		PageSize: conf.UI.ExplorePagingNum,
+	})
+	if err != nil {
+		c.ServerError("GetTrendingRepos", err)
+		return
+	}
+	c.Data["Since"] = string(period)
+	c.Data["TrendingPeriods"] = db.TrendingPeriods
+	c.Data["Language"] = language
+	c.Data["SortType"] = ""
+	c.Data["Total"] = count
+	c.Data["Page"] = paginater.New(int(count), conf.UI.ExplorePagingNum, page, 5)
+
+	list := make(db.RepositoryList, len(repos))
+	for i := range repos {
+		list[i] = repos[i].Repository
+	}
+	if err = list.LoadAttributes(); err != nil {
+		c.ServerError("RepositoryList.LoadAttributes", err)
+		return
+	}
+	if err = list.LoadTopics(); err != nil {
+		c.ServerError("RepositoryList.LoadTopics", err)
+		return
+	}
+	c.Data["Repos"] = repos
+
+	c.Success(EXPLORE_TRENDING)
+}
+
+func ExploreTopics(c *context.Context) {
+	c.Data["Title"] = c.Tr("explore")
+	c.Data["PageIsExplore"] = true
+	c.Data["PageIsExploreTopics"] = true
+
+	count, err := db.CountPopularTopics()
+	if err != nil {
+		c.ServerError("CountPopularTopics", err)
+		return
+	}
+
+	page := c.QueryInt("page")
+	if page <= 0 {
+		page = 1
+	}
+	pager := paginater.New(int(count), conf.UI.ExplorePagingNum, page, 5)
+	c.Data["Page"] = pager
+
+	topics, err := db.GetPopularTopics(pager.Current(), conf.UI.ExplorePagingNum)
+	if err != nil {
+		c.ServerError("GetPopularTopics", err)
+		return
+	}
+	c.Data["Topics"] = topics
+
+	c.Success(EXPLORE_TOPICS)
+}
+
 type UserSearchOptions struct {
 	Type     db.UserType
 	Counter  func() int64
@@ -84,6 +201,8 @@ type UserSearchOptions struct {
 	PageSize int
 	OrderBy  string
 	TplName  string
+	// Whether to include deactivated users in search results.
+	IncludeDeactivated bool
 }
 
 func RenderUserSearch(c *context.Context, opts *UserSearchOptions) {
@@ -113,6 +232,8 @@ func RenderUserSearch(c *context.Context, opts *UserSearchOptions) {
 			OrderBy:  opts.OrderBy,
 			Page:     page,
 			PageSize: opts.PageSize,
+
+			IncludeDeactivated: opts.IncludeDeactivated,
 		})
 		if err != nil {
 			c.ServerError("SearchUserByName", err)
@@ -134,8 +255,8 @@ func ExploreUsers(c *context.Context) {
 
 	RenderUserSearch(c, &UserSearchOptions{
 		Type:     db.USER_TYPE_INDIVIDUAL,
-		Counter:  db.CountUsers,
-		Ranger:   db.Users,
+		Counter:  db.CountListedUsers,
+		Ranger:   db.ListedUsers,
 		PageSize: conf.UI.ExplorePagingNum,
 		OrderBy:  "updated_unix DESC",
 		TplName:  EXPLORE_USERS,
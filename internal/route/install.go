@@ -80,6 +80,7 @@ func GlobalInit(customConf string) error {
 
 		db.LoadAuthSources()
 		db.LoadRepoConfig()
+		db.LoadAutolinks()
 		db.NewRepoContext()
 
 		// Booting long running goroutines.
@@ -87,6 +88,14 @@ func GlobalInit(customConf string) error {
 		db.InitSyncMirrors()
 		db.InitDeliverHooks()
 		db.InitTestPullRequests()
+		db.InitMigrations()
+		db.InitMaintenanceJobs()
+		db.InitGraphStats()
+		db.InitCodeIndexer()
+		db.InitLanguageStats()
+		db.InitIssueIndexer()
+		db.InitUserSearch()
+		db.InitFederation()
 	}
 	if db.EnableSQLite3 {
 		log.Info("SQLite3 is supported")
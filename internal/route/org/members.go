@@ -30,6 +30,13 @@ func Members(c *context.Context) {
 	}
 	c.Data["Members"] = org.Members
 
+	outsideCollaborators, err := db.GetOutsideCollaborators(org.ID)
+	if err != nil {
+		c.Handle(500, "GetOutsideCollaborators", err)
+		return
+	}
+	c.Data["OutsideCollaborators"] = outsideCollaborators
+
 	c.HTML(200, MEMBERS)
 }
 
@@ -0,0 +1,400 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package org
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/unknwon/com"
+	log "unknwon.dev/clog/v2"
+
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/db/errors"
+	"gogs.io/gogs/internal/form"
+	"gogs.io/gogs/internal/markup"
+)
+
+const (
+	PROJECTS     = "org/project/list"
+	PROJECT_NEW  = "org/project/new"
+	PROJECT_VIEW = "org/project/view"
+)
+
+func Projects(c *context.Context) {
+	org := c.Org.Organization
+	c.Data["Title"] = c.Tr("org.projects")
+	c.Data["PageIsOrgProjects"] = true
+
+	isShowClosed := c.Query("state") == "closed"
+	projects, err := db.GetProjects(org.ID, isShowClosed)
+	if err != nil {
+		c.ServerError("GetProjects", err)
+		return
+	}
+	c.Data["Projects"] = projects
+	c.Data["OpenCount"] = db.CountProjects(org.ID, false)
+	c.Data["ClosedCount"] = db.CountProjects(org.ID, true)
+	c.Data["IsShowClosed"] = isShowClosed
+
+	c.Success(PROJECTS)
+}
+
+func NewProject(c *context.Context) {
+	c.Data["Title"] = c.Tr("org.projects.new")
+	c.Data["PageIsOrgProjects"] = true
+	c.Success(PROJECT_NEW)
+}
+
+func NewProjectPost(c *context.Context, f form.CreateProject) {
+	c.Data["Title"] = c.Tr("org.projects.new")
+	c.Data["PageIsOrgProjects"] = true
+
+	if c.HasError() {
+		c.Success(PROJECT_NEW)
+		return
+	}
+
+	p := &db.Project{
+		OrgID:       c.Org.Organization.ID,
+		Title:       f.Title,
+		Description: f.Description,
+		CreatorID:   c.User.ID,
+	}
+	if err := db.NewProject(p); err != nil {
+		c.ServerError("NewProject", err)
+		return
+	}
+
+	log.Trace("Project created: %d/%d", p.OrgID, p.ID)
+	c.Flash.Success(c.Tr("org.projects.create_success", f.Title))
+	c.Redirect(c.Org.OrgLink + "/projects/" + com.ToStr(p.ID))
+}
+
+// parseProject returns the project of current organization by ID in the URL.
+func parseProject(c *context.Context) *db.Project {
+	p, err := db.GetProjectByID(c.Org.Organization.ID, c.ParamsInt64(":id"))
+	if err != nil {
+		c.NotFoundOrServerError("GetProjectByID", errors.IsProjectNotExist, err)
+		return nil
+	}
+	c.Data["Project"] = p
+	c.Data["ProjectLink"] = c.Org.OrgLink + "/projects/" + com.ToStr(p.ID)
+	return p
+}
+
+func ViewProject(c *context.Context) {
+	p := parseProject(c)
+	if c.Written() {
+		return
+	}
+	c.Data["Title"] = p.Title
+	c.Data["PageIsOrgProjects"] = true
+	c.Data["RenderedDescription"] = string(markup.Markdown(p.Description, c.Org.OrgLink, nil))
+
+	repoID := c.QueryInt64("repo")
+	label := strings.TrimSpace(c.Query("label"))
+	columns, err := p.GetBoard(db.ProjectCardsOptions{
+		UserID: c.User.ID,
+		RepoID: repoID,
+		Label:  label,
+	})
+	if err != nil {
+		c.ServerError("GetBoard", err)
+		return
+	}
+	c.Data["Columns"] = columns
+
+	org := c.Org.Organization
+	repos, _, err := org.GetUserRepositories(c.User.ID, 1, org.NumRepos)
+	if err != nil {
+		c.ServerError("GetUserRepositories", err)
+		return
+	}
+	c.Data["Repos"] = repos
+	c.Data["SelectedRepoID"] = repoID
+	c.Data["SelectedLabel"] = label
+
+	c.Success(PROJECT_VIEW)
+}
+
+func EditProject(c *context.Context) {
+	p := parseProject(c)
+	if c.Written() {
+		return
+	}
+	c.Data["Title"] = c.Tr("org.projects.edit")
+	c.Data["PageIsOrgProjects"] = true
+	c.Data["PageIsEditProject"] = true
+	c.Data["title"] = p.Title
+	c.Data["description"] = p.Description
+	c.Success(PROJECT_NEW)
+}
+
+func EditProjectPost(c *context.Context, f form.CreateProject) {
+	p := parseProject(c)
+	if c.Written() {
+		return
+	}
+	c.Data["Title"] = c.Tr("org.projects.edit")
+	c.Data["PageIsOrgProjects"] = true
+	c.Data["PageIsEditProject"] = true
+
+	if c.HasError() {
+		c.Success(PROJECT_NEW)
+		return
+	}
+
+	p.Title = f.Title
+	p.Description = f.Description
+	if err := db.UpdateProject(p); err != nil {
+		c.ServerError("UpdateProject", err)
+		return
+	}
+
+	c.Flash.Success(c.Tr("org.projects.edit_success", p.Title))
+	c.Redirect(c.Org.OrgLink + "/projects/" + com.ToStr(p.ID))
+}
+
+func ChangeProjectStatus(c *context.Context) {
+	p := parseProject(c)
+	if c.Written() {
+		return
+	}
+
+	location := url.URL{
+		Path: c.Org.OrgLink + "/projects",
+	}
+	switch c.Params(":action") {
+	case "open":
+		p.IsClosed = false
+		location.RawQuery = "state=open"
+	case "close":
+		p.IsClosed = true
+		location.RawQuery = "state=closed"
+	}
+	if err := db.UpdateProject(p); err != nil {
+		c.ServerError("UpdateProject", err)
+		return
+	}
+	c.Redirect(location.String())
+}
+
+func DeleteProject(c *context.Context) {
+	p, err := db.GetProjectByID(c.Org.Organization.ID, c.QueryInt64("id"))
+	if err != nil {
+		c.NotFoundOrServerError("GetProjectByID", errors.IsProjectNotExist, err)
+		return
+	}
+
+	// Only owners of the organization and the creator are allowed to delete the project.
+	if !c.Org.IsOwner && p.CreatorID != c.User.ID {
+		c.NotFound()
+		return
+	}
+
+	if err = db.DeleteProject(p); err != nil {
+		c.Flash.Error("DeleteProject: " + err.Error())
+	} else {
+		c.Flash.Success(c.Tr("org.projects.deletion_success"))
+	}
+
+	c.JSONSuccess(map[string]interface{}{
+		"redirect": c.Org.OrgLink + "/projects",
+	})
+}
+
+func NewProjectColumnPost(c *context.Context, f form.CreateProjectColumn) {
+	p := parseProject(c)
+	if c.Written() {
+		return
+	}
+
+	if c.HasError() {
+		c.Flash.Error(c.GetErrMsg())
+		c.Redirect(c.Data["ProjectLink"].(string))
+		return
+	}
+
+	if _, err := db.NewProjectColumn(p, f.Title); err != nil {
+		c.ServerError("NewProjectColumn", err)
+		return
+	}
+	c.Redirect(c.Data["ProjectLink"].(string))
+}
+
+func EditProjectColumnPost(c *context.Context, f form.CreateProjectColumn) {
+	p := parseProject(c)
+	if c.Written() {
+		return
+	}
+
+	col, err := db.GetProjectColumnByID(p.ID, c.ParamsInt64(":columnid"))
+	if err != nil {
+		c.NotFoundOrServerError("GetProjectColumnByID", errors.IsProjectColumnNotExist, err)
+		return
+	}
+
+	if c.HasError() {
+		c.Flash.Error(c.GetErrMsg())
+		c.Redirect(c.Data["ProjectLink"].(string))
+		return
+	}
+
+	col.Title = f.Title
+	if err = db.UpdateProjectColumn(col); err != nil {
+		c.ServerError("UpdateProjectColumn", err)
+		return
+	}
+	c.Redirect(c.Data["ProjectLink"].(string))
+}
+
+func DeleteProjectColumn(c *context.Context) {
+	p := parseProject(c)
+	if c.Written() {
+		return
+	}
+
+	col, err := db.GetProjectColumnByID(p.ID, c.QueryInt64("id"))
+	if err != nil {
+		c.NotFoundOrServerError("GetProjectColumnByID", errors.IsProjectColumnNotExist, err)
+		return
+	}
+
+	if err = db.DeleteProjectColumn(col); err != nil {
+		c.Flash.Error("DeleteProjectColumn: " + err.Error())
+	} else {
+		c.Flash.Success(c.Tr("org.projects.column_deletion_success"))
+	}
+
+	c.JSONSuccess(map[string]interface{}{
+		"redirect": c.Data["ProjectLink"],
+	})
+}
+
+// getIssueByRef returns the issue by given reference in the format of "repo#index" in
+// repositories of the organization that the user has read access to.
+func getIssueByRef(org *db.User, user *db.User, ref string) (*db.Issue, error) {
+	fields := strings.SplitN(strings.TrimSpace(ref), "#", 2)
+	if len(fields) != 2 {
+		return nil, errors.InvalidIssueReference{Ref: ref}
+	}
+
+	repo, err := db.GetRepositoryByName(org.ID, fields[0])
+	if err != nil {
+		if errors.IsRepoNotExist(err) {
+			return nil, errors.InvalidIssueReference{Ref: ref}
+		}
+		return nil, err
+	}
+	if has, err := db.HasAccess(user.ID, repo, db.ACCESS_MODE_READ); err != nil {
+		return nil, err
+	} else if !has {
+		return nil, errors.InvalidIssueReference{Ref: ref}
+	}
+
+	issue, err := db.GetRawIssueByIndex(repo.ID, com.StrTo(fields[1]).MustInt64())
+	if err != nil {
+		if errors.IsIssueNotExist(err) {
+			return nil, errors.InvalidIssueReference{Ref: ref}
+		}
+		return nil, err
+	}
+	issue.Repo = repo
+	return issue, nil
+}
+
+func AddProjectCardPost(c *context.Context, f form.AddProjectCard) {
+	p := parseProject(c)
+	if c.Written() {
+		return
+	}
+	projectLink := c.Data["ProjectLink"].(string)
+
+	if c.HasError() {
+		c.Flash.Error(c.GetErrMsg())
+		c.Redirect(projectLink)
+		return
+	}
+
+	col, err := db.GetProjectColumnByID(p.ID, f.ColumnID)
+	if err != nil {
+		c.NotFoundOrServerError("GetProjectColumnByID", errors.IsProjectColumnNotExist, err)
+		return
+	}
+
+	issue, err := getIssueByRef(c.Org.Organization, c.User, f.Issue)
+	if err != nil {
+		if errors.IsInvalidIssueReference(err) {
+			c.Flash.Error(c.Tr("org.projects.invalid_issue_ref", f.Issue))
+			c.Redirect(projectLink)
+		} else {
+			c.ServerError("getIssueByRef", err)
+		}
+		return
+	}
+
+	if _, err = db.AddProjectCard(p, col, issue); err != nil {
+		if errors.IsProjectCardAlreadyExist(err) {
+			c.Flash.Error(c.Tr("org.projects.card_exists"))
+			c.Redirect(projectLink)
+		} else {
+			c.ServerError("AddProjectCard", err)
+		}
+		return
+	}
+	c.Redirect(projectLink)
+}
+
+// parseProjectCard returns the card of the project by ID in the URL.
+func parseProjectCard(c *context.Context, p *db.Project) *db.ProjectCard {
+	card, err := db.GetProjectCardByID(p.ID, c.ParamsInt64(":cardid"))
+	if err != nil {
+		c.NotFoundOrServerError("GetProjectCardByID", errors.IsProjectCardNotExist, err)
+		return nil
+	}
+	return card
+}
+
+func MoveProjectCard(c *context.Context) {
+	p := parseProject(c)
+	if c.Written() {
+		return
+	}
+	card := parseProjectCard(c, p)
+	if c.Written() {
+		return
+	}
+
+	col, err := db.GetProjectColumnByID(p.ID, c.QueryInt64("column_id"))
+	if err != nil {
+		c.NotFoundOrServerError("GetProjectColumnByID", errors.IsProjectColumnNotExist, err)
+		return
+	}
+
+	if err = db.MoveProjectCard(card, col); err != nil {
+		c.ServerError("MoveProjectCard", err)
+		return
+	}
+	c.Redirect(c.Data["ProjectLink"].(string))
+}
+
+func DeleteProjectCard(c *context.Context) {
+	p := parseProject(c)
+	if c.Written() {
+		return
+	}
+	card := parseProjectCard(c, p)
+	if c.Written() {
+		return
+	}
+
+	if err := db.DeleteProjectCard(card); err != nil {
+		c.ServerError("DeleteProjectCard", err)
+		return
+	}
+	c.Redirect(c.Data["ProjectLink"].(string))
+}
@@ -7,6 +7,7 @@ package org
 import (
 	"strings"
 
+	"github.com/unknwon/com"
 	log "unknwon.dev/clog/v2"
 
 	"gogs.io/gogs/internal/conf"
@@ -21,17 +22,23 @@ const (
 	SETTINGS_OPTIONS  = "org/settings/options"
 	SETTINGS_DELETE   = "org/settings/delete"
 	SETTINGS_WEBHOOKS = "org/settings/webhooks"
+	SETTINGS_TEAMS    = "org/settings/teams"
+
+	SETTINGS_REPO_DEFAULTS = "org/settings/repo_defaults"
+	SETTINGS_COLLABORATORS = "org/settings/collaborators"
 )
 
 func Settings(c *context.Context) {
 	c.Data["Title"] = c.Tr("org.settings")
 	c.Data["PageIsSettingsOptions"] = true
+	c.Data["Licenses"] = db.InstanceAllowedLicenses()
 	c.HTML(200, SETTINGS_OPTIONS)
 }
 
 func SettingsPost(c *context.Context, f form.UpdateOrgSetting) {
 	c.Data["Title"] = c.Tr("org.settings")
 	c.Data["PageIsSettingsOptions"] = true
+	c.Data["Licenses"] = db.InstanceAllowedLicenses()
 
 	if c.HasError() {
 		c.HTML(200, SETTINGS_OPTIONS)
@@ -78,6 +85,16 @@ func SettingsPost(c *context.Context, f form.UpdateOrgSetting) {
 	org.Description = f.Description
 	org.Website = f.Website
 	org.Location = f.Location
+
+	// Only licenses allowed by the instance can be allowed by the organization.
+	allowedLicenses := make([]string, 0, 5)
+	for _, name := range strings.Split(f.AllowedLicenses, ",") {
+		if com.IsSliceContainsStr(db.InstanceAllowedLicenses(), name) {
+			allowedLicenses = append(allowedLicenses, name)
+		}
+	}
+	org.AllowedLicenses = strings.Join(allowedLicenses, ",")
+
 	if err := db.UpdateUser(org); err != nil {
 		c.Handle(500, "UpdateUser", err)
 		return
@@ -138,10 +155,122 @@ func SettingsDelete(c *context.Context) {
 	c.Success(SETTINGS_DELETE)
 }
 
+// SettingsTeams shows the hierarchy of teams of the organization.
+func SettingsTeams(c *context.Context) {
+	c.Data["Title"] = c.Tr("org.settings")
+	c.Data["PageIsSettingsTeams"] = true
+
+	teams, err := db.GetTeamTree(c.Org.Organization.ID)
+	if err != nil {
+		c.ServerError("GetTeamTree", err)
+		return
+	}
+	c.Data["TeamNodes"] = teams
+	c.Success(SETTINGS_TEAMS)
+}
+
+func SettingsRepoDefaults(c *context.Context) {
+	c.Data["Title"] = c.Tr("org.settings")
+	c.Data["PageIsSettingsRepoDefaults"] = true
+	c.Data["LabelTemplates"] = db.LabelTemplates
+
+	defaults, err := db.GetOrgRepoDefaults(c.Org.Organization.ID)
+	if err != nil {
+		c.ServerError("GetOrgRepoDefaults", err)
+		return
+	}
+	c.Data["Defaults"] = defaults
+	c.Success(SETTINGS_REPO_DEFAULTS)
+}
+
+func SettingsRepoDefaultsPost(c *context.Context, f form.OrgRepoDefaults) {
+	c.Data["Title"] = c.Tr("org.settings")
+	c.Data["PageIsSettingsRepoDefaults"] = true
+	c.Data["LabelTemplates"] = db.LabelTemplates
+
+	defaults, err := db.GetOrgRepoDefaults(c.Org.Organization.ID)
+	if err != nil {
+		c.ServerError("GetOrgRepoDefaults", err)
+		return
+	}
+	c.Data["Defaults"] = defaults
+
+	if c.HasError() {
+		c.Success(SETTINGS_REPO_DEFAULTS)
+		return
+	}
+
+	if f.LabelTemplate != "" && !com.IsSliceContainsStr(db.LabelTemplates, f.LabelTemplate) {
+		c.Data["Err_LabelTemplate"] = true
+		c.RenderWithErr(c.Tr("org.settings.repo_defaults.invalid_label_template"), SETTINGS_REPO_DEFAULTS, &f)
+		return
+	}
+
+	defaults.Visibility = db.RepoVisibility(f.Visibility)
+	if defaults.Visibility < db.REPO_VISIBILITY_ANY || defaults.Visibility > db.REPO_VISIBILITY_PRIVATE {
+		defaults.Visibility = db.REPO_VISIBILITY_ANY
+	}
+	defaults.DefaultBranch = f.DefaultBranch
+	defaults.LabelTemplate = f.LabelTemplate
+	defaults.ProtectDefaultBranch = f.ProtectDefaultBranch
+	defaults.RequirePullRequest = f.ProtectDefaultBranch && f.RequirePullRequest
+	defaults.WebhookURL = f.WebhookURL
+	defaults.WebhookContentType = db.HookContentType(f.WebhookContentType)
+	if defaults.WebhookContentType != db.FORM {
+		defaults.WebhookContentType = db.JSON
+	}
+	defaults.WebhookSecret = f.WebhookSecret
+	defaults.WebhookSendEverything = f.WebhookSendEverything
+	if err = db.UpdateOrgRepoDefaults(defaults); err != nil {
+		c.ServerError("UpdateOrgRepoDefaults", err)
+		return
+	}
+
+	log.Trace("Repository defaults of organization updated: %s", c.Org.Organization.Name)
+	c.Flash.Success(c.Tr("org.settings.repo_defaults.update_success"))
+	c.Redirect(c.Org.OrgLink + "/settings/repo_defaults")
+}
+
+// SettingsCollaborators lists outside collaborators of the organization for auditing.
+func SettingsCollaborators(c *context.Context) {
+	c.Data["Title"] = c.Tr("org.settings")
+	c.Data["PageIsSettingsCollaborators"] = true
+
+	collaborators, err := db.GetOutsideCollaborators(c.Org.Organization.ID)
+	if err != nil {
+		c.ServerError("GetOutsideCollaborators", err)
+		return
+	}
+	c.Data["OutsideCollaborators"] = collaborators
+	c.Success(SETTINGS_COLLABORATORS)
+}
+
+// SettingsCollaboratorsPost removes selected outside collaborators from all
+// repositories of the organization.
+func SettingsCollaboratorsPost(c *context.Context) {
+	userIDs := make([]int64, 0, 5)
+	for _, uid := range c.QueryStrings("uid") {
+		if id := com.StrTo(uid).MustInt64(); id > 0 {
+			userIDs = append(userIDs, id)
+		}
+	}
+
+	if err := db.RemoveOutsideCollaborators(c.Org.Organization.ID, userIDs); err != nil {
+		c.ServerError("RemoveOutsideCollaborators", err)
+		return
+	}
+
+	if len(userIDs) > 0 {
+		log.Trace("Outside collaborators removed from organization '%s': %v", c.Org.Organization.Name, userIDs)
+		c.Flash.Success(c.Tr("org.settings.outside_collaborators.remove_success", len(userIDs)))
+	}
+	c.Redirect(c.Org.OrgLink + "/settings/collaborators")
+}
+
 func Webhooks(c *context.Context) {
 	c.Data["Title"] = c.Tr("org.settings")
 	c.Data["PageIsSettingsHooks"] = true
-	c.Data["BaseLink"] = c.Org.OrgLink
+	c.Data["BaseLink"] = c.Org.OrgLink + "/settings/hooks"
 	c.Data["Description"] = c.Tr("org.settings.hooks_desc")
 	c.Data["Types"] = conf.Webhook.Types
 
@@ -141,11 +141,93 @@ func TeamsRepoAction(c *context.Context) {
 	c.Redirect(c.Org.OrgLink + "/teams/" + c.Org.Team.LowerName + "/repositories")
 }
 
+// parseTeamUnits returns access modes to repository units from the form, units
+// without a valid access mode follow the access level of the team.
+func parseTeamUnits(f form.CreateTeam) []*db.TeamUnit {
+	values := map[db.RepoUnit]string{
+		db.REPO_UNIT_CODE:     f.UnitCode,
+		db.REPO_UNIT_ISSUES:   f.UnitIssues,
+		db.REPO_UNIT_WIKI:     f.UnitWiki,
+		db.REPO_UNIT_RELEASES: f.UnitReleases,
+	}
+	units := make([]*db.TeamUnit, 0, len(values))
+	for _, unit := range db.RepoUnits {
+		if values[unit] == "" {
+			continue
+		}
+		mode := db.ACCESS_MODE_NONE
+		if values[unit] != mode.String() {
+			mode = db.ParseAccessMode(values[unit])
+		}
+		if !db.IsValidUnitAccessMode(mode) {
+			continue
+		}
+		units = append(units, &db.TeamUnit{
+			Unit: unit,
+			Mode: mode,
+		})
+	}
+	return units
+}
+
+// isTeamUnitsChanged returns true if access modes to repository units are different.
+func isTeamUnitsChanged(oldUnits, newUnits []*db.TeamUnit) bool {
+	if len(oldUnits) != len(newUnits) {
+		return true
+	}
+	for i := range oldUnits {
+		if oldUnits[i].Unit != newUnits[i].Unit || oldUnits[i].Mode != newUnits[i].Mode {
+			return true
+		}
+	}
+	return false
+}
+
+// prepareParentTeams sets teams that can be parent of the team, which are teams
+// of the organization except the owner team, the team itself and its child teams.
+func prepareParentTeams(c *context.Context, t *db.Team) {
+	if t.IsOwnerTeam() {
+		return
+	}
+
+	excludeIDs := map[int64]bool{t.ID: true}
+	if t.ID > 0 {
+		descendants, err := t.GetDescendants()
+		if err != nil {
+			c.Handle(500, "GetDescendants", err)
+			return
+		}
+		for _, team := range descendants {
+			excludeIDs[team.ID] = true
+		}
+	}
+
+	teams, err := db.GetTeamsByOrgID(c.Org.Organization.ID)
+	if err != nil {
+		c.Handle(500, "GetTeamsByOrgID", err)
+		return
+	}
+	parents := make([]*db.Team, 0, len(teams))
+	for _, team := range teams {
+		if team.IsOwnerTeam() || excludeIDs[team.ID] {
+			continue
+		}
+		parents = append(parents, team)
+	}
+	c.Data["ParentTeams"] = parents
+}
+
 func NewTeam(c *context.Context) {
 	c.Data["Title"] = c.Org.Organization.FullName
 	c.Data["PageIsOrgTeams"] = true
 	c.Data["PageIsOrgTeamsNew"] = true
-	c.Data["Team"] = &db.Team{}
+	c.Data["RepoUnits"] = db.RepoUnits
+	t := &db.Team{}
+	c.Data["Team"] = t
+	prepareParentTeams(c, t)
+	if c.Written() {
+		return
+	}
 	c.HTML(200, TEAM_NEW)
 }
 
@@ -153,14 +235,23 @@ func NewTeamPost(c *context.Context, f form.CreateTeam) {
 	c.Data["Title"] = c.Org.Organization.FullName
 	c.Data["PageIsOrgTeams"] = true
 	c.Data["PageIsOrgTeamsNew"] = true
+	c.Data["RepoUnits"] = db.RepoUnits
 
 	t := &db.Team{
-		OrgID:       c.Org.Organization.ID,
-		Name:        f.TeamName,
-		Description: f.Description,
-		Authorize:   db.ParseAccessMode(f.Permission),
+		OrgID:          c.Org.Organization.ID,
+		Name:           f.TeamName,
+		Description:    f.Description,
+		ParentID:       f.ParentID,
+		Authorize:      db.ParseAccessMode(f.Permission),
+		Units:          parseTeamUnits(f),
+		ReviewStrategy: db.ParseReviewAssignStrategy(f.ReviewStrategy),
+		ReviewerCount:  f.ReviewerCount,
 	}
 	c.Data["Team"] = t
+	prepareParentTeams(c, t)
+	if c.Written() {
+		return
+	}
 
 	if c.HasError() {
 		c.HTML(200, TEAM_NEW)
@@ -168,6 +259,12 @@ func NewTeamPost(c *context.Context, f form.CreateTeam) {
 	}
 
 	if err := db.NewTeam(t); err != nil {
+		if errors.IsInvalidTeamParent(err) {
+			c.Data["Err_ParentID"] = true
+			c.RenderWithErr(c.Tr("org.teams.invalid_parent"), TEAM_NEW, &f)
+			return
+		}
+
 		c.Data["Err_TeamName"] = true
 		switch {
 		case db.IsErrTeamAlreadyExist(err):
@@ -208,6 +305,15 @@ func EditTeam(c *context.Context) {
 	c.Data["PageIsOrgTeams"] = true
 	c.Data["team_name"] = c.Org.Team.Name
 	c.Data["desc"] = c.Org.Team.Description
+	c.Data["RepoUnits"] = db.RepoUnits
+	if err := c.Org.Team.GetUnits(); err != nil {
+		c.Handle(500, "GetUnits", err)
+		return
+	}
+	prepareParentTeams(c, c.Org.Team)
+	if c.Written() {
+		return
+	}
 	c.HTML(200, TEAM_NEW)
 }
 
@@ -215,8 +321,18 @@ func EditTeamPost(c *context.Context, f form.CreateTeam) {
 	t := c.Org.Team
 	c.Data["Title"] = c.Org.Organization.FullName
 	c.Data["PageIsOrgTeams"] = true
+	c.Data["RepoUnits"] = db.RepoUnits
 	c.Data["Team"] = t
 
+	if err := t.GetUnits(); err != nil {
+		c.Handle(500, "GetUnits", err)
+		return
+	}
+	prepareParentTeams(c, t)
+	if c.Written() {
+		return
+	}
+
 	if c.HasError() {
 		c.HTML(200, TEAM_NEW)
 		return
@@ -229,8 +345,12 @@ func EditTeamPost(c *context.Context, f form.CreateTeam) {
 		switch f.Permission {
 		case "read":
 			auth = db.ACCESS_MODE_READ
+		case "triage":
+			auth = db.ACCESS_MODE_TRIAGE
 		case "write":
 			auth = db.ACCESS_MODE_WRITE
+		case "maintain":
+			auth = db.ACCESS_MODE_MAINTAIN
 		case "admin":
 			auth = db.ACCESS_MODE_ADMIN
 		default:
@@ -243,9 +363,29 @@ func EditTeamPost(c *context.Context, f form.CreateTeam) {
 			isAuthChanged = true
 			t.Authorize = auth
 		}
+
+		units := parseTeamUnits(f)
+		if isTeamUnitsChanged(t.Units, units) {
+			isAuthChanged = true
+			t.Units = units
+		}
+
+		// Members of the parent team inherit access to repositories of the team.
+		if t.ParentID != f.ParentID {
+			isAuthChanged = true
+			t.ParentID = f.ParentID
+		}
 	}
 	t.Description = f.Description
+	t.ReviewStrategy = db.ParseReviewAssignStrategy(f.ReviewStrategy)
+	t.ReviewerCount = f.ReviewerCount
 	if err := db.UpdateTeam(t, isAuthChanged); err != nil {
+		if errors.IsInvalidTeamParent(err) {
+			c.Data["Err_ParentID"] = true
+			c.RenderWithErr(c.Tr("org.teams.invalid_parent"), TEAM_NEW, &f)
+			return
+		}
+
 		c.Data["Err_TeamName"] = true
 		switch {
 		case db.IsErrTeamAlreadyExist(err):
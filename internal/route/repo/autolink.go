@@ -0,0 +1,100 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package repo
+
+import (
+	log "unknwon.dev/clog/v2"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/db/errors"
+	"gogs.io/gogs/internal/form"
+)
+
+const (
+	SETTINGS_AUTOLINKS = "repo/settings/autolinks"
+	ADMIN_AUTOLINKS    = "admin/autolinks"
+)
+
+// autolinkCtx returns the repository ID, the link of the autolink list page and
+// the template of the context. The repository ID is 0 in the system context of
+// the admin panel.
+func autolinkCtx(c *context.Context) (repoID int64, link, tpl string) {
+	if c.Data["PageIsAdminAutolinks"] == true {
+		return 0, conf.Server.Subpath + "/admin/autolinks", ADMIN_AUTOLINKS
+	}
+	c.Data["PageIsSettingsAutolinks"] = true
+	return c.Repo.Repository.ID, c.Repo.RepoLink + "/settings/autolinks", SETTINGS_AUTOLINKS
+}
+
+// prepareAutolinks sets data of the autolink list page and returns its template.
+func prepareAutolinks(c *context.Context) string {
+	repoID, link, tpl := autolinkCtx(c)
+	if repoID == 0 {
+		c.Data["Title"] = c.Tr("admin.autolinks")
+		c.Data["Description"] = c.Tr("admin.autolinks.desc")
+	} else {
+		c.Data["Title"] = c.Tr("repo.settings.autolinks")
+		c.Data["Description"] = c.Tr("repo.settings.autolinks_desc")
+	}
+	c.Data["BaseLink"] = link
+
+	links, err := db.GetAutolinks(repoID)
+	if err != nil {
+		c.ServerError("GetAutolinks", err)
+		return ""
+	}
+	c.Data["Autolinks"] = links
+	return tpl
+}
+
+func Autolinks(c *context.Context) {
+	tpl := prepareAutolinks(c)
+	if c.Written() {
+		return
+	}
+	c.Success(tpl)
+}
+
+func AutolinksPost(c *context.Context, f form.Autolink) {
+	tpl := prepareAutolinks(c)
+	if c.Written() {
+		return
+	}
+
+	if c.HasError() {
+		c.Success(tpl)
+		return
+	}
+
+	repoID, link, _ := autolinkCtx(c)
+	if _, err := db.NewAutolink(repoID, f.Pattern, f.URL); err != nil {
+		if errors.IsInvalidAutolink(err) {
+			c.FormErr("Pattern", "URL")
+			c.RenderWithErr(c.Tr("repo.settings.autolink_invalid"), tpl, &f)
+		} else {
+			c.ServerError("NewAutolink", err)
+		}
+		return
+	}
+
+	log.Trace("Autolink added [repo_id: %d]: %s", repoID, f.Pattern)
+	c.Flash.Success(c.Tr("repo.settings.add_autolink_success"))
+	c.Redirect(link)
+}
+
+func DeleteAutolink(c *context.Context) {
+	repoID, link, _ := autolinkCtx(c)
+	if err := db.DeleteAutolink(repoID, c.QueryInt64("id")); err != nil {
+		c.Flash.Error("DeleteAutolink: " + err.Error())
+	} else {
+		c.Flash.Success(c.Tr("repo.settings.autolink_deletion_success"))
+	}
+
+	c.JSONSuccess(map[string]interface{}{
+		"redirect": link,
+	})
+}
@@ -5,6 +5,8 @@
 package repo
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	log "unknwon.dev/clog/v2"
@@ -14,6 +16,7 @@ import (
 
 	"gogs.io/gogs/internal/context"
 	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/db/errors"
 	"gogs.io/gogs/internal/tool"
 )
 
@@ -122,24 +125,32 @@ func DeleteBranchPost(c *context.Context) {
 	if !c.Repo.GitRepo.IsBranchExist(branchName) {
 		return
 	}
-	if len(commitID) > 0 {
-		branchCommitID, err := c.Repo.GitRepo.GetBranchCommitID(branchName)
-		if err != nil {
-			log.Error("Failed to get commit ID of branch %q: %v", branchName, err)
-			return
-		}
+	branchCommitID, err := c.Repo.GitRepo.GetBranchCommitID(branchName)
+	if err != nil {
+		log.Error("Failed to get commit ID of branch %q: %v", branchName, err)
+		return
+	}
+	if len(commitID) > 0 && branchCommitID != commitID {
+		c.Flash.Error(c.Tr("repo.pulls.delete_branch_has_new_commits"))
+		return
+	}
 
-		if branchCommitID != commitID {
-			c.Flash.Error(c.Tr("repo.pulls.delete_branch_has_new_commits"))
-			return
-		}
+	if err := deleteBranch(c, branchName, branchCommitID); err != nil {
+		log.Error("Failed to delete branch %q: %v", branchName, err)
 	}
+}
 
+// deleteBranch deletes the branch at given commit by current user, and keeps it to be
+// restored later.
+func deleteBranch(c *context.Context, branchName, branchCommitID string) error {
 	if err := c.Repo.GitRepo.DeleteBranch(branchName, git.DeleteBranchOptions{
 		Force: true,
 	}); err != nil {
-		log.Error("Failed to delete branch %q: %v", branchName, err)
-		return
+		return err
+	}
+
+	if err := c.Repo.Repository.AddDeletedBranch(c.User.ID, branchName, branchCommitID); err != nil {
+		log.Error("Failed to add deleted branch %q: %v", branchName, err)
 	}
 
 	if err := db.PrepareWebhooks(c.Repo.Repository, db.HOOK_EVENT_DELETE, &api.DeletePayload{
@@ -149,7 +160,15 @@ func DeleteBranchPost(c *context.Context) {
 		Repo:       c.Repo.Repository.APIFormat(nil),
 		Sender:     c.User.APIFormat(),
 	}); err != nil {
-		log.Error("Failed to prepare webhooks for %q: %v", db.HOOK_EVENT_DELETE, err)
-		return
+		return fmt.Errorf("prepare webhooks for %q: %v", db.HOOK_EVENT_DELETE, err)
+	}
+	return nil
+}
+
+// refNameNotAllowedMessage returns the localized message of why the branch or tag name is not allowed.
+func refNameNotAllowedMessage(c *context.Context, err errors.RefNameNotAllowed) string {
+	if len(err.Patterns) == 0 {
+		return c.Tr("repo.form."+err.Kind+"_name_unsafe_chars", err.Name)
 	}
+	return c.Tr("repo.form."+err.Kind+"_name_not_match", err.Name, strings.Join(err.Patterns, ", "))
 }
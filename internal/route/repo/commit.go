@@ -8,17 +8,22 @@ import (
 	"container/list"
 	"path"
 
+	log "unknwon.dev/clog/v2"
+
 	"github.com/gogs/git-module"
 
 	"gogs.io/gogs/internal/conf"
 	"gogs.io/gogs/internal/context"
 	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/db/errors"
+	"gogs.io/gogs/internal/form"
 	"gogs.io/gogs/internal/tool"
 )
 
 const (
 	COMMITS = "repo/commits"
 	DIFF    = "repo/diff/page"
+	CHECKS  = "repo/commit_checks"
 )
 
 func RefCommits(c *context.Context) {
@@ -42,6 +47,21 @@ func RenderIssueLinks(oldCommits *list.List, repoLink string) *list.List {
 	return newCommits
 }
 
+// setCommitVerifications sets verification results of signatures of commits in the
+// list for badges of commits signed by the server.
+func setCommitVerifications(c *context.Context, commits *list.List) {
+	commitIDs := make([]string, 0, commits.Len())
+	for e := commits.Front(); e != nil; e = e.Next() {
+		commitIDs = append(commitIDs, e.Value.(db.UserCommit).ID.String())
+	}
+	verifications, err := db.VerifyCommits(c.Repo.GitRepo.Path, commitIDs...)
+	if err != nil {
+		c.ServerError("VerifyCommits", err)
+		return
+	}
+	c.Data["Verifications"] = verifications
+}
+
 func renderCommits(c *context.Context, filename string) {
 	c.Data["Title"] = c.Tr("repo.commits.commit_history") + " · " + c.Repo.Repository.FullName()
 	c.Data["PageIsCommits"] = true
@@ -71,6 +91,10 @@ func renderCommits(c *context.Context, filename string) {
 	commits = RenderIssueLinks(commits, c.Repo.RepoLink)
 	commits = db.ValidateCommitsWithEmails(commits)
 	c.Data["Commits"] = commits
+	setCommitVerifications(c, commits)
+	if c.Written() {
+		return
+	}
 
 	if page > 1 {
 		c.Data["HasPrevious"] = true
@@ -108,6 +132,10 @@ func SearchCommits(c *context.Context) {
 	commits = RenderIssueLinks(commits, c.Repo.RepoLink)
 	commits = db.ValidateCommitsWithEmails(commits)
 	c.Data["Commits"] = commits
+	setCommitVerifications(c, commits)
+	if c.Written() {
+		return
+	}
 
 	c.Data["Keyword"] = keyword
 	c.Data["Username"] = c.Repo.Owner.Name
@@ -120,6 +148,18 @@ func FileHistory(c *context.Context) {
 	renderCommits(c, c.Repo.TreePath)
 }
 
+// setBeforeImageData sets data for comparing changed images with their versions
+// at beforeCommitID, which are served by the raw handler of the repository.
+func setBeforeImageData(c *context.Context, gitRepo *git.Repository, repoTarget, beforeCommitID string) {
+	beforeCommit, err := gitRepo.GetCommit(beforeCommitID)
+	if err != nil {
+		log.Trace("Failed to get commit %q for image diff: %v", beforeCommitID, err)
+		return
+	}
+	c.Data["BeforeIsImageFile"] = beforeCommit.IsImageFile
+	c.Data["BeforeRawPath"] = conf.Server.Subpath + "/" + path.Join(repoTarget, "raw", beforeCommitID)
+}
+
 func Diff(c *context.Context) {
 	c.PageIs("Diff")
 	c.RequireHighlightJS()
@@ -136,7 +176,7 @@ func Diff(c *context.Context) {
 
 	diff, err := db.GetDiffCommit(db.RepoPath(userName, repoName),
 		commitID, conf.Git.MaxGitDiffLines,
-		conf.Git.MaxGitDiffLineCharacters, conf.Git.MaxGitDiffFiles)
+		conf.Git.MaxGitDiffLineCharacters, conf.Git.MaxGitDiffFiles, c.QueryStrings("expand")...)
 	if err != nil {
 		c.NotFoundOrServerError("get diff commit", git.IsErrNotExist, err)
 		return
@@ -165,6 +205,7 @@ func Diff(c *context.Context) {
 	c.Data["IsImageFile"] = commit.IsImageFile
 	c.Data["Commit"] = commit
 	c.Data["Author"] = db.ValidateCommitWithEmail(commit)
+	c.Data["CoAuthors"] = db.GetCommitCoAuthors(commit)
 	c.Data["Diff"] = diff
 	c.Data["Parents"] = parents
 	c.Data["DiffNotAvailable"] = diff.NumFiles() == 0
@@ -173,9 +214,132 @@ func Diff(c *context.Context) {
 		c.Data["BeforeSourcePath"] = conf.Server.Subpath + "/" + path.Join(userName, repoName, "src", parents[0])
 	}
 	c.Data["RawPath"] = conf.Server.Subpath + "/" + path.Join(userName, repoName, "raw", commitID)
+	if commit.ParentCount() > 0 {
+		setBeforeImageData(c, c.Repo.GitRepo, path.Join(userName, repoName), parents[0])
+	}
+
+	c.Data["CanCherryPick"] = c.Repo.CanWrite(db.REPO_UNIT_CODE) && c.Repo.Repository.CanEnableEditor()
+
+	verifications, err := db.VerifyCommits(c.Repo.GitRepo.Path, commit.ID.String())
+	if err != nil {
+		c.ServerError("VerifyCommits", err)
+		return
+	}
+	c.Data["Verification"] = verifications[commit.ID.String()]
+
+	checks, err := db.GetCommitChecks([]int64{c.Repo.Repository.ID}, commit.ID.String())
+	if err != nil {
+		c.ServerError("GetCommitChecks", err)
+		return
+	}
+	c.Data["Checks"] = checks
+	c.Data["ChecksState"] = db.CombineCommitChecks(checks)
 	c.Success(DIFF)
 }
 
+// CommitChecks shows checks of the commit aggregated from statuses reported by
+// external systems.
+func CommitChecks(c *context.Context) {
+	c.PageIs("Diff")
+
+	commit, err := c.Repo.GitRepo.GetCommit(c.Params(":sha"))
+	if err != nil {
+		c.NotFoundOrServerError("get commit by ID", git.IsErrNotExist, err)
+		return
+	}
+
+	checks, err := db.GetCommitChecks([]int64{c.Repo.Repository.ID}, commit.ID.String())
+	if err != nil {
+		c.ServerError("GetCommitChecks", err)
+		return
+	}
+
+	c.Title(commit.Summary() + " · " + c.Tr("repo.checks"))
+	c.Data["Commit"] = commit
+	c.Data["HeadCommitID"] = commit.ID.String()
+	c.Data["Checks"] = checks
+	c.Data["ChecksState"] = db.CombineCommitChecks(checks)
+	c.Success(CHECKS)
+}
+
+func CherryPickPost(c *context.Context, f form.CherryPick) {
+	cherryPick(c, f, false)
+}
+
+func RevertPost(c *context.Context, f form.CherryPick) {
+	cherryPick(c, f, true)
+}
+
+// cherryPick applies or reverts changes of the commit on the selected branch. Changes
+// are committed to a new branch with a pull request when the user cannot push to the
+// selected branch directly or asks to.
+func cherryPick(c *context.Context, f form.CherryPick, revert bool) {
+	commitID := c.Params(":sha")
+	commitLink := c.Repo.RepoLink + "/commit/" + commitID
+	if c.HasError() {
+		c.Flash.Error(c.GetErrMsg())
+		c.Redirect(commitLink)
+		return
+	}
+
+	if !c.Repo.GitRepo.IsBranchExist(f.Branch) {
+		c.NotFound()
+		return
+	}
+
+	// Name of the action in branch names and locale keys.
+	action, localeKey := "cherry-pick", "repo.commits.cherry_pick"
+	if revert {
+		action, localeKey = "revert", "repo.commits.revert"
+	}
+
+	branchName := f.Branch
+	if f.NewBranchName != "" {
+		branchName = f.NewBranchName
+	} else if !c.Repo.Repository.CanPushToBranch(c.User.ID, f.Branch) {
+		branchName = action + "-" + tool.ShortSHA1(commitID)
+	}
+
+	if branchName != f.Branch {
+		if err := c.Repo.Repository.CheckBranchName(branchName); err != nil {
+			if errors.IsRefNameNotAllowed(err) {
+				c.Flash.Error(refNameNotAllowedMessage(c, err.(errors.RefNameNotAllowed)))
+				c.Redirect(commitLink)
+			} else {
+				c.ServerError("CheckBranchName", err)
+			}
+			return
+		}
+	}
+
+	if err := c.Repo.Repository.CherryPick(c.User, db.CherryPickOptions{
+		CommitID:  commitID,
+		OldBranch: f.Branch,
+		NewBranch: branchName,
+		Revert:    revert,
+	}); err != nil {
+		switch {
+		case errors.IsBranchAlreadyExists(err):
+			c.Flash.Error(c.Tr("repo.editor.branch_already_exists", branchName))
+		case errors.IsCherryPickConflict(err):
+			c.Flash.Error(c.Tr(localeKey+"_conflict", tool.ShortSHA1(commitID), f.Branch))
+		default:
+			c.ServerError("CherryPick", err)
+			return
+		}
+		c.Redirect(commitLink)
+		return
+	}
+	log.Trace("Commit %s is applied by %s to branch %q by %q [repo_id: %d]", commitID, action, branchName, c.User.Name, c.Repo.Repository.ID)
+
+	if branchName != f.Branch && c.Repo.PullRequest.Allowed {
+		c.Redirect(c.Repo.PullRequestURL(f.Branch, branchName))
+		return
+	}
+	c.Flash.Success(c.Tr(localeKey+"_success", tool.ShortSHA1(commitID), branchName))
+	c.Redirect(c.Repo.RepoLink + "/commits/" + branchName)
+}
+
 func RawDiff(c *context.Context) {
 	if err := git.GetRawDiff(
 		db.RepoPath(c.Repo.Owner.Name, c.Repo.Repository.Name),
@@ -203,7 +367,7 @@ func CompareDiff(c *context.Context) {
 
 	diff, err := db.GetDiffRange(db.RepoPath(userName, repoName), beforeCommitID,
 		afterCommitID, conf.Git.MaxGitDiffLines,
-		conf.Git.MaxGitDiffLineCharacters, conf.Git.MaxGitDiffFiles)
+		conf.Git.MaxGitDiffLineCharacters, conf.Git.MaxGitDiffFiles, c.QueryStrings("expand")...)
 	if err != nil {
 		c.Handle(404, "GetDiffRange", err)
 		return
@@ -232,5 +396,6 @@ func CompareDiff(c *context.Context) {
 	c.Data["SourcePath"] = conf.Server.Subpath + "/" + path.Join(userName, repoName, "src", afterCommitID)
 	c.Data["BeforeSourcePath"] = conf.Server.Subpath + "/" + path.Join(userName, repoName, "src", beforeCommitID)
 	c.Data["RawPath"] = conf.Server.Subpath + "/" + path.Join(userName, repoName, "raw", afterCommitID)
+	setBeforeImageData(c, c.Repo.GitRepo, path.Join(userName, repoName), beforeCommitID)
 	c.HTML(200, DIFF)
 }
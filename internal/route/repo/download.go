@@ -5,36 +5,65 @@
 package repo
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"path"
+	"time"
 
 	"github.com/gogs/git-module"
 
 	"gogs.io/gogs/internal/context"
 	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/db/errors"
+	"gogs.io/gogs/internal/storage"
 	"gogs.io/gogs/internal/tool"
 )
 
 func serveData(c *context.Context, name string, r io.Reader) error {
+	commit, err := c.Repo.Commit.GetCommitByPath(c.Repo.TreePath)
+	if err != nil {
+		return fmt.Errorf("GetCommitByPath: %v", err)
+	}
+	return serveContent(c, name, commit.Committer.When, r)
+}
+
+// serveContent writes the content of file with given name and last modified time
+// to the response.
+func serveContent(c *context.Context, name string, modTime time.Time, r io.Reader) error {
 	buf := make([]byte, 1024)
 	n, _ := r.Read(buf)
 	if n >= 0 {
 		buf = buf[:n]
 	}
 
-	commit, err := c.Repo.Commit.GetCommitByPath(c.Repo.TreePath)
-	if err != nil {
-		return fmt.Errorf("GetCommitByPath: %v", err)
+	c.Resp.Header().Set("Last-Modified", modTime.Format(http.TimeFormat))
+
+	// Both versions of files are served to be compared in diffs, browsers must not
+	// sniff the content type or run any script of the content.
+	c.Resp.Header().Set("X-Content-Type-Options", "nosniff")
+	// PDF viewers of browsers do not work in sandbox.
+	if !tool.IsPDFFile(buf) && (!conf.Repository.EnableRawFileRenderMode || !c.QueryBool("render")) {
+		c.Resp.Header().Set("Content-Security-Policy", "default-src 'none'; img-src 'self' data:; style-src 'unsafe-inline'; sandbox")
 	}
-	c.Resp.Header().Set("Last-Modified", commit.Committer.When.Format(http.TimeFormat))
 
+	contentType := tool.DetectContentType(name, buf)
 	if !tool.IsTextFile(buf) {
-		if !tool.IsImageFile(buf) {
+		switch {
+		case tool.IsPDFFile(buf):
+			// PDF files are displayed inline by browsers.
+			c.Resp.Header().Set("Content-Type", contentType)
+			c.Resp.Header().Set("Content-Disposition", "inline; filename=\""+name+"\"")
+		case !tool.IsImageFile(buf):
+			c.Resp.Header().Set("Content-Type", contentType)
 			c.Resp.Header().Set("Content-Disposition", "attachment; filename=\""+name+"\"")
 			c.Resp.Header().Set("Content-Transfer-Encoding", "binary")
 		}
+	} else if tool.IsGeoJSONFile(name) {
+		c.Resp.Header().Set("Content-Type", contentType+"; charset=utf-8")
 	} else if !conf.Repository.EnableRawFileRenderMode || !c.QueryBool("render") {
 		c.Resp.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	}
@@ -42,16 +71,59 @@ func serveData(c *context.Context, name string, r io.Reader) error {
 	if _, err := c.Resp.Write(buf); err != nil {
 		return fmt.Errorf("write buffer to response: %v", err)
 	}
-	_, err = io.Copy(c.Resp, r)
+	_, err := io.Copy(c.Resp, r)
 	return err
 }
 
+// openLFSObject opens the LFS object referenced by the content of pointer file, it
+// returns nil if the content is not a pointer or the object does not exist.
+func openLFSObject(repoID int64, pointer []byte) (io.ReadCloser, error) {
+	oid, _, ok := db.ParseLFSPointer(pointer)
+	if !ok {
+		return nil, nil
+	}
+
+	o, err := db.GetLFSObject(repoID, oid)
+	if err != nil {
+		if errors.IsLFSObjectNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("GetLFSObject: %v", err)
+	}
+
+	r, err := o.Open()
+	if err != nil {
+		if err == storage.ErrNotExist {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("open LFS object: %v", err)
+	}
+	return r, nil
+}
+
 func ServeBlob(c *context.Context, blob *git.Blob) error {
 	dataRc, err := blob.Data()
 	if err != nil {
 		return err
 	}
 
+	// Serve the content of LFS object instead of its pointer file.
+	if conf.LFS.Enabled && blob.Size() <= db.LFSPointerMaxSize {
+		data, err := ioutil.ReadAll(dataRc)
+		if err != nil {
+			return err
+		}
+		dataRc = bytes.NewReader(data)
+
+		r, err := openLFSObject(c.Repo.Repository.ID, data)
+		if err != nil {
+			return err
+		} else if r != nil {
+			defer r.Close()
+			dataRc = r
+		}
+	}
+
 	return serveData(c, path.Base(c.Repo.TreePath), dataRc)
 }
 
@@ -29,6 +29,7 @@ const (
 	EDIT_DIFF_PREVIEW = "repo/editor/diff_preview"
 	DELETE_FILE       = "repo/editor/delete"
 	UPLOAD_FILE       = "repo/editor/upload"
+	STAGED_CHANGES    = "repo/editor/changes"
 )
 
 // getParentTreeFields returns list of parent tree names and corresponding tree paths
@@ -46,6 +47,29 @@ func getParentTreeFields(treePath string) (treeNames []string, treePaths []strin
 	return treeNames, treePaths
 }
 
+// getStagedChange returns the change staged by current user to the file on current
+// branch, or nil if there is none.
+func getStagedChange(c *context.Context, treePath string) *db.StagedChange {
+	change, err := db.GetStagedChangeByTreePath(c.User.ID, c.Repo.Repository.ID, c.Repo.BranchName, treePath)
+	if err != nil {
+		if !errors.IsStagedChangeNotExist(err) {
+			c.ServerError("GetStagedChangeByTreePath", err)
+		}
+		return nil
+	}
+	return change
+}
+
+// loadNumStagedChanges sets the number of changes staged by current user to current branch.
+func loadNumStagedChanges(c *context.Context) {
+	count, err := db.CountStagedChanges(c.User.ID, c.Repo.Repository.ID, c.Repo.BranchName)
+	if err != nil {
+		c.ServerError("CountStagedChanges", err)
+		return
+	}
+	c.Data["NumStagedChanges"] = count
+}
+
 func editFile(c *context.Context, isNewFile bool) {
 	c.PageIs("Edit")
 	c.RequireHighlightJS()
@@ -54,7 +78,25 @@ func editFile(c *context.Context, isNewFile bool) {
 
 	treeNames, treePaths := getParentTreeFields(c.Repo.TreePath)
 
+	loadNumStagedChanges(c)
+	if c.Written() {
+		return
+	}
+
+	// Staged content is edited instead if the file has changes staged by the user.
+	var staged *db.StagedChange
 	if !isNewFile {
+		staged = getStagedChange(c, c.Repo.TreePath)
+		if c.Written() {
+			return
+		}
+	}
+	c.Data["IsStaged"] = staged != nil
+
+	if staged != nil && !staged.IsDelete {
+		c.Data["FileName"] = path.Base(staged.TreePath)
+		c.Data["FileContent"] = staged.Content
+	} else if !isNewFile {
 		entry, err := c.Repo.Commit.GetTreeEntryByPath(c.Repo.TreePath)
 		if err != nil {
 			c.NotFoundOrServerError("GetTreeEntryByPath", git.IsErrNotExist, err)
@@ -138,7 +180,23 @@ func editFilePost(c *context.Context, f form.EditRepoFile, isNewFile bool) {
 	lastCommit := f.LastCommit
 	f.LastCommit = c.Repo.Commit.ID.String()
 
-	if f.IsNewBrnach() {
+	loadNumStagedChanges(c)
+	if c.Written() {
+		return
+	}
+
+	// Changes to a file that has staged changes can only be staged.
+	var staged *db.StagedChange
+	if !isNewFile {
+		staged = getStagedChange(c, oldTreePath)
+		if c.Written() {
+			return
+		}
+	}
+	c.Data["IsStaged"] = staged != nil
+	isStage := f.Stage || staged != nil
+
+	if f.IsNewBrnach() && !isStage {
 		branchName = f.NewBranchName
 	}
 
@@ -177,6 +235,11 @@ func editFilePost(c *context.Context, f form.EditRepoFile, isNewFile bool) {
 			c.RenderWithErr(c.Tr("repo.editor.branch_already_exists", branchName), EDIT_FILE, &f)
 			return
 		}
+		if err := c.Repo.Repository.CheckBranchName(branchName); err != nil {
+			c.FormErr("NewBranchName")
+			c.RenderWithErr(refNameNotAllowedMessage(c, err.(errors.RefNameNotAllowed)), EDIT_FILE, &f)
+			return
+		}
 	}
 
 	var newTreePath string
@@ -211,7 +274,7 @@ func editFilePost(c *context.Context, f form.EditRepoFile, isNewFile bool) {
 		}
 	}
 
-	if !isNewFile {
+	if !isNewFile && staged == nil {
 		_, err := c.Repo.Commit.GetTreeEntryByPath(oldTreePath)
 		if err != nil {
 			if git.IsErrNotExist(err) {
@@ -238,7 +301,8 @@ func editFilePost(c *context.Context, f form.EditRepoFile, isNewFile bool) {
 		}
 	}
 
-	if oldTreePath != f.TreePath {
+	// Renaming a file with staged changes back to its original name is allowed.
+	if oldTreePath != f.TreePath && (staged == nil || staged.OldTreePath != f.TreePath) {
 		// We have a new filename (rename or completely new file) so we need to make sure it doesn't already exist, can't clobber.
 		entry, err := c.Repo.Commit.GetTreeEntryByPath(f.TreePath)
 		if err != nil {
@@ -254,6 +318,33 @@ func editFilePost(c *context.Context, f form.EditRepoFile, isNewFile bool) {
 		}
 	}
 
+	if isStage {
+		opts := db.StageRepoFileOptions{
+			Branch:      oldBranchName,
+			OldTreePath: oldTreePath,
+			TreePath:    f.TreePath,
+			Content:     strings.Replace(f.Content, "\r", "", -1),
+			IsNewFile:   isNewFile,
+		}
+		if isNewFile {
+			opts.OldTreePath = ""
+		}
+		if err := c.Repo.Repository.StageRepoFile(c.User, opts); err != nil {
+			c.FormErr("TreePath")
+			if db.IsErrRepoFileAlreadyExist(err) {
+				c.RenderWithErr(c.Tr("repo.editor.file_already_exists", f.TreePath), EDIT_FILE, &f)
+			} else {
+				log.Error("Failed to stage repo file: %v", err)
+				c.RenderWithErr(c.Tr("repo.editor.fail_to_stage_file", f.TreePath, errors.InternalServerError), EDIT_FILE, &f)
+			}
+			return
+		}
+
+		c.Flash.Success(c.Tr("repo.editor.file_stage_success", f.TreePath))
+		c.Redirect(c.Repo.RepoLink + "/_changes/" + oldBranchName)
+		return
+	}
+
 	message := strings.TrimSpace(f.CommitSummary)
 	if len(message) == 0 {
 		if isNewFile {
@@ -328,6 +419,10 @@ func DiffPreviewPost(c *context.Context, f form.EditPreviewDiff) {
 
 func DeleteFile(c *context.Context) {
 	c.PageIs("Delete")
+	loadNumStagedChanges(c)
+	if c.Written() {
+		return
+	}
 	c.Data["BranchLink"] = c.Repo.RepoLink + "/src/" + c.Repo.BranchName
 	c.Data["TreePath"] = c.Repo.TreePath
 	c.Data["commit_summary"] = ""
@@ -360,12 +455,34 @@ func DeleteFilePost(c *context.Context, f form.DeleteRepoFile) {
 		return
 	}
 
+	if f.Stage {
+		if err := c.Repo.Repository.StageRepoFile(c.User, db.StageRepoFileOptions{
+			Branch:      oldBranchName,
+			OldTreePath: c.Repo.TreePath,
+			TreePath:    c.Repo.TreePath,
+			IsDelete:    true,
+		}); err != nil {
+			log.Error("Failed to stage deletion of repo file: %v", err)
+			c.RenderWithErr(c.Tr("repo.editor.fail_to_stage_file", c.Repo.TreePath, errors.InternalServerError), DELETE_FILE, &f)
+			return
+		}
+
+		c.Flash.Success(c.Tr("repo.editor.file_stage_success", c.Repo.TreePath))
+		c.Redirect(c.Repo.RepoLink + "/_changes/" + oldBranchName)
+		return
+	}
+
 	if oldBranchName != branchName {
 		if _, err := c.Repo.Repository.GetBranch(branchName); err == nil {
 			c.FormErr("NewBranchName")
 			c.RenderWithErr(c.Tr("repo.editor.branch_already_exists", branchName), DELETE_FILE, &f)
 			return
 		}
+		if err := c.Repo.Repository.CheckBranchName(branchName); err != nil {
+			c.FormErr("NewBranchName")
+			c.RenderWithErr(refNameNotAllowedMessage(c, err.(errors.RefNameNotAllowed)), DELETE_FILE, &f)
+			return
+		}
 	}
 
 	message := strings.TrimSpace(f.CommitSummary)
@@ -398,6 +515,143 @@ func DeleteFilePost(c *context.Context, f form.DeleteRepoFile) {
 	}
 }
 
+// stagedChangesSummary returns the default commit summary of staged changes.
+func stagedChangesSummary(c *context.Context, changes []*db.StagedChange) string {
+	if len(changes) != 1 {
+		return c.Tr("repo.editor.update_files", len(changes))
+	}
+
+	change := changes[0]
+	switch {
+	case change.IsDelete:
+		return c.Tr("repo.editor.delete", change.OldTreePath)
+	case change.IsNew:
+		return c.Tr("repo.editor.add", change.TreePath)
+	}
+	return c.Tr("repo.editor.update", change.TreePath)
+}
+
+func loadStagedChanges(c *context.Context) []*db.StagedChange {
+	c.PageIs("Changes")
+	c.Data["Title"] = c.Tr("repo.editor.staged_changes") + " - " + c.Repo.Repository.FullName()
+	c.Data["BranchLink"] = c.Repo.RepoLink + "/src/" + c.Repo.BranchName
+
+	changes, err := db.GetStagedChanges(c.User.ID, c.Repo.Repository.ID, c.Repo.BranchName)
+	if err != nil {
+		c.ServerError("GetStagedChanges", err)
+		return nil
+	}
+	c.Data["Changes"] = changes
+	c.Data["DefaultCommitSummary"] = stagedChangesSummary(c, changes)
+	return changes
+}
+
+// StagedChanges shows changes staged by current user to current branch.
+func StagedChanges(c *context.Context) {
+	loadStagedChanges(c)
+	if c.Written() {
+		return
+	}
+
+	c.Data["commit_summary"] = ""
+	c.Data["commit_message"] = ""
+	c.Data["commit_choice"] = "direct"
+	c.Data["new_branch_name"] = ""
+	c.Success(STAGED_CHANGES)
+}
+
+// StagedChangesPost commits all changes staged by current user to current branch.
+func StagedChangesPost(c *context.Context, f form.CommitStagedChanges) {
+	changes := loadStagedChanges(c)
+	if c.Written() {
+		return
+	}
+
+	oldBranchName := c.Repo.BranchName
+	branchName := oldBranchName
+
+	if f.IsNewBrnach() {
+		branchName = f.NewBranchName
+	}
+	c.Data["commit_summary"] = f.CommitSummary
+	c.Data["commit_message"] = f.CommitMessage
+	c.Data["commit_choice"] = f.CommitChoice
+	c.Data["new_branch_name"] = branchName
+
+	if c.HasError() {
+		c.Success(STAGED_CHANGES)
+		return
+	}
+
+	if len(changes) == 0 {
+		c.RenderWithErr(c.Tr("repo.editor.no_staged_changes"), STAGED_CHANGES, &f)
+		return
+	}
+
+	if oldBranchName != branchName {
+		if _, err := c.Repo.Repository.GetBranch(branchName); err == nil {
+			c.FormErr("NewBranchName")
+			c.RenderWithErr(c.Tr("repo.editor.branch_already_exists", branchName), STAGED_CHANGES, &f)
+			return
+		}
+		if err := c.Repo.Repository.CheckBranchName(branchName); err != nil {
+			c.FormErr("NewBranchName")
+			c.RenderWithErr(refNameNotAllowedMessage(c, err.(errors.RefNameNotAllowed)), STAGED_CHANGES, &f)
+			return
+		}
+	}
+
+	message := strings.TrimSpace(f.CommitSummary)
+	if len(message) == 0 {
+		message = stagedChangesSummary(c, changes)
+	}
+
+	f.CommitMessage = strings.TrimSpace(f.CommitMessage)
+	if len(f.CommitMessage) > 0 {
+		message += "\n\n" + f.CommitMessage
+	}
+
+	if err := c.Repo.Repository.CommitStagedChanges(c.User, db.CommitStagedChangesOptions{
+		OldBranch: oldBranchName,
+		NewBranch: branchName,
+		Message:   message,
+	}); err != nil {
+		if db.IsErrRepoFileAlreadyExist(err) {
+			c.RenderWithErr(c.Tr("repo.editor.file_already_exists", err.(db.ErrRepoFileAlreadyExist).FileName), STAGED_CHANGES, &f)
+			return
+		}
+		log.Error("Failed to commit staged changes: %v", err)
+		c.RenderWithErr(c.Tr("repo.editor.fail_to_commit_changes", errors.InternalServerError), STAGED_CHANGES, &f)
+		return
+	}
+
+	if f.IsNewBrnach() && c.Repo.PullRequest.Allowed {
+		c.Redirect(c.Repo.PullRequestURL(oldBranchName, f.NewBranchName))
+	} else {
+		c.Flash.Success(c.Tr("repo.editor.changes_commit_success", len(changes)))
+		c.Redirect(c.Repo.RepoLink + "/src/" + branchName)
+	}
+}
+
+// UnstageChange removes a change staged by current user.
+func UnstageChange(c *context.Context) {
+	if err := db.UnstageChange(c.User.ID, c.QueryInt64("id")); err != nil {
+		c.NotFoundOrServerError("UnstageChange", errors.IsStagedChangeNotExist, err)
+		return
+	}
+	c.Redirect(c.Repo.RepoLink + "/_changes/" + c.Repo.BranchName)
+}
+
+// DiscardStagedChanges removes all changes staged by current user to current branch.
+func DiscardStagedChanges(c *context.Context) {
+	if err := db.DiscardStagedChanges(c.User.ID, c.Repo.Repository.ID, c.Repo.BranchName); err != nil {
+		c.ServerError("DiscardStagedChanges", err)
+		return
+	}
+	c.Flash.Success(c.Tr("repo.editor.changes_discard_success"))
+	c.Redirect(c.Repo.RepoLink + "/src/" + c.Repo.BranchName)
+}
+
 func renderUploadSettings(c *context.Context) {
 	c.RequireDropzone()
 	c.Data["UploadAllowedTypes"] = strings.Join(conf.Repository.Upload.AllowedTypes, ",")
@@ -463,6 +717,11 @@ func UploadFilePost(c *context.Context, f form.UploadRepoFile) {
 			c.RenderWithErr(c.Tr("repo.editor.branch_already_exists", branchName), UPLOAD_FILE, &f)
 			return
 		}
+		if err := c.Repo.Repository.CheckBranchName(branchName); err != nil {
+			c.FormErr("NewBranchName")
+			c.RenderWithErr(refNameNotAllowedMessage(c, err.(errors.RefNameNotAllowed)), UPLOAD_FILE, &f)
+			return
+		}
 	}
 
 	var newTreePath string
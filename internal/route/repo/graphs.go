@@ -0,0 +1,148 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package repo
+
+import (
+	"sort"
+
+	"github.com/json-iterator/go"
+
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/db/errors"
+)
+
+const (
+	GRAPHS = "repo/graphs"
+)
+
+// contributor is a contributor with the user account matched by email.
+type contributor struct {
+	*db.ContributorStats
+	User      *db.User
+	WeeksJSON string
+}
+
+// loadGraphStats loads graph statistics of the repository for rendering, it returns
+// nil if statistics are being computed.
+func loadGraphStats(c *context.Context, title string) *db.RepoGraphStats {
+	c.Data["Title"] = c.Tr(title) + " - " + c.Repo.Repository.FullName()
+	c.Data["PageIsGraphs"] = true
+
+	stats, err := db.GetRepoGraphStats(c.Repo.Repository)
+	if err != nil {
+		c.ServerError("GetRepoGraphStats", err)
+		return nil
+	}
+	c.Data["GraphStats"] = stats
+	return stats
+}
+
+// renderGraphData saves data of a graph in JSON for rendering by JavaScript.
+func renderGraphData(c *context.Context, data interface{}) {
+	graphData, err := jsoniter.MarshalToString(data)
+	if err != nil {
+		c.ServerError("MarshalToString", err)
+		return
+	}
+	c.Data["GraphData"] = graphData
+	c.HTML(200, GRAPHS)
+}
+
+func GraphContributors(c *context.Context) {
+	c.Data["PageIsGraphContributors"] = true
+	stats := loadGraphStats(c, "repo.graphs.contributors")
+	if c.Written() {
+		return
+	} else if stats == nil {
+		c.HTML(200, GRAPHS)
+		return
+	}
+
+	list, err := stats.GetContributors()
+	if err != nil {
+		c.ServerError("GetContributors", err)
+		return
+	}
+
+	// Weekly numbers of commits of all contributors.
+	weekSet := make(map[int64]*db.ContributorWeek)
+	contributors := make([]*contributor, len(list))
+	for i := range list {
+		contributors[i] = &contributor{ContributorStats: list[i]}
+		if contributors[i].User, err = db.GetUserByEmail(list[i].Email); err != nil && !errors.IsUserNotExist(err) {
+			c.ServerError("GetUserByEmail", err)
+			return
+		}
+
+		for _, w := range list[i].Weeks {
+			if weekSet[w.Week] == nil {
+				weekSet[w.Week] = &db.ContributorWeek{Week: w.Week}
+			}
+			weekSet[w.Week].Commits += w.Commits
+			weekSet[w.Week].Additions += w.Additions
+			weekSet[w.Week].Deletions += w.Deletions
+		}
+		if contributors[i].WeeksJSON, err = jsoniter.MarshalToString(list[i].Weeks); err != nil {
+			c.ServerError("MarshalToString", err)
+			return
+		}
+	}
+	c.Data["Contributors"] = contributors
+
+	weeks := make([]*db.ContributorWeek, 0, len(weekSet))
+	for _, w := range weekSet {
+		weeks = append(weeks, w)
+	}
+	sort.Slice(weeks, func(i, j int) bool { return weeks[i].Week < weeks[j].Week })
+	renderGraphData(c, weeks)
+}
+
+func GraphCommitActivity(c *context.Context) {
+	c.Data["PageIsGraphCommitActivity"] = true
+	stats := loadGraphStats(c, "repo.graphs.commit_activity")
+	if c.Written() {
+		return
+	} else if stats == nil {
+		c.HTML(200, GRAPHS)
+		return
+	}
+
+	activity, err := stats.GetCommitActivity()
+	if err != nil {
+		c.ServerError("GetCommitActivity", err)
+		return
+	}
+
+	// Numbers of commits in each day of a week in total.
+	var days [7]int
+	for _, a := range activity {
+		for i := range a.Days {
+			days[i] += a.Days[i]
+		}
+	}
+	c.Data["Days"] = days
+
+	renderGraphData(c, activity)
+}
+
+func GraphCodeFrequency(c *context.Context) {
+	c.Data["PageIsGraphCodeFrequency"] = true
+	stats := loadGraphStats(c, "repo.graphs.code_frequency")
+	if c.Written() {
+		return
+	} else if stats == nil {
+		c.HTML(200, GRAPHS)
+		return
+	}
+
+	frequency, err := stats.GetCodeFrequency()
+	if err != nil {
+		c.ServerError("GetCodeFrequency", err)
+		return
+	}
+
+	renderGraphData(c, frequency)
+}
@@ -24,6 +24,7 @@ import (
 	"gogs.io/gogs/internal/db"
 	"gogs.io/gogs/internal/db/errors"
 	"gogs.io/gogs/internal/lazyregexp"
+	"gogs.io/gogs/internal/osutil"
 	"gogs.io/gogs/internal/tool"
 )
 
@@ -34,6 +35,7 @@ type HTTPContext struct {
 	RepoID    int64
 	RepoName  string
 	AuthUser  *db.User
+	AgitOnly  bool
 }
 
 // askCredentials responses HTTP header and status which informs client to provide credentials.
@@ -58,6 +60,7 @@ func HTTPContexter() macaron.Handler {
 
 		ownerName := c.Params(":username")
 		repoName := strings.TrimSuffix(c.Params(":reponame"), ".git")
+		isWiki := strings.HasSuffix(repoName, ".wiki")
 		repoName = strings.TrimSuffix(repoName, ".wiki")
 
 		isPull := c.Query("service") == "git-upload-pack" ||
@@ -76,10 +79,17 @@ func HTTPContexter() macaron.Handler {
 			return
 		}
 
-		// Authentication is not required for pulling from public repositories.
-		if isPull && !repo.IsPrivate && !conf.Auth.RequireSigninView {
+		if isWiki && !repo.HasInternalWiki() {
+			c.NotFound()
+			return
+		}
+
+		// Authentication is not required for pulling from public repositories, or wiki
+		// of private repositories that allow public access to wiki.
+		if isPull && !conf.Auth.RequireSigninView && (!repo.IsPrivate || (isWiki && repo.CanGuestViewWiki())) {
 			c.Map(&HTTPContext{
 				Context: c,
+				RepoID:  repo.ID,
 			})
 			return
 		}
@@ -101,47 +111,8 @@ func HTTPContexter() macaron.Handler {
 			return
 		}
 
-		auths := strings.Fields(authHead)
-		if len(auths) != 2 || auths[0] != "Basic" {
-			askCredentials(c, http.StatusUnauthorized, "")
-			return
-		}
-		authUsername, authPassword, err := tool.BasicAuthDecode(auths[1])
-		if err != nil {
-			askCredentials(c, http.StatusUnauthorized, "")
-			return
-		}
-
-		authUser, err := db.UserLogin(authUsername, authPassword, -1)
-		if err != nil && !errors.IsUserNotExist(err) {
-			c.Handle(http.StatusInternalServerError, "UserLogin", err)
-			return
-		}
-
-		// If username and password combination failed, try again using username as a token.
-		if authUser == nil {
-			token, err := db.GetAccessTokenBySHA(authUsername)
-			if err != nil {
-				if db.IsErrAccessTokenEmpty(err) || db.IsErrAccessTokenNotExist(err) {
-					askCredentials(c, http.StatusUnauthorized, "")
-				} else {
-					c.Handle(http.StatusInternalServerError, "GetAccessTokenBySHA", err)
-				}
-				return
-			}
-			token.Updated = time.Now()
-			// TODO: verify or update token.Updated in database
-
-			authUser, err = db.GetUserByID(token.UID)
-			if err != nil {
-				// Once we found token, we're supposed to find its related user,
-				// thus any error is unexpected.
-				c.Handle(http.StatusInternalServerError, "GetUserByID", err)
-				return
-			}
-		} else if authUser.IsEnabledTwoFactor() {
-			askCredentials(c, http.StatusUnauthorized, `User with two-factor authentication enabled cannot perform HTTP/HTTPS operations via plain username and password
-Please create and use personal access token on user settings page`)
+		authUser := httpBasicAuth(c, authHead)
+		if c.Written() {
 			return
 		}
 
@@ -151,11 +122,26 @@ Please create and use personal access token on user settings page`)
 		if isPull {
 			mode = db.ACCESS_MODE_READ
 		}
-		has, err := db.HasAccess(authUser.ID, repo, mode)
+		unit := db.REPO_UNIT_CODE
+		if isWiki {
+			unit = db.REPO_UNIT_WIKI
+		}
+		accessMode, err := db.UserUnitAccessMode(authUser.ID, repo, unit)
 		if err != nil {
-			c.Handle(http.StatusInternalServerError, "HasAccess", err)
+			c.Handle(http.StatusInternalServerError, "UserUnitAccessMode", err)
 			return
-		} else if !has {
+		}
+		has := accessMode >= mode
+		agitOnly := false
+		if isWiki {
+			// Wiki has its own access control separate from code.
+			has = repo.CanAccessWiki(accessMode, mode)
+		} else if !has && accessMode >= db.ACCESS_MODE_READ && repo.AllowsPulls() {
+			// Users who can read the repository are able to create pull requests by
+			// pushing to references of AGit flow, which is checked by pre-receive hook.
+			has, agitOnly = true, true
+		}
+		if !has {
 			askCredentials(c, http.StatusForbidden, "User permission denied")
 			return
 		}
@@ -164,6 +150,22 @@ Please create and use personal access token on user settings page`)
 			c.HandleText(http.StatusForbidden, "Mirror repository is read-only")
 			return
 		}
+		if !isPull && repo.IsArchived {
+			c.HandleText(http.StatusForbidden, "Archived repository is read-only")
+			return
+		}
+		if !isPull && !authUser.HasAcceptedTerms() {
+			c.HandleText(http.StatusForbidden, "You must accept the terms before pushing: "+conf.Server.ExternalURL+"user/terms")
+			return
+		}
+
+		// Wiki is created by the first push if it does not exist yet.
+		if !isPull && isWiki {
+			if err = repo.InitWiki(); err != nil {
+				c.Handle(http.StatusInternalServerError, "InitWiki", err)
+				return
+			}
+		}
 
 		c.Map(&HTTPContext{
 			Context:   c,
@@ -172,10 +174,66 @@ Please create and use personal access token on user settings page`)
 			RepoID:    repo.ID,
 			RepoName:  repoName,
 			AuthUser:  authUser,
+			AgitOnly:  agitOnly,
 		})
 	}
 }
 
+// httpBasicAuth returns the user authenticated by the HTTP Basic Authentication header,
+// the password can be either the password of user or a personal access token in place
+// of the username. It responses to the client and returns nil if authentication failed.
+func httpBasicAuth(c *context.Context, authHead string) *db.User {
+	auths := strings.Fields(authHead)
+	if len(auths) != 2 || auths[0] != "Basic" {
+		askCredentials(c, http.StatusUnauthorized, "")
+		return nil
+	}
+	authUsername, authPassword, err := tool.BasicAuthDecode(auths[1])
+	if err != nil {
+		askCredentials(c, http.StatusUnauthorized, "")
+		return nil
+	}
+
+	authUser, err := db.UserLogin(authUsername, authPassword, -1)
+	if err != nil && !errors.IsUserNotExist(err) {
+		c.Handle(http.StatusInternalServerError, "UserLogin", err)
+		return nil
+	}
+
+	// If username and password combination failed, try again using username as a token.
+	if authUser == nil {
+		token, err := db.GetAccessTokenBySHA(authUsername)
+		if err != nil {
+			if db.IsErrAccessTokenEmpty(err) || db.IsErrAccessTokenNotExist(err) {
+				askCredentials(c, http.StatusUnauthorized, "")
+			} else {
+				c.Handle(http.StatusInternalServerError, "GetAccessTokenBySHA", err)
+			}
+			return nil
+		}
+		token.Updated = time.Now()
+		// TODO: verify or update token.Updated in database
+
+		authUser, err = db.GetUserByID(token.UID)
+		if err != nil {
+			// Once we found token, we're supposed to find its related user,
+			// thus any error is unexpected.
+			c.Handle(http.StatusInternalServerError, "GetUserByID", err)
+			return nil
+		}
+	} else if authUser.IsEnabledTwoFactor() {
+		askCredentials(c, http.StatusUnauthorized, `User with two-factor authentication enabled cannot perform HTTP/HTTPS operations via plain username and password
+Please create and use personal access token on user settings page`)
+		return nil
+	}
+
+	if authUser.IsDeactivated {
+		askCredentials(c, http.StatusForbidden, "User account is deactivated")
+		return nil
+	}
+	return authUser
+}
+
 type serviceHandler struct {
 	w    http.ResponseWriter
 	r    *http.Request
@@ -187,6 +245,7 @@ type serviceHandler struct {
 	ownerSalt string
 	repoID    int64
 	repoName  string
+	agitOnly  bool
 }
 
 func (h *serviceHandler) setHeaderNoCache() {
@@ -242,15 +301,17 @@ func serviceRPC(h serviceHandler, service string) {
 	}
 
 	var stderr bytes.Buffer
-	cmd := exec.Command("git", service, "--stateless-rpc", h.dir)
+	cmd := exec.Command("git", append(h.serviceConfigs(service), service, "--stateless-rpc", h.dir)...)
+	cmd.Env = h.serviceEnvs()
 	if service == "receive-pack" {
-		cmd.Env = append(os.Environ(), db.ComposeHookEnvs(db.ComposeHookEnvsOptions{
+		cmd.Env = append(cmd.Env, db.ComposeHookEnvs(db.ComposeHookEnvsOptions{
 			AuthUser:  h.authUser,
 			OwnerName: h.ownerName,
 			OwnerSalt: h.ownerSalt,
 			RepoID:    h.repoID,
 			RepoName:  h.repoName,
 			RepoPath:  h.dir,
+			AgitOnly:  h.agitOnly,
 		})...)
 	}
 	cmd.Dir = h.dir
@@ -264,6 +325,57 @@ func serviceRPC(h serviceHandler, service string) {
 	}
 }
 
+// isWiki returns true if the request is for the wiki of the repository.
+func (h *serviceHandler) isWiki() bool {
+	return strings.HasSuffix(h.dir, ".wiki.git")
+}
+
+// serviceConfigs returns Git config arguments for running the service, which enable
+// partial clone and advertise the pre-generated bundle of the repository for fetches,
+// and accept push options for pushes.
+func (h *serviceHandler) serviceConfigs(service string) []string {
+	if service == "receive-pack" {
+		return []string{"-c", "receive.advertisePushOptions=true"}
+	} else if service != "upload-pack" {
+		return nil
+	}
+
+	var args []string
+	if !conf.Git.DisablePartialClone {
+		args = append(args,
+			"-c", "uploadpack.allowFilter=true",
+			"-c", "uploadpack.allowAnySHA1InWant=true",
+		)
+	}
+	if !h.isWiki() && osutil.IsFile(db.RepoBundlePath(h.repoID)) {
+		args = append(args,
+			"-c", "uploadpack.advertiseBundleURIs=true",
+			"-c", "bundle.version=1",
+			"-c", "bundle.mode=all",
+			"-c", "bundle.clone.uri="+h.bundleURL(),
+		)
+	}
+	return args
+}
+
+// bundleURL returns the URL of the pre-generated bundle of the repository.
+func (h *serviceHandler) bundleURL() string {
+	u := *h.r.URL
+	u.Path = strings.TrimSuffix(u.Path, "/"+h.file) + "/info/bundles/clone.bundle"
+	u.RawQuery = ""
+	return strings.TrimSuffix(conf.Server.ExternalURL, "/") + strings.TrimPrefix(u.Path, conf.Server.Subpath)
+}
+
+// serviceEnvs returns environment variables for running the service, the Git wire
+// protocol version requested by the client is passed through.
+func (h *serviceHandler) serviceEnvs() []string {
+	envs := os.Environ()
+	if protocol := h.r.Header.Get("Git-Protocol"); protocol != "" {
+		envs = append(envs, "GIT_PROTOCOL="+protocol)
+	}
+	return envs
+}
+
 func serviceUploadPack(h serviceHandler) {
 	serviceRPC(h, "upload-pack")
 }
@@ -312,7 +424,16 @@ func getInfoRefs(h serviceHandler) {
 		return
 	}
 
-	refs := gitCommand(h.dir, service, "--stateless-rpc", "--advertise-refs", ".")
+	cmd := exec.Command("git", append(h.serviceConfigs(service), service, "--stateless-rpc", "--advertise-refs", ".")...)
+	cmd.Dir = h.dir
+	cmd.Env = h.serviceEnvs()
+	refs, err := cmd.Output()
+	if err != nil {
+		log.Error("HTTP.getInfoRefs: fail to advertise refs of '%s': %v", service, err)
+		h.w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
 	h.w.Header().Set("Content-Type", fmt.Sprintf("application/x-git-%s-advertisement", service))
 	h.w.WriteHeader(http.StatusOK)
 	h.w.Write(packetWrite("# service=git-" + service + "\n"))
@@ -320,6 +441,18 @@ func getInfoRefs(h serviceHandler) {
 	h.w.Write(refs)
 }
 
+func getBundleFile(h serviceHandler) {
+	bundlePath := db.RepoBundlePath(h.repoID)
+	if h.isWiki() || !osutil.IsFile(bundlePath) {
+		h.w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	h.setHeaderNoCache()
+	h.w.Header().Set("Content-Type", "application/x-git-bundle")
+	http.ServeFile(h.w, h.r, bundlePath)
+}
+
 func getTextFile(h serviceHandler) {
 	h.setHeaderNoCache()
 	h.sendFile("text/plain")
@@ -353,6 +486,7 @@ var routes = []struct {
 	{lazyregexp.New("(.*?)/git-upload-pack$"), "POST", serviceUploadPack},
 	{lazyregexp.New("(.*?)/git-receive-pack$"), "POST", serviceReceivePack},
 	{lazyregexp.New("(.*?)/info/refs$"), "GET", getInfoRefs},
+	{lazyregexp.New("(.*?)/info/bundles/clone\\.bundle$"), "GET", getBundleFile},
 	{lazyregexp.New("(.*?)/HEAD$"), "GET", getTextFile},
 	{lazyregexp.New("(.*?)/objects/info/alternates$"), "GET", getTextFile},
 	{lazyregexp.New("(.*?)/objects/info/http-alternates$"), "GET", getTextFile},
@@ -368,7 +502,14 @@ func getGitRepoPath(dir string) (string, error) {
 		dir += ".git"
 	}
 
-	filename := path.Join(conf.Repository.Root, dir)
+	// The first segment is the owner name, whose repositories may be stored in
+	// a storage root other than the default one.
+	fields := strings.SplitN(strings.TrimPrefix(dir, "/"), "/", 2)
+	if len(fields) != 2 {
+		return "", os.ErrNotExist
+	}
+
+	filename := path.Join(db.UserPath(fields[0]), fields[1])
 	if _, err := os.Stat(filename); os.IsNotExist(err) {
 		return "", err
 	}
@@ -416,6 +557,7 @@ func HTTP(c *HTTPContext) {
 			ownerSalt: c.OwnerSalt,
 			repoID:    c.RepoID,
 			repoName:  c.RepoName,
+			agitOnly:  c.AgitOnly,
 		})
 		return
 	}
@@ -49,6 +49,13 @@ var (
 		".gogs/ISSUE_TEMPLATE.md",
 		".github/ISSUE_TEMPLATE.md",
 	}
+	// Issue form takes precedence over issue template when both exist.
+	IssueFormCandidates = []string{
+		".gogs/ISSUE_FORM.yaml",
+		".gogs/ISSUE_FORM.yml",
+		".github/ISSUE_FORM.yaml",
+		".github/ISSUE_FORM.yml",
+	}
 )
 
 func MustEnableIssues(c *context.Context) {
@@ -146,14 +153,29 @@ func issues(c *context.Context, isPullList bool) {
 	selectLabels := c.Query("labels")
 	milestoneID := c.QueryInt64("milestone")
 	isShowClosed := c.Query("state") == "closed"
+
+	// Issue IDs are nil when there is no keyword, which means no limit on issues.
+	var issueIDs []int64
+	keyword := strings.TrimSpace(c.Query("q"))
+	if keyword != "" {
+		var err error
+		issueIDs, err = db.SearchIssueIDs([]int64{repo.ID}, keyword)
+		if err != nil {
+			c.ServerError("SearchIssueIDs", err)
+			return
+		}
+	}
+
 	issueStats := db.GetIssueStats(&db.IssueStatsOptions{
-		RepoID:      repo.ID,
-		UserID:      uid,
-		Labels:      selectLabels,
-		MilestoneID: milestoneID,
-		AssigneeID:  assigneeID,
-		FilterMode:  filterMode,
-		IsPull:      isPullList,
+		RepoID:           repo.ID,
+		UserID:           uid,
+		Labels:           selectLabels,
+		MilestoneID:      milestoneID,
+		AssigneeID:       assigneeID,
+		FilterMode:       filterMode,
+		IsPull:           isPullList,
+		IssueIDs:         issueIDs,
+		HideConfidential: !c.Repo.IsTriager(),
 	})
 
 	page := c.QueryInt("page")
@@ -176,12 +198,15 @@ func issues(c *context.Context, isPullList bool) {
 		RepoID:      repo.ID,
 		PosterID:    posterID,
 		MilestoneID: milestoneID,
+		IssueIDs:    issueIDs,
 		Page:        pager.Current(),
 		IsClosed:    isShowClosed,
 		IsMention:   filterMode == db.FILTER_MODE_MENTION,
 		IsPull:      isPullList,
 		Labels:      selectLabels,
 		SortType:    sortType,
+
+		HideConfidential: !c.Repo.IsTriager(),
 	})
 	if err != nil {
 		c.Handle(500, "Issues", err)
@@ -234,6 +259,7 @@ func issues(c *context.Context, isPullList bool) {
 	c.Data["SelectLabels"] = com.StrTo(selectLabels).MustInt64()
 	c.Data["ViewType"] = viewType
 	c.Data["SortType"] = sortType
+	c.Data["Keyword"] = keyword
 	c.Data["MilestoneID"] = milestoneID
 	c.Data["AssigneeID"] = assigneeID
 	c.Data["IsShowClosed"] = isShowClosed
@@ -283,7 +309,7 @@ func RetrieveRepoMilestonesAndAssignees(c *context.Context, repo *db.Repository)
 }
 
 func RetrieveRepoMetas(c *context.Context, repo *db.Repository) []*db.Label {
-	if !c.Repo.IsWriter() {
+	if !c.Repo.IsTriager() {
 		return nil
 	}
 
@@ -339,6 +365,30 @@ func setTemplateIfExists(c *context.Context, ctxDataKey string, possibleFiles []
 	}
 }
 
+// getIssueForm returns the issue form on default branch of the repository, it returns
+// nil when there is no issue form or the form is invalid.
+func getIssueForm(c *context.Context) *db.IssueForm {
+	for _, filename := range IssueFormCandidates {
+		content, found := getFileContentFromDefaultBranch(c, filename)
+		if !found {
+			continue
+		}
+
+		form, err := db.ParseIssueForm([]byte(content))
+		if err != nil {
+			log.Trace("Invalid issue form %q of repository %d: %v", filename, c.Repo.Repository.ID, err)
+			return nil
+		}
+		for _, field := range form.Body {
+			if !field.IsInput() {
+				field.Rendered = string(markup.Markdown(field.Attributes.Value, c.Repo.RepoLink, c.Repo.Repository.ComposeMetas()))
+			}
+		}
+		return form
+	}
+	return nil
+}
+
 func NewIssue(c *context.Context) {
 	c.Data["Title"] = c.Tr("repo.issues.new")
 	c.Data["PageIsIssueList"] = true
@@ -346,7 +396,14 @@ func NewIssue(c *context.Context) {
 	c.Data["RequireSimpleMDE"] = true
 	c.Data["title"] = c.Query("title")
 	c.Data["content"] = c.Query("content")
-	setTemplateIfExists(c, ISSUE_TEMPLATE_KEY, IssueTemplateCandidates)
+	if issueForm := getIssueForm(c); issueForm != nil {
+		c.Data["IssueForm"] = issueForm
+		if c.Data["title"] == "" {
+			c.Data["title"] = issueForm.Title
+		}
+	} else {
+		setTemplateIfExists(c, ISSUE_TEMPLATE_KEY, IssueTemplateCandidates)
+	}
 	renderAttachmentSettings(c)
 
 	RetrieveRepoMetas(c, c.Repo.Repository)
@@ -368,7 +425,7 @@ func ValidateRepoMetas(c *context.Context, f form.NewIssue) ([]int64, int64, int
 		return nil, 0, 0
 	}
 
-	if !c.Repo.IsWriter() {
+	if !c.Repo.IsTriager() {
 		return nil, 0, 0
 	}
 
@@ -423,11 +480,26 @@ func NewIssuePost(c *context.Context, f form.NewIssue) {
 		return
 	}
 
+	issueForm := getIssueForm(c)
+	c.Data["IssueForm"] = issueForm
 	if c.HasError() {
 		c.HTML(200, ISSUE_NEW)
 		return
 	}
 
+	var formValues []*db.IssueFormValue
+	if issueForm != nil {
+		var missing string
+		formValues, missing = issueForm.Values(func(id string) []string {
+			return c.QueryStrings("issue_form_" + id)
+		})
+		if missing != "" {
+			c.RenderWithErr(c.Tr("repo.issues.form_field_required", missing), ISSUE_NEW, &f)
+			return
+		}
+		f.Content = db.RenderIssueFormContent(formValues)
+	}
+
 	var attachments []string
 	if conf.AttachmentEnabled {
 		attachments = f.Files
@@ -441,17 +513,25 @@ func NewIssuePost(c *context.Context, f form.NewIssue) {
 		MilestoneID: milestoneID,
 		AssigneeID:  assigneeID,
 		Content:     f.Content,
+
+		IsConfidential: f.Confidential,
 	}
 	if err := db.NewIssue(c.Repo.Repository, issue, labelIDs, attachments); err != nil {
 		c.Handle(500, "NewIssue", err)
 		return
 	}
+	if issueForm != nil {
+		if err := db.NewIssueFormData(issue.ID, issueForm.Name, formValues); err != nil {
+			c.ServerError("NewIssueFormData", err)
+			return
+		}
+	}
 
 	log.Trace("Issue created: %d/%d", c.Repo.Repository.ID, issue.ID)
 	c.RawRedirect(c.Repo.MakeURL(fmt.Sprintf("issues/%d", issue.Index)))
 }
 
-func uploadAttachment(c *context.Context, allowedTypes []string) {
+func uploadAttachment(c *context.Context, allowedTypes []string, newAttachment func(name string, r io.Reader, size int64) (*db.Attachment, error)) {
 	file, header, err := c.Req.FormFile("file")
 	if err != nil {
 		c.Error(500, fmt.Sprintf("FormFile: %v", err))
@@ -461,10 +541,7 @@ func uploadAttachment(c *context.Context, allowedTypes []string) {
 
 	buf := make([]byte, 1024)
 	n, _ := file.Read(buf)
-	if n > 0 {
-		buf = buf[:n]
-	}
-	fileType := http.DetectContentType(buf)
+	fileType := http.DetectContentType(buf[:n])
 
 	allowed := false
 	for _, t := range allowedTypes {
@@ -480,7 +557,12 @@ func uploadAttachment(c *context.Context, allowedTypes []string) {
 		return
 	}
 
-	attach, err := db.NewAttachment(header.Filename, buf, file)
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		c.Error(500, fmt.Sprintf("Seek: %v", err))
+		return
+	}
+
+	attach, err := newAttachment(header.Filename, file, header.Size)
 	if err != nil {
 		c.Error(500, fmt.Sprintf("NewAttachment: %v", err))
 		return
@@ -498,7 +580,7 @@ func UploadIssueAttachment(c *context.Context) {
 		return
 	}
 
-	uploadAttachment(c, strings.Split(conf.AttachmentAllowedTypes, ","))
+	uploadAttachment(c, strings.Split(conf.AttachmentAllowedTypes, ","), db.NewAttachment)
 }
 
 func viewIssue(c *context.Context, isPullList bool) {
@@ -516,6 +598,9 @@ func viewIssue(c *context.Context, isPullList bool) {
 	if err != nil {
 		c.NotFoundOrServerError("GetIssueByIndex", errors.IsIssueNotExist, err)
 		return
+	} else if !c.Repo.CanSeeIssue(issue, c.UserID()) {
+		c.NotFound()
+		return
 	}
 	c.Data["Title"] = issue.Title
 
@@ -582,7 +667,7 @@ func viewIssue(c *context.Context, isPullList bool) {
 	c.Data["Labels"] = labels
 
 	// Check milestone and assignee.
-	if c.Repo.IsWriter() {
+	if c.Repo.IsTriager() {
 		RetrieveRepoMilestonesAndAssignees(c, repo)
 		if c.Written() {
 			return
@@ -610,6 +695,13 @@ func viewIssue(c *context.Context, isPullList bool) {
 	for _, comment = range issue.Comments {
 		if comment.Type == db.COMMENT_TYPE_COMMENT {
 			comment.RenderedContent = string(markup.Markdown(comment.Content, c.Repo.RepoLink, c.Repo.Repository.ComposeMetas()))
+			if comment.NumRevisions > 0 {
+				comment.Revisions, err = db.GetCommentRevisions(comment.ID)
+				if err != nil {
+					c.ServerError("GetCommentRevisions", err)
+					return
+				}
+			}
 
 			// Check tag.
 			tag, ok = marked[comment.PosterID]
@@ -644,31 +736,74 @@ func viewIssue(c *context.Context, isPullList bool) {
 
 	if issue.IsPull && issue.PullRequest.HasMerged {
 		pull := issue.PullRequest
-		branchProtected := false
-		protectBranch, err := db.GetProtectBranchOfRepoByName(pull.BaseRepoID, pull.HeadBranch)
+		c.Data["IsPullBranchDeletable"], err = canDeletePullBranch(c, pull)
 		if err != nil {
-			if !errors.IsErrBranchNotExist(err) {
-				c.ServerError("GetProtectBranchOfRepoByName", err)
-				return
-			}
-		} else {
-			branchProtected = protectBranch.Protected
+			c.ServerError("canDeletePullBranch", err)
+			return
 		}
 
-		c.Data["IsPullBranchDeletable"] = pull.BaseRepoID == pull.HeadRepoID &&
-			c.Repo.IsWriter() && c.Repo.GitRepo.IsBranchExist(pull.HeadBranch) &&
-			!branchProtected
-
 		c.Data["DeleteBranchLink"] = c.Repo.MakeURL(url.URL{
 			Path:     "branches/delete/" + pull.HeadBranch,
 			RawQuery: fmt.Sprintf("commit=%s&redirect_to=%s", pull.MergedCommitID, c.Data["Link"]),
 		})
+
+		// The head branch deleted after merging can be restored until it is purged.
+		if pull.BaseRepoID == pull.HeadRepoID && !pull.IsAgit() && c.Repo.CanWrite(db.REPO_UNIT_CODE) &&
+			!c.Repo.GitRepo.IsBranchExist(pull.HeadBranch) {
+			b, err := db.GetDeletedBranchByCommit(c.Repo.Repository.ID, pull.HeadBranch, pull.MergedCommitID)
+			if err == nil {
+				c.Data["RestorableBranch"] = b
+			} else if !errors.IsDeletedBranchNotExist(err) {
+				c.ServerError("GetDeletedBranchByCommit", err)
+				return
+			}
+		}
+	} else if issue.IsPull && !issue.IsClosed {
+		c.Data["CanDeletePullBranch"], err = canDeletePullBranch(c, issue.PullRequest)
+		if err != nil {
+			c.ServerError("canDeletePullBranch", err)
+			return
+		}
+	}
+
+	if issue.IsPull {
+		c.Data["ReviewRequests"], err = db.GetReviewRequestsByIssueID(issue.ID)
+		if err != nil {
+			c.ServerError("GetReviewRequestsByIssueID", err)
+			return
+		}
+	}
+
+	if issue.IsPull && c.Repo.Repository.PullsRequireResolved {
+		numUnresolved := 0
+		for _, comment := range issue.Comments {
+			if comment.Type == db.COMMENT_TYPE_COMMENT && !comment.IsResolved() {
+				numUnresolved++
+			}
+		}
+		c.Data["NumUnresolvedConversations"] = numUnresolved
+	}
+
+	events, err := db.GetIssueEventsByIssueID(issue.ID)
+	if err != nil {
+		c.ServerError("GetIssueEventsByIssueID", err)
+		return
+	}
+	c.Data["Timeline"] = issue.Timeline(events)
+
+	if c.IsLogged {
+		c.Data["IsIssueWatching"], err = db.IsIssueWatching(c.User.ID, issue)
+		if err != nil {
+			c.ServerError("IsIssueWatching", err)
+			return
+		}
 	}
 
 	c.Data["Participants"] = participants
 	c.Data["NumParticipants"] = len(participants)
 	c.Data["Issue"] = issue
-	c.Data["IsIssueOwner"] = c.Repo.IsWriter() || (c.IsLogged && issue.IsPoster(c.User.ID))
+	c.Data["IsIssueOwner"] = c.Repo.CanWrite(db.REPO_UNIT_ISSUES) || (c.IsLogged && issue.IsPoster(c.User.ID))
+	c.Data["CanChangeIssueStatus"] = c.Repo.IsTriager() || (c.IsLogged && issue.IsPoster(c.User.ID))
 	c.Data["SignInLink"] = conf.Server.Subpath + "/user/login?redirect_to=" + c.Data["Link"].(string)
 	c.HTML(200, ISSUE_VIEW)
 }
@@ -692,6 +827,9 @@ func getActionIssue(c *context.Context) *db.Issue {
 	if !c.Repo.HasAccess() && issue.IsPull {
 		c.NotFound()
 		return nil
+	} else if !c.Repo.CanSeeIssue(issue, c.UserID()) {
+		c.NotFound()
+		return nil
 	}
 
 	return issue
@@ -703,7 +841,7 @@ func UpdateIssueTitle(c *context.Context) {
 		return
 	}
 
-	if !c.IsLogged || (!issue.IsPoster(c.User.ID) && !c.Repo.IsWriter()) {
+	if !c.IsLogged || (!issue.IsPoster(c.User.ID) && !c.Repo.CanWrite(db.REPO_UNIT_ISSUES)) {
 		c.Error(403)
 		return
 	}
@@ -724,13 +862,54 @@ func UpdateIssueTitle(c *context.Context) {
 	})
 }
 
+// WatchIssue subscribes the user to the issue or unsubscribes from it regardless of
+// the watch mode of the repository.
+func WatchIssue(c *context.Context) {
+	issue := getActionIssue(c)
+	if c.Written() {
+		return
+	}
+
+	if err := db.SetIssueWatch(c.User.ID, issue, c.QueryBool("watch")); err != nil {
+		c.ServerError("SetIssueWatch", err)
+		return
+	}
+
+	if issue.IsPull {
+		c.RawRedirect(c.Repo.MakeURL(fmt.Sprintf("pulls/%d", issue.Index)))
+	} else {
+		c.RawRedirect(c.Repo.MakeURL(fmt.Sprintf("issues/%d", issue.Index)))
+	}
+}
+
+// UpdateIssueConfidential makes the issue confidential or public.
+func UpdateIssueConfidential(c *context.Context) {
+	issue := getActionIssue(c)
+	if c.Written() {
+		return
+	} else if issue.IsPull {
+		c.NotFound()
+		return
+	}
+
+	isConfidential := c.QueryBool("confidential")
+	if issue.IsConfidential != isConfidential {
+		if err := issue.ChangeConfidential(isConfidential); err != nil {
+			c.ServerError("ChangeConfidential", err)
+			return
+		}
+	}
+
+	c.RawRedirect(c.Repo.MakeURL(fmt.Sprintf("issues/%d", issue.Index)))
+}
+
 func UpdateIssueContent(c *context.Context) {
 	issue := getActionIssue(c)
 	if c.Written() {
 		return
 	}
 
-	if !c.IsLogged || (c.User.ID != issue.PosterID && !c.Repo.IsWriter()) {
+	if !c.IsLogged || (c.User.ID != issue.PosterID && !c.Repo.CanWrite(db.REPO_UNIT_ISSUES)) {
 		c.Error(403)
 		return
 	}
@@ -746,6 +925,35 @@ func UpdateIssueContent(c *context.Context) {
 	})
 }
 
+// UpdateIssueTask checks or unchecks a task list item in the issue content.
+func UpdateIssueTask(c *context.Context) {
+	issue := getActionIssue(c)
+	if c.Written() {
+		return
+	}
+
+	if !c.IsLogged || (c.User.ID != issue.PosterID && !c.Repo.CanWrite(db.REPO_UNIT_ISSUES)) {
+		c.Error(403)
+		return
+	}
+
+	content, ok := markup.SetTaskListItem(issue.Content, c.QueryInt("index"), c.QueryBool("checked"))
+	if !ok {
+		c.Error(422)
+		return
+	}
+	if content != issue.Content {
+		if err := issue.ChangeContent(c.User, content); err != nil {
+			c.ServerError("ChangeContent", err)
+			return
+		}
+	}
+
+	c.JSON(200, map[string]string{
+		"raw": issue.Content,
+	})
+}
+
 func UpdateIssueLabel(c *context.Context) {
 	issue := getActionIssue(c)
 	if c.Written() {
@@ -859,7 +1067,7 @@ func NewComment(c *context.Context, f form.CreateComment) {
 	var comment *db.Comment
 	defer func() {
 		// Check if issue admin/poster changes the status of issue.
-		if (c.Repo.IsWriter() || (c.IsLogged && issue.IsPoster(c.User.ID))) &&
+		if (c.Repo.IsTriager() || (c.IsLogged && issue.IsPoster(c.User.ID))) &&
 			(f.Status == "reopen" || f.Status == "close") &&
 			!(issue.IsPull && issue.PullRequest.HasMerged) {
 
@@ -962,6 +1170,41 @@ func UpdateCommentContent(c *context.Context) {
 	})
 }
 
+// UpdateCommentTask checks or unchecks a task list item in the comment content.
+func UpdateCommentTask(c *context.Context) {
+	comment, err := db.GetCommentByID(c.ParamsInt64(":id"))
+	if err != nil {
+		c.NotFoundOrServerError("GetCommentByID", db.IsErrCommentNotExist, err)
+		return
+	}
+
+	if c.UserID() != comment.PosterID && !c.Repo.IsAdmin() {
+		c.Error(404)
+		return
+	} else if comment.Type != db.COMMENT_TYPE_COMMENT {
+		c.Error(204)
+		return
+	}
+
+	content, ok := markup.SetTaskListItem(comment.Content, c.QueryInt("index"), c.QueryBool("checked"))
+	if !ok {
+		c.Error(422)
+		return
+	}
+	if content != comment.Content {
+		oldContent := comment.Content
+		comment.Content = content
+		if err = db.UpdateComment(c.User, comment, oldContent); err != nil {
+			c.ServerError("UpdateComment", err)
+			return
+		}
+	}
+
+	c.JSON(200, map[string]string{
+		"raw": comment.Content,
+	})
+}
+
 func DeleteComment(c *context.Context) {
 	comment, err := db.GetCommentByID(c.ParamsInt64(":id"))
 	if err != nil {
@@ -985,6 +1228,110 @@ func DeleteComment(c *context.Context) {
 	c.Status(200)
 }
 
+// getCommentRevision returns the revision of the comment of given IDs in the
+// current repository.
+func getCommentRevision(c *context.Context) *db.CommentRevision {
+	comment, err := db.GetCommentByID(c.ParamsInt64(":id"))
+	if err != nil {
+		c.NotFoundOrServerError("GetCommentByID", db.IsErrCommentNotExist, err)
+		return nil
+	} else if comment.Issue.RepoID != c.Repo.Repository.ID || !c.Repo.CanSeeIssue(comment.Issue, c.UserID()) {
+		c.NotFound()
+		return nil
+	}
+
+	revision, err := db.GetCommentRevision(comment.ID, c.ParamsInt64(":revision_id"))
+	if err != nil {
+		c.NotFoundOrServerError("GetCommentRevision", errors.IsCommentRevisionNotExist, err)
+		return nil
+	}
+	return revision
+}
+
+// GetCommentRevision returns the rendered content of the comment before the edit
+// of the revision.
+func GetCommentRevision(c *context.Context) {
+	revision := getCommentRevision(c)
+	if c.Written() {
+		return
+	}
+
+	c.JSON(200, map[string]string{
+		"content": string(markup.Markdown(revision.Content, c.Repo.RepoLink, c.Repo.Repository.ComposeMetas())),
+	})
+}
+
+// DeleteCommentRevision deletes a revision of the comment from the edit history.
+func DeleteCommentRevision(c *context.Context) {
+	if !c.Repo.IsAdmin() {
+		c.Error(404)
+		return
+	}
+
+	revision := getCommentRevision(c)
+	if c.Written() {
+		return
+	}
+
+	if err := db.DeleteCommentRevision(revision.CommentID, revision.ID); err != nil {
+		c.ServerError("DeleteCommentRevision", err)
+		return
+	}
+
+	c.Status(200)
+}
+
+// getResolvableComment returns the comment of given ID if the current user is allowed to
+// change the resolution of its conversation, i.e. a writer of the repository or the poster
+// of the pull request.
+func getResolvableComment(c *context.Context) *db.Comment {
+	comment, err := db.GetCommentByID(c.ParamsInt64(":id"))
+	if err != nil {
+		c.NotFoundOrServerError("GetCommentByID", db.IsErrCommentNotExist, err)
+		return nil
+	}
+
+	if comment.Issue.RepoID != c.Repo.Repository.ID || !comment.Issue.IsPull {
+		c.NotFound()
+		return nil
+	} else if !c.Repo.CanWrite(db.REPO_UNIT_ISSUES) && !comment.Issue.IsPoster(c.UserID()) {
+		c.Error(404)
+		return nil
+	} else if comment.Type != db.COMMENT_TYPE_COMMENT {
+		c.Error(204)
+		return nil
+	}
+	return comment
+}
+
+func ResolveComment(c *context.Context) {
+	comment := getResolvableComment(c)
+	if c.Written() {
+		return
+	}
+
+	if err := db.ResolveComment(c.User, comment); err != nil {
+		c.Handle(500, "ResolveComment", err)
+		return
+	}
+
+	c.Status(200)
+}
+
+func UnresolveComment(c *context.Context) {
+	comment := getResolvableComment(c)
+	if c.Written() {
+		return
+	}
+
+	if err := db.UnresolveComment(comment); err != nil {
+		c.Handle(500, "UnresolveComment", err)
+		return
+	}
+
+	c.Status(200)
+}
+
 func Labels(c *context.Context) {
 	c.Data["Title"] = c.Tr("repo.labels")
 	c.Data["PageIsIssueList"] = true
@@ -1125,6 +1472,22 @@ func Milestones(c *context.Context) {
 	c.HTML(200, MILESTONE)
 }
 
+// MilestoneProgress renders daily numbers of open and closed issues of the milestone in JSON.
+func MilestoneProgress(c *context.Context) {
+	m, err := db.GetMilestoneByRepoID(c.Repo.Repository.ID, c.ParamsInt64(":id"))
+	if err != nil {
+		c.NotFoundOrServerError("GetMilestoneByRepoID", db.IsErrMilestoneNotExist, err)
+		return
+	}
+
+	progress, err := m.Progress(false)
+	if err != nil {
+		c.ServerError("Progress", err)
+		return
+	}
+	c.JSONSuccess(progress)
+}
+
 func NewMilestone(c *context.Context) {
 	c.Data["Title"] = c.Tr("repo.milestones.new")
 	c.Data["PageIsIssueList"] = true
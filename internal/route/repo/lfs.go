@@ -0,0 +1,650 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package repo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/macaron.v1"
+	log "unknwon.dev/clog/v2"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/db/errors"
+	"gogs.io/gogs/internal/storage"
+)
+
+// The Git LFS API is documented at https://github.com/git-lfs/git-lfs/tree/master/docs/api.
+
+const lfsMediaType = "application/vnd.git-lfs+json"
+
+type LFSContext struct {
+	*context.Context
+	Repo *db.Repository
+	// AuthUser is nil for anonymous requests and requests authorized by tokens issued
+	// to deploy keys.
+	AuthUser *db.User
+	// AccessMode is the access mode of the request to the repository.
+	AccessMode db.AccessMode
+}
+
+type lfsErrorResponse struct {
+	Message string `json:"message"`
+}
+
+// lfsJSON responses the value in JSON with the media type of Git LFS.
+func lfsJSON(c *LFSContext, status int, v interface{}) {
+	c.Resp.Header().Set("Content-Type", lfsMediaType)
+	c.Resp.WriteHeader(status)
+	if err := json.NewEncoder(c.Resp).Encode(v); err != nil {
+		log.Error("Failed to encode LFS response: %v", err)
+	}
+}
+
+func lfsError(c *LFSContext, status int, msg string) {
+	lfsJSON(c, status, &lfsErrorResponse{Message: msg})
+}
+
+func lfsServerError(c *LFSContext, title string, err error) {
+	log.Error("%s: %v", title, err)
+	lfsError(c, http.StatusInternalServerError, "Internal server error")
+}
+
+// lfsDecode decodes the request body in JSON into v, and responses to the client
+// if it is malformed.
+func lfsDecode(c *LFSContext, v interface{}) bool {
+	if err := json.NewDecoder(c.Req.Request.Body).Decode(v); err != nil {
+		lfsError(c, http.StatusUnprocessableEntity, fmt.Sprintf("Malformed request body: %v", err))
+		return false
+	}
+	return true
+}
+
+// LFSContexter resolves the repository and authenticates the request of Git LFS API.
+// Either HTTP Basic Authentication or a token issued via SSH is accepted, requests
+// without credentials are processed as anonymous.
+func LFSContexter() macaron.Handler {
+	return func(c *context.Context) {
+		if !conf.LFS.Enabled {
+			c.NotFound()
+			return
+		}
+
+		owner, err := db.GetUserByName(c.Params(":username"))
+		if err != nil {
+			c.NotFoundOrServerError("GetUserByName", errors.IsUserNotExist, err)
+			return
+		}
+		repo, err := db.GetRepositoryByName(owner.ID, strings.TrimSuffix(c.Params(":reponame"), ".git"))
+		if err != nil {
+			c.NotFoundOrServerError("GetRepositoryByName", errors.IsRepoNotExist, err)
+			return
+		}
+
+		lc := &LFSContext{
+			Context:    c,
+			Repo:       repo,
+			AccessMode: db.ACCESS_MODE_NONE,
+		}
+		if !repo.IsPrivate && !conf.Auth.RequireSigninView {
+			lc.AccessMode = db.ACCESS_MODE_READ
+		}
+
+		authHead := c.Req.Header.Get("Authorization")
+		switch {
+		case authHead == "":
+		case strings.HasPrefix(authHead, "Bearer "):
+			userID, mode, ok := db.VerifyLFSToken(strings.TrimPrefix(authHead, "Bearer "), repo.ID)
+			if !ok {
+				lfsError(lc, http.StatusUnauthorized, "Invalid or expired token")
+				return
+			}
+			if userID > 0 {
+				lc.AuthUser, err = db.GetUserByID(userID)
+				if err != nil {
+					lfsServerError(lc, "GetUserByID", err)
+					return
+				} else if lc.AuthUser.IsDeactivated {
+					lfsError(lc, http.StatusForbidden, "User account is deactivated")
+					return
+				}
+			}
+			lc.AccessMode = mode
+		default:
+			lc.AuthUser = httpBasicAuth(c, authHead)
+			if c.Written() {
+				return
+			}
+			lc.AccessMode, err = db.UserUnitAccessMode(lc.AuthUser.ID, repo, db.REPO_UNIT_CODE)
+			if err != nil {
+				lfsServerError(lc, "UserUnitAccessMode", err)
+				return
+			}
+		}
+
+		c.Map(lc)
+	}
+}
+
+// requireAccess responses to the client if the request does not have given access
+// mode to the repository.
+func (c *LFSContext) requireAccess(mode db.AccessMode) bool {
+	if c.AccessMode < mode {
+		if c.Req.Header.Get("Authorization") == "" {
+			c.Resp.Header().Set("LFS-Authenticate", "Basic realm=\".\"")
+			c.Resp.Header().Set("WWW-Authenticate", "Basic realm=\".\"")
+			lfsError(c, http.StatusUnauthorized, "Credentials needed")
+		} else if c.AccessMode < db.ACCESS_MODE_READ {
+			lfsError(c, http.StatusNotFound, "Repository not found")
+		} else {
+			lfsError(c, http.StatusForbidden, "User permission denied")
+		}
+		return false
+	}
+
+	if mode >= db.ACCESS_MODE_WRITE {
+		switch {
+		case c.Repo.IsMirror:
+			lfsError(c, http.StatusForbidden, "Mirror repository is read-only")
+			return false
+		case c.Repo.IsArchived:
+			lfsError(c, http.StatusForbidden, "Archived repository is read-only")
+			return false
+		case c.AuthUser != nil && !c.AuthUser.HasAcceptedTerms():
+			lfsError(c, http.StatusForbidden, "You must accept the terms before pushing: "+conf.Server.ExternalURL+"user/terms")
+			return false
+		}
+	}
+	return true
+}
+
+// requireUser responses to the client if the request is not made by a user, which
+// is required by the lock API.
+func (c *LFSContext) requireUser() bool {
+	if c.AuthUser == nil {
+		lfsError(c, http.StatusForbidden, "Locks can only be managed by users")
+		return false
+	}
+	return true
+}
+
+// baseURL returns the URL of Git LFS API of the repository.
+func (c *LFSContext) baseURL() string {
+	return c.Repo.HTMLURL() + ".git/info/lfs"
+}
+
+type lfsPointer struct {
+	OID  string `json:"oid"`
+	Size int64  `json:"size"`
+}
+
+type lfsBatchRequest struct {
+	Operation string        `json:"operation"`
+	Transfers []string      `json:"transfers"`
+	Objects   []*lfsPointer `json:"objects"`
+}
+
+type lfsAction struct {
+	Href      string            `json:"href"`
+	Header    map[string]string `json:"header,omitempty"`
+	ExpiresIn int               `json:"expires_in,omitempty"`
+}
+
+type lfsObjectError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type lfsBatchObject struct {
+	OID           string                `json:"oid"`
+	Size          int64                 `json:"size"`
+	Authenticated bool                  `json:"authenticated,omitempty"`
+	Actions       map[string]*lfsAction `json:"actions,omitempty"`
+	Error         *lfsObjectError       `json:"error,omitempty"`
+}
+
+type lfsBatchResponse struct {
+	Transfer string            `json:"transfer"`
+	Objects  []*lfsBatchObject `json:"objects"`
+}
+
+// LFSBatch handles the batch API, which returns actions to download or upload objects
+// with the basic transfer adapter.
+func LFSBatch(c *LFSContext) {
+	var req lfsBatchRequest
+	if !lfsDecode(c, &req) {
+		return
+	}
+
+	mode := db.ACCESS_MODE_READ
+	switch req.Operation {
+	case "download":
+	case "upload":
+		mode = db.ACCESS_MODE_WRITE
+	default:
+		lfsError(c, http.StatusUnprocessableEntity, fmt.Sprintf("Unsupported operation %q", req.Operation))
+		return
+	}
+	if !c.requireAccess(mode) {
+		return
+	}
+
+	if len(req.Transfers) > 0 {
+		supported := false
+		for _, t := range req.Transfers {
+			if t == "basic" {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			lfsError(c, http.StatusUnprocessableEntity, "Only basic transfer adapter is supported")
+			return
+		}
+	}
+
+	// Credentials of the batch request are also used for transfers.
+	var header map[string]string
+	if authHead := c.Req.Header.Get("Authorization"); authHead != "" {
+		header = map[string]string{"Authorization": authHead}
+	}
+	objectURL := c.baseURL() + "/objects/basic/"
+
+	var newSize int64
+	objects := make([]*lfsBatchObject, len(req.Objects))
+	for i, p := range req.Objects {
+		obj := &lfsBatchObject{
+			OID:           p.OID,
+			Size:          p.Size,
+			Authenticated: true,
+		}
+		objects[i] = obj
+
+		if !db.IsValidLFSOID(p.OID) || p.Size < 0 {
+			obj.Error = &lfsObjectError{Code: http.StatusUnprocessableEntity, Message: "Invalid object ID or size"}
+			continue
+		}
+
+		o, err := db.GetLFSObject(c.Repo.ID, p.OID)
+		if err != nil && !errors.IsLFSObjectNotExist(err) {
+			lfsServerError(c, "GetLFSObject", err)
+			return
+		}
+
+		if req.Operation == "download" {
+			if o == nil {
+				obj.Error = &lfsObjectError{Code: http.StatusNotFound, Message: "Object does not exist"}
+				continue
+			}
+			obj.Size = o.Size
+			obj.Actions = map[string]*lfsAction{
+				"download": {Href: objectURL + o.OID, Header: header},
+			}
+			continue
+		}
+
+		// Objects already uploaded are omitted from actions.
+		if o != nil {
+			continue
+		}
+		if conf.LFS.MaxFileSize > 0 && p.Size > conf.LFS.MaxFileSize<<20 {
+			obj.Error = &lfsObjectError{
+				Code:    http.StatusUnprocessableEntity,
+				Message: fmt.Sprintf("Object exceeds the max size of %d MB", conf.LFS.MaxFileSize),
+			}
+			continue
+		}
+		newSize += p.Size
+		obj.Actions = map[string]*lfsAction{
+			"upload": {Href: objectURL + p.OID, Header: header},
+			"verify": {Href: objectURL + "verify", Header: header},
+		}
+	}
+
+	if newSize > 0 {
+		if err := db.CheckLFSQuota(c.Repo.ID, newSize); err != nil {
+			if errors.IsLFSQuotaExceeded(err) {
+				lfsError(c, http.StatusInsufficientStorage, fmt.Sprintf("Repository exceeds the LFS quota of %d MB", conf.LFS.MaxRepoSize))
+			} else {
+				lfsServerError(c, "CheckLFSQuota", err)
+			}
+			return
+		}
+	}
+
+	lfsJSON(c, http.StatusOK, &lfsBatchResponse{
+		Transfer: "basic",
+		Objects:  objects,
+	})
+}
+
+// LFSDownloadObject responses the content of object by ":oid" parameter.
+func LFSDownloadObject(c *LFSContext) {
+	if !c.requireAccess(db.ACCESS_MODE_READ) {
+		return
+	}
+
+	o, err := db.GetLFSObject(c.Repo.ID, c.Params(":oid"))
+	if err != nil {
+		if errors.IsLFSObjectNotExist(err) {
+			lfsError(c, http.StatusNotFound, "Object does not exist")
+		} else {
+			lfsServerError(c, "GetLFSObject", err)
+		}
+		return
+	}
+
+	r, err := o.Open()
+	if err != nil {
+		if err == storage.ErrNotExist {
+			lfsError(c, http.StatusNotFound, "Object does not exist")
+		} else {
+			lfsServerError(c, "Open", err)
+		}
+		return
+	}
+	defer r.Close()
+
+	c.Resp.Header().Set("Content-Type", "application/octet-stream")
+	c.Resp.Header().Set("Content-Length", strconv.FormatInt(o.Size, 10))
+	c.Resp.WriteHeader(http.StatusOK)
+	if _, err = io.Copy(c.Resp, r); err != nil {
+		log.Error("Failed to send LFS object [oid: %s]: %v", o.OID, err)
+	}
+}
+
+// LFSUploadObject saves the request body as content of object by ":oid" parameter.
+func LFSUploadObject(c *LFSContext) {
+	if !c.requireAccess(db.ACCESS_MODE_WRITE) {
+		return
+	}
+
+	oid := c.Params(":oid")
+	if !db.IsValidLFSOID(oid) {
+		lfsError(c, http.StatusUnprocessableEntity, "Invalid object ID")
+		return
+	}
+	size := c.Req.ContentLength
+	if size < 0 {
+		lfsError(c, http.StatusLengthRequired, "Content length is required")
+		return
+	} else if conf.LFS.MaxFileSize > 0 && size > conf.LFS.MaxFileSize<<20 {
+		lfsError(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("Object exceeds the max size of %d MB", conf.LFS.MaxFileSize))
+		return
+	}
+
+	// Uploading the same object again is a no-op.
+	_, err := db.GetLFSObject(c.Repo.ID, oid)
+	if err == nil {
+		c.Status(http.StatusOK)
+		return
+	} else if !errors.IsLFSObjectNotExist(err) {
+		lfsServerError(c, "GetLFSObject", err)
+		return
+	}
+
+	_, err = db.CreateLFSObject(c.Repo.ID, oid, size, c.Req.Request.Body)
+	if err != nil {
+		switch {
+		case errors.IsLFSObjectMismatch(err):
+			lfsError(c, http.StatusUnprocessableEntity, "Content does not match the object ID or size")
+		case errors.IsLFSQuotaExceeded(err):
+			lfsError(c, http.StatusInsufficientStorage, fmt.Sprintf("Repository exceeds the LFS quota of %d MB", conf.LFS.MaxRepoSize))
+		default:
+			lfsServerError(c, "CreateLFSObject", err)
+		}
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// LFSVerifyObject confirms the object has been uploaded with expected size.
+func LFSVerifyObject(c *LFSContext) {
+	if !c.requireAccess(db.ACCESS_MODE_WRITE) {
+		return
+	}
+
+	var p lfsPointer
+	if !lfsDecode(c, &p) {
+		return
+	}
+
+	o, err := db.GetLFSObject(c.Repo.ID, p.OID)
+	if err != nil {
+		if errors.IsLFSObjectNotExist(err) {
+			lfsError(c, http.StatusNotFound, "Object does not exist")
+		} else {
+			lfsServerError(c, "GetLFSObject", err)
+		}
+		return
+	} else if o.Size != p.Size {
+		lfsError(c, http.StatusUnprocessableEntity, "Object size does not match")
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+type lfsLockOwner struct {
+	Name string `json:"name"`
+}
+
+type lfsLock struct {
+	ID       string        `json:"id"`
+	Path     string        `json:"path"`
+	LockedAt time.Time     `json:"locked_at"`
+	Owner    *lfsLockOwner `json:"owner"`
+}
+
+func toLFSLock(l *db.LFSLock) *lfsLock {
+	return &lfsLock{
+		ID:       strconv.FormatInt(l.ID, 10),
+		Path:     l.Path,
+		LockedAt: l.Created.UTC(),
+		Owner:    &lfsLockOwner{Name: l.Owner.Name},
+	}
+}
+
+type lfsLockResponse struct {
+	Lock    *lfsLock `json:"lock"`
+	Message string   `json:"message,omitempty"`
+}
+
+type lfsLockListResponse struct {
+	Locks      []*lfsLock `json:"locks"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+// lfsLocksPageSize is the default and max number of locks per page.
+const lfsLocksPageSize = 100
+
+// listLFSLocks returns a page of locks after the cursor, and the cursor of next page
+// if there are more locks.
+func listLFSLocks(c *LFSContext, opts *db.LFSLocksOptions, cursor string, limit int) ([]*db.LFSLock, string, bool) {
+	if cursor != "" {
+		afterID, err := strconv.ParseInt(cursor, 10, 64)
+		if err != nil {
+			lfsError(c, http.StatusUnprocessableEntity, "Invalid cursor")
+			return nil, "", false
+		}
+		opts.AfterID = afterID
+	}
+	if limit <= 0 || limit > lfsLocksPageSize {
+		limit = lfsLocksPageSize
+	}
+	// Fetch one more lock to tell if there is a next page.
+	opts.PageSize = limit + 1
+
+	locks, err := db.GetLFSLocks(opts)
+	if err != nil {
+		lfsServerError(c, "GetLFSLocks", err)
+		return nil, "", false
+	}
+
+	var next string
+	if len(locks) > limit {
+		locks = locks[:limit]
+		next = strconv.FormatInt(locks[limit-1].ID, 10)
+	}
+	return locks, next, true
+}
+
+func LFSListLocks(c *LFSContext) {
+	if !c.requireAccess(db.ACCESS_MODE_READ) {
+		return
+	}
+
+	opts := &db.LFSLocksOptions{
+		RepoID: c.Repo.ID,
+		Path:   c.Query("path"),
+	}
+	if id := c.QueryInt64("id"); id > 0 {
+		lock, err := db.GetLFSLockByID(c.Repo.ID, id)
+		if err != nil {
+			if errors.IsLFSLockNotExist(err) {
+				lfsJSON(c, http.StatusOK, &lfsLockListResponse{Locks: []*lfsLock{}})
+			} else {
+				lfsServerError(c, "GetLFSLockByID", err)
+			}
+			return
+		}
+		lfsJSON(c, http.StatusOK, &lfsLockListResponse{Locks: []*lfsLock{toLFSLock(lock)}})
+		return
+	}
+
+	locks, next, ok := listLFSLocks(c, opts, c.Query("cursor"), c.QueryInt("limit"))
+	if !ok {
+		return
+	}
+	apiLocks := make([]*lfsLock, len(locks))
+	for i := range locks {
+		apiLocks[i] = toLFSLock(locks[i])
+	}
+	lfsJSON(c, http.StatusOK, &lfsLockListResponse{
+		Locks:      apiLocks,
+		NextCursor: next,
+	})
+}
+
+type lfsCreateLockRequest struct {
+	Path string `json:"path"`
+}
+
+func LFSCreateLock(c *LFSContext) {
+	if !c.requireAccess(db.ACCESS_MODE_WRITE) || !c.requireUser() {
+		return
+	}
+
+	var req lfsCreateLockRequest
+	if !lfsDecode(c, &req) {
+		return
+	} else if strings.Trim(req.Path, "/\\") == "" {
+		lfsError(c, http.StatusUnprocessableEntity, "Path is required")
+		return
+	}
+
+	lock, err := db.CreateLFSLock(c.Repo.ID, c.AuthUser, req.Path)
+	if err != nil {
+		if errors.IsLFSLockAlreadyExist(err) {
+			lfsJSON(c, http.StatusConflict, &lfsLockResponse{
+				Lock:    toLFSLock(lock),
+				Message: "Path is already locked",
+			})
+		} else {
+			lfsServerError(c, "CreateLFSLock", err)
+		}
+		return
+	}
+	lfsJSON(c, http.StatusCreated, &lfsLockResponse{Lock: toLFSLock(lock)})
+}
+
+type lfsVerifyLocksRequest struct {
+	Cursor string `json:"cursor"`
+	Limit  int    `json:"limit"`
+}
+
+type lfsVerifyLocksResponse struct {
+	Ours       []*lfsLock `json:"ours"`
+	Theirs     []*lfsLock `json:"theirs"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+// LFSVerifyLocks lists locks of the repository separated by whether they are owned
+// by current user, which is used by clients before pushing.
+func LFSVerifyLocks(c *LFSContext) {
+	if !c.requireAccess(db.ACCESS_MODE_WRITE) || !c.requireUser() {
+		return
+	}
+
+	var req lfsVerifyLocksRequest
+	if !lfsDecode(c, &req) {
+		return
+	}
+
+	locks, next, ok := listLFSLocks(c, &db.LFSLocksOptions{RepoID: c.Repo.ID}, req.Cursor, req.Limit)
+	if !ok {
+		return
+	}
+	resp := &lfsVerifyLocksResponse{
+		Ours:       []*lfsLock{},
+		Theirs:     []*lfsLock{},
+		NextCursor: next,
+	}
+	for _, l := range locks {
+		if l.OwnerID == c.AuthUser.ID {
+			resp.Ours = append(resp.Ours, toLFSLock(l))
+		} else {
+			resp.Theirs = append(resp.Theirs, toLFSLock(l))
+		}
+	}
+	lfsJSON(c, http.StatusOK, resp)
+}
+
+type lfsUnlockRequest struct {
+	Force bool `json:"force"`
+}
+
+// LFSUnlock deletes the lock by ":id" parameter. Locks held by others can only be
+// deleted with force by repository admins.
+func LFSUnlock(c *LFSContext) {
+	if !c.requireAccess(db.ACCESS_MODE_WRITE) || !c.requireUser() {
+		return
+	}
+
+	var req lfsUnlockRequest
+	if !lfsDecode(c, &req) {
+		return
+	}
+
+	lock, err := db.GetLFSLockByID(c.Repo.ID, c.ParamsInt64(":id"))
+	if err != nil {
+		if errors.IsLFSLockNotExist(err) {
+			lfsError(c, http.StatusNotFound, "Lock does not exist")
+		} else {
+			lfsServerError(c, "GetLFSLockByID", err)
+		}
+		return
+	}
+
+	if req.Force && c.AccessMode < db.ACCESS_MODE_ADMIN {
+		lfsError(c, http.StatusForbidden, "Only repository admins can force unlocking")
+		return
+	}
+	if err = db.DeleteLFSLock(lock, c.AuthUser, req.Force); err != nil {
+		if errors.IsLFSLockNotOwned(err) {
+			lfsError(c, http.StatusForbidden, "Lock is owned by another user")
+		} else {
+			lfsServerError(c, "DeleteLFSLock", err)
+		}
+		return
+	}
+	lfsJSON(c, http.StatusOK, &lfsLockResponse{Lock: toLFSLock(lock)})
+}
@@ -0,0 +1,143 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package repo
+
+import (
+	"net/url"
+
+	"github.com/unknwon/com"
+	"github.com/unknwon/paginater"
+	log "unknwon.dev/clog/v2"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/db/errors"
+)
+
+const (
+	PACKAGES     = "repo/packages/list"
+	PACKAGE_VIEW = "repo/packages/view"
+)
+
+// MustEnablePackages renders 404 if the package registry is disabled.
+func MustEnablePackages(c *context.Context) {
+	if !conf.Packages.Enabled {
+		c.NotFound()
+		return
+	}
+	c.Data["PageIsPackages"] = true
+}
+
+// registryHost returns the host of container registry that clients pull images from.
+func registryHost() string {
+	u, err := url.Parse(conf.Server.ExternalURL)
+	if err != nil {
+		log.Error("Failed to parse external URL %q: %v", conf.Server.ExternalURL, err)
+		return conf.Server.Domain
+	}
+	return u.Host
+}
+
+func Packages(c *context.Context) {
+	c.Data["Title"] = c.Tr("repo.packages")
+
+	total, err := db.CountPackages(c.Repo.Repository.ID)
+	if err != nil {
+		c.ServerError("CountPackages", err)
+		return
+	}
+
+	page := c.QueryInt("page")
+	if page <= 0 {
+		page = 1
+	}
+	pager := paginater.New(int(total), db.ItemsPerPage, page, 5)
+	c.Data["Page"] = pager
+
+	packages, err := db.GetPackages(c.Repo.Repository.ID, pager.Current(), db.ItemsPerPage)
+	if err != nil {
+		c.ServerError("GetPackages", err)
+		return
+	}
+	c.Data["Packages"] = packages
+	c.Data["GenericPackageURL"] = conf.Server.ExternalURL + "api/packages/" + c.Repo.Repository.FullName() + "/generic"
+	c.Data["RegistryImage"] = registryHost() + "/" + c.Repo.Repository.FullName()
+	c.Success(PACKAGES)
+}
+
+func getPackage(c *context.Context) *db.Package {
+	p, err := db.GetPackageByID(c.Repo.Repository.ID, c.ParamsInt64(":id"))
+	if err != nil {
+		c.NotFoundOrServerError("GetPackageByID", errors.IsPackageNotExist, err)
+		return nil
+	}
+	return p
+}
+
+func ViewPackage(c *context.Context) {
+	p := getPackage(c)
+	if c.Written() {
+		return
+	}
+
+	versions, err := db.GetPackageVersions(p.ID)
+	if err != nil {
+		c.ServerError("GetPackageVersions", err)
+		return
+	}
+
+	c.Data["Title"] = p.Name + " · " + c.Tr("repo.packages")
+	c.Data["Package"] = p
+	c.Data["Versions"] = versions
+	if len(versions) > 0 {
+		c.Data["LatestVersion"] = versions[0].Version
+	}
+	c.Data["GenericPackageURL"] = conf.Server.ExternalURL + "api/packages/" + c.Repo.Repository.FullName() + "/generic/" + url.PathEscape(p.Name)
+	image := registryHost() + "/" + c.Repo.Repository.FullName()
+	if p.Name != c.Repo.Repository.LowerName {
+		image += "/" + p.Name
+	}
+	c.Data["RegistryImage"] = image
+	c.Success(PACKAGE_VIEW)
+}
+
+// DeletePackage deletes a version of the package by the form value "id", or the whole package
+// if it is not given.
+func DeletePackage(c *context.Context) {
+	p := getPackage(c)
+	if c.Written() {
+		return
+	}
+
+	redirectTo := c.Repo.RepoLink + "/packages"
+	if versionID := c.QueryInt64("id"); versionID > 0 {
+		v, err := db.GetPackageVersionByID(p.ID, versionID)
+		if err != nil {
+			c.NotFoundOrServerError("GetPackageVersionByID", errors.IsPackageVersionNotExist, err)
+			return
+		}
+		if err = db.DeletePackageVersion(p, v); err != nil {
+			c.ServerError("DeletePackageVersion", err)
+			return
+		}
+		log.Trace("Package version deleted [repo_id: %d, package_id: %d]: %s", c.Repo.Repository.ID, p.ID, v.Version)
+		c.Flash.Success(c.Tr("repo.packages.delete_version_success", v.Version))
+		if p.NumVersions > 0 {
+			redirectTo = c.Repo.RepoLink + "/packages/" + com.ToStr(p.ID)
+		}
+	} else {
+		if err := db.DeletePackage(p); err != nil {
+			c.ServerError("DeletePackage", err)
+			return
+		}
+		log.Trace("Package deleted [repo_id: %d]: %s", c.Repo.Repository.ID, p.Name)
+		c.Flash.Success(c.Tr("repo.packages.delete_success", p.Name))
+	}
+
+	c.JSON(200, map[string]interface{}{
+		"redirect": redirectTo,
+	})
+}
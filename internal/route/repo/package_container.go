@@ -0,0 +1,549 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package repo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	gouuid "github.com/satori/go.uuid"
+	log "unknwon.dev/clog/v2"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/db/errors"
+)
+
+// The container registry implements the Docker Registry HTTP API V2, which is
+// documented at https://docs.docker.com/registry/spec/api/. Images are named as
+// "<owner>/<repo>" or "<owner>/<repo>/<image>".
+
+const (
+	containerManifestFileName = "manifest.json"
+	// containerMaxManifestSize is the max size of manifests in bytes.
+	containerMaxManifestSize = 4 << 20
+)
+
+type containerError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type containerErrorResponse struct {
+	Errors []*containerError `json:"errors"`
+}
+
+func containerErrorJSON(c *context.Context, status int, code, msg string) {
+	c.JSON(status, &containerErrorResponse{
+		Errors: []*containerError{{Code: code, Message: msg}},
+	})
+}
+
+// ContainerContext is the context of a request to the container registry of an image.
+type ContainerContext struct {
+	*PackageContext
+	// Image is the name of image as used by clients, e.g. "owner/repo/image".
+	Image string
+	// PackageName is the name of package of the image in the repository.
+	PackageName string
+}
+
+// containerImageURL returns the URL path to given resource of the image.
+func (c *ContainerContext) containerImageURL(resource string) string {
+	return "/v2/" + c.Image + "/" + resource
+}
+
+// parseContainerDigest returns the SHA256 digest in hex from the digest like
+// "sha256:<hex>", or an empty string if it is invalid.
+func parseContainerDigest(digest string) string {
+	sha := strings.TrimPrefix(digest, "sha256:")
+	if sha == digest || !db.IsValidSHA256(sha) {
+		return ""
+	}
+	return sha
+}
+
+// ContainerRegistry dispatches requests of the container registry by the path. It is
+// not possible to use routes because names of images may contain slashes.
+func ContainerRegistry(c *context.Context) {
+	if !conf.Packages.Enabled {
+		c.NotFound()
+		return
+	}
+	c.Resp.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+
+	p := c.Params("*")
+	if strings.Trim(p, "/") == "" {
+		containerCheckVersion(c)
+		return
+	}
+
+	var image, resource, arg string
+	if i := strings.LastIndex(p, "/blobs/uploads"); i > 0 {
+		image, resource, arg = p[:i], "uploads", strings.Trim(p[i+len("/blobs/uploads"):], "/")
+	} else if i = strings.LastIndex(p, "/manifests/"); i > 0 {
+		image, resource, arg = p[:i], "manifests", p[i+len("/manifests/"):]
+	} else if i = strings.LastIndex(p, "/blobs/"); i > 0 {
+		image, resource, arg = p[:i], "blobs", p[i+len("/blobs/"):]
+	} else if strings.HasSuffix(p, "/tags/list") {
+		image, resource = strings.TrimSuffix(p, "/tags/list"), "tags"
+	} else {
+		containerErrorJSON(c, http.StatusNotFound, "UNSUPPORTED", "The operation is unsupported")
+		return
+	}
+
+	fields := strings.SplitN(image, "/", 3)
+	if len(fields) < 2 {
+		containerErrorJSON(c, http.StatusNotFound, "NAME_INVALID", "Image name must start with the owner and the repository")
+		return
+	}
+	pc := newPackageContext(c, fields[0], fields[1])
+	if c.Written() {
+		return
+	}
+	cc := &ContainerContext{
+		PackageContext: pc,
+		Image:          image,
+		PackageName:    strings.ToLower(pc.Repo.Name),
+	}
+	if len(fields) == 3 {
+		cc.PackageName = strings.ToLower(fields[2])
+	}
+
+	switch resource {
+	case "uploads":
+		containerUploads(cc, arg)
+	case "manifests":
+		containerManifests(cc, arg)
+	case "blobs":
+		containerBlobs(cc, arg)
+	case "tags":
+		containerTags(cc)
+	}
+}
+
+// containerCheckVersion responses whether the registry is available to the client,
+// credentials are always asked so that clients are able to log in.
+func containerCheckVersion(c *context.Context) {
+	authHead := c.Req.Header.Get("Authorization")
+	if authHead == "" {
+		askCredentials(c, http.StatusUnauthorized, "")
+		return
+	}
+	if authenticatePackageUser(c, authHead) == nil {
+		return
+	}
+	c.JSON(http.StatusOK, map[string]string{})
+}
+
+func containerTags(c *ContainerContext) {
+	if !c.requireAccess(db.ACCESS_MODE_READ) {
+		return
+	}
+	if c.Req.Method != http.MethodGet {
+		containerErrorJSON(c.Context, http.StatusMethodNotAllowed, "UNSUPPORTED", "The operation is unsupported")
+		return
+	}
+
+	p, err := db.GetPackageByName(c.Repo.ID, db.PACKAGE_TYPE_CONTAINER, c.PackageName)
+	if err != nil {
+		if errors.IsPackageNotExist(err) {
+			containerErrorJSON(c.Context, http.StatusNotFound, "NAME_UNKNOWN", "Image does not exist")
+		} else {
+			c.Handle(http.StatusInternalServerError, "GetPackageByName", err)
+		}
+		return
+	}
+	versions, err := db.GetPackageVersions(p.ID)
+	if err != nil {
+		c.Handle(http.StatusInternalServerError, "GetPackageVersions", err)
+		return
+	}
+
+	// Manifests pushed by digests are not tags.
+	tags := make([]string, 0, len(versions))
+	for _, v := range versions {
+		if parseContainerDigest(v.Version) == "" {
+			tags = append(tags, v.Version)
+		}
+	}
+	sort.Strings(tags)
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"name": c.Image,
+		"tags": tags,
+	})
+}
+
+func containerBlobs(c *ContainerContext, digest string) {
+	if !c.requireAccess(db.ACCESS_MODE_READ) {
+		return
+	}
+	if c.Req.Method != http.MethodGet && c.Req.Method != http.MethodHead {
+		containerErrorJSON(c.Context, http.StatusMethodNotAllowed, "UNSUPPORTED", "The operation is unsupported")
+		return
+	}
+
+	sha := parseContainerDigest(digest)
+	if sha == "" {
+		containerErrorJSON(c.Context, http.StatusBadRequest, "DIGEST_INVALID", "Invalid digest")
+		return
+	}
+	blob, err := db.GetPackageBlob(c.Repo.ID, sha)
+	if err != nil {
+		if errors.IsPackageBlobNotExist(err) {
+			containerErrorJSON(c.Context, http.StatusNotFound, "BLOB_UNKNOWN", "Blob does not exist")
+		} else {
+			c.Handle(http.StatusInternalServerError, "GetPackageBlob", err)
+		}
+		return
+	}
+
+	c.Resp.Header().Set("Docker-Content-Digest", digest)
+	sendPackageBlob(c.Context, blob, "application/octet-stream")
+}
+
+// containerUploadPath returns the path of the upload in progress in local file system.
+func containerUploadPath(repoID int64, uuid string) string {
+	return filepath.Join(conf.Packages.TempPath, "uploads", strconv.FormatInt(repoID, 10), uuid)
+}
+
+// containerBlobCreated saves content read from r as the blob of given digest, and
+// responses the location of the blob.
+func containerBlobCreated(c *ContainerContext, r io.Reader, digest string) {
+	sha := parseContainerDigest(digest)
+	if sha == "" {
+		containerErrorJSON(c.Context, http.StatusBadRequest, "DIGEST_INVALID", "Invalid digest")
+		return
+	}
+
+	_, err := db.CreatePackageBlob(c.Repo.ID, r, sha)
+	if err != nil {
+		switch {
+		case errors.IsPackageBlobMismatch(err):
+			containerErrorJSON(c.Context, http.StatusBadRequest, "DIGEST_INVALID", "Content does not match the digest")
+		case errors.IsPackageBlobTooLarge(err):
+			containerErrorJSON(c.Context, http.StatusRequestEntityTooLarge, "SIZE_INVALID", fmt.Sprintf("Blob exceeds the max size of %d MB", conf.Packages.MaxFileSize))
+		default:
+			c.Handle(http.StatusInternalServerError, "CreatePackageBlob", err)
+		}
+		return
+	}
+
+	c.Resp.Header().Set("Location", c.containerImageURL("blobs/"+digest))
+	c.Resp.Header().Set("Docker-Content-Digest", digest)
+	c.Status(http.StatusCreated)
+}
+
+// containerUploadAccepted responses the status of the upload in progress.
+func containerUploadAccepted(c *ContainerContext, uuid string, size int64, status int) {
+	c.Resp.Header().Set("Location", c.containerImageURL("blobs/uploads/"+uuid))
+	c.Resp.Header().Set("Docker-Upload-UUID", uuid)
+	// The range is inclusive, and "0-0" means nothing has been uploaded.
+	end := size - 1
+	if end < 0 {
+		end = 0
+	}
+	c.Resp.Header().Set("Range", fmt.Sprintf("0-%d", end))
+	c.Resp.Header().Set("Content-Length", "0")
+	c.Status(status)
+}
+
+// containerUploads handles uploads of blobs, which are either monolithic with the
+// digest given at start, or chunked until the digest is given at the end.
+func containerUploads(c *ContainerContext, uuid string) {
+	if !c.requireAccess(db.ACCESS_MODE_WRITE) {
+		return
+	}
+
+	if uuid == "" {
+		if c.Req.Method != http.MethodPost {
+			containerErrorJSON(c.Context, http.StatusMethodNotAllowed, "UNSUPPORTED", "The operation is unsupported")
+			return
+		}
+
+		// Blobs mounted from other images are only available in the same repository.
+		if mount := c.Query("mount"); mount != "" {
+			if sha := parseContainerDigest(mount); sha != "" {
+				if _, err := db.GetPackageBlob(c.Repo.ID, sha); err == nil {
+					c.Resp.Header().Set("Location", c.containerImageURL("blobs/"+mount))
+					c.Resp.Header().Set("Docker-Content-Digest", mount)
+					c.Status(http.StatusCreated)
+					return
+				} else if !errors.IsPackageBlobNotExist(err) {
+					c.Handle(http.StatusInternalServerError, "GetPackageBlob", err)
+					return
+				}
+			}
+		}
+
+		if digest := c.Query("digest"); digest != "" {
+			containerBlobCreated(c, c.Req.Request.Body, digest)
+			return
+		}
+
+		uuid = gouuid.NewV4().String()
+		fpath := containerUploadPath(c.Repo.ID, uuid)
+		if err := os.MkdirAll(filepath.Dir(fpath), os.ModePerm); err != nil {
+			c.Handle(http.StatusInternalServerError, "MkdirAll", err)
+			return
+		} else if err = ioutil.WriteFile(fpath, nil, 0644); err != nil {
+			c.Handle(http.StatusInternalServerError, "WriteFile", err)
+			return
+		}
+		containerUploadAccepted(c, uuid, 0, http.StatusAccepted)
+		return
+	}
+
+	// The UUID is used in the path of the upload, therefore it must be validated.
+	if _, err := gouuid.FromString(uuid); err != nil {
+		containerErrorJSON(c.Context, http.StatusNotFound, "BLOB_UPLOAD_UNKNOWN", "Upload does not exist")
+		return
+	}
+	fpath := containerUploadPath(c.Repo.ID, uuid)
+	fi, err := os.Stat(fpath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			containerErrorJSON(c.Context, http.StatusNotFound, "BLOB_UPLOAD_UNKNOWN", "Upload does not exist")
+		} else {
+			c.Handle(http.StatusInternalServerError, "Stat", err)
+		}
+		return
+	}
+
+	switch c.Req.Method {
+	case http.MethodGet:
+		containerUploadAccepted(c, uuid, fi.Size(), http.StatusNoContent)
+
+	case http.MethodDelete:
+		if err = os.Remove(fpath); err != nil {
+			c.Handle(http.StatusInternalServerError, "Remove", err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+
+	case http.MethodPatch, http.MethodPut:
+		f, err := os.OpenFile(fpath, os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			c.Handle(http.StatusInternalServerError, "OpenFile", err)
+			return
+		}
+		n, err := io.Copy(f, c.Req.Request.Body)
+		f.Close()
+		if err != nil {
+			c.Handle(http.StatusInternalServerError, "Copy", err)
+			return
+		}
+
+		if c.Req.Method == http.MethodPatch {
+			containerUploadAccepted(c, uuid, fi.Size()+n, http.StatusAccepted)
+			return
+		}
+
+		f, err = os.Open(fpath)
+		if err != nil {
+			c.Handle(http.StatusInternalServerError, "Open", err)
+			return
+		}
+		containerBlobCreated(c, f, c.Query("digest"))
+		f.Close()
+		if err = os.Remove(fpath); err != nil {
+			log.Error("Failed to remove container upload %q: %v", fpath, err)
+		}
+
+	default:
+		containerErrorJSON(c.Context, http.StatusMethodNotAllowed, "UNSUPPORTED", "The operation is unsupported")
+	}
+}
+
+// containerManifest contains fields of image manifests that reference blobs.
+type containerManifest struct {
+	Config *struct {
+		Digest string `json:"digest"`
+	} `json:"config"`
+	Layers []struct {
+		Digest string `json:"digest"`
+	} `json:"layers"`
+}
+
+func containerManifests(c *ContainerContext, reference string) {
+	switch c.Req.Method {
+	case http.MethodGet, http.MethodHead:
+		containerGetManifest(c, reference)
+	case http.MethodPut:
+		containerPutManifest(c, reference)
+	case http.MethodDelete:
+		containerDeleteManifest(c, reference)
+	default:
+		containerErrorJSON(c.Context, http.StatusMethodNotAllowed, "UNSUPPORTED", "The operation is unsupported")
+	}
+}
+
+// getContainerManifestFile returns the package of the image and the manifest file of
+// the reference, which is either a tag or a digest.
+func getContainerManifestFile(c *ContainerContext, reference string) (*db.Package, *db.PackageFile) {
+	p, err := db.GetPackageByName(c.Repo.ID, db.PACKAGE_TYPE_CONTAINER, c.PackageName)
+	if err != nil {
+		if errors.IsPackageNotExist(err) {
+			containerErrorJSON(c.Context, http.StatusNotFound, "NAME_UNKNOWN", "Image does not exist")
+		} else {
+			c.Handle(http.StatusInternalServerError, "GetPackageByName", err)
+		}
+		return nil, nil
+	}
+
+	var f *db.PackageFile
+	if sha := parseContainerDigest(reference); sha != "" {
+		f, err = db.GetPackageFileBySHA256(p.ID, sha)
+	} else {
+		var v *db.PackageVersion
+		v, err = db.GetPackageVersion(p.ID, reference)
+		if err == nil {
+			f, err = db.GetPackageFile(v, containerManifestFileName)
+		}
+	}
+	if err != nil {
+		if errors.IsPackageVersionNotExist(err) || errors.IsPackageFileNotExist(err) {
+			containerErrorJSON(c.Context, http.StatusNotFound, "MANIFEST_UNKNOWN", "Manifest does not exist")
+		} else {
+			c.Handle(http.StatusInternalServerError, "get manifest file", err)
+		}
+		return nil, nil
+	}
+	return p, f
+}
+
+func containerGetManifest(c *ContainerContext, reference string) {
+	if !c.requireAccess(db.ACCESS_MODE_READ) {
+		return
+	}
+
+	_, f := getContainerManifestFile(c, reference)
+	if c.Written() {
+		return
+	}
+	blob, err := db.GetPackageBlob(c.Repo.ID, f.SHA256)
+	if err != nil {
+		c.Handle(http.StatusInternalServerError, "GetPackageBlob", err)
+		return
+	}
+
+	c.Resp.Header().Set("Docker-Content-Digest", "sha256:"+f.SHA256)
+	sendPackageBlob(c.Context, blob, f.ContentType)
+}
+
+func containerPutManifest(c *ContainerContext, reference string) {
+	if !c.requireAccess(db.ACCESS_MODE_WRITE) {
+		return
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(c.Req.Request.Body, containerMaxManifestSize+1))
+	if err != nil {
+		c.Handle(http.StatusInternalServerError, "ReadAll", err)
+		return
+	} else if len(data) > containerMaxManifestSize {
+		containerErrorJSON(c.Context, http.StatusRequestEntityTooLarge, "SIZE_INVALID", "Manifest is too large")
+		return
+	}
+
+	var manifest containerManifest
+	if err = json.Unmarshal(data, &manifest); err != nil {
+		containerErrorJSON(c.Context, http.StatusBadRequest, "MANIFEST_INVALID", fmt.Sprintf("Malformed manifest: %v", err))
+		return
+	}
+	digests := make([]string, 0, len(manifest.Layers)+1)
+	if manifest.Config != nil {
+		digests = append(digests, manifest.Config.Digest)
+	}
+	for _, l := range manifest.Layers {
+		digests = append(digests, l.Digest)
+	}
+	for _, digest := range digests {
+		if _, err = db.GetPackageBlob(c.Repo.ID, parseContainerDigest(digest)); err != nil {
+			if errors.IsPackageBlobNotExist(err) {
+				containerErrorJSON(c.Context, http.StatusBadRequest, "MANIFEST_BLOB_UNKNOWN", fmt.Sprintf("Blob %q does not exist", digest))
+			} else {
+				c.Handle(http.StatusInternalServerError, "GetPackageBlob", err)
+			}
+			return
+		}
+	}
+
+	blob, err := db.CreatePackageBlob(c.Repo.ID, bytes.NewReader(data), parseContainerDigest(reference))
+	if err != nil {
+		if errors.IsPackageBlobMismatch(err) {
+			containerErrorJSON(c.Context, http.StatusBadRequest, "DIGEST_INVALID", "Content does not match the digest")
+		} else {
+			c.Handle(http.StatusInternalServerError, "CreatePackageBlob", err)
+		}
+		return
+	}
+
+	_, err = db.AddPackageFile(c.AuthUser, c.Repo, blob, db.AddPackageFileOptions{
+		Type:        db.PACKAGE_TYPE_CONTAINER,
+		Name:        c.PackageName,
+		Version:     reference,
+		FileName:    containerManifestFileName,
+		ContentType: c.Req.Header.Get("Content-Type"),
+		Overwrite:   true,
+	})
+	if err != nil {
+		c.Handle(http.StatusInternalServerError, "AddPackageFile", err)
+		return
+	}
+
+	digest := "sha256:" + blob.SHA256
+	c.Resp.Header().Set("Location", c.containerImageURL("manifests/"+digest))
+	c.Resp.Header().Set("Docker-Content-Digest", digest)
+	c.Status(http.StatusCreated)
+}
+
+func containerDeleteManifest(c *ContainerContext, reference string) {
+	if !c.requireAccess(db.ACCESS_MODE_WRITE) {
+		return
+	}
+
+	p, f := getContainerManifestFile(c, reference)
+	if c.Written() {
+		return
+	}
+
+	// Deleting by a tag deletes only the tag, deleting by a digest deletes all tags of
+	// the manifest.
+	versions, err := db.GetPackageVersions(p.ID)
+	if err != nil {
+		c.Handle(http.StatusInternalServerError, "GetPackageVersions", err)
+		return
+	}
+	isDigest := parseContainerDigest(reference) != ""
+	for _, v := range versions {
+		matched := v.ID == f.VersionID
+		if isDigest {
+			for _, vf := range v.Files {
+				if vf.SHA256 == f.SHA256 {
+					matched = true
+				}
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		if err = db.DeletePackageVersion(p, v); err != nil {
+			c.Handle(http.StatusInternalServerError, "DeletePackageVersion", err)
+			return
+		}
+	}
+	c.Status(http.StatusAccepted)
+}
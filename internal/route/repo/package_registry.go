@@ -0,0 +1,295 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package repo
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/macaron.v1"
+	log "unknwon.dev/clog/v2"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/db/errors"
+	"gogs.io/gogs/internal/storage"
+)
+
+type PackageContext struct {
+	*context.Context
+	Repo *db.Repository
+	// AuthUser is nil for anonymous requests.
+	AuthUser *db.User
+	// AccessMode is the access mode of the request to the repository.
+	AccessMode db.AccessMode
+}
+
+// newPackageContext resolves the repository and authenticates the request to the
+// package registry. Either HTTP Basic Authentication or a personal access token in
+// the "Authorization" header is accepted, requests without credentials are processed
+// as anonymous. It responses to the client and returns nil if failed.
+func newPackageContext(c *context.Context, ownerName, repoName string) *PackageContext {
+	owner, err := db.GetUserByName(ownerName)
+	if err != nil {
+		packageNotFoundOrServerError(c, "GetUserByName", errors.IsUserNotExist, err)
+		return nil
+	}
+	repo, err := db.GetRepositoryByName(owner.ID, repoName)
+	if err != nil {
+		packageNotFoundOrServerError(c, "GetRepositoryByName", errors.IsRepoNotExist, err)
+		return nil
+	}
+
+	pc := &PackageContext{
+		Context:    c,
+		Repo:       repo,
+		AccessMode: db.ACCESS_MODE_NONE,
+	}
+	if !repo.IsPrivate && !conf.Auth.RequireSigninView {
+		pc.AccessMode = db.ACCESS_MODE_READ
+	}
+
+	authHead := c.Req.Header.Get("Authorization")
+	if authHead == "" {
+		return pc
+	}
+	pc.AuthUser = authenticatePackageUser(c, authHead)
+	if c.Written() {
+		return nil
+	}
+
+	pc.AccessMode, err = db.UserAccessMode(pc.AuthUser.ID, repo)
+	if err != nil {
+		c.Handle(http.StatusInternalServerError, "UserAccessMode", err)
+		return nil
+	}
+	return pc
+}
+
+// authenticatePackageUser returns the user authenticated by the "Authorization" header,
+// which is either HTTP Basic Authentication or a personal access token with "token" or
+// "Bearer" scheme. It responses to the client and returns nil if failed.
+func authenticatePackageUser(c *context.Context, authHead string) *db.User {
+	fields := strings.Fields(authHead)
+	if len(fields) != 2 || (fields[0] != "token" && fields[0] != "Bearer") {
+		return httpBasicAuth(c, authHead)
+	}
+
+	token, err := db.GetAccessTokenBySHA(fields[1])
+	if err != nil {
+		if db.IsErrAccessTokenEmpty(err) || db.IsErrAccessTokenNotExist(err) {
+			askCredentials(c, http.StatusUnauthorized, "Invalid access token")
+		} else {
+			c.Handle(http.StatusInternalServerError, "GetAccessTokenBySHA", err)
+		}
+		return nil
+	}
+	token.Updated = time.Now()
+	if err = db.UpdateAccessToken(token); err != nil {
+		log.Error("UpdateAccessToken: %v", err)
+	}
+
+	u, err := db.GetUserByID(token.UID)
+	if err != nil {
+		c.Handle(http.StatusInternalServerError, "GetUserByID", err)
+		return nil
+	} else if u.IsDeactivated {
+		askCredentials(c, http.StatusForbidden, "User account is deactivated")
+		return nil
+	}
+	return u
+}
+
+// requireAccess responses to the client if the request does not have given access
+// mode to the repository.
+func (c *PackageContext) requireAccess(mode db.AccessMode) bool {
+	if c.AccessMode < mode {
+		if c.AuthUser == nil {
+			askCredentials(c.Context, http.StatusUnauthorized, "Credentials needed")
+		} else if c.AccessMode < db.ACCESS_MODE_READ {
+			c.HandleText(http.StatusNotFound, "Repository not found")
+		} else {
+			c.HandleText(http.StatusForbidden, "User permission denied")
+		}
+		return false
+	}
+
+	if mode >= db.ACCESS_MODE_WRITE {
+		switch {
+		case c.Repo.IsMirror:
+			c.HandleText(http.StatusForbidden, "Mirror repository is read-only")
+			return false
+		case c.Repo.IsArchived:
+			c.HandleText(http.StatusForbidden, "Archived repository is read-only")
+			return false
+		case c.AuthUser != nil && !c.AuthUser.HasAcceptedTerms():
+			c.HandleText(http.StatusForbidden, "You must accept the terms before publishing packages: "+conf.Server.ExternalURL+"user/terms")
+			return false
+		}
+	}
+	return true
+}
+
+// packageNotFoundOrServerError responses in plain text that the resource is not found
+// if errck returns true for the error, or responses the server error otherwise.
+func packageNotFoundOrServerError(c *context.Context, title string, errck func(error) bool, err error) {
+	if errck(err) {
+		c.HandleText(http.StatusNotFound, "Not found")
+		return
+	}
+	c.Handle(http.StatusInternalServerError, title, err)
+}
+
+// sendPackageBlob responses the content of the blob, the body is omitted for HEAD
+// requests.
+func sendPackageBlob(c *context.Context, blob *db.PackageBlob, contentType string) {
+	c.Resp.Header().Set("Content-Type", contentType)
+	c.Resp.Header().Set("Content-Length", strconv.FormatInt(blob.Size, 10))
+	if c.Req.Method == http.MethodHead {
+		c.Resp.WriteHeader(http.StatusOK)
+		return
+	}
+
+	r, err := blob.Open()
+	if err != nil {
+		if err == storage.ErrNotExist {
+			c.HandleText(http.StatusNotFound, "Blob does not exist")
+		} else {
+			c.Handle(http.StatusInternalServerError, "Open", err)
+		}
+		return
+	}
+	defer r.Close()
+
+	c.Resp.WriteHeader(http.StatusOK)
+	if _, err = io.Copy(c.Resp, r); err != nil {
+		log.Error("Failed to send package blob [sha256: %s]: %v", blob.SHA256, err)
+	}
+}
+
+// GenericPackageContexter resolves the repository and authenticates the request of
+// generic packages.
+func GenericPackageContexter() macaron.Handler {
+	return func(c *context.Context) {
+		if !conf.Packages.Enabled {
+			c.NotFound()
+			return
+		}
+
+		pc := newPackageContext(c, c.Params(":username"), c.Params(":reponame"))
+		if c.Written() {
+			return
+		}
+		c.Map(pc)
+	}
+}
+
+// isValidGenericPackageParam returns true if given name, version or file name of a
+// generic package is allowed.
+func isValidGenericPackageParam(s string) bool {
+	return s != "" && s != "." && s != ".." && len(s) <= 255 && !strings.ContainsAny(s, "/\\")
+}
+
+// getGenericPackageVersion returns the package and the version by parameters.
+func getGenericPackageVersion(c *PackageContext) (*db.Package, *db.PackageVersion) {
+	p, err := db.GetPackageByName(c.Repo.ID, db.PACKAGE_TYPE_GENERIC, c.Params(":name"))
+	if err != nil {
+		packageNotFoundOrServerError(c.Context, "GetPackageByName", errors.IsPackageNotExist, err)
+		return nil, nil
+	}
+	v, err := db.GetPackageVersion(p.ID, c.Params(":version"))
+	if err != nil {
+		packageNotFoundOrServerError(c.Context, "GetPackageVersion", errors.IsPackageVersionNotExist, err)
+		return nil, nil
+	}
+	return p, v
+}
+
+// DownloadGenericPackageFile responses the content of file of a generic package.
+func DownloadGenericPackageFile(c *PackageContext) {
+	if !c.requireAccess(db.ACCESS_MODE_READ) {
+		return
+	}
+
+	_, v := getGenericPackageVersion(c)
+	if c.Written() {
+		return
+	}
+	f, err := db.GetPackageFile(v, c.Params(":filename"))
+	if err != nil {
+		packageNotFoundOrServerError(c.Context, "GetPackageFile", errors.IsPackageFileNotExist, err)
+		return
+	}
+	blob, err := db.GetPackageBlob(c.Repo.ID, f.SHA256)
+	if err != nil {
+		packageNotFoundOrServerError(c.Context, "GetPackageBlob", errors.IsPackageBlobNotExist, err)
+		return
+	}
+
+	c.Resp.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, f.Name))
+	sendPackageBlob(c.Context, blob, "application/octet-stream")
+}
+
+// UploadGenericPackageFile saves the request body as a file of a generic package, the
+// package and the version are created if they do not exist.
+func UploadGenericPackageFile(c *PackageContext) {
+	if !c.requireAccess(db.ACCESS_MODE_WRITE) {
+		return
+	}
+
+	name, version, filename := c.Params(":name"), c.Params(":version"), c.Params(":filename")
+	if !isValidGenericPackageParam(name) || !isValidGenericPackageParam(version) || !isValidGenericPackageParam(filename) {
+		c.HandleText(http.StatusBadRequest, "Invalid package name, version or file name")
+		return
+	}
+
+	blob, err := db.CreatePackageBlob(c.Repo.ID, c.Req.Request.Body, "")
+	if err != nil {
+		if errors.IsPackageBlobTooLarge(err) {
+			c.HandleText(http.StatusRequestEntityTooLarge, fmt.Sprintf("File exceeds the max size of %d MB", conf.Packages.MaxFileSize))
+		} else {
+			c.Handle(http.StatusInternalServerError, "CreatePackageBlob", err)
+		}
+		return
+	}
+
+	_, err = db.AddPackageFile(c.AuthUser, c.Repo, blob, db.AddPackageFileOptions{
+		Type:     db.PACKAGE_TYPE_GENERIC,
+		Name:     name,
+		Version:  version,
+		FileName: filename,
+	})
+	if err != nil {
+		if errors.IsPackageFileAlreadyExist(err) {
+			c.HandleText(http.StatusConflict, "File already exists")
+		} else {
+			c.Handle(http.StatusInternalServerError, "AddPackageFile", err)
+		}
+		return
+	}
+	c.Status(http.StatusCreated)
+}
+
+// DeleteGenericPackageVersion deletes a version of a generic package with all its files.
+func DeleteGenericPackageVersion(c *PackageContext) {
+	if !c.requireAccess(db.ACCESS_MODE_WRITE) {
+		return
+	}
+
+	p, v := getGenericPackageVersion(c)
+	if c.Written() {
+		return
+	}
+	if err := db.DeletePackageVersion(p, v); err != nil {
+		c.Handle(http.StatusInternalServerError, "DeletePackageVersion", err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -6,6 +6,7 @@ package repo
 
 import (
 	"container/list"
+	"fmt"
 	"path"
 	"strings"
 
@@ -23,10 +24,12 @@ import (
 )
 
 const (
-	FORK         = "repo/pulls/fork"
-	COMPARE_PULL = "repo/pulls/compare"
-	PULL_COMMITS = "repo/pulls/commits"
-	PULL_FILES   = "repo/pulls/files"
+	FORK           = "repo/pulls/fork"
+	COMPARE_PULL   = "repo/pulls/compare"
+	PULL_COMMITS   = "repo/pulls/commits"
+	PULL_FILES     = "repo/pulls/files"
+	PULL_CHECKS    = "repo/pulls/checks"
+	PULL_CONFLICTS = "repo/pulls/conflicts"
 
 	PULL_REQUEST_TEMPLATE_KEY       = "PullRequestTemplate"
 	PULL_REQUEST_TITLE_TEMPLATE_KEY = "PullRequestTitleTemplate"
@@ -176,10 +179,19 @@ func checkPullInfo(c *context.Context) *db.Issue {
 	return issue
 }
 
+// pullHeadTarget returns the name of the head of the pull request to be displayed, which
+// is the topic of the pusher for pull requests of AGit flow.
+func pullHeadTarget(pull *db.PullRequest) string {
+	if pull.IsAgit() {
+		return pull.HeadBranch
+	}
+	return pull.HeadUserName + "/" + pull.HeadBranch
+}
+
 func PrepareMergedViewPullInfo(c *context.Context, issue *db.Issue) {
 	pull := issue.PullRequest
 	c.Data["HasMerged"] = true
-	c.Data["HeadTarget"] = issue.PullRequest.HeadUserName + "/" + pull.HeadBranch
+	c.Data["HeadTarget"] = pullHeadTarget(pull)
 	c.Data["BaseTarget"] = c.Repo.Owner.Name + "/" + pull.BaseBranch
 
 	var err error
@@ -193,13 +205,23 @@ func PrepareMergedViewPullInfo(c *context.Context, issue *db.Issue) {
 		c.ServerError("Repo.GitRepo.FilesCountBetween", err)
 		return
 	}
+
+	// Pull requests merged before the head reference was kept have no checks.
+	headCommitID, err := git.GetFullCommitID(c.Repo.GitRepo.Path, pull.HeadRefName())
+	if err != nil {
+		if !git.IsErrNotExist(err) {
+			c.ServerError("GetFullCommitID", err)
+		}
+		return
+	}
+	preparePullChecks(c, pull, headCommitID)
 }
 
 func PrepareViewPullInfo(c *context.Context, issue *db.Issue) *git.PullRequestInfo {
 	repo := c.Repo.Repository
 	pull := issue.PullRequest
 
-	c.Data["HeadTarget"] = pull.HeadUserName + "/" + pull.HeadBranch
+	c.Data["HeadTarget"] = pullHeadTarget(pull)
 	c.Data["BaseTarget"] = c.Repo.Owner.Name + "/" + pull.BaseBranch
 
 	var (
@@ -215,7 +237,7 @@ func PrepareViewPullInfo(c *context.Context, issue *db.Issue) *git.PullRequestIn
 		}
 	}
 
-	if pull.HeadRepo == nil || !headGitRepo.IsBranchExist(pull.HeadBranch) {
+	if pull.HeadRepo == nil || !pull.IsHeadExist(headGitRepo) {
 		c.Data["IsPullReuqestBroken"] = true
 		c.Data["HeadTarget"] = "deleted"
 		c.Data["NumCommits"] = 0
@@ -224,7 +246,7 @@ func PrepareViewPullInfo(c *context.Context, issue *db.Issue) *git.PullRequestIn
 	}
 
 	prInfo, err := headGitRepo.GetPullRequestInfo(db.RepoPath(repo.Owner.Name, repo.Name),
-		pull.BaseBranch, pull.HeadBranch)
+		pull.BaseBranch, pull.HeadRevision())
 	if err != nil {
 		if strings.Contains(err.Error(), "fatal: Not a valid object name") {
 			c.Data["IsPullReuqestBroken"] = true
@@ -239,9 +261,53 @@ func PrepareViewPullInfo(c *context.Context, issue *db.Issue) *git.PullRequestIn
 	}
 	c.Data["NumCommits"] = prInfo.Commits.Len()
 	c.Data["NumFiles"] = prInfo.NumFiles
+
+	headCommitID, err := pull.HeadCommitID(headGitRepo)
+	if err != nil {
+		c.ServerError("HeadCommitID", err)
+		return nil
+	}
+	preparePullChecks(c, pull, headCommitID)
+	if c.Written() {
+		return nil
+	}
+
+	if pull.Status == db.PULL_REQUEST_STATUS_CONFLICT {
+		c.Data["CanResolveConflicts"] = canResolveConflicts(c, pull)
+	}
 	return prInfo
 }
 
+// preparePullChecks sets checks of the head commit of the pull request, which are
+// reported to either the base or the head repository.
+func preparePullChecks(c *context.Context, pull *db.PullRequest, headCommitID string) {
+	repoIDs := []int64{pull.BaseRepoID}
+	if pull.HeadRepoID != pull.BaseRepoID {
+		repoIDs = append(repoIDs, pull.HeadRepoID)
+	}
+	checks, err := db.GetCommitChecks(repoIDs, headCommitID)
+	if err != nil {
+		c.ServerError("GetCommitChecks", err)
+		return
+	}
+	c.Data["HeadCommitID"] = headCommitID
+	c.Data["Checks"] = checks
+	c.Data["ChecksState"] = db.CombineCommitChecks(checks)
+}
+
+// canResolveConflicts returns true if current user is able to push to the head branch of the pull request.
+func canResolveConflicts(c *context.Context, pull *db.PullRequest) bool {
+	if !c.IsLogged || pull.HeadRepo == nil || pull.IsAgit() {
+		return false
+	}
+	has, err := db.HasUnitAccess(c.User.ID, pull.HeadRepo, db.REPO_UNIT_CODE, db.ACCESS_MODE_WRITE)
+	if err != nil {
+		log.Error("HasUnitAccess [user_id: %d, repo_id: %d]: %v", c.User.ID, pull.HeadRepo.ID, err)
+		return false
+	}
+	return has
+}
+
 func ViewPullCommits(c *context.Context) {
 	c.Data["PageIsPullList"] = true
 	c.Data["PageIsPullCommits"] = true
@@ -297,6 +363,27 @@ func ViewPullCommits(c *context.Context) {
 	c.Success(PULL_COMMITS)
 }
 
+func ViewPullChecks(c *context.Context) {
+	c.Data["PageIsPullList"] = true
+	c.Data["PageIsPullChecks"] = true
+
+	issue := checkPullInfo(c)
+	if c.Written() {
+		return
+	}
+
+	if issue.PullRequest.HasMerged {
+		PrepareMergedViewPullInfo(c, issue)
+	} else {
+		PrepareViewPullInfo(c, issue)
+	}
+	if c.Written() {
+		return
+	}
+
+	c.Success(PULL_CHECKS)
+}
+
 func ViewPullFiles(c *context.Context) {
 	c.Data["PageIsPullList"] = true
 	c.Data["PageIsPullFiles"] = true
@@ -341,9 +428,9 @@ func ViewPullFiles(c *context.Context) {
 			return
 		}
 
-		headCommitID, err := headGitRepo.GetBranchCommitID(pull.HeadBranch)
+		headCommitID, err := pull.HeadCommitID(headGitRepo)
 		if err != nil {
-			c.ServerError("GetBranchCommitID", err)
+			c.ServerError("HeadCommitID", err)
 			return
 		}
 
@@ -355,7 +442,7 @@ func ViewPullFiles(c *context.Context) {
 
 	diff, err := db.GetDiffRange(diffRepoPath,
 		startCommitID, endCommitID, conf.Git.MaxGitDiffLines,
-		conf.Git.MaxGitDiffLineCharacters, conf.Git.MaxGitDiffFiles)
+		conf.Git.MaxGitDiffLineCharacters, conf.Git.MaxGitDiffFiles, c.QueryStrings("expand")...)
 	if err != nil {
 		c.ServerError("GetDiffRange", err)
 		return
@@ -386,6 +473,7 @@ func ViewPullFiles(c *context.Context) {
 		c.Data["SourcePath"] = conf.Server.Subpath + "/" + path.Join(headTarget, "src", endCommitID)
 		c.Data["BeforeSourcePath"] = conf.Server.Subpath + "/" + path.Join(headTarget, "src", startCommitID)
 		c.Data["RawPath"] = conf.Server.Subpath + "/" + path.Join(headTarget, "raw", endCommitID)
+		setBeforeImageData(c, gitRepo, headTarget, startCommitID)
 	}
 
 	c.Data["RequireHighlightJS"] = true
@@ -413,6 +501,29 @@ func MergePullRequest(c *context.Context) {
 		return
 	}
 
+	if c.Repo.Repository.PullsRequireResolved {
+		numUnresolved, err := db.CountUnresolvedComments(issue.ID)
+		if err != nil {
+			c.ServerError("CountUnresolvedComments", err)
+			return
+		} else if numUnresolved > 0 {
+			c.Flash.Error(c.Tr("repo.pulls.unresolved_conversations", numUnresolved))
+			c.Redirect(c.Repo.RepoLink + "/pulls/" + com.ToStr(pr.Index))
+			return
+		}
+	}
+
+	// The head branch is checked before merging because it is the head of the pull
+	// request itself until merged.
+	deleteHeadBranch := false
+	if c.QueryBool("delete_head_branch") {
+		deleteHeadBranch, err = canDeletePullBranch(c, pr)
+		if err != nil {
+			c.ServerError("canDeletePullBranch", err)
+			return
+		}
+	}
+
 	pr.Issue = issue
 	pr.Issue.Repo = c.Repo.Repository
 	if err = pr.Merge(c.User, c.Repo.GitRepo, db.MergeStyle(c.Query("merge_style")), c.Query("commit_description")); err != nil {
@@ -421,9 +532,202 @@ func MergePullRequest(c *context.Context) {
 	}
 
 	log.Trace("Pull request merged: %d", pr.ID)
+
+	// The branch is not deleted if it has new commits since merged.
+	if deleteHeadBranch {
+		branchCommitID, err := c.Repo.GitRepo.GetBranchCommitID(pr.HeadBranch)
+		if err != nil {
+			log.Error("Failed to get commit ID of branch %q: %v", pr.HeadBranch, err)
+		} else if branchCommitID == pr.MergedCommitID {
+			if err = deleteBranch(c, pr.HeadBranch, branchCommitID); err != nil {
+				log.Error("Failed to delete branch %q: %v", pr.HeadBranch, err)
+			}
+		}
+	}
+
 	c.Redirect(c.Repo.RepoLink + "/pulls/" + com.ToStr(pr.Index))
 }
 
+// canDeletePullBranch returns true if current user is able to delete the head branch of
+// the pull request, which must be in the base repository, not be the default branch or
+// a protected branch, and not be the head of other open pull requests.
+func canDeletePullBranch(c *context.Context, pull *db.PullRequest) (bool, error) {
+	if pull.BaseRepoID != pull.HeadRepoID || pull.IsAgit() || !c.Repo.CanWrite(db.REPO_UNIT_CODE) ||
+		pull.HeadBranch == c.Repo.Repository.DefaultBranch ||
+		!c.Repo.GitRepo.IsBranchExist(pull.HeadBranch) {
+		return false, nil
+	}
+
+	protectBranch, err := db.GetProtectBranchOfRepoByName(pull.BaseRepoID, pull.HeadBranch)
+	if err != nil {
+		if !errors.IsErrBranchNotExist(err) {
+			return false, fmt.Errorf("GetProtectBranchOfRepoByName: %v", err)
+		}
+	} else if protectBranch.Protected {
+		return false, nil
+	}
+
+	prs, err := db.GetUnmergedPullRequestsByHeadInfo(pull.HeadRepoID, pull.HeadBranch)
+	if err != nil {
+		return false, fmt.Errorf("GetUnmergedPullRequestsByHeadInfo: %v", err)
+	}
+	for _, pr := range prs {
+		if pr.ID != pull.ID {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// RestorePullBranch restores the head branch of the merged pull request after it is
+// deleted, until the deleted branch is purged.
+func RestorePullBranch(c *context.Context) {
+	issue := checkPullInfo(c)
+	if c.Written() {
+		return
+	}
+	pull := issue.PullRequest
+	if !pull.HasMerged || pull.BaseRepoID != pull.HeadRepoID || pull.IsAgit() {
+		c.NotFound()
+		return
+	}
+
+	b, err := db.GetDeletedBranchByCommit(c.Repo.Repository.ID, pull.HeadBranch, pull.MergedCommitID)
+	if err != nil {
+		c.NotFoundOrServerError("GetDeletedBranchByCommit", errors.IsDeletedBranchNotExist, err)
+		return
+	}
+
+	if err = c.Repo.Repository.RestoreDeletedBranch(c.User, b); err != nil {
+		if errors.IsBranchAlreadyExists(err) {
+			c.Flash.Error(c.Tr("repo.settings.restore_branch_already_exists", b.Name))
+		} else {
+			c.ServerError("RestoreDeletedBranch", err)
+			return
+		}
+	} else {
+		log.Trace("Deleted branch restored [repo_id: %d, name: %s]: %s", c.Repo.Repository.ID, b.Name, c.User.Name)
+		c.Flash.Success(c.Tr("repo.settings.restore_branch_success", b.Name))
+	}
+	c.Redirect(c.Repo.RepoLink + "/pulls/" + com.ToStr(issue.Index))
+}
+
+// checkConflictedPull returns the pull request that has conflicts and is able to be resolved
+// by current user.
+func checkConflictedPull(c *context.Context) *db.PullRequest {
+	issue := checkPullInfo(c)
+	if c.Written() {
+		return nil
+	}
+	pull := issue.PullRequest
+	if issue.IsClosed || pull.HasMerged || pull.Status != db.PULL_REQUEST_STATUS_CONFLICT {
+		c.NotFound()
+		return nil
+	}
+	pull.Issue = issue
+
+	PrepareViewPullInfo(c, issue)
+	if c.Written() {
+		return nil
+	} else if !canResolveConflicts(c, pull) {
+		c.NotFound()
+		return nil
+	}
+
+	c.Data["PageIsPullList"] = true
+	c.Data["PageIsPullConflicts"] = true
+	return pull
+}
+
+func setConflictedFiles(c *context.Context, files []*db.ConflictedFile) {
+	c.Data["ConflictedFiles"] = files
+	for _, f := range files {
+		if !f.IsText {
+			c.Data["HasUnresolvableConflicts"] = true
+			break
+		}
+	}
+}
+
+func ResolveConflicts(c *context.Context) {
+	pull := checkConflictedPull(c)
+	if c.Written() {
+		return
+	}
+
+	files, err := pull.GetConflictedFiles()
+	if err != nil {
+		c.ServerError("GetConflictedFiles", err)
+		return
+	} else if len(files) == 0 {
+		// Status of the pull request is outdated, check it again.
+		pull.AddToTaskQueue()
+		c.Flash.Info(c.Tr("repo.pulls.no_conflicts"))
+		c.Redirect(c.Repo.RepoLink + "/pulls/" + com.ToStr(pull.Index))
+		return
+	}
+	setConflictedFiles(c, files)
+	c.Data["CommitMessage"] = fmt.Sprintf("Merge branch '%s' into %s", pull.BaseBranch, pull.HeadBranch)
+	c.Success(PULL_CONFLICTS)
+}
+
+func ResolveConflictsPost(c *context.Context) {
+	pull := checkConflictedPull(c)
+	if c.Written() {
+		return
+	}
+
+	names := c.QueryStrings("file_name")
+	contents := c.QueryStrings("file_content")
+	if len(names) != len(contents) {
+		c.Error(422)
+		return
+	}
+	resolved := make(map[string]string, len(names))
+	for i := range names {
+		resolved[names[i]] = contents[i]
+	}
+
+	message := strings.TrimSpace(c.Query("commit_message"))
+	if len(message) == 0 {
+		message = fmt.Sprintf("Merge branch '%s' into %s", pull.BaseBranch, pull.HeadBranch)
+	}
+
+	pullLink := c.Repo.RepoLink + "/pulls/" + com.ToStr(pull.Index)
+	if err := pull.ResolveConflicts(c.User, resolved, message); err != nil {
+		switch {
+		case errors.IsPullRequestNotConflicted(err):
+			pull.AddToTaskQueue()
+			c.Flash.Info(c.Tr("repo.pulls.no_conflicts"))
+			c.Redirect(pullLink)
+		case errors.IsConflictNotResolvable(err):
+			c.Flash.Error(c.Tr("repo.pulls.conflict_not_resolvable", err.(errors.ConflictNotResolvable).Name))
+			c.Redirect(pullLink + "/conflicts")
+		case errors.IsConflictNotResolved(err):
+			files, err2 := pull.GetConflictedFiles()
+			if err2 != nil {
+				c.ServerError("GetConflictedFiles", err2)
+				return
+			}
+			for _, f := range files {
+				if content, ok := resolved[f.Name]; ok {
+					f.Content = content
+				}
+			}
+			setConflictedFiles(c, files)
+			c.Data["CommitMessage"] = message
+			c.RenderWithErr(c.Tr("repo.pulls.conflict_not_resolved", err.(errors.ConflictNotResolved).Name), PULL_CONFLICTS, nil)
+		default:
+			c.ServerError("ResolveConflicts", err)
+		}
+		return
+	}
+
+	log.Trace("Conflicts of pull request resolved: %d", pull.ID)
+	c.Flash.Success(c.Tr("repo.pulls.conflicts_resolved"))
+	c.Redirect(pullLink)
+}
+
 func ParseCompareInfo(c *context.Context) (*db.User, *db.Repository, *git.Repository, *git.PullRequestInfo, string, string) {
 	baseRepo := c.Repo.Repository
 
@@ -571,7 +875,7 @@ func PrepareCompareDiff(
 
 	diff, err := db.GetDiffRange(db.RepoPath(headUser.Name, headRepo.Name),
 		prInfo.MergeBase, headCommitID, conf.Git.MaxGitDiffLines,
-		conf.Git.MaxGitDiffLineCharacters, conf.Git.MaxGitDiffFiles)
+		conf.Git.MaxGitDiffLineCharacters, conf.Git.MaxGitDiffFiles, c.QueryStrings("expand")...)
 	if err != nil {
 		c.ServerError("GetDiffRange", err)
 		return false
@@ -596,6 +900,7 @@ func PrepareCompareDiff(
 	c.Data["SourcePath"] = conf.Server.Subpath + "/" + path.Join(headTarget, "src", headCommitID)
 	c.Data["BeforeSourcePath"] = conf.Server.Subpath + "/" + path.Join(headTarget, "src", prInfo.MergeBase)
 	c.Data["RawPath"] = conf.Server.Subpath + "/" + path.Join(headTarget, "raw", headCommitID)
+	setBeforeImageData(c, headGitRepo, headTarget, prInfo.MergeBase)
 	return false
 }
 
@@ -782,5 +1087,10 @@ func TriggerTask(c *context.Context) {
 
 	go db.HookQueue.Add(repo.ID)
 	go db.AddTestPullRequestTask(pusher, repo.ID, branch, true)
+	go db.AddPushMirrorSyncTask(repo.ID)
+	if branch == repo.DefaultBranch {
+		go db.AddCodeIndexerTask(repo.ID)
+		go db.AddLanguageStatsTask(repo.ID)
+	}
 	c.Status(202)
 }
@@ -6,12 +6,14 @@ package repo
 
 import (
 	"fmt"
+	"io"
 	"strings"
 
 	log "unknwon.dev/clog/v2"
 
 	"gogs.io/gogs/internal/context"
 	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/db/errors"
 	"gogs.io/gogs/internal/form"
 	"gogs.io/gogs/internal/markup"
 	"gogs.io/gogs/internal/conf"
@@ -227,6 +229,8 @@ func NewReleasePost(c *context.Context, f form.NewRelease) {
 			c.RenderWithErr(c.Tr("repo.release.tag_name_already_exist"), RELEASE_NEW, &f)
 		case db.IsErrInvalidTagName(err):
 			c.RenderWithErr(c.Tr("repo.release.tag_name_invalid"), RELEASE_NEW, &f)
+		case errors.IsRefNameNotAllowed(err):
+			c.RenderWithErr(refNameNotAllowedMessage(c, err.(errors.RefNameNotAllowed)), RELEASE_NEW, &f)
 		default:
 			c.Handle(500, "NewRelease", err)
 		}
@@ -316,7 +320,9 @@ func UploadReleaseAttachment(c *context.Context) {
 		c.NotFound()
 		return
 	}
-	uploadAttachment(c, conf.Release.Attachment.AllowedTypes)
+	uploadAttachment(c, conf.Release.Attachment.AllowedTypes, func(name string, r io.Reader, size int64) (*db.Attachment, error) {
+		return db.NewReleaseAttachment(0, name, r, size)
+	})
 }
 
 func DeleteRelease(c *context.Context) {
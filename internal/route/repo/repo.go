@@ -6,9 +6,11 @@ package repo
 
 import (
 	"fmt"
+	"net/http"
 	"os"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/unknwon/com"
 	log "unknwon.dev/clog/v2"
@@ -34,6 +36,14 @@ func MustBeNotBare(c *context.Context) {
 	}
 }
 
+// MustBeNotArchived makes sure the repository is not archived, archived
+// repositories are read-only.
+func MustBeNotArchived(c *context.Context) {
+	if c.Repo.Repository.IsArchived {
+		c.Handle(404, "MustBeNotArchived", nil)
+	}
+}
+
 func checkContextUser(c *context.Context, uid int64) *db.User {
 	orgs, err := db.GetOwnedOrgsByUserIDDesc(c.User.ID, "updated_unix")
 	if err != nil {
@@ -65,13 +75,40 @@ func checkContextUser(c *context.Context, uid int64) *db.User {
 	return org
 }
 
+// getTemplateRepository returns the template repository of given ID that the user has
+// read access to, it returns nil without writing response if ID is zero.
+func getTemplateRepository(c *context.Context, id int64) *db.Repository {
+	if id <= 0 {
+		return nil
+	}
+
+	repo, err := db.GetRepositoryByID(id)
+	if err != nil {
+		c.NotFoundOrServerError("GetRepositoryByID", errors.IsRepoNotExist, err)
+		return nil
+	}
+	if !repo.IsTemplate || repo.IsBare {
+		c.NotFound()
+		return nil
+	}
+	if has, err := db.HasAccess(c.User.ID, repo, db.ACCESS_MODE_READ); err != nil {
+		c.ServerError("HasAccess", err)
+		return nil
+	} else if !has {
+		c.NotFound()
+		return nil
+	}
+
+	c.Data["TemplateRepo"] = repo
+	return repo
+}
+
 func Create(c *context.Context) {
 	c.Title("new_repo")
 	c.RequireAutosize()
 
 	// Give default value for template to render.
 	c.Data["Gitignores"] = db.Gitignores
-	c.Data["Licenses"] = db.Licenses
 	c.Data["Readmes"] = db.Readmes
 	c.Data["readme"] = "Default"
 	c.Data["private"] = c.User.LastRepoVisibility
@@ -82,6 +119,13 @@ func Create(c *context.Context) {
 		return
 	}
 	c.Data["ContextUser"] = ctxUser
+	licenses, _ := db.AllowedLicenses(ctxUser)
+	c.Data["Licenses"] = licenses
+
+	getTemplateRepository(c, c.QueryInt64("template"))
+	if c.Written() {
+		return
+	}
 
 	c.HTML(200, CREATE)
 }
@@ -90,6 +134,8 @@ func handleCreateError(c *context.Context, owner *db.User, err error, name, tpl
 	switch {
 	case errors.IsReachLimitOfRepo(err):
 		c.RenderWithErr(c.Tr("repo.form.reach_limit_of_creation", owner.RepoCreationNum()), tpl, form)
+	case errors.IsLicenseNotAllowed(err):
+		c.RenderWithErr(c.Tr("repo.form.license_not_allowed", err.(errors.LicenseNotAllowed).Name), tpl, form)
 	case db.IsErrRepoAlreadyExist(err):
 		c.Data["Err_RepoName"] = true
 		c.RenderWithErr(c.Tr("form.repo_name_been_taken"), tpl, form)
@@ -108,7 +154,6 @@ func CreatePost(c *context.Context, f form.CreateRepo) {
 	c.Data["Title"] = c.Tr("new_repo")
 
 	c.Data["Gitignores"] = db.Gitignores
-	c.Data["Licenses"] = db.Licenses
 	c.Data["Readmes"] = db.Readmes
 
 	ctxUser := checkContextUser(c, f.UserID)
@@ -116,6 +161,13 @@ func CreatePost(c *context.Context, f form.CreateRepo) {
 		return
 	}
 	c.Data["ContextUser"] = ctxUser
+	licenses, _ := db.AllowedLicenses(ctxUser)
+	c.Data["Licenses"] = licenses
+
+	templateRepo := getTemplateRepository(c, f.TemplateID)
+	if c.Written() {
+		return
+	}
 
 	if c.HasError() {
 		c.HTML(200, CREATE)
@@ -129,7 +181,9 @@ func CreatePost(c *context.Context, f form.CreateRepo) {
 		License:     f.License,
 		Readme:      f.Readme,
 		IsPrivate:   f.Private || conf.Repository.ForcePrivate,
+		IsInternal:  f.Internal,
 		AutoInit:    f.AutoInit,
+		Template:    templateRepo,
 	})
 	if err == nil {
 		log.Trace("Repository created [%d]: %s/%s", repo.ID, ctxUser.Name, repo.Name)
@@ -151,6 +205,10 @@ func Migrate(c *context.Context) {
 	c.Data["private"] = c.User.LastRepoVisibility
 	c.Data["IsForcedPrivate"] = conf.Repository.ForcePrivate
 	c.Data["mirror"] = c.Query("mirror") == "1"
+	c.Data["service"] = ""
+	c.Data["issues"] = true
+	c.Data["pull_requests"] = true
+	c.Data["releases"] = true
 
 	ctxUser := checkContextUser(c, c.QueryInt64("org"))
 	if c.Written() {
@@ -202,9 +260,14 @@ func MigratePost(c *context.Context, f form.MigrateRepo) {
 		IsPrivate:   f.Private || conf.Repository.ForcePrivate,
 		IsMirror:    f.Mirror,
 		RemoteAddr:  remoteAddr,
+		Migration:   f.MigrationOptions(),
 	})
 	if err == nil {
 		log.Trace("Repository migrated [%d]: %s/%s", repo.ID, ctxUser.Name, f.RepoName)
+		if f.Service != "" {
+			c.Redirect(conf.Server.Subpath + "/" + ctxUser.Name + "/" + f.RepoName + "/settings/migration")
+			return
+		}
 		c.Redirect(conf.Server.Subpath + "/" + ctxUser.Name + "/" + f.RepoName)
 		return
 	}
@@ -233,7 +296,14 @@ func Action(c *context.Context) {
 	var err error
 	switch c.Params(":action") {
 	case "watch":
-		err = db.WatchRepo(c.User.ID, c.Repo.Repository.ID, true)
+		mode := db.WATCH_MODE_ALL
+		if name := c.Query("mode"); name != "" {
+			if mode = db.ParseWatchMode(name); mode == 0 {
+				c.Error(http.StatusBadRequest, "invalid watch mode")
+				return
+			}
+		}
+		err = db.SetWatchMode(c.User.ID, c.Repo.Repository.ID, mode)
 	case "unwatch":
 		if userID := c.QueryInt64("user_id"); userID != 0 {
 			if c.User.IsAdmin {
@@ -340,3 +410,46 @@ func Download(c *context.Context) {
 
 	c.ServeFile(archivePath, c.Repo.Repository.Name+"-"+refName+ext)
 }
+
+// DownloadBundle streams a Git bundle of references given by "ref" parameters, or of
+// all references when none is given. The SHA-256 checksum of the bundle is sent as
+// the "X-Checksum-Sha256" trailer for verification.
+func DownloadBundle(c *context.Context) {
+	if !conf.Repository.Bundle.Enabled {
+		c.NotFound()
+		return
+	}
+
+	refs, err := c.Repo.Repository.ResolveBundleRefs(c.QueryStrings("ref"))
+	if err != nil {
+		c.NotFoundOrServerError("ResolveBundleRefs", errors.IsInvalidBundleRef, err)
+		return
+	}
+
+	key := "ip:" + c.RemoteAddr()
+	if c.IsLogged {
+		key = "user:" + com.ToStr(c.User.ID)
+	}
+	release, err := db.AcquireBundle(key)
+	if err != nil {
+		if errors.IsBundleLimitExceeded(err) {
+			c.Error(http.StatusTooManyRequests)
+			return
+		}
+		c.ServerError("AcquireBundle", err)
+		return
+	}
+	defer release()
+
+	c.Resp.Header().Set("Content-Type", "application/x-git-bundle")
+	c.Resp.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.bundle"`,
+		c.Repo.Repository.Name, time.Now().Format("20060102150405")))
+	c.Resp.Header().Set("Trailer", "X-Checksum-Sha256")
+	checksum, err := c.Repo.Repository.WriteBundle(c.Resp, refs)
+	if err != nil {
+		// The response may have been partially written, nothing else can be done.
+		log.Error("Failed to write bundle of repository %d: %v", c.Repo.Repository.ID, err)
+		return
+	}
+	c.Resp.Header().Set("X-Checksum-Sha256", checksum)
+}
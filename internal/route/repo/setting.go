@@ -14,6 +14,8 @@ import (
 	"github.com/unknwon/com"
 	log "unknwon.dev/clog/v2"
 
+	api "github.com/gogs/go-gogs-client"
+
 	"gogs.io/gogs/internal/conf"
 	"gogs.io/gogs/internal/context"
 	"gogs.io/gogs/internal/db"
@@ -29,6 +31,10 @@ const (
 	SETTINGS_COLLABORATION    = "repo/settings/collaboration"
 	SETTINGS_BRANCHES         = "repo/settings/branches"
 	SETTINGS_PROTECTED_BRANCH = "repo/settings/protected_branch"
+	SETTINGS_PUSH_MIRRORS     = "repo/settings/push_mirrors"
+	SETTINGS_ISSUE_MIRROR     = "repo/settings/issue_mirror"
+	SETTINGS_DELETED_BRANCHES = "repo/settings/deleted_branches"
+	SETTINGS_MIGRATION        = "repo/settings/migration"
 	SETTINGS_GITHOOKS         = "repo/settings/githooks"
 	SETTINGS_GITHOOK_EDIT     = "repo/settings/githook_edit"
 	SETTINGS_DEPLOY_KEYS      = "repo/settings/deploy_keys"
@@ -38,6 +44,22 @@ func Settings(c *context.Context) {
 	c.Title("repo.settings")
 	c.PageIs("SettingsOptions")
 	c.RequireAutosize()
+
+	task, err := db.GetMigrationTaskByRepoID(c.Repo.Repository.ID)
+	if err != nil && !errors.IsMigrationTaskNotExist(err) {
+		c.ServerError("GetMigrationTaskByRepoID", err)
+		return
+	}
+	c.Data["MigrationTask"] = task
+
+	gc, err := db.GetRepoGC(c.Repo.Repository.ID)
+	if err != nil {
+		c.ServerError("GetRepoGC", err)
+		return
+	}
+	c.Data["RepoGC"] = gc
+	c.Data["IsRepoGCRunning"] = db.IsRepoGCRunning(c.Repo.Repository.ID)
+
 	c.Success(SETTINGS_OPTIONS)
 }
 
@@ -48,13 +70,20 @@ func SettingsPost(c *context.Context, f form.RepoSetting) {
 
 	repo := c.Repo.Repository
 
-	switch c.Query("action") {
+	switch action := c.Query("action"); action {
 	case "update":
 		if c.HasError() {
 			c.Success(SETTINGS_OPTIONS)
 			return
 		}
 
+		// Only admins can rename the repository or change its visibility.
+		if !c.Repo.IsAdmin() {
+			f.RepoName = repo.Name
+			f.Private = repo.IsPrivate
+			f.Internal = repo.IsInternal
+		}
+
 		isNameChanged := false
 		oldRepoName := repo.Name
 		newRepoName := f.RepoName
@@ -88,10 +117,18 @@ func SettingsPost(c *context.Context, f form.RepoSetting) {
 		// Visibility of forked repository is forced sync with base repository.
 		if repo.IsFork {
 			f.Private = repo.BaseRepo.IsPrivate
+			f.Internal = repo.BaseRepo.IsInternal
+		}
+		// Internal repository is always private.
+		if f.Internal {
+			f.Private = true
 		}
 
-		visibilityChanged := repo.IsPrivate != f.Private
+		visibilityChanged := repo.IsPrivate != f.Private || repo.IsInternal != f.Internal
 		repo.IsPrivate = f.Private
+		repo.IsInternal = f.Internal
+		repo.DisableIndexing = f.DisableIndexing
+		repo.IsTemplate = f.Template
 		if err := db.UpdateRepository(repo, visibilityChanged); err != nil {
 			c.ServerError("UpdateRepository", err)
 			return
@@ -102,6 +139,9 @@ func SettingsPost(c *context.Context, f form.RepoSetting) {
 			if err := db.RenameRepoAction(c.User, oldRepoName, repo); err != nil {
 				log.Error("RenameRepoAction: %v", err)
 			}
+			db.PrepareRepositoryWebhooks(c.User, repo, db.HOOK_REPO_RENAMED, &db.RepositoryChanges{
+				Name: &api.ChangesFromPayload{From: oldRepoName},
+			})
 		}
 
 		c.Flash.Success(c.Tr("repo.settings.update_settings_success"))
@@ -145,6 +185,7 @@ func SettingsPost(c *context.Context, f form.RepoSetting) {
 		repo.AllowPublicWiki = f.AllowPublicWiki
 		repo.EnableExternalWiki = f.EnableExternalWiki
 		repo.ExternalWikiURL = f.ExternalWikiURL
+		repo.WikiWriteAccess = db.ParseAccessMode(f.WikiWriteAccess)
 		repo.EnableIssues = f.EnableIssues
 		repo.AllowPublicIssues = f.AllowPublicIssues
 		repo.EnableExternalTracker = f.EnableExternalTracker
@@ -154,6 +195,8 @@ func SettingsPost(c *context.Context, f form.RepoSetting) {
 		repo.EnablePulls = f.EnablePulls
 		repo.PullsIgnoreWhitespace = f.PullsIgnoreWhitespace
 		repo.PullsAllowRebase = f.PullsAllowRebase
+		repo.PullsRequireResolved = f.PullsRequireResolved
+		repo.PullsDeleteHeadBranch = f.PullsDeleteHeadBranch
 
 		if err := db.UpdateRepository(repo, false); err != nil {
 			c.ServerError("UpdateRepository", err)
@@ -164,6 +207,25 @@ func SettingsPost(c *context.Context, f form.RepoSetting) {
 		c.Flash.Success(c.Tr("repo.settings.update_settings_success"))
 		c.Redirect(c.Repo.RepoLink + "/settings")
 
+	case "gc-settings":
+		if err := db.SetRepoAutoGC(repo.ID, f.EnableAutoGC); err != nil {
+			c.ServerError("SetRepoAutoGC", err)
+			return
+		}
+		log.Trace("Repository garbage collection settings updated: %s/%s", c.Repo.Owner.Name, repo.Name)
+
+		c.Flash.Success(c.Tr("repo.settings.update_settings_success"))
+		c.Redirect(repo.Link() + "/settings")
+
+	case "gc":
+		go func() {
+			if err := repo.RunGC(); err != nil {
+				log.Error("Failed to run garbage collection [repo_id: %d]: %v", repo.ID, err)
+			}
+		}()
+		c.Flash.Info(c.Tr("repo.settings.gc_in_progress"))
+		c.Redirect(repo.Link() + "/settings")
+
 	case "convert":
 		if !c.Repo.IsOwner() {
 			c.NotFound()
@@ -237,6 +299,37 @@ func SettingsPost(c *context.Context, f form.RepoSetting) {
 		c.Flash.Success(c.Tr("repo.settings.transfer_succeed"))
 		c.Redirect(conf.Server.Subpath + "/" + newOwner + "/" + repo.Name)
 
+	case "archive", "unarchive":
+		if !c.Repo.IsOwner() {
+			c.NotFound()
+			return
+		}
+		if repo.Name != f.RepoName {
+			c.RenderWithErr(c.Tr("form.enterred_invalid_repo_name"), SETTINGS_OPTIONS, nil)
+			return
+		}
+
+		if c.Repo.Owner.IsOrganization() && !c.User.IsAdmin {
+			if !c.Repo.Owner.IsOwnedBy(c.User.ID) {
+				c.NotFound()
+				return
+			}
+		}
+
+		archived := action == "archive"
+		if err := db.SetRepositoryArchived(c.User, repo, archived); err != nil {
+			c.ServerError("SetRepositoryArchived", err)
+			return
+		}
+		log.Trace("Repository archive status changed: %s/%s -> %v", c.Repo.Owner.Name, repo.Name, archived)
+
+		if archived {
+			c.Flash.Success(c.Tr("repo.settings.archive_success"))
+		} else {
+			c.Flash.Success(c.Tr("repo.settings.unarchive_success"))
+		}
+		c.Redirect(c.Repo.RepoLink + "/settings")
+
 	case "delete":
 		if !c.Repo.IsOwner() {
 			c.NotFound()
@@ -254,8 +347,8 @@ func SettingsPost(c *context.Context, f form.RepoSetting) {
 			}
 		}
 
-		if err := db.DeleteRepository(c.Repo.Owner.ID, repo.ID); err != nil {
-			c.ServerError("DeleteRepository", err)
+		if err := db.DeleteRepositoryByUser(c.User, repo); err != nil {
+			c.ServerError("DeleteRepositoryByUser", err)
 			return
 		}
 		log.Trace("Repository deleted: %s/%s", c.Repo.Owner.Name, repo.Name)
@@ -407,6 +500,15 @@ func SettingsCollaborationPost(c *context.Context) {
 }
 
 func ChangeCollaborationAccessMode(c *context.Context) {
+	if c.Query("mode") == "issues" {
+		if err := c.Repo.Repository.ChangeCollaborationToIssuesOnly(c.QueryInt64("uid")); err != nil {
+			log.Error("ChangeCollaborationToIssuesOnly: %v", err)
+			return
+		}
+		c.Status(204)
+		return
+	}
+
 	if err := c.Repo.Repository.ChangeCollaborationAccessMode(
 		c.QueryInt64("uid"),
 		db.AccessMode(c.QueryInt("mode"))); err != nil {
@@ -478,11 +580,35 @@ func UpdateDefaultBranch(c *context.Context) {
 		c.Handle(500, "UpdateRepository", err)
 		return
 	}
+	go db.AddCodeIndexerTask(c.Repo.Repository.ID)
+	go db.AddLanguageStatsTask(c.Repo.Repository.ID)
 
 	c.Flash.Success(c.Tr("repo.settings.update_default_branch_success"))
 	c.Redirect(c.Repo.RepoLink + "/settings/branches")
 }
 
+func UpdateRefNameRules(c *context.Context, f form.RefNameRules) {
+	repo := c.Repo.Repository
+	for _, patterns := range []string{f.BranchNamePatterns, f.TagNamePatterns} {
+		if _, err := db.ParseRefNamePatterns(patterns); err != nil {
+			c.Flash.Error(c.Tr("repo.settings.ref_name_invalid_pattern", err))
+			c.Redirect(c.Repo.RepoLink + "/settings/branches")
+			return
+		}
+	}
+
+	repo.BranchNamePatterns = strings.TrimSpace(f.BranchNamePatterns)
+	repo.TagNamePatterns = strings.TrimSpace(f.TagNamePatterns)
+	repo.RestrictRefNameChars = f.RestrictRefNameChars
+	if err := db.UpdateRepository(repo, false); err != nil {
+		c.Handle(500, "UpdateRepository", err)
+		return
+	}
+
+	c.Flash.Success(c.Tr("repo.settings.update_ref_name_rules_success"))
+	c.Redirect(c.Repo.RepoLink + "/settings/branches")
+}
+
 func SettingsProtectedBranch(c *context.Context) {
 	branch := c.Params("*")
 	if !c.Repo.GitRepo.IsBranchExist(branch) {
@@ -618,6 +744,277 @@ func SettingsGitHooksEditPost(c *context.Context) {
 	c.Redirect(c.Data["Link"].(string))
 }
 
+func SettingsPushMirrors(c *context.Context) {
+	c.Data["Title"] = c.Tr("repo.settings.push_mirrors")
+	c.Data["PageIsSettingsPushMirrors"] = true
+
+	mirrors, err := db.GetPushMirrorsByRepoID(c.Repo.Repository.ID)
+	if err != nil {
+		c.ServerError("GetPushMirrorsByRepoID", err)
+		return
+	}
+	c.Data["PushMirrors"] = mirrors
+
+	c.Success(SETTINGS_PUSH_MIRRORS)
+}
+
+func SettingsPushMirrorsPost(c *context.Context, f form.AddPushMirror) {
+	c.Data["Title"] = c.Tr("repo.settings.push_mirrors")
+	c.Data["PageIsSettingsPushMirrors"] = true
+
+	mirrors, err := db.GetPushMirrorsByRepoID(c.Repo.Repository.ID)
+	if err != nil {
+		c.ServerError("GetPushMirrorsByRepoID", err)
+		return
+	}
+	c.Data["PushMirrors"] = mirrors
+
+	if c.HasError() {
+		c.Success(SETTINGS_PUSH_MIRRORS)
+		return
+	}
+
+	if f.Interval < 0 {
+		c.FormErr("Interval")
+		c.RenderWithErr(c.Tr("repo.settings.push_mirror_invalid_interval"), SETTINGS_PUSH_MIRRORS, &f)
+		return
+	}
+
+	remoteAddr, err := f.ParseRemoteAddr()
+	if err != nil {
+		c.FormErr("Address")
+		c.RenderWithErr(c.Tr("repo.settings.push_mirror_invalid_address"), SETTINGS_PUSH_MIRRORS, &f)
+		return
+	}
+
+	m, err := db.CreatePushMirror(c.Repo.Repository, remoteAddr, f.Interval, f.SyncOnPush)
+	if err != nil {
+		c.ServerError("CreatePushMirror", err)
+		return
+	}
+	go db.PushMirrorQueue.Add(m.ID)
+
+	log.Trace("Push mirror added: %d", c.Repo.Repository.ID)
+	c.Flash.Success(c.Tr("repo.settings.add_push_mirror_success"))
+	c.Redirect(c.Repo.RepoLink + "/settings/push_mirrors")
+}
+
+func SyncPushMirror(c *context.Context) {
+	m, err := db.GetPushMirrorOfRepoByID(c.Repo.Repository.ID, c.QueryInt64("id"))
+	if err != nil {
+		c.NotFoundOrServerError("GetPushMirrorOfRepoByID", errors.IsPushMirrorNotExist, err)
+		return
+	}
+
+	go db.PushMirrorQueue.Add(m.ID)
+	c.Flash.Info(c.Tr("repo.settings.push_mirror_sync_in_progress"))
+	c.Redirect(c.Repo.RepoLink + "/settings/push_mirrors")
+}
+
+func DeletePushMirror(c *context.Context) {
+	m, err := db.GetPushMirrorOfRepoByID(c.Repo.Repository.ID, c.QueryInt64("id"))
+	if err == nil {
+		err = db.DeletePushMirror(c.Repo.Repository, m)
+	}
+	if err != nil {
+		c.Flash.Error("DeletePushMirror: " + err.Error())
+	} else {
+		c.Flash.Success(c.Tr("repo.settings.push_mirror_deletion_success"))
+	}
+
+	c.JSON(200, map[string]interface{}{
+		"redirect": c.Repo.RepoLink + "/settings/push_mirrors",
+	})
+}
+
+// issueMirror returns the issue mirror of the repository, it is nil when the
+// repository does not have one.
+func issueMirror(c *context.Context) *db.IssueMirror {
+	m, err := db.GetIssueMirrorByRepoID(c.Repo.Repository.ID)
+	if err != nil {
+		if !errors.IsIssueMirrorNotExist(err) {
+			c.ServerError("GetIssueMirrorByRepoID", err)
+		}
+		return nil
+	}
+	return m
+}
+
+func SettingsIssueMirror(c *context.Context) {
+	c.Title("repo.settings.issue_mirror")
+	c.PageIs("SettingsIssueMirror")
+
+	c.Data["IssueMirror"] = issueMirror(c)
+	if c.Written() {
+		return
+	}
+	c.Data["service"] = db.ISSUE_MIRROR_SERVICE_GOGS
+
+	c.Success(SETTINGS_ISSUE_MIRROR)
+}
+
+func SettingsIssueMirrorPost(c *context.Context, f form.IssueMirror) {
+	c.Title("repo.settings.issue_mirror")
+	c.PageIs("SettingsIssueMirror")
+
+	if m := issueMirror(c); c.Written() {
+		return
+	} else if m != nil {
+		c.Redirect(c.Repo.RepoLink + "/settings/issue_mirror")
+		return
+	}
+
+	if c.HasError() {
+		c.Success(SETTINGS_ISSUE_MIRROR)
+		return
+	}
+
+	if f.Interval < 0 {
+		c.FormErr("Interval")
+		c.RenderWithErr(c.Tr("repo.settings.issue_mirror_invalid_interval"), SETTINGS_ISSUE_MIRROR, &f)
+		return
+	}
+
+	m, err := db.CreateIssueMirror(c.User, c.Repo.Repository, db.IssueMirrorOptions{
+		Service:    f.Service,
+		RemoteAddr: f.Address,
+		Token:      f.Token,
+		Interval:   f.Interval,
+	})
+	if err != nil {
+		if db.IsErrInvalidCloneAddr(err) {
+			c.FormErr("Address")
+			c.RenderWithErr(c.Tr("repo.settings.issue_mirror_invalid_address"), SETTINGS_ISSUE_MIRROR, &f)
+		} else {
+			c.ServerError("CreateIssueMirror", err)
+		}
+		return
+	}
+	go db.IssueMirrorQueue.Add(m.ID)
+
+	log.Trace("Issue mirror added: %d", c.Repo.Repository.ID)
+	c.Flash.Success(c.Tr("repo.settings.add_issue_mirror_success"))
+	c.Redirect(c.Repo.RepoLink + "/settings/issue_mirror")
+}
+
+func SyncIssueMirror(c *context.Context) {
+	m, err := db.GetIssueMirrorByRepoID(c.Repo.Repository.ID)
+	if err != nil {
+		c.NotFoundOrServerError("GetIssueMirrorByRepoID", errors.IsIssueMirrorNotExist, err)
+		return
+	}
+
+	go db.IssueMirrorQueue.Add(m.ID)
+	c.Flash.Info(c.Tr("repo.settings.issue_mirror_sync_in_progress"))
+	c.Redirect(c.Repo.RepoLink + "/settings/issue_mirror")
+}
+
+func DeleteIssueMirror(c *context.Context) {
+	m, err := db.GetIssueMirrorByRepoID(c.Repo.Repository.ID)
+	if err == nil {
+		err = db.DeleteIssueMirror(m)
+	}
+	if err != nil {
+		c.Flash.Error("DeleteIssueMirror: " + err.Error())
+	} else {
+		c.Flash.Success(c.Tr("repo.settings.issue_mirror_deletion_success"))
+	}
+
+	c.JSON(200, map[string]interface{}{
+		"redirect": c.Repo.RepoLink + "/settings/issue_mirror",
+	})
+}
+
+func SettingsDeletedBranches(c *context.Context) {
+	c.Title("repo.settings.deleted_branches")
+	c.PageIs("SettingsDeletedBranches")
+
+	branches, err := db.GetDeletedBranches(c.Repo.Repository.ID)
+	if err != nil {
+		c.ServerError("GetDeletedBranches", err)
+		return
+	}
+	c.Data["DeletedBranches"] = branches
+
+	c.Success(SETTINGS_DELETED_BRANCHES)
+}
+
+func RestoreDeletedBranch(c *context.Context) {
+	b, err := db.GetDeletedBranchByID(c.Repo.Repository.ID, c.QueryInt64("id"))
+	if err != nil {
+		c.NotFoundOrServerError("GetDeletedBranchByID", errors.IsDeletedBranchNotExist, err)
+		return
+	}
+
+	if err = c.Repo.Repository.RestoreDeletedBranch(c.User, b); err != nil {
+		if errors.IsBranchAlreadyExists(err) {
+			c.Flash.Error(c.Tr("repo.settings.restore_branch_already_exists", b.Name))
+		} else {
+			c.ServerError("RestoreDeletedBranch", err)
+			return
+		}
+	} else {
+		log.Trace("Deleted branch restored [repo_id: %d, name: %s]: %s", c.Repo.Repository.ID, b.Name, c.User.Name)
+		c.Flash.Success(c.Tr("repo.settings.restore_branch_success", b.Name))
+	}
+	c.Redirect(c.Repo.RepoLink + "/settings/deleted_branches")
+}
+
+func PurgeDeletedBranch(c *context.Context) {
+	b, err := db.GetDeletedBranchByID(c.Repo.Repository.ID, c.QueryInt64("id"))
+	if err == nil {
+		err = c.Repo.Repository.PurgeDeletedBranch(b)
+	}
+	if err != nil {
+		c.Flash.Error("PurgeDeletedBranch: " + err.Error())
+	} else {
+		c.Flash.Success(c.Tr("repo.settings.purge_branch_success", b.Name))
+	}
+
+	c.JSON(200, map[string]interface{}{
+		"redirect": c.Repo.RepoLink + "/settings/deleted_branches",
+	})
+}
+
+func SettingsMigration(c *context.Context) {
+	c.Title("repo.settings.migration")
+	c.PageIs("SettingsOptions")
+
+	task, err := db.GetMigrationTaskByRepoID(c.Repo.Repository.ID)
+	if err != nil {
+		c.NotFoundOrServerError("GetMigrationTaskByRepoID", errors.IsMigrationTaskNotExist, err)
+		return
+	}
+	c.Data["MigrationTask"] = task
+
+	c.Success(SETTINGS_MIGRATION)
+}
+
+func RetryMigration(c *context.Context) {
+	task, err := db.GetMigrationTaskByRepoID(c.Repo.Repository.ID)
+	if err != nil {
+		c.NotFoundOrServerError("GetMigrationTaskByRepoID", errors.IsMigrationTaskNotExist, err)
+		return
+	}
+
+	if err = db.RetryMigrationTask(task); err != nil {
+		c.ServerError("RetryMigrationTask", err)
+		return
+	}
+
+	c.Flash.Info(c.Tr("repo.settings.migration_retry_success"))
+	c.Redirect(c.Repo.RepoLink + "/settings/migration")
+}
+
+func SettingsExport(c *context.Context) {
+	repo := c.Repo.Repository
+	c.Header().Set("Content-Type", "application/zip")
+	c.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.zip"`, repo.Name, time.Now().Format("20060102150405")))
+	if err := db.ExportRepository(repo, c.Resp); err != nil {
+		log.Error("Failed to export repository %d: %v", repo.ID, err)
+	}
+}
+
 func SettingsDeployKeys(c *context.Context) {
 	c.Data["Title"] = c.Tr("repo.settings.deploy_keys")
 	c.Data["PageIsSettingsKeys"] = true
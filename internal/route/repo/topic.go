@@ -0,0 +1,36 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package repo
+
+import (
+	"strings"
+
+	log "unknwon.dev/clog/v2"
+
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+)
+
+// TopicsPost replaces topics of the repository with the comma-separated list.
+func TopicsPost(c *context.Context) {
+	topics, invalid := db.SanitizeTopics(strings.Split(c.Query("topics"), ","))
+	if len(invalid) > 0 {
+		c.Flash.Error(c.Tr("repo.topics.invalid", strings.Join(invalid, ", ")))
+		c.Redirect(c.Repo.RepoLink)
+		return
+	} else if len(topics) > db.MaxTopicsPerRepo {
+		c.Flash.Error(c.Tr("repo.topics.too_many", db.MaxTopicsPerRepo))
+		c.Redirect(c.Repo.RepoLink)
+		return
+	}
+
+	if err := db.SaveRepoTopics(c.Repo.Repository.ID, topics); err != nil {
+		c.ServerError("SaveRepoTopics", err)
+		return
+	}
+	log.Trace("Repository topics updated [repo_id: %d]: %v", c.Repo.Repository.ID, topics)
+
+	c.Redirect(c.Repo.RepoLink)
+}
@@ -53,15 +53,16 @@ func renderDirectory(c *context.Context, treeLink string) {
 		return
 	}
 
+	// Prefer README files that can be rendered over plain text ones.
 	var readmeFile *git.Blob
 	for _, entry := range entries {
 		if entry.IsDir() || !markup.IsReadmeFile(entry.Name()) {
 			continue
 		}
 
-		// TODO: collect all possible README files and show with priority.
-		readmeFile = entry.Blob()
-		break
+		if readmeFile == nil || markup.IsRenderableFile(entry.Name()) && !markup.IsRenderableFile(readmeFile.Name()) {
+			readmeFile = entry.Blob()
+		}
 	}
 
 	if readmeFile != nil {
@@ -86,18 +87,15 @@ func renderDirectory(c *context.Context, treeLink string) {
 			d, _ := ioutil.ReadAll(dataRc)
 			buf = append(buf, d...)
 
-			switch markup.Detect(readmeFile.Name()) {
-			case markup.MARKDOWN:
-				c.Data["IsMarkdown"] = true
-				buf = markup.Markdown(buf, treeLink, c.Repo.Repository.ComposeMetas())
-			case markup.ORG_MODE:
-				c.Data["IsMarkdown"] = true
-				buf = markup.OrgMode(buf, treeLink, c.Repo.Repository.ComposeMetas())
+			switch typ := markup.Detect(readmeFile.Name()); typ {
 			case markup.IPYTHON_NOTEBOOK:
 				c.Data["IsIPythonNotebook"] = true
 				c.Data["RawFileLink"] = c.Repo.RepoLink + "/raw/" + path.Join(c.Repo.BranchName, c.Repo.TreePath, readmeFile.Name())
+			case markup.UNRECOGNIZED:
+				buf = bytes.Replace([]byte(gotemplate.HTMLEscapeString(string(buf))), []byte("\n"), []byte(`<br>`), -1)
 			default:
-				buf = bytes.Replace(buf, []byte("\n"), []byte(`<br>`), -1)
+				c.Data["IsMarkdown"] = true
+				buf = markup.Render(typ, buf, treeLink, c.Repo.Repository.ComposeMetas())
 			}
 			c.Data["FileContent"] = string(buf)
 		}
@@ -162,16 +160,50 @@ func renderFile(c *context.Context, entry *git.TreeEntry, treeLink, rawLink stri
 		d, _ := ioutil.ReadAll(dataRc)
 		buf = append(buf, d...)
 
-		switch markup.Detect(blob.Name()) {
-		case markup.MARKDOWN:
-			c.Data["IsMarkdown"] = true
-			c.Data["FileContent"] = string(markup.Markdown(buf, path.Dir(treeLink), c.Repo.Repository.ComposeMetas()))
-		case markup.ORG_MODE:
-			c.Data["IsMarkdown"] = true
-			c.Data["FileContent"] = string(markup.OrgMode(buf, path.Dir(treeLink), c.Repo.Repository.ComposeMetas()))
-		case markup.IPYTHON_NOTEBOOK:
+		// Notebooks, CSV, 3D model and GeoJSON files are displayed as source code
+		// if requested or they are too large to be rendered.
+		typ := markup.Detect(blob.Name())
+		delimiter := markup.CSVDelimiter(blob.Name())
+		isSTLFile := tool.IsSTLFile(blob.Name())
+		isGeoJSONFile := tool.IsGeoJSONFile(blob.Name())
+		if typ == markup.IPYTHON_NOTEBOOK || delimiter != 0 || isSTLFile || isGeoJSONFile {
+			displaySource := false
+			if blob.Size() >= conf.UI.MaxRichDisplayFileSize {
+				c.Data["IsRichFileTooLarge"] = true
+				displaySource = true
+			} else {
+				c.Data["IsRichFile"] = true
+				if c.Query("display") == "source" {
+					c.Data["IsDisplaySource"] = true
+					displaySource = true
+				}
+			}
+			if displaySource {
+				typ, delimiter = markup.UNRECOGNIZED, 0
+				isSTLFile, isGeoJSONFile = false, false
+			}
+		}
+
+		var csvRows [][]string
+		if delimiter != 0 {
+			csvRows, err = markup.ParseCSV(buf, delimiter)
+			if err != nil {
+				log.Trace("Failed to parse CSV file %q: %v", c.Repo.TreePath, err)
+			}
+		}
+
+		switch {
+		case typ == markup.IPYTHON_NOTEBOOK:
 			c.Data["IsIPythonNotebook"] = true
-		default:
+		case isSTLFile:
+			c.Data["IsSTLFile"] = true
+		case isGeoJSONFile:
+			c.Data["IsGeoJSONFile"] = true
+		case len(csvRows) > 0:
+			c.Data["IsCSV"] = true
+			c.Data["CSVHeader"] = csvRows[0]
+			c.Data["CSVRows"] = csvRows[1:]
+		case typ == markup.UNRECOGNIZED:
 			// Building code view blocks with line number on server side.
 			var fileContent string
 			if err, content := template.ToUTF8WithErr(buf); err != nil {
@@ -199,6 +231,9 @@ func renderFile(c *context.Context, entry *git.TreeEntry, treeLink, rawLink stri
 				output.WriteString(fmt.Sprintf(`<span id="L%d">%d</span>`, i+1, i+1))
 			}
 			c.Data["LineNums"] = gotemplate.HTML(output.String())
+		default:
+			c.Data["IsMarkdown"] = true
+			c.Data["FileContent"] = string(markup.Render(typ, buf, path.Dir(treeLink), c.Repo.Repository.ComposeMetas()))
 		}
 
 		if canEnableEditor {
@@ -206,12 +241,23 @@ func renderFile(c *context.Context, entry *git.TreeEntry, treeLink, rawLink stri
 			c.Data["EditFileTooltip"] = c.Tr("repo.editor.edit_this_file")
 		} else if !c.Repo.IsViewBranch {
 			c.Data["EditFileTooltip"] = c.Tr("repo.editor.must_be_on_a_branch")
-		} else if !c.Repo.IsWriter() {
+		} else if !c.Repo.CanWrite(db.REPO_UNIT_CODE) {
 			c.Data["EditFileTooltip"] = c.Tr("repo.editor.fork_before_edit")
 		}
 
+	case tool.IsSTLFile(blob.Name()):
+		// Binary STL files
+		if blob.Size() >= conf.UI.MaxRichDisplayFileSize {
+			c.Data["IsFileTooLarge"] = true
+		} else {
+			c.Data["IsSTLFile"] = true
+		}
 	case tool.IsPDFFile(buf):
-		c.Data["IsPDFFile"] = true
+		if blob.Size() >= conf.UI.MaxDisplayFileSize {
+			c.Data["IsFileTooLarge"] = true
+		} else {
+			c.Data["IsPDFFile"] = true
+		}
 	case tool.IsVideoFile(buf):
 		c.Data["IsVideoFile"] = true
 	case tool.IsImageFile(buf):
@@ -223,7 +269,7 @@ func renderFile(c *context.Context, entry *git.TreeEntry, treeLink, rawLink stri
 		c.Data["DeleteFileTooltip"] = c.Tr("repo.editor.delete_this_file")
 	} else if !c.Repo.IsViewBranch {
 		c.Data["DeleteFileTooltip"] = c.Tr("repo.editor.must_be_on_a_branch")
-	} else if !c.Repo.IsWriter() {
+	} else if !c.Repo.CanWrite(db.REPO_UNIT_CODE) {
 		c.Data["DeleteFileTooltip"] = c.Tr("repo.editor.must_have_write_access")
 	}
 }
@@ -273,6 +319,19 @@ func Home(c *context.Context) {
 			return
 		}
 		c.Data["CommitsCount"] = c.Repo.CommitsCount
+
+		c.Data["Topics"], err = db.GetRepoTopicNames(c.Repo.Repository.ID)
+		if err != nil {
+			c.ServerError("GetRepoTopicNames", err)
+			return
+		}
+
+		stats, err := db.GetLanguageStats(c.Repo.Repository.ID)
+		if err != nil {
+			c.ServerError("GetLanguageStats", err)
+			return
+		}
+		c.Data["LanguageStats"] = db.GroupLanguageStats(stats, 1)
 	}
 	c.Data["PageIsRepoHome"] = isRootDir
 
@@ -350,6 +409,16 @@ func Stars(c *context.Context) {
 	RenderUserCards(c, c.Repo.Repository.NumStars, c.Repo.Repository.GetStargazers, WATCHERS)
 }
 
+// StarHistory renders daily numbers of stars of the repository in JSON.
+func StarHistory(c *context.Context) {
+	history, err := c.Repo.Repository.StarHistory()
+	if err != nil {
+		c.ServerError("StarHistory", err)
+		return
+	}
+	c.JSONSuccess(history)
+}
+
 func Forks(c *context.Context) {
 	c.Data["Title"] = c.Tr("repos.forks")
 
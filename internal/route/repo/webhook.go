@@ -14,23 +14,24 @@ import (
 	git "github.com/gogs/git-module"
 	api "github.com/gogs/go-gogs-client"
 
+	"gogs.io/gogs/internal/conf"
 	"gogs.io/gogs/internal/context"
 	"gogs.io/gogs/internal/db"
 	"gogs.io/gogs/internal/db/errors"
 	"gogs.io/gogs/internal/form"
-	"gogs.io/gogs/internal/conf"
 )
 
 const (
-	WEBHOOKS        = "repo/settings/webhook/base"
-	WEBHOOK_NEW     = "repo/settings/webhook/new"
-	ORG_WEBHOOK_NEW = "org/settings/webhook_new"
+	WEBHOOKS          = "repo/settings/webhook/base"
+	WEBHOOK_NEW       = "repo/settings/webhook/new"
+	ORG_WEBHOOK_NEW   = "org/settings/webhook_new"
+	ADMIN_WEBHOOK_NEW = "admin/hook_new"
 )
 
 func Webhooks(c *context.Context) {
 	c.Data["Title"] = c.Tr("repo.settings.hooks")
 	c.Data["PageIsSettingsHooks"] = true
-	c.Data["BaseLink"] = c.Repo.RepoLink
+	c.Data["BaseLink"] = c.Repo.RepoLink + "/settings/hooks"
 	c.Data["Description"] = c.Tr("repo.settings.hooks_desc", "https://github.com/gogs/docs-api/blob/master/Repositories/Webhooks.md")
 	c.Data["Types"] = conf.Webhook.Types
 
@@ -45,19 +46,22 @@ func Webhooks(c *context.Context) {
 }
 
 type OrgRepoCtx struct {
-	OrgID       int64
-	RepoID      int64
+	OrgID    int64
+	RepoID   int64
+	IsSystem bool
+	// Link is the link of the webhook list page.
 	Link        string
 	NewTemplate string
 }
 
-// getOrgRepoCtx determines whether this is a repo context or organization context.
+// getOrgRepoCtx determines whether this is a repo context, organization context or
+// system context of the admin panel.
 func getOrgRepoCtx(c *context.Context) (*OrgRepoCtx, error) {
 	if len(c.Repo.RepoLink) > 0 {
 		c.Data["PageIsRepositoryContext"] = true
 		return &OrgRepoCtx{
 			RepoID:      c.Repo.Repository.ID,
-			Link:        c.Repo.RepoLink,
+			Link:        c.Repo.RepoLink + "/settings/hooks",
 			NewTemplate: WEBHOOK_NEW,
 		}, nil
 	}
@@ -66,14 +70,34 @@ func getOrgRepoCtx(c *context.Context) (*OrgRepoCtx, error) {
 		c.Data["PageIsOrganizationContext"] = true
 		return &OrgRepoCtx{
 			OrgID:       c.Org.Organization.ID,
-			Link:        c.Org.OrgLink,
+			Link:        c.Org.OrgLink + "/settings/hooks",
 			NewTemplate: ORG_WEBHOOK_NEW,
 		}, nil
 	}
 
+	if c.Data["PageIsAdminHooks"] == true {
+		return &OrgRepoCtx{
+			IsSystem:    true,
+			Link:        conf.Server.Subpath + "/admin/hooks",
+			NewTemplate: ADMIN_WEBHOOK_NEW,
+		}, nil
+	}
+
 	return nil, errors.New("Unable to set OrgRepo context")
 }
 
+// getOrgRepoWebhook returns the webhook of given ID that belongs to the context.
+func getOrgRepoWebhook(orCtx *OrgRepoCtx, id int64) (*db.Webhook, error) {
+	switch {
+	case orCtx.RepoID > 0:
+		return db.GetWebhookOfRepoByID(orCtx.RepoID, id)
+	case orCtx.OrgID > 0:
+		return db.GetWebhookByOrgID(orCtx.OrgID, id)
+	default:
+		return db.GetSystemWebhookByID(id)
+	}
+}
+
 func checkHookType(c *context.Context) string {
 	hookType := strings.ToLower(c.Params(":type"))
 	if !com.IsSliceContainsStr(conf.Webhook.Types, hookType) {
@@ -118,8 +142,26 @@ func ParseHookEvent(f form.Webhook) *db.HookEvent {
 			IssueComment: f.IssueComment,
 			PullRequest:  f.PullRequest,
 			Release:      f.Release,
+			Repository:   f.Repository,
+			Wiki:         f.Wiki,
+
+			ConfidentialIssues: f.ConfidentialIssues,
 		},
+		BranchFilter: strings.TrimSpace(f.BranchFilter),
+		PathFilter:   strings.TrimSpace(f.PathFilter),
+	}
+}
+
+// validateHookFilters renders the page with an error and returns false if branch or
+// path filter of the webhook has invalid patterns.
+func validateHookFilters(c *context.Context, tpl string, f form.Webhook) bool {
+	for _, filter := range []string{f.BranchFilter, f.PathFilter} {
+		if err := db.ValidateHookFilter(filter); err != nil {
+			c.RenderWithErr(c.Tr("repo.settings.invalid_hook_filter", err.(errors.InvalidHookFilter).Pattern), tpl, nil)
+			return false
+		}
 	}
+	return true
 }
 
 func WebHooksNewPost(c *context.Context, f form.NewWebhook) {
@@ -139,6 +181,8 @@ func WebHooksNewPost(c *context.Context, f form.NewWebhook) {
 	if c.HasError() {
 		c.HTML(200, orCtx.NewTemplate)
 		return
+	} else if !validateHookFilters(c, orCtx.NewTemplate, f.Webhook) {
+		return
 	}
 
 	contentType := db.JSON
@@ -155,6 +199,7 @@ func WebHooksNewPost(c *context.Context, f form.NewWebhook) {
 		IsActive:     f.Active,
 		HookTaskType: db.GOGS,
 		OrgID:        orCtx.OrgID,
+		IsSystem:     orCtx.IsSystem,
 	}
 	if err := w.UpdateEvent(); err != nil {
 		c.Handle(500, "UpdateEvent", err)
@@ -165,7 +210,7 @@ func WebHooksNewPost(c *context.Context, f form.NewWebhook) {
 	}
 
 	c.Flash.Success(c.Tr("repo.settings.add_hook_success"))
-	c.Redirect(orCtx.Link + "/settings/hooks")
+	c.Redirect(orCtx.Link)
 }
 
 func SlackHooksNewPost(c *context.Context, f form.NewSlackHook) {
@@ -183,6 +228,8 @@ func SlackHooksNewPost(c *context.Context, f form.NewSlackHook) {
 	if c.HasError() {
 		c.HTML(200, orCtx.NewTemplate)
 		return
+	} else if !validateHookFilters(c, orCtx.NewTemplate, f.Webhook) {
+		return
 	}
 
 	meta, err := jsoniter.Marshal(&db.SlackMeta{
@@ -205,6 +252,7 @@ func SlackHooksNewPost(c *context.Context, f form.NewSlackHook) {
 		HookTaskType: db.SLACK,
 		Meta:         string(meta),
 		OrgID:        orCtx.OrgID,
+		IsSystem:     orCtx.IsSystem,
 	}
 	if err := w.UpdateEvent(); err != nil {
 		c.Handle(500, "UpdateEvent", err)
@@ -215,7 +263,7 @@ func SlackHooksNewPost(c *context.Context, f form.NewSlackHook) {
 	}
 
 	c.Flash.Success(c.Tr("repo.settings.add_hook_success"))
-	c.Redirect(orCtx.Link + "/settings/hooks")
+	c.Redirect(orCtx.Link)
 }
 
 // FIXME: merge logic to Slack
@@ -234,6 +282,8 @@ func DiscordHooksNewPost(c *context.Context, f form.NewDiscordHook) {
 	if c.HasError() {
 		c.HTML(200, orCtx.NewTemplate)
 		return
+	} else if !validateHookFilters(c, orCtx.NewTemplate, f.Webhook) {
+		return
 	}
 
 	meta, err := jsoniter.Marshal(&db.SlackMeta{
@@ -255,6 +305,7 @@ func DiscordHooksNewPost(c *context.Context, f form.NewDiscordHook) {
 		HookTaskType: db.DISCORD,
 		Meta:         string(meta),
 		OrgID:        orCtx.OrgID,
+		IsSystem:     orCtx.IsSystem,
 	}
 	if err := w.UpdateEvent(); err != nil {
 		c.Handle(500, "UpdateEvent", err)
@@ -265,7 +316,7 @@ func DiscordHooksNewPost(c *context.Context, f form.NewDiscordHook) {
 	}
 
 	c.Flash.Success(c.Tr("repo.settings.add_hook_success"))
-	c.Redirect(orCtx.Link + "/settings/hooks")
+	c.Redirect(orCtx.Link)
 }
 
 func DingtalkHooksNewPost(c *context.Context, f form.NewDingtalkHook) {
@@ -283,6 +334,8 @@ func DingtalkHooksNewPost(c *context.Context, f form.NewDingtalkHook) {
 	if c.HasError() {
 		c.HTML(200, orCtx.NewTemplate)
 		return
+	} else if !validateHookFilters(c, orCtx.NewTemplate, f.Webhook) {
+		return
 	}
 
 	w := &db.Webhook{
@@ -293,6 +346,7 @@ func DingtalkHooksNewPost(c *context.Context, f form.NewDingtalkHook) {
 		IsActive:     f.Active,
 		HookTaskType: db.DINGTALK,
 		OrgID:        orCtx.OrgID,
+		IsSystem:     orCtx.IsSystem,
 	}
 	if err := w.UpdateEvent(); err != nil {
 		c.Handle(500, "UpdateEvent", err)
@@ -303,7 +357,115 @@ func DingtalkHooksNewPost(c *context.Context, f form.NewDingtalkHook) {
 	}
 
 	c.Flash.Success(c.Tr("repo.settings.add_hook_success"))
-	c.Redirect(orCtx.Link + "/settings/hooks")
+	c.Redirect(orCtx.Link)
+}
+
+func MatrixHooksNewPost(c *context.Context, f form.NewMatrixHook) {
+	c.Data["Title"] = c.Tr("repo.settings")
+	c.Data["PageIsSettingsHooks"] = true
+	c.Data["PageIsSettingsHooksNew"] = true
+	c.Data["Webhook"] = db.Webhook{HookEvent: &db.HookEvent{}}
+	c.Data["HookType"] = "matrix"
+
+	orCtx, err := getOrgRepoCtx(c)
+	if err != nil {
+		c.Handle(500, "getOrgRepoCtx", err)
+		return
+	}
+	c.Data["BaseLink"] = orCtx.Link
+
+	if c.HasError() {
+		c.HTML(200, orCtx.NewTemplate)
+		return
+	} else if !validateHookFilters(c, orCtx.NewTemplate, f.Webhook) {
+		return
+	}
+
+	meta, err := jsoniter.Marshal(&db.MatrixMeta{
+		HomeserverURL: f.HomeserverURL,
+		RoomID:        f.RoomID,
+		AccessToken:   f.AccessToken,
+		MessageType:   f.MessageType,
+	})
+	if err != nil {
+		c.Handle(500, "Marshal", err)
+		return
+	}
+
+	w := &db.Webhook{
+		RepoID:       orCtx.RepoID,
+		URL:          db.MatrixURL(f.HomeserverURL, f.RoomID),
+		ContentType:  db.JSON,
+		HookEvent:    ParseHookEvent(f.Webhook),
+		IsActive:     f.Active,
+		HookTaskType: db.MATRIX,
+		Meta:         string(meta),
+		OrgID:        orCtx.OrgID,
+		IsSystem:     orCtx.IsSystem,
+	}
+	if err := w.UpdateEvent(); err != nil {
+		c.Handle(500, "UpdateEvent", err)
+		return
+	} else if err := db.CreateWebhook(w); err != nil {
+		c.Handle(500, "CreateWebhook", err)
+		return
+	}
+
+	c.Flash.Success(c.Tr("repo.settings.add_hook_success"))
+	c.Redirect(orCtx.Link)
+}
+
+func TelegramHooksNewPost(c *context.Context, f form.NewTelegramHook) {
+	c.Data["Title"] = c.Tr("repo.settings")
+	c.Data["PageIsSettingsHooks"] = true
+	c.Data["PageIsSettingsHooksNew"] = true
+	c.Data["Webhook"] = db.Webhook{HookEvent: &db.HookEvent{}}
+	c.Data["HookType"] = "telegram"
+
+	orCtx, err := getOrgRepoCtx(c)
+	if err != nil {
+		c.Handle(500, "getOrgRepoCtx", err)
+		return
+	}
+	c.Data["BaseLink"] = orCtx.Link
+
+	if c.HasError() {
+		c.HTML(200, orCtx.NewTemplate)
+		return
+	} else if !validateHookFilters(c, orCtx.NewTemplate, f.Webhook) {
+		return
+	}
+
+	meta, err := jsoniter.Marshal(&db.TelegramMeta{
+		BotToken: f.BotToken,
+		ChatID:   f.ChatID,
+	})
+	if err != nil {
+		c.Handle(500, "Marshal", err)
+		return
+	}
+
+	w := &db.Webhook{
+		RepoID:       orCtx.RepoID,
+		URL:          db.TelegramURL(f.BotToken),
+		ContentType:  db.JSON,
+		HookEvent:    ParseHookEvent(f.Webhook),
+		IsActive:     f.Active,
+		HookTaskType: db.TELEGRAM,
+		Meta:         string(meta),
+		OrgID:        orCtx.OrgID,
+		IsSystem:     orCtx.IsSystem,
+	}
+	if err := w.UpdateEvent(); err != nil {
+		c.Handle(500, "UpdateEvent", err)
+		return
+	} else if err := db.CreateWebhook(w); err != nil {
+		c.Handle(500, "CreateWebhook", err)
+		return
+	}
+
+	c.Flash.Success(c.Tr("repo.settings.add_hook_success"))
+	c.Redirect(orCtx.Link)
 }
 
 func checkWebhook(c *context.Context) (*OrgRepoCtx, *db.Webhook) {
@@ -316,14 +478,9 @@ func checkWebhook(c *context.Context) (*OrgRepoCtx, *db.Webhook) {
 	}
 	c.Data["BaseLink"] = orCtx.Link
 
-	var w *db.Webhook
-	if orCtx.RepoID > 0 {
-		w, err = db.GetWebhookOfRepoByID(c.Repo.Repository.ID, c.ParamsInt64(":id"))
-	} else {
-		w, err = db.GetWebhookByOrgID(c.Org.Organization.ID, c.ParamsInt64(":id"))
-	}
+	w, err := getOrgRepoWebhook(orCtx, c.ParamsInt64(":id"))
 	if err != nil {
-		c.NotFoundOrServerError("GetWebhookOfRepoByID/GetWebhookByOrgID", errors.IsWebhookNotExist, err)
+		c.NotFoundOrServerError("getOrgRepoWebhook", errors.IsWebhookNotExist, err)
 		return nil, nil
 	}
 
@@ -336,14 +493,28 @@ func checkWebhook(c *context.Context) (*OrgRepoCtx, *db.Webhook) {
 		c.Data["HookType"] = "discord"
 	case db.DINGTALK:
 		c.Data["HookType"] = "dingtalk"
+	case db.MATRIX:
+		c.Data["MatrixHook"] = w.GetMatrixHook()
+		c.Data["HookType"] = "matrix"
+	case db.TELEGRAM:
+		c.Data["TelegramHook"] = w.GetTelegramHook()
+		c.Data["HookType"] = "telegram"
 	default:
 		c.Data["HookType"] = "gogs"
 	}
 
-	c.Data["History"], err = w.History(1)
+	page := c.QueryInt("page")
+	if page <= 0 {
+		page = 1
+	}
+	history, err := w.History(page)
 	if err != nil {
 		c.Handle(500, "History", err)
+		return orCtx, w
 	}
+	c.Data["History"] = history
+	c.Data["HistoryPage"] = page
+	c.Data["HistoryHasNext"] = len(history) == conf.Webhook.PagingNum
 	return orCtx, w
 }
 
@@ -375,6 +546,8 @@ func WebHooksEditPost(c *context.Context, f form.NewWebhook) {
 	if c.HasError() {
 		c.HTML(200, orCtx.NewTemplate)
 		return
+	} else if !validateHookFilters(c, orCtx.NewTemplate, f.Webhook) {
+		return
 	}
 
 	contentType := db.JSON
@@ -396,7 +569,7 @@ func WebHooksEditPost(c *context.Context, f form.NewWebhook) {
 	}
 
 	c.Flash.Success(c.Tr("repo.settings.update_hook_success"))
-	c.Redirect(fmt.Sprintf("%s/settings/hooks/%d", orCtx.Link, w.ID))
+	c.Redirect(fmt.Sprintf("%s/%d", orCtx.Link, w.ID))
 }
 
 func SlackHooksEditPost(c *context.Context, f form.NewSlackHook) {
@@ -413,6 +586,8 @@ func SlackHooksEditPost(c *context.Context, f form.NewSlackHook) {
 	if c.HasError() {
 		c.HTML(200, orCtx.NewTemplate)
 		return
+	} else if !validateHookFilters(c, orCtx.NewTemplate, f.Webhook) {
+		return
 	}
 
 	meta, err := jsoniter.Marshal(&db.SlackMeta{
@@ -439,7 +614,7 @@ func SlackHooksEditPost(c *context.Context, f form.NewSlackHook) {
 	}
 
 	c.Flash.Success(c.Tr("repo.settings.update_hook_success"))
-	c.Redirect(fmt.Sprintf("%s/settings/hooks/%d", orCtx.Link, w.ID))
+	c.Redirect(fmt.Sprintf("%s/%d", orCtx.Link, w.ID))
 }
 
 // FIXME: merge logic to Slack
@@ -457,6 +632,8 @@ func DiscordHooksEditPost(c *context.Context, f form.NewDiscordHook) {
 	if c.HasError() {
 		c.HTML(200, orCtx.NewTemplate)
 		return
+	} else if !validateHookFilters(c, orCtx.NewTemplate, f.Webhook) {
+		return
 	}
 
 	meta, err := jsoniter.Marshal(&db.SlackMeta{
@@ -482,7 +659,7 @@ func DiscordHooksEditPost(c *context.Context, f form.NewDiscordHook) {
 	}
 
 	c.Flash.Success(c.Tr("repo.settings.update_hook_success"))
-	c.Redirect(fmt.Sprintf("%s/settings/hooks/%d", orCtx.Link, w.ID))
+	c.Redirect(fmt.Sprintf("%s/%d", orCtx.Link, w.ID))
 }
 
 func DingtalkHooksEditPost(c *context.Context, f form.NewDingtalkHook) {
@@ -499,6 +676,8 @@ func DingtalkHooksEditPost(c *context.Context, f form.NewDingtalkHook) {
 	if c.HasError() {
 		c.HTML(200, orCtx.NewTemplate)
 		return
+	} else if !validateHookFilters(c, orCtx.NewTemplate, f.Webhook) {
+		return
 	}
 
 	w.URL = f.PayloadURL
@@ -513,14 +692,103 @@ func DingtalkHooksEditPost(c *context.Context, f form.NewDingtalkHook) {
 	}
 
 	c.Flash.Success(c.Tr("repo.settings.update_hook_success"))
-	c.Redirect(fmt.Sprintf("%s/settings/hooks/%d", orCtx.Link, w.ID))
+	c.Redirect(fmt.Sprintf("%s/%d", orCtx.Link, w.ID))
 }
 
-func TestWebhook(c *context.Context) {
+func MatrixHooksEditPost(c *context.Context, f form.NewMatrixHook) {
+	c.Data["Title"] = c.Tr("repo.settings")
+	c.Data["PageIsSettingsHooks"] = true
+	c.Data["PageIsSettingsHooksEdit"] = true
+
+	orCtx, w := checkWebhook(c)
+	if c.Written() {
+		return
+	}
+	c.Data["Webhook"] = w
+
+	if c.HasError() {
+		c.HTML(200, orCtx.NewTemplate)
+		return
+	} else if !validateHookFilters(c, orCtx.NewTemplate, f.Webhook) {
+		return
+	}
+
+	meta, err := jsoniter.Marshal(&db.MatrixMeta{
+		HomeserverURL: f.HomeserverURL,
+		RoomID:        f.RoomID,
+		AccessToken:   f.AccessToken,
+		MessageType:   f.MessageType,
+	})
+	if err != nil {
+		c.Handle(500, "Marshal", err)
+		return
+	}
+
+	w.URL = db.MatrixURL(f.HomeserverURL, f.RoomID)
+	w.Meta = string(meta)
+	w.HookEvent = ParseHookEvent(f.Webhook)
+	w.IsActive = f.Active
+	if err := w.UpdateEvent(); err != nil {
+		c.Handle(500, "UpdateEvent", err)
+		return
+	} else if err := db.UpdateWebhook(w); err != nil {
+		c.Handle(500, "UpdateWebhook", err)
+		return
+	}
+
+	c.Flash.Success(c.Tr("repo.settings.update_hook_success"))
+	c.Redirect(fmt.Sprintf("%s/%d", orCtx.Link, w.ID))
+}
+
+func TelegramHooksEditPost(c *context.Context, f form.NewTelegramHook) {
+	c.Data["Title"] = c.Tr("repo.settings")
+	c.Data["PageIsSettingsHooks"] = true
+	c.Data["PageIsSettingsHooksEdit"] = true
+
+	orCtx, w := checkWebhook(c)
+	if c.Written() {
+		return
+	}
+	c.Data["Webhook"] = w
+
+	if c.HasError() {
+		c.HTML(200, orCtx.NewTemplate)
+		return
+	} else if !validateHookFilters(c, orCtx.NewTemplate, f.Webhook) {
+		return
+	}
+
+	meta, err := jsoniter.Marshal(&db.TelegramMeta{
+		BotToken: f.BotToken,
+		ChatID:   f.ChatID,
+	})
+	if err != nil {
+		c.Handle(500, "Marshal", err)
+		return
+	}
+
+	w.URL = db.TelegramURL(f.BotToken)
+	w.Meta = string(meta)
+	w.HookEvent = ParseHookEvent(f.Webhook)
+	w.IsActive = f.Active
+	if err := w.UpdateEvent(); err != nil {
+		c.Handle(500, "UpdateEvent", err)
+		return
+	} else if err := db.UpdateWebhook(w); err != nil {
+		c.Handle(500, "UpdateWebhook", err)
+		return
+	}
+
+	c.Flash.Success(c.Tr("repo.settings.update_hook_success"))
+	c.Redirect(fmt.Sprintf("%s/%d", orCtx.Link, w.ID))
+}
+
+// TestPushPayload returns the payload of a push event to the default branch for
+// test deliveries of webhooks of the repository. The commit is the latest commit of
+// the default branch, a fake commit is used if it is nil, i.e. the repository is empty.
+func TestPushPayload(doer *db.User, repo *db.Repository, commit *git.Commit) (*api.PushPayload, error) {
 	var authorUsername, committerUsername string
 
-	// Grab latest commit or fake one if it's empty repository.
-	commit := c.Repo.Commit
 	if commit == nil {
 		ghost := db.NewGhostUser()
 		commit = &git.Commit{
@@ -537,35 +805,32 @@ func TestWebhook(c *context.Context) {
 		if err == nil {
 			authorUsername = author.Name
 		} else if !errors.IsUserNotExist(err) {
-			c.Handle(500, "GetUserByEmail.(author)", err)
-			return
+			return nil, fmt.Errorf("GetUserByEmail.(author): %v", err)
 		}
 
 		committer, err := db.GetUserByEmail(commit.Committer.Email)
 		if err == nil {
 			committerUsername = committer.Name
 		} else if !errors.IsUserNotExist(err) {
-			c.Handle(500, "GetUserByEmail.(committer)", err)
-			return
+			return nil, fmt.Errorf("GetUserByEmail.(committer): %v", err)
 		}
 	}
 
 	fileStatus, err := commit.FileStatus()
 	if err != nil {
-		c.Handle(500, "FileStatus", err)
-		return
+		return nil, fmt.Errorf("FileStatus: %v", err)
 	}
 
-	apiUser := c.User.APIFormat()
-	p := &api.PushPayload{
-		Ref:    git.BRANCH_PREFIX + c.Repo.Repository.DefaultBranch,
+	apiUser := doer.APIFormat()
+	return &api.PushPayload{
+		Ref:    git.BRANCH_PREFIX + repo.DefaultBranch,
 		Before: commit.ID.String(),
 		After:  commit.ID.String(),
 		Commits: []*api.PayloadCommit{
 			{
 				ID:      commit.ID.String(),
 				Message: commit.Message(),
-				URL:     c.Repo.Repository.HTMLURL() + "/commit/" + commit.ID.String(),
+				URL:     repo.HTMLURL() + "/commit/" + commit.ID.String(),
 				Author: &api.PayloadUser{
 					Name:     commit.Author.Name,
 					Email:    commit.Author.Email,
@@ -581,9 +846,17 @@ func TestWebhook(c *context.Context) {
 				Modified: fileStatus.Modified,
 			},
 		},
-		Repo:   c.Repo.Repository.APIFormat(nil),
+		Repo:   repo.APIFormat(nil),
 		Pusher: apiUser,
 		Sender: apiUser,
+	}, nil
+}
+
+func TestWebhook(c *context.Context) {
+	p, err := TestPushPayload(c.User, c.Repo.Repository, c.Repo.Commit)
+	if err != nil {
+		c.Handle(500, "TestPushPayload", err)
+		return
 	}
 	if err := db.TestWebhook(c.Repo.Repository, db.HOOK_EVENT_PUSH, p, c.ParamsInt64("id")); err != nil {
 		c.Handle(500, "TestWebhook", err)
@@ -593,10 +866,18 @@ func TestWebhook(c *context.Context) {
 	}
 }
 
+// RedeliveryWebhook delivers the payload of a previous delivery again as a new
+// delivery, for webhooks of repositories, organizations and the system.
 func RedeliveryWebhook(c *context.Context) {
-	webhook, err := db.GetWebhookOfRepoByID(c.Repo.Repository.ID, c.ParamsInt64(":id"))
+	orCtx, err := getOrgRepoCtx(c)
+	if err != nil {
+		c.Handle(500, "getOrgRepoCtx", err)
+		return
+	}
+
+	webhook, err := getOrgRepoWebhook(orCtx, c.ParamsInt64(":id"))
 	if err != nil {
-		c.NotFoundOrServerError("GetWebhookOfRepoByID/GetWebhookByOrgID", errors.IsWebhookNotExist, err)
+		c.NotFoundOrServerError("getOrgRepoWebhook", errors.IsWebhookNotExist, err)
 		return
 	}
 
@@ -606,14 +887,13 @@ func RedeliveryWebhook(c *context.Context) {
 		return
 	}
 
-	hookTask.IsDelivered = false
-	if err = db.UpdateHookTask(hookTask); err != nil {
-		c.Handle(500, "UpdateHookTask", err)
-	} else {
-		go db.HookQueue.Add(c.Repo.Repository.ID)
-		c.Flash.Info(c.Tr("repo.settings.webhook.redelivery_success", hookTask.UUID))
-		c.Status(200)
+	redelivery, err := db.RedeliverHookTask(hookTask)
+	if err != nil {
+		c.Handle(500, "RedeliverHookTask", err)
+		return
 	}
+	c.Flash.Info(c.Tr("repo.settings.webhook.redelivery_success", redelivery.UUID))
+	c.Status(200)
 }
 
 func DeleteWebhook(c *context.Context) {
@@ -5,25 +5,38 @@
 package repo
 
 import (
+	"fmt"
+	"io"
 	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
 	"strings"
 	"time"
 
 	"github.com/gogs/git-module"
+	log "unknwon.dev/clog/v2"
 
+	"gogs.io/gogs/internal/conf"
 	"gogs.io/gogs/internal/context"
 	"gogs.io/gogs/internal/db"
 	"gogs.io/gogs/internal/form"
+	"gogs.io/gogs/internal/lazyregexp"
 	"gogs.io/gogs/internal/markup"
+	"gogs.io/gogs/internal/tool"
 )
 
 const (
-	WIKI_START = "repo/wiki/start"
-	WIKI_VIEW  = "repo/wiki/view"
-	WIKI_NEW   = "repo/wiki/new"
-	WIKI_PAGES = "repo/wiki/pages"
+	WIKI_START   = "repo/wiki/start"
+	WIKI_VIEW    = "repo/wiki/view"
+	WIKI_NEW     = "repo/wiki/new"
+	WIKI_PAGES   = "repo/wiki/pages"
+	WIKI_HISTORY = "repo/wiki/history"
+	WIKI_COMPARE = "repo/wiki/compare"
 )
 
+var commitIDPattern = lazyregexp.New(`^[0-9a-f]{40}$`)
+
 func MustEnableWiki(c *context.Context) {
 	if !c.Repo.Repository.EnableWiki {
 		c.Handle(404, "MustEnableWiki", nil)
@@ -37,11 +50,36 @@ func MustEnableWiki(c *context.Context) {
 }
 
 type PageMeta struct {
-	Name    string
-	URL     string
-	Updated time.Time
+	Name     string
+	BaseName string
+	URL      string
+	Updated  time.Time
+	Depth    int
+	IsDir    bool
+}
+
+// renderWikiBreadcrumbs sets breadcrumbs of the wiki page that are directories of
+// the page, a directory is linked to the page with the same title if exists.
+func renderWikiBreadcrumbs(c *context.Context, commit *git.Commit, pageName string) {
+	dirs := strings.Split(pageName, "/")
+	dirs = dirs[:len(dirs)-1]
+	breadcrumbs := make([]PageMeta, len(dirs))
+	for i := range dirs {
+		name := strings.Join(dirs[:i+1], "/")
+		breadcrumbs[i] = PageMeta{
+			Name:     name,
+			BaseName: dirs[i],
+		}
+		if _, err := db.FindWikiPageFilename(commit, name); err == nil {
+			breadcrumbs[i].URL = db.ToWikiPageURL(name)
+		}
+	}
+	c.Data["Breadcrumbs"] = breadcrumbs
+	c.Data["PageBaseName"] = path.Base(pageName)
 }
 
+// renderWikiPage renders the wiki page of current request, and returns the wiki
+// repository and the file name of the page.
 func renderWikiPage(c *context.Context, isViewPage bool) (*git.Repository, string) {
 	wikiRepo, err := git.OpenRepository(c.Repo.Repository.WikiPath())
 	if err != nil {
@@ -56,20 +94,20 @@ func renderWikiPage(c *context.Context, isViewPage bool) (*git.Repository, strin
 
 	// Get page list.
 	if isViewPage {
-		entries, err := commit.ListEntries()
+		entries, err := db.ListWikiPages(commit)
 		if err != nil {
-			c.Handle(500, "ListEntries", err)
+			c.Handle(500, "ListWikiPages", err)
 			return nil, ""
 		}
 		pages := make([]PageMeta, 0, len(entries))
-		for i := range entries {
-			if entries[i].Type == git.OBJECT_BLOB && strings.HasSuffix(entries[i].Name(), ".md") {
-				name := strings.TrimSuffix(entries[i].Name(), ".md")
-				pages = append(pages, PageMeta{
-					Name: name,
-					URL:  db.ToWikiPageURL(name),
-				})
+		for _, e := range entries {
+			if e.IsDir {
+				continue
 			}
+			pages = append(pages, PageMeta{
+				Name: e.Title,
+				URL:  db.ToWikiPageURL(e.Title),
+			})
 		}
 		c.Data["Pages"] = pages
 	}
@@ -78,23 +116,28 @@ func renderWikiPage(c *context.Context, isViewPage bool) (*git.Repository, strin
 	if len(pageURL) == 0 {
 		pageURL = "Home"
 	}
-	c.Data["PageURL"] = pageURL
 
 	pageName := db.ToWikiPageName(pageURL)
+	c.Data["PageURL"] = db.ToWikiPageURL(pageName)
 	c.Data["old_title"] = pageName
 	c.Data["Title"] = pageName
 	c.Data["title"] = pageName
 	c.Data["RequireHighlightJS"] = true
 
-	blob, err := commit.GetBlobByPath(pageName + ".md")
+	filename, err := db.FindWikiPageFilename(commit, pageName)
 	if err != nil {
 		if git.IsErrNotExist(err) {
 			c.Redirect(c.Repo.RepoLink + "/wiki/_pages")
 		} else {
-			c.Handle(500, "GetBlobByPath", err)
+			c.Handle(500, "FindWikiPageFilename", err)
 		}
 		return nil, ""
 	}
+	blob, err := commit.GetBlobByPath(filename)
+	if err != nil {
+		c.Handle(500, "GetBlobByPath", err)
+		return nil, ""
+	}
 	r, err := blob.Data()
 	if err != nil {
 		c.Handle(500, "Data", err)
@@ -106,12 +149,14 @@ func renderWikiPage(c *context.Context, isViewPage bool) (*git.Repository, strin
 		return nil, ""
 	}
 	if isViewPage {
-		c.Data["content"] = string(markup.Markdown(data, c.Repo.RepoLink, c.Repo.Repository.ComposeMetas()))
+		c.Data["content"] = string(markup.Render(markup.Detect(filename), data, c.Repo.RepoLink, c.Repo.Repository.ComposeMetas()))
+		renderWikiBreadcrumbs(c, commit, pageName)
 	} else {
 		c.Data["content"] = string(data)
+		c.Data["format"] = path.Ext(filename)
 	}
 
-	return wikiRepo, pageName
+	return wikiRepo, filename
 }
 
 func Wiki(c *context.Context) {
@@ -123,13 +168,13 @@ func Wiki(c *context.Context) {
 		return
 	}
 
-	wikiRepo, pageName := renderWikiPage(c, true)
+	wikiRepo, filename := renderWikiPage(c, true)
 	if c.Written() {
 		return
 	}
 
 	// Get last change information.
-	lastCommit, err := wikiRepo.GetCommitByPath(pageName + ".md")
+	lastCommit, err := wikiRepo.GetCommitByPath(filename)
 	if err != nil {
 		c.Handle(500, "GetCommitByPath", err)
 		return
@@ -159,36 +204,63 @@ func WikiPages(c *context.Context) {
 		return
 	}
 
-	entries, err := commit.ListEntries()
+	entries, err := db.ListWikiPages(commit)
 	if err != nil {
-		c.Handle(500, "ListEntries", err)
+		c.ServerError("ListWikiPages", err)
 		return
 	}
 	pages := make([]PageMeta, 0, len(entries))
-	for i := range entries {
-		if entries[i].Type == git.OBJECT_BLOB && strings.HasSuffix(entries[i].Name(), ".md") {
-			commit, err := wikiRepo.GetCommitByPath(entries[i].Name())
+	for _, e := range entries {
+		page := PageMeta{
+			Name:     e.Title,
+			BaseName: e.Name,
+			Depth:    e.Depth,
+			IsDir:    e.IsDir,
+		}
+		if !e.IsDir {
+			commit, err := wikiRepo.GetCommitByPath(e.Filename)
 			if err != nil {
 				c.ServerError("GetCommitByPath", err)
 				return
 			}
-			name := strings.TrimSuffix(entries[i].Name(), ".md")
-			pages = append(pages, PageMeta{
-				Name:    name,
-				URL:     db.ToWikiPageURL(name),
-				Updated: commit.Author.When,
-			})
+			page.URL = db.ToWikiPageURL(e.Title)
+			page.Updated = commit.Author.When
 		}
+		pages = append(pages, page)
 	}
 	c.Data["Pages"] = pages
 
 	c.HTML(200, WIKI_PAGES)
 }
 
+func ExportWiki(c *context.Context) {
+	if !c.Repo.Repository.HasWiki() {
+		c.NotFound()
+		return
+	}
+
+	repo := c.Repo.Repository
+	c.Header().Set("Content-Type", "application/zip")
+	c.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-wiki-%s.zip"`, repo.Name, time.Now().Format("20060102150405")))
+	if err := repo.ExportWiki(c.Resp); err != nil {
+		log.Error("Failed to export wiki of repository %d: %v", repo.ID, err)
+	}
+}
+
+// renderWikiEditor sets data for the editor of wiki pages.
+func renderWikiEditor(c *context.Context) {
+	c.Data["RequireSimpleMDE"] = true
+	c.Data["WikiPageFormats"] = db.WikiPageFormats
+	if _, ok := c.Data["format"]; !ok {
+		c.Data["format"] = db.WikiPageFormats[0].Extension
+	}
+	renderAttachmentSettings(c)
+}
+
 func NewWiki(c *context.Context) {
 	c.Data["Title"] = c.Tr("repo.wiki.new_page")
 	c.Data["PageIsWiki"] = true
-	c.Data["RequireSimpleMDE"] = true
+	renderWikiEditor(c)
 
 	if !c.Repo.Repository.HasWiki() {
 		c.Data["title"] = "Home"
@@ -200,14 +272,14 @@ func NewWiki(c *context.Context) {
 func NewWikiPost(c *context.Context, f form.NewWiki) {
 	c.Data["Title"] = c.Tr("repo.wiki.new_page")
 	c.Data["PageIsWiki"] = true
-	c.Data["RequireSimpleMDE"] = true
+	renderWikiEditor(c)
 
 	if c.HasError() {
 		c.HTML(200, WIKI_NEW)
 		return
 	}
 
-	if err := c.Repo.Repository.AddWikiPage(c.User, f.Title, f.Content, f.Message); err != nil {
+	if err := c.Repo.Repository.AddWikiPage(c.User, f.Title, f.Format, f.Content, f.Message); err != nil {
 		if db.IsErrWikiAlreadyExist(err) {
 			c.Data["Err_Title"] = true
 			c.RenderWithErr(c.Tr("repo.wiki.page_already_exists"), WIKI_NEW, &f)
@@ -223,7 +295,7 @@ func NewWikiPost(c *context.Context, f form.NewWiki) {
 func EditWiki(c *context.Context) {
 	c.Data["PageIsWiki"] = true
 	c.Data["PageIsWikiEdit"] = true
-	c.Data["RequireSimpleMDE"] = true
+	renderWikiEditor(c)
 
 	if !c.Repo.Repository.HasWiki() {
 		c.Redirect(c.Repo.RepoLink + "/wiki")
@@ -241,14 +313,15 @@ func EditWiki(c *context.Context) {
 func EditWikiPost(c *context.Context, f form.NewWiki) {
 	c.Data["Title"] = c.Tr("repo.wiki.new_page")
 	c.Data["PageIsWiki"] = true
-	c.Data["RequireSimpleMDE"] = true
+	c.Data["PageURL"] = db.ToWikiPageURL(db.ToWikiPageName(c.Params(":page")))
+	renderWikiEditor(c)
 
 	if c.HasError() {
 		c.HTML(200, WIKI_NEW)
 		return
 	}
 
-	if err := c.Repo.Repository.EditWikiPage(c.User, f.OldTitle, f.Title, f.Content, f.Message); err != nil {
+	if err := c.Repo.Repository.EditWikiPage(c.User, f.OldTitle, f.Title, f.Format, f.Content, f.Message); err != nil {
 		c.Handle(500, "EditWikiPage", err)
 		return
 	}
@@ -272,3 +345,235 @@ func DeleteWikiPagePost(c *context.Context) {
 		"redirect": c.Repo.RepoLink + "/wiki/",
 	})
 }
+
+func WikiHistory(c *context.Context) {
+	c.Data["PageIsWiki"] = true
+
+	if !c.Repo.Repository.HasWiki() {
+		c.Redirect(c.Repo.RepoLink + "/wiki")
+		return
+	}
+
+	wikiRepo, err := git.OpenRepository(c.Repo.Repository.WikiPath())
+	if err != nil {
+		c.ServerError("OpenRepository", err)
+		return
+	}
+
+	pageName := db.ToWikiPageName(c.Params(":page"))
+	c.Data["Title"] = pageName + " · " + c.Tr("repo.wiki.history")
+	c.Data["title"] = pageName
+	c.Data["PageURL"] = db.ToWikiPageURL(pageName)
+
+	page := c.QueryInt("page")
+	if page < 1 {
+		page = 1
+	}
+	commit, err := wikiRepo.GetBranchCommit("master")
+	if err != nil {
+		c.ServerError("GetBranchCommit", err)
+		return
+	}
+	filename, err := db.FindWikiPageFilename(commit, pageName)
+	if err != nil {
+		c.NotFoundOrServerError("FindWikiPageFilename", git.IsErrNotExist, err)
+		return
+	}
+
+	commits, err := wikiRepo.CommitsByFileAndRange("master", filename, page)
+	if err != nil {
+		c.ServerError("CommitsByFileAndRange", err)
+		return
+	} else if commits.Len() == 0 && page == 1 {
+		c.NotFound()
+		return
+	}
+	c.Data["Commits"] = db.ValidateCommitsWithEmails(commits)
+	c.Data["IsFirstPage"] = page == 1
+
+	if page > 1 {
+		c.Data["HasPrevious"] = true
+		c.Data["PreviousPage"] = page - 1
+	}
+	if commits.Len() == git.DefaultCommitsPageSize {
+		c.Data["HasNext"] = true
+		c.Data["NextPage"] = page + 1
+	}
+
+	c.HTML(200, WIKI_HISTORY)
+}
+
+func WikiCompare(c *context.Context) {
+	c.Data["PageIsWiki"] = true
+
+	if !c.Repo.Repository.HasWiki() {
+		c.Redirect(c.Repo.RepoLink + "/wiki")
+		return
+	}
+
+	pageName := db.ToWikiPageName(c.Params(":page"))
+	pageURL := db.ToWikiPageURL(pageName)
+
+	// Revisions selected in the history page are given as query parameters.
+	if after := c.Query("after"); after != "" {
+		link := c.Repo.RepoLink + "/wiki/" + pageURL + "/_compare/"
+		if before := c.Query("before"); before != "" && before != after {
+			link += before + "..."
+		}
+		c.Redirect(link + after)
+		return
+	}
+
+	beforeCommitID := c.Params(":before")
+	afterCommitID := c.Params(":after")
+	if afterCommitID == "" {
+		afterCommitID = c.Params(":sha")
+	}
+
+	wikiRepo, err := git.OpenRepository(c.Repo.Repository.WikiPath())
+	if err != nil {
+		c.ServerError("OpenRepository", err)
+		return
+	}
+	commit, err := wikiRepo.GetCommit(afterCommitID)
+	if err != nil {
+		c.NotFoundOrServerError("GetCommit", git.IsErrNotExist, err)
+		return
+	}
+
+	diff, err := c.Repo.Repository.GetWikiPageDiff(pageName, beforeCommitID, afterCommitID)
+	if err != nil {
+		c.NotFoundOrServerError("GetWikiPageDiff", git.IsErrNotExist, err)
+		return
+	}
+
+	c.Data["Title"] = pageName + " · " + c.Tr("repo.wiki.history")
+	c.Data["title"] = pageName
+	c.Data["PageURL"] = pageURL
+	c.Data["Commit"] = commit
+	c.Data["BeforeCommitID"] = beforeCommitID
+	c.Data["AfterCommitID"] = afterCommitID
+	c.Data["Diff"] = diff
+	c.Data["DiffNotAvailable"] = diff.NumFiles() == 0
+	c.Data["IsSplitStyle"] = c.Query("style") == "split"
+	c.Data["IsImageFile"] = commit.IsImageFile
+	c.Data["RequireHighlightJS"] = true
+	c.HTML(200, WIKI_COMPARE)
+}
+
+func RevertWikiPagePost(c *context.Context) {
+	pageName := db.ToWikiPageName(c.Params(":page"))
+
+	commitID := c.Query("commit_id")
+	if !commitIDPattern.MatchString(commitID) {
+		c.NotFound()
+		return
+	}
+
+	if err := c.Repo.Repository.RevertWikiPage(c.User, pageName, commitID); err != nil {
+		c.NotFoundOrServerError("RevertWikiPage", git.IsErrNotExist, err)
+		return
+	}
+
+	c.Flash.Success(c.Tr("repo.wiki.revert_success", tool.ShortSHA1(commitID)))
+	c.JSON(200, map[string]interface{}{
+		"redirect": c.Repo.RepoLink + "/wiki/" + db.ToWikiPageURL(pageName),
+	})
+}
+
+func UploadWikiFile(c *context.Context) {
+	if !conf.AttachmentEnabled {
+		c.NotFound()
+		return
+	}
+
+	file, header, err := c.Req.FormFile("file")
+	if err != nil {
+		c.Error(http.StatusBadRequest, fmt.Sprintf("FormFile: %v", err))
+		return
+	}
+	defer file.Close()
+
+	if header.Size > conf.AttachmentMaxSize*tool.MByte {
+		c.Error(http.StatusBadRequest, c.Tr("repo.wiki.file_too_large", conf.AttachmentMaxSize))
+		return
+	}
+
+	buf := make([]byte, 1024)
+	n, _ := file.Read(buf)
+	fileType := http.DetectContentType(buf[:n])
+
+	allowed := false
+	for _, t := range strings.Split(conf.AttachmentAllowedTypes, ",") {
+		t := strings.Trim(t, " ")
+		if t == "*/*" || t == fileType {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		c.Error(http.StatusBadRequest, ErrFileTypeForbidden.Error())
+		return
+	}
+
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		c.Error(http.StatusInternalServerError, fmt.Sprintf("Seek: %v", err))
+		return
+	}
+
+	treePath, err := c.Repo.Repository.UploadWikiFile(c.User, header.Filename, file)
+	if err != nil {
+		c.ServerError("UploadWikiFile", err)
+		return
+	}
+
+	link := (&url.URL{Path: c.Repo.RepoLink + "/wiki/_raw/" + treePath}).String()
+	name := strings.NewReplacer("[", "", "]", "").Replace(path.Base(treePath))
+	markdown := "[" + name + "](" + link + ")"
+	if strings.HasPrefix(fileType, "image/") {
+		markdown = "!" + markdown
+	}
+	c.JSONSuccess(map[string]string{
+		"link":     link,
+		"markdown": markdown,
+	})
+}
+
+func WikiRaw(c *context.Context) {
+	if !c.Repo.Repository.HasWiki() {
+		c.NotFound()
+		return
+	}
+
+	wikiRepo, err := git.OpenRepository(c.Repo.Repository.WikiPath())
+	if err != nil {
+		c.ServerError("OpenRepository", err)
+		return
+	}
+	commit, err := wikiRepo.GetBranchCommit("master")
+	if err != nil {
+		c.ServerError("GetBranchCommit", err)
+		return
+	}
+
+	treePath := c.Params("*")
+	blob, err := commit.GetBlobByPath(treePath)
+	if err != nil {
+		c.NotFoundOrServerError("GetBlobByPath", git.IsErrNotExist, err)
+		return
+	}
+	lastCommit, err := commit.GetCommitByPath(treePath)
+	if err != nil {
+		c.ServerError("GetCommitByPath", err)
+		return
+	}
+	r, err := blob.Data()
+	if err != nil {
+		c.ServerError("Data", err)
+		return
+	}
+
+	if err = serveContent(c, path.Base(treePath), lastCommit.Committer.When, r); err != nil {
+		c.ServerError("serveContent", err)
+	}
+}
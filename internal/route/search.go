@@ -0,0 +1,111 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package route
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/unknwon/paginater"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/db/errors"
+	"gogs.io/gogs/internal/template/highlight"
+)
+
+const (
+	SEARCH_CODE = "explore/code"
+)
+
+// Search serves searches of all types, only code search is rendered here and other
+// types are redirected to the explore pages.
+func Search(c *context.Context) {
+	switch c.Query("type") {
+	case "repositories":
+		c.Redirect(conf.Server.Subpath + "/explore/repos?q=" + url.QueryEscape(c.Query("q")))
+	case "users":
+		c.Redirect(conf.Server.Subpath + "/explore/users?q=" + url.QueryEscape(c.Query("q")))
+	case "organizations":
+		c.Redirect(conf.Server.Subpath + "/explore/organizations?q=" + url.QueryEscape(c.Query("q")))
+	default:
+		SearchCode(c)
+	}
+}
+
+type codeSearchResult struct {
+	*db.CodeSearchResult
+	HighlightClass string
+}
+
+func SearchCode(c *context.Context) {
+	if !db.IsCodeIndexerEnabled() {
+		c.NotFound()
+		return
+	}
+
+	c.Data["Title"] = c.Tr("explore")
+	c.Data["PageIsExplore"] = true
+	c.Data["PageIsExploreCode"] = true
+	c.Data["RequireHighlightJS"] = true
+
+	page := c.QueryInt("page")
+	if page <= 0 {
+		page = 1
+	}
+	keyword := strings.TrimSpace(c.Query("q"))
+	language := c.Query("lang")
+	repoName := strings.TrimSpace(c.Query("repo"))
+	c.Data["Keyword"] = keyword
+	c.Data["Language"] = language
+	c.Data["RepoName"] = repoName
+
+	var repoID int64
+	if repoName != "" {
+		repo, err := db.GetRepositoryByRef(repoName)
+		if err != nil {
+			if !errors.IsRepoNotExist(err) && !errors.IsUserNotExist(err) && !errors.IsInvalidRepoReference(err) {
+				c.ServerError("GetRepositoryByRef", err)
+				return
+			}
+			c.Success(SEARCH_CODE)
+			return
+		}
+		repoID = repo.ID
+	}
+
+	if keyword == "" {
+		c.Success(SEARCH_CODE)
+		return
+	}
+
+	results, count, languages, err := db.SearchCode(&db.SearchCodeOptions{
+		Keyword:  keyword,
+		UserID:   c.UserID(),
+		RepoID:   repoID,
+		Language: language,
+		Page:     page,
+		PageSize: conf.UI.ExplorePagingNum,
+	})
+	if err != nil {
+		c.ServerError("SearchCode", err)
+		return
+	}
+
+	codeResults := make([]*codeSearchResult, len(results))
+	for i := range results {
+		codeResults[i] = &codeSearchResult{
+			CodeSearchResult: results[i],
+			HighlightClass:   highlight.FileNameToHighlightClass(results[i].Path),
+		}
+	}
+	c.Data["Results"] = codeResults
+	c.Data["Languages"] = languages
+	c.Data["Total"] = count
+	c.Data["Page"] = paginater.New(int(count), conf.UI.ExplorePagingNum, page, 5)
+
+	c.Success(SEARCH_CODE)
+}
@@ -0,0 +1,172 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package route
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+)
+
+// Maximum number of URLs in one sitemap allowed by the protocol.
+const maxSitemapURLs = 50000
+
+type sitemapIndex struct {
+	XMLName  xml.Name      `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+	Sitemaps []*sitemapURL `xml:"sitemap"`
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name      `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []*sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	Lastmod string `xml:"lastmod,omitempty"`
+}
+
+func newSitemapURL(loc string, lastmod time.Time) *sitemapURL {
+	u := &sitemapURL{Loc: loc}
+	if !lastmod.IsZero() {
+		u.Lastmod = lastmod.UTC().Format(time.RFC3339)
+	}
+	return u
+}
+
+func renderXML(c *context.Context, v interface{}) {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		c.ServerError("xml.Marshal", err)
+		return
+	}
+	c.Resp.Header().Set("Content-Type", "application/xml; charset=UTF-8")
+	c.Resp.WriteHeader(http.StatusOK)
+	_, _ = c.Resp.Write([]byte(xml.Header))
+	_, _ = c.Resp.Write(data)
+}
+
+func sitemapPagingNum() int {
+	num := conf.SearchEngine.SitemapPagingNum
+	if num <= 0 || num > maxSitemapURLs {
+		return maxSitemapURLs
+	}
+	return num
+}
+
+// SitemapIndex renders sitemap index that links to all pages of sitemaps
+// of users, repositories and releases.
+func SitemapIndex(c *context.Context) {
+	pageSize := int64(sitemapPagingNum())
+	index := new(sitemapIndex)
+	for _, typ := range []struct {
+		name  string
+		count func() (int64, error)
+	}{
+		{"users", db.CountSitemapUsers},
+		{"repos", db.CountSitemapRepositories},
+		{"releases", db.CountSitemapReleaseRepositories},
+	} {
+		count, err := typ.count()
+		if err != nil {
+			c.ServerError("count "+typ.name, err)
+			return
+		}
+		for page := int64(1); page <= (count+pageSize-1)/pageSize; page++ {
+			index.Sitemaps = append(index.Sitemaps,
+				newSitemapURL(fmt.Sprintf("%ssitemap/%s/%d", conf.Server.ExternalURL, typ.name, page), time.Time{}))
+		}
+	}
+	renderXML(c, index)
+}
+
+// Sitemap renders a page of sitemap of given type.
+func Sitemap(c *context.Context) {
+	page := c.ParamsInt(":page")
+	if page <= 0 {
+		c.NotFound()
+		return
+	}
+	pageSize := sitemapPagingNum()
+
+	set := new(sitemapURLSet)
+	switch c.Params(":type") {
+	case "users":
+		users, err := db.GetSitemapUsers(page, pageSize)
+		if err != nil {
+			c.ServerError("GetSitemapUsers", err)
+			return
+		}
+		for _, u := range users {
+			set.URLs = append(set.URLs, newSitemapURL(u.HTMLURL(), u.Updated))
+		}
+	case "repos":
+		repos, err := db.GetSitemapRepositories(page, pageSize)
+		if err != nil {
+			c.ServerError("GetSitemapRepositories", err)
+			return
+		}
+		for _, repo := range repos {
+			set.URLs = append(set.URLs, newSitemapURL(repo.HTMLURL(), repo.Updated))
+		}
+	case "releases":
+		releases, err := db.GetSitemapReleases(page, pageSize)
+		if err != nil {
+			c.ServerError("GetSitemapReleases", err)
+			return
+		}
+		for _, r := range releases {
+			set.URLs = append(set.URLs, newSitemapURL(r.Repo.HTMLURL()+"/releases", r.Updated))
+		}
+	}
+
+	if len(set.URLs) == 0 {
+		c.NotFound()
+		return
+	}
+	renderXML(c, set)
+}
+
+// RobotsTxt serves "custom/robots.txt" if exists, otherwise generates one according
+// to search engine settings.
+func RobotsTxt(c *context.Context) {
+	if conf.HasRobotsTxt {
+		c.ServeFileContent(filepath.Join(conf.CustomDir(), "robots.txt"))
+		return
+	}
+
+	var buf strings.Builder
+	buf.WriteString("User-agent: *\n")
+	if !conf.SearchEngine.EnableIndexing {
+		buf.WriteString("Disallow: /\n")
+		c.PlainText(http.StatusOK, []byte(buf.String()))
+		return
+	}
+
+	// Pages that are costly to generate and useless for search results.
+	for _, path := range []string{
+		"/*/*/archive/",
+		"/*/*/raw/",
+		"/*/*/commit/",
+		"/*/*/commits/",
+		"/*/*/compare/",
+	} {
+		buf.WriteString("Disallow: " + conf.Server.Subpath + path + "\n")
+	}
+	if conf.SearchEngine.CrawlDelay > 0 {
+		fmt.Fprintf(&buf, "Crawl-delay: %d\n", conf.SearchEngine.CrawlDelay)
+	}
+	if conf.SearchEngine.EnableSitemap {
+		buf.WriteString("\nSitemap: " + conf.Server.ExternalURL + "sitemap.xml\n")
+	}
+	c.PlainText(http.StatusOK, []byte(buf.String()))
+}
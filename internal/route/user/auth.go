@@ -24,6 +24,7 @@ const (
 	LOGIN                    = "user/auth/login"
 	TWO_FACTOR               = "user/auth/two_factor"
 	TWO_FACTOR_RECOVERY_CODE = "user/auth/two_factor_recovery_code"
+	REACTIVATE               = "user/auth/reactivate"
 	SIGNUP                   = "user/auth/signup"
 	ACTIVATE                 = "user/auth/activate"
 	FORGOT_PASSWORD          = "user/auth/forgot_passwd"
@@ -182,16 +183,80 @@ func LoginPost(c *context.Context, f form.SignIn) {
 		return
 	}
 
+	if u.IsDeactivated {
+		c.Session.Set("reactivateRemember", f.Remember)
+		c.Session.Set("reactivateUserID", u.ID)
+		c.SubURLRedirect("/user/login/reactivate")
+		return
+	}
+
+	continueLogin(c, u, f.Remember)
+}
+
+// continueLogin signs in the user who has passed password authentication, or asks
+// for passcode first if two-factor authentication is enabled.
+func continueLogin(c *context.Context, u *db.User, remember bool) {
 	if !u.IsEnabledTwoFactor() {
-		afterLogin(c, u, f.Remember)
+		afterLogin(c, u, remember)
 		return
 	}
 
-	c.Session.Set("twoFactorRemember", f.Remember)
+	c.Session.Set("twoFactorRemember", remember)
 	c.Session.Set("twoFactorUserID", u.ID)
 	c.SubURLRedirect("/user/login/two_factor")
 }
 
+// reactivateUser returns the deactivated user who has passed password authentication
+// and is asked to reactivate the account.
+func reactivateUser(c *context.Context) *db.User {
+	userID, ok := c.Session.Get("reactivateUserID").(int64)
+	if !ok {
+		c.NotFound()
+		return nil
+	}
+
+	u, err := db.GetUserByID(userID)
+	if err != nil {
+		c.ServerError("GetUserByID", err)
+		return nil
+	}
+	return u
+}
+
+func LoginReactivate(c *context.Context) {
+	u := reactivateUser(c)
+	if c.Written() {
+		return
+	}
+
+	c.Title("auth.reactivate")
+	c.Data["Deactivated"] = u.Deactivated
+	c.Data["CanReactivate"] = u.CanReactivate()
+	c.Success(REACTIVATE)
+}
+
+func LoginReactivatePost(c *context.Context) {
+	u := reactivateUser(c)
+	if c.Written() {
+		return
+	}
+
+	if err := db.ReactivateUser(u, u); err != nil {
+		if errors.IsUserReactivationExpired(err) {
+			c.SubURLRedirect("/user/login/reactivate")
+		} else {
+			c.ServerError("ReactivateUser", err)
+		}
+		return
+	}
+	log.Trace("Account reactivated: %s", u.Name)
+
+	remember, _ := c.Session.Get("reactivateRemember").(bool)
+	c.Session.Delete("reactivateRemember")
+	c.Session.Delete("reactivateUserID")
+	continueLogin(c, u, remember)
+}
+
 func LoginTwoFactor(c *context.Context) {
 	_, ok := c.Session.Get("twoFactorUserID").(int64)
 	if !ok {
@@ -53,7 +53,13 @@ func getDashboardContextUser(c *context.Context) *db.User {
 // The user could be organization so it is not always the logged in user,
 // which is why we have to explicitly pass the context user ID.
 func retrieveFeeds(c *context.Context, ctxUser *db.User, userID int64, isProfile bool) {
-	actions, err := db.GetFeeds(ctxUser, userID, c.QueryInt64("after_id"), isProfile)
+	feedType := c.Query("type")
+	if !db.IsValidActivityType(feedType) {
+		feedType = ""
+	}
+	c.Data["FeedType"] = feedType
+
+	actions, err := db.GetFeeds(ctxUser, userID, c.QueryInt64("after_id"), isProfile, feedType)
 	if err != nil {
 		c.Handle(500, "GetFeeds", err)
 		return
@@ -84,7 +90,11 @@ func retrieveFeeds(c *context.Context, ctxUser *db.User, userID int64, isProfile
 	if len(feeds) > 0 {
 		afterID := feeds[len(feeds)-1].ID
 		c.Data["AfterID"] = afterID
-		c.Header().Set("X-AJAX-URL", fmt.Sprintf("%s?after_id=%d", c.Data["Link"], afterID))
+		ajaxURL := fmt.Sprintf("%s?after_id=%d", c.Data["Link"], afterID)
+		if feedType != "" {
+			ajaxURL += "&type=" + feedType
+		}
+		c.Header().Set("X-AJAX-URL", ajaxURL)
 	}
 }
 
@@ -108,6 +118,25 @@ func Dashboard(c *context.Context) {
 	c.Data["PageIsDashboard"] = true
 	c.Data["PageIsNews"] = true
 
+	if !ctxUser.IsOrganization() && conf.Onboarding.Enabled {
+		o, err := db.GetOnboarding(c.User.ID)
+		if err != nil {
+			if !errors.IsOnboardingNotExist(err) {
+				c.ServerError("GetOnboarding", err)
+				return
+			}
+		} else if o.IsActive() {
+			loadOnboardingChecklist(c, o)
+			if c.Written() {
+				return
+			}
+			// The onboarding could be just completed by loading the checklist.
+			if !o.IsActive() {
+				delete(c.Data, "Onboarding")
+			}
+		}
+	}
+
 	// Only user can have collaborative repositories.
 	if !ctxUser.IsOrganization() {
 		collaborateRepos, err := c.User.GetAccessibleRepositories(conf.UI.User.RepoPagingNum)
@@ -261,11 +290,13 @@ func Issues(c *context.Context) {
 	}
 
 	issueOptions := &db.IssuesOptions{
-		RepoID:   repoID,
-		Page:     page,
-		IsClosed: isShowClosed,
-		IsPull:   isPullList,
-		SortType: sortType,
+		UserID:           c.User.ID,
+		RepoID:           repoID,
+		Page:             page,
+		IsClosed:         isShowClosed,
+		IsPull:           isPullList,
+		SortType:         sortType,
+		HideConfidential: !c.User.IsAdmin,
 	}
 	switch filterMode {
 	case db.FILTER_MODE_YOUR_REPOS:
@@ -317,7 +348,11 @@ func Issues(c *context.Context) {
 		}
 	}
 
-	issueStats := db.GetUserIssueStats(repoID, ctxUser.ID, userRepoIDs, filterMode, isPullList)
+	var viewer *db.User
+	if !c.User.IsAdmin {
+		viewer = c.User
+	}
+	issueStats := db.GetUserIssueStats(repoID, ctxUser.ID, userRepoIDs, filterMode, isPullList, viewer)
 
 	var total int
 	if !isShowClosed {
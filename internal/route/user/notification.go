@@ -0,0 +1,72 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package user
+
+import (
+	"github.com/unknwon/paginater"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+)
+
+const NOTIFICATIONS = "user/notifications"
+
+func Notifications(c *context.Context) {
+	c.Title("notification.title")
+	c.PageIs("Notifications")
+
+	status := db.ParseNotificationStatus(c.Query("status"))
+	if status == 0 {
+		status = db.NOTIFICATION_STATUS_UNREAD
+	}
+	page := c.QueryInt("page")
+	if page <= 0 {
+		page = 1
+	}
+
+	notifications, count, err := db.Notifications(&db.NotificationsOptions{
+		UserID:   c.User.ID,
+		Statuses: []db.NotificationStatus{status},
+		Page:     page,
+		PageSize: conf.UI.IssuePagingNum,
+	})
+	if err != nil {
+		c.ServerError("Notifications", err)
+		return
+	}
+	c.Data["Notifications"] = notifications
+	c.Data["Status"] = status.Name()
+	c.Data["Page"] = paginater.New(int(count), conf.UI.IssuePagingNum, page, 5)
+
+	c.Success(NOTIFICATIONS)
+}
+
+// NotificationStatusPost changes status of a notification, or all unread
+// notifications to read when no notification is specified.
+func NotificationStatusPost(c *context.Context) {
+	redirectTo := conf.Server.Subpath + "/notifications?status=" + c.Query("status")
+
+	status := db.ParseNotificationStatus(c.Query("to"))
+	if status == 0 {
+		c.Redirect(redirectTo)
+		return
+	}
+
+	var err error
+	if id := c.QueryInt64("id"); id > 0 {
+		err = db.SetNotificationStatus(c.User.ID, id, status)
+	} else {
+		err = db.SetNotificationsStatus(&db.NotificationsOptions{
+			UserID:   c.User.ID,
+			Statuses: []db.NotificationStatus{db.NOTIFICATION_STATUS_UNREAD},
+		}, db.NOTIFICATION_STATUS_READ)
+	}
+	if err != nil {
+		c.ServerError("set notification status", err)
+		return
+	}
+	c.Redirect(redirectTo)
+}
@@ -0,0 +1,94 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package user
+
+import (
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/db/errors"
+	"gogs.io/gogs/internal/markup"
+)
+
+const ONBOARDING = "user/onboarding"
+
+// getOnboarding returns the onboarding state of the signed in user, it renders
+// not found page if onboarding is disabled or the user does not have one.
+func getOnboarding(c *context.Context) *db.Onboarding {
+	if !conf.Onboarding.Enabled {
+		c.NotFound()
+		return nil
+	}
+
+	o, err := db.GetOnboarding(c.User.ID)
+	if err != nil {
+		c.NotFoundOrServerError("GetOnboarding", errors.IsOnboardingNotExist, err)
+		return nil
+	}
+	return o
+}
+
+// loadOnboardingChecklist loads the onboarding checklist of the signed in user to context data.
+func loadOnboardingChecklist(c *context.Context, o *db.Onboarding) {
+	steps, err := db.GetOnboardingChecklist(c.User, o)
+	if err != nil {
+		c.ServerError("GetOnboardingChecklist", err)
+		return
+	}
+
+	completed := 0
+	for _, step := range steps {
+		if step.Completed {
+			completed++
+		}
+	}
+	c.Data["Onboarding"] = o
+	c.Data["OnboardingSteps"] = steps
+	c.Data["OnboardingCompleted"] = completed
+}
+
+func Onboarding(c *context.Context) {
+	c.Title("onboarding.title")
+	o := getOnboarding(c)
+	if c.Written() {
+		return
+	}
+
+	loadOnboardingChecklist(c, o)
+	if c.Written() {
+		return
+	}
+	c.Data["WelcomeTitle"] = conf.Onboarding.WelcomeTitle
+	c.Data["WelcomeDescription"] = string(markup.Markdown(conf.Onboarding.WelcomeDescription, "", nil))
+	c.Data["WelcomeLink"] = conf.Onboarding.WelcomeLink
+	c.Success(ONBOARDING)
+}
+
+func OnboardingCompletePost(c *context.Context) {
+	getOnboarding(c)
+	if c.Written() {
+		return
+	}
+
+	if err := db.CompleteOnboardingStep(c.User.ID, c.Query("step")); err != nil {
+		c.NotFoundOrServerError("CompleteOnboardingStep", errors.IsInvalidOnboardingStep, err)
+		return
+	}
+	c.SubURLRedirect("/user/onboarding")
+}
+
+func OnboardingDismissPost(c *context.Context) {
+	o := getOnboarding(c)
+	if c.Written() {
+		return
+	}
+
+	if err := db.DismissOnboarding(o); err != nil {
+		c.ServerError("DismissOnboarding", err)
+		return
+	}
+	c.Flash.Info(c.Tr("onboarding.dismissed"))
+	c.SubURLRedirect("/")
+}
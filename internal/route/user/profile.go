@@ -13,6 +13,7 @@ import (
 
 	"gogs.io/gogs/internal/context"
 	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/db/errors"
 	"gogs.io/gogs/internal/conf"
 	"gogs.io/gogs/internal/tool"
 )
@@ -69,6 +70,7 @@ func Profile(c *context.Context, puser *context.ParamsUser) {
 		c.Data["Repos"], err = db.GetUserRepositories(&db.UserRepoOptions{
 			UserID:   puser.ID,
 			Private:  showPrivate,
+			Internal: c.IsLogged,
 			Page:     page,
 			PageSize: conf.UI.User.RepoPagingNum,
 		})
@@ -100,8 +102,59 @@ func Following(c *context.Context, puser *context.ParamsUser) {
 	repo2.RenderUserCards(c, puser.NumFollowing, puser.GetFollowing, FOLLOWERS)
 }
 
-func Stars(c *context.Context) {
+// Stars shows repositories starred by the user, which can be filtered by a star list.
+func Stars(c *context.Context, puser *context.ParamsUser) {
+	c.Title(puser.DisplayName())
+	c.PageIs("Stars")
+	c.Data["Owner"] = puser
+
+	isOwner := c.IsLogged && c.User.ID == puser.ID
+	showPrivate := c.IsLogged && (isOwner || c.User.IsAdmin)
+	c.Data["IsStarListsOwner"] = isOwner
+
+	lists, err := db.GetStarLists(puser.ID, showPrivate)
+	if err != nil {
+		c.ServerError("GetStarLists", err)
+		return
+	}
+	c.Data["StarLists"] = lists
+
+	page := c.QueryInt("page")
+	if page <= 0 {
+		page = 1
+	}
+	opts := &db.StarredReposOptions{
+		UserID:   puser.ID,
+		Private:  showPrivate,
+		Page:     page,
+		PageSize: conf.UI.User.RepoPagingNum,
+	}
+	if listID := c.QueryInt64("list"); listID > 0 {
+		list, err := db.GetStarListByID(puser.ID, listID)
+		if err != nil {
+			c.NotFoundOrServerError("GetStarListByID", errors.IsStarListNotExist, err)
+			return
+		}
+		opts.ListID = list.ID
+		c.Data["StarList"] = list
+	}
+
+	repos, err := db.GetStarredRepos(opts)
+	if err != nil {
+		c.ServerError("GetStarredRepos", err)
+		return
+	}
+	c.Data["Repos"] = repos
+
+	count, err := db.CountStarredRepos(opts)
+	if err != nil {
+		c.ServerError("CountStarredRepos", err)
+		return
+	}
+	c.Data["Total"] = count
+	c.Data["Page"] = paginater.New(int(count), opts.PageSize, page, 5)
 
+	c.Success(STARS)
 }
 
 func Action(c *context.Context, puser *context.ParamsUser) {
@@ -38,9 +38,11 @@ const (
 	SETTINGS_TWO_FACTOR_RECOVERY_CODES = "user/settings/two_factor_recovery_codes"
 	SETTINGS_REPOSITORIES              = "user/settings/repositories"
 	SETTINGS_ORGANIZATIONS             = "user/settings/organizations"
+	SETTINGS_DIGESTS                   = "user/settings/digests"
 	SETTINGS_APPLICATIONS              = "user/settings/applications"
 	SETTINGS_DELETE                    = "user/settings/delete"
 	NOTIFICATION                       = "user/notification"
+	DIGEST_UNSUBSCRIBE                 = "user/digest_unsubscribe"
 )
 
 func Settings(c *context.Context) {
@@ -575,6 +577,74 @@ func SettingsLeaveOrganization(c *context.Context) {
 	})
 }
 
+func SettingsDigests(c *context.Context) {
+	c.Title("settings.digests")
+	c.PageIs("SettingsDigests")
+
+	orgs, err := db.GetOrgsByUserID(c.User.ID, true)
+	if err != nil {
+		c.ServerError("GetOrgsByUserID", err)
+		return
+	}
+	c.Data["Orgs"] = orgs
+
+	subs, err := db.GetDigestSubscriptions(c.User.ID)
+	if err != nil {
+		c.ServerError("GetDigestSubscriptions", err)
+		return
+	}
+	frequencies := make(map[int64]db.DigestFrequency, len(subs))
+	for _, sub := range subs {
+		frequencies[sub.OrgID] = sub.Frequency
+	}
+	c.Data["WatchedFrequency"] = frequencies[0]
+	c.Data["Frequencies"] = frequencies
+
+	c.Success(SETTINGS_DIGESTS)
+}
+
+func SettingsDigestsPost(c *context.Context) {
+	orgs, err := db.GetOrgsByUserID(c.User.ID, true)
+	if err != nil {
+		c.ServerError("GetOrgsByUserID", err)
+		return
+	}
+
+	// Zero is the digest of watched repositories.
+	orgIDs := []int64{0}
+	for _, org := range orgs {
+		orgIDs = append(orgIDs, org.ID)
+	}
+	for _, orgID := range orgIDs {
+		freq := db.ParseDigestFrequency(c.QueryInt("digest_" + com.ToStr(orgID)))
+		if err = db.UpdateDigestSubscription(c.User.ID, orgID, freq); err != nil {
+			c.ServerError("UpdateDigestSubscription", err)
+			return
+		}
+	}
+
+	mode := db.ParseNotificationEmailMode(c.QueryInt("notification_email"))
+	if err = db.UpdateNotificationEmailMode(c.User, mode); err != nil {
+		c.ServerError("UpdateNotificationEmailMode", err)
+		return
+	}
+
+	c.Flash.Success(c.Tr("settings.digests.update_success"))
+	c.SubURLRedirect("/user/settings/digests")
+}
+
+// UnsubscribeDigest deletes the digest subscription by the token in unsubscribe link
+// of digest emails, it does not require signing in.
+func UnsubscribeDigest(c *context.Context) {
+	c.Title("settings.digests.unsubscribe")
+
+	if _, err := db.UnsubscribeDigestByToken(c.Query("token")); err != nil {
+		c.NotFoundOrServerError("UnsubscribeDigestByToken", errors.IsDigestSubscriptionNotExist, err)
+		return
+	}
+	c.Success(DIGEST_UNSUBSCRIBE)
+}
+
 func SettingsApplications(c *context.Context) {
 	c.Title("settings.applications")
 	c.PageIs("SettingsApplications")
@@ -636,9 +706,30 @@ func SettingsDeleteApplication(c *context.Context) {
 	})
 }
 
+func SettingsDeactivatePost(c *context.Context) {
+	if _, err := db.UserLogin(c.User.Name, c.Query("password"), c.User.LoginSource); err != nil {
+		if errors.IsUserNotExist(err) {
+			c.Flash.Error(c.Tr("form.enterred_invalid_password"))
+			c.SubURLRedirect("/user/settings/delete")
+		} else {
+			c.ServerError("UserLogin", err)
+		}
+		return
+	}
+
+	if err := db.DeactivateUser(c.User); err != nil {
+		c.ServerError("DeactivateUser", err)
+		return
+	}
+	log.Trace("Account deactivated: %s", c.User.Name)
+
+	SignOut(c)
+}
+
 func SettingsDelete(c *context.Context) {
 	c.Title("settings.delete")
 	c.PageIs("SettingsDelete")
+	c.Data["DeactivationCooldownDays"] = conf.User.DeactivationCooldownDays
 
 	if c.Req.Method == "POST" {
 		if _, err := db.UserLogin(c.User.Name, c.Query("password"), c.User.LoginSource); err != nil {
@@ -650,7 +741,7 @@ func SettingsDelete(c *context.Context) {
 			return
 		}
 
-		if err := db.DeleteUser(c.User); err != nil {
+		if err := db.DeleteUser(c.User, db.DeleteUserOptions{Doer: c.User}); err != nil {
 			switch {
 			case db.IsErrUserOwnRepos(err):
 				c.Flash.Error(c.Tr("form.still_own_repo"))
@@ -0,0 +1,145 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package user
+
+import (
+	"github.com/unknwon/com"
+	log "unknwon.dev/clog/v2"
+
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/db/errors"
+	"gogs.io/gogs/internal/form"
+)
+
+// starListLink returns the link to the stars page of current user filtered by the
+// star list, or all stars if the list ID is zero.
+func starListLink(c *context.Context, listID int64) string {
+	link := c.User.HomeLink() + "/stars"
+	if listID > 0 {
+		link += "?list=" + com.ToStr(listID)
+	}
+	return link
+}
+
+// getStarList returns the star list of current user by the ID in URL.
+func getStarList(c *context.Context) *db.StarList {
+	list, err := db.GetStarListByID(c.User.ID, c.ParamsInt64(":id"))
+	if err != nil {
+		c.NotFoundOrServerError("GetStarListByID", errors.IsStarListNotExist, err)
+		return nil
+	}
+	return list
+}
+
+func NewStarListPost(c *context.Context, f form.StarList) {
+	if c.HasError() {
+		c.Flash.Error(c.GetErrMsg())
+		c.Redirect(starListLink(c, 0))
+		return
+	}
+
+	list := &db.StarList{
+		UserID:      c.User.ID,
+		Name:        f.Name,
+		Description: f.Description,
+	}
+	if err := db.NewStarList(list); err != nil {
+		if errors.IsStarListAlreadyExist(err) {
+			c.Flash.Error(c.Tr("user.star_lists.name_been_taken", f.Name))
+			c.Redirect(starListLink(c, 0))
+		} else {
+			c.ServerError("NewStarList", err)
+		}
+		return
+	}
+	log.Trace("Star list created [user_id: %d, list_id: %d]", c.User.ID, list.ID)
+
+	c.Flash.Success(c.Tr("user.star_lists.create_success", list.Name))
+	c.Redirect(starListLink(c, list.ID))
+}
+
+func EditStarListPost(c *context.Context, f form.StarList) {
+	list := getStarList(c)
+	if c.Written() {
+		return
+	}
+	if c.HasError() {
+		c.Flash.Error(c.GetErrMsg())
+		c.Redirect(starListLink(c, list.ID))
+		return
+	}
+
+	list.Name = f.Name
+	list.Description = f.Description
+	if err := db.UpdateStarList(list); err != nil {
+		if errors.IsStarListAlreadyExist(err) {
+			c.Flash.Error(c.Tr("user.star_lists.name_been_taken", f.Name))
+			c.Redirect(starListLink(c, list.ID))
+		} else {
+			c.ServerError("UpdateStarList", err)
+		}
+		return
+	}
+
+	c.Flash.Success(c.Tr("user.star_lists.update_success"))
+	c.Redirect(starListLink(c, list.ID))
+}
+
+func DeleteStarListPost(c *context.Context) {
+	list := getStarList(c)
+	if c.Written() {
+		return
+	}
+
+	if err := db.DeleteStarList(list); err != nil {
+		c.ServerError("DeleteStarList", err)
+		return
+	}
+	log.Trace("Star list deleted [user_id: %d, list_id: %d]", c.User.ID, list.ID)
+
+	c.Flash.Success(c.Tr("user.star_lists.delete_success", list.Name))
+	c.Redirect(starListLink(c, 0))
+}
+
+// StarListReposPost adds a repository to or removes it from the star list, and
+// redirects back to the stars page the request is sent from.
+func StarListReposPost(c *context.Context) {
+	list := getStarList(c)
+	if c.Written() {
+		return
+	}
+
+	repo, err := db.GetRepositoryByID(c.QueryInt64("repo_id"))
+	if err != nil {
+		c.NotFoundOrServerError("GetRepositoryByID", errors.IsRepoNotExist, err)
+		return
+	}
+
+	switch c.Query("action") {
+	case "add":
+		has, err := db.HasAccess(c.User.ID, repo, db.ACCESS_MODE_READ)
+		if err != nil {
+			c.ServerError("HasAccess", err)
+			return
+		} else if !has {
+			c.NotFound()
+			return
+		}
+		err = list.AddRepo(repo.ID)
+	case "remove":
+		err = list.RemoveRepo(repo.ID)
+	default:
+		c.NotFound()
+		return
+	}
+	if err != nil {
+		c.ServerError("update repositories of star list", err)
+		return
+	}
+
+	c.Flash.Success(c.Tr("user.star_lists.update_success"))
+	c.Redirect(starListLink(c, c.QueryInt64("redirect_list")))
+}
@@ -0,0 +1,87 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package user
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	log "unknwon.dev/clog/v2"
+
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/markup"
+	"gogs.io/gogs/internal/tool"
+)
+
+const TERMS = "user/terms"
+
+// renderTermsDocuments returns rendered terms documents under "custom/terms",
+// documents that cannot be read are skipped.
+func renderTermsDocuments() []string {
+	docs := make([]string, 0, len(conf.Terms.Documents))
+	for _, name := range conf.Terms.Documents {
+		fpath := filepath.Join(conf.CustomDir(), "terms", filepath.Base(name))
+		data, err := ioutil.ReadFile(fpath)
+		if err != nil {
+			log.Error("Failed to read terms document %q: %v", fpath, err)
+			continue
+		}
+		docs = append(docs, string(markup.Markdown(data, "", nil)))
+	}
+	return docs
+}
+
+func Terms(c *context.Context) {
+	if !conf.Terms.Enabled {
+		c.NotFound()
+		return
+	}
+
+	c.Title("terms.title")
+	c.PageIs("Terms")
+	c.Data["TermsVersion"] = conf.Terms.Version
+	c.Data["TermsDocuments"] = renderTermsDocuments()
+	c.Data["redirect_to"] = c.Query("redirect_to")
+
+	if c.IsLogged {
+		acceptance, err := db.GetTermsAcceptance(c.User.ID, conf.Terms.Version)
+		if err != nil {
+			c.ServerError("GetTermsAcceptance", err)
+			return
+		}
+		c.Data["TermsAcceptance"] = acceptance
+	}
+
+	c.Success(TERMS)
+}
+
+func TermsPost(c *context.Context) {
+	if !conf.Terms.Enabled {
+		c.NotFound()
+		return
+	}
+
+	// The version is sent by the form to make sure users accept what they read.
+	if c.Query("version") != conf.Terms.Version {
+		c.Flash.Error(c.Tr("terms.version_changed"))
+		c.SubURLRedirect("/user/terms")
+		return
+	}
+
+	if err := db.AcceptTerms(c.User, conf.Terms.Version); err != nil {
+		c.ServerError("AcceptTerms", err)
+		return
+	}
+
+	c.Flash.Success(c.Tr("terms.accept_success"))
+	redirectTo := c.Query("redirect_to")
+	if tool.IsSameSiteURLPath(redirectTo) {
+		c.Redirect(redirectTo)
+		return
+	}
+	c.SubURLRedirect("/")
+}
@@ -0,0 +1,71 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package route
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"gogs.io/gogs/internal/activitypub"
+	"gogs.io/gogs/internal/conf"
+	"gogs.io/gogs/internal/context"
+	"gogs.io/gogs/internal/db"
+	"gogs.io/gogs/internal/db/errors"
+)
+
+// isLocalHost returns true if given host is the domain or the host of external URL
+// of the instance.
+func isLocalHost(host string) bool {
+	return strings.EqualFold(host, conf.Server.Domain) || strings.EqualFold(host, conf.Server.URL.Host)
+}
+
+// Webfinger returns the WebFinger resource of a user or an organization, which is
+// used by other instances to discover the ActivityPub actor from "acct:" URI.
+func Webfinger(c *context.Context) {
+	resource := c.Query("resource")
+	name, host, err := activitypub.ParseAcct(resource)
+	if err != nil {
+		c.HandleText(http.StatusBadRequest, err.Error())
+		return
+	} else if !isLocalHost(host) {
+		c.NotFound()
+		return
+	}
+
+	u, err := db.GetUserByName(name)
+	if err != nil {
+		c.NotFoundOrServerError("GetUserByName", errors.IsUserNotExist, err)
+		return
+	} else if !u.IsFederated() {
+		c.NotFound()
+		return
+	}
+
+	data, err := json.Marshal(&activitypub.WebfingerResource{
+		Subject: resource,
+		Aliases: []string{u.HTMLURL(), u.FederationActorURL()},
+		Links: []*activitypub.WebfingerLink{
+			{
+				Rel:  "http://webfinger.net/rel/profile-page",
+				Type: "text/html",
+				Href: u.HTMLURL(),
+			},
+			{
+				Rel:  "self",
+				Type: activitypub.ContentType,
+				Href: u.FederationActorURL(),
+			},
+		},
+	})
+	if err != nil {
+		c.ServerError("json.Marshal", err)
+		return
+	}
+	c.Resp.Header().Set("Content-Type", activitypub.JRDContentType)
+	c.Resp.Header().Set("Access-Control-Allow-Origin", "*")
+	c.Resp.WriteHeader(http.StatusOK)
+	_, _ = c.Resp.Write(data)
+}
@@ -0,0 +1,44 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalStore(t *testing.T) {
+	root, err := ioutil.TempDir("", "local-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	s := NewLocalStore(root)
+	if err = s.Put("a/b/ab12", strings.NewReader("content"), 7); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := s.Open("a/b/ab12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "content", string(data))
+
+	assert.Nil(t, s.Delete("a/b/ab12"))
+	assert.Nil(t, s.Delete("a/b/ab12"))
+
+	_, err = s.Open("a/b/ab12")
+	assert.Equal(t, ErrNotExist, err)
+}
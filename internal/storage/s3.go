@@ -0,0 +1,215 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Options contains options of an object storage service compatible with Amazon S3.
+type S3Options struct {
+	// Endpoint is the base URL of the service, e.g. "https://s3.amazonaws.com".
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// PathStyle indicates whether to put bucket name in the path rather than the host
+	// name, which is usually required by self-hosted services like MinIO.
+	PathStyle bool
+	// Prefix is prepended to keys as names of objects.
+	Prefix string
+}
+
+var _ Store = (*S3Store)(nil)
+
+// S3Store saves files as objects in a bucket of an object storage service compatible
+// with Amazon S3.
+type S3Store struct {
+	opts   S3Options
+	client *http.Client
+}
+
+// NewS3Store returns a new S3 store with given options.
+func NewS3Store(opts S3Options) *S3Store {
+	return &S3Store{
+		opts:   opts,
+		client: http.DefaultClient,
+	}
+}
+
+// s3EscapePath escapes the path as required by Signature Version 4, which leaves only
+// unreserved characters and slashes.
+func s3EscapePath(p string) string {
+	var buf strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			buf.WriteByte(c)
+		} else {
+			fmt.Fprintf(&buf, "%%%02X", c)
+		}
+	}
+	return buf.String()
+}
+
+// objectURL returns the URL of object of the key.
+func (s *S3Store) objectURL(key string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSuffix(s.opts.Endpoint, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("parse endpoint: %v", err)
+	}
+
+	name := path.Join(s.opts.Prefix, key)
+	if s.opts.PathStyle {
+		u.Path += "/" + s.opts.Bucket + "/" + name
+	} else {
+		u.Host = s.opts.Bucket + "." + u.Host
+		u.Path += "/" + name
+	}
+	u.RawPath = s3EscapePath(u.Path)
+	return u, nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// emptyPayloadHash is the SHA-256 hash of empty payload.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// signS3Request signs the request with Signature Version 4 of AWS, all headers of
+// the request are signed.
+func signS3Request(req *http.Request, region, accessKeyID, secretAccessKey, payloadHash string, t time.Time) {
+	amzDate := t.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{
+		"host": req.URL.Host,
+	}
+	for k, vs := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(vs, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	hash := sha256.Sum256([]byte(canonicalRequest))
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKeyID, scope, signedHeaders, signature))
+}
+
+// do sends a signed request of the method to object of the key.
+func (s *S3Store) do(method, key string, body io.Reader, size int64) (*http.Response, error) {
+	u, err := s.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	payloadHash := emptyPayloadHash
+	if body != nil {
+		req.ContentLength = size
+		// Payload is streamed without being read twice to compute its hash.
+		payloadHash = "UNSIGNED-PAYLOAD"
+	}
+	signS3Request(req, s.opts.Region, s.opts.AccessKeyID, s.opts.SecretAccessKey, payloadHash, time.Now())
+	return s.client.Do(req)
+}
+
+// s3Error returns an error of unexpected response.
+func s3Error(method, key string, resp *http.Response) error {
+	data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("%s %q: unexpected status %d: %s", method, key, resp.StatusCode, strings.TrimSpace(string(data)))
+}
+
+func (s *S3Store) Put(key string, r io.Reader, size int64) error {
+	resp, err := s.do(http.MethodPut, key, r, size)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return s3Error(http.MethodPut, key, resp)
+	}
+	return nil
+}
+
+func (s *S3Store) Open(key string) (io.ReadCloser, error) {
+	resp, err := s.do(http.MethodGet, key, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrNotExist
+	}
+	defer resp.Body.Close()
+	return nil, s3Error(http.MethodGet, key, resp)
+}
+
+func (s *S3Store) Delete(key string) error {
+	resp, err := s.do(http.MethodDelete, key, nil, 0)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	}
+	return s3Error(http.MethodDelete, key, resp)
+}
@@ -0,0 +1,31 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package storage provides blob stores that files are saved to.
+package storage
+
+import (
+	"errors"
+	"io"
+)
+
+// Names of supported blob stores.
+const (
+	LOCAL = "local"
+	S3    = "s3"
+)
+
+// ErrNotExist is returned when there is no content under the key.
+var ErrNotExist = errors.New("blob does not exist")
+
+// Store is a blob store that files are saved to by keys, which are slash-separated
+// relative paths.
+type Store interface {
+	// Put saves content of given size read from r under the key.
+	Put(key string, r io.Reader, size int64) error
+	// Open returns content under the key, or ErrNotExist if there is none.
+	Open(key string) (io.ReadCloser, error)
+	// Delete deletes content under the key, it is not an error if there is none.
+	Delete(key string) error
+}
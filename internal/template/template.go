@@ -60,6 +60,18 @@ func FuncMap() []template.FuncMap {
 			"DisableGravatar": func() bool {
 				return conf.DisableGravatar
 			},
+			"KaTeXURL": func() string {
+				if !conf.Markdown.EnableMath {
+					return ""
+				}
+				return conf.Markdown.KaTeXURL
+			},
+			"MermaidURL": func() string {
+				if !conf.Markdown.EnableMermaid {
+					return ""
+				}
+				return conf.Markdown.MermaidURL
+			},
 			"ShowFooterTemplateLoadTime": func() bool {
 				return conf.ShowFooterTemplateLoadTime
 			},
@@ -219,7 +231,7 @@ func ReplaceLeft(s, old, new string) string {
 // RenderCommitMessage renders commit message with special links.
 func RenderCommitMessage(full bool, msg, urlPrefix string, metas map[string]string) string {
 	cleanMsg := template.HTMLEscapeString(msg)
-	fullMessage := string(markup.RenderIssueIndexPattern([]byte(cleanMsg), urlPrefix, metas))
+	fullMessage := string(markup.RenderAutolinks(markup.RenderIssueIndexPattern([]byte(cleanMsg), urlPrefix, metas), metas))
 	msgLines := strings.Split(strings.TrimSpace(fullMessage), "\n")
 	numLines := len(msgLines)
 	if numLines == 0 {
@@ -266,7 +278,7 @@ func ActionIcon(opType int) string {
 		return "issue-opened"
 	case 7: // New pull request
 		return "git-pull-request"
-	case 9: // Push tag
+	case 9, 23: // Push tag or publish release
 		return "tag"
 	case 10: // Comment issue
 		return "comment-discussion"
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"math"
 	"net/http"
+	"path"
 	"strings"
 )
 
@@ -31,6 +32,29 @@ func IsVideoFile(data []byte) bool {
 	return strings.Contains(http.DetectContentType(data), "video/")
 }
 
+// IsSTLFile returns true if file name looks like a 3D model in STL format.
+func IsSTLFile(name string) bool {
+	return strings.ToLower(path.Ext(name)) == ".stl"
+}
+
+// IsGeoJSONFile returns true if file name looks like geographic data in GeoJSON format.
+func IsGeoJSONFile(name string) bool {
+	return strings.ToLower(path.Ext(name)) == ".geojson"
+}
+
+// DetectContentType returns the content type of file based on its name and the
+// beginning of its content, where formats that cannot be detected by content alone
+// are recognized by the file extension.
+func DetectContentType(name string, data []byte) string {
+	switch {
+	case IsSTLFile(name):
+		return "model/stl"
+	case IsGeoJSONFile(name):
+		return "application/geo+json"
+	}
+	return http.DetectContentType(data)
+}
+
 const (
 	Byte  = 1
 	KByte = Byte * 1024
@@ -20,6 +20,9 @@ import (
 
 	"github.com/unknwon/com"
 	"github.com/unknwon/i18n"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
 	log "unknwon.dev/clog/v2"
 
 	"github.com/gogs/chardet"
@@ -431,6 +434,26 @@ func TruncateString(str string, limit int) string {
 	return str[:limit]
 }
 
+// foldReplacer replaces letters that are not decomposed into base letters and
+// combining marks by Unicode normalization.
+var foldReplacer = strings.NewReplacer(
+	"ß", "ss", "æ", "ae", "œ", "oe", "ø", "o", "đ", "d",
+	"ð", "d", "ħ", "h", "ı", "i", "ł", "l", "þ", "th",
+)
+
+// FoldString returns the lower cased string with diacritics removed, e.g. "Zoë Åström"
+// becomes "zoe astrom", so that strings can be matched regardless of case and
+// diacritics.
+func FoldString(str string) string {
+	str = strings.ToLower(str)
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(t, str)
+	if err != nil {
+		folded = str
+	}
+	return foldReplacer.Replace(folded)
+}
+
 // StringsToInt64s converts a slice of string to a slice of int64.
 func StringsToInt64s(strs []string) []int64 {
 	ints := make([]int64, len(strs))
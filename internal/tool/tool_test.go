@@ -0,0 +1,51 @@
+// Copyright 2020 The Gogs Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package tool
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_FoldString(t *testing.T) {
+	Convey("Fold case and diacritics of strings", t, func() {
+		testCases := []struct {
+			str    string
+			expect string
+		}{
+			{"", ""},
+			{"alice", "alice"},
+			{"Zoë Åström", "zoe astrom"},
+			{"François Müller", "francois muller"},
+			{"Łukasz Øvergård", "lukasz overgard"},
+			{"STRAßE", "strasse"},
+			{"José_García-99", "jose_garcia-99"},
+			{"张伟", "张伟"},
+		}
+		for _, tc := range testCases {
+			So(FoldString(tc.str), ShouldEqual, tc.expect)
+		}
+	})
+}
+
+func Test_DetectContentType(t *testing.T) {
+	Convey("Detect content type of files", t, func() {
+		testCases := []struct {
+			name   string
+			data   []byte
+			expect string
+		}{
+			{"model.stl", []byte("solid cube\n"), "model/stl"},
+			{"MODEL.STL", []byte{0, 0, 0, 0}, "model/stl"},
+			{"map.geojson", []byte(`{"type": "FeatureCollection"}`), "application/geo+json"},
+			{"doc.pdf", []byte("%PDF-1.4\n"), "application/pdf"},
+			{"README", []byte("Hello"), "text/plain; charset=utf-8"},
+		}
+		for _, tc := range testCases {
+			So(DetectContentType(tc.name, tc.data), ShouldEqual, tc.expect)
+		}
+	})
+}
@@ -18,6 +18,7 @@ function initCommentPreviewTab($form) {
                 var $previewPanel = $form.find('.tab.segment[data-tab="' + $tabMenu.data('preview') + '"]');
                 $previewPanel.html(data);
                 emojify.run($previewPanel[0]);
+                renderMathAndDiagrams($previewPanel[0]);
                 $('pre code', $previewPanel[0]).each(function (i, block) {
                     hljs.highlightBlock(block);
                 });
@@ -28,6 +29,65 @@ function initCommentPreviewTab($form) {
     buttonsClickOnEnter();
 }
 
+var loadedScripts = {};
+
+// loadScript loads the script only once and calls the callback after it is loaded.
+function loadScript(url, callback) {
+    if (!loadedScripts[url]) {
+        loadedScripts[url] = $.ajax({url: url, dataType: 'script', cache: true});
+    }
+    loadedScripts[url].done(callback);
+}
+
+// renderMathAndDiagrams typesets math by KaTeX and renders diagrams by Mermaid
+// in code of language "math" and "mermaid", which are loaded on demand.
+function renderMathAndDiagrams(container) {
+    var katexURL = $('head').data('katex-url');
+    var $maths = $('code.language-math', container);
+    if (katexURL && $maths.length) {
+        if (!loadedScripts[katexURL + '/katex.min.js']) {
+            $('<link rel="stylesheet">').attr('href', katexURL + '/katex.min.css').appendTo('head');
+        }
+        loadScript(katexURL + '/katex.min.js', function () {
+            $maths.each(function () {
+                var $code = $(this);
+                var displayMode = $code.parent().is('pre');
+                var $math = $(displayMode ? '<div class="math display">' : '<span class="math inline">');
+                try {
+                    katex.render($code.text(), $math[0], {displayMode: displayMode, throwOnError: false});
+                } catch (e) {
+                    return;
+                }
+                (displayMode ? $code.parent() : $code).replaceWith($math);
+            });
+        });
+    }
+
+    var mermaidURL = $('head').data('mermaid-url');
+    var $diagrams = $('pre > code.language-mermaid', container);
+    if (mermaidURL && $diagrams.length) {
+        loadScript(mermaidURL, function () {
+            mermaid.initialize({startOnLoad: false, securityLevel: 'strict', theme: 'neutral'});
+            $diagrams.each(function (i) {
+                var $pre = $(this).parent();
+                try {
+                    mermaid.mermaidAPI.render('mermaid-' + Date.now() + '-' + i, $(this).text(), function (svg) {
+                        $pre.replaceWith($('<div class="mermaid diagram">').html(svg));
+                    });
+                } catch (e) {
+                    // Keep the source as it is when the diagram has syntax errors.
+                }
+            });
+        });
+    }
+}
+
+// enableTaskLists makes checkboxes of task lists in rendered content clickable if
+// the content is editable.
+function enableTaskLists($content) {
+    $content.filter('[data-task-url]').find('input[type=checkbox]').prop('disabled', false);
+}
+
 var previewFileModes;
 
 function initEditPreviewTab($form) {
@@ -47,6 +107,7 @@ function initEditPreviewTab($form) {
                     var $previewPanel = $form.find('.tab.segment[data-tab="' + $tabMenu.data('preview') + '"]');
                     $previewPanel.html(data);
                     emojify.run($previewPanel[0]);
+                    renderMathAndDiagrams($previewPanel[0]);
                     $('pre code', $previewPanel[0]).each(function (i, block) {
                         hljs.highlightBlock(block);
                     });
@@ -85,6 +146,62 @@ function initEditForm() {
 }
 
 
+function initMentionAutocomplete() {
+    $('textarea[data-mention-url]').each(function () {
+        var $textarea = $(this);
+        var $menu = $('<div class="ui vertical menu mention-menu"></div>').hide().insertAfter($textarea);
+        var mentionStart = -1;
+
+        var insertMention = function (username) {
+            var value = $textarea.val();
+            var caret = $textarea[0].selectionStart;
+            $textarea.val(value.substring(0, mentionStart) + '@' + username + ' ' + value.substring(caret));
+            var pos = mentionStart + username.length + 2;
+            $textarea[0].setSelectionRange(pos, pos);
+            $menu.hide();
+            $textarea.focus();
+        };
+
+        $textarea.keyup(function (e) {
+            // Escape
+            if (e.keyCode == 27) {
+                $menu.hide();
+                return;
+            }
+
+            var caret = this.selectionStart;
+            var matches = /(^|\s)@([0-9a-zA-Z-_\.]*)$/.exec($textarea.val().substring(0, caret));
+            if (!matches) {
+                $menu.hide();
+                return;
+            }
+            mentionStart = caret - matches[2].length - 1;
+
+            $.getJSON($textarea.data('mention-url'), {q: matches[2]}, function (users) {
+                $menu.empty();
+                if (!users || users.length == 0) {
+                    $menu.hide();
+                    return;
+                }
+                $.each(users, function (i, user) {
+                    var $item = $('<a class="item"></a>');
+                    $item.append($('<img class="ui avatar image">').attr('src', user.avatar_url));
+                    $item.append($('<span class="username"></span>').text(user.username));
+                    if (user.full_name) {
+                        $item.append($('<span class="text grey"></span>').text(user.full_name));
+                    }
+                    $item.click(function () {
+                        insertMention(user.username);
+                        return false;
+                    });
+                    $menu.append($item);
+                });
+                $menu.show();
+            });
+        });
+    });
+}
+
 function initCommentForm() {
     if ($('.comment.form').length == 0) {
         return
@@ -238,6 +355,37 @@ function initRepository() {
         });
     }
 
+    // Topics
+    if ($('#repo-topics-form').length > 0) {
+        var $topics = $('#repo-topics');
+        var $topicsForm = $('#repo-topics-form');
+        $('#manage-topics').click(function () {
+            $topics.hide();
+            $topicsForm.show();
+            return false;
+        });
+        $('#cancel-topics').click(function () {
+            $topicsForm.hide();
+            $topics.show();
+            return false;
+        });
+        $topicsForm.find('.dropdown').dropdown({
+            allowAdditions: true,
+            forceSelection: false,
+            saveRemoteData: false,
+            apiSettings: {
+                url: suburl + '/api/v1/topics/search?q={query}',
+                onResponse: function (response) {
+                    var results = [];
+                    $.each(response.topics, function (_, topic) {
+                        results.push({name: topic.name, value: topic.name});
+                    });
+                    return {success: true, results: results};
+                }
+            }
+        });
+    }
+
     // Wiki
     if ($('.repository.wiki.view').length > 0) {
         initFilterSearchDropdown('.choose.page .dropdown');
@@ -302,7 +450,57 @@ function initRepository() {
 
     // Milestones
     if ($('.repository.milestones').length > 0) {
+        $('.burndown-toggle').click(function () {
+            var $burndown = $(this).closest('.item').find('.burndown');
+            if (!$burndown.hasClass('hide') || $burndown.data('loaded')) {
+                $burndown.toggleClass('hide');
+                return false;
+            }
 
+            $.getJSON($(this).data('url'), function (progress) {
+                $burndown.data('loaded', true).removeClass('hide');
+                if (progress.length === 0) {
+                    $burndown.text($burndown.data('empty'));
+                    return;
+                }
+                $burndown.html(renderBurndown(progress));
+            });
+            return false;
+        });
+    }
+
+    // Star history
+    var $starHistory = $('.repository.watchers .star-history');
+    if ($starHistory.length > 0) {
+        $.getJSON($starHistory.data('url'), function (history) {
+            if (history.length === 0) {
+                $starHistory.text($starHistory.data('empty'));
+                return;
+            }
+            $starHistory.html(renderStarHistory(history));
+        });
+    }
+
+    // Graphs
+    var $graphs = $('.repository.graphs .graph');
+    if ($graphs.length > 0) {
+        var first = 0, last = 0;
+        $graphs.each(function () {
+            var $graph = $(this), data = $graph.data('graph') || [];
+            if ($graph.data('kind') === 'commits' && data.length > 0) {
+                // All contributors are drawn in the same range of weeks as the first graph.
+                if (!first) {
+                    first = data[0].w;
+                    last = data[data.length - 1].w;
+                }
+                data = denseWeeks(data, first, last);
+            }
+            if (data.length === 0) {
+                $graph.text($graph.data('empty') || '');
+                return;
+            }
+            $graph.html(renderGraph($graph.data('kind'), data, $graph.data('small')));
+        });
     }
     if ($('.repository.new.milestone').length > 0) {
         var $datepicker = $('.milestone.datepicker');
@@ -399,6 +597,8 @@ function initRepository() {
                             } else {
                                 $renderContent.html(data.content);
                                 emojify.run($renderContent[0]);
+                                renderMathAndDiagrams($renderContent[0]);
+                                enableTaskLists($renderContent);
                                 $('pre code', $renderContent[0]).each(function (i, block) {
                                     hljs.highlightBlock(block);
                                 });
@@ -419,6 +619,33 @@ function initRepository() {
             return false;
         });
 
+        // Check or uncheck task list items
+        var $taskListContents = $('.render-content[data-task-url]');
+        enableTaskLists($taskListContents);
+        $taskListContents.on('change', 'input[type=checkbox]', function () {
+            var $content = $(this).closest('.render-content');
+            var $checkboxes = $content.find('input[type=checkbox]');
+            var $checkbox = $(this);
+            var checked = $checkbox.prop('checked');
+            $checkboxes.prop('disabled', true);
+            $.ajax({
+                url: $content.data('task-url'),
+                type: 'PATCH',
+                data: {
+                    "_csrf": csrf,
+                    "index": $checkboxes.index($checkbox),
+                    "checked": checked
+                }
+            }).done(function (data) {
+                $content.siblings('.raw-content').text(data.raw);
+                $content.siblings('.edit-content-zone').find('textarea').val(data.raw);
+            }).fail(function () {
+                $checkbox.prop('checked', !checked);
+            }).always(function () {
+                $checkboxes.prop('disabled', false);
+            });
+        });
+
         // Delete comment
         $('.delete-comment').click(function () {
             var $this = $(this);
@@ -432,6 +659,41 @@ function initRepository() {
             return false;
         });
 
+        // View and delete revisions of comments
+        var $revisionModal = $('#comment-revision-modal');
+        $('.comment-revisions .menu .item').click(function () {
+            var $this = $(this);
+            $.get($this.data('url'), function (data) {
+                $revisionModal.find('.revision-desc').text($.trim($this.text()));
+                $revisionModal.find('.render-content').html(data.content);
+                emojify.run($revisionModal.find('.render-content')[0]);
+                $revisionModal.find('.delete-revision').data('url', $this.data('delete-url'));
+                $revisionModal.modal('show');
+            });
+            return false;
+        });
+        $revisionModal.find('.delete-revision').click(function () {
+            var $this = $(this);
+            if (confirm($this.data('locale'))) {
+                $.post($this.data('url'), {
+                    "_csrf": csrf
+                }).success(function () {
+                    window.location.reload();
+                });
+            }
+            return false;
+        });
+
+        // Resolve or unresolve conversation
+        $('.resolve-comment').click(function () {
+            $.post($(this).data('url'), {
+                "_csrf": csrf
+            }).success(function () {
+                window.location.reload();
+            });
+            return false;
+        });
+
         // Change status
         var $statusButton = $('#status-button');
         $('#comment-form .edit_area').keyup(function () {
@@ -509,14 +771,69 @@ function initRepository() {
     }
 }
 
+// uploadWikiFiles uploads files to wiki and inserts links of them into the editor.
+function uploadWikiFiles(simplemde, $editArea, files) {
+    var cm = simplemde.codemirror;
+    $.each(files, function (i, file) {
+        var placeholder = '[' + $editArea.data('uploading') + ' ' + file.name + ']';
+        cm.replaceSelection(placeholder + '\n');
+
+        var data = new FormData();
+        data.append('_csrf', csrf);
+        data.append('file', file);
+        $.ajax({
+            url: $editArea.data('upload-url'),
+            type: 'POST',
+            headers: {'X-Csrf-Token': csrf},
+            data: data,
+            processData: false,
+            contentType: false
+        }).done(function (resp) {
+            cm.setValue(cm.getValue().replace(placeholder, resp.markdown));
+        }).fail(function (xhr) {
+            cm.setValue(cm.getValue().replace(placeholder + '\n', ''));
+            alert(xhr.responseText);
+        });
+    });
+}
+
 function initWikiForm() {
     var $editArea = $('.repository.wiki textarea#edit_area');
     if ($editArea.length > 0) {
-        new SimpleMDE({
+        var toolbar = ["bold", "italic", "strikethrough", "|",
+            "heading-1", "heading-2", "heading-3", "heading-bigger", "heading-smaller", "|",
+            "code", "quote", "|",
+            "unordered-list", "ordered-list", "|",
+            "link", "image", "table", "horizontal-rule", "|",
+            "clean-block", "preview", "fullscreen"];
+        var $fileInput;
+        if ($editArea.data('upload-url')) {
+            $fileInput = $('<input type="file" class="hide" multiple>');
+            if ($editArea.data('accepts') && $editArea.data('accepts') !== '*/*') {
+                $fileInput.attr('accept', $editArea.data('accepts'));
+            }
+            $fileInput.insertAfter($editArea);
+            toolbar.splice(toolbar.indexOf('table'), 0, {
+                name: 'upload',
+                action: function () {
+                    $fileInput.click();
+                },
+                className: 'fa fa-upload',
+                title: $editArea.data('upload-title')
+            });
+        }
+
+        var simplemde = new SimpleMDE({
             autoDownloadFontAwesome: false,
             element: $editArea[0],
             forceSync: true,
             previewRender: function (plainText, preview) { // Async method
+                // Only Markdown pages can be previewed.
+                var format = $('.repository.wiki select[name=format]').val();
+                if (format && format !== '.md') {
+                    return '<pre>' + $('<div>').text(plainText).html() + '</pre>';
+                }
+
                 setTimeout(function () {
                     // FIXME: still send render request when return back to edit mode
                     $.post($editArea.data('url'), {
@@ -528,6 +845,7 @@ function initWikiForm() {
                         function (data) {
                             preview.innerHTML = '<div class="markdown">' + data + '</div>';
                             emojify.run($('.editor-preview')[0]);
+                            renderMathAndDiagrams($('.editor-preview')[0]);
                         }
                     );
                 }, 0);
@@ -540,13 +858,23 @@ function initWikiForm() {
             indentWithTabs: false,
             tabSize: 4,
             spellChecker: false,
-            toolbar: ["bold", "italic", "strikethrough", "|",
-                "heading-1", "heading-2", "heading-3", "heading-bigger", "heading-smaller", "|",
-                "code", "quote", "|",
-                "unordered-list", "ordered-list", "|",
-                "link", "image", "table", "horizontal-rule", "|",
-                "clean-block", "preview", "fullscreen"]
-        })
+            toolbar: toolbar
+        });
+
+        if ($fileInput) {
+            $fileInput.change(function () {
+                uploadWikiFiles(simplemde, $editArea, this.files);
+                $(this).val('');
+            });
+            simplemde.codemirror.on('drop', function (cm, e) {
+                var files = e.dataTransfer && e.dataTransfer.files;
+                if (files && files.length > 0) {
+                    e.preventDefault();
+                    cm.setCursor(cm.coordsChar({left: e.pageX, top: e.pageY}));
+                    uploadWikiFiles(simplemde, $editArea, files);
+                }
+            });
+        }
     }
 }
 
@@ -610,6 +938,7 @@ function setSimpleMDE($editArea) {
                     function (data) {
                         preview.innerHTML = '<div class="markdown">' + data + '</div>';
                         emojify.run($('.editor-preview')[0]);
+                        renderMathAndDiagrams($('.editor-preview')[0]);
                     }
                 );
             }, 0);
@@ -812,6 +1141,15 @@ function initOrganization() {
             }
         });
     }
+
+    // Project board
+    if ($('.organization.project.view').length > 0) {
+        $('.project.board .move select').change(function () {
+            if ($(this).val()) {
+                $(this).closest('form').submit();
+            }
+        });
+    }
 }
 
 function initAdmin() {
@@ -922,7 +1260,7 @@ function initAdmin() {
                     break;
             }
         });
-        $('#delete-selection').click(function () {
+        $('#delete-selection, .selection-action').click(function () {
             var $this = $(this);
             $this.addClass("loading disabled");
             var ids = [];
@@ -1085,6 +1423,359 @@ function initCodeView() {
             }
         }).trigger('hashchange');
     }
+
+    // Sort rows of CSV table by the clicked column, numbers are compared by values.
+    $('.csv-data th.sortable').click(function () {
+        var $th = $(this);
+        var $tbody = $th.closest('table').children('tbody');
+        var index = $th.index();
+        var ascending = !$th.hasClass('ascending');
+        $th.siblings().removeClass('ascending descending');
+        $th.toggleClass('ascending', ascending).toggleClass('descending', !ascending);
+
+        var rows = $tbody.children('tr').get();
+        rows.sort(function (a, b) {
+            var x = $(a).children().eq(index).text();
+            var y = $(b).children().eq(index).text();
+            var result;
+            if (x.trim() !== '' && y.trim() !== '' && !isNaN(x) && !isNaN(y)) {
+                result = parseFloat(x) - parseFloat(y);
+            } else {
+                result = x.localeCompare(y);
+            }
+            return ascending ? result : -result;
+        });
+        $tbody.append(rows);
+    });
+}
+
+// parseSTL returns triangles of 3D model in binary or ASCII STL format, where each
+// triangle is an array of three vertices.
+function parseSTL(buffer) {
+    var view = new DataView(buffer);
+    var triangles = [];
+    var i, j;
+
+    // Binary STL has a header of 80 bytes, the number of triangles and 50 bytes for each triangle.
+    if (buffer.byteLength >= 84 && 84 + view.getUint32(80, true) * 50 === buffer.byteLength) {
+        var count = view.getUint32(80, true);
+        for (i = 0; i < count; i++) {
+            // Skip the normal vector, which is computed from vertices.
+            var offset = 84 + i * 50 + 12;
+            var triangle = [];
+            for (j = 0; j < 3; j++) {
+                triangle.push([
+                    view.getFloat32(offset + j * 12, true),
+                    view.getFloat32(offset + j * 12 + 4, true),
+                    view.getFloat32(offset + j * 12 + 8, true)
+                ]);
+            }
+            triangles.push(triangle);
+        }
+        return triangles;
+    }
+
+    var text = new TextDecoder().decode(buffer);
+    var pattern = /vertex\s+(\S+)\s+(\S+)\s+(\S+)/g;
+    var vertices = [];
+    var m;
+    while ((m = pattern.exec(text)) !== null) {
+        vertices.push([parseFloat(m[1]), parseFloat(m[2]), parseFloat(m[3])]);
+    }
+    for (i = 0; i + 2 < vertices.length; i += 3) {
+        triangles.push([vertices[i], vertices[i + 1], vertices[i + 2]]);
+    }
+    return triangles;
+}
+
+// renderSTLViewer renders the 3D model on the canvas with flat shading, it can be
+// rotated by dragging and zoomed by scrolling.
+function renderSTLViewer($viewer, triangles) {
+    var canvas = $viewer.find('canvas')[0];
+    var ctx = canvas.getContext('2d');
+    canvas.width = $viewer.width();
+    canvas.height = 500;
+
+    // Move the model to the origin and scale it to fit the canvas.
+    var min = [Infinity, Infinity, Infinity], max = [-Infinity, -Infinity, -Infinity];
+    triangles.forEach(function (t) {
+        t.forEach(function (v) {
+            for (var k = 0; k < 3; k++) {
+                min[k] = Math.min(min[k], v[k]);
+                max[k] = Math.max(max[k], v[k]);
+            }
+        });
+    });
+    var center = [(min[0] + max[0]) / 2, (min[1] + max[1]) / 2, (min[2] + max[2]) / 2];
+    var radius = Math.sqrt(Math.pow(max[0] - min[0], 2) + Math.pow(max[1] - min[1], 2) + Math.pow(max[2] - min[2], 2)) / 2 || 1;
+
+    var yaw = Math.PI / 4, pitch = -Math.PI / 3, zoom = 1;
+    var pending = false;
+
+    function draw() {
+        pending = false;
+        var scale = Math.min(canvas.width, canvas.height) / 2 / radius * 0.9 * zoom;
+        var cy = Math.cos(yaw), sy = Math.sin(yaw), cp = Math.cos(pitch), sp = Math.sin(pitch);
+        var faces = triangles.map(function (t) {
+            var p = t.map(function (v) {
+                var x = v[0] - center[0], y = v[1] - center[1], z = v[2] - center[2];
+                var x1 = x * cy - y * sy, y1 = x * sy + y * cy;
+                return [x1, y1 * cp - z * sp, y1 * sp + z * cp];
+            });
+            var ux = p[1][0] - p[0][0], uy = p[1][1] - p[0][1], uz = p[1][2] - p[0][2];
+            var vx = p[2][0] - p[0][0], vy = p[2][1] - p[0][1], vz = p[2][2] - p[0][2];
+            var nx = uy * vz - uz * vy, ny = uz * vx - ux * vz, nz = ux * vy - uy * vx;
+            var length = Math.sqrt(nx * nx + ny * ny + nz * nz) || 1;
+            return {
+                points: p,
+                depth: p[0][2] + p[1][2] + p[2][2],
+                light: Math.abs(nz / length)
+            };
+        });
+        faces.sort(function (a, b) {
+            return a.depth - b.depth;
+        });
+
+        ctx.clearRect(0, 0, canvas.width, canvas.height);
+        faces.forEach(function (f) {
+            var shade = Math.round(80 + 150 * f.light);
+            ctx.fillStyle = ctx.strokeStyle = 'rgb(' + Math.round(shade * 0.4) + ',' + Math.round(shade * 0.6) + ',' + shade + ')';
+            ctx.beginPath();
+            f.points.forEach(function (p, k) {
+                var x = canvas.width / 2 + p[0] * scale, y = canvas.height / 2 - p[1] * scale;
+                if (k === 0) {
+                    ctx.moveTo(x, y);
+                } else {
+                    ctx.lineTo(x, y);
+                }
+            });
+            ctx.closePath();
+            ctx.fill();
+            ctx.stroke();
+        });
+    }
+    function redraw() {
+        if (!pending) {
+            pending = true;
+            window.requestAnimationFrame(draw);
+        }
+    }
+
+    var dragging = null;
+    $(canvas).on('mousedown', function (e) {
+        dragging = {x: e.clientX, y: e.clientY};
+        e.preventDefault();
+    }).on('wheel', function (e) {
+        zoom *= e.originalEvent.deltaY < 0 ? 1.1 : 1 / 1.1;
+        redraw();
+        e.preventDefault();
+    });
+    $(document).on('mousemove', function (e) {
+        if (dragging) {
+            yaw += (e.clientX - dragging.x) * 0.01;
+            pitch += (e.clientY - dragging.y) * 0.01;
+            dragging = {x: e.clientX, y: e.clientY};
+            redraw();
+        }
+    }).on('mouseup', function () {
+        dragging = null;
+    });
+    draw();
+}
+
+// renderGeoJSONViewer draws geometries of GeoJSON object as SVG in Web Mercator
+// projection, properties of features are shown as tooltips.
+function renderGeoJSONViewer($viewer, geojson) {
+    var svgNS = 'http://www.w3.org/2000/svg';
+    var shapes = [];
+
+    function project(c) {
+        var lat = Math.max(Math.min(c[1], 85), -85) * Math.PI / 180;
+        return [c[0], Math.log(Math.tan(Math.PI / 4 + lat / 2)) * 180 / Math.PI];
+    }
+    function collect(geometry, title) {
+        if (!geometry) {
+            return;
+        }
+        var c = geometry.coordinates;
+        switch (geometry.type) {
+            case 'Point':
+                shapes.push({type: 'point', rings: [[project(c)]], title: title});
+                break;
+            case 'MultiPoint':
+                c.forEach(function (p) {
+                    shapes.push({type: 'point', rings: [[project(p)]], title: title});
+                });
+                break;
+            case 'LineString':
+                shapes.push({type: 'line', rings: [c.map(project)], title: title});
+                break;
+            case 'MultiLineString':
+                shapes.push({type: 'line', rings: c.map(function (l) { return l.map(project); }), title: title});
+                break;
+            case 'Polygon':
+                shapes.push({type: 'polygon', rings: c.map(function (r) { return r.map(project); }), title: title});
+                break;
+            case 'MultiPolygon':
+                c.forEach(function (polygon) {
+                    shapes.push({type: 'polygon', rings: polygon.map(function (r) { return r.map(project); }), title: title});
+                });
+                break;
+            case 'GeometryCollection':
+                geometry.geometries.forEach(function (g) {
+                    collect(g, title);
+                });
+                break;
+        }
+    }
+    function collectObject(obj) {
+        switch (obj.type) {
+            case 'FeatureCollection':
+                obj.features.forEach(collectObject);
+                break;
+            case 'Feature':
+                collect(obj.geometry, obj.properties ? JSON.stringify(obj.properties, null, 2) : '');
+                break;
+            default:
+                collect(obj, '');
+        }
+    }
+    collectObject(geojson);
+    if (shapes.length === 0) {
+        throw new Error('no geometry');
+    }
+
+    var min = [Infinity, Infinity], max = [-Infinity, -Infinity];
+    shapes.forEach(function (s) {
+        s.rings.forEach(function (r) {
+            r.forEach(function (p) {
+                min = [Math.min(min[0], p[0]), Math.min(min[1], p[1])];
+                max = [Math.max(max[0], p[0]), Math.max(max[1], p[1])];
+            });
+        });
+    });
+    var width = $viewer.width(), height = 500, padding = 20;
+    var scale = Math.min((width - padding * 2) / (max[0] - min[0] || 1), (height - padding * 2) / (max[1] - min[1] || 1));
+    var offsetX = (width - (max[0] - min[0]) * scale) / 2, offsetY = (height - (max[1] - min[1]) * scale) / 2;
+    function point(p) {
+        return (offsetX + (p[0] - min[0]) * scale).toFixed(2) + ',' + (height - offsetY - (p[1] - min[1]) * scale).toFixed(2);
+    }
+
+    var svg = $viewer.find('svg')[0];
+    svg.setAttribute('width', width);
+    svg.setAttribute('height', height);
+    shapes.forEach(function (s) {
+        var el;
+        if (s.type === 'point') {
+            var xy = point(s.rings[0][0]).split(',');
+            el = document.createElementNS(svgNS, 'circle');
+            el.setAttribute('cx', xy[0]);
+            el.setAttribute('cy', xy[1]);
+            el.setAttribute('r', 5);
+        } else {
+            el = document.createElementNS(svgNS, 'path');
+            el.setAttribute('d', s.rings.map(function (r) {
+                return 'M' + r.map(point).join('L') + (s.type === 'polygon' ? 'Z' : '');
+            }).join(''));
+        }
+        el.setAttribute('class', s.type);
+        if (s.title) {
+            var title = document.createElementNS(svgNS, 'title');
+            title.textContent = s.title;
+            el.appendChild(title);
+        }
+        svg.appendChild(el);
+    });
+}
+
+function initFileViewers() {
+    $('.stl-viewer').each(function () {
+        var $viewer = $(this);
+        var xhr = new XMLHttpRequest();
+        xhr.open('GET', $viewer.data('url'));
+        xhr.responseType = 'arraybuffer';
+        xhr.onload = function () {
+            try {
+                if (xhr.status !== 200) {
+                    throw new Error(xhr.statusText);
+                }
+                var triangles = parseSTL(xhr.response);
+                if (triangles.length === 0) {
+                    throw new Error('no triangle');
+                }
+                renderSTLViewer($viewer, triangles);
+            } catch (e) {
+                $viewer.find('canvas').remove();
+                $viewer.find('.render-failed').removeClass('hide');
+            }
+        };
+        xhr.send();
+    });
+
+    $('.geojson-viewer').each(function () {
+        var $viewer = $(this);
+        $.getJSON($viewer.data('url'), function (geojson) {
+            try {
+                renderGeoJSONViewer($viewer, geojson);
+            } catch (e) {
+                $viewer.find('svg').remove();
+                $viewer.find('.render-failed').removeClass('hide');
+            }
+        }).fail(function () {
+            $viewer.find('svg').remove();
+            $viewer.find('.render-failed').removeClass('hide');
+        });
+    });
+}
+
+// initImageDiff enables comparison modes of changed images, where stacked images of
+// swipe and onion skin modes are scaled by the same ratio to fit the view.
+function initImageDiff() {
+    $('.image-diff').each(function () {
+        var $diff = $(this);
+
+        function layout() {
+            $diff.find('.image-diff-stack:visible').each(function () {
+                var $stack = $(this);
+                var images = $stack.children('img').get();
+                var width = Math.max(images[0].naturalWidth, images[1].naturalWidth);
+                var height = Math.max(images[0].naturalHeight, images[1].naturalHeight);
+                if (!width || !height) {
+                    return;
+                }
+                var ratio = Math.min(1, $stack.parent().width() / width);
+                $stack.css({width: width * ratio, height: height * ratio});
+                images.forEach(function (img) {
+                    $(img).css({width: img.naturalWidth * ratio, height: img.naturalHeight * ratio});
+                });
+            });
+            $diff.find('.swipe input, .onion-skin input').trigger('input');
+        }
+
+        $diff.find('.menu .item').click(function () {
+            var $item = $(this);
+            $item.addClass('active').siblings().removeClass('active');
+            $diff.find('.image-diff-view').addClass('hide').filter('.' + $item.data('mode')).removeClass('hide');
+            layout();
+        });
+        $diff.find('.side-by-side img').on('load', function () {
+            $(this).siblings('p').find('.dimensions').text(this.naturalWidth + ' × ' + this.naturalHeight);
+        });
+        $diff.find('img').on('load', layout).each(function () {
+            if (this.complete) {
+                $(this).trigger('load');
+            }
+        });
+
+        // The part of the new image on the left of the handle is clipped to reveal the old image.
+        $diff.find('.swipe input').on('input', function () {
+            var $stack = $(this).siblings('.image-diff-stack');
+            $stack.children('.after').css('clip-path', 'inset(0 0 0 ' + ($stack.width() * this.value / 100) + 'px)');
+        });
+        $diff.find('.onion-skin input').on('input', function () {
+            $(this).siblings('.image-diff-stack').children('.after').css('opacity', this.value / 100);
+        });
+    });
 }
 
 function initUserSettings() {
@@ -1152,6 +1843,103 @@ function initWebhookSettings() {
     });
 }
 
+// Renders open and closed issues of a milestone over time as an SVG line chart.
+function renderBurndown(progress) {
+    var width = 600, height = 120, padding = 5;
+    var max = 1;
+    $.each(progress, function (_, p) {
+        max = Math.max(max, p.open, p.closed);
+    });
+
+    var points = function (key) {
+        return $.map(progress, function (p, i) {
+            var x = progress.length > 1 ? padding + i * (width - 2 * padding) / (progress.length - 1) : width / 2;
+            var y = height - padding - p[key] * (height - 2 * padding) / max;
+            return x.toFixed(1) + ',' + y.toFixed(1);
+        }).join(' ');
+    };
+
+    var first = progress[0].date.substr(0, 10), last = progress[progress.length - 1].date.substr(0, 10);
+    return '<svg width="100%" height="' + height + '" viewBox="0 0 ' + width + ' ' + height + '" preserveAspectRatio="none">' +
+        '<polyline class="open" points="' + points('open') + '"/>' +
+        '<polyline class="closed" points="' + points('closed') + '"/>' +
+        '</svg>' +
+        '<div class="dates"><span>' + first + '</span><span class="right">' + last + '</span></div>';
+}
+
+// Renders numbers of stars of a repository over time as an SVG line chart.
+function renderStarHistory(history) {
+    var width = 600, height = 120, padding = 5;
+    var max = 1;
+    $.each(history, function (_, h) {
+        max = Math.max(max, h.stars);
+    });
+
+    var points = $.map(history, function (h, i) {
+        var x = history.length > 1 ? padding + i * (width - 2 * padding) / (history.length - 1) : width / 2;
+        var y = height - padding - h.stars * (height - 2 * padding) / max;
+        return x.toFixed(1) + ',' + y.toFixed(1);
+    }).join(' ');
+
+    var first = history[0].date.substr(0, 10), last = history[history.length - 1].date.substr(0, 10);
+    return '<svg width="100%" height="' + height + '" viewBox="0 0 ' + width + ' ' + height + '" preserveAspectRatio="none">' +
+        '<polyline points="' + points + '"/>' +
+        '</svg>' +
+        '<div class="dates"><span>' + first + '</span><span class="right">' + max + ' <i class="octicon octicon-star"></i> · ' + last + '</span></div>';
+}
+
+// Fills weeks without commits between first and last week (Unix timestamps) with zeros.
+function denseWeeks(weeks, first, last) {
+    var counts = {};
+    $.each(weeks, function (_, w) {
+        counts[w.w] = w.c;
+    });
+    var dense = [];
+    for (var week = first; week <= last; week += 7 * 24 * 3600) {
+        dense.push({week: week, total: counts[week] || 0});
+    }
+    return dense;
+}
+
+// Renders weekly statistics of a repository as an SVG bar chart, code frequency is drawn
+// with additions above and deletions below the axis.
+function renderGraph(kind, data, small) {
+    var width = 600, height = small ? 40 : 150, padding = 5;
+    var upKey = kind === 'frequency' ? 'additions' : 'total';
+    var downKey = kind === 'frequency' ? 'deletions' : null;
+
+    var maxUp = 1, maxDown = 0;
+    $.each(data, function (_, d) {
+        maxUp = Math.max(maxUp, d[upKey]);
+        if (downKey) {
+            maxDown = Math.max(maxDown, d[downKey]);
+        }
+    });
+    // Shares the height between additions and deletions by proportion.
+    var axis = padding + (height - 2 * padding) * maxUp / (maxUp + maxDown);
+    var scale = (height - 2 * padding) / (maxUp + maxDown);
+
+    var barWidth = (width - 2 * padding) / data.length;
+    var bars = $.map(data, function (d, i) {
+        var x = (padding + i * barWidth).toFixed(1), w = Math.max(barWidth - 1, 1).toFixed(1);
+        var up = d[upKey] * scale;
+        var rects = '<rect class="up" x="' + x + '" y="' + (axis - up).toFixed(1) + '" width="' + w + '" height="' + up.toFixed(1) + '"/>';
+        if (downKey) {
+            rects += '<rect class="down" x="' + x + '" y="' + axis.toFixed(1) + '" width="' + w + '" height="' + (d[downKey] * scale).toFixed(1) + '"/>';
+        }
+        return rects;
+    }).join('');
+
+    var html = '<svg width="100%" height="' + height + '" viewBox="0 0 ' + width + ' ' + height + '" preserveAspectRatio="none">' + bars + '</svg>';
+    if (!small) {
+        var date = function (week) {
+            return new Date(week * 1000).toISOString().substr(0, 10);
+        };
+        html += '<div class="dates"><span>' + date(data[0].week) + '</span><span class="right">' + date(data[data.length - 1].week) + '</span></div>';
+    }
+    return html;
+}
+
 $(document).ready(function () {
     csrf = $('meta[name=_csrf]').attr("content");
     suburl = $('meta[name=_suburl]').attr("content");
@@ -1248,6 +2036,9 @@ $(document).ready(function () {
         emojify.run(hasEmoji[i]);
     }
 
+    // Math and diagrams
+    renderMathAndDiagrams(document);
+
     // Clipboard JS
     var clipboard = new ClipboardJS('.clipboard');
     clipboard.on('success', function (e) {
@@ -1374,6 +2165,7 @@ $(document).ready(function () {
     searchRepositories();
 
     initCommentForm();
+    initMentionAutocomplete();
     initRepository();
     initWikiForm();
     initEditForm();
@@ -1381,6 +2173,8 @@ $(document).ready(function () {
     initOrganization();
     initAdmin();
     initCodeView();
+    initFileViewers();
+    initImageDiff();
 
     // Repo clone url.
     if ($('#repo-clone-url').length > 0) {